@@ -1,32 +1,97 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"perlc/pkg/ast"
+	"perlc/pkg/cache"
 	"perlc/pkg/codegen"
+	"perlc/pkg/deps"
+	"perlc/pkg/doctest"
 	"perlc/pkg/eval"
+	"perlc/pkg/features"
 	"perlc/pkg/lexer"
 	"perlc/pkg/parser"
+	"perlc/pkg/sv"
+	"perlc/pkg/translate"
+	"perlc/pkg/tunables"
+	"perlc/pkg/version"
 )
 
 func main() {
 	compile := flag.Bool("c", false, "Compile to Go code")
 	output := flag.String("o", "", "Output file name")
 	run := flag.Bool("r", false, "Compile and run")
+	optimize := flag.Bool("O", false, "Optimize generated code (eq chains to switches)")
+	compatNumbers := flag.Bool("perl-compat-numbers", false, "Compute + - * / % ** exactly as perl 5 does: integer results while they fit, floored modulo, die on a zero divisor")
+	doc := flag.Bool("doctest", false, "Run the code examples in the POD as tests")
+	report := flag.Bool("report", false, "On an internal perlc error print a bug report (version, line, tokens, AST)")
+	showVersion := flag.Bool("version", false, "Print the perlc version, commit, Go version and Perl feature level")
+	stream := flag.Bool("stream", false, "Interpret statements as they are read (FILE or - for stdin), without parsing the whole program first")
+	useCache := flag.Bool("cache", false, "Interpret the program parsed into FILE.plc, parsing and saving it there when FILE has changed")
+	watch := flag.Bool("watch", false, "Interpret FILE and restart it, parsed again, on SIGHUP or when FILE changes")
+	showFeatures := flag.Bool("features", false, "Print which builtins the lexer, parser, interpreter and compiler support")
+	packages := flag.String("packages", "", "With -c or -r, write the program to `DIR` as a Go module with a Go package per Perl package, and build it there")
+	translateGo := flag.Bool("translate", false, "Experimental: translate FILE, written in the statically typed subset, to idiomatic Go (stdout or -o)")
+	tune := tunables.Default()
+	if err := tune.Load(os.Getenv); err != nil {
+		fmt.Fprintf(os.Stderr, "perlc: %v\n", err)
+		os.Exit(2)
+	}
+	tune.Flags(flag.CommandLine)
 	flag.Parse()
+	sv.SetPerlCompatNumbers(*compatNumbers)
+
+	if *showVersion {
+		fmt.Println(version.String())
+		fmt.Printf("Perl feature level %s ($^V %s, $] %s)\n", version.Perl, version.PerlVars["$^V"], version.PerlVars["$]"])
+		return
+	}
+
+	if *showFeatures {
+		printFeatures()
+		return
+	}
 
 	if flag.NArg() < 1 {
-		repl()
+		repl(tune)
 		return
 	}
 
+	if flag.Arg(0) == "deps" && flag.NArg() == 2 {
+		os.Exit(printDeps(flag.Arg(1)))
+	}
+	if flag.Arg(0) == "scan" && flag.NArg() == 2 {
+		os.Exit(scanFile(flag.Arg(1)))
+	}
+
 	filename := flag.Arg(0)
+	// the arguments after the script are its @ARGV
+	args := flag.Args()[1:]
+	if *watch {
+		if *compile || *run || *doc || *stream || *translateGo {
+			fmt.Fprintln(os.Stderr, "perlc: -watch works only in the interpreter")
+			os.Exit(2)
+		}
+		interpretWatch(filename, args, tune)
+		return
+	}
+	if *stream {
+		if *compile || *run || *doc || *translateGo {
+			fmt.Fprintln(os.Stderr, "perlc: -stream works only in the interpreter")
+			os.Exit(2)
+		}
+		interpretStream(filename, args, tune)
+		return
+	}
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
@@ -35,31 +100,120 @@ func main() {
 
 	input := string(data)
 
+	if *translateGo {
+		if *compile || *run || *doc {
+			fmt.Fprintln(os.Stderr, "perlc: -translate cannot be combined with -c, -r or -doctest")
+			os.Exit(2)
+		}
+		os.Exit(translateToGo(input, filename, *output))
+	}
+
+	if *doc {
+		os.Exit(runDoctest(input, filename))
+	}
+
 	if *compile || *run {
-		compileToGo(input, filename, *output, *run)
+		compileToGo(input, filename, args, *output, *packages, *run, *optimize, *compatNumbers, tune)
 	} else {
-		interpret(input)
+		interpret(input, filename, args, tune, *report, *useCache)
 	}
 }
 
-func interpret(input string) {
-	l := lexer.New(input)
+// translateToGo writes the program as Go source to outputName, or stdout
+func translateToGo(input, filename, outputName string) int {
+	l := lexer.NewFile(input, filename)
 	p := parser.New(l)
 	program := p.ParseProgram()
-
 	if len(p.Errors()) > 0 {
 		for _, e := range p.Errors() {
 			fmt.Fprintf(os.Stderr, "Parse error: %s\n", e)
 		}
-		os.Exit(1)
+		return 1
 	}
+	src, err := translate.Translate(program, filepath.Base(filename))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "perlc: %s: %v\n", filename, err)
+		return 1
+	}
+	if outputName == "" {
+		fmt.Print(src)
+		return 0
+	}
+	if err := os.WriteFile(outputName, []byte(src), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "perlc: %v\n", err)
+		return 1
+	}
+	return 0
+}
 
+// interpret runs the program in the interpreter; with report a panic of
+// perlc itself prints a crash report instead of a bare Go trace. With
+// useCache the parsed program is kept in a .plc file next to the script and
+// the next runs skip the parser while the script is unchanged.
+func interpret(input, filename string, args []string, tune tunables.Tunables, report, useCache bool) {
 	interp := eval.New()
-	interp.Eval(program)
+	interp.SetTunables(tune)
+	interp.SetProgram(filename, args)
+	run := func() {
+		var program *ast.Program
+		cached := false
+		if useCache {
+			program, cached = cache.LoadProgram(cache.ProgramPath(filename), []byte(input))
+		}
+		if !cached {
+			l := lexer.NewFile(input, filename)
+			p := parser.New(l)
+			program = p.ParseProgram()
+
+			if len(p.Errors()) > 0 {
+				for _, e := range p.Errors() {
+					fmt.Fprintf(os.Stderr, "Parse error: %s\n", e)
+				}
+				os.Exit(1)
+			}
+			if useCache {
+				// a cache that cannot be written only costs the next
+				// run its parse
+				cache.StoreProgram(cache.ProgramPath(filename), []byte(input), program)
+			}
+		}
+
+		interp.Eval(program)
+	}
+	if report {
+		reportCrash(os.Stderr, input, filename, interp.Statement, run)
+		return
+	}
+	run()
 }
 
-func compileToGo(input, filename, outputName string, runAfter bool) {
-	l := lexer.New(input)
+// interpretStream runs the program in the interpreter statement by
+// statement while it is read, so neither a huge file nor a long stream on
+// stdin (filename "-") has to be parsed whole first.
+func interpretStream(filename string, args []string, tune tunables.Tunables) {
+	in := os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	interp := eval.New()
+	interp.SetTunables(tune)
+	interp.SetProgram(filename, args)
+	if errs := interp.EvalStream(parser.New(lexer.NewReader(in, filename))); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "Parse error: %s\n", e)
+		}
+		os.Exit(1)
+	}
+}
+
+func compileToGo(input, filename string, args []string, outputName, packagesDir string, runAfter, optimize, compatNumbers bool, tune tunables.Tunables) {
+	l := lexer.NewFile(input, filename)
 	p := parser.New(l)
 	program := p.ParseProgram()
 
@@ -71,10 +225,37 @@ func compileToGo(input, filename, outputName string, runAfter bool) {
 	}
 
 	gen := codegen.New()
-	goCode := gen.Generate(program)
+	gen.Optimize = optimize
+	gen.PerlCompatNumbers = compatNumbers
+	gen.Tunables = tune
+	var files map[string]string
+	if packagesDir != "" {
+		var err error
+		files, err = gen.GeneratePackages(program)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "perlc: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		files = map[string]string{"main.go": gen.Generate(program)}
+	}
+	for _, w := range gen.Warnings {
+		fmt.Fprint(os.Stderr, w)
+	}
 
 	fmt.Println("=== Generated Go Code ===")
-	fmt.Println(goCode)
+	if len(files) == 1 {
+		fmt.Println(files["main.go"])
+	} else {
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("// %s\n%s\n", name, files[name])
+		}
+	}
 	fmt.Println("=== End Generated Code ===")
 
 	// Determine output filename
@@ -83,18 +264,24 @@ func compileToGo(input, filename, outputName string, runAfter bool) {
 		outputName = base
 	}
 
-	// Create temp directory for compilation
-	tmpDir, err := os.MkdirTemp("", "perlc-*")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating temp dir: %v\n", err)
+	// Build in a temp directory, or in the one of -packages, which is kept
+	// so that go build only compiles the packages that changed
+	buildDir := packagesDir
+	if buildDir == "" {
+		tmpDir, err := os.MkdirTemp("", "perlc-*")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temp dir: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tmpDir)
+		buildDir = tmpDir
+	} else if err := os.MkdirAll(buildDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", buildDir, err)
 		os.Exit(1)
 	}
-	defer os.RemoveAll(tmpDir)
-
-	goFile := filepath.Join(tmpDir, "main.go")
 
-	// Write Go file
-	err = os.WriteFile(goFile, []byte(goCode), 0644)
+	// Write the Go files, in a module with the runtime packages they import
+	err := codegen.WriteModule(buildDir, files)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing Go file: %v\n", err)
 		os.Exit(1)
@@ -109,7 +296,8 @@ func compileToGo(input, filename, outputName string, runAfter bool) {
 	// Get absolute path for output
 	absExe, _ := filepath.Abs(exeName)
 
-	cmd := exec.Command("go", "build", "-o", absExe, goFile)
+	cmd := exec.Command("go", "build", "-o", absExe, ".")
+	cmd.Dir = buildDir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()
@@ -123,30 +311,154 @@ func compileToGo(input, filename, outputName string, runAfter bool) {
 	// Run if requested
 	if runAfter {
 		fmt.Println("---")
-		cmd = exec.Command(absExe)
+		cmd = exec.Command(absExe, args...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Run()
 	}
 }
-func repl() {
-	fmt.Println("perlc REPL (type 'exit' to quit)")
+
+// runDoctest runs every example from the POD of a file in a separate
+// interpreter process, together with the code of the file, and prints the
+// results in TAP format. Returns the exit code: 1 if an example failed.
+func runDoctest(input, filename string) int {
+	examples := doctest.Extract(input)
+	if len(examples) == 0 {
+		fmt.Printf("1..0 # SKIP no examples in %s\n", filename)
+		return 0
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	tmpDir, err := os.MkdirTemp("", "perlc-doctest-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating temp dir: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fmt.Printf("1..%d\n", len(examples))
+	failed := 0
+	for n, ex := range examples {
+		script := filepath.Join(tmpDir, fmt.Sprintf("example%d.pl", n+1))
+		if err := os.WriteFile(script, []byte(doctest.Program(input, ex)), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing example: %v\n", err)
+			return 1
+		}
+
+		var stdout, stderr strings.Builder
+		cmd := exec.Command(self, script)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err == nil {
+			err = doctest.Check(ex, stdout.String())
+		}
+
+		if err == nil {
+			fmt.Printf("ok %d - %s\n", n+1, ex.Name())
+			continue
+		}
+		failed++
+		fmt.Printf("not ok %d - %s\n", n+1, ex.Name())
+		fmt.Printf("#   %v\n", err)
+		for _, line := range strings.Split(strings.TrimRight(stderr.String(), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("#   %s\n", line)
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("# failed %d of %d examples\n", failed, len(examples))
+		return 1
+	}
+	return 0
+}
+
+// printDeps prints the dependency tree of a script (perlc deps script.pl).
+// Returns 1 if a dependency can be satisfied neither by a local file nor by
+// a module built into perlc.
+func printDeps(filename string) int {
+	root, err := deps.Resolve(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	root.Print(os.Stdout)
+
+	summary := root.Summary()
+	fmt.Printf("\n%d local, %d built-in, %d missing\n",
+		len(summary[deps.Local]), len(summary[deps.Builtin]), len(summary[deps.Missing]))
+	if missing := summary[deps.Missing]; len(missing) > 0 {
+		fmt.Printf("missing: %s\n", strings.Join(missing, ", "))
+		return 1
+	}
+	return 0
+}
+
+// printFeatures prints the support matrix of the builtins.
+func printFeatures() {
+	all := features.All()
+	fmt.Print(features.Table(all))
+	supported := 0
+	for _, b := range all {
+		if b.Supported() {
+			supported++
+		}
+	}
+	fmt.Printf("\n%d builtins, %d supported everywhere, %d with gaps\n", len(all), supported, len(all)-supported)
+}
+
+// scanFile reports the builtins a script uses that perlc does not fully
+// support. Returns 1 if there are any.
+func scanFile(filename string) int {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+	uses := features.Scan(string(input))
+	for _, u := range uses {
+		fmt.Printf("%s: %s\n", filename, u)
+	}
+	if len(uses) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func repl(tune tunables.Tunables) {
+	fmt.Println("perlc REPL (type 'exit' to quit, ':depth N' to limit how deep results are shown)")
 	interp := eval.New()
+	interp.SetTunables(tune)
+	depth := 0
 
+	in := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Print("perl> ")
-		var input string
-		_, err := fmt.Scanln(&input)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+		if !in.Scan() {
+			break
+		}
+		input := strings.TrimSpace(in.Text())
+		if input == "" {
 			continue
 		}
-
 		if input == "exit" || input == "quit" {
 			break
 		}
+		if arg, ok := strings.CutPrefix(input, ":depth"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || n < 0 {
+				fmt.Println("Error: :depth takes a number, 0 for no limit")
+				continue
+			}
+			depth = n
+			continue
+		}
 
 		l := lexer.New(input)
 		p := parser.New(l)
@@ -159,8 +471,32 @@ func repl() {
 			continue
 		}
 
-		interp.Eval(program)
+		result := interp.Eval(program)
+		if echoes(program) {
+			fmt.Println(sv.Dump(result, depth))
+		}
+	}
+}
+
+// echoes reports whether the REPL shows the value of the line: it ends with
+// an expression that is not print, printf or say, which show themselves
+func echoes(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	stmt, ok := program.Statements[len(program.Statements)-1].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	if call, ok := stmt.Expression.(*ast.CallExpr); ok {
+		if id, ok := call.Function.(*ast.Identifier); ok {
+			switch id.Value {
+			case "print", "printf", "say":
+				return false
+			}
+		}
 	}
+	return true
 }
 
 func Run(input string) {
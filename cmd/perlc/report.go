@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/lexer"
+	"perlc/pkg/version"
+)
+
+// reportContext is how many source lines and tokens around the offending
+// line a crash report shows.
+const reportContext = 2
+
+// writeCrashReport writes the bug-report bundle for a panic of the
+// interpreter: perlc version, the panic, the offending line with the lines
+// and tokens around it, the AST of the statement that was running and the
+// Go stack. stmt is nil if the panic came before any statement ran.
+func writeCrashReport(w io.Writer, r any, stack []byte, input, filename string, stmt ast.Statement) {
+	fmt.Fprintln(w, "==================== perlc crash report ====================")
+	fmt.Fprintln(w, version.String())
+	fmt.Fprintf(w, "panic: %v\n", r)
+
+	pos, ok := ast.PosOf(stmt)
+	if !ok || pos.Line == 0 {
+		fmt.Fprintln(w, "\nNo statement was running (the panic came while parsing).")
+	} else {
+		fmt.Fprintf(w, "\n--- %s line %d ---\n", filename, pos.Line)
+		lines := strings.Split(input, "\n")
+		for n := max(pos.Line-reportContext, 1); n <= min(pos.Line+reportContext, len(lines)); n++ {
+			mark := " "
+			if n == pos.Line {
+				mark = ">"
+			}
+			fmt.Fprintf(w, "%s %4d | %s\n", mark, n, lines[n-1])
+		}
+
+		fmt.Fprintln(w, "\n--- tokens ---")
+		for _, tok := range tokensAround(input, pos.Line) {
+			fmt.Fprintf(w, "  %d:%d\t%q\n", tok.Line, tok.Column, tok.String())
+		}
+
+		fmt.Fprintln(w, "\n--- AST ---")
+		fmt.Fprint(w, ast.Dump(stmt))
+	}
+
+	fmt.Fprintf(w, "\n--- stack ---\n%s", stack)
+	fmt.Fprintln(w, "============================================================")
+	fmt.Fprintln(w, "Please attach this report and the script to an issue at")
+	fmt.Fprintln(w, "https://github.com/djeday123/perl-compiler/issues")
+}
+
+// tokensAround re-lexes the input and returns the tokens of the given line
+// and of the reportContext lines around it.
+func tokensAround(input string, line int) []lexer.Token {
+	var toks []lexer.Token
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != lexer.TokEOF; tok = l.NextToken() {
+		if tok.Line > line+reportContext {
+			break
+		}
+		if tok.Line >= line-reportContext && tok.Type != lexer.TokNewline {
+			toks = append(toks, tok)
+		}
+	}
+	return toks
+}
+
+// reportCrash runs fn and, if it panics, writes a crash report to w and
+// exits with status 70 (EX_SOFTWARE).
+func reportCrash(w io.Writer, input, filename string, stmt func() ast.Statement, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			writeCrashReport(w, r, debug.Stack(), input, filename, stmt())
+			os.Exit(70)
+		}
+	}()
+	fn()
+}
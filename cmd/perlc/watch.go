@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/eval"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/tunables"
+)
+
+// watchInterval is how often -watch looks at the script for changes
+const watchInterval = time.Second
+
+// interpretWatch runs the program in the interpreter and restarts it when
+// perlc gets SIGHUP or the file changes: the script is read and parsed
+// again and run from the start in a fresh interpreter. The process stays
+// the same, so descriptors it inherited (a listening socket passed as
+// stdin by inetd or systemd) stay open across reloads. A program with its
+// own $SIG{HUP} handler gets the signal instead. If the new version does
+// not parse, the errors are printed and perlc waits for the next change.
+func interpretWatch(filename string, args []string, tune tunables.Tunables) {
+	reload := make(chan struct{}, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			requestReload(reload)
+		}
+	}()
+	go watchFile(filename, reload)
+
+	for {
+		program, ok := parseScript(filename)
+		if !ok {
+			<-reload
+			continue
+		}
+		interp := eval.New()
+		interp.SetTunables(tune)
+		interp.SetProgram(filename, args)
+		interp.SetReload(true)
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-reload:
+					interp.Signal("HUP")
+				case <-done:
+					return
+				}
+			}
+		}()
+		restarted := runReloadable(interp, program)
+		close(done)
+		if !restarted {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "perlc: reloading %s\n", filename)
+	}
+}
+
+// requestReload asks for a reload; requests that come before the last one
+// is taken are merged into it
+func requestReload(reload chan<- struct{}) {
+	select {
+	case reload <- struct{}{}:
+	default:
+	}
+}
+
+// watchFile requests a reload whenever the modification time or size of
+// the file changes
+func watchFile(filename string, reload chan<- struct{}) {
+	var mod time.Time
+	var size int64
+	if info, err := os.Stat(filename); err == nil {
+		mod, size = info.ModTime(), info.Size()
+	}
+	for range time.Tick(watchInterval) {
+		info, err := os.Stat(filename)
+		if err != nil {
+			// being replaced by an editor; look again on the next tick
+			continue
+		}
+		if !info.ModTime().Equal(mod) || info.Size() != size {
+			mod, size = info.ModTime(), info.Size()
+			requestReload(reload)
+		}
+	}
+}
+
+// parseScript reads and parses the script, printing what went wrong
+func parseScript(filename string) (*ast.Program, bool) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return nil, false
+	}
+	p := parser.New(lexer.NewFile(string(data), filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "Parse error: %s\n", e)
+		}
+		return nil, false
+	}
+	return program, true
+}
+
+// runReloadable runs the program and reports whether it was stopped by a
+// reload rather than having finished
+func runReloadable(interp *eval.Interpreter, program *ast.Program) (restarted bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != eval.ErrReload {
+				panic(r)
+			}
+			restarted = true
+		}
+	}()
+	interp.Eval(program)
+	return false
+}
@@ -1,3 +1,3 @@
 module perlc
 
-go 1.23.0
+go 1.24.0
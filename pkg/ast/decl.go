@@ -70,6 +70,9 @@ func (sd *SubDecl) String() string {
 	if sd.Prototype != "" {
 		out.WriteString(fmt.Sprintf("(%s)", sd.Prototype))
 	}
+	if sd.Params != nil {
+		out.WriteString(signatureString(sd.Params))
+	}
 	for _, attr := range sd.Attributes {
 		out.WriteString(" :" + attr)
 	}
@@ -200,3 +203,44 @@ func (sb *SpecialBlock) TokenLiteral() string { return sb.Token.Value }
 func (sb *SpecialBlock) String() string {
 	return sb.Kind + " " + sb.Body.String()
 }
+
+// ============================================================
+// Formats
+// Formatlar
+// ============================================================
+
+// FormatDecl represents format NAME = ... . declaring a report layout
+// for write.
+// FormatDecl, write için bir rapor düzeni bildiren format NAME = ... . temsil eder.
+type FormatDecl struct {
+	Token lexer.Token
+	Name  string // "STDOUT" when omitted
+	Lines []*FormatLine
+}
+
+// FormatLine is one picture line and the values for its fields.
+// FormatLine, bir resim satırı ve alanlarının değerleridir.
+type FormatLine struct {
+	Picture string
+	Args    []Expression // nil for a literal line
+}
+
+func (fd *FormatDecl) statementNode()       {}
+func (fd *FormatDecl) declarationNode()     {}
+func (fd *FormatDecl) TokenLiteral() string { return fd.Token.Value }
+func (fd *FormatDecl) String() string {
+	var sb strings.Builder
+	sb.WriteString("format " + fd.Name + " =\n")
+	for _, line := range fd.Lines {
+		sb.WriteString(line.Picture + "\n")
+		if line.Args != nil {
+			args := make([]string, len(line.Args))
+			for i, arg := range line.Args {
+				args[i] = arg.String()
+			}
+			sb.WriteString(strings.Join(args, ", ") + "\n")
+		}
+	}
+	sb.WriteString(".")
+	return sb.String()
+}
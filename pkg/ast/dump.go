@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"perlc/pkg/lexer"
+)
+
+// maxDumpDepth stops Dump on very deep (or cyclic) trees.
+const maxDumpDepth = 64
+
+var tokenType = reflect.TypeOf(lexer.Token{})
+
+// Dump returns the node and its children as an indented tree, one node or
+// field per line. Tokens are left out; use PosOf for the location.
+// Dump, düğümü ve çocuklarını girintili bir ağaç olarak döndürür.
+func Dump(n Node) string {
+	var b strings.Builder
+	dumpValue(&b, reflect.ValueOf(n), "", 0)
+	return b.String()
+}
+
+func dumpValue(b *strings.Builder, v reflect.Value, label string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if label != "" {
+		label += ": "
+	}
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		fmt.Fprintf(b, "%s%snil\n", indent, label)
+		return
+	}
+	if depth >= maxDumpDepth {
+		fmt.Fprintf(b, "%s%s...\n", indent, label)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.Elem().Kind() != reflect.Struct {
+			dumpValue(b, v.Elem(), strings.TrimSuffix(label, ": "), depth)
+			return
+		}
+		fmt.Fprintf(b, "%s%s%s\n", indent, label, v.Type())
+		dumpFields(b, v.Elem(), depth+1)
+	case reflect.Struct:
+		fmt.Fprintf(b, "%s%s%s\n", indent, label, v.Type())
+		dumpFields(b, v, depth+1)
+	case reflect.Slice:
+		fmt.Fprintf(b, "%s%s[%d]\n", indent, label, v.Len())
+		for n := 0; n < v.Len(); n++ {
+			dumpValue(b, v.Index(n), fmt.Sprint(n), depth+1)
+		}
+	case reflect.String:
+		fmt.Fprintf(b, "%s%s%q\n", indent, label, v.String())
+	default:
+		fmt.Fprintf(b, "%s%s%v\n", indent, label, v.Interface())
+	}
+}
+
+// dumpFields writes the exported fields of a node struct, skipping tokens
+// and empty values
+func dumpFields(b *strings.Builder, v reflect.Value, depth int) {
+	for n := 0; n < v.NumField(); n++ {
+		f := v.Type().Field(n)
+		if !f.IsExported() || f.Type == tokenType || v.Field(n).IsZero() {
+			continue
+		}
+		dumpValue(b, v.Field(n), f.Name, depth)
+	}
+}
+
+// PosOf returns the position of the node's token; false if the node has
+// no Token field.
+// PosOf, düğümün tokeninin konumunu döndürür.
+func PosOf(n Node) (Position, bool) {
+	v := reflect.ValueOf(n)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return Position{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return Position{}, false
+	}
+	f := v.FieldByName("Token")
+	if !f.IsValid() || f.Type() != tokenType {
+		return Position{}, false
+	}
+	return FromToken(f.Interface().(lexer.Token)), true
+}
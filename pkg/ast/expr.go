@@ -63,6 +63,30 @@ func (rl *RegexLiteral) expressionNode()      {}
 func (rl *RegexLiteral) TokenLiteral() string { return rl.Token.Value }
 func (rl *RegexLiteral) String() string       { return fmt.Sprintf("/%s/%s", rl.Pattern, rl.Flags) }
 
+// QrExpr represents qr/pattern/flags, a compiled regex value.
+// QrExpr, derlenmiş bir regex değeri olan qr/pattern/flags'i temsil eder.
+type QrExpr struct {
+	Token   lexer.Token
+	Pattern string
+	Flags   string
+}
+
+func (qe *QrExpr) expressionNode()      {}
+func (qe *QrExpr) TokenLiteral() string { return qe.Token.Value }
+func (qe *QrExpr) String() string       { return fmt.Sprintf("qr/%s/%s", qe.Pattern, qe.Flags) }
+
+// CommandExpr represents `command` and qx/command/: the command line is
+// an interpolating string, run through the shell and its output captured.
+// CommandExpr, `komut` ve qx/komut/ ifadesini temsil eder.
+type CommandExpr struct {
+	Token   lexer.Token
+	Command *StringLiteral
+}
+
+func (ce *CommandExpr) expressionNode()      {}
+func (ce *CommandExpr) TokenLiteral() string { return ce.Token.Value }
+func (ce *CommandExpr) String() string       { return fmt.Sprintf("qx{%s}", ce.Command.Value) }
+
 // UndefLiteral represents undef.
 // UndefLiteral, undef'i temsil eder.
 type UndefLiteral struct {
@@ -267,6 +291,44 @@ func (ha *HashAccess) String() string {
 	return fmt.Sprintf("%s{%s}", ha.Hash.String(), ha.Key.String())
 }
 
+// ArraySlice represents @arr[LIST].
+// ArraySlice, @arr[LIST]'i temsil eder.
+type ArraySlice struct {
+	Token   lexer.Token
+	Array   Expression // ArrayVar or @-DerefExpr (@$ref[...])
+	Indices []Expression
+}
+
+func (as *ArraySlice) expressionNode()      {}
+func (as *ArraySlice) TokenLiteral() string { return as.Token.Value }
+func (as *ArraySlice) String() string {
+	return fmt.Sprintf("%s[%s]", as.Array.String(), joinExpressions(as.Indices))
+}
+
+// HashSlice represents @hash{LIST}.
+// HashSlice, @hash{LIST}'i temsil eder.
+type HashSlice struct {
+	Token lexer.Token
+	Hash  Expression // HashVar or %-DerefExpr (@$ref{...})
+	Keys  []Expression
+}
+
+func (hs *HashSlice) expressionNode()      {}
+func (hs *HashSlice) TokenLiteral() string { return hs.Token.Value }
+func (hs *HashSlice) String() string {
+	return fmt.Sprintf("@%s{%s}", strings.TrimPrefix(hs.Hash.String(), "%"), joinExpressions(hs.Keys))
+}
+
+// joinExpressions renders a comma-separated expression list.
+// joinExpressions, virgülle ayrılmış ifade listesini yazar.
+func joinExpressions(exprs []Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
 // ArrowAccess represents $ref->[index] or $ref->{key} or $obj->method.
 // ArrowAccess, $ref->[index], $ref->{key} veya $obj->method'u temsil eder.
 type ArrowAccess struct {
@@ -307,10 +369,11 @@ func (ce *CallExpr) String() string {
 // MethodCall represents $obj->method(args).
 // MethodCall, $obj->method(args)'ı temsil eder.
 type MethodCall struct {
-	Token  lexer.Token
-	Object Expression
-	Method string
-	Args   []Expression
+	Token   lexer.Token
+	Object  Expression
+	Method  string
+	Dynamic Expression // $obj->$name(): a method name or code ref in a scalar
+	Args    []Expression
 }
 
 func (mc *MethodCall) expressionNode()      {}
@@ -320,7 +383,11 @@ func (mc *MethodCall) String() string {
 	for i, a := range mc.Args {
 		args[i] = a.String()
 	}
-	return fmt.Sprintf("%s->%s(%s)", mc.Object.String(), mc.Method, strings.Join(args, ", "))
+	method := mc.Method
+	if mc.Dynamic != nil {
+		method = mc.Dynamic.String()
+	}
+	return fmt.Sprintf("%s->%s(%s)", mc.Object.String(), method, strings.Join(args, ", "))
 }
 
 // ============================================================
@@ -382,6 +449,20 @@ func (rl *ReadLineExpr) String() string {
 	return "<>"
 }
 
+// BlockFilehandle represents the {EXPR} of print {EXPR} LIST: an expression
+// whose value is the handle to print to.
+// BlockFilehandle, print {EXPR} LIST'teki {EXPR}'i temsil eder.
+type BlockFilehandle struct {
+	Token lexer.Token
+	Expr  Expression
+}
+
+func (bf *BlockFilehandle) expressionNode()      {}
+func (bf *BlockFilehandle) TokenLiteral() string { return bf.Token.Value }
+func (bf *BlockFilehandle) String() string {
+	return "{" + bf.Expr.String() + "}"
+}
+
 // RangeExpr represents $a .. $b or $a ... $b.
 // RangeExpr, $a .. $b veya $a ... $b'yi temsil eder.
 type RangeExpr struct {
@@ -445,9 +526,55 @@ type AnonSubExpr struct {
 func (as *AnonSubExpr) expressionNode()      {}
 func (as *AnonSubExpr) TokenLiteral() string { return as.Token.Value }
 func (as *AnonSubExpr) String() string {
+	if as.Params != nil {
+		return fmt.Sprintf("sub %s { %s }", signatureString(as.Params), as.Body.String())
+	}
 	return fmt.Sprintf("sub { %s }", as.Body.String())
 }
 
+// EvalBlockExpr represents eval { ... }.
+// EvalBlockExpr, eval { ... }'u temsil eder.
+type EvalBlockExpr struct {
+	Token lexer.Token
+	Body  *BlockStmt
+}
+
+func (eb *EvalBlockExpr) expressionNode()      {}
+func (eb *EvalBlockExpr) TokenLiteral() string { return eb.Token.Value }
+func (eb *EvalBlockExpr) String() string {
+	return fmt.Sprintf("eval { %s }", eb.Body.String())
+}
+
+// DoBlockExpr represents do { ... }: the value of its last statement.
+// DoBlockExpr, do { ... }'yu temsil eder: son deyiminin değeri.
+type DoBlockExpr struct {
+	Token lexer.Token
+	Body  *BlockStmt
+}
+
+func (db *DoBlockExpr) expressionNode()      {}
+func (db *DoBlockExpr) TokenLiteral() string { return db.Token.Value }
+func (db *DoBlockExpr) String() string {
+	return fmt.Sprintf("do { %s }", db.Body.String())
+}
+
+// EvalStringExpr represents eval EXPR: the code string is parsed at run time.
+// Code is nil for a bare eval, which evaluates $_.
+// EvalStringExpr, eval EXPR'yi temsil eder: kod dizesi çalışma zamanında ayrıştırılır.
+type EvalStringExpr struct {
+	Token lexer.Token
+	Code  Expression
+}
+
+func (es *EvalStringExpr) expressionNode()      {}
+func (es *EvalStringExpr) TokenLiteral() string { return es.Token.Value }
+func (es *EvalStringExpr) String() string {
+	if es.Code == nil {
+		return "eval"
+	}
+	return fmt.Sprintf("eval %s", es.Code.String())
+}
+
 // Param represents a subroutine parameter.
 // Param, bir altyordam parametresini temsil eder.
 type Param struct {
@@ -456,6 +583,23 @@ type Param struct {
 	Default Expression
 }
 
+func (p *Param) String() string {
+	if p.Default != nil {
+		return p.Sigil + p.Name + " = " + p.Default.String()
+	}
+	return p.Sigil + p.Name
+}
+
+// signatureString formats a signature: ($x, $y = 10, @rest).
+// signatureString, bir imzayı biçimlendirir: ($x, $y = 10, @rest).
+func signatureString(params []*Param) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.String()
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
 // ============================================================
 // Regex Expressions
 // Regex İfadeleri
@@ -463,11 +607,15 @@ type Param struct {
 
 // MatchExpr represents $str =~ /pattern/.
 // MatchExpr, $str =~ /pattern/'ı temsil eder.
+// When the right side is not a literal ($str =~ $re), Pattern is nil and
+// PatternExpr holds the expression (a qr// value or a string).
+// Sağ taraf literal değilse Pattern nil'dir ve PatternExpr ifadeyi tutar.
 type MatchExpr struct {
-	Token   lexer.Token
-	Target  Expression
-	Pattern *RegexLiteral
-	Negate  bool // !~
+	Token       lexer.Token
+	Target      Expression
+	Pattern     *RegexLiteral
+	PatternExpr Expression
+	Negate      bool // !~
 }
 
 func (me *MatchExpr) expressionNode()      {}
@@ -477,6 +625,9 @@ func (me *MatchExpr) String() string {
 	if me.Negate {
 		op = "!~"
 	}
+	if me.Pattern == nil && me.PatternExpr != nil {
+		return fmt.Sprintf("(%s %s %s)", me.Target.String(), op, me.PatternExpr.String())
+	}
 	return fmt.Sprintf("(%s %s %s)", me.Target.String(), op, me.Pattern.String())
 }
 
@@ -497,6 +648,25 @@ func (se *SubstExpr) String() string {
 		se.Target.String(), se.Pattern, se.Replacement, se.Flags)
 }
 
+// TransExpr represents $str =~ tr/search/replace/flags (also y///).
+// Search and Replace keep their escapes; the value is the number of
+// characters matched.
+// TransExpr, $str =~ tr/search/replace/flags'ı temsil eder (y/// de).
+type TransExpr struct {
+	Token   lexer.Token
+	Target  Expression
+	Search  string
+	Replace string
+	Flags   string
+}
+
+func (te *TransExpr) expressionNode()      {}
+func (te *TransExpr) TokenLiteral() string { return te.Token.Value }
+func (te *TransExpr) String() string {
+	return fmt.Sprintf("(%s =~ tr/%s/%s/%s)",
+		te.Target.String(), te.Search, te.Replace, te.Flags)
+}
+
 // ============================================================
 // Identifier
 // Tanımlayıcı
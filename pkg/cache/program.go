@@ -0,0 +1,153 @@
+// pkg/cache/program.go
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/version"
+)
+
+// programFormat changes whenever the layout of a .plc file does; the perlc
+// version is checked as well, for changes of the AST itself.
+const programFormat = 1
+
+// programFile is the content of a .plc file: the parsed program with what
+// it was parsed from.
+type programFile struct {
+	Format  int
+	Perlc   string
+	Source  [sha256.Size]byte
+	Program *ast.Program
+	Empty   []int // the empty slices, which gob turns into nil ones
+}
+
+func init() {
+	// the node types stored behind the Statement and Expression interfaces
+	for _, node := range []any{
+		&ast.IntegerLiteral{}, &ast.FloatLiteral{}, &ast.StringLiteral{}, &ast.RegexLiteral{},
+		&ast.QrExpr{}, &ast.CommandExpr{}, &ast.UndefLiteral{}, &ast.ScalarVar{}, &ast.ArrayVar{}, &ast.HashVar{},
+		&ast.CodeVar{}, &ast.GlobVar{}, &ast.ArrayLengthVar{}, &ast.SpecialVar{},
+		&ast.PrefixExpr{}, &ast.PostfixExpr{}, &ast.InfixExpr{}, &ast.TernaryExpr{},
+		&ast.AssignExpr{}, &ast.ArrayAccess{}, &ast.HashAccess{}, &ast.ArraySlice{},
+		&ast.HashSlice{}, &ast.ArrowAccess{}, &ast.CallExpr{}, &ast.MethodCall{},
+		&ast.ArrayExpr{}, &ast.HashExpr{}, &ast.HashPair{}, &ast.ReadLineExpr{},
+		&ast.RangeExpr{}, &ast.RefExpr{}, &ast.DerefExpr{}, &ast.AnonSubExpr{},
+		&ast.EvalBlockExpr{}, &ast.DoBlockExpr{}, &ast.EvalStringExpr{}, &ast.Param{},
+		&ast.MatchExpr{}, &ast.SubstExpr{}, &ast.TransExpr{}, &ast.Identifier{},
+		&ast.BlockStmt{}, &ast.ExprStmt{}, &ast.IfStmt{}, &ast.ElsifClause{},
+		&ast.WhileStmt{}, &ast.ForStmt{}, &ast.ForeachStmt{}, &ast.LastStmt{},
+		&ast.NextStmt{}, &ast.RedoStmt{}, &ast.ReturnStmt{}, &ast.ModifierStmt{},
+		&ast.DoStmt{}, &ast.EvalStmt{}, &ast.LabelStmt{}, &ast.GivenStmt{},
+		&ast.WhenClause{}, &ast.OpenStmt{}, &ast.CloseStmt{}, &ast.VarDecl{},
+		&ast.SubDecl{}, &ast.PackageDecl{}, &ast.UseDecl{}, &ast.NoDecl{},
+		&ast.RequireDecl{}, &ast.SpecialBlock{}, &ast.FormatDecl{},
+	} {
+		gob.Register(node)
+	}
+}
+
+// ProgramPath returns the cache file of a script: foo.pl keeps its parsed
+// program in foo.plc, next to it.
+func ProgramPath(script string) string {
+	return strings.TrimSuffix(script, filepath.Ext(script)) + ".plc"
+}
+
+// LoadProgram returns the program cached in path if it was parsed from src
+// by this version of perlc; false for a missing, stale or damaged file.
+func LoadProgram(path string, src []byte) (*ast.Program, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var pf programFile
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&pf); err != nil {
+		return nil, false
+	}
+	if pf.Format != programFormat || pf.Perlc != version.String() ||
+		pf.Source != sha256.Sum256(src) || pf.Program == nil {
+		return nil, false
+	}
+	// sub f() {} has Params but none of them: nil would be no signature
+	empty := map[int]bool{}
+	for _, n := range pf.Empty {
+		empty[n] = true
+	}
+	n := 0
+	eachSlice(reflect.ValueOf(pf.Program), func(v reflect.Value) {
+		if empty[n] {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+		n++
+	})
+	return pf.Program, true
+}
+
+// StoreProgram writes program, parsed from src, to path. The file is
+// replaced at once, so a concurrent run reads either the old or the new
+// one.
+func StoreProgram(path string, src []byte, program *ast.Program) error {
+	if program == nil {
+		return errors.New("no program to cache")
+	}
+	var buf bytes.Buffer
+	pf := programFile{Format: programFormat, Perlc: version.String(), Source: sha256.Sum256(src), Program: program}
+	n := 0
+	eachSlice(reflect.ValueOf(program), func(v reflect.Value) {
+		if !v.IsNil() && v.Len() == 0 {
+			pf.Empty = append(pf.Empty, n)
+		}
+		n++
+	})
+	if err := gob.NewEncoder(&buf).Encode(&pf); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp.Chmod(0644)
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// eachSlice calls fn for every slice field of the tree under v, in the
+// same order for a tree and its decoded copy
+func eachSlice(v reflect.Value, fn func(reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			eachSlice(v.Elem(), fn)
+		}
+	case reflect.Struct:
+		for n := 0; n < v.NumField(); n++ {
+			if v.Type().Field(n).IsExported() {
+				eachSlice(v.Field(n), fn)
+			}
+		}
+	case reflect.Slice:
+		fn(v)
+		for n := 0; n < v.Len(); n++ {
+			eachSlice(v.Index(n), fn)
+		}
+	}
+}
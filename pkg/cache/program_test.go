@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func parse(t *testing.T, src []byte, filename string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.NewFile(string(src), filename))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Skipf("%s: parse errors: %v", filename, errs)
+	}
+	return program
+}
+
+func TestProgramPath(t *testing.T) {
+	for script, want := range map[string]string{
+		"job.pl": "job.plc", "/srv/cron/job": "/srv/cron/job.plc", "a.b/run.t": "a.b/run.plc",
+	} {
+		if got := ProgramPath(script); got != want {
+			t.Errorf("ProgramPath(%q) = %q, want %q", script, got, want)
+		}
+	}
+}
+
+// TestProgramRoundTrip stores the parsed test scripts and checks that the
+// loaded programs are the same trees.
+func TestProgramRoundTrip(t *testing.T) {
+	scripts, _ := filepath.Glob("../../tests/quick/*.pl")
+	if len(scripts) == 0 {
+		t.Skip("no test scripts")
+	}
+	dir := t.TempDir()
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			src, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			program := parse(t, src, script)
+			path := filepath.Join(dir, ProgramPath(filepath.Base(script)))
+			if err := StoreProgram(path, src, program); err != nil {
+				t.Fatalf("StoreProgram: %v", err)
+			}
+			loaded, ok := LoadProgram(path, src)
+			if !ok {
+				t.Fatal("LoadProgram: not found")
+			}
+			if got, want := ast.Dump(loaded), ast.Dump(program); got != want {
+				t.Errorf("loaded tree differs:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadProgramStale(t *testing.T) {
+	src := []byte("my $x = 1;\nprint $x + 1, \"\\n\";\n")
+	path := filepath.Join(t.TempDir(), "x.plc")
+	if err := StoreProgram(path, src, parse(t, src, "x.pl")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LoadProgram(path, append(src, '#')); ok {
+		t.Error("a changed source loaded the old program")
+	}
+	if err := os.WriteFile(path, []byte("not a program"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LoadProgram(path, src); ok {
+		t.Error("a damaged file loaded")
+	}
+	if _, ok := LoadProgram(filepath.Join(t.TempDir(), "none.plc"), src); ok {
+		t.Error("a missing file loaded")
+	}
+}
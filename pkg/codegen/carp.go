@@ -0,0 +1,160 @@
+package codegen
+
+import (
+	"fmt"
+
+	"perlc/pkg/ast"
+)
+
+// carpFuncs are the functions of Carp: a plain use Carp imports croak,
+// carp and confess, cluck only by name
+var carpFuncs = map[string]bool{
+	"croak": true, "carp": true, "confess": true, "cluck": true,
+}
+
+// useCarp records the names imported by use Carp LIST. Once Carp is used
+// the program keeps a call stack: subs and evals push frames, and every
+// statement sets _here, where a call from it happens.
+func (g *Generator) useCarp(args []ast.Expression) {
+	if g.carp == nil {
+		g.carp = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		g.carp["croak"], g.carp["carp"], g.carp["confess"] = true, true, true
+		return
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if carpFuncs[name] {
+				g.carp[name] = true
+			}
+		}
+	}
+}
+
+// markStatement sets _here before a statement of a program using Carp, so
+// frames pushed by the calls in it know their caller
+func (g *Generator) markStatement() {
+	if g.carp == nil {
+		return
+	}
+	switch g.stmt.(type) {
+	case *ast.SubDecl, *ast.PackageDecl, *ast.UseDecl:
+		return
+	}
+	if where := g.where(); where != "" {
+		g.writeln(fmt.Sprintf("_here = _site{%q, %q}", g.pkg, where))
+	}
+}
+
+// pushFrame emits the frame of a sub (name) or of an eval (name empty,
+// text the code of eval STRING) at the top of its Go function
+func (g *Generator) pushFrame(name, text string, eval bool) {
+	if g.carp == nil {
+		return
+	}
+	if eval {
+		g.writeln(fmt.Sprintf("_pushFrame(_frame{eval: true, text: %q}); defer _popFrame()", text))
+		return
+	}
+	g.writeln(fmt.Sprintf("_pushFrame(_frame{sub: %q, args: args}); defer _popFrame()", name))
+}
+
+// writeCarpRuntime emits the call stack and Carp::croak, confess, carp and
+// cluck: croak and carp report where the current sub was called from the
+// first package that does not trust it (itself or @ISA kin), and turn into
+// confess and cluck when there is none; those add every frame as
+// "\tmain::f(1, "a") called at FILE line N".
+func (g *Generator) writeCarpRuntime() {
+	g.writeln(`// _site is the package and "FILE line N" of a statement
+type _site struct{ pkg, at string }
+
+// _frame is a sub or an eval on the call stack: what it is, and the
+// statement it was called from
+type _frame struct {
+	sub  string
+	args []*perlrt.SV
+	eval bool
+	text string
+	pkg  string
+	from _site
+}
+
+var _here _site
+var _frames []_frame
+
+func _pushFrame(f _frame) {
+	f.from, f.pkg = _here, _here.pkg
+	if n := len(_frames); n > 0 { f.pkg = _framePkg(_frames[n-1]) }
+	_frames = append(_frames, f)
+}
+
+// _popFrame leaves a frame: the caller is at its statement again
+func _popFrame() {
+	_here = _frames[len(_frames)-1].from
+	_frames = _frames[:len(_frames)-1]
+}
+
+// _framePkg is the package of the code running in f
+func _framePkg(f _frame) string {
+	if f.eval { return f.pkg }
+	if i := strings.LastIndex(f.sub, "::"); i >= 0 { return f.sub[:i] }
+	return "main"
+}`)
+	g.writeln("")
+	g.writeln(`func _carp(name, where string, args []*perlrt.SV) *perlrt.SV {
+	fatal := name == "Carp::croak" || name == "Carp::confess"
+	if fatal && len(args) == 1 && args[0].Flags&perlrt.SVf_POK == 0 && perlrt.RefAddr(args[0]) != 0 {
+		return perlrt.Perl_die(args[0])
+	}
+	msg := ""
+	for _, a := range args { msg += a.AsString() }
+	msg = _carpMessage(msg, where, name == "Carp::confess" || name == "Carp::cluck")
+	if fatal { return perlrt.Perl_die(perlrt.SvStr(msg)) }
+	fmt.Fprint(perlrt.Stderr, msg)
+	return perlrt.SvInt(1)
+}`)
+	g.writeln("")
+	g.writeln(`func _carpMessage(msg, where string, long bool) string {
+	if !long && len(_frames) > 0 {
+		pkg := _framePkg(_frames[len(_frames)-1])
+		for i := len(_frames) - 1; i >= 0; i-- {
+			f := _frames[i]
+			if !perlrt.Perl_isa_check(pkg, f.pkg).IsTrue() && !perlrt.Perl_isa_check(f.pkg, pkg).IsTrue() {
+				return msg + " at " + f.from.at + ".\n"
+			}
+		}
+	}
+	if where != "" { msg += " at " + where + "." }
+	msg += "\n"
+	for i := len(_frames) - 1; i >= 0; i-- {
+		f := _frames[i]
+		call := f.sub + "(" + _carpArgs(f.args) + ")"
+		switch {
+		case f.eval && f.text != "": call = "eval '" + f.text + "'"
+		case f.eval: call = "eval {...}"
+		}
+		msg += "\t" + call + " called at " + f.from.at + "\n"
+	}
+	return msg
+}`)
+	g.writeln("")
+	g.writeln(`// _carpArgs are the arguments of a frame as Carp writes them: numbers as
+// they are, strings quoted, undef as a word
+func _carpArgs(args []*perlrt.SV) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		s := a.AsString()
+		switch {
+		case perlrt.RefAddr(a) != 0 && a.Flags&perlrt.SVf_POK == 0, a.Flags&perlrt.SVf_POK == 0 && a.Flags&(perlrt.SVf_IOK|perlrt.SVf_NOK) != 0:
+		case a.Flags == 0:
+			s = "undef"
+		case !perlstr.LooksLikeNumber(s):
+			s = "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s) + "\""
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ", ")
+}`)
+	g.writeln("")
+}
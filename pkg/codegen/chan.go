@@ -0,0 +1,85 @@
+package codegen
+
+// writeChanRuntime emits Perlc::Chan and Perlc::spawn: a channel is a Go
+// channel behind a blessed hash, spawn starts the code ref in a goroutine
+// and Perlc::wait joins all of them. send copies scalars, so a task never
+// shares a scalar with its sender (references still share their target).
+// A die that no eval inside the task catches ends the program.
+func (g *Generator) writeChanRuntime() {
+	g.writeln(`type _Chan struct {
+	c    chan *perlrt.SV
+	once sync.Once
+}
+
+var _chans = map[*perlrt.SV]*_Chan{}
+var _chansMu sync.Mutex
+var _tasks sync.WaitGroup`)
+	g.writeln("")
+	g.writeln(`func _chanOf(obj *perlrt.SV) *_Chan {
+	_chansMu.Lock()
+	defer _chansMu.Unlock()
+	return _chans[obj]
+}`)
+	g.writeln("")
+	g.writeln(`func _chanMethod(method string, args []*perlrt.SV) *perlrt.SV {
+	if method == "new" {
+		size := 0
+		if len(args) > 1 { size = int(args[1].AsInt()) }
+		obj := perlrt.Perl_bless(perlrt.SvHash(), perlrt.SvStr("Perlc::Chan"))
+		_chansMu.Lock()
+		_chans[obj] = &_Chan{c: make(chan *perlrt.SV, size)}
+		_chansMu.Unlock()
+		return obj
+	}
+	ch := _chanOf(args[0])
+	if ch == nil { return perlrt.SvUndef() }
+	switch method {
+	case "send":
+		defer func() {
+			if recover() != nil { perlrt.Perl_die(perlrt.SvStr("send on closed channel")) }
+		}()
+		for _, v := range perlrt.ListCopy(perlrt.Flatten(args[1:])) { ch.c <- v }
+		return perlrt.SvInt(1)
+	case "recv":
+		if v, ok := <-ch.c; ok { return v }
+	case "close":
+		ch.once.Do(func() { close(ch.c) })
+		return perlrt.SvInt(1)
+	case "len":
+		return perlrt.SvInt(int64(len(ch.c)))
+	}
+	return perlrt.SvUndef()
+}`)
+	g.writeln("")
+	g.writeln(`func init() {
+	for _, m := range []string{"new", "send", "recv", "close", "len"} {
+		method := m
+		perlrt.Methods["Perlc::Chan_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _chanMethod(method, args) }
+	}
+}`)
+	g.writeln("")
+	g.writeln(`func perl_Perlc_spawn(args ...*perlrt.SV) *perlrt.SV {
+	if len(args) == 0 || args[0].CV == nil { return perlrt.Perl_die(perlrt.SvStr("Perlc::spawn needs a code reference")) }
+	code, rest := args[0], perlrt.ListCopy(perlrt.Flatten(args[1:]))
+	_tasks.Add(1)
+	go func() {
+		defer _tasks.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				d, ok := r.(perlrt.PerlDie)
+				if !ok { panic(r) }
+				fmt.Fprint(perlrt.Stderr, d.Msg)
+				perlrt.Exit(1)
+			}
+		}()
+		code.CV(rest...)
+	}()
+	return perlrt.SvInt(1)
+}`)
+	g.writeln("")
+	g.writeln(`func perl_Perlc_wait(args ...*perlrt.SV) *perlrt.SV {
+	_tasks.Wait()
+	return perlrt.SvInt(1)
+}`)
+	g.writeln("")
+}
@@ -0,0 +1,142 @@
+package codegen
+
+import (
+	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+	"perlc/pkg/lexer"
+)
+
+// Closures: an anonymous sub is a Go closure, which captures the *SV of
+// every lexical it uses, so each sub { } made in a loop sees the my of
+// its own iteration. A named sub is a Go function of the package and sees
+// no locals of main: the lexicals of the file level it uses
+// (my $count; sub incr { $count++ }) are package-level *SV variables
+// instead, which the my of main assigns. That is the one instance of the
+// lexical Perl gives a named sub.
+
+// captureLexicals notes the lexicals of the file level (its my, also in
+// bare blocks and package blocks) that the named subs use
+func (g *Generator) captureLexicals(stmts []ast.Statement, subs []*ast.SubDecl) {
+	used := make(map[string]bool)
+	for _, sub := range subs {
+		for name := range g.freeLexicals(sub) {
+			used[name] = true
+		}
+	}
+	g.captured = make(map[string]string)
+	g.capturedDecls = make(map[*ast.VarDecl]bool)
+	var scan func(stmts []ast.Statement)
+	scan = func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *ast.VarDecl:
+				if s.Kind != "my" {
+					continue
+				}
+				for _, v := range s.Names {
+					if name := g.declName(v, "my"); used[name] {
+						g.captured[name] = name[:2]
+						g.capturedDecls[s] = true
+					}
+				}
+			case *ast.BlockStmt:
+				scan(s.Statements)
+			case *ast.PackageDecl:
+				if s.Block != nil {
+					scan(s.Block.Statements)
+				}
+			}
+		}
+	}
+	scan(stmts)
+}
+
+// declareCaptured makes the my of captured lexicals assign the package
+// variables of them, in whatever block of main the my is
+func (g *Generator) declareCaptured(decl *ast.VarDecl) {
+	if !g.capturedDecls[decl] {
+		return
+	}
+	for _, v := range decl.Names {
+		if name := g.declName(v, "my"); g.captured[name] != "" {
+			g.declare(name)
+		}
+	}
+}
+
+// freeLexicals returns the Go names of the variables the body of sub uses
+// and does not declare itself: its parameters, the my of its top level
+// and the variables of its loops and closures are its own
+func (g *Generator) freeLexicals(sub *ast.SubDecl) map[string]bool {
+	own := make(map[string]bool)
+	params := func(list []*ast.Param) {
+		for _, p := range list {
+			switch p.Sigil {
+			case "@":
+				own["a_"+p.Name] = true
+			case "%":
+				own["h_"+p.Name] = true
+			default:
+				own["v_"+p.Name] = true
+			}
+		}
+	}
+	params(sub.Params)
+	for _, stmt := range sub.Body.Statements {
+		if decl, ok := stmt.(*ast.VarDecl); ok && decl.Kind == "my" {
+			for _, v := range decl.Names {
+				own[g.declName(v, "my")] = true
+			}
+		}
+	}
+
+	free := make(map[string]bool)
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ForeachStmt:
+			own[g.declName(n.Variable, "my")] = true
+		case *ast.ForStmt:
+			if decl, ok := n.Init.(*ast.VarDecl); ok && decl.Kind == "my" {
+				for _, v := range decl.Names {
+					own[g.declName(v, "my")] = true
+				}
+			}
+		case *ast.AnonSubExpr:
+			params(n.Params)
+		case *ast.ScalarVar, *ast.ArrayVar, *ast.HashVar:
+			free[g.declName(n.(ast.Expression), "my")] = true
+		case *ast.ArrayLengthVar:
+			free["a_"+n.Name] = true
+		case *ast.ArrayAccess:
+			// $x[0] is an element of @x
+			if sv, ok := n.Array.(*ast.ScalarVar); ok {
+				free["a_"+sv.Name] = true
+				walkNodes(n.Index, visit)
+				return false
+			}
+		case *ast.HashAccess:
+			if sv, ok := n.Hash.(*ast.ScalarVar); ok {
+				free["h_"+sv.Name] = true
+				walkNodes(n.Key, visit)
+				return false
+			}
+		case *ast.StringLiteral:
+			if n.Interpolated {
+				for _, seg := range interpolate.Parse(n.Value) {
+					walkNodes(seg.Expr, visit)
+				}
+			}
+		case *ast.SubstExpr:
+			for _, seg := range interpolate.Parse(lexer.SubstReplacement(n.Replacement)) {
+				walkNodes(seg.Expr, visit)
+			}
+		}
+		return true
+	}
+	walkNodes(sub.Body, visit)
+	for name := range own {
+		delete(free, name)
+	}
+	return free
+}
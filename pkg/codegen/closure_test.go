@@ -0,0 +1,42 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestCapturedLexicals(t *testing.T) {
+	program := parser.New(lexer.New(`my $step = 3;
+my @seen;
+my $i = 0;
+sub bump { my $v = shift; push @seen, $v; for (my $i = 0; $i < 2; $i++) { $v += $step; } return $v; }
+{
+    my $id = 0;
+    sub next_id { return ++$id; }
+}
+print bump(1), next_id(), $i;`)).ParseProgram()
+	code := New().Generate(program)
+	main := code[strings.Index(code, "func main()"):]
+
+	for _, want := range []string{
+		"var v_step = perlrt.SvUndef()", // the subs and main share the *SV
+		"var a_seen = perlrt.SvArray()",
+		"var v_id = perlrt.SvUndef()",
+		"func perl_next_id(", // declared in a bare block
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("code does not contain %s:\n%s", want, code)
+		}
+	}
+	for _, want := range []string{"v_step = perlrt.SvInt(3)", "v_id = perlrt.SvInt(0)", "n_i := int64(0)"} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main does not contain %s:\n%s", want, main)
+		}
+	}
+	if strings.Contains(code, "var v_i ") {
+		t.Error("$i of the loop in bump is captured")
+	}
+}
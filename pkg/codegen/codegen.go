@@ -3,9 +3,15 @@ package codegen
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/tunables"
+	"perlc/pkg/warnings"
 )
 
 // Generator generates Go code from AST.
@@ -13,20 +19,88 @@ type Generator struct {
 	output strings.Builder
 	indent int
 	//varCount  int
-	tempCount    int
-	declaredVars map[string]bool
+	tempCount  int
+	evalCount  int               // eval STRING counter for "(eval N)" in errors
+	scopes     []map[string]bool // lexical scopes being generated, innermost last
+	timePiece  bool              // use Time::Piece: scalar localtime/gmtime return objects
+	parallel   bool              // use perlc::parallel: parallel_map and parallel_foreach
+	develSize  bool              // use Devel::Size: size and total_size without the package
+	hiRes      map[string]bool   // names imported by use Time::HiRes
+	carp       map[string]bool   // names imported by use Carp; set, the program keeps a call stack
+	scalarUtil map[string]bool   // names imported by use Scalar::Util
+	listUtil   map[string]bool   // names imported by use List::Util
+	posix      map[string]bool   // names imported by use POSIX
+	getopt     map[string]bool   // names imported by use Getopt::Long and Getopt::Std
+	fileFuncs  map[string]bool   // names imported by use File::Basename, File::Path and Cwd
+	fileSpec   bool              // use File::Spec: its class methods
+	storable   map[string]bool   // names imported by use Storable
+	dbi        bool              // use DBI: its classes over database/sql
+	http       bool              // use HTTP::Tiny or LWP::UserAgent
+	threads    bool              // use threads or Thread::Queue
+	chans      bool              // Perlc::Chan or Perlc::spawn is used
+
+	constants     map[string]*constant      // use constant NAME => VALUE
+	constantNames []string                  // constants in declaration order
+	inlineSubs    map[string]ast.Expression // subs replaced by their body at call sites
+	inlineArgs    *inlineFrame              // arguments of the sub being inlined
+	natives       map[string]nativeKind     // scalars of the current function kept unboxed
+	label         string                    // label of the loop about to be generated
+	loops         []loop                    // enclosing loops, innermost last
+	marks         []string                  // save stack marks of the blocks with a local, see local.go
+	stmt          ast.Statement             // statement being generated, for warning locations
+	numericWarn   bool                      // a top-level use warnings turns on the numeric category
+	uninitWarn    bool                      // a top-level use warnings turns on the uninitialized category
+	miscOff       bool                      // no warnings turned off the misc category, for compile-time warnings
+	pkg           string                    // current package (package NAME;), where use overload registers
+	subPkgs       map[string]string         // full name of each sub to the package it is declared in
+	subNames      map[string]bool           // full names of the subs of the program and its modules
+	argWriters    map[string]bool           // subs that store into their @_, see writesArgs
+	imports       map[string]string         // subs imported by use: "pkg::name" to "Module::name"
+	modules       map[string]*module        // module files by %INC name, nil when not found
+	moduleOrder   []*module                 // the modules found, in the order they were loaded
+	libDirs       []string                  // directories of use lib, searched first
+	importErrors  map[*ast.UseDecl]string   // why a use cannot import what it asks for
+	loads         bool                      // use or require loads a module file; _load is needed
+	ours          map[string]string         // names our declared: the Go name of the lexical to its package variable
+	globals       map[string]string         // package variables used: Go name to its v_, a_ or h_ prefix
+	captured      map[string]string         // lexicals of the file level named subs use, package-level too
+	capturedDecls map[*ast.VarDecl]bool     // the my declaring them in main, which assigns them
+
+	// Optimize enables the -O transformations: if/elsif eq chains on one
+	// scalar become Go switches. Hash dispatch tables ($dispatch{$op}->())
+	// need none, they already are Go map lookups.
+	Optimize bool
+
+	// PerlCompatNumbers makes + - * / % ** and unary minus compute as perl
+	// does (perlstr's NumAdd and the rest) instead of with plain int64 and
+	// float64: division and modulus by zero die, and no scalar is native.
+	PerlCompatNumbers bool
+
+	// Tunables are the runtime knobs compiled into the program; PERLC_*
+	// variables still override them at startup.
+	Tunables tunables.Tunables
+
+	// Warnings are the compile-time warnings of the last Generate, as perl
+	// prints them: the run-time ones the generator can already prove, such
+	// as a hash assigned an odd list.
+	Warnings []string
 }
 
 // New creates a new Generator.
 func New() *Generator {
 	return &Generator{
-		declaredVars: make(map[string]bool),
+		scopes:     []map[string]bool{{}},
+		constants:  make(map[string]*constant),
+		inlineSubs: make(map[string]ast.Expression),
+		Tunables:   tunables.Default(),
 	}
 }
 
 // Generate generates Go code from a program.
 func (g *Generator) Generate(program *ast.Program) string {
 	g.output.Reset()
+	g.Warnings = nil
+	g.pkg = "main"
 
 	// Header
 	g.writeln("package main")
@@ -34,13 +108,31 @@ func (g *Generator) Generate(program *ast.Program) string {
 	g.writeln("import (")
 	g.indent++
 	g.writeln(`"bufio"`)
+	g.writeln(`"bytes"`)
+	g.writeln(`"encoding/binary"`)
 	g.writeln(`"fmt"`)
+	g.writeln(`"io"`)
 	g.writeln(`"math"`)
+	g.writeln(`"math/rand"`)
 	g.writeln(`"os"`)
+	g.writeln(`"os/exec"`)
+	g.writeln(`"os/user"`)
+	g.writeln(`"path/filepath"`)
+	g.writeln(`"reflect"`)
 	g.writeln(`"regexp"`)
+	g.writeln(`"runtime"`)
+	g.writeln(`"sort"`)
 	g.writeln(`"strconv"`)
 	g.writeln(`"strings"`)
+	g.writeln(`"sync"`)
+	g.writeln(`"sync/atomic"`)
+	g.writeln(`"time"`)
 	g.writeln(`"unicode"`)
+	g.writeln(`"unicode/utf8"`)
+	g.writeln("")
+	g.writeln(`"perlc/pkg/perlre"`)
+	g.writeln(`"perlc/pkg/perlrt"`)
+	g.writeln(`"perlc/pkg/perlstr"`)
 	g.indent--
 	g.writeln(")")
 	g.writeln("")
@@ -49,41 +141,111 @@ func (g *Generator) Generate(program *ast.Program) string {
 	g.writeln("var _ = fmt.Sprint")
 	g.writeln("var _ = strings.Join")
 	g.writeln("var _ = math.Abs")
+	g.writeln("var _ = rand.Shuffle")
 	g.writeln("var _ = regexp.Compile")
+	g.writeln("var _ = utf8.RuneLen")
+	g.writeln("var _ = runtime.GOMAXPROCS")
 	g.writeln("var _ = bufio.NewReader")
+	g.writeln("var _ = bytes.Index")
+	g.writeln("var _ = io.EOF")
+	g.writeln("var _ = binary.AppendUvarint")
+	g.writeln("var _ = reflect.ValueOf")
 	g.writeln("var _ = os.Stdin")
+	g.writeln("var _ = exec.Command")
+	g.writeln("var _ = user.Lookup")
+	g.writeln("var _ = filepath.Glob")
+	g.writeln("var _ = sort.Strings")
 	g.writeln("var _ = strconv.Atoi")
+	g.writeln("var _ = time.Now")
+	g.writeln("var _ sync.Mutex")
+	g.writeln("var _ atomic.Bool")
 	g.writeln("var _ = unicode.ToLower")
+	g.writeln("var _ = perlre.MustCompile")
+	g.writeln("var _ = perlstr.ParseNumber")
 	g.writeln("")
 
-	// Runtime types and functions
-	g.writeRuntime()
+	// SV, filehandles, method dispatch and the builtins are the perlrt
+	// package; the program only sets it up
+	g.writeSetup()
 
-	// Collect subroutine declarations first
-	var subs []*ast.SubDecl
+	// Collect subroutine declarations first, under their full names
+	g.subPkgs = make(map[string]string)
+	subs := collectSubs(program.Statements, "main", g.subPkgs)
 	var stmts []ast.Statement
 	for _, stmt := range program.Statements {
-		if sub, ok := stmt.(*ast.SubDecl); ok {
-			subs = append(subs, sub)
-		} else {
+		if _, ok := stmt.(*ast.SubDecl); !ok {
+			if use, ok := stmt.(*ast.UseDecl); ok {
+				g.noteUse(use)
+			}
 			stmts = append(stmts, stmt)
 		}
 	}
 
-	// Generate subroutines as Go functions
+	// The modules used are compiled in; their subs and imports are known
+	// before any code is generated
+	g.loadModules(program.Statements)
+	g.subNames = make(map[string]bool)
+	g.argWriters = make(map[string]bool)
+	for _, sub := range subs {
+		g.subNames[sub.Name] = true
+		g.argWriters[sub.Name] = writesArgs(sub.Body.Statements)
+	}
+	for _, m := range g.moduleOrder {
+		for _, sub := range m.subs {
+			g.subNames[sub.Name] = true
+			g.argWriters[sub.Name] = writesArgs(sub.Body.Statements)
+		}
+	}
+	for _, m := range g.moduleOrder {
+		g.ours = nil
+		g.collectOurs(m.stmts, "main")
+		m.ours = g.ours
+	}
+	g.ours = nil
+
+	if g.parallel {
+		g.writeParallelRuntime()
+	}
+	g.writeConstants()
+
+	// Small subs are inlined at their call sites, unless Carp needs
+	// their frames
+	for _, sub := range subs {
+		if body := g.inlineBody(sub); body != nil && g.carp == nil {
+			g.inlineSubs[sub.Name] = body
+		}
+	}
+
+	// Generate subroutines as Go functions; they see the our variables
+	// of the file level and the lexicals they capture
+	g.collectOurs(stmts, "main")
+	g.captureLexicals(stmts, subs)
 	for _, sub := range subs {
 		g.generateSubDecl(sub)
 		g.writeln("")
 	}
+	ours := g.ours
+	g.generateModules()
+	g.ours = ours
 
-	// Generate init function to register methods
+	// Generate init function to register methods and the @ISA arrays
 	g.writeln("func init() {")
 	g.indent++
+	g.writeln("perlrt.IsaArray = _isaArray")
+	for _, m := range g.moduleOrder {
+		subs = append(subs, m.subs...)
+	}
 	for _, sub := range subs {
-		// Register each subroutine as a potential method
+		// Register each subroutine as a potential method, under
+		// PACKAGE_NAME as perlrt.Perl_find_and_call looks it up
 		funcName := "perl_" + strings.ReplaceAll(sub.Name, "::", "_")
-		g.writeln(fmt.Sprintf("perl_register_method(%q, %s)", strings.ReplaceAll(sub.Name, "::", "_"), funcName))
+		key := sub.Name
+		if i := strings.LastIndex(key, "::"); i >= 0 {
+			key = key[:i] + "_" + key[i+2:]
+		}
+		g.writeln(fmt.Sprintf("perlrt.Perl_register_method(%q, %s)", key, funcName))
 	}
+	g.registerModules()
 	g.indent--
 	g.writeln("}")
 	g.writeln("")
@@ -91,7 +253,14 @@ func (g *Generator) Generate(program *ast.Program) string {
 	// Generate main function
 	g.writeln("func main() {")
 	g.indent++
+	g.writeln("defer perlrt.FlushAll()")
 
+	// The locals of the subs are not seen here
+	g.resetScopes()
+	g.natives = g.scalarKinds(stmts)
+	for name := range g.captured {
+		delete(g.natives, name[2:])
+	}
 	for _, stmt := range stmts {
 		g.generateStatement(stmt)
 	}
@@ -99,904 +268,73 @@ func (g *Generator) Generate(program *ast.Program) string {
 	g.indent--
 	g.writeln("}")
 
-	return g.output.String()
-}
-
-func (g *Generator) writeRuntime() {
-	g.writeln("// ============ Runtime ============")
-	g.writeln("")
-
-	// SV type
-	g.writeln("type SV struct {")
-	g.indent++
-	g.writeln("iv    int64")
-	g.writeln("nv    float64")
-	g.writeln("pv    string")
-	g.writeln("av    []*SV")
-	g.writeln("hv    map[string]*SV")
-	g.writeln("flags uint8")
-	g.indent--
-	g.writeln("}")
-	g.writeln("")
-
-	g.writeln("const (")
-	g.indent++
-	g.writeln("SVf_IOK uint8 = 1 << iota")
-	g.writeln("SVf_NOK")
-	g.writeln("SVf_POK")
-	g.writeln("SVf_AOK")
-	g.writeln("SVf_HOK")
-	g.indent--
-	g.writeln(")")
-	g.writeln("")
-
-	// g.writeln("var _ = bufio.NewReader") //- Move to generate
-
-	// Constructors
-	g.writeln("func svInt(i int64) *SV { return &SV{iv: i, flags: SVf_IOK} }")
-	g.writeln("func svFloat(f float64) *SV { return &SV{nv: f, flags: SVf_NOK} }")
-	g.writeln("func svStr(s string) *SV { return &SV{pv: s, flags: SVf_POK} }")
-	g.writeln("func svUndef() *SV { return &SV{} }")
-	g.writeln("func svArray(elems ...*SV) *SV { return &SV{av: elems, flags: SVf_AOK} }")
-	g.writeln("func svHash() *SV { return &SV{hv: make(map[string]*SV), flags: SVf_HOK} }")
-	g.writeln("")
-
-	// Converters
-	g.writeln(`func (sv *SV) AsInt() int64 {
-	if sv == nil { return 0 }
-	if sv.flags&SVf_IOK != 0 { return sv.iv }
-	if sv.flags&SVf_NOK != 0 { return int64(sv.nv) }
-	if sv.flags&SVf_POK != 0 { 
-		var i int64
-		fmt.Sscanf(sv.pv, "%d", &i)
-		return i
-	}
-	return 0
-}`)
-	g.writeln("")
-
-	g.writeln(`func (sv *SV) AsFloat() float64 {
-	if sv == nil { return 0 }
-	if sv.flags&SVf_NOK != 0 { return sv.nv }
-	if sv.flags&SVf_IOK != 0 { return float64(sv.iv) }
-	if sv.flags&SVf_POK != 0 {
-		var f float64
-		fmt.Sscanf(sv.pv, "%f", &f)
-		return f
-	}
-	return 0
-}`)
-	g.writeln("")
-
-	g.writeln(`func (sv *SV) AsString() string {
-	if sv == nil { return "" }
-	if sv.flags&SVf_POK != 0 { return sv.pv }
-	if sv.flags&SVf_IOK != 0 { return fmt.Sprintf("%d", sv.iv) }
-	if sv.flags&SVf_NOK != 0 { 
-		if sv.nv == float64(int64(sv.nv)) {
-			return fmt.Sprintf("%d", int64(sv.nv))
-		}
-		return fmt.Sprintf("%g", sv.nv)
-	}
-	return ""
-}`)
-	g.writeln("")
-
-	g.writeln(`func (sv *SV) IsTrue() bool {
-	if sv == nil { return false }
-	if sv.flags&SVf_IOK != 0 { return sv.iv != 0 }
-	if sv.flags&SVf_NOK != 0 { return sv.nv != 0 }
-	if sv.flags&SVf_POK != 0 { return sv.pv != "" && sv.pv != "0" }
-	if sv.flags&SVf_AOK != 0 { return len(sv.av) > 0 }
-	if sv.flags&SVf_HOK != 0 { return len(sv.hv) > 0 }
-	return false
-}`)
-	g.writeln("")
-
-	// Operations
-	g.writeln(`func svAdd(a, b *SV) *SV { 
-	if a.flags&SVf_IOK != 0 && b.flags&SVf_IOK != 0 {
-		return svInt(a.iv + b.iv)
-	}
-	return svFloat(a.AsFloat() + b.AsFloat()) 
-}`)
-
-	g.writeln(`func svSub(a, b *SV) *SV {
-	if a.flags&SVf_IOK != 0 && b.flags&SVf_IOK != 0 {
-		return svInt(a.iv - b.iv)
-	}
-	return svFloat(a.AsFloat() - b.AsFloat())
-}`)
-
-	g.writeln(`func svMul(a, b *SV) *SV {
-	if a.flags&SVf_IOK != 0 && b.flags&SVf_IOK != 0 {
-		return svInt(a.iv * b.iv)
-	}
-	return svFloat(a.AsFloat() * b.AsFloat())
-}`)
-
-	g.writeln("func svDiv(a, b *SV) *SV { return svFloat(a.AsFloat() / b.AsFloat()) }")
-	g.writeln("func svMod(a, b *SV) *SV { return svInt(a.AsInt() % b.AsInt()) }")
-	g.writeln("func svPow(a, b *SV) *SV { return svFloat(math.Pow(a.AsFloat(), b.AsFloat())) }")
-	g.writeln("func svConcat(a, b *SV) *SV { return svStr(a.AsString() + b.AsString()) }")
-	g.writeln("func svRepeat(s, n *SV) *SV { return svStr(strings.Repeat(s.AsString(), int(n.AsInt()))) }")
-	g.writeln("func svNeg(a *SV) *SV { return svFloat(-a.AsFloat()) }")
-	g.writeln("func svNot(a *SV) *SV { if a.IsTrue() { return svInt(0) }; return svInt(1) }")
-	g.writeln("")
-
-	// Comparisons
-	g.writeln("func svNumEq(a, b *SV) *SV { if a.AsFloat() == b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svNumNe(a, b *SV) *SV { if a.AsFloat() != b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svNumLt(a, b *SV) *SV { if a.AsFloat() < b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svNumLe(a, b *SV) *SV { if a.AsFloat() <= b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svNumGt(a, b *SV) *SV { if a.AsFloat() > b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svNumGe(a, b *SV) *SV { if a.AsFloat() >= b.AsFloat() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrEq(a, b *SV) *SV { if a.AsString() == b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrNe(a, b *SV) *SV { if a.AsString() != b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrLt(a, b *SV) *SV { if a.AsString() < b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrLe(a, b *SV) *SV { if a.AsString() <= b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrGt(a, b *SV) *SV { if a.AsString() > b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("func svStrGe(a, b *SV) *SV { if a.AsString() >= b.AsString() { return svInt(1) }; return svInt(0) }")
-	g.writeln("")
-
-	// Array ops
-	g.writeln(`func svAGet(arr *SV, idx *SV) *SV {
-	if arr == nil || arr.flags&SVf_AOK == 0 { return svUndef() }
-	i := int(idx.AsInt())
-	if i < 0 { i = len(arr.av) + i }
-	if i < 0 || i >= len(arr.av) { return svUndef() }
-	return arr.av[i]
-}`)
-	g.writeln("")
-
-	g.writeln(`func svASet(arr *SV, idx *SV, val *SV) *SV {
-	if arr == nil { return val }
-	i := int(idx.AsInt())
-	for len(arr.av) <= i { arr.av = append(arr.av, svUndef()) }
-	arr.av[i] = val
-	return val
-}`)
-	g.writeln("")
-
-	g.writeln(`func svPush(arr *SV, vals ...*SV) *SV {
-	arr.av = append(arr.av, vals...)
-	return svInt(int64(len(arr.av)))
-}`)
-	g.writeln("")
-
-	g.writeln(`func svPop(arr *SV) *SV {
-	if len(arr.av) == 0 { return svUndef() }
-	val := arr.av[len(arr.av)-1]
-	arr.av = arr.av[:len(arr.av)-1]
-	return val
-}`)
-	g.writeln("")
-
-	g.writeln(`func svShift(arr *SV) *SV {
-	if len(arr.av) == 0 { return svUndef() }
-	val := arr.av[0]
-	arr.av = arr.av[1:]
-	return val
-}`)
-	g.writeln("")
-
-	g.writeln(`func svUnshift(arr *SV, vals ...*SV) *SV {
-	arr.av = append(vals, arr.av...)
-	return svInt(int64(len(arr.av)))
-}`)
-	g.writeln("")
-
-	// Hash ops
-	g.writeln(`func svHGet(h *SV, key *SV) *SV {
-	if h == nil || h.hv == nil { return svUndef() }
-	if v, ok := h.hv[key.AsString()]; ok { return v }
-	return svUndef()
-}`)
-	g.writeln("")
-
-	g.writeln(`func svHSet(h *SV, key *SV, val *SV) *SV {
-	if h.hv == nil { h.hv = make(map[string]*SV); h.flags |= SVf_HOK }
-	h.hv[key.AsString()] = val
-	return val
-}`)
-	g.writeln("")
-
-	// Builtins
-	g.writeln(`func perlPrint(args ...*SV) *SV {
-	for _, a := range args { fmt.Print(a.AsString()) }
-	return svInt(1)
-}`)
-	g.writeln("")
-
-	g.writeln(`func perlSay(args ...*SV) *SV {
-	for _, a := range args { fmt.Print(a.AsString()) }
-	fmt.Println()
-	return svInt(1)
-}`)
-	g.writeln("")
-
-	g.writeln(`func perlLength(s *SV) *SV { return svInt(int64(len(s.AsString()))) }`)
-	g.writeln(`func perlUc(s *SV) *SV { return svStr(strings.ToUpper(s.AsString())) }`)
-	g.writeln(`func perlLc(s *SV) *SV { return svStr(strings.ToLower(s.AsString())) }`)
-	g.writeln(`func perlAbs(n *SV) *SV { return svFloat(math.Abs(n.AsFloat())) }`)
-	g.writeln(`func perlInt(n *SV) *SV { return svInt(n.AsInt()) }`)
-	g.writeln(`func perlSqrt(n *SV) *SV { return svFloat(math.Sqrt(n.AsFloat())) }`)
-	g.writeln(`func perlChr(n *SV) *SV { return svStr(string(rune(n.AsInt()))) }`)
-	g.writeln(`func perlOrd(s *SV) *SV { r := []rune(s.AsString()); if len(r) > 0 { return svInt(int64(r[0])) }; return svUndef() }`)
-	g.writeln("")
-
-	g.writeln(`func perl_scalar(sv *SV) *SV {
-		if sv == nil { return svInt(0) }
-		if sv.flags&SVf_AOK != 0 { return svInt(int64(len(sv.av))) }
-		if sv.flags&SVf_HOK != 0 { return svInt(int64(len(sv.hv))) }
-		return sv
-}`)
-	g.writeln(`func perl_keys(h *SV) *SV {
-		if h == nil || h.hv == nil { return svArray() }
-		var keys []*SV
-		for k := range h.hv { keys = append(keys, svStr(k)) }
-		return svArray(keys...)
-}`)
-	g.writeln(`func perl_join(sep, arr *SV) *SV {
-		if arr == nil { return svStr("") }
-		var parts []string
-		for _, el := range arr.av { parts = append(parts, el.AsString()) }
-		return svStr(strings.Join(parts, sep.AsString()))
-}`)
-	g.writeln("")
-
-	g.writeln(`// OOP Support
-var _blessedPkg = make(map[*SV]string)
-var _packageISA = make(map[string][]string)
-var _methods = make(map[string]func(args ...*SV) *SV)
-
-func perl_register_method(name string, fn func(args ...*SV) *SV) {
-	_methods[name] = fn
-}
-
-func perl_bless(ref, class *SV) *SV {
-	_blessedPkg[ref] = class.AsString()
-	return ref
-}
-
-func perl_ref(sv *SV) *SV {
-	if sv == nil { return svStr("") }
-	if pkg, ok := _blessedPkg[sv]; ok { return svStr(pkg) }
-	if sv.flags&0x80 != 0 { return svStr("SCALAR") }
-	if sv.flags&SVf_AOK != 0 { return svStr("ARRAY") }
-	if sv.flags&SVf_HOK != 0 { return svStr("HASH") }
-	return svStr("")
-}
-
-func perl_set_isa(child *SV, parents ...*SV) *SV {
-	childName := child.AsString()
-	var parentNames []string
-	for _, p := range parents {
-		parentNames = append(parentNames, p.AsString())
-	}
-	_packageISA[childName] = parentNames
-	return svInt(1)
-}
-
-func perl_method_call(obj *SV, method string, args ...*SV) *SV {
-	var pkg string
-	
-	// Check if obj is a class name (string) or blessed reference
-	if obj.flags&SVf_POK != 0 && _blessedPkg[obj] == "" {
-		// Class method call: Point->new()
-		pkg = obj.AsString()
-	} else if p, ok := _blessedPkg[obj]; ok {
-		// Instance method call: $obj->method()
-		pkg = p
-	} else {
-		return svUndef()
-	}
-	
-	// Search for method in class hierarchy
-	fullArgs := append([]*SV{obj}, args...)
-	return perl_find_and_call(pkg, method, fullArgs)
-}
-
-func perl_find_and_call(pkg, method string, args []*SV) *SV {
-	// Try this package first
-	key := pkg + "_" + method
-	if fn, ok := _methods[key]; ok {
-		return fn(args...)
-	}
-	
-	// Try parent classes
-	for _, parent := range _packageISA[pkg] {
-		result := perl_find_and_call(parent, method, args)
-		if result != nil {
-			return result
-		}
-	}
-	
-	return svUndef()
-}
-
-func perl_isa(obj, class *SV) *SV {
-	pkg, ok := _blessedPkg[obj]
-	if !ok { return svInt(0) }
-	target := class.AsString()
-	if pkg == target { return svInt(1) }
-	return perl_isa_check(pkg, target)
-}
-
-func perl_isa_check(pkg, target string) *SV {
-	if pkg == target { return svInt(1) }
-	for _, parent := range _packageISA[pkg] {
-		if perl_isa_check(parent, target).IsTrue() { return svInt(1) }
+	// Known only once the code is generated, so it goes after main
+	if g.chans {
+		g.writeln("")
+		g.writeChanRuntime()
 	}
-	return svInt(0)
-}`)
-	g.writeln("")
-	// Regex captures
-	g.writeln("var _captures []string")
-	g.writeln("")
-	g.writeln(`func _getCapture(n int) string {
-	if n < 1 || n > len(_captures) { return "" }
-	return _captures[n-1]
-}`)
-
-	g.writeln("")
-
-	// File I/O
-	// File I/O - добавь _filehandles ПЕРЕД perlOpen
-	g.writeln("var _filehandles = make(map[string]*_FileHandle)")
-	g.writeln("")
-	g.writeln(`type _FileHandle struct {
-	file    *os.File
-	scanner *bufio.Scanner
-	writer  *bufio.Writer
-}`)
-	g.writeln("")
-
-	g.writeln(`func perlOpen(name, mode, filename string) *SV {
-	var file *os.File
-	var err error
-	switch mode {
-	case "<", "r":
-		file, err = os.Open(filename)
-	case ">", "w":
-		file, err = os.Create(filename)
-	case ">>", "a":
-		file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	default:
-		file, err = os.Open(filename)
+	if g.carp != nil {
+		g.writeln("")
+		g.writeCarpRuntime()
 	}
-	if err != nil { return svInt(0) }
-	fh := &_FileHandle{file: file}
-	if mode == "<" || mode == "r" || mode == "" {
-		fh.scanner = bufio.NewScanner(file)
-	} else {
-		fh.writer = bufio.NewWriter(file)
+	if g.getopt != nil {
+		g.writeln("")
+		g.writeGetoptRuntime()
 	}
-	_filehandles[name] = fh
-	return svInt(1)
-}`)
-	g.writeln("")
-	g.writeln(`func perlClose(name string) *SV {
-	if fh, ok := _filehandles[name]; ok {
-		if fh.writer != nil { fh.writer.Flush() }
-		fh.file.Close()
-		delete(_filehandles, name)
-		return svInt(1)
-	}
-	return svInt(0)
-}`)
-	g.writeln("")
-	g.writeln(`func perlReadLine(name string) *SV {
-	if name == "" {
-		scanner := bufio.NewScanner(os.Stdin)
-		if scanner.Scan() { return svStr(scanner.Text() + "\n") }
-		return svUndef()
+	if g.storable != nil {
+		g.writeln("")
+		g.writeStorableRuntime()
 	}
-	if fh, ok := _filehandles[name]; ok && fh.scanner != nil {
-		if fh.scanner.Scan() { return svStr(fh.scanner.Text() + "\n") }
+	if g.fileFuncs != nil || g.fileSpec {
+		g.writeln("")
+		g.writeFileRuntime()
 	}
-	return svUndef()
-}`)
-	g.writeln("")
-
-	g.writeln(`func perlPrintFH(fhName string, args ...*SV) *SV {
-	if fh, ok := _filehandles[fhName]; ok && fh.writer != nil {
-		for _, a := range args { fh.writer.WriteString(a.AsString()) }
-		return svInt(1)
+	if g.dbi {
+		g.writeln("")
+		g.writeDBIRuntime()
 	}
-	return svInt(0)
-}`)
-	g.writeln("")
-	g.writeln(`func perlSayFH(fhName string, args ...*SV) *SV {
-	if fh, ok := _filehandles[fhName]; ok && fh.writer != nil {
-		for _, a := range args { fh.writer.WriteString(a.AsString()) }
-		fh.writer.WriteString("\n")
-		return svInt(1)
-	}
-	return svInt(0)
-}`)
-	g.writeln("")
-
-	// === НАЧАЛО ПАТЧА - добавить в writeRuntime() ===
-
-	// split
-	g.writeln(`func perl_split(sep, str *SV) *SV {
-	parts := strings.Split(str.AsString(), sep.AsString())
-	var result []*SV
-	for _, p := range parts {
-		result = append(result, svStr(p))
+	if g.http {
+		g.writeln("")
+		g.writeHTTPRuntime()
 	}
-	return svArray(result...)
-}`)
-	g.writeln("")
-
-	// reverse
-	g.writeln(`func perl_reverse(arr *SV) *SV {
-	if arr == nil || arr.flags&SVf_AOK == 0 { return svArray() }
-	n := len(arr.av)
-	result := make([]*SV, n)
-	for i := 0; i < n; i++ {
-		result[i] = arr.av[n-1-i]
+	if g.threads {
+		g.writeln("")
+		g.writeThreadsRuntime()
 	}
-	return svArray(result...)
-}`)
-	g.writeln("")
-
-	// sort
-	g.writeln(`func perl_sort(arr *SV) *SV {
-	if arr == nil || arr.flags&SVf_AOK == 0 { return svArray() }
-	result := make([]*SV, len(arr.av))
-	copy(result, arr.av)
-	for i := 0; i < len(result)-1; i++ {
-		for j := i+1; j < len(result); j++ {
-			if result[i].AsString() > result[j].AsString() {
-				result[i], result[j] = result[j], result[i]
-			}
-		}
+	if g.loads {
+		g.writeln("")
+		g.writeModulesRuntime()
 	}
-	return svArray(result...)
-}`)
-	g.writeln("")
-
-	// values
-	g.writeln(`func perl_values(h *SV) *SV {
-	if h == nil || h.hv == nil { return svArray() }
-	var vals []*SV
-	for _, v := range h.hv { vals = append(vals, v) }
-	return svArray(vals...)
-}`)
-	g.writeln("")
-
-	// exists
-	g.writeln(`func perl_exists(v *SV) *SV {
-	if v == nil || v.flags == 0 { return svInt(0) }
-	return svInt(1)
-}`)
 	g.writeln("")
-
-	// delete (для хеша - нужно передавать хеш и ключ)
-	g.writeln(`func perl_delete(v *SV) *SV {
-	return svUndef()
-}`)
-	g.writeln("")
-
-	// chomp
-	g.writeln(`func perl_chomp(sv *SV) *SV {
-	if sv == nil { return svInt(0) }
-	s := sv.pv
-	if len(s) > 0 && s[len(s)-1] == '\n' {
-		sv.pv = s[:len(s)-1]
-		return svInt(1)
+	g.writeISAArrays()
+	if len(g.globals) > 0 {
+		g.writeln("")
+		g.writeGlobals(g.globals)
 	}
-	return svInt(0)
-}`)
-	g.writeln("")
-
-	// defined
-	g.writeln(`func perl_defined(sv *SV) *SV {
-	if sv == nil || sv.flags == 0 { return svInt(0) }
-	return svInt(1)
-}`)
-	g.writeln("")
-
-	g.writeln(`func svRef(sv *SV) *SV {
-		return &SV{av: []*SV{sv}, flags: SVf_AOK | 0x80}
-	}`)
-	g.writeln("")
-
-	g.writeln(`func svDeref(ref *SV) *SV {
-		if ref != nil && len(ref.av) > 0 {
-			return ref.av[0]
-		}
-		return svUndef()
-	}`)
-	g.writeln("")
-
-	// === КОНЕЦ ПАТЧА ===
-
-	// ============================================================
-	// ПАТЧ 2: Добавить в writeHelperFunctions перед "// === КОНЕЦ ПАТЧА ==="
-	// (в pkg/codegen/codegen.go)
-	// ============================================================
-
-	// index
-	g.writeln(`func perl_index(str, substr *SV, args ...*SV) *SV {
-	s := str.AsString()
-	sub := substr.AsString()
-	start := 0
-	if len(args) > 0 {
-		start = int(args[0].AsInt())
-		if start < 0 { start = 0 }
-		if start > len(s) { return svInt(-1) }
-	}
-	pos := strings.Index(s[start:], sub)
-	if pos == -1 { return svInt(-1) }
-	return svInt(int64(pos + start))
-}`)
-	g.writeln("")
-
-	// rindex
-	g.writeln(`func perl_rindex(str, substr *SV, args ...*SV) *SV {
-	s := str.AsString()
-	sub := substr.AsString()
-	end := len(s)
-	if len(args) > 0 {
-		end = int(args[0].AsInt()) + len(sub)
-		if end > len(s) { end = len(s) }
-		if end < 0 { return svInt(-1) }
-	}
-	pos := strings.LastIndex(s[:end], sub)
-	return svInt(int64(pos))
-}`)
-	g.writeln("")
-
-	// lcfirst
-	g.writeln(`func perl_lcfirst(sv *SV) *SV {
-	s := sv.AsString()
-	if len(s) == 0 { return svStr("") }
-	r := []rune(s)
-	r[0] = unicode.ToLower(r[0])
-	return svStr(string(r))
-}`)
-	g.writeln("")
-
-	// ucfirst
-	g.writeln(`func perl_ucfirst(sv *SV) *SV {
-	s := sv.AsString()
-	if len(s) == 0 { return svStr("") }
-	r := []rune(s)
-	r[0] = unicode.ToUpper(r[0])
-	return svStr(string(r))
-}`)
-	g.writeln("")
-
-	// chop
-	g.writeln(`func perl_chop(sv *SV) *SV {
-	if sv == nil { return svStr("") }
-	s := sv.pv
-	if len(s) == 0 { return svStr("") }
-	r := []rune(s)
-	last := string(r[len(r)-1])
-	sv.pv = string(r[:len(r)-1])
-	return svStr(last)
-}`)
-	g.writeln("")
-
-	// sprintf
-	g.writeln(`func perl_sprintf(args ...*SV) *SV {
-	if len(args) == 0 { return svStr("") }
-	format := args[0].AsString()
-	fmtArgs := make([]interface{}, len(args)-1)
-	fmtIdx := 0
-	for idx, arg := range args[1:] {
-		for fmtIdx < len(format) {
-			if format[fmtIdx] == '%' {
-				fmtIdx++
-				if fmtIdx < len(format) && format[fmtIdx] == '%' {
-					fmtIdx++
-					continue
-				}
-				for fmtIdx < len(format) {
-					c := format[fmtIdx]
-					if c == '-' || c == '+' || c == ' ' || c == '#' || c == '0' ||
-						(c >= '0' && c <= '9') || c == '.' || c == '*' {
-						fmtIdx++
-					} else {
-						break
-					}
-				}
-				if fmtIdx < len(format) {
-					spec := format[fmtIdx]
-					fmtIdx++
-					switch spec {
-					case 'd', 'i', 'o', 'x', 'X', 'b', 'c':
-						fmtArgs[idx] = arg.AsInt()
-					case 'e', 'E', 'f', 'F', 'g', 'G':
-						fmtArgs[idx] = arg.AsFloat()
-					default:
-						fmtArgs[idx] = arg.AsString()
-					}
-					break
-				}
-			} else {
-				fmtIdx++
-			}
-		}
-		if fmtArgs[idx] == nil {
-			fmtArgs[idx] = arg.AsString()
-		}
+	if len(g.captured) > 0 {
+		g.writeln("")
+		g.writeGlobals(g.captured)
 	}
-	return svStr(fmt.Sprintf(format, fmtArgs...))
-}`)
-	g.writeln("")
-
-	// quotemeta
-	g.writeln(`func perl_quotemeta(sv *SV) *SV {
-	return svStr(regexp.QuoteMeta(sv.AsString()))
-}`)
-	g.writeln("")
 
-	// hex
-	g.writeln(`func perl_hex(sv *SV) *SV {
-	s := sv.AsString()
-	s = strings.TrimPrefix(s, "0x")
-	s = strings.TrimPrefix(s, "0X")
-	v, _ := strconv.ParseInt(s, 16, 64)
-	return svInt(v)
-}`)
-	g.writeln("")
-
-	// oct
-	g.writeln(`func perl_oct(sv *SV) *SV {
-	s := strings.TrimSpace(sv.AsString())
-	var v int64
-	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
-		v, _ = strconv.ParseInt(s[2:], 16, 64)
-	} else if strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B") {
-		v, _ = strconv.ParseInt(s[2:], 2, 64)
-	} else if strings.HasPrefix(s, "0") && len(s) > 1 {
-		v, _ = strconv.ParseInt(s[1:], 8, 64)
-	} else {
-		v, _ = strconv.ParseInt(s, 8, 64)
+	out := g.output.String()
+	if g.dbi {
+		out = addImports(out, "database/sql")
 	}
-	return svInt(v)
-}`)
-	g.writeln("")
-
-	// fc (fold case)
-	g.writeln(`func perl_fc(sv *SV) *SV {
-	return svStr(strings.ToLower(sv.AsString()))
-}`)
-	g.writeln("")
-
-	// pack (simplified)
-	g.writeln(`func perl_pack(args ...*SV) *SV {
-	if len(args) == 0 { return svStr("") }
-	template := args[0].AsString()
-	values := args[1:]
-	var buf []byte
-	valIdx := 0
-	for i := 0; i < len(template) && valIdx < len(values); i++ {
-		ch := template[i]
-		switch ch {
-		case 'A', 'a':
-			buf = append(buf, []byte(values[valIdx].AsString())...)
-			valIdx++
-		case 'C', 'c':
-			buf = append(buf, byte(values[valIdx].AsInt()))
-			valIdx++
-		case 'Z':
-			buf = append(buf, []byte(values[valIdx].AsString())...)
-			buf = append(buf, 0)
-			valIdx++
-		}
+	if g.http {
+		out = addImports(out, "crypto/tls", "net/http", "net/url")
 	}
-	return svStr(string(buf))
-}`)
-	g.writeln("")
+	return pruneRuntime(out)
+}
 
-	// unpack (simplified)
-	g.writeln(`func perl_unpack(args ...*SV) *SV {
-	if len(args) < 2 { return svArray() }
-	template := args[0].AsString()
-	data := []byte(args[1].AsString())
-	var results []*SV
-	offset := 0
-	for i := 0; i < len(template) && offset < len(data); i++ {
-		ch := template[i]
-		// Check for count
-		count := 1
-		if i+1 < len(template) && template[i+1] >= '0' && template[i+1] <= '9' {
-			countStr := ""
-			for i+1 < len(template) && template[i+1] >= '0' && template[i+1] <= '9' {
-				i++
-				countStr += string(template[i])
-			}
-			count, _ = strconv.Atoi(countStr)
-		}
-		switch ch {
-		case 'A', 'a':
-			end := offset + count
-			if end > len(data) { end = len(data) }
-			results = append(results, svStr(string(data[offset:end])))
-			offset = end
-		case 'C', 'c':
-			for c := 0; c < count && offset < len(data); c++ {
-				results = append(results, svInt(int64(data[offset])))
-				offset++
-			}
-		case 'Z':
-			end := offset
-			for end < len(data) && data[end] != 0 { end++ }
-			results = append(results, svStr(string(data[offset:end])))
-			offset = end + 1
-		}
+// addImports adds packages only some runtimes use to the imports of the
+// generated file, once the code shows they are needed
+func addImports(src string, paths ...string) string {
+	var imports strings.Builder
+	imports.WriteString("import (\n")
+	for _, path := range paths {
+		imports.WriteString("\t" + strconv.Quote(path) + "\n")
 	}
-	return svArray(results...)
-}`)
-	g.writeln("")
-
-	// wantarray
-	g.writeln(`func perl_wantarray() *SV {
-		return svUndef()
-	}`)
-	g.writeln("")
-
-	// printf
-	g.writeln(`func perl_printf(args ...*SV) *SV {
-		if len(args) == 0 { return svInt(0) }
-		format := args[0].AsString()
-		fmtArgs := make([]interface{}, len(args)-1)
-		for i, arg := range args[1:] {
-			fmtArgs[i] = arg.AsString()
-		}
-		n, _ := fmt.Printf(format, fmtArgs...)
-		return svInt(int64(n))
-	}`)
-	g.writeln("")
-
-	// each
-	g.writeln(`var _hashIterators = make(map[*SV][]string)`)
-	g.writeln("")
-
-	g.writeln(`func perl_each(h *SV) *SV {
-		if h == nil || h.hv == nil { return svArray() }
-		
-		// Получаем или создаём список ключей для итерации
-		keys, ok := _hashIterators[h]
-		if !ok || len(keys) == 0 {
-			keys = make([]string, 0, len(h.hv))
-			for k := range h.hv {
-				keys = append(keys, k)
-			}
-			_hashIterators[h] = keys
-		}
-		
-		// Если ключи закончились - сбрасываем
-		if len(keys) == 0 {
-			delete(_hashIterators, h)
-			return svArray()
-		}
-		
-		// Берём первый ключ
-		k := keys[0]
-		_hashIterators[h] = keys[1:]
-		
-		return svArray(svStr(k), h.hv[k])
-	}`)
-
-	// pos
-	g.writeln(`func perl_pos(sv *SV) *SV {
-		return svUndef()
-	}`)
-	g.writeln("")
-
-	// grep
-	g.writeln(`func perl_grep(block func(*SV) *SV, arr *SV) *SV {
-		if arr == nil { return svArray() }
-		var results []*SV
-		for _, el := range arr.av {
-			if block(el).IsTrue() {
-				results = append(results, el)
-			}
-		}
-		return svArray(results...)
-	}`)
-	g.writeln("")
-
-	// map
-	g.writeln(`func perl_map(block func(*SV) *SV, arr *SV) *SV {
-		if arr == nil { return svArray() }
-		var results []*SV
-		for _, el := range arr.av {
-			results = append(results, block(el))
-		}
-		return svArray(results...)
-	}`)
-	g.writeln("")
-
-	// //grep - упрощённая версия без блока (пока не поддерживается в codegen)
-	// g.writeln(`func perl_grep(block, arr *SV) *SV {
-	// 	if arr == nil { return svArray() }
-	// 	// block игнорируется - возвращаем пустой массив
-	// 	// TODO: implement proper block support in codegen
-	// 	return svArray()
-	// }`)
-	// g.writeln("")
-
-	// // map - упрощённая версия
-	// g.writeln(`func perl_map(block, arr *SV) *SV {
-	// 	if arr == nil { return svArray() }
-	// 	// block игнорируется - возвращаем пустой массив
-	// 	// TODO: implement proper block support in codegen
-	// 	return svArray()
-	// }`)
-	// g.writeln("")
-
-	// eof - без аргументов или с SV
-	g.writeln(`func perl_eof(args ...*SV) *SV {
-		if len(args) == 0 { return svInt(1) }
-		name := args[0].AsString()
-		if fh, ok := _filehandles[name]; ok && fh.file != nil {
-			pos, _ := fh.file.Seek(0, 1)
-			fi, _ := fh.file.Stat()
-			if pos >= fi.Size() { return svInt(1) }
-			return svInt(0)
-		}
-		return svInt(1)
-	}`)
-	g.writeln("")
-
-	// tell
-	g.writeln(`func perl_tell(args ...*SV) *SV {
-		if len(args) == 0 { return svInt(-1) }
-		name := args[0].AsString()
-		if fh, ok := _filehandles[name]; ok && fh.file != nil {
-			pos, _ := fh.file.Seek(0, 1)
-			return svInt(pos)
-		}
-		return svInt(-1)
-	}`)
-	g.writeln("")
-
-	// seek
-	g.writeln(`func perl_seek(fh, pos, whence *SV) *SV {
-		name := fh.AsString()
-		if h, ok := _filehandles[name]; ok && h.file != nil {
-			_, err := h.file.Seek(pos.AsInt(), int(whence.AsInt()))
-			if err == nil {
-				if h.scanner != nil {
-					h.scanner = bufio.NewScanner(h.file)
-				}
-				return svInt(1)
-			}
-		}
-		return svInt(0)
-	}`)
-	g.writeln("")
-
-	// read
-	g.writeln(`func perl_read(fh, buf, length *SV) *SV {
-		name := fh.AsString()
-		if h, ok := _filehandles[name]; ok && h.file != nil {
-			data := make([]byte, length.AsInt())
-			n, _ := h.file.Read(data)
-			buf.pv = string(data[:n])
-			buf.flags = SVf_POK
-			return svInt(int64(n))
-		}
-		return svInt(0)
-	}`)
-	g.writeln("")
-
-	// binmode
-	g.writeln(`func perl_binmode(args ...*SV) *SV {
-		return svInt(1)
-	}`)
-	g.writeln("")
-
-	g.writeln("// ============ End Runtime ============")
-	g.writeln("")
+	return strings.Replace(src, "import (\n", imports.String(), 1)
 }
 
 func (g *Generator) write(s string) {
@@ -1012,6 +350,10 @@ func (g *Generator) writeln(s string) {
 }
 
 func (g *Generator) generateStatement(stmt ast.Statement) {
+	prev := g.stmt
+	g.stmt = stmt
+	defer func() { g.stmt = prev }()
+	g.markStatement()
 	switch s := stmt.(type) {
 	case *ast.ExprStmt:
 		// Special handling for open() to declare filehandle variable
@@ -1021,12 +363,19 @@ func (g *Generator) generateStatement(stmt ast.Statement) {
 				return
 			}
 		}
+		// open/sysopen(my $fh, ...) or die - declare the handle variable first
+		g.declareOpenHandles(s.Expression)
+		g.declareSubstTarget(s.Expression)
 		g.write(strings.Repeat("\t", g.indent))
-		g.generateExpression(s.Expression)
+		if !g.generateNativeStore(s.Expression) {
+			g.generateExpression(s.Expression)
+		}
 		g.write("\n")
 	case *ast.VarDecl:
+		g.declareOpenHandles(s.Value)
 		g.generateVarDecl(s)
 	case *ast.IfStmt:
+		g.declareOpenHandles(s.Condition)
 		g.generateIfStmt(s)
 	case *ast.WhileStmt:
 		g.generateWhileStmt(s)
@@ -1034,24 +383,204 @@ func (g *Generator) generateStatement(stmt ast.Statement) {
 		g.generateForStmt(s)
 	case *ast.ForeachStmt:
 		g.generateForeachStmt(s)
+	case *ast.GivenStmt:
+		g.generateGivenStmt(s)
+	case *ast.DoStmt:
+		g.generateDoStmt(s)
 	case *ast.BlockStmt:
 		g.generateBlockStmt(s)
 	case *ast.ReturnStmt:
 		g.generateReturnStmt(s)
 	case *ast.LastStmt:
-		g.writeln("break")
+		g.generateLoopJump("break", s.Label)
 	case *ast.NextStmt:
-		g.writeln("continue")
+		g.generateLoopJump("continue", s.Label)
+	case *ast.RedoStmt:
+		g.generateLoopJump("redo", s.Label)
+	case *ast.LabelStmt:
+		g.generateLabelStmt(s)
 	case *ast.SubDecl:
 		// Already handled at top level
 	case *ast.UseDecl:
-		// Ignore for now
+		if s.Module == "lib" {
+			g.write(strings.Repeat("\t", g.indent) + "perlrt.SvUnshift(perlrt.A_INC, ")
+			g.generateListValues(&ast.ArrayExpr{Elements: s.Args})
+			g.write("...)\n")
+		}
+		g.generateUse(s)
+		if s.Module == "warnings" {
+			g.generateUseWarnings(s.Args, false)
+		}
+		if s.Module == "overload" {
+			g.generateUseOverload(s)
+		}
+	case *ast.NoDecl:
+		if s.Module == "warnings" {
+			g.generateUseWarnings(s.Args, true)
+		}
+	case *ast.RequireDecl:
+		g.generateRequireDecl(s)
 	case *ast.PackageDecl:
-		// Ignore for now
+		g.generatePackageDecl(s)
+	}
+}
+
+// noteUse records what a use of the file level turns on for the whole
+// program: the modules and pragmas perlc implements itself
+func (g *Generator) noteUse(use *ast.UseDecl) {
+	switch use.Module {
+	case "Time::Piece":
+		g.timePiece = true
+	case "warnings":
+		if namesCategory(use.Args, warnings.Numeric) {
+			g.numericWarn = true
+		}
+		if namesCategory(use.Args, warnings.Uninitialized) {
+			g.uninitWarn = true
+		}
+	case "constant":
+		g.defineConstants(use)
+	case "perlc::parallel":
+		g.parallel = true
+	case "Devel::Size":
+		g.develSize = true
+	case "Time::HiRes":
+		g.useHiRes(use.Args)
+	case "Carp":
+		g.useCarp(use.Args)
+	case "Scalar::Util":
+		g.useScalarUtil(use.Args)
+	case "List::Util":
+		g.useListUtil(use.Args)
+	case "POSIX":
+		g.usePOSIX(use.Args)
+	case "Getopt::Long", "Getopt::Std":
+		g.useGetopt(use.Module, use.Args)
+	case "File::Basename", "File::Path", "Cwd":
+		g.useFileModule(use.Module, use.Args)
+	case "File::Spec":
+		g.fileSpec = true
+	case "Storable":
+		g.useStorable(use.Args)
+	case "DBI":
+		g.dbi = true
+	case "HTTP::Tiny", "LWP::UserAgent":
+		g.http = true
+	case "threads", "Thread::Queue":
+		g.threads = true
+	}
+}
+
+// generateRequireDecl loads the file of require Module or require EXPR
+// when the program gets there
+func (g *Generator) generateRequireDecl(decl *ast.RequireDecl) {
+	where := ""
+	if w := g.where(); w != "" {
+		where = " at " + w
+	}
+	if decl.Expr != nil {
+		g.loads = true
+		g.write(strings.Repeat("\t", g.indent) + "_load(")
+		g.generateScalarExpression(decl.Expr)
+		g.write(fmt.Sprintf(".AsString(), \"\", %q, false)\n", where))
+		return
+	}
+	file := moduleFile(decl.Module)
+	if file == "" {
+		g.generateRequire(decl.Module)
+		return
+	}
+	g.loads = true
+	g.writeln(fmt.Sprintf("_load(%q, %q, %q, false)", file, decl.Module, where))
+}
+
+// generateRequire notes the file of use or require Module in %INC for a
+// module perlc implements, there is nothing to load. use 5.010 is no
+// module.
+func (g *Generator) generateRequire(module string) {
+	if module == "" || isVersion(module) {
+		return
+	}
+	g.writeln("perlrt.Require(" + strconv.Quote(strings.ReplaceAll(module, "::", "/")+".pm") + ")")
+}
+
+// generatePackageDecl switches the current package: package NAME; to the
+// end of the file, package NAME { ... } for its block.
+func (g *Generator) generatePackageDecl(decl *ast.PackageDecl) {
+	if decl.Block == nil {
+		g.pkg = decl.Name
+		g.packageISA(decl.Name)
+		return
+	}
+	prev := g.pkg
+	g.pkg = decl.Name
+	g.packageISA(decl.Name)
+	g.generateBlockStmt(decl.Block)
+	g.pkg = prev
+	g.packageISA(prev)
+}
+
+// generateUseOverload registers the op => code pairs of use overload for
+// the current package; '""' is used when an object is printed.
+func (g *Generator) generateUseOverload(use *ast.UseDecl) {
+	g.write(strings.Repeat("\t", g.indent))
+	g.write(fmt.Sprintf("perlrt.Overload(%q", g.pkg))
+	for _, a := range use.Args {
+		g.write(", ")
+		g.generateExpression(a)
+	}
+	g.write(")\n")
+}
+
+// declareInnerMy declares the variables a my inside the value of a
+// declaration assigns: my ($x, $y) = my @l = (1, 2, 3)
+func (g *Generator) declareInnerMy(value ast.Expression) {
+	a, ok := value.(*ast.AssignExpr)
+	for ok && a.Operator == "=" {
+		targets := []ast.Expression{a.Left}
+		if list, isList := a.Left.(*ast.ArrayExpr); isList {
+			targets = list.Elements
+		}
+		for _, t := range targets {
+			name := g.declName(t, "my")
+			if name == "_" || g.isDeclared(name) {
+				continue
+			}
+			switch t.(type) {
+			case *ast.ArrayVar:
+				g.writeln(name + " := perlrt.SvArray()")
+			case *ast.HashVar:
+				g.writeln(name + " := perlrt.SvHash()")
+			default:
+				g.writeln(name + " := perlrt.SvUndef()")
+			}
+			g.writeln("_ = " + name)
+			g.declare(name)
+		}
+		a, ok = a.Right.(*ast.AssignExpr)
 	}
 }
 
 func (g *Generator) generateVarDecl(decl *ast.VarDecl) {
+	if decl.Kind == "local" {
+		g.generateLocal(decl)
+		return
+	}
+	if decl.Kind == "our" {
+		// our $x without a value keeps the value of the package variable
+		g.declareOurs(decl, g.pkg)
+		if decl.Value == nil {
+			return
+		}
+	}
+	if decl.Kind == "my" {
+		g.declareCaptured(decl)
+	}
+	g.declareInnerMy(decl.Value)
+	if g.generateNativeDecl(decl) {
+		return
+	}
+
 	// Handle list assignment: my ($a, $b) = @_
 	if decl.IsList && decl.Value != nil {
 		// Check if assigning from @_ (can be ArrayVar or SpecialVar)
@@ -1066,10 +595,20 @@ func (g *Generator) generateVarDecl(decl *ast.VarDecl) {
 		if isArgsAssign {
 			// Unpack from args
 			for i, v := range decl.Names {
-				name := g.varName(v)
-				g.declaredVars[name] = true
+				name := g.declName(v, decl.Kind)
+				op := g.assignOp(name)
 				g.write(strings.Repeat("\t", g.indent))
-				g.write(fmt.Sprintf("%s := func() *SV { if %d < len(args) { return args[%d] }; return svUndef() }()\n", name, i, i))
+				switch v.(type) {
+				case *ast.ArrayVar:
+					// my ($self, @rest) = @_: the array takes the rest
+					g.write(fmt.Sprintf("%s %s perlrt.SvArray(perlrt.Flatten(perlrt.ListRest(_args.AV, %d))...)\n", name, op, i))
+				case *ast.HashVar:
+					g.write(name + " " + op + " perlrt.SvHFill(perlrt.SvHash(), ")
+					g.generateHashPairs(decl.Value, i, func() { g.write(fmt.Sprintf("perlrt.Flatten(perlrt.ListRest(_args.AV, %d))", i)) })
+					g.write(")\n")
+				default:
+					g.write(fmt.Sprintf("%s %s perlrt.ListAt(_args.AV, %d)\n", name, op, i))
+				}
 				g.writeln("_ = " + name)
 			}
 			return
@@ -1079,54 +618,83 @@ func (g *Generator) generateVarDecl(decl *ast.VarDecl) {
 		tmpVar := fmt.Sprintf("_tmp%d", g.tempCount)
 		g.write(strings.Repeat("\t", g.indent))
 		g.write(tmpVar + " := ")
-		g.generateExpression(decl.Value)
+		if m, ok := isListMatch(decl.Value); ok {
+			g.generateMatchList(m)
+		} else if rl, ok := decl.Value.(*ast.ReadLineExpr); ok {
+			g.generateReadLineList(rl)
+		} else if c, ok := decl.Value.(*ast.CommandExpr); ok {
+			g.generateCommandList(c)
+		} else if a, ok := decl.Value.(*ast.AssignExpr); ok && a.Operator == "=" && isListTarget(a.Left) {
+			g.write("perlrt.SvArray(")
+			g.generateListValues(a)
+			g.write("...)")
+		} else {
+			g.generateExpression(decl.Value)
+		}
 		g.write("\n")
 		for i, v := range decl.Names {
-			name := g.varName(v)
-			g.declaredVars[name] = true
+			name := g.declName(v, decl.Kind)
+			op := g.assignOp(name)
 			g.write(strings.Repeat("\t", g.indent))
-			g.write(fmt.Sprintf("%s := svAGet(%s, svInt(%d))\n", name, tmpVar, i))
+			switch v.(type) {
+			case *ast.ArrayVar:
+				// my ($first, @rest) = @list: the array takes the rest
+				g.write(fmt.Sprintf("%s %s perlrt.SvArray(perlrt.ListRest(perlrt.ListOf(%s), %d)...)\n", name, op, tmpVar, i))
+			case *ast.HashVar:
+				g.write(name + " " + op + " perlrt.SvHFill(perlrt.SvHash(), ")
+				g.generateHashPairs(decl.Value, i, func() { g.write(fmt.Sprintf("perlrt.ListRest(perlrt.ListOf(%s), %d)", tmpVar, i)) })
+				g.write(")\n")
+			default:
+				g.write(fmt.Sprintf("%s %s perlrt.SvAGet(%s, perlrt.SvInt(%d))\n", name, op, tmpVar, i))
+			}
 			g.writeln("_ = " + name)
 		}
 		return
 	}
 
 	if len(decl.Names) == 1 {
-		name := g.varName(decl.Names[0])
+		checkHash := false
+		if _, ok := decl.Names[0].(*ast.HashVar); ok && decl.Value != nil {
+			checkHash = g.checkHashValue(decl.Value)
+		}
+		name := g.declName(decl.Names[0], decl.Kind)
 		g.write(strings.Repeat("\t", g.indent))
 
 		// Определяем оператор: := для нового, = для уже объявленного
 		op := " := "
-		if g.declaredVars[name] {
+		if g.declaredHere(name) {
 			op = " = "
 		} else {
-			g.declaredVars[name] = true
+			g.declare(name)
 		}
 
 		// Check variable type for proper initialization
 		switch decl.Names[0].(type) {
 		case *ast.ArrayVar:
 			if decl.Value != nil {
-				g.write(name + op)
-				g.generateExpression(decl.Value)
+				g.write(name + op + "perlrt.SvArray(")
+				g.generateListValues(decl.Value)
+				g.write("...)")
 			} else {
-				g.write(name + op + "svArray()")
+				g.write(name + op + "perlrt.SvArray()")
 			}
 		case *ast.HashVar:
-			if decl.Value != nil {
-				// Convert array to hash
-				g.write(name + op + "func() *SV { _arr := ")
+			if checkHash {
+				g.write(name + op)
+				g.generateHashValue(decl.Value)
+			} else if decl.Value != nil {
+				g.write(name + op + "perlrt.SvHashFrom(")
 				g.generateExpression(decl.Value)
-				g.write("; _h := svHash(); for _i := 0; _i+1 < len(_arr.av); _i += 2 { svHSet(_h, _arr.av[_i], _arr.av[_i+1]) }; return _h }()")
+				g.write(")")
 			} else {
-				g.write(name + op + "svHash()")
+				g.write(name + op + "perlrt.SvHash()")
 			}
 		default:
 			if decl.Value != nil {
 				g.write(name + op)
-				g.generateExpression(decl.Value)
+				g.generateScalarValue(decl.Value)
 			} else {
-				g.write(name + op + "svUndef()")
+				g.write(name + op + "perlrt.SvUndef()")
 			}
 		}
 		g.write("\n")
@@ -1138,107 +706,528 @@ func (g *Generator) generateVarDecl(decl *ast.VarDecl) {
 	}
 
 	for _, v := range decl.Names {
-		name := g.varName(v)
-		g.declaredVars[name] = true
+		name := g.declName(v, decl.Kind)
+		op := g.assignOp(name)
+		// my ($x, @list, %seen); - the aggregates start empty
+		value := "perlrt.SvUndef()"
+		switch v.(type) {
+		case *ast.ArrayVar:
+			value = "perlrt.SvArray()"
+		case *ast.HashVar:
+			value = "perlrt.SvHash()"
+		}
+		g.writeln(name + " " + op + " " + value)
+		g.writeln("_ = " + name)
+	}
+}
+
+// generateLocal emits local $x, @a, %h, $/, $a[i], $h{key} and lists of
+// them: the old value goes on perlrt's save stack and comes back when the
+// block of the local ends (see local.go). Other forms die when they run.
+func (g *Generator) generateLocal(decl *ast.VarDecl) {
+	if !decl.IsList && len(decl.Names) == 1 {
+		if !g.localize(decl.Names[0], decl.Value) {
+			g.localFail(decl.Names[0])
+		}
+		return
+	}
+	values := ""
+	if decl.Value != nil {
+		// the values are taken first: local ($x, $y) = ($y, $x)
+		g.tempCount++
+		values = fmt.Sprintf("_locals%d", g.tempCount)
+		g.write(strings.Repeat("\t", g.indent) + values + " := ")
+		g.generateListValues(decl.Value)
+		g.write("\n")
+	}
+	for _, name := range decl.Names {
+		if !g.localize(name, nil) {
+			g.localFail(name)
+			return
+		}
+	}
+	if values == "" {
+		return
+	}
+	for n, name := range decl.Names {
 		g.write(strings.Repeat("\t", g.indent))
-		g.write(name + " := svUndef()")
+		switch {
+		case isHashVar(name):
+			g.write("perlrt.SvHFill(")
+			g.generateArrayOperand(name)
+			g.write(fmt.Sprintf(", perlrt.ListRest(%s, %d))\n", values, n))
+			return
+		case isAggregate(name):
+			g.write("perlrt.SvAFill(")
+			g.generateArrayOperand(name)
+			g.write(fmt.Sprintf(", perlrt.ListRest(%s, %d))\n", values, n))
+			return
+		}
+		g.generateStore(name, fmt.Sprintf("perlrt.ListAt(%s, %d)", values, n))
 		g.write("\n")
-		g.writeln("_ = " + name)
 	}
 }
 
-func (g *Generator) generateSubDecl(sub *ast.SubDecl) {
-	// Очищаем declaredVars для нового scope функции
-	g.declaredVars = make(map[string]bool)
+// localFail emits the die of a local the compiled program cannot make
+func (g *Generator) localFail(name ast.Expression) {
+	msg := "Can't localize " + name.String() + " in a compiled program"
+	if at := g.where(); at != "" {
+		msg += " at " + at
+	}
+	g.writeln("perlrt.Perl_die(perlrt.SvStr(" + strconv.Quote(msg+".\n") + "))")
+}
+
+// localize saves the variable or element target on the save stack and
+// assigns it value, undef or empty without one; false for other forms
+func (g *Generator) localize(target, value ast.Expression) bool {
+	g.tempCount++
+	tmp := fmt.Sprintf("_local%d", g.tempCount)
+	ind := strings.Repeat("\t", g.indent)
+
+	switch target.(type) {
+	case *ast.ScalarVar, *ast.ArrayVar, *ast.HashVar:
+		// a name no my or our declared is the package variable
+		if !g.isDeclared(g.varName(target)) {
+			g.declareOurs(&ast.VarDecl{Names: []ast.Expression{target}}, g.pkg)
+		}
+	}
+	switch v := target.(type) {
+	case *ast.ScalarVar:
+		name := g.scalarName(v.Name)
+		g.writeln(tmp + " := " + name)
+		g.writeln("perlrt.Save(func() { " + name + " = " + tmp + " })")
+		g.write(ind + name + " = ")
+		if value != nil {
+			g.generateScalarExpression(value)
+		} else {
+			g.write("perlrt.SvUndef()")
+		}
+		g.write("\n")
+	case *ast.ArrayVar:
+		name := g.arrayName(v.Name)
+		g.writeln(tmp + " := " + name)
+		g.writeln("perlrt.Save(func() { " + name + " = " + tmp + " })")
+		g.write(ind + name + " = ")
+		if value != nil {
+			// a fresh array, so local @a = @b does not alias @b
+			g.write("perlrt.SvArray(perlrt.Flatten([]*perlrt.SV{")
+			g.generateExpression(value)
+			g.write("})...)")
+		} else {
+			g.write("perlrt.SvArray()")
+		}
+		g.write("\n")
+	case *ast.HashVar:
+		name := g.hashName(v.Name)
+		g.writeln(tmp + " := " + name)
+		g.writeln("perlrt.Save(func() { " + name + " = " + tmp + " })")
+		checkHash := value != nil && g.checkHashValue(value)
+		g.write(ind + name + " = ")
+		if checkHash {
+			g.generateHashValue(value)
+		} else if value != nil {
+			g.write("perlrt.SvHashFrom(")
+			g.generateExpression(value)
+			g.write(")")
+		} else {
+			g.write("perlrt.SvHash()")
+		}
+		g.write("\n")
+	case *ast.SpecialVar:
+		// local $/; local $, = "-"
+		global, ok := specialVars[v.Name]
+		if !ok {
+			return false
+		}
+		name := strconv.Quote(v.Name)
+		g.writeln(tmp + " := " + global)
+		g.writeln("perlrt.Save(func() { perlrt.SetSpecial(" + name + ", " + tmp + ") })")
+		g.write(ind + "perlrt.SetSpecial(" + name + ", ")
+		if value != nil {
+			g.generateScalarExpression(value)
+		} else {
+			g.write("perlrt.SvUndef()")
+		}
+		g.write(")\n")
+	case *ast.ArrayAccess:
+		g.write(ind + tmp + "a, " + tmp + "i := ")
+		if sv, ok := v.Array.(*ast.ScalarVar); ok {
+			g.write(g.arrayName(sv.Name))
+		} else {
+			g.generateExpression(v.Array)
+		}
+		g.write(", ")
+		g.generateExpression(v.Index)
+		g.write("\n")
+		g.writeln(tmp + " := perlrt.SvAGet(" + tmp + "a, " + tmp + "i)")
+		g.writeln("perlrt.Save(func() { perlrt.SvASet(" + tmp + "a, " + tmp + "i, " + tmp + ") })")
+		g.write(ind + "perlrt.SvASet(" + tmp + "a, " + tmp + "i, ")
+		if value != nil {
+			g.generateScalarExpression(value)
+		} else {
+			g.write("perlrt.SvUndef()")
+		}
+		g.write(")\n")
+	case *ast.HashAccess:
+		// local $ENV{PATH} changes the process environment until the restore
+		sync := ""
+		g.write(ind + tmp + "h, " + tmp + "k := ")
+		if sv, ok := v.Hash.(*ast.ScalarVar); ok {
+			g.write(g.hashName(sv.Name))
+			if sv.Name == "ENV" {
+				sync = "; perlrt.SyncEnv()"
+			}
+		} else {
+			g.generateExpression(v.Hash)
+		}
+		g.write(", ")
+		g.generateExpression(v.Key)
+		g.write(".AsString()\n")
+		g.writeln(tmp + ", " + tmp + "ok := " + tmp + "h.HV[" + tmp + "k]")
+		g.writeln("perlrt.Save(func() { if " + tmp + "ok { " + tmp + "h.HV[" + tmp + "k] = " + tmp + " } else { delete(" + tmp + "h.HV, " + tmp + "k) }" + sync + " })")
+		g.write(ind + tmp + "h.HV[" + tmp + "k] = ")
+		if value != nil {
+			g.generateExpression(value)
+		} else {
+			g.write("perlrt.SvUndef()")
+		}
+		g.write(sync + "\n")
+	default:
+		return false
+	}
+	return true
+}
+
+// generateDoBlock generates do { ... } as a closure called in place; the
+// last statement is the value of the block.
+func (g *Generator) generateDoBlock(block *ast.DoBlockExpr) {
+	g.pushScope()
+	defer g.popScope()
 
-	g.write("func perl_" + strings.ReplaceAll(sub.Name, "::", "_") + "(args ...*SV) *SV {\n")
+	g.write("func() *perlrt.SV {\n")
 	g.indent++
-	g.writeln("_ = args")
-	g.writeln("_args := svArray(args...)") // Создаём один массив для @_
-	g.writeln("_ = _args")                 // Предотвращаем ошибку "declared and not used"
+	g.generateBodyWithValue(block.Body.Statements)
+	g.indent--
+	g.write(strings.Repeat("\t", g.indent) + "}()")
+}
+
+// generateEvalBlock generates eval { ... } as a closure run under _eval,
+// which turns die into $@. The last statement is the value of the block.
+func (g *Generator) generateEvalBlock(block *ast.EvalBlockExpr) {
+	g.generateEval(block, "")
+}
+
+// generateEval is eval BLOCK, or eval STRING with its code as text
+func (g *Generator) generateEval(block *ast.EvalBlockExpr, text string) {
+	g.pushScope()
+	defer g.popScope()
+
+	g.write("perlrt.Eval(func() *perlrt.SV {\n")
+	g.indent++
+	g.pushFrame("", text, true)
+	g.generateBodyWithValue(block.Body.Statements)
+	g.indent--
+	g.write(strings.Repeat("\t", g.indent) + "})")
+}
+
+// generateEvalString compiles eval "code" whose code is a constant string:
+// it is parsed at generation time and runs like eval BLOCK, a syntax error
+// becomes $@ at run time. Code built at run time would need the parser in
+// the compiled program, so such evals fail with $@ set.
+func (g *Generator) generateEvalString(expr *ast.EvalStringExpr) {
+	g.evalCount++
+	name := fmt.Sprintf("(eval %d)", g.evalCount)
+
+	lit, ok := expr.Code.(*ast.StringLiteral)
+	code := ""
+	if ok {
+		code = lit.Value
+		if lit.Interpolated {
+			for _, seg := range interpolate.Parse(lit.Value) {
+				ok = ok && seg.Expr == nil
+			}
+			code = interpolate.Unescape(lit.Value)
+		}
+	}
+	if !ok {
+		g.write("perlrt.EvalFail(" + strconv.Quote("eval STRING with run-time code is not supported in compiled programs at "+name+"\n") + ")")
+		return
+	}
+
+	p := parser.New(lexer.NewFile(code, name))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		g.write("perlrt.EvalFail(" + strconv.Quote("syntax error at "+name+" "+errs[0]+"\n") + ")")
+		return
+	}
+	for _, stmt := range program.Statements {
+		if _, isSub := stmt.(*ast.SubDecl); isSub {
+			g.write("perlrt.EvalFail(" + strconv.Quote("sub declarations in eval STRING are not supported in compiled programs at "+name+"\n") + ")")
+			return
+		}
+	}
+
+	g.generateEval(&ast.EvalBlockExpr{Token: expr.Token, Body: &ast.BlockStmt{Statements: program.Statements}}, code)
+}
 
-	// Generate body
-	for _, stmt := range sub.Body.Statements {
+// generateBodyWithValue emits the statements of a closure body and returns
+// the value of the last one, as Perl does for subs and eval blocks.
+// Scalar assignments are Go statements, so they yield undef.
+func (g *Generator) generateBodyWithValue(stmts []ast.Statement) {
+	g.pushScope()
+	defer g.popScope()
+	prev := g.enterLocals(stmts)
+	defer func() { g.marks = prev }()
+	for idx, stmt := range stmts {
+		last, ok := stmt.(*ast.ExprStmt)
+		if ok && idx == len(stmts)-1 {
+			if _, isAssign := last.Expression.(*ast.AssignExpr); !isAssign {
+				g.stmt = stmt
+				g.markStatement()
+				g.write(strings.Repeat("\t", g.indent) + "return ")
+				g.generateScalarExpression(last.Expression)
+				g.write("\n")
+				return
+			}
+		}
 		g.generateStatement(stmt)
 	}
+	g.writeln("return perlrt.SvUndef()")
+}
+
+func (g *Generator) generateSubDecl(sub *ast.SubDecl) {
+	// Тело sub генерируется в пакете, где она объявлена
+	prev := g.pkg
+	g.pkg = g.subPkgs[sub.Name]
+	defer func() { g.pkg = prev }()
+	// Очищаем scopes для нового scope функции
+	g.resetScopes()
+	g.natives = g.scalarKinds(sub.Body.Statements)
+
+	g.write("func perl_" + strings.ReplaceAll(sub.Name, "::", "_") + "(args ...*perlrt.SV) *perlrt.SV {\n")
+	g.indent++
+	g.writeln("_ = args")
+	g.writeln("_args := perlrt.SvArray(args...)") // Создаём один массив для @_
+	g.writeln("_ = _args")                        // Предотвращаем ошибку "declared and not used"
+	g.enterSub(sub.Name, ast.FromToken(sub.Token))
+	g.generateSignature(sub.Name, sub.Params)
 
-	g.writeln("return svUndef()")
+	// Generate body; последнее выражение - возвращаемое значение
+	g.generateBodyWithValue(sub.Body.Statements)
 	g.indent--
 	g.writeln("}")
 }
 
+// generateAnonSub generates sub { ... } as a Go closure wrapped in an SV.
+// Variables declared inside the body stay local to it.
+func (g *Generator) generateAnonSub(sub *ast.AnonSubExpr) {
+	g.pushScope()
+	defer g.popScope()
+
+	g.write("perlrt.SvCode(func(args ...*perlrt.SV) *perlrt.SV {\n")
+	g.indent++
+	g.writeln("_ = args")
+	g.writeln("_args := perlrt.SvArray(args...)")
+	g.writeln("_ = _args")
+	g.enterSub("__ANON__", ast.FromToken(sub.Token))
+	g.generateSignature("__ANON__", sub.Params)
+	g.generateBodyWithValue(sub.Body.Statements)
+	g.indent--
+	g.write(strings.Repeat("\t", g.indent) + "})")
+}
+
+// generateSignature unpacks the parameters of sub f($x, $y = 10, @rest):
+// a missing scalar gets its default, computed after the parameters before
+// it, and @ or % takes the rest.
+func (g *Generator) generateSignature(name string, params []*ast.Param) {
+	if params == nil {
+		return
+	}
+	min, max := 0, 0
+	for _, p := range params {
+		if p.Sigil != "$" {
+			max = -1
+			break
+		}
+		max++
+		if p.Default == nil {
+			min = max
+		}
+	}
+	if !strings.Contains(name, "::") {
+		name = "main::" + name
+	}
+	g.writeln(fmt.Sprintf("_params := perlrt.Signature(%q, args, %d, %d)", name, min, max))
+	ind := strings.Repeat("\t", g.indent)
+	for n, p := range params {
+		// parameters are lexicals, even $a and $b
+		var goName string
+		switch p.Sigil {
+		case "@":
+			goName = "a_" + p.Name
+			g.writeln(fmt.Sprintf("%s := perlrt.SvArray(perlrt.ListRest(_params, %d)...)", goName, n))
+		case "%":
+			goName = "h_" + p.Name
+			g.writeln(fmt.Sprintf("%s := perlrt.SvHFill(perlrt.SvHash(), perlrt.ListRest(_params, %d))", goName, n))
+		default:
+			goName = "v_" + p.Name
+			g.writeln(fmt.Sprintf("%s := perlrt.ListAt(_params, %d)", goName, n))
+			if p.Default != nil {
+				g.write(fmt.Sprintf("%sif len(_params) <= %d { %s = ", ind, n, goName))
+				g.generateScalarExpression(p.Default)
+				g.write(" }\n")
+			}
+		}
+		g.writeln("_ = " + goName)
+		g.declare(goName)
+	}
+}
+
 func (g *Generator) generateIfStmt(stmt *ast.IfStmt) {
+	if g.Optimize {
+		if name, cases, ok := eqChain(stmt); ok {
+			g.generateEqSwitch(name, cases, stmt.Else)
+			return
+		}
+	}
 	g.write(strings.Repeat("\t", g.indent))
 	if stmt.Unless {
 		g.write("if !(")
+		g.generateCondition(stmt.Condition)
+		g.write(") {\n")
 	} else {
-		g.write("if (")
+		g.write("if ")
+		g.generateCondition(stmt.Condition)
+		g.write(" {\n")
 	}
-	g.generateExpression(stmt.Condition)
-	g.write(").IsTrue() {\n")
 	g.indent++
-	for _, s := range stmt.Then.Statements {
-		g.generateStatement(s)
-	}
+	g.generateBlock(stmt.Then.Statements)
 	g.indent--
 
 	for _, elsif := range stmt.Elsif {
 		g.write(strings.Repeat("\t", g.indent))
-		g.write("} else if (")
-		g.generateExpression(elsif.Condition)
-		g.write(").IsTrue() {\n")
+		g.write("} else if ")
+		g.generateCondition(elsif.Condition)
+		g.write(" {\n")
 		g.indent++
-		for _, s := range elsif.Body.Statements {
-			g.generateStatement(s)
-		}
+		g.generateBlock(elsif.Body.Statements)
 		g.indent--
 	}
 
 	if stmt.Else != nil {
 		g.writeln("} else {")
 		g.indent++
-		for _, s := range stmt.Else.Statements {
-			g.generateStatement(s)
-		}
+		g.generateBlock(stmt.Else.Statements)
 		g.indent--
 	}
 	g.writeln("}")
 }
 
 func (g *Generator) generateWhileStmt(stmt *ast.WhileStmt) {
+	// while ($x = EXPR) - assignment is not an expression in Go
+	if assign, ok := stmt.Condition.(*ast.AssignExpr); ok && assign.Operator == "=" && !stmt.Until {
+		if v, ok := assign.Left.(*ast.ScalarVar); ok {
+			g.generateAssignWhile(stmt, assign, g.scalarName(v.Name))
+			return
+		}
+	}
+	g.beginLoop(stmt.Body)
 	g.write(strings.Repeat("\t", g.indent))
 	if stmt.Until {
 		// until = пока НЕ выполняется условие
 		g.write("for !(")
-		g.generateExpression(stmt.Condition)
-		g.write(").IsTrue() {\n")
+		g.generateCondition(stmt.Condition)
+		g.write(") {\n")
 	} else {
 		// while = пока выполняется условие
-		g.write("for (")
-		g.generateExpression(stmt.Condition)
-		g.write(").IsTrue() {\n")
+		g.write("for ")
+		g.generateCondition(stmt.Condition)
+		g.write(" {\n")
 	}
 	g.indent++
-	for _, s := range stmt.Body.Statements {
-		g.generateStatement(s)
+	g.generateLoopBody(stmt.Body)
+	g.indent--
+	g.writeln("}")
+}
+
+// generateDoStmt generates do { ... } while/until (COND) as a Go loop that
+// tests the condition in its post statement, so the body runs at least once.
+func (g *Generator) generateDoStmt(stmt *ast.DoStmt) {
+	g.tempCount++
+	again := fmt.Sprintf("_do%d", g.tempCount)
+	g.write(strings.Repeat("\t", g.indent) + "for " + again + " := true; " + again + "; " + again + " = ")
+	if stmt.Until {
+		g.write("!(")
+		g.generateCondition(stmt.Condition)
+		g.write(")")
+	} else {
+		g.generateCondition(stmt.Condition)
+	}
+	g.write(" {\n")
+	g.indent++
+	g.writeln("perlrt.CheckSignals()")
+	g.generateBlock(stmt.Body.Statements)
+	g.indent--
+	g.writeln("}")
+}
+
+// generateAssignWhile lowers while ($x = EXPR) { ... } to a Go loop that
+// assigns first and then tests. Like Perl, readline and glob test defined().
+func (g *Generator) generateAssignWhile(stmt *ast.WhileStmt, assign *ast.AssignExpr, name string) {
+	if !g.isDeclared(name) {
+		g.writeln(name + " := perlrt.SvUndef()")
+		g.writeln("_ = " + name)
+		g.declare(name)
 	}
+	g.beginLoop(stmt.Body)
+	g.writeln("for {")
+	g.indent++
+	g.write(strings.Repeat("\t", g.indent))
+	g.generateAssignExpr(assign)
+	g.write("\n")
+	if isIteratorExpr(assign.Right) {
+		g.writeln("if " + name + ".Flags == 0 { break }")
+	} else {
+		g.writeln("if !" + name + ".IsTrue() { break }")
+	}
+	g.generateLoopBody(stmt.Body)
 	g.indent--
 	g.writeln("}")
 }
 
+// isIteratorExpr reports whether expr is readline or glob, which Perl
+// wraps in defined() when used as a while condition.
+func isIteratorExpr(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.ReadLineExpr:
+		return true
+	case *ast.CallExpr:
+		if ident, ok := e.Function.(*ast.Identifier); ok {
+			return ident.Value == "glob" || ident.Value == "readline"
+		}
+	}
+	return false
+}
+
 func (g *Generator) generateForStmt(stmt *ast.ForStmt) {
+	g.beginLoop(stmt.Body)
 	g.write(strings.Repeat("\t", g.indent))
 	g.write("for ")
 
 	// Init
 	if stmt.Init != nil {
-		if decl, ok := stmt.Init.(*ast.VarDecl); ok && len(decl.Names) > 0 {
+		if sv, ok := forNative(stmt.Init); ok && g.natives[sv.Name] != boxed {
+			g.write(g.nativeName(sv.Name) + " := ")
+			g.declare(g.nativeName(sv.Name))
+			g.generateNativeInit(sv.Name, stmt.Init.(*ast.VarDecl).Value)
+		} else if decl, ok := stmt.Init.(*ast.VarDecl); ok && len(decl.Names) > 0 {
 			name := g.varName(decl.Names[0])
 			g.write(name + " := ")
+			g.declare(name)
 			if decl.Value != nil {
 				g.generateExpression(decl.Value)
 			} else {
-				g.write("svUndef()")
+				g.write("perlrt.SvUndef()")
 			}
 		}
 	}
@@ -1246,53 +1235,118 @@ func (g *Generator) generateForStmt(stmt *ast.ForStmt) {
 
 	// Condition
 	if stmt.Condition != nil {
-		g.write("(")
-		g.generateExpression(stmt.Condition)
-		g.write(").IsTrue()")
+		g.generateCondition(stmt.Condition)
 	}
 	g.write("; ")
 
 	// Post
-	if stmt.Post != nil {
+	if stmt.Post != nil && !g.generateNativeStore(stmt.Post) {
 		g.generateExpression(stmt.Post)
 	}
 
 	g.write(" {\n")
 	g.indent++
-	for _, s := range stmt.Body.Statements {
-		g.generateStatement(s)
-	}
+	g.generateLoopBody(stmt.Body)
 	g.indent--
 	g.writeln("}")
 }
 func (g *Generator) generateForeachStmt(stmt *ast.ForeachStmt) {
 	iterVar := g.varName(stmt.Variable)
+	if _, ok := stmt.Variable.(*ast.ScalarVar); ok {
+		// the loop variable is a new lexical, even $a or $b
+		iterVar = g.declName(stmt.Variable, "my")
+	}
 	g.tempCount++
 	listVar := fmt.Sprintf("_list%d", g.tempCount)
 	idxVar := fmt.Sprintf("_i%d", g.tempCount)
 
 	g.write(strings.Repeat("\t", g.indent))
 	g.write(listVar + " := ")
-	g.generateExpression(stmt.List)
+	if rl, ok := stmt.List.(*ast.ReadLineExpr); ok {
+		// foreach (<$fh>) reads all the records first
+		g.generateReadLineList(rl)
+	} else if c, ok := stmt.List.(*ast.CommandExpr); ok {
+		g.generateCommandList(c)
+	} else {
+		g.generateExpression(stmt.List)
+	}
 	g.write("\n")
 
-	g.writeln(fmt.Sprintf("for %s := 0; %s < len(%s.av); %s++ {", idxVar, idxVar, listVar, idxVar))
+	g.beginLoop(stmt.Body)
+	g.writeln(fmt.Sprintf("for %s := 0; %s < len(%s.AV); %s++ {", idxVar, idxVar, listVar, idxVar))
 	g.indent++
-	g.writeln(fmt.Sprintf("%s := %s.av[%s]", iterVar, listVar, idxVar))
+	g.writeln(fmt.Sprintf("%s := %s.AV[%s]", iterVar, listVar, idxVar))
 	g.writeln("_ = " + iterVar)
-	for _, s := range stmt.Body.Statements {
-		g.generateStatement(s)
+	g.declare(iterVar)
+	g.generateLoopBody(stmt.Body)
+	g.indent--
+	g.writeln("}")
+}
+
+// generateGivenStmt lowers given/when to an if/else-if chain in a block
+// where v__ ($_) holds the topic. A plain chain, not a Go switch, so last and
+// next in a when body still reach the enclosing loop.
+func (g *Generator) generateGivenStmt(stmt *ast.GivenStmt) {
+	g.writeln("{")
+	g.indent++
+	g.write(strings.Repeat("\t", g.indent))
+	g.write("v__ := ")
+	g.generateScalarExpression(stmt.Topic)
+	g.write("\n")
+	g.writeln("_ = v__")
+
+	for n, clause := range stmt.Clauses {
+		g.write(strings.Repeat("\t", g.indent))
+		if n > 0 {
+			g.write("} else ")
+		}
+		g.write("if ")
+		g.generateWhenCondition(clause.Condition)
+		g.write(" {\n")
+		g.indent++
+		g.generateBlock(clause.Body.Statements)
+		g.indent--
+	}
+	if stmt.Default != nil {
+		if len(stmt.Clauses) > 0 {
+			g.writeln("} else {")
+		} else {
+			g.writeln("{")
+		}
+		g.indent++
+		g.generateBlock(stmt.Default.Statements)
+		g.indent--
 	}
+	if len(stmt.Clauses) > 0 || stmt.Default != nil {
+		g.writeln("}")
+	}
+
 	g.indent--
 	g.writeln("}")
 }
 
+// generateWhenCondition emits the Go condition of a when clause: a boolean
+// expression is tested as is, /re/ is matched against $_, anything else goes
+// through _smartMatch.
+func (g *Generator) generateWhenCondition(cond ast.Expression) {
+	if re, ok := cond.(*ast.RegexLiteral); ok {
+		cond = &ast.MatchExpr{Token: re.Token, Target: &ast.SpecialVar{Token: re.Token, Name: "$_"}, Pattern: re}
+	}
+	if isBooleanExpr(cond) {
+		g.write("(")
+		g.generateExpression(cond)
+		g.write(").IsTrue()")
+		return
+	}
+	g.write("perlrt.SmartMatch(v__, ")
+	g.generateExpression(cond)
+	g.write(")")
+}
+
 func (g *Generator) generateBlockStmt(stmt *ast.BlockStmt) {
 	g.writeln("{")
 	g.indent++
-	for _, s := range stmt.Statements {
-		g.generateStatement(s)
-	}
+	g.generateBlock(stmt.Statements)
 	g.indent--
 	g.writeln("}")
 }
@@ -1303,19 +1357,39 @@ func (g *Generator) generateReturnStmt(stmt *ast.ReturnStmt) {
 	if stmt.Value != nil {
 		g.generateExpression(stmt.Value)
 	} else {
-		g.write("svUndef()")
+		g.write("perlrt.SvUndef()")
 	}
 	g.write("\n")
 }
 
 func (g *Generator) generateMethodCall(e *ast.MethodCall) {
-	g.write("perl_method_call(")
-	g.generateExpression(e.Object)
-	g.write(fmt.Sprintf(", %q", e.Method))
-	for _, arg := range e.Args {
+	if class, ok := e.Object.(*ast.Identifier); ok && class.Value == "Perlc::Chan" {
+		g.chans = true
+	}
+	if e.Dynamic != nil {
+		g.write("perlrt.MethodCallDyn(")
+		g.generateScalarExpression(e.Object)
 		g.write(", ")
-		g.generateExpression(arg)
+		g.generateScalarExpression(e.Dynamic)
+	} else if method, ok := strings.CutPrefix(e.Method, "SUPER::"); ok {
+		// SUPER:: is relative to the package the sub is compiled in
+		g.write(fmt.Sprintf("perlrt.SuperCall(%q, ", g.pkg))
+		g.generateScalarExpression(e.Object)
+		g.write(fmt.Sprintf(", %q", method))
+	} else if g.dbi && dbiMethods[e.Method] {
+		g.write(fmt.Sprintf("_dbiCall(%q, ", g.where()))
+		g.generateScalarExpression(e.Object)
+		g.write(fmt.Sprintf(", %q", e.Method))
+	} else if g.threads && threadsMethods[e.Method] {
+		g.write(fmt.Sprintf("_threadsCall(%q, ", g.where()))
+		g.generateScalarExpression(e.Object)
+		g.write(fmt.Sprintf(", %q", e.Method))
+	} else {
+		g.write("perlrt.Perl_method_call(")
+		g.generateScalarExpression(e.Object)
+		g.write(fmt.Sprintf(", %q", e.Method))
 	}
+	g.generateCallArgs(e.Args, true)
 	g.write(")")
 }
 
@@ -1338,12 +1412,10 @@ func (g *Generator) GenerateMethodCall_test(e *ast.MethodCall) {
 	}
 
 	// Generate function call
-	methodName := strings.ReplaceAll(e.Method, "::", "_")
-
 	if isClassMethod {
-		// Class->new() becomes perl_Class_new(svStr("Class"), args...)
-		g.write("perl_" + strings.ReplaceAll(className, "::", "_") + "_" + methodName + "(")
-		g.write(fmt.Sprintf("svStr(%q)", className))
+		// Class->new() becomes perl_Class_new(perlrt.SvStr("Class"), args...)
+		g.write(g.funcName(className+"::"+e.Method) + "(")
+		g.write(fmt.Sprintf("perlrt.SvStr(%q)", className))
 		for _, arg := range e.Args {
 			g.write(", ")
 			g.generateExpression(arg)
@@ -1353,7 +1425,7 @@ func (g *Generator) GenerateMethodCall_test(e *ast.MethodCall) {
 		// $obj->method() - need to look up method based on blessed package
 		// For simplicity, we'll need runtime method dispatch
 		// For now, generate direct call if we know the type
-		g.write("perl_method_call(")
+		g.write("perlrt.Perl_method_call(")
 		g.generateExpression(e.Object)
 		g.write(fmt.Sprintf(", %q", e.Method))
 		for _, arg := range e.Args {
@@ -1367,137 +1439,159 @@ func (g *Generator) GenerateMethodCall_test(e *ast.MethodCall) {
 func (g *Generator) generateArrowAccess(expr *ast.ArrowAccess) {
 	switch right := expr.Right.(type) {
 	case *ast.ArrayAccess:
-		g.write("svAGet(")
+		g.write("perlrt.SvAGet(")
 		g.generateExpression(expr.Left)
 		g.write(", ")
 		g.generateExpression(right.Index)
 		g.write(")")
 	case *ast.HashAccess:
-		g.write("svHGet(")
+		g.write("perlrt.SvHGet(")
 		g.generateExpression(expr.Left)
 		g.write(", ")
 		g.generateExpression(right.Key)
 		g.write(")")
+	case *ast.CallExpr:
+		// $code->(args)
+		g.write("perlrt.CallCode(")
+		g.generateExpression(expr.Left)
+		g.generateCallArgs(right.Args, true)
+		g.write(")")
 	default:
 		g.generateExpression(expr.Left)
 	}
 }
 
 func (g *Generator) generateInterpolatedString(s string) {
-	g.write("func() *SV { var _s string; ")
-
-	i := 0
-	for i < len(s) {
-		if s[i] == '$' {
-			j := i + 1
-
-			// ${var}
-			if j < len(s) && s[j] == '{' {
-				k := j + 1
-				for k < len(s) && s[k] != '}' {
-					k++
-				}
-				varName := s[j+1 : k]
-				g.write("_s += " + g.scalarName(varName) + ".AsString(); ")
-				i = k + 1
-				continue
-			}
+	g.write("func() *perlrt.SV { var _s string; ")
+	for _, seg := range interpolate.Parse(s) {
+		switch {
+		case seg.Expr == nil:
+			g.write(fmt.Sprintf("_s += %q; ", seg.Text))
+		case seg.List:
+			// "@a" - элементы через пробел
+			g.write("_s += perlrt.JoinList(")
+			g.generateExpression(seg.Expr)
+			g.write("); ")
+		default:
+			g.write("_s += ")
+			g.generateStr(seg.Expr)
+			g.write("; ")
+		}
+	}
+	g.write("return perlrt.SvStr(_s) }()")
+}
 
-			// $var[idx] - элемент массива
-			// Сначала читаем имя переменной
-			for j < len(s) && (isAlnum(s[j]) || s[j] == '_') {
-				j++
-			}
-			varName := s[i+1 : j]
+func (g *Generator) generateOpenStatement(expr *ast.CallExpr) {
+	if len(expr.Args) < 2 {
+		return
+	}
 
-			if varName != "" && j < len(s) && s[j] == '[' {
-				// Это $arr[idx]
-				k := j + 1
-				for k < len(s) && s[k] != ']' {
-					k++
-				}
-				idxStr := s[j+1 : k]
-				g.write("_s += svAGet(" + g.arrayName(varName) + ", svInt(" + idxStr + ")).AsString(); ")
-				i = k + 1
-				continue
-			}
+	// Declare or assign filehandle variable
+	g.declareFileHandle(expr.Args[0])
 
-			if varName != "" && j < len(s) && s[j] == '{' {
-				// Это $hash{key}
-				k := j + 1
-				for k < len(s) && s[k] != '}' {
-					k++
-				}
-				keyStr := s[j+1 : k]
-				g.write("_s += svHGet(" + g.hashName(varName) + ", svStr(\"" + keyStr + "\")).AsString(); ")
-				i = k + 1
-				continue
-			}
+	g.write(strings.Repeat("\t", g.indent))
+	g.generateOpenCall(expr.Args)
+	g.write("\n")
+}
 
-			// Простая переменная $var
-			if varName != "" {
-				// Capture group $1, $2, etc.
-				if len(varName) > 0 && varName[0] >= '1' && varName[0] <= '9' {
-					g.write("_s += _getCapture(" + varName + "); ")
-				} else {
-					g.write("_s += " + g.scalarName(varName) + ".AsString(); ")
-				}
-			}
-			i = j
-		} else if s[i] == '@' {
-			// @array
-			j := i + 1
-			for j < len(s) && (isAlnum(s[j]) || s[j] == '_') {
-				j++
-			}
-			varName := s[i+1 : j]
-			if varName != "" {
-				g.write("_s += func() string { var _parts []string; for _, _el := range " + g.arrayName(varName) + ".av { _parts = append(_parts, _el.AsString()) }; return strings.Join(_parts, \" \") }(); ")
+// generateOpenCall emits open(FH, MODE, ...): perlOpenTemp for an undef
+// file, otherwise perlOpen with the rest of the arguments, a file, a
+// \$scalar or a command with its arguments.
+func (g *Generator) generateOpenCall(args []ast.Expression) {
+	if len(args) >= 3 {
+		if _, ok := args[2].(*ast.UndefLiteral); ok {
+			g.write("perlrt.PerlOpenTemp(")
+			g.generateExpression(args[0])
+			g.write(".AsString(), ")
+			g.generateExpression(args[1])
+			g.write(".AsString())")
+			return
+		}
+	}
+	g.write("perlrt.PerlOpen(")
+	g.generateExpression(args[0])
+	g.write(".AsString(), ")
+	g.generateExpression(args[1])
+	g.write(".AsString()")
+	for _, a := range args[2:] {
+		if a != nil {
+			g.write(", ")
+			g.generateExpression(a)
+		}
+	}
+	g.write(")")
+}
+
+// declareFileHandle puts a new glob in a lexical filehandle ($fh); the
+// glob's string, GLOB(0x...), is the handle name.
+func (g *Generator) declareFileHandle(fh ast.Expression) {
+	sv, ok := fh.(*ast.ScalarVar)
+	if !ok {
+		return
+	}
+	name := g.scalarName(sv.Name)
+	if !g.isDeclared(name) {
+		g.writeln(name + " := perlrt.NewGlob()")
+		g.writeln("_ = " + name)
+		g.declare(name)
+	} else {
+		g.writeln(name + " = perlrt.GlobFor(" + name + ")")
+	}
+}
+
+// declareOpenHandles declares the handles of open/sysopen calls nested in expr,
+// e.g. "sysopen(my $fh, ...) or die" or "if (open(my $fh, ...))".
+func (g *Generator) declareOpenHandles(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if ident, ok := e.Function.(*ast.Identifier); ok && (ident.Value == "open" || ident.Value == "sysopen") {
+			if len(e.Args) > 0 {
+				g.declareFileHandle(e.Args[0])
 			}
-			i = j
-		} else {
-			// Literal text
-			j := i
-			for j < len(s) && s[j] != '$' && s[j] != '@' {
-				j++
+		}
+		if ident, ok := e.Function.(*ast.Identifier); ok && ident.Value == "open3" {
+			for idx := 0; idx < 3 && idx < len(e.Args); idx++ {
+				g.declareFileHandle(open3Handle(e.Args[idx]))
 			}
-			g.write(fmt.Sprintf("_s += %q; ", s[i:j]))
-			i = j
 		}
+	case *ast.InfixExpr:
+		g.declareOpenHandles(e.Left)
+	case *ast.PrefixExpr:
+		g.declareOpenHandles(e.Right)
 	}
-
-	g.write("return svStr(_s) }()")
 }
 
-func (g *Generator) generateOpenStatement(expr *ast.CallExpr) {
-	if len(expr.Args) < 2 {
+// declareSubstTarget declares $x of "(my $x = $y) =~ s///" (or tr///)
+// before the statement, since the substitution itself runs inside a closure.
+func (g *Generator) declareSubstTarget(expr ast.Expression) {
+	var target ast.Expression
+	switch e := expr.(type) {
+	case *ast.SubstExpr:
+		target = e.Target
+	case *ast.TransExpr:
+		target = e.Target
+	default:
 		return
 	}
-
-	// Declare or assign filehandle variable
-	if sv, ok := expr.Args[0].(*ast.ScalarVar); ok {
-		name := g.scalarName(sv.Name)
-		if !g.declaredVars[name] {
-			g.writeln(name + " := svStr(\"" + sv.Name + "\")")
+	assign, ok := target.(*ast.AssignExpr)
+	if !ok {
+		return
+	}
+	if v, ok := assign.Left.(*ast.ScalarVar); ok {
+		name := g.scalarName(v.Name)
+		if !g.isDeclared(name) {
+			g.writeln("var " + name + " *perlrt.SV")
 			g.writeln("_ = " + name)
-			g.declaredVars[name] = true
-		} else {
-			g.writeln(name + " = svStr(\"" + sv.Name + "\")")
+			g.declare(name)
 		}
 	}
+}
 
-	// Call perlOpen
-	g.write(strings.Repeat("\t", g.indent))
-	g.write("perlOpen(")
-	g.generateExpression(expr.Args[0])
-	g.write(".AsString(), ")
-	g.generateExpression(expr.Args[1])
-	g.write(".AsString(), ")
-	if len(expr.Args) >= 3 && expr.Args[2] != nil {
-		g.generateExpression(expr.Args[2])
-		g.write(".AsString()")
-	} else {
-		g.write("\"\"")
+// open3Handle returns the handle variable of an open3 argument,
+// looking through "my $err = gensym".
+func open3Handle(expr ast.Expression) ast.Expression {
+	if assign, ok := expr.(*ast.AssignExpr); ok {
+		return assign.Left
 	}
-	g.write(")\n")
+	return expr
 }
@@ -3,37 +3,62 @@ package codegen
 import (
 	"fmt"
 	"perlc/pkg/ast"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/perlrt"
+	"perlc/pkg/version"
+	"strconv"
 	"strings"
 )
 
 func (g *Generator) generateExpression(expr ast.Expression) {
 	switch e := expr.(type) {
 	case *ast.IntegerLiteral:
-		g.write(fmt.Sprintf("svInt(%d)", e.Value))
+		g.write(fmt.Sprintf("perlrt.SvInt(%d)", e.Value))
 	case *ast.FloatLiteral:
-		g.write(fmt.Sprintf("svFloat(%f)", e.Value))
+		// an integer literal past int64 is perl's unsigned integer
+		if u, err := strconv.ParseUint(e.Token.Value, 0, 64); err == nil && g.PerlCompatNumbers {
+			g.write(fmt.Sprintf("perlrt.SvUint(%d)", u))
+			return
+		}
+		// every digit: %f would round 1.5e-7 to 0
+		g.write("perlrt.SvFloat(" + strconv.FormatFloat(e.Value, 'g', -1, 64) + ")")
 	case *ast.StringLiteral:
 		if e.Interpolated {
 			g.generateInterpolatedString(e.Value)
 		} else {
-			g.write(fmt.Sprintf("svStr(%q)", e.Value))
+			g.write(fmt.Sprintf("perlrt.SvStr(%q)", e.Value))
 		}
 	case *ast.ScalarVar:
-		g.write(g.scalarName(e.Name))
+		g.write(g.scalarValue(e.Name))
 	case *ast.ArrayVar:
 		g.write(g.arrayName(e.Name))
 	case *ast.HashVar:
 		g.write(g.hashName(e.Name))
+	case *ast.GlobVar:
+		g.write(fmt.Sprintf("perlrt.SvStr(%q)", "*main::"+e.Name))
 	case *ast.SpecialVar:
 		if e.Name == "@_" {
-			g.write("svArray(args...)")
+			g.write("_args")
 		} else if e.Name == "$_" {
 			g.write("v__") // default variable
+		} else if e.Name == "$?" {
+			g.write("perlrt.SvInt(perlrt.ChildStatus)")
+		} else if e.Name == "$!" {
+			g.write("perlrt.SvStr(perlrt.OsError)")
+		} else if e.Name == "$@" {
+			g.write("perlrt.EvalError")
 		} else if len(e.Name) >= 2 && e.Name[0] == '$' && e.Name[1] >= '1' && e.Name[1] <= '9' {
 			// Capture group $1, $2, ..., $99, etc.
-			g.write(fmt.Sprintf("svStr(_getCapture(%s))", e.Name[1:]))
+			g.write(fmt.Sprintf("perlrt.Capture(%s)", e.Name[1:]))
+		} else if v, ok := matchVars[e.Name]; ok {
+			g.write(v)
+		} else if v, ok := specialVars[e.Name]; ok {
+			g.write(v)
+		} else if v, ok := version.PerlVars[e.Name]; ok {
+			g.write("perlrt.SvStr(" + strconv.Quote(v) + ")")
 		} else {
-			g.write("svUndef()")
+			g.write("perlrt.SvUndef()")
 		}
 	case *ast.PrefixExpr:
 		g.generatePrefixExpr(e)
@@ -44,8 +69,8 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 	case *ast.AssignExpr:
 		g.generateAssignExpr(e)
 	case *ast.TernaryExpr:
-		g.write("func() *SV { if (")
-		g.generateExpression(e.Condition)
+		g.write("func() *perlrt.SV { if (")
+		g.generateScalarExpression(e.Condition)
 		g.write(").IsTrue() { return ")
 		g.generateExpression(e.Then)
 		g.write(" } else { return ")
@@ -54,7 +79,14 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 	case *ast.CallExpr:
 		g.generateCallExpr(e)
 	case *ast.ArrayExpr:
-		g.write("svArray(")
+		if e.Token.Value == "[" && !allScalarValues(e.Elements) {
+			// [@a, f()] holds the values of arrays, hashes and lists
+			g.write("perlrt.SvArray(")
+			g.generateListElements(e.Elements)
+			g.write("...)")
+			return
+		}
+		g.write("perlrt.SvArray(")
 		for i, el := range e.Elements {
 			if i > 0 {
 				g.write(", ")
@@ -65,9 +97,9 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 	case *ast.HashExpr:
 		g.tempCount++
 		hvar := fmt.Sprintf("_h%d", g.tempCount)
-		g.write("func() *SV { " + hvar + " := svHash(); ")
+		g.write("func() *perlrt.SV { " + hvar + " := perlrt.SvHash(); ")
 		for _, p := range e.Pairs {
-			g.write("svHSet(" + hvar + ", ")
+			g.write("perlrt.SvHSet(" + hvar + ", ")
 			g.generateExpression(p.Key)
 			g.write(", ")
 			g.generateExpression(p.Value)
@@ -75,10 +107,15 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 		}
 		g.write("return " + hvar + " }()")
 	case *ast.ArrayAccess:
-		g.write("svAGet(")
+		if g.generateInlineArg(e) {
+			return
+		}
+		g.write("perlrt.SvAGet(")
 		// $arr[0] means access to @arr element
 		if sv, ok := e.Array.(*ast.ScalarVar); ok {
 			g.write(g.arrayName(sv.Name))
+		} else if sv, ok := e.Array.(*ast.SpecialVar); ok && sv.Name == "$_" {
+			g.write("_args") // $_[0] is an element of @_
 		} else {
 			g.generateExpression(e.Array)
 		}
@@ -86,7 +123,14 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 		g.generateExpression(e.Index)
 		g.write(")")
 	case *ast.HashAccess:
-		g.write("svHGet(")
+		if v, ok := e.Hash.(*ast.SpecialVar); ok && v.Name == "%+" {
+			// $+{name}: a named group of the last match
+			g.write("perlrt.NamedCapture(")
+			g.generateStr(e.Key)
+			g.write(")")
+			return
+		}
+		g.write("perlrt.SvHGet(")
 		// $h{key} means access to %h element
 		if sv, ok := e.Hash.(*ast.ScalarVar); ok {
 			g.write(g.hashName(sv.Name))
@@ -96,55 +140,201 @@ func (g *Generator) generateExpression(expr ast.Expression) {
 		g.write(", ")
 		g.generateExpression(e.Key)
 		g.write(")")
+	case *ast.ArraySlice:
+		g.write("perlrt.SvASlice(")
+		g.generateExpression(e.Array)
+		g.write(", ")
+		g.generateSliceList(e.Indices)
+		g.write(")")
+	case *ast.HashSlice:
+		g.write("perlrt.SvHSlice(")
+		g.generateExpression(e.Hash)
+		g.write(", ")
+		g.generateSliceList(e.Keys)
+		g.write(")")
 	case *ast.ArrowAccess:
 		g.generateArrowAccess(e)
 	case *ast.MethodCall:
 		g.generateMethodCall(e)
 	case *ast.Identifier:
-		g.write(fmt.Sprintf("svStr(%q)", e.Value))
+		if g.generateConstant(e.Value) {
+			return
+		}
+		if _, ok := perlrt.FcntlConstants[e.Value]; ok {
+			// looked up at run time: O_* differ between platforms
+			g.write(fmt.Sprintf("perlrt.SvInt(perlrt.FcntlConstants[%q])", e.Value))
+		} else if v, ok := waitConstants[e.Value]; ok {
+			g.write(fmt.Sprintf("perlrt.SvInt(%d)", v))
+		} else if c, ok := g.posixConstant(e.Value); ok {
+			g.write(c)
+		} else if call, ok := bareCalls[e.Value]; ok {
+			g.write(call)
+		} else if g.hiRes[e.Value] {
+			// my $t0 = [gettimeofday];
+			g.generateCallExpr(&ast.CallExpr{Token: e.Token, Function: e})
+		} else {
+			g.write(fmt.Sprintf("perlrt.SvStr(%q)", e.Value))
+		}
 	case *ast.RangeExpr:
 		g.generateRangeExpr(e)
+	case *ast.AnonSubExpr:
+		g.generateAnonSub(e)
 	case *ast.UndefLiteral:
-		g.write("svUndef()")
+		g.write("perlrt.SvUndef()")
 	case *ast.MatchExpr:
 		g.generateMatchExpr(e)
+	case *ast.DoBlockExpr:
+		g.generateDoBlock(e)
+	case *ast.EvalBlockExpr:
+		g.generateEvalBlock(e)
+	case *ast.EvalStringExpr:
+		g.generateEvalString(e)
+	case *ast.QrExpr:
+		g.generateQrExpr(e)
 	case *ast.SubstExpr:
 		g.generateSubstExpr(e)
+	case *ast.TransExpr:
+		g.generateTransExpr(e)
 	case *ast.ReadLineExpr:
 		g.generateReadLineExpr(e)
+	case *ast.CommandExpr:
+		g.write("perlrt.PerlCommand(")
+		g.generateExpression(e.Command)
+		g.write(")")
 	case *ast.RefExpr:
 		g.generateRefExpr(e)
 	case *ast.DerefExpr:
 		g.generateDerefExpr(e)
 	default:
-		g.write("svUndef()")
+		g.write("perlrt.SvUndef()")
+	}
+}
+
+// generateScalarExpression generates expr in scalar context.
+// glob() iterates per call site; getpw*/getgr* return a single field;
+// localtime/gmtime return a ctime string or a Time::Piece object.
+func (g *Generator) generateScalarExpression(expr ast.Expression) {
+	if g.isGettimeofday(expr) {
+		// seconds with the fraction
+		g.write("perlrt.SvFloat(perlrt.HiResNow())")
+		return
+	}
+	if call, ok := expr.(*ast.CallExpr); ok {
+		if ident, ok := call.Function.(*ast.Identifier); ok {
+			switch ident.Value {
+			case "localtime", "gmtime":
+				g.write(fmt.Sprintf("perlrt.TimeScalar(%t, %t", ident.Value == "gmtime", g.timePiece))
+				for _, a := range call.Args {
+					g.write(", ")
+					g.generateExpression(a)
+				}
+				g.write(")")
+				return
+			case "unpack":
+				// the first value
+				g.write("perlrt.ListAt(perlrt.ListOf(")
+				g.generateExpression(call)
+				g.write("), 0)")
+				return
+			case "keys", "values":
+				// the count, without listing the keys
+				if len(call.Args) == 0 {
+					g.write("perlrt.SvInt(0)")
+					return
+				}
+				g.write("perlrt.HashLen(")
+				g.generateExpression(call.Args[0])
+				g.write(")")
+				return
+			}
+		}
 	}
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) > 0 {
+		if ident, ok := call.Function.(*ast.Identifier); ok {
+			switch ident.Value {
+			case "glob":
+				g.tempCount++
+				g.write(fmt.Sprintf("perlrt.Perl_glob_next(%d, ", g.tempCount))
+				g.generateExpression(call.Args[0])
+				g.write(")")
+				return
+			case "getpwnam", "getgrnam":
+				// by name -> id
+				g.write("perlrt.IdField(")
+				g.generateExpression(expr)
+				g.write(", 2)")
+				return
+			case "getpwuid", "getgrgid":
+				// by id -> name
+				g.write("perlrt.IdField(")
+				g.generateExpression(expr)
+				g.write(", 0)")
+				return
+			}
+		}
+	}
+	g.generateExpression(expr)
+}
+
+// generateScalarValue emits the value of an operand or of a scalar
+// assignment: an array or hash is its count (@a + 0, my $n = @a), not a
+// reference to it
+func (g *Generator) generateScalarValue(e ast.Expression) {
+	if isAggregate(e) {
+		g.write("perlrt.Perl_scalar(")
+		g.generateExpression(e)
+		g.write(")")
+		return
+	}
+	g.generateScalarExpression(e)
 }
 
 func (g *Generator) generatePrefixExpr(expr *ast.PrefixExpr) {
+	// -bareword is the string "-bareword", as -norequire of use parent;
+	// one letter is a file test, -e -d -f
+	if id, ok := expr.Right.(*ast.Identifier); ok && expr.Operator == "-" && len(id.Value) > 1 && g.constants[id.Value] == nil {
+		g.write(fmt.Sprintf("perlrt.SvStr(%q)", "-"+id.Value))
+		return
+	}
 	switch expr.Operator {
 	case "-":
-		g.write("svNeg(")
+		g.write("perlrt.SvNeg(")
 		g.generateExpression(expr.Right)
 		g.write(")")
 	case "!":
-		g.write("svNot(")
+		g.write("perlrt.SvNot(")
 		g.generateExpression(expr.Right)
 		g.write(")")
 	case "not":
-		g.write("svNot(")
+		g.write("perlrt.SvNot(")
+		g.generateExpression(expr.Right)
+		g.write(")")
+	case "~":
+		g.write("perlrt.SvBitNot(")
 		g.generateExpression(expr.Right)
 		g.write(")")
 	case "++":
 		// Pre-increment
-		if v, ok := expr.Right.(*ast.ScalarVar); ok {
+		if isIntExpr(expr, g.natives) {
+			g.write("perlrt.SvInt(")
+			g.generateInt(expr)
+			g.write(")")
+		} else if v, ok := expr.Right.(*ast.ScalarVar); ok {
 			name := g.scalarName(v.Name)
-			g.write("func() *SV { " + name + " = svAdd(" + name + ", svInt(1)); return " + name + " }()")
+			g.write("func() *perlrt.SV { " + name + " = perlrt.SvInc(" + name + "); return " + name + " }()")
+		} else if isElement(expr.Right) {
+			g.generateUpdate(expr.Right, "perlrt.SvInc", nil, false)
 		}
 	case "--":
-		if v, ok := expr.Right.(*ast.ScalarVar); ok {
+		if isIntExpr(expr, g.natives) {
+			g.write("perlrt.SvInt(")
+			g.generateInt(expr)
+			g.write(")")
+		} else if v, ok := expr.Right.(*ast.ScalarVar); ok {
 			name := g.scalarName(v.Name)
-			g.write("func() *SV { " + name + " = svSub(" + name + ", svInt(1)); return " + name + " }()")
+			g.write("func() *perlrt.SV { " + name + " = perlrt.SvDec(" + name + "); return " + name + " }()")
+		} else if isElement(expr.Right) {
+			g.generateUpdate(expr.Right, "perlrt.SvDec", nil, false)
 		}
 	default:
 		g.generateExpression(expr.Right)
@@ -152,76 +342,182 @@ func (g *Generator) generatePrefixExpr(expr *ast.PrefixExpr) {
 }
 
 func (g *Generator) generatePostfixExpr(expr *ast.PostfixExpr) {
+	if isIntExpr(expr, g.natives) {
+		g.write("perlrt.SvInt(")
+		g.generateInt(expr)
+		g.write(")")
+		return
+	}
 	switch expr.Operator {
 	case "++":
 		if v, ok := expr.Left.(*ast.ScalarVar); ok {
 			name := g.scalarName(v.Name)
-			g.write("func() *SV { _t := " + name + "; " + name + " = svAdd(" + name + ", svInt(1)); return _t }()")
+			g.write("func() *perlrt.SV { _t := " + name + "; " + name + " = perlrt.SvInc(" + name + "); return perlrt.SvPostInc(_t) }()")
+		} else if isElement(expr.Left) {
+			g.generateUpdate(expr.Left, "perlrt.SvInc", nil, true)
 		}
 	case "--":
 		if v, ok := expr.Left.(*ast.ScalarVar); ok {
 			name := g.scalarName(v.Name)
-			g.write("func() *SV { _t := " + name + "; " + name + " = svSub(" + name + ", svInt(1)); return _t }()")
+			g.write("func() *perlrt.SV { _t := " + name + "; " + name + " = perlrt.SvDec(" + name + "); return _t }()")
+		} else if isElement(expr.Left) {
+			g.generateUpdate(expr.Left, "perlrt.SvDec", nil, true)
 		}
 	}
 }
 
-func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
-	if ident, ok := expr.Function.(*ast.Identifier); ok {
-		name := ident.Value
-		switch name {
-		case "print":
-			// Check if first arg is filehandle
-			if len(expr.Args) >= 2 {
-				if _, ok := expr.Args[0].(*ast.ScalarVar); ok {
-					// print $fh "text" form
-					g.write("perlPrintFH(")
-					g.generateExpression(expr.Args[0])
-					g.write(".AsString()")
-					for _, a := range expr.Args[1:] {
-						g.write(", ")
-						g.generateExpression(a)
-					}
-					g.write(")")
-					return
-				}
-			}
-			g.write("perlPrint(")
-			for i, a := range expr.Args {
-				if i > 0 {
-					g.write(", ")
-				}
-				g.generateExpression(a)
-			}
+// generatePrint emits print/say LIST, print $fh LIST and print STDERR LIST
+func (g *Generator) generatePrint(args []ast.Expression, say bool) {
+	fn := "perlrt.PerlPrint("
+	if say {
+		fn = "perlrt.PerlSay("
+	}
+	if len(args) >= 2 {
+		switch fh := args[0].(type) {
+		case *ast.BlockFilehandle:
+			// print {$h{fh}} "text" form
+			g.write(fn[:len(fn)-1] + "FH(perlrt.HandleName(")
+			g.generateExpression(fh.Expr)
+			g.write("), ")
+			g.generatePrintArgs(args[1:])
 			g.write(")")
-		case "say":
-			// Check if first arg is filehandle
-			if len(expr.Args) >= 2 {
-				if _, ok := expr.Args[0].(*ast.ScalarVar); ok {
-					// say $fh "text" form
-					g.write("perlSayFH(")
-					g.generateExpression(expr.Args[0])
-					g.write(".AsString()")
-					for _, a := range expr.Args[1:] {
-						g.write(", ")
-						g.generateExpression(a)
-					}
-					g.write(")")
-					return
-				}
+			return
+		case *ast.ScalarVar:
+			// print $fh "text" form, or print $x, "text" when $x is no handle
+			g.write("perlrt.PrintTo(")
+			g.generateExpression(fh)
+			g.write(", " + strconv.FormatBool(say) + ", ")
+			g.generatePrintArgs(args[1:])
+			g.write(")")
+			return
+		case *ast.Identifier:
+			// print STDERR "text" form
+			fn = fmt.Sprintf("perlrt.PerlPrintFH(%q, ", fh.Value)
+			if say {
+				fn = fmt.Sprintf("perlrt.PerlSayFH(%q, ", fh.Value)
 			}
-			g.write("perlSay(")
-			for i, a := range expr.Args {
-				if i > 0 {
-					g.write(", ")
-				}
-				g.generateExpression(a)
+			args = args[1:]
+		}
+	}
+	g.write(fn)
+	g.generatePrintArgs(args)
+	g.write(")")
+}
+
+// generatePrintf emits printf, to a handle in the forms of generatePrint.
+// $x first is the handle only when it holds one: printf $fmt, @values
+func (g *Generator) generatePrintf(args []ast.Expression) {
+	loc := ""
+	if g.numericWarn {
+		loc = g.where()
+	}
+	if len(args) >= 2 {
+		switch fh := args[0].(type) {
+		case *ast.BlockFilehandle:
+			g.write("perlrt.PrintfFH(perlrt.HandleName(")
+			g.generateExpression(fh.Expr)
+			g.write("), ")
+			g.generateSprintf(loc, args[1:])
+			g.write(")")
+			return
+		case *ast.Identifier:
+			if g.constants[fh.Value] == nil {
+				g.write(fmt.Sprintf("perlrt.PrintfFH(%q, ", fh.Value))
+				g.generateSprintf(loc, args[1:])
+				g.write(")")
+				return
 			}
+		case *ast.ScalarVar:
+			g.write(fmt.Sprintf("perlrt.PrintfTo(%q, ", loc))
+			g.generateExpression(fh)
+			g.write(", ")
+			g.generatePrintArgs(args[1:])
 			g.write(")")
+			return
+		}
+	}
+	if loc != "" {
+		g.write("perlrt.PrintfWarn(" + strconv.Quote(loc))
+		if len(args) > 0 {
+			g.write(", ")
+		}
+	} else {
+		g.write("perlrt.Perl_printf(")
+	}
+	g.generatePrintArgs(args)
+	g.write(")")
+}
+
+// generateSprintf emits the string printf writes: sprintf of args, with
+// the warnings of SprintfWarn where loc is set
+func (g *Generator) generateSprintf(loc string, args []ast.Expression) {
+	if loc != "" {
+		g.write("perlrt.SprintfWarn(\"printf\", " + strconv.Quote(loc) + ", ")
+	} else {
+		g.write("perlrt.Perl_sprintf(")
+	}
+	g.generatePrintArgs(args)
+	g.write(")")
+}
+
+// generatePrintArgs emits the values print writes. Arrays, hashes and lists
+// from calls are flattened into their elements, so $, goes between them;
+// a list of plain scalars is passed as it is.
+func (g *Generator) generatePrintArgs(args []ast.Expression) {
+	for _, a := range args {
+		if !isScalarValue(a) && !isStrExpr(a, g.natives) && !isNumericExpr(a, g.natives) {
+			g.generateListValues(&ast.ArrayExpr{Elements: args})
+			g.write("...")
+			return
+		}
+	}
+	for i, a := range args {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.generateExpression(a)
+	}
+}
+
+func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
+	if ident, ok := expr.Function.(*ast.Identifier); ok {
+		name := ident.Value
+		if g.hiRes[name] {
+			name = "Time::HiRes::" + name
+		}
+		if g.carp[name] {
+			name = "Carp::" + name
+		}
+		if g.scalarUtil[name] {
+			name = "Scalar::Util::" + name
+		}
+		if g.listUtil[name] {
+			name = "List::Util::" + name
+		}
+		if g.posix[name] {
+			name = "POSIX::" + name
+		}
+		if c, ok := g.posixConstant(name); ok && len(expr.Args) == 0 {
+			g.write(c)
+			return
+		}
+		if g.getopt[name] {
+			name = getoptFuncs[name] + "::" + name
+		}
+		if g.fileFuncs[name] {
+			name = fileFuncs[name] + "::" + name
+		}
+		if g.storable[name] {
+			name = "Storable::" + name
+		}
+		switch name {
+		case "print", "say":
+			g.generatePrint(expr.Args, name == "say")
 		case "push":
 			if len(expr.Args) >= 1 {
-				if av, ok := expr.Args[0].(*ast.ArrayVar); ok {
-					g.write("svPush(" + g.arrayName(av.Name))
+				if isArrayOperand(expr.Args[0]) {
+					g.write("perlrt.SvPush(")
+					g.generateArrayOperand(expr.Args[0])
 					for _, a := range expr.Args[1:] {
 						g.write(", ")
 						g.generateExpression(a)
@@ -230,27 +526,32 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 					return
 				}
 			}
-			g.write("svUndef()")
+			g.write("perlrt.SvUndef()")
 		case "pop":
 			if len(expr.Args) >= 1 {
-				if av, ok := expr.Args[0].(*ast.ArrayVar); ok {
-					g.write("svPop(" + g.arrayName(av.Name) + ")")
+				if isArrayOperand(expr.Args[0]) {
+					g.write("perlrt.SvPop(")
+					g.generateArrayOperand(expr.Args[0])
+					g.write(")")
 					return
 				}
 			}
-			g.write("svUndef()")
+			g.write("perlrt.SvUndef()")
 		case "shift":
 			if len(expr.Args) >= 1 {
-				if av, ok := expr.Args[0].(*ast.ArrayVar); ok {
-					g.write("svShift(" + g.arrayName(av.Name) + ")")
+				if isArrayOperand(expr.Args[0]) {
+					g.write("perlrt.SvShift(")
+					g.generateArrayOperand(expr.Args[0])
+					g.write(")")
 					return
 				}
 			}
-			g.write("svShift(_args)")
+			g.write("perlrt.SvShift(_args)")
 		case "unshift":
 			if len(expr.Args) >= 1 {
-				if av, ok := expr.Args[0].(*ast.ArrayVar); ok {
-					g.write("svUnshift(" + g.arrayName(av.Name))
+				if isArrayOperand(expr.Args[0]) {
+					g.write("perlrt.SvUnshift(")
+					g.generateArrayOperand(expr.Args[0])
 					for _, a := range expr.Args[1:] {
 						g.write(", ")
 						g.generateExpression(a)
@@ -259,95 +560,131 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 					return
 				}
 			}
-			g.write("svUndef()")
+			g.write("perlrt.SvUndef()")
 		case "length":
 			if len(expr.Args) >= 1 {
-				g.write("perlLength(")
+				g.write("perlrt.PerlLength(")
 				g.generateExpression(expr.Args[0])
 				g.write(")")
 			} else {
-				g.write("svInt(0)")
+				g.write("perlrt.SvInt(0)")
 			}
 		case "uc":
-			g.write("perlUc(")
+			g.write("perlrt.PerlUc(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "lc":
-			g.write("perlLc(")
+			g.write("perlrt.PerlLc(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "abs":
-			g.write("perlAbs(")
-			g.generateExpression(expr.Args[0])
+			g.write("perlrt.PerlAbs(")
+			g.generateNumArg("abs", expr.Args[0])
 			g.write(")")
 		case "int":
-			g.write("perlInt(")
-			g.generateExpression(expr.Args[0])
+			g.write("perlrt.PerlInt(")
+			g.generateNumArg("int", expr.Args[0])
 			g.write(")")
 		case "sqrt":
-			g.write("perlSqrt(")
-			g.generateExpression(expr.Args[0])
+			g.write("perlrt.PerlSqrt(")
+			g.generateNumArg("sqrt", expr.Args[0])
 			g.write(")")
 		case "chr":
-			g.write("perlChr(")
-			g.generateExpression(expr.Args[0])
+			g.write("perlrt.PerlChr(")
+			g.generateNumArg("chr", expr.Args[0])
 			g.write(")")
 		case "ord":
-			g.write("perlOrd(")
+			g.write("perlrt.PerlOrd(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "scalar":
 			if len(expr.Args) >= 1 {
-				g.write("perl_scalar(")
-				g.generateExpression(expr.Args[0])
+				g.write("perlrt.Perl_scalar(")
+				g.generateScalarExpression(expr.Args[0])
 				g.write(")")
 			} else {
-				g.write("svUndef()")
+				g.write("perlrt.SvUndef()")
 			}
 		case "keys":
 			if len(expr.Args) >= 1 {
-				g.write("perl_keys(")
+				g.write("perlrt.Perl_keys(")
 				g.generateExpression(expr.Args[0])
 				g.write(")")
 			} else {
-				g.write("svArray()")
+				g.write("perlrt.SvArray()")
 			}
 		case "join":
 			if len(expr.Args) >= 2 {
-				g.write("perl_join(")
+				g.write("perlrt.Perl_join(")
 				g.generateExpression(expr.Args[0])
 				g.write(", ")
 				g.generateExpression(expr.Args[1])
 				g.write(")")
 			} else {
-				g.write("svStr(\"\")")
+				g.write("perlrt.SvStr(\"\")")
 			}
 		case "ref":
 			if len(expr.Args) >= 1 {
-				g.write("perl_ref(")
+				g.write("perlrt.Perl_ref(")
 				g.generateExpression(expr.Args[0])
 				g.write(")")
 			} else {
-				g.write("svStr(\"\")")
+				g.write("perlrt.SvStr(\"\")")
 			}
 		case "open":
 			if len(expr.Args) >= 2 {
-				g.write("perlOpen(")
+				g.generateOpenCall(expr.Args)
+			}
+		case "readlink", "unlink", "mkdir", "rmdir":
+			// without arguments they work on $_
+			g.write(g.funcName(name) + "(")
+			if len(expr.Args) == 0 {
+				g.write("v__")
+			}
+			for i, a := range expr.Args {
+				if i > 0 {
+					g.write(", ")
+				}
+				g.generateExpression(a)
+			}
+			g.write(")")
+		case "stat", "lstat":
+			// stat without arguments reads $_
+			g.write(g.funcName(name) + "(")
+			if len(expr.Args) > 0 {
 				g.generateExpression(expr.Args[0])
-				g.write(".AsString(), ")
-				g.generateExpression(expr.Args[1])
-				g.write(".AsString(), ")
-				if len(expr.Args) >= 3 && expr.Args[2] != nil {
-					g.generateExpression(expr.Args[2])
-					g.write(".AsString()")
-				} else {
-					g.write("\"\"")
+			} else {
+				g.write("v__")
+			}
+			g.write(")")
+		case "open3":
+			// Handles are passed by name; undef/"" error handle merges stderr into stdout
+			g.write("perlrt.Perl_open3(")
+			for idx, a := range expr.Args {
+				if idx > 0 {
+					g.write(", ")
 				}
-				g.write(")")
+				if idx < 3 {
+					switch h := open3Handle(a).(type) {
+					case *ast.ScalarVar:
+						if v := g.scalarName(h.Name); g.isDeclared(v) {
+							g.write(v)
+						} else {
+							g.write(fmt.Sprintf("perlrt.SvStr(%q)", h.Name))
+						}
+					case *ast.Identifier:
+						g.write(fmt.Sprintf("perlrt.SvStr(%q)", h.Value))
+					default:
+						g.write("perlrt.SvStr(\"\")")
+					}
+					continue
+				}
+				g.generateExpression(a)
 			}
+			g.write(")")
 		case "close":
 			if len(expr.Args) >= 1 {
-				g.write("perlClose(")
+				g.write("perlrt.PerlClose(")
 				g.generateExpression(expr.Args[0])
 				g.write(".AsString())")
 			}
@@ -355,7 +692,7 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 			// delete $h{key} - нужно получить хеш и ключ
 			if len(expr.Args) >= 1 {
 				if ha, ok := expr.Args[0].(*ast.HashAccess); ok {
-					g.write("func() *SV { ")
+					g.write("func() *perlrt.SV { ")
 					// Получаем хеш
 					hashName := ""
 					if sv, ok := ha.Hash.(*ast.ScalarVar); ok {
@@ -372,17 +709,20 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 					g.generateExpression(ha.Key)
 					g.write(".AsString(); ")
 					// Сохраняем старое значение
-					g.write("_v := " + hashName + ".hv[_k]; ")
+					g.write("_v := " + hashName + ".HV[_k]; ")
 					// Удаляем
-					g.write("delete(" + hashName + ".hv, _k); ")
+					g.write("delete(" + hashName + ".HV, _k); ")
+					if hashName == "perlrt.H_ENV" {
+						g.write("perlrt.SyncEnv(); ")
+					}
 					// Возвращаем старое значение
 					g.write("return _v }()")
 					return
 				}
 			}
-			g.write("svUndef()")
+			g.write("perlrt.SvUndef()")
 		case "index":
-			g.write("perl_index(")
+			g.write("perlrt.Perl_index(")
 			g.generateExpression(expr.Args[0])
 			g.write(", ")
 			g.generateExpression(expr.Args[1])
@@ -392,7 +732,7 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 			}
 			g.write(")")
 		case "rindex":
-			g.write("perl_rindex(")
+			g.write("perlrt.Perl_rindex(")
 			g.generateExpression(expr.Args[0])
 			g.write(", ")
 			g.generateExpression(expr.Args[1])
@@ -402,27 +742,31 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 			}
 			g.write(")")
 		case "lcfirst":
-			g.write("perl_lcfirst(")
+			g.write("perlrt.Perl_lcfirst(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "ucfirst":
-			g.write("perl_ucfirst(")
+			g.write("perlrt.Perl_ucfirst(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "chop":
 			if len(expr.Args) >= 1 {
 				if sv, ok := expr.Args[0].(*ast.ScalarVar); ok {
-					g.write("perl_chop(" + g.scalarName(sv.Name) + ")")
+					g.write("perlrt.Perl_chop(" + g.scalarName(sv.Name) + ")")
 				} else {
-					g.write("perl_chop(")
+					g.write("perlrt.Perl_chop(")
 					g.generateExpression(expr.Args[0])
 					g.write(")")
 				}
 			} else {
-				g.write("svStr(\"\")")
+				g.write("perlrt.SvStr(\"\")")
 			}
 		case "sprintf":
-			g.write("perl_sprintf(")
+			if g.numericWarn {
+				g.write("perlrt.SprintfWarn(\"sprintf\", " + strconv.Quote(g.where()) + ", ")
+			} else {
+				g.write("perlrt.Perl_sprintf(")
+			}
 			for i, a := range expr.Args {
 				if i > 0 {
 					g.write(", ")
@@ -430,33 +774,72 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 				g.generateExpression(a)
 			}
 			g.write(")")
+		case "printf":
+			g.generatePrintf(expr.Args)
 		case "quotemeta":
-			g.write("perl_quotemeta(")
+			g.write("perlrt.Perl_quotemeta(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "hex":
-			g.write("perl_hex(")
+			g.write("perlrt.Perl_hex(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "oct":
-			g.write("perl_oct(")
+			g.write("perlrt.Perl_oct(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
 		case "fc":
-			g.write("perl_fc(")
+			g.write("perlrt.Perl_fc(")
 			g.generateExpression(expr.Args[0])
 			g.write(")")
+		case "Carp::croak", "Carp::confess", "Carp::carp", "Carp::cluck":
+			g.write(fmt.Sprintf("_carp(%q, %q, ", name, g.where()))
+			g.generateListElements(expr.Args)
+			g.write(")")
+		case "die":
+			// die LIST joins the flattened list; a bare die throws $@ again
+			switch {
+			case len(expr.Args) == 0:
+				g.write(fmt.Sprintf("perlrt.DieAgain(%q)", g.where()))
+			case len(expr.Args) == 1 && !isAggregate(expr.Args[0]):
+				g.write(fmt.Sprintf("perlrt.DieAt(%q, ", g.where()))
+				g.generateExpression(expr.Args[0])
+				g.write(")")
+			default:
+				g.write(fmt.Sprintf("perlrt.DieAt(%q, ", g.where()))
+				g.generateListElements(expr.Args)
+				g.write("...)")
+			}
 		case "pack":
-			g.write("perl_pack(")
-			for i, a := range expr.Args {
-				if i > 0 {
+			// pack TEMPLATE, LIST: the list is flattened
+			g.write("perlrt.Perl_pack(")
+			g.generateExpression(expr.Args[0])
+			g.write(", ")
+			g.generateListElements(expr.Args[1:])
+			g.write(")")
+		case "unpack":
+			// unpack TEMPLATE unpacks $_
+			data := ast.Expression(&ast.ScalarVar{Token: expr.Token, Name: "_"})
+			if len(expr.Args) > 1 {
+				data = expr.Args[1]
+			}
+			g.write("perlrt.Perl_unpack(")
+			g.generateExpression(expr.Args[0])
+			g.write(", ")
+			g.generateExpression(data)
+			g.write(")")
+		case "split":
+			// split /re/, ... компилирует шаблон; строка или qr// - в perl_split
+			if lit, ok := expr.Args[0].(*ast.RegexLiteral); ok && len(expr.Args) > 0 {
+				g.write("perlrt.Perl_split_re(" + g.regexExpr(lit.Pattern, lit.Flags, lit.Token))
+				for _, a := range expr.Args[1:] {
 					g.write(", ")
+					g.generateExpression(a)
 				}
-				g.generateExpression(a)
+				g.write(")")
+				return
 			}
-			g.write(")")
-		case "unpack":
-			g.write("perl_unpack(")
+			g.write("perlrt.Perl_split(")
 			for i, a := range expr.Args {
 				if i > 0 {
 					g.write(", ")
@@ -464,13 +847,25 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 				g.generateExpression(a)
 			}
 			g.write(")")
+		case "sort":
+			g.generateSortCall(expr)
+		case "List::Util::first", "List::Util::any", "List::Util::all",
+			"List::Util::none", "List::Util::reduce":
+			g.generateListUtilCall(name, expr.Args)
+		case "Getopt::Std::getopts", "Getopt::Std::getopt":
+			g.generateGetoptsCall(name, expr.Args)
+		case "Storable::store", "Storable::nstore", "Storable::retrieve", "Storable::freeze",
+			"Storable::nfreeze", "Storable::thaw", "Storable::dclone":
+			g.generateStorableCall(name, expr.Args)
 		case "grep":
-			g.write("perl_grep(")
+			g.write("perlrt.Perl_grep(")
 			if len(expr.Args) >= 2 {
 				// Первый аргумент - блок или выражение
-				if block, ok := expr.Args[0].(*ast.AnonSubExpr); ok {
+				if block, ok := expr.Args[0].(*ast.AnonSubExpr); ok && !singleExprBlock(block) {
+					g.generateTopicBlock(block)
+				} else if ok {
 					// Генерируем анонимную функцию
-					g.write("func(_v *SV) *SV { ")
+					g.write("func(_v *perlrt.SV) *perlrt.SV { ")
 					// Устанавливаем $_ = _v
 					g.write("v__ := _v; _ = v__; ")
 					// Генерируем тело блока
@@ -483,7 +878,7 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 					}
 					g.write(" }")
 				} else {
-					g.write("func(_v *SV) *SV { v__ := _v; _ = v__; return ")
+					g.write("func(_v *perlrt.SV) *perlrt.SV { v__ := _v; _ = v__; return ")
 					g.generateExpression(expr.Args[0])
 					g.write(" }")
 				}
@@ -493,12 +888,14 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 			g.write(")")
 
 		case "map":
-			g.write("perl_map(")
+			g.write("perlrt.Perl_map(")
 			if len(expr.Args) >= 2 {
 				// Первый аргумент - блок или выражение
-				if block, ok := expr.Args[0].(*ast.AnonSubExpr); ok {
+				if block, ok := expr.Args[0].(*ast.AnonSubExpr); ok && !singleExprBlock(block) {
+					g.generateTopicBlock(block)
+				} else if ok {
 					// Генерируем анонимную функцию
-					g.write("func(_v *SV) *SV { ")
+					g.write("func(_v *perlrt.SV) *perlrt.SV { ")
 					g.write("v__ := _v; _ = v__; ")
 					for _, stmt := range block.Body.Statements {
 						g.write("return ")
@@ -509,7 +906,7 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 					}
 					g.write(" }")
 				} else {
-					g.write("func(_v *SV) *SV { v__ := _v; _ = v__; return ")
+					g.write("func(_v *perlrt.SV) *perlrt.SV { v__ := _v; _ = v__; return ")
 					g.generateExpression(expr.Args[0])
 					g.write(" }")
 				}
@@ -519,65 +916,386 @@ func (g *Generator) generateCallExpr(expr *ast.CallExpr) {
 			g.write(")")
 		default:
 			// User-defined function
-			//g.write("perl_" + name + "(")
-			g.write("perl_" + strings.ReplaceAll(name, "::", "_") + "(")
-			for i, a := range expr.Args {
-				if i > 0 {
-					g.write(", ")
+			if len(expr.Args) == 0 && g.generateConstant(name) {
+				return
+			}
+			name = g.subName(name)
+			if g.generateInlineCall(name, expr.Args) {
+				return
+			}
+			if g.argWriters[name] && g.generateAliasCall(name, expr.Args) {
+				return
+			}
+			if name == "Perlc::spawn" || name == "Perlc::wait" {
+				g.chans = true
+			}
+			if g.develSize && (name == "size" || name == "total_size") {
+				name = "Devel::Size::" + name
+			}
+			//g.write(g.funcName(name) + "(")
+			g.write(g.funcName(name) + "(")
+			goName := "perl_" + strings.ReplaceAll(name, "::", "_")
+			if variadic, ok := runtimeSub(goName); ok && !variadic && !g.subNames[name] {
+				// fixed parameters of perlrt take an array as one value
+				for i, a := range expr.Args {
+					if i > 0 {
+						g.write(", ")
+					}
+					g.generateExpression(a)
 				}
-				g.generateExpression(a)
+			} else {
+				g.generateCallArgs(expr.Args, false)
 			}
 			g.write(")")
 		}
 	}
 }
 
+// generateListUtilCall: first { ... } LIST -> perl_List_Util_first(func(_v
+// *SV) *SV {...}, ...), the block sees the element as $_; the block of
+// reduce reads the global v_a/v_b as sort does. A code reference instead
+// of a block is called.
+func (g *Generator) generateListUtilCall(name string, args []ast.Expression) {
+	g.write(g.funcName(name) + "(")
+	reduce := name == "List::Util::reduce"
+	if reduce {
+		g.write("func() *perlrt.SV {")
+	} else {
+		g.write("func(_v *perlrt.SV) *perlrt.SV {")
+	}
+	if len(args) == 0 {
+		g.write(" return perlrt.SvUndef() })")
+		return
+	}
+	if block, ok := args[0].(*ast.AnonSubExpr); ok {
+		g.pushScope()
+		g.write("\n")
+		g.indent++
+		if !reduce {
+			g.writeln("v__ := _v; _ = v__")
+		}
+		g.generateBodyWithValue(block.Body.Statements)
+		g.indent--
+		g.write(strings.Repeat("\t", g.indent) + "}")
+		g.popScope()
+	} else {
+		g.write(" return perlrt.CallCode(")
+		g.generateExpression(args[0])
+		if !reduce {
+			g.write(", _v")
+		}
+		g.write(") }")
+	}
+	for _, a := range args[1:] {
+		g.write(", ")
+		g.generateExpression(a)
+	}
+	g.write(")")
+}
+
+// singleExprBlock reports whether the block of map or grep is one
+// expression, which is returned in place
+func singleExprBlock(block *ast.AnonSubExpr) bool {
+	if len(block.Body.Statements) != 1 {
+		return false
+	}
+	_, ok := block.Body.Statements[0].(*ast.ExprStmt)
+	return ok
+}
+
+// generateTopicBlock emits the block of map or grep with statements, such
+// as my $x = ...; $x * 2, as a function of $_ whose last statement is the
+// value; its my variables are its own
+func (g *Generator) generateTopicBlock(block *ast.AnonSubExpr) {
+	g.write("func(_v *perlrt.SV) *perlrt.SV {\n")
+	g.indent++
+	g.writeln("v__ := _v; _ = v__")
+	g.generateBodyWithValue(block.Body.Statements)
+	g.indent--
+	g.write(strings.Repeat("\t", g.indent) + "}")
+}
+
+// generateSortCall: sort LIST -> perl_sort(...), с компаратором ->
+// perl_sort_by(func() *SV {...}, ...); $a/$b - глобальные v_a/v_b.
+func (g *Generator) generateSortCall(expr *ast.CallExpr) {
+	args := expr.Args
+	block, ok := (*ast.AnonSubExpr)(nil), false
+	if len(args) > 0 {
+		block, ok = args[0].(*ast.AnonSubExpr)
+	}
+	if !ok {
+		g.write("perlrt.Perl_sort(")
+	} else {
+		g.pushScope()
+		g.write("perlrt.Perl_sort_by(func() *perlrt.SV {\n")
+		g.indent++
+		g.generateBodyWithValue(block.Body.Statements)
+		g.indent--
+		g.write(strings.Repeat("\t", g.indent) + "}")
+		g.popScope()
+		args = args[1:]
+	}
+	for _, a := range args {
+		if ok {
+			g.write(", ")
+		}
+		g.generateExpression(a)
+		ok = true
+	}
+	g.write(")")
+}
+
 func (g *Generator) generateSubstExpr(expr *ast.SubstExpr) {
-	pattern := expr.Pattern
 	replacement := expr.Replacement
 	flags := expr.Flags
 
-	rePattern := pattern
-	if strings.Contains(flags, "i") {
-		rePattern = "(?i)" + rePattern
-	}
-
-	// Get variable name
-	varName := ""
-	if v, ok := expr.Target.(*ast.ScalarVar); ok {
-		varName = g.scalarName(v.Name)
-	}
-
-	if strings.Contains(flags, "g") {
-		// Global replace with capture support
-		g.write("func() *SV { re := regexp.MustCompile(`" + rePattern + "`); ")
-		g.write("_old := " + varName + ".AsString(); ")
-		g.write("_new := re.ReplaceAllStringFunc(_old, func(_match string) string { ")
-		g.write("_m := re.FindStringSubmatch(_match); _captures = _m[1:]; ")
-		g.write("_r := `" + replacement + "`; ")
-		// Replace $1, $2 etc in replacement
-		g.write("for _i := len(_captures); _i >= 1; _i-- { _r = strings.ReplaceAll(_r, fmt.Sprintf(\"$%d\", _i), _getCapture(_i)) }; ")
-		g.write("return _r }); ")
-		g.write(varName + " = svStr(_new); ")
-		g.write("if _old != _new { return svInt(1) }; return svInt(0) }()")
+	// (my $x = $y) =~ s/// assigns first, then edits $x
+	target := expr.Target
+	assign, isAssign := target.(*ast.AssignExpr)
+	if isAssign {
+		target = assign.Left
+	}
+
+	g.write("func() *perlrt.SV { ")
+	if isAssign {
+		g.generateAssignExpr(assign)
+		g.write("; ")
+	}
+	g.write("re := " + g.regexExpr(expr.Pattern, flags, expr.Token) + "; ")
+	g.write("_old := ")
+	g.generateExpression(target)
+	g.write(".AsString(); ")
+	// The replacement is a double-quoted string (code under /e) built after
+	// each match, so it sees $1, $& and $+{name} of that match
+	g.write(fmt.Sprintf("_new, _n := perlrt.Substitute(re, _old, %t, func() string { return ", strings.Contains(flags, "g")))
+	if strings.Contains(flags, "e") {
+		g.generateSubstCode(replacement)
 	} else {
-		// Single replace with capture support
-		g.write("func() *SV { re := regexp.MustCompile(`" + rePattern + "`); ")
-		g.write("_old := " + varName + ".AsString(); ")
-		g.write("_m := re.FindStringSubmatch(_old); ")
-		g.write("if _m != nil { _captures = _m[1:]; ")
-		g.write("_loc := re.FindStringIndex(_old); ")
-		g.write("_r := `" + replacement + "`; ")
-		g.write("for _i := len(_captures); _i >= 1; _i-- { _r = strings.ReplaceAll(_r, fmt.Sprintf(\"$%d\", _i), _getCapture(_i)) }; ")
-		g.write(varName + " = svStr(_old[:_loc[0]] + _r + _old[_loc[1]:]); return svInt(1) }; ")
-		g.write("return svInt(0) }()")
+		g.generateInterpolatedString(lexer.SubstReplacement(replacement))
+	}
+	g.write(".AsString() }); ")
+	g.write("if _n == 0 { return perlrt.SvInt(0) }; ")
+	g.generateStore(target, "perlrt.SvStr(_new)")
+	g.write("; return perlrt.SvInt(int64(_n)) }()")
+}
+
+// generateSubstCode emits the replacement of s///e: the code is parsed at
+// generation time and runs like a block whose last statement is the value.
+// A syntax error dies at run time, as in the interpreter.
+func (g *Generator) generateSubstCode(code string) {
+	p := parser.New(lexer.New(strings.ReplaceAll(code, `\/`, "/")))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		g.write("perlrt.Perl_die(perlrt.SvStr(" + strconv.Quote("syntax error in s///e replacement: "+errs[0]) + "))")
+		return
+	}
+
+	g.pushScope()
+	defer g.popScope()
+
+	g.write("func() *perlrt.SV {\n")
+	g.indent++
+	g.generateBodyWithValue(program.Statements)
+	g.indent--
+	g.write(strings.Repeat("\t", g.indent) + "}()")
+}
+
+// generateTransExpr: $x =~ tr/abc/xyz/ -> _tr над строкой и запись обратно,
+// значение - число найденных символов
+func (g *Generator) generateTransExpr(expr *ast.TransExpr) {
+	// (my $x = $y) =~ tr/// assigns first, then edits $x
+	target := expr.Target
+	assign, isAssign := target.(*ast.AssignExpr)
+	if isAssign {
+		target = assign.Left
+	}
+
+	g.write("func() *perlrt.SV { ")
+	if isAssign {
+		g.generateAssignExpr(assign)
+		g.write("; ")
+	}
+	g.write("_old := ")
+	g.generateExpression(target)
+	g.write(".AsString(); _new, _n := perlstr.Transliterate(_old, []rune(" + strconv.Quote(string(lexer.TrList(expr.Search))) + "), []rune(" + strconv.Quote(string(lexer.TrList(expr.Replace))) + "), " + strconv.Quote(expr.Flags) + "); ")
+	if strings.Contains(expr.Flags, "r") {
+		// tr///r returns the new string and leaves the target alone
+		g.write("_ = _n; return perlrt.SvStr(_new) }()")
+		return
+	}
+	g.write("if _new != _old { ")
+	g.generateStore(target, "perlrt.SvStr(_new)")
+	g.write(" }; return perlrt.SvInt(int64(_n)) }()")
+}
+
+// generateStore пишет value (готовое Go-выражение) в lvalue: переменную,
+// элемент массива/хеша, $ref->{..}/[..] или $$ref
+func (g *Generator) generateStore(target ast.Expression, value string) {
+	switch t := target.(type) {
+	case *ast.ScalarVar:
+		g.write(g.scalarName(t.Name) + " = " + value)
+	case *ast.SpecialVar:
+		if _, ok := specialVars[t.Name]; ok {
+			g.write("perlrt.SetSpecial(" + strconv.Quote(t.Name) + ", " + value + ")")
+		}
+	case *ast.ArrayAccess:
+		g.write("perlrt.SvASet(")
+		g.generateContainer(t.Array, false)
+		g.write(", ")
+		g.generateExpression(t.Index)
+		g.write(", " + value + ")")
+	case *ast.HashAccess:
+		g.write("perlrt.SvHSet(")
+		g.generateContainer(t.Hash, true)
+		g.write(", ")
+		g.generateExpression(t.Key)
+		g.write(", " + value + ")")
+	case *ast.ArrowAccess:
+		switch acc := t.Right.(type) {
+		case *ast.HashAccess:
+			g.write("perlrt.SvHSet(")
+			g.generateLvalue(t.Left)
+			g.write(", ")
+			g.generateExpression(acc.Key)
+			g.write(", " + value + ")")
+		case *ast.ArrayAccess:
+			g.write("perlrt.SvASet(")
+			g.generateLvalue(t.Left)
+			g.write(", ")
+			g.generateExpression(acc.Index)
+			g.write(", " + value + ")")
+		}
+	case *ast.DerefExpr:
+		if t.Sigil == "$" {
+			g.write("if _ref := ")
+			g.generateExpression(t.Value)
+			g.write("; _ref != nil && len(_ref.AV) > 0 { _v := " + value + "; ")
+			g.write("_ref.AV[0].IV, _ref.AV[0].NV, _ref.AV[0].PV, _ref.AV[0].Flags = _v.IV, _v.NV, _v.PV, _v.Flags }")
+		}
+	}
+}
+
+// generateContainer emits the hash or array an element with the given base
+// is stored in (Hash of a HashAccess, Array of an ArrayAccess): %h and @a for
+// a plain name, otherwise the element holding it, created if missing.
+func (g *Generator) generateContainer(base ast.Expression, hash bool) {
+	switch b := base.(type) {
+	case *ast.ScalarVar:
+		if hash {
+			g.write(g.hashName(b.Name))
+		} else {
+			g.write(g.arrayName(b.Name))
+		}
+	case *ast.SpecialVar:
+		if b.Name == "$_" && !hash {
+			g.write("_args")
+			return
+		}
+		g.generateExpression(base)
+	default:
+		g.generateLvalue(base)
+	}
+}
+
+// generateLvalue emits an element that is about to be stored into or
+// through: $h{a}{b}[0] = 1 fetches $h{a} and $h{a}{b} with perlrt.SvHGetLV, so
+// they are autovivified.
+func (g *Generator) generateLvalue(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.HashAccess:
+		g.write("perlrt.SvHGetLV(")
+		g.generateContainer(e.Hash, true)
+		g.write(", ")
+		g.generateExpression(e.Key)
+		g.write(")")
+	case *ast.ArrayAccess:
+		g.write("perlrt.SvAGetLV(")
+		g.generateContainer(e.Array, false)
+		g.write(", ")
+		g.generateExpression(e.Index)
+		g.write(")")
+	case *ast.ArrowAccess:
+		switch acc := e.Right.(type) {
+		case *ast.HashAccess:
+			g.write("perlrt.SvHGetLV(")
+			g.generateLvalue(e.Left)
+			g.write(", ")
+			g.generateExpression(acc.Key)
+			g.write(")")
+		case *ast.ArrayAccess:
+			g.write("perlrt.SvAGetLV(")
+			g.generateLvalue(e.Left)
+			g.write(", ")
+			g.generateExpression(acc.Index)
+			g.write(")")
+		default:
+			g.generateExpression(expr)
+		}
+	default:
+		g.generateExpression(expr)
+	}
+}
+
+// generateUpdate emits a read-modify-write of an element: the new value is
+// op(old, operand), or op(old) for ++ and -- (no operand); the result is
+// the new value, or the old one for x++.
+func (g *Generator) generateUpdate(target ast.Expression, op string, operand ast.Expression, postfix bool) {
+	g.write("func() *perlrt.SV { _old := ")
+	g.generateExpression(target)
+	g.write("; _new := " + g.opCall(op))
+	w, check := "", false
+	if operand != nil {
+		w, check = g.opWarn(compoundSymbols[op], target, operand, true)
+	}
+	switch {
+	case operand == nil:
+		g.write("_old")
+	case check:
+		g.write("perlrt.OpArgs(_old, ")
+		g.generateExpression(operand)
+		g.write(", " + w + ")")
+	default:
+		g.write("_old, ")
+		g.generateExpression(operand)
+	}
+	g.write("); ")
+	g.generateStore(target, "_new")
+	switch {
+	case postfix && op == "perlrt.SvInc":
+		g.write("; return perlrt.SvPostInc(_old) }()")
+	case postfix:
+		g.write("; return _old }()")
+	default:
+		g.write("; return _new }()")
+	}
+}
+
+// isElement reports whether e is an array or hash element: $a[0], $h{k}, $r->{k}
+func isElement(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.ArrayAccess, *ast.HashAccess, *ast.ArrowAccess:
+		return true
+	}
+	return false
+}
+
+// generateSliceList emits the indices or keys of a slice as a flat []*SV,
+// so ranges and arrays inside work: @a[1..3], @h{@keys}
+func (g *Generator) generateSliceList(exprs []ast.Expression) {
+	g.write("perlrt.Flatten([]*perlrt.SV{")
+	for i, e := range exprs {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.generateExpression(e)
 	}
+	g.write("})")
 }
 
 func (g *Generator) generateRefExpr(expr *ast.RefExpr) {
 	// \$scalar - ссылка на скаляр
 	if sv, ok := expr.Value.(*ast.ScalarVar); ok {
-		g.write("svRef(" + g.scalarName(sv.Name) + ")")
+		g.write("perlrt.SvRef(" + g.scalarName(sv.Name) + ")")
 		return
 	}
 
@@ -593,15 +1311,326 @@ func (g *Generator) generateRefExpr(expr *ast.RefExpr) {
 		return
 	}
 
-	// Для других выражений
-	g.write("svUndef()")
+	// \&name - ссылка на именованную подпрограмму
+	if cv, ok := expr.Value.(*ast.CodeVar); ok {
+		g.write("perlrt.SvCode(perl_" + strings.ReplaceAll(g.subName(cv.Name), "::", "_") + ")")
+		return
+	}
+
+	// \*STDOUT - glob, который называет handle
+	if gv, ok := expr.Value.(*ast.GlobVar); ok {
+		g.write(fmt.Sprintf("perlrt.GlobRef(%q)", gv.Name))
+		return
+	}
+
+	// \ выражение (${\ expr} в строке) - ссылка на значение
+	g.write("perlrt.SvRef(")
+	g.generateScalarExpression(expr.Value)
+	g.write(")")
+}
+
+// isArrayOperand reports whether e is an array push/pop/shift/unshift can
+// modify: @arr, @$ref, @{ expr } or $ref->@*
+func isArrayOperand(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.ArrayVar:
+		return true
+	case *ast.DerefExpr:
+		return v.Sigil == "@"
+	}
+	return false
+}
+
+// isListTarget reports whether an assignment to e is a list assignment:
+// ($a, $b) = ..., @a = ..., %h = ..., @$r = ...
+func isListTarget(e ast.Expression) bool {
+	_, isList := e.(*ast.ArrayExpr)
+	return isList || isAggregate(e)
+}
+
+// isAggregate reports whether e is an array or a hash: @a, %h, @$r, %{$r}
+func isAggregate(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.ArrayVar, *ast.HashVar:
+		return true
+	case *ast.DerefExpr:
+		return v.Sigil == "@" || v.Sigil == "%"
+	}
+	return false
+}
+
+func isHashVar(e ast.Expression) bool {
+	if d, ok := e.(*ast.DerefExpr); ok {
+		return d.Sigil == "%"
+	}
+	_, ok := e.(*ast.HashVar)
+	return ok
+}
+
+// allScalarValues reports whether each of elements is a single value
+func allScalarValues(elements []ast.Expression) bool {
+	for _, e := range elements {
+		if !isScalarValue(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// isScalarValue reports whether e always yields a single value, even when
+// that value is a reference: $r, $a[0], [1, 2], \@a
+func isScalarValue(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.ScalarVar, *ast.ArrayAccess, *ast.HashAccess, *ast.ArrowAccess,
+		*ast.RefExpr, *ast.HashExpr, *ast.AnonSubExpr,
+		*ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.UndefLiteral:
+		return true
+	case *ast.SpecialVar:
+		return strings.HasPrefix(v.Name, "$")
+	case *ast.ArrayExpr:
+		return v.Token.Value == "["
+	}
+	return false
+}
+
+// generateListValues emits the right side of a list assignment as a copied
+// []*SV: arrays, hashes and lists returned by calls are flattened, scalars
+// are kept as one value.
+func (g *Generator) generateListValues(expr ast.Expression) {
+	elements := []ast.Expression{expr}
+	if list, ok := expr.(*ast.ArrayExpr); ok && list.Token.Value != "[" {
+		elements = list.Elements
+	}
+	g.generateListElements(elements)
+}
+
+// generateListElements emits the values of the elements of a list as a
+// copied []*SV
+func (g *Generator) generateListElements(elements []ast.Expression) {
+	g.write("perlrt.ListCopy(")
+	for i, e := range elements {
+		if i > 0 {
+			g.write(", ")
+		}
+		if list, ok := e.(*ast.ArrayExpr); ok && list.Token.Value != "[" {
+			g.generateListValues(list)
+		} else if a, ok := e.(*ast.AssignExpr); ok && a.Operator == "=" && isListTarget(a.Left) {
+			// a list assignment in a list is its targets:
+			// my ($x, $y) = my @l = (1, 2, 3)
+			g.write("func() []*perlrt.SV { ")
+			g.generateListAssign(a)
+			g.write("; return ")
+			g.generateListValues(a.Left)
+			g.write(" }()")
+		} else if m, ok := isListMatch(e); ok {
+			g.write("perlrt.ListOf(")
+			g.generateMatchList(m)
+			g.write(")")
+		} else if rl, ok := e.(*ast.ReadLineExpr); ok {
+			g.write("perlrt.ListOf(")
+			g.generateReadLineList(rl)
+			g.write(")")
+		} else if c, ok := e.(*ast.CommandExpr); ok {
+			g.write("perlrt.ListOf(")
+			g.generateCommandList(c)
+			g.write(")")
+		} else if isScalarValue(e) {
+			g.write("[]*perlrt.SV{")
+			g.generateExpression(e)
+			g.write("}")
+		} else {
+			g.write("perlrt.ListOf(")
+			g.generateExpression(e)
+			g.write(")")
+		}
+	}
+	g.write(")")
+}
+
+// generateCallArgs emits the arguments of a sub or method call, after a
+// comma when comma is set. Arrays and hashes among them are flattened into
+// their elements and pairs, without copies: @_ aliases the caller's values.
+func (g *Generator) generateCallArgs(args []ast.Expression, comma bool) {
+	if len(args) == 0 {
+		return
+	}
+	if comma {
+		g.write(", ")
+	}
+	flatten := false
+	for _, a := range args {
+		flatten = flatten || isCallList(a)
+	}
+	if !flatten {
+		for i, a := range args {
+			if i > 0 {
+				g.write(", ")
+			}
+			g.generateExpression(a)
+		}
+		return
+	}
+	if len(args) == 1 {
+		g.write("perlrt.ListOf(")
+		g.generateExpression(args[0])
+		g.write(")...")
+		return
+	}
+	g.write("perlrt.ListJoin(")
+	for i, a := range args {
+		if i > 0 {
+			g.write(", ")
+		}
+		if isCallList(a) {
+			g.write("perlrt.ListOf(")
+			g.generateExpression(a)
+			g.write(")")
+		} else {
+			g.write("[]*perlrt.SV{")
+			g.generateExpression(a)
+			g.write("}")
+		}
+	}
+	g.write(")...")
+}
+
+// generateAliasCall calls a sub that stores into its @_ and writes the
+// variables and elements passed to it back after the call, where it
+// replaced them: its @_ is the argument slice, $_[N] = ... replaces the
+// element. Calls that flatten an array or hash are left to generateCallArgs.
+func (g *Generator) generateAliasCall(name string, args []ast.Expression) bool {
+	var vars []int
+	for i, a := range args {
+		if isCallList(a) {
+			return false
+		}
+		if g.aliasable(a) {
+			vars = append(vars, i)
+		}
+	}
+	if len(vars) == 0 {
+		return false
+	}
+	g.tempCount++
+	argv := fmt.Sprintf("_argv%d", g.tempCount)
+	in := fmt.Sprintf("_in%d", g.tempCount)
+	g.write("func() *perlrt.SV { " + argv + " := []*perlrt.SV{")
+	for i, a := range args {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.generateExpression(a)
+	}
+	g.write("}; " + in + " := append([]*perlrt.SV(nil), " + argv + "...); ")
+	g.write("_r := " + g.funcName(name) + "(" + argv + "...); ")
+	for _, i := range vars {
+		elem := fmt.Sprintf("%s[%d]", argv, i)
+		g.write(fmt.Sprintf("if %s != %s[%d] { ", elem, in, i))
+		g.generateStore(args[i], elem)
+		g.write(" }; ")
+	}
+	g.write("return _r }()")
+	return true
+}
+
+// aliasable reports whether an argument is written back by
+// generateAliasCall: a boxed scalar, or an element of a named array or hash
+// at a key that reads the same after the call
+func (g *Generator) aliasable(e ast.Expression) bool {
+	simple := func(key ast.Expression) bool {
+		switch k := key.(type) {
+		case *ast.IntegerLiteral:
+			return true
+		case *ast.StringLiteral:
+			return !k.Interpolated
+		case *ast.ScalarVar:
+			return true
+		}
+		return false
+	}
+	switch v := e.(type) {
+	case *ast.ScalarVar:
+		return g.natives[v.Name] == boxed
+	case *ast.ArrayAccess:
+		_, named := v.Array.(*ast.ScalarVar)
+		return named && simple(v.Index)
+	case *ast.HashAccess:
+		_, named := v.Hash.(*ast.ScalarVar)
+		return named && simple(v.Key)
+	}
+	return false
+}
+
+// isCallList reports whether a call argument is flattened: an array, a
+// hash or @_
+func isCallList(e ast.Expression) bool {
+	if v, ok := e.(*ast.SpecialVar); ok {
+		return v.Name == "@_"
+	}
+	return isAggregate(e)
+}
+
+// generateListAssign emits ($a, $b) = ($b, $a), ($x, @rest) = @list and
+// %h = (...). The values are copied before any target is set, so a swap
+// works; an array or hash takes all the remaining values. The result is the
+// number of values on the right, as in scalar context.
+func (g *Generator) generateListAssign(expr *ast.AssignExpr) {
+	targets := []ast.Expression{expr.Left}
+	if list, ok := expr.Left.(*ast.ArrayExpr); ok {
+		targets = list.Elements
+	}
+	g.write("func() *perlrt.SV { _lv := ")
+	g.generateListValues(expr.Right)
+	g.write("; ")
+	rest := false
+	for i, t := range targets {
+		switch {
+		case isAggregate(t) && !rest:
+			rest = true
+			if isHashVar(t) {
+				g.write("perlrt.SvHFill(")
+				g.generateArrayOperand(t)
+				g.write(", ")
+				g.generateHashPairs(expr.Right, i, func() { g.write(fmt.Sprintf("perlrt.ListRest(_lv, %d)", i)) })
+				g.write("); ")
+				break
+			}
+			g.write("perlrt.SvAFill(")
+			g.generateArrayOperand(t)
+			g.write(fmt.Sprintf(", perlrt.ListRest(_lv, %d)); ", i))
+		case isAggregate(t):
+			g.write("perlrt.SvAFill(")
+			g.generateArrayOperand(t)
+			g.write(", nil); ")
+		case rest:
+			g.generateStore(t, "perlrt.SvUndef()")
+			g.write("; ")
+		default:
+			if _, skip := t.(*ast.UndefLiteral); !skip {
+				g.generateStore(t, fmt.Sprintf("perlrt.ListAt(_lv, %d)", i))
+				g.write("; ")
+			}
+		}
+	}
+	g.write("return perlrt.SvInt(int64(len(_lv))) }()")
+}
+
+// generateArrayOperand emits the array (or hash) push and friends or a list
+// assignment modify; a dereferenced empty element becomes a new one:
+// push @{$h{list}}, 1
+func (g *Generator) generateArrayOperand(e ast.Expression) {
+	if d, ok := e.(*ast.DerefExpr); ok {
+		g.generateLvalue(d.Value)
+		return
+	}
+	g.generateExpression(e)
 }
 
 func (g *Generator) generateDerefExpr(expr *ast.DerefExpr) {
 	switch expr.Sigil {
 	case "$":
 		// $$ref - разыменование скаляра
-		g.write("svDeref(")
+		g.write("perlrt.SvDeref(")
 		g.generateExpression(expr.Value)
 		g.write(")")
 	case "@":
@@ -611,150 +1640,233 @@ func (g *Generator) generateDerefExpr(expr *ast.DerefExpr) {
 		// %$ref - разыменование хеша
 		g.generateExpression(expr.Value)
 	default:
-		g.write("svUndef()")
+		g.write("perlrt.SvUndef()")
 	}
 }
 
 func (g *Generator) generateInfixExpr(expr *ast.InfixExpr) {
+	if g.generateNativeInfix(expr) {
+		return
+	}
+	if list, ok := expr.Left.(*ast.ArrayExpr); ok && expr.Operator == "x" && list.Token.Value != "[" {
+		// (LIST) x N repeats the list, not a string
+		g.write("perlrt.SvListRepeat(")
+		g.generateListValues(list)
+		g.write(", ")
+		g.generateExpression(expr.Right)
+		g.write(")")
+		return
+	}
 	op := expr.Operator
 	switch op {
 	case "+":
-		g.write("svAdd(")
+		g.write("perlrt.SvAdd(")
 	case "-":
-		g.write("svSub(")
+		g.write("perlrt.SvSub(")
 	case "*":
-		g.write("svMul(")
+		g.write("perlrt.SvMul(")
 	case "/":
-		g.write("svDiv(")
+		g.write(g.opCall("perlrt.SvDiv"))
 	case "%":
-		g.write("svMod(")
+		g.write(g.opCall("perlrt.SvMod"))
 	case "**":
-		g.write("svPow(")
+		g.write("perlrt.SvPow(")
 	case ".":
-		g.write("svConcat(")
+		g.write("perlrt.SvConcat(")
 	case "x":
-		g.write("svRepeat(")
+		g.write("perlrt.SvRepeat(")
+	case "|":
+		g.write("perlrt.SvBitOr(")
+	case "&":
+		g.write("perlrt.SvBitAnd(")
+	case "<<":
+		g.write("perlrt.SvShl(")
+	case ">>":
+		g.write("perlrt.SvShr(")
 	case "==":
-		g.write("svNumEq(")
+		g.write("perlrt.SvNumEq(")
 	case "!=":
-		g.write("svNumNe(")
+		g.write("perlrt.SvNumNe(")
 	case "<":
-		g.write("svNumLt(")
+		g.write("perlrt.SvNumLt(")
 	case "<=":
-		g.write("svNumLe(")
+		g.write("perlrt.SvNumLe(")
 	case ">":
-		g.write("svNumGt(")
+		g.write("perlrt.SvNumGt(")
 	case ">=":
-		g.write("svNumGe(")
+		g.write("perlrt.SvNumGe(")
 	case "eq":
-		g.write("svStrEq(")
+		g.write("perlrt.SvStrEq(")
 	case "ne":
-		g.write("svStrNe(")
+		g.write("perlrt.SvStrNe(")
 	case "lt":
-		g.write("svStrLt(")
+		g.write("perlrt.SvStrLt(")
 	case "le":
-		g.write("svStrLe(")
+		g.write("perlrt.SvStrLe(")
 	case "gt":
-		g.write("svStrGt(")
+		g.write("perlrt.SvStrGt(")
 	case "ge":
-		g.write("svStrGe(")
+		g.write("perlrt.SvStrGe(")
+	case "<=>":
+		g.write("perlrt.SvNumCmp(")
+	case "cmp":
+		g.write("perlrt.SvStrCmp(")
 	case "&&", "and":
-		g.write("func() *SV { if (")
+		g.write("func() *perlrt.SV { if (")
 		g.generateExpression(expr.Left)
 		g.write(").IsTrue() { return ")
 		g.generateExpression(expr.Right)
-		g.write(" }; return svInt(0) }()")
+		g.write(" }; return perlrt.SvInt(0) }()")
 		return
 	case "||", "or":
-		g.write("func() *SV { if _v := ")
+		g.write("func() *perlrt.SV { if _v := ")
 		g.generateExpression(expr.Left)
 		g.write("; _v.IsTrue() { return _v }; return ")
 		g.generateExpression(expr.Right)
 		g.write(" }()")
 		return
 	case "//":
-		g.write("func() *SV { if _v := ")
+		g.write("func() *perlrt.SV { if _v := ")
 		g.generateExpression(expr.Left)
-		g.write("; _v != nil && _v.flags != 0 { return _v }; return ")
+		g.write("; _v != nil && _v.Flags != 0 { return _v }; return ")
 		g.generateExpression(expr.Right)
 		g.write(" }()")
 		return
 	default:
-		g.write("svUndef(")
+		g.write("perlrt.SvUndef(")
 	}
-	g.generateExpression(expr.Left)
-	g.write(", ")
-	g.generateExpression(expr.Right)
+	g.generateOpArgs(op, expr.Left, expr.Right, false)
 	g.write(")")
 }
 
+// compoundOps maps compound assignment operators to runtime functions
+var compoundOps = map[string]string{
+	"+=": "perlrt.SvAdd", "-=": "perlrt.SvSub", "*=": "perlrt.SvMul", "/=": "perlrt.SvDiv", "%=": "perlrt.SvMod", "**=": "perlrt.SvPow",
+	".=": "perlrt.SvConcat",
+}
+
+// compoundSymbols are the operators of the compound runtime functions
+var compoundSymbols = map[string]string{"perlrt.SvAdd": "+", "perlrt.SvSub": "-", "perlrt.SvMul": "*", "perlrt.SvDiv": "/", "perlrt.SvMod": "%",
+	"perlrt.SvPow": "**", "perlrt.SvConcat": "."}
+
 func (g *Generator) generateAssignExpr(expr *ast.AssignExpr) {
+	if expr.Operator == "=" && isListTarget(expr.Left) {
+		g.generateListAssign(expr)
+		return
+	}
+	if g.generateNativeStore(expr) {
+		return
+	}
 	switch left := expr.Left.(type) {
 	case *ast.ScalarVar:
 		name := g.scalarName(left.Name)
 		switch expr.Operator {
 		case "=":
 			g.write(name + " = ")
-			g.generateExpression(expr.Right)
+			g.generateScalarValue(expr.Right)
 		case "+=":
-			g.write(name + " = svAdd(" + name + ", ")
-			g.generateExpression(expr.Right)
+			g.write(name + " = perlrt.SvAdd(")
+			g.generateOpArgs("+", left, expr.Right, true)
 			g.write(")")
 		case "-=":
-			g.write(name + " = svSub(" + name + ", ")
-			g.generateExpression(expr.Right)
+			g.write(name + " = perlrt.SvSub(")
+			g.generateOpArgs("-", left, expr.Right, true)
 			g.write(")")
 		case "*=":
-			g.write(name + " = svMul(" + name + ", ")
-			g.generateExpression(expr.Right)
+			g.write(name + " = perlrt.SvMul(")
+			g.generateOpArgs("*", left, expr.Right, true)
 			g.write(")")
 		case "/=":
-			g.write(name + " = svDiv(" + name + ", ")
-			g.generateExpression(expr.Right)
+			g.write(name + " = " + g.opCall("perlrt.SvDiv"))
+			g.generateOpArgs("/", left, expr.Right, true)
+			g.write(")")
+		case "%=":
+			g.write(name + " = " + g.opCall("perlrt.SvMod"))
+			g.generateOpArgs("%", left, expr.Right, true)
+			g.write(")")
+		case "**=":
+			g.write(name + " = perlrt.SvPow(")
+			g.generateOpArgs("**", left, expr.Right, true)
 			g.write(")")
 		case ".=":
-			g.write(name + " = svConcat(" + name + ", ")
-			g.generateExpression(expr.Right)
+			g.write(name + " = perlrt.SvConcat(")
+			g.generateOpArgs(".", left, expr.Right, true)
+			g.write(")")
+		}
+	case *ast.CallExpr:
+		// keys %h = 1024 presizes the hash
+		if ident, ok := left.Function.(*ast.Identifier); ok && ident.Value == "keys" && len(left.Args) > 0 {
+			g.write("perlrt.HashPresize(")
+			g.generateContainer(left.Args[0], true)
+			g.write(", ")
+			g.generateScalarExpression(expr.Right)
+			g.write(")")
+		}
+	case *ast.SpecialVar:
+		// $, = "-", $| = 1, $0 = "name"; $_ and the read-only ones are kept
+		if _, ok := specialVars[left.Name]; ok && expr.Operator == "=" {
+			g.write("perlrt.SetSpecial(" + strconv.Quote(left.Name) + ", ")
+			g.generateScalarExpression(expr.Right)
 			g.write(")")
 		}
 	case *ast.ArrayAccess:
-		g.write("svASet(")
-		if sv, ok := left.Array.(*ast.ScalarVar); ok {
-			g.write(g.arrayName(sv.Name))
-		} else {
-			g.generateExpression(left.Array)
+		if op, ok := compoundOps[expr.Operator]; ok {
+			g.generateUpdate(left, op, expr.Right, false)
+			return
 		}
+		g.write("perlrt.SvASet(")
+		g.generateContainer(left.Array, false)
 		g.write(", ")
 		g.generateExpression(left.Index)
 		g.write(", ")
 		g.generateExpression(expr.Right)
 		g.write(")")
 	case *ast.HashAccess:
-		g.write("svHSet(")
-		if sv, ok := left.Hash.(*ast.ScalarVar); ok {
-			g.write(g.hashName(sv.Name))
-		} else {
-			g.generateExpression(left.Hash)
+		if op, ok := compoundOps[expr.Operator]; ok {
+			g.generateUpdate(left, op, expr.Right, false)
+			return
 		}
+		g.write("perlrt.SvHSet(")
+		g.generateContainer(left.Hash, true)
 		g.write(", ")
 		g.generateExpression(left.Key)
 		g.write(", ")
 		g.generateExpression(expr.Right)
 		g.write(")")
+	case *ast.ArraySlice:
+		g.write("perlrt.SvASliceSet(")
+		g.generateExpression(left.Array)
+		g.write(", ")
+		g.generateSliceList(left.Indices)
+		g.write(", ")
+		g.generateExpression(expr.Right)
+		g.write(")")
+	case *ast.HashSlice:
+		g.write("perlrt.SvHSliceSet(")
+		g.generateExpression(left.Hash)
+		g.write(", ")
+		g.generateSliceList(left.Keys)
+		g.write(", ")
+		g.generateExpression(expr.Right)
+		g.write(")")
 	case *ast.ArrowAccess:
 		// $ref->{"key"} = value or $ref->[idx] = value
+		if op, ok := compoundOps[expr.Operator]; ok {
+			g.generateUpdate(left, op, expr.Right, false)
+			return
+		}
 		switch acc := left.Right.(type) {
 		case *ast.HashAccess:
-			g.write("svHSet(")
-			g.generateExpression(left.Left)
+			g.write("perlrt.SvHSet(")
+			g.generateLvalue(left.Left)
 			g.write(", ")
 			g.generateExpression(acc.Key)
 			g.write(", ")
 			g.generateExpression(expr.Right)
 			g.write(")")
 		case *ast.ArrayAccess:
-			g.write("svASet(")
-			g.generateExpression(left.Left)
+			g.write("perlrt.SvASet(")
+			g.generateLvalue(left.Left)
 			g.write(", ")
 			g.generateExpression(acc.Index)
 			g.write(", ")
@@ -764,68 +1876,162 @@ func (g *Generator) generateAssignExpr(expr *ast.AssignExpr) {
 	case *ast.DerefExpr:
 		// $$ref = value - присваивание через разыменование скаляра
 		if left.Sigil == "$" {
-			g.write("func() *SV { ")
+			g.write("func() *perlrt.SV { ")
 			g.write("_ref := ")
 			g.generateExpression(left.Value)
 			g.write("; ")
 			g.write("_val := ")
 			g.generateExpression(expr.Right)
 			g.write("; ")
-			g.write("if _ref != nil && len(_ref.av) > 0 { ")
-			g.write("_ref.av[0].iv = _val.iv; ")
-			g.write("_ref.av[0].nv = _val.nv; ")
-			g.write("_ref.av[0].pv = _val.pv; ")
-			g.write("_ref.av[0].flags = _val.flags; ")
+			g.write("if _ref != nil && len(_ref.AV) > 0 { ")
+			g.write("_ref.AV[0].IV = _val.IV; ")
+			g.write("_ref.AV[0].NV = _val.NV; ")
+			g.write("_ref.AV[0].PV = _val.PV; ")
+			g.write("_ref.AV[0].Flags = _val.Flags; ")
 			g.write("}; return _val }()")
 			return
 		}
 		g.generateExpression(expr.Right)
+	case *ast.TernaryExpr:
+		// ($cond ? $a : $b) = value - присваивание в выбранную ветку
+		g.write("func() *perlrt.SV { if (")
+		g.generateExpression(left.Condition)
+		g.write(").IsTrue() { ")
+		g.generateAssignExpr(&ast.AssignExpr{Token: expr.Token, Left: left.Then, Operator: expr.Operator, Right: expr.Right})
+		g.write("; return ")
+		g.generateExpression(left.Then)
+		g.write(" }; ")
+		g.generateAssignExpr(&ast.AssignExpr{Token: expr.Token, Left: left.Else, Operator: expr.Operator, Right: expr.Right})
+		g.write("; return ")
+		g.generateExpression(left.Else)
+		g.write(" }()")
 	}
 }
 
 func (g *Generator) generateReadLineExpr(expr *ast.ReadLineExpr) {
+	g.write("perlrt.PerlReadLine(" + g.readLineHandle(expr) + ")")
+}
+
+// generateReadLineList emits <FH> in list context: an array of all the
+// records left, as my @lines = <$fh> reads them
+func (g *Generator) generateReadLineList(expr *ast.ReadLineExpr) {
+	g.write("perlrt.PerlReadLines(" + g.readLineHandle(expr) + ")")
+}
+
+// generateCommandList emits `command` in list context: an array of the
+// records of its output
+func (g *Generator) generateCommandList(expr *ast.CommandExpr) {
+	g.write("perlrt.PerlCommandLines(")
+	g.generateExpression(expr.Command)
+	g.write(")")
+}
+
+// readLineHandle is the Go expression of the handle name of <FH> or <$fh>;
+// "" is STDIN
+func (g *Generator) readLineHandle(expr *ast.ReadLineExpr) string {
 	var name string
 	if expr.Filehandle != nil {
 		switch fh := expr.Filehandle.(type) {
 		case *ast.Identifier:
 			name = fh.Value
 		case *ast.ScalarVar:
+			// open(my $fh, ...) положил в $fh glob, его строка - имя handle
+			if v := g.scalarName(fh.Name); g.isDeclared(v) {
+				return v + ".AsString()"
+			}
 			name = fh.Name // НЕ добавляем "v_" prefix!
 		}
 	}
+	return strconv.Quote(name)
+}
 
-	if name == "" {
-		g.write("perlReadLine(\"\")")
-	} else {
-		g.write("perlReadLine(\"" + name + "\")")
+// generateQrExpr emits qr// as its pattern in "(?^flags:...)" form; the
+// scalars in it are interpolated when the qr// runs
+func (g *Generator) generateQrExpr(e *ast.QrExpr) {
+	segs := lexer.SplitPattern(e.Pattern)
+	for _, seg := range segs {
+		if seg.Var != "" || seg.Quoted {
+			full := qrPattern(e.Pattern, e.Flags)
+			open := full[:strings.IndexByte(full, ':')+1]
+			g.write("perlrt.SvRegex(" + strconv.Quote(open) + " + " + g.patternString(segs) + " + " + strconv.Quote(full[len(open)+len(e.Pattern):]) + ")")
+			return
+		}
 	}
+	g.write("perlrt.SvRegex(" + strconv.Quote(qrPattern(e.Pattern, e.Flags)) + ")")
 }
 
 func (g *Generator) generateMatchExpr(expr *ast.MatchExpr) {
-	pattern := expr.Pattern.Pattern
-	flags := expr.Pattern.Flags
+	yes, no := "perlrt.SvInt(1)", "perlrt.SvInt(0)"
+	if expr.Negate {
+		yes, no = no, yes
+	}
+	if expr.Pattern == nil {
+		// $str =~ $re: шаблон известен только во время выполнения
+		g.write("func() *perlrt.SV { re := perlrt.Regex(")
+		g.generateExpression(expr.PatternExpr)
+		g.write(".AsString()); if perlrt.Match(re, ")
+		g.generateExpression(expr.Target)
+		g.write(".AsString()) { return " + yes + " }; return " + no + " }()")
+		return
+	}
+
+	re := g.regexExpr(expr.Pattern.Pattern, expr.Pattern.Flags, expr.Token)
 
-	// Add case-insensitive flag if needed
-	rePattern := pattern
-	if strings.Contains(flags, "i") {
-		rePattern = "(?i)" + rePattern
+	if flags := expr.Pattern.Flags; strings.Contains(flags, "g") {
+		// Scalar m//g continues from pos() of the target
+		g.write("func() *perlrt.SV { if perlrt.MatchPos(" + re + ", ")
+		g.generateExpression(expr.Target)
+		keep := strconv.FormatBool(strings.Contains(flags, "c"))
+		if expr.Negate {
+			g.write(", " + keep + ") { return perlrt.SvInt(0) }; return perlrt.SvInt(1) }()")
+		} else {
+			g.write(", " + keep + ") { return perlrt.SvInt(1) }; return perlrt.SvInt(0) }()")
+		}
+		return
 	}
 
-	if expr.Negate {
-		g.write("func() *SV { re := regexp.MustCompile(`" + rePattern + "`); _m := re.FindStringSubmatch(")
+	g.write("func() *perlrt.SV { if perlrt.Match(" + re + ", ")
+	g.generateExpression(expr.Target)
+	g.write(".AsString()) { return " + yes + " }; return " + no + " }()")
+}
+
+// isListMatch reports whether m// in list context returns a list: its
+// groups, or every match under /g. !~ stays scalar
+func isListMatch(e ast.Expression) (*ast.MatchExpr, bool) {
+	m, ok := e.(*ast.MatchExpr)
+	if !ok || m.Negate {
+		return nil, false
+	}
+	return m, true
+}
+
+// generateMatchList emits m// in list context (_matchList): the groups,
+// (1) without groups, () when the pattern does not match. m//g gives all
+// matches from pos() on (_matchAll)
+func (g *Generator) generateMatchList(expr *ast.MatchExpr) {
+	if expr.Pattern != nil && strings.Contains(expr.Pattern.Flags, "g") {
+		g.write("perlrt.MatchAll(" + g.regexExpr(expr.Pattern.Pattern, expr.Pattern.Flags, expr.Token) + ", ")
 		g.generateExpression(expr.Target)
-		g.write(".AsString()); if _m != nil { _captures = _m[1:]; return svInt(0) }; return svInt(1) }()")
+		g.write(")")
+		return
+	}
+	g.write("perlrt.MatchList(")
+	if expr.Pattern == nil {
+		g.write("perlrt.Regex(")
+		g.generateExpression(expr.PatternExpr)
+		g.write(".AsString())")
 	} else {
-		g.write("func() *SV { re := regexp.MustCompile(`" + rePattern + "`); _m := re.FindStringSubmatch(")
-		g.generateExpression(expr.Target)
-		g.write(".AsString()); if _m != nil { _captures = _m[1:]; return svInt(1) }; return svInt(0) }()")
+		g.write(g.regexExpr(expr.Pattern.Pattern, expr.Pattern.Flags, expr.Token))
 	}
+	g.write(", ")
+	g.generateExpression(expr.Target)
+	g.write(".AsString())")
 }
 
 func (g *Generator) generateRangeExpr(expr *ast.RangeExpr) {
-	g.write("func() *SV { var _r []*SV; for _i := int(")
+	g.write("func() *perlrt.SV { var _r []*perlrt.SV; for _i := int(")
 	g.generateExpression(expr.Start)
 	g.write(".AsInt()); _i <= int(")
 	g.generateExpression(expr.End)
-	g.write(".AsInt()); _i++ { _r = append(_r, svInt(int64(_i))) }; return svArray(_r...) }()")
+	g.write(".AsInt()); _i++ { _r = append(_r, perlrt.SvInt(int64(_i))) }; return perlrt.SvArray(_r...) }()")
 }
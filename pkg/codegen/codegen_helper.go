@@ -1,29 +1,334 @@
 package codegen
 
-import "perlc/pkg/ast"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/lexer"
+	"perlc/pkg/perlre"
+	"perlc/pkg/warnings"
+)
+
+// waitConstants are the POSIX :sys_wait_h flags for waitpid.
+var waitConstants = map[string]int64{
+	"WNOHANG":   1,
+	"WUNTRACED": 2,
+}
+
+// bareCalls are builtins that may be called as barewords (my $pid = wait;).
+var bareCalls = map[string]string{
+	"hostname": "perlrt.Perl_hostname()",
+	"wait":     "perlrt.Perl_wait()",
+}
+
+// hiResFuncs are the functions Time::HiRes exports
+var hiResFuncs = map[string]bool{
+	"time": true, "sleep": true, "usleep": true, "nanosleep": true,
+	"gettimeofday": true, "tv_interval": true,
+}
+
+// useHiRes records the names imported by use Time::HiRes LIST, which call
+// the Time::HiRes versions
+func (g *Generator) useHiRes(args []ast.Expression) {
+	if g.hiRes == nil {
+		g.hiRes = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if hiResFuncs[name] {
+				g.hiRes[name] = true
+			}
+		}
+	}
+}
+
+// scalarUtilFuncs are the functions Scalar::Util exports; none by default
+var scalarUtilFuncs = map[string]bool{
+	"blessed": true, "reftype": true, "looks_like_number": true,
+	"weaken": true, "unweaken": true, "isweak": true, "dualvar": true,
+}
+
+// useScalarUtil records the names imported by use Scalar::Util LIST,
+// which call Scalar::Util::...
+func (g *Generator) useScalarUtil(args []ast.Expression) {
+	if g.scalarUtil == nil {
+		g.scalarUtil = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if scalarUtilFuncs[name] {
+				g.scalarUtil[name] = true
+			}
+		}
+	}
+}
+
+// posixFuncs are the POSIX functions computed in place; use POSIX without
+// a list imports them all, together with the constants
+var posixFuncs = map[string]bool{
+	"floor": true, "ceil": true, "fmod": true, "pow": true, "strftime": true,
+}
+
+// posixIntConstants and posixFloatConstants are the limits.h and float.h
+// values of a 64-bit platform, as perl sees them
+var posixIntConstants = map[string]int64{
+	"INT_MAX":      math.MaxInt32,
+	"INT_MIN":      math.MinInt32,
+	"UINT_MAX":     math.MaxUint32,
+	"LONG_MAX":     math.MaxInt64,
+	"LONG_MIN":     math.MinInt64,
+	"EXIT_SUCCESS": 0,
+	"EXIT_FAILURE": 1,
+}
+
+var posixFloatConstants = map[string]float64{
+	"DBL_MAX":     math.MaxFloat64,
+	"DBL_MIN":     0x1p-1022,
+	"DBL_EPSILON": 0x1p-52,
+}
+
+// usePOSIX records the names imported by use POSIX LIST, which call
+// POSIX::...; without a list everything is imported
+func (g *Generator) usePOSIX(args []ast.Expression) {
+	if g.posix == nil {
+		g.posix = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		for name := range posixFuncs {
+			g.posix[name] = true
+		}
+		for name := range posixIntConstants {
+			g.posix[name] = true
+		}
+		for name := range posixFloatConstants {
+			g.posix[name] = true
+		}
+		return
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			g.posix[name] = true
+		}
+	}
+}
+
+// posixConstant is the Go expression of a POSIX constant, imported or
+// spelled POSIX::INT_MAX
+func (g *Generator) posixConstant(name string) (string, bool) {
+	if !g.posix[name] {
+		var ok bool
+		if name, ok = strings.CutPrefix(name, "POSIX::"); !ok {
+			return "", false
+		}
+	}
+	if v, ok := posixIntConstants[name]; ok {
+		return fmt.Sprintf("perlrt.SvInt(%d)", v), true
+	}
+	if v, ok := posixFloatConstants[name]; ok {
+		return "perlrt.SvFloat(" + strconv.FormatFloat(v, 'g', -1, 64) + ")", true
+	}
+	return "", false
+}
+
+// listUtilFuncs are the functions List::Util exports; none by default
+var listUtilFuncs = map[string]bool{
+	"sum": true, "sum0": true, "max": true, "min": true, "first": true,
+	"reduce": true, "any": true, "all": true, "none": true, "uniq": true,
+	"shuffle": true,
+}
+
+// useListUtil records the names imported by use List::Util LIST, which
+// call List::Util::...
+func (g *Generator) useListUtil(args []ast.Expression) {
+	if g.listUtil == nil {
+		g.listUtil = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if listUtilFuncs[name] {
+				g.listUtil[name] = true
+			}
+		}
+	}
+}
+
+// isGettimeofday reports whether expr calls Time::HiRes::gettimeofday,
+// also imported and without parentheses
+func (g *Generator) isGettimeofday(expr ast.Expression) bool {
+	var name string
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		name = e.Value
+	case *ast.CallExpr:
+		if ident, ok := e.Function.(*ast.Identifier); ok {
+			name = ident.Value
+		}
+	}
+	return name == "Time::HiRes::gettimeofday" || name == "gettimeofday" && g.hiRes[name]
+}
+
+// qrPattern builds the "(?^flags:pattern)" form perl prints for qr//, so
+// the value keeps its modifiers when interpolated into another pattern
+func qrPattern(pattern, flags string) string {
+	shown := ""
+	for _, f := range "msix" {
+		if strings.ContainsRune(flags, f) {
+			shown += string(f)
+		}
+	}
+	if strings.Contains(flags, "x") && strings.Contains(pattern[strings.LastIndex(pattern, "\n")+1:], "#") {
+	// a /x comment on the last line is ended by a newline, as perl's
+		pattern += "\n"
+	}
+	return "(?^" + shown + ":" + pattern + ")"
+}
+
+// inlineFlags maps /i, /m and /s to a leading (?ims) group. /x is applied
+// to the pattern text instead, before any interpolation.
+//
+// The rest of the Perl syntax is perlre's business at run time. RE2
+// divergence: without /m, $ in a pattern RE2 runs matches only at the very
+// end of the string, not before a trailing newline.
+func inlineFlags(flags string) string {
+	goFlags := ""
+	for _, f := range "ims" {
+		if strings.ContainsRune(flags, f) {
+			goFlags += string(f)
+		}
+	}
+	if goFlags == "" {
+		return ""
+	}
+	return "(?" + goFlags + ")"
+}
+
+// regexExpr returns a Go expression yielding the compiled pattern. Patterns
+// with interpolated scalars ($x, ${x}, \Q$x\E) are built at run time and
+// compiled through the _regex cache, or only once under /o. A literal
+// pattern perlre rejects becomes _badRegex, warning at the line of tok.
+func (g *Generator) regexExpr(pattern, flags string, tok lexer.Token) string {
+	if strings.Contains(flags, "x") {
+		pattern = lexer.StripExtended(pattern)
+	}
+	prefix := inlineFlags(flags)
+
+	segs := lexer.SplitPattern(pattern)
+	dynamic := false
+	for _, seg := range segs {
+		if seg.Var != "" || seg.Quoted {
+			dynamic = true
+		}
+	}
+	if !dynamic {
+		if _, err := perlre.Compile(prefix + pattern); err != nil {
+			where := warnings.Where(ast.FromToken(tok))
+			return "perlrt.BadRegex(" + strconv.Quote(where) + ", " + strconv.Quote(err.Error()) + ")"
+		}
+		return "perlre.MustCompile(" + strconv.Quote(prefix+pattern) + ")"
+	}
+
+	text := g.patternString(segs)
+	if prefix != "" {
+		text = strconv.Quote(prefix) + " + " + text
+	}
+	re := "perlrt.Regex(" + text + ")"
+	if strings.Contains(flags, "o") {
+		g.tempCount++
+		re = "perlrt.CompileOnce(" + strconv.Itoa(g.tempCount) + ", func() *perlre.Regexp { return " + re + " })"
+	}
+	return re
+}
+
+// patternString returns a Go string expression of the pattern segs with
+// their scalars interpolated
+func (g *Generator) patternString(segs []lexer.PatternSegment) string {
+	var parts []string
+	for _, seg := range segs {
+		part := strconv.Quote(seg.Text)
+		if seg.Var != "" {
+			part = g.scalarString(seg.Var)
+		}
+		if seg.Quoted {
+			part = "regexp.QuoteMeta(" + part + ")"
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return `""`
+	}
+	return strings.Join(parts, " + ")
+}
+
+// matchVars are the match variables other than $1..$N
+var matchVars = map[string]string{
+	"$&": "perlrt.SvStr(perlrt.LastMatch)", "$`": "perlrt.SvStr(perlrt.PreMatch)", "$'": "perlrt.SvStr(perlrt.PostMatch)", "$+": "perlrt.LastParen()",
+	"%+": "perlrt.NamedHash(false)", "%-": "perlrt.NamedHash(true)",
+}
+
+// specialVars are the special variables a program can assign and the
+// runtime globals holding them; _setSpecial stores into them
+var specialVars = map[string]string{
+	"$,": "perlrt.Ofs", "$\\": "perlrt.Ors", "$\"": "perlrt.ListSep", "$/": "perlrt.Irs", "$|": "perlrt.StdoutFlush", "$0": "perlrt.ProgName",
+}
+
+// booleanOps make a when condition a plain test instead of a smart match
+var booleanOps = map[string]bool{
+	"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"eq": true, "ne": true, "lt": true, "gt": true, "le": true, "ge": true,
+	"&&": true, "||": true, "and": true, "or": true, "not": true, "!": true,
+	"=~": true, "!~": true,
+}
+
+func isBooleanExpr(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.InfixExpr:
+		return booleanOps[v.Operator]
+	case *ast.PrefixExpr:
+		return booleanOps[v.Operator]
+	case *ast.MatchExpr:
+		return true
+	case *ast.CallExpr:
+		ident, ok := v.Function.(*ast.Identifier)
+		return ok && (ident.Value == "defined" || ident.Value == "exists")
+	}
+	return false
+}
 
 func (g *Generator) varName(expr ast.Expression) string {
 	switch v := expr.(type) {
 	case *ast.ScalarVar:
-		return "v_" + v.Name
+		return g.scalarName(v.Name)
 	case *ast.ArrayVar:
-		return "a_" + v.Name
+		return g.arrayName(v.Name)
 	case *ast.HashVar:
-		return "h_" + v.Name
+		return g.hashName(v.Name)
 	}
 	return "_"
 }
 
 func (g *Generator) scalarName(name string) string {
-	return "v_" + name
+	return g.packageVar("v_", name)
 }
 
 func (g *Generator) arrayName(name string) string {
-	return "a_" + name
+	return g.packageVar("a_", name)
 }
 
 func (g *Generator) hashName(name string) string {
-	return "h_" + name
+	return g.packageVar("h_", name)
+}
+
+// assignOp is := for a lexical declared here, = for a package variable,
+// which is a Go global, or a lexical this scope declares already
+func (g *Generator) assignOp(name string) string {
+	if g.declaredHere(name) {
+		return "="
+	}
+	g.declare(name)
+	return ":="
 }
 
 func isAlnum(c byte) bool {
@@ -0,0 +1,500 @@
+package codegen
+
+// dbiMethods are the methods of DBI, DBI::db and DBI::st: a call of one
+// of them notes where it is made, for the messages of PrintError and
+// RaiseError
+var dbiMethods = map[string]bool{
+	"connect": true, "prepare": true, "do": true, "selectrow_array": true,
+	"selectrow_arrayref": true, "selectrow_hashref": true, "selectall_arrayref": true,
+	"begin_work": true, "commit": true, "rollback": true, "execute": true,
+	"fetchrow_arrayref": true, "fetch": true, "fetchrow_array": true,
+	"fetchrow_hashref": true, "fetchall_arrayref": true,
+}
+
+// writeDBIRuntime emits DBI over database/sql, as the interpreter has it:
+// DBI->connect("dbi:Driver:...") opens the database with a database/sql
+// driver linked into the program, and the DBI::db and DBI::st objects are
+// blessed hashes holding their attributes, with the connection and the
+// statement kept by the hash.
+func (g *Generator) writeDBIRuntime() {
+	g.global("v_", "DBI::err")
+	g.global("v_", "DBI::errstr")
+	g.writeln(`// _dbiDrivers are the database/sql drivers that serve a DBD, in order
+var _dbiDrivers = map[string][]string{
+	"SQLite": {"sqlite3", "sqlite"}, "Pg": {"postgres", "pgx"}, "mysql": {"mysql"},
+	"MariaDB": {"mysql"}, "ODBC": {"odbc"}, "Oracle": {"godror", "oracle"},
+}
+
+type _dbiConn struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	driver string
+	attrs  *perlrt.SV
+	work   bool
+	err    string
+	lastID int64
+}
+
+type _dbiStmt struct {
+	conn   *_dbiConn
+	attrs  *perlrt.SV
+	query  string
+	stmt   *sql.Stmt
+	rows   *sql.Rows
+	cols   []string
+	count  int64
+	err    string
+	shared bool
+}
+
+var (
+	_dbConns  = map[*perlrt.SV]*_dbiConn{}
+	_dbStmts  = map[*perlrt.SV]*_dbiStmt{}
+	_dbiWhere string
+)
+
+// _dbiCall is a method call that may go to DBI: where is the caller's
+// "FILE line N"
+func _dbiCall(where string, obj *perlrt.SV, method string, args ...*perlrt.SV) *perlrt.SV {
+	_dbiWhere = where
+	return perlrt.Perl_method_call(obj, method, args...)
+}
+
+func _dbiAt() string {
+	if _dbiWhere == "" { return "" }
+	return " at " + _dbiWhere
+}
+
+// _dbiFail sets $DBI::err and $DBI::errstr, warns with PrintError and
+// dies with RaiseError, as DBI does; the result is undef
+func _dbiFail(attrs *perlrt.SV, errstr *string, what string, err error) *perlrt.SV {
+	*errstr = err.Error()
+	v_DBI__err = perlrt.SvInt(1)
+	v_DBI__errstr = perlrt.SvStr(*errstr)
+	msg := what + " failed: " + *errstr + _dbiAt() + ".\n"
+	if _dbiFlag(attrs, "PrintError") { perlrt.Perl_warn(perlrt.SvStr(msg)) }
+	if _dbiFlag(attrs, "RaiseError") { perlrt.Perl_die(perlrt.SvStr(msg)) }
+	return perlrt.SvUndef()
+}
+
+func _dbiFlag(attrs *perlrt.SV, name string) bool {
+	v, ok := attrs.HV[name]
+	return ok && v.IsTrue()
+}
+
+func _dbiMethod(class, method string, args []*perlrt.SV) *perlrt.SV {
+	arg := func(n int) *perlrt.SV {
+		if n < len(args) && args[n] != nil { return args[n] }
+		return perlrt.SvUndef()
+	}
+	if method != "err" && method != "errstr" {
+		v_DBI__err = perlrt.SvUndef()
+		v_DBI__errstr = perlrt.SvUndef()
+	}
+	switch class {
+	case "DBI":
+		switch method {
+		case "connect":
+			return _dbiConnect(arg(1).AsString(), arg(2), arg(3), arg(4))
+		case "err":
+			return v_DBI__err
+		case "errstr":
+			return v_DBI__errstr
+		}
+	case "DBI::db":
+		if c := _dbConns[args[0]]; c != nil {
+			if result, ok := _dbiConnMethod(c, method, args); ok { return result }
+		}
+	case "DBI::st":
+		if s := _dbStmts[args[0]]; s != nil {
+			if result, ok := _dbiStmtMethod(s, method, args[1:]); ok { return result }
+		}
+	}
+	return perlrt.Perl_die(perlrt.SvStr("Can't locate object method \"" + method + "\" via package \"" + class + "\"" + _dbiAt() + ".\n"))
+}
+
+// _dbiConnect is DBI->connect(DSN, USER, PASS, \%attr): undef if the
+// database does not open; without a driver it dies, as install_driver
+func _dbiConnect(dsn string, user, pass, attr *perlrt.SV) *perlrt.SV {
+	attrs := perlrt.SvHash()
+	attrs.HV["PrintError"] = perlrt.SvInt(1)
+	attrs.HV["RaiseError"] = perlrt.SvInt(0)
+	attrs.HV["AutoCommit"] = perlrt.SvInt(1)
+	if attr.Flags&perlrt.SVf_HOK != 0 {
+		for k, v := range attr.HV { attrs.HV[k] = perlrt.ListCopy([]*perlrt.SV{v})[0] }
+	}
+	parts := strings.SplitN(dsn, ":", 3)
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "dbi") || parts[1] == "" {
+		return perlrt.Perl_die(perlrt.SvStr("Can't connect to data source '" + dsn + "' because I can't work out what driver to use (it doesn't seem to contain a 'dbi:driver:' prefix)" + _dbiAt() + ".\n"))
+	}
+	driver, rest := parts[1], parts[2]
+	registered := map[string]bool{}
+	for _, d := range sql.Drivers() { registered[d] = true }
+	goDriver := ""
+	for _, d := range append(_dbiDrivers[driver], driver, strings.ToLower(driver)) {
+		if registered[d] { goDriver = d; break }
+	}
+	if goDriver == "" {
+		return perlrt.Perl_die(perlrt.SvStr("install_driver(" + driver + ") failed: no database/sql driver for DBD::" + driver + " is linked in" + _dbiAt() + ".\n"))
+	}
+	db, err := sql.Open(goDriver, _dbiDataSource(driver, rest, user.AsString(), pass.AsString()))
+	if err == nil {
+		if err = db.Ping(); err != nil { db.Close() }
+	}
+	if err != nil {
+		var errstr string
+		return _dbiFail(attrs, &errstr, "DBI connect('"+rest+"','"+user.AsString()+"',...)", err)
+	}
+	attrs.HV["Driver"] = perlrt.SvStr(driver)
+	attrs.HV["Name"] = perlrt.SvStr(rest)
+	dbh := perlrt.Perl_bless(attrs, perlrt.SvStr("DBI::db"))
+	_dbConns[dbh] = &_dbiConn{db: db, driver: driver, attrs: dbh}
+	return dbh
+}
+
+// _dbiDataSource turns the DSN of DBI ("dbname=x;host=y") into the one of
+// the Go driver: a file name for SQLite, "key=value ..." for Pg and
+// user:pass@tcp(host:port)/db for mysql; others get it as it is
+func _dbiDataSource(driver, rest, user, pass string) string {
+	params := map[string]string{}
+	for _, p := range strings.Split(rest, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok { params[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v) }
+	}
+	dbname := params["dbname"]
+	for _, k := range []string{"database", "db"} {
+		if dbname == "" { dbname = params[k] }
+	}
+	switch driver {
+	case "SQLite":
+		if dbname == "" { dbname = rest }
+		if dbname == ":memory:" || dbname == "" { return "file::memory:?cache=shared" }
+		return dbname
+	case "Pg":
+		var conn []string
+		for _, p := range strings.Split(rest, ";") {
+			if p = strings.TrimSpace(p); p != "" { conn = append(conn, p) }
+		}
+		if user != "" { conn = append(conn, "user="+user) }
+		if pass != "" { conn = append(conn, "password="+pass) }
+		return strings.Join(conn, " ")
+	case "mysql", "MariaDB":
+		if dbname == "" && !strings.Contains(rest, "=") { dbname = rest }
+		addr := ""
+		if sock := params["mysql_socket"]; sock != "" {
+			addr = "unix(" + sock + ")"
+		} else if host := params["host"]; host != "" {
+			if port := params["port"]; port != "" { host += ":" + port }
+			addr = "tcp(" + host + ")"
+		}
+		auth := user
+		if pass != "" { auth += ":" + pass }
+		return auth + "@" + addr + "/" + dbname
+	}
+	return rest
+}
+
+// _dbiConnMethod runs a method of DBI::db; args[0] is the handle
+func _dbiConnMethod(c *_dbiConn, method string, args []*perlrt.SV) (*perlrt.SV, bool) {
+	arg := func(n int) *perlrt.SV {
+		if n < len(args) && args[n] != nil { return args[n] }
+		return perlrt.SvUndef()
+	}
+	rest := func(n int) []*perlrt.SV {
+		if n < len(args) { return args[n:] }
+		return nil
+	}
+	what := "DBD::" + c.driver + "::db " + method
+	if method != "err" && method != "errstr" { c.err = "" }
+	switch method {
+	case "prepare":
+		s := _dbiPrepare(c, what, arg(1).AsString())
+		if s == nil { return perlrt.SvUndef(), true }
+		sth := perlrt.Perl_bless(s.attrs, perlrt.SvStr("DBI::st"))
+		_dbStmts[sth] = s
+		return sth, true
+	case "do":
+		s := _dbiStatement(c, what, arg(1))
+		if s == nil { return perlrt.SvUndef(), true }
+		defer s.close()
+		return _dbiExecute(s, what, rest(3)), true
+	case "selectrow_array", "selectrow_arrayref", "selectrow_hashref", "selectall_arrayref":
+		s := _dbiStatement(c, what, arg(1))
+		if s != nil { defer s.close() }
+		if s == nil || !_dbiExecute(s, what, rest(3)).IsTrue() {
+			if method == "selectrow_array" { return perlrt.SvArray(), true }
+			return perlrt.SvUndef(), true
+		}
+		switch method {
+		case "selectrow_array":
+			return perlrt.SvArray(_dbiFetch(s, what)...), true
+		case "selectrow_arrayref":
+			return _dbiRowRef(_dbiFetch(s, what)), true
+		case "selectrow_hashref":
+			return _dbiRowHash(s.cols, _dbiFetch(s, what)), true
+		}
+		var slice *perlrt.SV
+		if a := arg(2); a.Flags&perlrt.SVf_HOK != 0 { slice = a.HV["Slice"] }
+		return _dbiFetchAll(s, what, slice), true
+	case "begin_work":
+		if !_dbiFlag(c.attrs, "AutoCommit") {
+			return _dbiFail(c.attrs, &c.err, what, fmt.Errorf("Already in a transaction")), true
+		}
+		tx, err := c.db.Begin()
+		if err != nil { return _dbiFail(c.attrs, &c.err, what, err), true }
+		c.tx, c.work = tx, true
+		c.attrs.HV["AutoCommit"] = perlrt.SvInt(0)
+		return perlrt.SvInt(1), true
+	case "commit", "rollback":
+		if c.tx == nil { return perlrt.SvInt(1), true }
+		var err error
+		if method == "commit" {
+			err = c.tx.Commit()
+		} else {
+			err = c.tx.Rollback()
+		}
+		c.tx = nil
+		if c.work {
+			c.work = false
+			c.attrs.HV["AutoCommit"] = perlrt.SvInt(1)
+		}
+		if err != nil { return _dbiFail(c.attrs, &c.err, what, err), true }
+		return perlrt.SvInt(1), true
+	case "last_insert_id":
+		return perlrt.SvInt(c.lastID), true
+	case "quote":
+		if arg(1).Flags == 0 { return perlrt.SvStr("NULL"), true }
+		return perlrt.SvStr("'" + strings.ReplaceAll(arg(1).AsString(), "'", "''") + "'"), true
+	case "ping":
+		if c.db.Ping() == nil { return perlrt.SvInt(1), true }
+		return perlrt.SvStr(""), true
+	case "disconnect":
+		if c.tx != nil { c.tx.Rollback(); c.tx = nil }
+		c.db.Close()
+		delete(_dbConns, args[0])
+		return perlrt.SvInt(1), true
+	case "err":
+		if c.err == "" { return perlrt.SvUndef(), true }
+		return perlrt.SvInt(1), true
+	case "errstr":
+		if c.err == "" { return perlrt.SvUndef(), true }
+		return perlrt.SvStr(c.err), true
+	}
+	return nil, false
+}
+
+func _dbiPrepare(c *_dbiConn, what, query string) *_dbiStmt {
+	c.attrs.HV["Statement"] = perlrt.SvStr(query)
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		_dbiFail(c.attrs, &c.err, what, err)
+		return nil
+	}
+	attrs := perlrt.SvHash()
+	attrs.HV["Statement"] = perlrt.SvStr(query)
+	return &_dbiStmt{conn: c, attrs: attrs, query: query, stmt: stmt}
+}
+
+// _dbiStatement is the statement of do and select*: prepared from SQL,
+// closed after the call, or a copy of a DBI::st, which stays open
+func _dbiStatement(c *_dbiConn, what string, query *perlrt.SV) *_dbiStmt {
+	if class, ok := perlrt.Blessed(query); ok && class == "DBI::st" {
+		if s := _dbStmts[query]; s != nil {
+			return &_dbiStmt{conn: c, attrs: s.attrs, query: s.query, stmt: s.stmt, shared: true}
+		}
+		return nil
+	}
+	return _dbiPrepare(c, what, query.AsString())
+}
+
+// _dbiReturnsRows tells a statement that returns rows, SELECT and the
+// like or one with RETURNING; the others go through Exec for the count
+func _dbiReturnsRows(query string) bool {
+	upper := strings.ToUpper(query)
+	fields := strings.Fields(strings.TrimLeft(upper, " \t\r\n("))
+	if len(fields) == 0 { return false }
+	switch fields[0] {
+	case "SELECT", "WITH", "PRAGMA", "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "VALUES":
+		return true
+	}
+	return strings.Contains(upper, "RETURNING")
+}
+
+// _dbiStmtMethod runs a method of DBI::st; args are after the handle
+func _dbiStmtMethod(s *_dbiStmt, method string, args []*perlrt.SV) (*perlrt.SV, bool) {
+	what := "DBD::" + s.conn.driver + "::st " + method
+	if method != "err" && method != "errstr" { s.err = "" }
+	switch method {
+	case "execute":
+		return _dbiExecute(s, what, args), true
+	case "fetchrow_arrayref", "fetch":
+		return _dbiRowRef(_dbiFetch(s, what)), true
+	case "fetchrow_array":
+		return perlrt.SvArray(_dbiFetch(s, what)...), true
+	case "fetchrow_hashref":
+		return _dbiRowHash(s.cols, _dbiFetch(s, what)), true
+	case "fetchall_arrayref":
+		var slice *perlrt.SV
+		if len(args) > 0 { slice = args[0] }
+		return _dbiFetchAll(s, what, slice), true
+	case "finish":
+		s.finish()
+		return perlrt.SvInt(1), true
+	case "rows":
+		return perlrt.SvInt(s.count), true
+	case "err":
+		if s.err == "" { return perlrt.SvUndef(), true }
+		return perlrt.SvInt(1), true
+	case "errstr":
+		if s.err == "" { return perlrt.SvUndef(), true }
+		return perlrt.SvStr(s.err), true
+	}
+	return nil, false
+}
+
+// _dbiExecute runs the statement with the values for "?": one returning
+// rows opens a cursor and gives "0E0", others the number of rows changed
+// ("0E0" for none, the true zero of DBI)
+func _dbiExecute(s *_dbiStmt, what string, args []*perlrt.SV) *perlrt.SV {
+	s.finish()
+	c := s.conn
+	var binds []any
+	for _, a := range perlrt.Flatten(args) {
+		switch {
+		case a == nil || a.Flags == 0:
+			binds = append(binds, nil)
+		case a.Flags&perlrt.SVf_POK != 0:
+			binds = append(binds, a.PV)
+		case a.Flags&perlrt.SVf_IOK != 0:
+			binds = append(binds, a.IV)
+		case a.Flags&perlrt.SVf_NOK != 0:
+			binds = append(binds, a.NV)
+		default:
+			binds = append(binds, a.AsString())
+		}
+	}
+	if c.tx == nil && !_dbiFlag(c.attrs, "AutoCommit") {
+		tx, err := c.db.Begin()
+		if err != nil { return _dbiFail(c.attrs, &s.err, what, err) }
+		c.tx = tx
+	}
+	stmt := s.stmt
+	if c.tx != nil { stmt = c.tx.Stmt(stmt) }
+	if _dbiReturnsRows(s.query) {
+		rows, err := stmt.Query(binds...)
+		if err != nil { return _dbiFail(c.attrs, &s.err, what, err) }
+		s.rows = rows
+		s.cols, _ = rows.Columns()
+		names := make([]*perlrt.SV, len(s.cols))
+		for n, col := range s.cols { names[n] = perlrt.SvStr(col) }
+		s.attrs.HV["NAME"] = perlrt.SvArray(names...)
+		s.attrs.HV["NUM_OF_FIELDS"] = perlrt.SvInt(int64(len(s.cols)))
+		return perlrt.SvStr("0E0")
+	}
+	res, err := stmt.Exec(binds...)
+	if err != nil { return _dbiFail(c.attrs, &s.err, what, err) }
+	s.count, _ = res.RowsAffected()
+	if id, err := res.LastInsertId(); err == nil { c.lastID = id }
+	if s.count == 0 { return perlrt.SvStr("0E0") }
+	return perlrt.SvInt(s.count)
+}
+
+// _dbiFetch is the next row of the cursor; nil after the last, when the
+// cursor is closed
+func _dbiFetch(s *_dbiStmt, what string) []*perlrt.SV {
+	if s.rows == nil { return nil }
+	if !s.rows.Next() {
+		err := s.rows.Err()
+		s.finish()
+		if err != nil { _dbiFail(s.conn.attrs, &s.err, what, err) }
+		return nil
+	}
+	values := make([]any, len(s.cols))
+	ptrs := make([]any, len(s.cols))
+	for n := range values { ptrs[n] = &values[n] }
+	if err := s.rows.Scan(ptrs...); err != nil {
+		s.finish()
+		_dbiFail(s.conn.attrs, &s.err, what, err)
+		return nil
+	}
+	s.count++
+	row := make([]*perlrt.SV, len(values))
+	for n, v := range values {
+		switch v := v.(type) {
+		case nil:
+			row[n] = perlrt.SvUndef()
+		case int64:
+			row[n] = perlrt.SvInt(v)
+		case float64:
+			row[n] = perlrt.SvFloat(v)
+		case bool:
+			row[n] = perlrt.SvInt(0)
+			if v { row[n] = perlrt.SvInt(1) }
+		case []byte:
+			row[n] = perlrt.SvStr(string(v))
+		case string:
+			row[n] = perlrt.SvStr(v)
+		case time.Time:
+			row[n] = perlrt.SvStr(v.Format("2006-01-02 15:04:05"))
+		default:
+			row[n] = perlrt.SvStr(fmt.Sprint(v))
+		}
+	}
+	return row
+}
+
+// _dbiFetchAll is all the rows Left: array refs, or with a {} slice hash
+// refs by column name
+func _dbiFetchAll(s *_dbiStmt, what string, slice *perlrt.SV) *perlrt.SV {
+	hashes := slice != nil && slice.Flags&perlrt.SVf_HOK != 0
+	var rows []*perlrt.SV
+	for row := _dbiFetch(s, what); row != nil; row = _dbiFetch(s, what) {
+		if hashes {
+			rows = append(rows, _dbiRowHash(s.cols, row))
+		} else {
+			rows = append(rows, _dbiRowRef(row))
+		}
+	}
+	return perlrt.SvArray(rows...)
+}
+
+func _dbiRowRef(row []*perlrt.SV) *perlrt.SV {
+	if row == nil { return perlrt.SvUndef() }
+	return perlrt.SvArray(row...)
+}
+
+func _dbiRowHash(cols []string, row []*perlrt.SV) *perlrt.SV {
+	if row == nil { return perlrt.SvUndef() }
+	h := perlrt.SvHash()
+	for n, col := range cols { h.HV[col] = row[n] }
+	return h
+}
+
+func (s *_dbiStmt) finish() {
+	if s.rows != nil {
+		s.rows.Close()
+		s.rows = nil
+	}
+}
+
+func (s *_dbiStmt) close() {
+	s.finish()
+	if !s.shared { s.stmt.Close() }
+}
+
+func init() {
+	perlrt.Methods["DBI_connect"] = func(args ...*perlrt.SV) *perlrt.SV { return _dbiMethod("DBI", "connect", args) }
+	perlrt.Methods["DBI_err"] = func(args ...*perlrt.SV) *perlrt.SV { return _dbiMethod("DBI", "err", args) }
+	perlrt.Methods["DBI_errstr"] = func(args ...*perlrt.SV) *perlrt.SV { return _dbiMethod("DBI", "errstr", args) }
+	for _, m := range []string{"prepare", "do", "selectrow_array", "selectrow_arrayref", "selectrow_hashref",
+		"selectall_arrayref", "begin_work", "commit", "rollback", "last_insert_id", "quote", "ping",
+		"disconnect", "err", "errstr"} {
+		method := m
+		perlrt.Methods["DBI::db_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _dbiMethod("DBI::db", method, args) }
+	}
+	for _, m := range []string{"execute", "fetchrow_arrayref", "fetch", "fetchrow_array", "fetchrow_hashref",
+		"fetchall_arrayref", "finish", "rows", "err", "errstr"} {
+		method := m
+		perlrt.Methods["DBI::st_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _dbiMethod("DBI::st", method, args) }
+	}
+}`)
+}
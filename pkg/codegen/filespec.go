@@ -0,0 +1,263 @@
+package codegen
+
+import "perlc/pkg/ast"
+
+// fileFuncs are the functions of File::Basename, File::Path and Cwd by
+// module; fileDefaults are those a module exports without a list
+var fileFuncs = map[string]string{
+	"basename": "File::Basename", "dirname": "File::Basename", "fileparse": "File::Basename",
+	"make_path": "File::Path", "mkpath": "File::Path", "remove_tree": "File::Path", "rmtree": "File::Path",
+	"abs_path": "Cwd", "realpath": "Cwd",
+}
+
+var fileDefaults = map[string]bool{
+	"basename": true, "dirname": true, "fileparse": true, "mkpath": true, "rmtree": true,
+}
+
+// useFileModule records the names imported by use File::Basename, use
+// File::Path or use Cwd, which call Module::name
+func (g *Generator) useFileModule(module string, args []ast.Expression) {
+	if g.fileFuncs == nil {
+		g.fileFuncs = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		for name, m := range fileFuncs {
+			if m == module && fileDefaults[name] {
+				g.fileFuncs[name] = true
+			}
+		}
+		return
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if fileFuncs[name] == module {
+				g.fileFuncs[name] = true
+			}
+		}
+	}
+}
+
+// writeFileRuntime emits File::Basename, File::Path and Cwd::abs_path
+// over path/filepath and os, and with use File::Spec its class methods
+// in their unix flavour
+func (g *Generator) writeFileRuntime() {
+	g.writeln(`// perl_File_Basename_fileparse splits a path into (name, dir, suffix):
+// dir keeps its trailing "/" or is "./", and each suffix pattern is cut
+// off the end of the name in turn
+func perl_File_Basename_fileparse(args ...*perlrt.SV) *perlrt.SV {
+	args = perlrt.Flatten(args)
+	if len(args) == 0 { return perlrt.SvArray() }
+	name, dir, suffix := _fileparse(args[0].AsString(), args[1:], false)
+	return perlrt.SvArray(perlrt.SvStr(name), perlrt.SvStr(dir), perlrt.SvStr(suffix))
+}
+
+func _fileparse(path string, suffixes []*perlrt.SV, literal bool) (string, string, string) {
+	dir, name := "./", path
+	if idx := strings.LastIndex(path, "/"); idx >= 0 { dir, name = path[:idx+1], path[idx+1:] }
+	tail := ""
+	for _, s := range suffixes {
+		pattern := s.AsString()
+		if s.Flags&0x40 == 0 && literal { pattern = regexp.QuoteMeta(pattern) }
+		if loc := perlrt.Regex("(?s:" + pattern + ")\\z").FindStringSubmatchIndex(name); loc != nil {
+			tail = name[loc[0]:] + tail
+			name = name[:loc[0]]
+		}
+	}
+	return name, dir, tail
+}
+
+var _trailingSepRe = regexp.MustCompile(` + "`" + `(.)/*\z` + "`" + `)
+
+// perl_File_Basename_basename is the last part of a path, trailing
+// slashes ignored; a suffix is only removed if something is left
+func perl_File_Basename_basename(args ...*perlrt.SV) *perlrt.SV {
+	args = perlrt.Flatten(args)
+	if len(args) == 0 { return perlrt.SvStr("") }
+	name, dir, suffix := _fileparse(_trailingSepRe.ReplaceAllString(args[0].AsString(), "$1"), args[1:], true)
+	if suffix != "" && name == "" { name = suffix }
+	if name == "" { name = dir }
+	return perlrt.SvStr(name)
+}
+
+// perl_File_Basename_dirname is the path without its last part: "." for
+// a plain name, "/" for the root
+func perl_File_Basename_dirname(args ...*perlrt.SV) *perlrt.SV {
+	path := ""
+	if len(args) > 0 { path = args[0].AsString() }
+	name, dir, _ := _fileparse(path, nil, false)
+	dir = _trailingSepRe.ReplaceAllString(dir, "$1")
+	if name == "" {
+		_, dir, _ = _fileparse(dir, nil, false)
+		dir = _trailingSepRe.ReplaceAllString(dir, "$1")
+	}
+	if dir == "./" { dir = "." }
+	return perlrt.SvStr(dir)
+}
+
+// _pathArgs are the directories of make_path and remove_tree; an options
+// hash at the end is not one
+func _pathArgs(args []*perlrt.SV) []string {
+	var dirs []string
+	for _, a := range perlrt.Flatten(args) {
+		if a.Flags&perlrt.SVf_HOK == 0 { dirs = append(dirs, a.AsString()) }
+	}
+	return dirs
+}
+
+// perl_File_Path_make_path creates the directories with their missing
+// parents and returns those it created, outermost first
+func perl_File_Path_make_path(args ...*perlrt.SV) *perlrt.SV {
+	var created []*perlrt.SV
+	for _, dir := range _pathArgs(args) {
+		var missing []string
+		for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+			if _, err := os.Stat(d); err == nil { break }
+			missing = append([]string{d}, missing...)
+			if filepath.Dir(d) == d { break }
+		}
+		for _, d := range missing {
+			if err := os.Mkdir(d, 0o777); err != nil { perlrt.SetOSError(err); break }
+			created = append(created, perlrt.SvStr(d))
+		}
+	}
+	return perlrt.SvArray(created...)
+}
+
+func perl_File_Path_mkpath(args ...*perlrt.SV) *perlrt.SV { return perl_File_Path_make_path(args...) }
+
+// perl_File_Path_remove_tree removes the directories with everything in
+// them and returns how many files and directories went
+func perl_File_Path_remove_tree(args ...*perlrt.SV) *perlrt.SV {
+	count := 0
+	for _, dir := range _pathArgs(args) {
+		if _, err := os.Lstat(dir); err != nil { continue }
+		n := 0
+		filepath.Walk(dir, func(string, os.FileInfo, error) error { n++; return nil })
+		if err := os.RemoveAll(dir); err != nil { perlrt.SetOSError(err); continue }
+		count += n
+	}
+	return perlrt.SvInt(int64(count))
+}
+
+func perl_File_Path_rmtree(args ...*perlrt.SV) *perlrt.SV { return perl_File_Path_remove_tree(args...) }
+
+// perl_Cwd_abs_path resolves a path to an absolute one without symlinks;
+// undef if it does not exist
+func perl_Cwd_abs_path(args ...*perlrt.SV) *perlrt.SV {
+	path := "."
+	if len(args) > 0 && args[0].AsString() != "" { path = args[0].AsString() }
+	abs, err := filepath.Abs(path)
+	if err == nil { abs, err = filepath.EvalSymlinks(abs) }
+	if err != nil { perlrt.SetOSError(err); return perlrt.SvUndef() }
+	return perlrt.SvStr(abs)
+}
+
+func perl_Cwd_realpath(args ...*perlrt.SV) *perlrt.SV { return perl_Cwd_abs_path(args...) }`)
+	if !g.fileSpec {
+		return
+	}
+	g.writeln("")
+	g.writeln(`var (
+	_multiSlashRe = regexp.MustCompile(` + "`" + `/{2,}` + "`" + `)
+	_dotSegRe     = regexp.MustCompile(` + "`" + `(?:/\.)+(?:/|\z)` + "`" + `)
+	_leadDotRe    = regexp.MustCompile(` + "`" + `^(?:\./)+` + "`" + `)
+	_rootUpRe     = regexp.MustCompile(` + "`" + `^/(?:\.\./)+` + "`" + `)
+)
+
+// _canonpath is File::Spec::Unix::canonpath: extra "/" and "." go, and
+// ".." at the start of an absolute path is dropped
+func _canonpath(path string) string {
+	if path == "" { return "" }
+	path = _multiSlashRe.ReplaceAllString(path, "/")
+	path = _dotSegRe.ReplaceAllString(path, "/")
+	if path != "./" { path = _leadDotRe.ReplaceAllString(path, "") }
+	path = _rootUpRe.ReplaceAllString(path, "/")
+	if path == "/.." { path = "/" }
+	if path != "/" { path = strings.TrimSuffix(path, "/") }
+	return path
+}
+
+func _catdir(dirs []string) string {
+	if len(dirs) == 0 { return "" }
+	return _canonpath(strings.Join(dirs, "/") + "/")
+}
+
+// _fileSpecMethod runs File::Spec->method(args): args[0] is the class
+func _fileSpecMethod(method string, args []*perlrt.SV) *perlrt.SV {
+	var strs []string
+	for _, a := range perlrt.Flatten(args[1:]) { strs = append(strs, a.AsString()) }
+	arg := func(n int) string {
+		if n < len(strs) { return strs[n] }
+		return ""
+	}
+	switch method {
+	case "canonpath":
+		return perlrt.SvStr(_canonpath(arg(0)))
+	case "catdir":
+		return perlrt.SvStr(_catdir(strs))
+	case "catfile":
+		if len(strs) == 0 { return perlrt.SvStr("") }
+		file := _canonpath(strs[len(strs)-1])
+		if len(strs) == 1 { return perlrt.SvStr(file) }
+		dir := _catdir(strs[:len(strs)-1])
+		if !strings.HasSuffix(dir, "/") { dir += "/" }
+		return perlrt.SvStr(dir + file)
+	case "catpath":
+		dir, file := arg(1), arg(2)
+		if dir != "" && file != "" && !strings.HasSuffix(dir, "/") { dir += "/" }
+		return perlrt.SvStr(dir + file)
+	case "splitpath":
+		path := arg(0)
+		if len(args) > 2 && args[2].IsTrue() { return perlrt.SvArray(perlrt.SvStr(""), perlrt.SvStr(path), perlrt.SvStr("")) }
+		dir, file := "", path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 { dir, file = path[:idx+1], path[idx+1:] }
+		return perlrt.SvArray(perlrt.SvStr(""), perlrt.SvStr(dir), perlrt.SvStr(file))
+	case "splitdir":
+		var parts []*perlrt.SV
+		for _, p := range strings.Split(arg(0), "/") { parts = append(parts, perlrt.SvStr(p)) }
+		return perlrt.SvArray(parts...)
+	case "file_name_is_absolute":
+		if strings.HasPrefix(arg(0), "/") { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	case "rel2abs":
+		if strings.HasPrefix(arg(0), "/") { return perlrt.SvStr(_canonpath(arg(0))) }
+		base := arg(1)
+		if base == "" {
+			base, _ = os.Getwd()
+		} else if !strings.HasPrefix(base, "/") {
+			base = _fileSpecMethod("rel2abs", []*perlrt.SV{args[0], perlrt.SvStr(base)}).AsString()
+		}
+		return perlrt.SvStr(_catdir([]string{base, arg(0)}))
+	case "abs2rel":
+		base := arg(1)
+		if base == "" { base, _ = os.Getwd() }
+		path, _ := filepath.Abs(arg(0))
+		base, _ = filepath.Abs(base)
+		rel, err := filepath.Rel(base, path)
+		if err != nil { return perlrt.SvStr(_canonpath(arg(0))) }
+		return perlrt.SvStr(rel)
+	case "tmpdir":
+		if dir := os.Getenv("TMPDIR"); dir != "" {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() { return perlrt.SvStr(_canonpath(dir)) }
+		}
+		return perlrt.SvStr("/tmp")
+	case "curdir":
+		return perlrt.SvStr(".")
+	case "updir":
+		return perlrt.SvStr("..")
+	case "rootdir":
+		return perlrt.SvStr("/")
+	case "devnull":
+		return perlrt.SvStr("/dev/null")
+	}
+	return perlrt.SvUndef()
+}
+
+func init() {
+	for _, m := range []string{"canonpath", "catdir", "catfile", "catpath", "splitpath", "splitdir",
+		"file_name_is_absolute", "rel2abs", "abs2rel", "tmpdir", "curdir", "updir", "rootdir", "devnull"} {
+		method := m
+		perlrt.Methods["File::Spec_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _fileSpecMethod(method, args) }
+	}
+}`)
+}
@@ -0,0 +1,311 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"perlc/pkg/ast"
+)
+
+// getoptFuncs are the functions of Getopt::Long and Getopt::Std, by the
+// module that exports them by default
+var getoptFuncs = map[string]string{
+	"GetOptions": "Getopt::Long",
+	"getopts":    "Getopt::Std",
+	"getopt":     "Getopt::Std",
+}
+
+// useGetopt records the names imported by use Getopt::Long or use
+// Getopt::Std LIST; :config and other tags leave GetOptions imported
+func (g *Generator) useGetopt(module string, args []ast.Expression) {
+	if g.getopt == nil {
+		g.getopt = make(map[string]bool)
+	}
+	var names []string
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if getoptFuncs[name] == module {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 || module == "Getopt::Long" {
+		for name, m := range getoptFuncs {
+			if m == module {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		g.getopt[name] = true
+	}
+}
+
+// generateGetoptsCall emits getopts SPEC and getopt SPEC without a hash:
+// the options go to $opt_a of the current package, which the spec names
+// at compile time. With a hash, or a spec known only at run time, it is a
+// plain call.
+func (g *Generator) generateGetoptsCall(name string, args []ast.Expression) {
+	fn := g.funcName(name)
+	spec, ok := (*ast.StringLiteral)(nil), false
+	if len(args) == 1 {
+		spec, ok = args[0].(*ast.StringLiteral)
+	}
+	if !ok {
+		g.write(fn + "(")
+		for i, a := range args {
+			if i > 0 {
+				g.write(", ")
+			}
+			g.generateExpression(a)
+		}
+		g.write(")")
+		return
+	}
+	letters := map[string]bool{}
+	for _, c := range strings.ReplaceAll(spec.Value, ":", "") {
+		letters[string(c)] = true
+	}
+	names := make([]string, 0, len(letters))
+	for c := range letters {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	g.write(fmt.Sprintf("func() *perlrt.SV { _opts := perlrt.SvHash(); _r := %s(perlrt.SvStr(%q), _opts); ", fn, spec.Value))
+	for _, c := range names {
+		g.write(fmt.Sprintf("if v, ok := _opts.HV[%q]; ok { %s = v }; ", c, g.global("v_", g.pkg+"::opt_"+c)))
+	}
+	g.write("return _r }()")
+}
+
+// writeGetoptRuntime emits Getopt::Long::GetOptions and Getopt::Std's
+// getopts and getopt, which take their options off @ARGV. A reference
+// here is the aggregate itself, a scalar reference is an array flagged
+// 0x80 holding the variable, and a code reference has cv.
+func (g *Generator) writeGetoptRuntime() {
+	g.writeln(`// _getoptOption is an option of a GetOptions spec "name|n=s@": kind 0 a
+// flag, '!' negatable as --noname, '+' a counter, 's', 'i' or 'f' one
+// with a value (optional for ":"), dest '@' or '%' a list or a hash
+type _getoptOption struct {
+	names    []string
+	kind     byte
+	optional bool
+	dest     byte
+	link     *perlrt.SV
+}
+
+var _getoptSpecRe = regexp.MustCompile(` + "`" + `^([\w?-]+(?:\|[\w?-]*)*)(?:(!|\+)|([=:])([sif])([@%])?)?$` + "`" + `)
+var _getoptIntRe = regexp.MustCompile(` + "`" + `^[-+]?\d+$` + "`" + `)
+
+func _isRef(v *perlrt.SV) bool { return v != nil && (v.CV != nil || v.Flags&(perlrt.SVf_AOK|perlrt.SVf_HOK) != 0) }
+
+// perl_Getopt_Long_GetOptions parses @ARGV for GetOptions([\%h,] SPEC =>
+// \$var, ...): options are removed, other arguments stay in order. Names
+// ignore case and may be cut to a unique prefix; an error is warned about
+// and makes the result false.
+func perl_Getopt_Long_GetOptions(args ...*perlrt.SV) *perlrt.SV {
+	var storage *perlrt.SV
+	if len(args) > 0 && args[0].Flags&perlrt.SVf_HOK != 0 { storage, args = args[0], args[1:] }
+	var options []*_getoptOption
+	for n := 0; n < len(args); n++ {
+		m := _getoptSpecRe.FindStringSubmatch(args[n].AsString())
+		if m == nil {
+			fmt.Fprintf(perlrt.Stderr, "Error in option spec: \"%s\"\n", args[n].AsString())
+			return perlrt.SvStr("")
+		}
+		opt := &_getoptOption{names: strings.Split(strings.ToLower(m[1]), "|"), optional: m[3] == ":"}
+		if m[2] != "" { opt.kind = m[2][0] } else if m[4] != "" { opt.kind = m[4][0] }
+		if m[5] != "" { opt.dest = m[5][0] }
+		if n+1 < len(args) && _isRef(args[n+1]) { n++; opt.link = args[n] }
+		options = append(options, opt)
+	}
+	argv := perlrt.A_ARGV.AV
+	var rest []*perlrt.SV
+	ok := true
+	for n := 0; n < len(argv); n++ {
+		arg := argv[n].AsString()
+		if arg == "--" { rest = append(rest, argv[n+1:]...); break }
+		if len(arg) < 2 || arg[0] != '-' { rest = append(rest, argv[n]); continue }
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		opt, matched, negated, err := _getoptLookup(options, strings.ToLower(name))
+		if err != "" { fmt.Fprint(perlrt.Stderr, err); ok = false; continue }
+		if opt.kind == 0 || opt.kind == '!' || opt.kind == '+' {
+			if hasValue {
+				fmt.Fprintf(perlrt.Stderr, "Option %s does not take an argument\n", matched)
+				ok = false
+				continue
+			}
+			val := perlrt.SvInt(1)
+			if negated { val = perlrt.SvInt(0) }
+			_getoptStore(opt, storage, nil, val)
+			continue
+		}
+		if !hasValue && n+1 < len(argv) {
+			next := argv[n+1].AsString()
+			if !opt.optional || _getoptValueOK(opt.kind, next) && (next == "" || next[0] != '-' || opt.kind != 's') {
+				value, hasValue = next, true
+				n++
+			}
+		}
+		if !hasValue {
+			if !opt.optional {
+				fmt.Fprintf(perlrt.Stderr, "Option %s requires an argument\n", matched)
+				ok = false
+				continue
+			}
+			if opt.kind != 's' { value = "0" }
+		}
+		var key *perlrt.SV
+		if opt.dest == '%' || opt.link != nil && opt.link.Flags&perlrt.SVf_HOK != 0 {
+			k, v, found := strings.Cut(value, "=")
+			if !found && opt.kind == 's' {
+				fmt.Fprintf(perlrt.Stderr, "Option %s, key \"%s\", requires a value\n", matched, k)
+				ok = false
+				continue
+			}
+			if !found { v = "1" }
+			key, value = perlrt.SvStr(k), v
+		}
+		if !_getoptValueOK(opt.kind, value) {
+			kind := "number"
+			if opt.kind == 'f' { kind = "real number" }
+			fmt.Fprintf(perlrt.Stderr, "Value \"%s\" invalid for option %s (%s expected)\n", value, matched, kind)
+			ok = false
+			continue
+		}
+		_getoptStore(opt, storage, key, perlrt.SvStr(value))
+	}
+	perlrt.A_ARGV.AV = rest
+	if ok { return perlrt.SvInt(1) }
+	return perlrt.SvStr("")
+}
+
+// _getoptLookup finds the option of a command line name: the name itself,
+// noname and no-name for '!', then a unique prefix
+func _getoptLookup(options []*_getoptOption, name string) (*_getoptOption, string, bool, string) {
+	var prefixed []*_getoptOption
+	var prefixNames []string
+	for _, negate := range []bool{false, true} {
+		bare := name
+		if negate {
+			var ok bool
+			if bare, ok = strings.CutPrefix(name, "no"); !ok { break }
+			bare = strings.TrimPrefix(bare, "-")
+		}
+		for _, opt := range options {
+			if negate && opt.kind != '!' { continue }
+			for _, n := range opt.names { if n == bare { return opt, n, negate, "" } }
+			for _, n := range opt.names {
+				if !negate && strings.HasPrefix(n, bare) {
+					prefixed, prefixNames = append(prefixed, opt), append(prefixNames, n)
+					break
+				}
+			}
+		}
+	}
+	switch {
+	case len(prefixed) == 1:
+		return prefixed[0], prefixNames[0], false, ""
+	case len(prefixed) > 1:
+		sort.Strings(prefixNames)
+		return nil, "", false, fmt.Sprintf("Option %s is ambiguous (%s)\n", name, strings.Join(prefixNames, ", "))
+	}
+	return nil, "", false, fmt.Sprintf("Unknown option: %s\n", name)
+}
+
+func _getoptValueOK(kind byte, value string) bool {
+	switch kind {
+	case 'i': return _getoptIntRe.MatchString(value)
+	case 'f': return perlstr.LooksLikeNumber(value)
+	}
+	return true
+}
+
+// _getoptStore puts the value of an option where it is linked: a
+// variable, an array, a hash, a sub, or the hash \%h under its first name
+func _getoptStore(opt *_getoptOption, storage, key, val *perlrt.SV) {
+	name := opt.names[0]
+	link := opt.link
+	if link == nil {
+		if storage == nil { return }
+		old, found := storage.HV[name]
+		switch {
+		case opt.dest == '@' && !found: storage.HV[name] = perlrt.SvArray()
+		case opt.dest == '%' && !found: storage.HV[name] = perlrt.SvHash()
+		case opt.dest == 0:
+			if opt.kind == '+' && found { val = perlrt.SvAdd(old, val) }
+			storage.HV[name] = val
+			return
+		}
+		link = storage.HV[name]
+	}
+	switch {
+	case link.CV != nil:
+		if key != nil { link.CV(perlrt.SvStr(name), key, val) } else { link.CV(perlrt.SvStr(name), val) }
+	case link.Flags&0x80 != 0 && len(link.AV) > 0:
+		// "name=s@" => \$list: the values gather in the array it refers to
+		target := link.AV[0]
+		switch {
+		case opt.dest == '@':
+			if target.Flags&perlrt.SVf_AOK == 0 { *target = *perlrt.SvArray() }
+			target.AV = append(target.AV, val)
+		case opt.dest == '%':
+			if target.Flags&perlrt.SVf_HOK == 0 { *target = *perlrt.SvHash() }
+			target.HV[key.AsString()] = val
+		case opt.kind == '+':
+			*target = *perlrt.SvAdd(target, val)
+		default:
+			*target = *val
+		}
+	case link.Flags&perlrt.SVf_AOK != 0:
+		link.AV = append(link.AV, val)
+	case link.Flags&perlrt.SVf_HOK != 0:
+		link.HV[key.AsString()] = val
+	}
+}`)
+	g.writeln("")
+	g.writeln(`// perl_Getopt_Std_getopts parses the bundled one-letter options at the
+// start of @ARGV for getopts("ab:", \%h): a letter followed by ':' takes a
+// value. getopt("b", \%h) gives a value to the letters listed and makes
+// the others switches.
+func perl_Getopt_Std_getopts(args ...*perlrt.SV) *perlrt.SV { return _getopts(false, args) }
+
+func perl_Getopt_Std_getopt(args ...*perlrt.SV) *perlrt.SV { return _getopts(true, args) }
+
+func _getopts(all bool, args []*perlrt.SV) *perlrt.SV {
+	if len(args) < 2 || args[1].Flags&perlrt.SVf_HOK == 0 { return perlrt.SvStr("") }
+	spec, opts := args[0].AsString(), args[1]
+	argv := perlrt.A_ARGV.AV
+	ok := true
+	for len(argv) > 0 {
+		arg := argv[0].AsString()
+		if arg == "--" { argv = argv[1:]; break }
+		if len(arg) < 2 || arg[0] != '-' { break }
+		argv = argv[1:]
+		for n := 1; n < len(arg); n++ {
+			letter := arg[n]
+			pos := strings.IndexByte(spec, letter)
+			takesValue := pos >= 0 && pos+1 < len(spec) && spec[pos+1] == ':'
+			if all {
+				takesValue = pos >= 0
+			} else if pos < 0 || letter == ':' {
+				fmt.Fprintf(perlrt.Stderr, "Unknown option: %c\n", letter)
+				ok = false
+				continue
+			}
+			if !takesValue { opts.HV[string(letter)] = perlrt.SvInt(1); continue }
+			value := perlrt.SvStr(arg[n+1:])
+			if n+1 == len(arg) {
+				value = perlrt.SvUndef()
+				if len(argv) > 0 { value, argv = argv[0], argv[1:] }
+			}
+			opts.HV[string(letter)] = value
+			break
+		}
+	}
+	perlrt.A_ARGV.AV = argv
+	if ok { return perlrt.SvInt(1) }
+	return perlrt.SvStr("")
+}`)
+}
@@ -0,0 +1,362 @@
+package codegen
+
+// writeHTTPRuntime emits HTTP::Tiny and the LWP::UserAgent facade over
+// net/http, as the interpreter has them: the objects are blessed hashes of
+// their attributes, HTTP::Tiny answers with a hash of success, status,
+// reason, content, headers, url and protocol, LWP::UserAgent with an
+// HTTP::Response object. Redirects are followed for GET and HEAD, and 303.
+func (g *Generator) writeHTTPRuntime() {
+	g.writeln(`func _httpTinyDefaults() map[string]*perlrt.SV {
+	return map[string]*perlrt.SV{"agent": perlrt.SvStr("HTTP-Tiny/0.080"), "timeout": perlrt.SvInt(60), "max_redirect": perlrt.SvInt(5), "verify_SSL": perlrt.SvInt(1)}
+}
+
+func _lwpDefaults() map[string]*perlrt.SV {
+	return map[string]*perlrt.SV{"agent": perlrt.SvStr("libwww-perl/6.67"), "timeout": perlrt.SvInt(180), "max_redirect": perlrt.SvInt(7)}
+}
+
+// _httpNew is the constructor: the defaults, then the attributes given
+func _httpNew(class string, defaults map[string]*perlrt.SV, args []*perlrt.SV) *perlrt.SV {
+	obj := perlrt.SvHash()
+	for k, v := range defaults { obj.HV[k] = v }
+	pairs := perlrt.Flatten(args)
+	for n := 0; n+1 < len(pairs); n += 2 { obj.HV[pairs[n].AsString()] = perlrt.ListCopy(pairs[n+1 : n+2])[0] }
+	return perlrt.Perl_bless(obj, perlrt.SvStr(class))
+}
+
+// _httpAttrs are the attributes of the object; a class method call gets
+// the defaults
+func _httpAttrs(obj *perlrt.SV, defaults map[string]*perlrt.SV) map[string]*perlrt.SV {
+	if _, ok := perlrt.Blessed(obj); ok && obj.Flags&perlrt.SVf_HOK != 0 { return obj.HV }
+	return defaults
+}
+
+func _httpAccessor(attrs map[string]*perlrt.SV, name string, args []*perlrt.SV) *perlrt.SV {
+	if len(args) > 0 {
+		attrs[name] = perlrt.SvStr(args[0].AsString())
+		if args[0].Flags == 0 { attrs[name] = perlrt.SvUndef() }
+	}
+	if v, ok := attrs[name]; ok { return v }
+	return perlrt.SvUndef()
+}
+
+// _httpTinyMethod runs a method of HTTP::Tiny; args[0] is the invocant
+func _httpTinyMethod(method string, args []*perlrt.SV) *perlrt.SV {
+	arg := func(n int) *perlrt.SV {
+		if n < len(args) && args[n] != nil { return args[n] }
+		return perlrt.SvUndef()
+	}
+	if method == "new" { return _httpNew("HTTP::Tiny", _httpTinyDefaults(), args[1:]) }
+	attrs := _httpAttrs(args[0], _httpTinyDefaults())
+	switch method {
+	case "get", "head", "put", "post", "patch", "delete":
+		return _httpTinyRequest(attrs, strings.ToUpper(method), arg(1).AsString(), arg(2))
+	case "request":
+		return _httpTinyRequest(attrs, strings.ToUpper(arg(1).AsString()), arg(2).AsString(), arg(3))
+	case "post_form":
+		opts, headers := perlrt.SvHash(), perlrt.SvHash()
+		if o := arg(3); o.Flags&perlrt.SVf_HOK != 0 {
+			for k, v := range o.HV { opts.HV[k] = v }
+			if hd := opts.HV["headers"]; hd != nil && hd.Flags&perlrt.SVf_HOK != 0 {
+				for k, v := range hd.HV { headers.HV[k] = v }
+			}
+		}
+		headers.HV["content-type"] = perlrt.SvStr("application/x-www-form-urlencoded")
+		opts.HV["headers"] = headers
+		opts.HV["content"] = perlrt.SvStr(_httpFormEncode(arg(2)))
+		return _httpTinyRequest(attrs, "POST", arg(1).AsString(), opts)
+	case "www_form_urlencode":
+		return perlrt.SvStr(_httpFormEncode(arg(1)))
+	case "agent", "timeout", "max_redirect", "default_headers", "verify_SSL":
+		return _httpAccessor(attrs, method, args[1:])
+	}
+	return perlrt.SvUndef()
+}
+
+// _httpFormEncode is www_form_urlencode: hash keys in order, an array as
+// its pairs, an array value repeats the key
+func _httpFormEncode(data *perlrt.SV) string {
+	var parts []string
+	add := func(k string, v *perlrt.SV) {
+		if v.Flags&perlrt.SVf_AOK != 0 && v.Flags&0x80 == 0 {
+			for _, el := range v.AV { parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(el.AsString())) }
+			return
+		}
+		parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v.AsString()))
+	}
+	switch {
+	case data.Flags&perlrt.SVf_HOK != 0:
+		keys := make([]string, 0, len(data.HV))
+		for k := range data.HV { keys = append(keys, k) }
+		sort.Strings(keys)
+		for _, k := range keys { add(k, data.HV[k]) }
+	case data.Flags&perlrt.SVf_AOK != 0:
+		for n := 0; n+1 < len(data.AV); n += 2 { add(data.AV[n].AsString(), data.AV[n+1]) }
+	}
+	return strings.Join(parts, "&")
+}
+
+// _httpTinyRequest runs a request with the options headers and content; a
+// failed connection is a 599 response with the error as its content
+func _httpTinyRequest(attrs map[string]*perlrt.SV, method, rawURL string, opts *perlrt.SV) *perlrt.SV {
+	header := http.Header{}
+	var body *string
+	if opts.Flags&perlrt.SVf_HOK != 0 {
+		_httpAddHeaders(header, opts.HV["headers"])
+		if c, ok := opts.HV["content"]; ok && c.Flags != 0 {
+			s := c.AsString()
+			body = &s
+			if header.Get("Content-Type") == "" { header.Set("Content-Type", "application/octet-stream") }
+		}
+	}
+	_httpAddHeaders(header, attrs["default_headers"])
+	resp, content, err := _httpDo(method, rawURL, header, body, _httpSettings(attrs, "HTTP-Tiny/0.080"))
+	res, headers := perlrt.SvHash(), perlrt.SvHash()
+	res.HV["headers"] = headers
+	if err != nil {
+		msg := err.Error() + "\n"
+		res.HV["success"] = perlrt.SvStr("")
+		res.HV["status"] = perlrt.SvInt(599)
+		res.HV["reason"] = perlrt.SvStr("Internal Exception")
+		res.HV["content"] = perlrt.SvStr(msg)
+		res.HV["url"] = perlrt.SvStr(rawURL)
+		headers.HV["content-type"] = perlrt.SvStr("text/plain")
+		headers.HV["content-length"] = perlrt.SvInt(int64(len(msg)))
+		return res
+	}
+	for name, values := range resp.Header {
+		key := strings.ToLower(name)
+		if len(values) == 1 { headers.HV[key] = perlrt.SvStr(values[0]); continue }
+		list := make([]*perlrt.SV, len(values))
+		for n, v := range values { list[n] = perlrt.SvStr(v) }
+		headers.HV[key] = perlrt.SvArray(list...)
+	}
+	res.HV["success"] = perlrt.SvStr("")
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 { res.HV["success"] = perlrt.SvInt(1) }
+	res.HV["status"] = perlrt.SvInt(int64(resp.StatusCode))
+	res.HV["reason"] = perlrt.SvStr(_httpReason(resp))
+	res.HV["content"] = perlrt.SvStr(string(content))
+	res.HV["url"] = perlrt.SvStr(resp.Request.URL.String())
+	res.HV["protocol"] = perlrt.SvStr(resp.Proto)
+	return res
+}
+
+// _httpAddHeaders adds the headers of a hash; an array value is several
+// headers, and those already set are kept
+func _httpAddHeaders(header http.Header, hv *perlrt.SV) {
+	if hv == nil || hv.Flags&perlrt.SVf_HOK == 0 { return }
+	for name, v := range hv.HV {
+		if header.Get(name) != "" { continue }
+		if v.Flags&perlrt.SVf_AOK != 0 && v.Flags&0x80 == 0 {
+			for _, el := range v.AV { header.Add(name, el.AsString()) }
+			continue
+		}
+		header.Set(name, v.AsString())
+	}
+}
+
+type _httpConfig struct {
+	agent     string
+	timeout   time.Duration
+	redirects int
+	verify    bool
+}
+
+func _httpSettings(attrs map[string]*perlrt.SV, agent string) _httpConfig {
+	c := _httpConfig{agent: agent, timeout: 60 * time.Second, redirects: 5, verify: true}
+	if v, ok := attrs["agent"]; ok { c.agent = v.AsString() }
+	if v, ok := attrs["timeout"]; ok && v.AsFloat() > 0 { c.timeout = time.Duration(v.AsFloat() * float64(time.Second)) }
+	if v, ok := attrs["max_redirect"]; ok { c.redirects = int(v.AsInt()) }
+	if v, ok := attrs["verify_SSL"]; ok { c.verify = v.IsTrue() }
+	if v, ok := attrs["ssl_opts"]; ok && v.Flags&perlrt.SVf_HOK != 0 {
+		if verify, ok := v.HV["verify_hostname"]; ok { c.verify = verify.IsTrue() }
+	}
+	return c
+}
+
+// _httpDo runs the request and reads the body of the response
+func _httpDo(method, rawURL string, header http.Header, body *string, c _httpConfig) (*http.Response, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, fmt.Errorf("Unsupported URL scheme or malformed URL '%s'", rawURL)
+	}
+	var reader io.Reader
+	if body != nil { reader = strings.NewReader(*body) }
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil { return nil, nil, err }
+	req.Header = header
+	if req.Header.Get("User-Agent") == "" { req.Header.Set("User-Agent", c.agent) }
+	client := &http.Client{
+		Timeout: c.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			first := via[0].Method
+			if len(via) > c.redirects || first != "GET" && first != "HEAD" && req.Response.StatusCode != http.StatusSeeOther {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	if !c.verify {
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Do(req)
+	if err != nil { return nil, nil, err }
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil { return nil, nil, err }
+	return resp, content, nil
+}
+
+func _httpReason(resp *http.Response) string {
+	return strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+}
+
+func _httpIsForm(v *perlrt.SV) bool {
+	if _, ok := perlrt.Blessed(v); ok { return false }
+	return v.Flags&(perlrt.SVf_AOK|perlrt.SVf_HOK) != 0 && v.Flags&0x80 == 0
+}
+
+// _lwpMethod runs a method of LWP::UserAgent; args[0] is the invocant
+func _lwpMethod(method string, args []*perlrt.SV) *perlrt.SV {
+	if method == "new" { return _httpNew("LWP::UserAgent", _lwpDefaults(), args[1:]) }
+	attrs := _httpAttrs(args[0], _lwpDefaults())
+	switch method {
+	case "get", "head", "delete", "post", "put", "patch":
+		if len(args) < 2 { return perlrt.SvUndef() }
+		return _lwpRequest(attrs, strings.ToUpper(method), args[1].AsString(), args[2:])
+	case "agent", "timeout", "max_redirect":
+		return _httpAccessor(attrs, method, args[1:])
+	case "default_header":
+		headers, ok := attrs["default_headers"]
+		if !ok || headers.Flags&perlrt.SVf_HOK == 0 {
+			headers = perlrt.SvHash()
+			attrs["default_headers"] = headers
+		}
+		pairs := perlrt.Flatten(args[1:])
+		if len(pairs) == 1 {
+			if v, ok := headers.HV[strings.ToLower(pairs[0].AsString())]; ok { return v }
+			return perlrt.SvUndef()
+		}
+		for n := 0; n+1 < len(pairs); n += 2 { headers.HV[strings.ToLower(pairs[n].AsString())] = perlrt.SvStr(pairs[n+1].AsString()) }
+		return perlrt.SvInt(1)
+	}
+	return perlrt.SvUndef()
+}
+
+// _lwpRequest is $ua->get(URL, Header => Value, ..., Content => ...); for
+// post a hash or array ref after the URL is a form
+func _lwpRequest(attrs map[string]*perlrt.SV, method, rawURL string, args []*perlrt.SV) *perlrt.SV {
+	header := http.Header{}
+	var body *string
+	setForm := func(form *perlrt.SV) {
+		s := _httpFormEncode(form)
+		body = &s
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if len(args) > 0 && _httpIsForm(args[0]) {
+		setForm(args[0])
+		args = args[1:]
+	}
+	for n := 0; n+1 < len(args); n += 2 {
+		name, value := args[n].AsString(), args[n+1]
+		if name == "Content" {
+			if _httpIsForm(value) {
+				setForm(value)
+			} else {
+				s := value.AsString()
+				body = &s
+			}
+			continue
+		}
+		header.Add(name, value.AsString())
+	}
+	_httpAddHeaders(header, attrs["default_headers"])
+	resp, content, err := _httpDo(method, rawURL, header, body, _httpSettings(attrs, "libwww-perl/6.67"))
+	res, headers := perlrt.SvHash(), perlrt.SvHash()
+	res.HV["_headers"] = headers
+	if err != nil {
+		res.HV["_rc"] = perlrt.SvInt(500)
+		res.HV["_msg"] = perlrt.SvStr(err.Error())
+		res.HV["_content"] = perlrt.SvStr(err.Error() + "\n")
+		headers.HV["client-warning"] = perlrt.SvStr("Internal response")
+		headers.HV["content-type"] = perlrt.SvStr("text/plain")
+		return perlrt.Perl_bless(res, perlrt.SvStr("HTTP::Response"))
+	}
+	for name, values := range resp.Header { headers.HV[strings.ToLower(name)] = perlrt.SvStr(strings.Join(values, ", ")) }
+	res.HV["_rc"] = perlrt.SvInt(int64(resp.StatusCode))
+	res.HV["_msg"] = perlrt.SvStr(_httpReason(resp))
+	res.HV["_content"] = perlrt.SvStr(string(content))
+	res.HV["_protocol"] = perlrt.SvStr(resp.Proto)
+	res.HV["_base"] = perlrt.SvStr(resp.Request.URL.String())
+	return perlrt.Perl_bless(res, perlrt.SvStr("HTTP::Response"))
+}
+
+// _httpResponseMethod runs a method of HTTP::Response
+func _httpResponseMethod(method string, args []*perlrt.SV) *perlrt.SV {
+	r := args[0]
+	if r.Flags&perlrt.SVf_HOK == 0 { return perlrt.SvUndef() }
+	field := func(name string) *perlrt.SV {
+		if v, ok := r.HV[name]; ok { return v }
+		return perlrt.SvUndef()
+	}
+	header := func(name string) *perlrt.SV {
+		if hv := field("_headers"); hv.Flags&perlrt.SVf_HOK != 0 {
+			if v, ok := hv.HV[strings.ToLower(name)]; ok { return v }
+		}
+		return perlrt.SvUndef()
+	}
+	code := field("_rc").AsInt()
+	yes := func(b bool) *perlrt.SV {
+		if b { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	}
+	switch method {
+	case "code":
+		return field("_rc")
+	case "message":
+		return field("_msg")
+	case "status_line":
+		return perlrt.SvStr(strconv.FormatInt(code, 10) + " " + field("_msg").AsString())
+	case "content", "decoded_content":
+		return field("_content")
+	case "is_success":
+		return yes(code >= 200 && code < 300)
+	case "is_redirect":
+		return yes(code >= 300 && code < 400)
+	case "is_error":
+		return yes(code >= 400)
+	case "is_info":
+		return yes(code >= 100 && code < 200)
+	case "protocol":
+		return field("_protocol")
+	case "base":
+		return field("_base")
+	case "header":
+		if len(args) < 2 { return perlrt.SvUndef() }
+		return header(args[1].AsString())
+	case "content_type":
+		ct, _, _ := strings.Cut(header("Content-Type").AsString(), ";")
+		return perlrt.SvStr(strings.TrimSpace(ct))
+	case "content_length":
+		return header("Content-Length")
+	}
+	return perlrt.SvUndef()
+}
+
+func init() {
+	for _, m := range []string{"new", "get", "head", "put", "post", "patch", "delete", "request", "post_form",
+		"www_form_urlencode", "agent", "timeout", "max_redirect", "default_headers", "verify_SSL"} {
+		method := m
+		perlrt.Methods["HTTP::Tiny_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _httpTinyMethod(method, args) }
+	}
+	for _, m := range []string{"new", "get", "head", "delete", "post", "put", "patch", "agent", "timeout",
+		"max_redirect", "default_header"} {
+		method := m
+		perlrt.Methods["LWP::UserAgent_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _lwpMethod(method, args) }
+	}
+	for _, m := range []string{"code", "message", "status_line", "content", "decoded_content", "is_success",
+		"is_redirect", "is_error", "is_info", "protocol", "base", "header", "content_type", "content_length"} {
+		method := m
+		perlrt.Methods["HTTP::Response_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _httpResponseMethod(method, args) }
+	}
+}`)
+}
@@ -0,0 +1,237 @@
+package codegen
+
+import (
+	"strings"
+
+	"perlc/pkg/ast"
+)
+
+// constant is a use constant value. Literals are inlined at each use;
+// anything else is computed once into a package-level var, as Perl computes
+// it once at compile time.
+type constant struct {
+	value ast.Expression
+	goVar string
+}
+
+// inlineFrame holds the caller's arguments while an inlined sub body is
+// generated: $_[N] in the body becomes the N-th argument expression.
+type inlineFrame struct {
+	args  []ast.Expression
+	outer *inlineFrame
+}
+
+// pureBuiltins may appear in an inlined body: no side effects, one value.
+var pureBuiltins = map[string]bool{
+	"abs": true, "int": true, "sqrt": true, "length": true,
+	"uc": true, "lc": true, "ucfirst": true, "lcfirst": true,
+}
+
+// defineConstants records use constant NAME => VALUE and
+// use constant { A => 1, B => 2 }.
+func (g *Generator) defineConstants(use *ast.UseDecl) {
+	if len(use.Args) == 0 {
+		return
+	}
+	if h, ok := use.Args[0].(*ast.HashExpr); ok {
+		for _, pair := range h.Pairs {
+			if key, ok := pair.Key.(*ast.StringLiteral); ok {
+				g.defineConstant(key.Value, pair.Value)
+			}
+		}
+		return
+	}
+	name, ok := use.Args[0].(*ast.StringLiteral)
+	if !ok {
+		return
+	}
+	switch values := use.Args[1:]; len(values) {
+	case 0:
+		g.defineConstant(name.Value, &ast.UndefLiteral{Token: name.Token})
+	case 1:
+		g.defineConstant(name.Value, values[0])
+	default:
+		g.defineConstant(name.Value, &ast.ArrayExpr{Token: name.Token, Elements: values})
+	}
+}
+
+func (g *Generator) defineConstant(name string, value ast.Expression) {
+	c := &constant{value: value}
+	if !isLiteral(value) {
+		c.goVar = "c_" + name
+	}
+	if g.constants[name] == nil {
+		g.constantNames = append(g.constantNames, name)
+	}
+	g.constants[name] = c
+}
+
+// writeConstants emits the package-level vars of computed constants, in
+// declaration order.
+func (g *Generator) writeConstants() {
+	for _, name := range g.constantNames {
+		c := g.constants[name]
+		if c.goVar == "" {
+			continue
+		}
+		g.write("var " + c.goVar + " = ")
+		if list, ok := c.value.(*ast.ArrayExpr); ok && list.Token.Value != "[" {
+			// use constant DAYS => qw(Mon Tue)
+			g.write("perlrt.SvArray(")
+			g.generateListValues(c.value)
+			g.write("...)")
+		} else {
+			g.generateExpression(c.value)
+		}
+		g.write("\n\n")
+	}
+}
+
+func isLiteral(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.UndefLiteral:
+		return true
+	case *ast.StringLiteral:
+		return !v.Interpolated || !strings.ContainsAny(v.Value, "$@")
+	}
+	return false
+}
+
+// inlineBody returns the expression a sub can be replaced with at its call
+// sites: the body is a single (return) expression built from literals,
+// constants, $_[N], operators, pure builtins and subs inlined before it.
+// Otherwise nil.
+func (g *Generator) inlineBody(sub *ast.SubDecl) ast.Expression {
+	if len(sub.Params) > 0 || sub.Body == nil || len(sub.Body.Statements) != 1 {
+		return nil
+	}
+	var body ast.Expression
+	switch s := sub.Body.Statements[0].(type) {
+	case *ast.ReturnStmt:
+		body = s.Value
+	case *ast.ExprStmt:
+		body = s.Expression
+	}
+	if body == nil || !g.inlinable(body) {
+		return nil
+	}
+	return body
+}
+
+func (g *Generator) inlinable(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.UndefLiteral, *ast.StringLiteral:
+		return isLiteral(e)
+	case *ast.Identifier:
+		return g.constants[v.Value] != nil
+	case *ast.ArrayAccess:
+		return argIndex(v) >= 0
+	case *ast.InfixExpr:
+		return v.Operator != "=~" && v.Operator != "!~" && g.inlinable(v.Left) && g.inlinable(v.Right)
+	case *ast.PrefixExpr:
+		return v.Operator != "++" && v.Operator != "--" && g.inlinable(v.Right)
+	case *ast.TernaryExpr:
+		return g.inlinable(v.Condition) && g.inlinable(v.Then) && g.inlinable(v.Else)
+	case *ast.CallExpr:
+		ident, ok := v.Function.(*ast.Identifier)
+		if !ok {
+			return false
+		}
+		if g.constants[ident.Value] != nil {
+			return len(v.Args) == 0
+		}
+		if g.inlineSubs[ident.Value] == nil && (!pureBuiltins[ident.Value] || len(v.Args) != 1) {
+			return false
+		}
+		for _, a := range v.Args {
+			if !g.inlinable(a) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// argIndex returns N for $_[N] with a literal N, otherwise -1.
+func argIndex(e *ast.ArrayAccess) int {
+	sv, ok := e.Array.(*ast.SpecialVar)
+	if !ok || sv.Name != "$_" {
+		return -1
+	}
+	idx, ok := e.Index.(*ast.IntegerLiteral)
+	if !ok || idx.Value < 0 {
+		return -1
+	}
+	return int(idx.Value)
+}
+
+// generateConstant emits a use of constant name; false if it is not one.
+func (g *Generator) generateConstant(name string) bool {
+	c := g.constants[name]
+	if c == nil {
+		return false
+	}
+	if c.goVar != "" {
+		g.write(c.goVar)
+	} else {
+		g.generateExpression(c.value)
+	}
+	return true
+}
+
+// generateInlineCall emits the body of an inlinable sub in place of a call.
+// Each argument must be a simple value, so substituting it for every $_[N]
+// is the same as passing it; no @_ slice is built.
+func (g *Generator) generateInlineCall(name string, args []ast.Expression) bool {
+	body := g.inlineSubs[name]
+	if body == nil {
+		return false
+	}
+	for _, a := range args {
+		if !g.simpleArg(a) {
+			return false
+		}
+	}
+	frame := g.inlineArgs
+	g.inlineArgs = &inlineFrame{args: args, outer: frame}
+	g.generateExpression(body)
+	g.inlineArgs = frame
+	return true
+}
+
+// simpleArg reports whether a is a variable, literal or constant: reading
+// it twice is the same as reading it once.
+func (g *Generator) simpleArg(a ast.Expression) bool {
+	switch v := a.(type) {
+	case *ast.ScalarVar:
+		return true
+	case *ast.SpecialVar:
+		return v.Name == "$_"
+	case *ast.Identifier:
+		return g.constants[v.Value] != nil
+	case *ast.ArrayAccess:
+		// $_[N] of an enclosing inlined call is itself a simple argument
+		return g.inlineArgs != nil && argIndex(v) >= 0
+	}
+	return isLiteral(a)
+}
+
+// generateInlineArg emits $_[N] inside an inlined body as the caller's
+// N-th argument; false outside an inlined body.
+func (g *Generator) generateInlineArg(e *ast.ArrayAccess) bool {
+	frame := g.inlineArgs
+	n := argIndex(e)
+	if frame == nil || n < 0 {
+		return false
+	}
+	if n >= len(frame.args) {
+		g.write("perlrt.SvUndef()")
+		return true
+	}
+	// the argument belongs to the caller, which may itself be inlined
+	g.inlineArgs = frame.outer
+	g.generateExpression(frame.args[n])
+	g.inlineArgs = frame
+	return true
+}
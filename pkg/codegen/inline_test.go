@@ -0,0 +1,36 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestInlineSubs(t *testing.T) {
+	program := parser.New(lexer.New(`use constant PI => 3.14;
+use constant START => time;
+sub square { return $_[0] * $_[0] }
+sub area { PI * square($_[0]) }
+sub counter { my $n = shift; return $n + 1 }
+my $r = 2.5;
+my @l = (3);
+print area($r), square(@l), counter($r), START;`)).ParseProgram()
+	code := New().Generate(program)
+	main := code[strings.Index(code, "func main()"):]
+
+	for _, want := range []string{
+		"perlrt.SvMul(perlrt.SvFloat(3.14), perlrt.SvMul(v_r, v_r))", // area and square inlined, $_[0] is $r
+		"perl_square(perlrt.ListOf(a_l)...)",                         // @l may hold any number of values
+		"perl_counter(v_r)",                                          // not a single expression
+		"c_START",                                                    // computed once
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main does not contain %s:\n%s", want, main)
+		}
+	}
+	if !strings.Contains(code, "var c_START = ") {
+		t.Errorf("START not computed into a package var")
+	}
+}
@@ -0,0 +1,115 @@
+package codegen
+
+import (
+	"fmt"
+
+	"perlc/pkg/ast"
+)
+
+// Locals: local pushes the old value on perlrt's save stack, and the block
+// the local is in pops down to the mark it took at its start when it ends.
+// last, next and redo pop down to the mark of the loop body they leave;
+// subs, evals and do blocks restore theirs in a defer, for return and die.
+// g.marks are the marks of the current Go function, outermost first.
+
+// hasLocal reports whether stmts have a local of their own block; deep
+// also looks into nested blocks.
+func hasLocal(stmts []ast.Statement, deep bool) bool {
+	for _, s := range stmts {
+		switch v := s.(type) {
+		case *ast.VarDecl:
+			if v.Kind == "local" {
+				return true
+			}
+		case *ast.IfStmt:
+			if deep && (hasLocal(v.Then.Statements, true) || v.Else != nil && hasLocal(v.Else.Statements, true)) {
+				return true
+			}
+			for _, elsif := range v.Elsif {
+				if deep && hasLocal(elsif.Body.Statements, true) {
+					return true
+				}
+			}
+		case *ast.LabelStmt:
+			if deep && hasLocal([]ast.Statement{v.Statement}, true) {
+				return true
+			}
+		case *ast.BlockStmt:
+			if deep && hasLocal(v.Statements, true) {
+				return true
+			}
+		case *ast.WhileStmt:
+			if deep && hasLocal(v.Body.Statements, true) {
+				return true
+			}
+		case *ast.ForStmt:
+			if deep && hasLocal(v.Body.Statements, true) {
+				return true
+			}
+		case *ast.ForeachStmt:
+			if deep && hasLocal(v.Body.Statements, true) {
+				return true
+			}
+		case *ast.DoStmt:
+			if deep && hasLocal(v.Body.Statements, true) {
+				return true
+			}
+		case *ast.GivenStmt:
+			for _, c := range v.Clauses {
+				if deep && hasLocal(c.Body.Statements, true) {
+					return true
+				}
+			}
+			if deep && v.Default != nil && hasLocal(v.Default.Statements, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openLocals takes a mark at the start of a block with a local and
+// reports whether it did, for closeLocals
+func (g *Generator) openLocals(stmts []ast.Statement) bool {
+	if !hasLocal(stmts, false) {
+		return false
+	}
+	g.tempCount++
+	mark := fmt.Sprintf("_mark%d", g.tempCount)
+	g.writeln(mark + " := perlrt.SaveMark()")
+	g.marks = append(g.marks, mark)
+	return true
+}
+
+// closeLocals restores the mark of openLocals at the end of the block
+func (g *Generator) closeLocals(opened bool) {
+	if !opened {
+		return
+	}
+	g.writeln("perlrt.Restore(" + g.marks[len(g.marks)-1] + ")")
+	g.marks = g.marks[:len(g.marks)-1]
+}
+
+// enterLocals starts the marks of a Go function body: one restored by a
+// defer if the body has a local at any depth. It returns the marks of the
+// enclosing function, to put back when the body is done.
+func (g *Generator) enterLocals(stmts []ast.Statement) []string {
+	prev := g.marks
+	g.marks = nil
+	if hasLocal(stmts, true) {
+		g.tempCount++
+		mark := fmt.Sprintf("_mark%d", g.tempCount)
+		g.writeln(mark + " := perlrt.SaveMark()")
+		g.writeln("defer perlrt.Restore(" + mark + ")")
+		g.marks = []string{mark}
+	}
+	return prev
+}
+
+// leaveLocals restores the locals of the blocks a jump to the loop l
+// leaves: those its body made since it began
+func (g *Generator) leaveLocals(l loop) {
+	if l.marks < len(g.marks) {
+		g.writeln("perlrt.Restore(" + g.marks[l.marks] + ")")
+	}
+}
@@ -0,0 +1,136 @@
+package codegen
+
+import (
+	"fmt"
+
+	"perlc/pkg/ast"
+)
+
+// loop is an enclosing loop while its body is generated
+type loop struct {
+	label string // Perl label, "" if none
+	redo  string // Go label at the top of the body, "" if nothing redoes it
+	marks int    // len(g.marks) at the loop: the marks of its body follow
+}
+
+// loopJumps is what a loop body does to its own loop
+type loopJumps struct {
+	named bool // last/next LABEL: the Go loop needs the label
+	redo  bool
+}
+
+// scanJumps finds the last/next/redo in stmts that target the loop labeled
+// label: labeled ones at any depth, a bare redo outside nested loops.
+func scanJumps(stmts []ast.Statement, label string, nested bool, j *loopJumps) {
+	for _, s := range stmts {
+		switch v := s.(type) {
+		case *ast.LastStmt:
+			j.named = j.named || (label != "" && v.Label == label)
+		case *ast.NextStmt:
+			j.named = j.named || (label != "" && v.Label == label)
+		case *ast.RedoStmt:
+			j.redo = j.redo || (label != "" && v.Label == label) || (v.Label == "" && !nested)
+		case *ast.BlockStmt:
+			scanJumps(v.Statements, label, nested, j)
+		case *ast.LabelStmt:
+			scanJumps([]ast.Statement{v.Statement}, label, nested, j)
+		case *ast.IfStmt:
+			scanJumps(v.Then.Statements, label, nested, j)
+			for _, elsif := range v.Elsif {
+				scanJumps(elsif.Body.Statements, label, nested, j)
+			}
+			if v.Else != nil {
+				scanJumps(v.Else.Statements, label, nested, j)
+			}
+		case *ast.GivenStmt:
+			for _, c := range v.Clauses {
+				scanJumps(c.Body.Statements, label, nested, j)
+			}
+			if v.Default != nil {
+				scanJumps(v.Default.Statements, label, nested, j)
+			}
+		case *ast.DoStmt:
+			scanJumps(v.Body.Statements, label, nested, j)
+		case *ast.WhileStmt:
+			scanJumps(v.Body.Statements, label, true, j)
+		case *ast.ForStmt:
+			scanJumps(v.Body.Statements, label, true, j)
+		case *ast.ForeachStmt:
+			scanJumps(v.Body.Statements, label, true, j)
+		}
+	}
+}
+
+// generateLabelStmt generates LABEL: for a loop; other statements ignore
+// their label.
+func (g *Generator) generateLabelStmt(stmt *ast.LabelStmt) {
+	switch stmt.Statement.(type) {
+	case *ast.WhileStmt, *ast.ForStmt, *ast.ForeachStmt:
+		g.label = stmt.Label
+	}
+	g.generateStatement(stmt.Statement)
+	g.label = ""
+}
+
+// beginLoop starts a loop right before its "for": it takes the pending
+// label, writes it as a Go label if the body jumps to it by name (Go
+// rejects unused labels) and picks a goto target for redo.
+func (g *Generator) beginLoop(body *ast.BlockStmt) {
+	l := loop{label: g.label, marks: len(g.marks)}
+	g.label = ""
+	var j loopJumps
+	scanJumps(body.Statements, l.label, false, &j)
+	if j.named {
+		g.writeln(loopLabel(l.label) + ":")
+	}
+	if j.redo {
+		g.tempCount++
+		l.redo = fmt.Sprintf("_redo%d", g.tempCount)
+	}
+	g.loops = append(g.loops, l)
+	g.pushScope()
+}
+
+// generateLoopBody writes the body of the loop started by beginLoop and
+// ends the loop, and its scope, which also has the loop variable.
+func (g *Generator) generateLoopBody(body *ast.BlockStmt) {
+	if redo := g.loops[len(g.loops)-1].redo; redo != "" {
+		g.writeln(redo + ":")
+	}
+	g.writeln("perlrt.CheckSignals()")
+	opened := g.openLocals(body.Statements)
+	for _, s := range body.Statements {
+		g.generateStatement(s)
+	}
+	g.closeLocals(opened)
+	g.popScope()
+	g.loops = g.loops[:len(g.loops)-1]
+}
+
+// generateLoopJump generates last/next LABEL as a (labeled) break/continue
+// and redo as a goto to the top of the loop body. The locals of the body
+// are restored first.
+func (g *Generator) generateLoopJump(keyword, label string) {
+	for n := len(g.loops) - 1; n >= 0; n-- {
+		if l := g.loops[n]; label == "" || l.label == label {
+			g.leaveLocals(l)
+			if keyword == "redo" && l.redo != "" {
+				g.writeln("goto " + l.redo)
+				return
+			}
+			break
+		}
+	}
+	if keyword != "redo" {
+		if label != "" {
+			keyword += " " + loopLabel(label)
+		}
+		g.writeln(keyword)
+		return
+	}
+	g.writeln(`perlrt.Perl_die(perlrt.SvStr("Can't \"redo\" outside a loop block"))`)
+}
+
+func loopLabel(label string) string {
+	return "L_" + label
+}
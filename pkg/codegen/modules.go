@@ -0,0 +1,583 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+// Modules are compiled into the program: use and require of a module
+// perlc does not implement itself are resolved when the program is
+// compiled, along the directories of use lib, PERL5LIB, lib,
+// local/lib/perl5 and the current directory. Each file found becomes a
+// Go function running its top-level code, registered in _modules under
+// its %INC name; _load runs it once, where the use or require is. A file
+// not found or not parsed still compiles: _load dies with perl's message
+// when the program gets there.
+
+// module is a file use or require loads
+type module struct {
+	file  string // as %INC has it: My/Util.pm
+	path  string // where it was found
+	err   string // the syntax error it has
+	stmts []ast.Statement
+	subs  []*ast.SubDecl
+	ours  map[string]string // the our names of the file, see collectOurs
+}
+
+// loadModules finds the modules the statements use or require, reading
+// and parsing each once, and the modules those use in turn
+func (g *Generator) loadModules(stmts []ast.Statement) {
+	if g.modules == nil {
+		g.modules = make(map[string]*module)
+	}
+	for _, stmt := range stmts {
+		walkStatements(stmt, func(stmt ast.Statement) {
+			switch s := stmt.(type) {
+			case *ast.UseDecl:
+				if s.Module == "lib" {
+					var dirs []string
+					for _, arg := range s.Args {
+						dirs = append(dirs, constStrings(arg)...)
+					}
+					g.libDirs = append(dirs, g.libDirs...)
+				}
+				g.loadModule(moduleFile(s.Module))
+				if s.Module == "parent" || s.Module == "base" {
+					parents, norequire := parentNames(s.Args)
+					for _, parent := range parents {
+						if !norequire {
+							g.loadModule(moduleFile(parent))
+						}
+					}
+				}
+			case *ast.RequireDecl:
+				if s.Expr == nil {
+					g.loadModule(moduleFile(s.Module))
+				} else if lit, ok := s.Expr.(*ast.StringLiteral); ok && !strings.ContainsAny(lit.Value, "$@") {
+					g.loadModule(lit.Value)
+				}
+			}
+		})
+	}
+}
+
+// moduleFile is the file of use Module, empty for the modules perlc
+// implements and for use VERSION
+func moduleFile(name string) string {
+	if name == "" || context.BuiltinModules[name] || isVersion(name) {
+		return ""
+	}
+	return context.ModuleFile(name)
+}
+
+// isVersion tells use 5.010 and use v5.36 from a module
+func isVersion(name string) bool {
+	return name[0] >= '0' && name[0] <= '9' || name[0] == 'v' && len(name) > 1 && name[1] >= '0' && name[1] <= '9'
+}
+
+func (g *Generator) loadModule(file string) {
+	if file == "" {
+		return
+	}
+	if _, seen := g.modules[file]; seen {
+		return
+	}
+	path, found := g.findModule(file)
+	if !found {
+		// _load reports it along @INC of the running program
+		g.modules[file] = nil
+		return
+	}
+	m := &module{file: file, path: path}
+	g.modules[file] = m
+	g.moduleOrder = append(g.moduleOrder, m)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		m.err = fmt.Sprintf("Can't locate %s:   %v\n", file, err)
+		return
+	}
+	p := parser.New(lexer.NewFile(string(src), path))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		m.err = fmt.Sprintf("syntax error at %s %s\n", path, errs[0])
+		return
+	}
+	m.stmts = fileLexicals(program.Statements)
+	for _, stmt := range m.stmts {
+		if _, ok := stmt.(*ast.SubDecl); !ok {
+			if use, ok := stmt.(*ast.UseDecl); ok && use.Module != "warnings" {
+				g.noteUse(use)
+			}
+		}
+	}
+	m.subs = collectSubs(m.stmts, "main", g.subPkgs)
+	g.loadModules(m.stmts)
+}
+
+// findModule looks for file as require does, along the directories the
+// program will have in @INC; a path from / or ./ is taken as it is
+func (g *Generator) findModule(file string) (string, bool) {
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "./") || strings.HasPrefix(file, "../") {
+		info, err := os.Stat(file)
+		return file, err == nil && !info.IsDir()
+	}
+	dirs := append([]string{}, g.libDirs...)
+	if lib := os.Getenv("PERL5LIB"); lib != "" {
+		dirs = append(dirs, filepath.SplitList(lib)...)
+	}
+	for _, dir := range append(dirs, "lib", "local/lib/perl5", ".") {
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// fileLexicals turns the my variables of the file level of a module into
+// package variables of its package: its subs are Go functions and see
+// only those
+func fileLexicals(stmts []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, len(stmts))
+	for n, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.VarDecl:
+			if s.Kind == "my" {
+				our := *s
+				our.Kind = "our"
+				stmt = &our
+			}
+		case *ast.PackageDecl:
+			if s.Block != nil {
+				block := *s.Block
+				block.Statements = fileLexicals(s.Block.Statements)
+				pkg := *s
+				pkg.Block = &block
+				stmt = &pkg
+			}
+		}
+		out[n] = stmt
+	}
+	return out
+}
+
+// collectSubs returns the subs of the file level, bare blocks included,
+// under their full names: sub f after package Foo; is Foo::f, a sub of
+// main keeps its bare name.
+// pkgs gets the package each is declared in, where its body runs.
+func collectSubs(stmts []ast.Statement, pkg string, pkgs map[string]string) []*ast.SubDecl {
+	var subs []*ast.SubDecl
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.PackageDecl:
+			if s.Block == nil {
+				pkg = s.Name
+			} else {
+				subs = append(subs, collectSubs(s.Block.Statements, s.Name, pkgs)...)
+			}
+		case *ast.BlockStmt:
+			// { my $id = 0; sub next_id { ++$id } }
+			subs = append(subs, collectSubs(s.Statements, pkg, pkgs)...)
+		case *ast.SubDecl:
+			sub := *s
+			if !strings.Contains(sub.Name, "::") && pkg != "main" {
+				sub.Name = pkg + "::" + sub.Name
+			}
+			sub.Name = strings.TrimPrefix(sub.Name, "main::")
+			pkgs[sub.Name] = pkg
+			subs = append(subs, &sub)
+		}
+	}
+	return subs
+}
+
+// walkStatements calls visit for stmt and every statement inside it, in
+// blocks, sub bodies and eval blocks alike
+func walkStatements(stmt ast.Statement, visit func(ast.Statement)) {
+	walkNodes(stmt, func(n ast.Node) bool {
+		if s, ok := n.(ast.Statement); ok {
+			visit(s)
+		}
+		return true
+	})
+}
+
+// walkNodes calls visit for node and every node inside it, statements and
+// expressions alike; what is inside a node is skipped when visit returns
+// false for it
+func walkNodes(node ast.Node, visit func(ast.Node) bool) {
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Pointer:
+			if v.IsNil() {
+				return
+			}
+			if n, ok := v.Interface().(ast.Node); ok && v.Kind() == reflect.Pointer && !visit(n) {
+				return
+			}
+			walk(v.Elem())
+		case reflect.Struct:
+			for n := 0; n < v.NumField(); n++ {
+				if v.Type().Field(n).IsExported() {
+					walk(v.Field(n))
+				}
+			}
+		case reflect.Slice:
+			for n := 0; n < v.Len(); n++ {
+				walk(v.Index(n))
+			}
+		}
+	}
+	walk(reflect.ValueOf(node))
+}
+
+// subName is the sub a call of name in the current package means: a sub
+// of the package, one imported into it, or a sub of main, kept under its
+// bare name
+func (g *Generator) subName(name string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "::"), "main::")
+	if strings.Contains(name, "::") {
+		return name
+	}
+	if g.pkg != "main" && g.subNames[g.pkg+"::"+name] {
+		return g.pkg + "::" + name
+	}
+	if target, ok := g.imports[g.pkg+"::"+name]; ok {
+		return target
+	}
+	return name
+}
+
+// importModule resolves use Module LIST in package pkg as Exporter would,
+// from the literal @EXPORT, @EXPORT_OK and %EXPORT_TAGS of the module:
+// subs are called under the module's names, variables stand for its
+// package variables. A module with an import sub of its own gets it
+// called instead. The import errors are kept for the use to die with.
+func (g *Generator) importModule(use *ast.UseDecl, pkg string) {
+	m := g.modules[moduleFile(use.Module)]
+	if m == nil || m.err != "" || g.subNames[use.Module+"::import"] || noImport(use.Args) {
+		return
+	}
+	exports, tags := moduleExports(m.stmts, use.Module)
+	allowed := map[string]bool{}
+	for _, name := range append(exports[""], exports["OK"]...) {
+		allowed[strings.TrimPrefix(name, "&")] = true
+	}
+	var names []string
+	for _, arg := range use.Args {
+		names = append(names, constStrings(arg)...)
+	}
+	if len(use.Args) == 0 {
+		names = exports[""]
+	}
+	var bad []string
+	for _, name := range names {
+		if tag, ok := strings.CutPrefix(name, ":"); ok {
+			members, found := tags[tag]
+			if tag == "DEFAULT" {
+				members, found = exports[""], true
+			}
+			if !found {
+				bad = append(bad, fmt.Sprintf("%q is not defined in %%%s::EXPORT_TAGS", tag, use.Module))
+				continue
+			}
+			for _, member := range members {
+				g.exportName(use.Module, strings.TrimPrefix(member, "&"), pkg)
+			}
+			continue
+		}
+		name = strings.TrimPrefix(name, "&")
+		if !allowed[name] {
+			bad = append(bad, fmt.Sprintf("%q is not exported by the %s module", name, use.Module))
+			continue
+		}
+		g.exportName(use.Module, name, pkg)
+	}
+	if len(bad) > 0 {
+		if g.importErrors == nil {
+			g.importErrors = make(map[*ast.UseDecl]string)
+		}
+		g.importErrors[use] = strings.Join(bad, "\n") + "\nCan't continue after import errors"
+	}
+}
+
+// exportName makes name of the module seen in package pkg
+func (g *Generator) exportName(module, name, pkg string) {
+	prefixes := map[byte]string{'$': "v_", '@': "a_", '%': "h_"}
+	if name == "" {
+		return
+	}
+	if prefix := prefixes[name[0]]; prefix != "" {
+		if g.ours == nil {
+			g.ours = make(map[string]string)
+		}
+		g.ours[prefix+name[1:]] = module + "::" + name[1:]
+		g.global(prefix, module+"::"+name[1:])
+		return
+	}
+	if g.imports == nil {
+		g.imports = make(map[string]string)
+	}
+	g.imports[pkg+"::"+name] = module + "::" + name
+}
+
+// noImport tells use Module () from use Module
+func noImport(args []ast.Expression) bool {
+	if len(args) != 1 {
+		return false
+	}
+	list, ok := args[0].(*ast.ArrayExpr)
+	return ok && len(list.Elements) == 0
+}
+
+// moduleExports reads the literal lists the module assigns to @EXPORT
+// (under "") and @EXPORT_OK (under "OK"), and the tags of %EXPORT_TAGS
+func moduleExports(stmts []ast.Statement, name string) (map[string][]string, map[string][]string) {
+	exports := map[string][]string{}
+	tags := map[string][]string{}
+	assign := func(target, value ast.Expression) {
+		switch v := target.(type) {
+		case *ast.ArrayVar:
+			switch strings.TrimPrefix(v.Name, name+"::") {
+			case "EXPORT":
+				exports[""] = constStrings(value)
+			case "EXPORT_OK":
+				exports["OK"] = constStrings(value)
+			}
+		case *ast.HashVar:
+			list, ok := value.(*ast.ArrayExpr)
+			if !ok || strings.TrimPrefix(v.Name, name+"::") != "EXPORT_TAGS" {
+				return
+			}
+			for n := 0; n+1 < len(list.Elements); n += 2 {
+				if key := constStrings(list.Elements[n]); len(key) == 1 {
+					tags[key[0]] = constStrings(list.Elements[n+1])
+				}
+			}
+		}
+	}
+	pkg := "main"
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.PackageDecl:
+			if s.Block == nil {
+				pkg = s.Name
+			}
+		case *ast.VarDecl:
+			if pkg == name && len(s.Names) == 1 && s.Value != nil {
+				assign(s.Names[0], s.Value)
+			}
+		case *ast.ExprStmt:
+			if a, ok := s.Expression.(*ast.AssignExpr); ok && a.Operator == "=" && pkg == name {
+				assign(a.Left, a.Right)
+			}
+		}
+	}
+	return exports, tags
+}
+
+// generateModules emits the subs of the modules and their top-level code
+// as _module_ functions
+func (g *Generator) generateModules() {
+	inline := g.inlineSubs
+	g.inlineSubs = map[string]ast.Expression{}
+	defer func() { g.inlineSubs = inline }()
+	for _, m := range g.moduleOrder {
+		if m.err != "" {
+			continue
+		}
+		g.ours = m.ours
+		for _, sub := range m.subs {
+			g.generateSubDecl(sub)
+			g.writeln("")
+		}
+		g.resetScopes()
+		g.natives = g.scalarKinds(m.stmts)
+		g.pkg = "main"
+		g.writeln("func " + moduleFunc(m.file) + "() *perlrt.SV {")
+		g.indent++
+		g.generateBodyWithValue(m.stmts)
+		g.indent--
+		g.writeln("}")
+		g.writeln("")
+	}
+	g.pkg = "main"
+}
+
+// registerModules fills _modules in init
+func (g *Generator) registerModules() {
+	for _, m := range g.moduleOrder {
+		if m.err != "" {
+			g.writeln(fmt.Sprintf("_modules[%q] = _module{path: %q, err: %q}", m.file, m.path, m.err))
+			continue
+		}
+		g.writeln(fmt.Sprintf("_modules[%q] = _module{path: %q, body: %s}", m.file, m.path, moduleFunc(m.file)))
+	}
+}
+
+// moduleFunc is the Go function of the top-level code of a module file
+func moduleFunc(file string) string {
+	return "_module_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, file)
+}
+
+// generateUse loads the module of use Module LIST and imports from it: an
+// import sub of the module is called with the list, Exporter imports are
+// already resolved (importModule) and only their errors are left to die
+// with
+func (g *Generator) generateUse(use *ast.UseDecl) {
+	file := moduleFile(use.Module)
+	if file == "" {
+		g.generateRequire(use.Module)
+		switch use.Module {
+		case "parent", "base":
+			g.generateUseParent(use)
+		case "mro":
+			for _, arg := range use.Args {
+				for _, mro := range constStrings(arg) {
+					g.writeln(fmt.Sprintf("perlrt.Mro[%q] = %q", g.pkg, mro))
+				}
+			}
+		}
+		return
+	}
+	where := ""
+	if w := g.where(); w != "" {
+		where = " at " + w
+	}
+	g.loads = true
+	g.writeln(fmt.Sprintf("_load(%q, %q, %q, true)", file, use.Module, where))
+	if msg, ok := g.importErrors[use]; ok {
+		g.writeln(fmt.Sprintf("perlrt.Perl_die(perlrt.SvStr(%q))", msg+where+".\nBEGIN failed--compilation aborted"+where+".\n"))
+		return
+	}
+	if g.subNames[use.Module+"::import"] && !noImport(use.Args) {
+		g.write(strings.Repeat("\t", g.indent) + fmt.Sprintf("perlrt.Perl_find_and_call(%q, \"import\", append([]*perlrt.SV{perlrt.SvStr(%q)}, ", use.Module, use.Module))
+		g.generateListValues(&ast.ArrayExpr{Elements: use.Args})
+		g.write("...))\n")
+	}
+}
+
+// parentNames are the classes of use parent LIST or use base LIST, and
+// whether -norequire is among them
+func parentNames(args []ast.Expression) ([]string, bool) {
+	var parents []string
+	norequire := false
+	for _, arg := range args {
+		if p, ok := arg.(*ast.PrefixExpr); ok && p.Operator == "-" {
+			if id, ok := p.Right.(*ast.Identifier); ok && id.Value == "norequire" {
+				norequire = true
+				continue
+			}
+		}
+		parents = append(parents, constStrings(arg)...)
+	}
+	return parents, norequire
+}
+
+// generateUseParent adds the parents of use parent or use base to @ISA
+// of the current package, loading their modules first. base leaves out
+// a class the package already inherits from, and loads nothing for a
+// package the program has subs of.
+func (g *Generator) generateUseParent(use *ast.UseDecl) {
+	parents, norequire := parentNames(use.Args)
+	norequire = norequire && use.Module == "parent"
+	where := ""
+	if w := g.where(); w != "" {
+		where = " at " + w
+	}
+	isa := g.global("a_", g.pkg+"::ISA")
+	for _, parent := range parents {
+		file := moduleFile(parent)
+		switch {
+		case norequire || file == "":
+		case use.Module == "base" && g.modules[file] == nil && g.packageDefined(parent):
+		case use.Module == "base" && g.modules[file] == nil:
+			g.loads = true
+			g.writeln(fmt.Sprintf("perlrt.Perl_die(perlrt.SvStr(%q + _incDirs() + %q))", fmt.Sprintf("Base class package %q is empty.\n"+
+				"    (Perhaps you need to 'use' the module which defines that package first,\n"+
+				"    or make that module available in @INC (@INC contains: ", parent),
+				").\n"+where+".\nBEGIN failed--compilation aborted"+where+".\n"))
+		default:
+			g.loads = true
+			g.writeln(fmt.Sprintf("_load(%q, %q, %q, true)", file, parent, where))
+		}
+		add := fmt.Sprintf("%s.AV = append(%s.AV, perlrt.SvStr(%q))", isa, isa, parent)
+		if use.Module == "base" {
+			add = fmt.Sprintf("if !perlrt.Perl_isa_check(%q, %q).IsTrue() { %s }", g.pkg, parent, add)
+		}
+		g.writeln(add)
+	}
+}
+
+// packageDefined tells whether the program or its modules have a sub of
+// package pkg
+func (g *Generator) packageDefined(pkg string) bool {
+	for name := range g.subNames {
+		if strings.HasPrefix(name, pkg+"::") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeModulesRuntime emits _modules and _load, which runs a module file
+// the first time use or require asks for it
+func (g *Generator) writeModulesRuntime() {
+	g.writeln(`// _module is a file compiled into the program for use and require: where
+// it was found and its top-level code, or the syntax error it has
+type _module struct {
+	path string
+	body func() *perlrt.SV
+	err  string
+}
+
+var _modules = map[string]_module{}
+
+// _load runs a module file once, as require does: %INC has it from the
+// start, and it dies when the file was not found, does not compile or
+// does not return true. A use adds that compilation was aborted.
+func _load(file, module, where string, use bool) {
+	if _, ok := perlrt.H_INC.HV[file]; ok { return }
+	m, ok := _modules[file]
+	msg := ""
+	switch {
+	case !ok:
+		hint := ""
+		if module != "" { hint = " (you may need to install the " + module + " module)" }
+		msg = "Can't locate " + file + " in @INC" + hint + " (@INC contains: " + _incDirs() + ")" + where + ".\n"
+	case m.err != "":
+		msg = m.err + "Compilation failed in require" + where + ".\n"
+	default:
+		perlrt.H_INC.HV[file] = perlrt.SvStr(m.path)
+		if !m.body().IsTrue() {
+			delete(perlrt.H_INC.HV, file)
+			msg = file + " did not return a true value" + where + ".\n"
+		}
+	}
+	if msg == "" { return }
+	if use { msg += "BEGIN failed--compilation aborted" + where + ".\n" }
+	perlrt.Perl_die(perlrt.SvStr(msg))
+}
+
+// _incDirs lists @INC as "Can't locate" shows it
+func _incDirs() string {
+	var dirs []string
+	for _, dir := range perlrt.A_INC.AV { dirs = append(dirs, dir.AsString()) }
+	return strings.Join(dirs, " ")
+}`)
+	g.writeln("")
+}
@@ -0,0 +1,689 @@
+package codegen
+
+import (
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+	"perlc/pkg/lexer"
+)
+
+// nativeKind is the Go type a lexical scalar is generated with. Scalars
+// proven to only ever hold integers (or only strings) skip the *SV box:
+// loop counters and accumulators become int64/string and are boxed only
+// where a value leaves them (svInt(n_i), svStr(s_s)).
+type nativeKind int
+
+const (
+	boxed nativeKind = iota
+	nativeInt
+	nativeStr
+)
+
+// nativeWrite is one store into a candidate variable: my $x = V,
+// $x = V, $x += V, ... or $x++ / $x-- (op "++", no value).
+type nativeWrite struct {
+	op    string
+	value ast.Expression
+}
+
+// nativeOps are the statement-level stores a native variable supports
+var nativeOps = map[string]bool{"=": true, "+=": true, "-=": true, "*=": true, ".=": true}
+
+// mutatingBuiltins change a scalar argument in place, so it needs the box
+var mutatingBuiltins = map[string]bool{
+	"chomp": true, "chop": true, "undef": true, "read": true, "sysread": true,
+	"recv": true, "open": true, "opendir": true, "sysopen": true, "pipe": true,
+	"socket": true, "accept": true, "pos": true,
+}
+
+// nativeScan collects the stores of every my-scalar of a function body and
+// bans the ones used in ways the native forms cannot express: references,
+// aliasing builtins, matches (pos), list assignment, local/our, uses outside
+// the lexical scope of the my, arguments of subs that store into their @_.
+// Anything it does not understand (string eval, s///e, unknown nodes)
+// fails the whole function.
+type nativeScan struct {
+	scopes    []map[string]bool
+	declared  map[string]bool
+	writes    map[string][]nativeWrite
+	banned    map[string]bool
+	failed    bool
+	argWriter func(name string) bool // subs that store into their @_
+	argStores bool                   // the body stores into $_[N]
+}
+
+func newNativeScan(argWriter func(name string) bool) *nativeScan {
+	return &nativeScan{
+		declared:  make(map[string]bool),
+		writes:    make(map[string][]nativeWrite),
+		banned:    make(map[string]bool),
+		argWriter: argWriter,
+	}
+}
+
+// inferNatives returns the scalars of a function body that can be native;
+// argWriter (or nil) tells the subs whose arguments are written back
+func inferNatives(stmts []ast.Statement, argWriter func(name string) bool) map[string]nativeKind {
+	s := newNativeScan(argWriter)
+	s.block(stmts)
+	kinds := make(map[string]nativeKind)
+	if s.failed {
+		return kinds
+	}
+
+	// Optimistic fixpoint: assume every candidate is an int, drop the ones
+	// with a store that is not an int expression until nothing changes.
+	// The rest get the same treatment as strings.
+	for _, kind := range []nativeKind{nativeInt, nativeStr} {
+		try := make(map[string]nativeKind)
+		for name := range s.declared {
+			if !s.banned[name] && kinds[name] == boxed {
+				try[name] = kind
+			}
+		}
+		for changed := true; changed; {
+			changed = false
+			for name := range try {
+				if !s.storesFit(name, kind, try) {
+					delete(try, name)
+					changed = true
+				}
+			}
+		}
+		for name, k := range try {
+			kinds[name] = k
+		}
+	}
+	return kinds
+}
+
+// writesArgs reports whether a sub body stores into its @_: $_[0] = ...,
+// $_[0] =~ tr/a-z/A-Z/, chomp $_[0]. Its callers take the values back
+// into the variables they passed, as @_ aliases them.
+func writesArgs(stmts []ast.Statement) bool {
+	s := newNativeScan(nil)
+	s.block(stmts)
+	return s.argStores
+}
+
+func (s *nativeScan) storesFit(name string, kind nativeKind, kinds map[string]nativeKind) bool {
+	for _, w := range s.writes[name] {
+		switch {
+		case kind == nativeInt && w.op == "++":
+		case kind == nativeInt && w.op != ".=":
+			if !isIntExpr(w.value, kinds) {
+				return false
+			}
+		case kind == nativeStr && w.op == ".=":
+		case kind == nativeStr && w.op == "=":
+			if !isStrExpr(w.value, kinds) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (s *nativeScan) visible(name string) bool {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if s.scopes[i][name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *nativeScan) use(name string) {
+	if !s.visible(name) {
+		s.banned[name] = true
+	}
+}
+
+func (s *nativeScan) write(name, op string, value ast.Expression) {
+	s.use(name)
+	s.writes[name] = append(s.writes[name], nativeWrite{op, value})
+}
+
+func (s *nativeScan) declare(name string) {
+	s.scopes[len(s.scopes)-1][name] = true
+	s.declared[name] = true
+}
+
+func (s *nativeScan) block(stmts []ast.Statement) {
+	s.scopes = append(s.scopes, make(map[string]bool))
+	for _, st := range stmts {
+		s.stmt(st)
+	}
+	s.scopes = s.scopes[:len(s.scopes)-1]
+}
+
+func (s *nativeScan) body(b *ast.BlockStmt) {
+	if b != nil {
+		s.block(b.Statements)
+	}
+}
+
+func (s *nativeScan) stmt(st ast.Statement) {
+	switch v := st.(type) {
+	case *ast.ExprStmt:
+		s.update(v.Expression)
+	case *ast.VarDecl:
+		s.expr(v.Value, false)
+		if sv, ok := singleScalar(v); ok && v.Value != nil {
+			s.declare(sv.Name)
+			s.write(sv.Name, "=", v.Value)
+			return
+		}
+		for _, n := range v.Names {
+			if sv, ok := n.(*ast.ScalarVar); ok {
+				if v.Kind == "my" {
+					s.declare(sv.Name)
+				}
+				s.banned[sv.Name] = true
+			}
+		}
+	case *ast.IfStmt:
+		s.expr(v.Condition, false)
+		s.body(v.Then)
+		for _, e := range v.Elsif {
+			s.expr(e.Condition, false)
+			s.body(e.Body)
+		}
+		s.body(v.Else)
+	case *ast.WhileStmt:
+		s.expr(v.Condition, false)
+		s.body(v.Body)
+	case *ast.ForStmt:
+		s.scopes = append(s.scopes, make(map[string]bool))
+		if v.Init != nil {
+			s.stmt(v.Init)
+		}
+		s.expr(v.Condition, false)
+		if v.Post != nil {
+			s.update(v.Post)
+		}
+		s.body(v.Body)
+		s.scopes = s.scopes[:len(s.scopes)-1]
+	case *ast.ForeachStmt:
+		s.expr(v.List, false)
+		s.scopes = append(s.scopes, make(map[string]bool))
+		if sv, ok := v.Variable.(*ast.ScalarVar); ok {
+			s.declare(sv.Name)
+			s.banned[sv.Name] = true
+		}
+		s.body(v.Body)
+		s.scopes = s.scopes[:len(s.scopes)-1]
+	case *ast.GivenStmt:
+		s.expr(v.Topic, false)
+		for _, c := range v.Clauses {
+			s.expr(c.Condition, false)
+			s.body(c.Body)
+		}
+		s.body(v.Default)
+	case *ast.DoStmt:
+		s.body(v.Body)
+		s.expr(v.Condition, false)
+	case *ast.BlockStmt:
+		s.body(v)
+	case *ast.ReturnStmt:
+		s.expr(v.Value, false)
+	case *ast.LabelStmt:
+		s.stmt(v.Statement)
+	case *ast.UseDecl:
+		// use overload '""' => sub { ... }
+		s.exprs(v.Args, false)
+	case *ast.PackageDecl:
+		s.body(v.Block)
+	case *ast.RequireDecl:
+		s.expr(v.Expr, false)
+	case *ast.LastStmt, *ast.NextStmt, *ast.RedoStmt, *ast.SubDecl,
+		*ast.NoDecl:
+	default:
+		s.failed = true
+	}
+}
+
+// update scans a statement-level expression, where stores into a native
+// variable are plain Go assignments
+func (s *nativeScan) update(e ast.Expression) {
+	if a, ok := e.(*ast.AssignExpr); ok && nativeOps[a.Operator] {
+		if sv, ok := a.Left.(*ast.ScalarVar); ok {
+			s.expr(a.Right, false)
+			s.write(sv.Name, a.Operator, a.Right)
+			return
+		}
+	}
+	s.expr(e, false)
+}
+
+// expr scans an expression; under lvalue every scalar in it is banned
+func (s *nativeScan) expr(e ast.Expression, lvalue bool) {
+	switch v := e.(type) {
+	case nil:
+	case *ast.ScalarVar:
+		if lvalue {
+			s.banned[v.Name] = true
+		}
+		s.use(v.Name)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.UndefLiteral, *ast.RegexLiteral,
+		*ast.QrExpr, *ast.ArrayVar, *ast.HashVar, *ast.SpecialVar, *ast.Identifier,
+		*ast.CodeVar, *ast.GlobVar, *ast.ArrayLengthVar, *ast.ReadLineExpr:
+	case *ast.StringLiteral:
+		if v.Interpolated {
+			for _, seg := range interpolate.Parse(v.Value) {
+				s.expr(seg.Expr, lvalue)
+			}
+		}
+	case *ast.CommandExpr:
+		s.expr(v.Command, false)
+	case *ast.PrefixExpr:
+		if sv, ok := v.Right.(*ast.ScalarVar); ok && (v.Operator == "++" || v.Operator == "--") {
+			s.write(sv.Name, "++", nil)
+			return
+		}
+		s.expr(v.Right, lvalue || v.Operator == "++" || v.Operator == "--")
+	case *ast.PostfixExpr:
+		if sv, ok := v.Left.(*ast.ScalarVar); ok && (v.Operator == "++" || v.Operator == "--") {
+			s.write(sv.Name, "++", nil)
+			return
+		}
+		s.expr(v.Left, lvalue || v.Operator == "++" || v.Operator == "--")
+	case *ast.InfixExpr:
+		s.expr(v.Left, lvalue)
+		s.expr(v.Right, lvalue)
+	case *ast.TernaryExpr:
+		s.expr(v.Condition, false)
+		s.expr(v.Then, lvalue)
+		s.expr(v.Else, lvalue)
+	case *ast.AssignExpr:
+		s.expr(v.Left, true)
+		s.expr(v.Right, false)
+	case *ast.ArrayAccess:
+		if sv, ok := v.Array.(*ast.SpecialVar); ok && sv.Name == "$_" && lvalue {
+			s.argStores = true
+		}
+		s.base(v.Array)
+		s.expr(v.Index, false)
+	case *ast.HashAccess:
+		s.base(v.Hash)
+		s.expr(v.Key, false)
+	case *ast.ArraySlice:
+		s.base(v.Array)
+		s.exprs(v.Indices, false)
+	case *ast.HashSlice:
+		s.base(v.Hash)
+		s.exprs(v.Keys, false)
+	case *ast.ArrowAccess:
+		s.expr(v.Left, true)
+		s.expr(v.Right, false)
+	case *ast.CallExpr:
+		name := ""
+		if ident, ok := v.Function.(*ast.Identifier); ok {
+			name = ident.Value
+		} else {
+			s.expr(v.Function, false)
+		}
+		mutates := mutatingBuiltins[name] || (name == "substr" && len(v.Args) > 3) ||
+			(s.argWriter != nil && s.argWriter(name))
+		s.exprs(v.Args, lvalue || mutates)
+	case *ast.MethodCall:
+		s.expr(v.Object, false)
+		s.expr(v.Dynamic, false)
+		s.exprs(v.Args, false)
+	case *ast.ArrayExpr:
+		s.exprs(v.Elements, lvalue)
+	case *ast.HashExpr:
+		for _, p := range v.Pairs {
+			s.expr(p.Key, false)
+			s.expr(p.Value, lvalue)
+		}
+	case *ast.RangeExpr:
+		s.expr(v.Start, false)
+		s.expr(v.End, false)
+	case *ast.RefExpr:
+		s.expr(v.Value, true)
+	case *ast.DerefExpr:
+		s.expr(v.Value, true)
+	case *ast.AnonSubExpr:
+		// Signature parameters are boxed and shadow outer scalars
+		s.scopes = append(s.scopes, make(map[string]bool))
+		for _, p := range v.Params {
+			s.expr(p.Default, false)
+			if p.Sigil == "$" {
+				s.declare(p.Name)
+				s.banned[p.Name] = true
+			}
+		}
+		s.body(v.Body)
+		s.scopes = s.scopes[:len(s.scopes)-1]
+	case *ast.EvalBlockExpr:
+		s.body(v.Body)
+	case *ast.DoBlockExpr:
+		s.body(v.Body)
+	case *ast.MatchExpr:
+		s.expr(v.Target, true)
+		s.expr(v.PatternExpr, false)
+	case *ast.SubstExpr:
+		if strings.Contains(v.Flags, "e") {
+			s.failed = true
+		}
+		s.expr(v.Target, true)
+		for _, seg := range interpolate.Parse(lexer.SubstReplacement(v.Replacement)) {
+			s.expr(seg.Expr, false)
+		}
+	case *ast.TransExpr:
+		s.expr(v.Target, true)
+	default:
+		s.failed = true
+	}
+}
+
+func (s *nativeScan) exprs(list []ast.Expression, lvalue bool) {
+	for _, e := range list {
+		s.expr(e, lvalue)
+	}
+}
+
+// base scans the container of an element access: $x[0] is @x, not $x,
+// but a computed container may be autovivified through
+func (s *nativeScan) base(e ast.Expression) {
+	if _, ok := e.(*ast.ScalarVar); !ok {
+		s.expr(e, true)
+	}
+}
+
+func singleScalar(decl *ast.VarDecl) (*ast.ScalarVar, bool) {
+	if decl.Kind != "my" || decl.IsList || len(decl.Names) != 1 {
+		return nil, false
+	}
+	sv, ok := decl.Names[0].(*ast.ScalarVar)
+	return sv, ok
+}
+
+// forNative returns the variable of for (my $i = V; ...), which may be native
+func forNative(init ast.Statement) (*ast.ScalarVar, bool) {
+	decl, ok := init.(*ast.VarDecl)
+	if !ok || decl.Value == nil {
+		return nil, false
+	}
+	return singleScalar(decl)
+}
+
+// isIntExpr reports whether e always yields an integer
+func isIntExpr(e ast.Expression, kinds map[string]nativeKind) bool {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.ScalarVar:
+		return kinds[v.Name] == nativeInt
+	case *ast.InfixExpr:
+		switch v.Operator {
+		case "+", "-", "*":
+			return isIntExpr(v.Left, kinds) && isIntExpr(v.Right, kinds)
+		}
+	case *ast.PrefixExpr:
+		if v.Operator == "-" {
+			return isIntExpr(v.Right, kinds)
+		}
+		sv, ok := v.Right.(*ast.ScalarVar)
+		return ok && kinds[sv.Name] == nativeInt
+	case *ast.PostfixExpr:
+		sv, ok := v.Left.(*ast.ScalarVar)
+		return ok && kinds[sv.Name] == nativeInt
+	}
+	return false
+}
+
+// isStrExpr reports whether e always yields a string
+func isStrExpr(e ast.Expression, kinds map[string]nativeKind) bool {
+	switch v := e.(type) {
+	case *ast.StringLiteral:
+		return true
+	case *ast.ScalarVar:
+		return kinds[v.Name] == nativeStr
+	case *ast.InfixExpr:
+		if v.Operator == "x" {
+			_, list := v.Left.(*ast.ArrayExpr)
+			return !list
+		}
+		return v.Operator == "."
+	case *ast.CallExpr:
+		if ident, ok := v.Function.(*ast.Identifier); ok {
+			switch ident.Value {
+			case "sprintf", "join", "uc", "lc", "ucfirst", "lcfirst", "chr":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usesNative reports whether e reads a native variable of this function
+func (g *Generator) usesNative(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.ScalarVar:
+		return g.natives[v.Name] != boxed
+	case *ast.InfixExpr:
+		return g.usesNative(v.Left) || g.usesNative(v.Right)
+	case *ast.PrefixExpr:
+		return g.usesNative(v.Right)
+	case *ast.PostfixExpr:
+		return g.usesNative(v.Left)
+	}
+	return false
+}
+
+func (g *Generator) nativeName(name string) string {
+	if g.natives[name] == nativeStr {
+		return "s_" + name
+	}
+	return "n_" + name
+}
+
+// scalarValue is the *SV read of $name
+func (g *Generator) scalarValue(name string) string {
+	switch g.natives[name] {
+	case nativeInt:
+		return "perlrt.SvInt(n_" + name + ")"
+	case nativeStr:
+		return "perlrt.SvStr(s_" + name + ")"
+	}
+	return g.scalarName(name)
+}
+
+// scalarString is the Go string read of $name
+func (g *Generator) scalarString(name string) string {
+	switch g.natives[name] {
+	case nativeInt:
+		return "strconv.FormatInt(n_" + name + ", 10)"
+	case nativeStr:
+		return "s_" + name
+	}
+	return g.scalarName(name) + ".AsString()"
+}
+
+// generateInt emits e, an int expression, as a Go int64
+func (g *Generator) generateInt(e ast.Expression) {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		g.write(strconv.FormatInt(v.Value, 10))
+	case *ast.ScalarVar:
+		g.write("n_" + v.Name)
+	case *ast.InfixExpr:
+		g.write("(")
+		g.generateInt(v.Left)
+		g.write(" " + v.Operator + " ")
+		g.generateInt(v.Right)
+		g.write(")")
+	case *ast.PrefixExpr:
+		if sv, ok := v.Right.(*ast.ScalarVar); ok && v.Operator != "-" {
+			g.write("func() int64 { n_" + sv.Name + v.Operator + "; return n_" + sv.Name + " }()")
+			return
+		}
+		g.write("(-")
+		g.generateInt(v.Right)
+		g.write(")")
+	case *ast.PostfixExpr:
+		name := "n_" + v.Left.(*ast.ScalarVar).Name
+		g.write("func() int64 { _t := " + name + "; " + name + v.Operator + "; return _t }()")
+	}
+}
+
+// generateStr emits e as a Go string
+func (g *Generator) generateStr(e ast.Expression) {
+	switch v := e.(type) {
+	case *ast.ScalarVar:
+		g.write(g.scalarString(v.Name))
+		return
+	case *ast.StringLiteral:
+		if !v.Interpolated {
+			g.write(strconv.Quote(v.Value))
+			return
+		}
+		segs := interpolate.Parse(v.Value)
+		if len(segs) == 0 {
+			g.write(`""`)
+			return
+		}
+		if len(segs) > 1 {
+			g.write("(")
+		}
+		for n, seg := range segs {
+			if n > 0 {
+				g.write(" + ")
+			}
+			switch {
+			case seg.Expr == nil:
+				g.write(strconv.Quote(seg.Text))
+			case seg.List:
+				g.write("perlrt.JoinList(")
+				g.generateExpression(seg.Expr)
+				g.write(")")
+			default:
+				g.generateStr(seg.Expr)
+			}
+		}
+		if len(segs) > 1 {
+			g.write(")")
+		}
+		return
+	case *ast.InfixExpr:
+		if v.Operator == "." {
+			g.write("(")
+			g.generateConcatOperand(v.Left)
+			g.write(" + ")
+			g.generateConcatOperand(v.Right)
+			g.write(")")
+			return
+		}
+	}
+	g.generateScalarExpression(e)
+	g.write(".AsString()")
+}
+
+// generateNativeDecl emits my $x = VALUE for a native $x
+func (g *Generator) generateNativeDecl(decl *ast.VarDecl) bool {
+	sv, ok := singleScalar(decl)
+	if !ok || g.natives[sv.Name] == boxed {
+		return false
+	}
+	name := g.nativeName(sv.Name)
+	op := " := "
+	if g.declaredHere(name) {
+		op = " = "
+	}
+	g.write(strings.Repeat("\t", g.indent) + name + op)
+	g.generateNativeInit(sv.Name, decl.Value)
+	g.write("\n")
+	if op == " := " {
+		g.declare(name)
+		g.writeln("_ = " + name)
+	}
+	return true
+}
+
+// generateNativeInit emits the initial value of a native variable; ints
+// are converted so that := gives an int64 even for a constant
+func (g *Generator) generateNativeInit(name string, value ast.Expression) {
+	if g.natives[name] == nativeInt {
+		g.write("int64(")
+		g.generateInt(value)
+		g.write(")")
+	} else {
+		g.generateStr(value)
+	}
+}
+
+func (g *Generator) generateNativeValue(name string, value ast.Expression) {
+	if g.natives[name] == nativeInt {
+		g.generateInt(value)
+	} else {
+		g.generateStr(value)
+	}
+}
+
+// generateNativeStore emits a statement-level store into a native
+// variable ($x = V, $x += V, $x .= V, $x++) as a Go statement
+func (g *Generator) generateNativeStore(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.AssignExpr:
+		sv, ok := v.Left.(*ast.ScalarVar)
+		if !ok || g.natives[sv.Name] == boxed || !nativeOps[v.Operator] {
+			return false
+		}
+		op := v.Operator
+		if op == ".=" {
+			op = "+="
+		}
+		g.write(g.nativeName(sv.Name) + " " + op + " ")
+		g.generateNativeValue(sv.Name, v.Right)
+		return true
+	case *ast.PrefixExpr:
+		if sv, ok := v.Right.(*ast.ScalarVar); ok && g.natives[sv.Name] == nativeInt {
+			g.write("n_" + sv.Name + v.Operator)
+			return true
+		}
+	case *ast.PostfixExpr:
+		if sv, ok := v.Left.(*ast.ScalarVar); ok && g.natives[sv.Name] == nativeInt {
+			g.write("n_" + sv.Name + v.Operator)
+			return true
+		}
+	}
+	return false
+}
+
+// nativeCompare maps comparisons of two ints to Go operators
+var nativeCompare = map[string]string{
+	"<": "<", "<=": "<=", ">": ">", ">=": ">=", "==": "==", "!=": "!=",
+}
+
+// generateCondition emits cond as a Go bool. Comparisons of native ints
+// are compared directly, everything else is tested with IsTrue.
+func (g *Generator) generateCondition(cond ast.Expression) {
+	if v, ok := cond.(*ast.InfixExpr); ok && g.usesNative(v) {
+		if op, ok := nativeCompare[v.Operator]; ok && isIntExpr(v.Left, g.natives) && isIntExpr(v.Right, g.natives) {
+			g.generateInt(v.Left)
+			g.write(" " + op + " ")
+			g.generateInt(v.Right)
+			return
+		}
+	}
+	g.write("(")
+	g.generateScalarExpression(cond)
+	g.write(").IsTrue()")
+}
+
+// generateNativeInfix emits int arithmetic over native variables unboxed
+func (g *Generator) generateNativeInfix(e *ast.InfixExpr) bool {
+	if !g.usesNative(e) || !isIntExpr(e, g.natives) {
+		return false
+	}
+	g.write("perlrt.SvInt(")
+	g.generateInt(e)
+	g.write(")")
+	return true
+}
@@ -0,0 +1,62 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestInferNatives(t *testing.T) {
+	program := parser.New(lexer.New(`my $sum = 0;
+my $s = "";
+for (my $i = 0; $i < 10; $i++) {
+    $sum += $i * 2;
+    $s .= "x$i";
+}
+my $j = $sum - 1;
+my $half = 1;
+$half = $half / 2;
+my $ref = 1;
+my $r = \$ref;
+my $line = "a\n";
+chomp($line);
+my $tmp = 1;
+if (1) { $tmp = 2; }
+my $outer = 1;
+{ my $outer = "shadow"; }
+my $mixed = 1;
+$mixed .= "x";`)).ParseProgram()
+	kinds := inferNatives(program.Statements, nil)
+
+	want := map[string]nativeKind{
+		"sum": nativeInt, "i": nativeInt, "j": nativeInt, "tmp": nativeInt,
+		"s":    nativeStr,
+		"half": boxed, "ref": boxed, "r": boxed, "line": boxed, "outer": boxed, "mixed": boxed,
+	}
+	for name, kind := range want {
+		if kinds[name] != kind {
+			t.Errorf("$%s: kind %d, want %d", name, kinds[name], kind)
+		}
+	}
+}
+
+func TestGenerateNativeLoop(t *testing.T) {
+	program := parser.New(lexer.New(`my $sum = 0;
+for (my $i = 0; $i < 10; $i++) { $sum += $i; }
+print "$sum\n";`)).ParseProgram()
+	code := New().Generate(program)
+	main := code[strings.Index(code, "func main()"):]
+
+	for _, want := range []string{
+		"n_sum := int64(0)",
+		"for n_i := int64(0); n_i < 10; n_i++ {",
+		"n_sum += n_i",
+		"strconv.FormatInt(n_sum, 10)",
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main does not contain %s:\n%s", want, main)
+		}
+	}
+}
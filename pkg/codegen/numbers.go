@@ -0,0 +1,30 @@
+package codegen
+
+import (
+	"strconv"
+
+	"perlc/pkg/ast"
+)
+
+// compatDividers are the runtime functions that die on a zero divisor
+// under PerlCompatNumbers, with where the operator is
+var compatDividers = map[string]string{"perlrt.SvDiv": "perlrt.NumDiv", "perlrt.SvMod": "perlrt.NumMod"}
+
+// opCall starts a call of the runtime function of an arithmetic operator:
+// "perlrt.SvDiv(", or under PerlCompatNumbers `perlrt.NumDiv("FILE line N", ` so that
+// a division by zero dies with the line perl gives
+func (g *Generator) opCall(fn string) string {
+	if at, ok := compatDividers[fn]; ok && g.PerlCompatNumbers {
+		return at + "(" + strconv.Quote(g.where()) + ", "
+	}
+	return fn + "("
+}
+
+// scalarKinds is inferNatives of a body, or no native scalars under
+// PerlCompatNumbers: int64 arithmetic wraps where perl goes on in doubles
+func (g *Generator) scalarKinds(stmts []ast.Statement) map[string]nativeKind {
+	if g.PerlCompatNumbers {
+		return map[string]nativeKind{}
+	}
+	return inferNatives(stmts, func(name string) bool { return g.argWriters[g.subName(name)] })
+}
@@ -0,0 +1,188 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"perlc/pkg/ast"
+)
+
+// Package variables are Go globals named after the package: $Foo::bar is
+// v_Foo__bar, @Foo::bar a_Foo__bar and %Foo::bar h_Foo__bar. our $bar in
+// package Foo makes $bar stand for v_Foo__bar until a my $bar hides it.
+// Subs are generated before the main program, so the our declarations of
+// the file level are collected first.
+
+// runtimeVars are the package variables perlrt holds, as the runtime
+// reads them too: $a and $b of sort, %ENV, @ARGV, @INC, %INC and %SIG
+var runtimeVars = map[string]string{
+	"v_a": "perlrt.V_a", "v_b": "perlrt.V_b", "h_ENV": "perlrt.H_ENV", "a_ARGV": "perlrt.A_ARGV",
+	"a_INC": "perlrt.A_INC", "h_INC": "perlrt.H_INC", "h_SIG": "perlrt.H_SIG",
+}
+
+// packageVar is the Go name of the variable name with the prefix v_, a_
+// or h_: a global for a qualified name or an our, the perlrt variable
+// of a special one, else a local
+func (g *Generator) packageVar(prefix, name string) string {
+	if strings.HasPrefix(name, "::") {
+		name = "main" + name
+	}
+	if strings.Contains(name, "::") {
+		return g.global(prefix, name)
+	}
+	if rt, ok := runtimeVars[prefix+name]; ok && !g.isDeclared(prefix+name) {
+		return rt
+	}
+	if qualified, ok := g.ours[prefix+name]; ok && !g.isDeclared(prefix+name) {
+		return g.global(prefix, qualified)
+	}
+	return prefix + name
+}
+
+// global notes the package variable name, to be declared after main
+func (g *Generator) global(prefix, name string) string {
+	goName := prefix + strings.ReplaceAll(name, "::", "__")
+	if g.globals == nil {
+		g.globals = make(map[string]string)
+	}
+	g.globals[goName] = prefix
+	return goName
+}
+
+// declName is the Go name a declaration assigns: our the package
+// variable, my always a new local, even where an our of the name is seen
+func (g *Generator) declName(expr ast.Expression, kind string) string {
+	if kind == "our" {
+		return g.ourName(expr)
+	}
+	switch v := expr.(type) {
+	case *ast.ScalarVar:
+		return "v_" + v.Name
+	case *ast.ArrayVar:
+		return "a_" + v.Name
+	case *ast.HashVar:
+		return "h_" + v.Name
+	}
+	return "_"
+}
+
+// ourName is the Go global a name of our (...) assigns
+func (g *Generator) ourName(expr ast.Expression) string {
+	local := g.declName(expr, "my")
+	return g.global(local[:2], g.ours[local])
+}
+
+// declareOurs makes the names of our (...) in package pkg stand for its
+// package variables
+func (g *Generator) declareOurs(decl *ast.VarDecl, pkg string) {
+	if g.ours == nil {
+		g.ours = make(map[string]string)
+	}
+	for _, v := range decl.Names {
+		local := g.declName(v, "my")
+		prefix, name := local[:2], local[2:]
+		g.ours[local] = pkg + "::" + name
+		g.global(prefix, pkg+"::"+name)
+	}
+}
+
+// declareVars makes the names of use vars LIST in package pkg stand for
+// its package variables, as our does
+func (g *Generator) declareVars(args []ast.Expression, pkg string) {
+	if g.ours == nil {
+		g.ours = make(map[string]string)
+	}
+	prefixes := map[byte]string{'$': "v_", '@': "a_", '%': "h_"}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if len(name) < 2 || prefixes[name[0]] == "" {
+				continue
+			}
+			prefix := prefixes[name[0]]
+			g.ours[prefix+name[1:]] = pkg + "::" + name[1:]
+			g.global(prefix, pkg+"::"+name[1:])
+		}
+	}
+}
+
+// collectOurs notes the our declarations, use vars and the imports of
+// use Module of the file level
+func (g *Generator) collectOurs(stmts []ast.Statement, pkg string) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.PackageDecl:
+			if s.Block == nil {
+				pkg = s.Name
+				g.packageISA(pkg)
+			} else {
+				g.collectOurs(s.Block.Statements, s.Name)
+			}
+		case *ast.VarDecl:
+			if s.Kind == "our" {
+				g.declareOurs(s, pkg)
+			}
+		case *ast.UseDecl:
+			if s.Module == "vars" {
+				g.declareVars(s.Args, pkg)
+			} else {
+				g.importModule(s, pkg)
+			}
+		}
+	}
+}
+
+// packageISA makes @ISA in package pkg stand for @PKG::ISA, as if our
+// declared it
+func (g *Generator) packageISA(pkg string) {
+	if g.ours == nil {
+		g.ours = make(map[string]string)
+	}
+	g.ours["a_ISA"] = pkg + "::ISA"
+}
+
+// writeISAArrays emits _isaArray, the @ISA of a package by its name:
+// method lookup reads the arrays the program assigns
+func (g *Generator) writeISAArrays() {
+	var names []string
+	for name := range g.globals {
+		if strings.HasSuffix(name, "__ISA") && strings.HasPrefix(name, "a_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	g.writeln("func _isaArray(pkg string) *perlrt.SV {")
+	g.indent++
+	if len(names) > 0 {
+		g.writeln("switch pkg {")
+		for _, name := range names {
+			pkg := strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(name, "a_"), "__ISA"), "__", "::")
+			g.writeln(fmt.Sprintf("case %q:", pkg))
+			g.writeln("\treturn " + name)
+		}
+		g.writeln("}")
+	}
+	g.writeln("return nil")
+	g.indent--
+	g.writeln("}")
+}
+
+// writeGlobals declares the Go globals vars, by Go name to their prefix:
+// the package variables the program uses or the lexicals subs capture
+func (g *Generator) writeGlobals(vars map[string]string) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch vars[name] {
+		case "a_":
+			g.writeln("var " + name + " = perlrt.SvArray()")
+		case "h_":
+			g.writeln("var " + name + " = perlrt.SvHash()")
+		default:
+			g.writeln("var " + name + " = perlrt.SvUndef()")
+		}
+	}
+}
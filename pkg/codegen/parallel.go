@@ -0,0 +1,50 @@
+package codegen
+
+// writeParallelRuntime emits parallel_map and parallel_foreach of
+// use perlc::parallel: the code ref runs once per element in a pool of
+// GOMAXPROCS goroutines. Results keep the order of the list, and a die in
+// a worker is raised again in the caller once the pool has stopped.
+// The runtime is not synchronized: a callback should compute from its
+// argument and lexicals, and leave output and shared variables to the caller.
+func (g *Generator) writeParallelRuntime() {
+	g.writeln(`func perl_parallel_map(args ...*perlrt.SV) *perlrt.SV {
+	return perlrt.SvArray(_parallel(args, true)...)
+}`)
+	g.writeln("")
+	g.writeln(`func perl_parallel_foreach(args ...*perlrt.SV) *perlrt.SV {
+	_parallel(args, false)
+	return perlrt.SvArray()
+}`)
+	g.writeln("")
+	g.writeln(`func _parallel(args []*perlrt.SV, collect bool) []*perlrt.SV {
+	if len(args) == 0 { return nil }
+	code, items := args[0], perlrt.Flatten(args[1:])
+	results := make([][]*perlrt.SV, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var failOnce sync.Once
+	var failure any
+	for w := 0; w < runtime.GOMAXPROCS(0) && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil { failOnce.Do(func() { failure = r }) }
+				for range jobs {}
+			}()
+			for n := range jobs {
+				r := perlrt.CallCode(code, items[n])
+				if collect { results[n] = perlrt.ListOf(r) }
+			}
+		}()
+	}
+	for n := range items { jobs <- n }
+	close(jobs)
+	wg.Wait()
+	if failure != nil { panic(failure) }
+	var out []*perlrt.SV
+	for _, r := range results { out = append(out, r...) }
+	return out
+}`)
+	g.writeln("")
+}
@@ -0,0 +1,81 @@
+package codegen
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"sort"
+	"strings"
+)
+
+// pruneRuntime drops the runtime functions a program never reaches from main,
+// init, the compiled subs and package-level vars, so the generated file (and
+// the go build after it) only carries the helpers that are used.
+// Types, methods and vars are always kept: methods may satisfy interfaces
+// (String, Error) and var initializers may have side effects.
+func pruneRuntime(src string) string {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "main.go", src, goparser.ParseComments)
+	if err != nil {
+		// go build reports the error against the full source
+		return src
+	}
+
+	funcs := map[string]*goast.FuncDecl{}
+	var roots []goast.Decl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*goast.FuncDecl)
+		if ok && fn.Recv == nil && fn.Name.Name != "main" && fn.Name.Name != "init" {
+			funcs[fn.Name.Name] = fn
+			continue
+		}
+		roots = append(roots, decl)
+	}
+
+	live := map[string]bool{}
+	var mark func(n goast.Node)
+	mark = func(n goast.Node) {
+		goast.Inspect(n, func(n goast.Node) bool {
+			id, ok := n.(*goast.Ident)
+			if !ok || live[id.Name] {
+				return true
+			}
+			if fn, found := funcs[id.Name]; found {
+				live[id.Name] = true
+				mark(fn)
+			}
+			return true
+		})
+	}
+	for _, decl := range roots {
+		mark(decl)
+	}
+
+	var dead []*goast.FuncDecl
+	for name, fn := range funcs {
+		if !live[name] {
+			dead = append(dead, fn)
+		}
+	}
+	if len(dead) == 0 {
+		return src
+	}
+	sort.Slice(dead, func(a, b int) bool { return dead[a].Pos() < dead[b].Pos() })
+
+	tf := fset.File(file.Pos())
+	var out strings.Builder
+	last := 0
+	for _, fn := range dead {
+		start := fn.Pos()
+		if fn.Doc != nil {
+			start = fn.Doc.Pos()
+		}
+		out.WriteString(src[last:tf.Offset(start)])
+		last = tf.Offset(fn.End())
+		if last < len(src) && src[last] == '\n' {
+			last++
+		}
+	}
+	out.WriteString(src[last:])
+	return out.String()
+}
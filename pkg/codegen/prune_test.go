@@ -0,0 +1,62 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestPruneRuntime(t *testing.T) {
+	src := `package main
+
+type SV struct{}
+
+func (sv *SV) String() string { return unusedByMain() }
+
+func unusedByMain() string { return "" }
+
+// used is reached from main
+func used() *SV { return helper() }
+
+func helper() *SV { return &SV{} }
+
+// dead is never called
+func dead() { deadToo() }
+
+func deadToo() {}
+
+var table = map[string]func() *SV{"h": fromVar}
+
+func fromVar() *SV { return nil }
+
+func main() { used() }
+`
+	out := pruneRuntime(src)
+	for _, name := range []string{"func used", "func helper", "func unusedByMain", "func fromVar", "func (sv *SV) String"} {
+		if !strings.Contains(out, name) {
+			t.Errorf("%s was removed:\n%s", name, out)
+		}
+	}
+	for _, name := range []string{"func dead", "func deadToo", "never called"} {
+		if strings.Contains(out, name) {
+			t.Errorf("%s was kept:\n%s", name, out)
+		}
+	}
+
+	if got := pruneRuntime("package main\nfunc main() {"); got != "package main\nfunc main() {" {
+		t.Errorf("invalid source changed: %q", got)
+	}
+}
+
+func TestGenerateOmitsUnusedHelpers(t *testing.T) {
+	program := parser.New(lexer.New(`print "hi\n";`)).ParseProgram()
+	code := New().Generate(program)
+	if strings.Contains(code, "type SV struct") {
+		t.Error("runtime inlined into the program")
+	}
+	if !strings.Contains(code, "perlrt.PerlPrint(") {
+		t.Error("print does not call the runtime")
+	}
+}
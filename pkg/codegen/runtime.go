@@ -0,0 +1,132 @@
+package codegen
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"perlc/pkg/perlpack"
+	"perlc/pkg/perlre"
+	"perlc/pkg/perlrt"
+	"perlc/pkg/perlstr"
+)
+
+// runtimePackages are the packages a generated program imports, by their
+// directory in the perlc module: perlrt and the packages it builds on.
+var runtimePackages = map[string]fs.FS{
+	"pkg/perlrt":   perlrt.Sources,
+	"pkg/perlstr":  perlstr.Sources,
+	"pkg/perlre":   perlre.Sources,
+	"pkg/perlpack": perlpack.Sources,
+}
+
+// WriteModule writes the generated files, by their path in the module
+// (main.go, or those of GeneratePackages), into dir, together with a go.mod
+// of module perlc and the runtime packages under it, so that go build in
+// dir resolves the imports of the program without the perlc sources or the
+// network.
+func WriteModule(dir string, files map[string]string) error {
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module perlc\n\ngo 1.24\n"), 0644); err != nil {
+		return err
+	}
+	for pkgDir, files := range runtimePackages {
+		if err := writePackage(filepath.Join(dir, pkgDir), files); err != nil {
+			return err
+		}
+	}
+	for name, code := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(code), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePackage copies the Go files of a runtime package into dir
+func writePackage(dir string, files fs.FS) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		data, err := fs.ReadFile(files, e.Name())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	runtimeSubsOnce sync.Once
+	runtimeSubs     map[string]bool // Go name perl_NAME -> its parameters end in ...*SV
+)
+
+// isRuntimeSub reports whether perlrt has the Perl function of the Go name
+// perl_NAME, as Perl_NAME: a builtin or a sub of a module it emulates,
+// such as POSIX or List::Util. The names are read from its sources.
+func isRuntimeSub(goName string) bool {
+	_, ok := runtimeSub(goName)
+	return ok
+}
+
+// runtimeSub reports whether perlrt has the Perl function of the Go name
+// perl_NAME and whether it is variadic; the others take their arguments
+// as they are, an array as one value.
+func runtimeSub(goName string) (variadic, ok bool) {
+	runtimeSubsOnce.Do(func() {
+		runtimeSubs = make(map[string]bool)
+		entries, _ := fs.ReadDir(perlrt.Sources, ".")
+		fset := gotoken.NewFileSet()
+		for _, e := range entries {
+			data, err := fs.ReadFile(perlrt.Sources, e.Name())
+			if err != nil {
+				continue
+			}
+			file, err := goparser.ParseFile(fset, e.Name(), data, goparser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*goast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Perl_") {
+					variadic := false
+					if params := fn.Type.Params.List; len(params) > 0 {
+						_, variadic = params[len(params)-1].Type.(*goast.Ellipsis)
+					}
+					runtimeSubs["perl_"+strings.TrimPrefix(fn.Name.Name, "Perl_")] = variadic
+				}
+			}
+		}
+	})
+	variadic, ok = runtimeSubs[goName]
+	return variadic, ok
+}
+
+// funcName is the Go function of the sub name: perl_NAME, with :: as _,
+// for the subs of the program and of the runtimes generated with it, or
+// perlrt.Perl_NAME for what perlrt has
+func (g *Generator) funcName(name string) string {
+	goName := "perl_" + strings.ReplaceAll(name, "::", "_")
+	if g.subNames[name] || !isRuntimeSub(goName) {
+		return goName
+	}
+	return "perlrt.P" + goName[1:]
+}
@@ -0,0 +1,64 @@
+package codegen
+
+import "perlc/pkg/ast"
+
+// Lexical scopes: every Perl block is generated as a Go block (if and loop
+// bodies, bare blocks, closures for do, eval and sub), so a my in a block
+// is a new Go variable that shadows the outer one of the name until the
+// block ends, as in Perl. g.scopes are the scopes being generated,
+// innermost last, each with the Go names my declared in it; package
+// variables are Go globals and always declared.
+
+// resetScopes starts the scopes of a Go function that sees no lexicals of
+// the code around it: main, a named sub or the top level of a module
+func (g *Generator) resetScopes() {
+	g.scopes = []map[string]bool{{}}
+}
+
+// pushScope opens the scope of a block
+func (g *Generator) pushScope() {
+	g.scopes = append(g.scopes, map[string]bool{})
+}
+
+// popScope closes the innermost scope: what it declared is no longer seen
+func (g *Generator) popScope() {
+	g.scopes = g.scopes[:len(g.scopes)-1]
+}
+
+// declare records the Go variable name in the innermost scope
+func (g *Generator) declare(name string) {
+	g.scopes[len(g.scopes)-1][name] = true
+}
+
+// isDeclared reports whether the Go variable name is seen here: a lexical
+// of this scope or an enclosing one, or a package variable
+func (g *Generator) isDeclared(name string) bool {
+	if g.globals[name] != "" {
+		return true
+	}
+	for n := len(g.scopes) - 1; n >= 0; n-- {
+		if g.scopes[n][name] {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredHere reports whether the innermost scope already declares the
+// Go variable name, so that my assigns it again instead of shadowing it
+// (my $x; my $x; in one block)
+func (g *Generator) declaredHere(name string) bool {
+	return g.globals[name] != "" || g.scopes[len(g.scopes)-1][name]
+}
+
+// generateBlock generates the statements of a block in a scope of their
+// own, restoring its locals at the end; the caller writes the braces
+func (g *Generator) generateBlock(stmts []ast.Statement) {
+	g.pushScope()
+	defer g.popScope()
+	opened := g.openLocals(stmts)
+	for _, s := range stmts {
+		g.generateStatement(s)
+	}
+	g.closeLocals(opened)
+}
@@ -0,0 +1,30 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestLexicalScopes(t *testing.T) {
+	program := parser.New(lexer.New(`my $x = "a";
+if ($x) { my $x = "b"; print $x; }
+my $x = "c";
+{ my @l = (1); print @l; }
+{ my @l = (2); print @l; }
+print $x;`)).ParseProgram()
+	code := New().Generate(program)
+	main := code[strings.Index(code, "func main()"):]
+
+	if n := strings.Count(main, "s_x := "); n != 2 {
+		t.Errorf("s_x declared %d times, want 2 (outer and in the if block):\n%s", n, main)
+	}
+	if !strings.Contains(main, `s_x = "c"`) {
+		t.Errorf("my $x again in the same scope is not an assignment:\n%s", main)
+	}
+	if n := strings.Count(main, "a_l := "); n != 2 {
+		t.Errorf("a_l declared %d times, want once per block:\n%s", n, main)
+	}
+}
@@ -0,0 +1,484 @@
+package codegen
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"perlc/pkg/ast"
+)
+
+// programPackage is the Go package of what the packages of a split program
+// share: the package variables, the constants and the runtimes generated
+// with the program
+const programPackage = "pl/program"
+
+// GeneratePackages generates the program as the Go files of a module, by
+// their path in it: main.go with main and the subs of package main,
+// pl/NAME/NAME.go for every other Perl package with its subs and an
+// exported wrapper for each (Counter::new is counter.New), and
+// pl/program/program.go with what they share. The packages import only
+// pl/program and perlrt, so go build compiles each once until its Perl
+// package changes, and hand-written Go can import them; a sub calls the
+// subs of other packages the way methods are called, through
+// perlrt.Methods.
+func (g *Generator) GeneratePackages(program *ast.Program) (map[string]string, error) {
+	return splitPackages(g.Generate(program), g.subNames)
+}
+
+// unit is one Go package of a split program
+type unit struct {
+	pkg      string // Perl package; "" for pl/program
+	name     string // Go package name
+	decls    strings.Builder
+	inits    []string
+	imports  map[string]bool // import paths; "_ PATH" for a blank import
+	wrappers []string
+}
+
+// splitPackages splits the single-file program src between the Go packages
+// of GeneratePackages; subs are the full names of the Perl subs in it
+func splitPackages(src string, subs map[string]bool) (map[string]string, error) {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "main.go", src, goparser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.File(file.Pos())
+
+	// the import name of each package the program imports
+	importPaths := map[string]string{}
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		importPaths[path.Base(p)] = p
+	}
+
+	// the Go function of each sub to the sub
+	subOf := map[string]string{}
+	for name := range subs {
+		subOf["perl_"+strings.ReplaceAll(name, "::", "_")] = name
+	}
+
+	// Go package names must not hide what the files refer to
+	taken := map[string]bool{"main": true, "program": true}
+	for name := range importPaths {
+		taken[name] = true
+	}
+	for _, name := range types.Universe.Names() {
+		taken[name] = true
+	}
+	shared := &unit{name: "program", imports: map[string]bool{}}
+	mainUnit := &unit{pkg: "main", name: "main", imports: map[string]bool{}}
+	units := map[string]*unit{"main": mainUnit}
+	unitOf := func(pkg string) *unit {
+		if u, ok := units[pkg]; ok {
+			return u
+		}
+		u := &unit{pkg: pkg, name: goPackageName(pkg, taken), imports: map[string]bool{}}
+		taken[u.name] = true
+		units[pkg] = u
+		return u
+	}
+
+	// the unit of each top-level name; the shared ones are exported
+	declUnit := map[string]*unit{}
+	declared := map[string]bool{}
+	var sharedNames []string
+	for _, decl := range file.Decls {
+		for _, name := range declNames(decl) {
+			declared[name] = true
+			switch sub, ok := subOf[name]; {
+			case ok:
+				declUnit[name] = unitOf(subPackage(sub))
+			case name == "main":
+				declUnit[name] = mainUnit
+			default:
+				declUnit[name] = shared
+				sharedNames = append(sharedNames, name)
+			}
+		}
+	}
+	exported := map[string]string{}
+	for _, name := range sharedNames {
+		exported[name] = exportName(name, declared)
+		declared[exported[name]] = true
+	}
+
+	// the fields and methods of its types are exported as well: the subs
+	// fill in the structs of the runtimes, such as the frames of Carp
+	fields := map[string]string{}
+	addField := func(id *goast.Ident) {
+		if !gotoken.IsExported(id.Name) && id.Name != "_" {
+			fields[id.Name] = exportName(id.Name, nil)
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *goast.FuncDecl:
+			if d.Recv != nil {
+				addField(d.Name)
+			}
+		case *goast.GenDecl:
+			for _, spec := range d.Specs {
+				ts, ok := spec.(*goast.TypeSpec)
+				if !ok {
+					continue
+				}
+				var list *goast.FieldList
+				switch t := ts.Type.(type) {
+				case *goast.StructType:
+					list = t.Fields
+				case *goast.InterfaceType:
+					list = t.Methods
+				}
+				if list == nil {
+					continue
+				}
+				for _, f := range list.List {
+					for _, id := range f.Names {
+						addField(id)
+					}
+				}
+			}
+		}
+	}
+
+	// an exported wrapper for each sub outside package main
+	wrappers := map[string]string{}
+	var goFuncs []string
+	for goName := range subOf {
+		if u := declUnit[goName]; u != nil && u != mainUnit {
+			goFuncs = append(goFuncs, goName)
+		}
+	}
+	sort.Strings(goFuncs)
+	wrapperNames := map[string]bool{}
+	for _, goName := range goFuncs {
+		u, sub := declUnit[goName], subOf[goName]
+		name := wrapperName(sub[strings.LastIndex(sub, "::")+2:])
+		if name == "" || wrapperNames[u.name+"."+name] || declared[name] {
+			continue
+		}
+		wrapperNames[u.name+"."+name] = true
+		wrappers[goName] = name
+		u.wrappers = append(u.wrappers, fmt.Sprintf("// %s is %s\nfunc %s(args ...*perlrt.SV) *perlrt.SV {\n\treturn %s(args...)\n}\n", name, sub, name, goName))
+		u.imports["perlc/pkg/perlrt"] = true
+	}
+
+	// rewrite gives the source of node as seen from the unit from: the
+	// names of pl/program are qualified, main calls the subs of the other
+	// packages through their wrappers and the rest look them up
+	rewrite := func(node goast.Node, from *unit) string {
+		start, end := tf.Offset(node.Pos()), tf.Offset(node.End())
+		if fn, ok := node.(*goast.FuncDecl); ok && fn.Doc != nil {
+			start = tf.Offset(fn.Doc.Pos())
+		}
+		type edit struct {
+			at, n int
+			text  string
+		}
+		var edits []edit
+		field := func(id *goast.Ident) {
+			if exp, ok := fields[id.Name]; ok {
+				edits = append(edits, edit{tf.Offset(id.Pos()) - start, len(id.Name), exp})
+			}
+		}
+		var visit func(n goast.Node) bool
+		visit = func(n goast.Node) bool {
+			switch n := n.(type) {
+			case *goast.FuncDecl:
+				if n.Recv != nil {
+					field(n.Name)
+				}
+			case *goast.StructType:
+				for _, f := range n.Fields.List {
+					for _, id := range f.Names {
+						field(id)
+					}
+				}
+			case *goast.InterfaceType:
+				for _, f := range n.Methods.List {
+					for _, id := range f.Names {
+						field(id)
+					}
+				}
+			case *goast.KeyValueExpr:
+				// a key that is a name is a struct field
+				if id, ok := n.Key.(*goast.Ident); ok {
+					field(id)
+					goast.Inspect(n.Value, visit)
+					return false
+				}
+			case *goast.SelectorExpr:
+				if id, ok := n.X.(*goast.Ident); ok && id.Obj == nil && importPaths[id.Name] != "" {
+					from.imports[importPaths[id.Name]] = true
+				} else {
+					field(n.Sel)
+				}
+			case *goast.Ident:
+				to := declUnit[n.Name]
+				if to == nil || n.Obj == nil || file.Scope.Lookup(n.Name) != n.Obj {
+					return true
+				}
+				text := n.Name
+				switch {
+				case to == shared && from == shared:
+					text = exported[n.Name]
+				case to == shared:
+					text = "program." + exported[n.Name]
+					from.imports["perlc/"+programPackage] = true
+				case to == from:
+				case from == mainUnit && wrappers[n.Name] != "":
+					text = to.name + "." + wrappers[n.Name]
+					from.imports["perlc/pl/"+to.name] = true
+				default:
+					text = fmt.Sprintf("perlrt.Methods[%q]", methodKey(subOf[n.Name]))
+					from.imports["perlc/pkg/perlrt"] = true
+				}
+				if text != n.Name {
+					edits = append(edits, edit{tf.Offset(n.Pos()) - start, len(n.Name), text})
+				}
+			}
+			return true
+		}
+		goast.Inspect(node, visit)
+		sort.Slice(edits, func(a, b int) bool { return edits[a].at < edits[b].at })
+		text := src[start:end]
+		for i := len(edits) - 1; i >= 0; i-- {
+			e := edits[i]
+			text = text[:e.at] + e.text + text[e.at+e.n:]
+		}
+		return text
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *goast.GenDecl:
+			if d.Tok == gotoken.IMPORT || importGuard(d) {
+				continue
+			}
+			shared.decls.WriteString("\n" + rewrite(d, shared) + "\n")
+		case *goast.FuncDecl:
+			if d.Recv == nil && d.Name.Name == "init" {
+				// a statement runs in the init of the unit it registers
+				// the subs of
+				for _, stmt := range d.Body.List {
+					u := stmtUnit(stmt, file, declUnit, shared)
+					u.inits = append(u.inits, rewrite(stmt, u))
+				}
+				continue
+			}
+			u := shared
+			if d.Recv == nil {
+				u = declUnit[d.Name.Name]
+			}
+			u.decls.WriteString("\n" + rewrite(d, u) + "\n")
+		}
+	}
+
+	files := map[string]string{programPackage + "/program.go": unitSource(shared)}
+	for _, u := range units {
+		if u == mainUnit {
+			continue
+		}
+		files["pl/"+u.name+"/"+u.name+".go"] = unitSource(u)
+		// the subs register themselves in the init of their package
+		if !mainUnit.imports["perlc/pl/"+u.name] {
+			mainUnit.imports["_ perlc/pl/"+u.name] = true
+		}
+	}
+	// pl/program has the runtimes set up before main runs
+	if !mainUnit.imports["perlc/"+programPackage] {
+		mainUnit.imports["_ perlc/"+programPackage] = true
+	}
+	files["main.go"] = unitSource(mainUnit)
+	return files, nil
+}
+
+// declNames are the top-level names a declaration declares
+func declNames(decl goast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *goast.FuncDecl:
+		if d.Recv == nil && d.Name.Name != "init" {
+			names = append(names, d.Name.Name)
+		}
+	case *goast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *goast.ValueSpec:
+				for _, id := range s.Names {
+					if id.Name != "_" {
+						names = append(names, id.Name)
+					}
+				}
+			case *goast.TypeSpec:
+				names = append(names, s.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// importGuard reports whether d is one of the var _ = fmt.Sprint
+// declarations that keep an import used; a split file imports only what it
+// uses instead
+func importGuard(d *goast.GenDecl) bool {
+	if d.Tok != gotoken.VAR {
+		return false
+	}
+	for _, spec := range d.Specs {
+		s, ok := spec.(*goast.ValueSpec)
+		if !ok || len(s.Names) != 1 || s.Names[0].Name != "_" {
+			return false
+		}
+		for _, v := range s.Values {
+			if _, ok := v.(*goast.SelectorExpr); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stmtUnit is the unit whose init runs stmt: the one of the subs it
+// refers to, main when it refers to several and pl/program for none
+func stmtUnit(stmt goast.Stmt, file *goast.File, declUnit map[string]*unit, shared *unit) *unit {
+	var found *unit
+	several := false
+	goast.Inspect(stmt, func(n goast.Node) bool {
+		id, ok := n.(*goast.Ident)
+		if !ok || id.Obj == nil || file.Scope.Lookup(id.Name) != id.Obj {
+			return true
+		}
+		if u := declUnit[id.Name]; u != nil && u != shared {
+			several = several || found != nil && found != u
+			found = u
+		}
+		return true
+	})
+	switch {
+	case several:
+		return declUnit["main"]
+	case found != nil:
+		return found
+	}
+	return shared
+}
+
+// subPackage is the Perl package of the sub of the full name name
+func subPackage(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[:i]
+	}
+	return "main"
+}
+
+// methodKey is the key of the sub of the full name name in perlrt.Methods
+func methodKey(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[:i] + "_" + name[i+2:]
+	}
+	return name
+}
+
+// goPackageName is the Go package of the Perl package pkg: Foo::Bar is
+// foo_bar, and foo_barpkg if that is taken
+func goPackageName(pkg string, taken map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, strings.ReplaceAll(pkg, "::", "_"))
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "pl" + name
+	}
+	for taken[name] || gotoken.IsKeyword(name) {
+		name += "pkg"
+	}
+	return name
+}
+
+// exportName is the exported name of a name of pl/program: perl_croak is
+// Perl_croak, _isaArray X_isaArray; one of declared is not reused
+func exportName(name string, declared map[string]bool) string {
+	exp := name
+	switch {
+	case gotoken.IsExported(name):
+		return name
+	case name[0] >= 'a' && name[0] <= 'z':
+		exp = strings.ToUpper(name[:1]) + name[1:]
+	default:
+		exp = "X" + name
+	}
+	for declared[exp] {
+		exp += "_"
+	}
+	return exp
+}
+
+// wrapperName is the exported Go name of the sub name: total_count is
+// TotalCount. Subs starting with _ are private and get none.
+func wrapperName(name string) string {
+	if name == "" || name[0] == '_' {
+		return ""
+	}
+	var out strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			out.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	if !gotoken.IsExported(out.String()) {
+		return ""
+	}
+	return out.String()
+}
+
+// unitSource is the Go file of a unit
+func unitSource(u *unit) string {
+	var out strings.Builder
+	if u.pkg != "" && u.pkg != "main" {
+		out.WriteString("// Package " + u.name + " is the Perl package " + u.pkg + ".\n")
+	}
+	out.WriteString("package " + u.name + "\n")
+	var paths []string
+	for p := range u.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		out.WriteString("\nimport (\n")
+		for _, p := range paths {
+			if blank, ok := strings.CutPrefix(p, "_ "); ok {
+				out.WriteString("\t_ " + strconv.Quote(blank) + "\n")
+			} else {
+				out.WriteString("\t" + strconv.Quote(p) + "\n")
+			}
+		}
+		out.WriteString(")\n")
+	}
+	out.WriteString(u.decls.String())
+	for _, w := range u.wrappers {
+		out.WriteString("\n" + w)
+	}
+	if len(u.inits) > 0 {
+		out.WriteString("\nfunc init() {\n")
+		for _, stmt := range u.inits {
+			out.WriteString("\t" + stmt + "\n")
+		}
+		out.WriteString("}\n")
+	}
+	return out.String()
+}
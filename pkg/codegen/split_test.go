@@ -0,0 +1,62 @@
+package codegen
+
+import (
+	goparser "go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestGeneratePackages(t *testing.T) {
+	program := parser.New(lexer.New(`package Counter;
+our $count = 0;
+sub total_count { return $count; }
+sub _reset { $count = 0; }
+package Util;
+sub report { return "total " . Counter::total_count(); }
+package main;
+print Util::report(), "\n";
+`)).ParseProgram()
+	files, err := New().GeneratePackages(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main.go", "pl/counter/counter.go", "pl/util/util.go", "pl/program/program.go"} {
+		if _, err := goparser.ParseFile(gotoken.NewFileSet(), name, files[name], 0); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, files[name])
+		}
+	}
+
+	for name, want := range map[string][]string{
+		"main.go": {
+			`"perlc/pl/util"`,
+			`_ "perlc/pl/counter"`,
+			"util.Report()", // main calls the wrappers
+		},
+		"pl/counter/counter.go": {
+			"package counter",
+			"func TotalCount(args ...*perlrt.SV) *perlrt.SV",
+			"return program.V_Counter__count", // package variables are shared
+			`perlrt.Perl_register_method("Counter_total_count", perl_Counter_total_count)`,
+		},
+		"pl/util/util.go": {
+			`perlrt.Methods["Counter_total_count"]()`, // no import of another package
+		},
+		"pl/program/program.go": {
+			"package program",
+			"var V_Counter__count = perlrt.SvUndef()",
+		},
+	} {
+		for _, w := range want {
+			if !strings.Contains(files[name], w) {
+				t.Errorf("%s has no %s:\n%s", name, w, files[name])
+			}
+		}
+	}
+	if strings.Contains(files["pl/counter/counter.go"], "func Reset(") {
+		t.Error("private sub _reset got a wrapper")
+	}
+}
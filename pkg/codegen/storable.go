@@ -0,0 +1,276 @@
+package codegen
+
+import (
+	"fmt"
+
+	"perlc/pkg/ast"
+)
+
+// storableFuncs are the functions of Storable; store and retrieve are
+// exported by default, the others by name
+var storableFuncs = map[string]bool{
+	"store": true, "nstore": true, "retrieve": true, "freeze": true,
+	"nfreeze": true, "thaw": true, "dclone": true,
+}
+
+// useStorable records the names imported by use Storable LIST
+func (g *Generator) useStorable(args []ast.Expression) {
+	if g.storable == nil {
+		g.storable = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		g.storable["store"] = true
+		g.storable["retrieve"] = true
+		return
+	}
+	for _, arg := range args {
+		for _, name := range constStrings(arg) {
+			if storableFuncs[name] {
+				g.storable[name] = true
+			}
+		}
+	}
+}
+
+// generateStorableCall emits a Storable function with where it is called
+// from, for its errors; the arguments are not flattened, \@a is the array
+func (g *Generator) generateStorableCall(name string, args []ast.Expression) {
+	g.write(fmt.Sprintf("_storable(%q, %q", name, g.where()))
+	for _, a := range args {
+		g.write(", ")
+		g.generateExpression(a)
+	}
+	g.write(")")
+}
+
+// writeStorableRuntime emits freeze/thaw, store/retrieve and dclone. The
+// image is the interpreter's (sv.Freeze): "PLST\x01", then each value
+// tagged U, I, N, S or X (qr//), and a reference R, or O with its class,
+// followed by its referent: A, H, V (a scalar) or P and the index of a
+// referent already written, so shared data stays shared.
+func (g *Generator) writeStorableRuntime() {
+	g.writeln(`const _storableMagic = "PLST\x01"
+
+// _storable runs Storable::name; where is the caller's "FILE line N"
+func _storable(name, where string, args ...*perlrt.SV) *perlrt.SV {
+	arg := func(n int) *perlrt.SV {
+		if n < len(args) && args[n] != nil { return args[n] }
+		return perlrt.SvUndef()
+	}
+	die := func(msg string) *perlrt.SV {
+		if where != "" { msg += " at " + where }
+		return perlrt.Perl_die(perlrt.SvStr(msg + ".\n"))
+	}
+	freeze := func(v *perlrt.SV, notRef string) []byte {
+		if perlrt.RefAddr(v) == 0 && v.Flags&0x40 == 0 {
+			die(notRef)
+			return nil
+		}
+		f := &_freezer{buf: []byte(_storableMagic), seen: map[*perlrt.SV]uint64{}}
+		if err := f.value(v); err != "" { die(err) }
+		return f.buf
+	}
+	thaw := func(data []byte, failed string) *perlrt.SV {
+		if len(data) < len(_storableMagic) || string(data[:len(_storableMagic)]) != _storableMagic { return die(failed) }
+		t := &_thawer{data: data[len(_storableMagic):]}
+		v := t.value()
+		if t.bad || len(t.data) > 0 { return die(failed) }
+		return v
+	}
+	switch name {
+	case "Storable::freeze", "Storable::nfreeze":
+		return perlrt.SvStr(string(freeze(arg(0), "not a reference")))
+	case "Storable::thaw":
+		if arg(0).Flags == 0 { return perlrt.SvUndef() }
+		return thaw([]byte(arg(0).AsString()), "Magic number checking on storable string failed")
+	case "Storable::dclone":
+		return thaw(freeze(arg(0), "Not a reference"), "Magic number checking on storable string failed")
+	case "Storable::store", "Storable::nstore":
+		data := freeze(arg(0), "not a reference")
+		file := arg(1).AsString()
+		if err := os.WriteFile(file, data, 0o666); err != nil {
+			perlrt.SetOSError(err)
+			return die("can't create " + file + ": " + perlrt.OsError)
+		}
+		return perlrt.SvInt(1)
+	case "Storable::retrieve":
+		file := arg(0).AsString()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			perlrt.SetOSError(err)
+			return die("can't open " + file + ": " + perlrt.OsError)
+		}
+		return thaw(data, "Magic number checking on storable file failed")
+	}
+	return perlrt.SvUndef()
+}
+
+// _freezer writes the image; seen numbers the referents written so far
+type _freezer struct {
+	buf  []byte
+	seen map[*perlrt.SV]uint64
+}
+
+func (f *_freezer) str(s string) {
+	f.buf = binary.AppendUvarint(f.buf, uint64(len(s)))
+	f.buf = append(f.buf, s...)
+}
+
+func (f *_freezer) value(v *perlrt.SV) string {
+	switch {
+	case v == nil || v.Flags == 0 && v.CV == nil:
+		f.buf = append(f.buf, 'U')
+	case v.CV != nil:
+		return "Can't store CODE items"
+	case v.Flags&0x20 != 0:
+		return "Can't store GLOB items"
+	case v.Flags&0x40 != 0:
+		f.buf = append(f.buf, 'X')
+		f.str(v.PV)
+	case v.Flags&(perlrt.SVf_AOK|perlrt.SVf_HOK) != 0:
+		if pkg, ok := perlrt.Blessed(v); ok {
+			f.buf = append(f.buf, 'O')
+			f.str(pkg)
+		} else {
+			f.buf = append(f.buf, 'R')
+		}
+		return f.referent(v)
+	case v.Flags&perlrt.SVf_POK != 0:
+		f.buf = append(f.buf, 'S')
+		f.str(v.PV)
+	case v.Flags&perlrt.SVf_IOK != 0:
+		f.buf = append(f.buf, 'I')
+		f.buf = binary.AppendVarint(f.buf, v.IV)
+	default:
+		f.buf = append(f.buf, 'N')
+		f.buf = binary.BigEndian.AppendUint64(f.buf, math.Float64bits(v.NV))
+	}
+	return ""
+}
+
+// referent writes what the reference ref points to: an array or a hash is
+// the reference itself, a scalar reference holds its target in av[0]
+func (f *_freezer) referent(ref *perlrt.SV) string {
+	target := ref
+	if ref.Flags&0x80 != 0 {
+		target = nil
+		if len(ref.AV) > 0 { target = ref.AV[0] }
+		if target == nil { f.buf = append(f.buf, 'V', 'U'); return "" }
+	}
+	if n, ok := f.seen[target]; ok {
+		f.buf = append(f.buf, 'P')
+		f.buf = binary.AppendUvarint(f.buf, n)
+		return ""
+	}
+	f.seen[target] = uint64(len(f.seen))
+	switch {
+	case ref.Flags&0x80 != 0:
+		f.buf = append(f.buf, 'V')
+		return f.value(target)
+	case ref.Flags&perlrt.SVf_AOK != 0:
+		f.buf = append(f.buf, 'A')
+		f.buf = binary.AppendUvarint(f.buf, uint64(len(ref.AV)))
+		for _, el := range ref.AV {
+			if err := f.value(el); err != "" { return err }
+		}
+	default:
+		keys := make([]string, 0, len(ref.HV))
+		for k := range ref.HV { keys = append(keys, k) }
+		sort.Strings(keys)
+		f.buf = append(f.buf, 'H')
+		f.buf = binary.AppendUvarint(f.buf, uint64(len(keys)))
+		for _, k := range keys {
+			f.str(k)
+			if err := f.value(ref.HV[k]); err != "" { return err }
+		}
+	}
+	return ""
+}
+
+// _thawer reads an image back; seen holds the references to the
+// referents read so far, registered before their contents for cycles
+type _thawer struct {
+	data []byte
+	seen []*perlrt.SV
+	bad  bool
+}
+
+func (t *_thawer) byte() byte {
+	if len(t.data) == 0 { t.bad = true; return 0 }
+	b := t.data[0]
+	t.data = t.data[1:]
+	return b
+}
+
+func (t *_thawer) uvarint() uint64 {
+	n, size := binary.Uvarint(t.data)
+	if size <= 0 { t.bad = true; return 0 }
+	t.data = t.data[size:]
+	return n
+}
+
+func (t *_thawer) str() string {
+	n := t.uvarint()
+	if n > uint64(len(t.data)) { t.bad = true; return "" }
+	s := string(t.data[:n])
+	t.data = t.data[n:]
+	return s
+}
+
+func (t *_thawer) value() *perlrt.SV {
+	if t.bad { return perlrt.SvUndef() }
+	switch t.byte() {
+	case 'U':
+		return perlrt.SvUndef()
+	case 'I':
+		n, size := binary.Varint(t.data)
+		if size <= 0 { break }
+		t.data = t.data[size:]
+		return perlrt.SvInt(n)
+	case 'N':
+		if len(t.data) < 8 { break }
+		f := math.Float64frombits(binary.BigEndian.Uint64(t.data))
+		t.data = t.data[8:]
+		return perlrt.SvFloat(f)
+	case 'S':
+		return perlrt.SvStr(t.str())
+	case 'X':
+		return perlrt.SvRegex(t.str())
+	case 'R':
+		return t.referent()
+	case 'O':
+		pkg := t.str()
+		return perlrt.Perl_bless(t.referent(), perlrt.SvStr(pkg))
+	}
+	t.bad = true
+	return perlrt.SvUndef()
+}
+
+func (t *_thawer) referent() *perlrt.SV {
+	switch t.byte() {
+	case 'P':
+		if n := t.uvarint(); n < uint64(len(t.seen)) { return t.seen[n] }
+	case 'A':
+		a := perlrt.SvArray()
+		t.seen = append(t.seen, a)
+		for n := t.uvarint(); n > 0 && !t.bad; n-- { a.AV = append(a.AV, t.value()) }
+		return a
+	case 'H':
+		h := perlrt.SvHash()
+		t.seen = append(t.seen, h)
+		for n := t.uvarint(); n > 0 && !t.bad; n-- {
+			k := t.str()
+			h.HV[k] = t.value()
+		}
+		return h
+	case 'V':
+		target := perlrt.SvUndef()
+		ref := &perlrt.SV{AV: []*perlrt.SV{target}, Flags: perlrt.SVf_AOK | 0x80}
+		t.seen = append(t.seen, ref)
+		*target = *t.value()
+		return ref
+	}
+	t.bad = true
+	return perlrt.SvUndef()
+}`)
+}
@@ -0,0 +1,170 @@
+package codegen
+
+import (
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+)
+
+// switchMinBranches is the shortest if/elsif chain lowered to a Go switch
+const switchMinBranches = 3
+
+// switchCase is one branch of an eq chain: the constants it matches
+type switchCase struct {
+	values []string
+	body   *ast.BlockStmt
+}
+
+// eqChain recognizes an if/elsif chain that compares one scalar with string
+// constants: if ($op eq "add") ... elsif ($op eq "sub" || $op eq "neg") ...
+// A constant already matched by an earlier branch is dropped, as that
+// branch wins in Perl; a branch left without constants is unreachable.
+func eqChain(stmt *ast.IfStmt) (string, []switchCase, bool) {
+	if stmt.Unless || len(stmt.Elsif)+1 < switchMinBranches {
+		return "", nil, false
+	}
+	conds := []ast.Expression{stmt.Condition}
+	bodies := []*ast.BlockStmt{stmt.Then}
+	for _, elsif := range stmt.Elsif {
+		conds = append(conds, elsif.Condition)
+		bodies = append(bodies, elsif.Body)
+	}
+	if stmt.Else != nil && hasLast(stmt.Else.Statements) {
+		return "", nil, false
+	}
+
+	name := ""
+	seen := make(map[string]bool)
+	var cases []switchCase
+	for n, cond := range conds {
+		var values []string
+		if !eqValues(cond, &name, &values) || hasLast(bodies[n].Statements) {
+			return "", nil, false
+		}
+		c := switchCase{body: bodies[n]}
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				c.values = append(c.values, v)
+			}
+		}
+		if len(c.values) > 0 {
+			cases = append(cases, c)
+		}
+	}
+	return name, cases, true
+}
+
+// eqValues collects the constants of $x eq "a" || "b" eq $x || ...,
+// all compared with the same scalar *name
+func eqValues(cond ast.Expression, name *string, values *[]string) bool {
+	infix, ok := cond.(*ast.InfixExpr)
+	if !ok {
+		return false
+	}
+	switch infix.Operator {
+	case "||", "or":
+		return eqValues(infix.Left, name, values) && eqValues(infix.Right, name, values)
+	case "eq":
+	default:
+		return false
+	}
+	left, right := infix.Left, infix.Right
+	if _, ok := left.(*ast.ScalarVar); !ok {
+		left, right = right, left
+	}
+	v, ok := left.(*ast.ScalarVar)
+	if !ok || (*name != "" && *name != v.Name) {
+		return false
+	}
+	s, ok := constString(right)
+	if !ok {
+		return false
+	}
+	*name = v.Name
+	*values = append(*values, s)
+	return true
+}
+
+// constString is the value of a string literal without interpolated variables
+func constString(e ast.Expression) (string, bool) {
+	lit, ok := e.(*ast.StringLiteral)
+	if !ok {
+		return "", false
+	}
+	if !lit.Interpolated {
+		return lit.Value, true
+	}
+	var s strings.Builder
+	for _, seg := range interpolate.Parse(lit.Value) {
+		if seg.Expr != nil {
+			return "", false
+		}
+		s.WriteString(seg.Text)
+	}
+	return s.String(), true
+}
+
+// hasLast reports whether a last in stmts leaves them for an enclosing
+// loop. In a Go switch its break would stop at the switch instead.
+func hasLast(stmts []ast.Statement) bool {
+	for _, s := range stmts {
+		switch v := s.(type) {
+		case *ast.LastStmt:
+			return true
+		case *ast.BlockStmt:
+			if hasLast(v.Statements) {
+				return true
+			}
+		case *ast.LabelStmt:
+			if hasLast([]ast.Statement{v.Statement}) {
+				return true
+			}
+		case *ast.IfStmt:
+			if hasLast(v.Then.Statements) || (v.Else != nil && hasLast(v.Else.Statements)) {
+				return true
+			}
+			for _, elsif := range v.Elsif {
+				if hasLast(elsif.Body.Statements) {
+					return true
+				}
+			}
+		case *ast.GivenStmt:
+			if v.Default != nil && hasLast(v.Default.Statements) {
+				return true
+			}
+			for _, c := range v.Clauses {
+				if hasLast(c.Body.Statements) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// generateEqSwitch generates an eq chain as a switch on the string value of
+// the scalar: Go dispatches a constant string switch by binary search
+// instead of one comparison per branch.
+func (g *Generator) generateEqSwitch(name string, cases []switchCase, def *ast.BlockStmt) {
+	g.writeln("switch " + g.scalarString(name) + " {")
+	for _, c := range cases {
+		quoted := make([]string, len(c.values))
+		for n, v := range c.values {
+			quoted[n] = strconv.Quote(v)
+		}
+		g.writeln("case " + strings.Join(quoted, ", ") + ":")
+		g.indent++
+		g.generateBlock(c.body.Statements)
+		g.indent--
+	}
+	if def != nil {
+		g.writeln("default:")
+		g.indent++
+		g.generateBlock(def.Statements)
+		g.indent--
+	}
+	g.writeln("}")
+}
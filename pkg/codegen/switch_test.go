@@ -0,0 +1,41 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func TestEqSwitch(t *testing.T) {
+	input := `my $op = "add";
+if ($op eq "add") { print "a"; } elsif ($op eq "sub" || "neg" eq $op) { print "s"; } elsif ($op eq "add") { print "x"; } else { print "?"; }
+while (1) {
+    if ($op eq "a") { print 1; } elsif ($op eq "b") { last; } elsif ($op eq "c") { print 3; }
+}`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	g := New()
+	g.Optimize = true
+	code := g.Generate(program)
+	main := code[strings.Index(code, "func main()"):]
+	for _, want := range []string{
+		"switch s_op {",
+		`case "sub", "neg":`,
+		"default:",
+		"} else if (perlrt.SvStrEq(perlrt.SvStr(s_op)",
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main does not contain %s:\n%s", want, main)
+		}
+	}
+	if strings.Count(main, `case "add"`) != 1 {
+		t.Errorf("duplicate case not dropped:\n%s", main)
+	}
+
+	code = New().Generate(program)
+	if strings.Contains(code[strings.Index(code, "func main()"):], "switch s_op") {
+		t.Error("eq chain lowered to a switch without Optimize")
+	}
+}
@@ -0,0 +1,295 @@
+package codegen
+
+// threadsMethods are the methods of threads and Thread::Queue that may
+// die: a call of one of them passes where it is made on to the message
+var threadsMethods = map[string]bool{"create": true, "new": true, "join": true, "enqueue": true}
+
+// writeThreadsRuntime emits threads and Thread::Queue: a thread is a
+// goroutine, join waits for it and returns what its code returned, and a
+// queue is an unbounded list of values with a channel to wake up dequeue.
+// Unlike ithreads, nothing is cloned for a thread: the arguments of create
+// and the values enqueued are copied, but globals, closures and whatever
+// references point to are shared, so writing them from several threads
+// needs a lock of its own. A die nobody catches ends the thread with
+// "Thread N terminated abnormally"; threads still running when main
+// returns are stopped with it.
+func (g *Generator) writeThreadsRuntime() {
+	g.writeln(`type _Thread struct {
+	tid      int
+	done     chan struct{}
+	result   *perlrt.SV
+	err      *perlrt.SV
+	joined   bool
+	detached bool
+}
+
+// _threadsCall is a method call that may go to threads or Thread::Queue:
+// where is the caller's "FILE line N"
+func _threadsCall(where string, obj *perlrt.SV, method string, args ...*perlrt.SV) *perlrt.SV {
+	all := append([]*perlrt.SV{obj}, args...)
+	class, ok := perlrt.Blessed(obj)
+	if !ok && obj.Flags&perlrt.SVf_POK != 0 { class = obj.AsString() }
+	switch class {
+	case "threads":
+		return _threadsMethod(where, method, all)
+	case "Thread::Queue":
+		return _queueMethod(where, method, all)
+	}
+	return perlrt.Perl_method_call(obj, method, args...)
+}
+
+// _threadDie dies with msg at where, as the interpreter does
+func _threadDie(msg, where string) *perlrt.SV {
+	if where != "" { msg += " at " + where + "." }
+	return perlrt.Perl_die(perlrt.SvStr(msg))
+}
+
+var _threads = map[*perlrt.SV]*_Thread{}
+var _threadList []*_Thread
+var _threadOf = map[uint64]*_Thread{}
+var _threadsMu sync.Mutex
+
+// _goid is the id of the running goroutine, which threads->tid and die
+// need to know the thread they are in
+func _goid() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if n := bytes.IndexByte(b, ' '); n >= 0 { b = b[:n] }
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+func _threadCurrent() *_Thread {
+	_threadsMu.Lock()
+	defer _threadsMu.Unlock()
+	return _threadOf[_goid()]
+}
+
+// _threadObject is a threads object for t; all of them lead to t
+func _threadObject(t *_Thread) *perlrt.SV {
+	obj := perlrt.Perl_bless(perlrt.SvHash(), perlrt.SvStr("threads"))
+	_threadsMu.Lock()
+	_threads[obj] = t
+	_threadsMu.Unlock()
+	return obj
+}
+
+// _threadCreate is threads->create(CODE, ARGS); a hash of options before
+// the code is skipped
+func _threadCreate(where string, args []*perlrt.SV) *perlrt.SV {
+	if len(args) > 0 && args[0].Flags&perlrt.SVf_HOK != 0 { args = args[1:] }
+	if len(args) == 0 || args[0].CV == nil { return _threadDie("threads->create needs a code reference", where) }
+	code, rest := args[0], perlrt.ListCopy(perlrt.Flatten(args[1:]))
+	t := &_Thread{done: make(chan struct{})}
+	_threadsMu.Lock()
+	_threadList = append(_threadList, t)
+	t.tid = len(_threadList)
+	_threadsMu.Unlock()
+	started := make(chan struct{})
+	go func() {
+		defer close(t.done)
+		_threadsMu.Lock()
+		id := _goid()
+		_threadOf[id] = t
+		_threadsMu.Unlock()
+		close(started)
+		defer func() {
+			_threadsMu.Lock()
+			delete(_threadOf, id)
+			_threadsMu.Unlock()
+			if r := recover(); r != nil {
+				d, ok := r.(perlrt.PerlDie)
+				if !ok { panic(r) }
+				t.result, t.err = nil, perlrt.SvStr(d.Msg)
+				if d.Value != nil { t.err = d.Value }
+				perlrt.Perl_warn(perlrt.SvStr(fmt.Sprintf("Thread %d terminated abnormally: %s", t.tid, d.Msg)))
+			}
+		}()
+		t.result = code.CV(rest...)
+	}()
+	<-started
+	return _threadObject(t)
+}
+
+func _threadsMethod(where, method string, args []*perlrt.SV) *perlrt.SV {
+	if _, ok := perlrt.Blessed(args[0]); !ok {
+		switch method {
+		case "create", "new":
+			return _threadCreate(where, args[1:])
+		case "tid":
+			if t := _threadCurrent(); t != nil { return perlrt.SvInt(int64(t.tid)) }
+			return perlrt.SvInt(0)
+		case "self":
+			if t := _threadCurrent(); t != nil { return _threadObject(t) }
+			return _threadObject(&_Thread{done: make(chan struct{})})
+		case "list":
+			_threadsMu.Lock()
+			var list []*_Thread
+			for _, t := range _threadList {
+				if !t.joined && !t.detached { list = append(list, t) }
+			}
+			_threadsMu.Unlock()
+			objs := make([]*perlrt.SV, len(list))
+			for n, t := range list { objs[n] = _threadObject(t) }
+			return perlrt.SvArray(objs...)
+		case "yield":
+			runtime.Gosched()
+			return perlrt.SvInt(1)
+		}
+		return perlrt.SvUndef()
+	}
+	_threadsMu.Lock()
+	t := _threads[args[0]]
+	_threadsMu.Unlock()
+	if t == nil { return perlrt.SvUndef() }
+	running := func() bool {
+		select {
+		case <-t.done:
+			return false
+		default:
+			return true
+		}
+	}
+	switch method {
+	case "join":
+		_threadsMu.Lock()
+		detached, joined := t.detached, t.joined
+		t.joined = true
+		_threadsMu.Unlock()
+		if detached { return _threadDie("Cannot join a detached thread", where) }
+		if joined { return _threadDie("Thread already joined", where) }
+		<-t.done
+		if t.result == nil { return perlrt.SvUndef() }
+		return t.result
+	case "detach":
+		_threadsMu.Lock()
+		t.detached = true
+		_threadsMu.Unlock()
+		return perlrt.SvInt(1)
+	case "tid":
+		return perlrt.SvInt(int64(t.tid))
+	case "is_running":
+		if running() { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	case "is_joinable":
+		_threadsMu.Lock()
+		joinable := !t.joined && !t.detached
+		_threadsMu.Unlock()
+		if joinable && !running() { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	case "is_detached":
+		_threadsMu.Lock()
+		defer _threadsMu.Unlock()
+		if t.detached { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	case "error":
+		if running() || t.err == nil { return perlrt.SvUndef() }
+		return t.err
+	case "equal":
+		if len(args) < 2 { return perlrt.SvStr("") }
+		_threadsMu.Lock()
+		other := _threads[args[1]]
+		_threadsMu.Unlock()
+		if other == t { return perlrt.SvInt(1) }
+		return perlrt.SvStr("")
+	}
+	return perlrt.SvUndef()
+}
+
+// _Queue is a Thread::Queue: enqueue never blocks, dequeue waits on ready
+// until there are values or the queue is ended
+type _Queue struct {
+	mu    sync.Mutex
+	items []*perlrt.SV
+	ended bool
+	ready chan struct{}
+}
+
+var _queues = map[*perlrt.SV]*_Queue{}
+var _queuesMu sync.Mutex
+
+// _wake lets one waiting dequeue look at the queue again
+func (q *_Queue) _wake() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func _queueMethod(where, method string, args []*perlrt.SV) *perlrt.SV {
+	if method == "new" {
+		obj := perlrt.Perl_bless(perlrt.SvHash(), perlrt.SvStr("Thread::Queue"))
+		_queuesMu.Lock()
+		_queues[obj] = &_Queue{items: perlrt.ListCopy(perlrt.Flatten(args[1:])), ready: make(chan struct{}, 1)}
+		_queuesMu.Unlock()
+		return obj
+	}
+	_queuesMu.Lock()
+	q := _queues[args[0]]
+	_queuesMu.Unlock()
+	if q == nil { return perlrt.SvUndef() }
+	switch method {
+	case "enqueue":
+		items := perlrt.ListCopy(perlrt.Flatten(args[1:]))
+		q.mu.Lock()
+		if q.ended {
+			q.mu.Unlock()
+			return _threadDie("'enqueue' method called on queue that has been 'end'ed", where)
+		}
+		q.items = append(q.items, items...)
+		q.mu.Unlock()
+		q._wake()
+		return perlrt.SvInt(1)
+	case "dequeue", "dequeue_nb":
+		count := 1
+		if len(args) > 1 { count = max(1, int(args[1].AsInt())) }
+		q.mu.Lock()
+		for method == "dequeue" && len(q.items) < count && !q.ended {
+			q.mu.Unlock()
+			<-q.ready
+			q.mu.Lock()
+		}
+		n := min(count, len(q.items))
+		items := q.items[:n:n]
+		q.items = q.items[n:]
+		if len(q.items) > 0 || q.ended { q._wake() }
+		q.mu.Unlock()
+		if len(args) > 1 { return perlrt.SvArray(items...) }
+		if n == 0 { return perlrt.SvUndef() }
+		return items[0]
+	case "pending":
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.ended && len(q.items) == 0 { return perlrt.SvUndef() }
+		return perlrt.SvInt(int64(len(q.items)))
+	case "peek":
+		idx := 0
+		if len(args) > 1 { idx = int(args[1].AsInt()) }
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if idx < 0 { idx += len(q.items) }
+		if idx < 0 || idx >= len(q.items) { return perlrt.SvUndef() }
+		return q.items[idx]
+	case "end":
+		q.mu.Lock()
+		q.ended = true
+		q.mu.Unlock()
+		q._wake()
+		return perlrt.SvInt(1)
+	}
+	return perlrt.SvUndef()
+}
+
+func init() {
+	perlrt.InThread = func() bool { return _threadCurrent() != nil }
+	for _, m := range []string{"create", "new", "tid", "self", "list", "yield", "join", "detach",
+		"is_running", "is_joinable", "is_detached", "error", "equal"} {
+		method := m
+		perlrt.Methods["threads_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _threadsMethod("", method, args) }
+	}
+	for _, m := range []string{"new", "enqueue", "dequeue", "dequeue_nb", "pending", "peek", "end"} {
+		method := m
+		perlrt.Methods["Thread::Queue_"+method] = func(args ...*perlrt.SV) *perlrt.SV { return _queueMethod("", method, args) }
+	}
+}`)
+}
@@ -0,0 +1,42 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/warnings"
+)
+
+// writeSetup emits the first package var of the program, which sets up
+// the runtime before any other is initialized: the knobs the program was
+// compiled with (perlrt.LoadTunables still lets the PERLC_* variables
+// override them at startup, like in perlc itself) and the arithmetic of
+// PerlCompatNumbers.
+func (g *Generator) writeSetup() {
+	var vals []string
+	for _, v := range g.Tunables.Values() {
+		vals = append(vals, fmt.Sprint(v))
+	}
+	g.writeln("var _ = func() bool {")
+	g.indent++
+	g.writeln("perlrt.Tune = perlrt.LoadTunables(perlrt.Tunables{" + strings.Join(vals, ", ") + "})")
+	if g.PerlCompatNumbers {
+		g.writeln("perlrt.CompatNumbers = true")
+	}
+	g.writeln("return true")
+	g.indent--
+	g.writeln("}()")
+	g.writeln("")
+}
+
+// enterSub emits the depth check at the top of a sub declared at pos; it
+// costs one branch when neither a depth limit nor verbose warnings are set.
+// A program using Carp pushes the frame of the call too.
+func (g *Generator) enterSub(name string, pos ast.Position) {
+	if !strings.Contains(name, "::") {
+		name = "main::" + name
+	}
+	g.writeln(fmt.Sprintf("if perlrt.Tune.MaxDepth > 0 || perlrt.Tune.Warnings >= 2 { perlrt.EnterSub(%q, %q); defer perlrt.LeaveSub() }", name, warnings.Where(pos)))
+	g.pushFrame(name, "", false)
+}
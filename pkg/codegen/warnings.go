@@ -0,0 +1,258 @@
+package codegen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/warnings"
+)
+
+// where is the location of the statement being generated, for warnings
+func (g *Generator) where() string {
+	pos, _ := ast.PosOf(g.stmt)
+	return warnings.Where(pos)
+}
+
+// generateOpArgs emits "left, right", the operands of operator op (+,
+// ., eq, ...), in scalar context. When the program turns on uninitialized or numeric warnings
+// they go through perlrt.OpArgs, for "Use of uninitialized value $x in addition
+// (+)" and "Argument isn't numeric"; assign is a compound assignment
+// (op=), whose left side perl does not always check.
+func (g *Generator) generateOpArgs(op string, left, right ast.Expression, assign bool) {
+	w, check := g.opWarn(op, left, right, assign)
+	if check {
+		g.write("perlrt.OpArgs(")
+	}
+	g.generateScalarValue(left)
+	g.write(", ")
+	g.generateScalarValue(right)
+	if check {
+		g.write(", " + w + ")")
+	}
+}
+
+// opWarn returns the perlrt.OpWarn literal of the operands of op, if they need
+// a check: operands that are never undefined or strings are skipped.
+func (g *Generator) opWarn(op string, left, right ast.Expression, assign bool) (string, bool) {
+	desc, ok := warnings.OpName(op)
+	if !ok {
+		return "", false
+	}
+	_, numeric := warnings.NumericOps[op]
+	check := numeric && g.numericWarn && !(isNumericExpr(left, g.natives) && isNumericExpr(right, g.natives))
+	var leftMsg string
+	if !assign || !warnings.AssignExempt(op) {
+		leftMsg = g.uninitMsg(desc, left)
+	}
+	rightMsg := g.uninitMsg(desc, right)
+	if !check && leftMsg == "" && rightMsg == "" {
+		return "", false
+	}
+	return fmt.Sprintf("perlrt.OpWarn{%q, %q, %q, %q, %t}", desc, g.where(), leftMsg, rightMsg, numeric), true
+}
+
+// generateNumArg emits e, the argument of the numeric function op (int,
+// abs, ...); when the program turns on numeric warnings and e may be a
+// string it goes through perlrt.NumArg
+func (g *Generator) generateNumArg(op string, e ast.Expression) {
+	if !g.numericWarn || isNumericExpr(e, g.natives) {
+		g.generateExpression(e)
+		return
+	}
+	g.write("perlrt.NumArg(")
+	g.generateExpression(e)
+	g.write(", " + strconv.Quote(op) + ", " + strconv.Quote(g.where()) + ")")
+}
+
+// uninitMsg is the "Use of uninitialized value" warning for the operand
+// e of the operator desc, "" when the program does not turn the warnings
+// on or e is never undefined
+func (g *Generator) uninitMsg(desc string, e ast.Expression) string {
+	if !g.uninitWarn || isNumericExpr(e, g.natives) || isStrExpr(e, g.natives) {
+		return ""
+	}
+	return warnings.Uninit(warnings.VarName(e), desc)
+}
+
+// generateConcatOperand emits an operand of a native string concatenation
+// as a Go string; one that may be undefined goes through perlrt.Defined
+func (g *Generator) generateConcatOperand(e ast.Expression) {
+	msg := g.uninitMsg(warnings.StringOps["."], e)
+	if msg == "" {
+		g.generateStr(e)
+		return
+	}
+	g.write("perlrt.Defined(")
+	g.generateScalarExpression(e)
+	g.write(", " + strconv.Quote(g.where()) + ", " + strconv.Quote(msg) + ").AsString()")
+}
+
+// isNumericExpr reports whether e always yields a number
+func isNumericExpr(e ast.Expression, kinds map[string]nativeKind) bool {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		return true
+	case *ast.InfixExpr:
+		switch v.Operator {
+		case "+", "-", "*", "/", "%", "**":
+			return true
+		}
+	case *ast.PrefixExpr:
+		return v.Operator == "-" && isNumericExpr(v.Right, kinds)
+	}
+	return isIntExpr(e, kinds)
+}
+
+// generateUseWarnings compiles use/no warnings LIST. The categories are
+// checked at generation time; an unknown one dies when the statement runs,
+// as in the interpreter.
+func (g *Generator) generateUseWarnings(args []ast.Expression, off bool) {
+	var names []string
+	for _, arg := range args {
+		names = append(names, constStrings(arg)...)
+	}
+	cats, err := warnings.Parse(names...)
+	if err != nil {
+		g.writeln(fmt.Sprintf("perlrt.DieAt(%q, perlrt.SvStr(%q))", g.where(), err.Error()))
+		return
+	}
+	quoted := []string{strconv.FormatBool(off)}
+	for _, c := range cats {
+		if c == warnings.Misc {
+			g.miscOff = off
+		}
+		quoted = append(quoted, strconv.Quote(string(c)))
+	}
+	g.writeln("perlrt.Warnings(" + strings.Join(quoted, ", ") + ")")
+}
+
+// namesCategory reports whether use/no warnings LIST turns category c on
+// or off. The numeric category is optional: programs without it need no
+// checks.
+func namesCategory(args []ast.Expression, c warnings.Category) bool {
+	var names []string
+	for _, arg := range args {
+		names = append(names, constStrings(arg)...)
+	}
+	cats, _ := warnings.Parse(names...)
+	for _, cat := range cats {
+		if cat == c {
+			return true
+		}
+	}
+	return false
+}
+
+// hashListCheck looks at the list rhs assigned to a hash from its element
+// skip on. A list of known length needs no check at run time (static) and
+// an odd one is reported at compile time as well, with msg; others go
+// through _hashPairs.
+func (g *Generator) hashListCheck(rhs ast.Expression, skip int) (msg string, static bool) {
+	n, ok := listLen(rhs)
+	if !ok || (n-skip == 1 && !isRefValue(rhs) && !isLiteral(rhs)) {
+		// one scalar may hold a reference
+		return "", false
+	}
+	msg = warnings.HashList(n-skip, skip == 0 && isRefValue(rhs))
+	if msg != "" && !g.miscOff && g.Tunables.Warnings > 0 {
+		pos, _ := ast.PosOf(g.stmt)
+		g.Warnings = append(g.Warnings, warnings.Format(msg, pos))
+	}
+	return msg, true
+}
+
+// listLen is the number of elements of a list of scalars, with a hash
+// counted as 2: only whether it is odd matters
+func listLen(e ast.Expression) (int, bool) {
+	switch v := e.(type) {
+	case *ast.HashVar:
+		return 2, true
+	case *ast.DerefExpr:
+		return 2, v.Sigil == "%"
+	case *ast.ArrayExpr:
+		if v.Token.Value == "[" {
+			return 1, true
+		}
+		n := 0
+		for _, el := range v.Elements {
+			k, ok := listLen(el)
+			if !ok {
+				return 0, false
+			}
+			n += k
+		}
+		return n, true
+	}
+	return 1, isScalarValue(e)
+}
+
+// isRefValue reports whether e makes a reference: {...}, [...], \$x, sub {}
+func isRefValue(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.HashExpr, *ast.RefExpr, *ast.AnonSubExpr:
+		return true
+	case *ast.ArrayExpr:
+		return v.Token.Value == "["
+	}
+	return false
+}
+
+// checkHashValue checks the value of my %h = ... and local %h = ...: an
+// odd list of known length warns here, unconditionally, and a list of
+// unknown length needs _hashList (reported by the result)
+func (g *Generator) checkHashValue(rhs ast.Expression) bool {
+	msg, static := g.hashListCheck(rhs, 0)
+	if msg != "" {
+		g.writeln("perlrt.Warn(\"misc\", " + strconv.Quote(g.where()) + ", " + strconv.Quote(msg) + ")")
+	}
+	return !static
+}
+
+// generateHashValue emits the checked value of my %h = rhs for
+// svHashFrom: a list goes through _hashList, a scalar, which may hold a
+// reference, through _hashPairs
+func (g *Generator) generateHashValue(rhs ast.Expression) {
+	loc := strconv.Quote(g.where())
+	if isScalarValue(rhs) {
+		g.write("perlrt.SvHashFrom(perlrt.HashPairs([]*perlrt.SV{")
+		g.generateExpression(rhs)
+		g.write("}, " + loc + ")[0])")
+		return
+	}
+	g.write("perlrt.SvHashFrom(perlrt.HashList(")
+	g.generateExpression(rhs)
+	g.write(", " + loc + "))")
+}
+
+// generateHashPairs emits the values list (a []*SV) assigned to a hash
+// from element skip of rhs on, checked by _hashPairs unless rhs is known
+// to be even.
+func (g *Generator) generateHashPairs(rhs ast.Expression, skip int, list func()) {
+	if msg, static := g.hashListCheck(rhs, skip); static && msg == "" {
+		list()
+		return
+	}
+	g.write("perlrt.HashPairs(")
+	list()
+	g.write(", " + strconv.Quote(g.where()) + ")")
+}
+
+// constStrings returns the words of a constant import list: strings, qw()
+// and barewords such as FATAL
+func constStrings(e ast.Expression) []string {
+	switch v := e.(type) {
+	case *ast.StringLiteral:
+		return []string{v.Value}
+	case *ast.Identifier:
+		return []string{v.Value}
+	case *ast.ArrayExpr:
+		var out []string
+		for _, el := range v.Elements {
+			out = append(out, constStrings(el)...)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+// TestHashListWarnings checks the lists assigned to hashes: odd ones of
+// known length warn at compile time, even ones need no check at run time.
+func TestHashListWarnings(t *testing.T) {
+	input := `my %odd = (1, 2, 3);
+my %ref = {a => 1};
+my %even = (a => 1, %odd);
+my @list = (1, 2, 3);
+my %h = @list;
+my ($x, %rest) = (1, 2, 3);
+%h = (a => 1, 'b');
+no warnings 'misc';
+my %quiet = (1);
+`
+	program := parser.New(lexer.NewFile(input, "h.pl")).ParseProgram()
+	g := New()
+	code := g.Generate(program)
+	want := []string{
+		"Odd number of elements in hash assignment at h.pl line 1.\n",
+		"Reference found where even-sized list expected at h.pl line 2.\n",
+		"Odd number of elements in hash assignment at h.pl line 7.\n",
+	}
+	if strings.Join(g.Warnings, "") != strings.Join(want, "") {
+		t.Errorf("Warnings = %q, want %q", g.Warnings, want)
+	}
+
+	main := code[strings.Index(code, "func main()"):]
+	for _, line := range strings.Split(main, "\n") {
+		checked := strings.Contains(line, "perlrt.HashList(") || strings.Contains(line, "perlrt.HashPairs(")
+		switch {
+		case strings.Contains(line, "h_even") && strings.Contains(line, ":="), strings.Contains(line, "h_rest :="):
+			if checked {
+				t.Errorf("an even list is checked at run time: %s", strings.TrimSpace(line))
+			}
+		case strings.Contains(line, "h_h :="):
+			if !checked {
+				t.Errorf("an array is not checked at run time: %s", strings.TrimSpace(line))
+			}
+		}
+	}
+
+	g.Tunables.Warnings = 0
+	g.Generate(program)
+	if len(g.Warnings) != 0 {
+		t.Errorf("PERLC_WARNINGS=0 still warns: %q", g.Warnings)
+	}
+}
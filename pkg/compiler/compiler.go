@@ -173,6 +173,14 @@ func isStandardModule(name string) bool {
 		"File::Basename": true,
 		"Getopt::Long":   true,
 		"Pod::Usage":     true,
+		"Fcntl":          true,
+		"File::Glob":     true,
+		"IPC::Open3":     true,
+		"POSIX":          true,
+		"Symbol":         true,
+		"Sys::Hostname":  true,
+		"Time::HiRes":    true,
+		"Time::Piece":    true,
 	}
 	return standard[name]
 }
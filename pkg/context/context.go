@@ -3,9 +3,24 @@ package context
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"perlc/pkg/ast"
+	"perlc/pkg/stash"
 	"perlc/pkg/sv"
+	"perlc/pkg/version"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // Context holds interpreter state for a single execution.
@@ -18,8 +33,9 @@ type Context struct {
 	// Subroutines
 	subs map[string]*ast.BlockStmt
 
-	// Package @ISA arrays (для наследования)
-	packageISA map[string][]string
+	// Порядок разрешения методов пакетов, заданный use mro: "c3"; без
+	// него - "dfs", как в perl
+	mro map[string]string
 	// Arguments @_
 	args *sv.SV
 
@@ -30,13 +46,53 @@ type Context struct {
 	hasLast     bool
 	nextLabel   string
 	hasNext     bool
+	redoLabel   string
+	hasRedo     bool
 	filehandles map[string]*FileHandle
+	// Default output handle of print, say and printf (select)
+	selected string
+	// Page state of write, by handle name
+	formats map[string]*FormatState
+	// Buffer size of file handles in bytes, 0 - bufio default
+	ioBuffer int
 	// Calling context stack (для wantarray)
 	// 0 = void, 1 = scalar, 2 = list
 	contextStack []int
 
 	// Regex pos() для каждой переменной
 	regexPos map[string]int
+
+	// Child processes started by open3 and friends, by pid
+	children map[int]*childProc
+	// Pulsed whenever a child exits, for blocking wait()
+	childExit chan struct{}
+
+	// Signals waiting for delivery at the next safe point
+	sigMu          sync.Mutex
+	pendingSignals []string
+	sigPending     atomic.Bool
+	// Pulsed by RaiseSignal, wakes up sleep()
+	sigNotify chan struct{}
+	// Pending alarm(), raises ALRM when it fires
+	alarm   *time.Timer
+	alarmAt time.Time
+}
+
+// FormatState is what write keeps for an output handle: the formats it
+// uses and where it is on the page.
+type FormatState struct {
+	Name      string // $~, the handle name by default
+	Top       string // $^, NAME_TOP by default
+	PageLen   int    // $=, 60 by default
+	LinesLeft int    // $-, 0 forces a new page
+	Page      int    // $%, pages started so far
+}
+
+// childProc is a running or finished child; status is valid once done is closed.
+type childProc struct {
+	cmd    *exec.Cmd
+	done   chan struct{}
+	status int
 }
 
 type FileHandle struct {
@@ -44,6 +100,15 @@ type FileHandle struct {
 	Scanner *bufio.Scanner
 	Writer  *bufio.Writer
 	Mode    string
+	// Autoflush flushes after every print ($fh->autoflush(1))
+	Autoflush bool
+	// Pid is the command of a pipe open; close waits for it
+	Pid int
+	// Layers set by binmode or the open mode (<:raw, >:crlf)
+	Layers Layers
+	out    *layerWriter
+	// ahead is the read side of a read-write handle (+<, +>)
+	ahead *readAhead
 }
 
 // // В NewContext() добавь инициализацию:
@@ -56,31 +121,119 @@ type FileHandle struct {
 
 // New creates a new interpreter context.
 func New() *Context {
-	return &Context{
+	c := &Context{
 		runtime:      GetRuntime(),
 		scopes:       []map[string]*sv.SV{make(map[string]*sv.SV)},
 		subs:         make(map[string]*ast.BlockStmt),
-		packageISA:   make(map[string][]string),
+		mro:          make(map[string]string),
 		filehandles:  make(map[string]*FileHandle),
+		selected:     "STDOUT",
 		contextStack: make([]int, 0),
 		regexPos:     make(map[string]int),
+		children:     make(map[int]*childProc),
+		childExit:    make(chan struct{}, 1),
+		sigNotify:    make(chan struct{}, 1),
 	}
+	c.scopes[0]["ENV"] = envHash()
+	c.scopes[0]["ARGV"] = sv.NewArrayRef().Deref()
+	c.scopes[0]["INC"] = incArray()
+	c.scopes[0][IncHash] = sv.NewHashRef().Deref()
+	c.scopes[0]["SIG"] = sv.NewHashRef().Deref()
+	// The standard streams are handles like any other; STDOUT and STDERR
+	// have no buffer of their own, the interpreter writes them directly
+	c.filehandles["STDIN"] = c.newFileHandle(os.Stdin, "<")
+	c.filehandles["STDOUT"] = &FileHandle{File: os.Stdout, Mode: ">"}
+	c.filehandles["STDERR"] = &FileHandle{File: os.Stderr, Mode: ">"}
+	return c
 }
 
 // ============================================================
 // Variable Management
 // ============================================================
 
-// DeclareVar declares a variable in current scope.
+// DeclareVar declares a variable in current scope. A my variable hides an
+// our of the same name declared before it in the scope.
 func (c *Context) DeclareVar(name string, value *sv.SV, kind string) {
+	if setPackageVar(name, value) {
+		return
+	}
 	if len(c.scopes) == 0 {
 		c.scopes = append(c.scopes, make(map[string]*sv.SV))
 	}
-	c.scopes[len(c.scopes)-1][name] = value
+	scope := c.scopes[len(c.scopes)-1]
+	scope[name] = value
+	for _, sigil := range []string{"$", "@", "%"} {
+		delete(scope, ourKey(sigil, name))
+	}
+}
+
+// DeclareOur makes name (with its sigil) stand for the package variable
+// pkg::name until the end of the current scope: our $x in package Foo.
+func (c *Context) DeclareOur(sigil, name, pkg string) {
+	if len(c.scopes) == 0 {
+		c.scopes = append(c.scopes, make(map[string]*sv.SV))
+	}
+	c.scopes[len(c.scopes)-1][ourKey(sigil, name)] = sv.NewString(sigil + pkg + "::" + name)
+}
+
+// ourKey is where a scope keeps what an our made sigil+name stand for
+func ourKey(sigil, name string) string {
+	return "our " + sigil + name
+}
+
+// OurVar returns the key of the package variable an our declaration made
+// sigil+name stand for, unless a my variable of that name hides it.
+func (c *Context) OurVar(sigil, name string) (string, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if key, ok := c.scopes[i][ourKey(sigil, name)]; ok {
+			return key.AsString(), true
+		}
+		if _, ok := c.scopes[i][name]; ok {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// Package variables live in the symbol tables of pkg/stash, not in the
+// scopes: their keys are the sigil and the qualified name, "$Foo::bar",
+// "@Foo::bar" or "%Foo::bar".
+func isPackageVar(key string) bool {
+	return len(key) > 1 && strings.ContainsRune("$@%", rune(key[0])) && strings.Contains(key, "::")
+}
+
+func packageVar(key string) *sv.SV {
+	g := stash.Resolve(key[1:])
+	switch key[0] {
+	case '@':
+		return g.Array()
+	case '%':
+		return g.Hash()
+	}
+	return g.Scalar()
+}
+
+func setPackageVar(key string, value *sv.SV) bool {
+	if !isPackageVar(key) {
+		return false
+	}
+	g := stash.Resolve(key[1:])
+	switch key[0] {
+	case '@':
+		g.SetArray(value)
+	case '%':
+		g.SetHash(value)
+	default:
+		g.SetScalar(value)
+	}
+	return true
 }
 
 // SetVar sets a variable value (searches scopes).
 func (c *Context) SetVar(name string, value *sv.SV) {
+	if setPackageVar(name, value) {
+		return
+	}
 	// Search from innermost to outermost
 	for i := len(c.scopes) - 1; i >= 0; i-- {
 		if _, ok := c.scopes[i][name]; ok {
@@ -97,6 +250,9 @@ func (c *Context) SetVar(name string, value *sv.SV) {
 
 // GetVar gets a variable value.
 func (c *Context) GetVar(name string) *sv.SV {
+	if isPackageVar(name) {
+		return packageVar(name)
+	}
 	// Search from innermost to outermost
 	for i := len(c.scopes) - 1; i >= 0; i-- {
 		if v, ok := c.scopes[i][name]; ok {
@@ -106,6 +262,18 @@ func (c *Context) GetVar(name string) *sv.SV {
 	return sv.NewUndef()
 }
 
+// Global gets a variable of the outermost (file) scope, where top-level
+// my and our variables live.
+func (c *Context) Global(name string) (*sv.SV, bool) {
+	v, ok := c.scopes[0][name]
+	return v, ok
+}
+
+// SetGlobal sets a variable of the outermost (file) scope.
+func (c *Context) SetGlobal(name string, value *sv.SV) {
+	c.scopes[0][name] = value
+}
+
 // PushScope creates a new scope.
 func (c *Context) PushScope() {
 	c.scopes = append(c.scopes, make(map[string]*sv.SV))
@@ -118,46 +286,171 @@ func (c *Context) PopScope() {
 	}
 }
 
+// CaptureScopes returns the current scope chain for a closure. The scope maps
+// are shared, so the closure and its definer see each other's assignments.
+// The copy has no spare capacity: pushing onto it never touches the original.
+func (c *Context) CaptureScopes() []map[string]*sv.SV {
+	scopes := make([]map[string]*sv.SV, len(c.scopes))
+	copy(scopes, c.scopes)
+	return scopes
+}
+
+// SwapScopes installs scopes as the active chain and returns the previous one.
+func (c *Context) SwapScopes(scopes []map[string]*sv.SV) []map[string]*sv.SV {
+	old := c.scopes
+	c.scopes = scopes[:len(scopes):len(scopes)]
+	return old
+}
+
 // ============================================================
 // Inheritance Management
 // ============================================================
 
-// SetPackageISA sets the @ISA for a package.
-func (c *Context) SetPackageISA(pkg string, parents []string) {
-	c.packageISA[pkg] = parents
+// GetPackageISA returns the @ISA for a package: the package array
+// @PKG::ISA, which our @ISA, use parent and use base fill.
+func (c *Context) GetPackageISA(pkg string) []string {
+	var parents []string
+	for _, parent := range packageVar("@" + pkg + "::ISA").ArrayData() {
+		parents = append(parents, parent.AsString())
+	}
+	return parents
 }
 
-// GetPackageISA returns the @ISA for a package.
-func (c *Context) GetPackageISA(pkg string) []string {
-	return c.packageISA[pkg]
+// PushISA adds parents to the @ISA of pkg, as use parent does.
+func (c *Context) PushISA(pkg string, parents []string) {
+	isa := packageVar("@" + pkg + "::ISA")
+	items := isa.ArrayData()
+	for _, parent := range parents {
+		items = append(items, sv.NewString(parent))
+	}
+	isa.SetArrayData(items)
 }
 
-// FindMethod searches for a method in the class hierarchy.
-// Returns the full method name (Package::method) if found.
-func (c *Context) FindMethod(pkg, method string) string {
-	return c.findMethodRecursive(pkg, method, make(map[string]bool))
+// SetMRO sets the method resolution order of pkg, "dfs" or "c3".
+func (c *Context) SetMRO(pkg, mro string) {
+	c.mro[pkg] = mro
 }
 
-func (c *Context) findMethodRecursive(pkg, method string, visited map[string]bool) string {
-	// Prevent infinite loops in circular @ISA
-	if visited[pkg] {
-		return ""
+// MRO returns the method resolution order of pkg: "dfs" unless use mro
+// set "c3".
+func (c *Context) MRO(pkg string) string {
+	if mro, ok := c.mro[pkg]; ok {
+		return mro
 	}
-	visited[pkg] = true
+	return "dfs"
+}
 
-	// Try direct method
-	fullName := pkg + "::" + method
-	if c.subs[fullName] != nil {
-		return fullName
+// LinearISA returns the classes methods of pkg are looked up in, pkg
+// first: depth-first and left to right through @ISA, each class once,
+// or the C3 merge of the parents under use mro 'c3'. A hierarchy C3
+// cannot order is an error.
+func (c *Context) LinearISA(pkg string) ([]string, error) {
+	if c.MRO(pkg) == "c3" {
+		return c.c3(pkg, map[string]bool{})
 	}
+	var order []string
+	seen := map[string]bool{}
+	var walk func(pkg string)
+	walk = func(pkg string) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		order = append(order, pkg)
+		for _, parent := range c.GetPackageISA(pkg) {
+			walk(parent)
+		}
+	}
+	walk(pkg)
+	return order, nil
+}
 
-	// Search in parent classes (@ISA)
-	for _, parent := range c.packageISA[pkg] {
-		if found := c.findMethodRecursive(parent, method, visited); found != "" {
-			return found
+// c3 merges the C3 orders of the parents of pkg and the parents
+// themselves: the next class is the first head that is in no tail.
+// Like perl, a failed merge names the last head tried.
+func (c *Context) c3(pkg string, active map[string]bool) ([]string, error) {
+	if active[pkg] {
+		return nil, fmt.Errorf("Recursive inheritance detected in package '%s'", pkg)
+	}
+	active[pkg] = true
+	defer delete(active, pkg)
+	parents := c.GetPackageISA(pkg)
+	var seqs [][]string
+	for _, parent := range parents {
+		order, err := c.c3(parent, active)
+		if err != nil {
+			return nil, err
 		}
+		seqs = append(seqs, order)
 	}
+	seqs = append(seqs, parents)
+	result := []string{pkg}
+	for {
+		var heads [][]string
+		for _, seq := range seqs {
+			if len(seq) > 0 {
+				heads = append(heads, seq)
+			}
+		}
+		if len(heads) == 0 {
+			return result, nil
+		}
+		seqs = heads
+		next := ""
+		for _, seq := range seqs {
+			if !inTail(seqs, seq[0]) {
+				next = seq[0]
+				break
+			}
+		}
+		if next == "" {
+			return nil, fmt.Errorf("Inconsistent hierarchy during C3 merge of class '%s':\n\tcurrent merge results [\n\t\t%s,\n\t]\n\tmerging failed on '%s'",
+				pkg, strings.Join(result, ",\n\t\t"), seqs[len(seqs)-1][0])
+		}
+		result = append(result, next)
+		for n, seq := range seqs {
+			if seq[0] == next {
+				seqs[n] = seq[1:]
+			}
+		}
+	}
+}
+
+// inTail reports whether class is in the tail of any of seqs.
+func inTail(seqs [][]string, class string) bool {
+	for _, seq := range seqs {
+		for _, other := range seq[1:] {
+			if other == class {
+				return true
+			}
+		}
+	}
+	return false
+}
 
+// PackageDefined reports whether pkg has any sub.
+func (c *Context) PackageDefined(pkg string) bool {
+	prefix := pkg + "::"
+	for name := range c.subs {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindMethod searches for a method in the class hierarchy, in the order
+// of LinearISA. Returns the full method name (Package::method) if found.
+func (c *Context) FindMethod(pkg, method string) string {
+	order, err := c.LinearISA(pkg)
+	if err != nil {
+		return ""
+	}
+	for _, class := range order {
+		if fullName := class + "::" + method; c.subs[fullName] != nil {
+			return fullName
+		}
+	}
 	return ""
 }
 
@@ -179,13 +472,22 @@ func (c *Context) GetSub(name string) *ast.BlockStmt {
 // Arguments @_
 // ============================================================
 
-// SetArgs sets @_ for current call.
+// SetArgs sets @_ for current call. @_ keeps the args slice itself, so
+// the caller sees the values $_[n] = ... stored in it.
 func (c *Context) SetArgs(args []*sv.SV) {
 	ref := sv.NewArrayRef(args...)
 	deref := ref.Deref()
+	deref.SetArrayData(args)
 	c.args = deref
 }
 
+// SwapArgs sets @_ to args and returns the @_ it replaces.
+func (c *Context) SwapArgs(args *sv.SV) *sv.SV {
+	prev := c.args
+	c.args = args
+	return prev
+}
+
 // GetArgs returns @_ array.
 func (c *Context) GetArgs() *sv.SV {
 	if c.args == nil {
@@ -238,6 +540,11 @@ func (c *Context) HasLast() bool {
 	return c.hasLast
 }
 
+// LastLabel returns the label of the pending last, "" for the innermost loop.
+func (c *Context) LastLabel() string {
+	return c.lastLabel
+}
+
 // ClearLast clears last flag.
 func (c *Context) ClearLast() {
 	c.hasLast = false
@@ -259,12 +566,43 @@ func (c *Context) HasNext() bool {
 	return c.hasNext
 }
 
+// NextLabel returns the label of the pending next, "" for the innermost loop.
+func (c *Context) NextLabel() string {
+	return c.nextLabel
+}
+
 // ClearNext clears next flag.
 func (c *Context) ClearNext() {
 	c.hasNext = false
 	c.nextLabel = ""
 }
 
+// ============================================================
+// Redo Control
+// ============================================================
+
+// SetRedo sets redo flag.
+func (c *Context) SetRedo(label string) {
+	c.redoLabel = label
+	c.hasRedo = true
+}
+
+// HasRedo checks if redo was called.
+func (c *Context) HasRedo() bool {
+	return c.hasRedo
+}
+
+// RedoLabel returns the label of the pending redo, "" for the innermost loop.
+func (c *Context) RedoLabel() string {
+	return c.redoLabel
+}
+
+// ClearRedo clears redo flag.
+func (c *Context) ClearRedo() {
+	c.hasRedo = false
+	c.redoLabel = ""
+}
+
 // ============================================================
 // Special Variables
 // ============================================================
@@ -282,6 +620,14 @@ func (c *Context) GetSpecialVar(name string) *sv.SV {
 		return c.runtime.OutputFS()
 	case "$\"":
 		return c.runtime.ListSep()
+	case "$|":
+		if fh := c.filehandles[c.selected]; fh != nil && fh.Writer != nil {
+			if fh.Autoflush {
+				return sv.NewInt(1)
+			}
+			return sv.NewInt(0)
+		}
+		return c.runtime.Autoflush()
 	case "$$":
 		return c.runtime.PID()
 	case "$0":
@@ -300,14 +646,85 @@ func (c *Context) GetSpecialVar(name string) *sv.SV {
 		return c.runtime.PostMatch()
 	case "$+":
 		return c.runtime.LastParen()
-	case "$1", "$2", "$3", "$4", "$5", "$6", "$7", "$8", "$9":
-		n := int(name[1] - '0')
-		return c.runtime.Capture(n)
+	case "%+":
+		return c.runtime.NamedCaptures()
+	case "%-":
+		return c.runtime.AllNamedCaptures()
+	case "$~":
+		return sv.NewString(c.Format(c.selected).Name)
+	case "$^":
+		return sv.NewString(c.Format(c.selected).Top)
+	case "$=":
+		return sv.NewInt(int64(c.Format(c.selected).PageLen))
+	case "$-":
+		return sv.NewInt(int64(c.Format(c.selected).LinesLeft))
+	case "$%":
+		return sv.NewInt(int64(c.Format(c.selected).Page))
+	case "$^A":
+		return c.runtime.Accumulator()
+	case "$:":
+		return c.runtime.LineBreak()
+	case "$^L":
+		return c.runtime.FormFeed()
 	default:
+		// $1..$N
+		if n, err := strconv.Atoi(name[1:]); err == nil && n > 0 {
+			return c.runtime.Capture(n)
+		}
+		// $^V и $] - уровень Perl, который поддерживает perlc
+		if v, ok := version.PerlVars[name]; ok {
+			return sv.NewString(v)
+		}
 		return sv.NewUndef()
 	}
 }
 
+// SetSpecialVar assigns a special variable and applies its effect: $/
+// splits what readline returns, $, and $\ go into print, $" joins the
+// arrays interpolated in strings, $| is the autoflush of the selected
+// handle and $0 renames the process where the system allows it. $~, $^,
+// $=, $- and $% belong to the selected handle as well. The read-only ones
+// ($$, $1, ...) ignore the assignment.
+func (c *Context) SetSpecialVar(name string, v *sv.SV) {
+	switch name {
+	case "$/":
+		c.runtime.SetInputRS(v)
+	case "$\\":
+		c.runtime.SetOutputRS(v)
+	case "$,":
+		c.runtime.SetOutputFS(v)
+	case "$\"":
+		c.runtime.SetListSep(v)
+	case "$|":
+		if fh := c.filehandles[c.selected]; fh != nil && fh.Writer != nil {
+			fh.Autoflush = v.IsTrue()
+			fh.Flush()
+			return
+		}
+		c.runtime.SetAutoflush(v)
+	case "$0":
+		c.runtime.SetProgName(v)
+		setProcTitle(v.AsString())
+	case "$~":
+		c.Format(c.selected).Name = v.AsString()
+		c.runtime.SetFormat(v)
+	case "$^":
+		c.Format(c.selected).Top = v.AsString()
+	case "$=":
+		c.Format(c.selected).PageLen = int(v.AsInt())
+	case "$-":
+		c.Format(c.selected).LinesLeft = max(0, int(v.AsInt()))
+	case "$%":
+		c.Format(c.selected).Page = int(v.AsInt())
+	case "$^A":
+		c.runtime.SetAccumulator(v)
+	case "$:":
+		c.runtime.SetLineBreak(v)
+	case "$^L":
+		c.runtime.SetFormFeed(v)
+	}
+}
+
 // ============================================================
 // File Handle Management
 // ============================================================
@@ -316,6 +733,7 @@ func (c *Context) GetSpecialVar(name string) *sv.SV {
 func (c *Context) OpenFile(name, mode, filename string) error {
 	var file *os.File
 	var err error
+	mode, layers := SplitMode(mode)
 
 	switch mode {
 	case "<", "r":
@@ -324,6 +742,12 @@ func (c *Context) OpenFile(name, mode, filename string) error {
 		file, err = os.Create(filename)
 	case ">>", "a":
 		file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	case "+<":
+		file, err = os.OpenFile(filename, os.O_RDWR, 0644)
+	case "+>":
+		file, err = os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	case "+>>":
+		file, err = os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
 	default:
 		file, err = os.Open(filename)
 	}
@@ -332,47 +756,333 @@ func (c *Context) OpenFile(name, mode, filename string) error {
 		return err
 	}
 
-	fh := &FileHandle{File: file, Mode: mode}
-	if mode == "<" || mode == "r" {
-		fh.Scanner = bufio.NewScanner(file)
+	c.filehandles[name] = c.newFileHandle(file, mode)
+	return c.openLayers(name, layers)
+}
+
+// openLayers applies the layers of an open mode to the new handle
+func (c *Context) openLayers(name, layers string) error {
+	if layers == "" {
+		return nil
+	}
+	err := c.Binmode(name, layers)
+	if err != nil {
+		c.CloseFile(name)
+	}
+	return err
+}
+
+// OpenTempFile opens an anonymous temporary file: open($fh, '+>', undef).
+// The file is unlinked right away, so it vanishes once the handle is closed.
+func (c *Context) OpenTempFile(name, mode string) error {
+	file, err := os.CreateTemp("", "perlc-")
+	if err != nil {
+		return err
+	}
+	os.Remove(file.Name())
+
+	mode, layers := SplitMode(mode)
+	c.filehandles[name] = c.newFileHandle(file, mode)
+	return c.openLayers(name, layers)
+}
+
+// OpenPipe starts cmd for a pipe open: "-|" reads what it prints, "|-"
+// writes its input. It returns the pid; CloseFile waits for the command.
+func (c *Context) OpenPipe(name, mode string, cmd *exec.Cmd) (int, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	mine, theirs, fhMode := r, w, "<"
+	if mode == "|-" {
+		mine, theirs, fhMode = w, r, ">"
+		cmd.Stdin = r
 	} else {
-		fh.Writer = bufio.NewWriter(file)
+		cmd.Stdout = w
 	}
+	pid, err := c.StartChild(cmd)
+	// the child's end belongs to the child only
+	theirs.Close()
+	if err != nil {
+		mine.Close()
+		return 0, err
+	}
+	fh := c.newFileHandle(mine, fhMode)
+	fh.Pid = pid
+	c.filehandles[name] = fh
+	return pid, nil
+}
 
+// OpenScalar opens an in-memory handle on a string, open($fh, '<', \$buf):
+// "<" reads it, ">" empties it and ">>" appends to it. Writes show up in
+// the string right away.
+func (c *Context) OpenScalar(name, mode string, target *sv.SV) error {
+	mode, layers := SplitMode(mode)
+	fh := &FileHandle{Mode: mode}
+	switch mode {
+	case "<":
+		fh.Scanner = c.NewScanner(fh.Decoder(strings.NewReader(target.AsString())))
+	case ">", ">>":
+		if mode == ">" {
+			target.SetString("")
+		}
+		fh.Writer = bufio.NewWriter(fh.encoder(scalarWriter{target}))
+		fh.Autoflush = true
+	default:
+		return syscall.EINVAL
+	}
 	c.filehandles[name] = fh
+	return c.openLayers(name, layers)
+}
+
+// scalarWriter appends what is written to a handle to its string
+type scalarWriter struct{ target *sv.SV }
+
+func (w scalarWriter) Write(p []byte) (int, error) {
+	w.target.SetString(w.target.AsString() + string(p))
+	return len(p), nil
+}
+
+// SysOpen opens a file with raw open(2)-style flags: sysopen(FH, PATH, FLAGS, PERMS).
+// O_CREAT|O_EXCL fails if the file already exists, which is what lockfile scripts rely on.
+func (c *Context) SysOpen(name, filename string, flags int, perm os.FileMode) error {
+	file, err := os.OpenFile(filename, flags, perm)
+	if err != nil {
+		return err
+	}
+
+	mode := "<"
+	switch {
+	case flags&os.O_RDWR != 0:
+		mode = "+<"
+	case flags&os.O_WRONLY != 0:
+		mode = ">"
+	}
+	c.filehandles[name] = c.newFileHandle(file, mode)
 	return nil
 }
 
+// LockFile applies flock(2) semantics to an open handle.
+// op is a combination of LOCK_SH, LOCK_EX, LOCK_UN and LOCK_NB.
+func (c *Context) LockFile(name string, op int) error {
+	fh, ok := c.filehandles[name]
+	if !ok || fh.File == nil {
+		return os.ErrInvalid
+	}
+	fh.Flush()
+	return flockFile(fh.File, op)
+}
+
+// SetIOBuffer sets the buffer size of file handles opened from now on.
+func (c *Context) SetIOBuffer(size int) {
+	c.ioBuffer = size
+}
+
+func (c *Context) newFileHandle(file *os.File, mode string) *FileHandle {
+	fh := &FileHandle{File: file, Mode: mode}
+	switch {
+	case mode == "<" || mode == "r":
+		fh.Scanner = c.NewScanner(fh.Decoder(file))
+	case strings.HasPrefix(mode, "+"):
+		// Read-write: both directions share the file offset, so what the
+		// scanner reads ahead is given back before a write; records are
+		// read raw and decoded by ReadLine, to count bytes of the file
+		ra := &readAhead{file: file}
+		ra.scanner = func() *bufio.Scanner { return c.recordScanner(ra, &ra.used) }
+		fh.ahead = ra
+		fh.Scanner = ra.scanner()
+		fh.Writer = bufio.NewWriterSize(fh.encoder(aheadWriter{fh}), c.ioBuffer)
+	default:
+		fh.Writer = bufio.NewWriterSize(fh.encoder(file), c.ioBuffer)
+	}
+	return fh
+}
+
+// Flush writes buffered output and gives back what a read-write handle
+// read ahead, so the file offset is accurate before seek/tell/truncate/read.
+func (fh *FileHandle) Flush() {
+	fh.flushOutput()
+	fh.dropReadAhead()
+}
+
+func (fh *FileHandle) flushOutput() {
+	if fh.Writer != nil {
+		fh.Writer.Flush()
+	}
+	if fh.out != nil {
+		fh.out.finish()
+	}
+}
+
+// dropReadAhead moves the file of a read-write handle back to the end of
+// the last record read and restarts its scanner there
+func (fh *FileHandle) dropReadAhead() {
+	if ra := fh.ahead; ra != nil && ra.read > 0 {
+		if ra.read > ra.used {
+			ra.file.Seek(ra.used-ra.read, io.SeekCurrent)
+		}
+		ra.read, ra.used = 0, 0
+		fh.Scanner = ra.scanner()
+	}
+}
+
+// readAhead counts the bytes the scanner of a read-write handle read from
+// its file and the bytes of the records it handed out.
+type readAhead struct {
+	file       *os.File
+	read, used int64
+	scanner    func() *bufio.Scanner
+}
+
+func (ra *readAhead) Read(p []byte) (int, error) {
+	n, err := ra.file.Read(p)
+	ra.read += int64(n)
+	return n, err
+}
+
+// aheadWriter writes to the file of a read-write handle where the program
+// stopped reading, not where the scanner did
+type aheadWriter struct{ fh *FileHandle }
+
+func (w aheadWriter) Write(p []byte) (int, error) {
+	w.fh.dropReadAhead()
+	return w.fh.File.Write(p)
+}
+
+// FlushAll flushes every open handle. It runs before the process exits
+// (exit, die, exec, end of program) so buffered output is not lost.
+func (c *Context) FlushAll() {
+	for _, fh := range c.filehandles {
+		fh.Flush()
+	}
+}
+
+// CloseFile closes a handle. Closing a pipe waits for its command and
+// sets $?; like in perl it fails when the command did.
 func (c *Context) CloseFile(name string) error {
-	if fh, ok := c.filehandles[name]; ok {
-		if fh.Writer != nil {
-			fh.Writer.Flush()
+	fh, ok := c.filehandles[name]
+	if !ok {
+		return nil
+	}
+	fh.Flush()
+	var err error
+	if fh.File != nil {
+		err = fh.File.Close()
+	}
+	delete(c.filehandles, name)
+	if fh.Pid != 0 {
+		if _, status := c.WaitChild(fh.Pid, false); status >= 0 {
+			c.runtime.SetChildError(status)
+			if err == nil && status != 0 {
+				err = errChildStatus
+			}
 		}
-		err := fh.File.Close()
-		delete(c.filehandles, name)
-		return err
 	}
-	return nil
+	return err
 }
 
+// errChildStatus is the error of closing a pipe whose command failed
+var errChildStatus = errors.New("command exited with non-zero status")
+
+// ReadLine reads the next record of a handle, ending with $/ like in
+// perl; the last one may have no terminator.
 func (c *Context) ReadLine(name string) (string, bool) {
 	// Empty name means STDIN
 	if name == "" {
-		scanner := bufio.NewScanner(os.Stdin)
-		if scanner.Scan() {
-			return scanner.Text() + "\n", true
-		}
-		return "", false
+		name = "STDIN"
 	}
 
 	if fh, ok := c.filehandles[name]; ok && fh.Scanner != nil {
+		fh.flushOutput()
 		if fh.Scanner.Scan() {
-			return fh.Scanner.Text() + "\n", true
+			if fh.ahead != nil {
+				return fh.Decode(fh.Scanner.Bytes()), true
+			}
+			return fh.Scanner.Text(), true
 		}
 	}
 	return "", false
 }
 
+// ResetScanner restarts the scanner of a handle at the offset of its file,
+// after seek moved it.
+func (c *Context) ResetScanner(fh *FileHandle) {
+	if fh.ahead != nil {
+		fh.ahead.read, fh.ahead.used = 0, 0
+		fh.Scanner = fh.ahead.scanner()
+		return
+	}
+	fh.Scanner = c.NewScanner(fh.Decoder(fh.File))
+}
+
+// NewScanner returns a scanner of r whose tokens are the records of $/,
+// looked up at every read, so a program can change $/ between reads.
+func (c *Context) NewScanner(r io.Reader) *bufio.Scanner {
+	return c.recordScanner(r, nil)
+}
+
+// recordScanner is NewScanner adding the bytes of every record (and of
+// the empty lines skipped between paragraphs) to *used, if not nil.
+func (c *Context) recordScanner(r io.Reader, used *int64) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), math.MaxInt)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := SplitRecord(c.runtime.InputRS(), data, atEOF)
+		if used != nil {
+			*used += int64(advance)
+		}
+		return advance, token, err
+	})
+	return scanner
+}
+
+// SplitRecord is a bufio.SplitFunc for the input record separator rs
+// ($/): records keep their terminator, undef reads everything and ""
+// reads paragraphs, which end with one or more empty lines.
+func SplitRecord(rs *sv.SV, data []byte, atEOF bool) (int, []byte, error) {
+	var sep []byte
+	paragraph := false
+	switch {
+	case rs.IsUndef():
+	case rs.AsString() == "":
+		paragraph = true
+		sep = []byte("\n\n")
+	default:
+		sep = []byte(rs.AsString())
+	}
+	if paragraph {
+		// empty lines before a paragraph are skipped
+		skip := 0
+		for skip < len(data) && data[skip] == '\n' {
+			skip++
+		}
+		if skip > 0 && (skip < len(data) || atEOF) {
+			return skip, nil, nil
+		}
+	}
+	if sep != nil {
+		if i := bytes.Index(data, sep); i >= 0 {
+			end := i + len(sep)
+			if !paragraph {
+				return end, data[:end], nil
+			}
+			// the newlines after the empty line belong to no record
+			next := end
+			for next < len(data) && data[next] == '\n' {
+				next++
+			}
+			if next == len(data) && !atEOF {
+				return 0, nil, nil
+			}
+			return next, data[:end], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func (c *Context) GetFileHandle(name string) *FileHandle {
 	return c.filehandles[name]
 }
@@ -382,6 +1092,21 @@ func (c *Context) SetMatchVars(match, preMath, postMatch string, captures []stri
 	c.runtime.SetMatchVars(match, preMath, postMatch, captures)
 }
 
+// SetMatch sets the match variables, captures and their group names (%+, %-).
+func (c *Context) SetMatch(match, preMatch, postMatch string, captures []*sv.SV, names []string) {
+	c.runtime.SetMatch(match, preMatch, postMatch, captures, names)
+}
+
+// Captures returns $1..$N of the last successful match.
+func (c *Context) Captures() []*sv.SV {
+	return c.runtime.Captures()
+}
+
+// NamedCapture returns $+{name} of the last successful match.
+func (c *Context) NamedCapture(name string) *sv.SV {
+	return c.runtime.NamedCapture(name)
+}
+
 // ============================================================
 // Calling Context Management
 // ============================================================
@@ -434,3 +1159,365 @@ func (c *Context) ClearPos(varName string) {
 		delete(c.regexPos, varName)
 	}
 }
+
+// ============================================================
+// Process Management
+// ============================================================
+
+// envHash builds %ENV from the process environment.
+func envHash() *sv.SV {
+	env := sv.NewHashRef().Deref()
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexByte(kv, '='); idx > 0 {
+			env.HashData()[kv[:idx]] = sv.NewString(kv[idx+1:])
+		}
+	}
+	return env
+}
+
+// SyncEnv makes the process environment match %ENV, so that what the
+// script stores there or deletes is seen by os.Getenv and exec.LookPath
+// as well. The interpreter calls it after every write into %ENV.
+func (c *Context) SyncEnv() {
+	env := c.scopes[0]["ENV"]
+	if env == nil || !env.IsHash() {
+		return
+	}
+	data := env.HashData()
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexByte(kv, '='); idx > 0 {
+			if _, ok := data[kv[:idx]]; !ok {
+				os.Unsetenv(kv[:idx])
+			}
+		}
+	}
+	for k, v := range data {
+		if val := v.AsString(); os.Getenv(k) != val {
+			os.Setenv(k, val)
+		}
+	}
+}
+
+// SetProgram sets $0 to the script and @ARGV to its arguments.
+func (c *Context) SetProgram(script string, args []string) {
+	argv := make([]*sv.SV, len(args))
+	for i, a := range args {
+		argv[i] = sv.NewString(a)
+	}
+	c.scopes[0]["ARGV"] = sv.NewArrayRef(argv...).Deref()
+	c.runtime.SetProgName(sv.NewString(script))
+}
+
+// ============================================================
+// Module Search Path
+// ============================================================
+
+// IncHash is the name %INC is kept under: a name has one slot for all
+// its sigils in the interpreter, and "INC" is @INC.
+const IncHash = "%INC"
+
+// incArray builds @INC: the PERL5LIB directories, then lib,
+// local/lib/perl5 and the current directory, where perlc deps looks too.
+func incArray() *sv.SV {
+	var dirs []*sv.SV
+	if perl5lib := os.Getenv("PERL5LIB"); perl5lib != "" {
+		for _, dir := range filepath.SplitList(perl5lib) {
+			dirs = append(dirs, sv.NewString(dir))
+		}
+	}
+	for _, dir := range []string{"lib", "local/lib/perl5", "."} {
+		dirs = append(dirs, sv.NewString(dir))
+	}
+	return sv.NewArrayRef(dirs...).Deref()
+}
+
+// ModuleFile turns a module name into its file relative to @INC:
+// Foo::Bar is Foo/Bar.pm.
+func ModuleFile(module string) string {
+	return strings.ReplaceAll(module, "::", "/") + ".pm"
+}
+
+// UseLib puts dirs in front of @INC (use lib).
+func (c *Context) UseLib(dirs []string) {
+	inc := c.scopes[0]["INC"]
+	items := make([]*sv.SV, 0, len(dirs)+len(inc.ArrayData()))
+	for _, dir := range dirs {
+		items = append(items, sv.NewString(dir))
+	}
+	inc.SetArrayData(append(items, inc.ArrayData()...))
+}
+
+// BuiltinModules are the pragmas and modules perlc implements itself:
+// use and require only note them in %INC, nothing is loaded.
+var BuiltinModules = map[string]bool{
+	"strict":          true,
+	"warnings":        true,
+	"utf8":            true,
+	"feature":         true,
+	"lib":             true,
+	"vars":            true,
+	"integer":         true,
+	"bytes":           true,
+	"constant":        true,
+	"overload":        true,
+	"parent":          true,
+	"base":            true,
+	"mro":             true,
+	"Carp":            true,
+	"Cwd":             true,
+	"DBI":             true,
+	"Devel::Size":     true,
+	"Exporter":        true,
+	"Fcntl":           true,
+	"File::Basename":  true,
+	"File::Path":      true,
+	"File::Spec":      true,
+	"Getopt::Long":    true,
+	"Getopt::Std":     true,
+	"HTTP::Tiny":      true,
+	"IPC::Open3":      true,
+	"LWP::UserAgent":  true,
+	"List::Util":      true,
+	"POSIX":           true,
+	"Scalar::Util":    true,
+	"Storable":        true,
+	"Symbol":          true,
+	"Sys::Hostname":   true,
+	"Thread::Queue":   true,
+	"Time::HiRes":     true,
+	"Time::Piece":     true,
+	"perlc::parallel": true,
+	"threads":         true,
+}
+
+// FindInc looks file up along @INC: an absolute path or one that starts
+// with ./ or ../ is taken as it is.
+func (c *Context) FindInc(file string) (string, bool) {
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "./") || strings.HasPrefix(file, "../") {
+		info, err := os.Stat(file)
+		return file, err == nil && !info.IsDir()
+	}
+	for _, dir := range c.scopes[0]["INC"].ArrayData() {
+		candidate := filepath.Join(dir.AsString(), file)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return file, false
+}
+
+// IncDirs lists @INC as the "Can't locate" message shows it.
+func (c *Context) IncDirs() string {
+	var dirs []string
+	for _, dir := range c.scopes[0]["INC"].ArrayData() {
+		dirs = append(dirs, dir.AsString())
+	}
+	return strings.Join(dirs, " ")
+}
+
+// Required reports whether file is in %INC, loaded or being loaded.
+func (c *Context) Required(file string) bool {
+	_, ok := c.scopes[0][IncHash].HashData()[file]
+	return ok
+}
+
+// Require records in %INC that file was loaded. The value is the path
+// where file was found along @INC; a module perlc implements itself
+// has no file and keeps its own name.
+func (c *Context) Require(file string) {
+	path, _ := c.FindInc(file)
+	c.SetInc(file, path)
+}
+
+// SetInc sets $INC{file} to path; an empty path deletes the entry of a
+// file that failed to load.
+func (c *Context) SetInc(file, path string) {
+	if path == "" {
+		delete(c.scopes[0][IncHash].HashData(), file)
+		return
+	}
+	c.scopes[0][IncHash].HashData()[file] = sv.NewString(path)
+}
+
+// Environ returns the environment for child processes, taken from %ENV
+// so that changes made by the script are seen by system() and friends.
+func (c *Context) Environ() []string {
+	env := c.GetVar("ENV")
+	if !env.IsHash() {
+		return os.Environ()
+	}
+	var result []string
+	for k, v := range env.HashData() {
+		result = append(result, k+"="+v.AsString())
+	}
+	return result
+}
+
+// Select makes name the default output handle of print, say and printf
+// and returns the previous one.
+func (c *Context) Select(name string) string {
+	prev := c.selected
+	c.selected = name
+	return prev
+}
+
+// Format returns the write state of the handle name, with the defaults
+// the first time it is asked for.
+func (c *Context) Format(name string) *FormatState {
+	if c.formats == nil {
+		c.formats = make(map[string]*FormatState)
+	}
+	st := c.formats[name]
+	if st == nil {
+		st = &FormatState{Name: name, Top: name + "_TOP", PageLen: 60}
+		c.formats[name] = st
+	}
+	return st
+}
+
+// Selected returns the name of the default output handle.
+func (c *Context) Selected() string {
+	return c.selected
+}
+
+// AddFileHandle registers an already open file (e.g. a pipe end) as a handle.
+func (c *Context) AddFileHandle(name string, file *os.File, mode string) {
+	c.filehandles[name] = c.newFileHandle(file, mode)
+}
+
+// StartChild starts cmd and remembers it for a later WaitChild.
+// The child is reaped in the background; its exit raises CHLD.
+func (c *Context) StartChild(cmd *exec.Cmd) (int, error) {
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	child := &childProc{cmd: cmd, done: make(chan struct{})}
+	c.children[pid] = child
+	go func() {
+		child.status = ExitStatus(cmd.Wait())
+		// Queue CHLD first so it is pending by the time wait() returns
+		c.RaiseSignal("CHLD")
+		close(child.done)
+		select {
+		case c.childExit <- struct{}{}:
+		default:
+		}
+	}()
+	return pid, nil
+}
+
+// WaitChild implements waitpid: pid <= 0 waits for any child.
+// It returns the reaped pid and its status in $? form (exit code << 8),
+// 0 if nohang is set and no child has exited yet, or -1 if there is
+// no such child.
+func (c *Context) WaitChild(pid int, nohang bool) (int, int) {
+	for {
+		if pid > 0 {
+			child, ok := c.children[pid]
+			if !ok {
+				return -1, -1
+			}
+			if nohang {
+				select {
+				case <-child.done:
+				default:
+					return 0, 0
+				}
+			}
+			<-child.done
+			delete(c.children, pid)
+			return pid, child.status
+		}
+
+		if len(c.children) == 0 {
+			return -1, -1
+		}
+		for p, child := range c.children {
+			select {
+			case <-child.done:
+				delete(c.children, p)
+				return p, child.status
+			default:
+			}
+		}
+		if nohang {
+			return 0, 0
+		}
+		<-c.childExit
+	}
+}
+
+// RaiseSignal queues a signal; the interpreter runs its %SIG handler
+// at the next safe point (between statements), like Perl's safe signals.
+func (c *Context) RaiseSignal(name string) {
+	c.sigMu.Lock()
+	c.pendingSignals = append(c.pendingSignals, name)
+	c.sigMu.Unlock()
+	c.sigPending.Store(true)
+	select {
+	case c.sigNotify <- struct{}{}:
+	default:
+	}
+}
+
+// SignalNotify returns a channel that receives after RaiseSignal,
+// so that blocking builtins like sleep() can be interrupted.
+func (c *Context) SignalNotify() <-chan struct{} {
+	return c.sigNotify
+}
+
+// SignalsPending reports whether RaiseSignal was called since the last TakeSignals.
+func (c *Context) SignalsPending() bool {
+	return c.sigPending.Load()
+}
+
+// TakeSignals returns and clears the queued signals.
+func (c *Context) TakeSignals() []string {
+	c.sigMu.Lock()
+	defer c.sigMu.Unlock()
+	c.sigPending.Store(false)
+	select {
+	case <-c.sigNotify: // drop a stale wakeup
+	default:
+	}
+	names := c.pendingSignals
+	c.pendingSignals = nil
+	return names
+}
+
+// SetAlarm schedules ALRM after seconds (0 cancels) and returns
+// the seconds that were left on the previous alarm, like alarm().
+func (c *Context) SetAlarm(seconds float64) int {
+	c.sigMu.Lock()
+	defer c.sigMu.Unlock()
+	remaining := 0
+	if c.alarm != nil && c.alarm.Stop() {
+		remaining = int(math.Ceil(time.Until(c.alarmAt).Seconds()))
+	}
+	c.alarm = nil
+	if seconds > 0 {
+		d := time.Duration(seconds * float64(time.Second))
+		c.alarmAt = time.Now().Add(d)
+		c.alarm = time.AfterFunc(d, func() { c.RaiseSignal("ALRM") })
+	}
+	return remaining
+}
+
+// ErrNoExec is returned by ExecProcess where a program cannot replace
+// itself; exec then runs the command as a child and exits with its status.
+var ErrNoExec = errors.New("exec not supported on this platform")
+
+// ExitStatus converts the result of exec.Cmd.Wait/Run to a $? value.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return int(ws.Signal())
+		}
+		return exitErr.ExitCode() << 8
+	}
+	return -1
+}
@@ -2,8 +2,12 @@ package context
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"perlc/pkg/cv"
 	"perlc/pkg/stash"
@@ -412,6 +416,133 @@ func TestListSep(t *testing.T) {
 	}
 }
 
+// TestSetSpecialVar tests assigning the settable special variables.
+// TestSetSpecialVar, atanabilir özel değişkenleri test eder.
+func TestSetSpecialVar(t *testing.T) {
+	c := New()
+	defer func() {
+		// the runtime is shared by the tests
+		c.SetSpecialVar("$,", sv.NewString(""))
+		c.SetSpecialVar("$\\", sv.NewString(""))
+		c.SetSpecialVar("$\"", sv.NewString(" "))
+	}()
+
+	c.SetSpecialVar("$,", sv.NewString("-"))
+	c.SetSpecialVar("$\\", sv.NewString("!"))
+	c.SetSpecialVar("$\"", sv.NewString(":"))
+	c.SetSpecialVar("$|", sv.NewInt(5))
+	c.SetSpecialVar("$$", sv.NewInt(1))
+	for name, want := range map[string]string{"$,": "-", "$\\": "!", "$\"": ":", "$|": "1"} {
+		if got := c.GetSpecialVar(name).AsString(); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if c.GetSpecialVar("$$").AsInt() == 1 {
+		t.Error("$$ should be read-only")
+	}
+
+	c.SetSpecialVar("$|", sv.NewInt(0))
+	if got := c.GetSpecialVar("$|").AsString(); got != "0" {
+		t.Errorf("$| = %q after 0, want 0", got)
+	}
+}
+
+// TestSplitRecord tests reading records for each kind of $/.
+// TestSplitRecord, $/ türlerine göre kayıt okumayı test eder.
+func TestSplitRecord(t *testing.T) {
+	defer New().SetSpecialVar("$/", sv.NewString("\n"))
+	tests := []struct {
+		rs   *sv.SV
+		want []string
+	}{
+		{sv.NewString("\n"), []string{"a\n", "b\n", "\n", "\n", "c"}},
+		{sv.NewString("b"), []string{"a\nb", "\n\n\nc"}},
+		{sv.NewUndef(), []string{"a\nb\n\n\nc"}},
+		{sv.NewString(""), []string{"a\nb\n\n", "c"}},
+	}
+	for _, tt := range tests {
+		c := New()
+		c.SetSpecialVar("$/", tt.rs)
+		scanner := c.NewScanner(strings.NewReader("a\nb\n\n\nc"))
+		var got []string
+		for scanner.Scan() {
+			got = append(got, scanner.Text())
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("$/ = %q: records %q, want %q", tt.rs.AsString(), got, tt.want)
+		}
+	}
+
+	// empty lines before a paragraph belong to no record
+	c := New()
+	c.SetSpecialVar("$/", sv.NewString(""))
+	scanner := c.NewScanner(strings.NewReader("\n\nx\n\n\n\ny\n"))
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if want := []string{"x\n\n", "y\n"}; fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Errorf("paragraphs = %q", got)
+	}
+}
+
+// TestOpenScalar tests in-memory handles on a string.
+// TestOpenScalar, bir string üzerindeki bellek içi handle'ları test eder.
+func TestOpenScalar(t *testing.T) {
+	c := New()
+	buf := sv.NewString("old")
+	if err := c.OpenScalar("out", ">", buf); err != nil {
+		t.Fatal(err)
+	}
+	fh := c.GetFileHandle("out")
+	fh.Writer.WriteString("a\nb\n")
+	fh.Flush()
+	if buf.AsString() != "a\nb\n" {
+		t.Errorf("buf = %q after write", buf.AsString())
+	}
+	c.CloseFile("out")
+
+	if err := c.OpenScalar("in", "<", buf); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for {
+		line, ok := c.ReadLine("in")
+		if !ok {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if fmt.Sprintf("%q", lines) != `["a\n" "b\n"]` {
+		t.Errorf("lines = %q", lines)
+	}
+
+	if err := c.OpenScalar("rw", "+<", buf); err == nil {
+		t.Error("+< on a string should fail")
+	}
+}
+
+// TestOpenPipe tests that closing a pipe waits for its command.
+// TestOpenPipe, bir pipe kapatılınca komutun beklendiğini test eder.
+func TestOpenPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	c := New()
+	if _, err := c.OpenPipe("p", "-|", exec.Command("sh", "-c", "echo hi; exit 2")); err != nil {
+		t.Fatal(err)
+	}
+	if line, ok := c.ReadLine("p"); !ok || line != "hi\n" {
+		t.Errorf("ReadLine = %q, %v", line, ok)
+	}
+	if err := c.CloseFile("p"); err == nil {
+		t.Error("close should fail when the command does")
+	}
+	if got := c.runtime.ChildError().AsInt(); got != 2<<8 {
+		t.Errorf("$? = %d, want %d", got, 2<<8)
+	}
+}
+
 // TestProcessInfo tests uid/gid variables.
 // TestProcessInfo, uid/gid değişkenlerini test eder.
 func TestProcessInfo(t *testing.T) {
@@ -550,6 +681,46 @@ func TestEmptyCaptures(t *testing.T) {
 	}
 }
 
+// TestSetMatch tests undef groups, $+ and named captures.
+func TestSetMatch(t *testing.T) {
+	rt := NewRuntime()
+
+	captures := []*sv.SV{sv.NewString("a"), sv.NewUndef(), sv.NewString("c")}
+	for n := 4; n <= 10; n++ {
+		captures = append(captures, sv.NewString(string(rune('a'+n-1))))
+	}
+	names := make([]string, len(captures))
+	names[0], names[1], names[2] = "first", "dup", "dup"
+	rt.SetMatch("abc", "", "", captures, names)
+
+	if !rt.Capture(2).IsUndef() {
+		t.Error("$2 should be undef for a group that did not match")
+	}
+	if got := rt.Capture(10).AsString(); got != "j" {
+		t.Errorf("$10 = %q, want j", got)
+	}
+	if got := rt.LastParen().AsString(); got != "j" {
+		t.Errorf("$+ = %q, want j", got)
+	}
+	if got := rt.NamedCapture("first").AsString(); got != "a" {
+		t.Errorf("$+{first} = %q, want a", got)
+	}
+	if !rt.NamedCapture("none").IsUndef() {
+		t.Error("$+{none} should be undef")
+	}
+	// $+{dup} is the leftmost dup group that matched, %- has them all
+	if got := rt.NamedCapture("dup").AsString(); got != "c" {
+		t.Errorf("$+{dup} = %q, want c", got)
+	}
+	if got := len(rt.NamedCaptures().HashData()); got != 2 {
+		t.Errorf("%%+ has %d keys, want 2", got)
+	}
+	dup := rt.AllNamedCaptures().HashData()["dup"].Deref().ArrayData()
+	if len(dup) != 2 || !dup[0].IsUndef() || dup[1].AsString() != "c" {
+		t.Errorf("$-{dup} = %v, want (undef, c)", dup)
+	}
+}
+
 // TestMatchVarsDefault tests default match var values.
 // TestMatchVarsDefault, varsayılan eşleşme değişken değerlerini test eder.
 func TestMatchVarsDefault(t *testing.T) {
@@ -603,6 +774,14 @@ func TestOSError(t *testing.T) {
 	if rt.OSError().AsString() != "file not found" {
 		t.Error("$! should be 'file not found'")
 	}
+
+	// Path errors show only the strerror text, like Perl
+	// Yol hataları Perl gibi sadece strerror metnini gösterir
+	_, err := os.Stat("/no/such/perlc/file")
+	rt.SetOSError(err)
+	if got := rt.OSError().AsString(); runtime.GOOS != "windows" && got != "No such file or directory" {
+		t.Errorf("$! = %q, want 'No such file or directory'", got)
+	}
 }
 
 // TestChildError tests $?.
@@ -698,6 +877,24 @@ func TestTryEvalPanic(t *testing.T) {
 	}
 }
 
+// TestTryEvalObject tests that die with a reference keeps it in $@.
+// TestTryEvalObject, referansla die'ın onu $@ içinde tuttuğunu test eder.
+func TestTryEvalObject(t *testing.T) {
+	rt := NewRuntime()
+	obj := sv.NewHashRef()
+
+	success := rt.TryEval(func() {
+		panic(PerlDie{Message: obj.AsString(), Value: obj})
+	})
+
+	if success {
+		t.Error("TryEval should return false on die with an object")
+	}
+	if rt.EvalError() != obj {
+		t.Errorf("$@ should be the object, got '%s'", rt.EvalError().AsString())
+	}
+}
+
 // TestNestedEval tests nested eval blocks.
 // TestNestedEval, iç içe eval bloklarını test eder.
 func TestNestedEval(t *testing.T) {
@@ -998,3 +1195,388 @@ func TestConcurrentCallStack(t *testing.T) {
 		<-done
 	}
 }
+
+// TestPendingSignals tests the safe-signal queue.
+// TestPendingSignals, güvenli sinyal kuyruğunu test eder.
+func TestPendingSignals(t *testing.T) {
+	c := New()
+	if c.SignalsPending() {
+		t.Fatal("no signals should be pending")
+	}
+
+	done := make(chan bool)
+	go func() {
+		c.RaiseSignal("CHLD")
+		done <- true
+	}()
+	<-done
+
+	select {
+	case <-c.SignalNotify():
+	default:
+		t.Error("RaiseSignal should wake up SignalNotify")
+	}
+	if !c.SignalsPending() {
+		t.Fatal("CHLD should be pending")
+	}
+	names := c.TakeSignals()
+	if len(names) != 1 || names[0] != "CHLD" {
+		t.Errorf("TakeSignals = %v, want [CHLD]", names)
+	}
+	if c.SignalsPending() {
+		t.Error("TakeSignals should clear the queue")
+	}
+}
+
+// TestWaitChildNoChildren tests waitpid without children.
+// TestWaitChildNoChildren, çocuk süreç yokken waitpid'i test eder.
+func TestWaitChildNoChildren(t *testing.T) {
+	c := New()
+	if pid, _ := c.WaitChild(-1, false); pid != -1 {
+		t.Errorf("wait() = %d, want -1", pid)
+	}
+	if pid, _ := c.WaitChild(12345, true); pid != -1 {
+		t.Errorf("waitpid(unknown) = %d, want -1", pid)
+	}
+}
+
+// TestSetAlarm tests alarm scheduling and cancellation.
+// TestSetAlarm, alarm zamanlamasını ve iptalini test eder.
+func TestSetAlarm(t *testing.T) {
+	c := New()
+	if left := c.SetAlarm(10); left != 0 {
+		t.Errorf("first alarm returned %d, want 0", left)
+	}
+	if left := c.SetAlarm(0); left != 10 {
+		t.Errorf("cancel returned %d, want 10", left)
+	}
+
+	c.SetAlarm(0.01)
+	select {
+	case <-c.SignalNotify():
+	case <-time.After(2 * time.Second):
+		t.Fatal("ALRM was not raised")
+	}
+	names := c.TakeSignals()
+	if len(names) != 1 || names[0] != "ALRM" {
+		t.Errorf("TakeSignals = %v, want [ALRM]", names)
+	}
+}
+
+// TestCaptureScopes tests that a captured scope chain outlives PopScope and
+// shares variables with its definer.
+// TestCaptureScopes, yakalanan kapsam zincirinin PopScope'tan sonra yaşadığını test eder.
+func TestCaptureScopes(t *testing.T) {
+	c := New()
+	c.PushScope()
+	c.DeclareVar("n", sv.NewInt(1), "my")
+	env := c.CaptureScopes()
+	c.PopScope()
+
+	if !c.GetVar("n").IsUndef() {
+		t.Fatal("n should be out of scope after PopScope")
+	}
+
+	saved := c.SwapScopes(env)
+	c.PushScope()
+	if got := c.GetVar("n").AsInt(); got != 1 {
+		t.Errorf("captured n = %d, want 1", got)
+	}
+	c.SetVar("n", sv.NewInt(2))
+	c.PopScope()
+	c.SwapScopes(saved)
+
+	c.SwapScopes(env)
+	if got := c.GetVar("n").AsInt(); got != 2 {
+		t.Errorf("n after closure update = %d, want 2", got)
+	}
+	c.SwapScopes(saved)
+}
+
+// TestOurVar tests our variables: package variables of the stash seen
+// under their short name until a my of that name hides them.
+// TestOurVar, our değişkenlerini test eder.
+func TestOurVar(t *testing.T) {
+	c := New()
+	c.DeclareOur("$", "count", "Counter")
+	key, ok := c.OurVar("$", "count")
+	if !ok || key != "$Counter::count" {
+		t.Fatalf("OurVar($count) = %q, %v, want $Counter::count", key, ok)
+	}
+	if _, ok := c.OurVar("@", "count"); ok {
+		t.Error("our $count should not declare @count")
+	}
+
+	c.SetVar(key, sv.NewInt(3))
+	if got := stash.Get("Counter").Scalar("count").AsInt(); got != 3 {
+		t.Errorf("$Counter::count in the stash = %d, want 3", got)
+	}
+	c.DeclareVar("@Counter::log", sv.NewArrayRef(sv.NewString("a")).Deref(), "our")
+	if got := len(c.GetVar("@Counter::log").ArrayData()); got != 1 {
+		t.Errorf("@Counter::log has %d elements, want 1", got)
+	}
+
+	c.PushScope()
+	c.DeclareVar("count", sv.NewInt(1), "my")
+	if _, ok := c.OurVar("$", "count"); ok {
+		t.Error("my $count should hide our $count")
+	}
+	c.PopScope()
+	if _, ok := c.OurVar("$", "count"); !ok {
+		t.Error("our $count should be seen again after the block")
+	}
+}
+
+// TestStat tests the 13 stat fields built from os.FileInfo.
+// TestStat, os.FileInfo'dan oluşturulan 13 stat alanını test eder.
+func TestStat(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/f.txt"
+	if err := os.WriteFile(path, []byte("hello\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := Stat(fi)
+	if st[7] != 6 {
+		t.Errorf("size = %d, want 6", st[7])
+	}
+	if st[9] != fi.ModTime().Unix() {
+		t.Errorf("mtime = %d, want %d", st[9], fi.ModTime().Unix())
+	}
+	if runtime.GOOS != "windows" && st[2] != 0o100640 {
+		t.Errorf("mode = %o, want 100640", st[2])
+	}
+
+	dfi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := Stat(dfi)[2]; mode&0o170000 != 0o040000 {
+		t.Errorf("dir mode = %o, want S_IFDIR", mode)
+	}
+}
+
+// TestFileMode tests the S_IF* type bits and permission bits.
+// TestFileMode, S_IF* tür bitlerini ve izin bitlerini test eder.
+func TestFileMode(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		want int64
+	}{
+		{0o644, 0o100644},
+		{os.ModeDir | 0o755, 0o040755},
+		{os.ModeSymlink | 0o777, 0o120777},
+		{os.ModeNamedPipe | 0o600, 0o010600},
+		{os.ModeDevice | os.ModeCharDevice | 0o666, 0o020666},
+		{os.ModeDevice | 0o660, 0o060660},
+		{os.ModeDir | os.ModeSticky | 0o777, 0o041777},
+		{os.ModeSetuid | 0o755, 0o104755},
+	}
+	for _, tt := range tests {
+		if got := fileMode(tt.mode); got != tt.want {
+			t.Errorf("fileMode(%v) = %o, want %o", tt.mode, got, tt.want)
+		}
+	}
+}
+
+// TestSelect tests the standard handles and the selected output handle.
+// TestSelect, standart tanıtıcıları ve seçili çıkış tanıtıcısını test eder.
+func TestSelect(t *testing.T) {
+	ctx := New()
+	for _, name := range []string{"STDIN", "STDOUT", "STDERR"} {
+		if ctx.GetFileHandle(name) == nil {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+	if got := ctx.Selected(); got != "STDOUT" {
+		t.Fatalf("Selected() = %q, want STDOUT", got)
+	}
+
+	path := t.TempDir() + "/select.txt"
+	if err := ctx.OpenFile("OUT", ">", path); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.CloseFile("OUT")
+	if prev := ctx.Select("OUT"); prev != "STDOUT" {
+		t.Errorf("Select(OUT) = %q, want STDOUT", prev)
+	}
+	defer ctx.Select("STDOUT")
+
+	ctx.SetSpecialVar("$|", sv.NewInt(1))
+	if !ctx.GetFileHandle("OUT").Autoflush {
+		t.Error("$| = 1 did not set autoflush of the selected handle")
+	}
+	if got := ctx.GetSpecialVar("$|").AsInt(); got != 1 {
+		t.Errorf("$| = %d, want 1", got)
+	}
+	if prev := ctx.Select("STDOUT"); prev != "OUT" {
+		t.Errorf("Select(STDOUT) = %q, want OUT", prev)
+	}
+	if ctx.GetFileHandle("STDOUT").Autoflush {
+		t.Error("autoflush of OUT leaked to STDOUT")
+	}
+}
+
+// TestLayers tests parsing layer lists and reading and writing through them.
+// TestLayers, katman listelerinin ayrıştırılmasını ve onlarla okuma/yazmayı test eder.
+func TestLayers(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Layers
+		ok   bool
+	}{
+		{"", Layers{Bytes: true}, true},
+		{":raw", Layers{Bytes: true}, true},
+		{":utf8", Layers{}, true},
+		{":encoding(UTF-8)", Layers{}, true},
+		{":raw:crlf", Layers{Bytes: true, CRLF: true}, true},
+		{":crlf:raw", Layers{Bytes: true}, true},
+		{":encoding(KOI8-R)", Layers{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseLayers(Layers{}, tt.spec)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("ParseLayers(%q) = %+v, %v; want %+v, ok %v", tt.spec, got, err, tt.want, tt.ok)
+		}
+	}
+	if mode, layers := SplitMode("< :encoding(UTF-8)"); mode != "<" || layers != ":encoding(UTF-8)" {
+		t.Errorf("SplitMode = %q, %q", mode, layers)
+	}
+
+	// long enough to cut characters and "\r\n" at buffer boundaries
+	line := strings.Repeat("é", 3000) + "\n"
+	text := strings.Repeat(line, 20)
+	path := t.TempDir() + "/layers.txt"
+	ctx := New()
+	if err := ctx.OpenFile("OUT", ">:raw:crlf", path); err != nil {
+		t.Fatal(err)
+	}
+	fh := ctx.GetFileHandle("OUT")
+	for i := 0; i < len(text); i += 1001 {
+		fh.Writer.WriteString(text[i:min(i+1001, len(text))])
+	}
+	ctx.CloseFile("OUT")
+	data, _ := os.ReadFile(path)
+	if want := strings.Repeat(strings.Repeat("\xe9", 3000)+"\r\n", 20); string(data) != want {
+		t.Fatalf("file has %d bytes, want %d", len(data), len(want))
+	}
+
+	if err := ctx.OpenFile("IN", "<:raw:crlf", path); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.CloseFile("IN")
+	var got strings.Builder
+	for {
+		s, ok := ctx.ReadLine("IN")
+		if !ok {
+			break
+		}
+		got.WriteString(s)
+	}
+	if got.String() != text {
+		t.Errorf("read back %d bytes, want %d", got.Len(), len(text))
+	}
+
+	if err := ctx.OpenFile("BAD", "<:encoding(KOI8-R)", path); err == nil {
+		t.Error("open with an unknown layer succeeded")
+	}
+	if ctx.GetFileHandle("BAD") != nil {
+		t.Error("handle with an unknown layer left open")
+	}
+}
+
+// TestEnvSync tests that %ENV writes reach the process environment.
+// TestEnvSync, %ENV yazmalarının süreç ortamına ulaştığını test eder.
+func TestEnvSync(t *testing.T) {
+	t.Setenv("PERLC_SYNC_OLD", "old")
+	ctx := New()
+	env := ctx.GetVar("ENV").HashData()
+	env["PERLC_SYNC_NEW"] = sv.NewString("new")
+	delete(env, "PERLC_SYNC_OLD")
+	defer os.Unsetenv("PERLC_SYNC_NEW")
+	ctx.SyncEnv()
+
+	if got := os.Getenv("PERLC_SYNC_NEW"); got != "new" {
+		t.Errorf("PERLC_SYNC_NEW = %q, want new", got)
+	}
+	if _, ok := os.LookupEnv("PERLC_SYNC_OLD"); ok {
+		t.Error("PERLC_SYNC_OLD is still set after delete")
+	}
+}
+
+// TestModuleSearch tests @ARGV, use lib and the %INC entries of require.
+// TestModuleSearch, @ARGV, use lib ve require'ın %INC kayıtlarını test eder.
+func TestModuleSearch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/Foo", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/Foo/Bar.pm", []byte("1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := New()
+	ctx.SetProgram("script.pl", []string{"a", "b"})
+	if got := len(ctx.GetVar("ARGV").ArrayData()); got != 2 {
+		t.Errorf("@ARGV has %d elements, want 2", got)
+	}
+
+	ctx.UseLib([]string{dir})
+	if got := ctx.GetVar("INC").ArrayData()[0].AsString(); got != dir {
+		t.Errorf("$INC[0] = %q, want %q", got, dir)
+	}
+	ctx.Require(ModuleFile("Foo::Bar"))
+	ctx.Require(ModuleFile("strict"))
+	inc := ctx.GetVar(IncHash).HashData()
+	if got := inc["Foo/Bar.pm"].AsString(); got != dir+"/Foo/Bar.pm" {
+		t.Errorf("$INC{Foo/Bar.pm} = %q, want the file in %s", got, dir)
+	}
+	if got := inc["strict.pm"].AsString(); got != "strict.pm" {
+		t.Errorf("$INC{strict.pm} = %q, want strict.pm", got)
+	}
+
+	if _, found := ctx.FindInc(ModuleFile("No::Such")); found {
+		t.Errorf("FindInc found No/Such.pm")
+	}
+	if !ctx.Required("Foo/Bar.pm") || ctx.Required("No/Such.pm") {
+		t.Errorf("Required does not follow %%INC")
+	}
+	ctx.SetInc("Foo/Bar.pm", "")
+	if ctx.Required("Foo/Bar.pm") {
+		t.Errorf("SetInc with no path kept $INC{Foo/Bar.pm}")
+	}
+}
+
+func TestLinearISA(t *testing.T) {
+	ctx := New()
+	// a diamond: D from B and C, both from A
+	ctx.PushISA("MroB", []string{"MroA"})
+	ctx.PushISA("MroC", []string{"MroA"})
+	ctx.PushISA("MroD", []string{"MroB", "MroC"})
+
+	order, err := ctx.LinearISA("MroD")
+	if err != nil || strings.Join(order, " ") != "MroD MroB MroA MroC" {
+		t.Errorf("dfs order = %v, %v", order, err)
+	}
+	ctx.SetMRO("MroD", "c3")
+	if got := ctx.MRO("MroD"); got != "c3" {
+		t.Errorf("MRO = %q, want c3", got)
+	}
+	order, err = ctx.LinearISA("MroD")
+	if err != nil || strings.Join(order, " ") != "MroD MroB MroC MroA" {
+		t.Errorf("c3 order = %v, %v", order, err)
+	}
+
+	ctx.PushISA("MroX", []string{"MroB", "MroC"})
+	ctx.PushISA("MroY", []string{"MroC", "MroB"})
+	ctx.PushISA("MroZ", []string{"MroX", "MroY"})
+	ctx.SetMRO("MroZ", "c3")
+	if _, err := ctx.LinearISA("MroZ"); err == nil || !strings.Contains(err.Error(), "Inconsistent hierarchy") {
+		t.Errorf("inconsistent hierarchy not reported: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package context
+
+import "os/exec"
+
+// ExecProcess is not available on this platform.
+func ExecProcess(cmd *exec.Cmd) error {
+	return ErrNoExec
+}
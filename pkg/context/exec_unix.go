@@ -0,0 +1,17 @@
+//go:build unix
+
+package context
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ExecProcess replaces the running program with cmd, as execve(2) does.
+// It returns only on failure.
+func ExecProcess(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	return syscall.Exec(cmd.Path, cmd.Args, cmd.Env)
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package context
+
+import (
+	"os"
+	"syscall"
+)
+
+// flockFile locks or unlocks f; LOCK_* values match flock(2).
+func flockFile(f *os.File, op int) error {
+	return syscall.Flock(int(f.Fd()), op)
+}
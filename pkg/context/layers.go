@@ -0,0 +1,204 @@
+package context
+
+import (
+	"io"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// Layers are the PerlIO layers of a handle: how the characters of a perl
+// string map to the bytes of the file. Strings are kept as UTF-8, so the
+// default, :utf8 and :encoding(UTF-8) pass them through as they are.
+type Layers struct {
+	// Bytes reads every byte as one character and writes characters
+	// below 256 as one byte (:raw, :bytes, :encoding(latin1))
+	Bytes bool
+	// CRLF reads "\r\n" as "\n" and writes "\n" as "\r\n" (:crlf)
+	CRLF bool
+}
+
+// ParseLayers applies a layer list like ":raw:crlf" or ":encoding(UTF-8)"
+// to l. :raw also drops :crlf; an empty list is :raw, as for binmode(FH).
+func ParseLayers(l Layers, spec string) (Layers, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = ":raw"
+	}
+	for _, layer := range strings.Split(spec, ":") {
+		layer = strings.TrimSpace(layer)
+		switch strings.ToLower(layer) {
+		case "", "unix", "perlio", "stdio":
+		case "raw":
+			l = Layers{Bytes: true}
+		case "bytes", "encoding(latin1)", "encoding(latin-1)", "encoding(iso-8859-1)":
+			l.Bytes = true
+		case "utf8", "encoding(utf8)", "encoding(utf-8)", "encoding(utf-8-strict)":
+			l.Bytes = false
+		case "crlf":
+			l.CRLF = true
+		default:
+			return l, syscall.EINVAL
+		}
+	}
+	return l, nil
+}
+
+// SplitMode splits an open mode like "<:encoding(UTF-8)" into the mode
+// and its layers.
+func SplitMode(mode string) (string, string) {
+	if i := strings.IndexByte(mode, ':'); i >= 0 {
+		return strings.TrimSpace(mode[:i]), mode[i:]
+	}
+	return mode, ""
+}
+
+// Decoder wraps the reader of the handle so what is read goes through its
+// layers; they are looked up at every read, so binmode applies at once.
+func (fh *FileHandle) Decoder(r io.Reader) io.Reader {
+	return &layerReader{r: r, fh: fh}
+}
+
+// Decode turns bytes read from the file of the handle, past its scanner
+// (read), into a string by its layers.
+func (fh *FileHandle) Decode(p []byte) string {
+	if !fh.Layers.Bytes && !fh.Layers.CRLF {
+		return string(p)
+	}
+	out := decodeLayers(fh.Layers, p, nil)
+	return string(out)
+}
+
+// encoder wraps the writer of the handle so what is written goes through
+// its layers
+func (fh *FileHandle) encoder(w io.Writer) io.Writer {
+	fh.out = &layerWriter{w: w, fh: fh}
+	return fh.out
+}
+
+// decodeLayers appends p, read from a file, to out as a string in
+// memory: each byte a character under Bytes, "\r\n" as "\n" under CRLF
+func decodeLayers(l Layers, p, out []byte) []byte {
+	for i, b := range p {
+		if l.CRLF && b == '\r' && i+1 < len(p) && p[i+1] == '\n' {
+			continue
+		}
+		if l.Bytes && b >= utf8.RuneSelf {
+			out = utf8.AppendRune(out, rune(b))
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+type layerReader struct {
+	r       io.Reader
+	fh      *FileHandle
+	pending []byte // decoded but not yet read
+	cr      bool   // a "\r" ended the last chunk
+	buf     []byte
+}
+
+func (lr *layerReader) Read(p []byte) (int, error) {
+	l := lr.fh.Layers
+	if !l.Bytes && !l.CRLF && len(lr.pending) == 0 && !lr.cr {
+		return lr.r.Read(p)
+	}
+	for len(lr.pending) == 0 {
+		if cap(lr.buf) < len(p) {
+			lr.buf = make([]byte, len(p))
+		}
+		chunk := lr.buf[:len(p)]
+		n, err := lr.r.Read(chunk)
+		chunk = chunk[:n]
+		if lr.cr {
+			// the "\r" held back from the last chunk
+			chunk = append([]byte{'\r'}, chunk...)
+			lr.cr = false
+		}
+		if l.CRLF && len(chunk) > 0 && chunk[len(chunk)-1] == '\r' && err == nil {
+			chunk, lr.cr = chunk[:len(chunk)-1], true
+		}
+		lr.pending = decodeLayers(l, chunk, lr.pending)
+		if err != nil {
+			if len(lr.pending) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	n := copy(p, lr.pending)
+	lr.pending = lr.pending[n:]
+	return n, nil
+}
+
+type layerWriter struct {
+	w     io.Writer
+	fh    *FileHandle
+	carry []byte // the start of a character cut off by the buffer
+}
+
+func (lw *layerWriter) Write(p []byte) (int, error) {
+	l := lw.fh.Layers
+	if !l.Bytes && !l.CRLF && len(lw.carry) == 0 {
+		return lw.w.Write(p)
+	}
+	data := p
+	if len(lw.carry) > 0 {
+		data = append(lw.carry, p...)
+		lw.carry = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf || !l.Bytes {
+			if b == '\n' && l.CRLF {
+				out = append(out, '\r')
+			}
+			out = append(out, b)
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			lw.carry = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r < 0x100 && size > 1 {
+			out = append(out, byte(r))
+		} else {
+			// wide characters stay UTF-8, bytes that are no character as they are
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	if _, err := lw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish writes a cut off character left at the end of the output as it is
+func (lw *layerWriter) finish() {
+	if len(lw.carry) > 0 {
+		lw.w.Write(lw.carry)
+		lw.carry = nil
+	}
+}
+
+// Binmode sets the layers of handle name: binmode(FH, ":raw") and
+// friends. Output buffered so far is written with the old layers.
+func (c *Context) Binmode(name, spec string) error {
+	fh, ok := c.filehandles[name]
+	if !ok {
+		return syscall.EBADF
+	}
+	layers, err := ParseLayers(fh.Layers, spec)
+	if err != nil {
+		return err
+	}
+	fh.Flush()
+	fh.Layers = layers
+	return nil
+}
@@ -6,9 +6,13 @@
 package context
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 	"sync"
+	"syscall"
 
 	"perlc/pkg/cv"
 	"perlc/pkg/stash"
@@ -96,6 +100,7 @@ type SpecialVars struct {
 	outputRS   *sv.SV // $\ (output record separator)
 	outputFS   *sv.SV // $, (output field separator)
 	listSep    *sv.SV // $" (list separator)
+	autoflush  *sv.SV // $| (autoflush of the selected handle)
 
 	// Regex match results
 	// Regex eşleşme sonuçları
@@ -104,6 +109,7 @@ type SpecialVars struct {
 	postMatch *sv.SV   // $' (after match)
 	lastParen *sv.SV   // $+ (last bracket)
 	captures  []*sv.SV // $1, $2, $3... (capture groups)
+	names     []string // group names of $1..$N for %+ and %-
 
 	// Process info
 	// Süreç bilgisi
@@ -119,6 +125,8 @@ type SpecialVars struct {
 	subsep      *sv.SV // $; (subscript separator)
 	format      *sv.SV // $~ (format name)
 	accumulator *sv.SV // $^A (format accumulator)
+	lineBreak   *sv.SV // $: (where ^ fields of a format may break)
+	formFeed    *sv.SV // $^L (output by write before a new page)
 }
 
 // Hints holds pragma/hints state.
@@ -225,6 +233,7 @@ func newSpecialVars() *SpecialVars {
 		outputRS:   sv.NewString(""),
 		outputFS:   sv.NewString(""),
 		listSep:    sv.NewString(" "),
+		autoflush:  sv.NewInt(0),
 		subsep:     sv.NewString("\034"),
 		pid:        sv.NewInt(int64(os.Getpid())),
 		progName:   sv.NewString(os.Args[0]),
@@ -503,6 +512,14 @@ func (rt *Runtime) OutputFS() *sv.SV {
 	return rt.specials.outputFS
 }
 
+// SetOutputFS sets $,.
+// SetOutputFS, $, ayarlar.
+func (rt *Runtime) SetOutputFS(v *sv.SV) {
+	rt.specials.mu.Lock()
+	defer rt.specials.mu.Unlock()
+	rt.specials.outputFS = v
+}
+
 // ListSep returns $" (list separator for interpolation).
 // ListSep, $" (interpolasyon için liste ayırıcı) döndürür.
 func (rt *Runtime) ListSep() *sv.SV {
@@ -511,6 +528,34 @@ func (rt *Runtime) ListSep() *sv.SV {
 	return rt.specials.listSep
 }
 
+// SetListSep sets $".
+// SetListSep, $" ayarlar.
+func (rt *Runtime) SetListSep(v *sv.SV) {
+	rt.specials.mu.Lock()
+	defer rt.specials.mu.Unlock()
+	rt.specials.listSep = v
+}
+
+// Autoflush returns $| (1 when the selected handle flushes every print).
+// Autoflush, $| döndürür.
+func (rt *Runtime) Autoflush() *sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	return rt.specials.autoflush
+}
+
+// SetAutoflush sets $|; like in perl any true value reads back as 1.
+// SetAutoflush, $| ayarlar.
+func (rt *Runtime) SetAutoflush(v *sv.SV) {
+	rt.specials.mu.Lock()
+	defer rt.specials.mu.Unlock()
+	if v.IsTrue() {
+		rt.specials.autoflush = sv.NewInt(1)
+	} else {
+		rt.specials.autoflush = sv.NewInt(0)
+	}
+}
+
 // PID returns $$.
 // PID, $$ döndürür.
 func (rt *Runtime) PID() *sv.SV {
@@ -541,23 +586,114 @@ func (rt *Runtime) SetProgName(v *sv.SV) {
 // SetMatchVars sets regex match result variables.
 // SetMatchVars, regex eşleşme sonuç değişkenlerini ayarlar.
 func (rt *Runtime) SetMatchVars(match, preMath, postMatch string, captures []string) {
+	groups := make([]*sv.SV, len(captures))
+	for i, c := range captures {
+		groups[i] = sv.NewString(c)
+	}
+	rt.SetMatch(match, preMath, postMatch, groups, nil)
+}
+
+// SetMatch sets the match variables of a successful match: captures are
+// $1..$N (undef for a group that did not take part), names are their group
+// names ("" for an unnamed group) for %+ and %-.
+// SetMatch, başarılı bir eşleşmenin değişkenlerini ayarlar.
+func (rt *Runtime) SetMatch(match, preMath, postMatch string, captures []*sv.SV, names []string) {
 	rt.specials.mu.Lock()
 	defer rt.specials.mu.Unlock()
 
 	rt.specials.match = sv.NewString(match)
 	rt.specials.preMath = sv.NewString(preMath)
 	rt.specials.postMatch = sv.NewString(postMatch)
+	rt.specials.captures = captures
+	rt.specials.names = names
+
+	// $+ is the highest group that matched
+	rt.specials.lastParen = sv.NewUndef()
+	for i := len(captures) - 1; i >= 0; i-- {
+		if !captures[i].IsUndef() {
+			rt.specials.lastParen = captures[i]
+			break
+		}
+	}
+}
 
-	rt.specials.captures = make([]*sv.SV, len(captures))
-	for i, c := range captures {
-		rt.specials.captures[i] = sv.NewString(c)
+// Captures returns $1..$N of the last successful match.
+// Captures, son başarılı eşleşmenin $1..$N değerlerini döndürür.
+func (rt *Runtime) Captures() []*sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	return append([]*sv.SV(nil), rt.specials.captures...)
+}
+
+// NamedCapture returns $+{name}: the leftmost group of that name that took
+// part in the match.
+// NamedCapture, $+{name} döndürür.
+func (rt *Runtime) NamedCapture(name string) *sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	for i, n := range rt.specials.names {
+		if n == name && i < len(rt.specials.captures) && !rt.specials.captures[i].IsUndef() {
+			return rt.specials.captures[i]
+		}
 	}
+	return sv.NewUndef()
+}
 
-	if len(captures) > 0 {
-		rt.specials.lastParen = sv.NewString(captures[len(captures)-1])
-	} else {
-		rt.specials.lastParen = sv.NewUndef()
+// NamedCaptures returns %+: every name with a group that took part in the
+// match, mapped to $+{name}.
+// NamedCaptures, %+ döndürür.
+func (rt *Runtime) NamedCaptures() *sv.SV {
+	hash := sv.NewHashRef().Deref()
+	for _, name := range rt.groupNames() {
+		if v := rt.NamedCapture(name); !v.IsUndef() {
+			hash.HashData()[name] = sv.NewString(v.AsString())
+		}
+	}
+	return hash
+}
+
+// AllNamedCaptures returns %-: every group name of the last match mapped
+// to an array of all groups of that name, undef for those that did not
+// take part.
+// AllNamedCaptures, %- döndürür.
+func (rt *Runtime) AllNamedCaptures() *sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	hash := sv.NewHashRef().Deref()
+	groups := map[string][]*sv.SV{}
+	var order []string
+	for i, name := range rt.specials.names {
+		if name == "" || i >= len(rt.specials.captures) {
+			continue
+		}
+		if _, ok := groups[name]; !ok {
+			order = append(order, name)
+		}
+		c := sv.NewUndef()
+		if v := rt.specials.captures[i]; !v.IsUndef() {
+			c = sv.NewString(v.AsString())
+		}
+		groups[name] = append(groups[name], c)
+	}
+	for _, name := range order {
+		hash.HashData()[name] = sv.NewArrayRef(groups[name]...)
 	}
+	return hash
+}
+
+// groupNames returns the distinct group names of the last match
+func (rt *Runtime) groupNames() []string {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range rt.specials.names {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 // Match returns $& (entire match).
@@ -660,12 +796,31 @@ func (rt *Runtime) SetOSError(err error) {
 	rt.mu.Lock()
 	defer rt.mu.Unlock()
 	if err != nil {
-		rt.osError = sv.NewString(err.Error())
+		rt.osError = sv.NewString(osErrorText(err))
 	} else {
 		rt.osError = sv.NewString("")
 	}
 }
 
+// osErrorText returns the strerror-style text Perl shows in $!,
+// e.g. "No such file or directory" instead of "open x: no such file or directory".
+// osErrorText, Perl'ün $! içinde gösterdiği strerror tarzı metni döndürür.
+func osErrorText(err error) string {
+	if errors.Is(err, exec.ErrNotFound) {
+		// a command missing from $PATH, as exec(2) reports it
+		err = syscall.ENOENT
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		msg := errno.Error()
+		if msg == "" {
+			return msg
+		}
+		return strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	return err.Error()
+}
+
 // ChildError returns $?.
 // ChildError, $? döndürür.
 func (rt *Runtime) ChildError() *sv.SV {
@@ -730,6 +885,9 @@ func (rt *Runtime) Warn(msg string) {
 // PerlDie, die() için panic türüdür.
 type PerlDie struct {
 	Message string
+	// Value is the object for die $ref; $@ gets it instead of Message.
+	// Value, die $ref için nesnedir; $@ Message yerine onu alır.
+	Value *sv.SV
 }
 
 func (e PerlDie) Error() string {
@@ -779,7 +937,9 @@ func (rt *Runtime) TryEval(fn func()) bool {
 
 	defer func() {
 		if r := recover(); r != nil {
-			if die, ok := r.(PerlDie); ok {
+			if die, ok := r.(PerlDie); ok && die.Value != nil {
+				rt.SetEvalError(die.Value)
+			} else if ok {
 				rt.SetEvalError(sv.NewString(die.Message))
 			} else {
 				rt.SetEvalError(sv.NewString(fmt.Sprintf("%v", r)))
@@ -993,3 +1153,41 @@ func (rt *Runtime) SetAccumulator(v *sv.SV) {
 	defer rt.specials.mu.Unlock()
 	rt.specials.accumulator = v
 }
+
+// LineBreak returns $: (the characters a ^ field may break a line after).
+// LineBreak, $: (bir ^ alanının satırı bölebileceği karakterler) döndürür.
+func (rt *Runtime) LineBreak() *sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	if rt.specials.lineBreak == nil {
+		return sv.NewString(" \n-")
+	}
+	return rt.specials.lineBreak
+}
+
+// SetLineBreak sets $:.
+// SetLineBreak, $: ayarlar.
+func (rt *Runtime) SetLineBreak(v *sv.SV) {
+	rt.specials.mu.Lock()
+	defer rt.specials.mu.Unlock()
+	rt.specials.lineBreak = v
+}
+
+// FormFeed returns $^L (what write outputs before the top of a new page).
+// FormFeed, $^L (write'ın yeni sayfa başından önce yazdığı) döndürür.
+func (rt *Runtime) FormFeed() *sv.SV {
+	rt.specials.mu.RLock()
+	defer rt.specials.mu.RUnlock()
+	if rt.specials.formFeed == nil {
+		return sv.NewString("\f")
+	}
+	return rt.specials.formFeed
+}
+
+// SetFormFeed sets $^L.
+// SetFormFeed, $^L ayarlar.
+func (rt *Runtime) SetFormFeed(v *sv.SV) {
+	rt.specials.mu.Lock()
+	defer rt.specials.mu.Unlock()
+	rt.specials.formFeed = v
+}
@@ -0,0 +1,45 @@
+package context
+
+import "os"
+
+// Stat is the 13-element list of perl's stat and lstat: dev, ino, mode,
+// nlink, uid, gid, rdev, size, atime, mtime, ctime, blksize, blocks.
+// Fields the system does not keep (blksize and blocks on Windows) are -1,
+// which perl shows as "".
+func Stat(fi os.FileInfo) [13]int64 {
+	st := [13]int64{2: fileMode(fi.Mode()), 3: 1, 7: fi.Size(), 11: -1, 12: -1}
+	st[8], st[9], st[10] = fi.ModTime().Unix(), fi.ModTime().Unix(), fi.ModTime().Unix()
+	sysStat(fi, &st)
+	return st
+}
+
+// fileMode turns a Go FileMode into st_mode: S_IF* type bits and permissions
+func fileMode(m os.FileMode) int64 {
+	mode := int64(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	switch {
+	case m&os.ModeDir != 0:
+		mode |= 0o040000
+	case m&os.ModeSymlink != 0:
+		mode |= 0o120000
+	case m&os.ModeNamedPipe != 0:
+		mode |= 0o010000
+	case m&os.ModeSocket != 0:
+		mode |= 0o140000
+	case m&os.ModeCharDevice != 0:
+		mode |= 0o020000
+	case m&os.ModeDevice != 0:
+		mode |= 0o060000
+	default:
+		mode |= 0o100000
+	}
+	return mode
+}
@@ -0,0 +1,222 @@
+package deps
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"perlc/pkg/context"
+	"perlc/pkg/doctest"
+)
+
+// Status - можно ли удовлетворить зависимость под perlc
+type Status int
+
+const (
+	Missing Status = iota // нет ни файла, ни встроенной реализации
+	Builtin               // прагма или модуль, реализованный в perlc
+	Local                 // .pm/.pl файл рядом со скриптом, в lib или PERL5LIB
+)
+
+func (s Status) String() string {
+	switch s {
+	case Builtin:
+		return "built-in"
+	case Local:
+		return "local"
+	}
+	return "missing"
+}
+
+// Dep - узел дерева зависимостей
+type Dep struct {
+	Module string // имя модуля или путь для require "file.pl"
+	Status Status
+	Path   string // файл, если Status == Local
+	Deps   []*Dep
+	Seen   bool // модуль уже разобран выше (или цикл): зависимости не повторяются
+}
+
+var (
+	useRe     = regexp.MustCompile(`^\s*(?:use|no|require)\s+([A-Za-z_][\w:]*|v?\d[\d._]*|"[^"]+"|'[^']+')\s*(.*)`)
+	wordRe    = regexp.MustCompile(`[\w:./-]+`)
+	quotedArg = regexp.MustCompile(`^(?:qw\s*[(\[{/]\s*([^)\]}/]*)|"([^"]*)"|'([^']*)')`)
+)
+
+// Resolve строит дерево зависимостей скрипта, рекурсивно проходя
+// по найденным локальным .pm файлам
+func Resolve(script string) (*Dep, error) {
+	root := &Dep{Module: script, Status: Local, Path: script}
+	r := &resolver{
+		paths: searchPaths(filepath.Dir(script)),
+		seen:  map[string]bool{},
+	}
+	if err := r.scan(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type resolver struct {
+	paths []string
+	seen  map[string]bool
+}
+
+func (r *resolver) scan(node *Dep) error {
+	data, err := os.ReadFile(node.Path)
+	if err != nil {
+		return err
+	}
+	r.seen[node.Path] = true
+
+	for _, line := range strings.Split(doctest.Strip(string(data)), "\n") {
+		m := useRe.FindStringSubmatch(line)
+		if m == nil || isVersion(m[1]) {
+			continue
+		}
+		name, args := m[1], m[2]
+		node.Deps = append(node.Deps, r.resolve(name, filepath.Dir(node.Path)))
+
+		switch name {
+		case "lib":
+			// use lib 'dir' - каталоги поиска для следующих модулей;
+			// относительный путь ищется и от текущего каталога, и от файла
+			for _, dir := range importList(args) {
+				r.paths = append([]string{dir, filepath.Join(filepath.Dir(node.Path), dir)}, r.paths...)
+			}
+		case "parent", "base":
+			// use parent 'Foo' загружает Foo; с -norequire класс объявлен в том же файле
+			if strings.Contains(args, "-norequire") {
+				continue
+			}
+			for _, parent := range importList(args) {
+				node.Deps = append(node.Deps, r.resolve(parent, filepath.Dir(node.Path)))
+			}
+		}
+	}
+	return nil
+}
+
+// resolve определяет статус одной зависимости и разбирает найденный файл
+func (r *resolver) resolve(name, dir string) *Dep {
+	dep := &Dep{Module: name}
+	if unquoted := strings.Trim(name, `"'`); unquoted != name {
+		// require "file.pl" - путь относительно скрипта
+		dep.Module = unquoted
+		for _, base := range []string{dir, "."} {
+			if path := filepath.Join(base, unquoted); fileExists(path) {
+				dep.Status, dep.Path = Local, path
+				break
+			}
+		}
+	} else if context.BuiltinModules[name] {
+		dep.Status = Builtin
+		return dep
+	} else {
+		relPath := strings.ReplaceAll(name, "::", "/") + ".pm"
+		for _, base := range r.paths {
+			if path := filepath.Join(base, relPath); fileExists(path) {
+				dep.Status, dep.Path = Local, path
+				break
+			}
+		}
+	}
+
+	if dep.Status != Local {
+		return dep
+	}
+	if r.seen[dep.Path] {
+		dep.Seen = true
+		return dep
+	}
+	if err := r.scan(dep); err != nil {
+		dep.Status = Missing
+	}
+	return dep
+}
+
+// searchPaths - каталог скрипта и его lib, затем те же пути, что и в findLocal
+func searchPaths(scriptDir string) []string {
+	paths := []string{scriptDir, filepath.Join(scriptDir, "lib"), ".", "lib", "local/lib/perl5"}
+	if perl5lib := os.Getenv("PERL5LIB"); perl5lib != "" {
+		paths = append(paths, filepath.SplitList(perl5lib)...)
+	}
+	return paths
+}
+
+// importList разбирает литеральный список после имени модуля:
+// 'Foo', "Foo", qw(Foo Bar), ('a', 'b')
+func importList(args string) []string {
+	var out []string
+	args = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args), "("))
+	for args != "" {
+		m := quotedArg.FindStringSubmatch(args)
+		if m == nil {
+			break
+		}
+		out = append(out, wordRe.FindAllString(m[1]+m[2]+m[3], -1)...)
+		args = strings.TrimLeft(args[len(m[0]):], ")]}/ \t,")
+	}
+	return out
+}
+
+func isVersion(name string) bool {
+	return name[0] >= '0' && name[0] <= '9' || name[0] == 'v' && len(name) > 1 && name[1] >= '0' && name[1] <= '9'
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// Print выводит дерево зависимостей
+func (d *Dep) Print(w io.Writer) {
+	fmt.Fprintln(w, d.Module)
+	printDeps(w, d.Deps, "")
+}
+
+func printDeps(w io.Writer, deps []*Dep, prefix string) {
+	for n, dep := range deps {
+		branch, next := "├── ", "│   "
+		if n == len(deps)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s %s\n", prefix, branch, dep.Module, dep.describe())
+		printDeps(w, dep.Deps, prefix+next)
+	}
+}
+
+func (d *Dep) describe() string {
+	switch {
+	case d.Status == Local && d.Seen:
+		return "(local: " + d.Path + ", see above)"
+	case d.Status == Local:
+		return "(local: " + d.Path + ")"
+	}
+	return "(" + d.Status.String() + ")"
+}
+
+// Summary возвращает уникальные зависимости дерева (без корня) по статусам
+func (d *Dep) Summary() map[Status][]string {
+	seen := map[string]bool{}
+	out := map[Status][]string{}
+	var walk func(deps []*Dep)
+	walk = func(deps []*Dep) {
+		for _, dep := range deps {
+			if !seen[dep.Module] {
+				seen[dep.Module] = true
+				out[dep.Status] = append(out[dep.Status], dep.Module)
+			}
+			walk(dep.Deps)
+		}
+	}
+	walk(d.Deps)
+	for _, names := range out {
+		sort.Strings(names)
+	}
+	return out
+}
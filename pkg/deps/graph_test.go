@@ -0,0 +1,86 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.pl": `use strict;
+use 5.010;
+use lib 'vendor';
+use My::Util qw(helper);
+use JSON::XS;
+require "helpers.pl";
+
+=head1 SYNOPSIS
+
+  use Only::In::Pod;
+
+=cut
+`,
+		"lib/My/Util.pm":    "package My::Util;\nuse parent 'My::Base';\nuse POSIX qw(floor);\n1;\n",
+		"vendor/My/Base.pm": "package My::Base;\nrequire My::Util;\n1;\n",
+		"helpers.pl":        "1;\n",
+	})
+
+	root, err := Resolve(filepath.Join(dir, "app.pl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, dep := range root.Deps {
+		got = append(got, dep.Module+" "+dep.Status.String())
+	}
+	want := "strict built-in, lib built-in, My::Util local, JSON::XS missing, helpers.pl local"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("got %s\nwant %s", strings.Join(got, ", "), want)
+	}
+
+	util := root.Deps[2]
+	if len(util.Deps) != 3 || util.Deps[1].Module != "My::Base" || util.Deps[1].Status != Local {
+		t.Fatalf("parent class not resolved through use lib: %+v", util.Deps)
+	}
+	cycle := util.Deps[1].Deps[0]
+	if cycle.Module != "My::Util" || !cycle.Seen || len(cycle.Deps) != 0 {
+		t.Errorf("cycle back to My::Util not cut: %+v", cycle)
+	}
+
+	summary := root.Summary()
+	if m := summary[Missing]; len(m) != 1 || m[0] != "JSON::XS" {
+		t.Errorf("missing = %v", m)
+	}
+	if len(summary[Local]) != 3 || len(summary[Builtin]) != 4 {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	root := &Dep{Module: "app.pl", Deps: []*Dep{
+		{Module: "A", Status: Local, Path: "lib/A.pm", Deps: []*Dep{{Module: "strict", Status: Builtin}}},
+		{Module: "B", Status: Missing},
+	}}
+	var sb strings.Builder
+	root.Print(&sb)
+	want := "app.pl\n├── A (local: lib/A.pm)\n│   └── strict (built-in)\n└── B (missing)\n"
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,177 @@
+// Package doctest extracts the code examples from the POD of a Perl file
+// (the verbatim blocks under =head1 SYNOPSIS, EXAMPLES and similar
+// headings) so they can be run as tests against the file's own code.
+//
+// An example passes when it runs without dying. Lines may state the
+// output they expect with a "# =>" comment; the expected lines of an
+// example are compared with everything it prints:
+//
+//	my $c = Counter->new;
+//	$c->inc; $c->inc; $c->inc;
+//	say $c->value;    # => 3
+package doctest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Example is one code block found in the POD.
+type Example struct {
+	Section  string   // heading the block appears under
+	Line     int      // line of the first code line in the file
+	Code     string   // the code, with the POD indentation removed
+	Expected []string // output lines given by "# =>" comments
+}
+
+// Name describes the example in test reports: "SYNOPSIS (line 12)".
+func (ex Example) Name() string {
+	return fmt.Sprintf("%s (line %d)", ex.Section, ex.Line)
+}
+
+var expectedRe = regexp.MustCompile(`#\s*=>\s?(.*)$`)
+
+// Extract returns the examples of src in the order they appear.
+// Consecutive verbatim paragraphs form one example; a text paragraph or
+// a POD command between them starts a new one.
+func Extract(src string) []Example {
+	var examples []Example
+	var block []string
+	start := 0
+	section := ""
+	inPod := false
+
+	flush := func() {
+		for len(block) > 0 && strings.TrimSpace(block[len(block)-1]) == "" {
+			block = block[:len(block)-1]
+		}
+		if len(block) > 0 && isExampleSection(section) {
+			code := dedent(block)
+			examples = append(examples, Example{
+				Section:  section,
+				Line:     start,
+				Code:     code,
+				Expected: expected(code),
+			})
+		}
+		block = nil
+	}
+
+	for n, line := range strings.Split(src, "\n") {
+		if isCommand(line) {
+			inPod = true
+			flush()
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch {
+			case cmd == "=cut":
+				inPod = false
+			case strings.HasPrefix(cmd, "=head"):
+				section = strings.TrimSpace(arg)
+			}
+			continue
+		}
+		if !inPod {
+			continue
+		}
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(block) > 0 {
+				block = append(block, "")
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if len(block) == 0 {
+				start = n + 1
+			}
+			block = append(block, line)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return examples
+}
+
+// Strip returns the code of src without POD and without anything after
+// __END__ or __DATA__. POD lines become empty lines, so line numbers in
+// error messages still match the file.
+func Strip(src string) string {
+	lines := strings.Split(src, "\n")
+	inPod := false
+	for n, line := range lines {
+		if isCommand(line) {
+			inPod = !strings.HasPrefix(line, "=cut")
+			lines[n] = ""
+			continue
+		}
+		if inPod {
+			lines[n] = ""
+			continue
+		}
+		if t := strings.TrimSpace(line); t == "__END__" || t == "__DATA__" {
+			lines = lines[:n]
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Program returns the source that runs ex: the code of the file it was
+// taken from followed by the example in package main.
+func Program(src string, ex Example) string {
+	return Strip(src) + "\npackage main;\n" + ex.Code + "\n"
+}
+
+// Check compares the output of an example with its "# =>" lines.
+func Check(ex Example, output string) error {
+	if len(ex.Expected) == 0 {
+		return nil
+	}
+	got := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if strings.Join(got, "\n") != strings.Join(ex.Expected, "\n") {
+		return fmt.Errorf("expected output %q, got %q", ex.Expected, got)
+	}
+	return nil
+}
+
+// isCommand reports whether line is a POD command paragraph: =head1, =cut...
+func isCommand(line string) bool {
+	return len(line) > 1 && line[0] == '=' &&
+		(line[1] >= 'a' && line[1] <= 'z' || line[1] >= 'A' && line[1] <= 'Z')
+}
+
+func isExampleSection(section string) bool {
+	s := strings.ToUpper(section)
+	return strings.HasPrefix(s, "SYNOPSIS") || strings.Contains(s, "EXAMPLE")
+}
+
+// dedent removes the indentation shared by all non-blank lines
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	out := make([]string, len(lines))
+	for n, line := range lines {
+		if len(line) >= indent {
+			out[n] = line[indent:]
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func expected(code string) []string {
+	var out []string
+	for _, line := range strings.Split(code, "\n") {
+		if m := expectedRe.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package doctest
+
+import (
+	"strings"
+	"testing"
+)
+
+const module = `package Counter;
+
+=head1 NAME
+
+Counter - a simple counter
+
+    not an example
+
+=head1 SYNOPSIS
+
+    use Counter;
+
+    my $c = Counter->new;
+    say $c->value;    # => 0
+
+=cut
+
+sub new { return bless({ n => 0 }, shift); }
+sub value { return $_[0]->{n}; }
+
+1;
+__END__
+
+=head1 EXAMPLES
+
+Two separate blocks:
+
+	my $c = Counter->new;
+
+More text.
+
+  say "no expectations";
+`
+
+func TestExtract(t *testing.T) {
+	examples := Extract(module)
+	if len(examples) != 3 {
+		t.Fatalf("expected 3 examples, got %d: %+v", len(examples), examples)
+	}
+
+	synopsis := examples[0]
+	if synopsis.Section != "SYNOPSIS" || synopsis.Line != 11 {
+		t.Errorf("wrong example: %s", synopsis.Name())
+	}
+	if want := "use Counter;\n\nmy $c = Counter->new;\nsay $c->value;    # => 0"; synopsis.Code != want {
+		t.Errorf("code not dedented:\n%s", synopsis.Code)
+	}
+	if len(synopsis.Expected) != 1 || synopsis.Expected[0] != "0" {
+		t.Errorf("expected output %q, want [0]", synopsis.Expected)
+	}
+
+	if examples[1].Section != "EXAMPLES" || examples[1].Code != "my $c = Counter->new;" {
+		t.Errorf("wrong second example: %q under %s", examples[1].Code, examples[1].Section)
+	}
+	if examples[2].Code != `say "no expectations";` || len(examples[2].Expected) != 0 {
+		t.Errorf("wrong third example: %q", examples[2].Code)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	code := Strip(module)
+	if strings.Contains(code, "=head1") || strings.Contains(code, "SYNOPSIS") || strings.Contains(code, "EXAMPLES") {
+		t.Errorf("POD left in code:\n%s", code)
+	}
+	if !strings.Contains(code, "sub new") || !strings.HasSuffix(code, "1;") {
+		t.Errorf("code lost:\n%s", code)
+	}
+	lines := strings.Split(code, "\n")
+	if len(lines) < 18 || !strings.HasPrefix(lines[17], "sub new") {
+		t.Errorf("line numbers not kept, sub new not on line 18")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ex := Example{Expected: []string{"1", "two"}}
+	if err := Check(ex, "1\ntwo\n"); err != nil {
+		t.Errorf("unexpected failure: %v", err)
+	}
+	if err := Check(ex, "1\n3\n"); err == nil {
+		t.Errorf("expected a mismatch")
+	}
+	if err := Check(Example{}, "anything"); err != nil {
+		t.Errorf("examples without expectations only need to run: %v", err)
+	}
+}
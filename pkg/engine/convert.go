@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"perlc/pkg/sv"
+)
+
+// SVToInterface converts a Perl value to a Go one:
+//
+//	undef                   nil
+//	integer                 int64
+//	number                  float64
+//	string                  string (numeric strings stay strings)
+//	array, array ref        []any
+//	hash, hash ref          map[string]any (a blessed one loses its class)
+//	scalar ref, code, qr//  the *sv.SV itself
+//
+// Arrays and hashes are copied deeply. A reference back into a structure
+// being converted (a cycle) is left as its *sv.SV.
+func SVToInterface(v *sv.SV) any {
+	return toInterface(v, map[*sv.SV]bool{})
+}
+
+func toInterface(v *sv.SV, seen map[*sv.SV]bool) any {
+	if v == nil {
+		return nil
+	}
+	target := v
+	if v.IsRef() {
+		target = v.Deref()
+		if !target.IsArray() && !target.IsHash() {
+			return v
+		}
+	}
+	switch target.Type() {
+	case sv.TypeUndef:
+		return nil
+	case sv.TypeInt:
+		return target.AsInt()
+	case sv.TypeFloat:
+		return target.AsFloat()
+	case sv.TypeString:
+		return target.AsString()
+	case sv.TypeArray:
+		if seen[target] {
+			return v
+		}
+		seen[target] = true
+		defer delete(seen, target)
+		elems := target.ArrayData()
+		out := make([]any, len(elems))
+		for i, el := range elems {
+			out[i] = toInterface(el, seen)
+		}
+		return out
+	case sv.TypeHash:
+		if seen[target] {
+			return v
+		}
+		seen[target] = true
+		defer delete(seen, target)
+		out := make(map[string]any, len(target.HashData()))
+		for k, el := range target.HashData() {
+			out[k] = toInterface(el, seen)
+		}
+		return out
+	}
+	return v
+}
+
+// InterfaceToSV converts a Go value to a Perl one:
+//
+//	nil, nil pointer        undef
+//	bool                    1 or ""
+//	signed integers         integer
+//	unsigned integers       integer, number above math.MaxInt64
+//	floats                  number
+//	string, []byte          string
+//	slice, array            array ref
+//	map                     hash ref, keys formatted with fmt.Sprint
+//	pointer, interface      the value it points at
+//	*sv.SV                  itself
+//
+// Other kinds (channels, funcs, structs, complex numbers) are an error.
+func InterfaceToSV(x any) (*sv.SV, error) {
+	if v, ok := x.(*sv.SV); ok {
+		if v == nil {
+			return sv.NewUndef(), nil
+		}
+		return v, nil
+	}
+	if b, ok := x.([]byte); ok {
+		return sv.NewString(string(b)), nil
+	}
+	return fromValue(reflect.ValueOf(x))
+}
+
+func fromValue(rv reflect.Value) (*sv.SV, error) {
+	if !rv.IsValid() {
+		return sv.NewUndef(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return sv.NewInt(1), nil
+		}
+		return sv.NewString(""), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sv.NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return sv.NewFloat(float64(u)), nil
+		}
+		return sv.NewInt(int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return sv.NewFloat(rv.Float()), nil
+	case reflect.String:
+		return sv.NewString(rv.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return sv.NewUndef(), nil
+		}
+		return InterfaceToSV(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return sv.NewString(string(rv.Bytes())), nil
+		}
+		elems := make([]*sv.SV, rv.Len())
+		for i := range elems {
+			el, err := InterfaceToSV(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			elems[i] = el
+		}
+		return sv.NewArrayRef(elems...), nil
+	case reflect.Map:
+		ref := sv.NewHashRef()
+		hv := ref.Deref().HashData()
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			el, err := InterfaceToSV(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("{%s}: %w", key, err)
+			}
+			hv[key] = el
+		}
+		return ref, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to a Perl value", rv.Type())
+}
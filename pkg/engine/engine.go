@@ -0,0 +1,286 @@
+// Package engine embeds the perlc interpreter in Go programs.
+//
+//	e := engine.New()
+//	e.SetHash("main::opts", map[string]any{"verbose": true, "paths": []string{"/etc"}})
+//	if err := e.Run(`$config = { db => { host => "localhost" } } if $opts{verbose};`); err != nil {
+//		log.Fatal(err)
+//	}
+//	host, err := e.Get("main::config.db.host") // "localhost"
+//
+// Variables are shared with the script through its file scope. The script
+// uses them without a my declaration (my %opts makes a new, empty hash).
+// $x, @x and %x are one variable x in the interpreter, so main::x names
+// whichever of them was set last.
+//
+// A path names a variable and, optionally, a way into its data:
+//
+//	[main::]NAME(.KEY)*
+//
+// Each KEY indexes the hash or array reached so far, references are
+// followed on the way. A KEY of an array is an integer, negative ones count
+// from the end. Only the main package is known: other prefixes are an
+// error.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"perlc/pkg/context"
+	"perlc/pkg/eval"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/sv"
+)
+
+// ErrNotFound is returned (wrapped) for a path that leads nowhere.
+var ErrNotFound = errors.New("no such variable")
+
+// Engine is a Perl interpreter with its variables kept between runs.
+type Engine struct {
+	interp *eval.Interpreter
+	runs   int
+}
+
+// New creates an engine writing to os.Stdout and os.Stderr.
+func New() *Engine {
+	return &Engine{interp: eval.New()}
+}
+
+// Interpreter returns the underlying interpreter.
+func (e *Engine) Interpreter() *eval.Interpreter {
+	return e.interp
+}
+
+// SetOutput redirects the print and warn output of scripts.
+func (e *Engine) SetOutput(stdout, stderr io.Writer) {
+	e.interp.SetStdout(stdout)
+	e.interp.SetStderr(stderr)
+}
+
+// Run parses and runs code. A die in the script comes back as an error;
+// exit still ends the process.
+func (e *Engine) Run(code string) error {
+	e.runs++
+	return e.run(code, fmt.Sprintf("(engine %d)", e.runs))
+}
+
+// RunFile runs the script in path.
+func (e *Engine) RunFile(path string) error {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return e.run(string(code), path)
+}
+
+func (e *Engine) run(code, filename string) error {
+	p := parser.New(lexer.NewFile(code, filename))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return fmt.Errorf("parse error: %s", strings.Join(errs, "; "))
+	}
+	rt := context.GetRuntime()
+	if !rt.TryEval(func() { e.interp.Eval(program) }) {
+		return errors.New(strings.TrimSuffix(rt.EvalError().AsString(), "\n"))
+	}
+	return nil
+}
+
+// Lookup returns the value at path as it is stored, without conversion.
+func (e *Engine) Lookup(path string) (*sv.SV, error) {
+	name, keys, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := e.interp.Global(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	for n, key := range keys {
+		v = deref(v)
+		var next *sv.SV
+		switch {
+		case v.IsHash():
+			next = v.HashData()[key]
+		case v.IsArray():
+			if i, ok := arrayIndex(v, key); ok {
+				next = v.ArrayData()[i]
+			}
+		default:
+			return nil, fmt.Errorf("%s is not a hash or array", joinPath(name, keys[:n]))
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		v = next
+	}
+	return v, nil
+}
+
+// Get returns the value at path converted by SVToInterface.
+func (e *Engine) Get(path string) (any, error) {
+	v, err := e.Lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	return SVToInterface(v), nil
+}
+
+// Set stores value, converted by InterfaceToSV, at path. Missing hashes on
+// the way are created; a map or slice stored as a whole variable is a
+// reference ($name), use SetHash and SetArray for %name and @name.
+func (e *Engine) Set(path string, value any) error {
+	name, keys, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	val, err := InterfaceToSV(value)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		e.interp.SetGlobal(name, val)
+		return nil
+	}
+	v, ok := e.interp.Global(name)
+	if !ok || v.IsUndef() {
+		v = sv.NewHashRef()
+		e.interp.SetGlobal(name, v)
+	}
+	for n, key := range keys {
+		last := n == len(keys)-1
+		v = deref(v)
+		switch {
+		case v.IsHash():
+			hv := v.HashData()
+			if last {
+				hv[key] = val
+				return nil
+			}
+			if next, ok := hv[key]; ok && !next.IsUndef() {
+				v = next
+				continue
+			}
+			hv[key] = sv.NewHashRef()
+			v = hv[key]
+		case v.IsArray():
+			i, ok := arrayIndex(v, key)
+			if !ok {
+				if i < 0 {
+					return fmt.Errorf("%s: bad array index %q", joinPath(name, keys[:n]), key)
+				}
+				av := v.ArrayData()
+				for len(av) <= i {
+					av = append(av, sv.NewUndef())
+				}
+				v.SetArrayData(av)
+			}
+			av := v.ArrayData()
+			if last {
+				av[i] = val
+				return nil
+			}
+			if av[i] == nil || av[i].IsUndef() {
+				av[i] = sv.NewHashRef()
+			}
+			v = av[i]
+		default:
+			return fmt.Errorf("%s is not a hash or array", joinPath(name, keys[:n]))
+		}
+	}
+	return nil
+}
+
+// SetHash makes %name hold the entries of m.
+func (e *Engine) SetHash(name string, m map[string]any) error {
+	name, keys, err := splitPath(name)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("SetHash %s: not a variable name", joinPath(name, keys))
+	}
+	hash := sv.NewHashRef().Deref()
+	hv := hash.HashData()
+	for k, v := range m {
+		val, err := InterfaceToSV(v)
+		if err != nil {
+			return fmt.Errorf("%%%s{%s}: %w", name, k, err)
+		}
+		hv[k] = val
+	}
+	e.interp.SetGlobal(name, hash)
+	return nil
+}
+
+// SetArray makes @name hold the elements of a.
+func (e *Engine) SetArray(name string, a []any) error {
+	name, keys, err := splitPath(name)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("SetArray %s: not a variable name", joinPath(name, keys))
+	}
+	elems := make([]*sv.SV, len(a))
+	for i, v := range a {
+		val, err := InterfaceToSV(v)
+		if err != nil {
+			return fmt.Errorf("$%s[%d]: %w", name, i, err)
+		}
+		elems[i] = val
+	}
+	e.interp.SetGlobal(name, sv.NewArraySV(elems...))
+	return nil
+}
+
+// splitPath cuts a path into the variable name and the keys after it
+func splitPath(path string) (string, []string, error) {
+	rest := strings.TrimPrefix(path, "main::")
+	if strings.HasPrefix(rest, "::") {
+		rest = rest[2:]
+	}
+	parts := strings.Split(rest, ".")
+	name := parts[0]
+	if name == "" || strings.Contains(name, "::") {
+		return "", nil, fmt.Errorf("bad variable path %q", path)
+	}
+	if strings.ContainsAny(name[:1], "$@%") {
+		return "", nil, fmt.Errorf("bad variable path %q: no sigil expected", path)
+	}
+	return name, parts[1:], nil
+}
+
+func joinPath(name string, keys []string) string {
+	return strings.Join(append([]string{"main::" + name}, keys...), ".")
+}
+
+// deref follows references down to the value they point at
+func deref(v *sv.SV) *sv.SV {
+	for v.IsRef() {
+		v = v.Deref()
+	}
+	return v
+}
+
+// arrayIndex resolves key as an index into the array av. When the index is
+// out of range it returns the wanted (non-negative) index or -1 and false.
+func arrayIndex(av *sv.SV, key string) (int, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil {
+		return -1, false
+	}
+	n := len(av.ArrayData())
+	if i < 0 {
+		i += n
+		if i < 0 {
+			return -1, false
+		}
+	}
+	return i, i < n
+}
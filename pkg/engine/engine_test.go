@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"perlc/pkg/sv"
+)
+
+func TestGetNested(t *testing.T) {
+	e := New()
+	err := e.Run(`$config = { db => { host => "localhost", port => 5432, ratio => 0.5 }, tags => ["a", "b", undef] };`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"main::config.db.host", "localhost"},
+		{"config.db.port", int64(5432)},
+		{"config.db.ratio", 0.5},
+		{"config.tags.1", "b"},
+		{"config.tags.-3", "a"},
+		{"config.tags.2", nil},
+		{"config.tags", []any{"a", "b", nil}},
+		{"main::config.db", map[string]any{"host": "localhost", "port": int64(5432), "ratio": 0.5}},
+	}
+	for _, tt := range tests {
+		got, err := e.Get(tt.path)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%q) = %#v, %v; want %#v", tt.path, got, err, tt.want)
+		}
+	}
+	for _, path := range []string{"nope", "config.db.user", "config.tags.3", "config.tags.x"} {
+		if _, err := e.Get(path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): %v, want ErrNotFound", path, err)
+		}
+	}
+	for _, path := range []string{"config.db.host.x", "Foo::bar", "main::", "$config"} {
+		if _, err := e.Get(path); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): %v, want a path error", path, err)
+		}
+	}
+}
+
+func TestSetVisibleToScript(t *testing.T) {
+	e := New()
+	var out bytes.Buffer
+	e.SetOutput(&out, &out)
+	if err := e.SetHash("main::opts", map[string]any{"verbose": true, "level": 3, "paths": []string{"/etc", "/usr"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetArray("files", []any{"a.txt", uint8(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("main::limits.cpu.max", 4); err != nil {
+		t.Fatal(err)
+	}
+	err := e.Run(`my @got = ($opts{verbose}, $opts{level}, @{$opts{paths}}, scalar(@files), $files[1], $limits->{cpu}{max});
+print join(",", @got), "\n";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "1,3,/etc,/usr,2,2,4\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// the script's changes come back
+	if err := e.Run(`$opts{level}++; push @files, "b.txt"; $limits->{cpu}{min} = 1;`); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := e.Get("opts.level"); got != int64(4) {
+		t.Errorf("opts.level = %#v, want 4", got)
+	}
+	if got, _ := e.Get("files.-1"); got != "b.txt" {
+		t.Errorf("files.-1 = %#v, want b.txt", got)
+	}
+	if got, _ := e.Get("limits.cpu"); !reflect.DeepEqual(got, map[string]any{"max": int64(4), "min": int64(1)}) {
+		t.Errorf("limits.cpu = %#v", got)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	e := New()
+	if err := e.Run(`$cfg = { list => [1, 2] }; $name = "x";`); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("cfg.list.3", "four"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("cfg.list.-1", "last"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("cfg.list.5.k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := e.Get("cfg.list")
+	want := []any{int64(1), int64(2), nil, "last", nil, map[string]any{"k": "v"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cfg.list = %#v, want %#v", got, want)
+	}
+	if err := e.Set("cfg.list.-9", 1); err == nil {
+		t.Error("Set before the start of an array should fail")
+	}
+	if err := e.Set("name.x", 1); err == nil {
+		t.Error("Set into a string should fail")
+	}
+	if err := e.Set("cfg.ch", make(chan int)); err == nil {
+		t.Error("Set of a channel should fail")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	e := New()
+	e.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if err := e.Run(`die "boom\n";`); err == nil || err.Error() != "boom" {
+		t.Errorf("die: %v, want boom", err)
+	}
+	if err := e.Run(`my $x = ;`); err == nil || !strings.HasPrefix(err.Error(), "parse error") {
+		t.Errorf("syntax: %v, want a parse error", err)
+	}
+	// the engine keeps working after a die
+	if err := e.Run(`$ok = 1;`); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := e.Get("ok"); got != int64(1) {
+		t.Errorf("ok = %#v, want 1", got)
+	}
+}
+
+func TestSVToInterface(t *testing.T) {
+	code := sv.NewCodeRef("main::f")
+	scalarRef := sv.NewRef(sv.NewInt(1))
+	cyclic := sv.NewHashRef()
+	cyclic.Deref().HashData()["self"] = cyclic
+	tests := []struct {
+		in   *sv.SV
+		want any
+	}{
+		{nil, nil},
+		{sv.NewUndef(), nil},
+		{sv.NewInt(-7), int64(-7)},
+		{sv.NewFloat(1.5), 1.5},
+		{sv.NewString("42"), "42"},
+		{sv.NewArraySV(sv.NewInt(1), sv.NewArrayRef(sv.NewString("x"))), []any{int64(1), []any{"x"}}},
+		{code, code},
+		{scalarRef, scalarRef},
+		{cyclic, map[string]any{"self": cyclic}},
+	}
+	for _, tt := range tests {
+		if got := SVToInterface(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SVToInterface(%v) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceToSV(t *testing.T) {
+	var nilPtr *int
+	n := 5
+	tests := []struct {
+		in   any
+		want string // Perl's view of the value
+	}{
+		{nil, ""},
+		{nilPtr, ""},
+		{&n, "5"},
+		{true, "1"},
+		{false, ""},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "1.84467440737096e+19"},
+		{float32(0.25), "0.25"},
+		{[]byte("raw"), "raw"},
+		{"text", "text"},
+	}
+	for _, tt := range tests {
+		v, err := InterfaceToSV(tt.in)
+		if err != nil || v.AsString() != tt.want {
+			t.Errorf("InterfaceToSV(%#v) = %v, %v; want %q", tt.in, v, err, tt.want)
+		}
+	}
+	v, err := InterfaceToSV(map[int][]any{1: {"a", map[string]int{"b": 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"1": []any{"a", map[string]any{"b": int64(2)}}}
+	if got := SVToInterface(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+	if _, err := InterfaceToSV([]any{1, struct{}{}}); err == nil || !strings.Contains(err.Error(), "[1]") {
+		t.Errorf("struct: %v, want an error at [1]", err)
+	}
+}
@@ -0,0 +1,140 @@
+package eval
+
+import (
+	"perlc/pkg/ast"
+	"perlc/pkg/av"
+	"perlc/pkg/hv"
+	"perlc/pkg/sv"
+)
+
+// Автовивификация: при записи через цепочку $h{a}{b}[2]{c} = 1 или
+// $r->{list}[0] = 1 недостающие промежуточные хеши и массивы создаются,
+// а ссылки на них кладутся в пустые элементы и переменные.
+// При чтении ничего не создаётся.
+
+// container возвращает хеш или массив, в котором лежит элемент с основой
+// base (Hash у HashAccess, Array у ArrayAccess), создавая его при необходимости
+func (i *Interpreter) container(base ast.Expression, hash bool) *sv.SV {
+	switch b := base.(type) {
+	case *ast.ScalarVar, *ast.ArrayVar, *ast.HashVar:
+		// $h{k}, $a[0] - именованные %h и @a
+		sigil := "@"
+		if hash {
+			sigil = "%"
+		}
+		name := i.varKey(sigil, varName(b))
+		v := i.ctx.GetVar(name)
+		if v.IsRef() {
+			return v.Deref()
+		}
+		if v.IsUndef() {
+			v = newContainer(hash)
+			i.ctx.SetVar(name, v)
+		}
+		return v
+	case *ast.HashAccess, *ast.ArrayAccess, *ast.ArrowAccess:
+		// $h{a}{b}: в $h{a} должна лежать ссылка на хеш
+		return i.vivify(i.slot(base), hash)
+	case *ast.DerefExpr:
+		// @$r, %{$h{k}}
+		return i.vivify(i.slot(b.Value), hash)
+	}
+	return i.evalExpression(base)
+}
+
+// slot возвращает сам SV элемента или переменной, а не копию значения;
+// отсутствующий элемент добавляется как undef
+func (i *Interpreter) slot(expr ast.Expression) *sv.SV {
+	switch e := expr.(type) {
+	case *ast.ScalarVar:
+		name := i.varKey("$", e.Name)
+		v := i.ctx.GetVar(name)
+		if v.IsUndef() {
+			v = sv.NewUndef()
+			i.ctx.SetVar(name, v)
+		}
+		return v
+	case *ast.HashAccess:
+		return hashSlot(i.container(e.Hash, true), i.evalExpression(e.Key))
+	case *ast.ArrayAccess:
+		if s, ok := e.Array.(*ast.SpecialVar); ok && s.Name == "$_" {
+			return arraySlot(i.ctx.GetArgs(), i.evalExpression(e.Index))
+		}
+		return arraySlot(i.container(e.Array, false), i.evalExpression(e.Index))
+	case *ast.ArrowAccess:
+		switch right := e.Right.(type) {
+		case *ast.HashAccess:
+			return hashSlot(i.vivify(i.slot(e.Left), true), i.evalExpression(right.Key))
+		case *ast.ArrayAccess:
+			return arraySlot(i.vivify(i.slot(e.Left), false), i.evalExpression(right.Index))
+		}
+	}
+	return i.evalExpression(expr)
+}
+
+// vivify возвращает хеш или массив, на который ссылается slot; в пустой
+// slot кладётся ссылка на новый
+func (i *Interpreter) vivify(slot *sv.SV, hash bool) *sv.SV {
+	if slot.IsRef() {
+		return slot.Deref()
+	}
+	if slot.IsUndef() {
+		c := newContainer(hash)
+		slot.SetRef(c)
+		return c
+	}
+	return slot
+}
+
+func varName(expr ast.Expression) string {
+	switch v := expr.(type) {
+	case *ast.ArrayVar:
+		return v.Name
+	case *ast.HashVar:
+		return v.Name
+	}
+	return expr.(*ast.ScalarVar).Name
+}
+
+// varSigil - сигил переменной $x, @a или %h
+func varSigil(expr ast.Expression) string {
+	switch expr.(type) {
+	case *ast.ArrayVar:
+		return "@"
+	case *ast.HashVar:
+		return "%"
+	}
+	return "$"
+}
+
+func newContainer(hash bool) *sv.SV {
+	if hash {
+		return sv.NewHashRef().Deref()
+	}
+	return sv.NewArrayRef().Deref()
+}
+
+func hashSlot(hash, key *sv.SV) *sv.SV {
+	if !hash.IsHash() {
+		return sv.NewUndef()
+	}
+	if v := hv.Fetch(hash, key); !v.IsUndef() {
+		return v
+	}
+	// undef заменяется новым SV: он мог попасть в хеш из другой переменной
+	v := sv.NewUndef()
+	hv.Store(hash, key, v)
+	return v
+}
+
+func arraySlot(arr, idx *sv.SV) *sv.SV {
+	if !arr.IsArray() {
+		return sv.NewUndef()
+	}
+	if v := av.Fetch(arr, idx); !v.IsUndef() {
+		return v
+	}
+	v := sv.NewUndef()
+	av.Store(arr, idx, v)
+	return v
+}
@@ -2,72 +2,148 @@
 package eval
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"perlc/pkg/ast"
 	"perlc/pkg/av"
+	"perlc/pkg/context"
 	"perlc/pkg/hv"
+	"perlc/pkg/perlpack"
+	"perlc/pkg/perlre"
+	"perlc/pkg/perlstr"
 	"perlc/pkg/sv"
 )
 
 func (i *Interpreter) builtinPrint(expr *ast.CallExpr) *sv.SV {
-	// Check if first arg is filehandle
-	if len(expr.Args) >= 2 {
-		if fhVar, ok := expr.Args[0].(*ast.ScalarVar); ok {
-			fhName := i.ctx.GetVar(fhVar.Name)
-			if fhName != nil {
-				fh := i.ctx.GetFileHandle(fhName.AsString())
-				if fh != nil && fh.Writer != nil {
-					for _, arg := range expr.Args[1:] {
-						val := i.evalExpression(arg)
-						fh.Writer.WriteString(val.AsString())
-					}
-					return sv.NewInt(1)
-				}
+	return i.printTo(expr, i.ctx.GetSpecialVar("$\\").AsString())
+}
+
+func (i *Interpreter) builtinSay(expr *ast.CallExpr) *sv.SV {
+	return i.printTo(expr, "\n")
+}
+
+// printTo выводит аргументы print/say (и завершающую строку end: $\ или
+// "\n" у say) в handle из первого аргумента (print $fh ..., print STDERR
+// ..., print {$h->{fh}} ...) или в выбранный select handle. Массивы и хеши выводятся поэлементно, между
+// элементами списка ставится $,
+func (i *Interpreter) printTo(expr *ast.CallExpr, end string) *sv.SV {
+	w, fh, args := i.printTarget(expr.Args)
+	ofs := i.ctx.GetSpecialVar("$,").AsString()
+	first := true
+	for _, arg := range args {
+		var items []*sv.SV
+		if rl, ok := arg.(*ast.ReadLineExpr); ok {
+			// print <$fh> выводит все записи
+			items = i.svToList(i.evalReadLineList(rl))
+		} else {
+			// списки из функций (print unpack(...), print sort ...)
+			// раскрываются, как в правой части присваивания
+			items = i.listValues(arg)
+		}
+		for _, item := range items {
+			if !first {
+				io.WriteString(w, ofs)
 			}
+			first = false
+			io.WriteString(w, item.AsString())
 		}
 	}
-	// Normal print to stdout
-	for _, arg := range expr.Args {
-		val := i.evalExpression(arg)
-		fmt.Fprint(i.stdout, val.AsString())
+	io.WriteString(w, end)
+	if fh != nil && fh.Autoflush {
+		fh.Flush()
 	}
 	return sv.NewInt(1)
 }
 
-func (i *Interpreter) builtinSay(expr *ast.CallExpr) *sv.SV {
-	// Check if first arg is filehandle
-	if len(expr.Args) >= 2 {
-		if fhVar, ok := expr.Args[0].(*ast.ScalarVar); ok {
-			fhName := i.ctx.GetVar(fhVar.Name)
-			if fhName != nil {
-				fh := i.ctx.GetFileHandle(fhName.AsString())
-				if fh != nil && fh.Writer != nil {
-					for _, arg := range expr.Args[1:] {
-						val := i.evalExpression(arg)
-						fh.Writer.WriteString(val.AsString())
-					}
-					fh.Writer.WriteString("\n")
-					return sv.NewInt(1)
-				}
-			}
+// printTarget отделяет handle от выводимых аргументов. fh != nil для
+// открытых файлов (у них буфер и autoflush), STDOUT/STDERR - это
+// i.stdout/i.stderr, которые можно подменить через SetStdout/SetStderr.
+func (i *Interpreter) printTarget(args []ast.Expression) (io.Writer, *context.FileHandle, []ast.Expression) {
+	if len(args) < 2 {
+		w, fh := i.handleWriter(i.ctx.Selected())
+		return w, fh, args
+	}
+	var val *sv.SV
+	switch h := args[0].(type) {
+	case *ast.Identifier:
+		if w := i.stdStream(h.Value); w != nil {
+			return w, nil, args[1:]
 		}
+	case *ast.ScalarVar:
+		val = i.ctx.GetVar(h.Name)
+	case *ast.BlockFilehandle:
+		// print {$self->{fh}} ... - всегда handle, даже неоткрытый
+		w, fh := i.handleWriter(handleName(i.evalExpression(h.Expr)))
+		return w, fh, args[1:]
+	}
+	if val != nil {
+		if w := i.stdStream(handleName(val)); w != nil {
+			return w, nil, args[1:]
+		}
+		if fh := i.ctx.GetFileHandle(handleName(val)); fh != nil && fh.Writer != nil {
+			return fh.Writer, fh, args[1:]
+		}
+	}
+	w, fh := i.handleWriter(i.ctx.Selected())
+	return w, fh, args
+}
+
+// handleName - имя handle в значении: STDOUT у *STDOUT и \*STDOUT,
+// GLOB(0x...) у $fh из open, иначе сама строка
+func handleName(v *sv.SV) string {
+	if name := v.GlobName(); name != "" {
+		return name
 	}
-	// Normal say to stdout
-	for _, arg := range expr.Args {
-		val := i.evalExpression(arg)
-		fmt.Fprint(i.stdout, val.AsString())
+	return v.AsString()
+}
+
+// handleWriter - куда пишет handle name: STDOUT/STDERR или буфер файла;
+// вывод в закрытый handle пропадает, как в Perl
+func (i *Interpreter) handleWriter(name string) (io.Writer, *context.FileHandle) {
+	if w := i.stdStream(name); w != nil {
+		return w, nil
 	}
-	fmt.Fprintln(i.stdout)
-	return sv.NewInt(1)
+	if fh := i.ctx.GetFileHandle(name); fh != nil && fh.Writer != nil {
+		return fh.Writer, fh
+	}
+	return io.Discard, nil
+}
+
+// select(FH) делает FH handle по умолчанию для print/say/printf и
+// возвращает прежний; select() - текущий. Четыре аргумента -
+// select(undef, undef, undef, $sec) - просто пауза на $sec секунд.
+func (i *Interpreter) builtinSelect(args []*sv.SV) *sv.SV {
+	if len(args) == 4 {
+		time.Sleep(time.Duration(args[3].AsFloat() * float64(time.Second)))
+		return sv.NewInt(0)
+	}
+	prev := i.ctx.Selected()
+	if len(args) > 0 {
+		i.ctx.Select(strings.TrimPrefix(args[0].AsString(), "main::"))
+	}
+	if !strings.HasPrefix(prev, "GLOB(") {
+		prev = "main::" + prev
+	}
+	return sv.NewString(prev)
+}
+
+// stdStream - писатель стандартного потока по имени handle или nil
+func (i *Interpreter) stdStream(name string) io.Writer {
+	switch strings.TrimPrefix(name, "main::") {
+	case "STDOUT":
+		return i.stdout
+	case "STDERR":
+		return i.stderr
+	}
+	return nil
 }
 
 func (i *Interpreter) builtinOpen(expr *ast.CallExpr) *sv.SV {
@@ -75,21 +151,44 @@ func (i *Interpreter) builtinOpen(expr *ast.CallExpr) *sv.SV {
 		return sv.NewInt(0)
 	}
 
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value
-	}
+	fhName := i.openHandleName(expr.Args[0])
 
-	mode := i.evalExpression(expr.Args[1]).AsString()
+	mode := strings.TrimSpace(i.evalExpression(expr.Args[1]).AsString())
 	var filename string
 
+	if mode == "-|" || mode == "|-" {
+		// open($fh, '-|', 'cmd', @args) - канал к команде
+		var args []*sv.SV
+		for _, a := range expr.Args[2:] {
+			args = append(args, i.evalExpression(a))
+		}
+		return i.openPipe(fhName, mode, args)
+	}
+
 	if len(expr.Args) >= 3 && expr.Args[2] != nil {
-		filename = i.evalExpression(expr.Args[2]).AsString()
+		fileSV := i.evalExpression(expr.Args[2])
+		if fileSV.IsUndef() {
+			// open($fh, '+>', undef) - анонимный временный файл
+			if err := i.ctx.OpenTempFile(fhName, mode); err != nil {
+				return sv.NewInt(0)
+			}
+			return sv.NewInt(1)
+		}
+		if fileSV.IsRef() && fileSV.RefType() == "SCALAR" {
+			// open($fh, '<', \$buf) - handle в памяти
+			return fileResult(i.ctx.OpenScalar(fhName, mode, fileSV.Deref()))
+		}
+		filename = fileSV.AsString()
 	} else {
-		// 2-arg form: extract filename from mode
+		// 2-arg form: extract filename from mode, "cmd |" и "| cmd" - каналы
+		switch {
+		case strings.HasSuffix(mode, "|"):
+			cmd := strings.TrimSpace(strings.TrimSuffix(mode, "|"))
+			return i.openPipe(fhName, "-|", []*sv.SV{sv.NewString(cmd)})
+		case strings.HasPrefix(mode, "|"):
+			cmd := strings.TrimSpace(strings.TrimPrefix(mode, "|"))
+			return i.openPipe(fhName, "|-", []*sv.SV{sv.NewString(cmd)})
+		}
 		if len(mode) > 0 {
 			switch mode[0] {
 			case '<':
@@ -103,16 +202,14 @@ func (i *Interpreter) builtinOpen(expr *ast.CallExpr) *sv.SV {
 					filename = strings.TrimSpace(mode[1:])
 					mode = ">"
 				}
+			default:
+				filename = mode
+				mode = "<"
 			}
 		}
 	}
 
-	err := i.ctx.OpenFile(fhName, mode, filename)
-	if err != nil {
-		return sv.NewInt(0)
-	}
-	i.ctx.SetVar(fhName, sv.NewString(fhName))
-	return sv.NewInt(1)
+	return fileResult(i.ctx.OpenFile(fhName, mode, filename))
 }
 
 func (i *Interpreter) builtinClose(expr *ast.CallExpr) *sv.SV {
@@ -120,15 +217,7 @@ func (i *Interpreter) builtinClose(expr *ast.CallExpr) *sv.SV {
 		return sv.NewInt(0)
 	}
 
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value
-	default:
-		fhName = i.evalExpression(expr.Args[0]).AsString()
-	}
+	fhName := i.fileHandleName(expr.Args[0])
 
 	err := i.ctx.CloseFile(fhName)
 	if err != nil {
@@ -142,9 +231,7 @@ func (i *Interpreter) builtinPush(exprs []ast.Expression, args []*sv.SV) *sv.SV
 		return sv.NewInt(0)
 	}
 
-	// Get the array variable
-	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+	if arrSV := i.arrayOperand(exprs[0]); arrSV != nil {
 		for _, val := range args[1:] {
 			av.Push(arrSV, val)
 		}
@@ -158,8 +245,7 @@ func (i *Interpreter) builtinPop(exprs []ast.Expression) *sv.SV {
 		return sv.NewUndef()
 	}
 
-	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+	if arrSV := i.arrayOperand(exprs[0]); arrSV != nil {
 		return av.Pop(arrSV)
 	}
 	return sv.NewUndef()
@@ -172,8 +258,7 @@ func (i *Interpreter) builtinShift(exprs []ast.Expression) *sv.SV {
 		return av.Shift(args)
 	}
 
-	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+	if arrSV := i.arrayOperand(exprs[0]); arrSV != nil {
 		return av.Shift(arrSV)
 	}
 	return sv.NewUndef()
@@ -184,13 +269,29 @@ func (i *Interpreter) builtinUnshift(exprs []ast.Expression, args []*sv.SV) *sv.
 		return sv.NewInt(0)
 	}
 
-	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+	if arrSV := i.arrayOperand(exprs[0]); arrSV != nil {
 		return av.Unshift(arrSV, args[1:]...)
 	}
 	return sv.NewInt(0)
 }
 
+// arrayOperand возвращает массив для первого аргумента push/pop/shift/unshift:
+// @arr или разыменование @$ref, @{ expr }, $ref->@*
+func (i *Interpreter) arrayOperand(expr ast.Expression) *sv.SV {
+	switch e := expr.(type) {
+	case *ast.ArrayVar:
+		return i.ctx.GetVar(i.varKey("@", e.Name))
+	case *ast.DerefExpr:
+		// push @{$h{list}}, ... создаёт массив в пустом $h{list}
+		if e.Sigil == "@" {
+			if arr := i.vivify(i.slot(e.Value), false); arr.IsArray() {
+				return arr
+			}
+		}
+	}
+	return nil
+}
+
 func (i *Interpreter) builtinKeys(args []*sv.SV) *sv.SV {
 	if len(args) == 0 {
 		return sv.NewArrayRef()
@@ -214,13 +315,34 @@ func (i *Interpreter) builtinJoin(args []*sv.SV) *sv.SV {
 	return av.Join(args[0], args[1])
 }
 
-func (i *Interpreter) builtinSplit(args []*sv.SV) *sv.SV {
+// builtinSplit - split /PATTERN/, STR, LIMIT. Шаблон /.../, qr// или строка
+// делят по регулярному выражению, строка ' ' - как awk (perlstr.Fields);
+// пустые поля в конце отбрасываются, если LIMIT не задан.
+func (i *Interpreter) builtinSplit(exprs []ast.Expression, args []*sv.SV) *sv.SV {
 	if len(args) < 2 {
 		return sv.NewArrayRef()
 	}
-	pattern := args[0].AsString()
 	str := args[1].AsString()
-	parts := strings.Split(str, pattern)
+	limit := 0
+	if len(args) > 2 {
+		limit = int(args[2].AsInt())
+	}
+
+	var re *perlre.Regexp
+	if lit, ok := exprs[0].(*ast.RegexLiteral); ok {
+		re, _ = i.compilePattern(lit, lit.Pattern, lit.Flags)
+	} else if p, ok := args[0].RegexPattern(); ok {
+		re, _ = i.compileRegex(p)
+	} else if pattern := args[0].AsString(); pattern != " " {
+		re, _ = i.compileRegex(pattern)
+	}
+	var parts []string
+	switch {
+	case re != nil:
+		parts = perlstr.Split(re, str, limit)
+	case args[0].AsString() == " ":
+		parts = perlstr.Fields(str, limit)
+	}
 	elements := make([]*sv.SV, len(parts))
 	for idx, p := range parts {
 		elements[idx] = sv.NewString(p)
@@ -243,6 +365,7 @@ func (i *Interpreter) builtinAbs(args []*sv.SV) *sv.SV {
 	if len(args) == 0 {
 		return sv.NewFloat(0)
 	}
+	i.checkNumeric(args[0], "abs")
 	return sv.NewFloat(math.Abs(args[0].AsFloat()))
 }
 
@@ -250,6 +373,7 @@ func (i *Interpreter) builtinSqrt(args []*sv.SV) *sv.SV {
 	if len(args) == 0 {
 		return sv.NewFloat(0)
 	}
+	i.checkNumeric(args[0], "sqrt")
 	return sv.NewFloat(math.Sqrt(args[0].AsFloat()))
 }
 
@@ -257,6 +381,7 @@ func (i *Interpreter) builtinChr(args []*sv.SV) *sv.SV {
 	if len(args) == 0 {
 		return sv.NewString("")
 	}
+	i.checkNumeric(args[0], "chr")
 	return sv.NewString(string(rune(args[0].AsInt())))
 }
 
@@ -279,11 +404,12 @@ func (i *Interpreter) builtinChomp(exprs []ast.Expression) *sv.SV {
 	count := int64(0)
 	for _, expr := range exprs {
 		if v, ok := expr.(*ast.ScalarVar); ok {
-			val := i.ctx.GetVar(v.Name)
+			name := i.varKey("$", v.Name)
+			val := i.ctx.GetVar(name)
 			s := val.AsString()
 			if strings.HasSuffix(s, "\n") {
 				s = strings.TrimSuffix(s, "\n")
-				i.ctx.SetVar(v.Name, sv.NewString(s))
+				i.ctx.SetVar(name, sv.NewString(s))
 				count++
 			}
 		}
@@ -292,18 +418,41 @@ func (i *Interpreter) builtinChomp(exprs []ast.Expression) *sv.SV {
 }
 
 func (i *Interpreter) builtinDie(args []*sv.SV) *sv.SV {
+	// die $obj и die { code => 404 }: в $@ попадает сама ссылка, а не
+	// строка, чтобы можно было спросить $@->isa(...) и бросить дальше
+	var value *sv.SV
+	if len(args) == 1 && args[0].IsRef() {
+		value = args[0]
+	}
 	msg := ""
 	for _, arg := range args {
 		msg += arg.AsString()
 	}
 	if msg == "" {
-		msg = "Died"
+		// голый die повторно бросает $@: объект как есть, строку с
+		// пометкой "\t...propagated" после её перевода строки
+		prev := context.GetRuntime().EvalError()
+		switch {
+		case prev.IsRef():
+			value, msg = prev, prev.AsString()
+		case prev.AsString() != "":
+			msg = prev.AsString() + "\t...propagated"
+		default:
+			msg = "Died"
+		}
+		if at := i.at(); value == nil && at != "" {
+			msg += at + "."
+		}
 	}
 	if !strings.HasSuffix(msg, "\n") {
 		msg += "\n"
 	}
+	if context.GetRuntime().InEval() {
+		// eval { ... } поймает и положит сообщение (или объект) в $@
+		panic(context.PerlDie{Message: msg, Value: value})
+	}
 	fmt.Fprint(i.stderr, msg)
-	os.Exit(1)
+	i.exit(1)
 	return sv.NewUndef()
 }
 
@@ -315,7 +464,7 @@ func (i *Interpreter) builtinWarn(args []*sv.SV) *sv.SV {
 	if !strings.HasSuffix(msg, "\n") {
 		msg += "\n"
 	}
-	fmt.Fprint(i.stderr, msg)
+	i.warnings.Print(msg)
 	return sv.NewInt(1)
 }
 
@@ -324,10 +473,16 @@ func (i *Interpreter) builtinExit(args []*sv.SV) *sv.SV {
 	if len(args) > 0 {
 		code = int(args[0].AsInt())
 	}
-	os.Exit(code)
+	i.exit(code)
 	return sv.NewUndef()
 }
 
+// exit flushes open file handles before the process ends
+func (i *Interpreter) exit(code int) {
+	i.ctx.FlushAll()
+	os.Exit(code)
+}
+
 func (i *Interpreter) builtinScalar(args []*sv.SV) *sv.SV {
 
 	if len(args) == 0 {
@@ -365,13 +520,13 @@ func (i *Interpreter) builtinBless(exprs []ast.Expression, args []*sv.SV) *sv.SV
 	}
 
 	// Get package name - default to current package or caller's package
-	pkgName := "main"
+	pkgName := i.pkg
 	if len(args) >= 2 {
 		pkgName = args[1].AsString()
 	}
 
-	// Bless the reference into the package
-	ref.Bless(pkgName)
+	// Bless the reference into the package; its use overload applies
+	ref.BlessWith(pkgName, i)
 	return ref
 }
 
@@ -390,15 +545,25 @@ func (i *Interpreter) builtinIsa(args []*sv.SV) *sv.SV {
 		return sv.NewInt(0)
 	}
 
-	// Direct class check
-	if obj.Package() == className {
+	// Class itself or any class up the @ISA chain
+	if i.packageIsa(obj.Package(), className) {
 		return sv.NewInt(1)
 	}
-
-	// TODO: Check @ISA inheritance chain
 	return sv.NewInt(0)
 }
 
+// packageIsa - наследует ли pkg от target: сам класс или любой предок по
+// цепочке @ISA
+func (i *Interpreter) packageIsa(pkg, target string) bool {
+	order, _ := i.ctx.LinearISA(pkg)
+	for _, class := range order {
+		if class == target {
+			return true
+		}
+	}
+	return pkg == target
+}
+
 // builtinCan implements $obj->can('method') or UNIVERSAL::can($obj, 'method')
 // Returns coderef if $obj can do method, undef otherwise
 func (i *Interpreter) builtinCan(args []*sv.SV) *sv.SV {
@@ -419,34 +584,17 @@ func (i *Interpreter) builtinCan(args []*sv.SV) *sv.SV {
 
 	// Try to find the method using FindMethod (includes @ISA)
 	if found := i.ctx.FindMethod(pkgName, methodName); found != "" {
-		return sv.NewInt(1)
+		return sv.NewCodeRef(found)
 	}
 
 	// Try just the method name
 	if i.ctx.GetSub(methodName) != nil {
-		return sv.NewInt(1)
+		return sv.NewCodeRef(methodName)
 	}
 
 	return sv.NewUndef()
 }
 
-// builtinSetIsa sets the @ISA for a package
-// set_isa('Child', 'Parent1', 'Parent2', ...)
-func (i *Interpreter) builtinSetIsa(args []*sv.SV) *sv.SV {
-	if len(args) < 2 {
-		return sv.NewInt(0)
-	}
-
-	pkg := args[0].AsString()
-	parents := make([]string, len(args)-1)
-	for idx, arg := range args[1:] {
-		parents[idx] = arg.AsString()
-	}
-
-	i.ctx.SetPackageISA(pkg, parents)
-	return sv.NewInt(1)
-}
-
 func (i *Interpreter) builtinIndex(args []*sv.SV) *sv.SV {
 	if len(args) < 2 {
 		return sv.NewInt(-1)
@@ -546,13 +694,14 @@ func (i *Interpreter) builtinChop(exprs []ast.Expression) *sv.SV {
 	var lastChar string
 	for _, expr := range exprs {
 		if v, ok := expr.(*ast.ScalarVar); ok {
-			val := i.ctx.GetVar(v.Name)
+			name := i.varKey("$", v.Name)
+			val := i.ctx.GetVar(name)
 			s := val.AsString()
 			if len(s) > 0 {
 				runes := []rune(s)
 				lastChar = string(runes[len(runes)-1])
 				s = string(runes[:len(runes)-1])
-				i.ctx.SetVar(v.Name, sv.NewString(s))
+				i.ctx.SetVar(name, sv.NewString(s))
 			}
 		}
 	}
@@ -564,64 +713,26 @@ func (i *Interpreter) builtinChop(exprs []ast.Expression) *sv.SV {
 // ============================================================
 
 func (i *Interpreter) builtinSprintf(args []*sv.SV) *sv.SV {
+	return i.sprintf("sprintf", args)
+}
+
+// sprintf форматирует args[1:] по формату args[0]; о строке, прочитанной
+// как число (%d, ширина *), но на число не похожей, предупреждает
+// "Argument isn't numeric in op" (sprintf или printf)
+func (i *Interpreter) sprintf(op string, args []*sv.SV) *sv.SV {
 	if len(args) == 0 {
 		return sv.NewString("")
 	}
-
-	format := args[0].AsString()
-
-	// Конвертируем аргументы в interface{} для fmt.Sprintf
-	// Используем AsString для всех аргументов, Go сам разберётся с форматом
-	// Но для %d/%i/%x нужны числа, для %f/%e/%g нужны float
-	fmtArgs := make([]interface{}, len(args)-1)
-
-	// Простой подход: парсим формат и выбираем тип
-	fmtIdx := 0
+	fmtArgs := make([]any, len(args)-1)
 	for idx, arg := range args[1:] {
-		// Находим следующий % в формате
-		for fmtIdx < len(format) {
-			if format[fmtIdx] == '%' {
-				fmtIdx++
-				if fmtIdx < len(format) && format[fmtIdx] == '%' {
-					fmtIdx++
-					continue // %%
-				}
-				// Пропускаем флаги и ширину
-				for fmtIdx < len(format) {
-					c := format[fmtIdx]
-					if c == '-' || c == '+' || c == ' ' || c == '#' || c == '0' ||
-						(c >= '0' && c <= '9') || c == '.' || c == '*' {
-						fmtIdx++
-					} else {
-						break
-					}
-				}
-				// Смотрим спецификатор
-				if fmtIdx < len(format) {
-					spec := format[fmtIdx]
-					fmtIdx++
-					switch spec {
-					case 'd', 'i', 'o', 'x', 'X', 'b', 'c':
-						fmtArgs[idx] = arg.AsInt()
-					case 'e', 'E', 'f', 'F', 'g', 'G':
-						fmtArgs[idx] = arg.AsFloat()
-					default: // 's', 'v', etc.
-						fmtArgs[idx] = arg.AsString()
-					}
-					break
-				}
-			} else {
-				fmtIdx++
-			}
-		}
-		// Если формат закончился, используем строку
-		if fmtArgs[idx] == nil {
-			fmtArgs[idx] = arg.AsString()
+		fmtArgs[idx] = arg
+	}
+	num := func(v perlstr.Scalar) {
+		if arg, ok := v.(*sv.SV); ok {
+			i.checkNumeric(arg, op)
 		}
 	}
-
-	result := fmt.Sprintf(format, fmtArgs...)
-	return sv.NewString(result)
+	return sv.NewString(perlstr.SprintfNum(num, args[0].AsString(), fmtArgs...))
 }
 
 // ============================================================
@@ -700,237 +811,55 @@ func (i *Interpreter) builtinFc(args []*sv.SV) *sv.SV {
 }
 
 // ============================================================
-// pack - упаковка данных в бинарную строку
+// pack / unpack - шаблоны в perlpack, общем с компилятором
 // ============================================================
 
-func (i *Interpreter) builtinPack(args []*sv.SV) *sv.SV {
-	if len(args) < 1 {
+// builtinPack - pack TEMPLATE, LIST: список раскрывается, ошибка шаблона - die
+func (i *Interpreter) builtinPack(exprs []ast.Expression) *sv.SV {
+	if len(exprs) == 0 {
 		return sv.NewString("")
 	}
-
-	template := args[0].AsString()
-	values := args[1:]
-
-	var buf bytes.Buffer
-	valIdx := 0
-
-	for idx := 0; idx < len(template); idx++ {
-		if valIdx >= len(values) {
-			break
-		}
-
-		ch := template[idx]
-
-		// Проверяем count
-		count := 1
-		if idx+1 < len(template) {
-			if template[idx+1] >= '0' && template[idx+1] <= '9' {
-				countStr := ""
-				for idx+1 < len(template) && template[idx+1] >= '0' && template[idx+1] <= '9' {
-					idx++
-					countStr += string(template[idx])
-				}
-				count, _ = strconv.Atoi(countStr)
-			} else if template[idx+1] == '*' {
-				idx++
-				count = len(values) - valIdx
-			}
-		}
-
-		for c := 0; c < count && valIdx < len(values); c++ {
-			val := values[valIdx]
-
-			switch ch {
-			case 'A', 'a': // ASCII строка
-				s := val.AsString()
-				buf.WriteString(s)
-				valIdx++
-			case 'Z': // Null-terminated строка
-				s := val.AsString()
-				buf.WriteString(s)
-				buf.WriteByte(0)
-				valIdx++
-			case 'c', 'C': // char
-				buf.WriteByte(byte(val.AsInt()))
-				valIdx++
-			case 's': // signed short (little-endian)
-				binary.Write(&buf, binary.LittleEndian, int16(val.AsInt()))
-				valIdx++
-			case 'S': // unsigned short
-				binary.Write(&buf, binary.LittleEndian, uint16(val.AsInt()))
-				valIdx++
-			case 'l': // signed long
-				binary.Write(&buf, binary.LittleEndian, int32(val.AsInt()))
-				valIdx++
-			case 'L': // unsigned long
-				binary.Write(&buf, binary.LittleEndian, uint32(val.AsInt()))
-				valIdx++
-			case 'q': // signed quad
-				binary.Write(&buf, binary.LittleEndian, val.AsInt())
-				valIdx++
-			case 'Q': // unsigned quad
-				binary.Write(&buf, binary.LittleEndian, uint64(val.AsInt()))
-				valIdx++
-			case 'n': // unsigned short (big-endian)
-				binary.Write(&buf, binary.BigEndian, uint16(val.AsInt()))
-				valIdx++
-			case 'N': // unsigned long (big-endian)
-				binary.Write(&buf, binary.BigEndian, uint32(val.AsInt()))
-				valIdx++
-			case 'f': // float
-				binary.Write(&buf, binary.LittleEndian, float32(val.AsFloat()))
-				valIdx++
-			case 'd': // double
-				binary.Write(&buf, binary.LittleEndian, val.AsFloat())
-				valIdx++
-			case 'H': // hex string
-				s := val.AsString()
-				for j := 0; j < len(s); j += 2 {
-					end := j + 2
-					if end > len(s) {
-						end = len(s)
-					}
-					b, _ := strconv.ParseUint(s[j:end], 16, 8)
-					buf.WriteByte(byte(b))
-				}
-				valIdx++
-			case 'x': // null byte
-				buf.WriteByte(0)
-			}
+	template := i.evalScalarExpression(exprs[0]).AsString()
+	var values []perlpack.Arg
+	for _, e := range exprs[1:] {
+		for _, v := range i.listValues(e) {
+			values = append(values, v)
 		}
 	}
-
-	return sv.NewString(buf.String())
+	packed, err := perlpack.Pack(template, values)
+	if err != nil {
+		i.builtinDie([]*sv.SV{sv.NewString(err.Error())})
+	}
+	return sv.NewString(packed)
 }
 
-// ============================================================
-// unpack - распаковка бинарной строки
-// ============================================================
-
+// builtinUnpack - unpack TEMPLATE, EXPR; без EXPR распаковывает $_
 func (i *Interpreter) builtinUnpack(args []*sv.SV) *sv.SV {
-	if len(args) < 2 {
+	if len(args) == 0 {
 		return sv.NewArrayRef()
 	}
-
-	template := args[0].AsString()
-	data := []byte(args[1].AsString())
-
-	var results []*sv.SV
-	offset := 0
-
-	for idx := 0; idx < len(template); idx++ {
-		if offset >= len(data) {
-			break
-		}
-
-		ch := template[idx]
-
-		// Проверяем count
-		count := 1
-		if idx+1 < len(template) {
-			if template[idx+1] >= '0' && template[idx+1] <= '9' {
-				countStr := ""
-				for idx+1 < len(template) && template[idx+1] >= '0' && template[idx+1] <= '9' {
-					idx++
-					countStr += string(template[idx])
-				}
-				count, _ = strconv.Atoi(countStr)
-			} else if template[idx+1] == '*' {
-				idx++
-				count = len(data) - offset
-			}
-		}
-
-		for c := 0; c < count && offset < len(data); c++ {
-			switch ch {
-			case 'A', 'a': // ASCII строка
-				if count > 1 {
-					end := offset + count
-					if end > len(data) {
-						end = len(data)
-					}
-					results = append(results, sv.NewString(string(data[offset:end])))
-					offset = end
-					c = count
-				} else {
-					results = append(results, sv.NewString(string(data[offset])))
-					offset++
-				}
-			case 'Z': // Null-terminated
-				end := offset
-				for end < len(data) && data[end] != 0 {
-					end++
-				}
-				results = append(results, sv.NewString(string(data[offset:end])))
-				offset = end + 1
-			case 'c': // signed char
-				results = append(results, sv.NewInt(int64(int8(data[offset]))))
-				offset++
-			case 'C': // unsigned char
-				results = append(results, sv.NewInt(int64(data[offset])))
-				offset++
-			case 's': // signed short
-				if offset+2 <= len(data) {
-					val := int16(binary.LittleEndian.Uint16(data[offset:]))
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 2
-				}
-			case 'S': // unsigned short
-				if offset+2 <= len(data) {
-					val := binary.LittleEndian.Uint16(data[offset:])
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 2
-				}
-			case 'l': // signed long
-				if offset+4 <= len(data) {
-					val := int32(binary.LittleEndian.Uint32(data[offset:]))
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 4
-				}
-			case 'L': // unsigned long
-				if offset+4 <= len(data) {
-					val := binary.LittleEndian.Uint32(data[offset:])
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 4
-				}
-			case 'n': // unsigned short (big-endian)
-				if offset+2 <= len(data) {
-					val := binary.BigEndian.Uint16(data[offset:])
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 2
-				}
-			case 'N': // unsigned long (big-endian)
-				if offset+4 <= len(data) {
-					val := binary.BigEndian.Uint32(data[offset:])
-					results = append(results, sv.NewInt(int64(val)))
-					offset += 4
-				}
-			case 'H': // hex string
-				if count > 1 {
-					end := offset + (count+1)/2
-					if end > len(data) {
-						end = len(data)
-					}
-					var hex strings.Builder
-					for j := offset; j < end; j++ {
-						hex.WriteString(fmt.Sprintf("%02X", data[j]))
-					}
-					s := hex.String()
-					if len(s) > count {
-						s = s[:count]
-					}
-					results = append(results, sv.NewString(s))
-					offset = end
-					c = count
-				} else {
-					results = append(results, sv.NewString(fmt.Sprintf("%X", data[offset]>>4)))
-					offset++
-				}
-			case 'x': // skip byte
-				offset++
-			}
+	data := ""
+	if len(args) > 1 {
+		data = args[1].AsString()
+	} else if v := i.ctx.GetVar("_"); v != nil {
+		data = v.AsString()
+	}
+	values, err := perlpack.Unpack(args[0].AsString(), data)
+	if err != nil {
+		i.builtinDie([]*sv.SV{sv.NewString(err.Error())})
+	}
+	results := make([]*sv.SV, len(values))
+	for idx, v := range values {
+		switch v := v.(type) {
+		case int64:
+			results[idx] = sv.NewInt(v)
+		case uint64:
+			results[idx] = sv.NewFloat(float64(v))
+		case float64:
+			results[idx] = sv.NewFloat(v)
+		case string:
+			results[idx] = sv.NewString(v)
 		}
 	}
-
 	return sv.NewArrayRef(results...)
 }
@@ -19,13 +19,15 @@
 package eval
 
 import (
-	"bufio"
-	"fmt"
+	"io"
+	"os"
 	"perlc/pkg/ast"
 	"perlc/pkg/av"
+	"perlc/pkg/context"
 	"perlc/pkg/hv"
 	"perlc/pkg/sv"
 	"sort"
+	"strings"
 )
 
 func (i *Interpreter) builtinReverse(exprs []ast.Expression, args []*sv.SV) *sv.SV {
@@ -35,7 +37,7 @@ func (i *Interpreter) builtinReverse(exprs []ast.Expression, args []*sv.SV) *sv.
 
 	// Проверяем, если аргумент - переменная массива
 	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+		arrSV := i.ctx.GetVar(i.varKey("@", arrVar.Name))
 		if arrSV == nil || (!arrSV.IsArray() && !arrSV.IsRef()) {
 			return sv.NewArrayRef()
 		}
@@ -94,7 +96,7 @@ func (i *Interpreter) BuiltinSort_vOld(exprs []ast.Expression, args []*sv.SV) *s
 
 	// Проверяем, если аргумент - переменная массива
 	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
+		arrSV := i.ctx.GetVar(i.varKey("@", arrVar.Name))
 		if arrSV == nil || (!arrSV.IsArray() && !arrSV.IsRef()) {
 			return sv.NewArrayRef()
 		}
@@ -162,70 +164,42 @@ func (i *Interpreter) BuiltinSort_vOld(exprs []ast.Expression, args []*sv.SV) *s
 	return sv.NewArrayRef()
 }
 
+// builtinSort - sort LIST, sort { $a <=> $b } LIST, sort by_name LIST.
+// Компаратор вызывается с $a/$b, объявленными в отдельном scope,
+// поэтому именованная подпрограмма тоже их видит.
 func (i *Interpreter) builtinSort(exprs []ast.Expression, args []*sv.SV) *sv.SV {
-	if len(exprs) == 0 {
-		return sv.NewArrayRef()
-	}
-
-	// Проверяем, если аргумент - переменная массива
-	if arrVar, ok := exprs[0].(*ast.ArrayVar); ok {
-		arrSV := i.ctx.GetVar(arrVar.Name)
-		if arrSV == nil || (!arrSV.IsArray() && !arrSV.IsRef()) {
-			return sv.NewArrayRef()
-		}
-
-		// Получаем данные
-		var elements []*sv.SV
-		if arrSV.IsRef() {
-			deref := arrSV.Deref()
-			if deref != nil && deref.IsArray() {
-				elements = deref.ArrayData()
-			}
-		} else if arrSV.IsArray() {
-			elements = arrSV.ArrayData()
-		}
-
-		if elements == nil {
-			return sv.NewArrayRef()
+	var cmp *ast.AnonSubExpr
+	if len(exprs) > 0 {
+		if block, ok := exprs[0].(*ast.AnonSubExpr); ok {
+			cmp = block
+			args = args[1:]
 		}
+	}
 
-		// Создаём копию для сортировки
-		sorted := make([]*sv.SV, len(elements))
-		copy(sorted, elements)
+	elements := flattenArgs(args)
+	sorted := make([]*sv.SV, len(elements))
+	copy(sorted, elements)
 
-		// Сортируем
-		sort.Slice(sorted, func(i, j int) bool {
-			return sorted[i].AsString() < sorted[j].AsString()
+	if cmp == nil {
+		sort.SliceStable(sorted, func(a, b int) bool {
+			return sorted[a].AsString() < sorted[b].AsString()
 		})
-
 		return sv.NewArrayRef(sorted...)
 	}
 
-	// Если передан первый аргумент как значение
-	if len(args) > 0 && args[0] != nil {
-		var elements []*sv.SV
-		if args[0].IsRef() {
-			deref := args[0].Deref()
-			if deref != nil && deref.IsArray() {
-				elements = deref.ArrayData()
-			}
-		} else if args[0].IsArray() {
-			elements = args[0].ArrayData()
+	i.ctx.PushScope()
+	defer i.ctx.PopScope()
+	sort.SliceStable(sorted, func(a, b int) bool {
+		i.ctx.DeclareVar("a", sorted[a], "our")
+		i.ctx.DeclareVar("b", sorted[b], "our")
+		result := i.evalBlockStmt(cmp.Body)
+		if i.ctx.HasReturn() {
+			result = i.ctx.ReturnValue()
+			i.ctx.ClearReturn()
 		}
-
-		if elements != nil {
-			sorted := make([]*sv.SV, len(elements))
-			copy(sorted, elements)
-
-			sort.Slice(sorted, func(i, j int) bool {
-				return sorted[i].AsString() < sorted[j].AsString()
-			})
-
-			return sv.NewArrayRef(sorted...)
-		}
-	}
-
-	return sv.NewArrayRef()
+		return result.AsInt() < 0
+	})
+	return sv.NewArrayRef(sorted...)
 }
 
 func (i *Interpreter) builtinExists(expr *ast.CallExpr) *sv.SV {
@@ -235,7 +209,7 @@ func (i *Interpreter) builtinExists(expr *ast.CallExpr) *sv.SV {
 
 	// exists $hash{key}
 	if hashAccess, ok := expr.Args[0].(*ast.HashAccess); ok {
-		hash := i.evalExpression(hashAccess.Hash)
+		hash := i.hashBase(hashAccess.Hash)
 		key := i.evalExpression(hashAccess.Key)
 		return hv.Exists(hash, key)
 	}
@@ -257,8 +231,9 @@ func (i *Interpreter) builtinDelete(expr *ast.CallExpr) *sv.SV {
 
 	// delete $hash{key}
 	if hashAccess, ok := expr.Args[0].(*ast.HashAccess); ok {
-		hash := i.evalExpression(hashAccess.Hash)
+		hash := i.hashBase(hashAccess.Hash)
 		key := i.evalExpression(hashAccess.Key)
+		defer i.envChanged(hashAccess.Hash)
 		return hv.Delete(hash, key)
 	}
 
@@ -413,84 +388,40 @@ func (i *Interpreter) builtinEach(args []*sv.SV) *sv.SV {
 	return sv.NewArrayRef(pair...)
 }
 
-// pos - позиция последнего совпадения regex
-// pos($var) - получить позицию
+// pos - позиция последнего совпадения m//g
+// pos() - для $_, pos($var) - по имени переменной из выражения
 // В Perl также можно pos($var) = N для установки, но это lvalue
-func (i *Interpreter) builtinPos(args []*sv.SV) *sv.SV {
-	if len(args) == 0 {
-		// pos() без аргументов - позиция для $_
-		pos, ok := i.ctx.GetPos("_")
-		if !ok {
-			return sv.NewUndef()
-		}
-		return sv.NewInt(int64(pos))
+func (i *Interpreter) builtinPos(expr *ast.CallExpr) *sv.SV {
+	name := "_"
+	if len(expr.Args) > 0 {
+		name = matchPosVar(expr.Args[0])
 	}
-
-	// pos($var) - нужно получить имя переменной
-	// Но args[0] уже вычислен, поэтому мы не знаем имя
-	// Упрощённая реализация: ищем по значению строки
-	// TODO: для полной реализации нужно передавать expr
-
-	// Пока возвращаем позицию для $_ если есть аргумент
-	pos, ok := i.ctx.GetPos("_")
-	if !ok {
+	pos, ok := i.ctx.GetPos(name)
+	if name == "" || !ok {
 		return sv.NewUndef()
 	}
 	return sv.NewInt(int64(pos))
 }
 
-// printf - форматированный вывод
-func (i *Interpreter) builtinPrintf(args []*sv.SV) *sv.SV {
+// printf - форматированный вывод; handle - как у print: printf STDERR
+// ..., printf $fh ..., printf {$fh} ...
+func (i *Interpreter) builtinPrintf(expr *ast.CallExpr) *sv.SV {
+	w, fh, rest := i.printTarget(expr.Args)
+	var args []*sv.SV
+	for _, arg := range rest {
+		args = append(args, i.listValues(arg)...)
+	}
 	if len(args) == 0 {
 		return sv.NewInt(0)
 	}
 
-	format := args[0].AsString()
-	fmtArgs := make([]interface{}, len(args)-1)
-
-	// Парсим формат для определения типов (как в sprintf)
-	fmtIdx := 0
-	for idx, arg := range args[1:] {
-		for fmtIdx < len(format) {
-			if format[fmtIdx] == '%' {
-				fmtIdx++
-				if fmtIdx < len(format) && format[fmtIdx] == '%' {
-					fmtIdx++
-					continue
-				}
-				for fmtIdx < len(format) {
-					c := format[fmtIdx]
-					if c == '-' || c == '+' || c == ' ' || c == '#' || c == '0' ||
-						(c >= '0' && c <= '9') || c == '.' || c == '*' {
-						fmtIdx++
-					} else {
-						break
-					}
-				}
-				if fmtIdx < len(format) {
-					spec := format[fmtIdx]
-					fmtIdx++
-					switch spec {
-					case 'd', 'i', 'o', 'x', 'X', 'b', 'c':
-						fmtArgs[idx] = arg.AsInt()
-					case 'e', 'E', 'f', 'F', 'g', 'G':
-						fmtArgs[idx] = arg.AsFloat()
-					default:
-						fmtArgs[idx] = arg.AsString()
-					}
-					break
-				}
-			} else {
-				fmtIdx++
-			}
-		}
-		if fmtArgs[idx] == nil {
-			fmtArgs[idx] = arg.AsString()
-		}
+	// Формат разбирает perlstr.Sprintf, общий с sprintf и perlrt:
+	// %2$s, ширина из аргумента (%*d), флаги и точность как в Perl
+	result := i.sprintf("printf", args).AsString()
+	io.WriteString(w, result)
+	if fh != nil && fh.Autoflush {
+		fh.Flush()
 	}
-
-	result := fmt.Sprintf(format, fmtArgs...)
-	fmt.Fprint(i.stdout, result)
 	return sv.NewInt(int64(len(result)))
 }
 
@@ -502,15 +433,7 @@ func (i *Interpreter) builtinEof(expr *ast.CallExpr) *sv.SV {
 	}
 
 	// Получаем имя filehandle из AST
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value
-	default:
-		fhName = i.evalExpression(expr.Args[0]).AsString()
-	}
+	fhName := i.fileHandleName(expr.Args[0])
 
 	fh := i.ctx.GetFileHandle(fhName)
 	if fh == nil {
@@ -541,22 +464,14 @@ func (i *Interpreter) builtinTell(expr *ast.CallExpr) *sv.SV {
 		return sv.NewInt(-1)
 	}
 	// Получаем имя filehandle из AST
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value
-	default:
-		// Fallback - вычисляем значение
-		fhName = i.evalExpression(expr.Args[0]).AsString()
-	}
+	fhName := i.fileHandleName(expr.Args[0])
 
 	fh := i.ctx.GetFileHandle(fhName)
 	if fh == nil || fh.File == nil {
 		return sv.NewInt(-1)
 	}
 
+	fh.Flush()
 	pos, err := fh.File.Seek(0, 1) // SEEK_CUR = 1
 	if err != nil {
 		return sv.NewInt(-1)
@@ -572,15 +487,7 @@ func (i *Interpreter) builtinSeek(expr *ast.CallExpr) *sv.SV {
 	}
 
 	// Получаем имя filehandle из AST
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value
-	default:
-		fhName = i.evalExpression(expr.Args[0]).AsString()
-	}
+	fhName := i.fileHandleName(expr.Args[0])
 
 	position := i.evalExpression(expr.Args[1]).AsInt()
 	whence := int(i.evalExpression(expr.Args[2]).AsInt())
@@ -590,6 +497,7 @@ func (i *Interpreter) builtinSeek(expr *ast.CallExpr) *sv.SV {
 		return sv.NewInt(0)
 	}
 
+	fh.Flush()
 	_, err := fh.File.Seek(position, whence)
 	if err != nil {
 		return sv.NewInt(0)
@@ -597,12 +505,39 @@ func (i *Interpreter) builtinSeek(expr *ast.CallExpr) *sv.SV {
 
 	// После seek нужно пересоздать Scanner если он был
 	if fh.Scanner != nil {
-		fh.Scanner = bufio.NewScanner(fh.File)
+		i.ctx.ResetScanner(fh)
 	}
 
 	return sv.NewInt(1)
 }
 
+// truncate - обрезка файла до заданной длины
+func (i *Interpreter) builtinTruncate(expr *ast.CallExpr) *sv.SV {
+	// truncate(FH, LENGTH)
+	if len(expr.Args) < 2 {
+		return sv.NewInt(0)
+	}
+
+	fhName := i.fileHandleName(expr.Args[0])
+
+	length := i.evalExpression(expr.Args[1]).AsInt()
+
+	fh := i.ctx.GetFileHandle(fhName)
+	if fh == nil || fh.File == nil {
+		// truncate("file.txt", LENGTH) - по имени файла
+		if err := os.Truncate(fhName, length); err != nil {
+			return sv.NewInt(0)
+		}
+		return sv.NewInt(1)
+	}
+
+	fh.Flush()
+	if err := fh.File.Truncate(length); err != nil {
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(1)
+}
+
 // read - чтение байтов из файла
 func (i *Interpreter) builtinRead(expr *ast.CallExpr, args []*sv.SV) *sv.SV {
 	// read(FH, SCALAR, LENGTH, [OFFSET])
@@ -623,6 +558,7 @@ func (i *Interpreter) builtinRead(expr *ast.CallExpr, args []*sv.SV) *sv.SV {
 	}
 
 	// Читаем данные
+	fh.Flush()
 	buf := make([]byte, length)
 	n, err := fh.File.Read(buf)
 	if err != nil && n == 0 {
@@ -641,9 +577,9 @@ func (i *Interpreter) builtinRead(expr *ast.CallExpr, args []*sv.SV) *sv.SV {
 				for len(result) < offset {
 					result += "\x00"
 				}
-				result = result[:offset] + string(buf[:n])
+				result = result[:offset] + fh.Decode(buf[:n])
 			} else {
-				result = string(buf[:n])
+				result = fh.Decode(buf[:n])
 			}
 			i.ctx.SetVar(scalarVar.Name, sv.NewString(result))
 		}
@@ -652,35 +588,37 @@ func (i *Interpreter) builtinRead(expr *ast.CallExpr, args []*sv.SV) *sv.SV {
 	return sv.NewInt(int64(n))
 }
 
-// binmode - установка бинарного режима
+// binmode(FH) или binmode(FH, LAYER) - слои :raw, :bytes, :utf8,
+// :encoding(...), :crlf для чтения и записи файла
 func (i *Interpreter) builtinBinmode(expr *ast.CallExpr) *sv.SV {
-	// binmode(FH) или binmode(FH, LAYER)
-	// В Go файлы уже бинарные по умолчанию
 	if len(expr.Args) == 0 {
-		return sv.NewInt(1)
+		return sv.NewInt(0)
 	}
 
 	// Получаем имя filehandle из AST
-	var fhName string
-	switch fh := expr.Args[0].(type) {
-	case *ast.ScalarVar:
-		fhName = fh.Name
-	case *ast.Identifier:
-		fhName = fh.Value // STDOUT, STDERR, STDIN
-	default:
-		fhName = i.evalExpression(expr.Args[0]).AsString()
+	fhName := strings.TrimPrefix(i.fileHandleName(expr.Args[0]), "main::")
+	layers := ""
+	if len(expr.Args) > 1 {
+		layers = i.evalExpression(expr.Args[1]).AsString()
 	}
 
-	// Для стандартных потоков всегда успех
-	if fhName == "STDOUT" || fhName == "STDERR" || fhName == "STDIN" {
-		return sv.NewInt(1)
+	// Стандартные потоки пишутся как есть: слои только проверяются
+	if i.stdStream(fhName) != nil {
+		_, err := context.ParseLayers(context.Layers{}, layers)
+		return fileResult(err)
 	}
+	return fileResult(i.ctx.Binmode(fhName, layers))
+}
 
-	fh := i.ctx.GetFileHandle(fhName)
-	if fh == nil {
+// size / total_size - Devel::Size: примерный объём памяти значения
+// (по ссылке - того, на что она указывает); total_size идёт по элементам
+// и ссылкам, считая каждое значение один раз
+func (i *Interpreter) builtinSize(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
 		return sv.NewInt(0)
 	}
-
-	// Layer (":utf8", ":raw", etc.) - пока игнорируем
-	return sv.NewInt(1)
+	if name == "total_size" || name == "Devel::Size::total_size" {
+		return sv.NewInt(int64(sv.TotalSize(args[0])))
+	}
+	return sv.NewInt(int64(sv.Size(args[0])))
 }
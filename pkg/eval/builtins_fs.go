@@ -0,0 +1,219 @@
+package eval
+
+import (
+	"os"
+	"perlc/pkg/context"
+	"perlc/pkg/sv"
+	"syscall"
+	"time"
+)
+
+// Метаданные файлов: chmod, chown, utime, symlink, readlink; файлы и
+// каталоги: unlink, rename, mkdir, rmdir, chdir, cwd.
+// Списочные функции возвращают число успешно изменённых файлов,
+// при ошибке выставляют $! (последняя ошибка).
+
+// forEachFile применяет fn к каждому файлу и считает успешные вызовы
+func forEachFile(files []*sv.SV, fn func(name string) error) *sv.SV {
+	count := 0
+	for _, f := range files {
+		if err := fn(f.AsString()); err != nil {
+			context.GetRuntime().SetOSError(err)
+			continue
+		}
+		count++
+	}
+	return sv.NewInt(int64(count))
+}
+
+// chmod MODE, LIST
+func (i *Interpreter) builtinChmod(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	if len(args) == 0 {
+		return sv.NewInt(0)
+	}
+	mode := os.FileMode(args[0].AsInt())
+	return forEachFile(args[1:], func(name string) error {
+		return os.Chmod(name, mode)
+	})
+}
+
+// chown UID, GID, LIST - -1 (или undef) оставляет значение без изменений
+func (i *Interpreter) builtinChown(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	if len(args) < 2 {
+		return sv.NewInt(0)
+	}
+	uid, gid := ownerID(args[0]), ownerID(args[1])
+	return forEachFile(args[2:], func(name string) error {
+		return os.Chown(name, uid, gid)
+	})
+}
+
+func ownerID(v *sv.SV) int {
+	if v.IsUndef() {
+		return -1
+	}
+	return int(v.AsInt())
+}
+
+// utime ATIME, MTIME, LIST - undef undef означает "сейчас" (как touch)
+func (i *Interpreter) builtinUtime(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	if len(args) < 2 {
+		return sv.NewInt(0)
+	}
+	now := time.Now()
+	atime, mtime := now, now
+	if !args[0].IsUndef() || !args[1].IsUndef() {
+		atime = time.Unix(args[0].AsInt(), 0)
+		mtime = time.Unix(args[1].AsInt(), 0)
+	}
+	return forEachFile(args[2:], func(name string) error {
+		return os.Chtimes(name, atime, mtime)
+	})
+}
+
+// symlink OLDFILE, NEWFILE - 1 при успехе, 0 при ошибке
+func (i *Interpreter) builtinSymlink(args []*sv.SV) *sv.SV {
+	if len(args) < 2 {
+		return sv.NewInt(0)
+	}
+	if err := os.Symlink(args[0].AsString(), args[1].AsString()); err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(1)
+}
+
+// readlink EXPR - цель ссылки или undef (по умолчанию $_)
+func (i *Interpreter) builtinReadlink(args []*sv.SV) *sv.SV {
+	var name string
+	if len(args) > 0 {
+		name = args[0].AsString()
+	} else {
+		name = i.evalSpecialVar("$_").AsString()
+	}
+	target, err := os.Readlink(name)
+	if err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewUndef()
+	}
+	return sv.NewString(target)
+}
+
+// unlink LIST - удаляет файлы (каталоги не трогает), по умолчанию $_
+func (i *Interpreter) builtinUnlink(args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		args = []*sv.SV{i.evalSpecialVar("$_")}
+	}
+	return forEachFile(flattenArgs(args), unlinkFile)
+}
+
+func unlinkFile(name string) error {
+	if fi, err := os.Lstat(name); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "unlink", Path: name, Err: syscall.EISDIR}
+	}
+	return os.Remove(name)
+}
+
+// rename OLDNAME, NEWNAME - 1 при успехе, 0 при ошибке
+func (i *Interpreter) builtinRename(args []*sv.SV) *sv.SV {
+	if len(args) < 2 {
+		return sv.NewInt(0)
+	}
+	return fileResult(os.Rename(args[0].AsString(), args[1].AsString()))
+}
+
+// mkdir FILENAME, MODE - MODE по умолчанию 0777 (с учётом umask),
+// FILENAME по умолчанию $_
+func (i *Interpreter) builtinMkdir(args []*sv.SV) *sv.SV {
+	name, mode := "", os.FileMode(0o777)
+	if len(args) > 0 {
+		name = args[0].AsString()
+	} else {
+		name = i.evalSpecialVar("$_").AsString()
+	}
+	if len(args) > 1 {
+		mode = os.FileMode(args[1].AsInt())
+	}
+	return fileResult(os.Mkdir(name, mode))
+}
+
+// rmdir FILENAME - только пустой каталог, по умолчанию $_
+func (i *Interpreter) builtinRmdir(args []*sv.SV) *sv.SV {
+	var name string
+	if len(args) > 0 {
+		name = args[0].AsString()
+	} else {
+		name = i.evalSpecialVar("$_").AsString()
+	}
+	if fi, err := os.Lstat(name); err == nil && !fi.IsDir() {
+		return fileResult(&os.PathError{Op: "rmdir", Path: name, Err: syscall.ENOTDIR})
+	}
+	return fileResult(os.Remove(name))
+}
+
+// chdir EXPR - без аргумента в $ENV{HOME}
+func (i *Interpreter) builtinChdir(args []*sv.SV) *sv.SV {
+	dir := os.Getenv("HOME")
+	if len(args) > 0 {
+		dir = args[0].AsString()
+	}
+	return fileResult(os.Chdir(dir))
+}
+
+// cwd / getcwd (Cwd) - текущий каталог или undef
+func (i *Interpreter) builtinCwd() *sv.SV {
+	dir, err := os.Getwd()
+	if err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewUndef()
+	}
+	return sv.NewString(dir)
+}
+
+// fileResult - 1 при успехе, иначе 0 и $!
+func fileResult(err error) *sv.SV {
+	if err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(1)
+}
+
+// stat FILE / lstat FILE - 13 полей (context.Stat); для открытого handle
+// (stat $fh) - его файл. Без аргумента - $_. При ошибке пустой список и $!
+func (i *Interpreter) builtinStat(funcName string, args []*sv.SV) *sv.SV {
+	var name string
+	if len(args) > 0 {
+		name = args[0].AsString()
+	} else {
+		name = i.evalSpecialVar("$_").AsString()
+	}
+	var fi os.FileInfo
+	var err error
+	switch fh := i.ctx.GetFileHandle(name); {
+	case fh != nil && fh.File != nil:
+		fi, err = fh.File.Stat()
+	case funcName == "lstat":
+		fi, err = os.Lstat(name)
+	default:
+		fi, err = os.Stat(name)
+	}
+	if err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewArrayRef()
+	}
+	st := context.Stat(fi)
+	list := make([]*sv.SV, len(st))
+	for n, v := range st {
+		if v < 0 {
+			// blksize и blocks, которых нет в системе
+			list[n] = sv.NewString("")
+		} else {
+			list[n] = sv.NewInt(v)
+		}
+	}
+	return sv.NewArrayRef(list...)
+}
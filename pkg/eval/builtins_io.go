@@ -0,0 +1,281 @@
+package eval
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"perlc/pkg/ast"
+	"perlc/pkg/hv"
+	"perlc/pkg/sv"
+	"sort"
+	"strings"
+)
+
+// fileHandleName возвращает имя filehandle из AST ($fh или FH): для $fh,
+// открытого open, это строка его glob, GLOB(0x...)
+func (i *Interpreter) fileHandleName(expr ast.Expression) string {
+	switch fh := expr.(type) {
+	case *ast.ScalarVar:
+		if v := i.ctx.GetVar(fh.Name); v.IsGlobRef() {
+			return handleName(v)
+		}
+		return fh.Name
+	case *ast.Identifier:
+		return fh.Value
+	default:
+		return handleName(i.evalExpression(expr))
+	}
+}
+
+// openHandleName - имя handle для open/sysopen: open(my $fh, ...) кладёт
+// в $fh новый анонимный glob, повторный open использует тот же
+func (i *Interpreter) openHandleName(expr ast.Expression) string {
+	fh, ok := expr.(*ast.ScalarVar)
+	if !ok {
+		return i.fileHandleName(expr)
+	}
+	v := i.ctx.GetVar(fh.Name)
+	if !v.IsGlobRef() {
+		v = sv.NewGlobRef()
+		i.ctx.SetVar(fh.Name, v)
+	}
+	return v.AsString()
+}
+
+// flock - блокировка файла: flock($fh, LOCK_EX|LOCK_NB)
+func (i *Interpreter) builtinFlock(expr *ast.CallExpr) *sv.SV {
+	if len(expr.Args) < 2 {
+		return sv.NewInt(0)
+	}
+
+	fhName := i.fileHandleName(expr.Args[0])
+	op := int(i.evalExpression(expr.Args[1]).AsInt())
+
+	if err := i.ctx.LockFile(fhName, op); err != nil {
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(1)
+}
+
+// sysopen - открытие с флагами open(2): sysopen($fh, $path, O_WRONLY|O_CREAT|O_EXCL, 0644)
+func (i *Interpreter) builtinSysopen(expr *ast.CallExpr) *sv.SV {
+	if len(expr.Args) < 3 || expr.Args[2] == nil {
+		return sv.NewInt(0)
+	}
+
+	fhName := i.openHandleName(expr.Args[0])
+	filename := i.evalExpression(expr.Args[1]).AsString()
+	flags := int(i.evalExpression(expr.Args[2]).AsInt())
+
+	perm := os.FileMode(0666)
+	if len(expr.Args) >= 4 {
+		perm = os.FileMode(i.evalExpression(expr.Args[3]).AsInt())
+	}
+
+	if err := i.ctx.SysOpen(fhName, filename, flags, perm); err != nil {
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(1)
+}
+
+// glob - семантика File::Glob: glob("~/logs/*.{log,txt}")
+// В списочном контексте возвращает все совпадения.
+func (i *Interpreter) builtinGlob(args []*sv.SV) *sv.SV {
+	pattern := ""
+	if len(args) > 0 {
+		pattern = args[0].AsString()
+	} else {
+		pattern = i.ctx.GetSpecialVar("$_").AsString()
+	}
+
+	var elements []*sv.SV
+	for _, name := range perlGlob(pattern) {
+		elements = append(elements, sv.NewString(name))
+	}
+	return sv.NewArrayRef(elements...)
+}
+
+// globNext - glob в скалярном контексте: итератор, привязанный к месту вызова.
+// Возвращает следующее имя, а после последнего - undef и сбрасывает итератор.
+func (i *Interpreter) globNext(call *ast.CallExpr) *sv.SV {
+	names, ok := i.globIters[call]
+	if !ok {
+		pattern := ""
+		if len(call.Args) > 0 {
+			pattern = i.evalExpression(call.Args[0]).AsString()
+		} else {
+			pattern = i.ctx.GetSpecialVar("$_").AsString()
+		}
+		names = perlGlob(pattern)
+	}
+
+	if len(names) == 0 {
+		delete(i.globIters, call)
+		return sv.NewUndef()
+	}
+	i.globIters[call] = names[1:]
+	return sv.NewString(names[0])
+}
+
+// autoflush - $fh->autoflush(1) (IO::Handle): каждый print сразу уходит в файл.
+// STDOUT/STDERR не буферизуются, для них это ничего не меняет.
+func (i *Interpreter) builtinAutoflush(obj *sv.SV, args []*sv.SV) (*sv.SV, bool) {
+	name := obj.AsString()
+	if name == "STDOUT" || name == "STDERR" {
+		return sv.NewInt(1), true
+	}
+	fh := i.ctx.GetFileHandle(name)
+	if fh == nil {
+		return nil, false
+	}
+	fh.Autoflush = len(args) == 0 || args[0].IsTrue()
+	if fh.Autoflush {
+		fh.Flush()
+	}
+	return sv.NewInt(1), true
+}
+
+// evalScalarExpression вычисляет выражение в скалярном контексте.
+// Это нужно для glob (итератор), getpw*/getgr* (одно поле вместо списка),
+// localtime/gmtime (строка или объект Time::Piece) и keys/values
+// (число ключей без копирования списка).
+func (i *Interpreter) evalScalarExpression(expr ast.Expression) *sv.SV {
+	if i.isGettimeofday(expr) {
+		// скалярный gettimeofday - секунды с дробной частью
+		return sv.NewFloat(hiResNow())
+	}
+	if call, ok := expr.(*ast.CallExpr); ok {
+		if ident, ok := call.Function.(*ast.Identifier); ok {
+			switch ident.Value {
+			case "glob":
+				return i.globNext(call)
+			case "getpwnam", "getpwuid", "getgrnam", "getgrgid":
+				return i.idLookupScalar(call, ident.Value)
+			case "localtime", "gmtime":
+				return i.timeScalar(call, ident.Value)
+			case "unpack":
+				// скалярный unpack - первое значение
+				if values := i.evalExpression(call).Deref().ArrayData(); len(values) > 0 {
+					return values[0]
+				}
+				return sv.NewUndef()
+			case "keys", "values":
+				if len(call.Args) == 0 {
+					return sv.NewInt(0)
+				}
+				return sv.NewInt(int64(hv.Len(i.evalExpression(call.Args[0]))))
+			}
+		}
+	}
+	return i.evalExpression(expr)
+}
+
+func isScalarVar(expr ast.Expression) bool {
+	_, ok := expr.(*ast.ScalarVar)
+	return ok
+}
+
+// perlGlob раскрывает шаблон как File::Glob (csh_glob):
+// пробелы разделяют шаблоны, {a,b} и ~ раскрываются, совпадения сортируются,
+// шаблон без метасимволов возвращается как есть.
+func perlGlob(pattern string) []string {
+	var result []string
+	for _, pat := range strings.Fields(pattern) {
+		for _, p := range expandBraces(pat) {
+			p = expandTilde(p)
+			if !strings.ContainsAny(p, "*?[") {
+				result = append(result, p)
+				continue
+			}
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				continue
+			}
+			// Как в shell: * не совпадает со скрытыми файлами
+			hidden := strings.HasPrefix(filepath.Base(p), ".")
+			sort.Strings(matches)
+			for _, m := range matches {
+				if !hidden && strings.HasPrefix(filepath.Base(m), ".") {
+					continue
+				}
+				result = append(result, m)
+			}
+		}
+	}
+	return result
+}
+
+// expandBraces раскрывает {a,b,c}, включая вложенные: a{b,c{d,e}} -> ab acd ace
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+
+	// Ищем парную скобку и запятые верхнего уровня
+	depth := 0
+	end := -1
+	commas := []int{}
+	for j := start; j < len(pattern) && end < 0; j++ {
+		switch pattern[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, j)
+			}
+		}
+	}
+	if end < 0 {
+		return []string{pattern}
+	}
+	// {} и {x} без запятых остаются литералом
+	if len(commas) == 0 {
+		var result []string
+		for _, rest := range expandBraces(pattern[end+1:]) {
+			result = append(result, pattern[:end+1]+rest)
+		}
+		return result
+	}
+
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	var result []string
+	prev := start + 1
+	for _, c := range append(commas, end) {
+		for _, alt := range expandBraces(prefix + pattern[prev:c] + suffix) {
+			result = append(result, alt)
+		}
+		prev = c + 1
+	}
+	return result
+}
+
+// expandTilde раскрывает ~ и ~user в начале шаблона
+func expandTilde(pattern string) string {
+	if !strings.HasPrefix(pattern, "~") {
+		return pattern
+	}
+	name, rest := pattern[1:], ""
+	if idx := strings.IndexByte(name, '/'); idx >= 0 {
+		name, rest = name[:idx], name[idx:]
+	}
+
+	var home string
+	if name == "" {
+		home = os.Getenv("HOME")
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+	} else if u, err := user.Lookup(name); err == nil {
+		home = u.HomeDir
+	}
+	if home == "" {
+		return pattern
+	}
+	return home + rest
+}
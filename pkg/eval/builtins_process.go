@@ -0,0 +1,302 @@
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/sv"
+	"runtime"
+	"strings"
+)
+
+// shellMeta - если в команде есть эти символы, запускаем через shell (как perl)
+const shellMeta = "$&*(){}[]'\";\\|?<>~`\n"
+
+// commandFor строит exec.Cmd как system LIST / system STRING в perl
+func (i *Interpreter) commandFor(args []*sv.SV) *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(args) == 1 {
+		line := args[0].AsString()
+		if strings.ContainsAny(line, shellMeta) {
+			if runtime.GOOS == "windows" {
+				cmd = exec.Command("cmd", "/C", line)
+			} else {
+				cmd = exec.Command("/bin/sh", "-c", line)
+			}
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				return nil
+			}
+			cmd = exec.Command(fields[0], fields[1:]...)
+		}
+	} else {
+		words := make([]string, len(args))
+		for idx, a := range args {
+			words[idx] = a.AsString()
+		}
+		cmd = exec.Command(words[0], words[1:]...)
+	}
+	// Дочерний процесс видит %ENV, а не исходное окружение
+	cmd.Env = i.ctx.Environ()
+	return cmd
+}
+
+// system - запуск команды и ожидание, возвращает $?
+func (i *Interpreter) builtinSystem(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	rt := context.GetRuntime()
+	cmd := i.commandFor(args)
+	if cmd == nil {
+		rt.SetChildError(-1)
+		return sv.NewInt(-1)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = i.stdout
+	cmd.Stderr = i.stderr
+
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		// Команда не запустилась
+		rt.SetOSError(err)
+		rt.SetChildError(-1)
+		return sv.NewInt(-1)
+	}
+	status := context.ExitStatus(err)
+	rt.SetChildError(status)
+	return sv.NewInt(int64(status))
+}
+
+// exec - запуск команды вместо текущей программы.
+// Go не умеет заменять процесс переносимо, поэтому ждём и выходим с её кодом.
+func (i *Interpreter) builtinExec(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	cmd := i.commandFor(args)
+	if cmd == nil {
+		return sv.NewInt(0)
+	}
+	// Как в perl, exec заменяет процесс командой. Если вывод перенаправлен
+	// внутри программы (SetStdout) или платформа так не умеет, команда
+	// выполняется дочерним процессом, а программа выходит с её статусом
+	i.ctx.FlushAll()
+	if i.stdout == io.Writer(os.Stdout) && i.stderr == io.Writer(os.Stderr) {
+		err := context.ExecProcess(cmd)
+		if err != context.ErrNoExec {
+			// exec вернул управление - ошибка запуска
+			context.GetRuntime().SetOSError(err)
+			return sv.NewInt(0)
+		}
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = i.stdout
+	cmd.Stderr = i.stderr
+
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewInt(0)
+	}
+	i.exit(context.ExitStatus(err) >> 8)
+	return sv.NewInt(0)
+}
+
+// runCommand - `cmd` / qx//: запускает строку команды и возвращает её
+// stdout, stderr идёт как есть. Ставит $?; ok=false, если команда не
+// запустилась
+func (i *Interpreter) runCommand(expr *ast.CommandExpr) (string, bool) {
+	rt := context.GetRuntime()
+	line := i.evalExpression(expr.Command)
+	cmd := i.commandFor([]*sv.SV{line})
+	if cmd == nil {
+		rt.SetChildError(-1)
+		return "", false
+	}
+	var out bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &out
+	cmd.Stderr = i.stderr
+
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		rt.SetOSError(err)
+		rt.SetChildError(-1)
+		return "", false
+	}
+	rt.SetChildError(context.ExitStatus(err))
+	return out.String(), true
+}
+
+// evalCommandExpr - `cmd` в скалярном контексте: весь вывод, undef если
+// команда не запустилась
+func (i *Interpreter) evalCommandExpr(expr *ast.CommandExpr) *sv.SV {
+	out, ok := i.runCommand(expr)
+	if !ok {
+		return sv.NewUndef()
+	}
+	return sv.NewString(out)
+}
+
+// evalCommandList - `cmd` в списочном контексте: вывод, разбитый на
+// записи по $/, как <FH>
+func (i *Interpreter) evalCommandList(expr *ast.CommandExpr) *sv.SV {
+	out, _ := i.runCommand(expr)
+	var lines []*sv.SV
+	scanner := i.ctx.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		lines = append(lines, sv.NewString(scanner.Text()))
+	}
+	return sv.NewArrayRef(lines...)
+}
+
+// open3 - IPC::Open3: open3($in, $out, $err, @cmd), возвращает pid.
+// Если $err - undef или "", stderr идёт в $out, как в perl.
+func (i *Interpreter) builtinOpen3(expr *ast.CallExpr) *sv.SV {
+	if len(expr.Args) < 4 {
+		return sv.NewInt(0)
+	}
+
+	inName := i.open3HandleName(expr.Args[0])
+	outName := i.open3HandleName(expr.Args[1])
+	errName := i.open3HandleName(expr.Args[2])
+
+	var cmdArgs []*sv.SV
+	for _, a := range expr.Args[3:] {
+		cmdArgs = append(cmdArgs, i.evalExpression(a))
+	}
+	cmd := i.commandFor(flattenArgs(cmdArgs))
+	if cmd == nil {
+		return sv.NewInt(0)
+	}
+
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return i.open3Fail(err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return i.open3Fail(err)
+	}
+	cmd.Stdin = inR
+	cmd.Stdout = outW
+	cmd.Stderr = outW
+
+	var errR, errW *os.File
+	if errName != "" {
+		errR, errW, err = os.Pipe()
+		if err != nil {
+			return i.open3Fail(err)
+		}
+		cmd.Stderr = errW
+	}
+
+	pid, err := i.ctx.StartChild(cmd)
+	// Концы дочернего процесса родителю не нужны
+	inR.Close()
+	outW.Close()
+	if errW != nil {
+		errW.Close()
+	}
+	if err != nil {
+		return i.open3Fail(err)
+	}
+
+	i.ctx.AddFileHandle(inName, inW, ">")
+	i.ctx.AddFileHandle(outName, outR, "<")
+	if errR != nil {
+		i.ctx.AddFileHandle(errName, errR, "<")
+	}
+	return sv.NewInt(int64(pid))
+}
+
+// openPipe - open($fh, '-|', CMD...) читает вывод команды, '|-' пишет ей
+// на вход. Возвращает pid; close ждёт команду и выставляет $?
+func (i *Interpreter) openPipe(fhName, mode string, args []*sv.SV) *sv.SV {
+	cmd := i.commandFor(flattenArgs(args))
+	if cmd == nil {
+		return sv.NewInt(0)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, i.stdout, i.stderr
+	pid, err := i.ctx.OpenPipe(fhName, mode, cmd)
+	if err != nil {
+		context.GetRuntime().SetOSError(err)
+		return sv.NewInt(0)
+	}
+	return sv.NewInt(int64(pid))
+}
+
+func (i *Interpreter) open3Fail(err error) *sv.SV {
+	// IPC::Open3 умирает, если не смог запустить команду
+	fmt.Fprintf(i.stderr, "open3: %v\n", err)
+	context.GetRuntime().SetOSError(err)
+	return sv.NewInt(0)
+}
+
+// open3HandleName - имя handle из аргумента open3; "" значит "нет handle"
+func (i *Interpreter) open3HandleName(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.UndefLiteral:
+		return ""
+	case *ast.StringLiteral:
+		return e.Value
+	case *ast.AssignExpr:
+		// my $err = gensym
+		i.evalExpression(e)
+		return i.open3HandleName(e.Left)
+	}
+	return i.openHandleName(expr)
+}
+
+// waitConstants - константы POSIX :sys_wait_h
+var waitConstants = map[string]int64{
+	"WNOHANG":   1,
+	"WUNTRACED": 2,
+}
+
+// waitpid PID, FLAGS - ожидание дочернего процесса, устанавливает $?.
+// PID -1 - любой потомок; с WNOHANG возвращает 0, если никто ещё не завершился.
+func (i *Interpreter) builtinWaitpid(args []*sv.SV) *sv.SV {
+	if len(args) < 1 {
+		return sv.NewInt(-1)
+	}
+	nohang := len(args) > 1 && args[1].AsInt()&waitConstants["WNOHANG"] != 0
+	return i.reapChild(int(args[0].AsInt()), nohang)
+}
+
+// wait - ожидание любого потомка, -1 если потомков нет
+func (i *Interpreter) builtinWait() *sv.SV {
+	return i.reapChild(-1, false)
+}
+
+func (i *Interpreter) reapChild(pid int, nohang bool) *sv.SV {
+	reaped, status := i.ctx.WaitChild(pid, nohang)
+	if reaped > 0 {
+		context.GetRuntime().SetChildError(status)
+	} else if reaped < 0 {
+		context.GetRuntime().SetChildError(-1)
+	}
+	return sv.NewInt(int64(reaped))
+}
+
+// gensym - Symbol::gensym, уникальный анонимный handle
+func (i *Interpreter) builtinGensym() *sv.SV {
+	return sv.NewGlobRef()
+}
+
+// flattenArgs раскрывает массивы в аргументах (system @cmd)
+func flattenArgs(args []*sv.SV) []*sv.SV {
+	var result []*sv.SV
+	for _, a := range args {
+		if a.IsRef() && a.Deref() != nil && a.Deref().IsArray() {
+			result = append(result, a.Deref().ArrayData()...)
+		} else if a.IsArray() {
+			result = append(result, a.ArrayData()...)
+		} else {
+			result = append(result, a)
+		}
+	}
+	return result
+}
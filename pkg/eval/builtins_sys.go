@@ -0,0 +1,123 @@
+package eval
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+	"strconv"
+	"strings"
+)
+
+// Пользователи, группы и имя хоста (getpwnam, getgrnam, Sys::Hostname)
+
+// passwdShell ищет shell пользователя в /etc/passwd (os/user его не отдаёт)
+func passwdShell(name string) string {
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 7 && fields[0] == name {
+			return fields[6]
+		}
+	}
+	return ""
+}
+
+// pwEntry - список как у getpw*:
+// ($name, $passwd, $uid, $gid, $quota, $comment, $gcos, $dir, $shell)
+func pwEntry(u *user.User) *sv.SV {
+	return sv.NewArrayRef(
+		sv.NewString(u.Username),
+		sv.NewString("x"),
+		idValue(u.Uid),
+		idValue(u.Gid),
+		sv.NewString(""),
+		sv.NewString(""),
+		sv.NewString(u.Name),
+		sv.NewString(u.HomeDir),
+		sv.NewString(passwdShell(u.Username)),
+	)
+}
+
+// grEntry - список как у getgr*: ($name, $passwd, $gid, $members)
+func grEntry(g *user.Group) *sv.SV {
+	return sv.NewArrayRef(
+		sv.NewString(g.Name),
+		sv.NewString("x"),
+		idValue(g.Gid),
+		sv.NewString(""),
+	)
+}
+
+// idValue - uid/gid как число (на Windows это SID, оставляем строкой)
+func idValue(id string) *sv.SV {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return sv.NewInt(n)
+	}
+	return sv.NewString(id)
+}
+
+// getpwnam / getpwuid - полная запись, пустой список если пользователь не найден
+func (i *Interpreter) builtinGetpw(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewArrayRef()
+	}
+	var u *user.User
+	var err error
+	if name == "getpwuid" {
+		u, err = user.LookupId(args[0].AsString())
+	} else {
+		u, err = user.Lookup(args[0].AsString())
+	}
+	if err != nil {
+		return sv.NewArrayRef()
+	}
+	return pwEntry(u)
+}
+
+// getgrnam / getgrgid
+func (i *Interpreter) builtinGetgr(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewArrayRef()
+	}
+	var g *user.Group
+	var err error
+	if name == "getgrgid" {
+		g, err = user.LookupGroupId(args[0].AsString())
+	} else {
+		g, err = user.LookupGroup(args[0].AsString())
+	}
+	if err != nil {
+		return sv.NewArrayRef()
+	}
+	return grEntry(g)
+}
+
+// idLookupScalar - getpw*/getgr* в скалярном контексте:
+// getpwnam/getgrnam возвращают id, getpwuid/getgrgid - имя.
+func (i *Interpreter) idLookupScalar(call *ast.CallExpr, name string) *sv.SV {
+	entry := i.evalCallExpr(call)
+	list := i.svToList(entry)
+	if len(list) == 0 {
+		return sv.NewUndef()
+	}
+	if name == "getpwnam" || name == "getgrnam" {
+		return list[2]
+	}
+	return list[0]
+}
+
+// hostname - Sys::Hostname::hostname
+func (i *Interpreter) builtinHostname() *sv.SV {
+	name, err := os.Hostname()
+	if err != nil {
+		return sv.NewUndef()
+	}
+	return sv.NewString(name)
+}
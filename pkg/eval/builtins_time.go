@@ -0,0 +1,334 @@
+package eval
+
+import (
+	"fmt"
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+	"strings"
+	"time"
+)
+
+// Время: time, localtime, gmtime, POSIX::strftime и облегчённый Time::Piece
+// (после use Time::Piece скалярный localtime/gmtime возвращает объект
+// с методами ->year, ->mon, ->strftime и т.д.).
+
+// ctimeLayout - формат скалярного localtime: "Thu Jan  1 00:00:00 1970"
+const ctimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// builtinTime - секунды с эпохи
+func (i *Interpreter) builtinTime() *sv.SV {
+	return sv.NewInt(time.Now().Unix())
+}
+
+// hiResFuncs - функции, которые экспортирует Time::HiRes
+var hiResFuncs = map[string]bool{
+	"time": true, "sleep": true, "usleep": true, "nanosleep": true,
+	"gettimeofday": true, "tv_interval": true,
+}
+
+// hiResNow - Time::HiRes::time: секунды с эпохи с дробной частью
+func hiResNow() float64 {
+	return float64(time.Now().UnixMicro()) / 1e6
+}
+
+// isGettimeofday - вызов Time::HiRes::gettimeofday, в том числе
+// импортированного и без скобок
+func (i *Interpreter) isGettimeofday(expr ast.Expression) bool {
+	var name string
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		name = e.Value
+	case *ast.CallExpr:
+		if ident, ok := e.Function.(*ast.Identifier); ok {
+			name = ident.Value
+		}
+	}
+	return name == "Time::HiRes::gettimeofday" || name == "gettimeofday" && i.hiRes[name]
+}
+
+// gettimeofday в списочном контексте: (секунды, микросекунды)
+func gettimeofday() *sv.SV {
+	now := time.Now().UnixMicro()
+	return sv.NewArrayRef(sv.NewInt(now/1e6), sv.NewInt(now%1e6))
+}
+
+// tvInterval - tv_interval($t0, $t1): секунды между двумя [sec, usec],
+// без $t1 - до текущего момента
+func tvInterval(args []*sv.SV) *sv.SV {
+	micros := func(tv *sv.SV) int64 {
+		if tv == nil || !tv.IsRef() {
+			return time.Now().UnixMicro()
+		}
+		var parts [2]int64
+		for idx, v := range tv.Deref().ArrayData() {
+			if idx < len(parts) {
+				parts[idx] = v.AsInt()
+			}
+		}
+		return parts[0]*1e6 + parts[1]
+	}
+	var t0, t1 *sv.SV
+	if len(args) > 0 {
+		t0 = args[0]
+	}
+	if len(args) > 1 {
+		t1 = args[1]
+	}
+	return sv.NewFloat(float64(micros(t1)-micros(t0)) / 1e6)
+}
+
+// builtinHiResSleep - sleep в секундах, usleep в микро-, nanosleep в
+// наносекундах, дробные значения допустимы; возвращает сколько проспали
+// в тех же единицах
+func (i *Interpreter) builtinHiResSleep(name string, args []*sv.SV) *sv.SV {
+	unit := time.Second
+	switch name {
+	case "Time::HiRes::usleep":
+		unit = time.Microsecond
+	case "Time::HiRes::nanosleep":
+		unit = time.Nanosecond
+	}
+	d := time.Duration(-1)
+	if len(args) > 0 {
+		d = max(0, time.Duration(args[0].AsFloat()*float64(unit)))
+	}
+	return sv.NewFloat(float64(i.sleepFor(d)) / float64(unit))
+}
+
+// timeArg - время из первого аргумента (эпоха) или текущее
+func timeArg(args []*sv.SV, utc bool) time.Time {
+	t := time.Now()
+	if len(args) > 0 && !args[0].IsUndef() {
+		t = time.Unix(args[0].AsInt(), 0)
+	}
+	if utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+// timeList - список как у localtime:
+// ($sec, $min, $hour, $mday, $mon, $year, $wday, $yday, $isdst)
+func timeList(t time.Time) *sv.SV {
+	isdst := int64(0)
+	if t.IsDST() {
+		isdst = 1
+	}
+	return sv.NewArrayRef(
+		sv.NewInt(int64(t.Second())),
+		sv.NewInt(int64(t.Minute())),
+		sv.NewInt(int64(t.Hour())),
+		sv.NewInt(int64(t.Day())),
+		sv.NewInt(int64(t.Month())-1),
+		sv.NewInt(int64(t.Year()-1900)),
+		sv.NewInt(int64(t.Weekday())),
+		sv.NewInt(int64(t.YearDay()-1)),
+		sv.NewInt(isdst),
+	)
+}
+
+// localtime / gmtime в списочном контексте
+func (i *Interpreter) builtinLocaltime(name string, args []*sv.SV) *sv.SV {
+	return timeList(timeArg(args, name == "gmtime"))
+}
+
+// timeScalar - localtime/gmtime в скалярном контексте: строка как у ctime(3),
+// а после use Time::Piece - объект Time::Piece
+func (i *Interpreter) timeScalar(call *ast.CallExpr, name string) *sv.SV {
+	args := make([]*sv.SV, len(call.Args))
+	for idx, arg := range call.Args {
+		args[idx] = i.evalExpression(arg)
+	}
+	utc := name == "gmtime"
+	t := timeArg(args, utc)
+	if i.timePiece {
+		return newTimePiece(t, utc)
+	}
+	return sv.NewString(t.Format(ctimeLayout))
+}
+
+// newTimePiece - blessed хэш {epoch, utc}, остальное считается в методах
+func newTimePiece(t time.Time, utc bool) *sv.SV {
+	obj := sv.NewHashRef()
+	obj.Deref().HashData()["epoch"] = sv.NewInt(t.Unix())
+	obj.Deref().HashData()["utc"] = boolToSV(utc)
+	return obj.Bless("Time::Piece")
+}
+
+// timePieceTime восстанавливает time.Time из объекта Time::Piece
+func timePieceTime(obj *sv.SV) time.Time {
+	data := obj.Deref().HashData()
+	t := time.Unix(data["epoch"].AsInt(), 0)
+	if utc := data["utc"]; utc != nil && utc.IsTrue() {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+// timePieceMethod - встроенные методы Time::Piece
+func (i *Interpreter) timePieceMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	t := timePieceTime(obj)
+	switch method {
+	case "sec", "second":
+		return sv.NewInt(int64(t.Second()))
+	case "min", "minute":
+		return sv.NewInt(int64(t.Minute()))
+	case "hour":
+		return sv.NewInt(int64(t.Hour()))
+	case "mday", "day_of_month":
+		return sv.NewInt(int64(t.Day()))
+	case "mon":
+		return sv.NewInt(int64(t.Month()))
+	case "_mon":
+		return sv.NewInt(int64(t.Month()) - 1)
+	case "monname", "month":
+		return sv.NewString(t.Format("Jan"))
+	case "fullmonth":
+		return sv.NewString(t.Format("January"))
+	case "year":
+		return sv.NewInt(int64(t.Year()))
+	case "_year":
+		return sv.NewInt(int64(t.Year() - 1900))
+	case "yy":
+		return sv.NewInt(int64(t.Year() % 100))
+	case "wday":
+		return sv.NewInt(int64(t.Weekday()) + 1)
+	case "_wday", "day_of_week":
+		return sv.NewInt(int64(t.Weekday()))
+	case "wdayname", "day":
+		return sv.NewString(t.Format("Mon"))
+	case "fullday":
+		return sv.NewString(t.Format("Monday"))
+	case "yday", "day_of_year":
+		return sv.NewInt(int64(t.YearDay() - 1))
+	case "isdst", "daylight_savings":
+		return boolToSV(t.IsDST())
+	case "epoch":
+		return sv.NewInt(t.Unix())
+	case "ymd", "date":
+		return sv.NewString(joinDate(args, "-", "%04d", t.Year(), "%02d", int(t.Month()), "%02d", t.Day()))
+	case "mdy":
+		return sv.NewString(joinDate(args, "-", "%02d", int(t.Month()), "%02d", t.Day(), "%04d", t.Year()))
+	case "dmy":
+		return sv.NewString(joinDate(args, "-", "%02d", t.Day(), "%02d", int(t.Month()), "%04d", t.Year()))
+	case "hms", "time":
+		return sv.NewString(joinDate(args, ":", "%02d", t.Hour(), "%02d", t.Minute(), "%02d", t.Second()))
+	case "datetime":
+		return sv.NewString(t.Format("2006-01-02T15:04:05"))
+	case "cdate":
+		return sv.NewString(t.Format(ctimeLayout))
+	case "strftime":
+		format := "%a, %d %b %Y %H:%M:%S %Z"
+		if len(args) > 0 {
+			format = args[0].AsString()
+		}
+		return sv.NewString(strftime(format, t))
+	}
+	return sv.NewUndef()
+}
+
+// joinDate форматирует три поля через разделитель (первый аргумент метода)
+func joinDate(args []*sv.SV, sep string, parts ...interface{}) string {
+	if len(args) > 0 {
+		sep = args[0].AsString()
+	}
+	fields := make([]string, 0, 3)
+	for j := 0; j+1 < len(parts); j += 2 {
+		fields = append(fields, fmt.Sprintf(parts[j].(string), parts[j+1]))
+	}
+	return strings.Join(fields, sep)
+}
+
+// POSIX::strftime(FMT, sec, min, hour, mday, mon, year, ...) - поля
+// нормализуются как в mktime, wday/yday пересчитываются
+func (i *Interpreter) builtinStrftime(args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	if len(args) == 0 {
+		return sv.NewUndef()
+	}
+	field := func(n int) int {
+		if n < len(args) {
+			return int(args[n].AsInt())
+		}
+		return 0
+	}
+	t := time.Date(field(6)+1900, time.Month(field(5)+1), field(4),
+		field(3), field(2), field(1), 0, time.Local)
+	return sv.NewString(strftime(args[0].AsString(), t))
+}
+
+// strftime - подмножество strftime(3) поверх time.Time
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for j := 0; j < len(format); j++ {
+		if format[j] != '%' || j+1 >= len(format) {
+			b.WriteByte(format[j])
+			continue
+		}
+		j++
+		switch format[j] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'C':
+			fmt.Fprintf(&b, "%02d", t.Year()/100)
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'u':
+			fmt.Fprintf(&b, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 'D':
+			b.WriteString(t.Format("01/02/06"))
+		case 'c':
+			b.WriteString(t.Format(ctimeLayout))
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[j])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/perlstr"
+	"perlc/pkg/sv"
+	"perlc/pkg/warnings"
+)
+
+// carpFuncs - функции Carp: use Carp без списка импортирует croak, carp и
+// confess, cluck - только по имени (use Carp qw(cluck))
+var carpFuncs = map[string]bool{
+	"croak": true, "carp": true, "confess": true, "cluck": true,
+}
+
+// useCarp - use Carp LIST: импортированные имена вызывают Carp::...
+func (i *Interpreter) useCarp(args []ast.Expression) {
+	if i.carp == nil {
+		i.carp = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		i.carp["croak"], i.carp["carp"], i.carp["confess"] = true, true, true
+		return
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); carpFuncs[name] {
+				i.carp[name] = true
+			}
+		}
+	}
+}
+
+// builtinCarp - Carp::croak/confess (die) и Carp::carp/cluck (warn).
+// croak и carp сообщают место вызова sub, из которой их позвали,
+// confess и cluck - весь стек вызовов из context.Runtime.
+func (i *Interpreter) builtinCarp(name string, args []*sv.SV) *sv.SV {
+	fatal := name == "Carp::croak" || name == "Carp::confess"
+	if fatal && len(args) == 1 && args[0].IsRef() {
+		// croak $obj бросает объект как есть
+		return i.builtinDie(args)
+	}
+	msg := ""
+	for _, arg := range args {
+		msg += arg.AsString()
+	}
+	msg = i.carpMessage(msg, name == "Carp::confess" || name == "Carp::cluck")
+	if fatal {
+		return i.builtinDie([]*sv.SV{sv.NewString(msg)})
+	}
+	i.warnings.Print(msg)
+	return sv.NewInt(1)
+}
+
+// carpMessage дописывает к msg место: для короткой формы - первый вызов
+// из пакета, который не доверяет пакету текущей sub (не он сам и не
+// родня по @ISA). Если такого нет, как у croak из main, короткая форма
+// становится длинной: место самого вызова Carp и кадры стека
+// "\tmain::f(1, "a") called at FILE line N".
+func (i *Interpreter) carpMessage(msg string, long bool) string {
+	rt := context.GetRuntime()
+	if !long && rt.CallDepth() > 0 {
+		pkg := framePackage(rt.Caller(0))
+		for level := 0; level < rt.CallDepth(); level++ {
+			if f := rt.Caller(level); !i.carpTrusts(pkg, f.Package) {
+				return msg + " at " + frameWhere(f) + ".\n"
+			}
+		}
+	}
+	pos, _ := ast.PosOf(i.stmt)
+	if where := warnings.Where(pos); where != "" {
+		msg += " at " + where + "."
+	}
+	msg += "\n"
+	for level := 0; level < rt.CallDepth(); level++ {
+		f := rt.Caller(level)
+		call := f.Sub + "(" + carpArgs(f.Args) + ")"
+		switch {
+		case f.IsEval && f.EvalText != "":
+			call = "eval '" + f.EvalText + "'"
+		case f.IsEval:
+			call = "eval {...}"
+		}
+		msg += fmt.Sprintf("\t%s called at %s\n", call, frameWhere(f))
+	}
+	return msg
+}
+
+// carpTrusts - вызов из пакета caller не считается внешним для pkg
+func (i *Interpreter) carpTrusts(pkg, caller string) bool {
+	return i.packageIsa(pkg, caller) || i.packageIsa(caller, pkg)
+}
+
+// carpArgs - аргументы вызова в стеке, как их пишет Carp: числа как есть,
+// строки в кавычках, undef словом
+func carpArgs(args []*sv.SV) string {
+	parts := make([]string, len(args))
+	for n, arg := range args {
+		s := arg.AsString()
+		switch {
+		case arg.IsUndef():
+			s = "undef"
+		case arg.IsRef() || perlstr.LooksLikeNumber(s):
+		default:
+			s = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		parts[n] = s
+	}
+	return strings.Join(parts, ", ")
+}
+
+// frameWhere - "FILE line N" места вызова кадра
+func frameWhere(f *context.StackFrame) string {
+	return warnings.Where(ast.Position{File: f.File, Line: f.Line})
+}
+
+// framePackage - пакет кода, который выполняется в кадре: у sub - её
+// пакет, у eval - пакет того, кто его выполнил
+func framePackage(f *context.StackFrame) string {
+	if f.IsEval {
+		return f.Package
+	}
+	return subPackage(f.Sub)
+}
+
+// subPackage - пакет sub по полному имени: Foo::Bar::baz -> Foo::Bar
+func subPackage(name string) string {
+	if idx := strings.LastIndex(name, "::"); idx >= 0 {
+		return name[:idx]
+	}
+	return "main"
+}
@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"perlc/pkg/sv"
+)
+
+// Perlc::Chan и Perlc::spawn. Интерпретатор однопоточный, поэтому задачи
+// spawn не запускаются сразу, а ставятся в очередь: recv на пустом канале
+// выполняет их по очереди, пока не появится значение, Perlc::wait выполняет
+// все. Канал - неограниченная очередь, send не блокируется. В
+// скомпилированной программе это горутины и каналы Go.
+
+// chanQueue - состояние канала, ключ - хэш объекта Perlc::Chan
+type chanQueue struct {
+	values []*sv.SV
+	closed bool
+}
+
+// task - отложенный вызов Perlc::spawn(CODE, ARGS) или поток threads
+type task struct {
+	code   *sv.SV
+	args   []*sv.SV
+	thread *thread
+}
+
+// builtinSpawn - Perlc::spawn(sub {...}, ARGS): аргументы копируются
+func (i *Interpreter) builtinSpawn(args []*sv.SV) *sv.SV {
+	if len(args) == 0 || args[0].CodeName() == "" {
+		return i.builtinDie([]*sv.SV{sv.NewString("Perlc::spawn needs a code reference")})
+	}
+	i.tasks = append(i.tasks, task{code: args[0], args: i.copyList(i.flattenArgs(args[1:]))})
+	return sv.NewInt(1)
+}
+
+// runTask выполняет следующую задачу из очереди; false - очередь пуста
+func (i *Interpreter) runTask() bool {
+	if len(i.tasks) == 0 {
+		return false
+	}
+	t := i.tasks[0]
+	i.tasks = i.tasks[1:]
+	if t.thread != nil {
+		i.runThread(t.thread)
+		return true
+	}
+	i.callCode(t.code, t.args)
+	return true
+}
+
+// builtinTaskWait - Perlc::wait: выполняет все отложенные задачи
+func (i *Interpreter) builtinTaskWait() *sv.SV {
+	for i.runTask() {
+	}
+	return sv.NewInt(1)
+}
+
+// chanMethod - new, send, recv, close и len объекта Perlc::Chan
+func (i *Interpreter) chanMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	if method == "new" {
+		ch := sv.NewHashRef().Bless("Perlc::Chan")
+		i.chans[ch.Deref()] = &chanQueue{}
+		return ch
+	}
+	q := i.chans[obj.Deref()]
+	if q == nil {
+		return sv.NewUndef()
+	}
+	switch method {
+	case "send":
+		if q.closed {
+			return i.builtinDie([]*sv.SV{sv.NewString("send on closed channel")})
+		}
+		q.values = append(q.values, i.copyList(i.flattenArgs(args))...)
+		return sv.NewInt(1)
+	case "recv":
+		for len(q.values) == 0 && !q.closed && i.runTask() {
+		}
+		if len(q.values) == 0 {
+			return sv.NewUndef()
+		}
+		v := q.values[0]
+		q.values = q.values[1:]
+		return v
+	case "close":
+		q.closed = true
+		return sv.NewInt(1)
+	case "len":
+		return sv.NewInt(int64(len(q.values)))
+	}
+	return sv.NewUndef()
+}
+
+// flattenArgs разворачивает массивы в списке аргументов
+func (i *Interpreter) flattenArgs(args []*sv.SV) []*sv.SV {
+	var out []*sv.SV
+	for _, arg := range args {
+		if arg.IsArray() {
+			out = append(out, arg.ArrayData()...)
+		} else {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
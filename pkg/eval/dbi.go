@@ -0,0 +1,599 @@
+package eval
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"perlc/pkg/sv"
+)
+
+// DBI поверх database/sql. DBI->connect("dbi:Driver:...") открывает базу
+// драйвером database/sql, который слинкован в программу (пустой импорт
+// пакета драйвера); dbiDrivers сопоставляет имена DBD именам драйверов Go,
+// а имя, которого там нет, берётся как есть. Объекты DBI::db и DBI::st -
+// благословлённые хэши с атрибутами (RaiseError, PrintError, AutoCommit,
+// Statement, NAME), их соединение и запрос хранятся по хэшу объекта.
+
+// dbiDrivers - драйверы database/sql, которые подходят для DBD, по порядку
+var dbiDrivers = map[string][]string{
+	"SQLite":  {"sqlite3", "sqlite"},
+	"Pg":      {"postgres", "pgx"},
+	"mysql":   {"mysql"},
+	"MariaDB": {"mysql"},
+	"ODBC":    {"odbc"},
+	"Oracle":  {"godror", "oracle"},
+}
+
+// dbiConn - соединение объекта DBI::db
+type dbiConn struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	driver string // имя DBD из DSN: SQLite, Pg...
+	attrs  *sv.SV // хэш объекта
+	work   bool   // транзакция начата begin_work
+	err    string // $dbh->errstr
+	lastID int64  // last_insert_id последнего INSERT
+}
+
+// dbiStmt - запрос объекта DBI::st; rows - курсор после execute
+type dbiStmt struct {
+	conn  *dbiConn
+	attrs *sv.SV
+	query string
+	stmt  *sql.Stmt
+	rows  *sql.Rows
+	cols  []string
+	count int64
+	err   string
+	// shared - запрос чужого DBI::st, его не закрывать
+	shared bool
+}
+
+// dbiMethod - методы класса DBI и объектов DBI::db и DBI::st
+func (i *Interpreter) dbiMethod(obj *sv.SV, class, method string, args []*sv.SV) *sv.SV {
+	arg := func(n int) *sv.SV {
+		if n < len(args) {
+			return args[n]
+		}
+		return sv.NewUndef()
+	}
+	// Ошибка последнего вызова сбрасывается, как в DBI; err и errstr
+	// только читают её
+	if method != "err" && method != "errstr" {
+		i.ctx.SetVar("$DBI::err", sv.NewUndef())
+		i.ctx.SetVar("$DBI::errstr", sv.NewUndef())
+	}
+	switch class {
+	case "DBI":
+		switch method {
+		case "connect":
+			return i.dbiConnect(arg(0).AsString(), arg(1), arg(2), arg(3))
+		case "err", "errstr":
+			return i.ctx.GetVar("$DBI::" + method)
+		}
+	case "DBI::db":
+		if c := i.dbConns[obj.Deref()]; c != nil {
+			if result, ok := i.dbiConnMethod(obj, c, method, args); ok {
+				return result
+			}
+		}
+	case "DBI::st":
+		if s := i.dbStmts[obj.Deref()]; s != nil {
+			if result, ok := i.dbiStmtMethod(s, method, args); ok {
+				return result
+			}
+		}
+	}
+	return i.builtinDie([]*sv.SV{sv.NewString(`Can't locate object method "` + method + `" via package "` + class + `"` + i.at() + ".\n")})
+}
+
+// dbiConnect - DBI->connect(DSN, USER, PASS, \%attr): undef, если база
+// не открылась; без драйвера - die, как install_driver в DBI
+func (i *Interpreter) dbiConnect(dsn string, user, pass, attr *sv.SV) *sv.SV {
+	attrs := sv.NewHashRef()
+	h := attrs.Deref().HashData()
+	h["PrintError"] = sv.NewInt(1)
+	h["RaiseError"] = sv.NewInt(0)
+	h["AutoCommit"] = sv.NewInt(1)
+	if attr.IsRef() && attr.Deref().IsHash() {
+		for k, v := range attr.Deref().HashData() {
+			h[k] = sv.NewUndef()
+			h[k].CopyFrom(v)
+		}
+	}
+	parts := strings.SplitN(dsn, ":", 3)
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "dbi") || parts[1] == "" {
+		return i.builtinDie([]*sv.SV{sv.NewString("Can't connect to data source '" + dsn + "' because I can't work out what driver to use (it doesn't seem to contain a 'dbi:driver:' prefix)" + i.at() + ".\n")})
+	}
+	driver, rest := parts[1], parts[2]
+	goDriver, ok := dbiDriver(driver)
+	if !ok {
+		return i.builtinDie([]*sv.SV{sv.NewString("install_driver(" + driver + ") failed: no database/sql driver for DBD::" + driver + " is linked in" + i.at() + ".\n")})
+	}
+	db, err := sql.Open(goDriver, dbiDataSource(driver, rest, user.AsString(), pass.AsString()))
+	if err == nil {
+		if err = db.Ping(); err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		var errstr string
+		what := "DBI connect('" + rest + "','" + user.AsString() + "',...)"
+		return i.dbiFail(attrs, &errstr, what, err)
+	}
+	dbh := attrs.Bless("DBI::db")
+	h["Driver"] = sv.NewString(driver)
+	h["Name"] = sv.NewString(rest)
+	if i.dbConns == nil {
+		i.dbConns = make(map[*sv.SV]*dbiConn)
+	}
+	i.dbConns[dbh.Deref()] = &dbiConn{db: db, driver: driver, attrs: dbh}
+	return dbh
+}
+
+// dbiDriver - драйвер database/sql для DBD, из зарегистрированных
+func dbiDriver(name string) (string, bool) {
+	registered := make(map[string]bool)
+	for _, d := range sql.Drivers() {
+		registered[d] = true
+	}
+	for _, d := range append(dbiDrivers[name], name, strings.ToLower(name)) {
+		if registered[d] {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// dbiDataSource переводит DSN DBI ("dbname=x;host=y") в строку соединения
+// драйвера Go: имя файла для SQLite, "key=value ..." для Pg и
+// user:pass@tcp(host:port)/db для mysql; остальные получают её как есть
+func dbiDataSource(driver, rest, user, pass string) string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(rest, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	dbname := params["dbname"]
+	for _, k := range []string{"database", "db"} {
+		if dbname == "" {
+			dbname = params[k]
+		}
+	}
+	switch driver {
+	case "SQLite":
+		if dbname == "" {
+			dbname = rest
+		}
+		if dbname == ":memory:" || dbname == "" {
+			// Одна база в памяти на все соединения пула
+			return "file::memory:?cache=shared"
+		}
+		return dbname
+	case "Pg":
+		var conn []string
+		for _, p := range strings.Split(rest, ";") {
+			if p = strings.TrimSpace(p); p != "" {
+				conn = append(conn, p)
+			}
+		}
+		if user != "" {
+			conn = append(conn, "user="+user)
+		}
+		if pass != "" {
+			conn = append(conn, "password="+pass)
+		}
+		return strings.Join(conn, " ")
+	case "mysql", "MariaDB":
+		if dbname == "" && !strings.Contains(rest, "=") {
+			dbname = rest
+		}
+		addr := ""
+		if sock := params["mysql_socket"]; sock != "" {
+			addr = "unix(" + sock + ")"
+		} else if host := params["host"]; host != "" {
+			if port := params["port"]; port != "" {
+				host += ":" + port
+			}
+			addr = "tcp(" + host + ")"
+		}
+		auth := user
+		if pass != "" {
+			auth += ":" + pass
+		}
+		return auth + "@" + addr + "/" + dbname
+	}
+	return rest
+}
+
+// dbiFail - ошибка метода: $DBI::err и $DBI::errstr, errstr объекта,
+// warn при PrintError и die при RaiseError, как в DBI; результат - undef
+func (i *Interpreter) dbiFail(attrs *sv.SV, errstr *string, what string, err error) *sv.SV {
+	*errstr = err.Error()
+	i.ctx.SetVar("$DBI::err", sv.NewInt(1))
+	i.ctx.SetVar("$DBI::errstr", sv.NewString(*errstr))
+	msg := []*sv.SV{sv.NewString(what + " failed: " + *errstr + i.at() + ".\n")}
+	if dbiFlag(attrs, "PrintError") {
+		i.builtinWarn(msg)
+	}
+	if dbiFlag(attrs, "RaiseError") {
+		i.builtinDie(msg)
+	}
+	return sv.NewUndef()
+}
+
+// dbiFlag - истинен ли атрибут в хэше объекта
+func dbiFlag(attrs *sv.SV, name string) bool {
+	v, ok := attrs.Deref().HashData()[name]
+	return ok && v.IsTrue()
+}
+
+// dbiConnMethod - методы DBI::db; false - такого метода нет
+func (i *Interpreter) dbiConnMethod(dbh *sv.SV, c *dbiConn, method string, args []*sv.SV) (*sv.SV, bool) {
+	arg := func(n int) *sv.SV {
+		if n < len(args) {
+			return args[n]
+		}
+		return sv.NewUndef()
+	}
+	what := "DBD::" + c.driver + "::db " + method
+	if method != "err" && method != "errstr" {
+		c.err = ""
+	}
+	switch method {
+	case "prepare":
+		s := i.dbiPrepare(c, what, arg(0).AsString())
+		if s == nil {
+			return sv.NewUndef(), true
+		}
+		sth := s.attrs.Bless("DBI::st")
+		if i.dbStmts == nil {
+			i.dbStmts = make(map[*sv.SV]*dbiStmt)
+		}
+		i.dbStmts[sth.Deref()] = s
+		return sth, true
+	case "do":
+		s := i.dbiStatement(c, what, arg(0))
+		if s == nil {
+			return sv.NewUndef(), true
+		}
+		defer s.close()
+		return i.dbiExecute(s, what, args[min(2, len(args)):]), true
+	case "selectrow_array", "selectrow_arrayref", "selectrow_hashref", "selectall_arrayref":
+		s := i.dbiStatement(c, what, arg(0))
+		if s != nil {
+			defer s.close()
+		}
+		if s == nil || !i.dbiExecute(s, what, args[min(2, len(args)):]).IsTrue() {
+			if method == "selectrow_array" {
+				return sv.NewArrayRef(), true
+			}
+			return sv.NewUndef(), true
+		}
+		switch method {
+		case "selectrow_array":
+			row := i.dbiFetch(s, what)
+			return sv.NewArrayRef(row...), true
+		case "selectrow_arrayref":
+			return dbiRowRef(i.dbiFetch(s, what)), true
+		case "selectrow_hashref":
+			return dbiRowHash(s.cols, i.dbiFetch(s, what)), true
+		}
+		var slice *sv.SV
+		if a := arg(1); a.IsRef() && a.Deref().IsHash() {
+			slice = a.Deref().HashData()["Slice"]
+		}
+		return i.dbiFetchAll(s, what, slice), true
+	case "begin_work":
+		if !dbiFlag(c.attrs, "AutoCommit") {
+			return i.dbiFail(c.attrs, &c.err, what, errors.New("Already in a transaction")), true
+		}
+		tx, err := c.db.Begin()
+		if err != nil {
+			return i.dbiFail(c.attrs, &c.err, what, err), true
+		}
+		c.tx, c.work = tx, true
+		c.attrs.Deref().HashData()["AutoCommit"] = sv.NewInt(0)
+		return sv.NewInt(1), true
+	case "commit", "rollback":
+		if c.tx == nil {
+			return sv.NewInt(1), true
+		}
+		var err error
+		if method == "commit" {
+			err = c.tx.Commit()
+		} else {
+			err = c.tx.Rollback()
+		}
+		c.tx = nil
+		if c.work {
+			c.work = false
+			c.attrs.Deref().HashData()["AutoCommit"] = sv.NewInt(1)
+		}
+		if err != nil {
+			return i.dbiFail(c.attrs, &c.err, what, err), true
+		}
+		return sv.NewInt(1), true
+	case "last_insert_id":
+		return sv.NewInt(c.lastID), true
+	case "quote":
+		if arg(0).IsUndef() {
+			return sv.NewString("NULL"), true
+		}
+		return sv.NewString("'" + strings.ReplaceAll(arg(0).AsString(), "'", "''") + "'"), true
+	case "ping":
+		return boolToSV(c.db.Ping() == nil), true
+	case "disconnect":
+		if c.tx != nil {
+			c.tx.Rollback()
+			c.tx = nil
+		}
+		c.db.Close()
+		delete(i.dbConns, dbh.Deref())
+		return sv.NewInt(1), true
+	case "err":
+		if c.err == "" {
+			return sv.NewUndef(), true
+		}
+		return sv.NewInt(1), true
+	case "errstr":
+		if c.err == "" {
+			return sv.NewUndef(), true
+		}
+		return sv.NewString(c.err), true
+	}
+	return nil, false
+}
+
+// dbiPrepare готовит запрос; nil, если драйвер его не принял
+func (i *Interpreter) dbiPrepare(c *dbiConn, what, query string) *dbiStmt {
+	c.attrs.Deref().HashData()["Statement"] = sv.NewString(query)
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		i.dbiFail(c.attrs, &c.err, what, err)
+		return nil
+	}
+	attrs := sv.NewHashRef()
+	attrs.Deref().HashData()["Statement"] = sv.NewString(query)
+	return &dbiStmt{conn: c, attrs: attrs, query: query, stmt: stmt}
+}
+
+// dbiStatement - запрос методов do и select*: из SQL, он закрывается
+// после вызова, или копия готового DBI::st, которую закрывать не нужно
+func (i *Interpreter) dbiStatement(c *dbiConn, what string, query *sv.SV) *dbiStmt {
+	if query.IsRef() && query.Package() == "DBI::st" {
+		if s := i.dbStmts[query.Deref()]; s != nil {
+			return &dbiStmt{conn: c, attrs: s.attrs, query: s.query, stmt: s.stmt, shared: true}
+		}
+		return nil
+	}
+	return i.dbiPrepare(c, what, query.AsString())
+}
+
+// dbiReturnsRows - отдаёт ли запрос строки: SELECT и подобные, или с
+// RETURNING; остальные выполняются через Exec, чтобы узнать число строк
+func dbiReturnsRows(query string) bool {
+	upper := strings.ToUpper(query)
+	fields := strings.Fields(strings.TrimLeft(upper, " \t\r\n("))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "SELECT", "WITH", "PRAGMA", "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "VALUES":
+		return true
+	}
+	return strings.Contains(upper, "RETURNING")
+}
+
+// dbiStmtMethod - методы DBI::st; false - такого метода нет
+func (i *Interpreter) dbiStmtMethod(s *dbiStmt, method string, args []*sv.SV) (*sv.SV, bool) {
+	what := "DBD::" + s.conn.driver + "::st " + method
+	if method != "err" && method != "errstr" {
+		s.err = ""
+	}
+	switch method {
+	case "execute":
+		return i.dbiExecute(s, what, args), true
+	case "fetchrow_arrayref", "fetch":
+		return dbiRowRef(i.dbiFetch(s, what)), true
+	case "fetchrow_array":
+		return sv.NewArrayRef(i.dbiFetch(s, what)...), true
+	case "fetchrow_hashref":
+		return dbiRowHash(s.cols, i.dbiFetch(s, what)), true
+	case "fetchall_arrayref":
+		var slice *sv.SV
+		if len(args) > 0 {
+			slice = args[0]
+		}
+		return i.dbiFetchAll(s, what, slice), true
+	case "finish":
+		s.finish()
+		return sv.NewInt(1), true
+	case "rows":
+		return sv.NewInt(s.count), true
+	case "err":
+		if s.err == "" {
+			return sv.NewUndef(), true
+		}
+		return sv.NewInt(1), true
+	case "errstr":
+		if s.err == "" {
+			return sv.NewUndef(), true
+		}
+		return sv.NewString(s.err), true
+	}
+	return nil, false
+}
+
+// dbiExecute выполняет запрос со значениями для "?": запрос со строками
+// открывает курсор и отдаёт "0E0", остальные - число изменённых строк
+// ("0E0" для нуля, истинный ноль DBI)
+func (i *Interpreter) dbiExecute(s *dbiStmt, what string, args []*sv.SV) *sv.SV {
+	s.finish()
+	c := s.conn
+	binds := make([]any, 0, len(args))
+	for _, a := range i.flattenArgs(args) {
+		binds = append(binds, dbiBind(a))
+	}
+	if c.tx == nil && !dbiFlag(c.attrs, "AutoCommit") {
+		tx, err := c.db.Begin()
+		if err != nil {
+			return i.dbiFail(c.attrs, &s.err, what, err)
+		}
+		c.tx = tx
+	}
+	stmt := s.stmt
+	if c.tx != nil {
+		stmt = c.tx.Stmt(stmt)
+	}
+	if dbiReturnsRows(s.query) {
+		rows, err := stmt.Query(binds...)
+		if err != nil {
+			return i.dbiFail(c.attrs, &s.err, what, err)
+		}
+		s.rows = rows
+		s.cols, _ = rows.Columns()
+		names := make([]*sv.SV, len(s.cols))
+		for n, col := range s.cols {
+			names[n] = sv.NewString(col)
+		}
+		h := s.attrs.Deref().HashData()
+		h["NAME"] = sv.NewArrayRef(names...)
+		h["NUM_OF_FIELDS"] = sv.NewInt(int64(len(s.cols)))
+		return sv.NewString("0E0")
+	}
+	res, err := stmt.Exec(binds...)
+	if err != nil {
+		return i.dbiFail(c.attrs, &s.err, what, err)
+	}
+	s.count, _ = res.RowsAffected()
+	if id, err := res.LastInsertId(); err == nil {
+		c.lastID = id
+	}
+	if s.count == 0 {
+		return sv.NewString("0E0")
+	}
+	return sv.NewInt(s.count)
+}
+
+// dbiBind - значение Perl для "?" запроса: undef - NULL
+func dbiBind(v *sv.SV) any {
+	switch v.Type() {
+	case sv.TypeUndef:
+		return nil
+	case sv.TypeInt:
+		return v.AsInt()
+	case sv.TypeFloat:
+		return v.AsFloat()
+	}
+	return v.AsString()
+}
+
+// dbiFetch - следующая строка курсора; nil после последней, тогда курсор
+// закрывается
+func (i *Interpreter) dbiFetch(s *dbiStmt, what string) []*sv.SV {
+	if s.rows == nil {
+		return nil
+	}
+	if !s.rows.Next() {
+		err := s.rows.Err()
+		s.finish()
+		if err != nil {
+			i.dbiFail(s.conn.attrs, &s.err, what, err)
+		}
+		return nil
+	}
+	values := make([]any, len(s.cols))
+	ptrs := make([]any, len(s.cols))
+	for n := range values {
+		ptrs[n] = &values[n]
+	}
+	if err := s.rows.Scan(ptrs...); err != nil {
+		s.finish()
+		i.dbiFail(s.conn.attrs, &s.err, what, err)
+		return nil
+	}
+	s.count++
+	row := make([]*sv.SV, len(values))
+	for n, v := range values {
+		row[n] = dbiValue(v)
+	}
+	return row
+}
+
+// dbiFetchAll - все оставшиеся строки: ссылки на массивы, а со срезом
+// {} - на хэши по именам столбцов
+func (i *Interpreter) dbiFetchAll(s *dbiStmt, what string, slice *sv.SV) *sv.SV {
+	hashes := slice != nil && slice.IsRef() && slice.Deref().IsHash()
+	var rows []*sv.SV
+	for row := i.dbiFetch(s, what); row != nil; row = i.dbiFetch(s, what) {
+		if hashes {
+			rows = append(rows, dbiRowHash(s.cols, row))
+		} else {
+			rows = append(rows, dbiRowRef(row))
+		}
+	}
+	return sv.NewArrayRef(rows...)
+}
+
+// dbiValue - значение столбца для Perl: NULL - undef
+func dbiValue(v any) *sv.SV {
+	switch v := v.(type) {
+	case nil:
+		return sv.NewUndef()
+	case int64:
+		return sv.NewInt(v)
+	case float64:
+		return sv.NewFloat(v)
+	case bool:
+		if v {
+			return sv.NewInt(1)
+		}
+		return sv.NewInt(0)
+	case []byte:
+		return sv.NewString(string(v))
+	case string:
+		return sv.NewString(v)
+	case time.Time:
+		return sv.NewString(v.Format("2006-01-02 15:04:05"))
+	}
+	return sv.NewString(fmt.Sprint(v))
+}
+
+func dbiRowRef(row []*sv.SV) *sv.SV {
+	if row == nil {
+		return sv.NewUndef()
+	}
+	return sv.NewArrayRef(row...)
+}
+
+func dbiRowHash(cols []string, row []*sv.SV) *sv.SV {
+	if row == nil {
+		return sv.NewUndef()
+	}
+	h := sv.NewHashRef()
+	for n, col := range cols {
+		h.Deref().HashData()[col] = row[n]
+	}
+	return h
+}
+
+// finish закрывает курсор запроса
+func (s *dbiStmt) finish() {
+	if s.rows != nil {
+		s.rows.Close()
+		s.rows = nil
+	}
+}
+
+// close освобождает запрос, подготовленный для одного вызова
+func (s *dbiStmt) close() {
+	s.finish()
+	if !s.shared {
+		s.stmt.Close()
+	}
+}
@@ -0,0 +1,188 @@
+package eval
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memDriver is a database/sql driver for the DBI tests, registered as
+// sqlite3 so that dbi:SQLite finds it. Each database holds one table of
+// (id, name) rows and understands four statements: INSERT INTO t VALUES
+// (?, ?), SELECT id, name FROM t with an optional WHERE id = ?, and
+// DELETE FROM t.
+type memDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*memDB
+}
+
+type memDB struct {
+	mu   sync.Mutex
+	rows [][]driver.Value
+}
+
+type memConn struct {
+	db    *memDB
+	saved [][]driver.Value // the rows at Begin, for Rollback
+	inTx  bool
+}
+
+type memStmt struct {
+	conn  *memConn
+	query string
+}
+
+type memRows struct {
+	rows [][]driver.Value
+}
+
+func init() {
+	sql.Register("sqlite3", &memDriver{dbs: map[string]*memDB{}})
+}
+
+func (d *memDriver) Open(name string) (driver.Conn, error) {
+	if strings.HasPrefix(name, "missing/") {
+		return nil, errors.New("unable to open database file")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dbs[name] == nil {
+		d.dbs[name] = &memDB{}
+	}
+	return &memConn{db: d.dbs[name]}, nil
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO t VALUES"),
+		strings.HasPrefix(query, "SELECT id, name FROM t"),
+		query == "DELETE FROM t":
+		return &memStmt{conn: c, query: query}, nil
+	}
+	return nil, errors.New(`near "` + strings.Fields(query + " ?")[0] + `": syntax error`)
+}
+
+func (c *memConn) Close() error { return nil }
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.saved = append([][]driver.Value(nil), c.db.rows...)
+	c.db.mu.Unlock()
+	c.inTx = true
+	return c, nil
+}
+
+func (c *memConn) Commit() error {
+	c.inTx = false
+	return nil
+}
+
+func (c *memConn) Rollback() error {
+	c.db.mu.Lock()
+	c.db.rows = c.saved
+	c.db.mu.Unlock()
+	c.inTx = false
+	return nil
+}
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if s.query == "DELETE FROM t" {
+		n := len(db.rows)
+		db.rows = nil
+		return driver.RowsAffected(n), nil
+	}
+	db.rows = append(db.rows, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	rows := &memRows{}
+	for _, row := range db.rows {
+		if len(args) == 0 || row[0] == args[0] {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (r *memRows) Columns() []string { return []string{"id", "name"} }
+func (r *memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestDBI(t *testing.T) {
+	output, _ := evalInput(`use DBI;
+my $dbh = DBI->connect("dbi:SQLite:dbname=people", "", "", { RaiseError => 1, PrintError => 0 });
+print $dbh->do("INSERT INTO t VALUES (?, ?)", undef, 1, "alice"), "\n";
+my $sth = $dbh->prepare("INSERT INTO t VALUES (?, ?)");
+$sth->execute(2, "bob");
+$sth->execute(3, undef);
+$sth = $dbh->prepare("SELECT id, name FROM t");
+$sth->execute;
+print join(",", @{$sth->{NAME}}), "\n";
+while (my $row = $sth->fetchrow_hashref) {
+    print $row->{id}, "=", defined($row->{name}) ? $row->{name} : "NULL", "\n";
+}
+print $sth->rows, "\n";
+my $all = $dbh->selectall_arrayref("SELECT id, name FROM t WHERE id = ?", undef, 2);
+print scalar(@$all), " ", $all->[0][1], "\n";
+my $hashes = $dbh->selectall_arrayref("SELECT id, name FROM t", { Slice => {} });
+print $hashes->[0]{name}, "\n";
+$sth->execute;
+my $rows = $sth->fetchall_arrayref;
+print scalar(@$rows), "\n";
+$dbh->begin_work;
+$dbh->do("DELETE FROM t");
+$dbh->rollback;
+my ($id, $name) = $dbh->selectrow_array("SELECT id, name FROM t WHERE id = ?", undef, 1);
+print "$id $name\n";
+print $dbh->quote("it's"), "\n";
+eval { $dbh->prepare("DROP TABLE t") };
+print $@;
+print $DBI::errstr, "\n";
+$dbh->{RaiseError} = 0;
+print defined($dbh->prepare("DROP TABLE t")) ? "sth" : "undef", " ", $dbh->errstr, "\n";
+my $bad = DBI->connect("dbi:SQLite:dbname=missing/x", "", "", { PrintError => 0 });
+print defined($bad) ? "connected" : "failed: $DBI::errstr", "\n";
+$dbh->disconnect;
+`)
+	want := `1
+id,name
+1=alice
+2=bob
+3=NULL
+3
+1 bob
+alice
+3
+1 alice
+'it''s'
+DBD::SQLite::db prepare failed: near "DROP": syntax error at <input> line 27.
+near "DROP": syntax error
+undef near "DROP": syntax error
+failed: unable to open database file
+`
+	if output != want {
+		t.Errorf("got:\n%s\nwant:\n%s", output, want)
+	}
+}
@@ -2,17 +2,25 @@
 package eval
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"perlc/pkg/ast"
 	"perlc/pkg/av"
 	"perlc/pkg/context"
 	"perlc/pkg/hv"
+	"perlc/pkg/interpolate"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/perlre"
+	"perlc/pkg/perlrt"
+	"perlc/pkg/perlstr"
 	"perlc/pkg/sv"
+	"perlc/pkg/tunables"
+	"perlc/pkg/warnings"
 )
 
 // Interpreter executes Perl AST.
@@ -20,26 +28,145 @@ type Interpreter struct {
 	ctx    *context.Context
 	stdout io.Writer
 	stderr io.Writer
+
+	// Pending results of scalar-context glob(), keyed by call site
+	globIters map[*ast.CallExpr][]string
+
+	// Counter for anonymous subs, registered as __ANON__N
+	anonCount int
+	// Counter for eval STRING, named "(eval N)" in errors
+	evalCount int
+	// Scope chains captured by anonymous subs (closures), keyed by __ANON__N
+	closures map[string][]map[string]*sv.SV
+	// Patterns compiled once under /o, keyed by their AST node
+	onceRegex map[ast.Expression]*perlre.Regexp
+	// Set while a %SIG handler runs, so it is not re-entered
+	inSignal bool
+	// Set by SetReload: a HUP without handler restarts the program
+	reload bool
+
+	// Restore actions for local, undone when the enclosing block exits
+	locals []func()
+	// Set by use Time::Piece: scalar localtime/gmtime return objects
+	timePiece bool
+	// Names imported by use Time::HiRes: time, sleep, usleep... call the
+	// Time::HiRes versions
+	hiRes map[string]bool
+	// Names imported by use Scalar::Util: blessed, reftype, weaken...
+	scalarUtil map[string]bool
+	// Names imported by use List::Util: sum, max, first, reduce...
+	listUtil map[string]bool
+	// Names imported by use POSIX: floor, ceil, INT_MAX...
+	posix map[string]bool
+	// Names imported by use Getopt::Long and use Getopt::Std
+	getopt map[string]bool
+	// Names imported by use File::Basename, File::Path and Cwd
+	fileFuncs map[string]bool
+	// Names imported by use Storable: store, retrieve, freeze, thaw...
+	storable map[string]bool
+	// Names imported by use Carp: croak, carp, confess, cluck
+	carp map[string]bool
+	// Set by use perlc::parallel: parallel_map and parallel_foreach
+	parallel bool
+	// Set by use Devel::Size: size and total_size without the package
+	develSize bool
+	// Значения use constant: NAME и NAME() возвращают их без вызова sub
+	constants map[string]*sv.SV
+	// Сигнатуры sub f($x, $y = 10, @rest) по имени подпрограммы
+	signatures map[string][]*ast.Param
+	// Каналы Perlc::Chan и отложенные задачи Perlc::spawn
+	chans map[*sv.SV]*chanQueue
+	tasks []task
+	// Потоки threads по хэшу объекта, все по порядку tid и текущий
+	threads    map[*sv.SV]*thread
+	threadList []*thread
+	thread     *thread
+	// Форматы write по имени
+	formats map[string]*format
+	// Соединения DBI::db и запросы DBI::st по хэшу объекта
+	dbConns map[*sv.SV]*dbiConn
+	dbStmts map[*sv.SV]*dbiStmt
+	// Настройки PERLC_*, глубина вызовов sub и кэш скомпилированных шаблонов
+	tune       tunables.Tunables
+	depth      int
+	regexCache map[string]*perlre.Regexp
+	// Выполняемый оператор - для отчёта о падении (--report)
+	stmt ast.Statement
+	// Разобранный код замены s///e, по узлу подстановки
+	substCode map[*ast.SubstExpr][]ast.Statement
+	// Все предупреждения идут через него: повторы, категории, уровень
+	warnings *warnings.Reporter
+	// Текущий пакет (package NAME;) и операции use overload по пакетам
+	pkg       string
+	overloads map[string]map[string]*sv.SV
+	// Sub, импортированные use Module: "main::name" -> "Module::name"
+	imports map[string]string
+	// Пакет, в котором объявлена sub: в нём выполняется её тело
+	subPkgs map[string]string
+	// Глубина загрузки файлов require/use: их sub видят область файла
+	loading int
 }
 
 // New creates a new interpreter.
 func New() *Interpreter {
 	return &Interpreter{
-		ctx:    context.New(),
-		stdout: os.Stdout,
-		stderr: os.Stderr,
+		ctx:        context.New(),
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		globIters:  make(map[*ast.CallExpr][]string),
+		closures:   make(map[string][]map[string]*sv.SV),
+		onceRegex:  make(map[ast.Expression]*perlre.Regexp),
+		constants:  make(map[string]*sv.SV),
+		signatures: make(map[string][]*ast.Param),
+		chans:      make(map[*sv.SV]*chanQueue),
+		tune:       tunables.Default(),
+		regexCache: make(map[string]*perlre.Regexp),
+		substCode:  make(map[*ast.SubstExpr][]ast.Statement),
+		warnings:   warnings.New(os.Stderr, tunables.Default().Warnings),
+		pkg:        "main",
+		overloads:  make(map[string]map[string]*sv.SV),
+		imports:    make(map[string]string),
+		subPkgs:    make(map[string]string),
 	}
 }
 
-var interpolateRe = regexp.MustCompile(`\$(\w+)\[([^\]]+)\]|\$(\w+)\{([^}]+)\}|\$\{(\w+)\}|\$(\w+)|@(\w+)`)
+// bareCallBuiltins are builtins called without parentheses or arguments
+// (my $host = hostname; my $pid = wait;), which parse as barewords.
+var bareCallBuiltins = map[string]bool{
+	"hostname": true,
+	"wait":     true,
+}
+
+// selfEvalBuiltins evaluate their own argument expressions (filehandles,
+// blocks, lvalues); evaluating them up front too would run $f->() twice.
+var selfEvalBuiltins = map[string]bool{
+	"print": true, "say": true, "printf": true, "grep": true, "map": true,
+	"exists": true, "delete": true, "chomp": true, "chop": true,
+	"pop": true, "shift": true, "scalar": true, "pack": true,
+	"die": true, "Carp::croak": true, "Carp::confess": true, "write": true,
+}
 
 // SetStdout sets the output writer.
 func (i *Interpreter) SetStdout(w io.Writer) {
 	i.stdout = w
 }
 
+// SetStderr sets the writer for STDERR, warn and die messages.
+func (i *Interpreter) SetStderr(w io.Writer) {
+	i.stderr = w
+	i.warnings.SetOutput(w)
+}
+
+// SetProgram sets $0 to the script and @ARGV to its arguments.
+func (i *Interpreter) SetProgram(script string, args []string) {
+	i.ctx.SetProgram(script, args)
+}
+
 // Eval evaluates a program and returns the last value.
+// Handles left open by the program are flushed when it finishes.
 func (i *Interpreter) Eval(program *ast.Program) *sv.SV {
+	defer i.ctx.FlushAll()
+	i.declareFormats(program.Statements)
 	var result *sv.SV
 	for _, stmt := range program.Statements {
 		result = i.evalStatement(stmt)
@@ -50,24 +177,88 @@ func (i *Interpreter) Eval(program *ast.Program) *sv.SV {
 	return result
 }
 
+// EvalStream runs the program p parses one statement at a time, each as
+// soon as it is parsed, so a huge generated file or an endless stream from
+// stdin is never held as a whole AST. It stops at the first parse error
+// and returns the errors; the statements before it have run. Subs must be
+// declared before the statements that call them run.
+func (i *Interpreter) EvalStream(p *parser.Parser) []string {
+	defer i.ctx.FlushAll()
+	for {
+		stmt := p.ParseStatement()
+		if errs := p.Errors(); len(errs) > 0 {
+			return errs
+		}
+		if stmt == nil {
+			return nil
+		}
+		i.evalStatement(stmt)
+		if i.ctx.HasReturn() {
+			return nil
+		}
+	}
+}
+
 // ============================================================
 // Statement Evaluation
 // ============================================================
 
+// evalStatement remembers the statement being run for crash reports: on a
+// panic the innermost one stays in i.stmt
 func (i *Interpreter) evalStatement(stmt ast.Statement) *sv.SV {
+	prev := i.stmt
+	i.stmt = stmt
+	result := i.execStatement(stmt)
+	i.stmt = prev
+	return result
+}
+
+// Statement returns the statement being run, or the one that was running
+// when the interpreter panicked.
+func (i *Interpreter) Statement() ast.Statement {
+	return i.stmt
+}
+
+// Global returns a file-scope variable by name, without sigil ($x, @x
+// and %x share the name x), and whether it is set.
+func (i *Interpreter) Global(name string) (*sv.SV, bool) {
+	return i.ctx.Global(name)
+}
+
+// SetGlobal sets a file-scope variable; an array or hash value (not a
+// reference) makes @name or %name.
+func (i *Interpreter) SetGlobal(name string, value *sv.SV) {
+	i.ctx.SetGlobal(name, value)
+}
+
+func (i *Interpreter) execStatement(stmt ast.Statement) *sv.SV {
+	i.pollSignals()
 	switch s := stmt.(type) {
 	case *ast.ExprStmt:
 		return i.evalExpression(s.Expression)
+	case *ast.FormatDecl:
+		// объявленный заранее формат остаётся; здесь - форматы из
+		// require, eval строки и программы, читаемой потоком
+		if i.formats[strings.TrimPrefix(s.Name, "main::")] == nil {
+			i.declareFormat(s)
+		}
+		return sv.NewUndef()
 	case *ast.VarDecl:
 		return i.evalVarDecl(s)
 	case *ast.IfStmt:
 		return i.evalIfStmt(s)
 	case *ast.WhileStmt:
-		return i.evalWhileStmt(s)
+		return i.evalWhileStmt(s, "")
 	case *ast.ForStmt:
-		return i.evalForStmt(s)
+		return i.evalForStmt(s, "")
 	case *ast.ForeachStmt:
-		return i.evalForeachStmt(s)
+		return i.evalForeachStmt(s, "")
+	case *ast.LabelStmt:
+		return i.evalLabelStmt(s)
+	case *ast.GivenStmt:
+		return i.evalGivenStmt(s)
+	case *ast.DoStmt:
+		return i.evalDoStmt(s)
 	case *ast.SubDecl:
 		return i.evalSubDecl(s)
 	case *ast.ReturnStmt:
@@ -80,28 +271,224 @@ func (i *Interpreter) evalStatement(stmt ast.Statement) *sv.SV {
 	case *ast.NextStmt:
 		i.ctx.SetNext(s.Label)
 		return sv.NewUndef()
-	case *ast.UseDecl, *ast.PackageDecl, *ast.NoDecl, *ast.RequireDecl:
+	case *ast.RedoStmt:
+		i.ctx.SetRedo(s.Label)
 		return sv.NewUndef()
+	case *ast.UseDecl:
+		if s.Module == "lib" {
+			i.useLib(s.Args)
+		}
+		i.useModule(s)
+		if s.Module == "Time::Piece" {
+			i.timePiece = true
+		}
+		if s.Module == "Time::HiRes" {
+			i.useHiRes(s.Args)
+		}
+		if s.Module == "Carp" {
+			i.useCarp(s.Args)
+		}
+		if s.Module == "Scalar::Util" {
+			i.useScalarUtil(s.Args)
+		}
+		if s.Module == "List::Util" {
+			i.useListUtil(s.Args)
+		}
+		if s.Module == "POSIX" {
+			i.usePOSIX(s.Args)
+		}
+		if s.Module == "Getopt::Long" || s.Module == "Getopt::Std" {
+			i.useGetopt(s.Module, s.Args)
+		}
+		if s.Module == "Storable" {
+			i.useStorable(s.Args)
+		}
+		if s.Module == "File::Basename" || s.Module == "File::Path" || s.Module == "Cwd" {
+			i.useFileModule(s.Module, s.Args)
+		}
+		if s.Module == "perlc::parallel" {
+			i.parallel = true
+		}
+		if s.Module == "Devel::Size" {
+			i.develSize = true
+		}
+		if s.Module == "constant" {
+			i.defineConstants(s)
+		}
+		if s.Module == "warnings" {
+			i.useWarnings(s.Args, false)
+		}
+		if s.Module == "vars" {
+			i.useVars(s.Args)
+		}
+		if s.Module == "overload" {
+			i.useOverload(s)
+		}
+		return sv.NewUndef()
+	case *ast.NoDecl:
+		if s.Module == "warnings" {
+			i.useWarnings(s.Args, true)
+		}
+		return sv.NewUndef()
+	case *ast.PackageDecl:
+		return i.evalPackageDecl(s)
+	case *ast.RequireDecl:
+		return i.evalRequire(s)
 	default:
 		return sv.NewUndef()
 	}
 }
 
+// evalPackageDecl - package NAME; меняет текущий пакет до конца файла,
+// package NAME { ... } - только на время блока
+func (i *Interpreter) evalPackageDecl(decl *ast.PackageDecl) *sv.SV {
+	// @ISA в пакете - всегда его @PKG::ISA
+	if decl.Block == nil {
+		i.pkg = decl.Name
+		i.ctx.DeclareOur("@", "ISA", decl.Name)
+		return sv.NewUndef()
+	}
+	prev := i.pkg
+	i.pkg = decl.Name
+	i.ctx.DeclareOur("@", "ISA", decl.Name)
+	defer func() {
+		i.pkg = prev
+		i.ctx.DeclareOur("@", "ISA", prev)
+	}()
+	return i.evalBlockStmt(decl.Block)
+}
+
+// evalRequire - require Module и require "file.pl": файл ищется по @INC
+// и выполняется один раз (modules.go)
+func (i *Interpreter) evalRequire(decl *ast.RequireDecl) *sv.SV {
+	if decl.Expr != nil {
+		i.requireFile(i.evalExpression(decl.Expr).AsString())
+	} else {
+		i.requireModule(decl.Module)
+	}
+	return sv.NewInt(1)
+}
+
+func isVersion(name string) bool {
+	return name[0] >= '0' && name[0] <= '9' || name[0] == 'v' && len(name) > 1 && name[1] >= '0' && name[1] <= '9'
+}
+
+// useLib - use lib LIST добавляет каталоги в начало @INC
+func (i *Interpreter) useLib(args []ast.Expression) {
+	var dirs []string
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			dirs = append(dirs, v.AsString())
+		}
+	}
+	i.ctx.UseLib(dirs)
+}
+
+// useHiRes - use Time::HiRes LIST: импортированные имена вызывают версии
+// Time::HiRes (time с дробной частью, sleep на доли секунды, usleep...)
+func (i *Interpreter) useHiRes(args []ast.Expression) {
+	if i.hiRes == nil {
+		i.hiRes = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); hiResFuncs[name] {
+				i.hiRes[name] = true
+			}
+		}
+	}
+}
+
+// defineConstants - use constant NAME => VALUE и use constant { A => 1 }:
+// значение вычисляется один раз при объявлении, список хранится массивом
+func (i *Interpreter) defineConstants(decl *ast.UseDecl) {
+	if len(decl.Args) == 0 {
+		return
+	}
+	if h, ok := decl.Args[0].(*ast.HashExpr); ok {
+		for _, pair := range h.Pairs {
+			i.constants[i.evalExpression(pair.Key).AsString()] = i.evalExpression(pair.Value)
+		}
+		return
+	}
+	name := i.evalExpression(decl.Args[0]).AsString()
+	values := i.listValues(&ast.ArrayExpr{Token: decl.Token, Elements: decl.Args[1:]})
+	switch len(values) {
+	case 0:
+		i.constants[name] = sv.NewUndef()
+	case 1:
+		i.constants[name] = values[0]
+	default:
+		i.constants[name] = sv.NewArrayRef(values...)
+	}
+}
+
 func (i *Interpreter) evalBlockStmt(block *ast.BlockStmt) *sv.SV {
+	defer i.unwindLocals(len(i.locals))
+
 	var result *sv.SV
 	for _, stmt := range block.Statements {
 		result = i.evalStatement(stmt)
-		if i.ctx.HasReturn() || i.ctx.HasLast() || i.ctx.HasNext() {
+		if i.ctx.HasReturn() || i.ctx.HasLast() || i.ctx.HasNext() || i.ctx.HasRedo() {
 			break
 		}
 	}
 	return result
 }
 
+// useVars - use vars qw($x @y): как our, имена - переменные текущего
+// пакета в stash
+func (i *Interpreter) useVars(args []ast.Expression) {
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); len(name) > 1 && strings.ContainsRune("$@%", rune(name[0])) {
+				i.ctx.DeclareOur(name[:1], name[1:], i.pkg)
+			}
+		}
+	}
+}
+
 func (i *Interpreter) evalVarDecl(decl *ast.VarDecl) *sv.SV {
+	if decl.Kind == "local" {
+		return i.evalLocal(decl)
+	}
+	if decl.Kind == "our" {
+		// our $x: до конца блока $x - это $x текущего пакета в stash;
+		// без присваивания у неё остаётся прежнее значение
+		for _, name := range decl.Names {
+			i.ctx.DeclareOur(varSigil(name), varName(name), i.pkg)
+		}
+		if decl.Value == nil {
+			return i.evalExpression(decl.Names[0])
+		}
+	}
+
+	if len(decl.Names) == 1 && !decl.IsList && isAggregate(decl.Names[0]) && decl.Value != nil {
+		// my @b = @a копирует элементы, а не делит массив с @a
+		name := decl.Names[0]
+		value := newContainer(isHashVar(name))
+		i.assignToVar(name, value, decl.Kind)
+		values := i.copyList(i.listValues(decl.Value))
+		i.checkHashList(name, values)
+		i.fillContainer(name, values)
+		return value
+	}
+
 	var value *sv.SV
 	if decl.Value != nil {
-		value = i.evalExpression(decl.Value)
+		if len(decl.Names) == 1 && !decl.IsList && isScalarVar(decl.Names[0]) {
+			value = strongCopy(i.evalScalarExpression(decl.Value))
+		} else if m, ok := decl.Value.(*ast.MatchExpr); ok && decl.IsList {
+			value = i.evalMatchList(m)
+		} else if rl, ok := decl.Value.(*ast.ReadLineExpr); ok && decl.IsList {
+			value = i.evalReadLineList(rl)
+		} else if c, ok := decl.Value.(*ast.CommandExpr); ok && decl.IsList {
+			value = i.evalCommandList(c)
+		} else if a, ok := decl.Value.(*ast.AssignExpr); ok && decl.IsList && isListTarget(a.Left) {
+			value = sv.NewArrayRef(i.listValues(a)...)
+		} else {
+			value = i.evalExpression(decl.Value)
+		}
 	} else {
 		// Create appropriate empty value based on variable type
 		if len(decl.Names) == 1 {
@@ -123,13 +510,32 @@ func (i *Interpreter) evalVarDecl(decl *ast.VarDecl) *sv.SV {
 	if decl.IsList && decl.Value != nil {
 		values := i.svToList(value)
 		for idx, name := range decl.Names {
-			var val *sv.SV
-			if idx < len(values) {
-				val = values[idx]
+			if isAggregate(name) {
+				// my ($first, @rest) = @list: массив или хеш забирает
+				// остаток, имена после него остаются пустыми
+				var rest []*sv.SV
+				if idx < len(values) {
+					rest = values[idx:]
+				}
+				i.assignToVar(name, newContainer(isHashVar(name)), decl.Kind)
+				i.checkHashList(name, rest)
+				i.fillContainer(name, rest)
+				values = nil
+				continue
+			}
+			i.assignToVar(name, sliceValue(values, idx), decl.Kind)
+		}
+		return value
+	}
+	if len(decl.Names) > 1 && decl.Value == nil {
+		// my ($x, @list); - у каждого имени своё пустое значение, чтобы
+		// \$x ссылалась именно на него
+		for _, name := range decl.Names {
+			if isAggregate(name) {
+				i.assignToVar(name, newContainer(isHashVar(name)), decl.Kind)
 			} else {
-				val = sv.NewUndef()
+				i.assignToVar(name, sv.NewUndef(), decl.Kind)
 			}
-			i.assignToVar(name, val, decl.Kind)
 		}
 		return value
 	}
@@ -165,16 +571,16 @@ func (i *Interpreter) evalVarDecl(decl *ast.VarDecl) *sv.SV {
 func (i *Interpreter) assignToVar(expr ast.Expression, value *sv.SV, kind string) {
 	switch v := expr.(type) {
 	case *ast.ScalarVar:
-		i.ctx.DeclareVar(v.Name, value, kind)
+		i.ctx.DeclareVar(i.declKey("$", v.Name, kind), value, kind)
 	case *ast.ArrayVar:
-		i.ctx.DeclareVar(v.Name, value, kind)
+		i.ctx.DeclareVar(i.declKey("@", v.Name, kind), value, kind)
 	case *ast.HashVar:
-		i.ctx.DeclareVar(v.Name, value, kind)
+		i.ctx.DeclareVar(i.declKey("%", v.Name, kind), value, kind)
 	}
 }
 
 func (i *Interpreter) evalIfStmt(stmt *ast.IfStmt) *sv.SV {
-	cond := i.evalExpression(stmt.Condition)
+	cond := i.evalScalarExpression(stmt.Condition)
 	testResult := cond.IsTrue()
 	if stmt.Unless {
 		testResult = !testResult
@@ -185,7 +591,7 @@ func (i *Interpreter) evalIfStmt(stmt *ast.IfStmt) *sv.SV {
 	}
 
 	for _, elsif := range stmt.Elsif {
-		cond := i.evalExpression(elsif.Condition)
+		cond := i.evalScalarExpression(elsif.Condition)
 		if cond.IsTrue() {
 			return i.evalBlockStmt(elsif.Body)
 		}
@@ -197,10 +603,48 @@ func (i *Interpreter) evalIfStmt(stmt *ast.IfStmt) *sv.SV {
 	return sv.NewUndef()
 }
 
-func (i *Interpreter) evalWhileStmt(stmt *ast.WhileStmt) *sv.SV {
+// evalLabelStmt выполняет LABEL: ...; метка нужна только циклам
+func (i *Interpreter) evalLabelStmt(stmt *ast.LabelStmt) *sv.SV {
+	switch s := stmt.Statement.(type) {
+	case *ast.WhileStmt:
+		return i.evalWhileStmt(s, stmt.Label)
+	case *ast.ForStmt:
+		return i.evalForStmt(s, stmt.Label)
+	case *ast.ForeachStmt:
+		return i.evalForeachStmt(s, stmt.Label)
+	}
+	return i.evalStatement(stmt.Statement)
+}
+
+// evalLoopBody выполняет тело цикла с меткой label, повторяя его на redo.
+// Возвращает false, если цикл надо прервать: его last, а также return и
+// last/next/redo с меткой внешнего цикла - их флаги остаются для него.
+func (i *Interpreter) evalLoopBody(body *ast.BlockStmt, label string) (*sv.SV, bool) {
+	ours := func(l string) bool { return l == "" || l == label }
+	for {
+		i.pollSignals()
+		result := i.evalBlockStmt(body)
+		switch {
+		case i.ctx.HasRedo() && ours(i.ctx.RedoLabel()):
+			i.ctx.ClearRedo()
+			continue
+		case i.ctx.HasNext() && ours(i.ctx.NextLabel()):
+			i.ctx.ClearNext()
+		case i.ctx.HasLast() && ours(i.ctx.LastLabel()):
+			i.ctx.ClearLast()
+			return result, false
+		case i.ctx.HasReturn() || i.ctx.HasLast() || i.ctx.HasNext() || i.ctx.HasRedo():
+			return result, false
+		}
+		return result, true
+	}
+}
+
+func (i *Interpreter) evalWhileStmt(stmt *ast.WhileStmt, label string) *sv.SV {
 	var result *sv.SV
 	for {
-		cond := i.evalExpression(stmt.Condition)
+		i.pollSignals()
+		cond := i.evalScalarExpression(stmt.Condition)
 		testResult := cond.IsTrue()
 		if stmt.Until {
 			testResult = !testResult
@@ -209,24 +653,45 @@ func (i *Interpreter) evalWhileStmt(stmt *ast.WhileStmt) *sv.SV {
 			break
 		}
 
-		result = i.evalBlockStmt(stmt.Body)
-
-		if i.ctx.HasLast() {
-			i.ctx.ClearLast()
+		var more bool
+		if result, more = i.evalLoopBody(stmt.Body, label); !more {
 			break
 		}
-		if i.ctx.HasNext() {
-			i.ctx.ClearNext()
-			continue
+	}
+	return result
+}
+
+// evalDoStmt выполняет do { ... } while/until (COND): тело выполняется хотя
+// бы раз, условие проверяется после него. Как и в Perl, это не цикл для
+// last/next - они уходят во внешний цикл.
+func (i *Interpreter) evalDoStmt(stmt *ast.DoStmt) *sv.SV {
+	var result *sv.SV
+	for {
+		i.ctx.PushScope()
+		result = i.evalBlockStmt(stmt.Body)
+		i.ctx.PopScope()
+		if i.ctx.HasLast() || i.ctx.HasNext() || i.ctx.HasRedo() || i.ctx.HasReturn() {
+			break
 		}
-		if i.ctx.HasReturn() {
+		i.pollSignals()
+		if i.evalScalarExpression(stmt.Condition).IsTrue() == stmt.Until {
 			break
 		}
 	}
 	return result
 }
 
-func (i *Interpreter) evalForStmt(stmt *ast.ForStmt) *sv.SV {
+// evalDoBlock выполняет do { ... }: значение - последний оператор блока
+func (i *Interpreter) evalDoBlock(expr *ast.DoBlockExpr) *sv.SV {
+	i.ctx.PushScope()
+	defer i.ctx.PopScope()
+	if result := i.evalBlockStmt(expr.Body); result != nil {
+		return result
+	}
+	return sv.NewUndef()
+}
+
+func (i *Interpreter) evalForStmt(stmt *ast.ForStmt, label string) *sv.SV {
 	var result *sv.SV
 
 	// Init - может быть VarDecl или ExprStmt
@@ -236,24 +701,16 @@ func (i *Interpreter) evalForStmt(stmt *ast.ForStmt) *sv.SV {
 
 	for {
 		// Condition
+		i.pollSignals()
 		if stmt.Condition != nil {
-			cond := i.evalExpression(stmt.Condition)
+			cond := i.evalScalarExpression(stmt.Condition)
 			if !cond.IsTrue() {
 				break
 			}
 		}
 
-		result = i.evalBlockStmt(stmt.Body)
-
-		if i.ctx.HasLast() {
-			i.ctx.ClearLast()
-			break
-		}
-		if i.ctx.HasNext() {
-			i.ctx.ClearNext()
-			// Still need to execute Post before next iteration
-		}
-		if i.ctx.HasReturn() {
+		var more bool
+		if result, more = i.evalLoopBody(stmt.Body, label); !more {
 			break
 		}
 
@@ -265,9 +722,17 @@ func (i *Interpreter) evalForStmt(stmt *ast.ForStmt) *sv.SV {
 	return result
 }
 
-func (i *Interpreter) evalForeachStmt(stmt *ast.ForeachStmt) *sv.SV {
+func (i *Interpreter) evalForeachStmt(stmt *ast.ForeachStmt, label string) *sv.SV {
 	var result *sv.SV
-	list := i.evalExpression(stmt.List)
+	var list *sv.SV
+	if rl, ok := stmt.List.(*ast.ReadLineExpr); ok {
+		// foreach (<$fh>) читает все записи сразу
+		list = i.evalReadLineList(rl)
+	} else if c, ok := stmt.List.(*ast.CommandExpr); ok {
+		list = i.evalCommandList(c)
+	} else {
+		list = i.evalExpression(stmt.List)
+	}
 	values := i.svToList(list)
 
 	varName := ""
@@ -276,26 +741,37 @@ func (i *Interpreter) evalForeachStmt(stmt *ast.ForeachStmt) *sv.SV {
 	}
 
 	for _, val := range values {
-		i.ctx.SetVar(varName, val)
-		result = i.evalBlockStmt(stmt.Body)
-
-		if i.ctx.HasLast() {
-			i.ctx.ClearLast()
-			break
-		}
-		if i.ctx.HasNext() {
-			i.ctx.ClearNext()
-			continue
-		}
-		if i.ctx.HasReturn() {
+		// Своя область видимости на каждую итерацию: замыкания в теле цикла
+		// захватывают своё значение переменной, а после цикла она восстанавливается
+		i.ctx.PushScope()
+		i.ctx.DeclareVar(varName, val, "my")
+		var more bool
+		result, more = i.evalLoopBody(stmt.Body, label)
+		i.ctx.PopScope()
+		if !more {
 			break
 		}
 	}
 	return result
 }
 
+// evalSubDecl объявляет sub в текущем пакете: sub f после package Foo;
+// - это Foo::f, sub main хранятся без main::. Sub загружаемого файла
+// запоминают его область видимости, как замыкания.
 func (i *Interpreter) evalSubDecl(decl *ast.SubDecl) *sv.SV {
-	i.ctx.DeclareSub(decl.Name, decl.Body)
+	name := decl.Name
+	if !strings.Contains(name, "::") && i.pkg != "main" {
+		name = i.pkg + "::" + name
+	}
+	name = strings.TrimPrefix(name, "main::")
+	i.ctx.DeclareSub(name, decl.Body)
+	i.subPkgs[name] = i.pkg
+	if decl.Params != nil {
+		i.signatures[name] = decl.Params
+	}
+	if i.loading > 0 {
+		i.closures[name] = i.ctx.CaptureScopes()
+	}
 	return sv.NewUndef()
 }
 
@@ -326,6 +802,10 @@ func (i *Interpreter) evalExpression(expr ast.Expression) *sv.SV {
 	case *ast.IntegerLiteral:
 		return sv.NewInt(e.Value)
 	case *ast.FloatLiteral:
+		// целый литерал за int64 — беззнаковое целое perl
+		if u, err := strconv.ParseUint(e.Token.Value, 0, 64); err == nil && sv.PerlCompatNumbers() {
+			return sv.NewUint(u)
+		}
 		return sv.NewFloat(e.Value)
 	case *ast.StringLiteral:
 		if e.Interpolated {
@@ -335,15 +815,17 @@ func (i *Interpreter) evalExpression(expr ast.Expression) *sv.SV {
 	case *ast.UndefLiteral:
 		return sv.NewUndef()
 	case *ast.ScalarVar:
-		return i.ctx.GetVar(e.Name)
+		return i.ctx.GetVar(i.varKey("$", e.Name))
 	case *ast.ArrayVar:
 		if e.Name == "_" {
 			result := i.ctx.GetArgs()
 			return result
 		}
-		return i.ctx.GetVar(e.Name)
+		return i.ctx.GetVar(i.varKey("@", e.Name))
 	case *ast.HashVar:
-		return i.ctx.GetVar(e.Name)
+		return i.ctx.GetVar(i.varKey("%", e.Name))
+	case *ast.GlobVar:
+		return sv.NewGlob(e.Name)
 	case *ast.SpecialVar:
 		return i.evalSpecialVar(e.Name)
 	case *ast.PrefixExpr:
@@ -362,6 +844,10 @@ func (i *Interpreter) evalExpression(expr ast.Expression) *sv.SV {
 		return i.evalHashExpr(e)
 	case *ast.ArrayAccess:
 		return i.evalArrayAccess(e)
+	case *ast.ArraySlice:
+		return i.evalArraySlice(e)
+	case *ast.HashSlice:
+		return i.evalHashSlice(e)
 	case *ast.HashAccess:
 		return i.evalHashAccess(e)
 	case *ast.CallExpr:
@@ -371,17 +857,46 @@ func (i *Interpreter) evalExpression(expr ast.Expression) *sv.SV {
 	case *ast.RefExpr:
 		return i.evalRefExpr(e)
 	case *ast.Identifier:
+		if c, ok := i.constants[e.Value]; ok {
+			return c
+		}
+		if v, ok := perlrt.FcntlConstants[e.Value]; ok {
+			return sv.NewInt(v)
+		}
+		if v, ok := waitConstants[e.Value]; ok {
+			return sv.NewInt(v)
+		}
+		if v, ok := i.posixConstant(e.Value); ok {
+			return v
+		}
+		if bareCallBuiltins[e.Value] || i.hiRes[e.Value] {
+			return i.evalCallExpr(&ast.CallExpr{Token: e.Token, Function: e})
+		}
 		return sv.NewString(e.Value)
 	case *ast.RangeExpr:
 		return i.evalRangeExpr(e)
+	case *ast.AnonSubExpr:
+		return i.evalAnonSub(e)
+	case *ast.DoBlockExpr:
+		return i.evalDoBlock(e)
+	case *ast.EvalBlockExpr:
+		return i.evalEvalBlock(e)
+	case *ast.EvalStringExpr:
+		return i.evalEvalString(e)
 	case *ast.ArrowAccess:
 		return i.evalArrowAccess(e)
 	case *ast.MatchExpr:
 		return i.evalMatchExpr(e)
+	case *ast.QrExpr:
+		return sv.NewRegexRef(qrPattern(i.interpolatePattern(e.Pattern), e.Flags))
 	case *ast.SubstExpr:
 		return i.evalSubstExpr(e)
+	case *ast.TransExpr:
+		return i.evalTransExpr(e)
 	case *ast.ReadLineExpr:
 		return i.evalReadLineExpr(e)
+	case *ast.CommandExpr:
+		return i.evalCommandExpr(e)
 	case *ast.DerefExpr:
 		return i.evalDerefExpr(e)
 	default:
@@ -390,10 +905,18 @@ func (i *Interpreter) evalExpression(expr ast.Expression) *sv.SV {
 }
 
 func (i *Interpreter) evalPrefixExpr(expr *ast.PrefixExpr) *sv.SV {
+	// -bareword - строка "-bareword", как -norequire у use parent;
+	// одна буква - проверка файла, -e -d -f
+	if id, ok := expr.Right.(*ast.Identifier); ok && expr.Operator == "-" && len(id.Value) > 1 && i.constants[id.Value] == nil {
+		return sv.NewString("-" + id.Value)
+	}
 	right := i.evalExpression(expr.Right)
 
 	switch expr.Operator {
 	case "-":
+		if sv.PerlCompatNumbers() {
+			return sv.Neg(right)
+		}
 		return sv.NewFloat(-right.AsFloat())
 	case "+":
 		return sv.NewFloat(right.AsFloat())
@@ -402,13 +925,16 @@ func (i *Interpreter) evalPrefixExpr(expr *ast.PrefixExpr) *sv.SV {
 	case "not":
 		return boolToSV(!right.IsTrue())
 	case "~":
+		if sv.PerlCompatNumbers() {
+			return sv.NewUint(^uint64(right.AsInt()))
+		}
 		return sv.NewInt(^right.AsInt())
 	case "++":
-		val := sv.NewInt(right.AsInt() + 1)
+		val := sv.Inc(right.Copy())
 		i.assignBack(expr.Right, val)
 		return val
 	case "--":
-		val := sv.NewInt(right.AsInt() - 1)
+		val := sv.Dec(right.Copy())
 		i.assignBack(expr.Right, val)
 		return val
 	default:
@@ -440,8 +966,13 @@ func (i *Interpreter) evalInfixExpr(expr *ast.InfixExpr) *sv.SV {
 		return i.evalExpression(expr.Right)
 	}
 
-	left := i.evalExpression(expr.Left)
-	right := i.evalExpression(expr.Right)
+	if list, ok := expr.Left.(*ast.ArrayExpr); ok && expr.Operator == "x" && list.Token.Value != "[" {
+		return i.repeatList(i.listValues(list), i.evalExpression(expr.Right))
+	}
+
+	left := i.evalScalarExpression(expr.Left)
+	right := i.evalScalarExpression(expr.Right)
+	i.checkOperands(expr.Operator, expr.Left, expr.Right, left, right, false)
 
 	switch expr.Operator {
 	case "+":
@@ -450,10 +981,8 @@ func (i *Interpreter) evalInfixExpr(expr *ast.InfixExpr) *sv.SV {
 		return sv.Sub(left, right)
 	case "*":
 		return sv.Mul(left, right)
-	case "/":
-		return sv.Div(left, right)
-	case "%":
-		return sv.Mod(left, right)
+	case "/", "%":
+		return i.divide(expr.Operator, left, right)
 	case "**":
 		return sv.Pow(left, right)
 	case ".":
@@ -503,16 +1032,35 @@ func (i *Interpreter) evalInfixExpr(expr *ast.InfixExpr) *sv.SV {
 	}
 }
 
+// divide - / и %. Деление на ноль - die с местом, как в Perl; делитель
+// проверяется так же, как в sv.Div и sv.Mod (% - по целой части)
+func (i *Interpreter) divide(op string, left, right *sv.SV) *sv.SV {
+	if op == "/" {
+		if right.AsFloat() == 0 {
+			return i.builtinDie([]*sv.SV{sv.NewString("Illegal division by zero" + i.at() + ".\n")})
+		}
+		return sv.Div(left, right)
+	}
+	if right.AsInt() == 0 {
+		return i.builtinDie([]*sv.SV{sv.NewString("Illegal modulus zero" + i.at() + ".\n")})
+	}
+	return sv.Mod(left, right)
+}
+
 func (i *Interpreter) evalPostfixExpr(expr *ast.PostfixExpr) *sv.SV {
 	left := i.evalExpression(expr.Left)
-	oldVal := sv.NewInt(left.AsInt())
+	oldVal := left.Copy()
 
 	switch expr.Operator {
 	case "++":
-		i.assignBack(expr.Left, sv.NewInt(left.AsInt()+1))
+		// undef++ is 0, undef-- stays undef
+		if oldVal.IsUndef() {
+			oldVal = sv.NewInt(0)
+		}
+		i.assignBack(expr.Left, sv.Inc(left.Copy()))
 		return oldVal
 	case "--":
-		i.assignBack(expr.Left, sv.NewInt(left.AsInt()-1))
+		i.assignBack(expr.Left, sv.Dec(left.Copy()))
 		return oldVal
 	default:
 		return oldVal
@@ -520,10 +1068,20 @@ func (i *Interpreter) evalPostfixExpr(expr *ast.PostfixExpr) *sv.SV {
 }
 
 func (i *Interpreter) evalAssignExpr(expr *ast.AssignExpr) *sv.SV {
-	right := i.evalExpression(expr.Right)
+	if expr.Operator == "=" && isListTarget(expr.Left) {
+		return i.evalListAssign(expr)
+	}
+
+	var right *sv.SV
+	if isScalarVar(expr.Left) {
+		right = strongCopy(i.evalScalarExpression(expr.Right))
+	} else {
+		right = i.evalExpression(expr.Right)
+	}
 
 	if expr.Operator != "=" {
 		left := i.evalExpression(expr.Left)
+		i.checkOperands(strings.TrimSuffix(expr.Operator, "="), expr.Left, expr.Right, left, right, true)
 		switch expr.Operator {
 		case "+=":
 			right = sv.Add(left, right)
@@ -531,8 +1089,10 @@ func (i *Interpreter) evalAssignExpr(expr *ast.AssignExpr) *sv.SV {
 			right = sv.Sub(left, right)
 		case "*=":
 			right = sv.Mul(left, right)
-		case "/=":
-			right = sv.Div(left, right)
+		case "/=", "%=":
+			right = i.divide(strings.TrimSuffix(expr.Operator, "="), left, right)
+		case "**=":
+			right = sv.Pow(left, right)
 		case ".=":
 			right = sv.Concat(left, right)
 		case "||=":
@@ -550,8 +1110,318 @@ func (i *Interpreter) evalAssignExpr(expr *ast.AssignExpr) *sv.SV {
 	return right
 }
 
+// isListTarget - левая часть списочного присваивания: ($a, $b), @a, %h, @$r, %$r
+func isListTarget(expr ast.Expression) bool {
+	_, isList := expr.(*ast.ArrayExpr)
+	return isList || isAggregate(expr)
+}
+
+// isAggregate - массив или хеш: @a, %h, @$r, %{$r}
+func isAggregate(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.ArrayVar, *ast.HashVar:
+		return true
+	case *ast.DerefExpr:
+		return e.Sigil == "@" || e.Sigil == "%"
+	}
+	return false
+}
+
+// evalListAssign - ($a, $b) = ($b, $a), ($x, @rest) = @list, %h = (a => 1).
+// Правая часть копируется до присваивания, поэтому обмен работает;
+// результат - число элементов справа, как в скалярном контексте Perl
+// (while (($k, $v) = each %h) завершается на пустом списке)
+func (i *Interpreter) evalListAssign(expr *ast.AssignExpr) *sv.SV {
+	values := i.copyList(i.listValues(expr.Right))
+	count := len(values)
+
+	targets := []ast.Expression{expr.Left}
+	if list, ok := expr.Left.(*ast.ArrayExpr); ok {
+		targets = list.Elements
+	}
+	for _, target := range targets {
+		if isAggregate(target) {
+			// массив или хеш забирает все оставшиеся значения
+			i.checkHashList(target, values)
+			i.fillContainer(target, values)
+			values = nil
+		} else if _, skip := target.(*ast.UndefLiteral); !skip {
+			// (undef, $x) = ... пропускает значение
+			i.assignBack(target, sliceValue(values, 0))
+		}
+		if len(values) > 0 {
+			values = values[1:]
+		}
+	}
+	return sv.NewInt(int64(count))
+}
+
+// listValues раскрывает правую часть списочного присваивания: массивы,
+// хеши и списки из функций превращаются в элементы и пары, а скаляры
+// (в том числе ссылки: $r, [1, 2], \@a) остаются как есть
+func (i *Interpreter) listValues(expr ast.Expression) []*sv.SV {
+	elements := []ast.Expression{expr}
+	if list, ok := expr.(*ast.ArrayExpr); ok && list.Token.Value != "[" {
+		elements = list.Elements
+	}
+	var result []*sv.SV
+	for _, e := range elements {
+		if list, ok := e.(*ast.ArrayExpr); ok && list.Token.Value != "[" {
+			result = append(result, i.listValues(list)...)
+			continue
+		}
+		if m, ok := e.(*ast.MatchExpr); ok {
+			result = append(result, i.svToList(i.evalMatchList(m))...)
+			continue
+		}
+		if rl, ok := e.(*ast.ReadLineExpr); ok {
+			result = append(result, i.svToList(i.evalReadLineList(rl))...)
+			continue
+		}
+		if c, ok := e.(*ast.CommandExpr); ok {
+			result = append(result, i.svToList(i.evalCommandList(c))...)
+			continue
+		}
+		if a, ok := e.(*ast.AssignExpr); ok && a.Operator == "=" && isListTarget(a.Left) {
+			// списочное присваивание в списке - это его левая часть:
+			// my ($x, $y) = my @l = (1, 2, 3)
+			i.evalExpression(a)
+			result = append(result, i.listValues(a.Left)...)
+			continue
+		}
+		v := i.evalExpression(e)
+		switch {
+		case isScalarValue(e):
+			result = append(result, v)
+		case v.IsHash():
+			result = append(result, hv.Flatten(v)...)
+		case isAggregate(e) && v.IsRef() && v.Deref().IsHash():
+			result = append(result, hv.Flatten(v.Deref())...)
+		default:
+			result = append(result, i.svToList(v)...)
+		}
+	}
+	return result
+}
+
+// callArgs раскрывает аргументы вызова sub или метода: f(@a, %h) получает
+// элементы массива и пары хеша. Значения не копируются - @_ ссылается на
+// переменные вызывающего
+func callArgs(exprs []ast.Expression, values []*sv.SV) []*sv.SV {
+	var result []*sv.SV
+	for idx, v := range values {
+		if !isCallList(exprs[idx]) {
+			result = append(result, v)
+			continue
+		}
+		if v.IsRef() {
+			v = v.Deref()
+		}
+		switch {
+		case v == nil:
+		case v.IsHash():
+			result = append(result, hv.Flatten(v)...)
+		case v.IsArray():
+			result = append(result, v.ArrayData()...)
+		default:
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// aliasArgs переносит в переменные вызывающего то, что sub присвоила
+// элементам @_ ($_[0] = ..., $_[0] =~ tr/a-z/A-Z/): @_ - псевдонимы
+// аргументов. values - значения exprs, passed - @_ вызова
+func (i *Interpreter) aliasArgs(exprs []ast.Expression, values, passed []*sv.SV) {
+	pos := 0
+	for idx, e := range exprs {
+		if pos >= len(passed) {
+			return
+		}
+		if !isCallList(e) {
+			switch e.(type) {
+			case *ast.ScalarVar, *ast.SpecialVar, *ast.ArrayAccess, *ast.HashAccess, *ast.ArrowAccess:
+				if passed[pos] != values[idx] {
+					i.assignBack(e, passed[pos])
+				}
+			}
+			pos++
+			continue
+		}
+		v := values[idx]
+		if v.IsRef() {
+			v = v.Deref()
+		}
+		switch {
+		case v == nil:
+		case v.IsHash():
+			pos += 2 * len(v.HashData())
+		case v.IsArray():
+			for j, el := range v.ArrayData() {
+				if pos < len(passed) && passed[pos] != el {
+					av.Store(v, sv.NewInt(int64(j)), passed[pos])
+				}
+				pos++
+			}
+		default:
+			pos++
+		}
+	}
+}
+
+// isCallList - аргумент, который раскрывается в вызове: массив, хеш или @_
+func isCallList(expr ast.Expression) bool {
+	if s, ok := expr.(*ast.SpecialVar); ok {
+		return s.Name == "@_"
+	}
+	return isAggregate(expr)
+}
+
+// copyList копирует значения, чтобы присваивание не связывало переменные
+func (i *Interpreter) copyList(values []*sv.SV) []*sv.SV {
+	for idx, v := range values {
+		values[idx] = v.Copy()
+	}
+	return values
+}
+
+// repeatList - (LIST) x N: значения списка N раз подряд, каждый раз копии
+func (i *Interpreter) repeatList(values []*sv.SV, n *sv.SV) *sv.SV {
+	var out []*sv.SV
+	for k := n.AsInt(); k > 0; k-- {
+		out = append(out, i.copyList(append([]*sv.SV(nil), values...))...)
+	}
+	return sv.NewArrayRef(out...)
+}
+
+// isScalarValue - выражение всегда даёт один скаляр, даже если это ссылка
+func isScalarValue(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.ScalarVar, *ast.ArrayAccess, *ast.HashAccess, *ast.ArrowAccess,
+		*ast.RefExpr, *ast.HashExpr, *ast.AnonSubExpr:
+		return true
+	case *ast.SpecialVar:
+		return strings.HasPrefix(e.Name, "$")
+	case *ast.ArrayExpr:
+		return e.Token.Value == "["
+	}
+	return false
+}
+
+// fillContainer заменяет содержимое массива или хеша на месте, чтобы
+// ссылки на него (\@a) видели новые значения
+func (i *Interpreter) fillContainer(target ast.Expression, values []*sv.SV) {
+	hash := isHashVar(target)
+	c := i.container(target, hash)
+	if hash {
+		hv.Clear(c)
+		for j := 0; j < len(values); j += 2 {
+			hv.Store(c, values[j], sliceValue(values, j+1))
+		}
+		i.envChanged(target)
+		return
+	}
+	av.Clear(c)
+	av.Push(c, values...)
+}
+
+// isHashVar - %h или %$r
+func isHashVar(expr ast.Expression) bool {
+	if d, ok := expr.(*ast.DerefExpr); ok {
+		return d.Sigil == "%"
+	}
+	_, ok := expr.(*ast.HashVar)
+	return ok
+}
+
+// hashVarName - имя, под которым хеш лежит в контексте: у $x, @x и %x
+// одно место, поэтому %INC хранится отдельно от @INC
+func hashVarName(name string) string {
+	if name == "INC" {
+		return context.IncHash
+	}
+	return name
+}
+
+// varKey - ключ переменной в контексте: $Foo::bar и переменные, которые
+// our связал с пакетом, - это глобальные переменные пакета в stash
+func (i *Interpreter) varKey(sigil, name string) string {
+	if strings.HasPrefix(name, "::") {
+		name = "main" + name
+	}
+	if strings.Contains(name, "::") {
+		return sigil + name
+	}
+	if key, ok := i.ctx.OurVar(sigil, name); ok {
+		return key
+	}
+	if sigil == "%" {
+		return hashVarName(name)
+	}
+	return name
+}
+
+// declKey - ключ объявляемой переменной: my заводит новую лексическую
+// переменную, даже если our с тем же именем виден снаружи
+func (i *Interpreter) declKey(sigil, name, kind string) string {
+	switch {
+	case kind == "our":
+		return i.varKey(sigil, name)
+	case sigil == "%":
+		return hashVarName(name)
+	}
+	return name
+}
+
+// arrayBase - массив элемента $a[i] или среза @a[...], без автовивификации
+func (i *Interpreter) arrayBase(expr ast.Expression) *sv.SV {
+	if v, ok := expr.(*ast.ScalarVar); ok {
+		return i.ctx.GetVar(i.varKey("@", v.Name))
+	}
+	return i.evalExpression(expr)
+}
+
+// hashBase - хеш элемента $h{k} или среза @h{...}, без автовивификации
+func (i *Interpreter) hashBase(expr ast.Expression) *sv.SV {
+	switch v := expr.(type) {
+	case *ast.ScalarVar:
+		return i.ctx.GetVar(i.varKey("%", v.Name))
+	case *ast.HashVar:
+		return i.ctx.GetVar(i.varKey("%", v.Name))
+	}
+	return i.evalExpression(expr)
+}
+
+// envChanged переносит %ENV в окружение процесса после записи в него:
+// os.Getenv, поиск программ по PATH и дочерние процессы видят новые значения
+func (i *Interpreter) envChanged(expr ast.Expression) {
+	if isEnv(expr) {
+		i.ctx.SyncEnv()
+	}
+}
+
+// isEnv - %ENV или основа $ENV{...}
+func isEnv(expr ast.Expression) bool {
+	switch v := expr.(type) {
+	case *ast.ScalarVar:
+		return v.Name == "ENV"
+	case *ast.HashVar:
+		return v.Name == "ENV"
+	}
+	return false
+}
+
+// sliceValue - idx-й элемент правой части присваивания срезу или undef
+func sliceValue(values []*sv.SV, idx int) *sv.SV {
+	if idx < len(values) {
+		return values[idx]
+	}
+	return sv.NewUndef()
+}
+
 func (i *Interpreter) evalTernaryExpr(expr *ast.TernaryExpr) *sv.SV {
-	cond := i.evalExpression(expr.Condition)
+	cond := i.evalScalarExpression(expr.Condition)
 	if cond.IsTrue() {
 		return i.evalExpression(expr.Then)
 	}
@@ -559,9 +1429,14 @@ func (i *Interpreter) evalTernaryExpr(expr *ast.TernaryExpr) *sv.SV {
 }
 
 func (i *Interpreter) evalArrayExpr(expr *ast.ArrayExpr) *sv.SV {
-	elements := make([]*sv.SV, len(expr.Elements))
-	for idx, el := range expr.Elements {
-		elements[idx] = i.evalExpression(el)
+	elements := make([]*sv.SV, 0, len(expr.Elements))
+	for _, el := range expr.Elements {
+		if expr.Token.Value == "[" && !isScalarValue(el) {
+			// [@a, f()] - значения массивов, хешей и списков
+			elements = append(elements, i.copyList(i.listValues(el))...)
+			continue
+		}
+		elements = append(elements, i.evalExpression(el))
 	}
 	return sv.NewArrayRef(elements...)
 }
@@ -585,26 +1460,91 @@ func (i *Interpreter) evalArrayAccess(expr *ast.ArrayAccess) *sv.SV {
 		return result
 	}
 
-	array := i.evalExpression(expr.Array)
+	array := i.arrayBase(expr.Array)
 	index := i.evalExpression(expr.Index)
 	return av.Fetch(array, index)
 }
 
 func (i *Interpreter) evalHashAccess(expr *ast.HashAccess) *sv.SV {
-	hash := i.evalExpression(expr.Hash)
+	if s, ok := expr.Hash.(*ast.SpecialVar); ok && s.Name == "%+" {
+		// $+{name} - именованная группа последнего совпадения
+		return i.ctx.NamedCapture(i.evalExpression(expr.Key).AsString())
+	}
+	hash := i.hashBase(expr.Hash)
 	key := i.evalExpression(expr.Key)
 	return hv.Fetch(hash, key)
 }
 
+// evalSliceList - индексы или ключи среза, списки раскрываются (@a[1..3])
+func (i *Interpreter) evalSliceList(exprs []ast.Expression) []*sv.SV {
+	var result []*sv.SV
+	for _, e := range exprs {
+		result = append(result, flattenArgs(i.svToList(i.evalExpression(e)))...)
+	}
+	return result
+}
+
+// evalArraySlice - @arr[LIST]
+func (i *Interpreter) evalArraySlice(expr *ast.ArraySlice) *sv.SV {
+	array := i.arrayBase(expr.Array)
+	indices := i.evalSliceList(expr.Indices)
+	values := make([]*sv.SV, len(indices))
+	for idx, index := range indices {
+		values[idx] = av.Fetch(array, index)
+	}
+	return sv.NewArrayRef(values...)
+}
+
+// evalHashSlice - @hash{LIST}
+func (i *Interpreter) evalHashSlice(expr *ast.HashSlice) *sv.SV {
+	hash := i.hashBase(expr.Hash)
+	keys := i.evalSliceList(expr.Keys)
+	values := make([]*sv.SV, len(keys))
+	for idx, key := range keys {
+		values[idx] = hv.Fetch(hash, key)
+	}
+	return sv.NewArrayRef(values...)
+}
+
 func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	funcName := ""
 	if ident, ok := expr.Function.(*ast.Identifier); ok {
 		funcName = ident.Value
 	}
+	if i.hiRes[funcName] {
+		funcName = "Time::HiRes::" + funcName
+	}
+	if i.carp[funcName] {
+		funcName = "Carp::" + funcName
+	}
+	if i.scalarUtil[funcName] {
+		funcName = "Scalar::Util::" + funcName
+	}
+	if i.listUtil[funcName] {
+		funcName = "List::Util::" + funcName
+	}
+	if i.posix[funcName] {
+		funcName = "POSIX::" + funcName
+	}
+	if i.getopt[funcName] {
+		funcName = getoptFuncs[funcName] + "::" + funcName
+	}
+	if i.fileFuncs[funcName] {
+		funcName = fileFuncs[funcName] + "::" + funcName
+	}
+	if i.storable[funcName] {
+		funcName = "Storable::" + funcName
+	}
+	if v, ok := i.posixConstant(funcName); ok && len(expr.Args) == 0 {
+		return v
+	}
 
-	args := make([]*sv.SV, len(expr.Args))
-	for idx, arg := range expr.Args {
-		args[idx] = i.evalExpression(arg)
+	var args []*sv.SV
+	if !selfEvalBuiltins[funcName] {
+		args = make([]*sv.SV, len(expr.Args))
+		for idx, arg := range expr.Args {
+			args[idx] = i.evalExpression(arg)
+		}
 	}
 
 	// Built-in functions
@@ -617,12 +1557,103 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 		return i.builtinOpen(expr)
 	case "close":
 		return i.builtinClose(expr)
+	case "sysopen":
+		return i.builtinSysopen(expr)
+	case "flock":
+		return i.builtinFlock(expr)
+	case "glob":
+		return i.builtinGlob(args)
+	case "system":
+		return i.builtinSystem(args)
+	case "exec":
+		return i.builtinExec(args)
+	case "open3":
+		return i.builtinOpen3(expr)
+	case "waitpid":
+		return i.builtinWaitpid(args)
+	case "wait":
+		return i.builtinWait()
+	case "sleep":
+		return i.builtinSleep(args)
+	case "alarm":
+		return i.builtinAlarm(args)
+	case "gensym":
+		return i.builtinGensym()
+	case "getpwnam", "getpwuid":
+		return i.builtinGetpw(funcName, args)
+	case "getgrnam", "getgrgid":
+		return i.builtinGetgr(funcName, args)
+	case "hostname", "Sys::Hostname::hostname":
+		return i.builtinHostname()
+	case "chmod":
+		return i.builtinChmod(args)
+	case "chown":
+		return i.builtinChown(args)
+	case "utime":
+		return i.builtinUtime(args)
+	case "symlink":
+		return i.builtinSymlink(args)
+	case "readlink":
+		return i.builtinReadlink(args)
+	case "stat", "lstat":
+		return i.builtinStat(funcName, args)
+	case "unlink":
+		return i.builtinUnlink(args)
+	case "rename":
+		return i.builtinRename(args)
+	case "mkdir":
+		return i.builtinMkdir(args)
+	case "rmdir":
+		return i.builtinRmdir(args)
+	case "chdir":
+		return i.builtinChdir(args)
+	case "cwd", "getcwd", "Cwd::cwd", "Cwd::getcwd":
+		return i.builtinCwd()
+	case "time":
+		return i.builtinTime()
+	case "Time::HiRes::time":
+		return sv.NewFloat(hiResNow())
+	case "Time::HiRes::sleep", "Time::HiRes::usleep", "Time::HiRes::nanosleep":
+		return i.builtinHiResSleep(funcName, args)
+	case "Time::HiRes::gettimeofday":
+		return gettimeofday()
+	case "Time::HiRes::tv_interval":
+		return tvInterval(args)
+	case "localtime", "gmtime":
+		return i.builtinLocaltime(funcName, args)
+	case "strftime", "POSIX::strftime":
+		return i.builtinStrftime(args)
+	case "POSIX::floor", "POSIX::ceil", "POSIX::fmod", "POSIX::pow":
+		return i.builtinPOSIX(funcName, args)
+	case "Getopt::Long::GetOptions":
+		return i.builtinGetOptions(args)
+	case "Getopt::Std::getopts", "Getopt::Std::getopt":
+		return i.builtinGetopts(funcName, args)
+	case "File::Basename::basename", "File::Basename::dirname", "File::Basename::fileparse",
+		"File::Path::make_path", "File::Path::mkpath", "File::Path::remove_tree", "File::Path::rmtree",
+		"Cwd::abs_path", "Cwd::realpath":
+		return i.builtinFileFunc(funcName, args)
+	case "Storable::store", "Storable::nstore", "Storable::retrieve", "Storable::freeze",
+		"Storable::nfreeze", "Storable::thaw", "Storable::dclone":
+		return i.builtinStorable(funcName, args)
 	case "length":
 		return sv.Length(args[0])
 	case "defined":
 		return sv.Defined(args[0])
 	case "ref":
 		return sv.Ref(args[0])
+	case "looks_like_number":
+		if len(args) > 0 && args[0].LooksLikeNumber() {
+			return sv.NewInt(1)
+		}
+		return sv.NewString("")
+	case "Scalar::Util::blessed", "Scalar::Util::reftype", "Scalar::Util::looks_like_number",
+		"Scalar::Util::weaken", "Scalar::Util::unweaken", "Scalar::Util::isweak", "Scalar::Util::dualvar":
+		return i.builtinScalarUtil(funcName, args)
+	case "List::Util::sum", "List::Util::sum0", "List::Util::max", "List::Util::min",
+		"List::Util::first", "List::Util::reduce", "List::Util::any", "List::Util::all",
+		"List::Util::none", "List::Util::uniq", "List::Util::shuffle":
+		return i.builtinListUtil(funcName, expr.Args, args)
 	case "push":
 		return i.builtinPush(expr.Args, args)
 	case "pop":
@@ -638,11 +1669,12 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	case "join":
 		return i.builtinJoin(args)
 	case "split":
-		return i.builtinSplit(args)
+		return i.builtinSplit(expr.Args, args)
 	case "substr":
 		return i.builtinSubstr(args)
 	case "int":
 		if len(args) > 0 {
+			i.checkNumeric(args[0], "int")
 			return sv.NewInt(args[0].AsInt())
 		}
 		return sv.NewInt(0)
@@ -661,12 +1693,37 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	case "chomp":
 		return i.builtinChomp(expr.Args)
 	case "die":
-		return i.builtinDie(args)
+		// die LIST: массивы раскрываются, а [...] и {...} остаются ссылкой;
+		// сообщение без перевода строки получает " at FILE line N.", как в Perl
+		var list []*sv.SV
+		msg := ""
+		for _, e := range expr.Args {
+			for _, v := range i.listValues(e) {
+				list = append(list, v)
+				msg += v.AsString()
+			}
+		}
+		if msg != "" && !strings.HasSuffix(msg, "\n") && !(len(list) == 1 && list[0].IsRef()) && i.at() != "" {
+			list = append(list, sv.NewString(i.at()+"."))
+		}
+		return i.builtinDie(list)
+	case "Carp::croak", "Carp::confess":
+		// как die: массивы раскрываются, одна ссылка бросается объектом
+		var list []*sv.SV
+		for _, e := range expr.Args {
+			list = append(list, i.listValues(e)...)
+		}
+		return i.builtinCarp(funcName, list)
+	case "Carp::carp", "Carp::cluck":
+		return i.builtinCarp(funcName, args)
 	case "warn":
 		return i.builtinWarn(args)
 	case "exit":
 		return i.builtinExit(args)
 	case "scalar":
+		if len(expr.Args) > 0 {
+			args = []*sv.SV{i.evalScalarExpression(expr.Args[0])}
+		}
 		return i.builtinScalar(args)
 	case "bless":
 		return i.builtinBless(expr.Args, args)
@@ -674,9 +1731,8 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 		return i.builtinIsa(args)
 	case "can":
 		return i.builtinCan(args)
-	case "set_isa":
-		// Helper function: set_isa('Child', 'Parent1', 'Parent2', ...)
-		return i.builtinSetIsa(args)
+	case "mro::get_linear_isa", "mro::get_mro", "mro::set_mro":
+		return i.builtinMro(funcName, args)
 	case "reverse":
 		return i.builtinReverse(expr.Args, args)
 	case "sort":
@@ -696,7 +1752,7 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	case "chop":
 		return i.builtinChop(expr.Args)
 	case "sprintf":
-		return i.builtinSprintf(args)
+		return i.builtinSprintf(callArgs(expr.Args, args))
 	case "quotemeta":
 		return i.builtinQuotemeta(args)
 	case "hex":
@@ -706,7 +1762,7 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	case "fc":
 		return i.builtinFc(args)
 	case "pack":
-		return i.builtinPack(args)
+		return i.builtinPack(expr.Args)
 	case "unpack":
 		return i.builtinUnpack(args)
 	case "grep":
@@ -718,32 +1774,72 @@ func (i *Interpreter) evalCallExpr(expr *ast.CallExpr) *sv.SV {
 	case "each":
 		return i.builtinEach(args)
 	case "pos":
-		return i.builtinPos(args)
+		return i.builtinPos(expr)
 	case "printf":
-		return i.builtinPrintf(args)
+		return i.builtinPrintf(expr)
+	case "select":
+		return i.builtinSelect(args)
+	case "write":
+		return i.builtinWrite(expr)
 	case "eof":
 		return i.builtinEof(expr)
 	case "tell":
 		return i.builtinTell(expr)
 	case "seek":
 		return i.builtinSeek(expr)
+	case "truncate":
+		return i.builtinTruncate(expr)
 	case "binmode":
 		return i.builtinBinmode(expr)
 	case "read":
 		return i.builtinRead(expr, args)
-	}
-	return i.callUserSub(funcName, args)
+	case "parallel_map", "parallel_foreach":
+		if i.parallel {
+			return i.builtinParallel(funcName, args)
+		}
+	case "size", "total_size":
+		if i.develSize {
+			return i.builtinSize(funcName, args)
+		}
+	case "Devel::Size::size", "Devel::Size::total_size":
+		return i.builtinSize(funcName, args)
+	case "Perlc::spawn":
+		return i.builtinSpawn(args)
+	case "Perlc::wait":
+		return i.builtinTaskWait()
+	}
+	passed := callArgs(expr.Args, args)
+	result := i.callUserSub(funcName, passed)
+	i.aliasArgs(expr.Args, args, passed)
+	return result
 }
 
 func (i *Interpreter) evalMethodCall(expr *ast.MethodCall) *sv.SV {
-	// Evaluate the object/class
-	obj := i.evalExpression(expr.Object)
+	// Evaluate the object/class (the invocant is in scalar context)
+	obj := i.evalScalarExpression(expr.Object)
 
 	// Prepare arguments - first arg is always the invocant ($self or $class)
-	args := make([]*sv.SV, len(expr.Args)+1)
-	args[0] = obj
+	values := make([]*sv.SV, len(expr.Args))
 	for idx, arg := range expr.Args {
-		args[idx+1] = i.evalExpression(arg)
+		values[idx] = i.evalExpression(arg)
+	}
+	args := append([]*sv.SV{obj}, callArgs(expr.Args, values)...)
+
+	methodName := expr.Method
+	if expr.Dynamic != nil {
+		// $obj->$code(...) calls the code ref, $obj->$name(...) the method
+		method := i.evalScalarExpression(expr.Dynamic)
+		if method.CodeName() != "" {
+			return i.callCode(method, args)
+		}
+		methodName = method.AsString()
+	}
+
+	// IO::Handle methods on file handles: $fh->autoflush(1)
+	if methodName == "autoflush" && (!obj.IsRef() || obj.IsGlobRef()) {
+		if result, ok := i.builtinAutoflush(obj, args[1:]); ok {
+			return result
+		}
 	}
 
 	// Determine the package/class name
@@ -763,7 +1859,6 @@ func (i *Interpreter) evalMethodCall(expr *ast.MethodCall) *sv.SV {
 	}
 
 	// Find the method in the package
-	methodName := expr.Method
 
 	// Special handling for SUPER::
 	superCall := false
@@ -774,9 +1869,9 @@ func (i *Interpreter) evalMethodCall(expr *ast.MethodCall) *sv.SV {
 
 	var fullName string
 	if superCall {
-		// For SUPER:: calls, start search from parent classes
-		parents := i.ctx.GetPackageISA(pkgName)
-		for _, parent := range parents {
+		// SUPER:: ищет в родителях пакета, где скомпилирована sub, а не
+		// в классе объекта
+		for _, parent := range i.ctx.GetPackageISA(i.pkg) {
 			if found := i.ctx.FindMethod(parent, methodName); found != "" {
 				fullName = found
 				break
@@ -784,6 +1879,9 @@ func (i *Interpreter) evalMethodCall(expr *ast.MethodCall) *sv.SV {
 		}
 	} else {
 		// Normal method resolution - search class and @ISA
+		if _, err := i.ctx.LinearISA(pkgName); err != nil {
+			i.builtinDie([]*sv.SV{sv.NewString(err.Error() + i.at() + ".\n")})
+		}
 		fullName = i.ctx.FindMethod(pkgName, methodName)
 	}
 
@@ -791,11 +1889,51 @@ func (i *Interpreter) evalMethodCall(expr *ast.MethodCall) *sv.SV {
 		return i.callSubWithArgs(fullName, args)
 	}
 
+	// Built-in Perlc::Chan, unless the script defines the package
+	if pkgName == "Perlc::Chan" {
+		return i.chanMethod(obj, methodName, args[1:])
+	}
+
+	// Методы класса File::Spec, если скрипт не определил пакет сам
+	if pkgName == "File::Spec" && !obj.IsRef() {
+		return i.fileSpecMethod(methodName, args[1:])
+	}
+
+	// DBI и его объекты DBI::db и DBI::st поверх database/sql
+	if pkgName == "DBI" || pkgName == "DBI::db" || pkgName == "DBI::st" {
+		return i.dbiMethod(obj, pkgName, methodName, args[1:])
+	}
+
+	// threads и Thread::Queue поверх очереди задач, как Perlc::spawn
+	if pkgName == "threads" || pkgName == "Thread::Queue" {
+		return i.threadsMethod(obj, pkgName, methodName, args[1:])
+	}
+
+	// HTTP::Tiny, LWP::UserAgent и его ответы HTTP::Response поверх net/http
+	if pkgName == "HTTP::Tiny" || pkgName == "LWP::UserAgent" || pkgName == "HTTP::Response" {
+		return i.httpMethod(obj, pkgName, methodName, args[1:])
+	}
+
 	// Try just the method name (for main:: methods)
 	if body := i.ctx.GetSub(methodName); body != nil {
 		return i.callSubWithArgs(methodName, args)
 	}
 
+	// Built-in Time::Piece accessors, unless the script defines its own
+	if pkgName == "Time::Piece" && obj.IsRef() {
+		return i.timePieceMethod(obj, methodName, args[1:])
+	}
+
+	// Универсальные методы без своих в классе: isa и DOES - проверка
+	// по @ISA, в том числе для $@ с объектом исключения, can - ссылка
+	// на найденный метод
+	switch {
+	case (methodName == "isa" || methodName == "DOES") && len(args) > 1:
+		return boolToSV(i.packageIsa(pkgName, args[1].AsString()))
+	case methodName == "can" && len(args) > 1:
+		return i.builtinCan(args)
+	}
+
 	// TODO: AUTOLOAD support
 
 	// Method not found
@@ -807,6 +1945,7 @@ func (i *Interpreter) callSubWithArgs(name string, args []*sv.SV) *sv.SV {
 	if body == nil {
 		return sv.NewUndef()
 	}
+	defer i.enterSub(name, args)()
 
 	// Save current args and set new args
 	oldArgs := i.ctx.GetArgs()
@@ -845,32 +1984,40 @@ func (i *Interpreter) evalDerefExpr(expr *ast.DerefExpr) *sv.SV {
 func (i *Interpreter) evalRefExpr(expr *ast.RefExpr) *sv.SV {
 	// Для \@arr - создаём ссылку на массив
 	if arrVar, ok := expr.Value.(*ast.ArrayVar); ok {
-		arr := i.ctx.GetVar(arrVar.Name)
+		name := i.varKey("@", arrVar.Name)
+		arr := i.ctx.GetVar(name)
 		if arr == nil || arr.IsUndef() {
 			// Создаём пустой массив если не существует
 			arr = sv.NewArrayRef().Deref()
-			i.ctx.SetVar(arrVar.Name, arr)
+			i.ctx.SetVar(name, arr)
 		}
 		return sv.NewRef(arr)
 	}
 
 	// Для \%hash - создаём ссылку на хеш
 	if hashVar, ok := expr.Value.(*ast.HashVar); ok {
-		hash := i.ctx.GetVar(hashVar.Name)
+		name := i.varKey("%", hashVar.Name)
+		hash := i.ctx.GetVar(name)
 		if hash == nil || hash.IsUndef() {
 			// Создаём пустой хеш если не существует
 			hash = sv.NewHashRef().Deref()
-			i.ctx.SetVar(hashVar.Name, hash)
+			i.ctx.SetVar(name, hash)
 		}
 		return sv.NewRef(hash)
 	}
 
+	// Для \&name - ссылка на именованную подпрограмму
+	if codeVar, ok := expr.Value.(*ast.CodeVar); ok {
+		return sv.NewCodeRef(i.subName(codeVar.Name))
+	}
+
 	// Для \$scalar - создаём ссылку на скаляр
 	if scalarVar, ok := expr.Value.(*ast.ScalarVar); ok {
-		scalar := i.ctx.GetVar(scalarVar.Name)
+		name := i.varKey("$", scalarVar.Name)
+		scalar := i.ctx.GetVar(name)
 		if scalar == nil {
 			scalar = sv.NewUndef()
-			i.ctx.SetVar(scalarVar.Name, scalar)
+			i.ctx.SetVar(name, scalar)
 		}
 		return sv.NewRef(scalar)
 	}
@@ -904,37 +2051,84 @@ func (i *Interpreter) evalSpecialVar(name string) *sv.SV {
 // Helper Functions
 // ============================================================
 
+// resolveLvalue возвращает переменную, в которую на самом деле пишет выражение:
+// ветку ($c ? $a : $b) по условию, левую часть присваивания ($x = $y).
+// Присваивание при этом выполняется.
+func (i *Interpreter) resolveLvalue(expr ast.Expression) ast.Expression {
+	switch v := expr.(type) {
+	case *ast.TernaryExpr:
+		if i.evalExpression(v.Condition).IsTrue() {
+			return i.resolveLvalue(v.Then)
+		}
+		return i.resolveLvalue(v.Else)
+	case *ast.AssignExpr:
+		i.evalAssignExpr(v)
+		return i.resolveLvalue(v.Left)
+	}
+	return expr
+}
+
 func (i *Interpreter) assignBack(expr ast.Expression, value *sv.SV) {
 	switch v := expr.(type) {
+	case *ast.TernaryExpr, *ast.AssignExpr:
+		i.assignBack(i.resolveLvalue(v), value)
 	case *ast.ScalarVar:
-		i.ctx.SetVar(v.Name, value)
+		i.ctx.SetVar(i.varKey("$", v.Name), value)
+	case *ast.SpecialVar:
+		if v.Name == "$_" {
+			i.ctx.SetVar("_", value)
+		} else {
+			i.ctx.SetSpecialVar(v.Name, value.Copy())
+		}
 	case *ast.ArrayAccess:
-		arr := i.evalExpression(v.Array)
+		var arr *sv.SV
+		if sv, ok := v.Array.(*ast.SpecialVar); ok && sv.Name == "$_" {
+			arr = i.ctx.GetArgs() // $_[n] - элемент @_
+		} else {
+			arr = i.container(v.Array, false)
+		}
 		idx := i.evalExpression(v.Index)
 		av.Store(arr, idx, value)
 	case *ast.HashAccess:
-		hash := i.evalExpression(v.Hash)
+		hash := i.container(v.Hash, true)
 		key := i.evalExpression(v.Key)
 		hv.Store(hash, key, value)
-	case *ast.ArrowAccess:
-		// $ref->[index] = ... or $ref->{key} = ...
-		left := i.evalExpression(v.Left)
-		target := left
-		if left.IsRef() {
-			target = left.Deref()
+		i.envChanged(v.Hash)
+	case *ast.ArraySlice:
+		// @arr[0, 1] = (9, 8); лишние индексы получают undef
+		arr := i.arrayBase(v.Array)
+		values := flattenArgs(i.svToList(value))
+		for idx, index := range i.evalSliceList(v.Indices) {
+			av.Store(arr, index, sliceValue(values, idx))
 		}
+	case *ast.HashSlice:
+		hash := i.hashBase(v.Hash)
+		values := flattenArgs(i.svToList(value))
+		for idx, key := range i.evalSliceList(v.Keys) {
+			hv.Store(hash, key, sliceValue(values, idx))
+		}
+		i.envChanged(v.Hash)
+	case *ast.ArrowAccess:
+		// $ref->[index] = ... or $ref->{key} = ...; пустой $ref получает новую ссылку
 		switch right := v.Right.(type) {
 		case *ast.ArrayAccess:
+			target := i.vivify(i.slot(v.Left), false)
 			idx := i.evalExpression(right.Index)
 			av.Store(target, idx, value)
 		case *ast.HashAccess:
+			target := i.vivify(i.slot(v.Left), true)
 			key := i.evalExpression(right.Key)
 			hv.Store(target, key, value)
 		}
+	case *ast.CallExpr:
+		// keys %h = 1024 - заранее выделяет место под ключи
+		if ident, ok := v.Function.(*ast.Identifier); ok && ident.Value == "keys" && len(v.Args) > 0 {
+			hv.Presize(i.container(v.Args[0], true), int(value.AsInt()))
+		}
 	case *ast.DerefExpr:
 		// $$ref = value - assign to dereferenced scalar
 		ref := i.evalExpression(v.Value)
-		if ref != nil && ref.IsRef() {
+		if v.Sigil == "$" && ref != nil && ref.IsRef() {
 			target := ref.Deref()
 			if target != nil {
 				target.CopyFrom(value)
@@ -956,116 +2150,57 @@ func (i *Interpreter) svToList(val *sv.SV) []*sv.SV {
 	return []*sv.SV{val}
 }
 
-// Заменить функцию interpolateString на:
+// interpolateString подставляет переменные и выражения в строку в двойных
+// кавычках; разбор строки общий с кодогенератором (pkg/interpolate)
 func (i *Interpreter) interpolateString(s string) string {
-	return interpolateRe.ReplaceAllStringFunc(s, func(match string) string {
-		// $arr[idx] - элемент массива
-		if strings.HasPrefix(match, "$") && strings.Contains(match, "[") {
-			// Извлекаем имя и индекс
-			bracketIdx := strings.Index(match, "[")
-			name := match[1:bracketIdx]
-			idxStr := match[bracketIdx+1 : len(match)-1]
-
-			// Получаем массив
-			val := i.ctx.GetVar(name)
-			if val == nil {
-				return ""
-			}
-
-			// Парсим индекс
-			var idx int64
-			fmt.Sscanf(idxStr, "%d", &idx)
-
-			// Получаем элемент
-			var target *sv.SV
-			if val.IsRef() {
-				target = val.Deref()
-			} else {
-				target = val
-			}
-			if target != nil && target.IsArray() {
-				elements := target.ArrayData()
-				if idx < 0 {
-					idx = int64(len(elements)) + idx
-				}
-				if idx >= 0 && idx < int64(len(elements)) {
-					return elements[idx].AsString()
-				}
-			}
-			return ""
-		}
-
-		// $hash{key} - элемент хеша
-		if strings.HasPrefix(match, "$") && strings.Contains(match, "{") && !strings.HasPrefix(match, "${") {
-			braceIdx := strings.Index(match, "{")
-			name := match[1:braceIdx]
-			key := match[braceIdx+1 : len(match)-1]
-
-			// Получаем хеш
-			val := i.ctx.GetVar(name)
-			if val == nil {
-				return ""
-			}
-
-			var target *sv.SV
-			if val.IsRef() {
-				target = val.Deref()
-			} else {
-				target = val
-			}
-			if target != nil && target.IsHash() {
-				if elem := target.HashData()[key]; elem != nil {
-					return elem.AsString()
-				}
-			}
-			return ""
-		}
-
-		// @array - весь массив
-		if match[0] == '@' {
-			name := match[1:]
-			val := i.ctx.GetVar(name)
-			if val != nil && val.IsArray() {
-				elements := val.ArrayData()
-				parts := make([]string, len(elements))
-				for idx, el := range elements {
-					parts[idx] = el.AsString()
+	var sb strings.Builder
+	for _, seg := range interpolate.Parse(s) {
+		switch {
+		case seg.Expr == nil:
+			sb.WriteString(seg.Text)
+		case seg.List:
+			elements := i.svToList(i.evalExpression(seg.Expr))
+			sep := i.ctx.GetSpecialVar("$\"").AsString()
+			for idx, el := range elements {
+				if idx > 0 {
+					sb.WriteString(sep)
 				}
-				return strings.Join(parts, " ")
+				sb.WriteString(el.AsString())
 			}
-			return ""
+		default:
+			sb.WriteString(i.evalExpression(seg.Expr).AsString())
 		}
-
-		// ${var} - переменная в фигурных скобках
-		if strings.HasPrefix(match, "${") {
-			name := match[2 : len(match)-1]
-			val := i.ctx.GetVar(name)
-			if val != nil {
-				return val.AsString()
-			}
-			return ""
-		}
-
-		// $var - простая переменная
-		name := match[1:]
-		val := i.ctx.GetVar(name)
-		if val != nil {
-			return val.AsString()
-		}
-		return ""
-	})
+	}
+	return sb.String()
 }
 
 func (i *Interpreter) callUserSub(name string, args []*sv.SV) *sv.SV {
+	if c, ok := i.constants[name]; ok {
+		return c
+	}
+	name = i.subName(name)
 	body := i.ctx.GetSub(name)
 	if body == nil {
 		return sv.NewUndef()
 	}
+	defer i.enterSub(name, args)()
+
+	// Замыкание выполняется в захваченной цепочке областей видимости
+	if env, ok := i.closures[name]; ok {
+		saved := i.ctx.SwapScopes(env)
+		defer i.ctx.SwapScopes(saved)
+	}
 
 	i.ctx.PushScope()
 	defer i.ctx.PopScope()
 
+	// после вызова @_ снова тот, что был у вызывающего
+	prevArgs := i.ctx.SwapArgs(nil)
+	defer i.ctx.SwapArgs(prevArgs)
 	i.ctx.SetArgs(args)
+	if params, ok := i.signatures[name]; ok {
+		i.bindSignature(name, params, args)
+	}
 
 	result := i.evalBlockStmt(body)
 
@@ -1093,6 +2228,13 @@ func (i *Interpreter) evalArrowAccess(expr *ast.ArrowAccess) *sv.SV {
 	case *ast.HashAccess:
 		key := i.evalExpression(right.Key)
 		return hv.Fetch(target, key)
+	case *ast.CallExpr:
+		// $code->(args)
+		args := make([]*sv.SV, len(right.Args))
+		for idx, arg := range right.Args {
+			args[idx] = i.evalExpression(arg)
+		}
+		return i.callCode(left, callArgs(right.Args, args))
 	default:
 		return sv.NewUndef()
 	}
@@ -1102,37 +2244,59 @@ func (i *Interpreter) evalMatchExpr(expr *ast.MatchExpr) *sv.SV {
 	target := i.evalExpression(expr.Target)
 	str := target.AsString()
 
-	pattern := expr.Pattern.Pattern
-	flags := expr.Pattern.Flags
-
-	// Build regex pattern with flags
-	rePattern := pattern
-	if strings.Contains(flags, "i") {
-		rePattern = "(?i)" + rePattern
+	var re *perlre.Regexp
+	var err error
+	flags := ""
+	if expr.Pattern != nil {
+		flags = expr.Pattern.Flags
+		re, err = i.compilePattern(expr, expr.Pattern.Pattern, flags)
+	} else {
+		// $str =~ $re: qr// значение или строка с шаблоном
+		pv := i.evalExpression(expr.PatternExpr)
+		rePattern, ok := pv.RegexPattern()
+		if !ok {
+			rePattern = pv.AsString()
+		}
+		re, err = i.compileRegex(rePattern)
 	}
-
-	re, err := regexp.Compile(rePattern)
 	if err != nil {
 		return sv.NewInt(0)
 	}
 
-	loc := re.FindStringSubmatchIndex(str)
+	// m//g в скалярном контексте продолжает с pos() переменной;
+	// при неудаче pos сбрасывается, если нет /c
+	posVar := ""
+	start := 0
+	if strings.Contains(flags, "g") {
+		posVar = matchPosVar(expr.Target)
+	}
+	if posVar != "" {
+		if p, ok := i.ctx.GetPos(posVar); ok && p <= len(str) {
+			start = p
+		}
+	}
+
+	loc := re.FindStringSubmatchIndex(str[start:])
 	matched := loc != nil
 
 	// Set match variables
 	if matched {
-		matches := re.FindStringSubmatch(str)
-		preMath := str[:loc[0]]
-		postMatch := str[loc[1]:]
-		fullMatch := matches[0]
-
-		// Extract capture groups (skip index 0 which is the full match)
-		captures := []string{}
-		if len(matches) > 1 {
-			captures = matches[1:]
+		for n := range loc {
+			if loc[n] >= 0 {
+				loc[n] += start
+			}
 		}
+		i.setMatch(re, str, loc)
 
-		i.ctx.SetMatchVars(fullMatch, preMath, postMatch, captures)
+		if posVar != "" {
+			end := loc[1]
+			if end == loc[0] {
+				end++ // пустое совпадение: не зацикливаться на одном месте
+			}
+			i.ctx.SetPos(posVar, end)
+		}
+	} else if posVar != "" && !strings.Contains(flags, "c") {
+		i.ctx.ClearPos(posVar)
 	}
 
 	if expr.Negate {
@@ -1148,82 +2312,269 @@ func (i *Interpreter) evalMatchExpr(expr *ast.MatchExpr) *sv.SV {
 	return sv.NewInt(0)
 }
 
+// setMatch выставляет $&, $`, $', $1..$N, %+ и %- по индексам совпадения
+// loc (FindStringSubmatchIndex); группа, не участвовавшая в совпадении, - undef
+func (i *Interpreter) setMatch(re *perlre.Regexp, str string, loc []int) {
+	captures := make([]*sv.SV, 0, len(loc)/2-1)
+	for n := 2; n+1 < len(loc); n += 2 {
+		c := sv.NewUndef()
+		if loc[n] >= 0 {
+			c = sv.NewString(str[loc[n]:loc[n+1]])
+		}
+		captures = append(captures, c)
+	}
+	i.ctx.SetMatch(str[loc[0]:loc[1]], str[:loc[0]], str[loc[1]:], captures, re.SubexpNames()[1:])
+}
+
+// evalMatchList - m// в списочном контексте: при совпадении список групп
+// ($1..$N), а шаблон без групп даёт (1); без совпадения - пустой список.
+// m//g отдаёт все совпадения (evalMatchAll), !~ остаётся скаляром
+func (i *Interpreter) evalMatchList(expr *ast.MatchExpr) *sv.SV {
+	if !expr.Negate && expr.Pattern != nil && strings.Contains(expr.Pattern.Flags, "g") {
+		return i.evalMatchAll(expr)
+	}
+	result := i.evalMatchExpr(expr)
+	if expr.Negate {
+		return result
+	}
+	if !result.IsTrue() {
+		return sv.NewArrayRef()
+	}
+	captures := i.ctx.Captures()
+	if len(captures) == 0 {
+		return sv.NewArrayRef(sv.NewInt(1))
+	}
+	return sv.NewArrayRef(i.copyList(captures)...)
+}
+
+// evalMatchAll - m//g в списочном контексте: все совпадения начиная с
+// pos() переменной; группы всех совпадений подряд, а без групп - сами
+// совпадения. pos после этого сбрасывается, переменные совпадения - от
+// последнего
+func (i *Interpreter) evalMatchAll(expr *ast.MatchExpr) *sv.SV {
+	str := i.evalExpression(expr.Target).AsString()
+	re, err := i.compilePattern(expr, expr.Pattern.Pattern, expr.Pattern.Flags)
+	if err != nil {
+		return sv.NewArrayRef()
+	}
+	start := 0
+	if posVar := matchPosVar(expr.Target); posVar != "" {
+		if p, ok := i.ctx.GetPos(posVar); ok && p <= len(str) {
+			start = p
+		}
+		i.ctx.ClearPos(posVar)
+	}
+
+	var out []*sv.SV
+	for _, loc := range re.FindAllStringSubmatchIndex(str[start:], -1) {
+		for n := range loc {
+			if loc[n] >= 0 {
+				loc[n] += start
+			}
+		}
+		i.setMatch(re, str, loc)
+		if len(loc) == 2 {
+			out = append(out, sv.NewString(str[loc[0]:loc[1]]))
+			continue
+		}
+		out = append(out, i.copyList(i.ctx.Captures())...)
+	}
+	return sv.NewArrayRef(out...)
+}
+
+// matchPosVar возвращает имя переменной, чей pos() использует m//g,
+// или "" для целей без имени (элементы, выражения)
+func matchPosVar(target ast.Expression) string {
+	switch t := target.(type) {
+	case *ast.ScalarVar:
+		return t.Name
+	case *ast.SpecialVar:
+		if t.Name == "$_" {
+			return "_"
+		}
+	}
+	return ""
+}
+
+// compilePattern компилирует литеральный шаблон с модификаторами:
+// /x убирает пробелы и комментарии до подстановки переменных,
+// /i /m /s становятся встроенными флагами, /o компилирует шаблон один раз.
+// Остальное переводит perlre: то, что RE2 не умеет (обратные ссылки,
+// просмотр вперёд/назад, притяжательные квантификаторы), выполняет
+// движок с возвратами.
+//
+// Расхождение с Perl: $ без /m в шаблоне, выполняемом RE2, совпадает
+// только в конце строки, а не перед завершающим \n.
+func (i *Interpreter) compilePattern(node ast.Expression, pattern, flags string) (*perlre.Regexp, error) {
+	once := strings.Contains(flags, "o")
+	if once {
+		if re, ok := i.onceRegex[node]; ok {
+			return re, nil
+		}
+	}
+	if strings.Contains(flags, "x") {
+		pattern = lexer.StripExtended(pattern)
+	}
+	re, err := i.compileRegex(inlineFlags(flags) + i.interpolatePattern(pattern))
+	if err == nil && once {
+		i.onceRegex[node] = re
+	}
+	return re, err
+}
+
+// inlineFlags переводит /i /m /s в префикс (?ims) для Go regexp
+func inlineFlags(flags string) string {
+	goFlags := ""
+	for _, f := range "ims" {
+		if strings.ContainsRune(flags, f) {
+			goFlags += string(f)
+		}
+	}
+	if goFlags == "" {
+		return ""
+	}
+	return "(?" + goFlags + ")"
+}
+
+// qrPattern собирает шаблон qr// в виде (?^flags:pattern), как его печатает
+// perl: он сохраняет свои модификаторы при подстановке в другой шаблон
+func qrPattern(pattern, flags string) string {
+	shown := ""
+	for _, f := range "msix" {
+		if strings.ContainsRune(flags, f) {
+			shown += string(f)
+		}
+	}
+	if strings.Contains(flags, "x") && strings.Contains(pattern[strings.LastIndex(pattern, "\n")+1:], "#") {
+	// комментарий /x в последней строке закрывается переводом строки
+		pattern += "\n"
+	}
+	return "(?^" + shown + ":" + pattern + ")"
+}
+
 func (i *Interpreter) evalSubstExpr(expr *ast.SubstExpr) *sv.SV {
-	target := i.evalExpression(expr.Target)
+	// (my $x = $y) =~ s/// изменяет $x
+	lvalue := i.resolveLvalue(expr.Target)
+	target := i.evalExpression(lvalue)
 	str := target.AsString()
 
-	pattern := expr.Pattern
 	replacement := expr.Replacement
 	flags := expr.Flags
 
-	// Build regex with flags
-	rePattern := pattern
-	if strings.Contains(flags, "i") {
-		rePattern = "(?i)" + rePattern
-	}
-
-	re, err := regexp.Compile(rePattern)
+	re, err := i.compilePattern(expr, expr.Pattern, flags)
 	if err != nil {
 		return sv.NewInt(0)
 	}
 
-	var result string
-	changed := false
+	// s///e: замена - код, а не строка
+	replace := func() string { return i.interpolateReplacement(replacement) }
+	if strings.Contains(flags, "e") {
+		replace = func() string { return i.evalSubstCode(expr) }
+	}
 
+	var locs [][]int
 	if strings.Contains(flags, "g") {
-		// Global replacement with capture group support
-		result = re.ReplaceAllStringFunc(str, func(match string) string {
-			// Get submatch for this specific match
-			submatches := re.FindStringSubmatch(match)
-			return i.interpolateReplacement(replacement, submatches)
-		})
-		changed = result != str
-	} else {
-		// Single replacement
-		loc := re.FindStringSubmatchIndex(str)
-		if loc != nil {
-			matches := re.FindStringSubmatch(str)
-			// Set match vars for potential use
-			if len(matches) > 1 {
-				i.ctx.SetMatchVars(matches[0], str[:loc[0]], str[loc[1]:], matches[1:])
+		locs = re.FindAllStringSubmatchIndex(str, -1)
+	} else if loc := re.FindStringSubmatchIndex(str); loc != nil {
+		locs = [][]int{loc}
+	}
+	if len(locs) == 0 {
+		return sv.NewInt(0)
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range locs {
+		// после s///g переменные совпадения - от последнего совпадения
+		i.setMatch(re, str, loc)
+		sb.WriteString(str[last:loc[0]])
+		sb.WriteString(replace())
+		last = loc[1]
+	}
+	sb.WriteString(str[last:])
+
+	// Записываем обратно: переменная, элемент массива/хеша или разыменование
+	i.assignBack(lvalue, sv.NewString(sb.String()))
+
+	// s/// возвращает число замен
+	return sv.NewInt(int64(len(locs)))
+}
+
+// interpolatePattern подставляет скаляры ($x, ${x}) в шаблон до компиляции.
+// qr// значения вставляются со своими флагами, \Q...\E экранирует метасимволы.
+func (i *Interpreter) interpolatePattern(pattern string) string {
+	if !strings.ContainsAny(pattern, "$\\") {
+		return pattern
+	}
+	var sb strings.Builder
+	for _, seg := range lexer.SplitPattern(pattern) {
+		part := seg.Text
+		if seg.Var != "" {
+			v := i.ctx.GetVar(seg.Var)
+			if p, ok := v.RegexPattern(); ok {
+				part = p
+			} else {
+				part = v.AsString()
 			}
-			interpolated := i.interpolateReplacement(replacement, matches)
-			result = str[:loc[0]] + interpolated + str[loc[1]:]
-			changed = true
-		} else {
-			result = str
 		}
+		if seg.Quoted {
+			part = regexp.QuoteMeta(part)
+		}
+		sb.WriteString(part)
 	}
+	return sb.String()
+}
 
-	// Update the variable if it's a scalar
-	if v, ok := expr.Target.(*ast.ScalarVar); ok {
-		i.ctx.SetVar(v.Name, sv.NewString(result))
+// interpolateReplacement строит замену s/// как строку в двойных кавычках:
+// переменные совпадения ($1, $&, $+{name}) к этому моменту уже выставлены
+func (i *Interpreter) interpolateReplacement(replacement string) string {
+	return i.interpolateString(lexer.SubstReplacement(replacement))
+}
+
+// evalTransExpr выполняет $x =~ tr/search/replace/ и возвращает число
+// найденных символов. Пустой список замены - только подсчёт; если замена
+// короче, повторяется её последний символ.
+func (i *Interpreter) evalTransExpr(expr *ast.TransExpr) *sv.SV {
+	lvalue := i.resolveLvalue(expr.Target)
+	str := i.evalExpression(lvalue).AsString()
+
+	result, count := perlstr.Tr(str, expr.Search, expr.Replace, expr.Flags)
+
+	// tr///r возвращает новую строку и не трогает исходную
+	if strings.Contains(expr.Flags, "r") {
+		return sv.NewString(result)
 	}
+	if result != str {
+		i.assignBack(lvalue, sv.NewString(result))
+	}
+	return sv.NewInt(int64(count))
+}
 
-	if changed {
-		return sv.NewInt(1)
+func (i *Interpreter) evalReadLineExpr(expr *ast.ReadLineExpr) *sv.SV {
+	line, ok := i.ctx.ReadLine(i.readLineHandle(expr))
+	if !ok {
+		return sv.NewUndef()
 	}
-	return sv.NewInt(0)
+	return sv.NewString(line)
 }
 
-// interpolateReplacement replaces $1, $2, etc. in replacement string with captured groups
-func (i *Interpreter) interpolateReplacement(replacement string, matches []string) string {
-	result := replacement
-	// Replace $1, $2, ... $9 with captured groups
-	for n := 9; n >= 1; n-- {
-		placeholder := fmt.Sprintf("$%d", n)
-		if strings.Contains(result, placeholder) {
-			value := ""
-			if n < len(matches) {
-				value = matches[n]
-			}
-			result = strings.ReplaceAll(result, placeholder, value)
+// evalReadLineList - <FH> в списочном контексте: все оставшиеся записи
+// (по $/), как my @lines = <$fh>
+func (i *Interpreter) evalReadLineList(expr *ast.ReadLineExpr) *sv.SV {
+	name := i.readLineHandle(expr)
+	var lines []*sv.SV
+	for {
+		line, ok := i.ctx.ReadLine(name)
+		if !ok {
+			break
 		}
+		lines = append(lines, sv.NewString(line))
 	}
-	return result
+	return sv.NewArrayRef(lines...)
 }
 
-func (i *Interpreter) evalReadLineExpr(expr *ast.ReadLineExpr) *sv.SV {
+// readLineHandle - имя handle из <FH> или <$fh>; пустое имя - STDIN
+func (i *Interpreter) readLineHandle(expr *ast.ReadLineExpr) string {
 	var name string
 	if expr.Filehandle != nil {
 		switch fh := expr.Filehandle.(type) {
@@ -1240,12 +2591,7 @@ func (i *Interpreter) evalReadLineExpr(expr *ast.ReadLineExpr) *sv.SV {
 			}
 		}
 	}
-
-	line, ok := i.ctx.ReadLine(name)
-	if !ok {
-		return sv.NewUndef()
-	}
-	return sv.NewString(line)
+	return name
 }
 
 func boolToSV(b bool) *sv.SV {
@@ -0,0 +1,231 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/av"
+	"perlc/pkg/context"
+	"perlc/pkg/hv"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/sv"
+)
+
+// eval BLOCK и local. die внутри eval превращается в panic(context.PerlDie),
+// которую ловит Runtime.TryEval и кладёт сообщение в $@.
+// Значения local восстанавливаются при выходе из охватывающего блока,
+// в том числе при раскрутке стека после die.
+
+// evalEvalBlock выполняет eval { ... }: значение блока или undef при die
+func (i *Interpreter) evalEvalBlock(expr *ast.EvalBlockExpr) *sv.SV {
+	result := sv.NewUndef()
+	ok := context.GetRuntime().TryEval(func() {
+		defer i.enterEval("")()
+		result = i.evalBlockStmt(expr.Body)
+		// return внутри eval выходит только из eval
+		if i.ctx.HasReturn() {
+			result = i.ctx.ReturnValue()
+			i.ctx.ClearReturn()
+		}
+	})
+	if !ok || result == nil {
+		return sv.NewUndef()
+	}
+	return result
+}
+
+// evalEvalString выполняет eval "код": строка разбирается заново лексером и
+// парсером и исполняется в текущей области видимости (лексические
+// переменные видны, свои my не выходят наружу). Синтаксическая ошибка
+// или die кладут сообщение в $@.
+func (i *Interpreter) evalEvalString(expr *ast.EvalStringExpr) *sv.SV {
+	var code string
+	if expr.Code != nil {
+		code = i.evalExpression(expr.Code).AsString()
+	} else {
+		code = i.evalSpecialVar("$_").AsString()
+	}
+
+	i.evalCount++
+	name := fmt.Sprintf("(eval %d)", i.evalCount)
+	p := parser.New(lexer.NewFile(code, name))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		context.GetRuntime().SetEvalError(sv.NewString(fmt.Sprintf("syntax error at %s %s\n", name, errs[0])))
+		return sv.NewUndef()
+	}
+
+	result := sv.NewUndef()
+	ok := context.GetRuntime().TryEval(func() {
+		defer i.enterEval(code)()
+		i.ctx.PushScope()
+		defer i.ctx.PopScope()
+		result = i.evalBlockStmt(&ast.BlockStmt{Statements: program.Statements})
+		if i.ctx.HasReturn() {
+			result = i.ctx.ReturnValue()
+			i.ctx.ClearReturn()
+		}
+	})
+	if !ok || result == nil {
+		return sv.NewUndef()
+	}
+	return result
+}
+
+// evalSubstCode вычисляет замену s///e как код Perl в текущей области
+// видимости; код разбирается один раз на узел. Синтаксическая ошибка в
+// замене - die
+func (i *Interpreter) evalSubstCode(expr *ast.SubstExpr) string {
+	stmts, ok := i.substCode[expr]
+	if !ok {
+		p := parser.New(lexer.New(strings.ReplaceAll(expr.Replacement, `\/`, "/")))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			i.builtinDie([]*sv.SV{sv.NewString(fmt.Sprintf("syntax error in s///e replacement: %s", errs[0]))})
+			return ""
+		}
+		stmts = program.Statements
+		i.substCode[expr] = stmts
+	}
+
+	i.ctx.PushScope()
+	defer i.ctx.PopScope()
+	result := i.evalBlockStmt(&ast.BlockStmt{Statements: stmts})
+	if result == nil {
+		return ""
+	}
+	return result.AsString()
+}
+
+// evalLocal сохраняет текущие значения и присваивает новые (local $x = ...,
+// local @a, local %h, local $SIG{ALRM} = ...). Старые значения вернёт
+// unwindLocals. Переменные интерпретатора живут в областях видимости
+// контекста, а не в stash, поэтому Runtime.LocalScalar здесь не подходит.
+func (i *Interpreter) evalLocal(decl *ast.VarDecl) *sv.SV {
+	var value *sv.SV
+	if decl.Value != nil {
+		value = i.evalExpression(decl.Value)
+	}
+
+	// local @a / local %h: новый пустой (или присвоенный) массив или хэш
+	if !decl.IsList && len(decl.Names) == 1 && isNamedAggregate(decl.Names[0]) {
+		i.saveLocal(decl.Names[0])
+		var items []*sv.SV
+		if value != nil {
+			items = i.svToList(value)
+		}
+		return i.localContainer(decl.Names[0], items)
+	}
+
+	var values []*sv.SV
+	if decl.IsList && value != nil {
+		values = i.svToList(value)
+	}
+	for idx, name := range decl.Names {
+		i.saveLocal(name)
+		if decl.IsList && isNamedAggregate(name) {
+			// local ($x, @rest) = ...: массив или хэш забирает остаток списка
+			var items []*sv.SV
+			if idx < len(values) {
+				items = values[idx:]
+				values = values[:idx]
+			}
+			i.localContainer(name, items)
+			continue
+		}
+		val := sv.NewUndef()
+		switch {
+		case decl.IsList && idx < len(values):
+			val = values[idx]
+		case !decl.IsList && value != nil:
+			val = value
+		}
+		i.assignBack(name, val)
+	}
+	if value == nil {
+		return sv.NewUndef()
+	}
+	return value
+}
+
+// isNamedAggregate - @a или %h по имени, не разыменование
+func isNamedAggregate(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.ArrayVar, *ast.HashVar:
+		return true
+	}
+	return false
+}
+
+// localContainer ставит на место @a или %h новый массив или хэш из items
+func (i *Interpreter) localContainer(name ast.Expression, items []*sv.SV) *sv.SV {
+	if v, ok := name.(*ast.HashVar); ok {
+		hash := sv.NewHashRef().Deref()
+		for j := 0; j+1 < len(items); j += 2 {
+			hash.HashData()[items[j].AsString()] = items[j+1]
+		}
+		i.ctx.SetVar(i.varKey("%", v.Name), hash)
+		i.envChanged(v)
+		return hash
+	}
+	key := i.varKey("@", name.(*ast.ArrayVar).Name)
+	i.ctx.SetVar(key, sv.NewArrayRef(items...).Deref())
+	return i.ctx.GetVar(key)
+}
+
+// saveLocal запоминает, как восстановить переменную или элемент хэша
+func (i *Interpreter) saveLocal(expr ast.Expression) {
+	switch v := expr.(type) {
+	case *ast.ScalarVar:
+		name := i.varKey("$", v.Name)
+		old := i.ctx.GetVar(name)
+		i.locals = append(i.locals, func() { i.ctx.SetVar(name, old) })
+	case *ast.ArrayVar:
+		name := i.varKey("@", v.Name)
+		old := i.ctx.GetVar(name)
+		i.locals = append(i.locals, func() { i.ctx.SetVar(name, old) })
+	case *ast.HashVar:
+		name := i.varKey("%", v.Name)
+		old := i.ctx.GetVar(name)
+		i.locals = append(i.locals, func() {
+			i.ctx.SetVar(name, old)
+			i.envChanged(v)
+		})
+	case *ast.SpecialVar:
+		old := i.evalSpecialVar(v.Name)
+		i.locals = append(i.locals, func() { i.assignBack(v, old) })
+	case *ast.ArrayAccess:
+		arr := i.arrayBase(v.Array)
+		idx := i.evalExpression(v.Index)
+		old := av.Fetch(arr, idx)
+		i.locals = append(i.locals, func() { av.Store(arr, idx, old) })
+	case *ast.HashAccess:
+		hash := i.hashBase(v.Hash)
+		key := i.evalExpression(v.Key)
+		if !hv.Exists(hash, key).IsTrue() {
+			// элемента не было - после блока его снова не должно быть
+			i.locals = append(i.locals, func() {
+				hv.Delete(hash, key)
+				i.envChanged(v.Hash)
+			})
+			return
+		}
+		old := hv.Fetch(hash, key)
+		i.locals = append(i.locals, func() {
+			hv.Store(hash, key, old)
+			i.envChanged(v.Hash)
+		})
+	}
+}
+
+// unwindLocals восстанавливает значения local, сохранённые после mark
+func (i *Interpreter) unwindLocals(mark int) {
+	for len(i.locals) > mark {
+		last := len(i.locals) - 1
+		restore := i.locals[last]
+		i.locals = i.locals[:last]
+		restore()
+	}
+}
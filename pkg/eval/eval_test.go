@@ -2,7 +2,9 @@ package eval
 
 import (
 	"bytes"
+	"strings"
 	"testing"
+	"time"
 
 	"perlc/pkg/lexer"
 	"perlc/pkg/parser"
@@ -403,3 +405,113 @@ func TestForDebug(t *testing.T) {
 		t.Logf("stmt[%d]: %T = %s", i, stmt, stmt.String())
 	}
 }
+
+// ============================================================
+// Glob Tests
+// ============================================================
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*.{log,txt}", "*.log *.txt"},
+		{"a{b,c{d,e}}f", "abf acdf acef"},
+		{"x{}y", "x{}y"},
+		{"plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		got := strings.Join(expandBraces(tt.input), " ")
+		if got != tt.expected {
+			t.Errorf("expandBraces(%q): expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestGlobScalarIterator(t *testing.T) {
+	output, _ := evalInput(`
+		my $f;
+		while ($f = glob("b{1,2,3}")) { say $f; }
+		my @all = glob("x{a,b}y");
+		say join(",", @all);
+	`)
+	if output != "b1\nb2\nb3\nxay,xby\n" {
+		t.Errorf("expected 'b1\\nb2\\nb3\\nxay,xby\\n', got %q", output)
+	}
+}
+
+func TestOverloadStringify(t *testing.T) {
+	output, _ := evalInput(`
+		package Point;
+		use overload '""' => sub { my $p = shift; return "<" . $p->{x} . ">"; };
+		package main;
+		sub Point::new { my ($class, $x) = @_; return bless { x => $x }, $class; }
+		sub Point3::new { my ($class, $x) = @_; return bless { x => $x }, $class; }
+		@Point3::ISA = ('Point');
+		my $p = Point->new(1);
+		my $q = Point3->new(3);
+		print $p, "\n";
+		say "$p $q";
+	`)
+	if output != "<1>\n<1> <3>\n" {
+		t.Errorf("expected '<1>\\n<1> <3>\\n', got %q", output)
+	}
+}
+
+func TestStderrWriter(t *testing.T) {
+	p := parser.New(lexer.New(`
+		print "out\n";
+		print STDERR "err ", 1, "\n";
+		say STDOUT "say out";
+		say STDERR "say err";
+		warn "careful";
+	`))
+	program := p.ParseProgram()
+
+	interp := New()
+	var stdout, stderr bytes.Buffer
+	interp.SetStdout(&stdout)
+	interp.SetStderr(&stderr)
+	interp.Eval(program)
+
+	if got := stdout.String(); got != "out\nsay out\n" {
+		t.Errorf("stdout: expected 'out\\nsay out\\n', got %q", got)
+	}
+	if got := stderr.String(); got != "err 1\nsay err\ncareful\n" {
+		t.Errorf("stderr: expected 'err 1\\nsay err\\ncareful\\n', got %q", got)
+	}
+}
+
+func TestReloadOnHUP(t *testing.T) {
+	run := func(code string) (out string, reloaded bool) {
+		interp := New()
+		var buf bytes.Buffer
+		interp.SetStdout(&buf)
+		interp.SetReload(true)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			interp.Signal("HUP")
+		}()
+		defer func() {
+			if r := recover(); r != nil {
+				if r != ErrReload {
+					panic(r)
+				}
+				out, reloaded = buf.String(), true
+			}
+		}()
+		interp.Eval(parser.New(lexer.New(code)).ParseProgram())
+		return buf.String(), false
+	}
+
+	out, reloaded := run(`say "a"; sleep 5; say "b";`)
+	if !reloaded || out != "a\n" {
+		t.Errorf("HUP: reloaded = %v, output %q; want true, %q", reloaded, out, "a\n")
+	}
+
+	out, reloaded = run(`$SIG{HUP} = sub { say "got $_[0]" }; sleep 5; say "b";`)
+	if reloaded || out != "got HUP\nb\n" {
+		t.Errorf("HUP with handler: reloaded = %v, output %q; want false, %q", reloaded, out, "got HUP\nb\n")
+	}
+}
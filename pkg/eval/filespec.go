@@ -0,0 +1,368 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/perlre"
+	"perlc/pkg/sv"
+)
+
+// Пути и каталоги: File::Spec (методы класса), File::Basename, File::Path
+// и Cwd::abs_path поверх path/filepath и os, в unix-варианте
+
+// fileFuncs - функции File::Basename, File::Path и Cwd по модулю;
+// fileDefaults - те, что модуль экспортирует без списка
+var fileFuncs = map[string]string{
+	"basename": "File::Basename", "dirname": "File::Basename", "fileparse": "File::Basename",
+	"make_path": "File::Path", "mkpath": "File::Path", "remove_tree": "File::Path", "rmtree": "File::Path",
+	"abs_path": "Cwd", "realpath": "Cwd",
+}
+
+var fileDefaults = map[string]bool{
+	"basename": true, "dirname": true, "fileparse": true, "mkpath": true, "rmtree": true,
+}
+
+// useFileModule - use File::Basename, File::Path или Cwd: импортированные
+// имена вызывают Module::...
+func (i *Interpreter) useFileModule(module string, args []ast.Expression) {
+	if i.fileFuncs == nil {
+		i.fileFuncs = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		for name, m := range fileFuncs {
+			if m == module && fileDefaults[name] {
+				i.fileFuncs[name] = true
+			}
+		}
+		return
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if fileFuncs[v.AsString()] == module {
+				i.fileFuncs[v.AsString()] = true
+			}
+		}
+	}
+}
+
+// builtinFileFunc - функции File::Basename, File::Path и Cwd::abs_path
+func (i *Interpreter) builtinFileFunc(name string, args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	path := ""
+	if len(args) > 0 {
+		path = args[0].AsString()
+	}
+	switch name {
+	case "File::Basename::fileparse":
+		base, dir, suffix := fileparse(path, suffixPatterns(args[min(1, len(args)):], false))
+		return sv.NewArrayRef(sv.NewString(base), sv.NewString(dir), sv.NewString(suffix))
+	case "File::Basename::basename":
+		return sv.NewString(basename(path, suffixPatterns(args[min(1, len(args)):], true)))
+	case "File::Basename::dirname":
+		return sv.NewString(dirname(path))
+	case "File::Path::make_path", "File::Path::mkpath":
+		var created []*sv.SV
+		for _, dir := range pathArgs(args) {
+			for _, d := range makePath(dir) {
+				created = append(created, sv.NewString(d))
+			}
+		}
+		return sv.NewArrayRef(created...)
+	case "File::Path::remove_tree", "File::Path::rmtree":
+		count := 0
+		for _, dir := range pathArgs(args) {
+			count += removeTree(dir)
+		}
+		return sv.NewInt(int64(count))
+	case "Cwd::abs_path", "Cwd::realpath":
+		if path == "" {
+			path = "."
+		}
+		abs, err := filepath.Abs(path)
+		if err == nil {
+			abs, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			context.GetRuntime().SetOSError(err)
+			return sv.NewUndef()
+		}
+		return sv.NewString(abs)
+	}
+	return sv.NewUndef()
+}
+
+// pathArgs - каталоги make_path и remove_tree: хеш опций в конце и старая
+// форма mkpath([dirs], verbose, mode) не считаются каталогами
+func pathArgs(args []*sv.SV) []string {
+	var dirs []string
+	for _, a := range args {
+		switch {
+		case a.IsRef() && a.Deref().IsArray():
+			for _, d := range a.Deref().ArrayData() {
+				dirs = append(dirs, d.AsString())
+			}
+			return dirs
+		case a.IsRef():
+			continue
+		}
+		dirs = append(dirs, a.AsString())
+	}
+	return dirs
+}
+
+// suffixPatterns - суффиксы fileparse: регулярные выражения (qr// или
+// строка), для basename - буквальные строки
+func suffixPatterns(args []*sv.SV, literal bool) []*perlre.Regexp {
+	var res []*perlre.Regexp
+	for _, a := range args {
+		pattern, ok := a.RegexPattern()
+		if !ok {
+			pattern = a.AsString()
+			if literal {
+				pattern = regexp.QuoteMeta(pattern)
+			}
+		}
+		if re, err := perlre.Compile("(?s:" + pattern + ")\\z"); err == nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+// fileparse - (имя, каталог с "/" на конце или "./", суффикс); суффиксы
+// снимаются по очереди с конца имени
+func fileparse(path string, suffixes []*perlre.Regexp) (string, string, string) {
+	dir, base := "./", path
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		dir, base = path[:idx+1], path[idx+1:]
+	}
+	tail := ""
+	for _, re := range suffixes {
+		if loc := re.FindStringSubmatchIndex(base); loc != nil {
+			tail = base[loc[0]:] + tail
+			base = base[:loc[0]]
+		}
+	}
+	return base, dir, tail
+}
+
+var trailingSepRe = regexp.MustCompile(`(.)/*\z`)
+
+// stripTrailingSep убирает "/" в конце, кроме корня
+func stripTrailingSep(path string) string {
+	return trailingSepRe.ReplaceAllString(path, "$1")
+}
+
+// basename - последняя часть пути без "/" на конце; суффикс снимается,
+// только если от имени что-то остаётся
+func basename(path string, suffixes []*perlre.Regexp) string {
+	base, dir, suffix := fileparse(stripTrailingSep(path), suffixes)
+	if suffix != "" && base == "" {
+		base = suffix
+	}
+	if base == "" {
+		base = dir
+	}
+	return base
+}
+
+// dirname - путь без последней части: "." для простого имени, "/" для
+// корня
+func dirname(path string) string {
+	base, dir, _ := fileparse(path, nil)
+	dir = stripTrailingSep(dir)
+	if base == "" {
+		_, dir, _ = fileparse(dir, nil)
+		dir = stripTrailingSep(dir)
+	}
+	if dir == "./" {
+		return "."
+	}
+	return dir
+}
+
+// makePath создаёт каталог вместе с недостающими родителями и возвращает
+// созданные, начиная с внешнего
+func makePath(dir string) []string {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+	var created []string
+	for _, d := range missing {
+		if err := os.Mkdir(d, 0o777); err != nil {
+			context.GetRuntime().SetOSError(err)
+			break
+		}
+		created = append(created, d)
+	}
+	return created
+}
+
+// removeTree удаляет каталог со всем содержимым и возвращает число
+// удалённых файлов и каталогов
+func removeTree(dir string) int {
+	count := 0
+	filepath.Walk(dir, func(string, os.FileInfo, error) error {
+		count++
+		return nil
+	})
+	if _, err := os.Lstat(dir); err != nil {
+		return 0
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		context.GetRuntime().SetOSError(err)
+		return 0
+	}
+	return count
+}
+
+// fileSpecMethod - методы класса File::Spec для unix: пути склеиваются
+// через "/" и приводятся canonpath, ".." не раскрывается
+func (i *Interpreter) fileSpecMethod(method string, args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	strs := make([]string, len(args))
+	for n, a := range args {
+		strs[n] = a.AsString()
+	}
+	arg := func(n int) string {
+		if n < len(strs) {
+			return strs[n]
+		}
+		return ""
+	}
+	switch method {
+	case "canonpath":
+		return sv.NewString(canonpath(arg(0)))
+	case "catdir":
+		return sv.NewString(catdir(strs))
+	case "catfile":
+		return sv.NewString(catfile(strs))
+	case "catpath":
+		dir, file := arg(1), arg(2)
+		if dir != "" && file != "" && !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+		return sv.NewString(dir + file)
+	case "splitpath":
+		path := arg(0)
+		if len(args) > 1 && args[1].IsTrue() {
+			return sv.NewArrayRef(sv.NewString(""), sv.NewString(path), sv.NewString(""))
+		}
+		dir, file := "", path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 {
+			dir, file = path[:idx+1], path[idx+1:]
+		}
+		return sv.NewArrayRef(sv.NewString(""), sv.NewString(dir), sv.NewString(file))
+	case "splitdir":
+		var parts []*sv.SV
+		for _, p := range strings.Split(arg(0), "/") {
+			parts = append(parts, sv.NewString(p))
+		}
+		return sv.NewArrayRef(parts...)
+	case "file_name_is_absolute":
+		return boolToSV(strings.HasPrefix(arg(0), "/"))
+	case "rel2abs":
+		if strings.HasPrefix(arg(0), "/") {
+			return sv.NewString(canonpath(arg(0)))
+		}
+		base := arg(1)
+		if base == "" {
+			base, _ = os.Getwd()
+		} else if !strings.HasPrefix(base, "/") {
+			base = i.fileSpecMethod("rel2abs", args[1:2]).AsString()
+		}
+		return sv.NewString(catdir([]string{base, arg(0)}))
+	case "abs2rel":
+		base := arg(1)
+		if base == "" {
+			base, _ = os.Getwd()
+		}
+		path, _ := filepath.Abs(arg(0))
+		base, _ = filepath.Abs(base)
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return sv.NewString(canonpath(arg(0)))
+		}
+		return sv.NewString(rel)
+	case "tmpdir":
+		if dir := os.Getenv("TMPDIR"); dir != "" {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return sv.NewString(canonpath(dir))
+			}
+		}
+		return sv.NewString("/tmp")
+	case "curdir":
+		return sv.NewString(".")
+	case "updir":
+		return sv.NewString("..")
+	case "rootdir":
+		return sv.NewString("/")
+	case "devnull":
+		return sv.NewString("/dev/null")
+	}
+	return i.builtinDie([]*sv.SV{sv.NewString(`Can't locate object method "` + method + `" via package "File::Spec"` + i.at() + ".\n")})
+}
+
+var (
+	multiSlashRe = regexp.MustCompile(`/{2,}`)
+	dotSegRe     = regexp.MustCompile(`(?:/\.)+(?:/|\z)`)
+	leadDotRe    = regexp.MustCompile(`^(?:\./)+`)
+	rootUpRe     = regexp.MustCompile(`^/(?:\.\./)+`)
+)
+
+// canonpath - File::Spec::Unix::canonpath: лишние "/" и "." убираются,
+// ".." в начале абсолютного пути отбрасывается
+func canonpath(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = multiSlashRe.ReplaceAllString(path, "/")
+	path = dotSegRe.ReplaceAllString(path, "/")
+	if path != "./" {
+		path = leadDotRe.ReplaceAllString(path, "")
+	}
+	path = rootUpRe.ReplaceAllString(path, "/")
+	if path == "/.." {
+		path = "/"
+	}
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// catdir склеивает каталоги; пустой первый означает корень
+func catdir(dirs []string) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	return canonpath(strings.Join(dirs, "/") + "/")
+}
+
+// catfile - catdir каталогов и имя файла в конце
+func catfile(parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	file := canonpath(parts[len(parts)-1])
+	if len(parts) == 1 {
+		return file
+	}
+	dir := catdir(parts[:len(parts)-1])
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir + file
+}
@@ -0,0 +1,383 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// Форматы и write. format NAME = ... . объявляет отчёт: строки-шаблоны с
+// полями и строки значений для них. Шаблон компилируется один раз при
+// объявлении; write выводит его в handle, заголовок страницы (формат
+// NAME_TOP или $^) - когда на странице ($=, 60 строк) не осталось места.
+// Состояние страницы ($~ $^ $= $- $%) у каждого handle своё и хранится в
+// контексте.
+
+// format - скомпилированный формат
+type format struct {
+	name  string
+	lines []*formatLine
+}
+
+// formatLine - строка шаблона: части (текст и поля) и значения для полей
+type formatLine struct {
+	parts  []formatPart
+	blank  bool // ~ - строка без значений не выводится
+	repeat bool // ~~ - строка повторяется, пока есть значения
+	args   []ast.Expression
+}
+
+// formatPart - кусок текста шаблона или поле, если field != nil
+type formatPart struct {
+	text  string
+	field *formatField
+}
+
+// formatField - поле шаблона. kind: '<' '>' '|' - текст с выравниванием,
+// '#' - число, '*' - значение целиком (@*) или до конца строки (^*)
+type formatField struct {
+	kind     byte
+	width    int
+	caret    bool // ^ - выведенный текст отрезается от переменной
+	decimals int  // цифр после точки у числа
+	zero     bool // @0## - число с ведущими нулями
+	more     bool // ^<<<... - многоточие, если текст не поместился
+}
+
+// formatArg - значение для поля и выражение, куда вернуть остаток ^-поля
+type formatArg struct {
+	expr  ast.Expression
+	value *sv.SV
+}
+
+// declareFormats объявляет форматы программы заранее: в Perl они
+// объявляются при компиляции, и write обычно стоит выше них
+func (i *Interpreter) declareFormats(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FormatDecl); ok {
+			i.declareFormat(decl)
+		}
+	}
+}
+
+// declareFormat компилирует шаблон формата. Как и в Perl, из нескольких
+// форматов с одним именем действует последний
+func (i *Interpreter) declareFormat(decl *ast.FormatDecl) {
+	if i.formats == nil {
+		i.formats = make(map[string]*format)
+	}
+	name := strings.TrimPrefix(decl.Name, "main::")
+	f := &format{name: name}
+	for _, line := range decl.Lines {
+		compiled := compilePicture(line.Picture)
+		compiled.args = line.Args
+		f.lines = append(f.lines, compiled)
+	}
+	i.formats[name] = f
+}
+
+// compilePicture разбирает строку шаблона на текст и поля
+func compilePicture(picture string) *formatLine {
+	line := &formatLine{
+		blank:  strings.Contains(picture, "~"),
+		repeat: strings.Contains(picture, "~~"),
+	}
+	runes := []rune(strings.ReplaceAll(picture, "~", " "))
+	var text strings.Builder
+	for n := 0; n < len(runes); {
+		if runes[n] != '@' && runes[n] != '^' {
+			text.WriteRune(runes[n])
+			n++
+			continue
+		}
+		if text.Len() > 0 {
+			line.parts = append(line.parts, formatPart{text: text.String()})
+			text.Reset()
+		}
+		field, size := compileField(runes[n:])
+		line.parts = append(line.parts, formatPart{field: field})
+		n += size
+	}
+	if text.Len() > 0 {
+		line.parts = append(line.parts, formatPart{text: text.String()})
+	}
+	return line
+}
+
+// compileField разбирает поле в начале runes (с @ или ^) и возвращает его
+// и сколько символов оно заняло
+func compileField(runes []rune) (*formatField, int) {
+	field := &formatField{kind: '<', width: 1, caret: runes[0] == '^'}
+	n := 1
+	at := func(k int) rune {
+		if k < len(runes) {
+			return runes[k]
+		}
+		return 0
+	}
+	switch c := at(n); {
+	case c == '*':
+		field.kind = '*'
+		field.width = 0
+		return field, 2
+	case c == '<' || c == '>' || c == '|':
+		field.kind = byte(c)
+		for at(n) == c {
+			n++
+		}
+		if field.caret && at(n) == '.' && at(n+1) == '.' && at(n+2) == '.' {
+			field.more = true
+			n += 3
+		}
+		field.width = n
+	case c == '#' || c == '0' && (at(n+1) == '#' || at(n+1) == '.') || c == '.' && at(n+1) == '#':
+		field.kind = '#'
+		if c == '0' {
+			field.zero = true
+		}
+		for at(n) == '#' || n == 1 && at(n) == '0' {
+			n++
+		}
+		if at(n) == '.' && at(n+1) == '#' {
+			n++
+			for at(n) == '#' {
+				n++
+				field.decimals++
+			}
+		}
+		field.width = n
+	}
+	return field, n
+}
+
+// builtinWrite - write(FH): выводит запись по формату $~ handle (по
+// умолчанию - выбранного select), перед ней при необходимости заголовок
+// страницы
+func (i *Interpreter) builtinWrite(expr *ast.CallExpr) *sv.SV {
+	handle := i.ctx.Selected()
+	if len(expr.Args) > 0 {
+		handle = i.fileHandleName(expr.Args[0])
+	}
+	handle = strings.TrimPrefix(handle, "main::")
+	st := i.ctx.Format(handle)
+	f := i.formats[strings.TrimPrefix(st.Name, "main::")]
+	if f == nil {
+		return i.builtinDie([]*sv.SV{sv.NewString(`Undefined format "` + st.Name + `" called` + i.at() + ".\n")})
+	}
+	w, fh := i.handleWriter(handle)
+	body := i.formatRecord(f)
+	lines := strings.Count(body, "\n")
+	if st.LinesLeft < lines {
+		top := i.formats[strings.TrimPrefix(st.Top, "main::")]
+		if top == nil && i.formats["top"] != nil && st.Top == handle+"_TOP" {
+			top = i.formats["top"]
+		}
+		if top != nil {
+			if st.Page > 0 {
+				io.WriteString(w, i.ctx.GetSpecialVar("$^L").AsString())
+			}
+			st.LinesLeft = st.PageLen
+			st.Page++
+			head := i.formatRecord(top)
+			io.WriteString(w, head)
+			st.LinesLeft -= strings.Count(head, "\n")
+		} else {
+			st.LinesLeft = st.PageLen
+		}
+	}
+	st.LinesLeft = max(0, st.LinesLeft-lines)
+	io.WriteString(w, body)
+	if fh != nil && fh.Autoflush {
+		fh.Flush()
+	}
+	return sv.NewInt(1)
+}
+
+// formatRecord заполняет формат значениями и возвращает готовый текст
+func (i *Interpreter) formatRecord(f *format) string {
+	var out strings.Builder
+	for _, line := range f.lines {
+		for {
+			text, gotsome := i.formatLine(line)
+			if line.blank && !gotsome {
+				break
+			}
+			out.WriteString(text)
+			if !line.repeat {
+				break
+			}
+			if !line.hasCaret() {
+				i.builtinDie([]*sv.SV{sv.NewString("Runaway format" + i.at() + ".\n")})
+				break
+			}
+		}
+	}
+	return out.String()
+}
+
+// hasCaret - есть ли в строке ^-поле, без которого ~~ не кончится
+func (line *formatLine) hasCaret() bool {
+	for _, part := range line.parts {
+		if part.field != nil && part.field.caret {
+			return true
+		}
+	}
+	return false
+}
+
+// formatArgs вычисляет значения строки; у скаляров запоминается
+// выражение, чтобы ^-поле могло отрезать от них выведенный текст
+func (i *Interpreter) formatArgs(exprs []ast.Expression) []formatArg {
+	var args []formatArg
+	for _, expr := range exprs {
+		switch expr.(type) {
+		case *ast.ScalarVar, *ast.SpecialVar, *ast.ArrayAccess, *ast.HashAccess:
+			args = append(args, formatArg{expr: expr, value: i.evalExpression(expr)})
+			continue
+		}
+		for _, v := range i.flattenArgs([]*sv.SV{i.evalExpression(expr)}) {
+			args = append(args, formatArg{value: v})
+		}
+	}
+	return args
+}
+
+// formatLine заполняет одну строку шаблона. gotsome - было ли в полях
+// хоть одно значение, для ~ и ~~
+func (i *Interpreter) formatLine(line *formatLine) (string, bool) {
+	args := i.formatArgs(line.args)
+	var sb strings.Builder
+	gotsome := false
+	next := 0
+	for _, part := range line.parts {
+		if part.field == nil {
+			sb.WriteString(part.text)
+			continue
+		}
+		arg := formatArg{value: sv.NewUndef()}
+		if next < len(args) {
+			arg = args[next]
+		}
+		next++
+		text, rest, some := fillField(part.field, arg.value, i.ctx.GetSpecialVar("$:").AsString())
+		gotsome = gotsome || some
+		sb.WriteString(text)
+		if part.field.caret && arg.expr != nil && !arg.value.IsUndef() {
+			i.assignBack(arg.expr, sv.NewString(rest))
+		}
+	}
+	return strings.TrimRight(sb.String(), " ") + "\n", gotsome
+}
+
+// fillField заполняет поле значением v. Для ^-поля rest - то, что
+// останется в переменной; chopset - $:, символы, после которых можно
+// перенести строку
+func fillField(field *formatField, v *sv.SV, chopset string) (text, rest string, gotsome bool) {
+	if field.kind == '#' {
+		if field.caret && v.IsUndef() {
+			return strings.Repeat(" ", field.width), "", false
+		}
+		spec := "%*.*f"
+		if field.zero {
+			spec = "%0*.*f"
+		}
+		text = fmt.Sprintf(spec, field.width, field.decimals, v.AsFloat())
+		if len(text) > field.width {
+			text = strings.Repeat("#", field.width)
+		}
+		return text, "", true
+	}
+	s := ""
+	if !v.IsUndef() {
+		s = v.AsString()
+	}
+	chopspace := strings.ContainsRune(chopset, ' ')
+	var item string
+	switch {
+	case field.kind == '*' && !field.caret:
+		item = strings.TrimSuffix(s, "\n")
+		return item, "", item != ""
+	case field.kind == '*':
+		item, rest, _ = strings.Cut(s, "\n")
+		return item, rest, item != ""
+	case field.caret:
+		item, rest = chopField(s, field.width, chopset)
+		if chopspace {
+			rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		}
+	default:
+		item, _, _ = strings.Cut(s, "\n")
+		if utf8.RuneCountInString(item) > field.width {
+			item = string([]rune(item)[:field.width])
+		}
+	}
+	item = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, item)
+	gotsome = strings.TrimSpace(item) != ""
+	pad := field.width - utf8.RuneCountInString(item)
+	switch field.kind {
+	case '>':
+		text = strings.Repeat(" ", pad) + item
+	case '|':
+		text = strings.Repeat(" ", pad/2) + item + strings.Repeat(" ", pad-pad/2)
+	default:
+		text = item + strings.Repeat(" ", pad)
+	}
+	if field.more && rest != "" && field.width >= 3 {
+		runes := []rune(text)
+		end := len(runes) - 3
+		if string(runes[end:]) == "   " {
+			for end > 0 && runes[end-1] == ' ' {
+				end--
+			}
+		}
+		text = string(runes[:end]) + "..." + string(runes[end+3:])
+	}
+	return text, rest, gotsome
+}
+
+// chopField отрезает от s столько, сколько помещается в поле ширины width:
+// до последнего пробела или символа из chopset (он остаётся в строке), до
+// перевода строки или, если переносить негде, ровно width символов
+func chopField(s string, width int, chopset string) (item, rest string) {
+	runes := []rune(s)
+	chophere, size := -1, 0
+	chopspace := strings.ContainsRune(chopset, ' ')
+	n := 0
+	for ; n < len(runes); n++ {
+		r := runes[n]
+		if unicode.IsSpace(r) {
+			if r == '\n' {
+				chophere = n
+				break
+			}
+			if chopspace {
+				chophere = n
+			}
+			if size == width {
+				break
+			}
+		} else {
+			if size == width {
+				break
+			}
+			if strings.ContainsRune(chopset, r) {
+				chophere = n + 1
+			}
+		}
+		size++
+	}
+	if chophere < 0 || n == len(runes) {
+		chophere = n
+	}
+	return string(runes[:chophere]), string(runes[chophere:])
+}
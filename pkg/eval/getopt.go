@@ -0,0 +1,358 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/av"
+	"perlc/pkg/hv"
+	"perlc/pkg/sv"
+)
+
+// getoptFuncs - функции Getopt::Long и Getopt::Std; оба модуля
+// экспортируют их по умолчанию
+var getoptFuncs = map[string]string{
+	"GetOptions": "Getopt::Long",
+	"getopts":    "Getopt::Std",
+	"getopt":     "Getopt::Std",
+}
+
+// useGetopt - use Getopt::Long / use Getopt::Std: импортированные имена
+// вызывают Getopt::...; :config и прочие теги не мешают импорту GetOptions
+func (i *Interpreter) useGetopt(module string, args []ast.Expression) {
+	if i.getopt == nil {
+		i.getopt = make(map[string]bool)
+	}
+	var names []string
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if getoptFuncs[v.AsString()] == module {
+				names = append(names, v.AsString())
+			}
+		}
+	}
+	if len(names) == 0 || module == "Getopt::Long" {
+		for name, m := range getoptFuncs {
+			if m == module {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		i.getopt[name] = true
+	}
+}
+
+// getoptOption - опция GetOptions из спецификации "name|n=s@": kind 0 -
+// флаг, '!' - с отрицанием --noname, '+' - счётчик, 's', 'i', 'f' - со
+// значением (optional для ":"), dest '@' или '%' - список или хеш
+type getoptOption struct {
+	names    []string
+	kind     byte
+	optional bool
+	dest     byte
+	link     *sv.SV
+}
+
+var getoptSpecRe = regexp.MustCompile(`^([\w?-]+(?:\|[\w?-]*)*)(?:(!|\+)|([=:])([sif])([@%])?)?$`)
+
+// builtinGetOptions - GetOptions([\%h,] SPEC => \$var, ...): разбирает
+// @ARGV, опции убирает, остальные аргументы оставляет по порядку. Имена
+// не зависят от регистра и могут сокращаться до однозначного префикса;
+// при ошибке печатает предупреждение и возвращает ложь
+func (i *Interpreter) builtinGetOptions(args []*sv.SV) *sv.SV {
+	var storage *sv.SV
+	if len(args) > 0 && args[0].IsRef() && args[0].Deref().IsHash() {
+		storage, args = args[0].Deref(), args[1:]
+	}
+	var options []*getoptOption
+	for n := 0; n < len(args); n++ {
+		m := getoptSpecRe.FindStringSubmatch(args[n].AsString())
+		if m == nil {
+			fmt.Fprintf(i.stderr, "Error in option spec: \"%s\"\n", args[n].AsString())
+			return sv.NewString("")
+		}
+		opt := &getoptOption{names: strings.Split(strings.ToLower(m[1]), "|"), optional: m[3] == ":"}
+		switch {
+		case m[2] != "":
+			opt.kind = m[2][0]
+		case m[4] != "":
+			opt.kind = m[4][0]
+		}
+		if m[5] != "" {
+			opt.dest = m[5][0]
+		}
+		if n+1 < len(args) && args[n+1].IsRef() {
+			n++
+			opt.link = args[n]
+		}
+		options = append(options, opt)
+	}
+
+	argvSV := i.ctx.GetVar("ARGV")
+	argv := argvSV.ArrayData()
+	var rest []*sv.SV
+	ok := true
+	for n := 0; n < len(argv); n++ {
+		arg := argv[n].AsString()
+		if arg == "--" {
+			rest = append(rest, argv[n+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, argv[n])
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		opt, matched, negated, err := getoptLookup(options, strings.ToLower(name))
+		if err != "" {
+			fmt.Fprint(i.stderr, err)
+			ok = false
+			continue
+		}
+		var val *sv.SV
+		switch opt.kind {
+		case 0, '!', '+':
+			if hasValue {
+				fmt.Fprintf(i.stderr, "Option %s does not take an argument\n", matched)
+				ok = false
+				continue
+			}
+			val = sv.NewInt(1)
+			if negated {
+				val = sv.NewInt(0)
+			}
+		default:
+			if !hasValue && n+1 < len(argv) {
+				next := argv[n+1].AsString()
+				if !opt.optional || getoptValueOK(opt.kind, next) && (next == "" || next[0] != '-' || opt.kind != 's') {
+					value, hasValue = next, true
+					n++
+				}
+			}
+			if !hasValue {
+				if !opt.optional {
+					fmt.Fprintf(i.stderr, "Option %s requires an argument\n", matched)
+					ok = false
+					continue
+				}
+				if opt.kind != 's' {
+					value = "0"
+				}
+			}
+			var key *sv.SV
+			if opt.dest == '%' || opt.link != nil && opt.link.Deref().IsHash() {
+				k, v, found := strings.Cut(value, "=")
+				if !found && opt.kind == 's' {
+					fmt.Fprintf(i.stderr, "Option %s, key \"%s\", requires a value\n", matched, k)
+					ok = false
+					continue
+				}
+				if !found {
+					v = "1"
+				}
+				key, value = sv.NewString(k), v
+			}
+			if !getoptValueOK(opt.kind, value) {
+				kind := "number"
+				if opt.kind == 'f' {
+					kind = "real number"
+				}
+				fmt.Fprintf(i.stderr, "Value \"%s\" invalid for option %s (%s expected)\n", value, matched, kind)
+				ok = false
+				continue
+			}
+			i.getoptStore(opt, storage, key, sv.NewString(value))
+			continue
+		}
+		i.getoptStore(opt, storage, nil, val)
+	}
+	argvSV.SetArrayData(rest)
+	return boolToSV(ok)
+}
+
+// getoptLookup - опция по имени из командной строки: точное имя, noname
+// и no-name для '!', затем однозначный префикс
+func getoptLookup(options []*getoptOption, name string) (*getoptOption, string, bool, string) {
+	var prefixed []*getoptOption
+	var prefixNames []string
+	for _, negate := range []bool{false, true} {
+		bare := name
+		if negate {
+			var ok bool
+			if bare, ok = strings.CutPrefix(name, "no"); !ok {
+				break
+			}
+			bare = strings.TrimPrefix(bare, "-")
+		}
+		for _, opt := range options {
+			if negate && opt.kind != '!' {
+				continue
+			}
+			for _, n := range opt.names {
+				if n == bare {
+					return opt, n, negate, ""
+				}
+			}
+			for _, n := range opt.names {
+				if !negate && strings.HasPrefix(n, bare) {
+					prefixed = append(prefixed, opt)
+					prefixNames = append(prefixNames, n)
+					break
+				}
+			}
+		}
+	}
+	switch {
+	case len(prefixed) == 1:
+		return prefixed[0], prefixNames[0], false, ""
+	case len(prefixed) > 1:
+		sort.Strings(prefixNames)
+		return nil, "", false, fmt.Sprintf("Option %s is ambiguous (%s)\n", name, strings.Join(prefixNames, ", "))
+	}
+	return nil, "", false, fmt.Sprintf("Unknown option: %s\n", name)
+}
+
+var getoptIntRe = regexp.MustCompile(`^[-+]?\d+$`)
+
+// getoptValueOK - значение подходит опции типа kind: целое для i, число
+// для f, любое для s
+func getoptValueOK(kind byte, value string) bool {
+	switch kind {
+	case 'i':
+		return getoptIntRe.MatchString(value)
+	case 'f':
+		return sv.NewString(value).LooksLikeNumber()
+	}
+	return true
+}
+
+// getoptStore кладёт значение опции туда, куда она связана: в переменную,
+// массив, хеш, вызов подпрограммы или в общий хеш \%h под главным именем
+func (i *Interpreter) getoptStore(opt *getoptOption, storage, key, val *sv.SV) {
+	name := sv.NewString(opt.names[0])
+	link := opt.link
+	if link == nil {
+		if storage == nil {
+			return
+		}
+		switch opt.dest {
+		case '@':
+			if !hv.Fetch(storage, name).IsRef() {
+				hv.Store(storage, name, sv.NewArrayRef())
+			}
+		case '%':
+			if !hv.Fetch(storage, name).IsRef() {
+				hv.Store(storage, name, sv.NewHashRef())
+			}
+		default:
+			hv.Store(storage, name, getoptValue(opt, hv.Fetch(storage, name), val))
+			return
+		}
+		link = hv.Fetch(storage, name)
+	}
+	target := link.Deref()
+	switch {
+	case target.IsCode():
+		if key != nil {
+			i.callCode(link, []*sv.SV{name, key, val})
+		} else {
+			i.callCode(link, []*sv.SV{name, val})
+		}
+	case target.IsArray():
+		av.Push(target, val)
+	case target.IsHash():
+		hv.Store(target, key, val)
+	case opt.dest != 0:
+		// "name=s@" => \$list: значения копятся в массиве по ссылке
+		if !target.IsRef() {
+			if opt.dest == '@' {
+				target.CopyFrom(sv.NewArrayRef())
+			} else {
+				target.CopyFrom(sv.NewHashRef())
+			}
+		}
+		if opt.dest == '@' {
+			av.Push(target.Deref(), val)
+		} else {
+			hv.Store(target.Deref(), key, val)
+		}
+	default:
+		target.CopyFrom(getoptValue(opt, target, val))
+	}
+}
+
+// getoptValue - новое значение скалярной опции: '+' прибавляет к старому
+func getoptValue(opt *getoptOption, old, val *sv.SV) *sv.SV {
+	if opt.kind == '+' {
+		return sv.Add(old, val)
+	}
+	return val
+}
+
+// builtinGetopts - Getopt::Std: getopts("ab:", \%h) разбирает склеенные
+// однобуквенные опции в начале @ARGV, буква с ':' берёт значение;
+// getopt("b", \%h) - перечисленные буквы берут значение, остальные флаги.
+// Без хеша значения попадают в $opt_a текущего пакета
+func (i *Interpreter) builtinGetopts(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewString("")
+	}
+	spec := args[0].AsString()
+	var storage *sv.SV
+	if len(args) > 1 && args[1].IsRef() && args[1].Deref().IsHash() {
+		storage = args[1].Deref()
+	}
+	set := func(letter byte, value *sv.SV) {
+		if storage != nil {
+			hv.Store(storage, sv.NewString(string(letter)), value)
+			return
+		}
+		i.ctx.SetVar(i.varKey("$", i.pkg+"::opt_"+string(letter)), value)
+	}
+
+	argvSV := i.ctx.GetVar("ARGV")
+	argv := argvSV.ArrayData()
+	ok := true
+	for len(argv) > 0 {
+		arg := argv[0].AsString()
+		if arg == "--" {
+			argv = argv[1:]
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		argv = argv[1:]
+		for n := 1; n < len(arg); n++ {
+			letter := arg[n]
+			pos := strings.IndexByte(spec, letter)
+			takesValue := pos >= 0 && pos+1 < len(spec) && spec[pos+1] == ':'
+			if name == "Getopt::Std::getopt" {
+				takesValue = pos >= 0
+			} else if pos < 0 || letter == ':' {
+				fmt.Fprintf(i.stderr, "Unknown option: %c\n", letter)
+				ok = false
+				continue
+			}
+			if !takesValue {
+				set(letter, sv.NewInt(1))
+				continue
+			}
+			value := sv.NewString(arg[n+1:])
+			if n+1 == len(arg) {
+				value = sv.NewUndef()
+				if len(argv) > 0 {
+					value, argv = argv[0], argv[1:]
+				}
+			}
+			set(letter, value)
+			break
+		}
+	}
+	argvSV.SetArrayData(argv)
+	return boolToSV(ok)
+}
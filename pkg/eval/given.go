@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/perlre"
+	"perlc/pkg/sv"
+)
+
+// evalGivenStmt выполняет given/when: тема становится $_ внутри блока,
+// выполняется первая подходящая ветка when, иначе default.
+func (i *Interpreter) evalGivenStmt(stmt *ast.GivenStmt) *sv.SV {
+	topic := i.evalExpression(stmt.Topic)
+
+	i.ctx.PushScope()
+	defer i.ctx.PopScope()
+	i.ctx.DeclareVar("_", topic, "my")
+
+	for _, clause := range stmt.Clauses {
+		if i.whenMatches(topic, clause.Condition) {
+			return i.evalBlockStmt(clause.Body)
+		}
+	}
+	if stmt.Default != nil {
+		return i.evalBlockStmt(stmt.Default)
+	}
+	return sv.NewUndef()
+}
+
+// whenMatches - упрощённый smart match: условие-выражение (сравнение,
+// логическая операция, =~) проверяется на истинность, /re/ сопоставляется
+// с темой, список проверяется на вхождение, остальное сравнивается как
+// число или строка.
+func (i *Interpreter) whenMatches(topic *sv.SV, cond ast.Expression) bool {
+	if re, ok := cond.(*ast.RegexLiteral); ok {
+		match := &ast.MatchExpr{Token: re.Token, Target: &ast.SpecialVar{Token: re.Token, Name: "$_"}, Pattern: re}
+		return i.evalMatchExpr(match).IsTrue()
+	}
+	if isBooleanExpr(cond) {
+		return i.evalExpression(cond).IsTrue()
+	}
+	return smartMatch(topic, i.evalExpression(cond))
+}
+
+var booleanOps = map[string]bool{
+	"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"eq": true, "ne": true, "lt": true, "gt": true, "le": true, "ge": true,
+	"&&": true, "||": true, "and": true, "or": true, "not": true, "!": true,
+	"=~": true, "!~": true,
+}
+
+func isBooleanExpr(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.InfixExpr:
+		return booleanOps[v.Operator]
+	case *ast.PrefixExpr:
+		return booleanOps[v.Operator]
+	case *ast.MatchExpr:
+		return true
+	case *ast.CallExpr:
+		ident, ok := v.Function.(*ast.Identifier)
+		return ok && (ident.Value == "defined" || ident.Value == "exists")
+	}
+	return false
+}
+
+// smartMatch сравнивает тему со значением when
+func smartMatch(topic, value *sv.SV) bool {
+	if pattern, ok := value.RegexPattern(); ok {
+		re, err := perlre.Compile(pattern)
+		return err == nil && re.MatchString(topic.AsString())
+	}
+	if value.IsRef() && value.Deref().IsArray() {
+		value = value.Deref()
+	}
+	if value.IsArray() {
+		for _, el := range value.ArrayData() {
+			if smartMatch(topic, el) {
+				return true
+			}
+		}
+		return false
+	}
+	if value.IsUndef() {
+		return topic.IsUndef()
+	}
+	if isNumber(value) || (isNumber(topic) && looksLikeNumber(value.AsString())) {
+		return topic.AsFloat() == value.AsFloat()
+	}
+	return topic.AsString() == value.AsString()
+}
+
+func isNumber(v *sv.SV) bool {
+	return v.Type() == sv.TypeInt || v.Type() == sv.TypeFloat
+}
+
+func looksLikeNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
@@ -0,0 +1,511 @@
+package eval
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"perlc/pkg/sv"
+)
+
+// HTTP::Tiny и LWP::UserAgent поверх net/http. Объекты - благословлённые
+// хэши атрибутов (agent, timeout, max_redirect...), по ним на каждый запрос
+// собирается http.Client. HTTP::Tiny отвечает хэшем success, status,
+// reason, content, headers, url, protocol; LWP::UserAgent - объектом
+// HTTP::Response. Как и в Perl, переадресации проходят только GET и HEAD,
+// а также 303.
+
+// httpTinyAgent и lwpAgent - User-Agent по умолчанию
+const (
+	httpTinyAgent = "HTTP-Tiny/0.080"
+	lwpAgent      = "libwww-perl/6.67"
+)
+
+// httpMethod - методы HTTP::Tiny, LWP::UserAgent и HTTP::Response
+func (i *Interpreter) httpMethod(obj *sv.SV, class, method string, args []*sv.SV) *sv.SV {
+	var result *sv.SV
+	switch class {
+	case "HTTP::Tiny":
+		result = i.httpTinyMethod(obj, method, args)
+	case "LWP::UserAgent":
+		result = i.lwpMethod(obj, method, args)
+	case "HTTP::Response":
+		if obj.IsRef() && obj.Deref().IsHash() {
+			result = httpResponseMethod(obj.Deref().HashData(), method, args)
+		}
+	}
+	if result == nil {
+		return i.builtinDie([]*sv.SV{sv.NewString(`Can't locate object method "` + method + `" via package "` + class + `"` + i.at() + ".\n")})
+	}
+	return result
+}
+
+// httpPairs - список ключ/значение из аргументов; хэш даёт свои пары
+func (i *Interpreter) httpPairs(args []*sv.SV) []*sv.SV {
+	var pairs []*sv.SV
+	for _, a := range i.flattenArgs(args) {
+		if a.IsHash() {
+			for k, v := range a.HashData() {
+				pairs = append(pairs, sv.NewString(k), v)
+			}
+			continue
+		}
+		pairs = append(pairs, a)
+	}
+	return pairs
+}
+
+// httpNew - конструктор: атрибуты по умолчанию, затем заданные
+func (i *Interpreter) httpNew(class string, defaults map[string]*sv.SV, args []*sv.SV) *sv.SV {
+	obj := sv.NewHashRef()
+	h := obj.Deref().HashData()
+	for k, v := range defaults {
+		h[k] = v
+	}
+	pairs := i.httpPairs(args)
+	for n := 0; n+1 < len(pairs); n += 2 {
+		h[pairs[n].AsString()] = i.copyList(pairs[n+1 : n+2])[0]
+	}
+	return obj.Bless(class)
+}
+
+// httpAttrs - атрибуты объекта; вызов от имени класса берёт умолчания
+func httpAttrs(obj *sv.SV, defaults map[string]*sv.SV) map[string]*sv.SV {
+	if obj.IsRef() && obj.Deref().IsHash() {
+		return obj.Deref().HashData()
+	}
+	return defaults
+}
+
+// httpAccessor - $obj->agent, $obj->agent("x"): значение атрибута,
+// с аргументом - новое
+func httpAccessor(attrs map[string]*sv.SV, name string, args []*sv.SV) *sv.SV {
+	if len(args) > 0 {
+		attrs[name] = sv.NewString(args[0].AsString())
+		if args[0].IsUndef() {
+			attrs[name] = sv.NewUndef()
+		}
+	}
+	if v, ok := attrs[name]; ok {
+		return v
+	}
+	return sv.NewUndef()
+}
+
+func httpTinyDefaults() map[string]*sv.SV {
+	return map[string]*sv.SV{
+		"agent":        sv.NewString(httpTinyAgent),
+		"timeout":      sv.NewInt(60),
+		"max_redirect": sv.NewInt(5),
+		"verify_SSL":   sv.NewInt(1),
+	}
+}
+
+// httpTinyMethod - методы HTTP::Tiny; nil - такого метода нет
+func (i *Interpreter) httpTinyMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	arg := func(n int) *sv.SV {
+		if n < len(args) {
+			return args[n]
+		}
+		return sv.NewUndef()
+	}
+	if method == "new" {
+		return i.httpNew("HTTP::Tiny", httpTinyDefaults(), args)
+	}
+	attrs := httpAttrs(obj, httpTinyDefaults())
+	switch method {
+	case "get", "head", "put", "post", "patch", "delete":
+		return i.httpTinyRequest(attrs, strings.ToUpper(method), arg(0).AsString(), arg(1))
+	case "request":
+		return i.httpTinyRequest(attrs, strings.ToUpper(arg(0).AsString()), arg(1).AsString(), arg(2))
+	case "post_form":
+		opts := sv.NewHashRef()
+		h := opts.Deref().HashData()
+		headers := sv.NewHashRef()
+		if o := arg(2); o.IsRef() && o.Deref().IsHash() {
+			for k, v := range o.Deref().HashData() {
+				h[k] = v
+			}
+			if hd := h["headers"]; hd != nil && hd.IsRef() && hd.Deref().IsHash() {
+				for k, v := range hd.Deref().HashData() {
+					headers.Deref().HashData()[k] = v
+				}
+			}
+		}
+		headers.Deref().HashData()["content-type"] = sv.NewString("application/x-www-form-urlencoded")
+		h["headers"] = headers
+		h["content"] = sv.NewString(httpFormEncode(arg(1)))
+		return i.httpTinyRequest(attrs, "POST", arg(0).AsString(), opts)
+	case "www_form_urlencode":
+		return sv.NewString(httpFormEncode(arg(0)))
+	case "agent", "timeout", "max_redirect", "default_headers", "verify_SSL":
+		return httpAccessor(attrs, method, args)
+	}
+	return nil
+}
+
+// httpFormEncode - www_form_urlencode: ключи хэша по порядку, массив -
+// пары как есть, значение-массив повторяет ключ
+func httpFormEncode(data *sv.SV) string {
+	var keys, values []string
+	add := func(k string, v *sv.SV) {
+		if v.IsArray() || v.IsRef() && v.Deref().IsArray() {
+			arr := v
+			if v.IsRef() {
+				arr = v.Deref()
+			}
+			for _, el := range arr.ArrayData() {
+				keys, values = append(keys, k), append(values, el.AsString())
+			}
+			return
+		}
+		keys, values = append(keys, k), append(values, v.AsString())
+	}
+	target := data
+	if data.IsRef() {
+		target = data.Deref()
+	}
+	switch {
+	case target.IsHash():
+		hv := target.HashData()
+		names := make([]string, 0, len(hv))
+		for k := range hv {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			add(k, hv[k])
+		}
+	case target.IsArray():
+		items := target.ArrayData()
+		for n := 0; n+1 < len(items); n += 2 {
+			add(items[n].AsString(), items[n+1])
+		}
+	}
+	parts := make([]string, len(keys))
+	for n := range keys {
+		parts[n] = url.QueryEscape(keys[n]) + "=" + url.QueryEscape(values[n])
+	}
+	return strings.Join(parts, "&")
+}
+
+// httpTinyRequest выполняет запрос с опциями headers и content; ошибка
+// соединения - ответ 599 с её текстом в content, как у HTTP::Tiny
+func (i *Interpreter) httpTinyRequest(attrs map[string]*sv.SV, method, rawURL string, opts *sv.SV) *sv.SV {
+	header := http.Header{}
+	var body *string
+	if opts.IsRef() && opts.Deref().IsHash() {
+		o := opts.Deref().HashData()
+		httpAddHeaders(header, o["headers"])
+		if c, ok := o["content"]; ok && !c.IsUndef() {
+			s := c.AsString()
+			body = &s
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/octet-stream")
+			}
+		}
+	}
+	httpAddHeaders(header, attrs["default_headers"])
+	resp, content, err := httpDo(method, rawURL, header, body, httpSettings(attrs, httpTinyAgent))
+
+	res := sv.NewHashRef()
+	h := res.Deref().HashData()
+	headers := sv.NewHashRef()
+	if err != nil {
+		msg := err.Error() + "\n"
+		h["success"] = sv.NewString("")
+		h["status"] = sv.NewInt(599)
+		h["reason"] = sv.NewString("Internal Exception")
+		h["content"] = sv.NewString(msg)
+		h["url"] = sv.NewString(rawURL)
+		headers.Deref().HashData()["content-type"] = sv.NewString("text/plain")
+		headers.Deref().HashData()["content-length"] = sv.NewInt(int64(len(msg)))
+		h["headers"] = headers
+		return res
+	}
+	for name, values := range resp.Header {
+		key := strings.ToLower(name)
+		if len(values) == 1 {
+			headers.Deref().HashData()[key] = sv.NewString(values[0])
+			continue
+		}
+		list := make([]*sv.SV, len(values))
+		for n, v := range values {
+			list[n] = sv.NewString(v)
+		}
+		headers.Deref().HashData()[key] = sv.NewArrayRef(list...)
+	}
+	h["success"] = boolToSV(resp.StatusCode >= 200 && resp.StatusCode < 300)
+	h["status"] = sv.NewInt(int64(resp.StatusCode))
+	h["reason"] = sv.NewString(httpReason(resp))
+	h["content"] = sv.NewString(string(content))
+	h["url"] = sv.NewString(resp.Request.URL.String())
+	h["protocol"] = sv.NewString(resp.Proto)
+	h["headers"] = headers
+	return res
+}
+
+// httpAddHeaders добавляет заголовки из хэша; значение-массив - несколько
+// заголовков, уже заданные не заменяются
+func httpAddHeaders(header http.Header, hv *sv.SV) {
+	if hv == nil || !hv.IsRef() || !hv.Deref().IsHash() {
+		return
+	}
+	for name, v := range hv.Deref().HashData() {
+		if header.Get(name) != "" {
+			continue
+		}
+		if v.IsArray() {
+			for _, el := range v.ArrayData() {
+				header.Add(name, el.AsString())
+			}
+			continue
+		}
+		header.Set(name, v.AsString())
+	}
+}
+
+// httpConfig - настройки запроса из атрибутов объекта
+type httpConfig struct {
+	agent     string
+	timeout   time.Duration
+	redirects int
+	verify    bool
+}
+
+func httpSettings(attrs map[string]*sv.SV, agent string) httpConfig {
+	c := httpConfig{agent: agent, timeout: 60 * time.Second, redirects: 5, verify: true}
+	if v, ok := attrs["agent"]; ok {
+		c.agent = v.AsString()
+	}
+	if v, ok := attrs["timeout"]; ok && v.AsFloat() > 0 {
+		c.timeout = time.Duration(v.AsFloat() * float64(time.Second))
+	}
+	if v, ok := attrs["max_redirect"]; ok {
+		c.redirects = int(v.AsInt())
+	}
+	if v, ok := attrs["verify_SSL"]; ok {
+		c.verify = v.IsTrue()
+	}
+	if v, ok := attrs["ssl_opts"]; ok && v.IsRef() && v.Deref().IsHash() {
+		if verify, ok := v.Deref().HashData()["verify_hostname"]; ok {
+			c.verify = verify.IsTrue()
+		}
+	}
+	return c
+}
+
+// httpDo выполняет запрос и читает тело ответа
+func httpDo(method, rawURL string, header http.Header, body *string, c httpConfig) (*http.Response, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, errors.New("Unsupported URL scheme or malformed URL '" + rawURL + "'")
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = strings.NewReader(*body)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = header
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.agent)
+	}
+	client := &http.Client{
+		Timeout: c.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			first := via[0].Method
+			if len(via) > c.redirects || first != "GET" && first != "HEAD" && req.Response.StatusCode != http.StatusSeeOther {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	if !c.verify {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, content, nil
+}
+
+// httpReason - текст статуса без кода: "Not Found"
+func httpReason(resp *http.Response) string {
+	reason := strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))
+	return strings.TrimSpace(reason)
+}
+
+func lwpDefaults() map[string]*sv.SV {
+	return map[string]*sv.SV{
+		"agent":        sv.NewString(lwpAgent),
+		"timeout":      sv.NewInt(180),
+		"max_redirect": sv.NewInt(7),
+	}
+}
+
+// lwpMethod - методы LWP::UserAgent; nil - такого метода нет
+func (i *Interpreter) lwpMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	if method == "new" {
+		return i.httpNew("LWP::UserAgent", lwpDefaults(), args)
+	}
+	attrs := httpAttrs(obj, lwpDefaults())
+	switch method {
+	case "get", "head", "delete", "post", "put", "patch":
+		if len(args) == 0 {
+			return nil
+		}
+		return i.lwpRequest(attrs, strings.ToUpper(method), args[0].AsString(), args[1:])
+	case "agent", "timeout", "max_redirect":
+		return httpAccessor(attrs, method, args)
+	case "default_header":
+		headers, ok := attrs["default_headers"]
+		if !ok || !headers.IsRef() {
+			headers = sv.NewHashRef()
+			attrs["default_headers"] = headers
+		}
+		pairs := i.httpPairs(args)
+		if len(pairs) == 1 {
+			if v, ok := headers.Deref().HashData()[strings.ToLower(pairs[0].AsString())]; ok {
+				return v
+			}
+			return sv.NewUndef()
+		}
+		for n := 0; n+1 < len(pairs); n += 2 {
+			headers.Deref().HashData()[strings.ToLower(pairs[n].AsString())] = sv.NewString(pairs[n+1].AsString())
+		}
+		return sv.NewInt(1)
+	}
+	return nil
+}
+
+// lwpRequest - $ua->get(URL, Header => Value, ..., Content => ...): для
+// post ссылка на хэш или массив после URL - форма
+func (i *Interpreter) lwpRequest(attrs map[string]*sv.SV, method, rawURL string, args []*sv.SV) *sv.SV {
+	header := http.Header{}
+	var body *string
+	setForm := func(form *sv.SV) {
+		s := httpFormEncode(form)
+		body = &s
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if len(args) > 0 && httpIsForm(args[0]) {
+		setForm(args[0])
+		args = args[1:]
+	}
+	for n := 0; n+1 < len(args); n += 2 {
+		name, value := args[n].AsString(), args[n+1]
+		if name == "Content" {
+			if httpIsForm(value) {
+				setForm(value)
+			} else {
+				s := value.AsString()
+				body = &s
+			}
+			continue
+		}
+		header.Add(name, value.AsString())
+	}
+	httpAddHeaders(header, attrs["default_headers"])
+	resp, content, err := httpDo(method, rawURL, header, body, httpSettings(attrs, lwpAgent))
+
+	res := sv.NewHashRef()
+	h := res.Deref().HashData()
+	headers := sv.NewHashRef()
+	h["_headers"] = headers
+	if err != nil {
+		msg := err.Error()
+		h["_rc"] = sv.NewInt(500)
+		h["_msg"] = sv.NewString(msg)
+		h["_content"] = sv.NewString(msg + "\n")
+		headers.Deref().HashData()["client-warning"] = sv.NewString("Internal response")
+		headers.Deref().HashData()["content-type"] = sv.NewString("text/plain")
+		return res.Bless("HTTP::Response")
+	}
+	for name, values := range resp.Header {
+		headers.Deref().HashData()[strings.ToLower(name)] = sv.NewString(strings.Join(values, ", "))
+	}
+	h["_rc"] = sv.NewInt(int64(resp.StatusCode))
+	h["_msg"] = sv.NewString(httpReason(resp))
+	h["_content"] = sv.NewString(string(content))
+	h["_protocol"] = sv.NewString(resp.Proto)
+	h["_base"] = sv.NewString(resp.Request.URL.String())
+	return res.Bless("HTTP::Response")
+}
+
+// httpIsForm - данные формы: ссылка на хэш или массив пар
+func httpIsForm(v *sv.SV) bool {
+	if v.IsArray() {
+		return true
+	}
+	return v.IsRef() && !v.IsBlessed() && (v.Deref().IsHash() || v.Deref().IsArray())
+}
+
+// httpResponseMethod - методы HTTP::Response; nil - такого метода нет
+func httpResponseMethod(h map[string]*sv.SV, method string, args []*sv.SV) *sv.SV {
+	field := func(name string) *sv.SV {
+		if v, ok := h[name]; ok {
+			return v
+		}
+		return sv.NewUndef()
+	}
+	code := field("_rc").AsInt()
+	header := func(name string) *sv.SV {
+		if hv := field("_headers"); hv.IsRef() && hv.Deref().IsHash() {
+			if v, ok := hv.Deref().HashData()[strings.ToLower(name)]; ok {
+				return v
+			}
+		}
+		return sv.NewUndef()
+	}
+	switch method {
+	case "code":
+		return field("_rc")
+	case "message":
+		return field("_msg")
+	case "status_line":
+		return sv.NewString(strconv.FormatInt(code, 10) + " " + field("_msg").AsString())
+	case "content", "decoded_content":
+		return field("_content")
+	case "is_success":
+		return boolToSV(code >= 200 && code < 300)
+	case "is_redirect":
+		return boolToSV(code >= 300 && code < 400)
+	case "is_error":
+		return boolToSV(code >= 400)
+	case "is_info":
+		return boolToSV(code >= 100 && code < 200)
+	case "protocol":
+		return field("_protocol")
+	case "base":
+		return field("_base")
+	case "header":
+		if len(args) == 0 {
+			return sv.NewUndef()
+		}
+		return header(args[0].AsString())
+	case "content_type":
+		ct, _, _ := strings.Cut(header("Content-Type").AsString(), ";")
+		return sv.NewString(strings.TrimSpace(ct))
+	case "content_length":
+		return header("Content-Length")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"math/rand"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// listUtilFuncs - функции, которые экспортирует List::Util; по умолчанию
+// не экспортируется ничего
+var listUtilFuncs = map[string]bool{
+	"sum": true, "sum0": true, "max": true, "min": true, "first": true,
+	"reduce": true, "any": true, "all": true, "none": true, "uniq": true,
+	"shuffle": true,
+}
+
+// useListUtil - use List::Util LIST: импортированные имена вызывают
+// List::Util::...
+func (i *Interpreter) useListUtil(args []ast.Expression) {
+	if i.listUtil == nil {
+		i.listUtil = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); listUtilFuncs[name] {
+				i.listUtil[name] = true
+			}
+		}
+	}
+}
+
+// builtinListUtil - функции List::Util. first, any, all, none и reduce
+// принимают блок первым аргументом: блок видит элемент в $_ (reduce - в
+// $a и $b, как компаратор sort)
+func (i *Interpreter) builtinListUtil(name string, exprs []ast.Expression, args []*sv.SV) *sv.SV {
+	var block *ast.AnonSubExpr
+	if len(exprs) > 0 {
+		if b, ok := exprs[0].(*ast.AnonSubExpr); ok {
+			block = b
+			args = args[1:]
+		}
+	}
+	elements := flattenArgs(args)
+
+	switch name {
+	case "List::Util::sum", "List::Util::sum0":
+		if len(elements) == 0 {
+			if name == "List::Util::sum0" {
+				return sv.NewInt(0)
+			}
+			return sv.NewUndef()
+		}
+		total := sv.NewInt(0)
+		for _, el := range elements {
+			total = sv.Add(total, el)
+		}
+		return total
+	case "List::Util::max", "List::Util::min":
+		if len(elements) == 0 {
+			return sv.NewUndef()
+		}
+		best := elements[0]
+		for _, el := range elements[1:] {
+			if name == "List::Util::max" && el.AsFloat() > best.AsFloat() ||
+				name == "List::Util::min" && el.AsFloat() < best.AsFloat() {
+				best = el
+			}
+		}
+		return best
+	case "List::Util::uniq":
+		seen := make(map[string]bool)
+		seenUndef := false
+		var result []*sv.SV
+		for _, el := range elements {
+			if el.IsUndef() {
+				if !seenUndef {
+					seenUndef = true
+					result = append(result, el)
+				}
+				continue
+			}
+			if key := el.AsString(); !seen[key] {
+				seen[key] = true
+				result = append(result, el)
+			}
+		}
+		return sv.NewArrayRef(result...)
+	case "List::Util::shuffle":
+		shuffled := make([]*sv.SV, len(elements))
+		copy(shuffled, elements)
+		rand.Shuffle(len(shuffled), func(a, b int) {
+			shuffled[a], shuffled[b] = shuffled[b], shuffled[a]
+		})
+		return sv.NewArrayRef(shuffled...)
+	}
+
+	if block == nil {
+		return i.builtinDie([]*sv.SV{sv.NewString("Not a subroutine reference")})
+	}
+
+	if name == "List::Util::reduce" {
+		if len(elements) == 0 {
+			return sv.NewUndef()
+		}
+		i.ctx.PushScope()
+		defer i.ctx.PopScope()
+		acc := elements[0]
+		for _, el := range elements[1:] {
+			i.ctx.DeclareVar("a", acc, "our")
+			i.ctx.DeclareVar("b", el, "our")
+			acc = i.listUtilBlock(block)
+		}
+		return acc
+	}
+
+	for _, el := range elements {
+		i.ctx.SetVar("_", el)
+		ok := i.listUtilBlock(block).AsBool()
+		switch {
+		case ok && name == "List::Util::first":
+			return el
+		case ok && name == "List::Util::any":
+			return sv.NewInt(1)
+		case ok && name == "List::Util::none", !ok && name == "List::Util::all":
+			return sv.NewString("")
+		}
+	}
+	switch name {
+	case "List::Util::first":
+		return sv.NewUndef()
+	case "List::Util::any":
+		return sv.NewString("")
+	}
+	return sv.NewInt(1)
+}
+
+// listUtilBlock выполняет блок first/any/reduce; return внутри блока
+// возвращает значение из блока
+func (i *Interpreter) listUtilBlock(block *ast.AnonSubExpr) *sv.SV {
+	result := i.evalBlockStmt(block.Body)
+	if i.ctx.HasReturn() {
+		result = i.ctx.ReturnValue()
+		i.ctx.ClearReturn()
+	}
+	return result
+}
@@ -0,0 +1,291 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+	"perlc/pkg/sv"
+	"perlc/pkg/warnings"
+)
+
+// useModule - use Module LIST: загрузка как у require, затем import.
+// Ошибка загрузки при use, как в perl, завершается строкой
+// "BEGIN failed--compilation aborted".
+func (i *Interpreter) useModule(decl *ast.UseDecl) {
+	if decl.Module == "" || isVersion(decl.Module) {
+		return
+	}
+	if context.BuiltinModules[decl.Module] {
+		i.ctx.Require(context.ModuleFile(decl.Module))
+		switch decl.Module {
+		case "parent", "base":
+			i.useParent(decl)
+		case "mro":
+			for _, arg := range decl.Args {
+				for _, v := range i.listValues(arg) {
+					i.ctx.SetMRO(i.pkg, v.AsString())
+				}
+			}
+		}
+		return
+	}
+	if msg := i.loadFile(context.ModuleFile(decl.Module), decl.Module); msg != "" {
+		i.builtinDie([]*sv.SV{sv.NewString(msg + "BEGIN failed--compilation aborted" + i.at() + ".\n")})
+		return
+	}
+	// use Module () - без import
+	if len(decl.Args) == 1 {
+		if list, ok := decl.Args[0].(*ast.ArrayExpr); ok && len(list.Elements) == 0 {
+			return
+		}
+	}
+	var args []*sv.SV
+	for _, arg := range decl.Args {
+		args = append(args, i.listValues(arg)...)
+	}
+	if msg := i.importModule(decl.Module, args); msg != "" {
+		i.builtinDie([]*sv.SV{sv.NewString(msg + "BEGIN failed--compilation aborted" + i.at() + ".\n")})
+	}
+}
+
+// useParent - use parent LIST и use base LIST: родители добавляются в
+// @ISA текущего пакета. parent загружает их модули (кроме -norequire),
+// base - только если в пакете родителя ещё нет ни одной sub, и
+// пропускает тех, от кого пакет уже наследует.
+func (i *Interpreter) useParent(decl *ast.UseDecl) {
+	norequire := false
+	var parents []string
+	for _, arg := range decl.Args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); name == "-norequire" && decl.Module == "parent" {
+				norequire = true
+			} else {
+				parents = append(parents, name)
+			}
+		}
+	}
+	var added []string
+	for _, parent := range parents {
+		if decl.Module == "base" && i.packageIsa(i.pkg, parent) {
+			continue
+		}
+		added = append(added, parent)
+		if norequire || context.BuiltinModules[parent] || decl.Module == "base" && i.ctx.PackageDefined(parent) {
+			continue
+		}
+		msg := i.loadFile(context.ModuleFile(parent), parent)
+		if msg != "" && decl.Module == "base" && strings.HasPrefix(msg, "Can't locate") {
+			msg = fmt.Sprintf("Base class package %q is empty.\n"+
+				"    (Perhaps you need to 'use' the module which defines that package first,\n"+
+				"    or make that module available in @INC (@INC contains: %s).\n%s.\n", parent, i.ctx.IncDirs(), i.at())
+		}
+		if msg != "" {
+			i.builtinDie([]*sv.SV{sv.NewString(msg + "BEGIN failed--compilation aborted" + i.at() + ".\n")})
+		}
+	}
+	i.ctx.PushISA(i.pkg, added)
+}
+
+// builtinMro - mro::get_linear_isa(CLASS), mro::get_mro(CLASS) и
+// mro::set_mro(CLASS, TYPE)
+func (i *Interpreter) builtinMro(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewUndef()
+	}
+	class := args[0].AsString()
+	switch name {
+	case "mro::get_mro":
+		return sv.NewString(i.ctx.MRO(class))
+	case "mro::set_mro":
+		if len(args) > 1 {
+			i.ctx.SetMRO(class, args[1].AsString())
+		}
+		return sv.NewUndef()
+	}
+	order, err := i.ctx.LinearISA(class)
+	if err != nil {
+		i.builtinDie([]*sv.SV{sv.NewString(err.Error() + i.at() + ".\n")})
+	}
+	items := make([]*sv.SV, len(order))
+	for n, c := range order {
+		items[n] = sv.NewString(c)
+	}
+	return sv.NewArrayRef(items...)
+}
+
+// requireModule - require Module во время выполнения: встроенные модули
+// perlc только отмечаются в %INC, остальные ищутся по @INC
+func (i *Interpreter) requireModule(module string) {
+	if module == "" || isVersion(module) {
+		return
+	}
+	if context.BuiltinModules[module] {
+		i.ctx.Require(context.ModuleFile(module))
+		return
+	}
+	if msg := i.loadFile(context.ModuleFile(module), module); msg != "" {
+		i.builtinDie([]*sv.SV{sv.NewString(msg)})
+	}
+}
+
+// requireFile - require "file.pl" и require $path
+func (i *Interpreter) requireFile(file string) {
+	if msg := i.loadFile(file, ""); msg != "" {
+		i.builtinDie([]*sv.SV{sv.NewString(msg)})
+	}
+}
+
+// loadFile находит file по @INC и выполняет его один раз: повторный
+// require того, что уже есть в %INC, ничего не делает. Файл выполняется
+// в пакете main со своей лексической областью, которую видят его sub.
+// Возвращает сообщение об ошибке, если файла нет, он не разбирается
+// или не вернул истину.
+func (i *Interpreter) loadFile(file, module string) string {
+	if i.ctx.Required(file) {
+		return ""
+	}
+	path, found := i.ctx.FindInc(file)
+	if !found {
+		hint := ""
+		if module != "" {
+			hint = " (you may need to install the " + module + " module)"
+		}
+		return fmt.Sprintf("Can't locate %s in @INC%s (@INC contains: %s)%s.\n", file, hint, i.ctx.IncDirs(), i.at())
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("Can't locate %s:   %v%s.\n", file, err, i.at())
+	}
+	p := parser.New(lexer.NewFile(string(src), path))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return fmt.Sprintf("syntax error at %s %s\nCompilation failed in require%s.\n", path, errs[0], i.at())
+	}
+
+	// %INC заполняется до выполнения: require по кругу не зациклится
+	i.ctx.SetInc(file, path)
+	where := i.at()
+	if result := i.runFile(program); result == nil || !result.IsTrue() {
+		i.ctx.SetInc(file, "")
+		return fmt.Sprintf("%s did not return a true value%s.\n", file, where)
+	}
+	return ""
+}
+
+// runFile выполняет загруженный файл: пакет main, из областей видимости
+// только глобальная и своя область файла
+func (i *Interpreter) runFile(program *ast.Program) *sv.SV {
+	saved := i.ctx.SwapScopes(i.ctx.CaptureScopes()[:1])
+	prevPkg := i.pkg
+	i.pkg = "main"
+	i.loading++
+	defer func() {
+		i.loading--
+		i.pkg = prevPkg
+		i.ctx.SwapScopes(saved)
+	}()
+	i.ctx.PushScope()
+	result := i.evalBlockStmt(&ast.BlockStmt{Statements: program.Statements})
+	if i.ctx.HasReturn() {
+		result = i.ctx.ReturnValue()
+		i.ctx.ClearReturn()
+	}
+	return result
+}
+
+// importModule вызывает import модуля: его собственный Module::import,
+// а если его нет - импорт как у Exporter по @EXPORT, @EXPORT_OK и
+// %EXPORT_TAGS. Возвращает сообщение об ошибке импорта.
+func (i *Interpreter) importModule(module string, args []*sv.SV) string {
+	if name := i.ctx.FindMethod(module, "import"); name != "" {
+		i.callSubWithArgs(name, append([]*sv.SV{sv.NewString(module)}, args...))
+		return ""
+	}
+	exports := i.ctx.GetVar("@" + module + "::EXPORT").ArrayData()
+	allowed := map[string]bool{}
+	for _, list := range [][]*sv.SV{exports, i.ctx.GetVar("@" + module + "::EXPORT_OK").ArrayData()} {
+		for _, name := range list {
+			allowed[strings.TrimPrefix(name.AsString(), "&")] = true
+		}
+	}
+	names := args
+	if len(names) == 0 {
+		names = exports
+	}
+	var bad []string
+	for _, arg := range names {
+		name := arg.AsString()
+		if tag, ok := strings.CutPrefix(name, ":"); ok {
+			var members []*sv.SV
+			if tag == "DEFAULT" {
+				members = exports
+			} else if ref := i.ctx.GetVar("%" + module + "::EXPORT_TAGS").HashData()[tag]; ref != nil && ref.IsRef() {
+				members = ref.Deref().ArrayData()
+			} else {
+				bad = append(bad, fmt.Sprintf("%q is not defined in %%%s::EXPORT_TAGS", tag, module))
+				continue
+			}
+			for _, member := range members {
+				i.exportName(module, strings.TrimPrefix(member.AsString(), "&"))
+			}
+			continue
+		}
+		name = strings.TrimPrefix(name, "&")
+		if !allowed[name] {
+			bad = append(bad, fmt.Sprintf("%q is not exported by the %s module", name, module))
+			continue
+		}
+		i.exportName(module, name)
+	}
+	if len(bad) > 0 {
+		return strings.Join(bad, "\n") + "\nCan't continue after import errors" + i.at() + ".\n"
+	}
+	return ""
+}
+
+// exportName делает name модуля видимым в текущем пакете: sub - через
+// i.imports, переменная ($x, @x, %x) - как our на переменную модуля
+func (i *Interpreter) exportName(module, name string) {
+	if name == "" {
+		return
+	}
+	if strings.ContainsRune("$@%", rune(name[0])) {
+		i.ctx.DeclareOur(name[:1], name[1:], module)
+		return
+	}
+	i.imports[i.pkg+"::"+name] = module + "::" + name
+}
+
+// subName - какую sub вызывает name из текущего пакета: свою sub пакета,
+// импортированную или sub main, которые хранятся без main::
+func (i *Interpreter) subName(name string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "::"), "main::")
+	if strings.Contains(name, "::") {
+		return name
+	}
+	own := name
+	if i.pkg != "main" {
+		own = i.pkg + "::" + name
+	}
+	if i.ctx.GetSub(own) != nil {
+		return own
+	}
+	if target, ok := i.imports[i.pkg+"::"+name]; ok {
+		return target
+	}
+	return name
+}
+
+// at - " at FILE line N" выполняемого оператора для сообщений об ошибках
+func (i *Interpreter) at() string {
+	pos, _ := ast.PosOf(i.stmt)
+	if where := warnings.Where(pos); where != "" {
+		return " at " + where
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package eval
+
+import (
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// use overload '""' => sub {...}: пары операция => код хранятся по пакету,
+// в котором стоит use (package NAME;). Объект, благословлённый bless,
+// получает интерпретатор как sv.Overloader, поэтому "$obj", print $obj и
+// конкатенация вызывают '""' пакета или его @ISA с ($obj, undef, "").
+
+// useOverload регистрирует пары use overload для текущего пакета
+func (i *Interpreter) useOverload(decl *ast.UseDecl) {
+	values := i.listValues(&ast.ArrayExpr{Token: decl.Token, Elements: decl.Args})
+	ops := i.overloads[i.pkg]
+	if ops == nil {
+		ops = make(map[string]*sv.SV)
+		i.overloads[i.pkg] = ops
+	}
+	for n := 0; n+1 < len(values); n += 2 {
+		ops[values[n].AsString()] = values[n+1]
+	}
+}
+
+// Overload implements sv.Overloader for objects blessed by the program.
+func (i *Interpreter) Overload(obj *sv.SV, op string) (*sv.SV, bool) {
+	code := i.findOverload(obj.Package(), op)
+	if code == nil {
+		return nil, false
+	}
+	return i.callCode(code, []*sv.SV{obj, sv.NewUndef(), sv.NewString("")}), true
+}
+
+// findOverload ищет операцию в пакете, затем в @ISA
+func (i *Interpreter) findOverload(pkg, op string) *sv.SV {
+	order, _ := i.ctx.LinearISA(pkg)
+	for _, class := range order {
+		if code, ok := i.overloads[class][op]; ok {
+			return code
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package eval
+
+import (
+	"perlc/pkg/sv"
+)
+
+// builtinParallel - parallel_map/parallel_foreach из use perlc::parallel.
+// Интерпретатор один на всю программу, поэтому здесь код вызывается по
+// очереди; в скомпилированной программе его выполняют горутины. Порядок
+// результатов в обоих случаях совпадает с порядком списка.
+func (i *Interpreter) builtinParallel(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewArrayRef()
+	}
+	var results []*sv.SV
+	for _, arg := range args[1:] {
+		for _, item := range i.svToList(arg) {
+			result := i.callCode(args[0], []*sv.SV{item})
+			if name == "parallel_map" {
+				results = append(results, i.svToList(result)...)
+			}
+		}
+	}
+	return sv.NewArrayRef(results...)
+}
@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"math"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// posixFuncs - функции POSIX, которые вычисляются на месте; use POSIX без
+// списка импортирует их все вместе с константами
+var posixFuncs = map[string]bool{
+	"floor": true, "ceil": true, "fmod": true, "pow": true, "strftime": true,
+}
+
+// posixIntConstants и posixFloatConstants - пределы из limits.h и
+// float.h для 64-битной платформы, как их видит perl
+var posixIntConstants = map[string]int64{
+	"INT_MAX":      math.MaxInt32,
+	"INT_MIN":      math.MinInt32,
+	"UINT_MAX":     math.MaxUint32,
+	"LONG_MAX":     math.MaxInt64,
+	"LONG_MIN":     math.MinInt64,
+	"EXIT_SUCCESS": 0,
+	"EXIT_FAILURE": 1,
+}
+
+var posixFloatConstants = map[string]float64{
+	"DBL_MAX":     math.MaxFloat64,
+	"DBL_MIN":     0x1p-1022,
+	"DBL_EPSILON": 0x1p-52,
+}
+
+// usePOSIX - use POSIX LIST: импортированные имена вызывают POSIX::...;
+// без списка импортируется всё
+func (i *Interpreter) usePOSIX(args []ast.Expression) {
+	if i.posix == nil {
+		i.posix = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		for name := range posixFuncs {
+			i.posix[name] = true
+		}
+		for name := range posixIntConstants {
+			i.posix[name] = true
+		}
+		for name := range posixFloatConstants {
+			i.posix[name] = true
+		}
+		return
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			i.posix[v.AsString()] = true
+		}
+	}
+}
+
+// posixConstant - значение константы POSIX: импортированной или с полным
+// именем POSIX::INT_MAX
+func (i *Interpreter) posixConstant(name string) (*sv.SV, bool) {
+	if !i.posix[name] {
+		var ok bool
+		if name, ok = strings.CutPrefix(name, "POSIX::"); !ok {
+			return nil, false
+		}
+	}
+	if v, ok := posixIntConstants[name]; ok {
+		return sv.NewInt(v), true
+	}
+	if v, ok := posixFloatConstants[name]; ok {
+		return sv.NewFloat(v), true
+	}
+	return nil, false
+}
+
+// builtinPOSIX - floor, ceil, fmod и pow поверх math; + 0 убирает
+// отрицательный ноль, который perl печатает как 0
+func (i *Interpreter) builtinPOSIX(name string, args []*sv.SV) *sv.SV {
+	args = flattenArgs(args)
+	arg := func(n int) float64 {
+		if n < len(args) {
+			return args[n].AsFloat()
+		}
+		return 0
+	}
+	switch name {
+	case "POSIX::floor":
+		return sv.NewFloat(math.Floor(arg(0)) + 0)
+	case "POSIX::ceil":
+		return sv.NewFloat(math.Ceil(arg(0)) + 0)
+	case "POSIX::fmod":
+		return sv.NewFloat(math.Mod(arg(0), arg(1)))
+	case "POSIX::pow":
+		return sv.NewFloat(math.Pow(arg(0), arg(1)))
+	}
+	return sv.NewUndef()
+}
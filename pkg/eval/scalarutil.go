@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// scalarUtilFuncs - функции, которые экспортирует Scalar::Util; по
+// умолчанию не экспортируется ничего
+var scalarUtilFuncs = map[string]bool{
+	"blessed": true, "reftype": true, "looks_like_number": true,
+	"weaken": true, "unweaken": true, "isweak": true, "dualvar": true,
+}
+
+// useScalarUtil - use Scalar::Util LIST: импортированные имена вызывают
+// Scalar::Util::...
+func (i *Interpreter) useScalarUtil(args []ast.Expression) {
+	if i.scalarUtil == nil {
+		i.scalarUtil = make(map[string]bool)
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); scalarUtilFuncs[name] {
+				i.scalarUtil[name] = true
+			}
+		}
+	}
+}
+
+// builtinScalarUtil - функции Scalar::Util. weaken, unweaken и isweak
+// работают с самой переменной: слабая ссылка не держит то, на что
+// ссылается (sv.Weaken)
+func (i *Interpreter) builtinScalarUtil(name string, args []*sv.SV) *sv.SV {
+	if len(args) == 0 {
+		return sv.NewUndef()
+	}
+	v := args[0]
+	switch name {
+	case "Scalar::Util::blessed":
+		if v.IsRef() && v.IsBlessed() {
+			return sv.NewString(v.Package())
+		}
+		return sv.NewUndef()
+	case "Scalar::Util::reftype":
+		return sv.Reftype(v)
+	case "Scalar::Util::looks_like_number":
+		if v.LooksLikeNumber() {
+			return sv.NewInt(1)
+		}
+		return sv.NewString("")
+	case "Scalar::Util::weaken":
+		v.Weaken()
+	case "Scalar::Util::unweaken":
+		v.Unweaken()
+	case "Scalar::Util::isweak":
+		return boolToSV(v.IsWeak())
+	case "Scalar::Util::dualvar":
+		if len(args) < 2 {
+			return sv.NewUndef()
+		}
+		return sv.NewDualvar(v, args[1].AsString())
+	}
+	return sv.NewUndef()
+}
+
+// strongCopy - значение для присваивания: копия слабой ссылки сильная, как
+// в perl, остальное как есть
+func strongCopy(v *sv.SV) *sv.SV {
+	if v.IsWeak() {
+		return v.Copy()
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"perlc/pkg/ast"
+	"perlc/pkg/hv"
+	"perlc/pkg/sv"
+	"time"
+)
+
+// Обработчики %SIG. Сигналы (CHLD, ALRM) ставятся в очередь контекстом
+// и доставляются между операторами и на каждом шаге цикла, как "safe
+// signals" в perl.
+
+// ErrReload is what Eval panics with when a HUP arrives while reloading is
+// on and the program has no $SIG{HUP} handler: the caller parses the
+// script again and runs it from the start (perlc -watch).
+var ErrReload = errors.New("reload requested by HUP")
+
+// SetReload turns HUP into a restart of the program instead of its end.
+func (i *Interpreter) SetReload(on bool) {
+	i.reload = on
+}
+
+// Signal queues signal name (HUP, ALRM, ...) for the program; it may be
+// called from another goroutine and is handled at the next safe point.
+func (i *Interpreter) Signal(name string) {
+	i.ctx.RaiseSignal(name)
+}
+
+// pollSignals доставляет ожидающие сигналы; его вызывают перед каждым
+// оператором и каждой проверкой условия цикла, так что alarm прерывает
+// и пустой while (1) { }
+func (i *Interpreter) pollSignals() {
+	if i.ctx.SignalsPending() {
+		i.dispatchSignals()
+	}
+}
+
+// dispatchSignals вызывает обработчики $SIG{NAME} для всех ожидающих сигналов
+func (i *Interpreter) dispatchSignals() {
+	if i.inSignal {
+		return
+	}
+	i.inSignal = true
+	defer func() { i.inSignal = false }()
+
+	sig := i.ctx.GetVar("SIG")
+	for _, name := range i.ctx.TakeSignals() {
+		var handler *sv.SV
+		if sig.IsHash() {
+			handler = hv.Fetch(sig, sv.NewString(name))
+		}
+		switch {
+		case handler.IsUndef(), handler.AsString() == "DEFAULT", handler.AsString() == "":
+			// поведение по умолчанию: ALRM и HUP завершают процесс, для CHLD - ничего
+			switch {
+			case name == "ALRM":
+				fmt.Fprintln(i.stderr, "Alarm clock")
+				i.exit(128 + 14)
+			case name == "HUP" && i.reload:
+				// выходим из всех sub и блоков до Eval; хэндлы дописываются
+				i.ctx.FlushAll()
+				panic(ErrReload)
+			case name == "HUP":
+				fmt.Fprintln(i.stderr, "Hangup")
+				i.exit(128 + 1)
+			}
+		case handler.AsString() == "IGNORE":
+			if name == "CHLD" {
+				// потомки убираются автоматически, wait() их уже не увидит
+				for {
+					if pid, _ := i.ctx.WaitChild(-1, true); pid <= 0 {
+						break
+					}
+				}
+			}
+		default:
+			i.callCode(handler, []*sv.SV{sv.NewString(name)})
+		}
+	}
+}
+
+// evalAnonSub регистрирует sub { ... } как __ANON__N и возвращает ссылку на код.
+// Текущая цепочка областей видимости захватывается: лексические переменные
+// остаются доступны (по ссылке) и после выхода из объявившего блока.
+func (i *Interpreter) evalAnonSub(expr *ast.AnonSubExpr) *sv.SV {
+	i.anonCount++
+	name := fmt.Sprintf("__ANON__%d", i.anonCount)
+	i.ctx.DeclareSub(name, expr.Body)
+	if expr.Params != nil {
+		i.signatures[name] = expr.Params
+	}
+	i.closures[name] = i.ctx.CaptureScopes()
+	return sv.NewCodeRef(name)
+}
+
+// callCode вызывает ссылку на код или подпрограмму по имени ($SIG{CHLD} = 'reaper')
+func (i *Interpreter) callCode(code *sv.SV, args []*sv.SV) *sv.SV {
+	name := code.CodeName()
+	if name == "" {
+		name = code.AsString()
+	}
+	return i.callUserSub(name, args)
+}
+
+// sleep N - пауза в секундах, прерывается сигналом (возвращает сколько проспали).
+// Без аргумента спит до сигнала.
+func (i *Interpreter) builtinSleep(args []*sv.SV) *sv.SV {
+	d := time.Duration(-1)
+	if len(args) > 0 {
+		d = max(0, time.Duration(args[0].AsFloat()*float64(time.Second)))
+	}
+	return sv.NewInt(int64(i.sleepFor(d).Round(time.Second) / time.Second))
+}
+
+// sleepFor - ждёт d (d < 0 - до сигнала) или сигнал, возвращает сколько
+// прошло на самом деле
+func (i *Interpreter) sleepFor(d time.Duration) time.Duration {
+	start := time.Now()
+	var timeout <-chan time.Time
+	if d >= 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	if !i.ctx.SignalsPending() {
+		select {
+		case <-timeout:
+		case <-i.ctx.SignalNotify():
+		}
+	}
+	return time.Since(start)
+}
+
+// alarm N - через N секунд доставить ALRM (0 отменяет); возвращает остаток
+// от предыдущего alarm. Без аргумента берётся $_.
+func (i *Interpreter) builtinAlarm(args []*sv.SV) *sv.SV {
+	var seconds float64
+	if len(args) > 0 {
+		seconds = args[0].AsFloat()
+	} else {
+		seconds = i.evalSpecialVar("$_").AsFloat()
+	}
+	return sv.NewInt(int64(i.ctx.SetAlarm(seconds)))
+}
@@ -0,0 +1,75 @@
+package eval
+
+import (
+	"fmt"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/sv"
+)
+
+// bindSignature объявляет параметры sub f($x, $y = 10, @rest) в области
+// видимости вызова. Аргументы-массивы разворачиваются, значения копируются;
+// недостающий скаляр получает значение по умолчанию (вычисляется после
+// предыдущих параметров), @ или % забирает остаток. Неверное число
+// аргументов - die, как в Perl.
+func (i *Interpreter) bindSignature(name string, params []*ast.Param, args []*sv.SV) {
+	values := i.copyList(i.flattenArgs(args))
+
+	if msg := arityError(name, params, len(values)); msg != "" {
+		i.builtinDie([]*sv.SV{sv.NewString(msg)})
+		return
+	}
+
+	for idx, p := range params {
+		switch p.Sigil {
+		case "@", "%":
+			var target ast.Expression = &ast.ArrayVar{Name: p.Name}
+			if p.Sigil == "%" {
+				target = &ast.HashVar{Name: p.Name}
+			}
+			var rest []*sv.SV
+			if idx < len(values) {
+				rest = values[idx:]
+			}
+			i.assignToVar(target, newContainer(p.Sigil == "%"), "my")
+			i.fillContainer(target, rest)
+		default:
+			value := sliceValue(values, idx)
+			if idx >= len(values) && p.Default != nil {
+				value = i.evalScalarExpression(p.Default)
+			}
+			i.assignToVar(&ast.ScalarVar{Name: p.Name}, value, "my")
+		}
+	}
+}
+
+// arityError - сообщение Perl о неверном числе аргументов или ""
+func arityError(name string, params []*ast.Param, got int) string {
+	min, max := 0, 0
+	for _, p := range params {
+		if p.Sigil != "$" {
+			max = -1
+			break
+		}
+		max++
+		if p.Default == nil {
+			min = max
+		}
+	}
+	name = qualifiedSub(name)
+	switch {
+	case max >= 0 && got > max:
+		expected := fmt.Sprint(max)
+		if min != max {
+			expected = "at most " + expected
+		}
+		return fmt.Sprintf("Too many arguments for subroutine '%s' (got %d; expected %s)", name, got, expected)
+	case got < min:
+		expected := fmt.Sprint(min)
+		if min != max {
+			expected = "at least " + expected
+		}
+		return fmt.Sprintf("Too few arguments for subroutine '%s' (got %d; expected %s)", name, got, expected)
+	}
+	return ""
+}
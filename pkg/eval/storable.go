@@ -0,0 +1,101 @@
+package eval
+
+import (
+	"os"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/sv"
+)
+
+// storableFuncs - функции Storable; store и retrieve экспортируются по
+// умолчанию, остальные по имени
+var storableFuncs = map[string]bool{
+	"store": true, "nstore": true, "retrieve": true, "freeze": true,
+	"nfreeze": true, "thaw": true, "dclone": true,
+}
+
+// useStorable - use Storable LIST: импортированные имена вызывают
+// Storable::...
+func (i *Interpreter) useStorable(args []ast.Expression) {
+	if i.storable == nil {
+		i.storable = make(map[string]bool)
+	}
+	if len(args) == 0 {
+		i.storable["store"] = true
+		i.storable["retrieve"] = true
+		return
+	}
+	for _, arg := range args {
+		for _, v := range i.listValues(arg) {
+			if name := v.AsString(); storableFuncs[name] {
+				i.storable[name] = true
+			}
+		}
+	}
+}
+
+// builtinStorable - freeze/thaw в строку, store/retrieve в файл и dclone
+// поверх sv.Freeze и sv.Thaw; формат общий с компилированной программой,
+// так что файл, сохранённый одной, читает другая
+func (i *Interpreter) builtinStorable(name string, args []*sv.SV) *sv.SV {
+	arg := func(n int) *sv.SV {
+		if n < len(args) {
+			return args[n]
+		}
+		return sv.NewUndef()
+	}
+	die := func(msg string) *sv.SV {
+		return i.builtinDie([]*sv.SV{sv.NewString(msg + i.at() + ".\n")})
+	}
+	freeze := func(v *sv.SV, notRef string) []byte {
+		if !v.IsRef() {
+			die(notRef)
+			return nil
+		}
+		data, err := sv.Freeze(v)
+		if err != nil {
+			die(err.Error())
+		}
+		return data
+	}
+	// fileError - сообщение с текстом $!, который тоже выставляется
+	fileError := func(what, file string, err error) *sv.SV {
+		context.GetRuntime().SetOSError(err)
+		return die(what + " " + file + ": " + context.GetRuntime().OSError().AsString())
+	}
+	thaw := func(data []byte, failed string) *sv.SV {
+		v, err := sv.Thaw(data, i)
+		if err != nil {
+			return die(failed)
+		}
+		return v
+	}
+
+	switch name {
+	case "Storable::freeze", "Storable::nfreeze":
+		return sv.NewString(string(freeze(arg(0), "not a reference")))
+	case "Storable::thaw":
+		if arg(0).AsString() == "" {
+			return sv.NewUndef()
+		}
+		return thaw([]byte(arg(0).AsString()), sv.ErrStorableImage.Error())
+	case "Storable::dclone":
+		return thaw(freeze(arg(0), "Not a reference"), sv.ErrStorableImage.Error())
+	case "Storable::store", "Storable::nstore":
+		data := freeze(arg(0), "not a reference")
+		file := arg(1).AsString()
+		if err := os.WriteFile(file, data, 0o666); err != nil {
+			return fileError("can't create", file, err)
+		}
+		return sv.NewInt(1)
+	case "Storable::retrieve":
+		file := arg(0).AsString()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fileError("can't open", file, err)
+		}
+		return thaw(data, "Magic number checking on storable file failed")
+	}
+	return sv.NewUndef()
+}
@@ -0,0 +1,240 @@
+package eval
+
+import (
+	"fmt"
+
+	"perlc/pkg/context"
+	"perlc/pkg/sv"
+)
+
+// threads и Thread::Queue. Как и Perlc::spawn, поток в интерпретаторе не
+// запускается сразу: threads->create ставит его в очередь задач, а
+// выполняется он при join, при dequeue из пустой очереди, при
+// is_running/is_joinable или при Perlc::wait. В скомпилированной программе
+// поток - горутина, очередь - канал.
+//
+// Отличия от ithreads: данные потоку не копируются. Скопированы только
+// аргументы create и значения enqueue; глобальные переменные, замыкания и
+// всё, на что указывают ссылки, общие для всех потоков, поэтому
+// threads::shared не нужен, а одновременная запись из нескольких потоков
+// в скомпилированной программе требует своей синхронизации (удобнее всего
+// передавать данные через Thread::Queue). Потоки, не дождавшиеся join к
+// концу программы, завершаются молча.
+
+// thread - состояние потока threads, ключ - хэш объекта
+type thread struct {
+	tid      int
+	code     *sv.SV
+	args     []*sv.SV
+	result   *sv.SV
+	err      *sv.SV
+	started  bool
+	done     bool
+	joined   bool
+	detached bool
+}
+
+// threadsMethod - методы threads и Thread::Queue
+func (i *Interpreter) threadsMethod(obj *sv.SV, class, method string, args []*sv.SV) *sv.SV {
+	var result *sv.SV
+	if class == "threads" {
+		result = i.threadMethod(obj, method, args)
+	} else {
+		result = i.queueMethod(obj, method, args)
+	}
+	if result == nil {
+		return i.builtinDie([]*sv.SV{sv.NewString(`Can't locate object method "` + method + `" via package "` + class + `"` + i.at() + ".\n")})
+	}
+	return result
+}
+
+// threadMethod - методы класса threads и его объектов
+func (i *Interpreter) threadMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	if !obj.IsRef() {
+		switch method {
+		case "create", "new":
+			return i.threadCreate(args)
+		case "tid":
+			if i.thread != nil {
+				return sv.NewInt(int64(i.thread.tid))
+			}
+			return sv.NewInt(0)
+		case "self":
+			if i.thread != nil {
+				return i.threadObject(i.thread)
+			}
+			return i.threadObject(&thread{started: true})
+		case "list":
+			var list []*sv.SV
+			for _, t := range i.threadList {
+				if !t.joined && !t.detached {
+					list = append(list, i.threadObject(t))
+				}
+			}
+			return sv.NewArrayRef(list...)
+		case "yield":
+			i.runTask()
+			return sv.NewInt(1)
+		}
+		return nil
+	}
+	t := i.threads[obj.Deref()]
+	if t == nil {
+		return sv.NewUndef()
+	}
+	switch method {
+	case "join":
+		if t.detached {
+			return i.builtinDie([]*sv.SV{sv.NewString("Cannot join a detached thread" + i.at() + ".\n")})
+		}
+		if t.joined {
+			return i.builtinDie([]*sv.SV{sv.NewString("Thread already joined" + i.at() + ".\n")})
+		}
+		i.runThread(t)
+		t.joined = true
+		if t.result == nil {
+			return sv.NewUndef()
+		}
+		return t.result
+	case "detach":
+		t.detached = true
+		return sv.NewInt(1)
+	case "tid":
+		return sv.NewInt(int64(t.tid))
+	case "is_running":
+		i.runThread(t)
+		return boolToSV(!t.done)
+	case "is_joinable":
+		i.runThread(t)
+		return boolToSV(t.done && !t.joined && !t.detached)
+	case "is_detached":
+		return boolToSV(t.detached)
+	case "error":
+		if t.err == nil {
+			return sv.NewUndef()
+		}
+		return t.err
+	case "equal":
+		if len(args) == 0 || !args[0].IsRef() {
+			return boolToSV(false)
+		}
+		other := i.threads[args[0].Deref()]
+		return boolToSV(other != nil && other.tid == t.tid)
+	}
+	return nil
+}
+
+// threadCreate - threads->create(CODE, ARGS); хэш параметров перед кодом
+// ({context => 'list'} и т.п.) пропускается
+func (i *Interpreter) threadCreate(args []*sv.SV) *sv.SV {
+	if len(args) > 0 && args[0].IsRef() && args[0].Deref().IsHash() {
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0].CodeName() == "" {
+		return i.builtinDie([]*sv.SV{sv.NewString("threads->create needs a code reference" + i.at() + ".\n")})
+	}
+	if i.threads == nil {
+		i.threads = make(map[*sv.SV]*thread)
+	}
+	t := &thread{tid: len(i.threadList) + 1, code: args[0], args: i.copyList(i.flattenArgs(args[1:]))}
+	i.threadList = append(i.threadList, t)
+	i.tasks = append(i.tasks, task{thread: t})
+	return i.threadObject(t)
+}
+
+// threadObject - объект threads для потока; все объекты одного потока
+// ведут к одному состоянию
+func (i *Interpreter) threadObject(t *thread) *sv.SV {
+	obj := sv.NewHashRef().Bless("threads")
+	if i.threads == nil {
+		i.threads = make(map[*sv.SV]*thread)
+	}
+	i.threads[obj.Deref()] = t
+	return obj
+}
+
+// runThread выполняет поток, если он ещё не выполнялся. die внутри потока
+// завершает только его: предупреждение "Thread N terminated abnormally",
+// join вернёт undef, а $thr->error - ошибку. $@ вызывающего не меняется.
+func (i *Interpreter) runThread(t *thread) {
+	if t.started {
+		return
+	}
+	t.started = true
+	rt := context.GetRuntime()
+	saved := rt.EvalError()
+	rt.SetEvalError(sv.NewString(""))
+	outer := i.thread
+	i.thread = t
+	ok := rt.TryEval(func() {
+		t.result = i.callCode(t.code, t.args)
+	})
+	i.thread = outer
+	if !ok {
+		t.err = rt.EvalError()
+		t.result = nil
+		i.builtinWarn([]*sv.SV{sv.NewString(fmt.Sprintf("Thread %d terminated abnormally: %s", t.tid, t.err.AsString()))})
+	}
+	rt.SetEvalError(saved)
+	t.done = true
+}
+
+// queueMethod - new, enqueue, dequeue, dequeue_nb, pending, peek и end
+// объекта Thread::Queue. Очередь - тот же chanQueue, что у Perlc::Chan.
+func (i *Interpreter) queueMethod(obj *sv.SV, method string, args []*sv.SV) *sv.SV {
+	if method == "new" {
+		q := sv.NewHashRef().Bless("Thread::Queue")
+		i.chans[q.Deref()] = &chanQueue{values: i.copyList(i.flattenArgs(args))}
+		return q
+	}
+	q := i.chans[obj.Deref()]
+	if q == nil {
+		return sv.NewUndef()
+	}
+	switch method {
+	case "enqueue":
+		if q.closed {
+			return i.builtinDie([]*sv.SV{sv.NewString("'enqueue' method called on queue that has been 'end'ed" + i.at() + ".\n")})
+		}
+		q.values = append(q.values, i.copyList(i.flattenArgs(args))...)
+		return sv.NewInt(1)
+	case "dequeue", "dequeue_nb":
+		count := 1
+		if len(args) > 0 {
+			count = max(1, int(args[0].AsInt()))
+		}
+		for method == "dequeue" && len(q.values) < count && !q.closed && i.runTask() {
+		}
+		n := min(count, len(q.values))
+		items := q.values[:n:n]
+		q.values = q.values[n:]
+		if len(args) > 0 {
+			return sv.NewArrayRef(items...)
+		}
+		if n == 0 {
+			return sv.NewUndef()
+		}
+		return items[0]
+	case "pending":
+		if q.closed && len(q.values) == 0 {
+			return sv.NewUndef()
+		}
+		return sv.NewInt(int64(len(q.values)))
+	case "peek":
+		idx := 0
+		if len(args) > 0 {
+			idx = int(args[0].AsInt())
+		}
+		if idx < 0 {
+			idx += len(q.values)
+		}
+		if idx < 0 || idx >= len(q.values) {
+			return sv.NewUndef()
+		}
+		return q.values[idx]
+	case "end":
+		q.closed = true
+		return sv.NewInt(1)
+	}
+	return nil
+}
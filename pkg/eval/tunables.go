@@ -0,0 +1,191 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/context"
+	"perlc/pkg/hv"
+	"perlc/pkg/perlre"
+	"perlc/pkg/sv"
+	"perlc/pkg/tunables"
+	"perlc/pkg/warnings"
+)
+
+// deepRecursion - глубина, на которой выдаётся подробное (Warnings >= 2)
+// предупреждение "Deep recursion", как в perl -w
+const deepRecursion = 100
+
+// SetTunables sets the runtime knobs (PERLC_* variables and perlc flags).
+func (i *Interpreter) SetTunables(t tunables.Tunables) {
+	i.tune = t
+	i.warnings.SetLevel(t.Warnings)
+	hv.SetSeed(t.HashSeed)
+	i.ctx.SetIOBuffer(t.IOBuffer)
+}
+
+// enterSub считает вложенность вызовов sub: при превышении MaxDepth - die,
+// на глубине deepRecursion - подробное предупреждение. Кадр вызова (sub,
+// аргументы, место и пакет вызывающего) кладётся в стек context.Runtime
+// для Carp. Возвращает функцию выхода для defer.
+func (i *Interpreter) enterSub(name string, args []*sv.SV) func() {
+	i.depth++
+	if i.tune.MaxDepth > 0 && i.depth > i.tune.MaxDepth {
+		i.depth-- // этот вызов не состоялся, defer выхода не будет
+		i.builtinDie([]*sv.SV{sv.NewString(fmt.Sprintf("Deep recursion limit of %d exceeded in subroutine \"%s\"", i.tune.MaxDepth, qualifiedSub(name)))})
+	}
+	if i.depth == deepRecursion {
+		i.warn(warnings.Recursion, fmt.Sprintf("Deep recursion on subroutine \"%s\"", qualifiedSub(name)))
+	}
+	pop := i.pushFrame(&context.StackFrame{Sub: qualifiedSub(name), Args: args, HasArgs: true})
+	// тело sub выполняется в пакете, где она объявлена
+	prevPkg := i.pkg
+	if pkg, ok := i.subPkgs[name]; ok {
+		i.pkg = pkg
+	}
+	return func() {
+		pop()
+		i.depth--
+		i.pkg = prevPkg
+	}
+}
+
+// enterEval кладёт в стек вызовов кадр eval BLOCK (text пустой) или
+// eval STRING, Carp показывает их как "eval {...}" и "eval '...'"
+func (i *Interpreter) enterEval(text string) func() {
+	return i.pushFrame(&context.StackFrame{Sub: "(eval)", IsEval: true, EvalText: text})
+}
+
+// pushFrame дополняет кадр местом выполняемого оператора и пакетом
+// вызывающего кода и кладёт его в стек context.Runtime
+func (i *Interpreter) pushFrame(f *context.StackFrame) func() {
+	rt := context.GetRuntime()
+	pos, _ := ast.PosOf(i.stmt)
+	f.File, f.Line, f.Package = pos.File, pos.Line, i.pkg
+	if top := rt.CurrentFrame(); top != nil {
+		f.Package = framePackage(top)
+	}
+	rt.PushCall(f)
+	return func() { rt.PopCall() }
+}
+
+// warn выдаёт предупреждение интерпретатора с местом выполняемого
+// оператора; повторы с того же места, отключённые категории и уровень
+// Warnings отсекает Reporter
+func (i *Interpreter) warn(c warnings.Category, msg string) {
+	pos, _ := ast.PosOf(i.stmt)
+	i.warnings.Warn(c, pos, msg)
+}
+
+// checkOperands проверяет операнды оператора op (+, ., eq, ...):
+// undef - "Use of uninitialized value $x in addition (+)" с именем
+// переменной из AST, строка не-число у числового оператора - "Argument
+// isn't numeric". У числовых операторов perl проверяет сначала правый
+// операнд, у строковых - левый. assign - составное присваивание (+=):
+// левую часть $n += 1 и $s .= "x" perl не проверяет на undef.
+func (i *Interpreter) checkOperands(op string, leftExpr, rightExpr ast.Expression, left, right *sv.SV, assign bool) {
+	desc, ok := warnings.OpName(op)
+	if !ok {
+		return
+	}
+	_, numeric := warnings.NumericOps[op]
+	checkLeft := func() {
+		if !assign || !warnings.AssignExempt(op) {
+			i.checkOperand(leftExpr, left, desc, numeric)
+		} else if numeric {
+			i.checkNumeric(left, desc)
+		}
+	}
+	if !numeric {
+		checkLeft()
+	}
+	i.checkOperand(rightExpr, right, desc, numeric)
+	if numeric {
+		checkLeft()
+	}
+}
+
+// checkOperand предупреждает об undef-операнде e (значение v) оператора
+// desc, а для числового оператора - и о строке, не похожей на число
+func (i *Interpreter) checkOperand(e ast.Expression, v *sv.SV, desc string, numeric bool) {
+	if v == nil || v.IsUndef() {
+		i.warn(warnings.Uninitialized, warnings.Uninit(warnings.VarName(e), desc))
+		return
+	}
+	if numeric {
+		i.checkNumeric(v, desc)
+	}
+}
+
+// checkNumeric предупреждает "Argument isn't numeric", если строковый
+// операнд числового оператора op не похож на число
+func (i *Interpreter) checkNumeric(v *sv.SV, op string) {
+	if v != nil && v.Type() == sv.TypeString && !v.LooksLikeNumber() {
+		i.warn(warnings.Numeric, sv.NotNumeric(v.AsString(), op))
+	}
+}
+
+// checkHashList предупреждает, когда хешу присваивается нечётное число
+// элементов: "Odd number of elements in hash assignment", а для одной
+// ссылки (%h = {...}) "Reference found where even-sized list expected"
+func (i *Interpreter) checkHashList(target ast.Expression, values []*sv.SV) {
+	if !isHashVar(target) {
+		return
+	}
+	if msg := warnings.HashList(len(values), len(values) == 1 && values[0].IsRef()); msg != "" {
+		i.warn(warnings.Misc, msg)
+	}
+}
+
+// useWarnings - use warnings LIST (off = false) и no warnings LIST;
+// неизвестная категория - die, как в perl
+func (i *Interpreter) useWarnings(args []ast.Expression, off bool) {
+	var names []string
+	for _, arg := range args {
+		for _, v := range i.svToList(i.evalExpression(arg)) {
+			names = append(names, v.AsString())
+		}
+	}
+	set := i.warnings.Enable
+	if off {
+		set = i.warnings.Disable
+	}
+	if err := set(names...); err != nil {
+		i.builtinDie([]*sv.SV{sv.NewString(err.Error() + i.at() + ".\n")})
+	}
+}
+
+// compileRegex компилирует шаблон через кэш на RegexCache шаблонов;
+// переполненный кэш очищается целиком. Шаблон, который не компилируется
+// (или использует неподдерживаемую конструкцию вроде (?{...})), даёт
+// предупреждение категории regexp со строкой и не совпадает.
+func (i *Interpreter) compileRegex(pattern string) (*perlre.Regexp, error) {
+	if re, ok := i.regexCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := perlre.Compile(pattern)
+	if err != nil {
+		i.warn(warnings.Regexp, err.Error())
+		return nil, err
+	}
+	if i.tune.RegexCache <= 0 {
+		return re, nil
+	}
+	if len(i.regexCache) >= i.tune.RegexCache {
+		clear(i.regexCache)
+	}
+	i.regexCache[pattern] = re
+	return re, nil
+}
+
+// qualifiedSub - имя подпрограммы с пакетом, как в сообщениях perl
+func qualifiedSub(name string) string {
+	if strings.HasPrefix(name, "__ANON__") {
+		return "main::__ANON__"
+	}
+	if !strings.Contains(name, "::") {
+		return "main::" + name
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+// Package features is the support matrix of the perl builtins: for every
+// builtin whether the lexer has a keyword for it, the parser parses it and
+// the interpreter and the compiler implement it. The matrix is generated
+// from the sources themselves (see Walk) into matrix.json, so the layers
+// can be compared without running anything; perlc -features prints it and
+// perlc scan checks a script against it.
+package features
+
+//go:generate go run ./gen
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Builtin is one row of the matrix.
+type Builtin struct {
+	Name string `json:"name"`
+	// Keyword: the lexer turns the name into a token of its own
+	Keyword bool `json:"keyword"`
+	// Parser: the parser builds a call for it; a name without a keyword
+	// token is parsed like any call
+	Parser      bool `json:"parser"`
+	Interpreter bool `json:"interpreter"`
+	Compiler    bool `json:"compiler"`
+}
+
+// Supported reports whether every layer handles the builtin.
+func (b Builtin) Supported() bool {
+	return b.Parser && b.Interpreter && b.Compiler
+}
+
+// Missing lists the layers that do not handle the builtin.
+func (b Builtin) Missing() []string {
+	var out []string
+	if !b.Parser {
+		out = append(out, "parser")
+	}
+	if !b.Interpreter {
+		out = append(out, "interpreter")
+	}
+	if !b.Compiler {
+		out = append(out, "compiler")
+	}
+	return out
+}
+
+// Matrix is the content of matrix.json.
+type Matrix struct {
+	Builtins []Builtin `json:"builtins"`
+}
+
+//go:embed matrix.json
+var matrixJSON []byte
+
+var builtins map[string]Builtin
+
+func init() {
+	var m Matrix
+	if err := json.Unmarshal(matrixJSON, &m); err != nil {
+		panic("features: matrix.json: " + err.Error())
+	}
+	builtins = make(map[string]Builtin, len(m.Builtins))
+	for _, b := range m.Builtins {
+		builtins[b.Name] = b
+	}
+}
+
+// All returns the builtins of the matrix sorted by name.
+func All() []Builtin {
+	out := make([]Builtin, 0, len(builtins))
+	for _, b := range builtins {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Lookup returns the row of a builtin.
+func Lookup(name string) (Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// Encode returns the matrix as the indented JSON of matrix.json.
+func Encode(list []Builtin) ([]byte, error) {
+	data, err := json.MarshalIndent(Matrix{Builtins: list}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Table formats the matrix for people: one builtin per line with yes or
+// "-" for each layer.
+func Table(list []Builtin) string {
+	width := len("builtin")
+	for _, b := range list {
+		width = max(width, len(b.Name))
+	}
+	mark := func(ok bool) string {
+		if ok {
+			return "yes"
+		}
+		return "-"
+	}
+	var sb strings.Builder
+	row := func(cols ...string) {
+		line := cols[0] + strings.Repeat(" ", width-len(cols[0]))
+		for _, c := range cols[1:] {
+			line += "  " + c + strings.Repeat(" ", len("interpreter")-len(c))
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	row("builtin", "keyword", "parser", "interpreter", "compiler")
+	for _, b := range list {
+		row(b.Name, mark(b.Keyword), mark(b.Parser), mark(b.Interpreter), mark(b.Compiler))
+	}
+	return sb.String()
+}
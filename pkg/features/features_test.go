@@ -0,0 +1,116 @@
+package features
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// knownGaps are the builtins the lexer tokenizes that some layer does not
+// handle yet, with the missing layers. Implementing one fails the test
+// until it is taken off the list, so the list stays the real picture.
+var knownGaps = map[string]string{
+	"atan2":  "interpreter compiler",
+	"caller": "parser interpreter compiler",
+	"cos":    "interpreter compiler",
+	"exp":    "interpreter compiler",
+	"fork":   "interpreter compiler",
+	"goto":   "parser interpreter compiler",
+	"kill":   "interpreter compiler",
+	"log":    "interpreter compiler",
+	"rand":   "interpreter compiler",
+	"sin":    "interpreter compiler",
+	"splice": "interpreter compiler",
+	"srand":  "interpreter compiler",
+	"substr": "compiler",
+	"tie":    "parser interpreter compiler",
+	"tied":   "parser interpreter compiler",
+	"untie":  "parser interpreter compiler",
+	"write":  "compiler",
+}
+
+func TestMatrixUpToDate(t *testing.T) {
+	list, err := Walk("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, All()) {
+		t.Fatal("matrix.json does not match the sources, run go generate ./pkg/features")
+	}
+}
+
+func TestKeywordGaps(t *testing.T) {
+	list, err := Walk("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, b := range list {
+		if !b.Keyword {
+			continue
+		}
+		missing := strings.Join(b.Missing(), " ")
+		want, known := knownGaps[b.Name]
+		seen[b.Name] = known
+		switch {
+		case missing != "" && !known:
+			t.Errorf("%s is tokenized but not handled by the %s", b.Name, missing)
+		case known && missing != want:
+			t.Errorf("%s: missing %q, knownGaps says %q; update the list", b.Name, missing, want)
+		}
+	}
+	for name := range knownGaps {
+		if !seen[name] {
+			t.Errorf("knownGaps lists %s, which is no longer a keyword builtin", name)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	list, err := Walk("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string]Builtin{}
+	for _, b := range list {
+		rows[b.Name] = b
+	}
+	tests := []Builtin{
+		{Name: "print", Keyword: true, Parser: true, Interpreter: true, Compiler: true},
+		// lexed as an identifier, parsed as a call
+		{Name: "quotemeta", Parser: true, Interpreter: true, Compiler: true},
+		{Name: "tie", Keyword: true},
+	}
+	for _, want := range tests {
+		if got := rows[want.Name]; got != want {
+			t.Errorf("%s: got %+v, want %+v", want.Name, got, want)
+		}
+	}
+	for _, syntax := range []string{"if", "my", "qw", "eq", "eval"} {
+		if _, ok := rows[syntax]; ok {
+			t.Errorf("keyword %s is syntax, not a builtin", syntax)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	src := `my %h = (tied => 1);
+print $h{rand}, "rand";
+my $x = rand(10);
+sub total_size { 1 }
+print total_size(3);
+$x->can("print");
+print tied %h;
+`
+	var got []string
+	for _, u := range Scan(src) {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"line 3: rand is not supported by the interpreter and compiler",
+		"line 7: tied is not supported by the parser, interpreter and compiler",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+// Command gen writes matrix.json of package features from the sources of
+// the lexer, parser, interpreter and code generator:
+//
+//	go generate ./pkg/features
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"perlc/pkg/features"
+)
+
+func main() {
+	list, err := features.Walk("../..")
+	if err == nil {
+		var data []byte
+		if data, err = features.Encode(list); err == nil {
+			err = os.WriteFile("matrix.json", data, 0644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,74 @@
+package features
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/lexer"
+)
+
+// Use is a call in a script of a builtin that some layer does not handle.
+type Use struct {
+	Line    int
+	Builtin Builtin
+}
+
+func (u Use) String() string {
+	layers := u.Builtin.Missing()
+	list := layers[len(layers)-1]
+	if len(layers) > 1 {
+		list = strings.Join(layers[:len(layers)-1], ", ") + " and " + list
+	}
+	return fmt.Sprintf("line %d: %s is not supported by the %s", u.Line, u.Builtin.Name, list)
+}
+
+// Scan lexes a script and returns its uses of builtins the matrix marks
+// as unsupported: keyword tokens, and names called with parentheses that
+// the script does not define with sub itself. A hash key (write => 1,
+// $h{write}) and a method call (->can) are not uses.
+func Scan(src string) []Use {
+	l := lexer.New(src)
+	var toks []lexer.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == lexer.TokEOF {
+			break
+		}
+		toks = append(toks, tok)
+	}
+
+	subs := map[string]bool{}
+	for i := 0; i+1 < len(toks); i++ {
+		if toks[i].Type == lexer.TokSub && toks[i+1].Type == lexer.TokIdent {
+			subs[toks[i+1].Value] = true
+		}
+	}
+
+	var uses []Use
+	for i, tok := range toks {
+		b, ok := Lookup(tok.Value)
+		if !ok || b.Supported() || subs[tok.Value] {
+			continue
+		}
+		var prev, next lexer.TokenType = lexer.TokEOF, lexer.TokEOF
+		if i > 0 {
+			prev = toks[i-1].Type
+		}
+		if i+1 < len(toks) {
+			next = toks[i+1].Type
+		}
+		switch {
+		case prev == lexer.TokArrow || prev == lexer.TokSub || next == lexer.TokFatArrow:
+			continue
+		case prev == lexer.TokLBrace && next == lexer.TokRBrace:
+			continue
+		case tok.Type == lexer.TokIdent && next != lexer.TokLParen:
+			continue
+		case tok.Type != lexer.TokIdent && tok.Type != lexer.LookupKeyword(tok.Value):
+			// a string or variable that happens to have the name
+			continue
+		}
+		uses = append(uses, Use{Line: tok.Line, Builtin: b})
+	}
+	return uses
+}
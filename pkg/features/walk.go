@@ -0,0 +1,283 @@
+package features
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinParsers are the parse functions that turn a keyword into a call;
+// a keyword the parser handles with any other one (if, my, qw, eq) is
+// syntax, not a builtin
+var builtinParsers = map[string]bool{
+	"parseBuiltinCall":    true,
+	"parseGrepMap":        true,
+	"parseSortExpression": true,
+	"parseOpenExpr":       true,
+	"parseCloseExpr":      true,
+}
+
+// runtimeFunc finds the builtins of the compiler runtime, written out as
+// Go source in string literals: func perl_sleep(...)
+var runtimeFunc = regexp.MustCompile(`func perl_(\w+)\(`)
+
+// Walk builds the matrix from the Go sources under root, the module
+// directory:
+//   - the keywords table of pkg/lexer,
+//   - the registerPrefix calls of pkg/parser, and whether a keyword token
+//     is used by the parser at all,
+//   - the cases of the switch on the function name in evalCallExpr
+//     (pkg/eval),
+//   - the cases of the switch in generateCallExpr and the perl_NAME
+//     functions of the runtimes it generates (pkg/codegen),
+//   - the Perl_NAME functions of the runtime package (pkg/perlrt).
+func Walk(root string) ([]Builtin, error) {
+	lexerFiles, err := parseDir(filepath.Join(root, "pkg", "lexer"))
+	if err != nil {
+		return nil, err
+	}
+	parserFiles, err := parseDir(filepath.Join(root, "pkg", "parser"))
+	if err != nil {
+		return nil, err
+	}
+	evalFiles, err := parseDir(filepath.Join(root, "pkg", "eval"))
+	if err != nil {
+		return nil, err
+	}
+	codegenFiles, err := parseDir(filepath.Join(root, "pkg", "codegen"))
+	if err != nil {
+		return nil, err
+	}
+	perlrtFiles, err := parseDir(filepath.Join(root, "pkg", "perlrt"))
+	if err != nil {
+		return nil, err
+	}
+
+	keywords := keywordTable(lexerFiles)
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("no keywords table in pkg/lexer")
+	}
+	prefix, refs := parserTokens(parserFiles)
+	interpreter := switchCases(evalFiles, "evalCallExpr", "funcName")
+	compiler := switchCases(codegenFiles, "generateCallExpr", "name")
+	if len(interpreter) == 0 || len(compiler) == 0 {
+		return nil, fmt.Errorf("no builtin switch in evalCallExpr or generateCallExpr")
+	}
+	runtime := runtimeFuncs(codegenFiles)
+	for name := range packageFuncs(perlrtFiles) {
+		runtime[name] = true
+	}
+
+	rows := map[string]*Builtin{}
+	row := func(name string) *Builtin {
+		if rows[name] == nil {
+			rows[name] = &Builtin{Name: name, Parser: true}
+		}
+		return rows[name]
+	}
+	for name, tok := range keywords {
+		fn, registered := prefix[tok]
+		switch {
+		case registered && builtinParsers[fn]:
+			row(name).Keyword = true
+		case !registered && refs[tok] == 0:
+			// tokenized, but the parser never looks at the token
+			b := row(name)
+			b.Keyword, b.Parser = true, false
+		}
+	}
+	for name := range interpreter {
+		row(name).Interpreter = true
+	}
+	for name := range compiler {
+		row(name).Compiler = true
+	}
+	for name, b := range rows {
+		if runtime[strings.ReplaceAll(name, "::", "_")] {
+			b.Compiler = true
+		}
+		// a keyword that is not a builtin parses into its own node
+		if _, isKeyword := keywords[name]; isKeyword && !b.Keyword {
+			delete(rows, name)
+		}
+	}
+
+	out := make([]Builtin, 0, len(rows))
+	for _, b := range rows {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// parseDir parses the non-test Go files of a directory
+func parseDir(dir string) ([]*goast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := gotoken.NewFileSet()
+	var files []*goast.File
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := goparser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// keywordTable reads var keywords = map[string]TokenType{"if": TokIf, ...}
+func keywordTable(files []*goast.File) map[string]string {
+	table := map[string]string{}
+	for _, f := range files {
+		goast.Inspect(f, func(n goast.Node) bool {
+			spec, ok := n.(*goast.ValueSpec)
+			if !ok || len(spec.Names) != 1 || spec.Names[0].Name != "keywords" || len(spec.Values) != 1 {
+				return true
+			}
+			lit, ok := spec.Values[0].(*goast.CompositeLit)
+			if !ok {
+				return false
+			}
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*goast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				key, ok := kv.Key.(*goast.BasicLit)
+				tok, isIdent := kv.Value.(*goast.Ident)
+				if !ok || !isIdent {
+					continue
+				}
+				if name, err := strconv.Unquote(key.Value); err == nil {
+					table[name] = tok.Name
+				}
+			}
+			return false
+		})
+	}
+	return table
+}
+
+// parserTokens returns the prefix parse function of each token and how
+// often the parser mentions the token outside the registrations
+func parserTokens(files []*goast.File) (prefix map[string]string, refs map[string]int) {
+	prefix, refs = map[string]string{}, map[string]int{}
+	for _, f := range files {
+		goast.Inspect(f, func(n goast.Node) bool {
+			if call, ok := n.(*goast.CallExpr); ok {
+				sel, ok := call.Fun.(*goast.SelectorExpr)
+				if ok && (sel.Sel.Name == "registerPrefix" || sel.Sel.Name == "registerInfix") && len(call.Args) == 2 {
+					tok := lexerToken(call.Args[0])
+					if sel.Sel.Name == "registerInfix" {
+						// an operator keyword: syntax
+						refs[tok]++
+					} else if fn, ok := call.Args[1].(*goast.SelectorExpr); ok {
+						prefix[tok] = fn.Sel.Name
+					}
+					return false
+				}
+			}
+			if tok := lexerToken(n); tok != "" {
+				refs[tok]++
+			}
+			return true
+		})
+	}
+	return prefix, refs
+}
+
+// lexerToken returns X of lexer.X
+func lexerToken(n goast.Node) string {
+	sel, ok := n.(*goast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if pkg, ok := sel.X.(*goast.Ident); ok && pkg.Name == "lexer" {
+		return sel.Sel.Name
+	}
+	return ""
+}
+
+// switchCases collects the string cases of the switch on the variable tag
+// in the function fn
+func switchCases(files []*goast.File, fn, tag string) map[string]bool {
+	cases := map[string]bool{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*goast.FuncDecl)
+			if !ok || fd.Name.Name != fn || fd.Body == nil {
+				continue
+			}
+			goast.Inspect(fd.Body, func(n goast.Node) bool {
+				sw, ok := n.(*goast.SwitchStmt)
+				if !ok {
+					return true
+				}
+				if id, ok := sw.Tag.(*goast.Ident); !ok || id.Name != tag {
+					return true
+				}
+				for _, stmt := range sw.Body.List {
+					for _, e := range stmt.(*goast.CaseClause).List {
+						if lit, ok := e.(*goast.BasicLit); ok && lit.Kind == gotoken.STRING {
+							if name, err := strconv.Unquote(lit.Value); err == nil {
+								cases[name] = true
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return cases
+}
+
+// runtimeFuncs finds the perl_NAME functions in the string literals of
+// the code generator
+func runtimeFuncs(files []*goast.File) map[string]bool {
+	funcs := map[string]bool{}
+	for _, f := range files {
+		goast.Inspect(f, func(n goast.Node) bool {
+			lit, ok := n.(*goast.BasicLit)
+			if !ok || lit.Kind != gotoken.STRING {
+				return true
+			}
+			src, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			for _, m := range runtimeFunc.FindAllStringSubmatch(src, -1) {
+				funcs[m[1]] = true
+			}
+			return true
+		})
+	}
+	return funcs
+}
+
+// packageFuncs finds the Perl_NAME functions of the runtime package
+func packageFuncs(files []*goast.File) map[string]bool {
+	funcs := map[string]bool{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*goast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Perl_") {
+				funcs[strings.TrimPrefix(fn.Name.Name, "Perl_")] = true
+			}
+		}
+	}
+	return funcs
+}
@@ -6,11 +6,52 @@
 package hv
 
 import (
+	"hash/fnv"
+	"reflect"
 	"sort"
+	"strconv"
 
 	"perlc/pkg/sv"
 )
 
+// seed fixes the key order of Keys, Values and Each (PERLC_HASH_SEED);
+// 0 keeps Go's randomized map order.
+// seed, Keys, Values ve Each anahtar sırasını sabitler (PERLC_HASH_SEED).
+var seed int64
+
+// SetSeed sets the hash seed.
+// SetSeed, hash tohumunu ayarlar.
+func SetSeed(s int64) {
+	seed = s
+}
+
+// keyOrder returns the keys of a hash in iteration order, the same for
+// Keys, Values and Each.
+// keyOrder, hash anahtarlarını yineleme sırasında döndürür.
+func keyOrder(data map[string]*sv.SV) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	if seed == 0 {
+		return keys
+	}
+	prefix := strconv.FormatInt(seed, 10) + ":"
+	sums := make(map[string]uint64, len(keys))
+	for _, k := range keys {
+		h := fnv.New64a()
+		h.Write([]byte(prefix + k))
+		sums[k] = h.Sum64()
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if sums[keys[a]] != sums[keys[b]] {
+			return sums[keys[a]] < sums[keys[b]]
+		}
+		return keys[a] < keys[b]
+	})
+	return keys
+}
+
 // ============================================================
 // Hash Access Operations
 // Hash Erişim İşlemleri
@@ -148,6 +189,44 @@ func Scalar(hash *sv.SV) *sv.SV {
 	return sv.NewInt(int64(len(data)))
 }
 
+// Len returns the number of keys without listing them: keys %h in scalar
+// or boolean context. Like keys, it resets the each() iterator.
+// Len, anahtarları listelemeden anahtar sayısını döndürür.
+func Len(hash *sv.SV) int {
+	target := hash
+	if hash.IsRef() {
+		target = hash.Deref()
+	}
+	if target == nil || !target.IsHash() {
+		return 0
+	}
+	delete(iterators, target)
+	return len(target.HashData())
+}
+
+// Presize makes room for n keys up front: keys %h = n. A smaller n than
+// the hash already holds is ignored, as in Perl.
+// Presize, n anahtar için önceden yer ayırır: keys %h = n.
+func Presize(hash *sv.SV, n int) {
+	target := hash
+	if hash.IsRef() {
+		target = hash.Deref()
+	}
+	if target == nil || !target.IsHash() {
+		return
+	}
+	delete(iterators, target)
+	data := target.HashData()
+	if n <= len(data) {
+		return
+	}
+	grown := make(map[string]*sv.SV, n)
+	for k, v := range data {
+		grown[k] = v
+	}
+	target.SetHashData(grown)
+}
+
 // ============================================================
 // Keys, Values, Each
 // Anahtarlar, Değerler, Her Biri
@@ -164,13 +243,14 @@ func Keys(hash *sv.SV) []*sv.SV {
 		return []*sv.SV{}
 	}
 
+	delete(iterators, target)
 	data := target.HashData()
 	if data == nil {
 		return []*sv.SV{}
 	}
 
 	result := make([]*sv.SV, 0, len(data))
-	for k := range data {
+	for _, k := range keyOrder(data) {
 		result = append(result, sv.NewString(k))
 	}
 	return result
@@ -197,13 +277,15 @@ func Values(hash *sv.SV) []*sv.SV {
 		return []*sv.SV{}
 	}
 
+	delete(iterators, target)
 	data := target.HashData()
 	if data == nil {
 		return []*sv.SV{}
 	}
 
 	result := make([]*sv.SV, 0, len(data))
-	for _, v := range data {
+	for _, k := range keyOrder(data) {
+		v := data[k]
 		if v != nil {
 			v.IncRef()
 		}
@@ -212,11 +294,34 @@ func Values(hash *sv.SV) []*sv.SV {
 	return result
 }
 
-// HashIterator maintains state for each() function.
+// HashIterator maintains state for each() function. Without a hash seed
+// it walks the map itself, so each() on a big hash does not list all keys
+// first; with a seed it steps through the seeded key order.
 // HashIterator, each() fonksiyonu için durumu korur.
 type HashIterator struct {
 	keys  []string
 	index int
+	walk  *reflect.MapIter
+	data  reflect.Value
+}
+
+// next returns the next key still in data.
+// next, data'da hâlâ bulunan sonraki anahtarı döndürür.
+func (it *HashIterator) next(data map[string]*sv.SV) (string, bool) {
+	if it.walk != nil {
+		if !it.walk.Next() {
+			return "", false
+		}
+		return it.walk.Key().String(), true
+	}
+	for it.index < len(it.keys) {
+		key := it.keys[it.index]
+		it.index++
+		if _, ok := data[key]; ok {
+			return key, true
+		}
+	}
+	return "", false
 }
 
 // iterators stores per-hash iterator state.
@@ -242,32 +347,29 @@ func Each(hash *sv.SV) []*sv.SV {
 		return []*sv.SV{}
 	}
 
-	// Get or create iterator
+	// Get or create iterator; a hash whose map was replaced starts over
 	// İteratörü al veya oluştur
 	iter, ok := iterators[target]
-	if !ok {
-		iter = &HashIterator{
-			keys:  make([]string, 0, len(data)),
-			index: 0,
-		}
-		for k := range data {
-			iter.keys = append(iter.keys, k)
+	if !ok || iter.data.UnsafePointer() != reflect.ValueOf(data).UnsafePointer() {
+		iter = &HashIterator{data: reflect.ValueOf(data)}
+		if seed == 0 {
+			iter.walk = iter.data.MapRange()
+		} else {
+			iter.keys = keyOrder(data)
 		}
 		iterators[target] = iter
 	}
 
 	// Return next pair
 	// Sonraki çifti döndür
-	if iter.index >= len(iter.keys) {
+	key, ok := iter.next(data)
+	if !ok {
 		// Reset for next iteration
 		// Sonraki iterasyon için sıfırla
 		delete(iterators, target)
 		return []*sv.SV{}
 	}
-
-	key := iter.keys[iter.index]
 	val := data[key]
-	iter.index++
 
 	if val != nil {
 		val.IncRef()
@@ -437,7 +539,8 @@ func Flatten(hash *sv.SV) []*sv.SV {
 	data := target.HashData()
 	result := make([]*sv.SV, 0, len(data)*2)
 
-	for k, v := range data {
+	for _, k := range keyOrder(data) {
+		v := data[k]
 		result = append(result, sv.NewString(k))
 		if v != nil {
 			v.IncRef()
@@ -165,3 +165,136 @@ func TestSlice(t *testing.T) {
 		t.Error("Got wrong values from slice")
 	}
 }
+
+// TestSeededKeys tests that a hash seed fixes the key order.
+func TestSeededKeys(t *testing.T) {
+	defer SetSeed(0)
+	hash := sv.NewHashRef()
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		Store(hash, sv.NewString(k), sv.NewInt(1))
+	}
+	order := func() string {
+		var s string
+		for _, k := range Keys(hash) {
+			s += k.AsString()
+		}
+		return s
+	}
+
+	SetSeed(7)
+	first := order()
+	for n := 0; n < 5; n++ {
+		if got := order(); got != first {
+			t.Fatalf("seed 7: order changed from %s to %s", first, got)
+		}
+	}
+	SetSeed(3)
+	if got := order(); got == first {
+		t.Errorf("seed 3 gave the same order as seed 7: %s", got)
+	}
+}
+
+// TestLenPresize tests keys %h in scalar context and keys %h = n.
+// TestLenPresize, skaler bağlamda keys %h ve keys %h = n'yi test eder.
+func TestLenPresize(t *testing.T) {
+	hash := sv.NewHashRef()
+	if n := Len(hash); n != 0 {
+		t.Errorf("empty hash: expected 0, got %d", n)
+	}
+	Store(hash, sv.NewString("a"), sv.NewInt(1))
+	Store(hash, sv.NewString("b"), sv.NewInt(2))
+
+	Presize(hash, 1024)
+	if n := Len(hash); n != 2 {
+		t.Errorf("presize must keep the keys: expected 2, got %d", n)
+	}
+	if val := Fetch(hash, sv.NewString("b")); val.AsInt() != 2 {
+		t.Errorf("expected 2 after presize, got %s", val.AsString())
+	}
+
+	// Len resets the each() iterator like keys
+	// Len, keys gibi each() iteratörünü sıfırlar
+	Each(hash)
+	Len(hash)
+	count := 0
+	for len(Each(hash)) > 0 {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("each after Len: expected 2 pairs, got %d", count)
+	}
+}
+
+// TestEachDelete tests deleting the current key during each().
+// TestEachDelete, each() sırasında geçerli anahtarın silinmesini test eder.
+func TestEachDelete(t *testing.T) {
+	for _, s := range []int64{0, 7} {
+		SetSeed(s)
+		hash := sv.NewHashRef()
+		for n := 0; n < 100; n++ {
+			Store(hash, sv.NewInt(int64(n)), sv.NewInt(int64(n)))
+		}
+		seen := 0
+		for pair := Each(hash); len(pair) > 0; pair = Each(hash) {
+			seen++
+			Delete(hash, pair[0])
+		}
+		if seen != 100 || Len(hash) != 0 {
+			t.Errorf("seed %d: saw %d pairs, %d keys left", s, seen, Len(hash))
+		}
+	}
+	SetSeed(0)
+}
+
+// bigHash returns a hash with n keys.
+// bigHash, n anahtarlı bir hash döndürür.
+func bigHash(n int) *sv.SV {
+	hash := sv.NewHashRef()
+	Presize(hash, n)
+	for k := 0; k < n; k++ {
+		Store(hash, sv.NewInt(int64(k)), sv.NewInt(int64(k)))
+	}
+	return hash
+}
+
+func BenchmarkStore(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		bigHash(1 << 20)
+	}
+}
+
+func BenchmarkKeys(b *testing.B) {
+	hash := bigHash(1 << 20)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Keys(hash)
+	}
+}
+
+func BenchmarkLen(b *testing.B) {
+	hash := bigHash(1 << 20)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Len(hash)
+	}
+}
+
+func BenchmarkEach(b *testing.B) {
+	hash := bigHash(1 << 20)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for len(Each(hash)) > 0 {
+		}
+	}
+}
+
+// BenchmarkEachFirst measures starting an each() loop that stops early.
+// BenchmarkEachFirst, erken biten bir each() döngüsünü ölçer.
+func BenchmarkEachFirst(b *testing.B) {
+	hash := bigHash(1 << 20)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Each(hash)
+		ResetIterator(hash)
+	}
+}
@@ -0,0 +1,328 @@
+// Package interpolate splits the body of a double-quoted string into literal
+// text and embedded expressions. The expressions are ordinary AST nodes, so
+// the interpreter evaluates them and the code generator compiles them exactly
+// like the same expressions written outside a string.
+//
+// Supported forms:
+//
+//	$x ${x} $1 $@ $! $? $| $, $/ scalars and special variables
+//	$@->{code} $@->[0]        fields of an exception object
+//	$& $` $' $+{name}         match variables and named captures
+//	$-{name}[0]               all groups of a name
+//	$^V                       caret variables
+//	$~ $% $= $-               format variables
+//	$a[0] $h{key} $h{$k}      elements, with any index expression
+//	$r->[0]{k} $x[0][1]       subscript chains (the arrow is optional)
+//	$$r ${$r} ${\ expr}       scalar dereference
+//	@a @{$r} @$r @{[ expr ]}  lists, joined with a space
+//	@a[0,1] @h{qw(x y)}       slices
+//
+// Method calls are not interpolated ("$obj->name" is $obj followed by the
+// text "->name"); @{[ $obj->name ]} runs them.
+package interpolate
+
+import (
+	"strings"
+	"sync"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+// Segment is one piece of an interpolated string: literal text when Expr is
+// nil, otherwise an expression whose value is inserted.
+type Segment struct {
+	Text string
+	Expr ast.Expression
+	// List is set for @-forms: the elements are joined with a space
+	List bool
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string][]Segment{}
+)
+
+// Parse splits s into segments. The lexer keeps \$, \@ and \\ escaped in
+// double-quoted strings; Parse turns them into literal characters.
+// Results are cached, so strings evaluated in a loop are parsed once.
+func Parse(s string) []Segment {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if segs, ok := cache[s]; ok {
+		return segs
+	}
+	segs := parse(s)
+	cache[s] = segs
+	return segs
+}
+
+// Unescape returns s with interpolation escapes removed, for strings that
+// are used as they are (no variables inside).
+func Unescape(s string) string {
+	var sb strings.Builder
+	for _, seg := range Parse(s) {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
+
+func parse(s string) []Segment {
+	var segs []Segment
+	var text strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && strings.IndexByte(`\$@`, s[i+1]) >= 0 {
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		if c == '$' || c == '@' {
+			if expr, end, list := scanExpr(s, i); expr != nil {
+				if text.Len() > 0 {
+					segs = append(segs, Segment{Text: text.String()})
+					text.Reset()
+				}
+				segs = append(segs, Segment{Expr: expr, List: list})
+				i = end
+				continue
+			}
+		}
+		text.WriteByte(c)
+		i++
+	}
+	if text.Len() > 0 {
+		segs = append(segs, Segment{Text: text.String()})
+	}
+	return segs
+}
+
+// scanExpr reads the variable expression starting at s[i] ('$' or '@').
+// It returns nil if the sigil is plain text ("cost: $ 5", "a@", "$)").
+func scanExpr(s string, i int) (ast.Expression, int, bool) {
+	j := i + 1
+	if j >= len(s) {
+		return nil, 0, false
+	}
+	if s[i] == '@' {
+		return scanList(s, j)
+	}
+
+	switch c := s[j]; {
+	case c == '{':
+		// ${name} or ${ expr }
+		k := matching(s, j)
+		if k < 0 {
+			return nil, 0, false
+		}
+		inner := strings.TrimSpace(s[j+1 : k])
+		if isName(inner) {
+			return scanChain(s, "$"+inner, k+1)
+		}
+		// ${$r}[0] is $r->[0]
+		if chain, end := subscripts(s, k+1); chain != "" {
+			return single("("+inner+")->"+strings.TrimPrefix(chain, "->"), end)
+		}
+		value := parseExpr(inner)
+		if value == nil {
+			return nil, 0, false
+		}
+		return &ast.DerefExpr{Token: token(s[i : k+1]), Sigil: "$", Value: value}, k + 1, false
+	case c == '$':
+		// $$ref (a bare $$ stays text)
+		k := j
+		for k < len(s) && s[k] == '$' {
+			k++
+		}
+		end := nameEnd(s, k)
+		if end == k {
+			return nil, 0, false
+		}
+		return scanChain(s, s[i:end], end)
+	case c >= '0' && c <= '9':
+		k := j
+		for k < len(s) && s[k] >= '0' && s[k] <= '9' {
+			k++
+		}
+		return single(s[i:k], k)
+	case c == '@' && strings.HasPrefix(s[j+1:], "->"):
+		// $@->{code} of an exception object; without the arrow "$@[0]"
+		// stays $@ and the text "[0]"
+		return scanChain(s, "$@", j+1)
+	case c == '&' || c == '@' || c == '!' || c == '`' || c == '\'' || c == '|' || c == ',' || c == '/' || c == '?':
+		return single(s[i:j+1], j+1)
+	case c == '~' || c == '%' || c == '=' || c == '-' && (j+1 == len(s) || !strings.ContainsRune("[{>", rune(s[j+1]))):
+		// $~ $% $= $- of formats
+		return single(s[i:j+1], j+1)
+	case c == '^' && j+1 < len(s) && s[j+1] >= 'A' && s[j+1] <= 'Z':
+		// $^V
+		return single(s[i:j+2], j+2)
+	case c == '+':
+		// $+ and named captures $+{name}
+		return scanChain(s, "$+", j+1)
+	case c == '-' && j+1 < len(s) && s[j+1] == '{':
+		// $-{name}[0]
+		return scanChain(s, "$-", j+1)
+	case c == ':' && strings.HasPrefix(s[j:], "::") && nameEnd(s, j+2) > j+2:
+		// $::name is $main::name
+		end := nameEnd(s, j+2)
+		return scanChain(s, s[i:end], end)
+	default:
+		end := nameEnd(s, j)
+		if end == j {
+			return nil, 0, false
+		}
+		return scanChain(s, s[i:end], end)
+	}
+}
+
+// scanList reads the @-forms; j points after the '@'
+func scanList(s string, j int) (ast.Expression, int, bool) {
+	var expr ast.Expression
+	end := j
+	switch c := s[j]; {
+	case c == '{':
+		k := matching(s, j)
+		if k < 0 {
+			return nil, 0, false
+		}
+		end = k + 1
+		inner := strings.TrimSpace(s[j+1 : k])
+		if isName(inner) {
+			// "@{a}[0]" is @a followed by the text "[0]"
+			expr = parseExpr("@" + inner)
+			return expr, end, expr != nil
+		}
+		value := parseExpr(inner)
+		if value == nil {
+			return nil, 0, false
+		}
+		expr = &ast.DerefExpr{Token: token(s[j-1 : end]), Sigil: "@", Value: value}
+	case c == '$':
+		end = nameEnd(s, j+1)
+		if end == j+1 {
+			return nil, 0, false
+		}
+		value := parseExpr(s[j:end])
+		if value == nil {
+			return nil, 0, false
+		}
+		expr = &ast.DerefExpr{Token: token(s[j-1 : end]), Sigil: "@", Value: value}
+	default:
+		end = nameEnd(s, j)
+		if end == j {
+			return nil, 0, false
+		}
+		expr = parseExpr(s[j-1 : end])
+	}
+	if expr == nil {
+		return nil, 0, false
+	}
+	// @a[0, 1] @h{qw(x y)} @{$r}[0] are slices
+	if end < len(s) && (s[end] == '[' || s[end] == '{') {
+		k := matching(s, end)
+		if k > 0 && strings.TrimSpace(s[end+1:k]) != "" {
+			if slice := parseExpr(s[j-1 : k+1]); slice != nil {
+				return slice, k + 1, true
+			}
+		}
+	}
+	return expr, end, true
+}
+
+// scanChain extends a scalar with subscripts: [..], {..}, ->[..], ->{..}
+func scanChain(s, src string, k int) (ast.Expression, int, bool) {
+	chain, end := subscripts(s, k)
+	return single(src+chain, end)
+}
+
+// subscripts returns the subscript chain starting at s[k] and its end
+func subscripts(s string, k int) (string, int) {
+	start := k
+	for k < len(s) {
+		next := k
+		if strings.HasPrefix(s[k:], "->") && k+2 < len(s) && (s[k+2] == '[' || s[k+2] == '{') {
+			next += 2
+		}
+		if s[next] != '[' && s[next] != '{' {
+			break
+		}
+		end := matching(s, next)
+		if end < 0 || strings.TrimSpace(s[next+1:end]) == "" {
+			break
+		}
+		k = end + 1
+	}
+	return s[start:k], k
+}
+
+func single(src string, end int) (ast.Expression, int, bool) {
+	expr := parseExpr(src)
+	if expr == nil {
+		return nil, 0, false
+	}
+	return expr, end, false
+}
+
+// parseExpr parses src as a single expression with the regular parser
+func parseExpr(src string) ast.Expression {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 || len(program.Statements) != 1 {
+		return nil
+	}
+	stmt, ok := program.Statements[0].(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	return stmt.Expression
+}
+
+// matching returns the index of the bracket closing s[open], or -1
+func matching(s string, open int) int {
+	closer := map[byte]byte{'[': ']', '{': '}', '(': ')'}[s[open]]
+	depth := 0
+	for k := open; k < len(s); k++ {
+		switch s[k] {
+		case s[open]:
+			depth++
+		case closer:
+			depth--
+			if depth == 0 {
+				return k
+			}
+		}
+	}
+	return -1
+}
+
+// nameEnd returns the end of an identifier (with :: package separators)
+// starting at s[k], or k if there is none
+func nameEnd(s string, k int) int {
+	if k >= len(s) || !(isAlpha(s[k]) || s[k] == '_') {
+		return k
+	}
+	for k < len(s) {
+		switch {
+		case isAlpha(s[k]) || isDigit(s[k]) || s[k] == '_':
+			k++
+		case strings.HasPrefix(s[k:], "::") && k+2 < len(s) && (isAlpha(s[k+2]) || s[k+2] == '_'):
+			k += 2
+		default:
+			return k
+		}
+	}
+	return k
+}
+
+func isName(s string) bool {
+	return s != "" && nameEnd(s, 0) == len(s)
+}
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func token(src string) lexer.Token {
+	return lexer.Token{Type: lexer.TokString, Value: src}
+}
@@ -0,0 +1,112 @@
+package interpolate
+
+import (
+	"testing"
+
+	"perlc/pkg/ast"
+)
+
+// describe renders segments as "text", <expr> and @<expr> for comparison
+func describe(segs []Segment) []string {
+	var out []string
+	for _, seg := range segs {
+		switch {
+		case seg.Expr == nil:
+			out = append(out, `"`+seg.Text+`"`)
+		case seg.List:
+			out = append(out, "@<"+seg.Expr.String()+">")
+		default:
+			out = append(out, "<"+seg.Expr.String()+">")
+		}
+	}
+	return out
+}
+
+func TestParseSegments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int // number of segments
+		exprs    int // of which expressions
+	}{
+		{"plain text", 1, 0},
+		{"Hello, $name!", 3, 1},
+		{"$a[0] $h{key} $h{$k}", 5, 3},
+		{"$obj->{list}[1]", 1, 1},
+		{"$r->[0]{x}->[2] done", 2, 1},
+		{"${name}s", 2, 1},
+		{"$$r $$r[0] ${$r}[1]", 5, 3},
+		{"@a @{$r} @$r @{[ 1 + 2 ]}", 7, 4},
+		{"@a[0, 1] @h{qw(x y)} @$r[1]", 5, 3},
+		{"@{a}[0]", 2, 1},
+		{"${\\ $x}", 1, 1},
+		{"$1 and $@", 3, 2},
+		{"[$`|$&|$'] $+{year}", 8, 4},
+		{"$Foo::bar", 1, 1},
+		{"$::x and $::y[0]", 3, 2},
+		{"code $@->{code}: $@->[0]{msg}", 4, 2},
+		{"$@->method", 2, 1},
+	}
+
+	for _, tt := range tests {
+		segs := Parse(tt.input)
+		exprs := 0
+		for _, seg := range segs {
+			if seg.Expr != nil {
+				exprs++
+			}
+		}
+		if len(segs) != tt.expected || exprs != tt.exprs {
+			t.Errorf("Parse(%q) = %v, want %d segments with %d expressions",
+				tt.input, describe(segs), tt.expected, tt.exprs)
+		}
+	}
+}
+
+func TestParseLiteralText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`cost \$5`, "cost $5"},
+		{`user\@example.com`, "user@example.com"},
+		{`back\\slash`, `back\slash`},
+		{"a $ b", "a $ b"},
+		{"trailing $", "trailing $"},
+		{"100%", "100%"},
+		{"@ alone", "@ alone"},
+	}
+
+	for _, tt := range tests {
+		segs := Parse(tt.input)
+		if len(segs) != 1 || segs[0].Expr != nil || segs[0].Text != tt.expected {
+			t.Errorf("Parse(%q) = %v, want literal %q", tt.input, describe(segs), tt.expected)
+		}
+		if got := Unescape(tt.input); got != tt.expected {
+			t.Errorf("Unescape(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseExpressionKinds(t *testing.T) {
+	segs := Parse("$obj->method and @{[ $obj->method ]}")
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %v", describe(segs))
+	}
+	if _, ok := segs[0].Expr.(*ast.ScalarVar); !ok {
+		t.Errorf("method calls are not interpolated, got %T", segs[0].Expr)
+	}
+	if segs[1].Text != "->method and " {
+		t.Errorf("expected literal \"->method and \", got %q", segs[1].Text)
+	}
+	deref, ok := segs[2].Expr.(*ast.DerefExpr)
+	if !ok || deref.Sigil != "@" || !segs[2].List {
+		t.Fatalf("expected list dereference, got %T", segs[2].Expr)
+	}
+	if _, ok := deref.Value.(*ast.ArrayExpr); !ok {
+		t.Errorf("expected anonymous array inside @{[ ]}, got %T", deref.Value)
+	}
+
+	if ref, ok := Parse(`${\ $x}`)[0].Expr.(*ast.DerefExpr); !ok || ref.Sigil != "$" {
+		t.Errorf("expected scalar dereference for ${\\ expr}")
+	}
+}
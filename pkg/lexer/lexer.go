@@ -1,10 +1,14 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"perlc/pkg/perlstr"
 )
 
 // Lexer tokenizes Perl source code.
@@ -21,6 +25,8 @@ type Lexer struct {
 	// Context for disambiguation
 	// Belirsizlik giderme için bağlam
 	lastToken TokenType // Previous token type / Önceki token türü
+
+	src *bufio.Reader // Rest of a streamed source, nil once read / Akış kaynağının geri kalanı
 }
 
 // New creates a new lexer for the given input.
@@ -44,10 +50,44 @@ func NewFile(input, filename string) *Lexer {
 	return l
 }
 
+// NewReader creates a lexer that reads the source from r a line at a time
+// as tokens are needed, keeping only the current line in memory: a program
+// can run while it is still being read.
+// NewReader, kaynağı r'den gerektikçe satır satır okuyan bir lexer oluşturur.
+func NewReader(r io.Reader, filename string) *Lexer {
+	l := &Lexer{
+		file: filename,
+		line: 1,
+		src:  bufio.NewReader(r),
+	}
+	l.readChar()
+	return l
+}
+
+// fill appends the next line of a streamed source to input and drops the
+// text before the current character. It reports whether there was more.
+// fill, akış kaynağının sonraki satırını input'a ekler.
+func (l *Lexer) fill() bool {
+	if l.src == nil {
+		return false
+	}
+	line, err := l.src.ReadString('\n')
+	if err != nil {
+		l.src = nil
+	}
+	if line == "" {
+		return false
+	}
+	l.input = l.input[l.pos:] + line
+	l.readPos -= l.pos
+	l.pos = 0
+	return true
+}
+
 // readChar advances to the next character.
 // readChar, sonraki karaktere ilerler.
 func (l *Lexer) readChar() {
-	if l.readPos >= len(l.input) {
+	if l.readPos >= len(l.input) && !l.fill() {
 		l.ch = 0 // EOF
 	} else {
 		l.ch, _ = utf8.DecodeRuneInString(l.input[l.readPos:])
@@ -65,7 +105,7 @@ func (l *Lexer) readChar() {
 // peekChar returns next character without advancing.
 // peekChar, ilerlemeden sonraki karakteri döndürür.
 func (l *Lexer) peekChar() rune {
-	if l.readPos >= len(l.input) {
+	if l.readPos >= len(l.input) && !l.fill() {
 		return 0
 	}
 	ch, _ := utf8.DecodeRuneInString(l.input[l.readPos:])
@@ -220,8 +260,6 @@ func (l *Lexer) NextToken() Token {
 			tok = l.readNumber()
 		} else if l.ch == 's' && l.peekChar() == '/' {
 			tok = l.readSubst()
-		} else if l.ch == 'm' && l.peekChar() == '/' {
-			tok = l.readMatchOp()
 		} else if isIdentStart(l.ch) {
 			tok = l.readIdentifier()
 		} else {
@@ -312,9 +350,11 @@ func (l *Lexer) readStar() Token {
 func (l *Lexer) readSlash() Token {
 	tok := Token{Line: l.line, Column: l.column, File: l.file}
 
-	// Check for // or //= first (defined-or) - before regex check
-	// Önce // veya //= kontrol et (defined-or) - regex kontrolünden önce
-	if l.peekChar() == '/' {
+	// Check for // or //= first (defined-or) - before regex check.
+	// After split, grep, ( or , it is an empty pattern: split //, $s
+	// Önce // veya //= kontrol et (defined-or) - regex kontrolünden önce.
+	// split, grep, ( veya , sonrasında boş desendir: split //, $s
+	if l.peekChar() == '/' && !l.emptyPatternAllowed() {
 		l.readChar() // consume first /
 		l.readChar() // consume second /
 		if l.ch == '=' {
@@ -359,6 +399,22 @@ func (l *Lexer) readPercent() Token {
 		return tok
 	}
 
+	// %$ref, %{$ref}, ->%* - but $a % $b is modulo
+	// %$ref, %{$ref}, ->%* - ama $a % $b mod işlemidir
+	if !l.operandBefore() {
+		if cast, ok := l.readCast(tok, "%"); ok {
+			return cast
+		}
+		// %+ and %-: the named groups of the last match
+		// %+ ve %-: son eşleşmenin adlandırılmış grupları
+		if l.ch == '+' || l.ch == '-' {
+			tok.Type = TokSpecialVar
+			tok.Value = "%" + string(l.ch)
+			l.readChar()
+			return tok
+		}
+	}
+
 	if l.ch == '=' {
 		tok.Type = TokPercentEq
 		tok.Value = "%="
@@ -481,6 +537,14 @@ func (l *Lexer) readLess() Token {
 			l.readChar()
 		}
 	default:
+		// <*.txt>, <~/logs/*.{log,txt}> - glob in term position
+		if l.expectRegex() {
+			if pattern, ok := l.readGlobPattern(); ok {
+				tok.Type = TokReadLine
+				tok.Value = pattern
+				return tok
+			}
+		}
 		// Check if it's <FH> (bareword filehandle)
 		if isIdentStart(l.ch) {
 			tok.Type = TokReadLine
@@ -501,6 +565,25 @@ func (l *Lexer) readLess() Token {
 	return tok
 }
 
+// readGlobPattern reads the body of <PATTERN> up to '>' when it looks like
+// a file glob (no whitespace, contains a glob or path character).
+// readGlobPattern, dosya glob'una benzeyen <PATTERN> gövdesini okur.
+func (l *Lexer) readGlobPattern() (string, bool) {
+	end := strings.IndexByte(l.input[l.pos:], '>')
+	if end <= 0 {
+		return "", false
+	}
+	body := l.input[l.pos : l.pos+end]
+	if strings.ContainsAny(body, " \t\n<=;") || !strings.ContainsAny(body, "*?[{~/.") {
+		return "", false
+	}
+	for l.ch != '>' {
+		l.readChar()
+	}
+	l.readChar() // skip '>'
+	return body, true
+}
+
 func (l *Lexer) readGreater() Token {
 	tok := Token{Line: l.line, Column: l.column, File: l.file}
 	l.readChar()
@@ -641,11 +724,35 @@ func (l *Lexer) readScalar() Token {
 		tok.Value = "$$"
 		l.readChar()
 		return tok
-	case '_', '@', '!', '?', '"', '/', '\\', '&', '`', '\'', '+', '.', '|', '-', '^', '~', '=', '%', ':':
+	case '^':
+		// $^V, $^O, ... (a caret and a capital letter); $^ alone
+		tok.Type = TokSpecialVar
+		tok.Value = "$^"
+		l.readChar()
+		if l.ch >= 'A' && l.ch <= 'Z' {
+			tok.Value += string(l.ch)
+			l.readChar()
+		}
+		return tok
+	case '_', '@', '!', '?', '"', '/', '\\', '&', '`', '\'', '+', '.', '|', '-', '~', '=', '%', ']', ',':
 		tok.Type = TokSpecialVar
 		tok.Value = "$" + string(l.ch)
 		l.readChar()
 		return tok
+	case ':':
+		// $::name is $main::name; $: alone is a special variable
+		// $::isim, $main::isim demektir; tek başına $: özel değişkendir
+		if l.peekChar() == ':' && l.readPos+1 < len(l.input) && isIdentStart(rune(l.input[l.readPos+1])) {
+			l.readChar()
+			l.readChar()
+			tok.Type = TokScalar
+			tok.Value = "$main::" + l.readIdentName()
+			return tok
+		}
+		tok.Type = TokSpecialVar
+		tok.Value = "$:"
+		l.readChar()
+		return tok
 	case '#':
 		// $#array - array length
 		l.readChar()
@@ -658,7 +765,18 @@ func (l *Lexer) readScalar() Token {
 			tok.Value = "$#"
 		}
 		return tok
+	case '*':
+		// ->$* - postfix dereference
+		// ->$* - sonek referans çözme
+		if cast, ok := l.readCast(tok, "$"); ok {
+			return cast
+		}
 	case '{':
+		// ${ expr } - dereference block
+		// ${ expr } - referans çözme bloğu
+		if cast, ok := l.readCast(tok, "$"); ok {
+			return cast
+		}
 		// ${var} - explicit variable name
 		// ${var} - açık değişken adı
 		l.readChar()
@@ -703,7 +821,17 @@ func (l *Lexer) readArray() Token {
 		tok.Value = "@_"
 		l.readChar()
 		return tok
+	case '$', '*':
+		// @$ref, ->@*
+		if cast, ok := l.readCast(tok, "@"); ok {
+			return cast
+		}
 	case '{':
+		// @{ expr }
+		if cast, ok := l.readCast(tok, "@"); ok {
+			return cast
+		}
+		// @{name}
 		l.readChar()
 		name := l.readIdentName()
 		if l.ch == '}' {
@@ -726,6 +854,66 @@ func (l *Lexer) readArray() Token {
 	return tok
 }
 
+// readCast is called with l.ch just after a sigil. For a dereference
+// (sigil before $ref or a { expr } block, or ->@* after an arrow) it returns
+// a TokCast carrying the sigil; the $ref or block is left for the parser.
+// ${name} and @{name} are plain variables and are not casts.
+// readCast, sigil'den sonra bir referans çözme (TokCast) olup olmadığını belirler.
+func (l *Lexer) readCast(tok Token, sigil string) (Token, bool) {
+	tok.Type = TokCast
+	tok.Value = sigil
+	switch l.ch {
+	case '$':
+		if sigil == "$" {
+			return tok, false
+		}
+		return tok, true
+	case '{':
+		return tok, !l.braceIsName()
+	case '*':
+		if l.lastToken != TokArrow {
+			return tok, false
+		}
+		l.readChar()
+		tok.Value = sigil + "*"
+		return tok, true
+	}
+	return tok, false
+}
+
+// braceIsName reports whether the { at l.ch encloses just a name: ${name}.
+// braceIsName, l.ch'deki { yalnızca bir isim içeriyorsa true döndürür.
+func (l *Lexer) braceIsName() bool {
+	rest := strings.TrimLeft(l.input[l.pos+1:], " \t")
+	end := strings.IndexByte(rest, '}')
+	if end <= 0 {
+		return false
+	}
+	name := strings.TrimSpace(rest[:end])
+	if name == "" || !isIdentStart(rune(name[0])) {
+		return false
+	}
+	for _, ch := range name {
+		if !isIdentChar(ch) && ch != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// operandBefore reports whether the previous token ends an operand, so a
+// following % is the modulo operator: $a %$b, f() %$n. A name or a closing
+// brace comes before a hash: keys %$h, map { ... } %$h.
+// operandBefore, önceki token bir işleneni bitiriyorsa true döndürür.
+func (l *Lexer) operandBefore() bool {
+	switch l.lastToken {
+	case TokScalar, TokArray, TokHash, TokSpecialVar, TokArrayLen, TokInteger, TokFloat,
+		TokString, TokRawString, TokRParen, TokRBracket:
+		return true
+	}
+	return false
+}
+
 // ============================================================
 // String readers
 // String okuyucuları
@@ -733,43 +921,46 @@ func (l *Lexer) readArray() Token {
 
 func (l *Lexer) readDoubleQuotedString() Token {
 	tok := Token{Line: l.line, Column: l.column, File: l.file, Type: TokString}
-	l.readChar() // Skip opening "
+	tok.Value = unescapeDouble(l.readDelimited())
+	return tok
+}
 
+// unescapeDouble processes backslash escapes of a double-quoted string.
+// \\, \$ and \@ are left for pkg/interpolate.
+// unescapeDouble, çift tırnaklı string'in ters eğik çizgi kaçışlarını işler;
+// \\, \$ ve \@ kaçışları pkg/interpolate'e bırakılır.
+func unescapeDouble(s string) string {
 	var sb strings.Builder
-	for l.ch != '"' && l.ch != 0 {
-		if l.ch == '\\' {
-			l.readChar()
-			switch l.ch {
-			case 'n':
-				sb.WriteByte('\n')
-			case 't':
-				sb.WriteByte('\t')
-			case 'r':
-				sb.WriteByte('\r')
-			case '\\':
-				sb.WriteByte('\\')
-			case '"':
-				sb.WriteByte('"')
-			case '$':
-				sb.WriteByte('$')
-			case '@':
-				sb.WriteByte('@')
-			default:
-				sb.WriteByte('\\')
-				sb.WriteRune(l.ch)
-			}
-		} else {
-			sb.WriteRune(l.ch)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '"':
+			sb.WriteByte('"')
+		default:
+			// \\, \$ and \@ stay escaped too, so "\$x" is not a variable
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
 		}
-		l.readChar()
-	}
-
-	if l.ch == '"' {
-		l.readChar() // Skip closing "
 	}
+	return sb.String()
+}
 
-	tok.Value = sb.String()
-	return tok
+// SubstReplacement turns the replacement part of s/// into the body of a
+// double-quoted string for pkg/interpolate: escapes are processed as in
+// "..." and an escaped delimiter (\/) becomes the delimiter itself.
+// SubstReplacement, s/// değiştirme kısmını çift tırnaklı string gövdesine çevirir.
+func SubstReplacement(s string) string {
+	return unescapeDouble(strings.ReplaceAll(s, `\/`, "/"))
 }
 
 func (l *Lexer) readSingleQuotedString() Token {
@@ -798,21 +989,11 @@ func (l *Lexer) readSingleQuotedString() Token {
 	return tok
 }
 
+// readBacktickString reads `command`; the body interpolates like "...".
+// readBacktickString, `komut` okur; gövde "..." gibi değişken yerleştirir.
 func (l *Lexer) readBacktickString() Token {
-	tok := Token{Line: l.line, Column: l.column, File: l.file, Type: TokString}
-	l.readChar() // Skip opening `
-
-	var sb strings.Builder
-	for l.ch != '`' && l.ch != 0 {
-		sb.WriteRune(l.ch)
-		l.readChar()
-	}
-
-	if l.ch == '`' {
-		l.readChar()
-	}
-
-	tok.Value = sb.String()
+	tok := Token{Line: l.line, Column: l.column, File: l.file, Type: TokCommand}
+	tok.Value = unescapeDouble(l.readDelimited())
 	return tok
 }
 
@@ -935,11 +1116,417 @@ func (l *Lexer) readIdentifier() Token {
 	tok := Token{Line: l.line, Column: l.column, File: l.file}
 	name := l.readIdentName()
 
+	switch name {
+	case "q", "qq", "qw", "qr", "qx", "m", "tr", "y":
+		// $obj->q(...) is a method call
+		if l.lastToken != TokArrow && l.atQuoteDelimiter() {
+			return l.readQuoteLike(tok, name)
+		}
+	case "format":
+		if l.atStatementStart() {
+			if format, ok := l.readFormat(tok); ok {
+				return format
+			}
+		}
+	}
+
 	tok.Type = LookupKeyword(name)
 	tok.Value = name
 	return tok
 }
 
+// ============================================================
+// Formats: format NAME = ... .
+// Formatlar: format NAME = ... .
+// ============================================================
+
+// atStatementStart reports whether the previous token ends a statement or
+// opens a block, where "format" begins a declaration rather than a call.
+// atStatementStart, önceki tokenin bir deyimi bitirip bitirmediğini bildirir.
+func (l *Lexer) atStatementStart() bool {
+	switch l.lastToken {
+	case TokEOF, TokNewline, TokSemi, TokLBrace, TokRBrace:
+		return true
+	}
+	return false
+}
+
+// readFormat reads a format declaration after the word "format": an
+// optional name and "=" end the line, and the lines up to one holding only
+// "." are taken as they are. The token value is the name, a newline and
+// the lines; the parser splits them into pictures and arguments.
+// readFormat, "format" kelimesinden sonra bir format bildirimini okur.
+func (l *Lexer) readFormat(tok Token) (Token, bool) {
+	rest := l.input[l.pos:]
+	if n := strings.IndexByte(rest, '\n'); n >= 0 {
+		rest = rest[:n]
+	}
+	head := strings.TrimSpace(rest)
+	if !strings.HasSuffix(head, "=") {
+		return tok, false
+	}
+	name := strings.TrimSpace(strings.TrimSuffix(head, "="))
+	for _, part := range strings.Split(name, "::") {
+		if name != "" && (part == "" || !isIdentStart(rune(part[0])) || strings.IndexFunc(part, func(r rune) bool { return !isIdentChar(r) }) >= 0) {
+			return tok, false
+		}
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	var sb strings.Builder
+	for l.ch != 0 {
+		l.readChar() // skip the newline
+		var line strings.Builder
+		for l.ch != '\n' && l.ch != 0 {
+			line.WriteRune(l.ch)
+			l.readChar()
+		}
+		text := strings.TrimRight(line.String(), "\r")
+		if strings.TrimRight(text, " \t") == "." {
+			break
+		}
+		sb.WriteString(text)
+		sb.WriteByte('\n')
+	}
+	tok.Type = TokFormat
+	tok.Value = name + "\n" + sb.String()
+	return tok, true
+}
+
+// ============================================================
+// Quote-like operators: q qq qw qr qx m
+// Tırnak benzeri operatörler: q qq qw qr qx m
+// ============================================================
+
+// closingDelimiter returns the closing delimiter for open; brackets pair up.
+// closingDelimiter, açılış için kapanış sınırlayıcısını döndürür; parantezler eşleşir.
+func closingDelimiter(open rune) rune {
+	switch open {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	case '{':
+		return '}'
+	case '<':
+		return '>'
+	}
+	return open
+}
+
+// atQuoteDelimiter reports whether a quote-like operator name is followed by
+// a delimiter. Whitespace is allowed only before a bracket (qw (a b)), so that
+// barewords like "q => 1" and $h{q} stay identifiers.
+// atQuoteDelimiter, tırnak benzeri operatör adından sonra sınırlayıcı gelip gelmediğini bildirir.
+func (l *Lexer) atQuoteDelimiter() bool {
+	ch := l.ch
+	if ch == ' ' || ch == '\t' {
+		i := l.pos
+		for i < len(l.input) && (l.input[i] == ' ' || l.input[i] == '\t') {
+			i++
+		}
+		if i >= len(l.input) || !strings.ContainsRune("([{<", rune(l.input[i])) {
+			return false
+		}
+		for l.ch == ' ' || l.ch == '\t' {
+			l.readChar()
+		}
+		return true
+	}
+	if ch == '=' && l.peekChar() == '>' {
+		return false // q => 1
+	}
+	if ch == 0 || isIdentChar(ch) || isSpace(ch) {
+		return false
+	}
+	return !strings.ContainsRune(",;)}", ch)
+}
+
+// readDelimited reads the body between the current opening delimiter and its
+// closing pair. Bracket pairs nest. A backslash before a delimiter is dropped;
+// other escapes are kept for the caller to interpret.
+// readDelimited, açılış sınırlayıcısı ile kapanışı arasındaki gövdeyi okur.
+func (l *Lexer) readDelimited() string {
+	open := l.ch
+	closer := closingDelimiter(open)
+	l.readChar() // skip opening delimiter
+
+	var sb strings.Builder
+	depth := 0
+	for l.ch != 0 {
+		if l.ch == '\\' {
+			l.readChar()
+			if l.ch == 0 {
+				break
+			}
+			if l.ch != open && l.ch != closer {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(l.ch)
+			l.readChar()
+			continue
+		}
+		if l.ch == closer && depth == 0 {
+			break
+		}
+		if open != closer {
+			if l.ch == open {
+				depth++
+			} else if l.ch == closer {
+				depth--
+			}
+		}
+		sb.WriteRune(l.ch)
+		l.readChar()
+	}
+	if l.ch == closer {
+		l.readChar() // skip closing delimiter
+	}
+	return sb.String()
+}
+
+// readQuoteLike reads q//, qq//, qw//, qr//, qx// and m// with any delimiter.
+// readQuoteLike, herhangi bir sınırlayıcı ile q//, qq//, qw//, qr//, qx// ve m// okur.
+func (l *Lexer) readQuoteLike(tok Token, op string) Token {
+	delim := l.ch
+	body := l.readDelimited()
+
+	switch op {
+	case "q":
+		tok.Type = TokRawString
+		tok.Value = strings.ReplaceAll(body, "\\\\", "\\")
+	case "qq":
+		tok.Type = TokString
+		tok.Value = unescapeDouble(body)
+	case "qw":
+		tok.Type = TokQw
+		tok.Value = strings.ReplaceAll(body, "\\\\", "\\")
+	case "qx":
+		tok.Type = TokCommand
+		if delim == '\'' {
+			// qx'...' does not interpolate: escape what "..." would expand
+			// qx'...' yerleştirme yapmaz: "..." içinde açılacakları kaçışla
+			tok.Value = strings.NewReplacer(`\`, `\\`, "$", `\$`, "@", `\@`).Replace(body)
+		} else {
+			tok.Value = unescapeDouble(body)
+		}
+	case "tr", "y":
+		return l.readTrans(tok, delim, body)
+	default: // qr, m
+		tok.Type = TokRegex
+		if op == "qr" {
+			tok.Type = TokQr
+		}
+		if delim != '/' {
+			body = escapeSlashes(body)
+		}
+		var mods strings.Builder
+		for strings.ContainsRune("msixpodualngc", l.ch) && l.ch != 0 {
+			mods.WriteRune(l.ch)
+			l.readChar()
+		}
+		tok.Value = body
+		if mods.Len() > 0 {
+			tok.Value = body + "/" + mods.String()
+		}
+	}
+	return tok
+}
+
+// readTrans reads the replacement list and flags of tr/// (y///) whose search
+// list is already in body. Bracketed forms take a second delimited part:
+// tr[a-z][A-Z]. The token value is "search/replace/flags" like s///.
+// readTrans, arama listesi body'de olan tr/// (y///) için değiştirme listesini ve bayrakları okur.
+func (l *Lexer) readTrans(tok Token, delim rune, body string) Token {
+	var replace string
+	if closingDelimiter(delim) != delim {
+		for isSpace(l.ch) {
+			l.readChar()
+		}
+		replace = l.readDelimited()
+	} else {
+		var sb strings.Builder
+		for l.ch != delim && l.ch != 0 {
+			if l.ch == '\\' {
+				l.readChar()
+				if l.ch != delim {
+					sb.WriteByte('\\')
+				}
+			}
+			sb.WriteRune(l.ch)
+			l.readChar()
+		}
+		l.readChar() // skip closing delimiter
+		replace = sb.String()
+	}
+
+	var flags strings.Builder
+	for strings.ContainsRune("cdsr", l.ch) && l.ch != 0 {
+		flags.WriteRune(l.ch)
+		l.readChar()
+	}
+	tok.Type = TokTrans
+	tok.Value = escapeSlashes(body) + "/" + escapeSlashes(replace) + "/" + flags.String()
+	return tok
+}
+
+// TrList expands a tr/// search or replacement list; see perlstr.TrList.
+// TrList, tr/// arama veya değiştirme listesini açar: kaçışlar ve a-z gibi aralıklar.
+func TrList(s string) []rune {
+	return perlstr.TrList(s)
+}
+
+// escapeSlashes escapes bare "/" so a pattern read with other delimiters
+// keeps the "pattern/flags" token format.
+// escapeSlashes, çıplak "/" karakterlerini kaçışlar.
+func escapeSlashes(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			sb.WriteByte(pattern[i])
+			i++
+			sb.WriteByte(pattern[i])
+			continue
+		}
+		if pattern[i] == '/' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(pattern[i])
+	}
+	return sb.String()
+}
+
+// PatternSegment is a piece of a regex pattern: literal text or an
+// interpolated scalar. Quoted segments come from \Q...\E and must match
+// literally once their value is known.
+// PatternSegment, regex deseninin bir parçasıdır: literal metin veya enterpolasyonlu skaler.
+type PatternSegment struct {
+	Text   string
+	Var    string // scalar name without $ / $ olmadan skaler adı
+	Quoted bool
+}
+
+// SplitPattern splits a regex pattern into literal text and interpolated
+// scalars ($name, ${name}); escapes are kept as written. A $ that is not
+// followed by a name ($, $), $|) stays an anchor.
+// SplitPattern, regex desenini literal metin ve enterpolasyonlu skalerlere böler.
+func SplitPattern(pattern string) []PatternSegment {
+	var segs []PatternSegment
+	var text strings.Builder
+	quoted := false
+	flush := func() {
+		if text.Len() > 0 {
+			segs = append(segs, PatternSegment{Text: text.String(), Quoted: quoted})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '\\' && i+1 < len(pattern) {
+			i++
+			switch next := pattern[i]; {
+			case next == 'Q':
+				flush()
+				quoted = true
+			case next == 'E':
+				flush()
+				quoted = false
+			case quoted && !isIdentChar(rune(next)):
+				text.WriteByte(next) // \Q\/ - the slash itself is quoted later
+			default:
+				text.WriteByte(c)
+				text.WriteByte(next)
+			}
+			continue
+		}
+		if c == '$' && i+1 < len(pattern) {
+			if name, n := patternVarName(pattern[i+1:]); name != "" {
+				flush()
+				segs = append(segs, PatternSegment{Var: name, Quoted: quoted})
+				i += n
+				continue
+			}
+		}
+		text.WriteByte(c)
+	}
+	flush()
+	return segs
+}
+
+// patternVarName reads "name" or "{name}" after a $ and returns the name and
+// the number of bytes consumed, or "" if no variable starts there.
+// patternVarName, $ sonrasındaki "name" veya "{name}" okur.
+func patternVarName(s string) (string, int) {
+	if s[0] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 2 {
+			return "", 0
+		}
+		name := s[1:end]
+		for _, ch := range name {
+			if !isIdentChar(ch) && ch != ':' {
+				return "", 0
+			}
+		}
+		return name, end + 1
+	}
+	if !isIdentStart(rune(s[0])) {
+		return "", 0
+	}
+	n := 0
+	for n < len(s) && (isIdentChar(rune(s[n])) || (s[n] == ':' && n+2 < len(s) && s[n+1] == ':' && isIdentStart(rune(s[n+2])))) {
+		if s[n] == ':' {
+			n++
+		}
+		n++
+	}
+	return s[:n], n
+}
+
+// StripExtended removes the whitespace and #-comments of an /x pattern.
+// Escaped characters (\ , \#) and everything inside [...] classes are kept.
+// StripExtended, /x deseninin boşluklarını ve #-yorumlarını kaldırır.
+func StripExtended(pattern string) string {
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			sb.WriteByte(c)
+			i++
+			sb.WriteByte(pattern[i])
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			sb.WriteByte(c)
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+			// []...] and [^]...] start with a literal ]
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+				sb.WriteByte('^')
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+				sb.WriteByte(']')
+			}
+		case c == '#':
+			for i+1 < len(pattern) && pattern[i+1] != '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
 func (l *Lexer) readIdentName() string {
 	var sb strings.Builder
 	for isIdentChar(l.ch) {
@@ -995,7 +1582,7 @@ func (l *Lexer) readRegex(delim rune) Token {
 	// Read modifiers
 	// Değiştiricileri oku
 	var mods strings.Builder
-	for l.ch == 'i' || l.ch == 'm' || l.ch == 's' || l.ch == 'x' || l.ch == 'g' || l.ch == 'o' {
+	for strings.ContainsRune("imsxgoc", l.ch) && l.ch != 0 {
 		mods.WriteRune(l.ch)
 		l.readChar()
 	}
@@ -1016,7 +1603,19 @@ func (l *Lexer) expectRegex() bool {
 	case TokEOF, TokNewline, TokSemi, TokLParen, TokLBracket, TokLBrace,
 		TokComma, TokAssign, TokMatch, TokNotMatch, TokAnd, TokOr,
 		TokNot, TokQuestion, TokColon, TokIf, TokUnless, TokWhile,
-		TokUntil, TokFor, TokForeach, TokAndWord, TokOrWord, TokNotWord:
+		TokUntil, TokFor, TokForeach, TokAndWord, TokOrWord, TokNotWord,
+		TokSplit, TokGrep:
+		return true
+	}
+	return false
+}
+
+// emptyPatternAllowed reports whether // here is an empty pattern, which
+// only makes sense as a split/grep argument.
+// emptyPatternAllowed, buradaki //'nin boş desen olup olmadığını bildirir.
+func (l *Lexer) emptyPatternAllowed() bool {
+	switch l.lastToken {
+	case TokSplit, TokGrep, TokLParen, TokComma:
 		return true
 	}
 	return false
@@ -1064,7 +1663,7 @@ func (l *Lexer) readSubst() Token {
 
 	// Read flags
 	var flags strings.Builder
-	for l.ch == 'g' || l.ch == 'i' || l.ch == 'm' || l.ch == 's' || l.ch == 'x' || l.ch == 'e' {
+	for strings.ContainsRune("gimsxeo", l.ch) && l.ch != 0 {
 		flags.WriteRune(l.ch)
 		l.readChar()
 	}
@@ -1074,12 +1673,6 @@ func (l *Lexer) readSubst() Token {
 	return tok
 }
 
-func (l *Lexer) readMatchOp() Token {
-	//tok := Token{Line: l.line, Column: l.column, File: l.file, Type: TokRegex}
-	l.readChar() // skip 'm'
-	return l.readRegex(l.ch)
-}
-
 // ============================================================
 // Helper functions
 // Yardımcı fonksiyonlar
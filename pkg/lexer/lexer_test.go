@@ -494,9 +494,10 @@ func TestDoubleQuotedStrings(t *testing.T) {
 		{`"line1\nline2"`, "line1\nline2"},
 		{`"tab\there"`, "tab\there"},
 		{`"quote\"here"`, `quote"here`},
-		{`"back\\slash"`, `back\slash`},
-		{`"dollar\$var"`, `dollar$var`},
-		{`"at\@arr"`, `at@arr`},
+		// \\, \$ and \@ are unescaped by pkg/interpolate
+		{`"back\\slash"`, `back\\slash`},
+		{`"dollar\$var"`, `dollar\$var`},
+		{`"at\@arr"`, `at\@arr`},
 	}
 
 	for _, tt := range tests {
@@ -544,15 +545,15 @@ func TestSingleQuotedStrings(t *testing.T) {
 // TestBacktickStrings tests backtick strings.
 // TestBacktickStrings, backtick stringleri test eder.
 func TestBacktickStrings(t *testing.T) {
-	input := "`ls -la`"
+	input := "`ls -la $dir\\n \\``"
 	l := New(input)
 	tok := l.NextToken()
 
-	if tok.Type != TokString {
-		t.Errorf("wrong type. expected=TokString, got=%v", tok.Type)
+	if tok.Type != TokCommand {
+		t.Errorf("wrong type. expected=TokCommand, got=%v", tok.Type)
 	}
-	if tok.Value != "ls -la" {
-		t.Errorf("wrong value. expected=%q, got=%q", "ls -la", tok.Value)
+	if tok.Value != "ls -la $dir\n `" {
+		t.Errorf("wrong value. expected=%q, got=%q", "ls -la $dir\n `", tok.Value)
 	}
 }
 
@@ -686,6 +687,42 @@ func TestArrayLength(t *testing.T) {
 	}
 }
 
+// TestCasts tests dereference sigils: @$ref, %{...}, ->@*.
+// TestCasts, referans çözme sigil'lerini test eder: @$ref, %{...}, ->@*.
+func TestCasts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{"@$r", []TokenType{TokCast, TokScalar}},
+		{"@{$r}", []TokenType{TokCast, TokLBrace, TokScalar, TokRBrace}},
+		{"%$h", []TokenType{TokCast, TokScalar}},
+		{"dump %{$h}", []TokenType{TokIdent, TokCast, TokLBrace, TokScalar, TokRBrace}},
+		{"${$r}", []TokenType{TokCast, TokLBrace, TokScalar, TokRBrace}},
+		{"$r->@*", []TokenType{TokScalar, TokArrow, TokCast}},
+		{"$r->%*", []TokenType{TokScalar, TokArrow, TokCast}},
+		{"${name}", []TokenType{TokScalar}},
+		{"@{name}", []TokenType{TokArray}},
+		{"$a %$b", []TokenType{TokScalar, TokPercent, TokScalar}},
+		{"2 * @a", []TokenType{TokInteger, TokStar, TokArray}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Errorf("input %q - token %d wrong type. expected=%v, got=%v (%q)",
+					tt.input, i, expected, tok.Type, tok.Value)
+				break
+			}
+		}
+		if tok := l.NextToken(); tok.Type != TokEOF {
+			t.Errorf("input %q - expected EOF, got=%v (%q)", tt.input, tok.Type, tok.Value)
+		}
+	}
+}
+
 // TestSpecialVariables tests special variables.
 // TestSpecialVariables, özel değişkenleri test eder.
 func TestSpecialVariables(t *testing.T) {
@@ -739,6 +776,8 @@ func TestPackageVariables(t *testing.T) {
 		{"$Foo::bar", "$Foo::bar"},
 		{"@Foo::Bar::arr", "@Foo::Bar::arr"},
 		{"%A::B::C::hash", "%A::B::C::hash"},
+		{"$::x", "$main::x"},
+		{"$: ", "$:"},
 	}
 
 	for _, tt := range tests {
@@ -793,6 +832,7 @@ func TestKeywords(t *testing.T) {
 		{"warn", TokWarn},
 		{"print", TokPrint},
 		{"say", TokSay},
+		{"printf", TokPrintf},
 		{"defined", TokDefined},
 		{"undef", TokUndef},
 		{"ref", TokRef},
@@ -896,6 +936,180 @@ func TestRegexWithEscapes(t *testing.T) {
 	}
 }
 
+// TestGlobPattern tests <PATTERN> file globs versus comparisons.
+// TestGlobPattern, <PATTERN> dosya glob'larını karşılaştırmalardan ayırt etmeyi test eder.
+func TestGlobPattern(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  TokenType
+		expectedValue string
+	}{
+		{`= <*.txt>`, TokReadLine, "*.txt"},
+		{`= <~/logs/*.{log,txt}>`, TokReadLine, "~/logs/*.{log,txt}"},
+		{`= <STDIN>`, TokReadLine, "STDIN"},
+		{`$x <.5`, TokLt, "<"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.NextToken() // = or $x
+
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q: wrong type. expected=%v, got=%v", tt.input, tt.expectedType, tok.Type)
+		}
+		if tok.Value != tt.expectedValue {
+			t.Errorf("%q: wrong value. expected=%q, got=%q", tt.input, tt.expectedValue, tok.Value)
+		}
+	}
+}
+
+// TestQuoteLike tests q, qq, qw, qr and m with arbitrary delimiters.
+// TestQuoteLike, q, qq, qw, qr ve m operatörlerini farklı ayraçlarla test eder.
+func TestQuoteLike(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  TokenType
+		expectedValue string
+	}{
+		{`q(it's $x)`, TokRawString, "it's $x"},
+		{`q{a {nested} b}`, TokRawString, "a {nested} b"},
+		{`q!a\!b!`, TokRawString, "a!b"},
+		{`qq{Hello\t$name}`, TokString, "Hello\t$name"},
+		{`qq|a\|b|`, TokString, "a|b"},
+		{`qw(a b  c)`, TokQw, "a b  c"},
+		{`qw[x y]`, TokQw, "x y"},
+		{`qr/ab+c/i`, TokQr, "ab+c/i"},
+		{`qr{a/b}`, TokQr, `a\/b`},
+		{`m{a/b}x`, TokRegex, `a\/b/x`},
+		{`m!^/tmp!`, TokRegex, `^\/tmp`},
+		{`q => 1`, TokIdent, "q"},
+		{`tr/abc/xyz/`, TokTrans, "abc/xyz/"},
+		{`tr{a/b}{c}d`, TokTrans, `a\/b/c/d`},
+		{`y/\//_/`, TokTrans, `\//_/`},
+		{`y => 2`, TokIdent, "y"},
+		{`qx{ls $dir}`, TokCommand, "ls $dir"},
+		{`qx'echo $HOME @x'`, TokCommand, `echo \$HOME \@x`},
+		{`qx => 3`, TokIdent, "qx"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q: wrong type. expected=%v, got=%v", tt.input, tt.expectedType, tok.Type)
+		}
+		if tok.Value != tt.expectedValue {
+			t.Errorf("%q: wrong value. expected=%q, got=%q", tt.input, tt.expectedValue, tok.Value)
+		}
+	}
+}
+
+// TestSplitPattern tests splitting regex patterns into text and variables.
+// TestSplitPattern, regex desenlerinin metin ve değişkenlere bölünmesini test eder.
+func TestSplitPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected []PatternSegment
+	}{
+		{`^abc$`, []PatternSegment{{Text: `^abc$`}}},
+		{`$prefix\d+`, []PatternSegment{{Var: "prefix"}, {Text: `\d+`}}},
+		{`^a${re}c$`, []PatternSegment{{Text: "^a"}, {Var: "re"}, {Text: "c$"}}},
+		{`(x$)|$Pkg::name`, []PatternSegment{{Text: "(x$)|"}, {Var: "Pkg::name"}}},
+		{`\$x`, []PatternSegment{{Text: `\$x`}}},
+		{`^\Q$dot.\/\E$`, []PatternSegment{{Text: "^"}, {Var: "dot", Quoted: true}, {Text: "./", Quoted: true}, {Text: "$"}}},
+	}
+
+	for _, tt := range tests {
+		got := SplitPattern(tt.pattern)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: expected %d segments, got %v", tt.pattern, len(tt.expected), got)
+			continue
+		}
+		for n := range got {
+			if got[n] != tt.expected[n] {
+				t.Errorf("%q: segment %d = %+v, want %+v", tt.pattern, n, got[n], tt.expected[n])
+			}
+		}
+	}
+}
+
+// TestStripExtended tests removing /x whitespace and comments.
+// TestStripExtended, /x boşluk ve yorumlarının kaldırılmasını test eder.
+func TestStripExtended(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"a b  c", "abc"},
+		{"(\\d+) # digits\n - (\\w)", `(\d+)-(\w)`},
+		{`a\ b\#c`, `a\ b\#c`},
+		{"[ #] x", "[ #]x"},
+		{"[] ] x", "[] ]x"},
+		{"[^] ] x", "[^] ]x"},
+	}
+
+	for _, tt := range tests {
+		if got := StripExtended(tt.pattern); got != tt.expected {
+			t.Errorf("StripExtended(%q) = %q, want %q", tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+// TestNamedGroups tests translating Perl named groups to Go syntax.
+// TestNamedGroups, Perl adlandırılmış gruplarının Go sözdizimine çevrilmesini test eder.
+// TestRegexFlagsOC tests that /o and /c are kept as modifiers.
+// TestRegexFlagsOC, /o ve /c değiştiricilerinin korunduğunu test eder.
+func TestRegexFlagsOC(t *testing.T) {
+	l := New(`$s =~ /a/gco; $s =~ s/a/b/gxo;`)
+	l.NextToken()
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != TokRegex || tok.Value != "a/gco" {
+		t.Errorf("expected regex a/gco, got %v %q", tok.Type, tok.Value)
+	}
+	l.NextToken()
+	l.NextToken()
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != TokSubst || tok.Value != "a/b/gxo" {
+		t.Errorf("expected subst a/b/gxo, got %v %q", tok.Type, tok.Value)
+	}
+}
+
+// TestTrList tests tr/// list expansion.
+// TestTrList, tr/// liste açılımını test eder.
+func TestTrList(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected string
+	}{
+		{"a-e", "abcde"},
+		{"a-cx-z", "abcxyz"},
+		{"-a", "-a"},
+		{"a-", "a-"},
+		{`a\-c`, "a-c"},
+		{`\n\t\/`, "\n\t/"},
+		{"0-9A-C", "0123456789ABC"},
+	}
+
+	for _, tt := range tests {
+		if got := string(TrList(tt.list)); got != tt.expected {
+			t.Errorf("TrList(%q) = %q, want %q", tt.list, got, tt.expected)
+		}
+	}
+}
+
+// TestSplitEmptyPattern tests that // after split is a pattern, not defined-or.
+// TestSplitEmptyPattern, split sonrasındaki //'nin desen olduğunu test eder.
+func TestSplitEmptyPattern(t *testing.T) {
+	l := New(`split //, $s; $x // 0`)
+	expected := []TokenType{TokSplit, TokRegex, TokComma, TokScalar, TokSemi, TokScalar, TokDefinedOr, TokInteger}
+	for n, want := range expected {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("token %d: expected %v, got %v (%q)", n, want, tok.Type, tok.Value)
+		}
+	}
+}
+
 // ============================================================
 // Comment Tests
 // Yorum Testleri
@@ -1250,3 +1464,30 @@ func TestLookupKeyword(t *testing.T) {
 		t.Error("'foo' should be TokIdent")
 	}
 }
+
+// TestFormatToken tests that a format declaration is read as one token
+// with its lines taken as they are, and that format elsewhere stays a word.
+// TestFormatToken, format bildiriminin tek token olarak okunmasını test eder.
+func TestFormatToken(t *testing.T) {
+	l := New("format STDOUT =\n@<<< @##.# # not a comment\n$name, $x\n.\nwrite;")
+	tok := l.NextToken()
+	if tok.Type != TokFormat {
+		t.Fatalf("expected TokFormat, got %v", tok.Type)
+	}
+	want := "STDOUT\n@<<< @##.# # not a comment\n$name, $x\n"
+	if tok.Value != want {
+		t.Errorf("format value %q, want %q", tok.Value, want)
+	}
+	for _, typ := range []TokenType{TokNewline, TokWrite, TokSemi} {
+		if tok = l.NextToken(); tok.Type != typ {
+			t.Errorf("after format: got %v, want %v", tok, tokenNames[typ])
+		}
+	}
+
+	l = New("$h{format} = 1; $obj->format(1);")
+	for tok = l.NextToken(); tok.Type != TokEOF; tok = l.NextToken() {
+		if tok.Type == TokFormat {
+			t.Errorf("format read as a declaration in %q", l.input)
+		}
+	}
+}
@@ -17,9 +17,12 @@ const (
 	TokFloat     // 3.14, 6.02e23
 	TokString    // 'single', "double", q(), qq()
 	TokRawString // Raw string (no interpolation)
-	TokRegex     // /pattern/, m//, qr//
+	TokRegex     // /pattern/, m//
+	TokQr        // qr// - compiled regex value
+	TokCommand   // `command`, qx// - run through the shell, output captured
 	TokHeredoc   // <<EOF
 	TokVersion   // v5.36, 5.036
+	TokFormat    // format NAME = ... . - value is "NAME\nPICTURE LINES"
 
 	// Identifiers and keywords
 	TokIdent      // identifier
@@ -172,8 +175,13 @@ const (
 	TokWarn
 	TokPrint
 	TokSay
+	TokPrintf
 	TokOpen
 	TokClose
+	TokSysopen
+	TokBinmode
+	TokFlock
+	TokTruncate
 	TokRead
 	TokDiamond  // <>
 	TokReadLine // <$fh> or <FH>
@@ -249,14 +257,36 @@ const (
 	TokGmtime
 	TokTime
 	TokSleep
+	TokAlarm
 	TokExit
 	TokSystem
 	TokExec
 	TokFork
 	TokWait
+	TokWaitpid
 	TokKill
+	TokGetpwnam
+	TokGetpwuid
+	TokGetgrnam
+	TokGetgrgid
+
+	// File functions
+	TokUnlink
+	TokMkdir
+	TokRmdir
+	TokRename
+	TokChdir
+	TokChmod
+	TokChown
+	TokUtime
+	TokSymlink
+	TokReadlink
+	TokStat
+	TokLstat
 
 	TokSubst // s/pattern/replacement/
+	TokTrans // tr/search/replace/, y///
+	TokCast  // dereference sigil: @$ref, @{...}, %$ref, ${...}, ->@*
 )
 
 // Token represents a lexical token.
@@ -289,7 +319,12 @@ var tokenNames = map[TokenType]string{
 	TokString:    "STRING",
 	TokRawString: "RAWSTRING",
 	TokRegex:     "REGEX",
+	TokQr:        "QR",
+	TokCommand:   "COMMAND",
+	TokTrans:     "TRANS",
+	TokCast:      "CAST",
 	TokHeredoc:   "HEREDOC",
+	TokFormat:    "FORMAT",
 	TokIdent:     "IDENT",
 	TokScalar:    "SCALAR",
 	TokArray:     "ARRAY",
@@ -382,8 +417,13 @@ var keywords = map[string]TokenType{
 	"warn":      TokWarn,
 	"print":     TokPrint,
 	"say":       TokSay,
+	"printf":    TokPrintf,
 	"open":      TokOpen,
 	"close":     TokClose,
+	"sysopen":   TokSysopen,
+	"binmode":   TokBinmode,
+	"flock":     TokFlock,
+	"truncate":  TokTruncate,
 	"read":      TokRead,
 	"write":     TokWrite,
 	"defined":   TokDefined,
@@ -451,12 +491,32 @@ var keywords = map[string]TokenType{
 	"gmtime":    TokGmtime,
 	"time":      TokTime,
 	"sleep":     TokSleep,
+	"alarm":     TokAlarm,
 	"exit":      TokExit,
 	"system":    TokSystem,
 	"exec":      TokExec,
 	"fork":      TokFork,
 	"wait":      TokWait,
+	"waitpid":   TokWaitpid,
 	"kill":      TokKill,
+	"getpwnam":  TokGetpwnam,
+	"getpwuid":  TokGetpwuid,
+	"getgrnam":  TokGetgrnam,
+	"getgrgid":  TokGetgrgid,
+
+	// File functions
+	"unlink":   TokUnlink,
+	"mkdir":    TokMkdir,
+	"rmdir":    TokRmdir,
+	"rename":   TokRename,
+	"chdir":    TokChdir,
+	"chmod":    TokChmod,
+	"chown":    TokChown,
+	"utime":    TokUtime,
+	"symlink":  TokSymlink,
+	"readlink": TokReadlink,
+	"stat":     TokStat,
+	"lstat":    TokLstat,
 }
 
 // LookupKeyword returns the token type for an identifier.
@@ -4,9 +4,11 @@ package parser
 // Paket parser, Pratt ayrıştırma kullanarak Perl ayrıştırmasını uygular.
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"perlc/pkg/ast"
 	"perlc/pkg/lexer"
@@ -112,6 +114,20 @@ type Parser struct {
 
 	curToken  lexer.Token
 	peekToken lexer.Token
+	advance   bool // ParseStatement left curToken on the end of a statement
+
+	// Names imported as list operators, called without parentheses:
+	// croak "msg" after use Carp
+	// Liste operatörü olarak içe aktarılan adlar: use Carp sonrası croak "msg"
+	listOps map[string]bool
+
+	// use strict 'vars': the lexical scopes of the check, the variables of
+	// use vars, and the variables whose my the AST leaves out
+	// use strict 'vars': denetimin kapsamları, use vars değişkenleri ve
+	// AST'nin my'ını atladığı değişkenler
+	strict     *strictScope
+	strictVars map[string]bool
+	declared   map[ast.Expression]bool
 
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
@@ -138,6 +154,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(lexer.TokArray, p.parseArrayVar)
 	p.registerPrefix(lexer.TokHash, p.parseHashVar)
 	p.registerPrefix(lexer.TokCode, p.parseCodeVar)
+	p.registerPrefix(lexer.TokStar, p.parseGlobVar)
+	p.registerPrefix(lexer.TokBitAnd, p.parseCodeDerefCall)
+	p.registerPrefix(lexer.TokCast, p.parseCastExpr)
 	p.registerPrefix(lexer.TokArrayLen, p.parseArrayLengthVar)
 	p.registerPrefix(lexer.TokSpecialVar, p.parseSpecialVar)
 	p.registerPrefix(lexer.TokIdent, p.parseIdentifier)
@@ -147,6 +166,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(lexer.TokLBrace, p.parseHashLiteral)
 	p.registerPrefix(lexer.TokBackslash, p.parseRefExpr)
 	p.registerPrefix(lexer.TokRegex, p.parseRegexLiteral)
+	p.registerPrefix(lexer.TokQr, p.parseQrExpr)
+	p.registerPrefix(lexer.TokQw, p.parseQwExpr)
+	p.registerPrefix(lexer.TokCommand, p.parseCommandExpr)
+	p.registerPrefix(lexer.TokEval, p.parseEvalBlock)
+	p.registerPrefix(lexer.TokDo, p.parseDoBlock)
 	p.registerPrefix(lexer.TokSub, p.parseAnonSub)
 
 	// Prefix operators
@@ -230,6 +254,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(lexer.TokBless, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokPrint, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokSay, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokPrintf, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokWrite, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokDie, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokWarn, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokDefined, p.parseBuiltinCall)
@@ -247,7 +273,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(lexer.TokEach, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokExists, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokDelete, p.parseBuiltinCall)
-	p.registerPrefix(lexer.TokSort, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokSort, p.parseSortExpression)
 	p.registerPrefix(lexer.TokReverse, p.parseBuiltinCall)
 
 	p.registerPrefix(lexer.TokJoin, p.parseBuiltinCall)
@@ -289,15 +315,40 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(lexer.TokGmtime, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokTime, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokSleep, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokAlarm, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokExit, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokSystem, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokExec, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokFork, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokWait, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokWaitpid, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokKill, p.parseBuiltinCall)
-
+	p.registerPrefix(lexer.TokGetpwnam, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokGetpwuid, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokGetgrnam, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokGetgrgid, p.parseBuiltinCall)
+
+	// File builtins
+	p.registerPrefix(lexer.TokUnlink, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokMkdir, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokRmdir, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokRename, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokChdir, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokChmod, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokChown, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokUtime, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokSymlink, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokReadlink, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokStat, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokLstat, p.parseBuiltinCall)
+
+	p.registerPrefix(lexer.TokMy, p.parseMyExpression)
 	p.registerPrefix(lexer.TokOpen, p.parseOpenExpr)
+	p.registerPrefix(lexer.TokSysopen, p.parseOpenExpr)
 	p.registerPrefix(lexer.TokClose, p.parseCloseExpr)
+	p.registerPrefix(lexer.TokBinmode, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokFlock, p.parseBuiltinCall)
+	p.registerPrefix(lexer.TokTruncate, p.parseBuiltinCall)
 	p.registerPrefix(lexer.TokDiamond, p.parseReadLineExpr)
 	p.registerPrefix(lexer.TokReadLine, p.parseReadLineExpr)
 
@@ -345,15 +396,35 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for !p.curTokenIs(lexer.TokEOF) {
+	for stmt := p.ParseStatement(); stmt != nil; stmt = p.ParseStatement() {
+		program.Statements = append(program.Statements, stmt)
+	}
+
+	return program
+}
+
+// ParseStatement parses the next top-level statement, nil at the end of
+// the input. With a lexer from lexer.NewReader a program can be run one
+// statement at a time while it is read; check Errors after each one.
+// ParseStatement, bir sonraki üst düzey deyimi ayrıştırır.
+func (p *Parser) ParseStatement() ast.Statement {
+	for {
+		// moving past a statement reads one more token: wait until the
+		// next one is wanted, so a statement from a stream runs first
+		if p.advance {
+			p.nextToken()
+			p.advance = false
+		}
+		if p.curTokenIs(lexer.TokEOF) {
+			return nil
+		}
 		stmt := p.parseStatement()
+		p.advance = true
 		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+			p.checkStrict(stmt)
+			return stmt
 		}
-		p.nextToken()
 	}
-
-	return program
 }
 
 // ============================================================
@@ -388,6 +459,13 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseForStmt()
 	case lexer.TokForeach:
 		return p.parseForeachStmt()
+	case lexer.TokGiven:
+		return p.parseGivenStmt()
+	case lexer.TokIdent:
+		if p.peekTokenIs(lexer.TokColon) {
+			return p.parseLabelStmt()
+		}
+		return p.parseExpressionStatement()
 	case lexer.TokLast:
 		return p.parseLastStmt()
 	case lexer.TokNext:
@@ -400,6 +478,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseBlockStmt()
 	case lexer.TokBEGIN, lexer.TokEND, lexer.TokCHECK, lexer.TokINIT, lexer.TokUNITCHECK:
 		return p.parseSpecialBlock()
+	case lexer.TokFormat:
+		return p.parseFormatDecl()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -409,44 +489,44 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 	exprStmt := &ast.ExprStmt{Token: p.curToken}
 	exprStmt.Expression = p.parseExpression(LOWEST)
 
-	// Check for statement modifiers: expr if COND, expr unless COND
-	if p.peekTokenIs(lexer.TokIf) {
-		p.nextToken() // consume 'if'
-		p.nextToken() // move to condition
-		cond := p.parseExpression(LOWEST)
-		ifStmt := &ast.IfStmt{
-			Token:     p.curToken,
-			Condition: cond,
-			Then:      &ast.BlockStmt{Statements: []ast.Statement{exprStmt}},
-		}
+	// do { ... } while/until COND: the body runs before the first test
+	// do { ... } while/until COND: gövde ilk sınamadan önce çalışır
+	if do, ok := exprStmt.Expression.(*ast.DoBlockExpr); ok && (p.peekTokenIs(lexer.TokWhile) || p.peekTokenIs(lexer.TokUntil)) {
+		p.nextToken()
+		stmt := &ast.DoStmt{Token: do.Token, Body: do.Body, Until: p.curTokenIs(lexer.TokUntil)}
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
 		if p.peekTokenIs(lexer.TokSemi) {
 			p.nextToken()
 		}
-		return ifStmt
+		return stmt
 	}
 
-	if p.peekTokenIs(lexer.TokUnless) {
-		p.nextToken() // consume 'unless'
+	return p.parseStatementModifiers(exprStmt)
+}
+
+// parseStatementModifiers parses the modifiers of a simple statement:
+// stmt if COND, stmt unless COND, and the optional semicolon.
+// parseStatementModifiers, basit bir deyimin if/unless ekini ve isteğe bağlı noktalı virgülü ayrıştırır.
+func (p *Parser) parseStatementModifiers(stmt ast.Statement) ast.Statement {
+	if p.peekTokenIs(lexer.TokIf) || p.peekTokenIs(lexer.TokUnless) {
+		p.nextToken() // consume 'if' / 'unless'
+		unless := p.curTokenIs(lexer.TokUnless)
 		p.nextToken() // move to condition
 		cond := p.parseExpression(LOWEST)
-		ifStmt := &ast.IfStmt{
+		stmt = &ast.IfStmt{
 			Token:     p.curToken,
 			Condition: cond,
-			Unless:    true,
-			Then:      &ast.BlockStmt{Statements: []ast.Statement{exprStmt}},
+			Unless:    unless,
+			Then:      &ast.BlockStmt{Statements: []ast.Statement{stmt}},
 		}
-		if p.peekTokenIs(lexer.TokSemi) {
-			p.nextToken()
-		}
-		return ifStmt
 	}
 
 	// Optional semicolon
 	if p.peekTokenIs(lexer.TokSemi) {
 		p.nextToken()
 	}
-
-	return exprStmt
+	return stmt
 }
 
 func (p *Parser) parseBlockStmt() *ast.BlockStmt {
@@ -501,6 +581,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Value, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Past int64 the literal is a number like perl's: an unsigned
+		// integer while it fits, a float after that
+		if u, err := strconv.ParseUint(p.curToken.Value, 0, 64); err == nil {
+			return &ast.FloatLiteral{Token: p.curToken, Value: float64(u)}
+		}
+		if f, err := strconv.ParseFloat(p.curToken.Value, 64); err == nil {
+			return &ast.FloatLiteral{Token: p.curToken, Value: f}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("line %d: could not parse %q as integer",
 			p.curToken.Line, p.curToken.Value)
@@ -546,15 +636,53 @@ func (p *Parser) parseRegexLiteral() ast.Expression {
 
 	// Value may contain pattern/flags
 	// Değer pattern/flags içerebilir
-	parts := strings.SplitN(p.curToken.Value, "/", 2)
-	lit.Pattern = parts[0]
-	if len(parts) > 1 {
-		lit.Flags = parts[1]
-	}
+	lit.Pattern, lit.Flags = splitRegexToken(p.curToken.Value)
 
 	return lit
 }
 
+// parseQrExpr parses qr/pattern/flags.
+// parseQrExpr, qr/pattern/flags ayrıştırır.
+func (p *Parser) parseQrExpr() ast.Expression {
+	qr := &ast.QrExpr{Token: p.curToken}
+	qr.Pattern, qr.Flags = splitRegexToken(p.curToken.Value)
+	return qr
+}
+
+// parseCommandExpr parses `command` and qx//.
+// parseCommandExpr, `komut` ve qx// ayrıştırır.
+func (p *Parser) parseCommandExpr() ast.Expression {
+	return &ast.CommandExpr{
+		Token:   p.curToken,
+		Command: &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Value, Interpolated: true},
+	}
+}
+
+// parseQwExpr parses qw(...) into a list of strings.
+// parseQwExpr, qw(...) ifadesini string listesine ayrıştırır.
+func (p *Parser) parseQwExpr() ast.Expression {
+	list := &ast.ArrayExpr{Token: p.curToken, Elements: []ast.Expression{}}
+	for _, word := range strings.Fields(p.curToken.Value) {
+		list.Elements = append(list.Elements, &ast.StringLiteral{Token: p.curToken, Value: word})
+	}
+	return list
+}
+
+// splitRegexToken splits a "pattern/flags" token value at the first
+// unescaped slash.
+// splitRegexToken, "pattern/flags" token değerini ilk kaçışsız eğik çizgide böler.
+func splitRegexToken(value string) (pattern, flags string) {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '/':
+			return value[:i], value[i+1:]
+		}
+	}
+	return value, ""
+}
+
 func (p *Parser) parseUndef() ast.Expression {
 	return &ast.UndefLiteral{Token: p.curToken}
 }
@@ -600,6 +728,70 @@ func (p *Parser) parseCodeVar() ast.Expression {
 	return &ast.CodeVar{Token: p.curToken, Name: name}
 }
 
+// parseGlobVar parses *NAME where a term is expected: \*STDOUT, *STDERR
+// parseGlobVar, terim beklenen yerde *NAME ayrıştırır
+func (p *Parser) parseGlobVar() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(lexer.TokIdent) {
+		return nil
+	}
+	return &ast.GlobVar{Token: tok, Name: strings.TrimPrefix(p.curToken.Value, "main::")}
+}
+
+// parseCastExpr parses sigil dereferences: @$ref, %$ref, $$ref and the
+// block forms @{ expr }, %{ expr }, ${ expr }.
+// parseCastExpr, sigil ile referans çözmeyi ayrıştırır: @$ref, %{ expr } vb.
+func (p *Parser) parseCastExpr() ast.Expression {
+	tok := p.curToken
+	p.nextToken()
+
+	var value ast.Expression
+	switch p.curToken.Type {
+	case lexer.TokLBrace:
+		p.nextToken()
+		value = p.parseExpression(LOWEST)
+		if !p.expectPeek(lexer.TokRBrace) {
+			return nil
+		}
+	case lexer.TokScalar:
+		// Only the variable: @$ref[0] is a slice of @$ref
+		// Yalnızca değişken: @$ref[0], @$ref'in dilimidir
+		value = p.parseScalarVar()
+	case lexer.TokCast:
+		value = p.parseCastExpr()
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("line %d: expected reference after %s", tok.Line, tok.Value))
+		return nil
+	}
+	return &ast.DerefExpr{Token: tok, Sigil: tok.Value, Value: value}
+}
+
+// parseCodeDerefCall parses &$code(args) and &{$code}(args) into the same
+// ArrowAccess call as $code->(args). Without parentheses no arguments are passed.
+// parseCodeDerefCall, &$code(args) ve &{$code}(args) ifadelerini $code->(args) gibi ayrıştırır.
+func (p *Parser) parseCodeDerefCall() ast.Expression {
+	tok := p.curToken
+	p.nextToken()
+
+	var code ast.Expression
+	if p.curTokenIs(lexer.TokLBrace) {
+		p.nextToken()
+		code = p.parseExpression(LOWEST)
+		if !p.expectPeek(lexer.TokRBrace) {
+			return nil
+		}
+	} else {
+		code = p.parseExpression(CALL)
+	}
+
+	call := &ast.CallExpr{Token: tok, Function: code}
+	if p.peekTokenIs(lexer.TokLParen) {
+		p.nextToken()
+		call.Args = p.parseExpressionList(lexer.TokRParen)
+	}
+	return &ast.ArrowAccess{Token: tok, Left: code, Right: call}
+}
+
 func (p *Parser) parseArrayLengthVar() ast.Expression {
 	name := p.curToken.Value
 	name = strings.TrimPrefix(name, "$#")
@@ -611,6 +803,9 @@ func (p *Parser) parseSpecialVar() ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	if p.listOps[p.curToken.Value] && !p.peekTokenIs(lexer.TokFatArrow) {
+		return p.parseBuiltinCall()
+	}
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
 }
 
@@ -753,6 +948,10 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	if !p.expectPeek(lexer.TokRParen) {
 		return nil
 	}
+	// (0) x 30 repeats a list, "0" x 30 a string: keep the parens
+	if p.peekTokenIs(lexer.TokX) {
+		return &ast.ArrayExpr{Token: startToken, Elements: []ast.Expression{exp}}
+	}
 	return exp
 }
 
@@ -778,13 +977,14 @@ func (p *Parser) parseHashLikeList(startToken lexer.Token) ast.Expression {
 		p.nextToken() // move to value
 		elements = append(elements, p.parseExpression(COMMA))
 
-		if p.peekTokenIs(lexer.TokComma) {
-			p.nextToken() // move to ,
-			if p.peekTokenIs(lexer.TokRParen) {
-				break // trailing comma
-			}
-			p.nextToken() // move to next key
+		if !p.peekTokenIs(lexer.TokComma) {
+			break
 		}
+		p.nextToken() // move to ,
+		if p.peekTokenIs(lexer.TokRParen) {
+			break // trailing comma
+		}
+		p.nextToken() // move to next key
 	}
 
 	if !p.expectPeek(lexer.TokRParen) {
@@ -834,6 +1034,30 @@ func (p *Parser) parseHashLikeListWithFirst(startToken lexer.Token, firstKey ast
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	// ${$r}[0] and $$r[0] are $r->[0]
+	// ${$r}[0] ve $$r[0], $r->[0] demektir
+	if deref, ok := left.(*ast.DerefExpr); ok && deref.Sigil == "$" {
+		tok := p.curToken
+		p.nextToken()
+		index := p.parseExpression(LOWEST)
+		if !p.expectPeek(lexer.TokRBracket) {
+			return nil
+		}
+		return &ast.ArrowAccess{Token: tok, Left: deref.Value, Right: &ast.ArrayAccess{Token: p.curToken, Index: index}}
+	}
+
+	// @arr[1, 3] - slice
+	// @arr[1, 3] - dilim
+	if isArrayOperand(left) {
+		slice := &ast.ArraySlice{Token: p.curToken, Array: left}
+		p.nextToken()
+		slice.Indices = p.parseListExpression()
+		if !p.expectPeek(lexer.TokRBracket) {
+			return nil
+		}
+		return slice
+	}
+
 	exp := &ast.ArrayAccess{Token: p.curToken, Array: left}
 	p.nextToken()
 	exp.Index = p.parseExpression(LOWEST)
@@ -844,19 +1068,109 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseHashAccessExpression(left ast.Expression) ast.Expression {
+	// ${$r}{key} and $$r{key} are $r->{key}
+	// ${$r}{key} ve $$r{key}, $r->{key} demektir
+	if deref, ok := left.(*ast.DerefExpr); ok && deref.Sigil == "$" {
+		access := p.parseHashAccessExpression(&ast.ScalarVar{Token: deref.Token})
+		if access, ok := access.(*ast.HashAccess); ok {
+			return &ast.ArrowAccess{Token: access.Token, Left: deref.Value, Right: &ast.HashAccess{Token: access.Token, Key: access.Key}}
+		}
+		return nil
+	}
+
+	// @h{'a', 'b'} - slice of %h, @{$r}{'a', 'b'} - slice of %$r
+	// @h{'a', 'b'} - %h dilimi, @{$r}{'a', 'b'} - %$r dilimi
+	if isArrayOperand(left) {
+		var hash ast.Expression
+		switch arr := left.(type) {
+		case *ast.ArrayVar:
+			hash = &ast.HashVar{Token: arr.Token, Name: arr.Name}
+		case *ast.DerefExpr:
+			hash = &ast.DerefExpr{Token: arr.Token, Sigil: "%", Value: arr.Value}
+		}
+		slice := &ast.HashSlice{Token: p.curToken, Hash: hash}
+		p.nextToken()
+		if p.isBareword() && p.peekTokenIs(lexer.TokRBrace) {
+			slice.Keys = []ast.Expression{&ast.StringLiteral{Token: p.curToken, Value: p.curToken.Value}}
+		} else {
+			slice.Keys = p.parseListExpression()
+		}
+		if !p.expectPeek(lexer.TokRBrace) {
+			return nil
+		}
+		return slice
+	}
+
+	// $+{name} and $-{name} are elements of %+ and %-
+	// $+{ad} ve $-{ad}, %+ ve %- öğeleridir
+	if v, ok := left.(*ast.SpecialVar); ok && (v.Name == "$+" || v.Name == "$-") {
+		left = &ast.SpecialVar{Token: v.Token, Name: "%" + v.Name[1:]}
+	}
 	exp := &ast.HashAccess{Token: p.curToken, Hash: left}
 	p.nextToken()
-	exp.Key = p.parseExpression(LOWEST)
+	// Barewords are autoquoted, including operator words: $h{x}, $h{eq}
+	// Çıplak kelimeler tırnaklanır, operatör kelimeleri dahil: $h{x}, $h{eq}
+	if p.isBareword() && p.peekTokenIs(lexer.TokRBrace) {
+		exp.Key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Value}
+	} else {
+		exp.Key = p.parseExpression(LOWEST)
+	}
 	if !p.expectPeek(lexer.TokRBrace) {
 		return nil
 	}
 	return exp
 }
 
+// isArrayOperand reports whether a subscript after expr makes a slice:
+// @arr[...], @$ref[...], @{$ref}{...}
+// isArrayOperand, ardından gelen indisin dilim olup olmadığını bildirir.
+func isArrayOperand(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.ArrayVar:
+		return true
+	case *ast.DerefExpr:
+		return e.Sigil == "@"
+	}
+	return false
+}
+
 func (p *Parser) parseArrowExpression(left ast.Expression) ast.Expression {
 	token := p.curToken
 	p.nextToken()
 
+	// ->method or ->method(); a keyword is a method name here ($ch->close)
+	// ->method veya ->method(); burada anahtar kelime de metot adıdır
+	if p.isBareword() {
+		method := p.curToken.Value
+		if p.peekTokenIs(lexer.TokLParen) {
+			p.nextToken()
+			args := p.parseExpressionList(lexer.TokRParen)
+			return &ast.MethodCall{
+				Token:  token,
+				Object: left,
+				Method: method,
+				Args:   args,
+			}
+		}
+		return &ast.MethodCall{
+			Token:  token,
+			Object: left,
+			Method: method,
+			Args:   nil,
+		}
+	}
+
+	// ->$name or ->$name(): the method name or code ref is in a scalar
+	// ->$name veya ->$name(): metot adı veya kod referansı bir skalerde
+	if p.curTokenIs(lexer.TokScalar) {
+		call := &ast.MethodCall{Token: token, Object: left, Dynamic: p.parseScalarVar()}
+		if p.peekTokenIs(lexer.TokLParen) {
+			p.nextToken()
+			call.Args = p.parseExpressionList(lexer.TokRParen)
+		}
+		return call
+	}
+
 	// Check what follows ->
 	// -> sonrasını kontrol et
 	switch p.curToken.Type {
@@ -877,7 +1191,7 @@ func (p *Parser) parseArrowExpression(left ast.Expression) ast.Expression {
 		p.nextToken()
 		var key ast.Expression
 		// If it's a bare identifier or keyword, treat it as a string
-		if p.isBareword() {
+		if p.isBareword() && p.peekTokenIs(lexer.TokRBrace) {
 			key = &ast.StringLiteral{
 				Token:        p.curToken,
 				Value:        p.curToken.Value,
@@ -894,25 +1208,19 @@ func (p *Parser) parseArrowExpression(left ast.Expression) ast.Expression {
 			Left:  left,
 			Right: &ast.HashAccess{Token: p.curToken, Key: key},
 		}
-	case lexer.TokIdent:
-		// ->method or ->method()
-		method := p.curToken.Value
-		if p.peekTokenIs(lexer.TokLParen) {
-			p.nextToken()
-			args := p.parseExpressionList(lexer.TokRParen)
-			return &ast.MethodCall{
-				Token:  token,
-				Object: left,
-				Method: method,
-				Args:   args,
-			}
-		}
-		return &ast.MethodCall{
-			Token:  token,
-			Object: left,
-			Method: method,
-			Args:   nil,
+	case lexer.TokLParen:
+		// ->(args) - call through a code reference
+		// ->(args) - kod referansı üzerinden çağrı
+		call := &ast.CallExpr{Token: p.curToken}
+		call.Args = p.parseExpressionList(lexer.TokRParen)
+		return &ast.ArrowAccess{Token: token, Left: left, Right: call}
+	case lexer.TokCast:
+		// ->@*, ->%*, ->$* - postfix dereference
+		// ->@*, ->%*, ->$* - sonek referans çözme
+		if sigil := strings.TrimSuffix(p.curToken.Value, "*"); sigil != p.curToken.Value {
+			return &ast.DerefExpr{Token: token, Sigil: sigil, Value: left}
 		}
+		return &ast.ArrowAccess{Token: token, Left: left}
 	default:
 		return &ast.ArrowAccess{Token: token, Left: left}
 	}
@@ -921,6 +1229,13 @@ func (p *Parser) parseArrowExpression(left ast.Expression) ast.Expression {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpr{Token: p.curToken, Function: function}
 	exp.Args = p.parseExpressionList(lexer.TokRParen)
+
+	// The arrow is optional between subscripts: $subs[0](1) is $subs[0]->(1)
+	// İndisler arasında ok isteğe bağlıdır: $subs[0](1), $subs[0]->(1) demektir
+	switch function.(type) {
+	case *ast.ArrayAccess, *ast.HashAccess, *ast.ArrowAccess:
+		return &ast.ArrowAccess{Token: exp.Token, Left: function, Right: &ast.CallExpr{Token: exp.Token, Args: exp.Args}}
+	}
 	return exp
 }
 
@@ -936,8 +1251,12 @@ func (p *Parser) parseExpressionList(end lexer.TokenType) []ast.Expression {
 	p.nextToken()
 	list = append(list, p.parseExpression(LOWEST))
 
-	for p.peekTokenIs(lexer.TokComma) {
-		p.nextToken()
+	// foo('key' => sub { ... }): the => infix is already the current token
+	// foo('key' => sub { ... }): => infix'i zaten geçerli belirteçtir
+	for p.curTokenIs(lexer.TokFatArrow) || p.peekTokenIs(lexer.TokComma) {
+		if !p.curTokenIs(lexer.TokFatArrow) {
+			p.nextToken()
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -957,19 +1276,10 @@ func (p *Parser) parseMatchExpression(left ast.Expression) ast.Expression {
 	// Handle s/pattern/replacement/flags
 	if p.curToken.Type == lexer.TokSubst {
 		tok := p.curToken
-		parts := strings.SplitN(tok.Value, "/", 3)
-		pattern := ""
-		replacement := ""
-		flags := ""
-		if len(parts) >= 1 {
-			pattern = parts[0]
-		}
-		if len(parts) >= 2 {
-			replacement = parts[1]
-		}
-		if len(parts) >= 3 {
-			flags = parts[2]
-		}
+		// Split at unescaped slashes: s/a\/b/c/ has pattern a\/b
+		// Kaçışsız eğik çizgilerde böl: s/a\/b/c/ deseni a\/b olur
+		pattern, rest := splitRegexToken(tok.Value)
+		replacement, flags := splitRegexToken(rest)
 
 		return &ast.SubstExpr{
 			Token:       tok,
@@ -980,6 +1290,21 @@ func (p *Parser) parseMatchExpression(left ast.Expression) ast.Expression {
 		}
 	}
 
+	// Handle tr/search/replace/flags (y///)
+	// tr/arama/değiştirme/bayraklar işle (y///)
+	if p.curToken.Type == lexer.TokTrans {
+		tok := p.curToken
+		search, rest := splitRegexToken(tok.Value)
+		replace, flags := splitRegexToken(rest)
+		return &ast.TransExpr{
+			Token:   tok,
+			Target:  left,
+			Search:  search,
+			Replace: replace,
+			Flags:   flags,
+		}
+	}
+
 	// Handle /pattern/flags
 	if p.curToken.Type == lexer.TokRegex {
 		exp := &ast.MatchExpr{
@@ -991,7 +1316,18 @@ func (p *Parser) parseMatchExpression(left ast.Expression) ast.Expression {
 		return exp
 	}
 
-	return nil
+	// Handle $str =~ $re (qr// value or string pattern)
+	// $str =~ $re işle (qr// değeri veya string desen)
+	pattern := p.parseExpression(MULTIPLICATIVE)
+	if pattern == nil {
+		return nil
+	}
+	return &ast.MatchExpr{
+		Token:       matchTok,
+		Target:      left,
+		PatternExpr: pattern,
+		Negate:      negate,
+	}
 }
 
 // ============================================================
@@ -1096,6 +1432,10 @@ func (p *Parser) parseRefExpr() ast.Expression {
 func (p *Parser) parseAnonSub() ast.Expression {
 	exp := &ast.AnonSubExpr{Token: p.curToken}
 
+	if p.peekTokenIs(lexer.TokLParen) {
+		p.nextToken()
+		exp.Params = p.parseSignature()
+	}
 	if !p.expectPeek(lexer.TokLBrace) {
 		return nil
 	}
@@ -1104,6 +1444,40 @@ func (p *Parser) parseAnonSub() ast.Expression {
 	return exp
 }
 
+// parseEvalBlock parses eval { ... }.
+// parseEvalBlock, eval { ... } ayrıştırır.
+func (p *Parser) parseEvalBlock() ast.Expression {
+	tok := p.curToken
+
+	// eval EXPR / eval: code string parsed at run time
+	// eval EXPR / eval: kod dizesi çalışma zamanında ayrıştırılır
+	if !p.peekTokenIs(lexer.TokLBrace) {
+		exp := &ast.EvalStringExpr{Token: tok}
+		switch p.peekToken.Type {
+		case lexer.TokSemi, lexer.TokComma, lexer.TokRParen, lexer.TokRBrace, lexer.TokEOF:
+			return exp
+		}
+		p.nextToken()
+		exp.Code = p.parseExpression(OR)
+		return exp
+	}
+
+	exp := &ast.EvalBlockExpr{Token: tok}
+	p.nextToken()
+	exp.Body = p.parseBlockStmt()
+	return exp
+}
+
+// parseDoBlock parses do { ... }.
+// parseDoBlock, do { ... } ifadesini ayrıştırır.
+func (p *Parser) parseDoBlock() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(lexer.TokLBrace) {
+		return nil
+	}
+	return &ast.DoBlockExpr{Token: tok, Body: p.parseBlockStmt()}
+}
+
 // ============================================================
 // Declaration Parsers
 // Bildirim Ayrıştırıcıları
@@ -1147,18 +1521,22 @@ func (p *Parser) parseSubDecl() ast.Statement {
 	}
 	decl.Name = p.curToken.Value
 
-	// Optional prototype
-	// Opsiyonel prototip
+	// Optional signature or prototype
+	// Opsiyonel imza veya prototip
 	if p.peekTokenIs(lexer.TokLParen) {
 		p.nextToken()
-		p.nextToken()
-		// Read prototype until )
-		var proto strings.Builder
-		for !p.curTokenIs(lexer.TokRParen) && !p.curTokenIs(lexer.TokEOF) {
-			proto.WriteString(p.curToken.Value)
+		if p.peekIsParam() {
+			decl.Params = p.parseSignature()
+		} else {
 			p.nextToken()
+			// Read prototype until )
+			var proto strings.Builder
+			for !p.curTokenIs(lexer.TokRParen) && !p.curTokenIs(lexer.TokEOF) {
+				proto.WriteString(p.curToken.Value)
+				p.nextToken()
+			}
+			decl.Prototype = proto.String()
 		}
-		decl.Prototype = proto.String()
 	}
 
 	// Optional attributes
@@ -1177,6 +1555,43 @@ func (p *Parser) parseSubDecl() ast.Statement {
 	return decl
 }
 
+// peekIsParam reports whether the token after '(' is a named variable, which
+// makes the parenthesis a signature rather than a prototype like ($$;@).
+// peekIsParam, '(' sonrasındaki token'ın adlı bir değişken olup olmadığını bildirir.
+func (p *Parser) peekIsParam() bool {
+	switch p.peekToken.Type {
+	case lexer.TokScalar, lexer.TokArray, lexer.TokHash:
+		v := p.peekToken.Value
+		return len(v) > 1 && (v[1] == '_' || unicode.IsLetter(rune(v[1])))
+	}
+	return false
+}
+
+// parseSignature parses a signature after '(': ($x, $y = 10, @rest).
+// parseSignature, '(' sonrasındaki imzayı ayrıştırır: ($x, $y = 10, @rest).
+func (p *Parser) parseSignature() []*ast.Param {
+	params := []*ast.Param{}
+	for !p.peekTokenIs(lexer.TokRParen) {
+		if !p.peekIsParam() {
+			p.peekError(lexer.TokScalar)
+			return params
+		}
+		p.nextToken()
+		param := &ast.Param{Sigil: p.curToken.Value[:1], Name: p.curToken.Value[1:]}
+		if param.Sigil == "$" && p.peekTokenIs(lexer.TokAssign) {
+			p.nextToken()
+			p.nextToken()
+			param.Default = p.parseExpression(COMMA)
+		}
+		params = append(params, param)
+		if !p.peekTokenIs(lexer.TokRParen) && !p.expectPeek(lexer.TokComma) {
+			return params
+		}
+	}
+	p.nextToken() // ')'
+	return params
+}
+
 func (p *Parser) parsePackageDecl() ast.Statement {
 	decl := &ast.PackageDecl{Token: p.curToken}
 
@@ -1230,10 +1645,43 @@ func (p *Parser) parseUseDecl() ast.Statement {
 		decl.Version = p.curToken.Value
 	}
 
-	// Optional import list
-	if p.peekTokenIs(lexer.TokQw) || p.peekTokenIs(lexer.TokLParen) {
+	// use constant NAME => VALUE; use constant { A => 1, B => 2 };
+	// use constant AD => değer; use constant { A => 1, B => 2 };
+	if decl.Module == "constant" && !p.peekTokenIs(lexer.TokSemi) {
 		p.nextToken()
-		// TODO: Parse qw() or import list
+		if p.curTokenIs(lexer.TokLBrace) {
+			decl.Args = []ast.Expression{p.parseExpression(LOWEST)}
+		} else {
+			decl.Args = []ast.Expression{&ast.StringLiteral{Token: p.curToken, Value: p.curToken.Value}}
+			if p.peekTokenIs(lexer.TokFatArrow) || p.peekTokenIs(lexer.TokComma) {
+				p.nextToken()
+				p.nextToken()
+				decl.Args = append(decl.Args, p.parseListExpression()...)
+			}
+		}
+		if p.peekTokenIs(lexer.TokSemi) {
+			p.nextToken()
+		}
+		return decl
+	}
+
+	// Optional import list: use POSIX qw(floor); use warnings 'once';
+	// Opsiyonel içe aktarma listesi
+	decl.Args = p.parseImportList()
+	if decl.Module == "Carp" {
+		p.importCarp(decl.Args)
+	}
+	if decl.Module == "List::Util" {
+		p.importListUtil(decl.Args)
+	}
+	if decl.Module == "POSIX" {
+		p.importPOSIX(decl.Args)
+	}
+	if decl.Module == "Getopt::Long" || decl.Module == "Getopt::Std" {
+		p.importGetopt()
+	}
+	if moduleExports[decl.Module] != nil {
+		p.importModuleFuncs(decl.Module, decl.Args)
 	}
 
 	if p.peekTokenIs(lexer.TokSemi) {
@@ -1249,6 +1697,9 @@ func (p *Parser) parseNoDecl() ast.Statement {
 	p.nextToken()
 	decl.Module = p.curToken.Value
 
+	// no warnings 'uninitialized';
+	decl.Args = p.parseImportList()
+
 	if p.peekTokenIs(lexer.TokSemi) {
 		p.nextToken()
 	}
@@ -1256,6 +1707,135 @@ func (p *Parser) parseNoDecl() ast.Statement {
 	return decl
 }
 
+// parseImportList parses the list after use/no Module up to the semicolon;
+// nil if there is none.
+// parseImportList, use/no Module sonrasındaki listeyi ayrıştırır.
+func (p *Parser) parseImportList() []ast.Expression {
+	if p.peekTokenIs(lexer.TokSemi) || p.peekTokenIs(lexer.TokEOF) || p.peekTokenIs(lexer.TokNewline) {
+		return nil
+	}
+	p.nextToken()
+	return p.parseListExpression()
+}
+
+// importCarp makes the functions use Carp imports list operators:
+// croak, carp and confess by default, cluck only by name.
+// importCarp, use Carp'ın içe aktardığı işlevleri liste operatörü yapar.
+func (p *Parser) importCarp(args []ast.Expression) {
+	if p.listOps == nil {
+		p.listOps = make(map[string]bool)
+	}
+	names := []string{"croak", "carp", "confess"}
+	if len(args) > 0 {
+		names = importNames(args)
+	}
+	for _, name := range append(names, "Carp::croak", "Carp::carp", "Carp::confess", "Carp::cluck") {
+		p.listOps[name] = true
+	}
+}
+
+// importPOSIX makes the functions use POSIX imports list operators:
+// floor $x / 2 is floor($x / 2). Without a list all of them are imported.
+// importPOSIX, use POSIX'in içe aktardığı işlevleri liste operatörü yapar.
+func (p *Parser) importPOSIX(args []ast.Expression) {
+	if p.listOps == nil {
+		p.listOps = make(map[string]bool)
+	}
+	names := []string{"floor", "ceil", "fmod", "pow", "strftime"}
+	if len(args) > 0 {
+		names = importNames(args)
+	}
+	for _, name := range names {
+		if name == strings.ToLower(name) {
+			p.listOps[name] = true
+		}
+	}
+}
+
+// importGetopt makes GetOptions, getopts and getopt list operators:
+// getopts 'ab:', \%opts;
+// importGetopt, GetOptions, getopts ve getopt'u liste operatörü yapar.
+func (p *Parser) importGetopt() {
+	if p.listOps == nil {
+		p.listOps = make(map[string]bool)
+	}
+	for _, name := range []string{"GetOptions", "getopts", "getopt"} {
+		p.listOps[name] = true
+	}
+}
+
+// moduleExports are the functions File::Basename, File::Path and
+// Storable export without a list; Cwd's cwd and getcwd are builtins
+// already.
+// moduleExports, bu modüllerin listesiz dışa aktardığı işlevlerdir.
+var moduleExports = map[string][]string{
+	"File::Basename": {"basename", "dirname", "fileparse"},
+	"File::Path":     {"mkpath", "rmtree"},
+	"Cwd":            {},
+	"Storable":       {"store", "retrieve"},
+}
+
+// importModuleFuncs makes the functions use File::Basename, File::Path,
+// Cwd or Storable imports list operators: basename $0 is basename($0).
+// importModuleFuncs, bu modüllerin içe aktardığı işlevleri liste
+// operatörü yapar.
+func (p *Parser) importModuleFuncs(module string, args []ast.Expression) {
+	if p.listOps == nil {
+		p.listOps = make(map[string]bool)
+	}
+	names := moduleExports[module]
+	if len(args) > 0 {
+		names = importNames(args)
+	}
+	for _, name := range names {
+		p.listOps[name] = true
+	}
+}
+
+// blockListOps are the List::Util functions that take a block first:
+// first { $_ > 1 } @list, reduce { $a + $b } @list.
+// blockListOps, önce blok alan List::Util işlevleridir.
+var blockListOps = map[string]bool{
+	"first": true, "any": true, "all": true, "none": true, "reduce": true,
+}
+
+// namedUnaryOps take a single argument without parentheses and bind
+// tighter than comparisons: keys %h == 0, stat $f.
+// namedUnaryOps parantezsiz tek argüman alır ve karşılaştırmadan sıkı bağlanır.
+var namedUnaryOps = map[string]bool{
+	"keys": true, "values": true, "each": true, "stat": true, "lstat": true,
+	"getpwnam": true, "getpwuid": true, "getgrnam": true, "getgrgid": true,
+	"ref": true,
+}
+
+// importListUtil makes the functions use List::Util imports list
+// operators; List::Util exports nothing by default.
+// importListUtil, use List::Util'in içe aktardığı işlevleri liste
+// operatörü yapar.
+func (p *Parser) importListUtil(args []ast.Expression) {
+	if p.listOps == nil {
+		p.listOps = make(map[string]bool)
+	}
+	for _, name := range importNames(args) {
+		p.listOps[name] = true
+	}
+}
+
+// importNames lists the constant names of an import list: 'a', qw(b c).
+// importNames, bir içe aktarma listesindeki sabit adları listeler.
+func importNames(args []ast.Expression) []string {
+	var names []string
+	for _, arg := range args {
+		switch a := arg.(type) {
+		case *ast.StringLiteral:
+			names = append(names, a.Value)
+		case *ast.ArrayExpr:
+			names = append(names, importNames(a.Elements)...)
+		}
+	}
+	return names
+}
+
 func (p *Parser) parseRequireDecl() ast.Statement {
 	decl := &ast.RequireDecl{Token: p.curToken}
 
@@ -1284,3 +1864,61 @@ func (p *Parser) parseSpecialBlock() ast.Statement {
 	block.Body = p.parseBlockStmt()
 	return block
 }
+
+// parseFormatDecl splits the lines of a format token into pictures and
+// arguments. A picture with @ or ^ fields is followed by a line of values,
+// which may be a { ... } block over several lines; lines starting with #
+// are comments.
+// parseFormatDecl, bir format tokeninin satırlarını resimlere ve argümanlara ayırır.
+func (p *Parser) parseFormatDecl() ast.Statement {
+	tok := p.curToken
+	name, body, _ := strings.Cut(tok.Value, "\n")
+	decl := &ast.FormatDecl{Token: tok, Name: name}
+	if decl.Name == "" {
+		decl.Name = "STDOUT"
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if body == "" {
+		lines = nil
+	}
+	for n := 0; n < len(lines); n++ {
+		picture := lines[n]
+		if strings.HasPrefix(picture, "#") {
+			continue
+		}
+		line := &ast.FormatLine{Picture: picture}
+		decl.Lines = append(decl.Lines, line)
+		if !strings.ContainsAny(picture, "@^") || n+1 == len(lines) {
+			continue
+		}
+		n++
+		start, args := n, lines[n]
+		if strings.HasPrefix(strings.TrimSpace(args), "{") {
+			for depth := strings.Count(args, "{") - strings.Count(args, "}"); depth > 0 && n+1 < len(lines); {
+				n++
+				args += "\n" + lines[n]
+				depth += strings.Count(lines[n], "{") - strings.Count(lines[n], "}")
+			}
+			args = strings.TrimSpace(args)
+			args = strings.TrimSuffix(strings.TrimPrefix(args, "{"), "}")
+		}
+		line.Args = p.parseFormatArgs(args, tok, tok.Line+1+start)
+	}
+	return decl
+}
+
+// parseFormatArgs parses the values of a picture line, which starts at
+// line of the file; errors are reported as the parser's own.
+// parseFormatArgs, bir resim satırının değerlerini ayrıştırır.
+func (p *Parser) parseFormatArgs(src string, tok lexer.Token, line int) []ast.Expression {
+	sub := New(lexer.NewFile(strings.Repeat("\n", line-1)+src, tok.File))
+	args := []ast.Expression{}
+	if !sub.curTokenIs(lexer.TokEOF) {
+		args = sub.parseListExpression()
+		if !sub.peekTokenIs(lexer.TokEOF) && !sub.peekTokenIs(lexer.TokSemi) {
+			sub.errors = append(sub.errors, fmt.Sprintf("line %d: unexpected %v in format arguments", line, sub.peekToken))
+		}
+	}
+	p.errors = append(p.errors, sub.errors...)
+	return args
+}
@@ -6,6 +6,7 @@ package parser
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	"perlc/pkg/ast"
 	"perlc/pkg/lexer"
@@ -163,10 +164,14 @@ func (p *Parser) parseForStmt() ast.Statement {
 func (p *Parser) parseForeachStyleFor(token lexer.Token) ast.Statement {
 	stmt := &ast.ForeachStmt{Token: token}
 
-	if p.curTokenIs(lexer.TokMy) {
+	declared := p.curTokenIs(lexer.TokMy)
+	if declared {
 		p.nextToken()
 	}
 	stmt.Variable = p.parseExpression(LOWEST)
+	if declared {
+		p.declare(stmt.Variable)
+	}
 
 	if !p.expectPeek(lexer.TokLParen) {
 		return nil
@@ -194,12 +199,16 @@ func (p *Parser) parseForeachStmt() ast.Statement {
 	p.nextToken() // skip foreach
 
 	// Optional my/our/local
-	if p.curTokenIs(lexer.TokMy) || p.curTokenIs(lexer.TokOur) || p.curTokenIs(lexer.TokLocal) {
+	declared := p.curTokenIs(lexer.TokMy) || p.curTokenIs(lexer.TokOur)
+	if declared || p.curTokenIs(lexer.TokLocal) {
 		p.nextToken()
 	}
 
 	// Variable - parse with high precedence to stop before (
 	stmt.Variable = p.parseExpression(CALL)
+	if declared {
+		p.declare(stmt.Variable)
+	}
 
 	// List in parentheses
 	if !p.expectPeek(lexer.TokLParen) {
@@ -220,16 +229,73 @@ func (p *Parser) parseForeachStmt() ast.Statement {
 	return stmt
 }
 
+// parseGivenStmt parses given (EXPR) { when (EXPR) {...} ... default {...} }.
+// parseGivenStmt, given/when/default bloğunu ayrıştırır.
+func (p *Parser) parseGivenStmt() ast.Statement {
+	stmt := &ast.GivenStmt{Token: p.curToken}
+
+	if !p.expectPeek(lexer.TokLParen) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Topic = p.parseExpression(LOWEST)
+	if !p.expectPeek(lexer.TokRParen) {
+		return nil
+	}
+	if !p.expectPeek(lexer.TokLBrace) {
+		return nil
+	}
+	p.nextToken() // skip {
+
+	for !p.curTokenIs(lexer.TokRBrace) && !p.curTokenIs(lexer.TokEOF) {
+		switch p.curToken.Type {
+		case lexer.TokWhen:
+			if !p.expectPeek(lexer.TokLParen) {
+				return nil
+			}
+			p.nextToken()
+			clause := &ast.WhenClause{Condition: p.parseExpression(LOWEST)}
+			if !p.expectPeek(lexer.TokRParen) || !p.expectPeek(lexer.TokLBrace) {
+				return nil
+			}
+			clause.Body = p.parseBlockStmt()
+			stmt.Clauses = append(stmt.Clauses, clause)
+		case lexer.TokDefault:
+			if !p.expectPeek(lexer.TokLBrace) {
+				return nil
+			}
+			stmt.Default = p.parseBlockStmt()
+		case lexer.TokSemi:
+		default:
+			p.errors = append(p.errors, fmt.Sprintf("line %d: expected when or default inside given, got %s", p.curToken.Line, p.curToken.Value))
+			return nil
+		}
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLabelStmt parses LABEL: statement.
+// parseLabelStmt, LABEL: deyimini ayrıştırır.
+func (p *Parser) parseLabelStmt() ast.Statement {
+	stmt := &ast.LabelStmt{Token: p.curToken, Label: p.curToken.Value}
+	p.nextToken() // ':'
+	p.nextToken()
+	stmt.Statement = p.parseStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseLastStmt() ast.Statement {
 	stmt := &ast.LastStmt{Token: p.curToken}
 	if p.peekTokenIs(lexer.TokIdent) {
 		p.nextToken()
 		stmt.Label = p.curToken.Value
 	}
-	if p.peekTokenIs(lexer.TokSemi) {
-		p.nextToken()
-	}
-	return stmt
+	return p.parseStatementModifiers(stmt)
 }
 
 func (p *Parser) parseNextStmt() ast.Statement {
@@ -238,10 +304,7 @@ func (p *Parser) parseNextStmt() ast.Statement {
 		p.nextToken()
 		stmt.Label = p.curToken.Value
 	}
-	if p.peekTokenIs(lexer.TokSemi) {
-		p.nextToken()
-	}
-	return stmt
+	return p.parseStatementModifiers(stmt)
 }
 
 func (p *Parser) parseRedoStmt() ast.Statement {
@@ -250,10 +313,7 @@ func (p *Parser) parseRedoStmt() ast.Statement {
 		p.nextToken()
 		stmt.Label = p.curToken.Value
 	}
-	if p.peekTokenIs(lexer.TokSemi) {
-		p.nextToken()
-	}
-	return stmt
+	return p.parseStatementModifiers(stmt)
 }
 
 func (p *Parser) parseReturnStmt() ast.Statement {
@@ -281,8 +341,8 @@ func (p *Parser) parseBuiltinCall() ast.Expression {
 	tok := p.curToken
 	name := tok.Value
 
-	// Special handling for print/say with filehandle: print $fh "text"
-	if name == "print" || name == "say" {
+	// Special handling for print/say/printf with filehandle: print $fh "text"
+	if name == "print" || name == "say" || name == "printf" {
 		return p.parsePrintCall(tok, name)
 	}
 
@@ -295,6 +355,24 @@ func (p *Parser) parseBuiltinCall() ast.Expression {
 	if p.peekTokenIs(lexer.TokLParen) {
 		p.nextToken()
 		expr.Args = p.parseExpressionList(lexer.TokRParen)
+	} else if blockListOps[name] && p.peekTokenIs(lexer.TokLBrace) {
+		// first { ... } @list: the block is an anonymous sub, no comma after it
+		// first { ... } @list: blok anonim bir sub, ardından virgül yok
+		p.nextToken()
+		expr.Args = []ast.Expression{p.parseBlockAsAnonSub()}
+		if !p.peekEndsCall() && !p.peekTokenIs(lexer.TokRParen) {
+			p.nextToken()
+			expr.Args = append(expr.Args, p.parseListExpression()...)
+		}
+	} else if name == "time" || p.peekTokenIs(lexer.TokRBrace) || p.peekTokenIs(lexer.TokRParen) ||
+		p.peekTokenIs(lexer.TokComma) || p.peekTokenIs(lexer.TokArrow) || p.peekEndsCall() {
+		// No arguments: sub { shift } / (pop) / print time, "\n" / time - $start / localtime->year / die;
+		// Argümansız: sub { shift } / (pop) / print time, "\n" / time - $start / localtime->year / die;
+	} else if namedUnaryOps[name] {
+		// Named unary: keys %h = 1024 / keys %h == 0 / stat $f take one argument
+		// İsimli tekli: keys %h = 1024 / keys %h == 0 / stat $f tek argüman alır
+		p.nextToken()
+		expr.Args = []ast.Expression{p.parseExpression(COMPARISON)}
 	} else {
 		// No parentheses - parse arguments
 		p.nextToken()
@@ -304,6 +382,18 @@ func (p *Parser) parseBuiltinCall() ast.Expression {
 	return expr
 }
 
+// peekEndsCall reports whether the next token ends a call without
+// parentheses before any argument: die; / die if $x / open(...) or die;
+// peekEndsCall, sonraki token'ın argümansız bir çağrıyı bitirip bitirmediğini bildirir.
+func (p *Parser) peekEndsCall() bool {
+	switch p.peekToken.Type {
+	case lexer.TokSemi, lexer.TokEOF, lexer.TokIf, lexer.TokUnless, lexer.TokWhile,
+		lexer.TokUntil, lexer.TokFor, lexer.TokForeach, lexer.TokOrWord, lexer.TokAndWord:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parsePrintCall(tok lexer.Token, name string) ast.Expression {
 	expr := &ast.CallExpr{
 		Token:    tok,
@@ -319,6 +409,37 @@ func (p *Parser) parsePrintCall(tok lexer.Token, name string) ast.Expression {
 
 	p.nextToken()
 
+	// Block filehandle: print {$fh} "text", print {$self->{out}} @lines
+	// Blok dosya tanıtıcısı: print {$fh} "text"
+	if p.curTokenIs(lexer.TokLBrace) {
+		fh := &ast.BlockFilehandle{Token: p.curToken}
+		p.nextToken()
+		fh.Expr = p.parseExpression(LOWEST)
+		if !p.expectPeek(lexer.TokRBrace) {
+			return expr
+		}
+		if id, ok := fh.Expr.(*ast.Identifier); ok {
+			// print {STDERR} is print STDERR
+			expr.Args = append(expr.Args, id)
+		} else {
+			expr.Args = append(expr.Args, fh)
+		}
+		p.nextToken()
+		expr.Args = append(expr.Args, p.parseListExpression()...)
+		return expr
+	}
+
+	// Standard stream: print STDERR "text"
+	// Standart akış: print STDERR "text"
+	if p.curTokenIs(lexer.TokIdent) && (p.curToken.Value == "STDOUT" || p.curToken.Value == "STDERR") &&
+		!p.peekTokenIs(lexer.TokComma) && !p.peekTokenIs(lexer.TokSemi) && !p.peekTokenIs(lexer.TokEOF) &&
+		!p.isOperatorToken(p.peekToken.Type) {
+		expr.Args = append(expr.Args, &ast.Identifier{Token: p.curToken, Value: p.curToken.Value})
+		p.nextToken()
+		expr.Args = append(expr.Args, p.parseListExpression()...)
+		return expr
+	}
+
 	// Check if first token is a scalar variable (potential filehandle)
 	// Filehandle form: print $fh "text" or print $fh $var
 	// But NOT: print $a + $b (that's an expression)
@@ -419,19 +540,10 @@ func (p *Parser) ParsePrintCallComplex2(tok lexer.Token, name string) ast.Expres
 func (p *Parser) parseSubstExpression(left ast.Expression) ast.Expression {
 	tok := p.curToken
 	// Parse s/pattern/replacement/flags from token value
-	parts := strings.SplitN(tok.Value, "/", 3)
-	pattern := ""
-	replacement := ""
-	flags := ""
-	if len(parts) >= 1 {
-		pattern = parts[0]
-	}
-	if len(parts) >= 2 {
-		replacement = parts[1]
-	}
-	if len(parts) >= 3 {
-		flags = parts[2]
-	}
+	// Split at unescaped slashes: s/a\/b/c/ has pattern a\/b
+	// Kaçışsız eğik çizgilerde böl: s/a\/b/c/ deseni a\/b olur
+	pattern, rest := splitRegexToken(tok.Value)
+	replacement, flags := splitRegexToken(rest)
 
 	return &ast.SubstExpr{
 		Token:       tok,
@@ -454,10 +566,14 @@ func (p *Parser) parseOpenExpr() ast.Expression {
 
 	// Filehandle
 	var fh ast.Expression
-	if p.curTokenIs(lexer.TokMy) {
+	declared := p.curTokenIs(lexer.TokMy)
+	if declared {
 		p.nextToken() // skip my
 	}
 	fh = p.parseExpression(LOWEST)
+	if declared {
+		p.declare(fh)
+	}
 
 	if !p.expectPeek(lexer.TokComma) {
 		return nil
@@ -466,13 +582,16 @@ func (p *Parser) parseOpenExpr() ast.Expression {
 
 	// Mode
 	mode := p.parseExpression(LOWEST)
+	args := []ast.Expression{fh, mode}
 
-	// Optional third argument (filename)
-	var filename ast.Expression
-	if p.peekTokenIs(lexer.TokComma) {
+	// Optional filename (open) or FILENAME, FLAGS[, PERMS] (sysopen)
+	for p.peekTokenIs(lexer.TokComma) {
 		p.nextToken() // skip comma
 		p.nextToken()
-		filename = p.parseExpression(LOWEST)
+		args = append(args, p.parseExpression(LOWEST))
+	}
+	if len(args) == 2 {
+		args = append(args, nil)
 	}
 
 	if p.peekTokenIs(lexer.TokRParen) {
@@ -481,9 +600,24 @@ func (p *Parser) parseOpenExpr() ast.Expression {
 
 	return &ast.CallExpr{
 		Token:    tok,
-		Function: &ast.Identifier{Token: tok, Value: "open"},
-		Args:     []ast.Expression{fh, mode, filename},
+		Function: &ast.Identifier{Token: tok, Value: tok.Value},
+		Args:     args,
+	}
+}
+
+// parseMyExpression parses "my $x" inside an expression, e.g.
+// open3(my $in, my $out, ...) or while (my $line = <$fh>).
+// The variable is created on first assignment, so only the variable is kept.
+// List declarations "my (...)" are still only supported as statements:
+// my ($x) = my ($y, $z) = @l is a parse error, my ($x) = my @l = ... is not.
+func (p *Parser) parseMyExpression() ast.Expression {
+	if p.peekTokenIs(lexer.TokLParen) {
+		p.errors = append(p.errors, fmt.Sprintf("line %d: %s (...) is only supported at the start of a statement",
+			p.curToken.Line, p.curToken.Value))
+		return nil
 	}
+	p.nextToken() // skip my
+	return p.declare(p.parseExpression(INDEX))
 }
 
 func (p *Parser) parseCloseExpr() ast.Expression {
@@ -516,6 +650,13 @@ func (p *Parser) parseReadLineExpr() ast.Expression {
 	if tok.Type == lexer.TokDiamond {
 		// <> - STDIN/ARGV
 		expr.Filehandle = nil
+	} else if !isFileHandleName(tok.Value) {
+		// <*.txt> - glob("*.txt")
+		return &ast.CallExpr{
+			Token:    tok,
+			Function: &ast.Identifier{Token: tok, Value: "glob"},
+			Args:     []ast.Expression{&ast.StringLiteral{Token: tok, Value: tok.Value, Interpolated: true}},
+		}
 	} else {
 		// <FH> or <$fh>
 		if len(tok.Value) > 0 && tok.Value[0] == '$' {
@@ -530,6 +671,21 @@ func (p *Parser) parseReadLineExpr() ast.Expression {
 	return expr
 }
 
+// isFileHandleName reports whether <...> holds a filehandle (FH, $fh)
+// rather than a glob pattern.
+func isFileHandleName(s string) bool {
+	s = strings.TrimPrefix(s, "$")
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if !(ch == '_' || ch == ':' || unicode.IsLetter(ch) || unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
 // parseListExpression parses comma-separated expressions until semicolon or EOF
 func (p *Parser) parseListExpression() []ast.Expression {
 	var list []ast.Expression
@@ -540,8 +696,12 @@ func (p *Parser) parseListExpression() []ast.Expression {
 
 	list = append(list, p.parseExpression(LOWEST))
 
-	for p.peekTokenIs(lexer.TokComma) {
-		p.nextToken()
+	// After 'key' => the => infix is already the current token
+	// 'key' => sonrasında => infix'i zaten geçerli belirteçtir
+	for p.curTokenIs(lexer.TokFatArrow) || p.peekTokenIs(lexer.TokComma) {
+		if !p.curTokenIs(lexer.TokFatArrow) {
+			p.nextToken()
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -630,18 +790,68 @@ func (p *Parser) isBareword() bool {
 		lexer.TokUse, lexer.TokPackage, lexer.TokReturn, lexer.TokLast, lexer.TokNext,
 		lexer.TokStrEq, lexer.TokStrNe, lexer.TokStrLt, lexer.TokStrLe, lexer.TokStrGt, lexer.TokStrGe,
 		lexer.TokAndWord, lexer.TokOrWord, lexer.TokNotWord,
-		lexer.TokPrint, lexer.TokSay, lexer.TokDefined, lexer.TokUndef, lexer.TokRef,
+		lexer.TokPrint, lexer.TokSay, lexer.TokPrintf, lexer.TokDefined, lexer.TokUndef, lexer.TokRef,
 		lexer.TokLength, lexer.TokPush, lexer.TokPop, lexer.TokShift, lexer.TokUnshift,
 		lexer.TokKeys, lexer.TokValues, lexer.TokJoin, lexer.TokSplit,
 		lexer.TokAbs, lexer.TokInt, lexer.TokSqrt, lexer.TokChr, lexer.TokOrd,
 		lexer.TokLc, lexer.TokUc, lexer.TokChomp, lexer.TokChop,
-		lexer.TokOpen, lexer.TokClose, lexer.TokDie, lexer.TokWarn, lexer.TokExit:
+		lexer.TokOpen, lexer.TokClose, lexer.TokDie, lexer.TokWarn, lexer.TokExit, lexer.TokDo:
 		return true
 	default:
 		return false
 	}
 }
 
+// parseSortExpression parses sort BLOCK LIST, sort SUBNAME LIST and plain sort LIST.
+// The comparator (if any) becomes Args[0] as an AnonSubExpr; a named comparator
+// is wrapped into a block calling that sub, so $a/$b are seen the same way.
+// parseSortExpression sort BLOK LISTE, sort ALTPROGRAM LISTE ve sort LISTE ayrıştırır.
+// Karşılaştırıcı (varsa) Args[0] olarak AnonSubExpr olur; isimli karşılaştırıcı
+// o altprogramı çağıran bir bloğa sarılır, böylece $a/$b aynı şekilde görülür.
+func (p *Parser) parseSortExpression() ast.Expression {
+	tok := p.curToken
+	call := &ast.CallExpr{
+		Token:    tok,
+		Function: &ast.Identifier{Token: tok, Value: tok.Value},
+	}
+
+	switch {
+	case p.peekTokenIs(lexer.TokLBrace):
+		// sort { $a <=> $b } @list
+		p.nextToken()
+		call.Args = append(call.Args, p.parseBlockAsAnonSub())
+	case p.peekTokenIs(lexer.TokIdent):
+		// sort by_num @list - like perl, an identifier followed by a list (even a
+		// parenthesized one) names the comparator; use sort +f(@x) to sort a call
+		// sort by_num @list - perl gibi, ardından liste gelen tanımlayıcı karşılaştırıcıdır
+		p.nextToken()
+		if p.peekTokenIs(lexer.TokComma) || p.peekTokenIs(lexer.TokSemi) || p.peekTokenIs(lexer.TokRParen) {
+			call.Args = p.parseListExpression()
+			return call
+		}
+		name := p.curToken
+		body := &ast.BlockStmt{Token: name, Statements: []ast.Statement{
+			&ast.ExprStmt{Token: name, Expression: &ast.CallExpr{
+				Token:    name,
+				Function: &ast.Identifier{Token: name, Value: name.Value},
+			}},
+		}}
+		call.Args = append(call.Args, &ast.AnonSubExpr{Token: name, Body: body})
+	case p.peekTokenIs(lexer.TokLParen):
+		p.nextToken()
+		call.Args = p.parseExpressionList(lexer.TokRParen)
+		return call
+	default:
+		p.nextToken()
+		call.Args = p.parseListExpression()
+		return call
+	}
+
+	p.nextToken()
+	call.Args = append(call.Args, p.parseListExpression()...)
+	return call
+}
+
 // parseBlockAsAnonSub парсит { block } как AnonSubExpr
 func (p *Parser) parseBlockAsAnonSub() ast.Expression {
 	tok := p.curToken // должен быть {
@@ -697,8 +907,8 @@ func (p *Parser) parseGrepMap() ast.Expression {
 			// Создаём массив из аргументов
 			arrExpr := &ast.ArrayExpr{Token: tok, Elements: args}
 			call.Args = append(call.Args, arrExpr)
-		} else if p.curTokenIs(lexer.TokScalar) {
-			// grep { ... } @$ref - разыменование
+		} else if p.curTokenIs(lexer.TokScalar) || p.curTokenIs(lexer.TokCast) {
+			// grep { ... } @$ref, @{ expr } - разыменование
 			arr := p.parseExpression(LOWEST)
 			call.Args = append(call.Args, arr)
 		}
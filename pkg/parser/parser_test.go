@@ -3,6 +3,8 @@ package parser
 // Package parser tests
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"perlc/pkg/ast"
@@ -283,6 +285,32 @@ func TestSubDecl(t *testing.T) {
 	}
 }
 
+func TestSubSignature(t *testing.T) {
+	input := `sub add($x, $y = 10, @rest) { $x + $y } sub proto($$) { 1 }`
+	program := parseProgram(t, input)
+
+	decl, ok := program.Statements[0].(*ast.SubDecl)
+	if !ok {
+		t.Fatalf("not SubDecl, got %T", program.Statements[0])
+	}
+	if len(decl.Params) != 3 {
+		t.Fatalf("expected 3 params, got %d", len(decl.Params))
+	}
+	if got := decl.String(); !strings.Contains(got, "add($x, $y = 10, @rest)") {
+		t.Errorf("signature not printed, got %s", got)
+	}
+	if decl.Params[1].Default == nil || decl.Params[2].Sigil != "@" {
+		t.Errorf("wrong params: %s", decl.String())
+	}
+	proto, ok := program.Statements[1].(*ast.SubDecl)
+	if !ok {
+		t.Fatalf("not SubDecl, got %T", program.Statements[1])
+	}
+	if proto.Params != nil || proto.Prototype != "$$" {
+		t.Errorf("expected prototype $$, got %q with %d params", proto.Prototype, len(proto.Params))
+	}
+}
+
 func TestPackageDecl(t *testing.T) {
 	input := `package Foo::Bar;`
 	program := parseProgram(t, input)
@@ -309,6 +337,115 @@ func TestUseDecl(t *testing.T) {
 	}
 }
 
+func TestUseOverload(t *testing.T) {
+	// the => after a quoted key separates list items like a comma
+	program := parseProgram(t, `use overload '""' => sub { 1 }, 'eq' => \&same; f('a' => sub { 2 });`)
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(program.Statements))
+	}
+	use := program.Statements[0].(*ast.UseDecl)
+	if len(use.Args) != 4 {
+		t.Fatalf("use overload: args = %v", use.Args)
+	}
+	if _, ok := use.Args[1].(*ast.AnonSubExpr); !ok {
+		t.Errorf("'\"\"' value not AnonSubExpr, got %T", use.Args[1])
+	}
+	call := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+	if len(call.Args) != 2 {
+		t.Errorf("f('a' => sub {...}): args = %v", call.Args)
+	}
+}
+
+func TestUseConstant(t *testing.T) {
+	program := parseProgram(t, `use constant PI => 3.14; use constant DAYS => qw(Mon Tue); use constant { A => 1, B => 2 }; print PI;`)
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+
+	pi := program.Statements[0].(*ast.UseDecl)
+	if len(pi.Args) != 2 || pi.Args[0].(*ast.StringLiteral).Value != "PI" {
+		t.Errorf("use constant PI: args = %v", pi.Args)
+	}
+	if _, ok := pi.Args[1].(*ast.FloatLiteral); !ok {
+		t.Errorf("value not FloatLiteral, got %T", pi.Args[1])
+	}
+	days := program.Statements[1].(*ast.UseDecl)
+	if list, ok := days.Args[1].(*ast.ArrayExpr); !ok || len(list.Elements) != 2 {
+		t.Errorf("use constant DAYS: value = %v", days.Args[1])
+	}
+	hash := program.Statements[2].(*ast.UseDecl)
+	if h, ok := hash.Args[0].(*ast.HashExpr); !ok || len(h.Pairs) != 2 {
+		t.Errorf("use constant {...}: args = %v", hash.Args)
+	}
+}
+
+func TestUseCarp(t *testing.T) {
+	// Imported names are list operators: croak "msg", $x
+	// İçe aktarılan adlar liste operatörüdür
+	program := parseProgram(t, `use Carp; croak "bad ", $x; carp "careful" if $y;`)
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+	croak, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+	if !ok || croak.Function.(*ast.Identifier).Value != "croak" || len(croak.Args) != 2 {
+		t.Errorf("croak: got %v", program.Statements[1])
+	}
+	if _, ok := program.Statements[2].(*ast.IfStmt); !ok {
+		t.Errorf("carp ... if: not IfStmt, got %T", program.Statements[2])
+	}
+	// cluck is only imported by name
+	// cluck yalnızca adıyla içe aktarılır
+	program = parseProgram(t, `use Carp qw(cluck); cluck "x";`)
+	if _, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr); !ok {
+		t.Errorf("cluck: not CallExpr, got %v", program.Statements[1])
+	}
+}
+
+func TestUseListUtil(t *testing.T) {
+	// first, reduce... take a block without a comma; sum is a list operator
+	// first, reduce... virgülsüz blok alır; sum bir liste operatörüdür
+	program := parseProgram(t, `use List::Util qw(first reduce sum);
+first { $_ > 1 } @l;
+reduce { $a + $b } 1, 2, 3;
+sum @l, 4;`)
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+	for idx, want := range []int{2, 4, 2} {
+		call, ok := program.Statements[idx+1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+		if !ok || len(call.Args) != want {
+			t.Errorf("statement %d: got %v", idx+1, program.Statements[idx+1])
+			continue
+		}
+		if _, isBlock := call.Args[0].(*ast.AnonSubExpr); isBlock != (idx < 2) {
+			t.Errorf("statement %d: block = %v", idx+1, isBlock)
+		}
+	}
+}
+
+func TestUsePOSIX(t *testing.T) {
+	// floor $x / 2 is floor($x / 2); constants stay barewords
+	// floor $x / 2, floor($x / 2) demektir; sabitler çıplak kelime kalır
+	program := parseProgram(t, `use POSIX; floor $x / 2; INT_MAX - 1;`)
+	call, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		t.Errorf("floor: got %v", program.Statements[1])
+	}
+	if _, ok := program.Statements[2].(*ast.ExprStmt).Expression.(*ast.InfixExpr); !ok {
+		t.Errorf("INT_MAX - 1: not InfixExpr, got %v", program.Statements[2])
+	}
+}
+
+func TestUseFileBasename(t *testing.T) {
+	// basename $p, ".pl" is basename($p, ".pl")
+	// basename $p, ".pl", basename($p, ".pl") demektir
+	program := parseProgram(t, `use File::Basename; basename $p, ".pl";`)
+	call, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		t.Errorf("basename: got %v", program.Statements[1])
+	}
+}
+
 // ============================================================
 // Control Flow Tests
 // Kontrol Akışı Testleri
@@ -385,6 +522,75 @@ func TestForeachStmt(t *testing.T) {
 	}
 }
 
+func TestGivenStmt(t *testing.T) {
+	input := `given ($x) { when (1) { print "one"; } when (/^a/) { print "a"; } default { print "other"; } }`
+	program := parseProgram(t, input)
+
+	stmt, ok := program.Statements[0].(*ast.GivenStmt)
+	if !ok {
+		t.Fatalf("not GivenStmt, got %T", program.Statements[0])
+	}
+	if len(stmt.Clauses) != 2 {
+		t.Fatalf("expected 2 when clauses, got %d", len(stmt.Clauses))
+	}
+	if _, ok := stmt.Clauses[1].Condition.(*ast.RegexLiteral); !ok {
+		t.Errorf("second condition not RegexLiteral, got %T", stmt.Clauses[1].Condition)
+	}
+	if stmt.Default == nil {
+		t.Error("default is nil")
+	}
+}
+
+func TestDoStmt(t *testing.T) {
+	input := `my $x = do { 1; 2 }; do { $i++; } until $i > 3;`
+	program := parseProgram(t, input)
+
+	decl, ok := program.Statements[0].(*ast.VarDecl)
+	if !ok {
+		t.Fatalf("not VarDecl, got %T", program.Statements[0])
+	}
+	if _, ok := decl.Value.(*ast.DoBlockExpr); !ok {
+		t.Errorf("value not DoBlockExpr, got %T", decl.Value)
+	}
+	stmt, ok := program.Statements[1].(*ast.DoStmt)
+	if !ok {
+		t.Fatalf("not DoStmt, got %T", program.Statements[1])
+	}
+	if !stmt.Until {
+		t.Error("Until is false")
+	}
+	if _, ok := stmt.Condition.(*ast.InfixExpr); !ok {
+		t.Errorf("condition not InfixExpr, got %T", stmt.Condition)
+	}
+}
+
+func TestLabelStmt(t *testing.T) {
+	input := `OUTER: foreach my $i (@a) { next OUTER if $i > 1; last; }`
+	program := parseProgram(t, input)
+
+	stmt, ok := program.Statements[0].(*ast.LabelStmt)
+	if !ok {
+		t.Fatalf("not LabelStmt, got %T", program.Statements[0])
+	}
+	if stmt.Label != "OUTER" {
+		t.Errorf("label is %q, want OUTER", stmt.Label)
+	}
+	loop, ok := stmt.Statement.(*ast.ForeachStmt)
+	if !ok {
+		t.Fatalf("not ForeachStmt, got %T", stmt.Statement)
+	}
+	cond, ok := loop.Body.Statements[0].(*ast.IfStmt)
+	if !ok {
+		t.Fatalf("not IfStmt, got %T", loop.Body.Statements[0])
+	}
+	if next, ok := cond.Then.Statements[0].(*ast.NextStmt); !ok || next.Label != "OUTER" {
+		t.Errorf("expected next OUTER, got %s", cond.Then.Statements[0].String())
+	}
+	if _, ok := loop.Body.Statements[1].(*ast.LastStmt); !ok {
+		t.Errorf("not LastStmt, got %T", loop.Body.Statements[1])
+	}
+}
+
 func TestReturnStmt(t *testing.T) {
 	input := `return 42;`
 	program := parseProgram(t, input)
@@ -462,6 +668,94 @@ func TestHashAccess(t *testing.T) {
 	}
 }
 
+func TestSlices(t *testing.T) {
+	program := parseProgram(t, `@arr[1, 3]; @h{qw(a b)}; @h{key}; @arr[0, 1] = (9, 8);`)
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+
+	as, ok := program.Statements[0].(*ast.ExprStmt).Expression.(*ast.ArraySlice)
+	if !ok {
+		t.Fatalf("not ArraySlice, got %T", program.Statements[0].(*ast.ExprStmt).Expression)
+	}
+	if len(as.Indices) != 2 {
+		t.Errorf("expected 2 indices, got %d", len(as.Indices))
+	}
+
+	hs, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.HashSlice)
+	if !ok {
+		t.Fatalf("not HashSlice, got %T", program.Statements[1].(*ast.ExprStmt).Expression)
+	}
+	if hv, ok := hs.Hash.(*ast.HashVar); !ok || hv.Name != "h" {
+		t.Errorf("slice of wrong hash: %s", hs.Hash.String())
+	}
+
+	hs = program.Statements[2].(*ast.ExprStmt).Expression.(*ast.HashSlice)
+	if key, ok := hs.Keys[0].(*ast.StringLiteral); !ok || key.Value != "key" {
+		t.Errorf("bareword key not autoquoted, got %s", hs.Keys[0].String())
+	}
+
+	assign, ok := program.Statements[3].(*ast.ExprStmt).Expression.(*ast.AssignExpr)
+	if !ok {
+		t.Fatalf("not AssignExpr, got %T", program.Statements[3].(*ast.ExprStmt).Expression)
+	}
+	if _, ok := assign.Left.(*ast.ArraySlice); !ok {
+		t.Errorf("assignment target not ArraySlice, got %T", assign.Left)
+	}
+}
+
+func TestDereference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // sigil of the DerefExpr
+	}{
+		{`@$r;`, "@"},
+		{`@{$r};`, "@"},
+		{`@{ $h->{list} };`, "@"},
+		{`%$h;`, "%"},
+		{`%{$h};`, "%"},
+		{`${$s};`, "$"},
+		{`$r->@*;`, "@"},
+		{`$h->%*;`, "%"},
+		{`$s->$*;`, "$"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		expr := program.Statements[0].(*ast.ExprStmt).Expression
+		deref, ok := expr.(*ast.DerefExpr)
+		if !ok {
+			t.Errorf("%s: not DerefExpr, got %T", tt.input, expr)
+			continue
+		}
+		if deref.Sigil != tt.expected {
+			t.Errorf("%s: sigil %q, want %q", tt.input, deref.Sigil, tt.expected)
+		}
+	}
+
+	// ${$r}[0] and $$h{k} are elements of the referenced array or hash
+	program := parseProgram(t, `${$r}[0]; $$h{k}; @{$r}[1, 2]; @$h{'a', 'b'};`)
+	stmts := program.Statements
+	if acc, ok := stmts[0].(*ast.ExprStmt).Expression.(*ast.ArrowAccess); !ok {
+		t.Errorf("${$r}[0]: not ArrowAccess, got %T", stmts[0].(*ast.ExprStmt).Expression)
+	} else if _, ok := acc.Right.(*ast.ArrayAccess); !ok {
+		t.Errorf("${$r}[0]: not an element access, got %T", acc.Right)
+	}
+	if acc, ok := stmts[1].(*ast.ExprStmt).Expression.(*ast.ArrowAccess); !ok {
+		t.Errorf("$$h{k}: not ArrowAccess, got %T", stmts[1].(*ast.ExprStmt).Expression)
+	} else if _, ok := acc.Right.(*ast.HashAccess); !ok {
+		t.Errorf("$$h{k}: not a hash element, got %T", acc.Right)
+	}
+	if _, ok := stmts[2].(*ast.ExprStmt).Expression.(*ast.ArraySlice); !ok {
+		t.Errorf("@{$r}[1, 2]: not ArraySlice, got %T", stmts[2].(*ast.ExprStmt).Expression)
+	}
+	if hs, ok := stmts[3].(*ast.ExprStmt).Expression.(*ast.HashSlice); !ok {
+		t.Errorf("@$h{...}: not HashSlice, got %T", stmts[3].(*ast.ExprStmt).Expression)
+	} else if d, ok := hs.Hash.(*ast.DerefExpr); !ok || d.Sigil != "%" {
+		t.Errorf("@$h{...}: slice of %s, want %%$h", hs.Hash.String())
+	}
+}
+
 func TestMethodCall(t *testing.T) {
 	input := `$obj->method(1, 2);`
 	program := parseProgram(t, input)
@@ -479,6 +773,47 @@ func TestMethodCall(t *testing.T) {
 	}
 }
 
+func TestKeywordMethodCall(t *testing.T) {
+	input := `$ch->close; $fh->print("x"); $dbh->do("DELETE FROM t");`
+	program := parseProgram(t, input)
+
+	for idx, want := range []string{"close", "print", "do"} {
+		stmt := program.Statements[idx].(*ast.ExprStmt)
+		call, ok := stmt.Expression.(*ast.MethodCall)
+		if !ok {
+			t.Fatalf("not MethodCall, got %T", stmt.Expression)
+		}
+		if call.Method != want {
+			t.Errorf("method not %s, got %s", want, call.Method)
+		}
+	}
+}
+
+func TestArrowChain(t *testing.T) {
+	input := `$obj->foo->$m(1)->{key}[0];`
+	program := parseProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	outer, ok := stmt.Expression.(*ast.ArrayAccess)
+	if !ok {
+		t.Fatalf("not ArrayAccess, got %T", stmt.Expression)
+	}
+	inner, ok := outer.Array.(*ast.ArrowAccess)
+	if !ok {
+		t.Fatalf("array not ArrowAccess, got %T", outer.Array)
+	}
+	dyn, ok := inner.Left.(*ast.MethodCall)
+	if !ok {
+		t.Fatalf("not MethodCall, got %T", inner.Left)
+	}
+	if v, ok := dyn.Dynamic.(*ast.ScalarVar); !ok || v.Name != "m" || len(dyn.Args) != 1 {
+		t.Errorf("expected ->$m(1), got %d args", len(dyn.Args))
+	}
+	if call, ok := dyn.Object.(*ast.MethodCall); !ok || call.Method != "foo" {
+		t.Errorf("expected ->foo, got %T", dyn.Object)
+	}
+}
+
 func TestCallExpr(t *testing.T) {
 	input := `foo(1, 2, 3);`
 	program := parseProgram(t, input)
@@ -521,11 +856,282 @@ func TestAnonSub(t *testing.T) {
 	}
 }
 
+func TestQwList(t *testing.T) {
+	input := `my @w = qw(a b c);`
+	program := parseProgram(t, input)
+
+	decl := program.Statements[0].(*ast.VarDecl)
+	list, ok := decl.Value.(*ast.ArrayExpr)
+	if !ok {
+		t.Fatalf("not ArrayExpr, got %T", decl.Value)
+	}
+	if len(list.Elements) != 3 {
+		t.Fatalf("expected 3 elements, got %d", len(list.Elements))
+	}
+	if lit, ok := list.Elements[1].(*ast.StringLiteral); !ok || lit.Value != "b" {
+		t.Errorf("element 1 not \"b\", got %v", list.Elements[1])
+	}
+}
+
+func TestMatchQr(t *testing.T) {
+	input := `$s =~ $re;`
+	program := parseProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	match, ok := stmt.Expression.(*ast.MatchExpr)
+	if !ok {
+		t.Fatalf("not MatchExpr, got %T", stmt.Expression)
+	}
+	if match.Pattern != nil {
+		t.Errorf("pattern should be nil, got %v", match.Pattern)
+	}
+	if _, ok := match.PatternExpr.(*ast.ScalarVar); !ok {
+		t.Errorf("pattern expr not ScalarVar, got %T", match.PatternExpr)
+	}
+
+	program = parseProgram(t, `my $re = qr{a/b}i;`)
+	decl := program.Statements[0].(*ast.VarDecl)
+	qr, ok := decl.Value.(*ast.QrExpr)
+	if !ok {
+		t.Fatalf("not QrExpr, got %T", decl.Value)
+	}
+	if qr.Pattern != `a\/b` || qr.Flags != "i" {
+		t.Errorf("wrong qr, got pattern=%q flags=%q", qr.Pattern, qr.Flags)
+	}
+}
+
+func TestCommandExpr(t *testing.T) {
+	program := parseProgram(t, "my @lines = qx{ls $dir};")
+	decl := program.Statements[0].(*ast.VarDecl)
+	cmd, ok := decl.Value.(*ast.CommandExpr)
+	if !ok {
+		t.Fatalf("not CommandExpr, got %T", decl.Value)
+	}
+	if cmd.Command.Value != "ls $dir" || !cmd.Command.Interpolated {
+		t.Errorf("wrong command, got %q interpolated=%v", cmd.Command.Value, cmd.Command.Interpolated)
+	}
+}
+
+func TestTransOnElement(t *testing.T) {
+	input := `$_[0] =~ tr/abc/ABC/;`
+	program := parseProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	tr, ok := stmt.Expression.(*ast.TransExpr)
+	if !ok {
+		t.Fatalf("not TransExpr, got %T", stmt.Expression)
+	}
+	if tr.Search != "abc" || tr.Replace != "ABC" {
+		t.Errorf("wrong tr, got search=%q replace=%q", tr.Search, tr.Replace)
+	}
+	if _, ok := tr.Target.(*ast.ArrayAccess); !ok {
+		t.Errorf("target not ArrayAccess, got %T", tr.Target)
+	}
+
+	program = parseProgram(t, `$ref->{name} =~ s/a/b/;`)
+	stmt = program.Statements[0].(*ast.ExprStmt)
+	subst, ok := stmt.Expression.(*ast.SubstExpr)
+	if !ok {
+		t.Fatalf("not SubstExpr, got %T", stmt.Expression)
+	}
+	if _, ok := subst.Target.(*ast.ArrowAccess); !ok {
+		t.Errorf("target not ArrowAccess, got %T", subst.Target)
+	}
+}
+
+func TestArrowCall(t *testing.T) {
+	input := `get()->[0]{k}->(1, 2);`
+	program := parseProgram(t, input)
+
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	call, ok := stmt.Expression.(*ast.ArrowAccess)
+	if !ok {
+		t.Fatalf("not ArrowAccess, got %T", stmt.Expression)
+	}
+	args, ok := call.Right.(*ast.CallExpr)
+	if !ok {
+		t.Fatalf("right not CallExpr, got %T", call.Right)
+	}
+	if len(args.Args) != 2 {
+		t.Errorf("expected 2 args, got %d", len(args.Args))
+	}
+	if _, ok := call.Left.(*ast.HashAccess); !ok {
+		t.Errorf("left not HashAccess, got %T", call.Left)
+	}
+
+	// The arrow between subscripts is optional
+	program = parseProgram(t, `$subs[0](3);`)
+	stmt = program.Statements[0].(*ast.ExprStmt)
+	call, ok = stmt.Expression.(*ast.ArrowAccess)
+	if !ok {
+		t.Fatalf("not ArrowAccess, got %T", stmt.Expression)
+	}
+	if _, ok := call.Right.(*ast.CallExpr); !ok {
+		t.Errorf("right not CallExpr, got %T", call.Right)
+	}
+}
+
+func TestCodeDerefCall(t *testing.T) {
+	for _, input := range []string{`&$f(1, 2);`, `&{$f}(1, 2);`} {
+		program := parseProgram(t, input)
+		stmt := program.Statements[0].(*ast.ExprStmt)
+		arrow, ok := stmt.Expression.(*ast.ArrowAccess)
+		if !ok {
+			t.Fatalf("%s: not ArrowAccess, got %T", input, stmt.Expression)
+		}
+		if _, ok := arrow.Left.(*ast.ScalarVar); !ok {
+			t.Errorf("%s: left not ScalarVar, got %T", input, arrow.Left)
+		}
+		call, ok := arrow.Right.(*ast.CallExpr)
+		if !ok {
+			t.Fatalf("%s: right not CallExpr, got %T", input, arrow.Right)
+		}
+		if len(call.Args) != 2 {
+			t.Errorf("%s: expected 2 args, got %d", input, len(call.Args))
+		}
+	}
+}
+
+func TestEvalString(t *testing.T) {
+	tests := []struct {
+		input   string
+		hasCode bool
+	}{
+		{`eval "1 + 2";`, true},
+		{`eval $code;`, true},
+		{`eval($code) or die;`, true},
+		{`eval;`, false},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := program.Statements[0].(*ast.ExprStmt)
+		expr := stmt.Expression
+		if infix, ok := expr.(*ast.InfixExpr); ok {
+			expr = infix.Left
+		}
+		es, ok := expr.(*ast.EvalStringExpr)
+		if !ok {
+			t.Fatalf("%s: not EvalStringExpr, got %T", tt.input, expr)
+		}
+		if (es.Code != nil) != tt.hasCode {
+			t.Errorf("%s: code = %v, want code %v", tt.input, es.Code, tt.hasCode)
+		}
+	}
+
+	program := parseProgram(t, `eval { 1 };`)
+	if _, ok := program.Statements[0].(*ast.ExprStmt).Expression.(*ast.EvalBlockExpr); !ok {
+		t.Errorf("eval { 1 } is not EvalBlockExpr")
+	}
+}
+
+func TestSortComparator(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantCmp  bool
+		wantArgs int
+	}{
+		{`sort { $a <=> $b } @list;`, true, 2},
+		{`sort by_num @list;`, true, 2},
+		{`sort { $h{$a} cmp $h{$b} } keys %h;`, true, 2},
+		{`sort @list;`, false, 1},
+		{`sort(3, 1, 2);`, false, 3},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := program.Statements[0].(*ast.ExprStmt)
+		call, ok := stmt.Expression.(*ast.CallExpr)
+		if !ok {
+			t.Fatalf("%s: not CallExpr, got %T", tt.input, stmt.Expression)
+		}
+		if len(call.Args) != tt.wantArgs {
+			t.Fatalf("%s: expected %d args, got %d", tt.input, tt.wantArgs, len(call.Args))
+		}
+		_, isCmp := call.Args[0].(*ast.AnonSubExpr)
+		if isCmp != tt.wantCmp {
+			t.Errorf("%s: comparator = %v, want %v", tt.input, isCmp, tt.wantCmp)
+		}
+	}
+}
+
+func TestBuiltinWithoutArgs(t *testing.T) {
+	program := parseProgram(t, `time - $start;`)
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	infix, ok := stmt.Expression.(*ast.InfixExpr)
+	if !ok {
+		t.Fatalf("time - $start: not InfixExpr, got %T", stmt.Expression)
+	}
+	if call, ok := infix.Left.(*ast.CallExpr); !ok || len(call.Args) != 0 {
+		t.Errorf("time - $start: left = %T, want CallExpr without args", infix.Left)
+	}
+
+	program = parseProgram(t, `localtime->year;`)
+	stmt = program.Statements[0].(*ast.ExprStmt)
+	method, ok := stmt.Expression.(*ast.MethodCall)
+	if !ok {
+		t.Fatalf("localtime->year: not MethodCall, got %T", stmt.Expression)
+	}
+	if _, ok := method.Object.(*ast.CallExpr); !ok || method.Method != "year" {
+		t.Errorf("localtime->year: object %T, method %q", method.Object, method.Method)
+	}
+}
+
 // ============================================================
 // Real Perl Code Test
 // Gerçek Perl Kodu Testi
 // ============================================================
 
+func TestKeysNamedUnary(t *testing.T) {
+	program := parseProgram(t, `keys %h = 1024;`)
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	assign, ok := stmt.Expression.(*ast.AssignExpr)
+	if !ok {
+		t.Fatalf("keys %%h = 1024: not AssignExpr, got %T", stmt.Expression)
+	}
+	if call, ok := assign.Left.(*ast.CallExpr); !ok || len(call.Args) != 1 {
+		t.Errorf("keys %%h = 1024: left = %T, want keys with one arg", assign.Left)
+	}
+
+	program = parseProgram(t, `keys %h == 0;`)
+	stmt = program.Statements[0].(*ast.ExprStmt)
+	if infix, ok := stmt.Expression.(*ast.InfixExpr); !ok || infix.Operator != "==" {
+		t.Errorf("keys %%h == 0: not a comparison, got %s", stmt.Expression.String())
+	}
+}
+
+func TestPrintBlockFilehandle(t *testing.T) {
+	program := parseProgram(t, `print {$h{out}} "a", "b";`)
+	stmt := program.Statements[0].(*ast.ExprStmt)
+	call, ok := stmt.Expression.(*ast.CallExpr)
+	if !ok {
+		t.Fatalf("not CallExpr, got %T", stmt.Expression)
+	}
+	if len(call.Args) != 3 {
+		t.Fatalf("args = %d, want 3", len(call.Args))
+	}
+	fh, ok := call.Args[0].(*ast.BlockFilehandle)
+	if !ok {
+		t.Fatalf("filehandle = %T, want *ast.BlockFilehandle", call.Args[0])
+	}
+	if _, ok := fh.Expr.(*ast.HashAccess); !ok {
+		t.Errorf("filehandle expr = %T, want *ast.HashAccess", fh.Expr)
+	}
+}
+
+func TestBuiltinWithoutArgsBeforeStatement(t *testing.T) {
+	program := parseProgram(t, "open(my $fh, '<', 'f') or die;\n{\n    local $/ = undef;\n}\ndie if 0;")
+	if len(program.Statements) != 3 {
+		t.Fatalf("statements = %d, want 3", len(program.Statements))
+	}
+	if _, ok := program.Statements[1].(*ast.BlockStmt); !ok {
+		t.Errorf("statement 2 = %T, want *ast.BlockStmt", program.Statements[1])
+	}
+	if _, ok := program.Statements[2].(*ast.IfStmt); !ok {
+		t.Errorf("statement 3 = %T, want *ast.IfStmt", program.Statements[2])
+	}
+}
+
 func TestRealPerlCode(t *testing.T) {
 	input := `
 use strict;
@@ -579,3 +1185,128 @@ func TestDebugTokens(t *testing.T) {
 		}
 	}
 }
+
+// TestParseStatementStream checks that a statement of a streamed source is
+// returned while the rest of it is still being written.
+// TestParseStatementStream, akış kaynağındaki deyimin kaynak bitmeden döndüğünü test eder.
+func TestParseStatementStream(t *testing.T) {
+	r, w := io.Pipe()
+	go io.WriteString(w, "my $x = 1;\nprint $x;\n")
+	p := New(lexer.NewReader(r, "-"))
+
+	// one token of lookahead past the statement is enough
+	stmt := p.ParseStatement()
+	checkParserErrors(t, p)
+	if stmt == nil || !strings.Contains(stmt.String(), "my") {
+		t.Fatalf("first statement = %v, want my $x", stmt)
+	}
+
+	w.Close()
+	if stmt := p.ParseStatement(); stmt == nil || !strings.Contains(stmt.String(), "print") {
+		t.Fatalf("second statement = %v, want print", stmt)
+	}
+	if stmt := p.ParseStatement(); stmt != nil {
+		t.Errorf("expected nil at EOF, got %v", stmt)
+	}
+}
+
+// TestListRepetition checks that the parens of (LIST) x N are kept, so
+// the list is repeated and not a string.
+// TestListRepetition, (LIST) x N parantezlerinin korunduğunu test eder.
+func TestListRepetition(t *testing.T) {
+	tests := []struct {
+		input string
+		list  bool
+	}{
+		{`my @a = (0) x 30;`, true},
+		{`my @a = (1, 2) x 3;`, true},
+		{`my @a = qw(a b) x 2;`, true},
+		{`my $s = "ab" x 3;`, false},
+		{`my $s = ($n + 1) * 2;`, false},
+	}
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		decl, ok := program.Statements[0].(*ast.VarDecl)
+		if !ok {
+			t.Fatalf("%s: expected VarDecl, got %T", tt.input, program.Statements[0])
+		}
+		infix, ok := decl.Value.(*ast.InfixExpr)
+		if !ok {
+			t.Fatalf("%s: expected InfixExpr, got %T", tt.input, decl.Value)
+		}
+		if _, list := infix.Left.(*ast.ArrayExpr); list != tt.list {
+			t.Errorf("%s: left operand %T, list %v", tt.input, infix.Left, tt.list)
+		}
+	}
+}
+
+// TestStrictVars checks use strict 'vars': undeclared variables are
+// errors, declared, qualified and special ones are not.
+// TestStrictVars, use strict 'vars' denetimini test eder.
+func TestStrictVars(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string // undeclared variables, in order
+	}{
+		{`$x = 1;`, nil},
+		{`use strict; $x = 1; print "$y @z $h{a} $l[0]";`, []string{`$x`, `$y`, `@z`, `%h`, `@l`}},
+		{`use strict; my ($x, @l, %h) = (1); print "$x $l[0] $h{a} $$x[0] @{$x}", $#l;`, nil},
+		{`use strict; foreach my $i (1) { print $i } print $i;`, []string{`$i`}},
+		{`use strict; while (my $l = <STDIN>) { print $l } { my $b2 = 1 } print $b2;`, []string{`$b2`}},
+		{`use strict; sub f ($p) { return $p + $q } my $c = sub { my $r = shift; $r };`, []string{`$q`}},
+		{`use strict; open(my $fh, '<', 'f'); print $fh; my @s = sort { $a <=> $b } @ARGV;`, nil},
+		{`use strict; print $_, $0, $1, $Foo::x, $::y, %ENV, @INC, "\$n \@m user\@host";`, nil},
+		{`use strict; our $o; use vars qw(@v); print $o, @v; { no strict 'vars'; $g = 1; } $g = 2;`, []string{`$g`}},
+		{`use strict 'refs'; $x = 1;`, nil},
+		{`use 5.012; $x = 1;`, []string{`$x`}},
+		{`{ use strict; $x = 1; } $y = 1;`, []string{`$x`}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		var got []string
+		for _, msg := range p.Errors() {
+			start := strings.Index(msg, `Global symbol "`)
+			if start < 0 {
+				t.Fatalf("%s: unexpected error %q", tt.input, msg)
+			}
+			name := msg[start+len(`Global symbol "`):]
+			got = append(got, name[:strings.Index(name, `"`)])
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: undeclared %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestFormatDecl checks that format lines are split into pictures and
+// their arguments, with comments dropped and { } blocks over lines.
+// TestFormatDecl, format satırlarının resim ve argümanlara ayrılmasını test eder.
+func TestFormatDecl(t *testing.T) {
+	input := "format =\n# comment\nName: @<<<< @>>>\n$name, $h{x} + 1\nplain\n^<<<\n{\n  $text\n}\n.\nwrite;\n"
+	program := parseProgram(t, input)
+	decl, ok := program.Statements[0].(*ast.FormatDecl)
+	if !ok {
+		t.Fatalf("expected FormatDecl, got %T", program.Statements[0])
+	}
+	if decl.Name != "STDOUT" {
+		t.Errorf("format name %q, want STDOUT", decl.Name)
+	}
+	want := []struct {
+		picture string
+		args    int
+	}{{"Name: @<<<< @>>>", 2}, {"plain", 0}, {"^<<<", 1}}
+	if len(decl.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %s", len(decl.Lines), len(want), decl)
+	}
+	for n, w := range want {
+		line := decl.Lines[n]
+		if line.Picture != w.picture || len(line.Args) != w.args {
+			t.Errorf("line %d: %q with %d args, want %q with %d", n, line.Picture, len(line.Args), w.picture, w.args)
+		}
+	}
+	call, ok := program.Statements[1].(*ast.ExprStmt).Expression.(*ast.CallExpr)
+	if !ok || call.Function.String() != "write" {
+		t.Errorf("expected write call, got %s", program.Statements[1])
+	}
+}
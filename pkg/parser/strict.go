@@ -0,0 +1,437 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"perlc/pkg/ast"
+)
+
+// ============================================================
+// use strict 'vars'
+// use strict 'vars' denetimi
+// ============================================================
+
+// strictScope is one lexical scope of the strict vars check: the variables
+// declared in it, by sigil and name ("$x", "@list"), and whether strict
+// vars is in effect there.
+// strictScope, strict vars denetiminin bir sözcüksel kapsamıdır.
+type strictScope struct {
+	parent *strictScope
+	names  map[string]bool
+	vars   bool
+	pkg    string
+}
+
+// strictExempt are the names strict vars lets through in every package.
+// strictExempt, strict vars'ın her pakette izin verdiği adlardır.
+var strictExempt = map[string]bool{
+	"_": true, "ARGV": true, "ARGVOUT": true, "ENV": true, "INC": true,
+	"SIG": true, "STDIN": true, "STDOUT": true, "STDERR": true,
+}
+
+// declare marks a variable the AST keeps without its my: foreach my $x,
+// while (my $line = ...), open(my $fh, ...).
+// declare, AST'nin my olmadan tuttuğu bir değişkeni işaretler.
+func (p *Parser) declare(expr ast.Expression) ast.Expression {
+	if expr == nil {
+		return nil
+	}
+	if p.declared == nil {
+		p.declared = make(map[ast.Expression]bool)
+	}
+	p.declared[expr] = true
+	return expr
+}
+
+// checkStrict checks a top-level statement against use strict 'vars': a
+// variable that is neither declared nor qualified with a package is an
+// error, as in perl.
+// checkStrict, üst düzey bir deyimi use strict 'vars'a göre denetler.
+func (p *Parser) checkStrict(stmt ast.Statement) {
+	if p.strict == nil {
+		p.strict = &strictScope{names: map[string]bool{}, pkg: "main"}
+	}
+	p.strictStmt(stmt)
+}
+
+func (p *Parser) pushStrict() {
+	p.strict = &strictScope{parent: p.strict, names: map[string]bool{}, vars: p.strict.vars, pkg: p.strict.pkg}
+}
+
+func (p *Parser) popStrict() {
+	p.strict = p.strict.parent
+}
+
+func (p *Parser) strictStmt(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case nil:
+	case *ast.BlockStmt:
+		if s == nil {
+			return
+		}
+		p.pushStrict()
+		for _, st := range s.Statements {
+			p.strictStmt(st)
+		}
+		p.popStrict()
+	case *ast.VarDecl:
+		p.strictNode(s.Value)
+		for _, name := range s.Names {
+			if s.Kind == "local" {
+				p.strictNode(name)
+			} else {
+				p.declareVar(name)
+			}
+		}
+	case *ast.SubDecl:
+		p.strictSub(s.Params, s.Body)
+	case *ast.UseDecl:
+		switch s.Module {
+		case "strict":
+			if strictNamesVars(s.Args) {
+				p.strict.vars = true
+			}
+		case "vars":
+			for _, name := range importNames(s.Args) {
+				if len(name) > 1 {
+					p.strictGlobal(name[:1], name[1:])
+				}
+			}
+		default:
+			// use v5.12 and later turn strict on
+			// use v5.12 ve sonrası strict'i açar
+			if useVersionStrict(s.Module) {
+				p.strict.vars = true
+			}
+			// variables named in an import list are imported into the
+			// package, as with use vars
+			// içe aktarma listesindeki değişkenler pakete aktarılır
+			for _, name := range importNames(s.Args) {
+				if len(name) > 1 && strings.ContainsRune("$@%", rune(name[0])) {
+					p.strictGlobal(name[:1], name[1:])
+				}
+			}
+		}
+	case *ast.NoDecl:
+		if s.Module == "strict" && strictNamesVars(s.Args) {
+			p.strict.vars = false
+		}
+	case *ast.PackageDecl:
+		if s.Block == nil {
+			p.strict.pkg = s.Name
+			return
+		}
+		p.pushStrict()
+		p.strict.pkg = s.Name
+		p.strictStmt(s.Block)
+		p.popStrict()
+	case *ast.ForeachStmt:
+		// the loop variable belongs to the loop
+		// döngü değişkeni döngüye aittir
+		p.pushStrict()
+		p.strictNode(s.List)
+		if p.declared[s.Variable] {
+			p.declareVar(s.Variable)
+		} else {
+			p.strictNode(s.Variable)
+		}
+		p.strictStmt(s.Body)
+		p.strictStmt(s.Continue)
+		p.popStrict()
+	case *ast.IfStmt, *ast.WhileStmt, *ast.ForStmt, *ast.GivenStmt:
+		// my in the condition is seen by the blocks of the statement only
+		// koşuldaki my yalnızca deyimin bloklarında görülür
+		p.pushStrict()
+		p.strictFields(reflect.ValueOf(s).Elem())
+		p.popStrict()
+	case *ast.ModifierStmt:
+		p.strictStmt(s.Statement)
+		p.strictNode(s.Condition)
+	default:
+		v := reflect.ValueOf(stmt)
+		if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+			p.strictFields(v.Elem())
+		}
+	}
+}
+
+// strictSub checks a sub body with its signature parameters declared.
+// strictSub, imza parametreleri bildirilmiş bir sub gövdesini denetler.
+func (p *Parser) strictSub(params []*ast.Param, body *ast.BlockStmt) {
+	p.pushStrict()
+	for _, param := range params {
+		p.strict.names[param.Sigil+param.Name] = true
+	}
+	p.strictStmt(body)
+	p.popStrict()
+}
+
+// strictNode checks the variables of an expression or a statement.
+// strictNode, bir ifadenin veya deyimin değişkenlerini denetler.
+func (p *Parser) strictNode(node ast.Node) {
+	switch n := node.(type) {
+	case nil:
+	case ast.Expression:
+		if stmt, ok := n.(ast.Statement); ok && isStrictStmt(n) {
+			p.strictStmt(stmt)
+			return
+		}
+		p.strictExpr(n)
+	case ast.Statement:
+		p.strictStmt(n)
+	}
+}
+
+// isStrictStmt tells the statements strictStmt handles apart from
+// expressions.
+func isStrictStmt(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.BlockStmt, *ast.VarDecl, *ast.SubDecl, *ast.UseDecl, *ast.NoDecl,
+		*ast.PackageDecl, *ast.ForeachStmt, *ast.IfStmt, *ast.WhileStmt,
+		*ast.ForStmt, *ast.GivenStmt, *ast.ModifierStmt:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) strictExpr(expr ast.Expression) {
+	switch e := expr.(type) {
+	case nil:
+	case *ast.ScalarVar:
+		if e != nil {
+			p.strictUse(e, "$", e.Name, e.Token.Line)
+		}
+	case *ast.ArrayVar:
+		if e != nil {
+			p.strictUse(e, "@", e.Name, e.Token.Line)
+		}
+	case *ast.HashVar:
+		if e != nil {
+			p.strictUse(e, "%", e.Name, e.Token.Line)
+		}
+	case *ast.ArrayLengthVar:
+		if e != nil {
+			p.strictVar("@", e.Name, e.Token.Line)
+		}
+	case *ast.ArrayAccess:
+		// $x[0] is an element of @x
+		// $x[0], @x'in bir elemanıdır
+		if v, ok := e.Array.(*ast.ScalarVar); ok && v != nil {
+			p.strictVar("@", v.Name, v.Token.Line)
+			p.strictExpr(e.Index)
+			return
+		}
+		p.strictFields(reflect.ValueOf(e).Elem())
+	case *ast.HashAccess:
+		if v, ok := e.Hash.(*ast.ScalarVar); ok && v != nil {
+			p.strictVar("%", v.Name, v.Token.Line)
+			p.strictExpr(e.Key)
+			return
+		}
+		p.strictFields(reflect.ValueOf(e).Elem())
+	case *ast.AnonSubExpr:
+		p.strictSub(e.Params, e.Body)
+	case *ast.StringLiteral:
+		if e != nil && e.Interpolated {
+			p.strictString(e.Value, e.Token.Line)
+		}
+	case *ast.RegexLiteral:
+		if e != nil {
+			p.strictString(e.Pattern, e.Token.Line)
+		}
+	case *ast.QrExpr:
+		if e != nil {
+			p.strictString(e.Pattern, e.Token.Line)
+		}
+	case *ast.SubstExpr:
+		if e != nil {
+			p.strictExpr(e.Target)
+			p.strictString(e.Pattern, e.Token.Line)
+			p.strictString(e.Replacement, e.Token.Line)
+		}
+	default:
+		v := reflect.ValueOf(expr)
+		if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+			p.strictFields(v.Elem())
+		}
+	}
+}
+
+// strictFields checks every node held by the fields of a node struct.
+// strictFields, bir düğüm yapısının alanlarındaki tüm düğümleri denetler.
+func (p *Parser) strictFields(v reflect.Value) {
+	for n := 0; n < v.NumField(); n++ {
+		if v.Type().Field(n).IsExported() {
+			p.strictValue(v.Field(n))
+		}
+	}
+}
+
+func (p *Parser) strictValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if node, ok := v.Interface().(ast.Node); ok {
+			p.strictNode(node)
+			return
+		}
+		if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct {
+			// ElsifClause, HashPair, WhenClause
+			p.strictFields(v.Elem())
+		}
+	case reflect.Slice:
+		for n := 0; n < v.Len(); n++ {
+			p.strictValue(v.Index(n))
+		}
+	}
+}
+
+// strictString checks the variables an interpolating string or pattern
+// uses: $x, ${x}, $x[0] (of @x), $x{k} (of %x) and @x.
+// strictString, enterpolasyonlu bir dizgenin değişkenlerini denetler.
+func (p *Parser) strictString(s string, line int) {
+	if !p.strict.vars {
+		return
+	}
+	for n := 0; n < len(s); n++ {
+		c := s[n]
+		if c == '\\' {
+			n++
+			continue
+		}
+		if c != '$' && c != '@' {
+			continue
+		}
+		start, braced := n+1, false
+		if start < len(s) && s[start] == '{' {
+			start, braced = start+1, true
+		}
+		end := start
+		for end < len(s) && (isIdentByte(s[end]) || s[end] == ':' && end+1 < len(s) && s[end+1] == ':') {
+			if s[end] == ':' {
+				end++
+			}
+			end++
+		}
+		if end == start || s[start] >= '0' && s[start] <= '9' {
+			continue
+		}
+		name := s[start:end]
+		if braced {
+			if end >= len(s) || s[end] != '}' {
+				continue
+			}
+			end++
+		}
+		sigil := string(c)
+		if n > 0 && (s[n-1] == '$' || s[n-1] == '@') {
+			// $$ref[0] and @$ref are through the scalar $ref
+			// $$ref[0] ve @$ref, $ref skaleri üzerindendir
+			sigil = "$"
+		} else if c == '$' && end < len(s) {
+			switch s[end] {
+			case '[':
+				sigil = "@"
+			case '{':
+				sigil = "%"
+			}
+		}
+		p.strictVar(sigil, name, line)
+		n = end - 1
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// strictUse checks a variable, or declares it when a dropped my stood
+// before it.
+// strictUse, bir değişkeni denetler ya da önünde my varsa bildirir.
+func (p *Parser) strictUse(expr ast.Expression, sigil, name string, line int) {
+	if p.declared[expr] {
+		p.declareVar(expr)
+		return
+	}
+	p.strictVar(sigil, name, line)
+}
+
+// declareVar declares the variable of a my, our or state in the current
+// scope.
+// declareVar, my, our veya state değişkenini geçerli kapsamda bildirir.
+func (p *Parser) declareVar(expr ast.Expression) {
+	switch v := expr.(type) {
+	case *ast.ScalarVar:
+		p.strict.names["$"+v.Name] = true
+	case *ast.ArrayVar:
+		p.strict.names["@"+v.Name] = true
+	case *ast.HashVar:
+		p.strict.names["%"+v.Name] = true
+	}
+}
+
+// strictGlobal declares a package variable of use vars: it is seen in the
+// package for the rest of the file.
+// strictGlobal, use vars ile bir paket değişkeni bildirir.
+func (p *Parser) strictGlobal(sigil, name string) {
+	if p.strictVars == nil {
+		p.strictVars = make(map[string]bool)
+	}
+	p.strictVars[sigil+p.strict.pkg+"::"+name] = true
+}
+
+// strictVar reports a variable that strict vars does not allow here.
+// strictVar, strict vars'ın burada izin vermediği bir değişkeni bildirir.
+func (p *Parser) strictVar(sigil, name string, line int) {
+	if !p.strict.vars || name == "" || strings.Contains(name, "::") || strictExempt[name] {
+		return
+	}
+	if c := name[0]; c != '_' && !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+		// $0, $1, ${^WARNING_BITS}
+		return
+	}
+	if sigil == "$" && (name == "a" || name == "b") {
+		return
+	}
+	for scope := p.strict; scope != nil; scope = scope.parent {
+		if scope.names[sigil+name] {
+			return
+		}
+	}
+	if p.strictVars[sigil+p.strict.pkg+"::"+name] {
+		return
+	}
+	p.errors = append(p.errors, fmt.Sprintf(
+		"line %d: Global symbol \"%s%s\" requires explicit package name (did you forget to declare \"my %s%s\"?)",
+		line, sigil, name, sigil, name))
+}
+
+// strictNamesVars tells whether use/no strict LIST covers vars: a bare
+// use strict covers all of refs, subs and vars.
+// strictNamesVars, use/no strict LIST'in vars'ı kapsayıp kapsamadığını söyler.
+func strictNamesVars(args []ast.Expression) bool {
+	if len(args) == 0 {
+		return true
+	}
+	for _, name := range importNames(args) {
+		if name == "vars" {
+			return true
+		}
+	}
+	return false
+}
+
+// useVersionStrict tells whether use VERSION asks for v5.12 or later,
+// which turns strict on.
+// useVersionStrict, use VERSION'ın v5.12 veya sonrasını isteyip istemediğini söyler.
+func useVersionStrict(module string) bool {
+	var major, minor int
+	if n, _ := fmt.Sscanf(strings.TrimPrefix(module, "v"), "%d.%d", &major, &minor); n < 2 || major != 5 {
+		return false
+	}
+	return minor >= 12
+}
@@ -0,0 +1,787 @@
+// Package perlpack has Perl's pack and unpack, without Perl values: a
+// template turns a list of scalars into a binary string and back.
+//
+//	perlpack.Pack("n/a* N", args)      // a length-prefixed string, then a 32-bit big-endian number
+//	perlpack.Unpack("(A2)*", "aabbcc") // "aa" "bb" "cc"
+//	perlpack.Unpack("H*", "\x12\xab")  // "12ab"
+//
+// Supported: the strings a A Z, the bit and hex strings b B h H, the
+// integers c C W U s S l L q Q i I j J n N v V and w (BER), the floats
+// f d F, the positions x X @, groups (...), the length prefix /, the
+// checksums %<bits> of unpack and the modifiers < > !. Counts are a number, [number] or *. Integers are
+// little-endian unless > or n N says otherwise, as on the machines Go runs
+// perlc on.
+//
+// perlc uses it in both backends: the interpreter imports it and the
+// generated programs embed its source (see Source).
+package perlpack
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Arg is a value pack reads as a string, an integer or a float; perlc's
+// values are Args. A missing argument packs as undef: "" or 0.
+type Arg interface {
+	AsString() string
+	AsInt() int64
+	AsFloat() float64
+}
+
+// packItem is one letter of a template with its modifiers and count
+type packItem struct {
+	code  byte
+	big   bool // > or n N: big-endian
+	bang  bool // !: native sizes, signed n N v V
+	count int
+	star  bool // count is *
+	// counted: the template gave a count; the string letters differ on it
+	counted bool
+	group   []packItem
+	// slash: the value is the length of the next item, as in n/a*
+	slash bool
+}
+
+// parsePackTemplate splits a template into items; whitespace and comments
+// from # to the end of a line are ignored
+func parsePackTemplate(tpl, fn string) ([]packItem, error) {
+	items, rest, err := parsePackGroup(tpl, fn, false)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("Mismatched brackets in template")
+	}
+	return items, err
+}
+
+func parsePackGroup(tpl, fn string, inGroup bool) ([]packItem, string, error) {
+	var items []packItem
+	for len(tpl) > 0 {
+		c := tpl[0]
+		tpl = tpl[1:]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		case c == '#':
+			if nl := strings.IndexByte(tpl, '\n'); nl >= 0 {
+				tpl = tpl[nl+1:]
+			} else {
+				tpl = ""
+			}
+			continue
+		case c == ')':
+			if !inGroup {
+				return nil, "", fmt.Errorf("Mismatched brackets in template")
+			}
+			return items, tpl, nil
+		case c == '/':
+			if len(items) == 0 || !packNumeric(items[len(items)-1].code) {
+				return nil, "", fmt.Errorf("'/' must follow a numeric type in %s", fn)
+			}
+			items[len(items)-1].slash = true
+			continue
+		}
+
+		it := packItem{code: c, count: 1}
+		if c == '%' && fn == "pack" {
+			return nil, "", fmt.Errorf("'%%' may not be used in pack")
+		}
+		if c == '(' {
+			group, rest, err := parsePackGroup(tpl, fn, true)
+			if err != nil {
+				return nil, "", err
+			}
+			it.group, tpl = group, rest
+		} else if !strings.ContainsRune("aAZbBhHcCWUsSlLqQiIjJnNvVwfdFxX@%", rune(c)) {
+			return nil, "", fmt.Errorf("Invalid type '%c' in %s", c, fn)
+		}
+		for len(tpl) > 0 && strings.IndexByte("<>!", tpl[0]) >= 0 {
+			switch tpl[0] {
+			case '>':
+				it.big = true
+			case '!':
+				it.bang = true
+			}
+			tpl = tpl[1:]
+		}
+		if c == 'n' || c == 'N' {
+			it.big = true
+		}
+
+		switch {
+		case len(tpl) > 0 && tpl[0] == '*':
+			it.star, it.counted = true, true
+			tpl = tpl[1:]
+		case len(tpl) > 0 && (tpl[0] >= '0' && tpl[0] <= '9' || tpl[0] == '['):
+			bracket := tpl[0] == '['
+			if bracket {
+				tpl = tpl[1:]
+			}
+			n := 0
+			for len(tpl) > 0 && tpl[0] >= '0' && tpl[0] <= '9' {
+				n = n*10 + int(tpl[0]-'0')
+				tpl = tpl[1:]
+			}
+			if bracket {
+				if len(tpl) == 0 || tpl[0] != ']' {
+					return nil, "", fmt.Errorf("Malformed integer in [] in %s", fn)
+				}
+				tpl = tpl[1:]
+			}
+			it.count, it.counted = n, true
+		}
+		items = append(items, it)
+	}
+	if inGroup {
+		return nil, "", fmt.Errorf("No group ending character ')' found in template")
+	}
+	return items, "", nil
+}
+
+// packNumeric reports whether code packs a number, which can prefix a
+// length with /
+func packNumeric(code byte) bool {
+	return strings.IndexByte("cCWUsSlLqQiIjJnNvVw", code) >= 0
+}
+
+// packIntSize returns the size in bytes and signedness of an integer code
+func packIntSize(it packItem) (size int, signed bool) {
+	switch it.code {
+	case 'c':
+		return 1, true
+	case 'C':
+		return 1, false
+	case 's':
+		return 2, true
+	case 'S':
+		return 2, false
+	case 'l':
+		if it.bang {
+			return 8, true
+		}
+		return 4, true
+	case 'L':
+		if it.bang {
+			return 8, false
+		}
+		return 4, false
+	case 'i':
+		return 4, true
+	case 'I':
+		return 4, false
+	case 'q', 'j':
+		return 8, true
+	case 'Q', 'J':
+		return 8, false
+	case 'n', 'v':
+		return 2, it.bang
+	case 'N', 'V':
+		return 4, it.bang
+	}
+	return 0, false
+}
+
+// ============================================================
+// pack
+// ============================================================
+
+type packer struct {
+	buf  []byte
+	args []Arg
+	next int
+}
+
+// Pack packs args by template, as pack TEMPLATE, LIST does.
+func Pack(template string, args []Arg) (string, error) {
+	items, err := parsePackTemplate(template, "pack")
+	if err != nil {
+		return "", err
+	}
+	p := &packer{args: args}
+	if err := p.pack(items, 0); err != nil {
+		return "", err
+	}
+	return string(p.buf), nil
+}
+
+// arg takes the next argument; nil when they ran out
+func (p *packer) arg() Arg {
+	if p.next >= len(p.args) {
+		return nil
+	}
+	a := p.args[p.next]
+	p.next++
+	return a
+}
+
+func (p *packer) left() int { return len(p.args) - p.next }
+
+func packString(a Arg) string {
+	if a == nil {
+		return ""
+	}
+	return a.AsString()
+}
+
+func packInt(a Arg) int64 {
+	if a == nil {
+		return 0
+	}
+	return a.AsInt()
+}
+
+func packFloat(a Arg) float64 {
+	if a == nil {
+		return 0
+	}
+	return a.AsFloat()
+}
+
+// packUint reads an unsigned value; beyond int64 perl keeps it as a float
+func packUint(a Arg) uint64 {
+	if a == nil {
+		return 0
+	}
+	if f := a.AsFloat(); f >= math.MaxInt64 {
+		if f >= math.MaxUint64 {
+			return math.MaxUint64
+		}
+		return uint64(f)
+	}
+	return uint64(a.AsInt())
+}
+
+func (p *packer) putInt(v uint64, size int, big bool) {
+	for k := 0; k < size; k++ {
+		shift := 8 * k
+		if big {
+			shift = 8 * (size - 1 - k)
+		}
+		p.buf = append(p.buf, byte(v>>shift))
+	}
+}
+
+// pack packs items; start is where the innermost group began, for @
+func (p *packer) pack(items []packItem, start int) error {
+	for idx := 0; idx < len(items); idx++ {
+		it := items[idx]
+		if it.slash && idx+1 < len(items) {
+			// n/a*: the length of the next item first
+			idx++
+			next := items[idx]
+			var body packer
+			body.args = p.args[p.next:]
+			if next.group != nil {
+				reps := body.left()
+				if next.counted && !next.star {
+					reps = min(reps, next.count)
+				}
+				for r := 0; r < reps; r++ {
+					if err := body.pack(next.group, 0); err != nil {
+						return err
+					}
+				}
+				p.packNumber(it, []Arg{packCount(reps)})
+			} else {
+				s := packString(body.arg())
+				if next.counted && !next.star && next.count < len(s) {
+					s = s[:next.count]
+				}
+				if next.code == 'Z' {
+					s += "\x00"
+				}
+				body.buf = []byte(s)
+				p.packNumber(it, []Arg{packCount(len(s))})
+			}
+			p.next += body.next
+			p.buf = append(p.buf, body.buf...)
+			continue
+		}
+
+		switch it.code {
+		case '(':
+			reps := it.count
+			if it.star {
+				reps = math.MaxInt
+			}
+			for r := 0; r < reps && (!it.star || p.left() > 0); r++ {
+				if err := p.pack(it.group, len(p.buf)); err != nil {
+					return err
+				}
+			}
+		case 'a', 'A', 'Z':
+			s := packString(p.arg())
+			n := it.count
+			if it.star {
+				n = len(s)
+				if it.code == 'Z' {
+					n++
+				}
+			}
+			pad := byte(0)
+			if it.code == 'A' {
+				pad = ' '
+			}
+			field := make([]byte, n)
+			copy(field, s)
+			for k := min(len(s), n); k < n; k++ {
+				field[k] = pad
+			}
+			if it.code == 'Z' && n > 0 {
+				field[n-1] = 0
+			}
+			p.buf = append(p.buf, field...)
+		case 'b', 'B':
+			s := packString(p.arg())
+			n := it.count
+			if it.star || n > len(s) {
+				n = len(s)
+			}
+			var cur byte
+			for k := 0; k < n; k++ {
+				if s[k]&1 == 1 {
+					if it.code == 'b' {
+						cur |= 1 << (k % 8)
+					} else {
+						cur |= 0x80 >> (k % 8)
+					}
+				}
+				if k%8 == 7 {
+					p.buf = append(p.buf, cur)
+					cur = 0
+				}
+			}
+			if n%8 != 0 {
+				p.buf = append(p.buf, cur)
+			}
+		case 'h', 'H':
+			s := packString(p.arg())
+			n := it.count
+			if it.star || n > len(s) {
+				n = len(s)
+			}
+			var cur byte
+			for k := 0; k < n; k++ {
+				nyb := packHexDigit(s[k])
+				if (k%2 == 0) == (it.code == 'H') {
+					cur |= nyb << 4
+				} else {
+					cur |= nyb
+				}
+				if k%2 == 1 {
+					p.buf = append(p.buf, cur)
+					cur = 0
+				}
+			}
+			if n%2 == 1 {
+				p.buf = append(p.buf, cur)
+			}
+		case 'x':
+			n := it.count
+			if it.star {
+				n = 0
+			}
+			p.buf = append(p.buf, make([]byte, n)...)
+		case 'X':
+			n := it.count
+			if it.star {
+				n = 0
+			}
+			if n > len(p.buf) {
+				return fmt.Errorf("'X' outside of string in pack")
+			}
+			p.buf = p.buf[:len(p.buf)-n]
+		case '@':
+			pos := start + it.count
+			if it.star {
+				pos = len(p.buf)
+			}
+			if pos < len(p.buf) {
+				p.buf = p.buf[:pos]
+			} else {
+				p.buf = append(p.buf, make([]byte, pos-len(p.buf))...)
+			}
+		default:
+			n := it.count
+			if it.star {
+				n = p.left()
+			}
+			vals := make([]Arg, n)
+			for k := range vals {
+				vals[k] = p.arg()
+			}
+			if err := p.packNumber(it, vals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// packNumber packs the numeric item it once for each value
+func (p *packer) packNumber(it packItem, vals []Arg) error {
+	for _, a := range vals {
+		switch it.code {
+		case 'U':
+			p.buf = utf8.AppendRune(p.buf, rune(packInt(a)))
+		case 'W':
+			if r := packInt(a); r < 0x100 {
+				p.buf = append(p.buf, byte(r))
+			} else {
+				p.buf = utf8.AppendRune(p.buf, rune(r))
+			}
+		case 'w':
+			if packFloat(a) < 0 {
+				return fmt.Errorf("Cannot compress negative numbers in pack")
+			}
+			v := packUint(a)
+			var ber []byte
+			ber = append(ber, byte(v&0x7f))
+			for v >>= 7; v > 0; v >>= 7 {
+				ber = append([]byte{byte(v&0x7f) | 0x80}, ber...)
+			}
+			p.buf = append(p.buf, ber...)
+		case 'f':
+			p.putInt(uint64(math.Float32bits(float32(packFloat(a)))), 4, it.big)
+		case 'd', 'F':
+			p.putInt(math.Float64bits(packFloat(a)), 8, it.big)
+		default:
+			size, signed := packIntSize(it)
+			if signed {
+				p.putInt(uint64(packInt(a)), size, it.big)
+			} else {
+				p.putInt(packUint(a), size, it.big)
+			}
+		}
+	}
+	return nil
+}
+
+// packCount is a length written before a / item
+type packCount int
+
+func (c packCount) AsString() string { return fmt.Sprint(int(c)) }
+func (c packCount) AsInt() int64     { return int64(c) }
+func (c packCount) AsFloat() float64 { return float64(c) }
+
+func packHexDigit(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	// perl takes the low bits of any other character
+	return (c + 9) & 0xf
+}
+
+// ============================================================
+// unpack
+// ============================================================
+
+type unpacker struct {
+	data []byte
+	pos  int
+	out  []any
+}
+
+// Unpack splits data by template, as unpack TEMPLATE, EXPR does. The values
+// are int64, uint64 (unsigned ones beyond int64), float64 or string.
+func Unpack(template, data string) ([]any, error) {
+	items, err := parsePackTemplate(template, "unpack")
+	if err != nil {
+		return nil, err
+	}
+	u := &unpacker{data: []byte(data)}
+	if err := u.unpack(items, 0); err != nil {
+		return nil, err
+	}
+	return u.out, nil
+}
+
+func (u *unpacker) left() int { return len(u.data) - u.pos }
+
+func (u *unpacker) getInt(size int, big bool) uint64 {
+	var v uint64
+	for k := 0; k < size; k++ {
+		b := uint64(u.data[u.pos+k])
+		if big {
+			v = v<<8 | b
+		} else {
+			v |= b << (8 * k)
+		}
+	}
+	u.pos += size
+	return v
+}
+
+// unpack reads items; start is where the innermost group began, for @
+func (u *unpacker) unpack(items []packItem, start int) error {
+	for idx := 0; idx < len(items); idx++ {
+		it := items[idx]
+		if it.slash && idx+1 < len(items) {
+			// n/a*: the count of the next item comes from the data
+			mark := len(u.out)
+			one := it
+			one.count, one.star = 1, false
+			if err := u.unpackNumber(one); err != nil {
+				return err
+			}
+			n := 0
+			if len(u.out) > mark {
+				n = int(unpackInt(u.out[mark]))
+				u.out = u.out[:mark]
+			}
+			idx++
+			next := items[idx]
+			next.count, next.star, next.counted = n, false, true
+			if err := u.unpack([]packItem{next}, start); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch it.code {
+		case '%':
+			// %32C*: instead of the values of the next item their sum
+			// in as many bits as the count, 16 without one
+			if idx+1 == len(items) {
+				continue
+			}
+			bits := 16
+			if it.counted && !it.star {
+				bits = it.count
+			}
+			mark := len(u.out)
+			idx++
+			if err := u.unpack(items[idx:idx+1], start); err != nil {
+				return err
+			}
+			sum := checksum(items[idx].code, u.out[mark:], bits)
+			u.out = append(u.out[:mark], sum)
+		case '(':
+			reps := it.count
+			if it.star {
+				reps = math.MaxInt
+			}
+			for r := 0; r < reps && (!it.star || u.left() > 0); r++ {
+				if err := u.unpack(it.group, u.pos); err != nil {
+					return err
+				}
+			}
+		case 'a', 'A', 'Z':
+			n := it.count
+			if it.star || n > u.left() {
+				n = u.left()
+			}
+			field := string(u.data[u.pos : u.pos+n])
+			switch it.code {
+			case 'A':
+				field = strings.TrimRight(field, " \t\n\r\f\x00")
+			case 'Z':
+				if nul := strings.IndexByte(field, 0); nul >= 0 {
+					field = field[:nul]
+					if it.star {
+						n = nul + 1
+					}
+				}
+			}
+			u.pos += n
+			u.out = append(u.out, field)
+		case 'b', 'B':
+			n := it.count
+			if it.star || n > 8*u.left() {
+				n = 8 * u.left()
+			}
+			bits := make([]byte, n)
+			for k := 0; k < n; k++ {
+				b := u.data[u.pos+k/8]
+				var set bool
+				if it.code == 'b' {
+					set = b&(1<<(k%8)) != 0
+				} else {
+					set = b&(0x80>>(k%8)) != 0
+				}
+				bits[k] = '0'
+				if set {
+					bits[k] = '1'
+				}
+			}
+			u.pos += (n + 7) / 8
+			u.out = append(u.out, string(bits))
+		case 'h', 'H':
+			n := it.count
+			if it.star || n > 2*u.left() {
+				n = 2 * u.left()
+			}
+			const digits = "0123456789abcdef"
+			hex := make([]byte, n)
+			for k := 0; k < n; k++ {
+				b := u.data[u.pos+k/2]
+				if (k%2 == 0) == (it.code == 'H') {
+					b >>= 4
+				}
+				hex[k] = digits[b&0xf]
+			}
+			u.pos += (n + 1) / 2
+			u.out = append(u.out, string(hex))
+		case 'x':
+			n := it.count
+			if it.star {
+				n = 0
+			}
+			if n > u.left() {
+				return fmt.Errorf("'x' outside of string in unpack")
+			}
+			u.pos += n
+		case 'X':
+			n := it.count
+			if it.star {
+				n = 0
+			}
+			if n > u.pos {
+				return fmt.Errorf("'X' outside of string in unpack")
+			}
+			u.pos -= n
+		case '@':
+			pos := start + it.count
+			if it.star {
+				pos = len(u.data)
+			}
+			if pos > len(u.data) {
+				return fmt.Errorf("'@' outside of string in unpack")
+			}
+			u.pos = pos
+		default:
+			if err := u.unpackNumber(it); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unpackNumber reads count values of a numeric item, as many as the data
+// holds for *; a value cut short by the end of the data is dropped
+func (u *unpacker) unpackNumber(it packItem) error {
+	n := it.count
+	if it.star {
+		n = math.MaxInt
+	}
+	for k := 0; k < n && u.left() > 0; k++ {
+		switch it.code {
+		case 'U':
+			r, size := utf8.DecodeRune(u.data[u.pos:])
+			u.pos += size
+			u.out = append(u.out, int64(r))
+		case 'W':
+			u.out = append(u.out, int64(u.data[u.pos]))
+			u.pos++
+		case 'w':
+			var v uint64
+			for {
+				if u.left() == 0 {
+					return fmt.Errorf("Unterminated compressed integer in unpack")
+				}
+				b := u.data[u.pos]
+				u.pos++
+				v = v<<7 | uint64(b&0x7f)
+				if b&0x80 == 0 {
+					break
+				}
+			}
+			u.out = append(u.out, unpackUint(v))
+		case 'f':
+			if u.left() < 4 {
+				return nil
+			}
+			u.out = append(u.out, float64(math.Float32frombits(uint32(u.getInt(4, it.big)))))
+		case 'd', 'F':
+			if u.left() < 8 {
+				return nil
+			}
+			u.out = append(u.out, math.Float64frombits(u.getInt(8, it.big)))
+		default:
+			size, signed := packIntSize(it)
+			if u.left() < size {
+				return nil
+			}
+			v := u.getInt(size, it.big)
+			if signed {
+				// sign-extend from size bytes
+				shift := 64 - 8*size
+				u.out = append(u.out, int64(v<<shift)>>shift)
+			} else {
+				u.out = append(u.out, unpackUint(v))
+			}
+		}
+	}
+	return nil
+}
+
+// checksum adds up the values unpacked by an item of code, as %bits does:
+// the set bits of b B, the bytes of a A Z, the numbers otherwise. The sum
+// wraps at bits; floats, and sums wider than 64 bits, are added as
+// float64 and keep the sign of the sum.
+func checksum(code byte, vals []any, bits int) any {
+	var n uint64
+	var f float64
+	for _, v := range vals {
+		switch x := v.(type) {
+		case int64:
+			n += uint64(x)
+			f += float64(x)
+		case uint64:
+			n += x
+			f += float64(x)
+		case float64:
+			f += x
+		case string:
+			for k := 0; k < len(x); k++ {
+				switch code {
+				case 'b', 'B':
+					if x[k] == '1' {
+						n++
+						f++
+					}
+				case 'a', 'A', 'Z':
+					n += uint64(x[k])
+					f += float64(x[k])
+				}
+			}
+		}
+	}
+	if code == 'f' || code == 'd' || code == 'F' || bits > 64 {
+		scale := math.Ldexp(1, bits)
+		_, frac := math.Modf(f / scale)
+		return frac * scale
+	}
+	if bits < 64 {
+		n &= 1<<bits - 1
+	}
+	return unpackUint(n)
+}
+
+// unpackUint keeps an unsigned value an int64 when it fits
+func unpackUint(v uint64) any {
+	if v <= math.MaxInt64 {
+		return int64(v)
+	}
+	return v
+}
+
+// unpackInt reads an unpacked value as a count
+func unpackInt(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
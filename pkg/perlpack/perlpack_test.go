@@ -0,0 +1,170 @@
+package perlpack
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// arg is a test scalar: a number when it parses as one
+type arg string
+
+func (a arg) AsString() string { return string(a) }
+func (a arg) AsInt() int64 {
+	var n int64
+	fmt.Sscan(string(a), &n)
+	return n
+}
+func (a arg) AsFloat() float64 {
+	var f float64
+	fmt.Sscan(string(a), &f)
+	return f
+}
+
+func args(vals ...string) []Arg {
+	out := make([]Arg, len(vals))
+	for i, v := range vals {
+		out[i] = arg(v)
+	}
+	return out
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		template string
+		args     []string
+		want     string
+	}{
+		{"N n V v", []string{"1", "2", "3", "4"}, "\x00\x00\x00\x01\x00\x02\x03\x00\x00\x00\x04\x00"},
+		{"c C s S", []string{"-1", "255", "-2", "65535"}, "\xff\xff\xfe\xff\xff\xff"},
+		{"l> L< q Q>", []string{"-1", "1", "-2", "2"}, "\xff\xff\xff\xff\x01\x00\x00\x00\xfe\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x02"},
+		{"a3 A3 Z3", []string{"x", "y", "abcdef"}, "x\x00\x00y  ab\x00"},
+		{"a* A* Z*", []string{"ab", "cd", "ef"}, "abcdef\x00"},
+		{"H4 h* H", []string{"12ab", "12", "f"}, "\x12\xab\x21\xf0"},
+		{"b8 B*", []string{"10000000", "0100000110"}, "\x01\x41\x80"},
+		{"C*", []string{"72", "105"}, "Hi"},
+		{"n3", []string{"1"}, "\x00\x01\x00\x00\x00\x00"},
+		{"w w w", []string{"0", "127", "300"}, "\x00\x7f\x82\x2c"},
+		{"U W", []string{"233", "65"}, "\xc3\xa9A"},
+		{"f> d>", []string{"1.5", "-2"}, "\x3f\xc0\x00\x00\xc0\x00\x00\x00\x00\x00\x00\x00"},
+		{"a x2 a X a", []string{"a", "b", "c"}, "a\x00\x00c"},
+		{"a @4 a", []string{"a", "b"}, "a\x00\x00\x00b"},
+		{"(A2 n)2", []string{"ab", "1", "cd", "2"}, "ab\x00\x01cd\x00\x02"},
+		{"(C)*", []string{"1", "2", "3"}, "\x01\x02\x03"},
+		{"n/a* C/a3", []string{"hello", "abcdef"}, "\x00\x05hello\x03abc"},
+		{"C/(a2)", []string{"xy", "zw"}, "\x02xyzw"},
+		{"N # big-endian\n n", []string{"1", "2"}, "\x00\x00\x00\x01\x00\x02"},
+		{"C[3]", []string{"1", "2", "3"}, "\x01\x02\x03"},
+		{"Q", []string{"18446744073709551615"}, "\xff\xff\xff\xff\xff\xff\xff\xff"},
+	}
+	for _, tt := range tests {
+		got, err := Pack(tt.template, args(tt.args...))
+		if err != nil {
+			t.Errorf("Pack(%q): %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Pack(%q, %q) = %q, want %q", tt.template, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	tests := []struct {
+		template string
+		data     string
+		want     []any
+	}{
+		{"N n V v", "\x00\x00\x00\x01\x00\x02\x03\x00\x00\x00\x04\x00", []any{int64(1), int64(2), int64(3), int64(4)}},
+		{"c C s S", "\xff\xff\xfe\xff\xff\xff", []any{int64(-1), int64(255), int64(-2), int64(65535)}},
+		{"n! N!", "\xff\xfe\xff\xff\xff\xfd", []any{int64(-2), int64(-3)}},
+		{"Q", "\xff\xff\xff\xff\xff\xff\xff\xff", []any{uint64(18446744073709551615)}},
+		{"a3 A3 Z*", "x\x00\x00y  ab\x00cd", []any{"x\x00\x00", "y", "ab"}},
+		{"H* ", "\x12\xab", []any{"12ab"}},
+		{"h3 b8 B*", "\x21\xf0\x01\x41", []any{"120", "10000000", "01000001"}},
+		{"C*", "Hi", []any{int64(72), int64(105)}},
+		{"w*", "\x00\x7f\x82\x2c", []any{int64(0), int64(127), int64(300)}},
+		{"U*", "\xc3\xa9A", []any{int64(233), int64(65)}},
+		{"f> d>", "\x3f\xc0\x00\x00\xc0\x00\x00\x00\x00\x00\x00\x00", []any{1.5, -2.0}},
+		{"a x2 a X a", "a\x00\x00bc", []any{"a", "b", "b"}},
+		{"(A2 n)*", "ab\x00\x01cd\x00\x02", []any{"ab", int64(1), "cd", int64(2)}},
+		{"n/a* C/a*", "\x00\x05hello\x03abcdef", []any{"hello", "abc"}},
+		{"C/(a2) a*", "\x02xyzwrest", []any{"xy", "zw", "rest"}},
+		{"a2 @0 a1 @* a", "abc", []any{"ab", "a", ""}},
+		{"N2", "\x00\x00\x00\x01\x00\x00", []any{int64(1)}},
+		{"%32C*", "abc", []any{int64(294)}},
+		{"%C*", strings.Repeat("\xff", 300), []any{int64(10964)}},
+		{"%3C* ", "\x05\x05", []any{int64(2)}},
+		{"%32c*", "\xff\xff", []any{int64(4294967294)}},
+		{"%64Q2", "\xff\xff\xff\xff\xff\xff\xff\xff\x05\x00\x00\x00\x00\x00\x00\x00", []any{int64(4)}},
+		{"%b16 %32a2 C", "\x07\x01ab\x09", []any{int64(4), int64(195), int64(9)}},
+		{"%32d>2", "\x3f\xf8\x00\x00\x00\x00\x00\x00\x40\x02\x00\x00\x00\x00\x00\x00", []any{3.75}},
+		{"%65C", "\x01", []any{1.0}},
+	}
+	for _, tt := range tests {
+		got, err := Unpack(tt.template, tt.data)
+		if err != nil {
+			t.Errorf("Unpack(%q): %v", tt.template, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Unpack(%q, %q) = %#v, want %#v", tt.template, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		unpack   bool
+		want     string
+	}{
+		{"y", false, "Invalid type 'y' in pack"},
+		{"N y", true, "Invalid type 'y' in unpack"},
+		{"(N", false, "No group ending character ')' found in template"},
+		{"N)", true, "Mismatched brackets in template"},
+		{"a/a*", false, "'/' must follow a numeric type in pack"},
+		{"X2", false, "'X' outside of string in pack"},
+		{"x9", true, "'x' outside of string in unpack"},
+		{"%32C", false, "'%' may not be used in pack"},
+	}
+	for _, tt := range tests {
+		var err error
+		if tt.unpack {
+			_, err = Unpack(tt.template, "abc")
+		} else {
+			_, err = Pack(tt.template, args("-1"))
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %q", tt.template, err, tt.want)
+		}
+	}
+	if _, err := Pack("w", args("-1")); err == nil || err.Error() != "Cannot compress negative numbers in pack" {
+		t.Errorf("w -1: got error %v", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	var src strings.Builder
+	files, err := fs.ReadDir(Sources, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), "_test.go") {
+			t.Errorf("Sources hold the test %s", f.Name())
+		}
+		data, _ := fs.ReadFile(Sources, f.Name())
+		if !strings.Contains("\n"+string(data), "\npackage perlpack\n") {
+			t.Errorf("%s is not of the package", f.Name())
+		}
+		src.Write(data)
+	}
+	for _, decl := range []string{"func Pack(", "func Unpack(", "type Arg interface", "var Sources embed.FS"} {
+		if !strings.Contains(src.String(), decl) {
+			t.Errorf("Sources are missing %s", decl)
+		}
+	}
+}
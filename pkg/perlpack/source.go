@@ -0,0 +1,9 @@
+package perlpack
+
+import "embed"
+
+// Sources are the files of the package, for a generated program that
+// imports it: perlc writes them into the module it builds the program in.
+//
+//go:embed perlpack.go source.go
+var Sources embed.FS
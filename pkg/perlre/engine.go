@@ -0,0 +1,1054 @@
+package perlre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The backtracking engine: a parser building a tree of reNodes and a
+// matcher walking it with continuations, so a failure anywhere resumes
+// the most recent choice point (the next alternative, one repetition
+// less, ...), which is how perl finds its leftmost-first match.
+
+type reOp uint8
+
+const (
+	reOpEmpty    reOp = iota
+	reOpChar          // a character
+	reOpAny           // .
+	reOpSet           // [...], \d, \w, ...
+	reOpBOL           // ^
+	reOpEOL           // $
+	reOpBOT           // \A
+	reOpEOT           // \z
+	reOpEOTNL         // \Z
+	reOpWordB         // \b
+	reOpNotWordB      // \B
+	reOpConcat        //
+	reOpAlt           // a|b
+	reOpGroup         // (...), capturing when cap > 0
+	reOpRepeat        // *, +, ?, {n,m}
+	reOpBackref       // \1, \k<name>
+	reOpLook          // (?=...), (?!...), (?<=...), (?<!...)
+	reOpAtomic        // (?>...) and possessive quantifiers
+	reOpKeep          // \K
+)
+
+type reNode struct {
+	op      reOp
+	r       rune
+	set     *reSet
+	subs    []*reNode
+	fold    bool // /i, for characters, sets and backreferences
+	multi   bool // /m, for ^ and $
+	dotNL   bool // /s, for .
+	cap     int
+	refs    []int // backreference: the leftmost set group is used
+	refName string
+	min     int
+	max     int // < 0 for no limit
+	lazy    bool
+	neg     bool // negative lookaround
+	behind  bool
+}
+
+type reProg struct {
+	root     *reNode
+	ncap     int
+	names    []string
+	anchored bool
+}
+
+// reSet is a character class; nots are negated classes inside it ([\D])
+type reSet struct {
+	neg    bool
+	ranges []rune
+	tables []*unicode.RangeTable
+	nots   []*reSet
+}
+
+func (s *reSet) has(r rune) bool {
+	for i := 0; i < len(s.ranges); i += 2 {
+		if r >= s.ranges[i] && r <= s.ranges[i+1] {
+			return true
+		}
+	}
+	for _, t := range s.tables {
+		if unicode.Is(t, r) {
+			return true
+		}
+	}
+	for _, n := range s.nots {
+		if n.match(r, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *reSet) match(r rune, fold bool) bool {
+	in := s.has(r)
+	if !in && fold {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if s.has(f) {
+				in = true
+				break
+			}
+		}
+	}
+	return in != s.neg
+}
+
+func (s *reSet) add(o *reSet) {
+	if o.neg {
+		s.nots = append(s.nots, o)
+		return
+	}
+	s.ranges = append(s.ranges, o.ranges...)
+	s.tables = append(s.tables, o.tables...)
+	s.nots = append(s.nots, o.nots...)
+}
+
+// reClasses are the backslash classes; upper case letters negate them
+var reClasses = map[byte][]rune{
+	'd': {'0', '9'},
+	'w': {'0', '9', 'A', 'Z', '_', '_', 'a', 'z'},
+	's': {'\t', '\n', '\f', '\r', ' ', ' '},
+	'h': reHSpace,
+	'v': reVSpace,
+}
+
+// rePosix are the [:name:] classes
+var rePosix = map[string][]rune{
+	"alpha":  {'A', 'Z', 'a', 'z'},
+	"digit":  {'0', '9'},
+	"alnum":  {'0', '9', 'A', 'Z', 'a', 'z'},
+	"upper":  {'A', 'Z'},
+	"lower":  {'a', 'z'},
+	"space":  {'\t', '\r', ' ', ' '},
+	"blank":  {'\t', '\t', ' ', ' '},
+	"punct":  {'!', '/', ':', '@', '[', '`', '{', '~'},
+	"xdigit": {'0', '9', 'A', 'F', 'a', 'f'},
+	"word":   {'0', '9', 'A', 'Z', '_', '_', 'a', 'z'},
+	"cntrl":  {0, 31, 127, 127},
+	"print":  {' ', '~'},
+	"graph":  {'!', '~'},
+	"ascii":  {0, 127},
+}
+
+// reProperties are the perl names of \p{...} that package unicode lacks
+var reProperties = map[string][]*unicode.RangeTable{
+	"Alpha":       {unicode.Letter},
+	"Alnum":       {unicode.Letter, unicode.Nd},
+	"Digit":       {unicode.Nd},
+	"Upper":       {unicode.Lu},
+	"Lower":       {unicode.Ll},
+	"Punct":       {unicode.P},
+	"Space":       {unicode.White_Space},
+	"Word":        {unicode.Letter, unicode.M, unicode.Nd, unicode.Pc},
+	"XPosixAlpha": {unicode.Letter},
+	"XPosixDigit": {unicode.Nd},
+}
+
+// reSyntaxError is raised (as a panic) by the parser and returned by
+// reParse
+type reSyntaxError struct {
+	err error
+}
+
+type reParser struct {
+	src   string
+	pos   int
+	ncap  int
+	total int // capture groups in the whole pattern, for \10 vs octal
+	names []string
+	refs  []*reNode
+}
+
+func reParse(src string) (prog *reProg, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(reSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, se.err
+		}
+	}()
+	p := &reParser{src: src, names: []string{""}, total: reCountGroups(src)}
+	root := p.alt(reFlags{})
+	if p.pos < len(src) {
+		p.fail("Unmatched )", p.pos+1)
+	}
+	for _, ref := range p.refs {
+		if ref.refName == "" {
+			if ref.refs[0] > p.ncap {
+				p.fail("Reference to nonexistent group", len(src))
+			}
+			continue
+		}
+		for n, name := range p.names {
+			if name == ref.refName {
+				ref.refs = append(ref.refs, n)
+			}
+		}
+		if len(ref.refs) == 0 {
+			p.fail("Reference to nonexistent named group", len(src))
+		}
+	}
+	prog = &reProg{root: root, ncap: p.ncap, names: p.names}
+	first := root
+	if first.op == reOpConcat {
+		first = first.subs[0]
+	}
+	prog.anchored = first.op == reOpBOT || (first.op == reOpBOL && !first.multi)
+	return prog, nil
+}
+
+// reCountGroups counts the capture groups of a pattern
+func reCountGroups(src string) int {
+	n := 0
+	class := false
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '(':
+			rest := src[i+1:]
+			if !strings.HasPrefix(rest, "?") || (reHasPrefix(rest, "?<", "?'", "?P<") && !reHasPrefix(rest, "?<=", "?<!")) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+type reFlags struct {
+	i, m, s, x, n bool
+}
+
+func (p *reParser) fail(msg string, at int) {
+	if at > len(p.src) {
+		at = len(p.src)
+	}
+	panic(reSyntaxError{fmt.Errorf("%s in regex; marked by <-- HERE in m/%s <-- HERE %s/", msg, p.src[:at], p.src[at:])})
+}
+
+func (p *reParser) more() bool {
+	return p.pos < len(p.src)
+}
+
+func (p *reParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *reParser) alt(f reFlags) *reNode {
+	var branches []*reNode
+	for {
+		branches = append(branches, p.concat(&f))
+		if !p.peek("|") {
+			break
+		}
+		p.pos++
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return &reNode{op: reOpAlt, subs: branches}
+}
+
+func (p *reParser) concat(f *reFlags) *reNode {
+	var items []*reNode
+	for p.more() && !p.peek("|") && !p.peek(")") {
+		if f.x && p.skipSpace() {
+			continue
+		}
+		if atom := p.atom(f); atom != nil {
+			items = append(items, p.repeat(atom))
+		}
+	}
+	switch len(items) {
+	case 0:
+		return &reNode{op: reOpEmpty}
+	case 1:
+		return items[0]
+	}
+	return &reNode{op: reOpConcat, subs: items}
+}
+
+// skipSpace skips the white space or comment at p.pos under /x
+func (p *reParser) skipSpace() bool {
+	switch p.src[p.pos] {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		p.pos++
+		return true
+	case '#':
+		if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+			p.pos += end + 1
+		} else {
+			p.pos = len(p.src)
+		}
+		return true
+	}
+	return false
+}
+
+func (p *reParser) repeat(atom *reNode) *reNode {
+	for p.more() {
+		min, max, n := p.quantifier()
+		if n == 0 {
+			break
+		}
+		p.pos += n
+		node := &reNode{op: reOpRepeat, subs: []*reNode{atom}, min: min, max: max}
+		switch {
+		case p.peek("?"):
+			node.lazy = true
+			p.pos++
+		case p.peek("+"):
+			node = &reNode{op: reOpAtomic, subs: []*reNode{node}}
+			p.pos++
+		}
+		atom = node
+	}
+	return atom
+}
+
+// quantifier reads the quantifier at p.pos without consuming it
+func (p *reParser) quantifier() (min, max, n int) {
+	switch p.src[p.pos] {
+	case '*':
+		return 0, -1, 1
+	case '+':
+		return 1, -1, 1
+	case '?':
+		return 0, 1, 1
+	case '{':
+		n = reCounted(p.src[p.pos:])
+		if n == 0 {
+			return 0, 0, 0
+		}
+		body := p.src[p.pos+1 : p.pos+n-1]
+		lo, hi, comma := strings.Cut(body, ",")
+		min, _ = strconv.Atoi(lo)
+		max = min
+		if comma {
+			max = -1
+			if hi != "" {
+				max, _ = strconv.Atoi(hi)
+			}
+		}
+		if max >= 0 && max < min {
+			p.fail("Can't do {n,m} with n > m", p.pos+n)
+		}
+		return min, max, n
+	}
+	return 0, 0, 0
+}
+
+func (p *reParser) atom(f *reFlags) *reNode {
+	switch p.src[p.pos] {
+	case '(':
+		return p.group(f)
+	case '[':
+		return &reNode{op: reOpSet, set: p.class(), fold: f.i}
+	case '.':
+		p.pos++
+		return &reNode{op: reOpAny, dotNL: f.s}
+	case '^':
+		p.pos++
+		return &reNode{op: reOpBOL, multi: f.m}
+	case '$':
+		p.pos++
+		return &reNode{op: reOpEOL, multi: f.m}
+	case '\\':
+		return p.escape(f)
+	case '*', '+', '?':
+		p.fail("Quantifier follows nothing", p.pos+1)
+	case '{':
+		if reCounted(p.src[p.pos:]) > 0 {
+			p.fail("Quantifier follows nothing", p.pos+1)
+		}
+	}
+	r, w := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += w
+	return p.char(r, f)
+}
+
+func (p *reParser) char(r rune, f *reFlags) *reNode {
+	return &reNode{op: reOpChar, r: r, fold: f.i && unicode.SimpleFold(r) != r}
+}
+
+func (p *reParser) group(f *reFlags) *reNode {
+	start := p.pos
+	if what := reUnsupported(p.src, start); what != "" {
+		panic(reSyntaxError{&UnsupportedError{Construct: what, Pattern: p.src, Offset: start}})
+	}
+	p.pos++
+	node := &reNode{op: reOpGroup}
+	inner := *f
+	switch {
+	case !p.peek("?"):
+		if !f.n {
+			node.cap = p.newGroup("")
+		}
+	case p.peek("?#"):
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			p.fail("Sequence (?#... not terminated", len(p.src))
+		}
+		p.pos += end + 1
+		return nil
+	case p.peek("?:"):
+		p.pos += 2
+	case p.peek("?="), p.peek("?!"):
+		node = &reNode{op: reOpLook, neg: p.peek("?!")}
+		p.pos += 2
+	case p.peek("?<="), p.peek("?<!"):
+		node = &reNode{op: reOpLook, neg: p.peek("?<!"), behind: true}
+		p.pos += 3
+	case p.peek("?>"):
+		node = &reNode{op: reOpAtomic}
+		p.pos += 2
+	case p.peek("?P="):
+		p.pos += 3
+		ref := p.namedRef(")")
+		return ref
+	case p.peek("?<"), p.peek("?'"), p.peek("?P<"):
+		if p.peek("?P") {
+			p.pos++
+		}
+		close := ">"
+		if p.peek("?'") {
+			close = "'"
+		}
+		p.pos += 2
+		end := strings.Index(p.src[p.pos:], close)
+		if end <= 0 {
+			p.fail("Sequence (?<... not terminated", p.pos)
+		}
+		node.cap = p.newGroup(p.src[p.pos : p.pos+end])
+		p.pos += end + 1
+	default:
+		p.pos++
+		end := strings.IndexAny(p.src[p.pos:], ":)")
+		if end < 0 {
+			p.fail("Sequence (? incomplete", len(p.src))
+		}
+		p.flags(&inner, p.src[p.pos:p.pos+end])
+		p.pos += end
+		if p.peek(")") {
+			p.pos++
+			*f = inner
+			return nil
+		}
+		p.pos++
+	}
+	node.subs = []*reNode{p.alt(inner)}
+	if !p.peek(")") {
+		p.fail("Unmatched (", start+1)
+	}
+	p.pos++
+	return node
+}
+
+func (p *reParser) newGroup(name string) int {
+	p.ncap++
+	p.names = append(p.names, name)
+	return p.ncap
+}
+
+// flags applies the letters of (?imsx-imsx) or (?^...)
+func (p *reParser) flags(f *reFlags, letters string) {
+	on := true
+	for i, c := range letters {
+		switch c {
+		case '^':
+			*f = reFlags{}
+		case '-':
+			on = false
+		case 'i':
+			f.i = on
+		case 'm':
+			f.m = on
+		case 's':
+			f.s = on
+		case 'x':
+			f.x = on
+		case 'n':
+			f.n = on
+		case 'a', 'u', 'l', 'd', 'p', 'o':
+		default:
+			p.fail(fmt.Sprintf("Sequence (?%s...) not recognized", letters[:i+1]), p.pos+i+1)
+		}
+	}
+}
+
+func (p *reParser) namedRef(close string) *reNode {
+	end := strings.Index(p.src[p.pos:], close)
+	if end <= 0 {
+		p.fail("Sequence \\k... not terminated", p.pos)
+	}
+	ref := &reNode{op: reOpBackref, refName: p.src[p.pos : p.pos+end]}
+	p.pos += end + len(close)
+	p.refs = append(p.refs, ref)
+	return ref
+}
+
+func (p *reParser) numberedRef(n int, at int) *reNode {
+	if n <= 0 {
+		p.fail("Reference to nonexistent or unclosed group", at)
+	}
+	ref := &reNode{op: reOpBackref, refs: []int{n}}
+	p.refs = append(p.refs, ref)
+	return ref
+}
+
+func (p *reParser) escape(f *reFlags) *reNode {
+	start := p.pos
+	if p.pos+1 >= len(p.src) {
+		p.fail("Trailing \\", len(p.src))
+	}
+	if what := reUnsupported(p.src, start); what != "" {
+		panic(reSyntaxError{&UnsupportedError{Construct: what, Pattern: p.src, Offset: start}})
+	}
+	e := p.src[p.pos+1]
+	p.pos += 2
+	var node *reNode
+	switch e {
+	case 'd', 'w', 's', 'h', 'v', 'D', 'W', 'S', 'H', 'V', 'p', 'P':
+		p.pos--
+		return &reNode{op: reOpSet, set: p.classEscape(), fold: f.i}
+	case 'N':
+		if !p.peek("{") {
+			return &reNode{op: reOpSet, set: &reSet{neg: true, ranges: []rune{'\n', '\n'}}}
+		}
+	case 'R':
+		crlf := &reNode{op: reOpConcat, subs: []*reNode{{op: reOpChar, r: '\r'}, {op: reOpChar, r: '\n'}}}
+		return &reNode{op: reOpAlt, subs: []*reNode{crlf, {op: reOpSet, set: &reSet{ranges: reVSpace}}}}
+	case 'b':
+		return &reNode{op: reOpWordB}
+	case 'B':
+		return &reNode{op: reOpNotWordB}
+	case 'A':
+		return &reNode{op: reOpBOT}
+	case 'z':
+		return &reNode{op: reOpEOT}
+	case 'Z':
+		return &reNode{op: reOpEOTNL}
+	case 'K':
+		return &reNode{op: reOpKeep}
+	case 'k':
+		for _, pair := range []string{"<>", "''", "{}"} {
+			if p.peek(pair[:1]) {
+				p.pos++
+				node = p.namedRef(pair[1:])
+				node.fold = f.i
+				return node
+			}
+		}
+		p.fail("Sequence \\k... not terminated", p.pos)
+	case 'g':
+		braced := p.peek("{")
+		if braced {
+			p.pos++
+		}
+		end := p.pos
+		for end < len(p.src) && (p.src[end] == '-' || p.src[end] >= '0' && p.src[end] <= '9') {
+			end++
+		}
+		if end == p.pos {
+			if !braced {
+				p.fail("Unterminated \\g... pattern", p.pos)
+			}
+			node = p.namedRef("}")
+		} else {
+			n, _ := strconv.Atoi(p.src[p.pos:end])
+			if n < 0 {
+				n += p.ncap + 1
+			}
+			p.pos = end
+			if braced {
+				if !p.peek("}") {
+					p.fail("Unterminated \\g{...} pattern", p.pos)
+				}
+				p.pos++
+			}
+			node = p.numberedRef(n, start)
+		}
+		node.fold = f.i
+		return node
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		end := p.pos
+		for end < len(p.src) && p.src[end] >= '0' && p.src[end] <= '9' {
+			end++
+		}
+		n, _ := strconv.Atoi(p.src[p.pos-1 : end])
+		if n <= 9 || n <= p.total {
+			p.pos = end
+			node = p.numberedRef(n, start)
+			node.fold = f.i
+			return node
+		}
+	}
+	p.pos--
+	return p.char(p.runeEscape(), f)
+}
+
+// runeEscape reads the escape at p.pos (just after the backslash) that
+// stands for one character
+func (p *reParser) runeEscape() rune {
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'f':
+		return '\f'
+	case 'a':
+		return '\a'
+	case 'e':
+		return 0x1B
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		end := p.pos - 1
+		for end < len(p.src) && end < p.pos+2 && p.src[end] >= '0' && p.src[end] <= '7' {
+			end++
+		}
+		v, _ := strconv.ParseInt(p.src[p.pos-1:end], 8, 32)
+		p.pos = end
+		return rune(v)
+	case 'o', 'x', 'N':
+		base, digits := 16, "0123456789abcdefABCDEF"
+		if c == 'o' {
+			base, digits = 8, "01234567"
+		}
+		if p.peek("{") {
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 {
+				p.fail("Missing right brace on \\"+string(c)+"{}", p.pos)
+			}
+			body := strings.TrimPrefix(p.src[p.pos+1:p.pos+end], "U+")
+			p.pos += end + 1
+			v, _ := strconv.ParseInt(body, base, 32)
+			return rune(v)
+		}
+		end := p.pos
+		for end < len(p.src) && end < p.pos+2 && strings.IndexByte(digits, p.src[end]) >= 0 {
+			end++
+		}
+		v, _ := strconv.ParseInt(p.src[p.pos:end], base, 32)
+		p.pos = end
+		return rune(v)
+	case 'c':
+		if !p.more() {
+			p.fail("Character following \"\\c\" must be printable ASCII", p.pos)
+		}
+		r := unicode.ToUpper(rune(p.src[p.pos])) ^ 64
+		p.pos++
+		return r
+	}
+	if c >= utf8.RuneSelf {
+		r, w := utf8.DecodeRuneInString(p.src[p.pos-1:])
+		p.pos += w - 1
+		return r
+	}
+	return rune(c)
+}
+
+// classEscape reads \d, \W, \h, \p{L}, ... at p.pos (after the backslash)
+func (p *reParser) classEscape() *reSet {
+	c := p.src[p.pos]
+	p.pos++
+	if c == 'p' || c == 'P' {
+		return p.property(c == 'P')
+	}
+	lower := c | 0x20
+	return &reSet{neg: c != lower, ranges: reClasses[lower]}
+}
+
+func (p *reParser) property(neg bool) *reSet {
+	start := p.pos
+	name := ""
+	if p.peek("{") {
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			p.fail("Missing right brace on \\p{}", p.pos)
+		}
+		name = strings.TrimSpace(p.src[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+	} else if p.more() {
+		name = p.src[p.pos : p.pos+1]
+		p.pos++
+	}
+	if strings.HasPrefix(name, "^") {
+		neg = !neg
+		name = name[1:]
+	}
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "Is"), "In")
+	set := &reSet{neg: neg}
+	if tables, ok := reProperties[name]; ok {
+		set.tables = tables
+	} else if t := reUnicodeTable(name); t != nil {
+		set.tables = []*unicode.RangeTable{t}
+	} else {
+		p.fail(fmt.Sprintf("Can't find Unicode property definition \"%s\"", name), start)
+	}
+	return set
+}
+
+func reUnicodeTable(name string) *unicode.RangeTable {
+	if name == "L&" {
+		name = "LC"
+	}
+	for _, tables := range []map[string]*unicode.RangeTable{unicode.Categories, unicode.Scripts, unicode.Properties} {
+		if t, ok := tables[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// class reads a bracketed character class
+func (p *reParser) class() *reSet {
+	start := p.pos
+	p.pos++
+	set := &reSet{}
+	if p.peek("^") {
+		set.neg = true
+		p.pos++
+	}
+	for first := true; ; first = false {
+		if !p.more() {
+			p.fail("Unmatched [", start+1)
+		}
+		if p.peek("]") && !first {
+			p.pos++
+			return set
+		}
+		if p.peek("[:") {
+			if end := strings.Index(p.src[p.pos:], ":]"); end > 0 {
+				name := p.src[p.pos+2 : p.pos+end]
+				neg := strings.HasPrefix(name, "^")
+				ranges, ok := rePosix[strings.TrimPrefix(name, "^")]
+				if !ok {
+					p.fail(fmt.Sprintf("POSIX class [:%s:] unknown", name), p.pos+end+2)
+				}
+				set.add(&reSet{neg: neg, ranges: ranges})
+				p.pos += end + 2
+				continue
+			}
+		}
+		lo, sub := p.classAtom()
+		if sub != nil {
+			set.add(sub)
+			continue
+		}
+		hi := lo
+		if p.peek("-") && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			p.pos++
+			var sub2 *reSet
+			if hi, sub2 = p.classAtom(); sub2 != nil {
+				set.ranges = append(set.ranges, lo, lo, '-', '-')
+				set.add(sub2)
+				continue
+			}
+			if hi < lo {
+				p.fail("Invalid [] range", p.pos)
+			}
+		}
+		set.ranges = append(set.ranges, lo, hi)
+	}
+}
+
+// classAtom reads one character of a class, or a class escape
+func (p *reParser) classAtom() (rune, *reSet) {
+	if !p.peek("\\") || p.pos+1 >= len(p.src) {
+		r, w := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += w
+		return r, nil
+	}
+	p.pos++
+	switch p.src[p.pos] {
+	case 'd', 'w', 's', 'h', 'v', 'D', 'W', 'S', 'H', 'V', 'p', 'P':
+		return 0, p.classEscape()
+	case 'b':
+		p.pos++
+		return '\b', nil
+	}
+	return p.runeEscape(), nil
+}
+
+// reMatcher runs a program against one subject string
+type reMatcher struct {
+	s    string
+	caps []int
+	keep int
+}
+
+func (prog *reProg) exec(s string, pos int) []int {
+	m := &reMatcher{s: s, caps: make([]int, 2*(prog.ncap+1))}
+	for start := pos; start <= len(s); {
+		for i := range m.caps {
+			m.caps[i] = -1
+		}
+		m.keep = -1
+		end := -1
+		if m.match(prog.root, start, func(j int) bool { end = j; return true }) {
+			m.caps[0], m.caps[1] = start, end
+			if m.keep >= 0 {
+				m.caps[0] = m.keep
+			}
+			return m.caps
+		}
+		if prog.anchored || start == len(s) {
+			break
+		}
+		_, w := utf8.DecodeRuneInString(s[start:])
+		start += w
+	}
+	return nil
+}
+
+// match matches n at i and calls k with the end of every way it matches,
+// most preferred first, until k accepts one
+func (m *reMatcher) match(n *reNode, i int, k func(int) bool) bool {
+	s := m.s
+	switch n.op {
+	case reOpEmpty:
+		return k(i)
+	case reOpChar, reOpAny, reOpSet:
+		if j, ok := m.one(n, i); ok {
+			return k(j)
+		}
+		return false
+	case reOpBOL:
+		return (i == 0 || n.multi && s[i-1] == '\n') && k(i)
+	case reOpEOL:
+		if n.multi {
+			return (i == len(s) || s[i] == '\n') && k(i)
+		}
+		return (i == len(s) || i == len(s)-1 && s[i] == '\n') && k(i)
+	case reOpBOT:
+		return i == 0 && k(i)
+	case reOpEOT:
+		return i == len(s) && k(i)
+	case reOpEOTNL:
+		return (i == len(s) || i == len(s)-1 && s[i] == '\n') && k(i)
+	case reOpWordB, reOpNotWordB:
+		boundary := m.isWord(i-1) != m.isWord(i)
+		return boundary == (n.op == reOpWordB) && k(i)
+	case reOpConcat:
+		return m.seq(n.subs, i, k)
+	case reOpAlt:
+		for _, sub := range n.subs {
+			if m.match(sub, i, k) {
+				return true
+			}
+		}
+		return false
+	case reOpGroup:
+		if n.cap == 0 {
+			return m.match(n.subs[0], i, k)
+		}
+		c := 2 * n.cap
+		return m.match(n.subs[0], i, func(j int) bool {
+			start, end := m.caps[c], m.caps[c+1]
+			m.caps[c], m.caps[c+1] = i, j
+			if k(j) {
+				return true
+			}
+			m.caps[c], m.caps[c+1] = start, end
+			return false
+		})
+	case reOpRepeat:
+		if sub := n.subs[0]; sub.op == reOpChar || sub.op == reOpAny || sub.op == reOpSet {
+			return m.repeatOne(n, i, k)
+		}
+		return m.repeat(n, i, 0, k)
+	case reOpBackref:
+		start, end := -1, -1
+		for _, g := range n.refs {
+			if m.caps[2*g+1] >= 0 {
+				start, end = m.caps[2*g], m.caps[2*g+1]
+				break
+			}
+		}
+		if start < 0 {
+			return false
+		}
+		if j, ok := m.same(s[start:end], i, n.fold); ok {
+			return k(j)
+		}
+		return false
+	case reOpLook:
+		saved := m.save()
+		found := false
+		if n.behind {
+			for start := i; start >= 0 && !found; start-- {
+				if start < len(s) && !utf8.RuneStart(s[start]) {
+					continue
+				}
+				found = m.match(n.subs[0], start, func(j int) bool { return j == i })
+			}
+		} else {
+			found = m.match(n.subs[0], i, func(int) bool { return true })
+		}
+		if found != n.neg && k(i) {
+			return true
+		}
+		m.restore(saved)
+		return false
+	case reOpAtomic:
+		saved := m.save()
+		end := -1
+		if m.match(n.subs[0], i, func(j int) bool { end = j; return true }) && k(end) {
+			return true
+		}
+		m.restore(saved)
+		return false
+	case reOpKeep:
+		keep := m.keep
+		m.keep = i
+		if k(i) {
+			return true
+		}
+		m.keep = keep
+		return false
+	}
+	return false
+}
+
+func (m *reMatcher) seq(nodes []*reNode, i int, k func(int) bool) bool {
+	if len(nodes) == 0 {
+		return k(i)
+	}
+	return m.match(nodes[0], i, func(j int) bool { return m.seq(nodes[1:], j, k) })
+}
+
+// repeat tries one more iteration of n (count done so far) or stopping,
+// in the order greediness asks for
+func (m *reMatcher) repeat(n *reNode, i, count int, k func(int) bool) bool {
+	again := func() bool {
+		if n.max >= 0 && count >= n.max {
+			return false
+		}
+		return m.match(n.subs[0], i, func(j int) bool {
+			if j == i && count >= n.min {
+				return false // an empty iteration would loop forever
+			}
+			return m.repeat(n, j, count+1, k)
+		})
+	}
+	if count < n.min {
+		return again()
+	}
+	if n.lazy {
+		return k(i) || again()
+	}
+	return again() || k(i)
+}
+
+// repeatOne is repeat for a single character, without recursion
+func (m *reMatcher) repeatOne(n *reNode, i int, k func(int) bool) bool {
+	sub := n.subs[0]
+	ends := []int{i}
+	for j := i; n.max < 0 || len(ends) <= n.max; {
+		if n.lazy && len(ends) > n.min && k(j) {
+			return true
+		}
+		next, ok := m.one(sub, j)
+		if !ok {
+			break
+		}
+		j = next
+		ends = append(ends, j)
+	}
+	if n.lazy {
+		last := ends[len(ends)-1]
+		return len(ends) > n.min && len(ends)-1 == n.max && k(last)
+	}
+	for c := len(ends) - 1; c >= n.min; c-- {
+		if k(ends[c]) {
+			return true
+		}
+	}
+	return false
+}
+
+// one matches a single character node at i
+func (m *reMatcher) one(n *reNode, i int) (int, bool) {
+	if i >= len(m.s) {
+		return i, false
+	}
+	r, w := rune(m.s[i]), 1
+	if r >= utf8.RuneSelf {
+		r, w = utf8.DecodeRuneInString(m.s[i:])
+	}
+	switch n.op {
+	case reOpChar:
+		return i + w, r == n.r || n.fold && reFoldEqual(r, n.r)
+	case reOpAny:
+		return i + w, n.dotNL || r != '\n'
+	}
+	return i + w, n.set.match(r, n.fold)
+}
+
+// same matches the text of a group at i
+func (m *reMatcher) same(text string, i int, fold bool) (int, bool) {
+	if !fold {
+		return i + len(text), strings.HasPrefix(m.s[i:], text)
+	}
+	for _, r := range text {
+		if i >= len(m.s) {
+			return i, false
+		}
+		c, w := utf8.DecodeRuneInString(m.s[i:])
+		if c != r && !reFoldEqual(c, r) {
+			return i, false
+		}
+		i += w
+	}
+	return i, true
+}
+
+func (m *reMatcher) isWord(i int) bool {
+	if i < 0 || i >= len(m.s) {
+		return false
+	}
+	c := m.s[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+type reState struct {
+	caps []int
+	keep int
+}
+
+func (m *reMatcher) save() reState {
+	return reState{append([]int(nil), m.caps...), m.keep}
+}
+
+func (m *reMatcher) restore(st reState) {
+	copy(m.caps, st.caps)
+	m.keep = st.keep
+}
+
+func reFoldEqual(a, b rune) bool {
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
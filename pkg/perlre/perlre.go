@@ -0,0 +1,177 @@
+// Package perlre compiles Perl regular expressions for Go.
+//
+// A pattern is first translated to the RE2 syntax of package regexp
+// (named groups, \h, \v, \R, \N, \e, octal escapes, comments). What RE2
+// cannot run — backreferences, lookaround, atomic groups, possessive
+// quantifiers, \Z, \K — goes to a small backtracking engine instead, with
+// the same leftmost-first semantics. Constructs neither can run (code
+// blocks, recursion, \G, verbs) are reported as an *UnsupportedError.
+//
+// The generated programs embed this package as is (see Source), so it
+// imports only the standard library and its unexported names start with
+// "re" to stay out of the way of the runtime.
+package perlre
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Regexp is a compiled Perl pattern.
+type Regexp struct {
+	expr string
+	re2  *regexp.Regexp
+	prog *reProg
+}
+
+// UnsupportedError reports a construct that neither RE2 nor the
+// backtracking engine implements.
+type UnsupportedError struct {
+	Construct string
+	Pattern   string
+	Offset    int
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("Sequence %s not supported in regex; marked by <-- HERE in m/%s <-- HERE %s/",
+		e.Construct, e.Pattern[:e.Offset], e.Pattern[e.Offset:])
+}
+
+// Compile parses a Perl pattern. Inline flags such as (?i) are accepted;
+// /x must already be applied to the text.
+func Compile(expr string) (*Regexp, error) {
+	translated, backtrack, err := Translate(expr)
+	if err != nil {
+		return nil, err
+	}
+	if !backtrack {
+		if re, err := regexp.Compile(translated); err == nil {
+			return &Regexp{expr: expr, re2: re}, nil
+		}
+		// counted repeats over 1000, \y and such: the engine is more lenient
+	}
+	prog, err := reParse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Regexp{expr: expr, prog: prog}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern does not compile.
+func MustCompile(expr string) *Regexp {
+	re, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// String returns the Perl source of the pattern.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// Backtracking reports whether the pattern runs on the backtracking engine.
+func (re *Regexp) Backtracking() bool {
+	return re.prog != nil
+}
+
+// NumSubexp returns the number of capture groups.
+func (re *Regexp) NumSubexp() int {
+	if re.prog != nil {
+		return re.prog.ncap
+	}
+	return re.re2.NumSubexp()
+}
+
+// SubexpNames returns the names of the capture groups, "" for unnamed
+// ones; element 0 stands for the whole match.
+func (re *Regexp) SubexpNames() []string {
+	if re.prog != nil {
+		return re.prog.names
+	}
+	return re.re2.SubexpNames()
+}
+
+// MatchString reports whether s contains a match.
+func (re *Regexp) MatchString(s string) bool {
+	if re.prog != nil {
+		return re.prog.exec(s, 0) != nil
+	}
+	return re.re2.MatchString(s)
+}
+
+// FindStringSubmatchIndex returns the offsets of the leftmost match and
+// its groups, -1 for groups that did not take part; nil if none.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	if re.prog != nil {
+		return re.prog.exec(s, 0)
+	}
+	return re.re2.FindStringSubmatchIndex(s)
+}
+
+// FindAllStringSubmatchIndex returns up to n successive matches (all of
+// them if n < 0). Like package regexp, an empty match right after the
+// previous match is skipped.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	if re.prog == nil {
+		return re.re2.FindAllStringSubmatchIndex(s, n)
+	}
+	var all [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(s) && (n < 0 || len(all) < n); {
+		loc := re.prog.exec(s, pos)
+		if loc == nil {
+			break
+		}
+		accept := true
+		if loc[1] == pos {
+			if loc[0] == prevEnd {
+				accept = false
+			}
+			if pos < len(s) {
+				_, w := utf8.DecodeRuneInString(s[pos:])
+				pos += w
+			} else {
+				pos++
+			}
+		} else {
+			pos = loc[1]
+		}
+		prevEnd = loc[1]
+		if accept {
+			all = append(all, loc)
+		}
+	}
+	return all
+}
+
+// Split slices s around the matches, as regexp.Regexp.Split does.
+func (re *Regexp) Split(s string, n int) []string {
+	if re.prog == nil {
+		return re.re2.Split(s, n)
+	}
+	if n == 0 {
+		return nil
+	}
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+	var parts []string
+	beg, end := 0, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, n) {
+		if n > 0 && len(parts) == n-1 {
+			break
+		}
+		end = loc[0]
+		if loc[1] != 0 {
+			parts = append(parts, s[beg:end])
+		}
+		beg = loc[1]
+	}
+	if end != len(s) {
+		parts = append(parts, s[beg:])
+	}
+	return parts
+}
@@ -0,0 +1,173 @@
+package perlre
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+		backtrack     bool
+	}{
+		{`(?<year>\d+)-(?'mon'\d+)`, `(?P<year>\d+)-(?P<mon>\d+)`, false},
+		{`a(?#comment)b`, `ab`, false},
+		{`\e\cA\0\o{101}\x41`, `\x{1B}\x{1}\x{0}\x{41}\x{41}`, false},
+		{`a\N+`, `a[^\n]+`, false},
+		{`[\h]`, `[\x{9}\x{20}\x{A0}\x{1680}\x{180E}\x{2000}-\x{200A}\x{202F}\x{205F}\x{3000}]`, false},
+		{`[]a]`, `[\]a]`, false},
+		{`(?i)a\z`, `(?i)a\z`, false},
+		{`(?^i:a)(?^:b)`, `(?i-ms:a)(?-ims:b)`, false},
+		{`(?^x: a )`, ``, true},
+		{`(\w)\1`, ``, true},
+		{`foo(?=bar)`, ``, true},
+		{`(?<!x)y`, ``, true},
+		{`a++`, ``, true},
+		{`a{2}+`, ``, true},
+		{`a\Z`, ``, true},
+		{`(?x) a b `, ``, true},
+	}
+	for _, tt := range tests {
+		got, backtrack, err := Translate(tt.pattern)
+		if err != nil || got != tt.want || backtrack != tt.backtrack {
+			t.Errorf("Translate(%q) = %q, %v, %v; want %q, %v", tt.pattern, got, backtrack, err, tt.want, tt.backtrack)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for pattern, construct := range map[string]string{
+		`a(?{ 1 })`: "(?{...})",
+		`(a|(?R))`:  "(?R)",
+		`\Gfoo`:     `\G`,
+		`x(*FAIL)`:  "(*VERB)",
+		`(?1)`:      "(?PARNO)",
+	} {
+		_, err := Compile(pattern)
+		var unsupported *UnsupportedError
+		if !errors.As(err, &unsupported) || unsupported.Construct != construct {
+			t.Errorf("Compile(%q): %v, want unsupported %s", pattern, err, construct)
+		}
+	}
+	_, err := Compile(`a(?{ 1 })`)
+	if want := "Sequence (?{...}) not supported in regex; marked by <-- HERE in m/a <-- HERE (?{ 1 })/"; err.Error() != want {
+		t.Errorf("got %q\nwant %q", err, want)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for pattern, msg := range map[string]string{
+		`(\w)\1(`:  "Unmatched (",
+		`a)\1`:     "Unmatched )",
+		`[a\1`:     "Unmatched [",
+		`(?=*)`:    "Quantifier follows nothing",
+		`(a)\2`:    "Reference to nonexistent group",
+		`\k<nope>`: "Reference to nonexistent named group",
+	} {
+		if _, err := Compile(pattern); err == nil || !strings.HasPrefix(err.Error(), msg+" in regex") {
+			t.Errorf("Compile(%q): %v, want %s", pattern, err, msg)
+		}
+	}
+}
+
+func TestBacktracking(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             []string // whole match and groups, nil for no match
+	}{
+		{`(\w)\1`, "hello", []string{"ll", "l"}},
+		{`(?i)(a)\1`, "xaA", []string{"aA", "a"}},
+		{`(?<q>['"]).*?\k<q>`, `say "hi" 'x'`, []string{`"hi"`, `"`}},
+		{`\b(\w+) \g{-1}\b`, "it is is fine", []string{"is is", "is"}},
+		{`foo(?=bar)`, "foobaz foobar", []string{"foo"}},
+		{`\d+(?!px)\b`, "10px 20em 30", []string{"30"}},
+		{`(?<=\$)\d+`, "cost: $42", []string{"42"}},
+		{`(?<!-)\b\d+`, "-5 7", []string{"7"}},
+		{`"[^"]*+"`, `"abc"`, []string{`"abc"`}},
+		{`a++b`, "aaa", nil},
+		{`(?>a+)ab`, "aaab", nil},
+		{`x\Z`, "x\n", []string{"x"}},
+		{`foo\Kbar`, "foobar", []string{"bar"}},
+		{`(?x) a  b # comment`, "ab", []string{"ab"}},
+		{`(a)|(b)\2`, "bb", []string{"bb", "", "b"}},
+		{`^(a+)+\1$`, "aaaa", []string{"aaaa", "a"}},
+		{`(?s)a.b(?=c)`, "a\nbc", []string{"a\nb"}},
+		{`(\p{Lu})\p{Ll}+\1`, "ÀbcÀ", []string{"ÀbcÀ", "À"}},
+		{`x{1001}`, strings.Repeat("x", 1001), []string{strings.Repeat("x", 1001)}},
+	}
+	for _, tt := range tests {
+		re, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		if !re.Backtracking() {
+			t.Errorf("%q should run on the backtracking engine", tt.pattern)
+		}
+		var got []string
+		if loc := re.FindStringSubmatchIndex(tt.subject); loc != nil {
+			for i := 0; i < len(loc); i += 2 {
+				if loc[i] < 0 {
+					got = append(got, "")
+					continue
+				}
+				got = append(got, tt.subject[loc[i]:loc[i+1]])
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q on %q = %q, want %q", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+// TestAgreesWithRE2 runs patterns both engines handle through each and
+// compares the results of every method.
+func TestAgreesWithRE2(t *testing.T) {
+	patterns := []string{`a*`, `\w+`, `(a|ab)(c|bcd)(d*)`, `x*?y`, `(?i)straße|ß`, `^$`, `(?m)^\w`, `[^,]*`, `(\d+)?,`, `.`}
+	subjects := []string{"", "abcd", "xxy xy y", "a,b,,1,", "foo\nbar", "STRASSE ß", "héllo wörld"}
+	for _, pattern := range patterns {
+		re2 := MustCompile(pattern)
+		prog, err := reParse(pattern)
+		if err != nil {
+			t.Fatalf("reParse(%q): %v", pattern, err)
+		}
+		bt := &Regexp{expr: pattern, prog: prog}
+		if !reflect.DeepEqual(bt.SubexpNames(), re2.SubexpNames()) {
+			t.Errorf("%q: names %q, want %q", pattern, bt.SubexpNames(), re2.SubexpNames())
+		}
+		for _, s := range subjects {
+			if got, want := bt.FindAllStringSubmatchIndex(s, -1), re2.FindAllStringSubmatchIndex(s, -1); !reflect.DeepEqual(got, want) {
+				t.Errorf("%q on %q: %v, want %v", pattern, s, got, want)
+			}
+			if got, want := bt.Split(s, -1), re2.Split(s, -1); !reflect.DeepEqual(got, want) {
+				t.Errorf("split %q on %q: %q, want %q", pattern, s, got, want)
+			}
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	var src strings.Builder
+	files, err := fs.ReadDir(Sources, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), "_test.go") {
+			t.Errorf("Sources hold the test %s", f.Name())
+		}
+		data, _ := fs.ReadFile(Sources, f.Name())
+		if !strings.Contains("\n"+string(data), "\npackage perlre\n") {
+			t.Errorf("%s is not of the package", f.Name())
+		}
+		src.Write(data)
+	}
+	for _, decl := range []string{"func Compile(", "var Sources embed.FS"} {
+		if !strings.Contains(src.String(), decl) {
+			t.Errorf("Sources are missing %s", decl)
+		}
+	}
+}
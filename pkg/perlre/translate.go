@@ -0,0 +1,325 @@
+package perlre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reHSpace and reVSpace are the \h and \v sets of perl, as lo/hi pairs
+var (
+	reHSpace = []rune{'\t', '\t', ' ', ' ', 0xA0, 0xA0, 0x1680, 0x1680, 0x180E, 0x180E,
+		0x2000, 0x200A, 0x202F, 0x202F, 0x205F, 0x205F, 0x3000, 0x3000}
+	reVSpace = []rune{'\n', '\r', 0x85, 0x85, 0x2028, 0x2029}
+)
+
+// Translate rewrites a Perl pattern in the RE2 syntax. It reports
+// backtrack when the pattern needs the backtracking engine (the
+// translation is then empty), and an *UnsupportedError for constructs
+// neither can run.
+func Translate(pattern string) (translated string, backtrack bool, err error) {
+	var out strings.Builder
+	class := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			if what := reUnsupported(pattern, i); what != "" {
+				return "", false, &UnsupportedError{Construct: what, Pattern: pattern, Offset: i}
+			}
+			text, n, engine := reTranslateEscape(pattern[i:], class)
+			if engine {
+				return "", true, nil
+			}
+			out.WriteString(text)
+			i += n - 1
+		case class:
+			if c == '[' && strings.HasPrefix(pattern[i:], "[:") {
+				if end := strings.Index(pattern[i:], ":]"); end > 0 {
+					out.WriteString(pattern[i : i+end+2])
+					i += end + 1
+					continue
+				}
+			}
+			if c == ']' {
+				class = false
+			}
+			out.WriteByte(c)
+		case c == '[':
+			class = true
+			out.WriteByte(c)
+			// a ] right after [ or [^ is a literal
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				out.WriteByte('^')
+				i++
+			}
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				out.WriteString(`\]`)
+				i++
+			}
+		case c == '(':
+			if what := reUnsupported(pattern, i); what != "" {
+				return "", false, &UnsupportedError{Construct: what, Pattern: pattern, Offset: i}
+			}
+			rest := pattern[i+1:]
+			switch {
+			case strings.HasPrefix(rest, "?#"):
+				end := strings.IndexByte(rest, ')')
+				if end < 0 {
+					return "", true, nil // the engine reports it
+				}
+				i += end + 1
+			case reHasPrefix(rest, "?=", "?!", "?<=", "?<!", "?>", "?P="):
+				return "", true, nil
+			case reHasPrefix(rest, "?<", "?'"):
+				end := strings.IndexAny(rest[2:], ">'")
+				if end < 0 {
+					return "", true, nil
+				}
+				out.WriteString("(?P<" + rest[2:2+end] + ">")
+				i += end + 3
+			case strings.HasPrefix(rest, "?P<"):
+				out.WriteString("(?P<")
+				i += 3
+			case strings.HasPrefix(rest, "?^"):
+				// (?^i: of qr// resets the other flags: (?i-ms:
+				end := strings.IndexAny(rest, ":)")
+				if end < 0 || strings.Trim(rest[2:end], "ims") != "" {
+					return "", true, nil
+				}
+				off := ""
+				for _, f := range "ims" {
+					if !strings.ContainsRune(rest[2:end], f) {
+						off += string(f)
+					}
+				}
+				out.WriteString("(?" + rest[2:end])
+				if off != "" {
+					out.WriteString("-" + off)
+				}
+				i += end
+			case strings.HasPrefix(rest, "?"):
+				end := strings.IndexAny(rest, ":)")
+				if end < 0 || strings.Trim(rest[1:end], "ims-") != "" {
+					return "", true, nil // /x, /n, (?^...) and the like
+				}
+				out.WriteString("(?")
+				i++
+			default:
+				out.WriteByte('(')
+			}
+		case c == '*' || c == '+' || c == '?':
+			if strings.HasPrefix(pattern[i+1:], "+") {
+				return "", true, nil // possessive
+			}
+			out.WriteByte(c)
+		case c == '{':
+			if n := reCounted(pattern[i:]); n > 0 && strings.HasPrefix(pattern[i+n:], "+") {
+				return "", true, nil
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), false, nil
+}
+
+// reTranslateEscape translates the escape at the start of s; n is its
+// length in s and engine reports an escape RE2 has no equivalent for.
+func reTranslateEscape(s string, class bool) (text string, n int, engine bool) {
+	switch e := s[1]; e {
+	case 'g', 'k', 'K', 'Z':
+		return "", 0, true
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if !class {
+			return "", 0, true // backreference
+		}
+		if e >= '8' {
+			return s[1:2], 2, false
+		}
+		return reOctal(s, 1, 3)
+	case '0':
+		return reOctal(s, 1, 3)
+	case 'o':
+		end := strings.IndexByte(s, '}')
+		if !strings.HasPrefix(s[2:], "{") || end < 0 {
+			return "", 0, true
+		}
+		v, err := strconv.ParseUint(s[3:end], 8, 32)
+		if err != nil {
+			return "", 0, true
+		}
+		return fmt.Sprintf(`\x{%X}`, v), end + 1, false
+	case 'x':
+		if strings.HasPrefix(s[2:], "{") {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", 0, true
+			}
+			return s[:end+1], end + 1, false
+		}
+		n := 2
+		for n < 4 && n < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[n]) >= 0 {
+			n++
+		}
+		if n == 2 {
+			return `\x{0}`, 2, false
+		}
+		return `\x{` + s[2:n] + `}`, n, false
+	case 'c':
+		if len(s) < 3 {
+			return "", 0, true
+		}
+		return fmt.Sprintf(`\x{%X}`, rune(strings.ToUpper(s[2:3])[0])^64), 3, false
+	case 'e':
+		return `\x{1B}`, 2, false
+	case 'b':
+		if class {
+			return `\x{8}`, 2, false // backspace
+		}
+	case 'h', 'v':
+		set := reRangesText(reHSpace)
+		if e == 'v' {
+			set = reRangesText(reVSpace)
+		}
+		if class {
+			return set, 2, false
+		}
+		return "[" + set + "]", 2, false
+	case 'H', 'V':
+		if class {
+			return "", 0, true
+		}
+		set := reRangesText(reHSpace)
+		if e == 'V' {
+			set = reRangesText(reVSpace)
+		}
+		return "[^" + set + "]", 2, false
+	case 'R':
+		if class {
+			return "", 0, true
+		}
+		return `(?:\r\n|[` + reRangesText(reVSpace) + `])`, 2, false
+	case 'N':
+		if strings.HasPrefix(s[2:], "{U+") {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", 0, true
+			}
+			return `\x{` + s[5:end] + `}`, end + 1, false
+		}
+		if class {
+			return "", 0, true
+		}
+		return `[^\n]`, 2, false
+	case 'p', 'P':
+		if strings.HasPrefix(s[2:], "{") {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", 0, true
+			}
+			return s[:end+1], end + 1, false
+		}
+	}
+	return s[:2], 2, false
+}
+
+// reOctal translates the octal escape at s[from:], at most max digits
+func reOctal(s string, from, max int) (string, int, bool) {
+	n := from
+	for n < from+max && n < len(s) && s[n] >= '0' && s[n] <= '7' {
+		n++
+	}
+	v, _ := strconv.ParseUint(s[from:n], 8, 32)
+	return fmt.Sprintf(`\x{%X}`, v), n, false
+}
+
+// reRangesText writes lo/hi pairs as the body of an RE2 class
+func reRangesText(ranges []rune) string {
+	var b strings.Builder
+	for i := 0; i < len(ranges); i += 2 {
+		fmt.Fprintf(&b, `\x{%X}`, ranges[i])
+		if ranges[i+1] != ranges[i] {
+			fmt.Fprintf(&b, `-\x{%X}`, ranges[i+1])
+		}
+	}
+	return b.String()
+}
+
+// reUnsupported names the construct starting at p[i] (a backslash or an
+// open paren) when neither RE2 nor the engine implements it.
+func reUnsupported(p string, i int) string {
+	rest := p[i:]
+	if rest[0] == '\\' {
+		switch {
+		case strings.HasPrefix(rest, `\G`), strings.HasPrefix(rest, `\X`):
+			return rest[:2]
+		case strings.HasPrefix(rest, `\N{`) && !strings.HasPrefix(rest, `\N{U+`):
+			return `\N{NAME}`
+		}
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(rest, "(?{"):
+		return "(?{...})"
+	case strings.HasPrefix(rest, "(??{"):
+		return "(??{...})"
+	case strings.HasPrefix(rest, "(*"):
+		return "(*VERB)"
+	case strings.HasPrefix(rest, "(?|"):
+		return "(?|...)"
+	case strings.HasPrefix(rest, "(?R)"):
+		return "(?R)"
+	case strings.HasPrefix(rest, "(?&"), strings.HasPrefix(rest, "(?P>"):
+		return "(?&NAME)"
+	case strings.HasPrefix(rest, "(?("):
+		return "(?(condition)...)"
+	}
+	if len(rest) > 3 && rest[1] == '?' {
+		c := rest[2]
+		if c == '+' || c == '-' {
+			c = rest[3]
+		}
+		if c >= '0' && c <= '9' {
+			return "(?PARNO)"
+		}
+	}
+	return ""
+}
+
+// reCounted returns the length of the {n}, {n,} or {n,m} quantifier at
+// the start of s, 0 if there is none
+func reCounted(s string) int {
+	if !strings.HasPrefix(s, "{") {
+		return 0
+	}
+	i := 1
+	digits := func() int {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+	if digits() == 0 {
+		return 0
+	}
+	if i < len(s) && s[i] == ',' {
+		i++
+		digits()
+	}
+	if i < len(s) && s[i] == '}' {
+		return i + 1
+	}
+	return 0
+}
+
+func reHasPrefix(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package perlrt
+
+import (
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Builtins
+func PrintArgs(w io.Writer, args []*SV, end string) {
+	ofs := SpecialStr(Ofs)
+	for i, a := range args {
+		if i > 0 && ofs != "" {
+			io.WriteString(w, ofs)
+		}
+		io.WriteString(w, a.AsString())
+	}
+	if end != "" {
+		io.WriteString(w, end)
+	}
+}
+
+func PerlPrint(args ...*SV) *SV {
+	if Selected != "STDOUT" {
+		return PrintFH(Selected, args, SpecialStr(Ors))
+	}
+	PrintArgs(Stdout, args, SpecialStr(Ors))
+	return SvInt(1)
+}
+
+func PerlSay(args ...*SV) *SV {
+	if Selected != "STDOUT" {
+		return PrintFH(Selected, args, "\n")
+	}
+	PrintArgs(Stdout, args, "\n")
+	return SvInt(1)
+}
+
+// select(FH) returns the previous default handle, select() the current
+// one; select(undef, undef, undef, $sec) sleeps
+func Perl_select(args ...*SV) *SV {
+	if len(args) == 4 {
+		time.Sleep(time.Duration(args[3].AsFloat() * float64(time.Second)))
+		return SvInt(0)
+	}
+	prev := Selected
+	if len(args) > 0 {
+		Selected = strings.TrimPrefix(args[0].AsString(), "main::")
+		if fh, ok := Filehandles[Selected]; ok && fh.Autoflush {
+			StdoutFlush = SvInt(1)
+		} else {
+			StdoutFlush = SvInt(0)
+		}
+	}
+	if !strings.HasPrefix(prev, "GLOB(") {
+		prev = "main::" + prev
+	}
+	return SvStr(prev)
+}
+
+func PerlLength(s *SV) *SV { return SvInt(int64(utf8.RuneCountInString(s.AsString()))) }
+func PerlUc(s *SV) *SV     { return SvStr(strings.ToUpper(s.AsString())) }
+func PerlLc(s *SV) *SV     { return SvStr(strings.ToLower(s.AsString())) }
+func PerlAbs(n *SV) *SV    { return SvFloat(math.Abs(n.AsFloat())) }
+func PerlInt(n *SV) *SV    { return SvInt(n.AsInt()) }
+func PerlSqrt(n *SV) *SV   { return SvFloat(math.Sqrt(n.AsFloat())) }
+func PerlChr(n *SV) *SV    { return SvStr(string(rune(n.AsInt()))) }
+func PerlOrd(s *SV) *SV {
+	r := []rune(s.AsString())
+	if len(r) > 0 {
+		return SvInt(int64(r[0]))
+	}
+	return SvUndef()
+}
+
+func Perl_scalar(sv *SV) *SV {
+	if sv == nil {
+		return SvInt(0)
+	}
+	if sv.Flags&SVf_AOK != 0 {
+		return SvInt(int64(len(sv.AV)))
+	}
+	if sv.Flags&SVf_HOK != 0 {
+		return SvInt(int64(len(sv.HV)))
+	}
+	return sv
+}
+func Perl_keys(h *SV) *SV {
+	if h == nil || h.HV == nil {
+		return SvArray()
+	}
+	delete(HashIterators, h)
+	var keys []*SV
+	for _, k := range HashKeys(h.HV) {
+		keys = append(keys, SvStr(k))
+	}
+	return SvArray(keys...)
+}
+
+// HashLen is keys %h in scalar or boolean context: the count, without
+// listing the keys; like keys it resets the each iterator
+func HashLen(h *SV) *SV {
+	if h == nil {
+		return SvInt(0)
+	}
+	delete(HashIterators, h)
+	return SvInt(int64(len(h.HV)))
+}
+
+// HashPresize is keys %h = n: room for n keys up front (a smaller n is
+// ignored)
+func HashPresize(h, n *SV) *SV {
+	if h == nil || h.Flags&SVf_HOK == 0 {
+		return n
+	}
+	delete(HashIterators, h)
+	if int(n.AsInt()) > len(h.HV) {
+		grown := make(map[string]*SV, n.AsInt())
+		for k, v := range h.HV {
+			grown[k] = v
+		}
+		h.HV = grown
+	}
+	return n
+}
+func Perl_join(sep, arr *SV) *SV {
+	if arr == nil {
+		return SvStr("")
+	}
+	var parts []string
+	for _, el := range arr.AV {
+		parts = append(parts, el.AsString())
+	}
+	return SvStr(strings.Join(parts, sep.AsString()))
+}
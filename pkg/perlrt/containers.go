@@ -0,0 +1,302 @@
+package perlrt
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Array ops
+func SvAGet(arr *SV, idx *SV) *SV {
+	if arr == nil || arr.Flags&SVf_AOK == 0 {
+		return SvUndef()
+	}
+	i := int(idx.AsInt())
+	if i < 0 {
+		i = len(arr.AV) + i
+	}
+	if i < 0 || i >= len(arr.AV) {
+		return SvUndef()
+	}
+	return arr.AV[i]
+}
+
+func SvASet(arr *SV, idx *SV, val *SV) *SV {
+	if arr == nil {
+		return val
+	}
+	if arr.Flags == 0 {
+		arr.Flags = SVf_AOK
+	}
+	i := int(idx.AsInt())
+	for len(arr.AV) <= i {
+		arr.AV = append(arr.AV, SvUndef())
+	}
+	arr.AV[i] = val
+	return val
+}
+
+func SvPush(arr *SV, vals ...*SV) *SV {
+	if arr.Flags == 0 {
+		arr.Flags = SVf_AOK
+	}
+	arr.AV = append(arr.AV, vals...)
+	return SvInt(int64(len(arr.AV)))
+}
+
+func SvPop(arr *SV) *SV {
+	if len(arr.AV) == 0 {
+		return SvUndef()
+	}
+	val := arr.AV[len(arr.AV)-1]
+	arr.AV = arr.AV[:len(arr.AV)-1]
+	return val
+}
+
+func SvShift(arr *SV) *SV {
+	if len(arr.AV) == 0 {
+		return SvUndef()
+	}
+	val := arr.AV[0]
+	arr.AV = arr.AV[1:]
+	return val
+}
+
+func SvUnshift(arr *SV, vals ...*SV) *SV {
+	if arr.Flags == 0 {
+		arr.Flags = SVf_AOK
+	}
+	arr.AV = append(vals, arr.AV...)
+	return SvInt(int64(len(arr.AV)))
+}
+
+// Hash ops
+func SvHGet(h *SV, key *SV) *SV {
+	if h == nil || h.HV == nil {
+		return SvUndef()
+	}
+	if v, ok := h.HV[key.AsString()]; ok {
+		return v
+	}
+	return SvUndef()
+}
+
+func SvHSet(h *SV, key *SV, val *SV) *SV {
+	if h.HV == nil {
+		h.HV = make(map[string]*SV)
+		h.Flags |= SVf_HOK
+	}
+	h.HV[key.AsString()] = val
+	if h == H_ENV {
+		SyncEnv()
+	}
+	return val
+}
+
+// Autovivification: elements on the way to a store ($h{a}{b}[2] = 1) are
+// fetched with these. A missing or undef element is replaced by a fresh
+// undef, which the following SvHSet/SvASet/SvPush turns into a hash or
+// an array in place.
+func SvHGetLV(h *SV, key *SV) *SV {
+	if h == nil {
+		return SvUndef()
+	}
+	if h.HV == nil {
+		h.HV = make(map[string]*SV)
+		h.Flags |= SVf_HOK
+	}
+	k := key.AsString()
+	if v, ok := h.HV[k]; ok && v.Flags != 0 {
+		return v
+	}
+	v := SvUndef()
+	h.HV[k] = v
+	return v
+}
+
+func SvAGetLV(arr *SV, idx *SV) *SV {
+	if arr == nil {
+		return SvUndef()
+	}
+	if arr.Flags == 0 {
+		arr.Flags = SVf_AOK
+	}
+	i := int(idx.AsInt())
+	if i < 0 {
+		i = len(arr.AV) + i
+	}
+	if i < 0 {
+		return SvUndef()
+	}
+	for len(arr.AV) <= i {
+		arr.AV = append(arr.AV, SvUndef())
+	}
+	if arr.AV[i].Flags == 0 {
+		arr.AV[i] = SvUndef()
+	}
+	return arr.AV[i]
+}
+
+// %h = LIST or %h = %$ref: a new hash from key/value pairs or a copy of a hash
+func SvHashFrom(v *SV) *SV {
+	h := SvHash()
+	if v.Flags&SVf_HOK != 0 {
+		for k, e := range v.HV {
+			c := *e
+			h.HV[k] = &c
+		}
+		return h
+	}
+	src := Flatten([]*SV{v})
+	for i := 0; i < len(src); i += 2 {
+		SvHSet(h, src[i], ListAt(src, i+1))
+	}
+	return h
+}
+
+// List assignment: ($a, $b) = ($b, $a), ($x, @rest) = @list, %h = (...)
+func ListOf(v *SV) []*SV {
+	if v.Flags&SVf_AOK != 0 && v.Flags&0x80 == 0 {
+		return v.AV
+	}
+	if v.Flags&SVf_HOK != 0 {
+		out := make([]*SV, 0, 2*len(v.HV))
+		for _, k := range HashKeys(v.HV) {
+			out = append(out, SvStr(k), v.HV[k])
+		}
+		return out
+	}
+	return []*SV{v}
+}
+
+func ListCopy(parts ...[]*SV) []*SV {
+	var out []*SV
+	for _, part := range parts {
+		for _, v := range part {
+			if v.Flags&(SVf_AOK|SVf_HOK) != 0 || v.CV != nil {
+				out = append(out, v)
+				continue
+			}
+			c := *v
+			out = append(out, &c)
+		}
+	}
+	return out
+}
+
+// Call arguments: f(@a, %h, $x) passes the elements of @a, the pairs of %h
+// and $x. The values are not copied, @_ aliases the caller's variables.
+func ListJoin(parts ...[]*SV) []*SV {
+	var out []*SV
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// (LIST) x N: the values N times, each time copies
+func SvListRepeat(list []*SV, n *SV) *SV {
+	var out []*SV
+	for k := n.AsInt(); k > 0; k-- {
+		out = append(out, ListCopy(list)...)
+	}
+	return SvArray(out...)
+}
+
+func ListAt(l []*SV, i int) *SV {
+	if i < len(l) {
+		return l[i]
+	}
+	return SvUndef()
+}
+
+// Signatures: sub f($x, $y = 10, @rest) unpacks a flattened copy of
+// the arguments and dies on a wrong count; max < 0 is a slurpy @ or %
+func Signature(name string, args []*SV, min, max int) []*SV {
+	parts := make([][]*SV, len(args))
+	for i, a := range args {
+		parts[i] = ListOf(a)
+	}
+	params := ListCopy(parts...)
+	expected := func(n int, bound string) string {
+		if min != max {
+			return fmt.Sprintf("%s %d", bound, n)
+		}
+		return strconv.Itoa(n)
+	}
+	if max >= 0 && len(params) > max {
+		Perl_die(SvStr(fmt.Sprintf("Too many arguments for subroutine '%s' (got %d; expected %s)", name, len(params), expected(max, "at most"))))
+	}
+	if len(params) < min {
+		Perl_die(SvStr(fmt.Sprintf("Too few arguments for subroutine '%s' (got %d; expected %s)", name, len(params), expected(min, "at least"))))
+	}
+	return params
+}
+
+func ListRest(l []*SV, i int) []*SV {
+	if i < len(l) {
+		return l[i:]
+	}
+	return nil
+}
+
+func SvAFill(arr *SV, vals []*SV) *SV {
+	arr.Flags = SVf_AOK
+	arr.AV = append([]*SV(nil), vals...)
+	return arr
+}
+
+func SvHFill(h *SV, vals []*SV) *SV {
+	h.Flags = SVf_HOK
+	h.HV = make(map[string]*SV)
+	for i := 0; i < len(vals); i += 2 {
+		h.HV[vals[i].AsString()] = ListAt(vals, i+1)
+	}
+	if h == H_ENV {
+		SyncEnv()
+	}
+	return h
+}
+
+// Slices: @arr[LIST], @hash{LIST}
+func SvASlice(arr *SV, idx []*SV) *SV {
+	out := make([]*SV, len(idx))
+	for i, ix := range idx {
+		out[i] = SvAGet(arr, ix)
+	}
+	return SvArray(out...)
+}
+
+func SvHSlice(h *SV, keys []*SV) *SV {
+	out := make([]*SV, len(keys))
+	for i, k := range keys {
+		out[i] = SvHGet(h, k)
+	}
+	return SvArray(out...)
+}
+
+func SvASliceSet(arr *SV, idx []*SV, vals *SV) *SV {
+	src := Flatten([]*SV{vals})
+	for i, ix := range idx {
+		v := SvUndef()
+		if i < len(src) {
+			v = src[i]
+		}
+		if n := ix.AsInt(); n < 0 {
+			ix = SvInt(int64(len(arr.AV)) + n)
+		}
+		SvASet(arr, ix, v)
+	}
+	return vals
+}
+
+func SvHSliceSet(h *SV, keys []*SV, vals *SV) *SV {
+	src := Flatten([]*SV{vals})
+	for i, k := range keys {
+		v := SvUndef()
+		if i < len(src) {
+			v = src[i]
+		}
+		SvHSet(h, k, v)
+	}
+	return vals
+}
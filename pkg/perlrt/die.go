@@ -0,0 +1,109 @@
+package perlrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// die/eval: die panics with PerlDie inside eval, otherwise exits.
+// die $obj carries the reference itself into $@. A die in a thread of
+// use threads ends only that thread: InThread is set by its runtime.
+var EvalError = SvStr("")
+var EvalDepth int
+var InThread func() bool
+
+type PerlDie struct {
+	Msg   string
+	Value *SV
+}
+
+func Perl_die(args ...*SV) *SV { return DieAt("", args...) }
+
+// DieAt is die LIST at where, "FILE line N": a message without a newline
+// ends in " at FILE line N.", as perl's
+func DieAt(where string, args ...*SV) *SV {
+	if len(args) == 1 && args[0].Flags&SVf_POK == 0 && RefAddr(args[0]) != 0 {
+		Throw(PerlDie{Msg: args[0].AsString(), Value: args[0]})
+	}
+	msg := ""
+	for _, a := range args {
+		msg += a.AsString()
+	}
+	if msg == "" {
+		msg = "Died"
+	}
+	if where != "" && !strings.HasSuffix(msg, "\n") {
+		msg += " at " + where + "."
+	}
+	Throw(PerlDie{Msg: msg})
+	return SvUndef()
+}
+
+// DieAgain is a bare die: an object in $@ is thrown again as is, a
+// message gets "\t...propagated" with where the die is after its newline
+func DieAgain(where string) *SV {
+	switch {
+	case EvalError.Flags&SVf_POK == 0 && RefAddr(EvalError) != 0:
+		Throw(PerlDie{Msg: EvalError.AsString(), Value: EvalError})
+	case EvalError.AsString() != "":
+		msg := EvalError.AsString() + "\t...propagated"
+		if where != "" {
+			msg += " at " + where + "."
+		}
+		Throw(PerlDie{Msg: msg})
+	}
+	return DieAt(where)
+}
+
+func Throw(d PerlDie) {
+	if !strings.HasSuffix(d.Msg, "\n") {
+		d.Msg += "\n"
+	}
+	if EvalDepth > 0 || InThread != nil && InThread() {
+		panic(d)
+	}
+	fmt.Fprint(Stderr, d.Msg)
+	Exit(1)
+}
+
+func Perl_warn(args ...*SV) *SV {
+	msg := ""
+	for _, a := range args {
+		msg += a.AsString()
+	}
+	if msg == "" {
+		msg = "Warning: something's wrong"
+	}
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	fmt.Fprint(Stderr, msg)
+	return SvInt(1)
+}
+
+func Eval(body func() *SV) (result *SV) {
+	EvalDepth++
+	EvalError = SvStr("")
+	defer func() {
+		EvalDepth--
+		if r := recover(); r != nil {
+			if d, ok := r.(PerlDie); ok && d.Value != nil {
+				EvalError = d.Value
+			} else if ok {
+				EvalError = SvStr(d.Msg)
+			} else {
+				EvalError = SvStr(fmt.Sprint(r))
+			}
+			result = SvUndef()
+		}
+	}()
+	return body()
+}
+
+// EvalFail is an eval that died before running: $@ is set, result undef
+func EvalFail(msg string) *SV {
+	EvalError = SvStr(msg)
+	return SvUndef()
+}
+
+func Perl_gensym(args ...*SV) *SV { return NewGlob() }
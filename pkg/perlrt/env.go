@@ -0,0 +1,100 @@
+package perlrt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// %ENV and $?
+var H_ENV = func() *SV {
+	h := SvHash()
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexByte(kv, '='); idx > 0 {
+			h.HV[kv[:idx]] = SvStr(kv[idx+1:])
+		}
+	}
+	return h
+}()
+
+var ChildStatus int64
+
+func Environ() []string {
+	var env []string
+	for k, v := range H_ENV.HV {
+		env = append(env, k+"="+v.AsString())
+	}
+	return env
+}
+
+// A store into %ENV goes into the process environment too, for
+// os.Getenv and the PATH lookup of exec
+func SyncEnv() {
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexByte(kv, '='); idx > 0 {
+			if _, ok := H_ENV.HV[kv[:idx]]; !ok {
+				os.Unsetenv(kv[:idx])
+			}
+		}
+	}
+	for k, v := range H_ENV.HV {
+		if val := v.AsString(); os.Getenv(k) != val {
+			os.Setenv(k, val)
+		}
+	}
+}
+
+// @ARGV, and @INC/%INC: require of a module perlc implements only
+// notes the file in %INC (where it is found along @INC, or its own
+// name); module files compiled in go through _load (modules.go)
+var A_ARGV = func() *SV {
+	args := make([]*SV, len(os.Args)-1)
+	for i, a := range os.Args[1:] {
+		args[i] = SvStr(a)
+	}
+	return SvArray(args...)
+}()
+
+var A_INC = func() *SV {
+	var dirs []*SV
+	if lib := os.Getenv("PERL5LIB"); lib != "" {
+		for _, dir := range filepath.SplitList(lib) {
+			dirs = append(dirs, SvStr(dir))
+		}
+	}
+	return SvArray(append(dirs, SvStr("lib"), SvStr("local/lib/perl5"), SvStr("."))...)
+}()
+
+var H_INC = SvHash()
+
+func Require(file string) *SV {
+	path := file
+	if !filepath.IsAbs(file) {
+		for _, dir := range A_INC.AV {
+			candidate := filepath.Join(dir.AsString(), file)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				path = candidate
+				break
+			}
+		}
+	}
+	H_INC.HV[file] = SvStr(path)
+	return SvInt(1)
+}
+
+// $? of a finished child: the exit code in the high byte, the number of
+// the signal that killed it in the low bits
+func ExitStatus(err error) int64 {
+	if err == nil {
+		return 0
+	}
+	if e, ok := err.(*exec.ExitError); ok {
+		if ws, ok := e.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return int64(ws.Signal())
+		}
+		return int64(e.ExitCode()) << 8
+	}
+	return -1
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package perlrt
+
+import "os/exec"
+
+// execProcess is not available on this platform.
+func execProcess(cmd *exec.Cmd) error {
+	return errNoExec
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package perlrt
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// execProcess replaces the running program with cmd, as execve(2) does.
+// It returns only on failure.
+func execProcess(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	return syscall.Exec(cmd.Path, cmd.Args, cmd.Env)
+}
@@ -0,0 +1,873 @@
+package perlrt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// File I/O
+// The standard streams are handles too; STDOUT and STDERR are written
+// through Stdout and Stderr, unbuffered
+var Filehandles = map[string]*FileHandle{
+	"STDIN":  {File: os.Stdin},
+	"STDOUT": {File: os.Stdout},
+	"STDERR": {File: os.Stderr},
+}
+
+// STDIN's scanner is set in init: a scanner reaches method calls through
+// AsString, and a method call can die, which flushes Filehandles
+func init() { Filehandles["STDIN"].Scanner = NewScanner(os.Stdin) }
+
+// Selected is the default output handle of print, say and printf (select)
+var Selected = "STDOUT"
+
+type FileHandle struct {
+	File      *os.File
+	Scanner   *bufio.Scanner
+	Writer    *bufio.Writer
+	Autoflush bool
+	Pid       int // command of a pipe open, close waits for it
+	Layers    Layers
+	Out       *LayerWriter
+	Ahead     *ReadAhead // read side of a read-write handle (+<, +>)
+}
+
+// Flush writes buffered output and gives back what a read-write handle
+// read ahead, so the file offset is right for seek/tell/truncate/read
+func (fh *FileHandle) Flush() {
+	if fh.Writer != nil {
+		fh.Writer.Flush()
+	}
+	if fh.Out != nil {
+		fh.Out.Finish()
+	}
+	fh.DropReadAhead()
+}
+
+// DropReadAhead moves the file of a read-write handle back to the end of
+// the last record read and restarts its scanner there
+func (fh *FileHandle) DropReadAhead() {
+	if ra := fh.Ahead; ra != nil && ra.Read > 0 {
+		if ra.Read > ra.Used {
+			ra.File.Seek(ra.Used-ra.Read, io.SeekCurrent)
+		}
+		fh.Scanner = ra.Restart()
+	}
+}
+
+// ReadAhead counts the bytes the scanner of a read-write handle read from
+// its file and the bytes of the records it handed out. Records are read
+// raw and decoded by PerlReadLine, so both count bytes of the file.
+type ReadAhead struct {
+	File       *os.File
+	Read, Used int64
+}
+
+func (ra *ReadAhead) Restart() *bufio.Scanner {
+	ra.Read, ra.Used = 0, 0
+	scanner := NewScanner(readAheadFile{ra})
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := SplitRecord(data, atEOF)
+		ra.Used += int64(advance)
+		return advance, token, err
+	})
+	return scanner
+}
+
+type readAheadFile struct{ ra *ReadAhead }
+
+func (r readAheadFile) Read(p []byte) (int, error) {
+	n, err := r.ra.File.Read(p)
+	r.ra.Read += int64(n)
+	return n, err
+}
+
+// aheadWriter writes to the file of a read-write handle where the program
+// stopped reading, not where the scanner did
+type aheadWriter struct{ fh *FileHandle }
+
+func (w aheadWriter) Write(p []byte) (int, error) {
+	w.fh.DropReadAhead()
+	return w.fh.File.Write(p)
+}
+
+// PerlIO layers: strings are UTF-8, so the default, :utf8 and
+// :encoding(UTF-8) pass them through; :raw and :bytes make every byte
+// one character, :crlf maps "\r\n" to "\n" and back
+type Layers struct{ Bytes, Crlf bool }
+
+func ParseLayers(l Layers, spec string) (Layers, bool) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = ":raw"
+	}
+	for _, layer := range strings.Split(spec, ":") {
+		switch strings.ToLower(strings.TrimSpace(layer)) {
+		case "", "unix", "perlio", "stdio":
+		case "raw":
+			l = Layers{Bytes: true}
+		case "bytes", "encoding(latin1)", "encoding(latin-1)", "encoding(iso-8859-1)":
+			l.Bytes = true
+		case "utf8", "encoding(utf8)", "encoding(utf-8)", "encoding(utf-8-strict)":
+			l.Bytes = false
+		case "crlf":
+			l.Crlf = true
+		default:
+			return l, false
+		}
+	}
+	return l, true
+}
+
+func SplitMode(mode string) (string, string) {
+	if i := strings.IndexByte(mode, ':'); i >= 0 {
+		return strings.TrimSpace(mode[:i]), mode[i:]
+	}
+	return mode, ""
+}
+
+func DecodeLayers(l Layers, p, out []byte) []byte {
+	for i, b := range p {
+		if l.Crlf && b == '\r' && i+1 < len(p) && p[i+1] == '\n' {
+			continue
+		}
+		if l.Bytes && b >= utf8.RuneSelf {
+			out = utf8.AppendRune(out, rune(b))
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func (fh *FileHandle) Decode(p []byte) string {
+	if !fh.Layers.Bytes && !fh.Layers.Crlf {
+		return string(p)
+	}
+	return string(DecodeLayers(fh.Layers, p, nil))
+}
+
+type LayerReader struct {
+	R       io.Reader
+	Fh      *FileHandle
+	Pending []byte
+	Cr      bool
+	Buf     []byte
+}
+
+func (lr *LayerReader) Read(p []byte) (int, error) {
+	l := lr.Fh.Layers
+	if !l.Bytes && !l.Crlf && len(lr.Pending) == 0 && !lr.Cr {
+		return lr.R.Read(p)
+	}
+	for len(lr.Pending) == 0 {
+		if cap(lr.Buf) < len(p) {
+			lr.Buf = make([]byte, len(p))
+		}
+		chunk := lr.Buf[:len(p)]
+		n, err := lr.R.Read(chunk)
+		chunk = chunk[:n]
+		if lr.Cr {
+			chunk, lr.Cr = append([]byte{'\r'}, chunk...), false
+		}
+		if l.Crlf && len(chunk) > 0 && chunk[len(chunk)-1] == '\r' && err == nil {
+			chunk, lr.Cr = chunk[:len(chunk)-1], true
+		}
+		lr.Pending = DecodeLayers(l, chunk, lr.Pending)
+		if err != nil {
+			if len(lr.Pending) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	n := copy(p, lr.Pending)
+	lr.Pending = lr.Pending[n:]
+	return n, nil
+}
+
+type LayerWriter struct {
+	W     io.Writer
+	Fh    *FileHandle
+	Carry []byte
+}
+
+func (lw *LayerWriter) Write(p []byte) (int, error) {
+	l := lw.Fh.Layers
+	if !l.Bytes && !l.Crlf && len(lw.Carry) == 0 {
+		return lw.W.Write(p)
+	}
+	data := p
+	if len(lw.Carry) > 0 {
+		data, lw.Carry = append(lw.Carry, p...), nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf || !l.Bytes {
+			if b == '\n' && l.Crlf {
+				out = append(out, '\r')
+			}
+			out = append(out, b)
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			lw.Carry = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r < 0x100 && size > 1 {
+			out = append(out, byte(r))
+		} else {
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	if _, err := lw.W.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (lw *LayerWriter) Finish() {
+	if len(lw.Carry) > 0 {
+		lw.W.Write(lw.Carry)
+		lw.Carry = nil
+	}
+}
+
+func (fh *FileHandle) Encoder(w io.Writer) io.Writer {
+	fh.Out = &LayerWriter{W: w, Fh: fh}
+	return fh.Out
+}
+
+// open(FH, MODE, FILE), open(FH, MODE, \$buf), open(FH, "-|", CMD...)
+// and the 2-arg forms open(FH, "<file"), open(FH, "cmd |")
+func PerlOpen(name, mode string, args ...*SV) *SV {
+	mode = strings.TrimSpace(mode)
+	if len(args) == 0 {
+		switch {
+		case mode == "-|" || mode == "|-":
+		case strings.HasSuffix(mode, "|"):
+			return OpenPipe(name, "-|", SvStr(strings.TrimSpace(strings.TrimSuffix(mode, "|"))))
+		case strings.HasPrefix(mode, "|"):
+			return OpenPipe(name, "|-", SvStr(strings.TrimSpace(strings.TrimPrefix(mode, "|"))))
+		case strings.HasPrefix(mode, ">>"):
+			mode, args = ">>", []*SV{SvStr(strings.TrimSpace(mode[2:]))}
+		case strings.HasPrefix(mode, ">") || strings.HasPrefix(mode, "<"):
+			mode, args = mode[:1], []*SV{SvStr(strings.TrimSpace(mode[1:]))}
+		default:
+			mode, args = "<", []*SV{SvStr(mode)}
+		}
+	}
+	mode, layers := SplitMode(mode)
+	if mode == "-|" || mode == "|-" {
+		return OpenPipe(name, mode, args...)
+	}
+	if len(args) == 0 {
+		return SvInt(0)
+	}
+	if t := args[0]; t.Flags&0x80 != 0 && len(t.AV) > 0 {
+		return OpenLayers(name, layers, OpenScalar(name, mode, t.AV[0]))
+	}
+	filename := args[0].AsString()
+	var file *os.File
+	var err error
+	switch mode {
+	case "<", "r":
+		file, err = os.Open(filename)
+	case ">", "w":
+		file, err = os.Create(filename)
+	case ">>", "a":
+		file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	case "+<":
+		file, err = os.OpenFile(filename, os.O_RDWR, 0644)
+	case "+>":
+		file, err = os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	case "+>>":
+		file, err = os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	default:
+		file, err = os.Open(filename)
+	}
+	if err != nil {
+		SetOSError(err)
+		return SvInt(0)
+	}
+	Filehandles[name] = NewFileHandle(file, mode)
+	return OpenLayers(name, layers, SvInt(1))
+}
+
+// the layers of an open mode (<:raw) apply to the new handle
+func OpenLayers(name, layers string, ok *SV) *SV {
+	if layers == "" || !ok.IsTrue() {
+		return ok
+	}
+	if !Perl_binmode(SvStr(name), SvStr(layers)).IsTrue() {
+		PerlClose(name)
+		return SvInt(0)
+	}
+	return ok
+}
+
+// In-memory handles: reads come from the string, writes go into it at once
+type ScalarWriter struct{ Target *SV }
+
+func (w ScalarWriter) Set(s string) {
+	v := SvStr(s)
+	w.Target.IV, w.Target.NV, w.Target.PV, w.Target.Flags = v.IV, v.NV, v.PV, v.Flags
+}
+func (w ScalarWriter) Write(p []byte) (int, error) {
+	w.Set(w.Target.AsString() + string(p))
+	return len(p), nil
+}
+
+func OpenScalar(name, mode string, target *SV) *SV {
+	fh := &FileHandle{}
+	switch mode {
+	case "<":
+		fh.Scanner = NewScanner(&LayerReader{R: strings.NewReader(target.AsString()), Fh: fh})
+	case ">", ">>":
+		if mode == ">" {
+			ScalarWriter{target}.Set("")
+		}
+		fh.Writer, fh.Autoflush = bufio.NewWriter(fh.Encoder(ScalarWriter{target})), true
+	default:
+		SetOSError(fmt.Errorf("invalid argument"))
+		return SvInt(0)
+	}
+	Filehandles[name] = fh
+	return SvInt(1)
+}
+
+// Pipe opens: "-|" reads what the command prints, "|-" writes its input
+func OpenPipe(name, mode string, args ...*SV) *SV {
+	cmd := Command(args)
+	if cmd == nil {
+		return SvInt(0)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		SetOSError(err)
+		return SvInt(0)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, w, Stderr
+	mine, theirs, fhMode := r, w, "<"
+	if mode == "|-" {
+		cmd.Stdin, cmd.Stdout = r, Stdout
+		mine, theirs, fhMode = w, r, ">"
+	}
+	err = cmd.Start()
+	theirs.Close()
+	if err != nil {
+		mine.Close()
+		SetOSError(err)
+		return SvInt(0)
+	}
+	StartChild(cmd)
+	fh := NewFileHandle(mine, fhMode)
+	fh.Pid = cmd.Process.Pid
+	Filehandles[name] = fh
+	return SvInt(int64(fh.Pid))
+}
+
+// open($fh, '+>', undef) - anonymous temp file, unlinked right away
+func PerlOpenTemp(name, mode string) *SV {
+	file, err := os.CreateTemp("", "perlc-")
+	if err != nil {
+		return SvInt(0)
+	}
+	os.Remove(file.Name())
+	mode, layers := SplitMode(strings.TrimSpace(mode))
+	Filehandles[name] = NewFileHandle(file, mode)
+	return OpenLayers(name, layers, SvInt(1))
+}
+
+func NewFileHandle(file *os.File, mode string) *FileHandle {
+	fh := &FileHandle{File: file}
+	switch {
+	case mode == "<" || mode == "r" || mode == "":
+		fh.Scanner = NewScanner(&LayerReader{R: file, Fh: fh})
+	case strings.HasPrefix(mode, "+"):
+		// both directions share the file offset
+		fh.Ahead = &ReadAhead{File: file}
+		fh.Scanner = fh.Ahead.Restart()
+		fh.Writer = bufio.NewWriterSize(fh.Encoder(aheadWriter{fh}), int(Tune.IoBuffer))
+	default:
+		fh.Writer = bufio.NewWriterSize(fh.Encoder(file), int(Tune.IoBuffer))
+	}
+	return fh
+}
+
+// close of a pipe waits for the command, sets $? and fails if it did
+func PerlClose(name string) *SV {
+	fh, ok := Filehandles[name]
+	if !ok {
+		return SvInt(0)
+	}
+	fh.Flush()
+	if fh.File != nil {
+		fh.File.Close()
+	}
+	delete(Filehandles, name)
+	if fh.Pid != 0 && Reap(fh.Pid, false) > 0 && ChildStatus != 0 {
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+// Records end with $/ and keep it; undef reads everything, "" reads
+// paragraphs. $/ is looked up at every read.
+func NewScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), math.MaxInt)
+	scanner.Split(SplitRecord)
+	return scanner
+}
+
+func SplitRecord(data []byte, atEOF bool) (int, []byte, error) {
+	var sep []byte
+	paragraph := false
+	switch {
+	case Irs.Flags == 0:
+	case Irs.AsString() == "":
+		paragraph = true
+		sep = []byte("\n\n")
+	default:
+		sep = []byte(Irs.AsString())
+	}
+	if paragraph {
+		skip := 0
+		for skip < len(data) && data[skip] == '\n' {
+			skip++
+		}
+		if skip > 0 && (skip < len(data) || atEOF) {
+			return skip, nil, nil
+		}
+	}
+	if sep != nil {
+		if i := bytes.Index(data, sep); i >= 0 {
+			end := i + len(sep)
+			if !paragraph {
+				return end, data[:end], nil
+			}
+			next := end
+			for next < len(data) && data[next] == '\n' {
+				next++
+			}
+			if next == len(data) && !atEOF {
+				return 0, nil, nil
+			}
+			return next, data[:end], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func PerlReadLine(name string) *SV {
+	if name == "" {
+		name = "STDIN"
+	}
+	if fh, ok := Filehandles[name]; ok && fh.Scanner != nil {
+		if fh.Writer != nil {
+			fh.Writer.Flush()
+		}
+		if fh.Scanner.Scan() {
+			if fh.Ahead != nil {
+				return SvStr(fh.Decode(fh.Scanner.Bytes()))
+			}
+			return SvStr(fh.Scanner.Text())
+		}
+	}
+	return SvUndef()
+}
+
+// PerlReadLines is <FH> in list context: all the records left
+func PerlReadLines(name string) *SV {
+	var lines []*SV
+	for {
+		line := PerlReadLine(name)
+		if line.Flags == 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return SvArray(lines...)
+}
+
+// open(my $fh, ...) puts a glob in $fh: it prints as GLOB(0x...), which
+// is also the name the handle is kept under
+func NewGlob() *SV {
+	gv := &SV{Flags: SVf_POK | 0x20}
+	gv.PV = fmt.Sprintf("GLOB(%p)", gv)
+	return gv
+}
+
+// GlobFor keeps the glob of a handle that is opened again
+func GlobFor(v *SV) *SV {
+	if v != nil && v.Flags&0x20 != 0 {
+		return v
+	}
+	return NewGlob()
+}
+
+// globHandles are the handle names of the globs of GlobRef, by their
+// GLOB(0x...); namedGlobs are those globs by handle name
+var (
+	globHandles = map[string]string{}
+	namedGlobs  = map[string]*SV{}
+)
+
+// GlobRef is \*NAME: a glob like open's that names the handle NAME
+func GlobRef(name string) *SV {
+	if gv, ok := namedGlobs[name]; ok {
+		return gv
+	}
+	gv := NewGlob()
+	namedGlobs[name] = gv
+	globHandles[gv.PV] = name
+	return gv
+}
+
+// HandleName is the handle a value names: NAME for \*NAME and *NAME, the
+// string of anything else
+func HandleName(v *SV) string {
+	name := v.AsString()
+	if h, ok := globHandles[name]; ok {
+		return h
+	}
+	return strings.TrimPrefix(name, "*main::")
+}
+
+// print $x, ... is print $fh LIST only when $x is a handle
+func PrintTo(fh *SV, say bool, args ...*SV) *SV {
+	name := HandleName(fh)
+	if _, ok := Filehandles[name]; ok || StdStream(name) != nil {
+		if say {
+			return PerlSayFH(name, args...)
+		}
+		return PerlPrintFH(name, args...)
+	}
+	args = append([]*SV{fh}, args...)
+	if say {
+		return PerlSay(args...)
+	}
+	return PerlPrint(args...)
+}
+
+func PrintFH(fhName string, args []*SV, end string) *SV {
+	if w := StdStream(fhName); w != nil {
+		PrintArgs(w, args, end)
+		return SvInt(1)
+	}
+	if fh, ok := Filehandles[fhName]; ok && fh.Writer != nil {
+		PrintArgs(fh.Writer, args, end)
+		if fh.Autoflush {
+			fh.Writer.Flush()
+		}
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+
+func PerlPrintFH(fhName string, args ...*SV) *SV { return PrintFH(fhName, args, SpecialStr(Ors)) }
+func PerlSayFH(fhName string, args ...*SV) *SV   { return PrintFH(fhName, args, "\n") }
+
+// Buffered handles are flushed on every way out: end of main, exit, die, exec
+func FlushAll() {
+	for _, fh := range Filehandles {
+		fh.Flush()
+	}
+}
+
+func Exit(code int) {
+	FlushAll()
+	os.Exit(code)
+}
+
+func Perl_exit(args ...*SV) *SV {
+	code := 0
+	if len(args) > 0 {
+		code = int(args[0].AsInt())
+	}
+	Exit(code)
+	return SvUndef()
+}
+
+// $fh->autoflush(1); STDOUT/STDERR are not buffered
+func Autoflush(obj *SV, args []*SV) (*SV, bool) {
+	name := obj.AsString()
+	if name == "STDOUT" || name == "STDERR" {
+		return SvInt(1), true
+	}
+	fh, ok := Filehandles[name]
+	if !ok {
+		return nil, false
+	}
+	fh.Autoflush = len(args) == 0 || args[0].IsTrue()
+	if fh.Autoflush && fh.Writer != nil {
+		fh.Writer.Flush()
+	}
+	return SvInt(1), true
+}
+
+// eof - без аргументов или с SV
+func Perl_eof(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(1)
+	}
+	name := args[0].AsString()
+	if fh, ok := Filehandles[name]; ok && fh.File != nil {
+		pos, _ := fh.File.Seek(0, 1)
+		fi, _ := fh.File.Stat()
+		if pos >= fi.Size() {
+			return SvInt(1)
+		}
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+// tell
+func Perl_tell(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(-1)
+	}
+	name := args[0].AsString()
+	if fh, ok := Filehandles[name]; ok && fh.File != nil {
+		fh.Flush()
+		pos, _ := fh.File.Seek(0, 1)
+		return SvInt(pos)
+	}
+	return SvInt(-1)
+}
+
+// seek
+func Perl_seek(fh, pos, whence *SV) *SV {
+	name := fh.AsString()
+	if h, ok := Filehandles[name]; ok && h.File != nil {
+		h.Flush()
+		_, err := h.File.Seek(pos.AsInt(), int(whence.AsInt()))
+		if err == nil {
+			if h.Ahead != nil {
+				h.Scanner = h.Ahead.Restart()
+			} else if h.Scanner != nil {
+				h.Scanner = NewScanner(&LayerReader{R: h.File, Fh: h})
+			}
+			return SvInt(1)
+		}
+	}
+	return SvInt(0)
+}
+
+// truncate
+func Perl_truncate(fh, length *SV) *SV {
+	name := fh.AsString()
+	if h, ok := Filehandles[name]; ok && h.File != nil {
+		h.Flush()
+		if h.File.Truncate(length.AsInt()) == nil {
+			return SvInt(1)
+		}
+		return SvInt(0)
+	}
+	if os.Truncate(name, length.AsInt()) == nil {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+
+// read
+func Perl_read(fh, buf, length *SV) *SV {
+	name := fh.AsString()
+	if h, ok := Filehandles[name]; ok && h.File != nil {
+		h.Flush()
+		data := make([]byte, length.AsInt())
+		n, _ := h.File.Read(data)
+		buf.PV = h.Decode(data[:n])
+		buf.Flags = SVf_POK
+		return SvInt(int64(n))
+	}
+	return SvInt(0)
+}
+
+// binmode(FH, LAYERS); the standard streams are written as they are,
+// their layers only checked
+func Perl_binmode(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(0)
+	}
+	name := strings.TrimPrefix(args[0].AsString(), "main::")
+	spec := ""
+	if len(args) > 1 {
+		spec = args[1].AsString()
+	}
+	fh, ok := Filehandles[name]
+	if !ok && StdStream(name) == nil {
+		SetOSError(fmt.Errorf("bad file descriptor"))
+		return SvInt(0)
+	}
+	layers, valid := ParseLayers(Layers{}, spec)
+	if ok {
+		layers, valid = ParseLayers(fh.Layers, spec)
+	}
+	if !valid {
+		SetOSError(fmt.Errorf("invalid argument"))
+		return SvInt(0)
+	}
+	if ok && StdStream(name) == nil {
+		fh.Flush()
+		fh.Layers = layers
+	}
+	return SvInt(1)
+}
+
+// sysopen - open(2)-style flags, O_CREAT|O_EXCL fails on existing files
+func Perl_sysopen(fh, path, flags *SV, perm ...*SV) *SV {
+	mode := os.FileMode(0666)
+	if len(perm) > 0 {
+		mode = os.FileMode(perm[0].AsInt())
+	}
+	f := int(flags.AsInt())
+	file, err := os.OpenFile(path.AsString(), f, mode)
+	if err != nil {
+		return SvInt(0)
+	}
+	m := "<"
+	if f&os.O_RDWR != 0 {
+		m = "+<"
+	} else if f&os.O_WRONLY != 0 {
+		m = ">"
+	}
+	Filehandles[fh.AsString()] = NewFileHandle(file, m)
+	return SvInt(1)
+}
+
+// glob - File::Glob semantics: {a,b}, ~, sorted matches
+func Perl_glob(args ...*SV) *SV {
+	var out []*SV
+	if len(args) == 0 {
+		return SvArray()
+	}
+	for _, name := range Glob(args[0].AsString()) {
+		out = append(out, SvStr(name))
+	}
+	return SvArray(out...)
+}
+
+var GlobIters = map[int][]string{}
+
+// glob in scalar context - iterator bound to the call site
+func Perl_glob_next(site int, pattern *SV) *SV {
+	names, ok := GlobIters[site]
+	if !ok {
+		names = Glob(pattern.AsString())
+	}
+	if len(names) == 0 {
+		delete(GlobIters, site)
+		return SvUndef()
+	}
+	GlobIters[site] = names[1:]
+	return SvStr(names[0])
+}
+
+func Glob(pattern string) []string {
+	var result []string
+	for _, pat := range strings.Fields(pattern) {
+		for _, p := range ExpandBraces(pat) {
+			p = ExpandTilde(p)
+			if !strings.ContainsAny(p, "*?[") {
+				result = append(result, p)
+				continue
+			}
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				continue
+			}
+			hidden := strings.HasPrefix(filepath.Base(p), ".")
+			sort.Strings(matches)
+			for _, m := range matches {
+				if !hidden && strings.HasPrefix(filepath.Base(m), ".") {
+					continue
+				}
+				result = append(result, m)
+			}
+		}
+	}
+	return result
+}
+
+func ExpandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+	depth, end := 0, -1
+	var commas []int
+	for j := start; j < len(pattern) && end < 0; j++ {
+		switch pattern[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, j)
+			}
+		}
+	}
+	if end < 0 {
+		return []string{pattern}
+	}
+	var result []string
+	if len(commas) == 0 {
+		for _, rest := range ExpandBraces(pattern[end+1:]) {
+			result = append(result, pattern[:end+1]+rest)
+		}
+		return result
+	}
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	prev := start + 1
+	for _, c := range append(commas, end) {
+		result = append(result, ExpandBraces(prefix+pattern[prev:c]+suffix)...)
+		prev = c + 1
+	}
+	return result
+}
+
+func ExpandTilde(pattern string) string {
+	if !strings.HasPrefix(pattern, "~") {
+		return pattern
+	}
+	name, rest := pattern[1:], ""
+	if idx := strings.IndexByte(name, '/'); idx >= 0 {
+		name, rest = name[:idx], name[idx:]
+	}
+	var home string
+	if name == "" {
+		home = os.Getenv("HOME")
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+	} else if u, err := user.Lookup(name); err == nil {
+		home = u.HomeDir
+	}
+	if home == "" {
+		return pattern
+	}
+	return home + rest
+}
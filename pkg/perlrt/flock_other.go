@@ -0,0 +1,13 @@
+//go:build !unix
+
+package perlrt
+
+import (
+	"errors"
+	"os"
+)
+
+// flockFile is not available on this platform.
+func flockFile(f *os.File, op int) error {
+	return errors.New("flock not supported on this platform")
+}
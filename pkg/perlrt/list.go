@@ -0,0 +1,224 @@
+package perlrt
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"perlc/pkg/perlre"
+	"perlc/pkg/perlstr"
+)
+
+// split
+// split ' ' - awk mode, another string is a pattern
+func Perl_split(sep, str *SV, limit ...*SV) *SV {
+	if sep.Flags&0x40 == 0 && sep.AsString() == " " {
+		return StrArray(perlstr.Fields(str.AsString(), SplitLimit(limit)))
+	}
+	return Perl_split_re(Regex(sep.AsString()), str, limit...)
+}
+
+// split /re/ - пустые поля в конце отбрасываются, если нет LIMIT
+func Perl_split_re(re *perlre.Regexp, str *SV, limit ...*SV) *SV {
+	return StrArray(perlstr.Split(re, str.AsString(), SplitLimit(limit)))
+}
+
+func SplitLimit(limit []*SV) int {
+	if len(limit) == 0 {
+		return 0
+	}
+	return int(limit[0].AsInt())
+}
+
+func StrArray(parts []string) *SV {
+	result := make([]*SV, len(parts))
+	for i, p := range parts {
+		result[i] = SvStr(p)
+	}
+	return SvArray(result...)
+}
+
+// reverse
+func Perl_reverse(arr *SV) *SV {
+	if arr == nil || arr.Flags&SVf_AOK == 0 {
+		return SvArray()
+	}
+	n := len(arr.AV)
+	result := make([]*SV, n)
+	for i := 0; i < n; i++ {
+		result[i] = arr.AV[n-1-i]
+	}
+	return SvArray(result...)
+}
+
+// sort
+func Perl_sort(args ...*SV) *SV {
+	result := Flatten(args)
+	sort.SliceStable(result, func(i, j int) bool { return result[i].AsString() < result[j].AsString() })
+	return SvArray(result...)
+}
+
+// sort { $a <=> $b } - компаратор читает глобальные $a/$b
+var V_a, V_b = SvUndef(), SvUndef()
+
+func Perl_sort_by(cmp func() *SV, args ...*SV) *SV {
+	result := Flatten(args)
+	saveA, saveB := V_a, V_b
+	defer func() { V_a, V_b = saveA, saveB }()
+	sort.SliceStable(result, func(i, j int) bool {
+		V_a, V_b = result[i], result[j]
+		return cmp().AsInt() < 0
+	})
+	return SvArray(result...)
+}
+
+// values
+func Perl_values(h *SV) *SV {
+	if h == nil || h.HV == nil {
+		return SvArray()
+	}
+	delete(HashIterators, h)
+	var vals []*SV
+	for _, k := range HashKeys(h.HV) {
+		vals = append(vals, h.HV[k])
+	}
+	return SvArray(vals...)
+}
+
+// exists
+func Perl_exists(v *SV) *SV {
+	if v == nil || v.Flags == 0 {
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+// delete (для хеша - нужно передавать хеш и ключ)
+func Perl_delete(v *SV) *SV {
+	return SvUndef()
+}
+
+// chomp
+func Perl_chomp(sv *SV) *SV {
+	if sv == nil {
+		return SvInt(0)
+	}
+	s := sv.PV
+	if len(s) > 0 && s[len(s)-1] == '\n' {
+		sv.PV = s[:len(s)-1]
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+
+// defined
+func Perl_defined(sv *SV) *SV {
+	if sv == nil || sv.Flags == 0 {
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+func SvRef(sv *SV) *SV {
+	return &SV{AV: []*SV{sv}, Flags: SVf_AOK | 0x80}
+}
+
+func SvDeref(ref *SV) *SV {
+	if ref != nil && len(ref.AV) > 0 {
+		return ref.AV[0]
+	}
+	return SvUndef()
+}
+
+// JoinList - значение "@a" в строке: элементы через $" (пробел)
+func JoinList(v *SV) string {
+	if v == nil {
+		return ""
+	}
+	if v.Flags&SVf_AOK == 0 || v.Flags&0x80 != 0 {
+		return v.AsString()
+	}
+	parts := make([]string, len(v.AV))
+	for i, el := range v.AV {
+		parts[i] = el.AsString()
+	}
+	return strings.Join(parts, ListSep.AsString())
+}
+
+// each
+// HashIter - состояние each: без hash seed обходим саму map, не собирая
+// заранее все ключи, с seed идём по ключам в порядке seed
+type HashIter struct {
+	Keys []string
+	Walk *reflect.MapIter
+	M    reflect.Value
+}
+
+var HashIterators = make(map[*SV]*HashIter)
+
+func Perl_each(h *SV) *SV {
+	if h == nil || h.HV == nil {
+		return SvArray()
+	}
+
+	// Получаем или создаём итератор; если map хеша заменили - начинаем заново
+	it, ok := HashIterators[h]
+	if !ok || it.M.UnsafePointer() != reflect.ValueOf(h.HV).UnsafePointer() {
+		it = &HashIter{M: reflect.ValueOf(h.HV)}
+		if Tune.HashSeed == 0 {
+			it.Walk = it.M.MapRange()
+		} else {
+			it.Keys = HashKeys(h.HV)
+		}
+		HashIterators[h] = it
+	}
+
+	// Следующий ключ, который ещё есть в хеше
+	for {
+		var k string
+		if it.Walk != nil {
+			if !it.Walk.Next() {
+				break
+			}
+			k = it.Walk.Key().String()
+		} else {
+			if len(it.Keys) == 0 {
+				break
+			}
+			k, it.Keys = it.Keys[0], it.Keys[1:]
+		}
+		if v, ok := h.HV[k]; ok {
+			return SvArray(SvStr(k), v)
+		}
+	}
+
+	// Если ключи закончились - сбрасываем
+	delete(HashIterators, h)
+	return SvArray()
+}
+
+// grep
+func Perl_grep(block func(*SV) *SV, arr *SV) *SV {
+	if arr == nil {
+		return SvArray()
+	}
+	var results []*SV
+	for _, el := range arr.AV {
+		if block(el).IsTrue() {
+			results = append(results, el)
+		}
+	}
+	return SvArray(results...)
+}
+
+// map
+func Perl_map(block func(*SV) *SV, arr *SV) *SV {
+	if arr == nil {
+		return SvArray()
+	}
+	var results []*SV
+	for _, el := range arr.AV {
+		results = append(results, block(el))
+	}
+	return SvArray(results...)
+}
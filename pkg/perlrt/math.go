@@ -0,0 +1,96 @@
+package perlrt
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// POSIX floor, ceil, fmod and pow; + 0 drops the negative zero perl
+// prints as 0
+func PosixArg(args []*SV, n int) float64 {
+	if flat := Flatten(args); n < len(flat) {
+		return flat[n].AsFloat()
+	}
+	return 0
+}
+func Perl_POSIX_floor(args ...*SV) *SV { return SvFloat(math.Floor(PosixArg(args, 0)) + 0) }
+func Perl_POSIX_ceil(args ...*SV) *SV  { return SvFloat(math.Ceil(PosixArg(args, 0)) + 0) }
+func Perl_POSIX_fmod(args ...*SV) *SV  { return SvFloat(math.Mod(PosixArg(args, 0), PosixArg(args, 1))) }
+func Perl_POSIX_pow(args ...*SV) *SV   { return SvFloat(math.Pow(PosixArg(args, 0), PosixArg(args, 1))) }
+
+func Strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for j := 0; j < len(format); j++ {
+		if format[j] != '%' || j+1 >= len(format) {
+			b.WriteByte(format[j])
+			continue
+		}
+		j++
+		switch format[j] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'C':
+			fmt.Fprintf(&b, "%02d", t.Year()/100)
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'u':
+			fmt.Fprintf(&b, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 'D':
+			b.WriteString(t.Format("01/02/06"))
+		case 'c':
+			b.WriteString(t.Format(CtimeLayout))
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[j])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,260 @@
+package perlrt
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+
+	"perlc/pkg/perlre"
+	"perlc/pkg/perlstr"
+)
+
+// Perl_looks_like_number: numbers, and strings that are one as a whole
+// (ParseNumber, shared with the interpreter); not undef or references
+func Perl_looks_like_number(args ...*SV) *SV {
+	if len(args) == 0 || args[0] == nil {
+		return SvStr("")
+	}
+	v := args[0]
+	if v.CV == nil && v.Flags&(0x20|0x40|0x80) == 0 {
+		// the string of a dualvar decides
+		if v.Flags&SVf_POK != 0 {
+			if perlstr.LooksLikeNumber(v.PV) {
+				return SvInt(1)
+			}
+			return SvStr("")
+		}
+		if v.Flags&(SVf_IOK|SVf_NOK) != 0 {
+			return SvInt(1)
+		}
+	}
+	return SvStr("")
+}
+
+func Perl_Scalar_Util_looks_like_number(args ...*SV) *SV { return Perl_looks_like_number(args...) }
+
+// Perl_Scalar_Util_blessed is the class of a blessed reference, or undef
+func Perl_Scalar_Util_blessed(v *SV) *SV {
+	if pkg, ok := Blessed(v); ok {
+		return SvStr(pkg)
+	}
+	return SvUndef()
+}
+
+// Perl_Scalar_Util_reftype is the type of what a reference points to,
+// blessed or not; undef for a plain value
+func Perl_Scalar_Util_reftype(v *SV) *SV {
+	switch {
+	case v == nil:
+		return SvUndef()
+	case v.CV != nil:
+		return SvStr("CODE")
+	case v.Flags&0x20 != 0:
+		return SvStr("GLOB")
+	case v.Flags&0x40 != 0:
+		return SvStr("REGEXP")
+	case v.Flags&(0x80|SVf_AOK|SVf_HOK) != 0:
+		return SvStr(RefKind(v))
+	}
+	return SvUndef()
+}
+
+// Perl_Scalar_Util_dualvar is a value that is num as a number and str as
+// a string
+func Perl_Scalar_Util_dualvar(num, str *SV) *SV {
+	n := perlstr.ParseNumber(num.AsString())
+	v := &SV{PV: str.AsString(), IV: num.AsInt(), NV: num.AsFloat(), Flags: SVf_IOK | SVf_NOK | SVf_POK}
+	if num.Flags&SVf_IOK == 0 && !n.IsInt {
+		v.IV = int64(v.NV)
+	}
+	return v
+}
+
+// A reference to an array or hash is the aggregate itself here, so there
+// is no reference of its own to weaken: weaken and unweaken leave it
+// strong and isweak is false. The Go garbage collector frees circular
+// structures no longer reachable without weak links.
+func Perl_Scalar_Util_weaken(v *SV) *SV { return SvUndef() }
+
+func Perl_Scalar_Util_unweaken(v *SV) *SV { return SvUndef() }
+
+func Perl_Scalar_Util_isweak(v *SV) *SV { return SvStr("") }
+
+// List::Util: the block of first, any, all and none gets the element as
+// $_, reduce reads the global $a and $b like a sort comparator
+func Perl_List_Util_sum(args ...*SV) *SV {
+	list := Flatten(args)
+	if len(list) == 0 {
+		return SvUndef()
+	}
+	return Perl_List_Util_sum0(list...)
+}
+
+func Perl_List_Util_sum0(args ...*SV) *SV {
+	total := SvInt(0)
+	for _, v := range Flatten(args) {
+		total = SvAdd(total, v)
+	}
+	return total
+}
+
+func Perl_List_Util_max(args ...*SV) *SV {
+	var best *SV
+	for _, v := range Flatten(args) {
+		if best == nil || v.AsFloat() > best.AsFloat() {
+			best = v
+		}
+	}
+	if best == nil {
+		return SvUndef()
+	}
+	return best
+}
+
+func Perl_List_Util_min(args ...*SV) *SV {
+	var best *SV
+	for _, v := range Flatten(args) {
+		if best == nil || v.AsFloat() < best.AsFloat() {
+			best = v
+		}
+	}
+	if best == nil {
+		return SvUndef()
+	}
+	return best
+}
+
+func Perl_List_Util_uniq(args ...*SV) *SV {
+	seen := make(map[string]bool)
+	seenUndef := false
+	var out []*SV
+	for _, v := range Flatten(args) {
+		if v.Flags == 0 && v.CV == nil {
+			if !seenUndef {
+				seenUndef = true
+				out = append(out, v)
+			}
+			continue
+		}
+		if key := v.AsString(); !seen[key] {
+			seen[key] = true
+			out = append(out, v)
+		}
+	}
+	return SvArray(out...)
+}
+
+func Perl_List_Util_shuffle(args ...*SV) *SV {
+	list := append([]*SV(nil), Flatten(args)...)
+	rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+	return SvArray(list...)
+}
+
+func Perl_List_Util_first(block func(*SV) *SV, args ...*SV) *SV {
+	for _, v := range Flatten(args) {
+		if block(v).IsTrue() {
+			return v
+		}
+	}
+	return SvUndef()
+}
+
+func Perl_List_Util_any(block func(*SV) *SV, args ...*SV) *SV {
+	for _, v := range Flatten(args) {
+		if block(v).IsTrue() {
+			return SvInt(1)
+		}
+	}
+	return SvStr("")
+}
+
+func Perl_List_Util_all(block func(*SV) *SV, args ...*SV) *SV {
+	for _, v := range Flatten(args) {
+		if !block(v).IsTrue() {
+			return SvStr("")
+		}
+	}
+	return SvInt(1)
+}
+
+func Perl_List_Util_none(block func(*SV) *SV, args ...*SV) *SV {
+	for _, v := range Flatten(args) {
+		if block(v).IsTrue() {
+			return SvStr("")
+		}
+	}
+	return SvInt(1)
+}
+
+func Perl_List_Util_reduce(block func() *SV, args ...*SV) *SV {
+	list := Flatten(args)
+	if len(list) == 0 {
+		return SvUndef()
+	}
+	saveA, saveB := V_a, V_b
+	defer func() { V_a, V_b = saveA, saveB }()
+	acc := list[0]
+	for _, v := range list[1:] {
+		V_a, V_b = acc, v
+		acc = block()
+	}
+	return acc
+}
+
+// Perl_Devel_Size_size is Devel::Size's size: the memory of a value, or of
+// what a reference points to, without following its elements
+func Perl_Devel_Size_size(args ...*SV) *SV {
+	if len(args) == 0 || args[0] == nil {
+		return SvInt(0)
+	}
+	v := args[0]
+	if v.Flags&0x80 != 0 && len(v.AV) > 0 {
+		v = v.AV[0]
+	}
+	return SvInt(int64(SvSize(v)))
+}
+
+// Perl_Devel_Size_total_size also counts everything reachable through
+// elements and references, each SV once
+func Perl_Devel_Size_total_size(args ...*SV) *SV {
+	if len(args) == 0 || args[0] == nil {
+		return SvInt(0)
+	}
+	v := args[0]
+	if v.Flags&0x80 != 0 && len(v.AV) > 0 {
+		v = v.AV[0]
+	}
+	seen := map[*SV]bool{}
+	var walk func(v *SV) int
+	walk = func(v *SV) int {
+		if v == nil || seen[v] {
+			return 0
+		}
+		seen[v] = true
+		n := SvSize(v)
+		for _, e := range v.AV {
+			n += walk(e)
+		}
+		for _, e := range v.HV {
+			n += walk(e)
+		}
+		return n
+	}
+	return SvInt(int64(walk(v)))
+}
+
+// SvSize is the SV, its string and its array or hash slots, counted as
+// in perlc (sv.Size)
+func SvSize(v *SV) int {
+	slot := int(reflect.TypeOf(v).Size())
+	n := int(reflect.TypeOf(*v).Size()) + len(v.PV) + cap(v.AV)*slot
+	for k := range v.HV {
+		n += (int(reflect.TypeOf(k).Size())+slot+1)*16/13 + len(k)
+	}
+	return n
+}
+
+// Regex compiles a runtime pattern ($str =~ $re), caching up to
+// Tune.regexCache patterns by source; a full cache is emptied
+var RegexCache = map[string]*perlre.Regexp{}
+var RegexMu sync.Mutex
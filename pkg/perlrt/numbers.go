@@ -0,0 +1,70 @@
+package perlrt
+
+import "perlc/pkg/perlstr"
+
+// CompatNumbers is --perl-compat-numbers, set by the program before it
+// runs: every arithmetic operator then reads its operands as perlstr
+// Numbers and goes through NumAdd, NumDiv and the rest, so results are
+// integers or doubles exactly when perl's are, and print the same.
+var CompatNumbers bool
+
+// SvNumber is a as an operand of the perl arithmetic
+func SvNumber(a *SV) perlstr.Number {
+	switch {
+	case a == nil:
+		return perlstr.IntNumber(0)
+	case a.Flags&SVf_UV != 0:
+		return perlstr.UintNumber(uint64(a.IV))
+	case a.Flags&SVf_IOK != 0:
+		return perlstr.IntNumber(a.IV)
+	case a.Flags&SVf_NOK != 0:
+		return perlstr.FloatNumber(a.NV)
+	case a.Flags&SVf_POK != 0:
+		return perlstr.ParseNumber(a.PV)
+	}
+	return perlstr.IntNumber(a.AsInt())
+}
+
+func SvOfNumber(n perlstr.Number) *SV {
+	if n.IsInt {
+		return SvInt(n.IV)
+	}
+	if n.IsUV {
+		return SvUint(n.UV)
+	}
+	return SvFloat(n.NV)
+}
+
+// NumDiv and NumMod are / and % under CompatNumbers: where is the
+// operator's "FILE line N" for the die of a zero divisor
+func NumDiv(where string, a, b *SV) *SV {
+	n, ok := perlstr.NumDiv(SvNumber(a), SvNumber(b))
+	if !ok {
+		return numDie("Illegal division by zero", where)
+	}
+	return SvOfNumber(n)
+}
+
+func NumMod(where string, a, b *SV) *SV {
+	n, ok := perlstr.NumMod(SvNumber(a), SvNumber(b))
+	if !ok {
+		return numDie("Illegal modulus zero", where)
+	}
+	return SvOfNumber(n)
+}
+
+func numDie(msg, where string) *SV {
+	if where != "" {
+		msg += " at " + where + "."
+	}
+	return Perl_die(SvStr(msg))
+}
+
+// compatNeg is -a under CompatNumbers: integers stay integers; a string
+// that is no number is negated as a double as before
+func compatNeg(a *SV) *SV {
+	if a != nil && a.Flags&(SVf_IOK|SVf_NOK) != 0 {
+		return SvOfNumber(perlstr.NumSub(perlstr.IntNumber(0), SvNumber(a)))
+	}
+	return SvFloat(-a.AsFloat())
+}
@@ -0,0 +1,371 @@
+package perlrt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// OOP Support
+var BlessedPkg = make(map[*SV]string)
+var BlessMu sync.RWMutex
+var Mro = make(map[string]string)
+var Methods = make(map[string]func(args ...*SV) *SV)
+
+func Perl_register_method(name string, fn func(args ...*SV) *SV) {
+	Methods[name] = fn
+}
+
+func Perl_bless(ref, class *SV) *SV {
+	BlessMu.Lock()
+	BlessedPkg[ref] = class.AsString()
+	BlessMu.Unlock()
+	return ref
+}
+
+// Blessed is the class of a blessed reference; tasks bless concurrently
+func Blessed(ref *SV) (string, bool) {
+	BlessMu.RLock()
+	defer BlessMu.RUnlock()
+	pkg, ok := BlessedPkg[ref]
+	return pkg, ok
+}
+
+func Perl_ref(sv *SV) *SV {
+	if sv == nil {
+		return SvStr("")
+	}
+	if pkg, ok := Blessed(sv); ok {
+		return SvStr(pkg)
+	}
+	if sv.CV != nil {
+		return SvStr("CODE")
+	}
+	if sv.Flags&0x20 != 0 {
+		return SvStr("GLOB")
+	}
+	if sv.Flags&0x40 != 0 {
+		return SvStr("Regexp")
+	}
+	if sv.Flags&(0x80|SVf_AOK|SVf_HOK) != 0 {
+		return SvStr(RefKind(sv))
+	}
+	return SvStr("")
+}
+
+// IsaArray is the @ISA of a package by its name: the program sets it to
+// its own, which knows the arrays it assigns
+var IsaArray = func(pkg string) *SV { return nil }
+
+// Isa is @ISA of pkg, the package array @PKG::ISA
+func Isa(pkg string) []string {
+	a := IsaArray(pkg)
+	if a == nil {
+		return nil
+	}
+	names := make([]string, len(a.AV))
+	for i, p := range a.AV {
+		names[i] = p.AsString()
+	}
+	return names
+}
+
+// LinearISA is the order methods of pkg are looked up in, pkg first:
+// depth-first through @ISA, each class once, or the C3 merge of the
+// parents under use mro 'c3'
+func LinearISA(pkg string) []string {
+	if Mro[pkg] == "c3" {
+		return C3(pkg, map[string]bool{})
+	}
+	var order []string
+	seen := map[string]bool{}
+	var walk func(pkg string)
+	walk = func(pkg string) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		order = append(order, pkg)
+		for _, parent := range Isa(pkg) {
+			walk(parent)
+		}
+	}
+	walk(pkg)
+	return order
+}
+
+// C3 takes the first head of the parents' orders that is in no tail,
+// and dies when there is none
+func C3(pkg string, active map[string]bool) []string {
+	if active[pkg] {
+		Perl_die(SvStr("Recursive inheritance detected in package '" + pkg + "'"))
+	}
+	active[pkg] = true
+	defer delete(active, pkg)
+	parents := Isa(pkg)
+	var seqs [][]string
+	for _, parent := range parents {
+		seqs = append(seqs, C3(parent, active))
+	}
+	seqs = append(seqs, parents)
+	result := []string{pkg}
+	for {
+		var heads [][]string
+		for _, seq := range seqs {
+			if len(seq) > 0 {
+				heads = append(heads, seq)
+			}
+		}
+		if len(heads) == 0 {
+			return result
+		}
+		seqs = heads
+		next := ""
+		for _, seq := range seqs {
+			inTail := false
+			for _, other := range seqs {
+				for _, c := range other[1:] {
+					if c == seq[0] {
+						inTail = true
+					}
+				}
+			}
+			if !inTail {
+				next = seq[0]
+				break
+			}
+		}
+		if next == "" {
+			Perl_die(SvStr("Inconsistent hierarchy during C3 merge of class '" + pkg + "':\n\tcurrent merge results [\n\t\t" +
+				strings.Join(result, ",\n\t\t") + ",\n\t]\n\tmerging failed on '" + seqs[len(seqs)-1][0] + "'"))
+		}
+		result = append(result, next)
+		for i, seq := range seqs {
+			if seq[0] == next {
+				seqs[i] = seq[1:]
+			}
+		}
+	}
+}
+
+// Perl_mro_get_linear_isa, Perl_mro_get_mro and Perl_mro_set_mro are
+// mro::get_linear_isa(CLASS), mro::get_mro(CLASS), mro::set_mro(CLASS, TYPE)
+func Perl_mro_get_linear_isa(class *SV) *SV {
+	var items []*SV
+	for _, c := range LinearISA(class.AsString()) {
+		items = append(items, SvStr(c))
+	}
+	return SvArray(items...)
+}
+
+func Perl_mro_get_mro(class *SV) *SV {
+	if mro, ok := Mro[class.AsString()]; ok {
+		return SvStr(mro)
+	}
+	return SvStr("dfs")
+}
+
+func Perl_mro_set_mro(class, mro *SV) *SV {
+	Mro[class.AsString()] = mro.AsString()
+	return SvUndef()
+}
+
+func Perl_method_call(obj *SV, method string, args ...*SV) *SV {
+	var pkg string
+
+	// IO::Handle methods on file handles: $fh->autoflush(1)
+	blessed, isObj := Blessed(obj)
+	if method == "autoflush" && !isObj {
+		if result, ok := Autoflush(obj, args); ok {
+			return result
+		}
+	}
+
+	// Check if obj is a class name (string) or blessed reference
+	if obj.Flags&SVf_POK != 0 && !isObj {
+		// Class method call: Point->new()
+		pkg = obj.AsString()
+	} else if isObj {
+		// Instance method call: $obj->method()
+		pkg = blessed
+	} else {
+		return SvUndef()
+	}
+
+	// The universal methods, unless the class has its own: isa and DOES
+	// walk @ISA, can returns the method found as a code ref
+	if len(args) > 0 && (method == "isa" || method == "DOES" || method == "can") && !HasMethod(pkg, method) {
+		if method == "can" {
+			return Can(pkg, args[0].AsString())
+		}
+		return Perl_isa_check(pkg, args[0].AsString())
+	}
+
+	// Search for method in class hierarchy
+	fullArgs := append([]*SV{obj}, args...)
+	return Perl_find_and_call(pkg, method, fullArgs)
+}
+
+// SuperCall is $obj->SUPER::method(...) in a sub of package pkg: the
+// method is looked up in the parents of pkg, not in the class of $obj
+func SuperCall(pkg string, obj *SV, method string, args ...*SV) *SV {
+	fullArgs := append([]*SV{obj}, args...)
+	for _, parent := range Isa(pkg) {
+		for _, class := range LinearISA(parent) {
+			if fn, ok := Methods[class+"_"+method]; ok {
+				return fn(fullArgs...)
+			}
+		}
+	}
+	return SvUndef()
+}
+
+// Can is the method of pkg named method as a code ref, or undef
+func Can(pkg, method string) *SV {
+	for _, class := range LinearISA(pkg) {
+		if fn, ok := Methods[class+"_"+method]; ok {
+			return SvCode(fn)
+		}
+	}
+	return SvUndef()
+}
+
+// Perl_can is can($obj, 'method')
+func Perl_can(obj, method *SV) *SV {
+	if pkg, ok := Blessed(obj); ok {
+		return Can(pkg, method.AsString())
+	}
+	if obj.Flags&SVf_POK != 0 {
+		return Can(obj.AsString(), method.AsString())
+	}
+	return SvUndef()
+}
+
+func HasMethod(pkg, method string) bool {
+	for _, class := range LinearISA(pkg) {
+		if _, ok := Methods[class+"_"+method]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodCallDyn is $obj->$m(...): a code ref in $m is called directly
+func MethodCallDyn(obj, m *SV, args ...*SV) *SV {
+	if m.CV != nil {
+		return m.CV(append([]*SV{obj}, args...)...)
+	}
+	return Perl_method_call(obj, m.AsString(), args...)
+}
+
+func Perl_find_and_call(pkg, method string, args []*SV) *SV {
+	// The package, then its parent classes in method resolution order
+	for _, class := range LinearISA(pkg) {
+		if fn, ok := Methods[class+"_"+method]; ok {
+			return fn(args...)
+		}
+	}
+	return SvUndef()
+}
+
+func Perl_isa(obj, class *SV) *SV {
+	pkg, ok := Blessed(obj)
+	if !ok {
+		return SvInt(0)
+	}
+	target := class.AsString()
+	if pkg == target {
+		return SvInt(1)
+	}
+	return Perl_isa_check(pkg, target)
+}
+
+func Perl_isa_check(pkg, target string) *SV {
+	for _, class := range LinearISA(pkg) {
+		if class == target {
+			return SvInt(1)
+		}
+	}
+	return SvInt(0)
+}
+
+// use overload: '""' is how a blessed reference prints
+var Overloads = make(map[string]map[string]*SV)
+
+// Overload registers the op => code pairs of use overload in pkg
+func Overload(pkg string, pairs ...*SV) {
+	ops := Overloads[pkg]
+	if ops == nil {
+		ops = make(map[string]*SV)
+		Overloads[pkg] = ops
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		ops[pairs[i].AsString()] = pairs[i+1]
+	}
+}
+
+// FindOverload looks op up in pkg, then in its @ISA
+func FindOverload(pkg, op string) *SV {
+	for _, class := range LinearISA(pkg) {
+		if code, ok := Overloads[class][op]; ok {
+			return code
+		}
+	}
+	return nil
+}
+
+// RefString is a blessed reference as a string: its '""' overload, called
+// with (obj, undef, ""), or Class=HASH(0x...)
+func RefString(sv *SV) string {
+	addr := RefAddr(sv)
+	if addr == 0 {
+		return ""
+	}
+	pkg, blessed := Blessed(sv)
+	if blessed {
+		if code := FindOverload(pkg, "\"\""); code != nil {
+			args := []*SV{sv, SvUndef(), SvStr("")}
+			if code.CV != nil {
+				return code.CV(args...).AsString()
+			}
+			return Perl_find_and_call(pkg, code.AsString(), args).AsString()
+		}
+	}
+	kind := RefKind(sv)
+	if blessed {
+		return fmt.Sprintf("%s=%s(0x%x)", pkg, kind, addr)
+	}
+	return fmt.Sprintf("%s(0x%x)", kind, addr)
+}
+
+// RefAddr is the address a reference prints and numifies as: the target
+// of \$x, the array, hash or sub itself otherwise; 0 for a non-reference
+func RefAddr(sv *SV) uintptr {
+	switch {
+	case sv.Flags&0x80 != 0 && len(sv.AV) > 0:
+		return reflect.ValueOf(sv.AV[0]).Pointer()
+	case sv.CV != nil, sv.Flags&(SVf_AOK|SVf_HOK) != 0:
+		return reflect.ValueOf(sv).Pointer()
+	}
+	return 0
+}
+
+// RefKind is the type of what a reference points to, as ref() names it
+// for an unblessed one
+func RefKind(sv *SV) string {
+	switch {
+	case sv.CV != nil:
+		return "CODE"
+	case sv.Flags&0x80 != 0:
+		if len(sv.AV) > 0 && sv.AV[0] != nil && sv.AV[0].Flags&SVf_POK == 0 && RefAddr(sv.AV[0]) != 0 {
+			return "REF"
+		}
+		return "SCALAR"
+	case sv.Flags&SVf_AOK != 0:
+		return "ARRAY"
+	case sv.Flags&SVf_HOK != 0:
+		return "HASH"
+	}
+	return "SCALAR"
+}
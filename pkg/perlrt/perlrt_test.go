@@ -0,0 +1,35 @@
+package perlrt
+
+import (
+	"testing"
+
+	"perlc/pkg/tunables"
+	"perlc/pkg/warnings"
+)
+
+// The runtime does not import the compiler's packages, so the knobs and
+// warning categories it shares with them are checked here.
+
+func TestTunablesMatch(t *testing.T) {
+	def := tunables.Default()
+	want := def.Values()
+	got := defaultTunables
+	values := []int64{got.MaxDepth, got.HashSeed, got.RegexCache, got.IoBuffer, got.Warnings}
+	for i, k := range tunables.Knobs() {
+		if values[i] != want[i] {
+			t.Errorf("%s defaults to %d, tunables to %d", k.Env, values[i], want[i])
+		}
+	}
+}
+
+func TestWarningCategoriesMatch(t *testing.T) {
+	for _, name := range warnings.Categories() {
+		c := warnings.Category(name)
+		if WarnVerbose[name] != warnings.Verbose(c) {
+			t.Errorf("%s: verbose %v, warnings %v", name, WarnVerbose[name], warnings.Verbose(c))
+		}
+		if WarnOptional[name] != warnings.Optional(c) {
+			t.Errorf("%s: optional %v, warnings %v", name, WarnOptional[name], warnings.Optional(c))
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package perlrt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// system LIST / system STRING; strings with shell metacharacters go through the shell
+func Command(args []*SV) *exec.Cmd {
+	var words []string
+	for _, a := range args {
+		if a.Flags&SVf_AOK != 0 {
+			for _, e := range a.AV {
+				words = append(words, e.AsString())
+			}
+		} else {
+			words = append(words, a.AsString())
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	var cmd *exec.Cmd
+	if len(words) == 1 {
+		line := words[0]
+		if strings.ContainsAny(line, "$&*(){}[]'\";\\|?<>~`\n") {
+			if runtime.GOOS == "windows" {
+				cmd = exec.Command("cmd", "/C", line)
+			} else {
+				cmd = exec.Command("/bin/sh", "-c", line)
+			}
+		} else {
+			f := strings.Fields(line)
+			if len(f) == 0 {
+				return nil
+			}
+			cmd = exec.Command(f[0], f[1:]...)
+		}
+	} else {
+		cmd = exec.Command(words[0], words[1:]...)
+	}
+	cmd.Env = Environ()
+	return cmd
+}
+
+func Perl_system(args ...*SV) *SV {
+	cmd := Command(args)
+	if cmd == nil {
+		ChildStatus = -1
+		return SvInt(-1)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, Stdout, Stderr
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		ChildStatus = -1
+		return SvInt(-1)
+	}
+	ChildStatus = ExitStatus(err)
+	return SvInt(ChildStatus)
+}
+
+// errNoExec: execProcess cannot replace the program on this platform
+var errNoExec = errors.New("exec not supported on this platform")
+
+// exec replaces the program with the command, as in perl. When STDOUT or
+// STDERR are redirected inside the program, or the platform cannot exec,
+// the command runs as a child and the program exits with its status.
+func Perl_exec(args ...*SV) *SV {
+	cmd := Command(args)
+	if cmd == nil {
+		return SvInt(0)
+	}
+	FlushAll()
+	if Stdout == io.Writer(os.Stdout) && Stderr == io.Writer(os.Stderr) {
+		if err := execProcess(cmd); err != errNoExec {
+			SetOSError(err)
+			return SvInt(0)
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, Stdout, Stderr
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return SvInt(0)
+	}
+	Exit(int(ExitStatus(err) >> 8))
+	return SvInt(0)
+}
+
+// `command` / qx//: stdout is captured, stderr passes through
+func RunCommand(line *SV) (string, bool) {
+	cmd := Command([]*SV{line})
+	if cmd == nil {
+		ChildStatus = -1
+		return "", false
+	}
+	var out bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &out, Stderr
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		ChildStatus = -1
+		return "", false
+	}
+	ChildStatus = ExitStatus(err)
+	return out.String(), true
+}
+
+func PerlCommand(line *SV) *SV {
+	out, ok := RunCommand(line)
+	if !ok {
+		return SvUndef()
+	}
+	return SvStr(out)
+}
+
+// in list context the output is split into records by $/, like <FH>
+func PerlCommandLines(line *SV) *SV {
+	out, _ := RunCommand(line)
+	var lines []*SV
+	scanner := NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		lines = append(lines, SvStr(scanner.Text()))
+	}
+	return SvArray(lines...)
+}
+
+// IPC::Open3 - stderr joins stdout when the error handle is undef or ""
+type Child struct {
+	Done   chan struct{}
+	Status int64
+}
+
+var Children = map[int]*Child{}
+var ChildExit = make(chan struct{}, 1)
+
+// Children are reaped in the background; exit queues CHLD before done is closed
+func StartChild(cmd *exec.Cmd) {
+	c := &Child{Done: make(chan struct{})}
+	Children[cmd.Process.Pid] = c
+	go func() {
+		c.Status = ExitStatus(cmd.Wait())
+		RaiseSignal("CHLD")
+		close(c.Done)
+		select {
+		case ChildExit <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+func Perl_open3(in, out, errh *SV, args ...*SV) *SV {
+	cmd := Command(args)
+	if cmd == nil {
+		return SvInt(0)
+	}
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return SvInt(0)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return SvInt(0)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = inR, outW, outW
+	var errR, errW *os.File
+	if errh.AsString() != "" {
+		if errR, errW, err = os.Pipe(); err != nil {
+			return SvInt(0)
+		}
+		cmd.Stderr = errW
+	}
+	err = cmd.Start()
+	inR.Close()
+	outW.Close()
+	if errW != nil {
+		errW.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(Stderr, "open3: %v\n", err)
+		return SvInt(0)
+	}
+	StartChild(cmd)
+	Filehandles[in.AsString()] = NewFileHandle(inW, ">")
+	Filehandles[out.AsString()] = NewFileHandle(outR, "<")
+	if errR != nil {
+		Filehandles[errh.AsString()] = NewFileHandle(errR, "<")
+	}
+	return SvInt(int64(cmd.Process.Pid))
+}
+
+func Reap(pid int, nohang bool) int {
+	for {
+		if pid > 0 {
+			c, ok := Children[pid]
+			if !ok {
+				return -1
+			}
+			if nohang {
+				select {
+				case <-c.Done:
+				default:
+					return 0
+				}
+			}
+			<-c.Done
+			delete(Children, pid)
+			ChildStatus = c.Status
+			return pid
+		}
+		if len(Children) == 0 {
+			return -1
+		}
+		for p, c := range Children {
+			select {
+			case <-c.Done:
+				delete(Children, p)
+				ChildStatus = c.Status
+				return p
+			default:
+			}
+		}
+		if nohang {
+			return 0
+		}
+		<-ChildExit
+	}
+}
+
+func Perl_waitpid(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(-1)
+	}
+	nohang := len(args) > 1 && args[1].AsInt()&1 != 0
+	pid := Reap(int(args[0].AsInt()), nohang)
+	if pid < 0 {
+		ChildStatus = -1
+	}
+	CheckSignals()
+	return SvInt(int64(pid))
+}
+
+func Perl_wait(args ...*SV) *SV {
+	pid := Reap(-1, false)
+	if pid < 0 {
+		ChildStatus = -1
+	}
+	CheckSignals()
+	return SvInt(int64(pid))
+}
+
+// %SIG: handlers run at safe points (loop iterations, wait, sleep)
+var H_SIG = SvHash()
+var SigMu sync.Mutex
+var SigQueue []string
+var SigPending atomic.Bool
+var SigNotify = make(chan struct{}, 1)
+var InSignal bool
+
+func RaiseSignal(name string) {
+	SigMu.Lock()
+	SigQueue = append(SigQueue, name)
+	SigMu.Unlock()
+	SigPending.Store(true)
+	select {
+	case SigNotify <- struct{}{}:
+	default:
+	}
+}
+
+func CheckSignals() {
+	if !SigPending.Load() || InSignal {
+		return
+	}
+	InSignal = true
+	defer func() { InSignal = false }()
+	SigMu.Lock()
+	names := SigQueue
+	SigQueue = nil
+	SigPending.Store(false)
+	select {
+	case <-SigNotify:
+	default:
+	}
+	SigMu.Unlock()
+	for _, name := range names {
+		h := H_SIG.HV[name]
+		if h == nil || h.AsString() == "DEFAULT" {
+			if name == "ALRM" {
+				fmt.Fprintln(Stderr, "Alarm clock")
+				Exit(128 + 14)
+			}
+			continue
+		}
+		if h.CV != nil {
+			h.CV(SvStr(name))
+		} else if h.AsString() == "IGNORE" && name == "CHLD" {
+			for Reap(-1, true) > 0 {
+			}
+		}
+	}
+}
+
+func Perl_time(args ...*SV) *SV { return SvInt(time.Now().Unix()) }
@@ -0,0 +1,14 @@
+//go:build linux
+
+package perlrt
+
+import "os"
+
+// setProcTitle renames the process for ps and top. /proc/self/comm names
+// the main thread whichever thread writes it; the kernel keeps 15 bytes.
+func setProcTitle(name string) {
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	os.WriteFile("/proc/self/comm", []byte(name), 0)
+}
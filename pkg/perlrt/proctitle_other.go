@@ -0,0 +1,7 @@
+//go:build !linux
+
+package perlrt
+
+// setProcTitle cannot rename the process on this platform; $0 only
+// changes its value.
+func setProcTitle(name string) {}
@@ -0,0 +1,240 @@
+package perlrt
+
+import (
+	"strings"
+
+	"perlc/pkg/perlre"
+)
+
+func Regex(p string) *perlre.Regexp {
+	RegexMu.Lock()
+	defer RegexMu.Unlock()
+	if re, ok := RegexCache[p]; ok {
+		return re
+	}
+	re, err := perlre.Compile(p)
+	if err != nil {
+		Warn("regexp", "", err.Error())
+		re = NoMatch
+	}
+	if Tune.RegexCache <= 0 {
+		return re
+	}
+	if int64(len(RegexCache)) >= Tune.RegexCache {
+		clear(RegexCache)
+	}
+	RegexCache[p] = re
+	return re
+}
+
+// NoMatch stands for a pattern that does not compile: it never matches
+var NoMatch = perlre.MustCompile("[^\\x00-\\x{10FFFF}]")
+
+// BadRegex is a literal pattern perlre rejected when the program was
+// compiled; the warning carries the line of the match
+func BadRegex(loc, msg string) *perlre.Regexp {
+	Warn("regexp", loc, msg)
+	return NoMatch
+}
+
+var RegexOnce = map[int]*perlre.Regexp{}
+
+// CompileOnce keeps the first compiled form of an /o pattern
+func CompileOnce(id int, build func() *perlre.Regexp) *perlre.Regexp {
+	if re, ok := RegexOnce[id]; ok {
+		return re
+	}
+	re := build()
+	RegexOnce[id] = re
+	return re
+}
+
+// Regex captures
+// The last successful match: $1..$N in Captures (nil for a group that
+// did not take part), $&, $`, $' and the group names for %+ and %-
+var Captures []*SV
+var LastMatch, PreMatch, PostMatch string
+var Names []string
+
+func SetMatch(re *perlre.Regexp, s string, loc []int) {
+	LastMatch, PreMatch, PostMatch = s[loc[0]:loc[1]], s[:loc[0]], s[loc[1]:]
+	Captures, Names = make([]*SV, len(loc)/2-1), re.SubexpNames()[1:]
+	for n := 1; n < len(loc)/2; n++ {
+		if loc[2*n] < 0 {
+			continue
+		}
+		Captures[n-1] = SvStr(s[loc[2*n]:loc[2*n+1]])
+	}
+}
+
+func Capture(n int) *SV {
+	if n < 1 || n > len(Captures) || Captures[n-1] == nil {
+		return SvUndef()
+	}
+	c := *Captures[n-1]
+	return &c
+}
+
+// LastParen is $+, the highest group that matched
+func LastParen() *SV {
+	for n := len(Captures); n > 0; n-- {
+		if Captures[n-1] != nil {
+			return Capture(n)
+		}
+	}
+	return SvUndef()
+}
+
+// NamedCapture is $+{name}, the leftmost group of that name that matched
+func NamedCapture(name string) *SV {
+	for n, g := range Names {
+		if g == name && Captures[n] != nil {
+			return Capture(n + 1)
+		}
+	}
+	return SvUndef()
+}
+
+// NamedHash is %+, or %- with all: each name with its leftmost group
+// that matched, or with the array of all its groups
+func NamedHash(all bool) *SV {
+	h := SvHash()
+	for n, name := range Names {
+		if name == "" {
+			continue
+		}
+		if !all {
+			if _, ok := h.HV[name]; !ok && Captures[n] != nil {
+				h.HV[name] = Capture(n + 1)
+			}
+			continue
+		}
+		if _, ok := h.HV[name]; !ok {
+			h.HV[name] = SvArray()
+		}
+		h.HV[name].AV = append(h.HV[name].AV, Capture(n+1))
+	}
+	return h
+}
+
+// Match runs re on s and keeps the match variables when it matches
+func Match(re *perlre.Regexp, s string) bool {
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return false
+	}
+	SetMatch(re, s, loc)
+	return true
+}
+
+// MatchList is m// in list context: the groups, (1) for a pattern
+// without groups and () when it does not match
+func MatchList(re *perlre.Regexp, s string) *SV {
+	if !Match(re, s) {
+		return SvArray()
+	}
+	if len(Captures) == 0 {
+		return SvArray(SvInt(1))
+	}
+	out := make([]*SV, len(Captures))
+	for n := range out {
+		out[n] = Capture(n + 1)
+	}
+	return SvArray(out...)
+}
+
+// Substitute is s///: it replaces the first match of re in s (all of
+// them with /g), setting the match variables before repl builds each
+// replacement, and returns the new string and the number of replacements
+func Substitute(re *perlre.Regexp, s string, all bool, repl func() string) (string, int) {
+	limit := 1
+	if all {
+		limit = -1
+	}
+	var b strings.Builder
+	last, n := 0, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, limit) {
+		SetMatch(re, s, loc)
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(repl())
+		last = loc[1]
+		n++
+	}
+	if n == 0 {
+		return s, 0
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// pos
+var Pos = map[*SV]int{}
+
+func Perl_pos(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvUndef()
+	}
+	p, ok := Pos[args[0]]
+	if !ok {
+		return SvUndef()
+	}
+	return SvInt(int64(p))
+}
+
+// MatchPos runs a scalar m//g match starting at pos(t). On failure pos
+// is reset unless keep (/c) is set
+func MatchPos(re *perlre.Regexp, t *SV, keep bool) bool {
+	s := t.AsString()
+	start := Pos[t]
+	if start > len(s) {
+		start = 0
+	}
+	loc := re.FindStringSubmatchIndex(s[start:])
+	if loc == nil {
+		if !keep {
+			delete(Pos, t)
+		}
+		return false
+	}
+	for n := range loc {
+		if loc[n] >= 0 {
+			loc[n] += start
+		}
+	}
+	SetMatch(re, s, loc)
+	end := loc[1]
+	if loc[1] == loc[0] {
+		end++
+	}
+	Pos[t] = end
+	return true
+}
+
+// MatchAll is m//g in list context: from pos(t), the groups of every
+// match in a row, or the matches themselves for a pattern without
+// groups. pos is reset
+func MatchAll(re *perlre.Regexp, t *SV) *SV {
+	s := t.AsString()
+	start := Pos[t]
+	if start > len(s) {
+		start = 0
+	}
+	delete(Pos, t)
+	var out []*SV
+	for _, loc := range re.FindAllStringSubmatchIndex(s[start:], -1) {
+		for n := range loc {
+			if loc[n] >= 0 {
+				loc[n] += start
+			}
+		}
+		SetMatch(re, s, loc)
+		if len(Captures) == 0 {
+			out = append(out, SvStr(LastMatch))
+			continue
+		}
+		for n := range Captures {
+			out = append(out, Capture(n+1))
+		}
+	}
+	return SvArray(out...)
+}
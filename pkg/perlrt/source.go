@@ -0,0 +1,10 @@
+package perlrt
+
+import "embed"
+
+// Sources are the files of the package, for a generated program that
+// imports it: perlc writes them into the module it builds the program in.
+// The files of every platform are there; go build picks by build tag.
+//
+//go:embed *.go
+var Sources embed.FS
@@ -0,0 +1,95 @@
+package perlrt
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// Standard streams: all output of the program goes through these two
+// writers, so a harness or an embedding program can capture it
+var Stdout io.Writer = os.Stdout
+var Stderr io.Writer = os.Stderr
+
+func StdStream(name string) io.Writer {
+	switch strings.TrimPrefix(name, "main::") {
+	case "STDOUT":
+		return Stdout
+	case "STDERR":
+		return Stderr
+	}
+	return nil
+}
+
+// Special variables a program can assign: print puts $, between its
+// values and $\ after them, "@a" joins with $", readline splits on $/
+var (
+	Ofs         = SvUndef()         // $,
+	Ors         = SvUndef()         // $\
+	ListSep     = SvStr(" ")        // $"
+	Irs         = SvStr("\n")       // $/
+	StdoutFlush = SvInt(0)          // $| of the selected handle
+	ProgName    = SvStr(os.Args[0]) // $0
+)
+
+func SetSpecial(name string, v *SV) *SV {
+	c := *v
+	v = &c
+	switch name {
+	case "$,":
+		Ofs = v
+	case "$\\":
+		Ors = v
+	case "$\"":
+		ListSep = v
+	case "$/":
+		Irs = v
+	case "$|":
+		if v.IsTrue() {
+			v = SvInt(1)
+		} else {
+			v = SvInt(0)
+		}
+		StdoutFlush = v
+		if fh, ok := Filehandles[Selected]; ok {
+			fh.Autoflush = v.IV == 1
+			if fh.Writer != nil {
+				fh.Writer.Flush()
+			}
+		}
+	case "$0":
+		ProgName = v
+		setProcTitle(v.AsString())
+	}
+	return v
+}
+
+// SpecialStr is the string of $, or $\: undef, the default, adds nothing
+func SpecialStr(v *SV) string {
+	if v.Flags == 0 {
+		return ""
+	}
+	return v.AsString()
+}
+
+// The save stack of local: a local pushes what puts the old value back,
+// and the block of the local pops down to the mark it took when it began.
+// Subs, evals and do blocks restore their mark in a defer, so return and
+// die undo the locals of the blocks they leave too.
+var saveStack []func()
+
+// SaveMark is the height of the save stack at the start of a block
+func SaveMark() int { return len(saveStack) }
+
+// Save pushes restore, which local runs when its block is left
+func Save(restore func()) { saveStack = append(saveStack, restore) }
+
+// Restore undoes the locals made since mark, the latest first
+func Restore(mark int) {
+	for len(saveStack) > mark {
+		n := len(saveStack) - 1
+		restore := saveStack[n]
+		saveStack = saveStack[:n]
+		restore()
+	}
+}
@@ -0,0 +1,20 @@
+//go:build darwin || freebsd || netbsd
+
+package perlrt
+
+import (
+	"os"
+	"syscall"
+)
+
+// sysStat fills the fields os.FileInfo does not have from stat(2).
+func sysStat(fi os.FileInfo, st *[13]int64) {
+	s, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	st[0], st[1], st[2], st[3] = int64(s.Dev), int64(s.Ino), int64(s.Mode), int64(s.Nlink)
+	st[4], st[5], st[6] = int64(s.Uid), int64(s.Gid), int64(s.Rdev)
+	st[8], st[10] = int64(s.Atimespec.Sec), int64(s.Ctimespec.Sec)
+	st[11], st[12] = int64(s.Blksize), int64(s.Blocks)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+
+package perlrt
+
+import "os"
+
+// sysStat has nothing to add here (Windows): dev, ino, uid and gid are 0,
+// nlink 1, the times all mtime, blksize and blocks unknown.
+func sysStat(fi os.FileInfo, st *[13]int64) {}
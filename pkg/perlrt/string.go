@@ -0,0 +1,237 @@
+package perlrt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"perlc/pkg/perlpack"
+	"perlc/pkg/perlstr"
+)
+
+// index
+func Perl_index(str, substr *SV, args ...*SV) *SV {
+	s := str.AsString()
+	sub := substr.AsString()
+	start := 0
+	if len(args) > 0 {
+		start = int(args[0].AsInt())
+		if start < 0 {
+			start = 0
+		}
+		if start > len(s) {
+			return SvInt(-1)
+		}
+	}
+	pos := strings.Index(s[start:], sub)
+	if pos == -1 {
+		return SvInt(-1)
+	}
+	return SvInt(int64(pos + start))
+}
+
+// rindex
+func Perl_rindex(str, substr *SV, args ...*SV) *SV {
+	s := str.AsString()
+	sub := substr.AsString()
+	end := len(s)
+	if len(args) > 0 {
+		end = int(args[0].AsInt()) + len(sub)
+		if end > len(s) {
+			end = len(s)
+		}
+		if end < 0 {
+			return SvInt(-1)
+		}
+	}
+	pos := strings.LastIndex(s[:end], sub)
+	return SvInt(int64(pos))
+}
+
+// lcfirst
+func Perl_lcfirst(sv *SV) *SV {
+	s := sv.AsString()
+	if len(s) == 0 {
+		return SvStr("")
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return SvStr(string(r))
+}
+
+// ucfirst
+func Perl_ucfirst(sv *SV) *SV {
+	s := sv.AsString()
+	if len(s) == 0 {
+		return SvStr("")
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return SvStr(string(r))
+}
+
+// chop
+func Perl_chop(sv *SV) *SV {
+	if sv == nil {
+		return SvStr("")
+	}
+	s := sv.PV
+	if len(s) == 0 {
+		return SvStr("")
+	}
+	r := []rune(s)
+	last := string(r[len(r)-1])
+	sv.PV = string(r[:len(r)-1])
+	return SvStr(last)
+}
+
+// sprintf
+func Perl_sprintf(args ...*SV) *SV {
+	return sprintf(nil, args)
+}
+
+// SprintfWarn is sprintf (op "sprintf") or printf (op "printf") under use
+// warnings: a string read as a number that does not look like one warns
+// "Argument isn't numeric in op" at loc
+func SprintfWarn(op, loc string, args ...*SV) *SV {
+	return sprintf(func(v perlstr.Scalar) {
+		if a, ok := v.(*SV); ok {
+			NumArg(a, op, loc)
+		}
+	}, args)
+}
+
+func sprintf(num func(perlstr.Scalar), args []*SV) *SV {
+	if len(args) == 0 {
+		return SvStr("")
+	}
+	fmtArgs := make([]any, len(args)-1)
+	for i, a := range args[1:] {
+		fmtArgs[i] = a
+	}
+	return SvStr(perlstr.SprintfNum(num, args[0].AsString(), fmtArgs...))
+}
+
+// quotemeta
+func Perl_quotemeta(sv *SV) *SV {
+	return SvStr(regexp.QuoteMeta(sv.AsString()))
+}
+
+// hex
+func Perl_hex(sv *SV) *SV {
+	s := sv.AsString()
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.TrimPrefix(s, "0X")
+	v, _ := strconv.ParseInt(s, 16, 64)
+	return SvInt(v)
+}
+
+// oct
+func Perl_oct(sv *SV) *SV {
+	s := strings.TrimSpace(sv.AsString())
+	var v int64
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		v, _ = strconv.ParseInt(s[2:], 16, 64)
+	} else if strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B") {
+		v, _ = strconv.ParseInt(s[2:], 2, 64)
+	} else if strings.HasPrefix(s, "0") && len(s) > 1 {
+		v, _ = strconv.ParseInt(s[1:], 8, 64)
+	} else {
+		v, _ = strconv.ParseInt(s, 8, 64)
+	}
+	return SvInt(v)
+}
+
+// fc (fold case)
+func Perl_fc(sv *SV) *SV {
+	return SvStr(strings.ToLower(sv.AsString()))
+}
+
+// pack/unpack: the templates are perlpack's, as in the interpreter
+func Perl_pack(template *SV, list []*SV) *SV {
+	args := make([]perlpack.Arg, len(list))
+	for i, v := range list {
+		args[i] = v
+	}
+	packed, err := perlpack.Pack(template.AsString(), args)
+	if err != nil {
+		Perl_die(SvStr(err.Error()))
+	}
+	return SvStr(packed)
+}
+
+func Perl_unpack(template, data *SV) *SV {
+	values, err := perlpack.Unpack(template.AsString(), data.AsString())
+	if err != nil {
+		Perl_die(SvStr(err.Error()))
+	}
+	results := make([]*SV, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case int64:
+			results[i] = SvInt(v)
+		case uint64:
+			results[i] = SvFloat(float64(v))
+		case float64:
+			results[i] = SvFloat(v)
+		case string:
+			results[i] = SvStr(v)
+		}
+	}
+	return SvArray(results...)
+}
+
+// wantarray
+func Perl_wantarray() *SV {
+	return SvUndef()
+}
+
+// printf - same conversions as sprintf (%02d needs an integer argument)
+func Perl_printf(args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(0)
+	}
+	return printString(Perl_sprintf(args...).AsString())
+}
+
+// PrintfWarn is printf with the warnings of SprintfWarn
+func PrintfWarn(loc string, args ...*SV) *SV {
+	if len(args) == 0 {
+		return SvInt(0)
+	}
+	return printString(SprintfWarn("printf", loc, args...).AsString())
+}
+
+// PrintfFH is printf to the handle fhName of s, the string of sprintf
+func PrintfFH(fhName string, s *SV) *SV {
+	return PrintFH(fhName, []*SV{s}, "")
+}
+
+// PrintfTo is printf $x LIST: to the handle in $x, or $x is the format.
+// loc is where for the warnings of SprintfWarn, "" for none
+func PrintfTo(loc string, fh *SV, args ...*SV) *SV {
+	name := HandleName(fh)
+	if _, ok := Filehandles[name]; !ok && StdStream(name) == nil {
+		args = append([]*SV{fh}, args...)
+		if loc != "" {
+			return PrintfWarn(loc, args...)
+		}
+		return Perl_printf(args...)
+	}
+	if loc != "" {
+		return PrintfFH(name, SprintfWarn("printf", loc, args...))
+	}
+	return PrintfFH(name, Perl_sprintf(args...))
+}
+
+// printString prints s to the selected handle, for printf
+func printString(s string) *SV {
+	if Selected != "STDOUT" {
+		PrintFH(Selected, []*SV{SvStr(s)}, "")
+	} else {
+		fmt.Fprint(Stdout, s)
+	}
+	return SvInt(int64(len(s)))
+}
@@ -0,0 +1,364 @@
+package perlrt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"perlc/pkg/perlstr"
+)
+
+// SV type
+type SV struct {
+	IV    int64
+	NV    float64
+	PV    string
+	AV    []*SV
+	HV    map[string]*SV
+	CV    func(args ...*SV) *SV
+	Flags uint16
+}
+
+const (
+	SVf_IOK uint16 = 1 << iota
+	SVf_NOK
+	SVf_POK
+	SVf_AOK
+	SVf_HOK
+)
+
+// SVf_UV marks an IOK integer past int64: IV holds the bits of perl's
+// unsigned integer (CompatNumbers only)
+const SVf_UV uint16 = 0x100
+
+// Constructors
+func SvInt(i int64) *SV                   { return &SV{IV: i, Flags: SVf_IOK} }
+func SvFloat(f float64) *SV               { return &SV{NV: f, Flags: SVf_NOK} }
+func SvStr(s string) *SV                  { return &SV{PV: s, Flags: SVf_POK} }
+func SvUndef() *SV                        { return &SV{} }
+func SvArray(elems ...*SV) *SV            { return &SV{AV: elems, Flags: SVf_AOK} }
+func SvHash() *SV                         { return &SV{HV: make(map[string]*SV), Flags: SVf_HOK} }
+func SvCode(fn func(args ...*SV) *SV) *SV { return &SV{CV: fn} }
+func SvRegex(p string) *SV                { return &SV{PV: p, Flags: SVf_POK | 0x40} }
+
+// SvUint is u, one of perl's unsigned integers past int64
+func SvUint(u uint64) *SV {
+	if u <= math.MaxInt64 {
+		return SvInt(int64(u))
+	}
+	return &SV{IV: int64(u), Flags: SVf_IOK | SVf_UV}
+}
+
+func CallCode(c *SV, args ...*SV) *SV {
+	if c == nil || c.CV == nil {
+		return SvUndef()
+	}
+	return c.CV(args...)
+}
+
+// Converters
+func (sv *SV) AsInt() int64 {
+	if sv == nil {
+		return 0
+	}
+	if sv.Flags&SVf_IOK != 0 {
+		return sv.IV
+	}
+	if sv.Flags&SVf_NOK != 0 {
+		return int64(sv.NV)
+	}
+	if sv.Flags&SVf_POK != 0 {
+		return perlstr.ParseNumber(sv.PV).IV
+	}
+	return int64(RefAddr(sv))
+}
+
+func (sv *SV) AsFloat() float64 {
+	if sv == nil {
+		return 0
+	}
+	if sv.Flags&SVf_NOK != 0 {
+		return sv.NV
+	}
+	if sv.Flags&SVf_UV != 0 {
+		return float64(uint64(sv.IV))
+	}
+	if sv.Flags&SVf_IOK != 0 {
+		return float64(sv.IV)
+	}
+	if sv.Flags&SVf_POK != 0 {
+		return perlstr.ParseNumber(sv.PV).NV
+	}
+	return float64(RefAddr(sv))
+}
+
+func (sv *SV) AsString() string {
+	if sv == nil {
+		return ""
+	}
+	if sv.Flags&SVf_POK != 0 {
+		return sv.PV
+	}
+	if sv.Flags&SVf_UV != 0 {
+		return fmt.Sprintf("%d", uint64(sv.IV))
+	}
+	if sv.Flags&SVf_IOK != 0 {
+		return fmt.Sprintf("%d", sv.IV)
+	}
+	if sv.Flags&SVf_NOK != 0 {
+		return perlstr.FormatFloat(sv.NV)
+	}
+	return RefString(sv)
+}
+
+func (sv *SV) IsTrue() bool {
+	if sv == nil {
+		return false
+	}
+	if sv.CV != nil {
+		return true
+	}
+	if sv.Flags&SVf_IOK != 0 {
+		return sv.IV != 0
+	}
+	if sv.Flags&SVf_NOK != 0 {
+		return sv.NV != 0
+	}
+	if sv.Flags&SVf_POK != 0 {
+		return sv.PV != "" && sv.PV != "0"
+	}
+	if sv.Flags&SVf_AOK != 0 {
+		return len(sv.AV) > 0
+	}
+	if sv.Flags&SVf_HOK != 0 {
+		return len(sv.HV) > 0
+	}
+	return false
+}
+
+// Operations; under CompatNumbers they are perl's, in numbers.go
+func SvAdd(a, b *SV) *SV {
+	if CompatNumbers {
+		return SvOfNumber(perlstr.NumAdd(SvNumber(a), SvNumber(b)))
+	}
+	if a.Flags&SVf_IOK != 0 && b.Flags&SVf_IOK != 0 {
+		return SvInt(a.IV + b.IV)
+	}
+	return SvFloat(a.AsFloat() + b.AsFloat())
+}
+func SvSub(a, b *SV) *SV {
+	if CompatNumbers {
+		return SvOfNumber(perlstr.NumSub(SvNumber(a), SvNumber(b)))
+	}
+	if a.Flags&SVf_IOK != 0 && b.Flags&SVf_IOK != 0 {
+		return SvInt(a.IV - b.IV)
+	}
+	return SvFloat(a.AsFloat() - b.AsFloat())
+}
+func SvMul(a, b *SV) *SV {
+	if CompatNumbers {
+		return SvOfNumber(perlstr.NumMul(SvNumber(a), SvNumber(b)))
+	}
+	if a.Flags&SVf_IOK != 0 && b.Flags&SVf_IOK != 0 {
+		return SvInt(a.IV * b.IV)
+	}
+	return SvFloat(a.AsFloat() * b.AsFloat())
+}
+func SvDiv(a, b *SV) *SV {
+	if CompatNumbers {
+		return NumDiv("", a, b)
+	}
+	return SvFloat(a.AsFloat() / b.AsFloat())
+}
+func SvMod(a, b *SV) *SV {
+	if CompatNumbers {
+		return NumMod("", a, b)
+	}
+	return SvInt(a.AsInt() % b.AsInt())
+}
+func SvPow(a, b *SV) *SV {
+	if CompatNumbers {
+		return SvOfNumber(perlstr.NumPow(SvNumber(a), SvNumber(b)))
+	}
+	if a.Flags&SVf_IOK != 0 && b.Flags&SVf_IOK != 0 {
+		if n, ok := perlstr.PowInt(a.IV, b.IV); ok {
+			return SvOfNumber(n)
+		}
+	}
+	return SvFloat(math.Pow(a.AsFloat(), b.AsFloat()))
+}
+func SvNeg(a *SV) *SV {
+	if CompatNumbers {
+		return compatNeg(a)
+	}
+	return SvFloat(-a.AsFloat())
+}
+
+// ++ and --: the magic string increment of IncrementString, else +-1;
+// integer strings stay integers
+func SvInc(a *SV) *SV {
+	if a != nil && a.Flags == SVf_POK {
+		if s, ok := perlstr.IncrementString(a.PV); ok {
+			return SvStr(s)
+		}
+		if n := perlstr.ParseNumber(a.PV); n.IsInt && n.IV < math.MaxInt64 {
+			return SvInt(n.IV + 1)
+		}
+	}
+	if a == nil || a.Flags == 0 {
+		return SvInt(1)
+	}
+	return SvAdd(a, SvInt(1))
+}
+func SvDec(a *SV) *SV {
+	if a != nil && a.Flags == SVf_POK {
+		if n := perlstr.ParseNumber(a.PV); n.IsInt && n.IV > math.MinInt64 {
+			return SvInt(n.IV - 1)
+		}
+	}
+	if a == nil || a.Flags == 0 {
+		return SvInt(-1)
+	}
+	return SvSub(a, SvInt(1))
+}
+
+// SvPostInc is the value of $x++: the old one, 0 for undef
+func SvPostInc(old *SV) *SV {
+	if old == nil || old.Flags == 0 {
+		return SvInt(0)
+	}
+	return old
+}
+func SvConcat(a, b *SV) *SV { return SvStr(a.AsString() + b.AsString()) }
+func SvRepeat(s, n *SV) *SV { return SvStr(strings.Repeat(s.AsString(), int(n.AsInt()))) }
+func SvNot(a *SV) *SV {
+	if a.IsTrue() {
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+func SvBitOr(a, b *SV) *SV  { return SvInt(a.AsInt() | b.AsInt()) }
+func SvBitAnd(a, b *SV) *SV { return SvInt(a.AsInt() & b.AsInt()) }
+func SvShl(a, b *SV) *SV    { return SvInt(a.AsInt() << uint(b.AsInt())) }
+func SvShr(a, b *SV) *SV    { return SvInt(a.AsInt() >> uint(b.AsInt())) }
+
+// SvBitNot is ~a: perl's is unsigned, ~0 is 18446744073709551615
+func SvBitNot(a *SV) *SV {
+	if CompatNumbers {
+		return SvUint(^uint64(a.AsInt()))
+	}
+	return SvInt(^a.AsInt())
+}
+
+// Comparisons
+func SvNumEq(a, b *SV) *SV {
+	if a.AsFloat() == b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumNe(a, b *SV) *SV {
+	if a.AsFloat() != b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumLt(a, b *SV) *SV {
+	if a.AsFloat() < b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumLe(a, b *SV) *SV {
+	if a.AsFloat() <= b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumGt(a, b *SV) *SV {
+	if a.AsFloat() > b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumGe(a, b *SV) *SV {
+	if a.AsFloat() >= b.AsFloat() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrEq(a, b *SV) *SV {
+	if a.AsString() == b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrNe(a, b *SV) *SV {
+	if a.AsString() != b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrLt(a, b *SV) *SV {
+	if a.AsString() < b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrLe(a, b *SV) *SV {
+	if a.AsString() <= b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrGt(a, b *SV) *SV {
+	if a.AsString() > b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrGe(a, b *SV) *SV {
+	if a.AsString() >= b.AsString() {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvNumCmp(a, b *SV) *SV {
+	x, y := a.AsFloat(), b.AsFloat()
+	if x < y {
+		return SvInt(-1)
+	}
+	if x > y {
+		return SvInt(1)
+	}
+	return SvInt(0)
+}
+func SvStrCmp(a, b *SV) *SV { return SvInt(int64(strings.Compare(a.AsString(), b.AsString()))) }
+
+// given/when: smart match of the topic against a when value
+func SmartMatch(topic, v *SV) bool {
+	if v == nil || v.Flags == 0 {
+		return topic == nil || topic.Flags == 0
+	}
+	if v.Flags&0x40 != 0 {
+		return Regex(v.PV).MatchString(topic.AsString())
+	}
+	if v.Flags&SVf_AOK != 0 && v.Flags&0x80 == 0 {
+		for _, el := range v.AV {
+			if SmartMatch(topic, el) {
+				return true
+			}
+		}
+		return false
+	}
+	if v.Flags&SVf_POK == 0 || (topic != nil && topic.Flags&SVf_POK == 0 && LooksNum(v.PV)) {
+		return topic.AsFloat() == v.AsFloat()
+	}
+	return topic.AsString() == v.AsString()
+}
+
+func LooksNum(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
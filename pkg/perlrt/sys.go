@@ -0,0 +1,331 @@
+package perlrt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getpwnam/getgrnam/hostname
+func IdValue(id string) *SV {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return SvInt(n)
+	}
+	return SvStr(id)
+}
+
+func PasswdShell(name string) string {
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 7 && fields[0] == name {
+			return fields[6]
+		}
+	}
+	return ""
+}
+
+func PwEntry(u *user.User, err error) *SV {
+	if err != nil {
+		return SvArray()
+	}
+	return SvArray(SvStr(u.Username), SvStr("x"), IdValue(u.Uid), IdValue(u.Gid), SvStr(""), SvStr(""),
+		SvStr(u.Name), SvStr(u.HomeDir), SvStr(PasswdShell(u.Username)))
+}
+
+func GrEntry(gr *user.Group, err error) *SV {
+	if err != nil {
+		return SvArray()
+	}
+	return SvArray(SvStr(gr.Name), SvStr("x"), IdValue(gr.Gid), SvStr(""))
+}
+
+func Perl_getpwnam(name *SV) *SV { return PwEntry(user.Lookup(name.AsString())) }
+func Perl_getpwuid(uid *SV) *SV  { return PwEntry(user.LookupId(uid.AsString())) }
+func Perl_getgrnam(name *SV) *SV { return GrEntry(user.LookupGroup(name.AsString())) }
+func Perl_getgrgid(gid *SV) *SV  { return GrEntry(user.LookupGroupId(gid.AsString())) }
+
+func IdField(entry *SV, idx int) *SV {
+	if idx >= len(entry.AV) {
+		return SvUndef()
+	}
+	return entry.AV[idx]
+}
+
+func Perl_hostname(args ...*SV) *SV {
+	name, err := os.Hostname()
+	if err != nil {
+		return SvUndef()
+	}
+	return SvStr(name)
+}
+func Perl_Sys_Hostname_hostname(args ...*SV) *SV { return Perl_hostname() }
+
+// $! and chmod/chown/utime/symlink/readlink
+var OsError string
+
+func SetOSError(err error) {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	case *exec.Error:
+		err = e.Err
+		if err == exec.ErrNotFound {
+			err = fmt.Errorf("no such file or directory")
+		}
+	}
+	msg := err.Error()
+	if msg != "" {
+		msg = strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	OsError = msg
+}
+
+func Flatten(args []*SV) []*SV {
+	var out []*SV
+	for _, a := range args {
+		if a.Flags&SVf_AOK != 0 {
+			out = append(out, a.AV...)
+		} else {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func ForEachFile(files []*SV, fn func(name string) error) *SV {
+	count := 0
+	for _, f := range files {
+		if err := fn(f.AsString()); err != nil {
+			SetOSError(err)
+			continue
+		}
+		count++
+	}
+	return SvInt(int64(count))
+}
+
+func Perl_chmod(args ...*SV) *SV {
+	args = Flatten(args)
+	if len(args) == 0 {
+		return SvInt(0)
+	}
+	mode := os.FileMode(args[0].AsInt())
+	return ForEachFile(args[1:], func(name string) error { return os.Chmod(name, mode) })
+}
+
+func OwnerID(v *SV) int {
+	if v.Flags == 0 {
+		return -1
+	}
+	return int(v.AsInt())
+}
+
+func Perl_chown(args ...*SV) *SV {
+	args = Flatten(args)
+	if len(args) < 2 {
+		return SvInt(0)
+	}
+	uid, gid := OwnerID(args[0]), OwnerID(args[1])
+	return ForEachFile(args[2:], func(name string) error { return os.Chown(name, uid, gid) })
+}
+
+func Perl_utime(args ...*SV) *SV {
+	args = Flatten(args)
+	if len(args) < 2 {
+		return SvInt(0)
+	}
+	atime, mtime := time.Now(), time.Now()
+	if args[0].Flags != 0 || args[1].Flags != 0 {
+		atime, mtime = time.Unix(args[0].AsInt(), 0), time.Unix(args[1].AsInt(), 0)
+	}
+	return ForEachFile(args[2:], func(name string) error { return os.Chtimes(name, atime, mtime) })
+}
+
+func Perl_symlink(oldname, newname *SV) *SV {
+	if err := os.Symlink(oldname.AsString(), newname.AsString()); err != nil {
+		SetOSError(err)
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+func Perl_readlink(name *SV) *SV {
+	target, err := os.Readlink(name.AsString())
+	if err != nil {
+		SetOSError(err)
+		return SvUndef()
+	}
+	return SvStr(target)
+}
+
+// unlink/rename/mkdir/rmdir/chdir/cwd
+func FileResult(err error) *SV {
+	if err != nil {
+		SetOSError(err)
+		return SvInt(0)
+	}
+	return SvInt(1)
+}
+
+func Perl_unlink(args ...*SV) *SV {
+	return ForEachFile(Flatten(args), func(name string) error {
+		if fi, err := os.Lstat(name); err == nil && fi.IsDir() {
+			return fmt.Errorf("is a directory")
+		}
+		return os.Remove(name)
+	})
+}
+
+func Perl_rename(oldname, newname *SV) *SV {
+	return FileResult(os.Rename(oldname.AsString(), newname.AsString()))
+}
+
+func Perl_mkdir(name *SV, args ...*SV) *SV {
+	mode := os.FileMode(0o777)
+	if len(args) > 0 {
+		mode = os.FileMode(args[0].AsInt())
+	}
+	return FileResult(os.Mkdir(name.AsString(), mode))
+}
+
+func Perl_rmdir(name *SV) *SV {
+	if fi, err := os.Lstat(name.AsString()); err == nil && !fi.IsDir() {
+		return FileResult(fmt.Errorf("not a directory"))
+	}
+	return FileResult(os.Remove(name.AsString()))
+}
+
+func Perl_chdir(args ...*SV) *SV {
+	dir := os.Getenv("HOME")
+	if len(args) > 0 {
+		dir = args[0].AsString()
+	}
+	return FileResult(os.Chdir(dir))
+}
+
+func Perl_cwd(args ...*SV) *SV {
+	dir, err := os.Getwd()
+	if err != nil {
+		SetOSError(err)
+		return SvUndef()
+	}
+	return SvStr(dir)
+}
+func Perl_getcwd(args ...*SV) *SV     { return Perl_cwd() }
+func Perl_Cwd_cwd(args ...*SV) *SV    { return Perl_cwd() }
+func Perl_Cwd_getcwd(args ...*SV) *SV { return Perl_cwd() }
+
+// stat/lstat: 13 fields; what os.FileInfo lacks comes from syscall.Stat_t
+func FileMode(m os.FileMode) int64 {
+	mode := int64(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	switch {
+	case m&os.ModeDir != 0:
+		mode |= 0o040000
+	case m&os.ModeSymlink != 0:
+		mode |= 0o120000
+	case m&os.ModeNamedPipe != 0:
+		mode |= 0o010000
+	case m&os.ModeSocket != 0:
+		mode |= 0o140000
+	case m&os.ModeCharDevice != 0:
+		mode |= 0o020000
+	case m&os.ModeDevice != 0:
+		mode |= 0o060000
+	default:
+		mode |= 0o100000
+	}
+	return mode
+}
+
+func StatList(fi os.FileInfo) *SV {
+	mtime := fi.ModTime().Unix()
+	st := [13]int64{2: FileMode(fi.Mode()), 3: 1, 7: fi.Size(), 8: mtime, 9: mtime, 10: mtime, 11: -1, 12: -1}
+	sysStat(fi, &st)
+	out := make([]*SV, len(st))
+	for n, v := range st {
+		if v < 0 {
+			out[n] = SvStr("")
+		} else {
+			out[n] = SvInt(v)
+		}
+	}
+	return SvArray(out...)
+}
+
+func Stat(name *SV, link bool) *SV {
+	var fi os.FileInfo
+	var err error
+	if h, ok := Filehandles[name.AsString()]; ok && h.File != nil {
+		fi, err = h.File.Stat()
+	} else if link {
+		fi, err = os.Lstat(name.AsString())
+	} else {
+		fi, err = os.Stat(name.AsString())
+	}
+	if err != nil {
+		SetOSError(err)
+		return SvArray()
+	}
+	return StatList(fi)
+}
+func Perl_stat(name *SV) *SV  { return Stat(name, false) }
+func Perl_lstat(name *SV) *SV { return Stat(name, true) }
+
+// FcntlConstants are the Fcntl barewords (LOCK_EX, O_CREAT, ...). The
+// interpreter and compiled programs share this table; O_* come from os of the
+// platform the program runs on, so they feed os.OpenFile as is.
+var FcntlConstants = map[string]int64{
+	"LOCK_SH": 1,
+	"LOCK_EX": 2,
+	"LOCK_NB": 4,
+	"LOCK_UN": 8,
+
+	"O_RDONLY": int64(os.O_RDONLY),
+	"O_WRONLY": int64(os.O_WRONLY),
+	"O_RDWR":   int64(os.O_RDWR),
+	"O_CREAT":  int64(os.O_CREATE),
+	"O_EXCL":   int64(os.O_EXCL),
+	"O_TRUNC":  int64(os.O_TRUNC),
+	"O_APPEND": int64(os.O_APPEND),
+
+	"SEEK_SET": 0,
+	"SEEK_CUR": 1,
+	"SEEK_END": 2,
+}
+
+// flock; flockFile is chosen by build tags of the platform the program runs on
+func Perl_flock(fh, op *SV) *SV {
+	if h, ok := Filehandles[fh.AsString()]; ok && h.File != nil {
+		if h.Writer != nil {
+			h.Writer.Flush()
+		}
+		if flockFile(h.File, int(op.AsInt())) == nil {
+			return SvInt(1)
+		}
+	}
+	return SvInt(0)
+}
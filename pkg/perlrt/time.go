@@ -0,0 +1,247 @@
+package perlrt
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// localtime/gmtime: list of fields, ctime string or Time::Piece object
+const CtimeLayout = "Mon Jan _2 15:04:05 2006"
+
+func TimeArg(args []*SV, utc bool) time.Time {
+	t := time.Now()
+	if len(args) > 0 && args[0].Flags != 0 {
+		t = time.Unix(args[0].AsInt(), 0)
+	}
+	if utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+func TimeList(t time.Time) *SV {
+	isdst := int64(0)
+	if t.IsDST() {
+		isdst = 1
+	}
+	return SvArray(SvInt(int64(t.Second())), SvInt(int64(t.Minute())), SvInt(int64(t.Hour())),
+		SvInt(int64(t.Day())), SvInt(int64(t.Month())-1), SvInt(int64(t.Year()-1900)),
+		SvInt(int64(t.Weekday())), SvInt(int64(t.YearDay()-1)), SvInt(isdst))
+}
+
+func Perl_localtime(args ...*SV) *SV { return TimeList(TimeArg(args, false)) }
+func Perl_gmtime(args ...*SV) *SV    { return TimeList(TimeArg(args, true)) }
+
+func TimeScalar(utc, piece bool, args ...*SV) *SV {
+	t := TimeArg(args, utc)
+	if piece {
+		return TimePiece(t, utc)
+	}
+	return SvStr(t.Format(CtimeLayout))
+}
+
+func TimePiece(t time.Time, utc bool) *SV {
+	obj := SvHash()
+	obj.HV["epoch"] = SvInt(t.Unix())
+	obj.HV["utc"] = SvInt(0)
+	if utc {
+		obj.HV["utc"] = SvInt(1)
+	}
+	return Perl_bless(obj, SvStr("Time::Piece"))
+}
+
+func JoinDate(args []*SV, sep string, fields ...string) *SV {
+	if len(args) > 1 {
+		sep = args[1].AsString()
+	}
+	return SvStr(strings.Join(fields, sep))
+}
+
+func TimePieceMethod(method string, args []*SV) *SV {
+	if len(args) == 0 || args[0].HV == nil {
+		return SvUndef()
+	}
+	t := time.Unix(args[0].HV["epoch"].AsInt(), 0).Local()
+	if args[0].HV["utc"].IsTrue() {
+		t = t.UTC()
+	}
+	switch method {
+	case "sec", "second":
+		return SvInt(int64(t.Second()))
+	case "min", "minute":
+		return SvInt(int64(t.Minute()))
+	case "hour":
+		return SvInt(int64(t.Hour()))
+	case "mday", "day_of_month":
+		return SvInt(int64(t.Day()))
+	case "mon":
+		return SvInt(int64(t.Month()))
+	case "_mon":
+		return SvInt(int64(t.Month()) - 1)
+	case "monname", "month":
+		return SvStr(t.Format("Jan"))
+	case "fullmonth":
+		return SvStr(t.Format("January"))
+	case "year":
+		return SvInt(int64(t.Year()))
+	case "_year":
+		return SvInt(int64(t.Year() - 1900))
+	case "yy":
+		return SvInt(int64(t.Year() % 100))
+	case "wday":
+		return SvInt(int64(t.Weekday()) + 1)
+	case "_wday", "day_of_week":
+		return SvInt(int64(t.Weekday()))
+	case "wdayname", "day":
+		return SvStr(t.Format("Mon"))
+	case "fullday":
+		return SvStr(t.Format("Monday"))
+	case "yday", "day_of_year":
+		return SvInt(int64(t.YearDay() - 1))
+	case "isdst", "daylight_savings":
+		if t.IsDST() {
+			return SvInt(1)
+		}
+		return SvInt(0)
+	case "epoch":
+		return SvInt(t.Unix())
+	case "ymd", "date":
+		return JoinDate(args, "-", fmt.Sprintf("%04d", t.Year()), fmt.Sprintf("%02d", int(t.Month())), fmt.Sprintf("%02d", t.Day()))
+	case "mdy":
+		return JoinDate(args, "-", fmt.Sprintf("%02d", int(t.Month())), fmt.Sprintf("%02d", t.Day()), fmt.Sprintf("%04d", t.Year()))
+	case "dmy":
+		return JoinDate(args, "-", fmt.Sprintf("%02d", t.Day()), fmt.Sprintf("%02d", int(t.Month())), fmt.Sprintf("%04d", t.Year()))
+	case "hms", "time":
+		return JoinDate(args, ":", fmt.Sprintf("%02d", t.Hour()), fmt.Sprintf("%02d", t.Minute()), fmt.Sprintf("%02d", t.Second()))
+	case "datetime":
+		return SvStr(t.Format("2006-01-02T15:04:05"))
+	case "cdate":
+		return SvStr(t.Format(CtimeLayout))
+	case "strftime":
+		format := "%a, %d %b %Y %H:%M:%S %Z"
+		if len(args) > 1 {
+			format = args[1].AsString()
+		}
+		return SvStr(Strftime(format, t))
+	}
+	return SvUndef()
+}
+
+func init() {
+	for _, m := range []string{"sec", "second", "min", "minute", "hour", "mday", "day_of_month",
+		"mon", "_mon", "monname", "month", "fullmonth", "year", "_year", "yy", "wday", "_wday",
+		"day_of_week", "wdayname", "day", "fullday", "yday", "day_of_year", "isdst", "daylight_savings",
+		"epoch", "ymd", "date", "mdy", "dmy", "hms", "time", "datetime", "cdate", "strftime"} {
+		method := m
+		Methods["Time::Piece_"+method] = func(args ...*SV) *SV { return TimePieceMethod(method, args) }
+	}
+}
+
+func Perl_strftime(args ...*SV) *SV {
+	var flat []*SV
+	for _, a := range args {
+		if a.Flags&SVf_AOK != 0 {
+			flat = append(flat, a.AV...)
+		} else {
+			flat = append(flat, a)
+		}
+	}
+	if len(flat) == 0 {
+		return SvUndef()
+	}
+	field := func(n int) int {
+		if n < len(flat) {
+			return int(flat[n].AsInt())
+		}
+		return 0
+	}
+	t := time.Date(field(6)+1900, time.Month(field(5)+1), field(4), field(3), field(2), field(1), 0, time.Local)
+	return SvStr(Strftime(flat[0].AsString(), t))
+}
+func Perl_POSIX_strftime(args ...*SV) *SV { return Perl_strftime(args...) }
+
+// sleep waits for d, or forever when d < 0, unless a signal comes first
+func SleepFor(d time.Duration) time.Duration {
+	start := time.Now()
+	var timeout <-chan time.Time
+	if d >= 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	if !SigPending.Load() {
+		select {
+		case <-timeout:
+		case <-SigNotify:
+		}
+	}
+	CheckSignals()
+	return time.Since(start)
+}
+
+func Perl_sleep(args ...*SV) *SV {
+	d := time.Duration(-1)
+	if len(args) > 0 {
+		d = max(0, time.Duration(args[0].AsFloat()*float64(time.Second)))
+	}
+	return SvInt(int64(SleepFor(d).Round(time.Second) / time.Second))
+}
+
+// Time::HiRes: fractional time and sleeps, gettimeofday and tv_interval
+func HiResNow() float64 { return float64(time.Now().UnixMicro()) / 1e6 }
+
+func Perl_Time_HiRes_time(args ...*SV) *SV { return SvFloat(HiResNow()) }
+
+func HiResSleep(unit time.Duration, args []*SV) *SV {
+	d := time.Duration(-1)
+	if len(args) > 0 {
+		d = max(0, time.Duration(args[0].AsFloat()*float64(unit)))
+	}
+	return SvFloat(float64(SleepFor(d)) / float64(unit))
+}
+func Perl_Time_HiRes_sleep(args ...*SV) *SV     { return HiResSleep(time.Second, args) }
+func Perl_Time_HiRes_usleep(args ...*SV) *SV    { return HiResSleep(time.Microsecond, args) }
+func Perl_Time_HiRes_nanosleep(args ...*SV) *SV { return HiResSleep(time.Nanosecond, args) }
+
+func Perl_Time_HiRes_gettimeofday(args ...*SV) *SV {
+	now := time.Now().UnixMicro()
+	return SvArray(SvInt(now/1e6), SvInt(now%1e6))
+}
+
+func Perl_Time_HiRes_tv_interval(args ...*SV) *SV {
+	micros := func(i int) int64 {
+		if i >= len(args) || args[i].Flags&SVf_AOK == 0 {
+			return time.Now().UnixMicro()
+		}
+		var parts [2]int64
+		for j, v := range args[i].AV {
+			if j < len(parts) {
+				parts[j] = v.AsInt()
+			}
+		}
+		return parts[0]*1e6 + parts[1]
+	}
+	return SvFloat(float64(micros(1)-micros(0)) / 1e6)
+}
+
+// alarm: ALRM goes through the same queue as CHLD
+var Alarm *time.Timer
+var AlarmAt time.Time
+
+func Perl_alarm(args ...*SV) *SV {
+	SigMu.Lock()
+	defer SigMu.Unlock()
+	remaining := 0
+	if Alarm != nil && Alarm.Stop() {
+		remaining = int(math.Ceil(time.Until(AlarmAt).Seconds()))
+	}
+	Alarm = nil
+	if len(args) > 0 && args[0].AsFloat() > 0 {
+		d := time.Duration(args[0].AsFloat() * float64(time.Second))
+		AlarmAt = time.Now().Add(d)
+		Alarm = time.AfterFunc(d, func() { RaiseSignal("ALRM") })
+	}
+	return SvInt(int64(remaining))
+}
@@ -0,0 +1,72 @@
+package perlrt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+type Tunables struct{ MaxDepth, HashSeed, RegexCache, IoBuffer, Warnings int64 }
+
+var defaultTunables = Tunables{0, 0, 1000, 4096, 1}
+
+var Tune = LoadTunables(defaultTunables)
+
+func LoadTunables(t Tunables) Tunables {
+	for _, k := range []struct {
+		Env string
+		V   *int64
+	}{{"PERLC_MAX_DEPTH", &t.MaxDepth}, {"PERLC_HASH_SEED", &t.HashSeed}, {"PERLC_REGEX_CACHE", &t.RegexCache}, {"PERLC_IO_BUFFER", &t.IoBuffer}, {"PERLC_WARNINGS", &t.Warnings}} {
+		if n, err := strconv.ParseInt(os.Getenv(k.Env), 10, 64); err == nil {
+			*k.V = n
+		}
+	}
+	return t
+}
+
+var Depth atomic.Int64
+
+// EnterSub counts nested sub calls: beyond maxDepth it dies, at depth 100
+// verbose warnings report deep recursion at loc, where the sub is
+func EnterSub(name, loc string) {
+	d := Depth.Add(1)
+	if Tune.MaxDepth > 0 && d > Tune.MaxDepth {
+		Depth.Add(-1)
+		Perl_die(SvStr(fmt.Sprintf("Deep recursion limit of %d exceeded in subroutine \"%s\"", Tune.MaxDepth, name)))
+	}
+	if d == 100 {
+		Warn("recursion", loc, fmt.Sprintf("Deep recursion on subroutine \"%s\"", name))
+	}
+}
+
+func LeaveSub() { Depth.Add(-1) }
+
+// HashKeys lists the keys of a hash: in map order, or with a hash seed
+// in an order fixed by the seed (FNV-1a of "seed:key", as in perlc)
+func HashKeys(h map[string]*SV) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	if Tune.HashSeed == 0 {
+		return keys
+	}
+	prefix := strconv.FormatInt(Tune.HashSeed, 10) + ":"
+	sums := make(map[string]uint64, len(keys))
+	for _, k := range keys {
+		s, sum := prefix+k, uint64(14695981039346656037)
+		for i := 0; i < len(s); i++ {
+			sum = (sum ^ uint64(s[i])) * 1099511628211
+		}
+		sums[k] = sum
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if sums[keys[a]] != sums[keys[b]] {
+			return sums[keys[a]] < sums[keys[b]]
+		}
+		return keys[a] < keys[b]
+	})
+	return keys
+}
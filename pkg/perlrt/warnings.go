@@ -0,0 +1,119 @@
+package perlrt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"perlc/pkg/perlstr"
+)
+
+var WarnOff = map[string]bool{}
+var WarnSeen = map[string]bool{}
+var WarnVerbose = map[string]bool{"recursion": true}
+var WarnOptional = map[string]bool{"numeric": true, "uninitialized": true}
+var WarnMu sync.Mutex
+
+// Warn prints msg + " at loc." unless its category is off or the same
+// message came from the same place before
+func Warn(cat, loc, msg string) { WarnKey(cat, cat+"\x00"+loc+"\x00"+msg, loc, msg) }
+
+// WarnOnce prints msg the first time only, from whatever place
+func WarnOnce(cat, loc, msg string) { WarnKey(cat, cat+"\x00once\x00"+msg, loc, msg) }
+
+func WarnKey(cat, key, loc, msg string) {
+	WarnMu.Lock()
+	defer WarnMu.Unlock()
+	off, set := WarnOff[cat]
+	if Tune.Warnings <= 0 || (WarnVerbose[cat] && Tune.Warnings < 2) || off || (!set && WarnOptional[cat]) || WarnSeen[key] {
+		return
+	}
+	WarnSeen[key] = true
+	if strings.HasSuffix(msg, "\n") {
+		fmt.Fprint(Stderr, msg)
+		return
+	}
+	if loc != "" {
+		msg += " at " + loc
+	}
+	fmt.Fprint(Stderr, msg+".\n")
+}
+
+// Warnings is use warnings (off false) or no warnings for the categories
+func Warnings(off bool, cats ...string) {
+	WarnMu.Lock()
+	defer WarnMu.Unlock()
+	for _, c := range cats {
+		WarnOff[c] = off
+	}
+}
+
+// OpWarn describes the operand checks of an operator: its name, the
+// "Use of uninitialized value" warnings of its operands ("" for none) and
+// whether it is numeric
+type OpWarn struct {
+	Op, Loc, Left, Right string
+	Numeric              bool
+}
+
+// OpArgs passes the operands of an operator through, warning about the
+// undefined ones and, for a numeric operator, about the strings that are
+// not numbers; the right one first for numeric operators like perl
+func OpArgs(a, b *SV, w OpWarn) (*SV, *SV) {
+	if !w.Numeric {
+		OpArg(a, w.Left, w)
+	}
+	OpArg(b, w.Right, w)
+	if w.Numeric {
+		OpArg(a, w.Left, w)
+	}
+	return a, b
+}
+
+// Defined passes v through, warning msg when it is undefined
+func Defined(v *SV, loc, msg string) *SV {
+	if v == nil || v.Flags == 0 {
+		Warn("uninitialized", loc, msg)
+	}
+	return v
+}
+
+func OpArg(a *SV, uninit string, w OpWarn) {
+	switch {
+	case a == nil || a.Flags == 0:
+		if uninit != "" {
+			Warn("uninitialized", w.Loc, uninit)
+		}
+	case w.Numeric && a.Flags == SVf_POK && !perlstr.LooksLikeNumber(a.PV):
+		Warn("numeric", w.Loc, perlstr.NotNumeric(a.PV, w.Op))
+	}
+}
+
+// NumArg passes v, the argument of the numeric function op (int, abs,
+// ...), through, warning when it is a string that is not a number
+func NumArg(v *SV, op, loc string) *SV {
+	OpArg(v, "", OpWarn{Op: op, Loc: loc, Numeric: true})
+	return v
+}
+
+// HashPairs passes a list assigned to a hash through, warning when it
+// has an odd number of elements
+func HashPairs(l []*SV, loc string) []*SV {
+	if len(l)%2 == 0 {
+		return l
+	}
+	if len(l) == 1 && Perl_ref(l[0]).PV != "" {
+		Warn("misc", loc, "Reference found where even-sized list expected")
+	} else {
+		Warn("misc", loc, "Odd number of elements in hash assignment")
+	}
+	return l
+}
+
+// HashList is HashPairs for the list or hash of my %h = ...
+func HashList(v *SV, loc string) *SV {
+	if v.Flags&SVf_HOK == 0 {
+		HashPairs(ListOf(v), loc)
+	}
+	return v
+}
@@ -0,0 +1,321 @@
+package perlstr
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Arithmetic
+//
+// perl computes with integers while the operands and the result are
+// integers and with doubles otherwise, so a result has a type as well as
+// a value, and the type decides how it prints. NumAdd, NumSub, NumMul,
+// NumDiv, NumMod and NumPow follow pp_add, pp_subtract, pp_multiply,
+// pp_divide, pp_modulo and pp_pow:
+//
+//   - an operand is an integer when it is one (IsInt or IsUV), or when it
+//     is a whole double below 2**53, which perl holds as an integer too
+//   - an integer result is signed (IV) when it fits int64 and unsigned
+//     (UV) when it is positive and fits uint64: 9223372036854775807 + 1 is
+//     9223372036854775808
+//   - + - * of integers that overflow both are done again with doubles
+//   - / is a double, except that an exact division of integers past 2**53
+//     stays an integer: 9007199254740993 / 1 is 9007199254740993
+//   - % is floored, the result has the sign of the right operand (-7 % 3
+//     is 2, 7 % -3 is -2); both operands are truncated to integers first,
+//     unless one is past 2**64: then they are taken modulo as doubles,
+//     rounded to whole numbers if it is the left one
+//   - ** of integers is exact while the result surely fits 64 bits, except
+//     for a power of 2, which stays a double (2**53 is
+//     9.00719925474099e+15); any other ** is math.Pow
+//
+// perl's caching of the integer value of a double is not modelled: a
+// whole double past 2**53 is a double here, where perl may take it as an
+// integer once it has been used as one.
+//
+// perlc uses these under --perl-compat-numbers.
+
+// IntNumber is the integer iv as a Number.
+func IntNumber(iv int64) Number {
+	return Number{IV: iv, NV: float64(iv), IsInt: true}
+}
+
+// UintNumber is the integer u as a Number: an IV when it fits one, else
+// an unsigned integer (IsUV).
+func UintNumber(u uint64) Number {
+	if u <= math.MaxInt64 {
+		return IntNumber(int64(u))
+	}
+	return Number{IV: math.MaxInt64, NV: float64(u), UV: u, IsUV: true}
+}
+
+// FloatNumber is the double nv as a Number.
+func FloatNumber(nv float64) Number {
+	return Number{IV: numToInt(nv), NV: nv}
+}
+
+// preciseInt is 2**53: the whole doubles below it are exact integers
+const preciseInt = 1 << 53
+
+// integer is n as an integer operand, if perl takes it as one
+func (n Number) integer() (int64, bool) {
+	if n.IsInt {
+		return n.IV, true
+	}
+	if n.NV == math.Trunc(n.NV) && math.Abs(n.NV) < preciseInt {
+		return int64(n.NV), true
+	}
+	return 0, false
+}
+
+// magnitude is n as an integer operand of either sign: its absolute
+// value and whether it is negative, if perl takes it as an integer
+func (n Number) magnitude() (uint64, bool, bool) {
+	if n.IsUV {
+		return n.UV, false, true
+	}
+	if x, ok := n.integer(); ok {
+		return absInt(x), x < 0, true
+	}
+	return 0, false, false
+}
+
+// float is n as a double operand
+func (n Number) float() float64 {
+	if n.IsInt {
+		return float64(n.IV)
+	}
+	return n.NV
+}
+
+// NumAdd is a + b.
+func NumAdd(a, b Number) Number {
+	if n, ok := addInt(a, b, false); ok {
+		return n
+	}
+	return FloatNumber(a.float() + b.float())
+}
+
+// NumSub is a - b.
+func NumSub(a, b Number) Number {
+	if n, ok := addInt(a, b, true); ok {
+		return n
+	}
+	return FloatNumber(a.float() - b.float())
+}
+
+// addInt is a + b, or a - b if sub, of integers; false when an operand
+// is no integer or the result fits neither an IV nor a UV
+func addInt(a, b Number, sub bool) (Number, bool) {
+	x, xneg, ok := a.magnitude()
+	if !ok {
+		return Number{}, false
+	}
+	y, yneg, ok := b.magnitude()
+	if !ok {
+		return Number{}, false
+	}
+	if sub && y != 0 {
+		yneg = !yneg
+	}
+	switch {
+	case xneg == yneg:
+		if sum := x + y; sum >= x {
+			return signedInt(sum, xneg)
+		}
+		return Number{}, false
+	case x >= y:
+		return signedInt(x-y, xneg)
+	}
+	return signedInt(y-x, yneg)
+}
+
+// NumMul is a * b.
+func NumMul(a, b Number) Number {
+	if x, xneg, ok := a.magnitude(); ok {
+		if y, yneg, ok := b.magnitude(); ok {
+			if hi, p := bits.Mul64(x, y); hi == 0 {
+				if n, ok := signedInt(p, xneg != yneg && p != 0); ok {
+					return n
+				}
+			}
+		}
+	}
+	return FloatNumber(a.float() * b.float())
+}
+
+// NumDiv is a / b; false for a division by zero.
+func NumDiv(a, b Number) (Number, bool) {
+	if left, xneg, ok := a.magnitude(); ok {
+		if right, yneg, ok := b.magnitude(); ok {
+			if right == 0 {
+				return Number{}, false
+			}
+			if left >= right && left > preciseInt && left%right == 0 {
+				return signedNumber(left/right, xneg != yneg), true
+			}
+		}
+	}
+	if b.float() == 0 {
+		return Number{}, false
+	}
+	return FloatNumber(a.float() / b.float()), true
+}
+
+// NumMod is a % b; false for a modulus zero.
+func NumMod(a, b Number) (Number, bool) {
+	const uintLimit = 1 << 64
+	var left, right uint64
+	var dleft, dright float64
+	useDouble, rightDouble := false, false
+	leftNeg, rightNeg := a.float() < 0, b.float() < 0
+	if y, _, ok := b.magnitude(); ok {
+		right = y
+	} else if dright = math.Abs(b.NV); dright < uintLimit {
+		right, rightDouble = uint64(dright), true
+	} else {
+		useDouble = true
+	}
+	x, _, ok := a.magnitude()
+	switch {
+	case useDouble:
+		dleft = math.Abs(a.float())
+	case ok:
+		left = x
+	default:
+		if dleft = math.Abs(a.NV); dleft < uintLimit {
+			left = uint64(dleft)
+			break
+		}
+		useDouble = true
+		dleft = math.Floor(dleft + 0.5)
+		if rightDouble {
+			dright = math.Floor(dright + 0.5)
+		} else {
+			dright = float64(right)
+		}
+	}
+	if useDouble {
+		if dright == 0 {
+			return Number{}, false
+		}
+		ans := math.Mod(dleft, dright)
+		if leftNeg != rightNeg && ans != 0 {
+			ans = dright - ans
+		}
+		if rightNeg {
+			ans = -ans
+		}
+		return FloatNumber(ans), true
+	}
+	if right == 0 {
+		return Number{}, false
+	}
+	ans := left % right
+	if leftNeg != rightNeg && ans != 0 {
+		ans = right - ans
+	}
+	return signedNumber(ans, rightNeg), true
+}
+
+// NumPow is a ** b.
+func NumPow(a, b Number) Number {
+	x, xok := a.integer()
+	y, yok := b.integer()
+	if !yok {
+		return FloatNumber(math.Pow(a.float(), b.float()))
+	}
+	if xok {
+		if n, ok := PowInt(x, y); ok {
+			return n
+		}
+	}
+	return FloatNumber(intPow(a.float(), y))
+}
+
+// PowInt is x ** y when perl computes it with integers: y is not
+// negative, x is not a power of two (those powers are doubles) and the
+// result fits in 64 bits. ok is false when the power is a double.
+func PowInt(x, y int64) (Number, bool) {
+	if y < 0 {
+		return Number{}, false
+	}
+	base := absInt(x)
+	bits := int64(0)
+	for v := base; v != 0; v >>= 1 {
+		bits++
+	}
+	if base&(base-1) == 0 || y > 64 || y*bits > 64 {
+		return Number{}, false
+	}
+	result := uint64(1)
+	for n := y; n > 0; n-- {
+		result *= base
+	}
+	return signedNumber(result, x < 0 && y%2 == 1), true
+}
+
+// intPow is base ** n rounded as C's pow rounds it, which math.Pow does
+// not always do (7**33, 0.1**-5): the power is computed by squaring in
+// double-double arithmetic, with about 106 bits, and rounded once at the
+// end
+func intPow(base float64, n int64) float64 {
+	hi, lo := 1.0, 0.0
+	bhi, blo := base, 0.0
+	for m := absInt(n); m > 0; m >>= 1 {
+		if m&1 == 1 {
+			hi, lo = mulDouble(hi, lo, bhi, blo)
+		}
+		if m > 1 {
+			bhi, blo = mulDouble(bhi, blo, bhi, blo)
+		}
+	}
+	if n < 0 {
+		// 1/(hi+lo): q and the remainder of hi*q against 1
+		q := 1 / hi
+		if math.IsInf(q, 0) || q == 0 {
+			return q
+		}
+		return q + (math.FMA(-q, hi, 1)-q*lo)*q
+	}
+	return hi + lo
+}
+
+// mulDouble is (ahi+alo) * (bhi+blo) as a double-double
+func mulDouble(ahi, alo, bhi, blo float64) (float64, float64) {
+	p := ahi * bhi
+	if math.IsInf(p, 0) {
+		return p, 0
+	}
+	e := math.FMA(ahi, bhi, -p) + ahi*blo + alo*bhi
+	hi := p + e
+	return hi, e - (hi - p)
+}
+
+// absInt is |n|, which fits a uint64 even for math.MinInt64
+func absInt(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
+
+// signedNumber is u, negated if neg: an integer if it fits, else a double
+func signedNumber(u uint64, neg bool) Number {
+	if n, ok := signedInt(u, neg); ok {
+		return n
+	}
+	return FloatNumber(-float64(u))
+}
+
+// signedInt is u, negated if neg, as an IV or a UV; false for a negative
+// number past int64
+func signedInt(u uint64, neg bool) (Number, bool) {
+	switch {
+	case !neg:
+		return UintNumber(u), true
+	case u <= 1<<63:
+		return IntNumber(-int64(u)), true
+	}
+	return Number{}, false
+}
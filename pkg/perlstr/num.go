@@ -0,0 +1,226 @@
+package perlstr
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Numeric strings
+//
+// A string converts to a number by its longest numeric prefix; the rest
+// is ignored ("42abc" is 42, "abc" is 0). The grammar, after perl's
+// grok_number:
+//
+//	number   = space* sign? (decimal | infnan) space*
+//	sign     = "+" | "-"
+//	decimal  = (digits ("." digit*)? | "." digits) exponent?
+//	exponent = ("e" | "E") sign? digits
+//	infnan   = "Inf" | "Infinity" | "NaN"      (any case, a whole word)
+//	space    = " " | "\t" | "\n" | "\r" | "\f" | "\v"
+//
+// A string looks like a number (Scalar::Util::looks_like_number) when all
+// of it matches, trailing white space included. Hex ("0x1A"), binary,
+// underscores ("1_000"), "" and lone signs or dots do not.
+//
+// The integer value of a string is its integer digits ("3.9" and "3e2"
+// are 3), the float value the whole prefix. Integer digits past int64
+// that fit uint64 are one of perl's unsigned integers (IsUV).
+//
+// ++ on a string is not numeric when the string is non-empty and matches
+// /^[a-zA-Z]*[0-9]*\z/: each character then counts up within its own class
+// with a carry to the left, "aa" to "ab", "Az" to "Ba", "a9" to "b0", "zz"
+// to "aaa", "Zz" to "AAa" and "99" to "100".
+//
+// A string that does not look like a number is still used by its prefix,
+// with the warning of NotNumeric in category numeric.
+//
+// The generated programs embed this package, so both backends agree on
+// every string.
+
+// Number is a string read as a number.
+type Number struct {
+	IV    int64   // integer value
+	NV    float64 // float value
+	UV    uint64  // unsigned integer value, when IsUV
+	IsInt bool    // integer digits only, within int64
+	IsUV  bool    // integer digits only, past int64 but within uint64
+	Len   int     // bytes of the numeric prefix, leading space included; 0 if none
+	Whole bool    // the whole string is a number
+}
+
+// ParseNumber reads the numeric prefix of s.
+func ParseNumber(s string) Number {
+	i := 0
+	for i < len(s) && isNumSpace(s[i]) {
+		i++
+	}
+	start := i
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := i
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	intEnd := i
+	var n Number
+	if i < len(s) && s[i] == '.' && (i > digits || i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9') {
+		for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		}
+	}
+	if i == digits {
+		// no digits: Inf, NaN or nothing
+		word := i
+		for word < len(s) && (s[word]|0x20 >= 'a' && s[word]|0x20 <= 'z') {
+			word++
+		}
+		switch strings.ToLower(s[i:word]) {
+		case "inf", "infinity":
+			n.NV = math.Inf(1)
+			if s[start] == '-' {
+				n.NV = math.Inf(-1)
+			}
+		case "nan":
+			n.NV = math.NaN()
+		default:
+			return n
+		}
+		n.IV = numToInt(n.NV)
+		n.Len = word
+		n.Whole = numRestIsSpace(s[word:])
+		return n
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for i = j; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			}
+		}
+	}
+	n.Len = i
+	n.Whole = numRestIsSpace(s[i:])
+	n.NV, _ = strconv.ParseFloat(s[start:i], 64)
+	if iv, err := strconv.ParseInt(s[start:intEnd], 10, 64); err == nil {
+		n.IV = iv
+		n.IsInt = intEnd == i
+	} else if intEnd > digits {
+		n.IV = numToInt(n.NV)
+		if uv, err := strconv.ParseUint(strings.TrimPrefix(s[start:intEnd], "+"), 10, 64); err == nil {
+			n.UV = uv
+			n.IsUV = intEnd == i
+		}
+	}
+	return n
+}
+
+// LooksLikeNumber reports whether all of s is a number.
+func LooksLikeNumber(s string) bool {
+	return ParseNumber(s).Whole
+}
+
+// NotNumeric is the warning for s used as an operand of the numeric
+// operator op ("addition (+)"). Like perl it shows s escaped and cut to
+// about 56 bytes.
+func NotNumeric(s, op string) string {
+	var b strings.Builder
+	i := 0
+	for ; i < len(s) && b.Len() < 56; i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\f':
+			b.WriteString(`\f`)
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == 0:
+			b.WriteString(`\0`)
+		case c < ' ' || c == 0x7f:
+			b.WriteByte('^')
+			b.WriteByte(c ^ 64)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	// finish a cut UTF-8 character
+	for ; i < len(s) && s[i]&0xC0 == 0x80; i++ {
+		b.WriteByte(s[i])
+	}
+	if i < len(s) {
+		b.WriteString("...")
+	}
+	return "Argument \"" + b.String() + "\" isn't numeric in " + op
+}
+
+// numToInt truncates f to int64, saturating; NaN is 0
+func numToInt(f float64) int64 {
+	switch {
+	case f != f:
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+func isNumSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func numRestIsSpace(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isNumSpace(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IncrementString is the magic string ++: the string after s and true,
+// or false when ++ on s is numeric.
+func IncrementString(s string) (string, bool) {
+	i := 0
+	for i < len(s) && (s[i]|0x20 >= 'a' && s[i]|0x20 <= 'z') {
+		i++
+	}
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if s == "" || i < len(s) {
+		return s, false
+	}
+	return incrementString(s), true
+}
+
+// incrementString counts s up by one, each letter or digit within its own
+// class; a carry out of the first character adds a new one ("zz" -> "aaa")
+func incrementString(s string) string {
+	b := []byte(s)
+	for i := len(b) - 1; i >= 0; i-- {
+		switch c := b[i]; c {
+		case 'z':
+			b[i] = 'a'
+		case 'Z':
+			b[i] = 'A'
+		case '9':
+			b[i] = '0'
+		default:
+			b[i] = c + 1
+			return string(b)
+		}
+	}
+	switch b[0] {
+	case 'a':
+		return "a" + string(b)
+	case 'A':
+		return "A" + string(b)
+	}
+	return "1" + string(b)
+}
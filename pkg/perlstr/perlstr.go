@@ -0,0 +1,114 @@
+// Package perlstr has Perl's rules for strings and numbers, without Perl
+// values: numeric strings, sprintf, split and tr///. A Go program ported
+// from Perl gets the results the script got:
+//
+//	perlstr.Sprintf("%-5s|%05.1f|%x", "ab", 3.14159, -1) // "ab   |003.1|ffffffffffffffff"
+//	perlstr.Split(re, "a,b,,c,,", 0)                    // a b "" c
+//	perlstr.Fields("  a b  c ", 0)                      // split ' ': a b c
+//	perlstr.Tr("hello", "a-y", "b-z", "")               // "ifmmp", 5
+//
+// perlc itself uses it in both backends: the interpreter imports it and the
+// generated programs embed its source (see Source), so the two agree on
+// every string.
+package perlstr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Scalar is a value Perl can read as a string, an integer or a float, each
+// by its own rules; perlc's values are Scalars.
+type Scalar interface {
+	AsString() string
+	AsInt() int64
+	AsFloat() float64
+}
+
+// ScalarOf reads a Go value the way Perl would read the same data: a
+// string is a string (numeric by ParseNumber), integers and floats are
+// numbers, true is 1, false "" and nil undef. Anything else is its
+// fmt.Sprint string.
+func ScalarOf(x any) Scalar {
+	switch v := x.(type) {
+	case Scalar:
+		return v
+	case nil:
+		return strScalar("")
+	case string:
+		return strScalar(v)
+	case []byte:
+		return strScalar(v)
+	case bool:
+		if v {
+			return intScalar(1)
+		}
+		return strScalar("")
+	case int:
+		return intScalar(v)
+	case int8:
+		return intScalar(v)
+	case int16:
+		return intScalar(v)
+	case int32:
+		return intScalar(v)
+	case int64:
+		return intScalar(v)
+	case uint:
+		return uintScalar(uint64(v))
+	case uint8:
+		return intScalar(v)
+	case uint16:
+		return intScalar(v)
+	case uint32:
+		return intScalar(v)
+	case uint64:
+		return uintScalar(v)
+	case float32:
+		return floatScalar(v)
+	case float64:
+		return floatScalar(v)
+	}
+	return strScalar(fmt.Sprint(x))
+}
+
+func uintScalar(u uint64) Scalar {
+	if u > math.MaxInt64 {
+		return floatScalar(u)
+	}
+	return intScalar(u)
+}
+
+type (
+	strScalar   string
+	intScalar   int64
+	floatScalar float64
+)
+
+func (s strScalar) AsString() string   { return string(s) }
+func (s strScalar) AsInt() int64       { return ParseNumber(string(s)).IV }
+func (s strScalar) AsFloat() float64   { return ParseNumber(string(s)).NV }
+func (n intScalar) AsString() string   { return strconv.FormatInt(int64(n), 10) }
+func (n intScalar) AsInt() int64       { return int64(n) }
+func (n intScalar) AsFloat() float64   { return float64(n) }
+func (f floatScalar) AsString() string { return FormatFloat(float64(f)) }
+func (f floatScalar) AsInt() int64     { return numToInt(float64(f)) }
+func (f floatScalar) AsFloat() float64 { return float64(f) }
+
+// FormatFloat is the string of a float as perl prints it, with %.15g: 15
+// significant digits, so 0.1+0.2 is "0.3" and 1e15 is "1e+15"; a whole
+// number below 1e15 without a fraction, and "Inf", "-Inf" and "NaN".
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', 15, 64)
+}
@@ -0,0 +1,267 @@
+package perlstr
+
+import (
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"perlc/pkg/perlre"
+)
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []any
+		want   string
+	}{
+		{"%5d|%-5d|%05d|%+d|% d", []any{42, 42, 42, 42, 42}, "   42|42   |00042|+42| 42"},
+		{"%d %i %D", []any{"42abc", 3.7, -2}, "42 3 -2"},
+		{"%u %x %X %o %b", []any{-1, 255, 255, 8, 5}, "18446744073709551615 ff FF 10 101"},
+		{"%x %o", []any{-1, -1}, "ffffffffffffffff 1777777777777777777777"},
+		{"%#x %#o %#b %#B %#x", []any{255, 8, 5, 5, 0}, "0xff 010 0b101 0B101 0"},
+		{"%.3d|%5.2s|%05s|%-4s|", []any{5, "abc", "ab", "ab"}, "005|   ab|000ab|ab  |"},
+		{"%g %g %g %.2g %G", []any{0.1 + 0.2, 1e6, 100000.0, 1234.0, 1e-10}, "0.3 1e+06 100000 1.2e+03 1E-10"},
+		{"%f %.2f %e %8.3f", []any{2, 2.675, 1.5, math.Pi}, "2.000000 2.67 1.500000e+00    3.142"},
+		{"%d %5.1f %e %+f", []any{math.Inf(1), math.NaN(), math.Inf(-1), math.Inf(1)}, "Inf   NaN -Inf +Inf"},
+		{"%d %u", []any{1e20, 1.5e19}, "100000000000000000000 15000000000000000000"},
+		{"%2$s %1$s", []any{"world", "hello"}, "hello world"},
+		{"%*d|%-*d|%.*f|%*s", []any{4, 7, 4, 7, 2, math.Pi, -3, "a"}, "   7|7   |3.14|a  "},
+		{"%vd %s", []any{"1.22.333", "x"}, "49.46.50.50.46.51.51.51 x"},
+		{"%c%c %5c", []any{72, 105, "65"}, "Hi     A"},
+		{"100%% %y %5", []any{1}, "100% %y %5"},
+		{"%s-%s-%d", []any{"a"}, "a--0"},
+		{"%ld %lld %hd %qd", []any{1, 2, 3, 4}, "1 2 3 4"},
+		{"%s %s %s", []any{true, false, nil}, "1  "},
+	}
+	for _, tt := range tests {
+		if got := Sprintf(tt.format, tt.args...); got != tt.want {
+			t.Errorf("Sprintf(%q, %v) = %q, want %q", tt.format, tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestSprintfNum tests which arguments SprintfNum reports as read as numbers.
+func TestSprintfNum(t *testing.T) {
+	var nums []string
+	got := SprintfNum(func(v Scalar) { nums = append(nums, v.AsString()) },
+		"%s %d %*x %.*f %c %vd", "a", "42abc", "3w", 255, 1, 2.25, 65, "1.2")
+	if want := "a 42  ff 2.2 A 49.46.50"; got != want {
+		t.Errorf("SprintfNum = %q, want %q", got, want)
+	}
+	if want := "42abc 3w 255 1 2.25 65"; strings.Join(nums, " ") != want {
+		t.Errorf("SprintfNum read %q as numbers, want %q", nums, want)
+	}
+}
+
+func TestScalarOf(t *testing.T) {
+	tests := []struct {
+		x   any
+		str string
+		iv  int64
+		nv  float64
+	}{
+		{"3.5 apples", "3.5 apples", 3, 3.5},
+		{uint64(math.MaxUint64), "1.84467440737096e+19", math.MaxInt64, math.MaxUint64},
+		{int8(-3), "-3", -3, -3},
+		{2.5, "2.5", 2, 2.5},
+		{[]byte("7"), "7", 7, 7},
+		{nil, "", 0, 0},
+	}
+	for _, tt := range tests {
+		v := ScalarOf(tt.x)
+		if v.AsString() != tt.str || v.AsInt() != tt.iv || v.AsFloat() != tt.nv {
+			t.Errorf("ScalarOf(%v) = %q %d %g, want %q %d %g", tt.x, v.AsString(), v.AsInt(), v.AsFloat(), tt.str, tt.iv, tt.nv)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{0.1 + 0.2, "0.3"},
+		{1.0 / 3, "0.333333333333333"},
+		{2.5, "2.5"},
+		{-7, "-7"},
+		{1e14, "100000000000000"},
+		{1e15, "1e+15"},
+		{123456789012345678, "1.23456789012346e+17"},
+		{1e-5, "1e-05"},
+		{0.0001, "0.0001"},
+		{3.14159265358979, "3.14159265358979"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := FormatFloat(tt.f); got != tt.want {
+			t.Errorf("FormatFloat(%v) = %q, want %q", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		limit   int
+		want    []string
+	}{
+		{",", "a,b,,c,,", 0, []string{"a", "b", "", "c"}},
+		{",", "a,b,,c,,", -1, []string{"a", "b", "", "c", "", ""}},
+		{",", "a,b,c,d", 2, []string{"a", "b,c,d"}},
+		{",", ",a", 0, []string{"", "a"}},
+		{"", "abc", 0, []string{"a", "b", "c"}},
+		{`\.`, "1.2.3", 0, []string{"1", "2", "3"}},
+		{",", "", -1, nil},
+		{",", ",,,", 0, nil},
+		{"(,)", "a,b", 0, []string{"a", ",", "b"}},
+		{"(-)|(,)", "a,b-c", 0, []string{"a", "", ",", "b", "-", "", "c"}},
+		{"(,)", "a,b,c", 2, []string{"a", ",", "b,c"}},
+		{"()", "ab", -1, []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		re, err := perlre.Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Split(re, tt.s, tt.limit); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("Split(/%s/, %q, %d) = %q, want %q", tt.pattern, tt.s, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  []string
+	}{
+		{"  a b\t\tc \n", 0, []string{"a", "b", "c"}},
+		{"  a b  c ", -1, []string{"a", "b", "c", ""}},
+		{"a b  c ", 2, []string{"a", "b  c "}},
+		{"   ", 0, nil},
+	}
+	for _, tt := range tests {
+		if got := Fields(tt.s, tt.limit); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("Fields(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTr(t *testing.T) {
+	tests := []struct {
+		s, search, replace, flags string
+		want                      string
+		count                     int
+	}{
+		{"hello", "a-y", "b-z", "", "ifmmp", 5},
+		{"hello world", "o", "", "", "hello world", 2},
+		{"hello", "a-z", "A-C", "", "CCCCC", 5},
+		{"hello", "l", "", "d", "heo", 2},
+		{"aabbcc", "a-z", "", "s", "abc", 6},
+		{"hello, world", "a-z", "_", "c", "hello__world", 2},
+		{"tab\there", `\t`, " ", "", "tab here", 1},
+	}
+	for _, tt := range tests {
+		got, n := Tr(tt.s, tt.search, tt.replace, tt.flags)
+		if got != tt.want || n != tt.count {
+			t.Errorf("Tr(%q, %q, %q, %q) = %q, %d, want %q, %d", tt.s, tt.search, tt.replace, tt.flags, got, n, tt.want, tt.count)
+		}
+	}
+}
+
+func TestArith(t *testing.T) {
+	ops := map[string]func(a, b Number) (Number, bool){
+		"+":  func(a, b Number) (Number, bool) { return NumAdd(a, b), true },
+		"-":  func(a, b Number) (Number, bool) { return NumSub(a, b), true },
+		"*":  func(a, b Number) (Number, bool) { return NumMul(a, b), true },
+		"/":  NumDiv,
+		"%":  NumMod,
+		"**": func(a, b Number) (Number, bool) { return NumPow(a, b), true },
+	}
+	tests := []struct {
+		a    Number
+		op   string
+		b    Number
+		want string
+	}{
+		{IntNumber(-7), "%", IntNumber(3), "2"},
+		{IntNumber(7), "%", IntNumber(-3), "-2"},
+		{IntNumber(-7), "%", IntNumber(-3), "-1"},
+		{FloatNumber(-13.9), "%", IntNumber(4), "3"},
+		{FloatNumber(7.5), "%", FloatNumber(1e20), "7.5"},
+		{FloatNumber(1e20), "%", FloatNumber(2.5), "1"},
+		{FloatNumber(-1e20), "%", IntNumber(7), "5"},
+		{IntNumber(1), "%", FloatNumber(0.5), "zero"},
+		{IntNumber(7), "/", IntNumber(2), "3.5"},
+		{IntNumber(9007199254740993), "/", IntNumber(1), "9007199254740993"},
+		{IntNumber(-18014398509481985), "/", IntNumber(5), "-3602879701896397"},
+		{IntNumber(18014398509481984), "/", IntNumber(3), "6.00479950316066e+15"},
+		{FloatNumber(1), "/", ParseNumber("0.0"), "zero"},
+		{IntNumber(math.MaxInt64), "+", IntNumber(1), "9223372036854775808"},
+		{IntNumber(math.MaxInt64), "*", IntNumber(2), "18446744073709551614"},
+		{IntNumber(4294967296), "*", IntNumber(4294967295), "18446744069414584320"},
+		{ParseNumber("18446744073709551615"), "+", IntNumber(1), "1.84467440737096e+19"},
+		{ParseNumber("18446744073709551615"), "-", IntNumber(1), "18446744073709551614"},
+		{ParseNumber("9223372036854775808"), "-", IntNumber(1), "9223372036854775807"},
+		{IntNumber(0), "-", ParseNumber("9223372036854775808"), "-9223372036854775808"},
+		{IntNumber(0), "-", ParseNumber("18446744073709551615"), "-1.84467440737096e+19"},
+		{ParseNumber("18446744073709551615"), "*", IntNumber(-1), "-1.84467440737096e+19"},
+		{ParseNumber("18446744073709551615"), "%", IntNumber(10), "5"},
+		{ParseNumber("18446744073709551614"), "/", IntNumber(2), "9223372036854775807"},
+		{IntNumber(-7), "%", ParseNumber("18446744073709551615"), "18446744073709551608"},
+		{IntNumber(math.MinInt64), "-", IntNumber(1), "-9.22337203685478e+18"},
+		{IntNumber(3037000500), "*", IntNumber(-3037000500), "-9.22337203700025e+18"},
+		{FloatNumber(1125899906842624), "+", IntNumber(1), "1125899906842625"},
+		{ParseNumber("3.0"), "*", IntNumber(1), "3"},
+		{IntNumber(2), "**", IntNumber(50), "1.12589990684262e+15"},
+		{IntNumber(3), "**", IntNumber(32), "1853020188851841"},
+		{IntNumber(3), "**", IntNumber(33), "5.55906056655552e+15"},
+		{IntNumber(-7), "**", IntNumber(21), "-558545864083284007"},
+		{IntNumber(7), "**", IntNumber(33), "7.73099371970744e+27"},
+		{FloatNumber(0.1), "**", IntNumber(-5), "100000"},
+		{IntNumber(4), "**", FloatNumber(0.5), "2"},
+		{IntNumber(0), "**", IntNumber(0), "1"},
+	}
+	for _, tt := range tests {
+		n, ok := ops[tt.op](tt.a, tt.b)
+		got := "zero"
+		switch {
+		case ok && n.IsInt:
+			got = strconv.FormatInt(n.IV, 10)
+		case ok && n.IsUV:
+			got = strconv.FormatUint(n.UV, 10)
+		case ok:
+			got = FormatFloat(n.NV)
+		}
+		if got != tt.want {
+			t.Errorf("%v %s %v = %s, want %s", tt.a.NV, tt.op, tt.b.NV, got, tt.want)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	var src strings.Builder
+	files, err := fs.ReadDir(Sources, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), "_test.go") {
+			t.Errorf("Sources hold the test %s", f.Name())
+		}
+		data, _ := fs.ReadFile(Sources, f.Name())
+		if !strings.Contains("\n"+string(data), "\npackage perlstr\n") {
+			t.Errorf("%s is not of the package", f.Name())
+		}
+		src.Write(data)
+	}
+	for _, decl := range []string{"func Sprintf(", "func ParseNumber(", "func Split(", "func Transliterate(", "func NumMod(", "var Sources embed.FS"} {
+		if !strings.Contains(src.String(), decl) {
+			t.Errorf("Sources are missing %s", decl)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package perlstr
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Splitter finds the matches of a pattern and their groups, as
+// regexp.Regexp.FindAllStringSubmatchIndex does; *perlre.Regexp is one.
+type Splitter interface {
+	FindAllStringSubmatchIndex(s string, n int) [][]int
+}
+
+// Split is split /PATTERN/, s, limit. A positive limit is the most fields
+// to return, the last one holding the rest of s. Empty fields at the end
+// are dropped unless limit is not 0; a negative one only lifts that. A
+// match of positive width at the start gives an empty first field, an
+// empty s no fields at all. The groups of the pattern are returned between
+// the fields they separate, "" for a group that did not take part.
+func Split(sep Splitter, s string, limit int) []string {
+	if s == "" {
+		return nil
+	}
+	var parts []string
+	beg, fields := 0, 0
+	for _, loc := range sep.FindAllStringSubmatchIndex(s, -1) {
+		if limit > 0 && fields == limit-1 {
+			break
+		}
+		if loc[1] == 0 {
+			// an empty match at the start gives no empty field
+			continue
+		}
+		if loc[0] == len(s) {
+			break
+		}
+		parts = append(parts, s[beg:loc[0]])
+		fields++
+		for g := 2; g+1 < len(loc); g += 2 {
+			if loc[g] < 0 {
+				parts = append(parts, "")
+			} else {
+				parts = append(parts, s[loc[g]:loc[g+1]])
+			}
+		}
+		beg = loc[1]
+	}
+	parts = append(parts, s[beg:])
+	if limit == 0 {
+		parts = dropEmpty(parts)
+	}
+	return parts
+}
+
+// Fields is split ' ', s, limit, the awk mode: white space separates the
+// fields and leading white space is skipped. The limit is that of Split.
+func Fields(s string, limit int) []string {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	var parts []string
+	for s != "" {
+		if limit > 0 && len(parts) == limit-1 {
+			parts = append(parts, s)
+			break
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			parts = append(parts, s)
+			break
+		}
+		parts = append(parts, s[:end])
+		s = strings.TrimLeftFunc(s[end:], unicode.IsSpace)
+		if s == "" {
+			// white space at the end leaves an empty last field
+			parts = append(parts, "")
+		}
+	}
+	if limit == 0 {
+		parts = dropEmpty(parts)
+	}
+	return parts
+}
+
+// dropEmpty drops the empty fields at the end of parts
+func dropEmpty(parts []string) []string {
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
@@ -0,0 +1,326 @@
+package perlstr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Sprintf formats like Perl's sprintf. Each argument is read by ScalarOf,
+// so "42abc" is 42 for %d and 3.7 is 3. A directive is
+//
+//	%[INDEX$][FLAGS][v][WIDTH][.PRECISION][SIZE]CONVERSION
+//
+// with the flags space, +, -, 0 and #, a WIDTH or PRECISION of * (or *N$)
+// taken from the arguments and the SIZE (h, l, ll, q, L, V, ...) ignored.
+// The conversions are %% c s d i u o x X b B e E f F g G and the synonyms D
+// U O. Where Perl differs from C and Go:
+//
+//   - %u, %o, %x and %b show a negative number as the unsigned 64-bit one;
+//   - %g without a precision has 6 digits, as in C, not the shortest form;
+//   - Inf and NaN print as "Inf", "-Inf" and "NaN" for every number;
+//   - %05s pads a string with zeros;
+//   - %vd prints the ordinals of the characters joined by "." (a version);
+//   - a missing argument is undef, an unknown directive is printed as is.
+func Sprintf(format string, args ...any) string {
+	return SprintfNum(nil, format, args...)
+}
+
+// SprintfNum is Sprintf calling num, if not nil, with every argument it
+// reads as a number: a * width or precision and the values of all the
+// conversions but %s. It is how the backends warn "Argument isn't numeric
+// in sprintf".
+func SprintfNum(num func(Scalar), format string, args ...any) string {
+	if num == nil {
+		num = func(Scalar) {}
+	}
+	var b strings.Builder
+	next := 0
+	arg := func(index int) Scalar {
+		if index == 0 {
+			index = next + 1
+			next++
+		}
+		if index > len(args) {
+			return strScalar("")
+		}
+		return ScalarOf(args[index-1])
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		d, n := parseDirective(format[i+1:])
+		if d.conv == 0 {
+			b.WriteByte('%')
+			continue
+		}
+		i += n
+		if d.conv == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if d.widthArg >= 0 {
+			v := arg(d.widthArg)
+			num(v)
+			w := int(v.AsInt())
+			if w < 0 {
+				d.minus, w = true, -w
+			}
+			d.width = w
+		}
+		if d.precArg >= 0 {
+			v := arg(d.precArg)
+			num(v)
+			if p := int(v.AsInt()); p >= 0 {
+				d.prec = p
+			} else {
+				d.prec = -1
+			}
+		}
+		if !strings.ContainsRune("csdiuoxXbBeEfFgGDUO", rune(d.conv)) {
+			b.WriteString(format[i-n : i+1])
+			continue
+		}
+		v := arg(d.index)
+		if d.conv != 's' && !d.vector {
+			num(v)
+		}
+		b.WriteString(d.format(v))
+	}
+	return b.String()
+}
+
+// directive is a parsed %... of Sprintf
+type directive struct {
+	index              int // argument number, 0 for the next one
+	plus, minus, space bool
+	zero, sharp        bool
+	vector             bool
+	width, widthArg    int // widthArg: argument number of *, 0 for the next, -1 if none
+	prec, precArg      int // prec -1: none
+	conv               byte
+}
+
+// parseDirective reads the directive after a % in s and returns it with
+// the number of bytes it took; conv is 0 for an unfinished one.
+func parseDirective(s string) (directive, int) {
+	d := directive{widthArg: -1, prec: -1, precArg: -1}
+	i := 0
+	// number followed by $, or 0 if there is none
+	explicit := func() int {
+		j := i
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if j > i && j < len(s) && s[j] == '$' {
+			n, _ := strconv.Atoi(s[i:j])
+			i = j + 1
+			return n
+		}
+		return 0
+	}
+	d.index = explicit()
+flags:
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '+':
+			d.plus = true
+		case '-':
+			d.minus = true
+		case ' ':
+			d.space = true
+		case '0':
+			d.zero = true
+		case '#':
+			d.sharp = true
+		default:
+			break flags
+		}
+	}
+	if i < len(s) && s[i] == 'v' {
+		d.vector = true
+		i++
+	}
+	if i < len(s) && s[i] == '*' {
+		i++
+		d.widthArg = explicit()
+	} else {
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			d.width = d.width*10 + int(s[i]-'0')
+		}
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		d.prec = 0
+		if i < len(s) && s[i] == '*' {
+			i++
+			d.precArg = explicit()
+		} else {
+			for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				d.prec = d.prec*10 + int(s[i]-'0')
+			}
+		}
+	}
+	for i < len(s) && strings.IndexByte("hlqLVjzt", s[i]) >= 0 {
+		i++
+	}
+	if i == len(s) {
+		return d, 0
+	}
+	d.conv = s[i]
+	return d, i + 1
+}
+
+// format formats one argument
+func (d directive) format(v Scalar) string {
+	switch d.conv {
+	case 'D':
+		d.conv = 'd'
+	case 'U':
+		d.conv = 'u'
+	case 'O':
+		d.conv = 'o'
+	}
+	if d.vector && strings.IndexByte("diuoxXbB", d.conv) >= 0 {
+		s := v.AsString()
+		parts := make([]string, 0, len(s))
+		vd := d
+		vd.width, vd.minus = 0, false
+		for _, r := range s {
+			parts = append(parts, vd.integer(intScalar(r)))
+		}
+		return d.pad(strings.Join(parts, "."), false)
+	}
+	switch d.conv {
+	case 'c':
+		return d.pad(string(rune(v.AsInt())), d.zero)
+	case 's':
+		s := v.AsString()
+		if d.prec >= 0 && utf8.RuneCountInString(s) > d.prec {
+			s = string([]rune(s)[:d.prec])
+		}
+		return d.pad(s, d.zero)
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		f := v.AsFloat()
+		if s, ok := d.infNaN(f); ok {
+			return s
+		}
+		prec := d.prec
+		if prec < 0 {
+			prec = 6
+		}
+		return fmt.Sprintf(d.spec()+"."+strconv.Itoa(prec)+string(d.conv), f)
+	}
+	return d.integer(v)
+}
+
+// integer formats the integer conversions d i u o x X b B
+func (d directive) integer(v Scalar) string {
+	f := v.AsFloat()
+	if s, ok := d.infNaN(f); ok {
+		return s
+	}
+	n := v.AsInt()
+	u := uint64(n)
+	switch {
+	case (d.conv == 'd' || d.conv == 'i') && math.Abs(f) >= 1e19:
+		// too big for an integer: the digits of the float
+		d.conv, d.prec = 'f', 0
+		return d.format(floatScalar(math.Trunc(f)))
+	case f > math.MaxInt64 && f < 1<<64:
+		u = uint64(f)
+	}
+	spec := d.spec()
+	if d.prec >= 0 {
+		spec += "." + strconv.Itoa(d.prec)
+	}
+	switch d.conv {
+	case 'd', 'i':
+		return fmt.Sprintf(spec+"d", n)
+	case 'u':
+		return fmt.Sprintf(spec+"d", u)
+	}
+	if u == 0 && d.conv != 'o' {
+		// no 0x for zero, as in C
+		d.sharp = false
+		spec = d.spec()
+		if d.prec >= 0 {
+			spec += "." + strconv.Itoa(d.prec)
+		}
+	}
+	switch d.conv {
+	case 'o':
+		if u == 0 {
+			spec = strings.Replace(spec, "#", "", 1)
+		}
+		return fmt.Sprintf(spec+"o", u)
+	case 'B':
+		return strings.Replace(fmt.Sprintf(spec+"b", u), "0b", "0B", 1)
+	}
+	return fmt.Sprintf(spec+string(d.conv), u)
+}
+
+// spec rebuilds the flags and width for fmt
+func (d directive) spec() string {
+	s := "%"
+	if d.plus {
+		s += "+"
+	}
+	if d.minus {
+		s += "-"
+	}
+	if d.space {
+		s += " "
+	}
+	if d.sharp {
+		s += "#"
+	}
+	if d.zero {
+		s += "0"
+	}
+	if d.width > 0 {
+		s += strconv.Itoa(d.width)
+	}
+	return s
+}
+
+// infNaN formats an infinite or NaN number as a string: "Inf", "-Inf" or
+// "NaN", with + or space when asked, padded with spaces
+func (d directive) infNaN(f float64) (string, bool) {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "Inf"
+		if d.plus {
+			s = "+Inf"
+		} else if d.space {
+			s = " Inf"
+		}
+	case math.IsInf(f, -1):
+		s = "-Inf"
+	case math.IsNaN(f):
+		s = "NaN"
+	default:
+		return "", false
+	}
+	return d.pad(s, false), true
+}
+
+// pad fills s to the width, on the right for -, else on the left with
+// zeros or spaces
+func (d directive) pad(s string, zero bool) string {
+	n := d.width - utf8.RuneCountInString(s)
+	switch {
+	case n <= 0:
+		return s
+	case d.minus:
+		return s + strings.Repeat(" ", n)
+	case zero:
+		return strings.Repeat("0", n) + s
+	}
+	return strings.Repeat(" ", n) + s
+}
@@ -0,0 +1,117 @@
+package perlstr
+
+import (
+	"strings"
+)
+
+// Tr is tr/search/replace/flags on s (y/// too): the new string and the
+// number of characters matched. The lists are expanded by TrList. The
+// flags are c (the characters not in search), d (delete the characters
+// that have no replacement) and s (squeeze runs of the same replacement
+// to one); r only matters to the caller, who keeps s. An empty replace
+// list without d leaves s as it is and only counts; a short one repeats
+// its last character.
+func Tr(s, search, replace, flags string) (string, int) {
+	return Transliterate(s, TrList(search), TrList(replace), flags)
+}
+
+// TrList expands a tr/// search or replacement list: escapes (\n, \t,
+// \r, \0, \\, \-, \/) and ranges such as a-z. A dash that is first, last
+// or escaped is literal.
+func TrList(s string) []rune {
+	type item struct {
+		r   rune
+		esc bool
+	}
+	var items []item
+	runes := []rune(s)
+	for n := 0; n < len(runes); n++ {
+		it := item{r: runes[n]}
+		if it.r == '\\' && n+1 < len(runes) {
+			n++
+			it.esc = true
+			switch runes[n] {
+			case 'n':
+				it.r = '\n'
+			case 't':
+				it.r = '\t'
+			case 'r':
+				it.r = '\r'
+			case '0':
+				it.r = 0
+			default:
+				it.r = runes[n]
+			}
+		}
+		items = append(items, it)
+	}
+
+	var out []rune
+	for n := 0; n < len(items); n++ {
+		if n+2 < len(items) && items[n+1].r == '-' && !items[n+1].esc && items[n].r <= items[n+2].r {
+			for r := items[n].r; r <= items[n+2].r; r++ {
+				out = append(out, r)
+			}
+			n += 2
+			continue
+		}
+		out = append(out, items[n].r)
+	}
+	return out
+}
+
+// Transliterate is Tr with the lists already expanded.
+func Transliterate(s string, from, to []rune, flags string) (string, int) {
+	complement := strings.Contains(flags, "c")
+	del := strings.Contains(flags, "d")
+	squeeze := strings.Contains(flags, "s")
+	keep := len(to) == 0 && !del
+
+	var sb strings.Builder
+	count := 0
+	var last rune
+	squeezing := false
+	for _, r := range s {
+		idx := indexRune(from, r)
+		if complement {
+			if idx >= 0 {
+				idx = -1
+			} else {
+				idx = len(from) + len(to) // past to: its last character or deleted
+			}
+		}
+		if idx < 0 {
+			sb.WriteRune(r)
+			squeezing = false
+			continue
+		}
+		count++
+
+		out := r
+		switch {
+		case keep:
+		case idx < len(to):
+			out = to[idx]
+		case del || len(to) == 0:
+			continue
+		default:
+			out = to[len(to)-1]
+		}
+		if squeeze && squeezing && out == last {
+			continue
+		}
+		sb.WriteRune(out)
+		last, squeezing = out, true
+	}
+	return sb.String(), count
+}
+
+// indexRune is the index of r in list, -1 if it is not there
+func indexRune(list []rune, r rune) int {
+	for n, c := range list {
+		if c == r {
+			return n
+		}
+	}
+	return -1
+}
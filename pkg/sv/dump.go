@@ -0,0 +1,168 @@
+package sv
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dump renders v the way Data::Dumper does with Indent = 1, Sortkeys and
+// Terse: nested arrays and hashes over several lines, two spaces a level,
+// numbers bare and strings in single quotes:
+//
+//	[
+//	  1,
+//	  'two',
+//	  {
+//	    'a' => undef
+//	  }
+//	]
+//
+// An array or hash that is not a reference is a list in parentheses. A
+// reference seen before, as in a cycle, is its path from the top ($VAR1).
+// A maxDepth above 0 is Data::Dumper's Maxdepth: deeper references are
+// shown as their string, 'HASH(0x...)'.
+func Dump(v *SV, maxDepth int) string {
+	d := &dumper{maxDepth: maxDepth, seen: map[*SV]string{}}
+	d.value(v, "$VAR1", 0)
+	return d.b.String()
+}
+
+type dumper struct {
+	b        strings.Builder
+	maxDepth int
+	seen     map[*SV]string // containers already shown, by path
+}
+
+// bareNumber is what Data::Dumper leaves unquoted
+var bareNumber = regexp.MustCompile(`^(?:0|-?[1-9]\d{0,8})$`)
+
+func (d *dumper) value(v *SV, path string, level int) {
+	switch {
+	case v == nil || v.typ == TypeUndef:
+		d.b.WriteString("undef")
+	case v.typ == TypeRef:
+		d.ref(v, path, level)
+	case v.typ == TypeArray:
+		d.array(v, "(", ")", path, level)
+	case v.typ == TypeHash:
+		d.hash(v, "(", ")", path, level)
+	case v.typ == TypeCode:
+		d.b.WriteString(`sub { "DUMMY" }`)
+	default:
+		s := v.AsString()
+		if bareNumber.MatchString(s) {
+			d.b.WriteString(s)
+		} else {
+			d.b.WriteString(quote(s))
+		}
+	}
+}
+
+func (d *dumper) ref(v *SV, path string, level int) {
+	target := v.target()
+	if target == nil {
+		d.b.WriteString("undef")
+		return
+	}
+	if seen, ok := d.seen[target]; ok {
+		d.b.WriteString(seen)
+		return
+	}
+	if d.maxDepth > 0 && level >= d.maxDepth {
+		d.b.WriteString(quote(v.AsString()))
+		return
+	}
+	d.seen[target] = path
+	blessed := v.flags&FlagBless != 0
+	if blessed {
+		d.b.WriteString("bless( ")
+	}
+	switch target.typ {
+	case TypeArray:
+		d.array(target, "[", "]", path, level)
+	case TypeHash:
+		d.hash(target, "{", "}", path, level)
+	case TypeCode:
+		d.b.WriteString(`sub { "DUMMY" }`)
+	case TypeRegex:
+		d.b.WriteString(qr(target.pv))
+	default:
+		d.b.WriteString(`\`)
+		d.value(target, "${"+path+"}", level+1)
+	}
+	if blessed {
+		d.b.WriteString(", " + quote(v.stash) + " )")
+	}
+}
+
+func (d *dumper) array(v *SV, open, close, path string, level int) {
+	if len(v.av) == 0 {
+		d.b.WriteString(open + close)
+		return
+	}
+	d.b.WriteString(open + "\n")
+	for i, e := range v.av {
+		d.indent(level + 1)
+		d.value(e, elemPath(path, "["+strconv.Itoa(i)+"]"), level+1)
+		if i < len(v.av)-1 {
+			d.b.WriteByte(',')
+		}
+		d.b.WriteByte('\n')
+	}
+	d.indent(level)
+	d.b.WriteString(close)
+}
+
+func (d *dumper) hash(v *SV, open, close, path string, level int) {
+	if len(v.hv) == 0 {
+		d.b.WriteString(open + close)
+		return
+	}
+	keys := make([]string, 0, len(v.hv))
+	for k := range v.hv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	d.b.WriteString(open + "\n")
+	for i, k := range keys {
+		d.indent(level + 1)
+		d.b.WriteString(quote(k) + " => ")
+		d.value(v.hv[k], elemPath(path, "{"+quote(k)+"}"), level+1)
+		if i < len(keys)-1 {
+			d.b.WriteByte(',')
+		}
+		d.b.WriteByte('\n')
+	}
+	d.indent(level)
+	d.b.WriteString(close)
+}
+
+func (d *dumper) indent(level int) {
+	d.b.WriteString(strings.Repeat("  ", level))
+}
+
+// elemPath is the path of an element: $VAR1->[0], then $VAR1->[0]{'a'}
+func elemPath(path, sub string) string {
+	if strings.HasSuffix(path, "]") || strings.HasSuffix(path, "'}") {
+		return path + sub
+	}
+	return path + "->" + sub
+}
+
+// quote puts s in single quotes, escaping \ and '
+func quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// qr turns the "(?flags:...)" form of a pattern back into qr/.../flags
+func qr(pattern string) string {
+	if strings.HasPrefix(pattern, "(?") && strings.HasSuffix(pattern, ")") {
+		if colon := strings.IndexByte(pattern, ':'); colon > 0 {
+			flags := strings.TrimPrefix(pattern[2:colon], "^")
+			return "qr/" + pattern[colon+1:len(pattern)-1] + "/" + flags
+		}
+	}
+	return "qr/" + pattern + "/"
+}
@@ -0,0 +1,45 @@
+package sv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	inner := NewHashRef()
+	inner.Deref().HashData()["b'c"] = NewUndef()
+	inner.Deref().HashData()["a"] = NewArrayRef()
+	nested := NewArrayRef(NewInt(1), NewString("two"), NewFloat(2.5), NewString("007"), inner)
+
+	cyclic := NewHashRef()
+	cyclic.Deref().HashData()["self"] = cyclic
+	cyclic.Deref().HashData()["list"] = NewArrayRef(cyclic)
+
+	tests := []struct {
+		name  string
+		v     *SV
+		depth int
+		want  string
+	}{
+		{"scalars", NewArraySV(NewInt(-42), NewInt(1234567890), NewString(`a\b`), NewUndef()), 0,
+			"(\n  -42,\n  '1234567890',\n  'a\\\\b',\n  undef\n)"},
+		{"nested", nested, 0,
+			"[\n  1,\n  'two',\n  '2.5',\n  '007',\n  {\n    'a' => [],\n    'b\\'c' => undef\n  }\n]"},
+		{"blessed", NewHashRef().Bless("Point"), 0, "bless( {}, 'Point' )"},
+		{"scalar ref", NewRef(NewRef(NewString("x"))), 0, `\\'x'`},
+		{"code and qr", NewArraySV(NewCodeRef("main::f"), NewRegexRef("(?i:ab+)")), 0,
+			"(\n  sub { \"DUMMY\" },\n  qr/ab+/i\n)"},
+		{"cycle", cyclic, 0,
+			"{\n  'list' => [\n    $VAR1\n  ],\n  'self' => $VAR1\n}"},
+	}
+	for _, tt := range tests {
+		if got := Dump(tt.v, tt.depth); got != tt.want {
+			t.Errorf("%s: Dump =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	got := Dump(nested, 1)
+	if !strings.HasPrefix(got, "[\n  1,\n") || !strings.Contains(got, "  'HASH(0x") {
+		t.Errorf("Dump with depth 1 =\n%s", got)
+	}
+}
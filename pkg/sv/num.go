@@ -0,0 +1,79 @@
+package sv
+
+import "perlc/pkg/perlstr"
+
+// Numeric strings are read by package perlstr, which the generated
+// programs embed as well; see its doc for the rules.
+
+// Number is a string read as a number.
+type Number = perlstr.Number
+
+// ParseNumber reads the numeric prefix of s.
+func ParseNumber(s string) Number {
+	return perlstr.ParseNumber(s)
+}
+
+// LooksLikeNumber reports whether all of s is a number.
+func LooksLikeNumber(s string) bool {
+	return perlstr.LooksLikeNumber(s)
+}
+
+// NotNumeric is the warning for s used as an operand of the numeric
+// operator op ("addition (+)").
+func NotNumeric(s, op string) string {
+	return perlstr.NotNumeric(s, op)
+}
+
+// IncrementString is the magic string ++: the string after s and true,
+// or false when ++ on s is numeric.
+func IncrementString(s string) (string, bool) {
+	return perlstr.IncrementString(s)
+}
+
+// perlCompatNumbers switches the arithmetic of ops.go to perlstr's
+var perlCompatNumbers bool
+
+// SetPerlCompatNumbers makes +, -, *, /, % and ** follow perl exactly
+// (perlstr's NumAdd and the rest): integer results while they fit, floored
+// modulo, exact integer division and powers. perlc --perl-compat-numbers
+// sets it.
+func SetPerlCompatNumbers(on bool) {
+	perlCompatNumbers = on
+}
+
+// PerlCompatNumbers reports whether SetPerlCompatNumbers is on.
+func PerlCompatNumbers() bool {
+	return perlCompatNumbers
+}
+
+// number is v as an operand of perlstr's arithmetic
+func number(v *SV) Number {
+	switch {
+	case v == nil:
+		return perlstr.IntNumber(0)
+	case v.typ == TypeFloat:
+		return perlstr.FloatNumber(v.nv)
+	case v.typ == TypeInt && v.flags&FlagUV != 0:
+		return perlstr.UintNumber(uint64(v.iv))
+	case v.typ == TypeString:
+		return ParseNumber(v.pv)
+	}
+	return perlstr.IntNumber(v.AsInt())
+}
+
+// fromNumber is the result of perlstr's arithmetic as a value
+func fromNumber(n Number) *SV {
+	if n.IsInt {
+		return NewInt(n.IV)
+	}
+	if n.IsUV {
+		return NewUint(n.UV)
+	}
+	return NewFloat(n.NV)
+}
+
+// setNumber stores n into v in place, as ++ and -- do
+func (v *SV) setNumber(n Number) {
+	r := fromNumber(n)
+	v.typ, v.iv, v.nv, v.flags = r.typ, r.iv, r.nv, r.flags
+}
@@ -4,6 +4,8 @@ import (
 	"math"
 	"strings"
 	"unicode/utf8"
+
+	"perlc/pkg/perlstr"
 )
 
 // ============================================================
@@ -12,6 +14,9 @@ import (
 
 // Add performs $a + $b
 func Add(a, b *SV) *SV {
+	if perlCompatNumbers {
+		return fromNumber(perlstr.NumAdd(number(a), number(b)))
+	}
 	// Check if either operand wants float math
 	if needsFloatMath(a) || needsFloatMath(b) {
 		return NewFloat(a.AsFloat() + b.AsFloat())
@@ -21,6 +26,9 @@ func Add(a, b *SV) *SV {
 
 // Sub performs $a - $b
 func Sub(a, b *SV) *SV {
+	if perlCompatNumbers {
+		return fromNumber(perlstr.NumSub(number(a), number(b)))
+	}
 	if needsFloatMath(a) || needsFloatMath(b) {
 		return NewFloat(a.AsFloat() - b.AsFloat())
 	}
@@ -29,6 +37,9 @@ func Sub(a, b *SV) *SV {
 
 // Mul performs $a * $b
 func Mul(a, b *SV) *SV {
+	if perlCompatNumbers {
+		return fromNumber(perlstr.NumMul(number(a), number(b)))
+	}
 	if needsFloatMath(a) || needsFloatMath(b) {
 		return NewFloat(a.AsFloat() * b.AsFloat())
 	}
@@ -37,6 +48,13 @@ func Mul(a, b *SV) *SV {
 
 // Div performs $a / $b (always returns float like Perl)
 func Div(a, b *SV) *SV {
+	if perlCompatNumbers {
+		n, ok := perlstr.NumDiv(number(a), number(b))
+		if !ok {
+			panic("Illegal division by zero")
+		}
+		return fromNumber(n)
+	}
 	bv := b.AsFloat()
 	if bv == 0 {
 		// Perl: division by zero is fatal error
@@ -56,6 +74,13 @@ func IntDiv(a, b *SV) *SV {
 
 // Mod performs $a % $b
 func Mod(a, b *SV) *SV {
+	if perlCompatNumbers {
+		n, ok := perlstr.NumMod(number(a), number(b))
+		if !ok {
+			panic("Illegal modulus zero")
+		}
+		return fromNumber(n)
+	}
 	bv := b.AsInt()
 	if bv == 0 {
 		panic("Illegal modulus zero")
@@ -65,16 +90,24 @@ func Mod(a, b *SV) *SV {
 
 // Pow performs $a ** $b
 func Pow(a, b *SV) *SV {
-	result := math.Pow(a.AsFloat(), b.AsFloat())
-	// Return int if result is whole and fits
-	if result == math.Trunc(result) && result >= math.MinInt64 && result <= math.MaxInt64 {
-		return NewInt(int64(result))
+	if perlCompatNumbers {
+		return fromNumber(perlstr.NumPow(number(a), number(b)))
+	}
+	// An integer only where perl computes one (10**15, not 2**53); other
+	// powers are floats and print with %.15g
+	if !needsFloatMath(a) && !needsFloatMath(b) {
+		if n, ok := perlstr.PowInt(a.AsInt(), b.AsInt()); ok {
+			return fromNumber(n)
+		}
 	}
-	return NewFloat(result)
+	return NewFloat(math.Pow(a.AsFloat(), b.AsFloat()))
 }
 
 // Neg performs -$a (negation)
 func Neg(a *SV) *SV {
+	if perlCompatNumbers && a.typ != TypeRef {
+		return fromNumber(perlstr.NumSub(perlstr.IntNumber(0), number(a)))
+	}
 	if a.typ == TypeFloat || a.flags&FlagNOK != 0 {
 		return NewFloat(-a.AsFloat())
 	}
@@ -86,16 +119,13 @@ func needsFloatMath(sv *SV) bool {
 	if sv == nil {
 		return false
 	}
-	if sv.typ == TypeFloat {
+	if sv.typ == TypeFloat || sv.flags&FlagUV != 0 {
 		return true
 	}
 	if sv.typ == TypeString {
-		s := sv.pv
-		for _, c := range s {
-			if c == '.' || c == 'e' || c == 'E' {
-				return true
-			}
-		}
+		// "1.5", "1e3", "Inf", but not "42" or "abc"
+		n := ParseNumber(sv.pv)
+		return n.Len > 0 && !n.IsInt
 	}
 	return false
 }
@@ -109,13 +139,17 @@ func Inc(a *SV) *SV {
 	a.checkWritable()
 
 	// Perl's magical string increment: "aa" -> "ab", "az" -> "ba", "z9" -> "aa0"
-	if a.typ == TypeString && !hasNumericPrefix(a.pv) && isIncrementableString(a.pv) {
-		a.pv = incrementString(a.pv)
-		a.flags = FlagPOK | FlagUTF8
-		return a
+	if a.typ == TypeString {
+		if s, ok := IncrementString(a.pv); ok {
+			a.pv = s
+			a.flags = FlagPOK | FlagUTF8
+			return a
+		}
 	}
 
-	if a.typ == TypeFloat || a.flags&FlagNOK != 0 {
+	if perlCompatNumbers {
+		a.setNumber(perlstr.NumAdd(number(a), perlstr.IntNumber(1)))
+	} else if a.flags&FlagNOK != 0 || needsFloatMath(a) || a.AsInt() == math.MaxInt64 {
 		a.nv = a.AsFloat() + 1
 		a.flags = FlagNOK
 		a.typ = TypeFloat
@@ -132,7 +166,9 @@ func Inc(a *SV) *SV {
 func Dec(a *SV) *SV {
 	a.checkWritable()
 
-	if a.typ == TypeFloat || a.flags&FlagNOK != 0 {
+	if perlCompatNumbers {
+		a.setNumber(perlstr.NumSub(number(a), perlstr.IntNumber(1)))
+	} else if a.flags&FlagNOK != 0 || needsFloatMath(a) || a.AsInt() == math.MinInt64 {
 		a.nv = a.AsFloat() - 1
 		a.flags = FlagNOK
 		a.typ = TypeFloat
@@ -167,57 +203,6 @@ func isIncrementableString(s string) bool {
 	return true
 }
 
-// incrementString performs Perl's magical string increment
-func incrementString(s string) string {
-	if s == "" {
-		return "1"
-	}
-
-	runes := []rune(s)
-	carry := true
-
-	for i := len(runes) - 1; i >= 0 && carry; i-- {
-		c := runes[i]
-
-		switch {
-		case c >= 'a' && c < 'z':
-			runes[i] = c + 1
-			carry = false
-		case c == 'z':
-			runes[i] = 'a'
-		case c >= 'A' && c < 'Z':
-			runes[i] = c + 1
-			carry = false
-		case c == 'Z':
-			runes[i] = 'A'
-		case c >= '0' && c < '9':
-			runes[i] = c + 1
-			carry = false
-		case c == '9':
-			runes[i] = '0'
-		default:
-			carry = false
-		}
-	}
-
-	if carry {
-		// Need to prepend
-		first := runes[0]
-		var prefix rune
-		switch {
-		case first >= 'a' && first <= 'z':
-			prefix = 'a'
-		case first >= 'A' && first <= 'Z':
-			prefix = 'A'
-		default:
-			prefix = '1'
-		}
-		runes = append([]rune{prefix}, runes...)
-	}
-
-	return string(runes)
-}
-
 // ============================================================
 // Bitwise Operations
 // ============================================================
@@ -620,7 +605,7 @@ func DefinedOr(a, b *SV) *SV {
 
 // Defined checks if value is defined
 func Defined(a *SV) *SV {
-	if a == nil || a.typ == TypeUndef {
+	if a.IsUndef() {
 		return NewString("")
 	}
 	return NewInt(1)
@@ -663,7 +648,11 @@ func Range(a, b *SV) []*SV {
 		if len(result) > 1000000 { // Safety limit
 			break
 		}
-		current = incrementString(current)
+		next, ok := IncrementString(current)
+		if !ok {
+			break
+		}
+		current = next
 		// Prevent infinite loop if we passed the end
 		if len(current) > len(endStr) {
 			break
@@ -689,7 +678,7 @@ func isStringRange(a, b *SV) bool {
 
 // Ref returns ref($a) - the reference type as string
 func Ref(a *SV) *SV {
-	if a == nil || a.typ != TypeRef {
+	if !a.IsRef() {
 		return NewString("")
 	}
 
@@ -699,11 +688,12 @@ func Ref(a *SV) *SV {
 	}
 
 	// Otherwise return the type
-	if a.rv == nil {
+	target := a.target()
+	if target == nil {
 		return NewString("SCALAR")
 	}
 
-	switch a.rv.typ {
+	switch target.typ {
 	case TypeArray:
 		return NewString("ARRAY")
 	case TypeHash:
@@ -716,6 +706,8 @@ func Ref(a *SV) *SV {
 		return NewString("Regexp")
 	case TypeIO:
 		return NewString("IO")
+	case TypeRef:
+		return NewString("REF")
 	default:
 		return NewString("SCALAR")
 	}
@@ -723,11 +715,11 @@ func Ref(a *SV) *SV {
 
 // Reftype returns reftype($a) - always the underlying type, ignoring blessing
 func Reftype(a *SV) *SV {
-	if a == nil || a.typ != TypeRef || a.rv == nil {
+	if a == nil || a.typ != TypeRef || a.target() == nil {
 		return NewUndef()
 	}
 
-	switch a.rv.typ {
+	switch a.target().typ {
 	case TypeArray:
 		return NewString("ARRAY")
 	case TypeHash:
@@ -737,9 +729,11 @@ func Reftype(a *SV) *SV {
 	case TypeGlob:
 		return NewString("GLOB")
 	case TypeRegex:
-		return NewString("Regexp")
+		return NewString("REGEXP")
 	case TypeIO:
 		return NewString("IO")
+	case TypeRef:
+		return NewString("REF")
 	default:
 		return NewString("SCALAR")
 	}
@@ -43,6 +43,21 @@ func TestArithmetic(t *testing.T) {
 	if Pow(NewInt(2), NewInt(10)).AsInt() != 1024 {
 		t.Errorf("2 ** 10 should be 1024")
 	}
+	// integers only where perl computes them with integers
+	for _, tt := range []struct {
+		x, y int64
+		want string
+	}{
+		{2, 53, "9.00719925474099e+15"},
+		{10, 15, "1000000000000000"},
+		{7, 19, "11398895185373143"},
+		{3, 33, "5.55906056655552e+15"},
+		{-7, 19, "-11398895185373143"},
+	} {
+		if got := Pow(NewInt(tt.x), NewInt(tt.y)).AsString(); got != tt.want {
+			t.Errorf("%d ** %d = %s, want %s", tt.x, tt.y, got, tt.want)
+		}
+	}
 }
 
 func TestFloatArithmetic(t *testing.T) {
@@ -74,6 +89,18 @@ func TestStringIncrement(t *testing.T) {
 		{"A", "B"},
 		{"Z", "AA"},
 		{"a1", "a2"},
+		{"Zz", "AAa"},
+		{"zZ9", "aaA0"},
+		{"99", "100"},
+		{"0009", "0010"},
+		{"Inf", "Ing"},
+		// not /^[a-zA-Z]*[0-9]*$/: numeric
+		{"a-b", "1"},
+		{"9a", "10"},
+		{"a9a", "1"},
+		{"", "1"},
+		{" 7", "8"},
+		{"1.5", "2.5"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,71 @@
+package sv
+
+import "unsafe"
+
+// Approximate costs of the parts of an SV, in bytes, as Devel::Size counts
+// them: the SV itself, its string buffer, one slot per array element and,
+// for a hash, the key and value slots of the map at Go's load factor.
+const (
+	svHeader  = int(unsafe.Sizeof(SV{}))
+	slotSize  = int(unsafe.Sizeof((*SV)(nil)))
+	hashEntry = (int(unsafe.Sizeof("")) + slotSize + 1) * 16 / 13
+)
+
+// Size is Devel::Size's size: the memory of v alone, or of what v refers
+// to. The elements of an array or hash are not followed, only their slots
+// counted.
+func Size(v *SV) int {
+	if v != nil && v.typ == TypeRef && v.rv != nil {
+		v = v.rv
+	}
+	return ownSize(v)
+}
+
+// TotalSize is Devel::Size's total_size: the memory of v and everything
+// reachable from it through elements and references, each SV counted once.
+func TotalSize(v *SV) int {
+	seen := map[*SV]bool{}
+	var walk func(v *SV) int
+	walk = func(v *SV) int {
+		if v == nil || seen[v] {
+			return 0
+		}
+		seen[v] = true
+		n := ownSize(v)
+		switch v.typ {
+		case TypeRef:
+			n += walk(v.rv)
+		case TypeArray:
+			for _, e := range v.av {
+				n += walk(e)
+			}
+		case TypeHash:
+			for _, e := range v.hv {
+				n += walk(e)
+			}
+		}
+		return n
+	}
+	if v != nil && v.typ == TypeRef && v.rv != nil {
+		// the reference passed in is only the argument, as in perl
+		return walk(v.rv)
+	}
+	return walk(v)
+}
+
+// ownSize is the SV header, its string and its array or hash slots
+func ownSize(v *SV) int {
+	if v == nil {
+		return 0
+	}
+	n := svHeader + len(v.pv)
+	switch v.typ {
+	case TypeArray:
+		n += cap(v.av) * slotSize
+	case TypeHash:
+		for k := range v.hv {
+			n += hashEntry + len(k)
+		}
+	}
+	return n
+}
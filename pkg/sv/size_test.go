@@ -0,0 +1,35 @@
+package sv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	short, long := NewString("ab"), NewString(strings.Repeat("x", 1000))
+	if d := Size(long) - Size(short); d != 998 {
+		t.Errorf("string buffer: sizes differ by %d, want 998", d)
+	}
+
+	elem := NewString(strings.Repeat("y", 500))
+	arr := NewArrayRef(elem, NewInt(1))
+	if Size(arr) != Size(arr.Deref()) {
+		t.Errorf("size of a reference is not the size of its target")
+	}
+	if Size(arr) >= Size(elem) {
+		t.Errorf("size(\\@a) = %d counts the elements (element alone is %d)", Size(arr), Size(elem))
+	}
+	if want := Size(arr) + Size(elem) + Size(NewInt(1)); TotalSize(arr) != want {
+		t.Errorf("total_size(\\@a) = %d, want %d", TotalSize(arr), want)
+	}
+
+	// A cycle is counted once: the hash, then the reference stored in it
+	cyclic := NewHashRef()
+	cyclic.Deref().HashData()["self"] = cyclic
+	if want := Size(cyclic) + svHeader; TotalSize(cyclic) != want {
+		t.Errorf("total_size of a cycle = %d, want %d", TotalSize(cyclic), want)
+	}
+	if TotalSize(NewUndef()) != Size(NewUndef()) {
+		t.Errorf("total_size(undef) != size(undef)")
+	}
+}
@@ -0,0 +1,275 @@
+package sv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// StorableMagic starts every image Freeze makes, in memory or in a file.
+// The compiled runtime writes the same format, so a file stored by one
+// backend is retrieved by the other.
+const StorableMagic = "PLST\x01"
+
+// Tags of the serialized values. A reference is tagged 'R', or 'O' with
+// the package it is blessed into, and followed by its referent; a referent
+// already written is 'P' and its index in the order referents were first
+// written, which keeps shared references shared and cycles finite.
+const (
+	stUndef   = 'U'
+	stInt     = 'I'
+	stFloat   = 'N'
+	stString  = 'S'
+	stRegex   = 'X'
+	stRef     = 'R'
+	stBlessed = 'O'
+	stArray   = 'A'
+	stHash    = 'H'
+	stScalar  = 'V'
+	stSeen    = 'P'
+)
+
+// ErrStorableImage is returned by Thaw for data Freeze did not make
+var ErrStorableImage = errors.New("Magic number checking on storable string failed")
+
+// Freeze serializes v and everything it refers to (Storable::freeze).
+// CODE, GLOB and IO values cannot be stored; the error names the kind.
+func Freeze(v *SV) ([]byte, error) {
+	f := &freezer{buf: []byte(StorableMagic), seen: map[*SV]uint64{}}
+	if err := f.value(v); err != nil {
+		return nil, err
+	}
+	return f.buf, nil
+}
+
+type freezer struct {
+	buf  []byte
+	seen map[*SV]uint64
+}
+
+func (f *freezer) str(s string) {
+	f.buf = binary.AppendUvarint(f.buf, uint64(len(s)))
+	f.buf = append(f.buf, s...)
+}
+
+func (f *freezer) value(v *SV) error {
+	switch {
+	case v.IsUndef():
+		f.buf = append(f.buf, stUndef)
+	case v.typ == TypeRef:
+		target := v.target()
+		if target != nil && target.typ == TypeRegex {
+			f.buf = append(f.buf, stRegex)
+			f.str(target.pv)
+			return nil
+		}
+		if pkg := v.Package(); pkg != "" {
+			f.buf = append(f.buf, stBlessed)
+			f.str(pkg)
+		} else {
+			f.buf = append(f.buf, stRef)
+		}
+		return f.referent(target)
+	case v.typ == TypeArray, v.typ == TypeHash:
+		f.buf = append(f.buf, stRef)
+		return f.referent(v)
+	case v.typ == TypeInt:
+		f.buf = append(f.buf, stInt)
+		f.buf = binary.AppendVarint(f.buf, v.iv)
+	case v.typ == TypeFloat:
+		f.buf = append(f.buf, stFloat)
+		f.buf = binary.BigEndian.AppendUint64(f.buf, math.Float64bits(v.nv))
+	case v.typ == TypeString:
+		f.buf = append(f.buf, stString)
+		f.str(v.pv)
+	default:
+		return errors.New("Can't store " + v.typeName() + " items")
+	}
+	return nil
+}
+
+// referent writes what a reference points to, or its index if an earlier
+// reference already wrote it
+func (f *freezer) referent(t *SV) error {
+	if t == nil {
+		f.buf = append(f.buf, stScalar, stUndef)
+		return nil
+	}
+	if n, ok := f.seen[t]; ok {
+		f.buf = append(f.buf, stSeen)
+		f.buf = binary.AppendUvarint(f.buf, n)
+		return nil
+	}
+	switch t.typ {
+	case TypeCode, TypeGlob, TypeIO:
+		return errors.New("Can't store " + t.typeName() + " items")
+	}
+	f.seen[t] = uint64(len(f.seen))
+	switch t.typ {
+	case TypeArray:
+		f.buf = append(f.buf, stArray)
+		f.buf = binary.AppendUvarint(f.buf, uint64(len(t.av)))
+		for _, el := range t.av {
+			if err := f.value(el); err != nil {
+				return err
+			}
+		}
+	case TypeHash:
+		keys := make([]string, 0, len(t.hv))
+		for k := range t.hv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		f.buf = append(f.buf, stHash)
+		f.buf = binary.AppendUvarint(f.buf, uint64(len(keys)))
+		for _, k := range keys {
+			f.str(k)
+			if err := f.value(t.hv[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		f.buf = append(f.buf, stScalar)
+		return f.value(t)
+	}
+	return nil
+}
+
+// typeName is the kind Storable names in "Can't store ... items"
+func (sv *SV) typeName() string {
+	switch sv.typ {
+	case TypeCode:
+		return "CODE"
+	case TypeGlob:
+		return "GLOB"
+	case TypeIO:
+		return "IO"
+	}
+	return "UNKNOWN"
+}
+
+// Thaw rebuilds the values Freeze serialized (Storable::thaw): new
+// arrays, hashes and scalars, shared where the originals were. Objects
+// are blessed with ov answering for their overloaded operators.
+func Thaw(data []byte, ov Overloader) (*SV, error) {
+	if len(data) < len(StorableMagic) || string(data[:len(StorableMagic)]) != StorableMagic {
+		return nil, ErrStorableImage
+	}
+	t := &thawer{data: data[len(StorableMagic):], ov: ov}
+	v := t.value()
+	if t.err != nil || len(t.data) > 0 {
+		return nil, ErrStorableImage
+	}
+	return v, nil
+}
+
+type thawer struct {
+	data []byte
+	seen []*SV
+	ov   Overloader
+	err  error
+}
+
+func (t *thawer) byte() byte {
+	if len(t.data) == 0 {
+		t.err = ErrStorableImage
+		return 0
+	}
+	b := t.data[0]
+	t.data = t.data[1:]
+	return b
+}
+
+func (t *thawer) uvarint() uint64 {
+	n, size := binary.Uvarint(t.data)
+	if size <= 0 {
+		t.err = ErrStorableImage
+		return 0
+	}
+	t.data = t.data[size:]
+	return n
+}
+
+func (t *thawer) str() string {
+	n := t.uvarint()
+	if n > uint64(len(t.data)) {
+		t.err = ErrStorableImage
+		return ""
+	}
+	s := string(t.data[:n])
+	t.data = t.data[n:]
+	return s
+}
+
+func (t *thawer) value() *SV {
+	if t.err != nil {
+		return NewUndef()
+	}
+	switch t.byte() {
+	case stUndef:
+		return NewUndef()
+	case stInt:
+		n, size := binary.Varint(t.data)
+		if size <= 0 {
+			t.err = ErrStorableImage
+			return NewUndef()
+		}
+		t.data = t.data[size:]
+		return NewInt(n)
+	case stFloat:
+		if len(t.data) < 8 {
+			t.err = ErrStorableImage
+			return NewUndef()
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(t.data))
+		t.data = t.data[8:]
+		return NewFloat(f)
+	case stString:
+		return NewString(t.str())
+	case stRegex:
+		return NewRegexRef(t.str())
+	case stRef:
+		return NewRef(t.referent())
+	case stBlessed:
+		pkg := t.str()
+		return NewRef(t.referent()).BlessWith(pkg, t.ov)
+	}
+	t.err = ErrStorableImage
+	return NewUndef()
+}
+
+// referent reads what a reference points to; it is registered before its
+// contents so that a cycle back to it finds it
+func (t *thawer) referent() *SV {
+	switch t.byte() {
+	case stSeen:
+		if n := t.uvarint(); n < uint64(len(t.seen)) {
+			return t.seen[n]
+		}
+	case stArray:
+		av := NewArraySV()
+		t.seen = append(t.seen, av)
+		n := t.uvarint()
+		for ; n > 0 && t.err == nil; n-- {
+			av.av = append(av.av, t.value())
+		}
+		return av
+	case stHash:
+		hv := NewHashRef().rv
+		t.seen = append(t.seen, hv)
+		n := t.uvarint()
+		for ; n > 0 && t.err == nil; n-- {
+			k := t.str()
+			hv.hv[k] = t.value()
+		}
+		return hv
+	case stScalar:
+		sc := NewUndef()
+		t.seen = append(t.seen, sc)
+		sc.CopyFrom(t.value())
+		return sc
+	}
+	t.err = ErrStorableImage
+	return NewUndef()
+}
@@ -0,0 +1,57 @@
+package sv
+
+import "testing"
+
+func TestFreezeThaw(t *testing.T) {
+	shared := NewArrayRef(NewInt(1), NewFloat(2.5))
+	obj := NewHashRef()
+	obj.Deref().HashData()["name"] = NewString("x")
+	obj.Deref().HashData()["list"] = shared
+	obj.Deref().HashData()["again"] = shared
+	obj.Deref().HashData()["self"] = obj
+	obj.Deref().HashData()["none"] = NewUndef()
+	obj.Bless("Point")
+
+	data, err := Freeze(obj)
+	if err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	got, err := Thaw(data, nil)
+	if err != nil {
+		t.Fatalf("Thaw: %v", err)
+	}
+	h := got.Deref().HashData()
+	if got.Package() != "Point" || h["name"].AsString() != "x" || !h["none"].IsUndef() {
+		t.Errorf("thawed %s name=%q", got.Package(), h["name"].AsString())
+	}
+	list := h["list"].Deref()
+	if list == shared.Deref() || list.ArrayData()[1].AsFloat() != 2.5 {
+		t.Errorf("list not copied: %v", list.ArrayData())
+	}
+	if h["again"].Deref() != list {
+		t.Errorf("shared array thawed as two arrays")
+	}
+	if h["self"].Deref() != got.Deref() || h["self"].Package() != "Point" {
+		t.Errorf("cycle not restored")
+	}
+
+	// A reference to a reference, and a qr//
+	ref, _ := Freeze(NewRef(NewRef(NewString("deep"))))
+	if v, _ := Thaw(ref, nil); v.Deref().Deref().AsString() != "deep" {
+		t.Errorf("ref to ref: got %v", v)
+	}
+	re, _ := Freeze(NewRegexRef("(?i:a.b)"))
+	if v, _ := Thaw(re, nil); !v.IsRef() || v.Deref().Type() != TypeRegex {
+		t.Errorf("qr//: got %v", v)
+	}
+
+	if _, err := Freeze(NewArrayRef(NewCodeRef("main::f"))); err == nil || err.Error() != "Can't store CODE items" {
+		t.Errorf("code ref: err = %v", err)
+	}
+	if _, err := Thaw([]byte("garbage"), nil); err != ErrStorableImage {
+		t.Errorf("garbage: err = %v", err)
+	}
+	if _, err := Thaw(data[:len(data)-1], nil); err != ErrStorableImage {
+		t.Errorf("truncated: err = %v", err)
+	}
+}
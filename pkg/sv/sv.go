@@ -10,6 +10,9 @@ import (
 	"sync/atomic"
 	"unicode/utf8"
 	"unsafe"
+	"weak"
+
+	"perlc/pkg/perlstr"
 )
 
 // Type represents the primary type of an SV
@@ -43,6 +46,7 @@ const (
 	FlagBless                   // Blessed into a package
 	FlagWeak                    // Weak reference
 	FlagTied                    // Tied variable
+	FlagUV                      // The integer is unsigned, past int64 (perl's IsUV)
 )
 
 // SV is the core scalar value type, similar to Perl's internal SV structure.
@@ -59,12 +63,14 @@ type SV struct {
 	pvUTF8 bool    // pv contains valid UTF-8
 
 	// For references and complex types
-	rv *SV            // Referenced SV (when TypeRef)
-	av []*SV          // Array storage (when TypeArray)
-	hv map[string]*SV // Hash storage (when TypeHash)
+	rv *SV              // Referenced SV (when TypeRef)
+	wv weak.Pointer[SV] // Referenced SV of a weak reference (FlagWeak), see Weaken
+	av []*SV            // Array storage (when TypeArray)
+	hv map[string]*SV   // Hash storage (when TypeHash)
 
 	// For blessed references
-	stash string // Package name if blessed
+	stash string     // Package name if blessed
+	ov    Overloader // Operators of the package (use overload), see BlessWith
 
 	// For tied variables
 	tiedObj *SV // The tie object
@@ -101,6 +107,16 @@ func NewInt(v int64) *SV {
 	}
 }
 
+// NewUint creates an integer SV of u; past int64 it is one of perl's
+// unsigned integers
+func NewUint(u uint64) *SV {
+	v := NewInt(int64(u))
+	if u > math.MaxInt64 {
+		v.flags |= FlagUV
+	}
+	return v
+}
+
 // NewFloat creates a float SV
 func NewFloat(v float64) *SV {
 	return &SV{
@@ -111,6 +127,21 @@ func NewFloat(v float64) *SV {
 	}
 }
 
+// NewDualvar creates a value that is num as a number and str as a string
+// (Scalar::Util::dualvar)
+func NewDualvar(num *SV, str string) *SV {
+	v := NewString(str)
+	v.flags |= FlagIOK | FlagNOK
+	if num.Type() == TypeInt || num.Type() == TypeString && ParseNumber(num.AsString()).IsInt {
+		v.iv = num.AsInt()
+		v.nv = float64(v.iv)
+	} else {
+		v.nv = num.AsFloat()
+		v.iv = int64(v.nv)
+	}
+	return v
+}
+
 // NewString creates a string SV
 func NewString(v string) *SV {
 	flags := FlagPOK
@@ -165,6 +196,51 @@ func NewHashRef() *SV {
 	return NewRef(hv)
 }
 
+// NewCodeRef creates a reference to a subroutine known by name (\&name, sub {...})
+func NewCodeRef(name string) *SV {
+	cv := &SV{
+		typ:    TypeCode,
+		refcnt: 1,
+		pv:     name,
+	}
+	return NewRef(cv)
+}
+
+// NewRegexRef creates a qr// value: a reference to a compiled pattern.
+// The pattern is kept in "(?flags:...)" form so it can be interpolated into
+// other patterns.
+func NewRegexRef(pattern string) *SV {
+	rx := &SV{
+		typ:    TypeRegex,
+		refcnt: 1,
+		pv:     pattern,
+	}
+	return NewRef(rx)
+}
+
+// NewGlobRef creates a reference to a new anonymous glob, the lexical
+// filehandle of open(my $fh, ...) and Symbol::gensym. It stringifies as
+// GLOB(0x...), which also names the handle.
+func NewGlobRef() *SV {
+	gv := &SV{
+		typ:    TypeGlob,
+		refcnt: 1,
+	}
+	return NewRef(gv)
+}
+
+// NewGlob creates the glob *main::name, as *STDOUT; it and a reference
+// to it name the filehandle name (GlobName)
+func NewGlob(name string) *SV {
+	return &SV{
+		typ:    TypeGlob,
+		flags:  FlagPOK | FlagUTF8,
+		refcnt: 1,
+		pv:     "*main::" + name,
+		pvUTF8: true,
+	}
+}
+
 // NewArraySV creates a new array (not a reference)
 func NewArraySV(elements ...*SV) *SV {
 	av := &SV{
@@ -239,21 +315,108 @@ func (sv *SV) free() {
 // ============================================================
 
 func (sv *SV) Type() Type      { return sv.typ }
-func (sv *SV) IsUndef() bool   { return sv == nil || sv.typ == TypeUndef }
-func (sv *SV) IsRef() bool     { return sv != nil && sv.typ == TypeRef }
+func (sv *SV) IsUndef() bool   { return sv == nil || sv.typ == TypeUndef || sv.expired() }
+func (sv *SV) IsRef() bool     { return sv != nil && sv.typ == TypeRef && !sv.expired() }
 func (sv *SV) IsArray() bool   { return sv != nil && sv.typ == TypeArray }
 func (sv *SV) IsHash() bool    { return sv != nil && sv.typ == TypeHash }
 func (sv *SV) IsCode() bool    { return sv != nil && sv.typ == TypeCode }
 func (sv *SV) IsBlessed() bool { return sv != nil && sv.flags&FlagBless != 0 }
+func (sv *SV) IsGlobRef() bool {
+	return sv.IsRef() && sv.target() != nil && sv.target().typ == TypeGlob
+}
+
+// GlobName returns the handle name of a glob of NewGlob or a reference to
+// one: STDOUT for *STDOUT and \*STDOUT, "" for anything else
+func (sv *SV) GlobName() string {
+	g := sv
+	if sv.IsGlobRef() {
+		g = sv.target()
+	}
+	if g == nil || g.typ != TypeGlob {
+		return ""
+	}
+	return strings.TrimPrefix(g.pv, "*main::")
+}
+
+// CodeName returns the subroutine name behind a CODE value or reference
+func (sv *SV) CodeName() string {
+	if sv.IsRef() {
+		sv = sv.target()
+	}
+	if !sv.IsCode() {
+		return ""
+	}
+	return sv.pv
+}
+
+// RegexPattern returns the pattern behind a qr// value
+func (sv *SV) RegexPattern() (string, bool) {
+	if sv.IsRef() {
+		sv = sv.target()
+	}
+	if sv == nil || sv.typ != TypeRegex {
+		return "", false
+	}
+	return sv.pv, true
+}
 
 // Deref dereferences a reference, returns nil if not a ref
 func (sv *SV) Deref() *SV {
 	if sv == nil || sv.typ != TypeRef {
 		return nil
 	}
+	return sv.target()
+}
+
+// target is what a reference refers to; for a weak reference nil once
+// the referent is gone
+func (sv *SV) target() *SV {
+	if sv.flags&FlagWeak != 0 {
+		return sv.wv.Value()
+	}
 	return sv.rv
 }
 
+// expired reports a weak reference whose referent the garbage collector
+// has freed, and turns it into undef, as perl does
+func (sv *SV) expired() bool {
+	if sv.flags&FlagWeak == 0 || sv.wv.Value() != nil {
+		return false
+	}
+	sv.typ = TypeUndef
+	sv.flags = 0
+	sv.wv = weak.Pointer[SV]{}
+	return true
+}
+
+// Weaken makes a reference weak (Scalar::Util::weaken): it no longer keeps
+// its referent alive, so a circular structure linked back through it can
+// be collected. Once nothing else refers to the referent the reference
+// reads as undef. Copies of a weak reference are strong.
+func (sv *SV) Weaken() {
+	if sv == nil || sv.typ != TypeRef || sv.flags&FlagWeak != 0 || sv.rv == nil {
+		return
+	}
+	sv.wv = weak.Make(sv.rv)
+	sv.rv = nil
+	sv.flags |= FlagWeak
+}
+
+// Unweaken makes a weak reference strong again (Scalar::Util::unweaken)
+func (sv *SV) Unweaken() {
+	if sv == nil || sv.flags&FlagWeak == 0 {
+		return
+	}
+	sv.rv = sv.wv.Value()
+	sv.wv = weak.Pointer[SV]{}
+	sv.flags &^= FlagWeak
+}
+
+// IsWeak reports a weak reference (Scalar::Util::isweak)
+func (sv *SV) IsWeak() bool {
+	return sv != nil && sv.typ == TypeRef && sv.flags&FlagWeak != 0 && !sv.expired()
+}
+
 // ============================================================
 // Value Coercion - The Heart of Perl's Type System
 // ============================================================
@@ -279,12 +442,12 @@ func (sv *SV) AsInt() int64 {
 		sv.flags |= FlagIOK
 		return sv.iv
 	case TypeString:
-		sv.iv = stringToInt(sv.pv)
+		sv.iv = ParseNumber(sv.pv).IV
 		sv.flags |= FlagIOK
 		return sv.iv
 	case TypeRef:
 		// Reference as integer = memory address (we fake it)
-		return int64(uintptr(unsafe.Pointer(sv.rv)))
+		return int64(uintptr(unsafe.Pointer(sv.target())))
 	case TypeArray:
 		// Array in scalar context = length
 		return int64(len(sv.av))
@@ -311,14 +474,20 @@ func (sv *SV) AsFloat() float64 {
 		return 0.0
 	case TypeInt:
 		sv.nv = float64(sv.iv)
+		if sv.flags&FlagUV != 0 {
+			sv.nv = float64(uint64(sv.iv))
+		}
 		sv.flags |= FlagNOK
 		return sv.nv
 	case TypeFloat:
 		return sv.nv
 	case TypeString:
-		sv.nv = stringToFloat(sv.pv)
+		sv.nv = ParseNumber(sv.pv).NV
 		sv.flags |= FlagNOK
 		return sv.nv
+	case TypeRef:
+		// Reference as number = memory address, as in AsInt
+		return float64(uintptr(unsafe.Pointer(sv.target())))
 	default:
 		return 0.0
 	}
@@ -339,6 +508,9 @@ func (sv *SV) AsString() string {
 		return ""
 	case TypeInt:
 		sv.pv = strconv.FormatInt(sv.iv, 10)
+		if sv.flags&FlagUV != 0 {
+			sv.pv = strconv.FormatUint(uint64(sv.iv), 10)
+		}
 		sv.flags |= FlagPOK | FlagUTF8
 		sv.pvUTF8 = true
 		return sv.pv
@@ -362,7 +534,7 @@ func (sv *SV) AsString() string {
 
 // AsBool returns boolean value (Perl's SvTRUE)
 func (sv *SV) AsBool() bool {
-	if sv == nil || sv.typ == TypeUndef {
+	if sv == nil || sv.typ == TypeUndef || sv.expired() {
 		return false
 	}
 
@@ -386,16 +558,40 @@ func (sv *SV) AsBool() bool {
 	}
 }
 
+// LooksLikeNumber reports whether the value is a number or a string that
+// is one as a whole (Scalar::Util::looks_like_number); see ParseNumber for
+// the grammar. Undef and references are not numbers.
+func (sv *SV) LooksLikeNumber() bool {
+	if sv == nil {
+		return false
+	}
+	switch sv.typ {
+	case TypeInt, TypeFloat:
+		return true
+	case TypeString:
+		return LooksLikeNumber(sv.pv)
+	}
+	return false
+}
+
 // refString returns the string representation of a reference
 func (sv *SV) refString() string {
-	if sv.rv == nil {
+	target := sv.target()
+	if target == nil {
+		if sv.expired() {
+			return ""
+		}
 		return "REF(0x0)"
 	}
 
-	target := sv.rv
 	prefix := ""
 
 	if sv.flags&FlagBless != 0 {
+		if sv.ov != nil {
+			if s, ok := sv.ov.Overload(sv, `""`); ok {
+				return s.AsString()
+			}
+		}
 		prefix = sv.stash + "="
 	}
 
@@ -406,103 +602,23 @@ func (sv *SV) refString() string {
 		return fmt.Sprintf("%sHASH(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
 	case TypeCode:
 		return fmt.Sprintf("%sCODE(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
+	case TypeGlob:
+		return fmt.Sprintf("%sGLOB(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
+	case TypeRegex:
+		if prefix == "" {
+			return target.pv
+		}
+		return fmt.Sprintf("%sRegexp(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
+	case TypeRef:
+		return fmt.Sprintf("%sREF(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
 	default:
 		return fmt.Sprintf("%sSCALAR(0x%x)", prefix, uintptr(unsafe.Pointer(target)))
 	}
 }
 
-// ============================================================
-// String-to-Number Conversion (Perl semantics)
-// ============================================================
-
-// stringToInt converts string to int with Perl semantics
-// "42abc" -> 42, "abc" -> 0, "  123  " -> 123
-func stringToInt(s string) int64 {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0
-	}
-
-	// Find numeric prefix
-	end := 0
-	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
-		end = 1
-	}
-	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
-		end++
-	}
-
-	if end == 0 || (end == 1 && (s[0] == '-' || s[0] == '+')) {
-		return 0
-	}
-
-	v, _ := strconv.ParseInt(s[:end], 10, 64)
-	return v
-}
-
-// stringToFloat converts string to float with Perl semantics
-func stringToFloat(s string) float64 {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0.0
-	}
-
-	// Try parsing as float, accepting partial matches
-	// This is simplified - real Perl is more complex
-	end := 0
-	sawDot := false
-	sawE := false
-
-	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
-		end = 1
-	}
-
-	for end < len(s) {
-		c := s[end]
-		if c >= '0' && c <= '9' {
-			end++
-		} else if c == '.' && !sawDot && !sawE {
-			sawDot = true
-			end++
-		} else if (c == 'e' || c == 'E') && !sawE && end > 0 {
-			sawE = true
-			end++
-			if end < len(s) && (s[end] == '+' || s[end] == '-') {
-				end++
-			}
-		} else {
-			break
-		}
-	}
-
-	if end == 0 {
-		return 0.0
-	}
-
-	v, _ := strconv.ParseFloat(s[:end], 64)
-	return v
-}
-
 // formatFloat formats a float like Perl does
 func formatFloat(v float64) string {
-	if math.IsInf(v, 1) {
-		return "Inf"
-	}
-	if math.IsInf(v, -1) {
-		return "-Inf"
-	}
-	if math.IsNaN(v) {
-		return "NaN"
-	}
-
-	// If it's a whole number, format without decimal
-	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
-		return strconv.FormatInt(int64(v), 10)
-	}
-
-	// Otherwise use %g style formatting
-	s := strconv.FormatFloat(v, 'g', -1, 64)
-	return s
+	return perlstr.FormatFloat(v)
 }
 
 // ============================================================
@@ -556,6 +672,7 @@ func (sv *SV) SetUndef() {
 		sv.rv.DecRef()
 		sv.rv = nil
 	}
+	sv.wv = weak.Pointer[SV]{}
 }
 
 // SetRef sets as reference to target
@@ -567,6 +684,7 @@ func (sv *SV) SetRef(target *SV) {
 	sv.typ = TypeRef
 	sv.flags = FlagROK
 	sv.rv = target
+	sv.wv = weak.Pointer[SV]{}
 	if target != nil {
 		target.IncRef()
 	}
@@ -592,6 +710,21 @@ func (sv *SV) Bless(pkg string) *SV {
 	return sv
 }
 
+// Overloader resolves the overloaded operators of a package (use overload).
+// Overload returns the result of op ("\"\"" for stringification) on obj, or
+// false when the package does not overload it.
+type Overloader interface {
+	Overload(obj *SV, op string) (*SV, bool)
+}
+
+// BlessWith blesses the reference like Bless and makes ov answer for the
+// package's overloaded operators.
+func (sv *SV) BlessWith(pkg string, ov Overloader) *SV {
+	sv.Bless(pkg)
+	sv.ov = ov
+	return sv
+}
+
 // Package returns the package name if blessed, empty string otherwise
 func (sv *SV) Package() string {
 	if sv.flags&FlagBless == 0 {
@@ -657,18 +790,19 @@ func (sv *SV) Copy() *SV {
 
 	cp := &SV{
 		typ:    sv.typ,
-		flags:  sv.flags &^ (FlagRO | FlagTemp), // Clear RO and Temp
+		flags:  sv.flags &^ (FlagRO | FlagTemp | FlagWeak), // Clear RO and Temp; a copy is strong
 		refcnt: 1,
 		iv:     sv.iv,
 		nv:     sv.nv,
 		pv:     sv.pv,
 		pvUTF8: sv.pvUTF8,
 		stash:  sv.stash,
+		ov:     sv.ov,
 	}
 
 	// For refs, copy the reference (not deep copy)
-	if sv.rv != nil {
-		cp.rv = sv.rv
+	if target := sv.target(); target != nil {
+		cp.rv = target
 		cp.rv.IncRef()
 	}
 
@@ -687,23 +821,25 @@ func (sv *SV) CopyFrom(src *SV) {
 	sv.checkWritable()
 
 	sv.typ = src.typ
-	sv.flags = src.flags &^ (FlagRO | FlagTemp)
+	sv.flags = src.flags &^ (FlagRO | FlagTemp | FlagWeak)
 	sv.iv = src.iv
 	sv.nv = src.nv
 	sv.pv = src.pv
 	sv.pvUTF8 = src.pvUTF8
 	sv.stash = src.stash
+	sv.ov = src.ov
 
 	// Handle reference
 	if sv.rv != nil {
 		sv.rv.DecRef()
 	}
-	if src.rv != nil {
-		sv.rv = src.rv
+	if target := src.target(); target != nil {
+		sv.rv = target
 		sv.rv.IncRef()
 	} else {
 		sv.rv = nil
 	}
+	sv.wv = weak.Pointer[SV]{}
 
 	// Share array/hash data
 	sv.av = src.av
@@ -1,6 +1,10 @@
 package sv
 
 import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -84,6 +88,73 @@ func TestStringCoercion(t *testing.T) {
 	}
 }
 
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		iv    int64
+		nv    float64
+		isInt bool
+		whole bool
+	}{
+		{"42", 42, 42, true, true},
+		{" 12 \n", 12, 12, true, true},
+		{"-3e4", -3, -30000, false, true},
+		{".5", 0, 0.5, false, true},
+		{"1.", 1, 1, false, true},
+		{"12abc", 12, 12, true, false},
+		{"1e", 1, 1, true, false},
+		{"0x10", 0, 0, true, false},
+		{"1_000", 1, 1, true, false},
+		{"99999999999999999999", math.MaxInt64, 1e20, false, true},
+		{"", 0, 0, false, false},
+		{"+", 0, 0, false, false},
+		{".", 0, 0, false, false},
+		{"information", 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		n := ParseNumber(tt.input)
+		if n.IV != tt.iv || n.NV != tt.nv || n.IsInt != tt.isInt || n.Whole != tt.whole {
+			t.Errorf("ParseNumber(%q) = %+v, want iv %d nv %g int %v whole %v", tt.input, n, tt.iv, tt.nv, tt.isInt, tt.whole)
+		}
+	}
+	if n := ParseNumber("-Infinity "); !math.IsInf(n.NV, -1) || !n.Whole {
+		t.Errorf("ParseNumber(-Infinity) = %+v", n)
+	}
+	if n := ParseNumber("NaN"); !math.IsNaN(n.NV) || n.IV != 0 || !n.Whole {
+		t.Errorf("ParseNumber(NaN) = %+v", n)
+	}
+}
+
+func TestLooksLikeNumber(t *testing.T) {
+	for _, v := range []*SV{NewInt(1), NewFloat(0.5), NewString("1e5"), NewString(" -7\n"), NewString("inf")} {
+		if !v.LooksLikeNumber() {
+			t.Errorf("%q should look like a number", v.AsString())
+		}
+	}
+	for _, v := range []*SV{NewUndef(), NewString("12abc"), NewString(""), NewRef(NewInt(1)), nil} {
+		if v.LooksLikeNumber() {
+			t.Errorf("%q should not look like a number", v.AsString())
+		}
+	}
+	if inf := Add(NewString("Inf"), NewInt(1)); !math.IsInf(inf.AsFloat(), 1) {
+		t.Errorf("Inf + 1 = %s", inf.AsString())
+	}
+}
+
+func TestNotNumeric(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"42abc", `Argument "42abc" isn't numeric in addition (+)`},
+		{"a\tb\n\\\x00", `Argument "a^Ib\n\\\0" isn't numeric in addition (+)`},
+		{strings.Repeat("ab", 40), `Argument "` + strings.Repeat("ab", 28) + `..." isn't numeric in addition (+)`},
+		{strings.Repeat("x", 55) + "é!", `Argument "` + strings.Repeat("x", 55) + `é..." isn't numeric in addition (+)`},
+	}
+	for _, tt := range tests {
+		if got := NotNumeric(tt.in, "addition (+)"); got != tt.want {
+			t.Errorf("NotNumeric(%q) = %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
 func TestReferences(t *testing.T) {
 	// Scalar ref
 	scalar := NewInt(42)
@@ -103,6 +174,65 @@ func TestReferences(t *testing.T) {
 	if scalar.AsInt() != 100 {
 		t.Errorf("Original should be modified to 100, got %d", scalar.AsInt())
 	}
+
+	// A reference to a reference is a REF, and numifies to its address
+	refRef := NewRef(ref)
+	if got := refRef.RefType(); got != "REF" {
+		t.Errorf("RefType of a ref to a ref = %q, want REF", got)
+	}
+	if s := refRef.AsString(); !strings.HasPrefix(s, "REF(0x") || s != fmt.Sprintf("REF(0x%x)", refRef.AsInt()) {
+		t.Errorf("ref to a ref stringifies as %q, numifies as %d", s, refRef.AsInt())
+	}
+	if ref.AsFloat() != float64(ref.AsInt()) {
+		t.Errorf("AsFloat of a reference = %g, want the address %d", ref.AsFloat(), ref.AsInt())
+	}
+}
+
+func TestWeaken(t *testing.T) {
+	strong := NewHashRef()
+	weakRef := strong.Copy()
+	weakRef.Weaken()
+	if !weakRef.IsWeak() || strong.IsWeak() {
+		t.Fatalf("IsWeak: weakened %v, original %v", weakRef.IsWeak(), strong.IsWeak())
+	}
+	if weakRef.Deref() != strong.Deref() || weakRef.RefType() != "HASH" {
+		t.Errorf("a weak reference does not reach its referent")
+	}
+	if cp := weakRef.Copy(); cp.IsWeak() || cp.Deref() != strong.Deref() {
+		t.Errorf("a copy of a weak reference is not strong")
+	}
+
+	// once only the weak reference is left it becomes undef
+	strong.SetUndef()
+	for n := 0; n < 5 && !weakRef.IsUndef(); n++ {
+		runtime.GC()
+	}
+	if !weakRef.IsUndef() || weakRef.IsRef() || weakRef.AsString() != "" {
+		t.Errorf("weak reference to a freed hash = %q, want undef", weakRef.AsString())
+	}
+
+	kept := NewArrayRef()
+	back := kept.Copy()
+	back.Weaken()
+	back.Unweaken()
+	if back.IsWeak() || back.Deref() != kept.Deref() {
+		t.Errorf("Unweaken did not restore a strong reference")
+	}
+}
+
+func TestDualvar(t *testing.T) {
+	v := NewDualvar(NewInt(5), "five")
+	// looks_like_number goes by the string, as in perl
+	if v.AsInt() != 5 || v.AsString() != "five" || v.LooksLikeNumber() {
+		t.Errorf("dualvar(5, five) = %d, %q", v.AsInt(), v.AsString())
+	}
+	f := NewDualvar(NewFloat(1.5), "x")
+	if f.AsFloat() != 1.5 || f.AsString() != "x" {
+		t.Errorf("dualvar(1.5, x) = %g, %q", f.AsFloat(), f.AsString())
+	}
+	if c := v.Copy(); c.AsInt() != 5 || c.AsString() != "five" {
+		t.Errorf("copy of a dualvar = %d, %q", c.AsInt(), c.AsString())
+	}
 }
 
 func TestArrayRef(t *testing.T) {
@@ -151,6 +281,46 @@ func TestBlessing(t *testing.T) {
 	}
 }
 
+// stringOverload answers '""' with a fixed string
+type stringOverload string
+
+func (o stringOverload) Overload(obj *SV, op string) (*SV, bool) {
+	if op != `""` {
+		return nil, false
+	}
+	return NewString(string(o)), true
+}
+
+func TestBlessWith(t *testing.T) {
+	obj := NewHashRef().BlessWith("Point", stringOverload("(1, 2)"))
+	if s := obj.AsString(); s != "(1, 2)" {
+		t.Errorf("overloaded object = %q, want (1, 2)", s)
+	}
+	if s := obj.Copy().AsString(); s != "(1, 2)" {
+		t.Errorf("copy of overloaded object = %q, want (1, 2)", s)
+	}
+	plain := NewHashRef().BlessWith("Plain", nil)
+	if s := plain.AsString(); !strings.HasPrefix(s, "Plain=HASH(0x") {
+		t.Errorf("object without overload = %q", s)
+	}
+}
+
+func TestGlobRef(t *testing.T) {
+	fh := NewGlobRef()
+	if !fh.IsGlobRef() || NewHashRef().IsGlobRef() {
+		t.Error("IsGlobRef should be true only for glob references")
+	}
+	if s := fh.AsString(); !strings.HasPrefix(s, "GLOB(0x") {
+		t.Errorf("glob ref = %q, want GLOB(0x...)", s)
+	}
+	if s := fh.AsString(); s == NewGlobRef().AsString() {
+		t.Errorf("two globs print the same: %q", s)
+	}
+	if r := Ref(fh).AsString(); r != "GLOB" {
+		t.Errorf("ref = %q, want GLOB", r)
+	}
+}
+
 func TestRefCount(t *testing.T) {
 	sv := NewInt(42)
 	if sv.RefCount() != 1 {
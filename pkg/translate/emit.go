@@ -0,0 +1,1742 @@
+package translate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+)
+
+// value is a translated expression
+type value struct {
+	code string
+	typ  goType
+	prec int // precedence of its outermost Go operator
+}
+
+// operand is the precedence of a value that needs no parentheses
+const operand = 6
+
+var precedence = map[string]int{
+	"||": 1, "&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4, "*": 5, "/": 5, "%": 5,
+}
+
+// binary joins two values with a Go operator, parenthesizing them where
+// its precedence needs it
+func binary(l value, op string, r value, typ goType) value {
+	p := precedence[op]
+	lc, rc := l.code, r.code
+	if l.prec < p {
+		lc = "(" + lc + ")"
+	}
+	if r.prec < p || r.prec == p && op != "+" && op != "*" && op != "&&" && op != "||" {
+		rc = "(" + rc + ")"
+	}
+	return value{lc + " " + op + " " + rc, typ, p}
+}
+
+func paren(v value) string {
+	if v.prec < operand {
+		return "(" + v.code + ")"
+	}
+	return v.code
+}
+
+func operandOf(code string, typ goType) value { return value{code, typ, operand} }
+
+// isIntLiteral reports whether code is an integer constant, which Go also
+// takes as a float64
+func isIntLiteral(code string) bool {
+	_, err := strconv.Atoi(code)
+	return err == nil
+}
+
+func (t *translator) line(format string, args ...any) {
+	fmt.Fprintf(t.out, format+"\n", args...)
+}
+
+func (t *translator) unsupported(n ast.Node) {
+	src := n.String()
+	if len(src) > 60 {
+		src = src[:57] + "..."
+	}
+	t.fail(n, "cannot translate %s", src)
+}
+
+// known checks that the type of a variable was inferred
+func (t *translator) known(n ast.Node, v *variable) bool {
+	switch {
+	case v.typ.kind == unknown:
+		t.fail(n, "cannot infer the type of %s; it is never given a value", v.perl)
+	case (v.typ.kind == sliceT || v.typ.kind == mapT) && v.typ.elem == unknown:
+		t.fail(n, "cannot infer the element type of %s", v.perl)
+	default:
+		return true
+	}
+	return false
+}
+
+// emitProgram writes the package-level variables, main (with run when the
+// program can die) and a function for every sub
+func (t *translator) emitProgram(program *ast.Program) string {
+	var src strings.Builder
+	switch len(t.globals) {
+	case 0:
+	case 1:
+		if v := t.globals[0]; t.known(nil, v) {
+			fmt.Fprintf(&src, "var %s %s\n\n", v.goName, v.typ)
+		}
+	default:
+		src.WriteString("var (\n")
+		for _, v := range t.globals {
+			if t.known(nil, v) {
+				fmt.Fprintf(&src, "%s %s\n", v.goName, v.typ)
+			}
+		}
+		src.WriteString(")\n\n")
+	}
+
+	t.fileScope = map[string]*variable{}
+	t.scopes = []map[string]*variable{t.fileScope}
+	t.goNames = []map[string]bool{{}}
+	t.cur = t.main
+	var stmts []ast.Statement
+	for _, st := range program.Statements {
+		if _, ok := st.(*ast.SubDecl); !ok {
+			stmts = append(stmts, st)
+		}
+	}
+	body := t.emitBody(t.main, stmts)
+	if t.main.fails {
+		t.use("fmt")
+		t.use("os")
+		src.WriteString("func main() {\nif err := run(); err != nil {\nfmt.Fprintln(os.Stderr, err)\nos.Exit(1)\n}\n}\n\n")
+		src.WriteString("func run() error {\n" + body + "}\n")
+	} else {
+		src.WriteString("func main() {\n" + body + "}\n")
+	}
+
+	for _, fn := range t.subs {
+		t.cur = fn
+		t.scopes = []map[string]*variable{t.fileScope, {}}
+		t.goNames = []map[string]bool{{}, {}}
+		for _, p := range fn.params {
+			t.scopes[1][p.perl] = p
+		}
+		sig := t.signature(fn)
+		body := t.emitBody(fn, fn.body[fn.paramStmts:])
+		src.WriteString("\n" + sig + " {\n" + body + "}\n")
+	}
+	return src.String()
+}
+
+// signature is the Go signature of a sub: typed parameters, its value
+// and an error when it can fail
+func (t *translator) signature(fn *function) string {
+	var params []string
+	for i, p := range fn.params {
+		if p.typ.kind == unknown {
+			t.fail(fn.decl, "cannot infer the type of %s, a parameter of %s", p.perl, fn.perl)
+			return ""
+		}
+		if i+1 < len(fn.params) && fn.params[i+1].typ == p.typ {
+			params = append(params, p.goName)
+		} else {
+			params = append(params, p.goName+" "+p.typ.String())
+		}
+	}
+	result := ""
+	switch has := fn.result.typ.kind != unknown; {
+	case has && !t.known(fn.decl, fn.result):
+	case has && fn.fails:
+		result = " (" + fn.result.typ.String() + ", error)"
+	case has:
+		result = " " + fn.result.typ.String()
+	case fn.fails:
+		result = " error"
+	}
+	return "func " + fn.goName + "(" + strings.Join(params, ", ") + ")" + result
+}
+
+func (t *translator) emitBody(fn *function, stmts []ast.Statement) string {
+	t.out = &strings.Builder{}
+	if value := t.implicitReturn(fn); value != nil && fn.result.typ.kind != unknown {
+		t.block(stmts[:len(stmts)-1])
+		t.ret(value, value)
+	} else {
+		t.block(stmts)
+		if !terminates(stmts) && (fn == t.main && fn.fails || fn != t.main && (fn.fails || fn.result.typ.kind != unknown)) {
+			t.line("%s", t.returns("", ""))
+		}
+	}
+	body := t.out.String()
+	if fn.needErr {
+		body = "var err error\n" + body
+	}
+	return body
+}
+
+// implicitReturn is the last statement of a sub when perl returns its
+// value: an expression that is not only run for its effect
+func (t *translator) implicitReturn(fn *function) ast.Expression {
+	if fn == t.main || len(fn.body) <= fn.paramStmts {
+		return nil
+	}
+	es, ok := fn.body[len(fn.body)-1].(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	switch e := es.Expression.(type) {
+	case *ast.AssignExpr, *ast.PostfixExpr:
+		return nil
+	case *ast.PrefixExpr:
+		if e.Operator == "++" || e.Operator == "--" {
+			return nil
+		}
+	case *ast.CallExpr:
+		if id, ok := e.Function.(*ast.Identifier); ok {
+			switch id.Value {
+			case "print", "say", "printf", "push", "delete", "die", "exit":
+				return nil
+			}
+		}
+	}
+	return es.Expression
+}
+
+// terminates reports whether Go sees the statements end in a return
+func terminates(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch s := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := s.Expression.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Function.(*ast.Identifier)
+		return ok && id.Value == "die"
+	case *ast.BlockStmt:
+		return terminates(s.Statements)
+	case *ast.IfStmt:
+		if s.Else == nil || !terminates(s.Then.Statements) || !terminates(s.Else.Statements) {
+			return false
+		}
+		for _, e := range s.Elsif {
+			if !terminates(e.Body.Statements) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// returns is a return statement of the current function: its value, or
+// the zero value, and err, or nil
+func (t *translator) returns(val, err string) string {
+	fn := t.cur
+	var parts []string
+	if fn != t.main && fn.result.typ.kind != unknown {
+		if val == "" {
+			val = fn.result.typ.zero()
+		}
+		parts = append(parts, val)
+	}
+	if fn.fails {
+		if err == "" {
+			err = "nil"
+		}
+		parts = append(parts, err)
+	}
+	return strings.TrimSpace("return " + strings.Join(parts, ", "))
+}
+
+func (t *translator) checkErr() {
+	t.line("if err != nil {")
+	t.line("%s", t.returns("", "err"))
+	t.line("}")
+}
+
+// unused keeps Go from rejecting a local perl only stores into
+func (t *translator) unused(v *variable) {
+	if !v.read && !v.global {
+		t.line("_ = %s", v.goName)
+	}
+}
+
+func (t *translator) block(stmts []ast.Statement) {
+	for _, st := range stmts {
+		if t.err != nil {
+			return
+		}
+		t.stmt(st)
+	}
+}
+
+func (t *translator) scoped(b *ast.BlockStmt) {
+	t.pushScope()
+	t.block(b.Statements)
+	t.popScope()
+}
+
+func (t *translator) stmt(st ast.Statement) {
+	switch s := st.(type) {
+	case *ast.ExprStmt:
+		t.exprStmt(s.Expression)
+	case *ast.VarDecl:
+		t.varDecl(s)
+	case *ast.IfStmt:
+		t.line("if %s {", t.ifCondition(s.Condition, s.Unless))
+		t.scoped(s.Then)
+		for _, e := range s.Elsif {
+			t.line("} else if %s {", t.ifCondition(e.Condition, false))
+			t.scoped(e.Body)
+		}
+		if s.Else != nil {
+			t.line("} else {")
+			t.scoped(s.Else)
+		}
+		t.line("}")
+	case *ast.WhileStmt:
+		if s.Continue != nil {
+			t.fail(s, "continue blocks are not translated")
+			return
+		}
+		if lit, ok := s.Condition.(*ast.IntegerLiteral); ok && lit.Value != 0 && !s.Until {
+			t.line("for {")
+		} else {
+			t.line("for %s {", t.condition(s.Condition, s.Until))
+		}
+		t.scoped(s.Body)
+		t.line("}")
+	case *ast.ForStmt:
+		t.forStmt(s)
+	case *ast.ForeachStmt:
+		t.foreach(s)
+	case *ast.ReturnStmt:
+		t.ret(s, s.Value)
+	case *ast.LastStmt, *ast.NextStmt:
+		label, keyword := "", "break"
+		if l, ok := s.(*ast.LastStmt); ok {
+			label = l.Label
+		} else {
+			label, keyword = s.(*ast.NextStmt).Label, "continue"
+		}
+		if label != "" {
+			t.fail(s, "loop labels are not translated")
+			return
+		}
+		t.line(keyword)
+	case *ast.BlockStmt:
+		t.line("{")
+		t.scoped(s)
+		t.line("}")
+	case *ast.UseDecl:
+	default:
+		t.unsupported(st)
+	}
+}
+
+// condition is e as a Go condition, negated for unless and until
+func (t *translator) condition(e ast.Expression, negate bool) string {
+	if !negate {
+		return t.cond(e).code
+	}
+	switch v := e.(type) {
+	case *ast.InfixExpr:
+		if inv, ok := inverse[v.Operator]; ok {
+			c := *v
+			c.Operator = inv
+			return t.cond(&c).code
+		}
+	case *ast.PrefixExpr:
+		if v.Operator == "!" || v.Operator == "not" {
+			return t.cond(v.Right).code
+		}
+	}
+	return "!" + paren(t.cond(e))
+}
+
+var inverse = map[string]string{
+	"==": "!=", "!=": "==", "<": ">=", ">=": "<", ">": "<=", "<=": ">",
+	"eq": "ne", "ne": "eq", "lt": "ge", "ge": "lt", "gt": "le", "le": "gt",
+}
+
+// ifCondition is condition for an if, where exists $h{k} becomes the
+// comma-ok form
+func (t *translator) ifCondition(e ast.Expression, negate bool) string {
+	if p, ok := e.(*ast.PrefixExpr); ok && (p.Operator == "!" || p.Operator == "not") && isCall(p.Right, "exists") {
+		e, negate = p.Right, !negate
+	}
+	if !isCall(e, "exists") {
+		return t.condition(e, negate)
+	}
+	call := e.(*ast.CallExpr)
+	if len(call.Args) != 1 {
+		t.unsupported(e)
+		return ""
+	}
+	access, ok := call.Args[0].(*ast.HashAccess)
+	if !ok {
+		t.fail(e, "exists is translated only for hash elements")
+		return ""
+	}
+	h, key := t.hashAccess(access)
+	if negate {
+		return fmt.Sprintf("_, ok := %s[%s]; !ok", h, key)
+	}
+	return fmt.Sprintf("_, ok := %s[%s]; ok", h, key)
+}
+
+func isCall(e ast.Expression, name string) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := call.Function.(*ast.Identifier)
+	return ok && id.Value == name
+}
+
+// cond is e as a Go bool: numbers are true when not 0, strings when not
+// empty and arrays and hashes when they have elements
+func (t *translator) cond(e ast.Expression) value {
+	if in, ok := e.(*ast.InfixExpr); ok {
+		switch in.Operator {
+		case "&&", "and":
+			return binary(t.cond(in.Left), "&&", t.cond(in.Right), scalar(boolT))
+		case "||", "or":
+			return binary(t.cond(in.Left), "||", t.cond(in.Right), scalar(boolT))
+		}
+	}
+	v := t.expr(e)
+	b := scalar(boolT)
+	switch v.typ.kind {
+	case boolT:
+		return v
+	case intT, floatT:
+		return binary(v, "!=", operandOf("0", v.typ), b)
+	case stringT:
+		return binary(v, "!=", operandOf(`""`, v.typ), b)
+	case sliceT, mapT:
+		return binary(operandOf("len("+v.code+")", scalar(intT)), ">", operandOf("0", scalar(intT)), b)
+	}
+	if t.err == nil {
+		t.fail(e, "cannot infer the type of the condition %s", e.String())
+	}
+	return v
+}
+
+func (t *translator) exprStmt(e ast.Expression) {
+	if call, ok := e.(*ast.CallExpr); ok {
+		if id, ok := call.Function.(*ast.Identifier); ok {
+			switch id.Value {
+			case "print", "say", "printf":
+				t.print(call, id.Value)
+				return
+			case "die":
+				t.die(call)
+				return
+			case "exit":
+				code := "0"
+				if len(call.Args) > 0 {
+					code = t.exprAs(call.Args[0], scalar(intT)).code
+				}
+				t.use("os")
+				t.line("os.Exit(%s)", code)
+				return
+			case "push":
+				t.push(call)
+				return
+			case "delete":
+				access, ok := call.Args[0].(*ast.HashAccess)
+				if len(call.Args) != 1 || !ok {
+					t.fail(call, "delete is translated only for one hash element")
+					return
+				}
+				h, key := t.hashAccess(access)
+				t.line("delete(%s, %s)", h, key)
+				return
+			}
+			if fn := t.funcs[id.Value]; fn != nil {
+				code := t.userCall(call, fn)
+				if !fn.fails {
+					t.line("%s", code)
+					return
+				}
+				blank := ""
+				if fn.result.typ.kind != unknown {
+					blank = "_, "
+				}
+				t.line("if %serr := %s; err != nil {", blank, code)
+				t.line("%s", t.returns("", "err"))
+				t.line("}")
+				return
+			}
+		}
+	}
+	if a, ok := e.(*ast.AssignExpr); ok {
+		t.assignStmt(a)
+		return
+	}
+	if code, ok := t.simple(e); ok {
+		t.line("%s", code)
+	}
+}
+
+// simple is an assignment, ++ or -- as a Go simple statement, as the
+// post statement of a for loop takes it
+func (t *translator) simple(e ast.Expression) (string, bool) {
+	switch v := e.(type) {
+	case *ast.AssignExpr:
+		return t.assignCode(v)
+	case *ast.PostfixExpr:
+		return t.incDec(v, v.Left, v.Operator)
+	case *ast.PrefixExpr:
+		if v.Operator == "++" || v.Operator == "--" {
+			return t.incDec(v, v.Right, v.Operator)
+		}
+	}
+	if t.err == nil {
+		t.fail(e, "%s is only run for its effect, which is not translated", e.String())
+	}
+	return "", false
+}
+
+func (t *translator) incDec(n ast.Node, target ast.Expression, op string) (string, bool) {
+	lv := t.lvalue(target)
+	if t.err != nil {
+		return "", false
+	}
+	if !numeric(lv.typ.kind) {
+		t.fail(n, "%s of a %s", op, lv.typ)
+		return "", false
+	}
+	return lv.code + op, true
+}
+
+// lvalue is a variable or element stored into
+func (t *translator) lvalue(e ast.Expression) value {
+	switch e.(type) {
+	case *ast.ScalarVar, *ast.ArrayVar, *ast.HashVar, *ast.ArrayAccess, *ast.HashAccess:
+		if v, ok := e.(*ast.ArrayVar); ok && v.Name == "ARGV" {
+			break
+		}
+		return t.expr(e)
+	}
+	t.fail(e, "cannot assign to %s", e.String())
+	return value{}
+}
+
+// failing is the sub a call calls if that can fail
+func (t *translator) failing(e ast.Expression) *function {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if fn := t.funcs[id.Value]; fn != nil && fn.fails {
+		return fn
+	}
+	return nil
+}
+
+func (t *translator) assignStmt(a *ast.AssignExpr) {
+	if a.Operator == "=" {
+		if tern, ok := a.Right.(*ast.TernaryExpr); ok {
+			lv := t.lvalue(a.Left)
+			c := t.cond(tern.Condition).code
+			then := t.scalarValue(tern.Then, lv.typ).code
+			els := t.scalarValue(tern.Else, lv.typ).code
+			t.line("if %s {", c)
+			t.line("%s = %s", lv.code, then)
+			t.line("} else {")
+			t.line("%s = %s", lv.code, els)
+			t.line("}")
+			return
+		}
+		if fn := t.failing(a.Right); fn != nil {
+			lv := t.lvalue(a.Left)
+			if lv.typ != fn.result.typ {
+				t.fail(a, "%s is a %s but %s returns a %s", a.Left.String(), lv.typ, fn.perl, fn.result.typ)
+				return
+			}
+			t.cur.needErr = true
+			t.line("%s, err = %s", lv.code, t.userCall(a.Right.(*ast.CallExpr), fn))
+			t.checkErr()
+			return
+		}
+	}
+	if code, ok := t.assignCode(a); ok {
+		t.line("%s", code)
+	}
+}
+
+func (t *translator) assignCode(a *ast.AssignExpr) (string, bool) {
+	lv := t.lvalue(a.Left)
+	if t.err != nil {
+		return "", false
+	}
+	switch lv.typ.kind {
+	case sliceT:
+		if a.Operator != "=" {
+			break
+		}
+		return lv.code + " = " + t.listValue(a.Right, lv.typ).code, t.err == nil
+	case mapT:
+		if a.Operator != "=" {
+			break
+		}
+		return lv.code + " = " + t.mapValue(a.Right, lv.typ), t.err == nil
+	}
+	var code string
+	switch op := a.Operator; op {
+	case "=":
+		code = lv.code + " = " + t.scalarValue(a.Right, lv.typ).code
+	case "+=", "-=", "*=", "/=", "%=":
+		r := t.exprAs(a.Right, lv.typ)
+		switch {
+		case !numeric(lv.typ.kind):
+			t.fail(a, "%s on a %s", op, lv.typ)
+		case op == "/=" && lv.typ.kind != floatT:
+			t.fail(a, "%s is an int; /= would make it a float64", a.Left.String())
+		case op == "%=" && lv.typ.kind != intT:
+			t.fail(a, "%%= on a %s", lv.typ)
+		case (op == "+=" || op == "-=") && r.code == "1":
+			code = lv.code + op[:1] + op[:1]
+		default:
+			code = lv.code + " " + op + " " + r.code
+		}
+	case ".=":
+		if lv.typ.kind != stringT {
+			t.fail(a, ".= on a %s", lv.typ)
+		}
+		code = lv.code + " += " + t.str(a.Right).code
+	case "x=":
+		t.use("strings")
+		code = lv.code + " = strings.Repeat(" + lv.code + ", " + t.exprAs(a.Right, scalar(intT)).code + ")"
+	case "**=":
+		if lv.typ.kind != floatT {
+			t.fail(a, "%s is an int; **= would make it a float64", a.Left.String())
+		}
+		t.use("math")
+		code = lv.code + " = math.Pow(" + lv.code + ", " + t.exprAs(a.Right, scalar(floatT)).code + ")"
+	default:
+		t.fail(a, "%s is not translated", op)
+	}
+	return code, t.err == nil
+}
+
+// scalarValue is the value of e stored in a scalar of type want: an
+// array there is its length
+func (t *translator) scalarValue(e ast.Expression, want goType) value {
+	if arr, ok := e.(*ast.ArrayVar); ok {
+		v := t.expr(arr)
+		return t.convert(e, operandOf("len("+v.code+")", scalar(intT)), want)
+	}
+	return t.exprAs(e, want)
+}
+
+// listValue is the value of e stored in an array
+func (t *translator) listValue(e ast.Expression, want goType) value {
+	switch v := e.(type) {
+	case *ast.ArrayExpr:
+		elems := make([]string, len(v.Elements))
+		for i, el := range v.Elements {
+			elems[i] = t.exprAs(el, scalar(want.elem)).code
+		}
+		return operandOf(want.String()+"{"+strings.Join(elems, ", ")+"}", want)
+	case *ast.ArrayVar:
+		if v.Name != "ARGV" {
+			t.use("slices")
+			return operandOf("slices.Clone("+t.expr(v).code+")", want)
+		}
+	case *ast.RangeExpr:
+		t.fail(e, "a range as a list is not translated; use a for loop")
+		return value{}
+	}
+	v := t.expr(e)
+	if v.typ.kind != sliceT && v.typ.kind != mapT && t.err == nil {
+		v = t.convert(e, v, scalar(want.elem))
+		return operandOf(want.String()+"{"+v.code+"}", want)
+	}
+	return t.convert(e, v, want)
+}
+
+// mapValue is the value of e stored in a hash: a list of pairs or a copy
+func (t *translator) mapValue(e ast.Expression, want goType) string {
+	switch v := e.(type) {
+	case nil:
+		return want.String() + "{}"
+	case *ast.ArrayExpr:
+		if len(v.Elements)%2 != 0 {
+			t.fail(e, "a hash is given an odd number of elements")
+			return ""
+		}
+		if len(v.Elements) == 0 {
+			return want.String() + "{}"
+		}
+		var b strings.Builder
+		b.WriteString(want.String() + "{\n")
+		for i := 0; i < len(v.Elements); i += 2 {
+			key := t.str(v.Elements[i]).code
+			val := t.exprAs(v.Elements[i+1], scalar(want.elem)).code
+			fmt.Fprintf(&b, "%s: %s,\n", key, val)
+		}
+		b.WriteString("}")
+		return b.String()
+	case *ast.HashVar:
+		t.use("maps")
+		return "maps.Clone(" + t.expr(v).code + ")"
+	}
+	t.fail(e, "a hash is translated only from a list of pairs or another hash")
+	return ""
+}
+
+func (t *translator) varDecl(d *ast.VarDecl) {
+	if d.Kind != "my" {
+		t.fail(d, "%s variables are not translated; use my", d.Kind)
+		return
+	}
+	if d.IsList && len(d.Names) > 1 {
+		t.listDecl(d)
+		return
+	}
+	if len(d.Names) != 1 {
+		t.unsupported(d)
+		return
+	}
+	switch n := d.Names[0].(type) {
+	case *ast.ScalarVar:
+		t.scalarDecl(d, t.decls[declKey{d, "$" + n.Name}])
+	case *ast.ArrayVar:
+		v := t.decls[declKey{d, "@" + n.Name}]
+		if !t.known(d, v) {
+			return
+		}
+		var val string
+		if list, ok := d.Value.(*ast.ArrayExpr); ok && len(list.Elements) == 0 || d.Value == nil {
+			val = "nil"
+		} else {
+			val = t.listValue(d.Value, v.typ).code
+		}
+		t.declare(d, v.perl)
+		switch {
+		case v.global:
+			t.line("%s = %s", v.goName, val)
+		case val == "nil":
+			t.line("var %s %s", v.goName, v.typ)
+		default:
+			t.line("%s := %s", v.goName, val)
+		}
+		t.unused(v)
+	case *ast.HashVar:
+		v := t.decls[declKey{d, "%" + n.Name}]
+		if !t.known(d, v) {
+			return
+		}
+		val := t.mapValue(d.Value, v.typ)
+		t.declare(d, v.perl)
+		if v.global {
+			t.line("%s = %s", v.goName, val)
+		} else {
+			t.line("%s := %s", v.goName, val)
+		}
+		t.unused(v)
+	default:
+		t.unsupported(d)
+	}
+}
+
+func (t *translator) scalarDecl(d *ast.VarDecl, v *variable) {
+	if !t.known(d, v) {
+		return
+	}
+	name := v.goName
+	if fn := t.failing(d.Value); fn != nil {
+		if fn.result.typ != v.typ {
+			t.fail(d, "%s is a %s but %s returns a %s", v.perl, v.typ, fn.perl, fn.result.typ)
+			return
+		}
+		call := t.userCall(d.Value.(*ast.CallExpr), fn)
+		t.declare(d, v.perl)
+		if v.global {
+			t.cur.needErr = true
+			t.line("%s, err = %s", name, call)
+		} else {
+			t.line("%s, err := %s", name, call)
+		}
+		t.checkErr()
+		t.unused(v)
+		return
+	}
+	switch value := d.Value.(type) {
+	case nil:
+		t.declare(d, v.perl)
+		if v.global {
+			t.line("%s = %s", name, v.typ.zero())
+		} else {
+			t.line("var %s %s", name, v.typ)
+		}
+	case *ast.TernaryExpr:
+		c := t.cond(value.Condition).code
+		then := t.scalarValue(value.Then, v.typ).code
+		els := t.scalarValue(value.Else, v.typ).code
+		t.declare(d, v.perl)
+		if !v.global {
+			t.line("var %s %s", name, v.typ)
+		}
+		t.line("if %s {", c)
+		t.line("%s = %s", name, then)
+		t.line("} else {")
+		t.line("%s = %s", name, els)
+		t.line("}")
+	default:
+		code := t.scalarValue(value, v.typ).code
+		if v.typ.kind == floatT && isIntLiteral(code) {
+			code += ".0"
+		}
+		t.declare(d, v.perl)
+		if v.global {
+			t.line("%s = %s", name, code)
+		} else {
+			t.line("%s := %s", name, code)
+		}
+	}
+	t.unused(v)
+}
+
+// listDecl is my ($a, $b) = ($x, $y);
+func (t *translator) listDecl(d *ast.VarDecl) {
+	list, ok := d.Value.(*ast.ArrayExpr)
+	if d.Value != nil && (!ok || len(list.Elements) != len(d.Names)) {
+		t.fail(d, "my (...) is translated only with a list of as many values")
+		return
+	}
+	vars := make([]*variable, len(d.Names))
+	names := make([]string, len(d.Names))
+	var vals []string
+	for i, n := range d.Names {
+		sv, ok := n.(*ast.ScalarVar)
+		if !ok {
+			t.fail(d, "my (...) is translated only for scalars")
+			return
+		}
+		vars[i] = t.decls[declKey{d, "$" + sv.Name}]
+		if !t.known(d, vars[i]) {
+			return
+		}
+		names[i] = vars[i].goName
+		if list != nil {
+			code := t.scalarValue(list.Elements[i], vars[i].typ).code
+			if vars[i].typ.kind == floatT && isIntLiteral(code) {
+				code += ".0"
+			}
+			vals = append(vals, code)
+		}
+	}
+	for _, v := range vars {
+		t.declare(d, v.perl)
+	}
+	switch {
+	case list != nil && vars[0].global:
+		t.line("%s = %s", strings.Join(names, ", "), strings.Join(vals, ", "))
+	case list != nil:
+		t.line("%s := %s", strings.Join(names, ", "), strings.Join(vals, ", "))
+	case !vars[0].global:
+		for _, v := range vars {
+			t.line("var %s %s", v.goName, v.typ)
+		}
+	}
+	for _, v := range vars {
+		t.unused(v)
+	}
+}
+
+func (t *translator) forStmt(s *ast.ForStmt) {
+	t.pushScope()
+	defer t.popScope()
+	init := ""
+	switch in := s.Init.(type) {
+	case nil:
+	case *ast.VarDecl:
+		sv, ok := in.Names[0].(*ast.ScalarVar)
+		if len(in.Names) != 1 || !ok || in.Value == nil || in.Kind != "my" {
+			t.unsupported(in)
+			return
+		}
+		v := t.decls[declKey{in, "$" + sv.Name}]
+		if !t.known(in, v) {
+			return
+		}
+		code := t.scalarValue(in.Value, v.typ).code
+		if v.typ.kind == floatT && isIntLiteral(code) {
+			code += ".0"
+		}
+		t.declare(in, v.perl)
+		init = v.goName + " := " + code
+	case *ast.ExprStmt:
+		init, _ = t.simple(in.Expression)
+	default:
+		t.unsupported(in)
+	}
+	cond := ""
+	if s.Condition != nil {
+		cond = t.cond(s.Condition).code
+	}
+	post := ""
+	if s.Post != nil {
+		post, _ = t.simple(s.Post)
+	}
+	if init == "" && post == "" {
+		t.line("for %s {", cond)
+	} else {
+		t.line("for %s; %s; %s {", init, cond, post)
+	}
+	t.block(s.Body.Statements)
+	t.line("}")
+}
+
+func (t *translator) foreach(s *ast.ForeachStmt) {
+	if s.Continue != nil {
+		t.fail(s, "continue blocks are not translated")
+		return
+	}
+	sv, ok := s.Variable.(*ast.ScalarVar)
+	if !ok {
+		t.fail(s, "foreach is translated only with a my variable")
+		return
+	}
+	v := t.decls[declKey{s, "$" + sv.Name}]
+	if v == nil || !t.known(s, v) {
+		return
+	}
+	name := v.goName
+	var header string
+	switch list := s.List.(type) {
+	case *ast.RangeExpr:
+		start := t.exprAs(list.Start, scalar(intT)).code
+		end := t.exprAs(list.End, scalar(intT)).code
+		header = fmt.Sprintf("for %s := %s; %s <= %s; %s++", name, start, name, end, name)
+	default:
+		var over string
+		if isCall(list, "keys") && len(list.(*ast.CallExpr).Args) == 1 {
+			over = t.expr(list.(*ast.CallExpr).Args[0]).code
+			header = "for " + name + " := range " + over
+			if !v.read {
+				header = "for range " + over
+			}
+			break
+		}
+		if _, ok := list.(*ast.ArrayExpr); ok {
+			over = t.listValue(list, goType{kind: sliceT, elem: v.typ.kind}).code
+		} else {
+			val := t.expr(list)
+			if val.typ.kind != sliceT && t.err == nil {
+				t.fail(s, "foreach over a %s", val.typ)
+			}
+			over = val.code
+		}
+		header = "for _, " + name + " := range " + over
+		if !v.read {
+			header = "for range " + over
+		}
+	}
+	t.pushScope()
+	t.declare(s, v.perl)
+	t.line("%s {", header)
+	t.block(s.Body.Statements)
+	t.popScope()
+	t.line("}")
+}
+
+func (t *translator) ret(node ast.Node, e ast.Expression) {
+	fn := t.cur
+	if e == nil {
+		t.line("%s", t.returns("", ""))
+		return
+	}
+	if fn == t.main {
+		t.fail(node, "return with a value outside a sub")
+		return
+	}
+	if tern, ok := e.(*ast.TernaryExpr); ok {
+		t.line("if %s {", t.cond(tern.Condition).code)
+		t.ret(node, tern.Then)
+		t.line("}")
+		t.ret(node, tern.Else)
+		return
+	}
+	if callee := t.failing(e); callee != nil {
+		if callee.result.typ != fn.result.typ {
+			t.fail(node, "%s returns a %s but %s a %s", fn.perl, fn.result.typ, callee.perl, callee.result.typ)
+			return
+		}
+		t.line("return %s", t.userCall(e.(*ast.CallExpr), callee))
+		return
+	}
+	var v value
+	if fn.result.typ.kind == sliceT {
+		v = t.listValue(e, fn.result.typ)
+	} else {
+		v = t.scalarValue(e, fn.result.typ)
+	}
+	t.line("%s", t.returns(v.code, ""))
+}
+
+func (t *translator) push(call *ast.CallExpr) {
+	arr, ok := call.Args[0].(*ast.ArrayVar)
+	if !ok || arr.Name == "ARGV" {
+		t.fail(call, "push is translated only onto a my array")
+		return
+	}
+	target := t.expr(arr)
+	a := target.code
+	var items []string
+	flush := func() {
+		if len(items) > 0 {
+			t.line("%s = append(%s, %s)", a, a, strings.Join(items, ", "))
+			items = nil
+		}
+	}
+	for _, el := range call.Args[1:] {
+		v := t.expr(el)
+		if v.typ.kind == sliceT {
+			flush()
+			t.line("%s = append(%s, %s...)", a, a, v.code)
+			continue
+		}
+		items = append(items, t.convert(el, v, scalar(target.typ.elem)).code)
+	}
+	flush()
+}
+
+func (t *translator) userCall(call *ast.CallExpr, fn *function) string {
+	args := make([]string, len(call.Args))
+	for i, a := range call.Args {
+		args[i] = t.exprAs(a, fn.params[i].typ).code
+	}
+	return fn.goName + "(" + strings.Join(args, ", ") + ")"
+}
+
+// exprAs is e converted to want: an int where a float64 is expected
+func (t *translator) exprAs(e ast.Expression, want goType) value {
+	return t.convert(e, t.expr(e), want)
+}
+
+func (t *translator) convert(n ast.Node, v value, want goType) value {
+	if t.err != nil || want.kind == unknown || v.typ == want {
+		return v
+	}
+	if want.kind == floatT && v.typ.kind == intT {
+		return t.toFloat(v)
+	}
+	t.fail(n, "%s is a %s where a %s is expected", n.String(), v.typ, want)
+	return v
+}
+
+func (t *translator) toFloat(v value) value {
+	if isIntLiteral(v.code) {
+		return operandOf(v.code, scalar(floatT))
+	}
+	return operandOf("float64("+v.code+")", scalar(floatT))
+}
+
+// str is e as a string: numbers go through strconv
+func (t *translator) str(e ast.Expression) value {
+	if lit, ok := e.(*ast.IntegerLiteral); ok {
+		return operandOf(strconv.Quote(strconv.FormatInt(lit.Value, 10)), scalar(stringT))
+	}
+	v := t.expr(e)
+	switch v.typ.kind {
+	case stringT:
+		return v
+	case intT:
+		t.use("strconv")
+		return operandOf("strconv.Itoa("+v.code+")", scalar(stringT))
+	case floatT:
+		t.use("strconv")
+		return operandOf("strconv.FormatFloat("+v.code+", 'g', 15, 64)", scalar(stringT))
+	}
+	if t.err == nil {
+		t.fail(e, "%s is a %s where a string is expected", e.String(), v.typ)
+	}
+	return v
+}
+
+func (t *translator) expr(e ast.Expression) value {
+	if t.err != nil {
+		return value{}
+	}
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		return operandOf(strconv.FormatInt(v.Value, 10), scalar(intT))
+	case *ast.FloatLiteral:
+		s := strconv.FormatFloat(v.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return operandOf(s, scalar(floatT))
+	case *ast.StringLiteral:
+		return t.stringValue(v)
+	case *ast.ScalarVar:
+		if r := t.lookup(v, "$"+v.Name); r != nil {
+			return operandOf(r.goName, r.typ)
+		}
+		return value{}
+	case *ast.ArrayVar:
+		if v.Name == "ARGV" {
+			t.use("os")
+			return operandOf("os.Args[1:]", goType{kind: sliceT, elem: stringT})
+		}
+		if r := t.lookup(v, "@"+v.Name); r != nil {
+			return operandOf(r.goName, r.typ)
+		}
+		return value{}
+	case *ast.HashVar:
+		if r := t.lookup(v, "%"+v.Name); r != nil {
+			return operandOf(r.goName, r.typ)
+		}
+		return value{}
+	case *ast.ArrayLengthVar:
+		if r := t.lookup(v, "@"+v.Name); r != nil {
+			n := operandOf("len("+r.goName+")", scalar(intT))
+			return binary(n, "-", operandOf("1", scalar(intT)), scalar(intT))
+		}
+		return value{}
+	case *ast.ArrayAccess:
+		return t.arrayAccess(v)
+	case *ast.HashAccess:
+		h, key := t.hashAccess(v)
+		if r := t.refs[v.Hash]; r != nil {
+			return operandOf(h+"["+key+"]", scalar(r.typ.elem))
+		}
+		return value{}
+	case *ast.PrefixExpr:
+		switch v.Operator {
+		case "-":
+			r := t.expr(v.Right)
+			if !numeric(r.typ.kind) && t.err == nil {
+				t.fail(v, "- of a %s", r.typ)
+			}
+			code := paren(r)
+			if strings.HasPrefix(code, "-") {
+				code = "(" + code + ")"
+			}
+			return operandOf("-"+code, r.typ)
+		case "+":
+			return t.expr(v.Right)
+		case "!", "not":
+			return operandOf("!"+paren(t.cond(v.Right)), scalar(boolT))
+		}
+	case *ast.InfixExpr:
+		return t.infix(v)
+	case *ast.CallExpr:
+		return t.callValue(v)
+	case *ast.TernaryExpr:
+		t.fail(v, "?: is translated only as the whole value of my, an assignment or return")
+		return value{}
+	}
+	t.unsupported(e)
+	return value{}
+}
+
+func (t *translator) arrayAccess(v *ast.ArrayAccess) value {
+	sv, ok := v.Array.(*ast.ScalarVar)
+	if !ok {
+		t.unsupported(v)
+		return value{}
+	}
+	if sv.Name == "ARGV" {
+		t.use("os")
+		idx := t.exprAs(v.Index, scalar(intT))
+		if n, err := strconv.Atoi(idx.code); err == nil {
+			return operandOf(fmt.Sprintf("os.Args[%d]", n+1), scalar(stringT))
+		}
+		return operandOf("os.Args["+binary(idx, "+", operandOf("1", scalar(intT)), scalar(intT)).code+"]", scalar(stringT))
+	}
+	r := t.lookup(sv, "@"+sv.Name)
+	if r == nil {
+		return value{}
+	}
+	idx := t.exprAs(v.Index, scalar(intT))
+	if p, ok := v.Index.(*ast.PrefixExpr); ok && p.Operator == "-" {
+		// $a[-1] counts from the end
+		n := operandOf("len("+r.goName+")", scalar(intT))
+		idx = binary(n, "-", t.exprAs(p.Right, scalar(intT)), scalar(intT))
+	}
+	return operandOf(r.goName+"["+idx.code+"]", scalar(r.typ.elem))
+}
+
+// hashAccess is the map and the key of $h{key}
+func (t *translator) hashAccess(v *ast.HashAccess) (string, string) {
+	sv, ok := v.Hash.(*ast.ScalarVar)
+	if !ok {
+		t.unsupported(v)
+		return "", ""
+	}
+	r := t.lookup(sv, "%"+sv.Name)
+	if r == nil {
+		return "", ""
+	}
+	return r.goName, t.str(v.Key).code
+}
+
+var stringOps = map[string]string{"eq": "==", "ne": "!=", "lt": "<", "gt": ">", "le": "<=", "ge": ">="}
+
+func (t *translator) infix(e *ast.InfixExpr) value {
+	op := e.Operator
+	switch op {
+	case "+", "-", "*", "/", "%", "**", "==", "!=", "<", ">", "<=", ">=", "<=>":
+		l, r := t.expr(e.Left), t.expr(e.Right)
+		if t.err != nil {
+			return value{}
+		}
+		if !numeric(l.typ.kind) || !numeric(r.typ.kind) {
+			t.fail(e, "%s needs numbers, not a %s and a %s", op, l.typ, r.typ)
+			return value{}
+		}
+		float := l.typ.kind == floatT || r.typ.kind == floatT
+		switch op {
+		case "**":
+			t.use("math")
+			return operandOf("math.Pow("+t.toFloat(l).code+", "+t.toFloat(r).code+")", scalar(floatT))
+		case "%":
+			if float {
+				t.fail(e, "%% needs integers")
+			}
+			return binary(l, op, r, scalar(intT))
+		case "/":
+			float = true
+		}
+		if float {
+			if l.typ.kind == intT {
+				l = t.toFloat(l)
+			}
+			if r.typ.kind == intT {
+				r = t.toFloat(r)
+			}
+		}
+		if op == "<=>" {
+			t.use("cmp")
+			return operandOf("cmp.Compare("+l.code+", "+r.code+")", scalar(intT))
+		}
+		typ := l.typ
+		if precedence[op] == 3 {
+			typ = scalar(boolT)
+		}
+		return binary(l, op, r, typ)
+	case ".":
+		return binary(t.str(e.Left), "+", t.str(e.Right), scalar(stringT))
+	case "x":
+		t.use("strings")
+		return operandOf("strings.Repeat("+t.str(e.Left).code+", "+t.exprAs(e.Right, scalar(intT)).code+")", scalar(stringT))
+	case "eq", "ne", "lt", "gt", "le", "ge":
+		return binary(t.str(e.Left), stringOps[op], t.str(e.Right), scalar(boolT))
+	case "cmp":
+		t.use("strings")
+		return operandOf("strings.Compare("+t.str(e.Left).code+", "+t.str(e.Right).code+")", scalar(intT))
+	case "&&", "||", "and", "or":
+		t.fail(e, "%s is translated only in conditions; its value is one of its operands in perl", op)
+		return value{}
+	}
+	t.unsupported(e)
+	return value{}
+}
+
+func (t *translator) callValue(call *ast.CallExpr) value {
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		t.unsupported(call)
+		return value{}
+	}
+	if fn := t.funcs[id.Value]; fn != nil {
+		switch {
+		case fn.fails:
+			t.fail(call, "%s can fail, so a call is translated only as a statement of its own or the whole value of my, an assignment or return", fn.perl)
+		case fn.result.typ.kind == unknown:
+			t.fail(call, "%s returns no value", fn.perl)
+		default:
+			return operandOf(t.userCall(call, fn), fn.result.typ)
+		}
+		return value{}
+	}
+	args := call.Args
+	name := id.Value
+	one := func() bool {
+		if len(args) != 1 {
+			t.fail(call, "%s is translated only with one argument", name)
+			return false
+		}
+		return true
+	}
+	switch name {
+	case "length":
+		if one() {
+			t.use("unicode/utf8")
+			return operandOf("utf8.RuneCountInString("+t.str(args[0]).code+")", scalar(intT))
+		}
+	case "uc", "lc":
+		if one() {
+			t.use("strings")
+			fn := map[string]string{"uc": "ToUpper", "lc": "ToLower"}[name]
+			return operandOf("strings."+fn+"("+t.str(args[0]).code+")", scalar(stringT))
+		}
+	case "abs":
+		if one() {
+			v := t.expr(args[0])
+			switch v.typ.kind {
+			case intT:
+				return operandOf("max("+v.code+", -"+paren(v)+")", v.typ)
+			case floatT:
+				t.use("math")
+				return operandOf("math.Abs("+v.code+")", v.typ)
+			}
+			t.fail(call, "abs of a %s", v.typ)
+		}
+	case "int":
+		if one() {
+			if in, ok := args[0].(*ast.InfixExpr); ok && in.Operator == "/" {
+				l, r := t.expr(in.Left), t.expr(in.Right)
+				if l.typ.kind == intT && r.typ.kind == intT {
+					return binary(l, "/", r, scalar(intT))
+				}
+			}
+			v := t.expr(args[0])
+			switch v.typ.kind {
+			case intT:
+				return v
+			case floatT:
+				return operandOf("int("+v.code+")", scalar(intT))
+			}
+			t.fail(call, "int of a %s", v.typ)
+		}
+	case "sqrt":
+		if one() {
+			t.use("math")
+			return operandOf("math.Sqrt("+t.exprAs(args[0], scalar(floatT)).code+")", scalar(floatT))
+		}
+	case "scalar":
+		if one() {
+			if isCall(args[0], "keys") {
+				args = args[0].(*ast.CallExpr).Args
+			}
+			v := t.expr(args[0])
+			if v.typ.kind == sliceT || v.typ.kind == mapT {
+				return operandOf("len("+v.code+")", scalar(intT))
+			}
+			t.fail(call, "scalar of a %s", v.typ)
+		}
+	case "join":
+		if len(args) < 2 {
+			t.fail(call, "join needs a separator and a list")
+			break
+		}
+		t.use("strings")
+		sep := t.str(args[0]).code
+		if len(args) == 2 {
+			if v := t.expr(args[1]); v.typ.kind == sliceT {
+				if v.typ.elem != stringT {
+					t.fail(call, "join of a %s; only []string is joined", v.typ)
+				}
+				return operandOf("strings.Join("+v.code+", "+sep+")", scalar(stringT))
+			}
+		}
+		parts := make([]string, len(args)-1)
+		for i, a := range args[1:] {
+			parts[i] = t.str(a).code
+		}
+		return operandOf("strings.Join([]string{"+strings.Join(parts, ", ")+"}, "+sep+")", scalar(stringT))
+	case "sprintf":
+		if len(args) == 0 {
+			break
+		}
+		verbs, vals := t.printfArgs(call)
+		t.use("fmt")
+		return operandOf("fmt.Sprintf("+strings.Join(append([]string{strconv.Quote(verbs)}, vals...), ", ")+")", scalar(stringT))
+	case "keys", "values":
+		if one() {
+			h := t.expr(args[0])
+			if h.typ.kind != mapT {
+				t.fail(call, "%s of a %s", name, h.typ)
+				break
+			}
+			t.use("slices")
+			t.use("maps")
+			fn, elem := "Keys", stringT
+			if name == "values" {
+				fn, elem = "Values", h.typ.elem
+			}
+			return operandOf("slices.Collect(maps."+fn+"("+h.code+"))", goType{kind: sliceT, elem: elem})
+		}
+	case "sort":
+		return t.sorted(call)
+	case "exists":
+		t.fail(call, "exists is translated only as the condition of if, elsif or unless")
+		return value{}
+	}
+	if t.err == nil {
+		t.fail(call, "the builtin %s is not translated", name)
+	}
+	return value{}
+}
+
+// sorted is sort LIST, or sort with a block comparing $a and $b in
+// ascending order; both become slices.Sorted
+func (t *translator) sorted(call *ast.CallExpr) value {
+	args := call.Args
+	numericSort := false
+	if len(args) == 2 {
+		sub, ok := args[0].(*ast.AnonSubExpr)
+		var cmp *ast.InfixExpr
+		if ok && len(sub.Body.Statements) == 1 {
+			if es, ok := sub.Body.Statements[0].(*ast.ExprStmt); ok {
+				cmp, _ = es.Expression.(*ast.InfixExpr)
+			}
+		}
+		if cmp == nil || !isSortVar(cmp.Left, "a") || !isSortVar(cmp.Right, "b") || cmp.Operator != "<=>" && cmp.Operator != "cmp" {
+			t.fail(call, "sort is translated only without a block or with { $a <=> $b } or { $a cmp $b }")
+			return value{}
+		}
+		numericSort = cmp.Operator == "<=>"
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		t.fail(call, "sort is translated only for one array or keys %%hash")
+		return value{}
+	}
+	t.use("slices")
+	if isCall(args[0], "keys") && len(args[0].(*ast.CallExpr).Args) == 1 && !numericSort {
+		t.use("maps")
+		h := t.expr(args[0].(*ast.CallExpr).Args[0])
+		return operandOf("slices.Sorted(maps.Keys("+h.code+"))", goType{kind: sliceT, elem: stringT})
+	}
+	v := t.expr(args[0])
+	switch {
+	case v.typ.kind != sliceT:
+		t.fail(call, "sort of a %s", v.typ)
+	case numericSort && !numeric(v.typ.elem):
+		t.fail(call, "sort { $a <=> $b } of a %s", v.typ)
+	case !numericSort && v.typ.elem != stringT:
+		t.fail(call, "sort without <=> compares %s as strings; use sort { $a <=> $b }", v.typ)
+	}
+	return operandOf("slices.Sorted(slices.Values("+v.code+"))", v.typ)
+}
+
+func isSortVar(e ast.Expression, name string) bool {
+	sv, ok := e.(*ast.ScalarVar)
+	return ok && sv.Name == name
+}
+
+// piece is literal text or a value of a printed or interpolated string
+type piece struct {
+	text  string
+	arg   string
+	verb  string
+	isArg bool
+}
+
+// printFormat collects the pieces of print arguments and interpolated strings
+type printFormat struct {
+	pieces []piece
+}
+
+func (f *printFormat) literal(s string) {
+	if n := len(f.pieces); n > 0 && !f.pieces[n-1].isArg {
+		f.pieces[n-1].text += s
+		return
+	}
+	f.pieces = append(f.pieces, piece{text: s})
+}
+
+func (f *printFormat) hasArgs() bool {
+	for _, p := range f.pieces {
+		if p.isArg {
+			return true
+		}
+	}
+	return false
+}
+
+// text is the literal text of a format without values
+func (f *printFormat) text() string {
+	var b strings.Builder
+	for _, p := range f.pieces {
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// verbs is the format as a fmt format string and its arguments
+func (f *printFormat) verbs() (string, []string) {
+	var b strings.Builder
+	var args []string
+	for _, p := range f.pieces {
+		if p.isArg {
+			b.WriteString(p.verb)
+			args = append(args, p.arg)
+		} else {
+			b.WriteString(strings.ReplaceAll(p.text, "%", "%%"))
+		}
+	}
+	return b.String(), args
+}
+
+// trimNewline drops the newline that ends the text, as die does
+func (f *printFormat) trimNewline() {
+	if n := len(f.pieces); n > 0 && !f.pieces[n-1].isArg {
+		f.pieces[n-1].text = strings.TrimSuffix(f.pieces[n-1].text, "\n")
+	}
+}
+
+// printCall is the fmt print function for the pieces and its arguments:
+// Print for text, Println for values between spaces ending in a newline
+// and Printf for anything else
+func (f *printFormat) printCall() (string, []string) {
+	if !f.hasArgs() {
+		text := f.text()
+		switch {
+		case text == "\n":
+			return "Println", nil
+		case strings.HasSuffix(text, "\n"):
+			return "Println", []string{strconv.Quote(text[:len(text)-1])}
+		}
+		return "Print", []string{strconv.Quote(text)}
+	}
+	var args []string
+	println := true
+	for i, p := range f.pieces {
+		last := i == len(f.pieces)-1
+		switch {
+		case i%2 == 0 && p.isArg && !last:
+			args = append(args, p.arg)
+		case i%2 == 1 && !p.isArg && (p.text == " " && !last || p.text == "\n" && last):
+		default:
+			println = false
+		}
+	}
+	if println && len(f.pieces)%2 == 0 {
+		return "Println", args
+	}
+	verbs, vals := f.verbs()
+	return "Printf", append([]string{strconv.Quote(verbs)}, vals...)
+}
+
+// formatArg adds one argument of print or die: list elements are joined
+// with sep
+func (t *translator) formatArg(f *printFormat, e ast.Expression, sep string) {
+	if lit, ok := e.(*ast.StringLiteral); ok {
+		t.formatString(f, lit)
+		return
+	}
+	v := t.expr(e)
+	verb := "%v"
+	switch v.typ.kind {
+	case intT:
+		verb = "%d"
+	case stringT:
+		verb = "%s"
+	case boolT:
+		verb = "%t"
+	case sliceT:
+		if v.typ.elem != stringT {
+			t.fail(e, "a %s is printed; only a []string is joined", v.typ)
+			return
+		}
+		t.use("strings")
+		v.code, verb = "strings.Join("+v.code+", "+strconv.Quote(sep)+")", "%s"
+	case floatT:
+	default:
+		if t.err == nil {
+			t.fail(e, "a %s cannot be printed", v.typ)
+		}
+		return
+	}
+	f.pieces = append(f.pieces, piece{arg: v.code, verb: verb, isArg: true})
+}
+
+func (t *translator) formatString(f *printFormat, lit *ast.StringLiteral) {
+	if !lit.Interpolated {
+		f.literal(lit.Value)
+		return
+	}
+	for _, seg := range interpolate.Parse(lit.Value) {
+		if seg.Expr == nil {
+			f.literal(seg.Text)
+			continue
+		}
+		// the parsed segments are shared by equal strings: bind them here
+		t.rexpr(seg.Expr)
+		t.formatArg(f, seg.Expr, " ")
+	}
+}
+
+// stringValue is a string literal: a Go string, the one value it holds or
+// fmt.Sprintf
+func (t *translator) stringValue(lit *ast.StringLiteral) value {
+	f := &printFormat{}
+	t.formatString(f, lit)
+	if !f.hasArgs() {
+		return operandOf(strconv.Quote(f.text()), scalar(stringT))
+	}
+	if len(f.pieces) == 1 && f.pieces[0].verb == "%s" {
+		return operandOf(f.pieces[0].arg, scalar(stringT))
+	}
+	t.use("fmt")
+	verbs, args := f.verbs()
+	return operandOf("fmt.Sprintf("+strings.Join(append([]string{strconv.Quote(verbs)}, args...), ", ")+")", scalar(stringT))
+}
+
+func (t *translator) print(call *ast.CallExpr, name string) {
+	args := call.Args
+	var w string
+	if len(args) > 0 {
+		if id, ok := args[0].(*ast.Identifier); ok {
+			switch id.Value {
+			case "STDERR":
+				t.use("os")
+				w = "os.Stderr"
+			case "STDOUT":
+			default:
+				t.fail(call, "printing to %s is not translated", id.Value)
+				return
+			}
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		t.fail(call, "%s without arguments prints $_, which is not translated", name)
+		return
+	}
+	var fn string
+	var fnArgs []string
+	if name == "printf" {
+		verbs, vals := t.printfArgs(&ast.CallExpr{Token: call.Token, Function: call.Function, Args: args})
+		fn, fnArgs = "Printf", append([]string{strconv.Quote(verbs)}, vals...)
+	} else {
+		f := &printFormat{}
+		for _, a := range args {
+			t.formatArg(f, a, "")
+		}
+		if name == "say" {
+			f.literal("\n")
+		}
+		fn, fnArgs = f.printCall()
+	}
+	if t.err != nil {
+		return
+	}
+	t.use("fmt")
+	if w != "" {
+		fn = "F" + strings.ToLower(fn[:1]) + fn[1:]
+		fnArgs = append([]string{w}, fnArgs...)
+	}
+	t.line("fmt.%s(%s)", fn, strings.Join(fnArgs, ", "))
+}
+
+// printfArgs turns the format and arguments of printf or sprintf into a
+// Go format: %s of a number becomes %v, %d of a float64 truncates it
+func (t *translator) printfArgs(call *ast.CallExpr) (string, []string) {
+	lit, ok := call.Args[0].(*ast.StringLiteral)
+	spec := ""
+	if ok {
+		f := &printFormat{}
+		t.formatString(f, lit)
+		if f.hasArgs() {
+			ok = false
+		}
+		spec = f.text()
+	}
+	if !ok {
+		t.fail(call, "the format of printf or sprintf must be a constant string")
+		return "", nil
+	}
+	args := call.Args[1:]
+	var b strings.Builder
+	var vals []string
+	for i := 0; i < len(spec); i++ {
+		c := spec[i]
+		b.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+		if i+1 < len(spec) && spec[i+1] == '%' {
+			b.WriteByte('%')
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(spec) && strings.IndexByte("-+ 0#.123456789", spec[j]) >= 0 {
+			j++
+		}
+		if j >= len(spec) || spec[j] == '*' {
+			t.fail(call, "the format %q is not translated", spec)
+			return "", nil
+		}
+		if len(vals) == len(args) {
+			t.fail(call, "the format %q needs more arguments", spec)
+			return "", nil
+		}
+		b.WriteString(spec[i+1 : j])
+		verb := spec[j]
+		arg := args[len(vals)]
+		v := t.expr(arg)
+		switch verb {
+		case 's':
+			if numeric(v.typ.kind) {
+				verb = 'v'
+			}
+		case 'd', 'i':
+			verb = 'd'
+			if v.typ.kind == floatT {
+				v.code = "int(" + v.code + ")"
+			}
+		case 'f', 'e', 'g', 'E', 'G':
+			v = t.convert(arg, v, scalar(floatT))
+		}
+		b.WriteByte(verb)
+		vals = append(vals, v.code)
+		i = j
+	}
+	if len(vals) != len(args) {
+		t.fail(call, "the format %q takes %d arguments, not %d", spec, len(vals), len(args))
+	}
+	return b.String(), vals
+}
+
+// die returns the message as an error from the current function
+func (t *translator) die(call *ast.CallExpr) {
+	if len(call.Args) == 0 {
+		t.fail(call, "die without a message is not translated")
+		return
+	}
+	f := &printFormat{}
+	for _, a := range call.Args {
+		t.formatArg(f, a, "")
+	}
+	f.trimNewline()
+	var err string
+	switch {
+	case !f.hasArgs():
+		t.use("errors")
+		err = "errors.New(" + strconv.Quote(f.text()) + ")"
+	case len(f.pieces) == 1 && f.pieces[0].verb == "%s":
+		t.use("errors")
+		err = "errors.New(" + f.pieces[0].arg + ")"
+	default:
+		t.use("fmt")
+		verbs, args := f.verbs()
+		err = "fmt.Errorf(" + strings.Join(append([]string{strconv.Quote(verbs)}, args...), ", ") + ")"
+	}
+	t.line("%s", t.returns("", err))
+}
@@ -0,0 +1,738 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+
+	"perlc/pkg/ast"
+	"perlc/pkg/interpolate"
+)
+
+// callSite is a call of a sub: its arguments give the parameter types
+type callSite struct {
+	fn   *function
+	node *ast.CallExpr
+}
+
+// collect walks the program once before anything is written: it binds
+// every variable to its my, registers subs and their parameters and
+// records the stores the types are inferred from
+func (t *translator) collect(program *ast.Program) {
+	t.resolving = true
+	defer func() { t.resolving = false }()
+
+	t.main = &function{goName: "main", result: &variable{}}
+	funcNames := map[string]bool{}
+	for _, st := range program.Statements {
+		sub, ok := st.(*ast.SubDecl)
+		if !ok {
+			continue
+		}
+		if t.funcs[sub.Name] != nil {
+			t.fail(sub, "sub %s is defined twice", sub.Name)
+			return
+		}
+		if strings.Contains(sub.Name, "::") {
+			t.fail(sub, "sub %s is in a package; packages are not translated", sub.Name)
+			return
+		}
+		fn := &function{
+			perl:   sub.Name,
+			goName: goIdent(sub.Name),
+			decl:   sub,
+			body:   sub.Body.Statements,
+			result: &variable{perl: "the value of " + sub.Name},
+		}
+		t.funcs[sub.Name] = fn
+		t.subs = append(t.subs, fn)
+		funcNames[fn.goName] = true
+	}
+	t.funcNames = funcNames
+
+	t.cur = t.main
+	t.scopes = []map[string]*variable{t.fileScope}
+	t.goNames = []map[string]bool{{}}
+	for _, st := range program.Statements {
+		if sub, ok := st.(*ast.SubDecl); ok {
+			t.resolveSub(sub)
+			t.cur = t.main
+			continue
+		}
+		t.rstmt(st)
+	}
+
+	for _, c := range t.calls {
+		if len(c.node.Args) != len(c.fn.params) {
+			t.fail(c.node, "%s takes %d arguments, called with %d", c.fn.perl, len(c.fn.params), len(c.node.Args))
+			return
+		}
+		for i, arg := range c.node.Args {
+			arg := arg
+			t.rules = append(t.rules, rule{v: c.fn.params[i], node: arg, typ: func() goType { return t.typeOf(arg) }})
+		}
+	}
+}
+
+func (t *translator) resolveSub(sub *ast.SubDecl) {
+	fn := t.funcs[sub.Name]
+	t.cur = fn
+	scopes, names := t.scopes, t.goNames
+	t.scopes = []map[string]*variable{t.fileScope, {}}
+	t.goNames = []map[string]bool{{}}
+	t.params(fn, sub)
+	t.rblock(fn.body[fn.paramStmts:])
+	if value := t.implicitReturn(fn); value != nil {
+		t.rules = append(t.rules, rule{v: fn.result, node: value, typ: func() goType { return t.scalarType(value) }})
+	}
+	t.scopes, t.goNames = scopes, names
+}
+
+// params declares the parameters of a sub: a signature, my ($a, $b) = @_
+// or my $x = shift; lines at the start of the body
+func (t *translator) params(fn *function, sub *ast.SubDecl) {
+	if sub.Params != nil {
+		for _, p := range sub.Params {
+			if p.Sigil != "$" || p.Default != nil {
+				t.fail(sub, "parameter %s%s of %s: only plain scalar parameters are translated", p.Sigil, p.Name, sub.Name)
+				return
+			}
+			fn.params = append(fn.params, t.declare(sub, "$"+p.Name))
+		}
+		return
+	}
+	body := fn.body
+	if len(body) > 0 {
+		if d, ok := body[0].(*ast.VarDecl); ok && d.Kind == "my" && d.IsList && isArgs(d.Value) {
+			for _, n := range d.Names {
+				sv, ok := n.(*ast.ScalarVar)
+				if !ok {
+					t.fail(d, "parameter %s of %s: only scalar parameters are translated", n.String(), sub.Name)
+					return
+				}
+				fn.params = append(fn.params, t.declare(d, "$"+sv.Name))
+			}
+			fn.paramStmts = 1
+			return
+		}
+	}
+	for _, st := range body {
+		d, ok := st.(*ast.VarDecl)
+		if !ok || d.Kind != "my" || len(d.Names) != 1 || !isShift(d.Value) {
+			break
+		}
+		sv, ok := d.Names[0].(*ast.ScalarVar)
+		if !ok {
+			break
+		}
+		fn.params = append(fn.params, t.declare(d, "$"+sv.Name))
+		fn.paramStmts++
+	}
+}
+
+// isArgs reports whether e is @_
+func isArgs(e ast.Expression) bool {
+	switch v := e.(type) {
+	case *ast.SpecialVar:
+		return v.Name == "@_"
+	case *ast.ArrayVar:
+		return v.Name == "_"
+	}
+	return false
+}
+
+// isShift reports whether e is shift or shift(@_)
+func isShift(e ast.Expression) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := call.Function.(*ast.Identifier)
+	return ok && id.Value == "shift" && (len(call.Args) == 0 || len(call.Args) == 1 && isArgs(call.Args[0]))
+}
+
+func (t *translator) pushScope() {
+	t.scopes = append(t.scopes, map[string]*variable{})
+	t.goNames = append(t.goNames, map[string]bool{})
+}
+
+func (t *translator) popScope() {
+	t.scopes = t.scopes[:len(t.scopes)-1]
+	t.goNames = t.goNames[:len(t.goNames)-1]
+}
+
+// declare brings the variable perl ($x, @a, %h) declared by node into the
+// current scope. The first walk creates it, the one that writes the Go
+// code finds the same variable again.
+func (t *translator) declare(node ast.Node, perl string) *variable {
+	key := declKey{node, perl}
+	v := t.decls[key]
+	if v == nil {
+		v = &variable{perl: perl}
+		switch perl[0] {
+		case '@':
+			v.typ.kind = sliceT
+		case '%':
+			v.typ.kind = mapT
+		}
+		base := goIdent(perl[1:])
+		name := base
+		names := t.goNames[len(t.goNames)-1]
+		for n := 2; names[name] || t.funcNames[name]; n++ {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		names[name] = true
+		v.goName = name
+		t.decls[key] = v
+	}
+	t.scopes[len(t.scopes)-1][perl] = v
+	return v
+}
+
+// lookup finds the variable a node names; a file-scope variable used in a
+// sub becomes a package-level one
+func (t *translator) lookup(node ast.Node, perl string) *variable {
+	return t.bind(node, perl, true)
+}
+
+// bind is lookup for a node that reads the variable, or only stores into it
+func (t *translator) bind(node ast.Node, perl string, read bool) *variable {
+	for i := len(t.scopes) - 1; i >= 0; i-- {
+		v := t.scopes[i][perl]
+		if v == nil {
+			continue
+		}
+		if i == 0 && t.cur != t.main && !v.global {
+			v.global = true
+			t.globals = append(t.globals, v)
+		}
+		if t.resolving && read {
+			v.read = true
+		}
+		t.refs[node] = v
+		return v
+	}
+	t.fail(node, "%s is not declared with my; only lexical variables are translated", perl)
+	return nil
+}
+
+func (t *translator) rblock(stmts []ast.Statement) {
+	for _, st := range stmts {
+		t.rstmt(st)
+	}
+}
+
+func (t *translator) rscoped(b *ast.BlockStmt) {
+	if b == nil {
+		return
+	}
+	t.pushScope()
+	t.rblock(b.Statements)
+	t.popScope()
+}
+
+func (t *translator) rstmt(st ast.Statement) {
+	switch s := st.(type) {
+	case *ast.ExprStmt:
+		t.rstore(s.Expression)
+	case *ast.VarDecl:
+		t.rdecl(s)
+	case *ast.IfStmt:
+		t.rexpr(s.Condition)
+		t.rscoped(s.Then)
+		for _, e := range s.Elsif {
+			t.rexpr(e.Condition)
+			t.rscoped(e.Body)
+		}
+		t.rscoped(s.Else)
+	case *ast.WhileStmt:
+		t.rexpr(s.Condition)
+		t.rscoped(s.Body)
+	case *ast.ForStmt:
+		t.pushScope()
+		if s.Init != nil {
+			t.rstmt(s.Init)
+		}
+		t.rexpr(s.Condition)
+		if s.Post != nil {
+			t.rstore(s.Post)
+		}
+		t.rblock(s.Body.Statements)
+		t.popScope()
+	case *ast.ForeachStmt:
+		t.rexpr(s.List)
+		t.pushScope()
+		if sv, ok := s.Variable.(*ast.ScalarVar); ok {
+			v := t.declare(s, "$"+sv.Name)
+			list := s.List
+			t.rules = append(t.rules, rule{v: v, node: s, typ: func() goType { return scalar(t.elemOf(list)) }})
+		}
+		t.rblock(s.Body.Statements)
+		t.popScope()
+	case *ast.ReturnStmt:
+		if s.Value != nil {
+			t.rexpr(s.Value)
+			fn, value := t.cur, s.Value
+			t.rules = append(t.rules, rule{v: fn.result, node: s, typ: func() goType { return t.scalarType(value) }})
+		}
+	case *ast.BlockStmt:
+		t.rscoped(s)
+	case *ast.UseDecl:
+		switch s.Module {
+		case "strict", "warnings", "utf8", "feature":
+		default:
+			t.fail(s, "use %s: modules are not translated", s.Module)
+		}
+	}
+}
+
+// rdecl resolves my declarations and the types their values give
+func (t *translator) rdecl(d *ast.VarDecl) {
+	t.rexpr(d.Value)
+	if d.Kind != "my" {
+		return
+	}
+	if d.IsList && len(d.Names) > 1 {
+		list, _ := d.Value.(*ast.ArrayExpr)
+		for i, n := range d.Names {
+			sv, ok := n.(*ast.ScalarVar)
+			if !ok {
+				return
+			}
+			v := t.declare(d, "$"+sv.Name)
+			if list != nil && i < len(list.Elements) {
+				el := list.Elements[i]
+				t.rules = append(t.rules, rule{v: v, node: el, typ: func() goType { return t.typeOf(el) }})
+			}
+		}
+		return
+	}
+	if len(d.Names) != 1 {
+		return
+	}
+	value := d.Value
+	switch n := d.Names[0].(type) {
+	case *ast.ScalarVar:
+		v := t.declare(d, "$"+n.Name)
+		if value != nil {
+			t.rules = append(t.rules, rule{v: v, node: d, typ: func() goType { return t.scalarType(value) }})
+		}
+	case *ast.ArrayVar:
+		t.rlist(t.declare(d, "@"+n.Name), value)
+	case *ast.HashVar:
+		v := t.declare(d, "%"+n.Name)
+		if list, ok := value.(*ast.ArrayExpr); ok {
+			for i := 1; i < len(list.Elements); i += 2 {
+				el := list.Elements[i]
+				t.rules = append(t.rules, rule{v: v, elem: true, node: el, typ: func() goType { return t.typeOf(el) }})
+			}
+		}
+	}
+}
+
+// rlist records the element types of a list stored into an array
+func (t *translator) rlist(v *variable, value ast.Expression) {
+	switch list := value.(type) {
+	case nil:
+	case *ast.ArrayExpr:
+		for _, el := range list.Elements {
+			el := el
+			t.rules = append(t.rules, rule{v: v, elem: true, node: el, typ: func() goType { return t.typeOf(el) }})
+		}
+	default:
+		t.rules = append(t.rules, rule{v: v, elem: true, node: value, typ: func() goType {
+			if ty := t.typeOf(value); ty.kind != sliceT {
+				// one scalar is a list of one
+				return ty
+			}
+			return scalar(t.elemOf(value))
+		}})
+	}
+}
+
+// rstore resolves a statement-level expression and the stores in it
+func (t *translator) rstore(e ast.Expression) {
+	switch v := e.(type) {
+	case *ast.AssignExpr:
+		t.rexpr(v.Right)
+		right, op := v.Right, v.Operator
+		switch l := v.Left.(type) {
+		case *ast.ScalarVar:
+			target := t.bind(l, "$"+l.Name, op != "=")
+			if target == nil {
+				return
+			}
+			t.rules = append(t.rules, rule{v: target, node: v, typ: func() goType { return t.storeType(op, right) }})
+		case *ast.ArrayAccess, *ast.HashAccess:
+			t.rexpr(l)
+			if target := t.refs[containerNode(l)]; target != nil {
+				t.rules = append(t.rules, rule{v: target, elem: true, node: v, typ: func() goType { return t.storeType(op, right) }})
+			}
+		case *ast.ArrayVar:
+			if target := t.lookup(l, "@"+l.Name); target != nil && op == "=" {
+				t.rlist(target, right)
+			}
+		default:
+			t.rexpr(v.Left)
+		}
+	case *ast.PostfixExpr, *ast.PrefixExpr:
+		t.rexpr(e)
+		var target ast.Expression
+		if p, ok := e.(*ast.PostfixExpr); ok {
+			target = p.Left
+		} else {
+			target = e.(*ast.PrefixExpr).Right
+		}
+		intType := func() goType { return scalar(intT) }
+		switch l := target.(type) {
+		case *ast.ScalarVar:
+			if v := t.refs[l]; v != nil {
+				t.rules = append(t.rules, rule{v: v, node: e, typ: intType})
+			}
+		case *ast.ArrayAccess, *ast.HashAccess:
+			if v := t.refs[containerNode(l)]; v != nil {
+				t.rules = append(t.rules, rule{v: v, elem: true, node: e, typ: intType})
+			}
+		}
+	case *ast.CallExpr:
+		t.rexpr(e)
+		if id, ok := v.Function.(*ast.Identifier); ok && id.Value == "push" && len(v.Args) > 0 {
+			if target := t.refs[v.Args[0]]; target != nil {
+				for _, el := range v.Args[1:] {
+					el := el
+					t.rules = append(t.rules, rule{v: target, elem: true, node: el, typ: func() goType {
+						if ty := t.typeOf(el); ty.kind == sliceT {
+							return scalar(ty.elem)
+						}
+						return t.typeOf(el)
+					}})
+				}
+			}
+		}
+	default:
+		t.rexpr(e)
+	}
+}
+
+// containerNode is the node an element access binds its array or hash to
+func containerNode(e ast.Expression) ast.Node {
+	switch v := e.(type) {
+	case *ast.ArrayAccess:
+		return v.Array
+	case *ast.HashAccess:
+		return v.Hash
+	}
+	return nil
+}
+
+// rexpr binds the variables of an expression and records its sub calls
+func (t *translator) rexpr(e ast.Expression) {
+	switch v := e.(type) {
+	case nil, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Identifier, *ast.AnonSubExpr:
+		// barewords are filehandles, blocks sort blocks: the emitter checks them
+	case *ast.ScalarVar:
+		t.lookup(v, "$"+v.Name)
+	case *ast.ArrayVar:
+		if v.Name != "ARGV" {
+			t.lookup(v, "@"+v.Name)
+		}
+	case *ast.HashVar:
+		t.lookup(v, "%"+v.Name)
+	case *ast.ArrayLengthVar:
+		t.lookup(v, "@"+v.Name)
+	case *ast.ArrayAccess:
+		if sv, ok := v.Array.(*ast.ScalarVar); ok && sv.Name != "ARGV" {
+			t.lookup(sv, "@"+sv.Name)
+		}
+		t.rexpr(v.Index)
+	case *ast.HashAccess:
+		if sv, ok := v.Hash.(*ast.ScalarVar); ok {
+			t.lookup(sv, "%"+sv.Name)
+		}
+		t.rexpr(v.Key)
+	case *ast.StringLiteral:
+		if v.Interpolated {
+			for _, seg := range interpolate.Parse(v.Value) {
+				t.rexpr(seg.Expr)
+			}
+		}
+	case *ast.PrefixExpr:
+		t.rexpr(v.Right)
+	case *ast.PostfixExpr:
+		t.rexpr(v.Left)
+	case *ast.InfixExpr:
+		t.rexpr(v.Left)
+		t.rexpr(v.Right)
+	case *ast.TernaryExpr:
+		t.rexpr(v.Condition)
+		t.rexpr(v.Then)
+		t.rexpr(v.Else)
+	case *ast.AssignExpr:
+		t.rexpr(v.Left)
+		t.rexpr(v.Right)
+	case *ast.ArrayExpr:
+		for _, el := range v.Elements {
+			t.rexpr(el)
+		}
+	case *ast.RangeExpr:
+		t.rexpr(v.Start)
+		t.rexpr(v.End)
+	case *ast.SpecialVar:
+		if isArgs(v) {
+			t.fail(v, "@_ is translated only as the parameters: my ($a, $b) = @_; or my $x = shift;")
+			return
+		}
+		t.fail(v, "the special variable %s is not translated", v.Name)
+	case *ast.CallExpr:
+		if id, ok := v.Function.(*ast.Identifier); ok {
+			if fn := t.funcs[id.Value]; fn != nil {
+				t.cur.callees = append(t.cur.callees, fn)
+				t.calls = append(t.calls, callSite{fn, v})
+			} else if id.Value == "die" {
+				t.cur.fails = true
+			}
+		}
+		for _, a := range v.Args {
+			t.rexpr(a)
+		}
+	default:
+		t.unsupported(e)
+	}
+}
+
+// infer runs the stores to a fixpoint: every variable gets the join of
+// the types stored into it, parameters that of their arguments and subs
+// the type of their return values. Subs that call a failing sub fail.
+func (t *translator) infer() {
+	for changed := true; changed && t.err == nil; {
+		changed = false
+		for _, r := range t.rules {
+			if t.apply(r) {
+				changed = true
+			}
+		}
+	}
+	all := append([]*function{t.main}, t.subs...)
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range all {
+			if fn.fails {
+				continue
+			}
+			for _, c := range fn.callees {
+				if c.fails {
+					fn.fails, changed = true, true
+					break
+				}
+			}
+		}
+	}
+}
+
+// apply joins the type of one store into its variable; it reports whether
+// the variable changed
+func (t *translator) apply(r rule) bool {
+	vt := r.typ()
+	if vt.kind == unknown || t.err != nil {
+		return false
+	}
+	v := r.v
+	if r.elem || v.typ.kind == sliceT || v.typ.kind == mapT {
+		elem := vt.kind
+		if !r.elem {
+			if vt.kind != v.typ.kind {
+				t.fail(r.node, "%s is given a %s", v.perl, vt)
+				return false
+			}
+			elem = vt.elem
+		} else if vt.kind == sliceT || vt.kind == mapT {
+			t.fail(r.node, "an element of %s is given a %s; nested data structures are not translated", v.perl, vt)
+			return false
+		}
+		k, ok := join(v.typ.elem, elem)
+		if !ok {
+			t.fail(r.node, "%s holds both %s and %s values", v.perl, scalar(v.typ.elem), scalar(elem))
+			return false
+		}
+		if k == v.typ.elem {
+			return false
+		}
+		v.typ.elem = k
+		return true
+	}
+	if vt.kind == sliceT || vt.kind == mapT {
+		// only a sub returns a list as a whole
+		if v.typ.kind == unknown && strings.HasPrefix(v.perl, "the value of") {
+			v.typ = vt
+			return true
+		}
+		t.fail(r.node, "%s is given a %s", v.perl, vt)
+		return false
+	}
+	k, ok := join(v.typ.kind, vt.kind)
+	if !ok {
+		t.fail(r.node, "%s holds both %s and %s values", v.perl, v.typ, vt)
+		return false
+	}
+	if k == v.typ.kind {
+		return false
+	}
+	v.typ.kind = k
+	return true
+}
+
+// storeType is the type a store op= value gives its variable
+func (t *translator) storeType(op string, value ast.Expression) goType {
+	switch op {
+	case "=":
+		return t.scalarType(value)
+	case "/=", "**=":
+		return scalar(floatT)
+	case "%=":
+		return scalar(intT)
+	case ".=", "x=":
+		return scalar(stringT)
+	}
+	return t.typeOf(value)
+}
+
+// scalarType is the type of value stored in a scalar: an array there is
+// its length
+func (t *translator) scalarType(value ast.Expression) goType {
+	if _, ok := value.(*ast.ArrayVar); ok {
+		return scalar(intT)
+	}
+	return t.typeOf(value)
+}
+
+// elemOf is the element type of a list a foreach walks
+func (t *translator) elemOf(list ast.Expression) kind {
+	switch v := list.(type) {
+	case *ast.RangeExpr:
+		return intT
+	case *ast.ArrayExpr:
+		k := unknown
+		for _, el := range v.Elements {
+			if ty := t.typeOf(el); ty.kind == sliceT {
+				k, _ = join(k, ty.elem)
+			} else {
+				k, _ = join(k, ty.kind)
+			}
+		}
+		return k
+	}
+	if ty := t.typeOf(list); ty.kind == sliceT {
+		return ty.elem
+	}
+	return unknown
+}
+
+// typeOf is the Go type of an expression as far as it is known yet
+func (t *translator) typeOf(e ast.Expression) goType {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		return scalar(intT)
+	case *ast.FloatLiteral:
+		return scalar(floatT)
+	case *ast.StringLiteral:
+		return scalar(stringT)
+	case *ast.ScalarVar, *ast.HashVar:
+		if r := t.refs[v]; r != nil {
+			return r.typ
+		}
+	case *ast.ArrayVar:
+		if v.Name == "ARGV" {
+			return goType{kind: sliceT, elem: stringT}
+		}
+		if r := t.refs[v]; r != nil {
+			return r.typ
+		}
+	case *ast.ArrayAccess:
+		if sv, ok := v.Array.(*ast.ScalarVar); ok && sv.Name == "ARGV" {
+			return scalar(stringT)
+		}
+		if r := t.refs[v.Array]; r != nil {
+			return scalar(r.typ.elem)
+		}
+	case *ast.HashAccess:
+		if r := t.refs[v.Hash]; r != nil {
+			return scalar(r.typ.elem)
+		}
+	case *ast.ArrayLengthVar:
+		return scalar(intT)
+	case *ast.PrefixExpr:
+		switch v.Operator {
+		case "!", "not":
+			return scalar(boolT)
+		}
+		return t.typeOf(v.Right)
+	case *ast.PostfixExpr:
+		return t.typeOf(v.Left)
+	case *ast.InfixExpr:
+		switch v.Operator {
+		case "+", "-", "*":
+			l, r := t.typeOf(v.Left).kind, t.typeOf(v.Right).kind
+			switch {
+			case l == floatT && numeric(r), r == floatT && numeric(l):
+				return scalar(floatT)
+			case l == intT && r == intT:
+				return scalar(intT)
+			}
+		case "/", "**":
+			return scalar(floatT)
+		case "%", "<=>", "cmp":
+			return scalar(intT)
+		case ".", "x":
+			return scalar(stringT)
+		case "==", "!=", "<", ">", "<=", ">=", "eq", "ne", "lt", "gt", "le", "ge",
+			"&&", "||", "and", "or":
+			return scalar(boolT)
+		}
+	case *ast.TernaryExpr:
+		a, b := t.typeOf(v.Then), t.typeOf(v.Else)
+		if k, ok := join(a.kind, b.kind); ok && a.kind != sliceT && a.kind != mapT {
+			return scalar(k)
+		}
+		return a
+	case *ast.CallExpr:
+		return t.callType(v)
+	}
+	return goType{}
+}
+
+// callType is the type of the value of a sub or builtin call
+func (t *translator) callType(call *ast.CallExpr) goType {
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return goType{}
+	}
+	if fn := t.funcs[id.Value]; fn != nil {
+		return fn.result.typ
+	}
+	var arg goType
+	if len(call.Args) > 0 {
+		// sort BLOCK LIST sorts its last argument
+		arg = t.typeOf(call.Args[len(call.Args)-1])
+	}
+	switch id.Value {
+	case "length", "scalar", "int", "index":
+		return scalar(intT)
+	case "sqrt":
+		return scalar(floatT)
+	case "abs":
+		return arg
+	case "uc", "lc", "join", "sprintf":
+		return scalar(stringT)
+	case "exists":
+		return scalar(boolT)
+	case "keys":
+		return goType{kind: sliceT, elem: stringT}
+	case "values":
+		return goType{kind: sliceT, elem: arg.elem}
+	case "sort":
+		if arg.kind == mapT {
+			return goType{kind: sliceT, elem: stringT}
+		}
+		return arg
+	}
+	return goType{}
+}
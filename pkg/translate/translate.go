@@ -0,0 +1,265 @@
+// Package translate turns a perl program written in a statically typed
+// subset into idiomatic Go source, for migrating code to Go for good:
+// scalars become int, float64, string or bool, arrays slices, hashes maps
+// with string keys, subs functions with typed parameters and die an error
+// return. Unlike the code generator it does not model perl (no SV boxes,
+// no context, no numeric strings), so what it cannot express with plain Go
+// types - references, regexes, local, packages, string eval, a variable
+// that holds both numbers and strings - is reported instead of translated.
+package translate
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"perlc/pkg/ast"
+)
+
+// kind is the Go type of a scalar, or the container of a slice or map
+type kind int
+
+const (
+	unknown kind = iota
+	intT
+	floatT
+	stringT
+	boolT
+	sliceT
+	mapT
+)
+
+// goType is the type of a variable or expression; elem is the element
+// type of a slice or map (map keys are always strings)
+type goType struct {
+	kind kind
+	elem kind
+}
+
+func (t goType) String() string {
+	switch t.kind {
+	case intT:
+		return "int"
+	case floatT:
+		return "float64"
+	case stringT:
+		return "string"
+	case boolT:
+		return "bool"
+	case sliceT:
+		return "[]" + goType{kind: t.elem}.String()
+	case mapT:
+		return "map[string]" + goType{kind: t.elem}.String()
+	}
+	return "any"
+}
+
+// zero is the Go zero value of the type
+func (t goType) zero() string {
+	switch t.kind {
+	case intT, floatT:
+		return "0"
+	case stringT:
+		return `""`
+	case boolT:
+		return "false"
+	}
+	return "nil"
+}
+
+func scalar(k kind) goType { return goType{kind: k} }
+
+// join merges two types a value takes: ints widen to float64, any other
+// mix fails
+func join(a, b kind) (kind, bool) {
+	switch {
+	case a == unknown:
+		return b, true
+	case b == unknown || a == b:
+		return a, true
+	case a == intT && b == floatT, a == floatT && b == intT:
+		return floatT, true
+	}
+	return a, false
+}
+
+func numeric(k kind) bool { return k == intT || k == floatT }
+
+// variable is one my-variable of the program
+type variable struct {
+	perl   string // $x, @a or %h
+	goName string
+	typ    goType
+	global bool // a file-scope my used by a sub: a package-level var
+	read   bool // used after its declaration; Go rejects unused locals
+}
+
+// function is a sub, or the main program
+type function struct {
+	perl    string
+	goName  string
+	decl    *ast.SubDecl
+	body    []ast.Statement
+	params  []*variable
+	result  *variable // typ.kind is unknown for a sub without a value
+	fails   bool      // dies or calls a sub that fails: returns an error
+	callees []*function
+	// statements at the start of the body that only take parameters
+	paramStmts int
+	// an assignment of a failing call needs err declared up front
+	needErr bool
+}
+
+// rule is one store into a variable: its type joins the type of the value
+type rule struct {
+	v    *variable
+	elem bool
+	node ast.Node
+	typ  func() goType
+}
+
+// declKey identifies a variable by the node that declares it
+type declKey struct {
+	node ast.Node
+	name string
+}
+
+type translator struct {
+	filename string
+	funcs    map[string]*function
+	// funcNames are the Go names of the subs, which variables avoid
+	funcNames map[string]bool
+	subs      []*function
+	main      *function
+	cur       *function
+
+	decls     map[declKey]*variable
+	refs      map[ast.Node]*variable
+	scopes    []map[string]*variable
+	fileScope map[string]*variable
+	goNames   []map[string]bool
+	globals   []*variable
+	rules     []rule
+	calls     []callSite
+	resolving bool
+
+	imports map[string]bool
+	out     *strings.Builder
+	err     error
+}
+
+// Error is a construct the translator cannot express in Go
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return e.Msg
+}
+
+// Translate returns program as a Go main package, or the first construct
+// it cannot translate. filename is named in the header comment.
+func Translate(program *ast.Program, filename string) (string, error) {
+	t := &translator{
+		filename:  filename,
+		funcs:     make(map[string]*function),
+		decls:     make(map[declKey]*variable),
+		refs:      make(map[ast.Node]*variable),
+		fileScope: make(map[string]*variable),
+		imports:   make(map[string]bool),
+	}
+	t.collect(program)
+	if t.err == nil {
+		t.infer()
+	}
+	if t.err != nil {
+		return "", t.err
+	}
+	body := t.emitProgram(program)
+	if t.err != nil {
+		return "", t.err
+	}
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "// Translated from %s by perlc -translate.\n\npackage main\n\n", filename)
+	if len(t.imports) > 0 {
+		paths := make([]string, 0, len(t.imports))
+		for p := range t.imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		src.WriteString("import (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&src, "%q\n", p)
+		}
+		src.WriteString(")\n\n")
+	}
+	src.WriteString(body)
+	out, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return src.String(), fmt.Errorf("translated code does not parse: %v", err)
+	}
+	return string(out), nil
+}
+
+// fail records the first construct that cannot be translated
+func (t *translator) fail(n ast.Node, format string, args ...any) {
+	if t.err != nil {
+		return
+	}
+	e := &Error{Msg: fmt.Sprintf(format, args...)}
+	if n != nil {
+		if pos, ok := ast.PosOf(n); ok {
+			e.Line = pos.Line
+		}
+	}
+	t.err = e
+}
+
+func (t *translator) use(pkg string) { t.imports[pkg] = true }
+
+// goReserved are names a translated identifier must not take: keywords,
+// the predeclared identifiers and the packages the output imports
+var goReserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+	"append": true, "bool": true, "cap": true, "copy": true, "delete": true,
+	"error": true, "false": true, "float64": true, "int": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "nil": true, "print": true,
+	"println": true, "string": true, "true": true, "any": true, "err": true,
+	"cmp": true, "errors": true, "fmt": true, "maps": true, "math": true,
+	"os": true, "slices": true, "strconv": true, "strings": true, "utf8": true,
+	"main": true, "run": true, "ok": true,
+}
+
+// goIdent turns a perl name into a Go one: snake_case becomes camelCase
+func goIdent(name string) string {
+	parts := strings.Split(name, "_")
+	var b strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString(strings.ToLower(p[:1]) + p[1:])
+		} else {
+			b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "v"
+	}
+	if goReserved[id] {
+		id += "_"
+	}
+	return id
+}
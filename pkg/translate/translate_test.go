@@ -0,0 +1,114 @@
+package translate
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/lexer"
+	"perlc/pkg/parser"
+)
+
+func translateSource(t *testing.T, src string) (string, error) {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return Translate(program, "test.pl")
+}
+
+func TestTranslate(t *testing.T) {
+	code, err := translateSource(t, `use strict;
+my $total = 0;
+sub add {
+    my ($a, $b) = @_;
+    return $a + $b;
+}
+sub bump { my $by = shift; $total += $by; }
+sub label { my ($n) = @_; "n=" . $n }
+my @values = (3, 5);
+my %count = (apples => 2);
+foreach my $v (@values) { bump($v); }
+for (my $i = 0; $i < 3; $i++) { print "i=$i\n"; }
+foreach my $k (sort keys %count) { print "$k: $count{$k}\n"; }
+push @values, 13;
+my $n = @values;
+my $half = $n / 2;
+print add(2, 3), "\n";
+print label($n), "\n";
+if (exists $count{apples}) { print "yes\n"; }
+my $word = $n > 3 ? "many" : "few";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"var total int",
+		"func add(a, b int) int {",
+		"return a + b",
+		"func bump(by int) {",
+		"total += by",
+		`return "n=" + strconv.Itoa(n)`,
+		"values := []int{3, 5}",
+		`"apples": 2,`,
+		"for _, v := range values {",
+		"for i := 0; i < 3; i++ {",
+		`fmt.Printf("i=%d\n", i)`,
+		"for _, k := range slices.Sorted(maps.Keys(count)) {",
+		"values = append(values, 13)",
+		"n := len(values)",
+		"half := float64(n) / 2",
+		"fmt.Println(add(2, 3))",
+		`if _, ok := count["apples"]; ok {`,
+		"var word string",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("translation does not contain %s:\n%s", want, code)
+		}
+	}
+}
+
+func TestTranslateDie(t *testing.T) {
+	code, err := translateSource(t, `sub mean {
+    my ($sum, $n) = @_;
+    die "no values\n" if $n == 0;
+    return $sum / $n;
+}
+sub report { my $n = shift; my $m = mean(10, $n); print "$m\n"; }
+report(2);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func mean(sum, n int) (float64, error) {",
+		`return 0, errors.New("no values")`,
+		"return float64(sum) / float64(n), nil",
+		"func report(n int) error {",
+		"m, err := mean(10, n)",
+		"if err := report(2); err != nil {",
+		"func run() error {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("translation does not contain %s:\n%s", want, code)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`my $x = 1; $x = "a";`, "line 1: $x holds both int and string values"},
+		{"my $s = 'x';\nmy $r = \\$s;", `line 2: cannot translate \$s`},
+		{`our $g = 1;`, "our variables are not translated"},
+		{`print $y;`, "$y is not declared with my"},
+		{`use Data::Dumper;`, "modules are not translated"},
+		{`sub f { my $x = shift; return $x } my $v = f(1, 2);`, "f takes 1 arguments, called with 2"},
+	}
+	for _, tt := range tests {
+		_, err := translateSource(t, tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
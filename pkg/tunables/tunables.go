@@ -0,0 +1,118 @@
+// Package tunables holds the runtime knobs shared by the interpreter and the
+// generated programs. Each knob is read from a PERLC_* environment variable
+// and can be overridden by a perlc flag; a compiled program starts from the
+// values it was compiled with and reads the same variables again at startup.
+package tunables
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// Tunables are the runtime knobs.
+type Tunables struct {
+	// MaxDepth limits nested sub calls; 0 means no limit
+	MaxDepth int
+	// HashSeed fixes the order of keys, values and each; 0 keeps Go's
+	// randomized map order
+	HashSeed int64
+	// RegexCache is how many compiled runtime patterns are kept
+	RegexCache int
+	// IOBuffer is the buffer size of file handles in bytes
+	IOBuffer int
+	// Warnings is 0 for none, 1 for the default warnings and 2 for verbose
+	// ones such as deep recursion
+	Warnings int
+}
+
+// Knob describes one tunable: its environment variable and flag.
+type Knob struct {
+	Env   string
+	Flag  string
+	Usage string
+}
+
+// Default returns the values used when nothing is set.
+func Default() Tunables {
+	return Tunables{RegexCache: 1000, IOBuffer: 4096, Warnings: 1}
+}
+
+// Knobs lists the tunables in the order of Values.
+func Knobs() []Knob {
+	return []Knob{
+		{"PERLC_MAX_DEPTH", "max-depth", "limit nested sub calls (0 = no limit)"},
+		{"PERLC_HASH_SEED", "hash-seed", "fix the key order of hashes (0 = random)"},
+		{"PERLC_REGEX_CACHE", "regex-cache", "number of compiled runtime patterns kept"},
+		{"PERLC_IO_BUFFER", "io-buffer", "file handle buffer size in bytes"},
+		{"PERLC_WARNINGS", "warnings", "warning verbosity: 0 none, 1 default, 2 verbose"},
+	}
+}
+
+// Values returns the knobs' values in the order of Knobs.
+func (t *Tunables) Values() []int64 {
+	return []int64{int64(t.MaxDepth), t.HashSeed, int64(t.RegexCache), int64(t.IOBuffer), int64(t.Warnings)}
+}
+
+// set stores the value of the n-th knob.
+func (t *Tunables) set(n int, v int64) {
+	switch n {
+	case 0:
+		t.MaxDepth = int(v)
+	case 1:
+		t.HashSeed = v
+	case 2:
+		t.RegexCache = int(v)
+	case 3:
+		t.IOBuffer = int(v)
+	case 4:
+		t.Warnings = int(v)
+	}
+}
+
+// Load reads the PERLC_* variables through getenv (os.Getenv); unset ones
+// keep their value. A value that is not an integer is an error.
+func (t *Tunables) Load(getenv func(string) string) error {
+	for n, k := range Knobs() {
+		s := getenv(k.Env)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", k.Env, s)
+		}
+		t.set(n, v)
+	}
+	return nil
+}
+
+// Flags registers -max-depth, -hash-seed, -regex-cache, -io-buffer and
+// -warnings on fs with the current values as defaults.
+func (t *Tunables) Flags(fs *flag.FlagSet) {
+	for n, k := range Knobs() {
+		fs.Var(&knobValue{t: t, n: n}, k.Flag, k.Usage+" ($"+k.Env+")")
+	}
+}
+
+// knobValue is a flag.Value for one knob.
+type knobValue struct {
+	t *Tunables
+	n int
+}
+
+func (v *knobValue) String() string {
+	if v.t == nil {
+		return "0"
+	}
+	return strconv.FormatInt(v.t.Values()[v.n], 10)
+}
+
+func (v *knobValue) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	v.t.set(v.n, n)
+	return nil
+}
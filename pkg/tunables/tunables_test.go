@@ -0,0 +1,40 @@
+package tunables
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	env := map[string]string{"PERLC_MAX_DEPTH": "50", "PERLC_HASH_SEED": "-3"}
+	tune := Default()
+	if err := tune.Load(func(k string) string { return env[k] }); err != nil {
+		t.Fatal(err)
+	}
+	want := Tunables{MaxDepth: 50, HashSeed: -3, RegexCache: 1000, IOBuffer: 4096, Warnings: 1}
+	if tune != want {
+		t.Errorf("got %+v, want %+v", tune, want)
+	}
+
+	env["PERLC_IO_BUFFER"] = "4k"
+	if err := tune.Load(func(k string) string { return env[k] }); err == nil {
+		t.Error("PERLC_IO_BUFFER=4k: expected an error")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tune := Default()
+	tune.MaxDepth = 10 // from PERLC_MAX_DEPTH
+	fs := flag.NewFlagSet("perlc", flag.ContinueOnError)
+	tune.Flags(fs)
+	if err := fs.Parse([]string{"-warnings", "2", "-regex-cache", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	want := Tunables{MaxDepth: 10, RegexCache: 0, IOBuffer: 4096, Warnings: 2}
+	if tune != want {
+		t.Errorf("got %+v, want %+v", tune, want)
+	}
+	if def := fs.Lookup("max-depth").DefValue; def != "10" {
+		t.Errorf("max-depth default = %s, want 10", def)
+	}
+}
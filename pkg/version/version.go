@@ -0,0 +1,70 @@
+// Package version holds the build metadata of perlc: its own version and
+// git commit, set at build time with
+//
+//	go build -ldflags "-X perlc/pkg/version.Version=1.2.0 -X perlc/pkg/version.Commit=$(git rev-parse --short HEAD)" ./cmd/perlc
+//
+// and the Perl version whose features perlc implements, which Perl code
+// sees as $^V and $].
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Version is the semantic version of perlc; "dev" for a build without
+// ldflags.
+var Version = "dev"
+
+// Commit is the git commit perlc was built from. Without ldflags it is
+// taken from the VCS stamp of go build, if there is one.
+var Commit = ""
+
+// Perl is the Perl feature level perlc supports: say, signatures, postfix
+// dereference and the other features of this release.
+const Perl = "5.36.0"
+
+// PerlVars are the values of $^V ("v5.36.0") and $] ("5.036000").
+var PerlVars = map[string]string{
+	"$^V": "v" + Perl,
+	"$]":  numeric(Perl),
+}
+
+// numeric turns "5.36.0" into the $] form "5.036000"
+func numeric(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	out := parts[0] + "."
+	for n := 1; n < 3; n++ {
+		x := 0
+		if n < len(parts) {
+			x, _ = strconv.Atoi(parts[n])
+		}
+		out += fmt.Sprintf("%03d", x)
+	}
+	return out
+}
+
+// GitCommit returns Commit, or the revision recorded by go build; "unknown"
+// if neither is known.
+func GitCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				return s.Value[:7]
+			}
+		}
+	}
+	return "unknown"
+}
+
+// String is the one-line version of perlc, as printed by perlc --version
+// and at the top of crash reports.
+func String() string {
+	return fmt.Sprintf("perlc %s (commit %s, %s %s/%s)", Version, GitCommit(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
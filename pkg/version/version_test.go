@@ -0,0 +1,17 @@
+package version
+
+import "testing"
+
+func TestPerlVars(t *testing.T) {
+	if got := PerlVars["$^V"]; got != "v5.36.0" {
+		t.Errorf("$^V = %q", got)
+	}
+	if got := PerlVars["$]"]; got != "5.036000" {
+		t.Errorf("$] = %q", got)
+	}
+	for in, want := range map[string]string{"5.8.1": "5.008001", "5.10": "5.010000", "5": "5.000000"} {
+		if got := numeric(in); got != want {
+			t.Errorf("numeric(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+// Package warnings is the central reporter of the run-time warnings of
+// perlc. Every warning goes through a Reporter, which
+//
+//   - prints an identical message from the same location only once, so a
+//     loop does not flood STDERR with the same warning;
+//   - prints a WarnOnce message once per run, wherever it comes from;
+//   - drops the warnings of disabled categories (no warnings 'once') and
+//     all of them at level 0 (PERLC_WARNINGS=0);
+//   - prints the warnings of optional categories (numeric, uninitialized)
+//     only after use warnings turns them on.
+//
+// The generated programs have the same rules in their runtime (_warn).
+package warnings
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"perlc/pkg/ast"
+)
+
+// Category is a warnings category, as named in use/no warnings.
+type Category string
+
+const (
+	Deprecated    Category = "deprecated"
+	Misc          Category = "misc"
+	Numeric       Category = "numeric"
+	Once          Category = "once"
+	Recursion     Category = "recursion"
+	Redefine      Category = "redefine"
+	Regexp        Category = "regexp"
+	Uninitialized Category = "uninitialized"
+	Void          Category = "void"
+)
+
+// NumericOps are perl's names of the numeric operators, as the
+// "Argument isn't numeric" warnings print them. The compound assignments
+// (+= ...) go by the name of their operator.
+var NumericOps = map[string]string{
+	"+": "addition (+)", "-": "subtraction (-)", "*": "multiplication (*)",
+	"/": "division (/)", "%": "modulus (%)", "**": "exponentiation (**)",
+	"==": "numeric eq (==)", "!=": "numeric ne (!=)", "<": "numeric lt (<)",
+	"<=": "numeric le (<=)", ">": "numeric gt (>)", ">=": "numeric ge (>=)",
+	"<=>": "numeric comparison (<=>)",
+}
+
+// StringOps are perl's names of the string operators, as the "Use of
+// uninitialized value" warnings print them.
+var StringOps = map[string]string{
+	".": "concatenation (.) or string", "x": "repeat (x)",
+	"eq": "string eq", "ne": "string ne", "lt": "string lt", "le": "string le",
+	"gt": "string gt", "ge": "string ge", "cmp": "string comparison (cmp)",
+}
+
+// OpName returns perl's name of a numeric or string operator; ok is false
+// for operators that do not warn about their operands.
+func OpName(op string) (name string, ok bool) {
+	if name, ok = NumericOps[op]; ok {
+		return name, true
+	}
+	name, ok = StringOps[op]
+	return name, ok
+}
+
+// AssignExempt reports whether the left side of the compound assignment
+// op= is not warned about when undefined: $n += 1 and $s .= "x" are the
+// way to start a sum or a string.
+func AssignExempt(op string) bool {
+	switch op {
+	case "+", "-", ".", "|", "^":
+		return true
+	}
+	return false
+}
+
+// Uninit is the warning for an undefined operand of the operator named
+// op; name is the variable, as VarName gives it, or "".
+func Uninit(name, op string) string {
+	if name == "" {
+		return "Use of uninitialized value in " + op
+	}
+	return "Use of uninitialized value " + name + " in " + op
+}
+
+// VarName is how perl names the operand e in "Use of uninitialized
+// value" warnings: $x, $a[0], $h{"k"}, or "within @a" for an element
+// with a computed subscript; "" for other expressions.
+func VarName(e ast.Expression) string {
+	switch v := e.(type) {
+	case *ast.ScalarVar:
+		return "$" + v.Name
+	case *ast.SpecialVar:
+		if strings.HasPrefix(v.Name, "$") {
+			return v.Name
+		}
+	case *ast.ArrayAccess:
+		name := containerName(v.Array)
+		if name == "" {
+			return ""
+		}
+		if index, ok := constIndex(v.Index); ok {
+			return "$" + name + "[" + index + "]"
+		}
+		return "within @" + name
+	case *ast.HashAccess:
+		name := containerName(v.Hash)
+		if name == "" {
+			return ""
+		}
+		if key, ok := constKey(v.Key); ok {
+			return "$" + name + "{" + strconv.Quote(key) + "}"
+		}
+		return "within %" + name
+	}
+	return ""
+}
+
+// containerName is the name of the array or hash of an element access
+func containerName(e ast.Expression) string {
+	switch v := e.(type) {
+	case *ast.ScalarVar:
+		return v.Name
+	case *ast.ArrayVar:
+		return v.Name
+	case *ast.HashVar:
+		return v.Name
+	}
+	return ""
+}
+
+func constIndex(e ast.Expression) (string, bool) {
+	switch v := e.(type) {
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(v.Value, 10), true
+	case *ast.PrefixExpr:
+		if n, ok := v.Right.(*ast.IntegerLiteral); ok && v.Operator == "-" {
+			return "-" + strconv.FormatInt(n.Value, 10), true
+		}
+	}
+	return "", false
+}
+
+func constKey(e ast.Expression) (string, bool) {
+	switch v := e.(type) {
+	case *ast.StringLiteral:
+		return v.Value, !v.Interpolated || !strings.ContainsAny(v.Value, "$@")
+	case *ast.Identifier:
+		return v.Value, true
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(v.Value, 10), true
+	}
+	return "", false
+}
+
+// HashList is the warning for a list of n elements assigned to a hash, ""
+// when n is even; ref tells that the one element is a reference, as in
+// %h = {...}.
+func HashList(n int, ref bool) string {
+	switch {
+	case n%2 == 0:
+		return ""
+	case n == 1 && ref:
+		return "Reference found where even-sized list expected"
+	}
+	return "Odd number of elements in hash assignment"
+}
+
+// All is the name that stands for every category.
+const All = "all"
+
+// categories are the known categories; verbose ones print only at level 2
+var categories = map[Category]bool{
+	Deprecated: false, Misc: false, Numeric: false, Once: false, Recursion: true,
+	Redefine: false, Regexp: false, Uninitialized: false, Void: false,
+}
+
+// perlCategories are the other categories of perl's warnings.pm: use
+// and no warnings accept them, but perlc has no warnings of theirs
+var perlCategories = map[string]bool{
+	"closure": true, "exiting": true, "glob": true, "imprecision": true,
+	"io": true, "closed": true, "exec": true, "layer": true, "newline": true,
+	"pipe": true, "syscalls": true, "unopened": true, "locale": true,
+	"missing": true, "overflow": true, "pack": true, "portable": true,
+	"redundant": true, "scalar": true, "severe": true, "debugging": true,
+	"inplace": true, "internal": true, "malloc": true, "shadow": true,
+	"signal": true, "substr": true, "syntax": true, "ambiguous": true,
+	"bareword": true, "digit": true, "illegalproto": true, "parenthesis": true,
+	"precedence": true, "printf": true, "prototype": true, "qw": true,
+	"reserved": true, "semicolon": true, "taint": true, "threads": true,
+	"unpack": true, "untie": true, "utf8": true, "non_unicode": true,
+	"nonchar": true, "surrogate": true,
+
+	"deprecated::apostrophe_as_package_separator": true,
+	"deprecated::delimiter_will_be_paired":        true,
+	"deprecated::dot_in_inc":                      true,
+	"deprecated::goto_construct":                  true,
+	"deprecated::missing_import_called_with_args": true,
+	"deprecated::smartmatch":                      true,
+	"deprecated::subsequent_use_version":          true,
+	"deprecated::unicode_property_name":           true,
+	"deprecated::version_downgrade":               true,
+
+	"experimental": true, "experimental::alpha_assertions": true,
+	"experimental::args_array_with_signatures": true, "experimental::autoderef": true,
+	"experimental::bitwise": true, "experimental::builtin": true,
+	"experimental::class": true, "experimental::const_attr": true,
+	"experimental::declared_refs": true, "experimental::defer": true,
+	"experimental::extra_paired_delimiters": true, "experimental::for_list": true,
+	"experimental::isa": true, "experimental::lexical_subs": true,
+	"experimental::lexical_topic": true, "experimental::postderef": true,
+	"experimental::private_use": true, "experimental::re_strict": true,
+	"experimental::refaliasing": true, "experimental::regex_sets": true,
+	"experimental::script_run": true, "experimental::signatures": true,
+	"experimental::smartmatch": true, "experimental::try": true,
+	"experimental::uniprop_wildcards": true, "experimental::vlb": true,
+	"experimental::win32_perlio": true,
+}
+
+// Categories returns the names of the known categories, sorted.
+func Categories() []string {
+	var names []string
+	for c := range categories {
+		names = append(names, string(c))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verbose reports whether the category is printed only at level 2.
+func Verbose(c Category) bool {
+	return categories[c]
+}
+
+// optional are the categories off until use warnings turns them on, as in
+// perl: "42abc" + 8 and sums started from undef are common idioms in
+// scripts without use warnings
+var optional = map[Category]bool{Numeric: true, Uninitialized: true}
+
+// Optional reports whether the category is printed only after use
+// warnings, by name or as all, turns it on.
+func Optional(c Category) bool {
+	return optional[c]
+}
+
+// Reporter prints warnings to a writer.
+type Reporter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level int
+	off   map[Category]bool
+	seen  map[string]bool
+}
+
+// New returns a reporter that writes to out. Level is 0 for no warnings,
+// 1 for the default ones and 2 for verbose ones as well.
+func New(out io.Writer, level int) *Reporter {
+	return &Reporter{out: out, level: level, off: map[Category]bool{}, seen: map[string]bool{}}
+}
+
+// SetOutput changes the writer warnings go to.
+func (r *Reporter) SetOutput(out io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out = out
+}
+
+// SetLevel changes the verbosity level.
+func (r *Reporter) SetLevel(level int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.level = level
+}
+
+// Enable turns categories on (use warnings LIST); no names or "all" turns
+// on every category. FATAL and NONFATAL are accepted and ignored.
+func (r *Reporter) Enable(names ...string) error {
+	return r.set(false, names)
+}
+
+// Disable turns categories off (no warnings LIST); no names or "all"
+// turns off every category.
+func (r *Reporter) Disable(names ...string) error {
+	return r.set(true, names)
+}
+
+func (r *Reporter) set(off bool, names []string) error {
+	cats, err := Parse(names...)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range cats {
+		r.off[c] = off
+	}
+	return nil
+}
+
+// Parse returns the categories named in a use/no warnings list; an empty
+// list or "all" stands for every category. Categories of perl that perlc
+// has no warnings of are accepted and left out; other names are errors.
+func Parse(names ...string) ([]Category, error) {
+	var cats []Category
+	all := len(names) == 0
+	for _, name := range names {
+		switch _, known := categories[Category(name)]; {
+		case name == All:
+			all = true
+		case name == "FATAL" || name == "NONFATAL":
+		case known:
+			cats = append(cats, Category(name))
+		case perlCategories[name]:
+			// a category of perl that perlc does not warn about
+		default:
+			return nil, fmt.Errorf("Unknown warnings category '%s'", name)
+		}
+	}
+	if all {
+		cats = cats[:0]
+		for _, name := range Categories() {
+			cats = append(cats, Category(name))
+		}
+	}
+	return cats, nil
+}
+
+// Enabled reports whether warnings of the category are printed.
+func (r *Reporter) Enabled(c Category) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled(c)
+}
+
+func (r *Reporter) enabled(c Category) bool {
+	if r.level <= 0 || (Verbose(c) && r.level < 2) {
+		return false
+	}
+	off, set := r.off[c]
+	if !set {
+		return !Optional(c)
+	}
+	return !off
+}
+
+// Warn prints msg with the location appended, unless the category is off
+// or the same message was already printed for this location. It reports
+// whether the warning was printed.
+func (r *Reporter) Warn(c Category, pos ast.Position, msg string) bool {
+	msg = Format(msg, pos)
+	return r.emit(c, string(c)+"\x00"+msg, msg)
+}
+
+// WarnOnce prints msg only the first time it is reported for this
+// category, from whatever location.
+func (r *Reporter) WarnOnce(c Category, pos ast.Position, msg string) bool {
+	return r.emit(c, string(c)+"\x00once\x00"+msg, Format(msg, pos))
+}
+
+func (r *Reporter) emit(c Category, key, msg string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabled(c) || r.seen[key] {
+		return false
+	}
+	r.seen[key] = true
+	fmt.Fprint(r.out, msg)
+	return true
+}
+
+// Print writes the message of warn(): it is never deduplicated or
+// silenced, like in perl.
+func (r *Reporter) Print(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprint(r.out, msg)
+}
+
+// Format appends " at FILE line N." and a newline to msg, as perl does; a
+// message that already ends with a newline or an unknown location is only
+// terminated.
+func Format(msg string, pos ast.Position) string {
+	if strings.HasSuffix(msg, "\n") {
+		return msg
+	}
+	if where := Where(pos); where != "" {
+		msg += " at " + where
+	}
+	return msg + ".\n"
+}
+
+// Where returns "FILE line N" for a position, "" if the line is unknown;
+// a program read without a file name is "-".
+func Where(pos ast.Position) string {
+	if pos.Line == 0 {
+		return ""
+	}
+	file := pos.File
+	if file == "" {
+		file = "-"
+	}
+	return fmt.Sprintf("%s line %d", file, pos.Line)
+}
@@ -0,0 +1,135 @@
+package warnings
+
+import (
+	"strings"
+	"testing"
+
+	"perlc/pkg/ast"
+)
+
+func TestWarnDeduplicates(t *testing.T) {
+	var out strings.Builder
+	r := New(&out, 1)
+	r.Enable("uninitialized")
+	at3 := ast.Position{File: "t.pl", Line: 3}
+	for n := 0; n < 5; n++ {
+		r.Warn(Uninitialized, at3, "Use of uninitialized value $x in addition (+)")
+	}
+	r.Warn(Uninitialized, ast.Position{File: "t.pl", Line: 4}, "Use of uninitialized value $x in addition (+)")
+	r.WarnOnce(Once, at3, "Name \"main::y\" used only once")
+	r.WarnOnce(Once, ast.Position{File: "t.pl", Line: 9}, "Name \"main::y\" used only once")
+
+	want := "Use of uninitialized value $x in addition (+) at t.pl line 3.\n" +
+		"Use of uninitialized value $x in addition (+) at t.pl line 4.\n" +
+		"Name \"main::y\" used only once at t.pl line 3.\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	var out strings.Builder
+	r := New(&out, 1)
+	if err := r.Disable("uninitialized"); err != nil {
+		t.Fatal(err)
+	}
+	r.Warn(Uninitialized, ast.Position{}, "silenced")
+	r.Warn(Misc, ast.Position{}, "misc")
+	r.Warn(Recursion, ast.Position{}, "verbose only")
+	if out.String() != "misc.\n" {
+		t.Errorf("got %q", out.String())
+	}
+
+	r.Disable()
+	if r.Enabled(Numeric) {
+		t.Error("no warnings should turn off every category")
+	}
+	r.Enable("FATAL", "all")
+	r.SetLevel(2)
+	if !r.Enabled(Uninitialized) || !r.Enabled(Recursion) {
+		t.Error("use warnings should turn every category back on")
+	}
+	if err := r.Enable("experimental::signatures", "io", "syntax", "once"); err != nil {
+		t.Errorf("perl categories: %v", err)
+	}
+	if err := r.Enable("bogus"); err == nil || err.Error() != "Unknown warnings category 'bogus'" {
+		t.Errorf("unknown category: %v", err)
+	}
+
+	r.SetLevel(0)
+	r.Print("warn() is never silenced\n")
+	r.Warn(Misc, ast.Position{}, "level 0")
+	if !strings.HasSuffix(out.String(), "warn() is never silenced\n") {
+		t.Errorf("got %q", out.String())
+	}
+}
+
+func TestOptional(t *testing.T) {
+	var out strings.Builder
+	r := New(&out, 2)
+	r.Warn(Numeric, ast.Position{}, "before use warnings")
+	if err := r.Enable("numeric"); err != nil {
+		t.Fatal(err)
+	}
+	r.SetLevel(1)
+	r.Warn(Numeric, ast.Position{}, "after use warnings")
+	r.Disable("numeric")
+	r.Warn(Numeric, ast.Position{}, "after no warnings")
+	r.Enable()
+	r.Warn(Numeric, ast.Position{}, "after use warnings all")
+	if want := "after use warnings.\nafter use warnings all.\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestHashList(t *testing.T) {
+	tests := []struct {
+		n    int
+		ref  bool
+		want string
+	}{
+		{0, false, ""},
+		{4, true, ""},
+		{3, false, "Odd number of elements in hash assignment"},
+		{1, false, "Odd number of elements in hash assignment"},
+		{1, true, "Reference found where even-sized list expected"},
+		{3, true, "Odd number of elements in hash assignment"},
+	}
+	for _, tt := range tests {
+		if got := HashList(tt.n, tt.ref); got != tt.want {
+			t.Errorf("HashList(%d, %v) = %q, want %q", tt.n, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestUninit(t *testing.T) {
+	tests := []struct {
+		e    ast.Expression
+		op   string
+		want string
+	}{
+		{&ast.ScalarVar{Name: "x"}, "+", "Use of uninitialized value $x in addition (+)"},
+		{&ast.SpecialVar{Name: "$_"}, ".", "Use of uninitialized value $_ in concatenation (.) or string"},
+		{&ast.ArrayAccess{Array: &ast.ArrayVar{Name: "a"}, Index: &ast.IntegerLiteral{Value: 2}}, ">", "Use of uninitialized value $a[2] in numeric gt (>)"},
+		{&ast.ArrayAccess{Array: &ast.ArrayVar{Name: "a"}, Index: &ast.PrefixExpr{Operator: "-", Right: &ast.IntegerLiteral{Value: 1}}}, "eq", "Use of uninitialized value $a[-1] in string eq"},
+		{&ast.ArrayAccess{Array: &ast.ArrayVar{Name: "a"}, Index: &ast.ScalarVar{Name: "i"}}, "*", "Use of uninitialized value within @a in multiplication (*)"},
+		{&ast.HashAccess{Hash: &ast.HashVar{Name: "h"}, Key: &ast.StringLiteral{Value: "k"}}, "cmp", `Use of uninitialized value $h{"k"} in string comparison (cmp)`},
+		{&ast.HashAccess{Hash: &ast.HashVar{Name: "h"}, Key: &ast.ScalarVar{Name: "k"}}, "x", "Use of uninitialized value within %h in repeat (x)"},
+		{&ast.CallExpr{}, "-", "Use of uninitialized value in subtraction (-)"},
+	}
+	for _, tt := range tests {
+		op, ok := OpName(tt.op)
+		if !ok {
+			t.Fatalf("OpName(%q) not found", tt.op)
+		}
+		if got := Uninit(VarName(tt.e), op); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+	if _, ok := OpName("&&"); ok {
+		t.Error("&& should not warn about its operands")
+	}
+	if !AssignExempt(".") || AssignExempt("*") {
+		t.Error("only $x .= and friends start from undef quietly")
+	}
+}
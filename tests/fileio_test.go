@@ -229,6 +229,233 @@ say "$l1 and $l2";`,
 			ExpectedOutput: "First and Second",
 			CleanupFiles:   []string{"append_mode.txt"},
 		},
+		{
+			Name: "pipe from a command",
+			Code: `my $pid = open(my $fh, "-|", "echo", "from", "child") or die "pipe: $!";
+my $line = <$fh>;
+print "pid\n" if $pid > 0;
+print "got: $line";
+say close($fh) ? "ok" : "failed";
+open(my $bad, "-|", "sh", "-c", "exit 3");
+while (my $l = <$bad>) { }
+say close($bad) ? "ok" : "failed $?";`,
+			ExpectedOutput: "pid\ngot: from child\nok\nfailed 768",
+		},
+		{
+			Name: "pipe to a command",
+			Code: `open(my $fh, "|-", "tr a-z A-Z") or die "pipe: $!";
+print $fh "shout\n";
+close($fh);
+say "after $?";`,
+			ExpectedOutput: "SHOUT\nafter 0",
+		},
+		{
+			Name: "2-arg opens",
+			Code: `open(my $fh, "printf 'x\\ny\\n' |");
+my $n = 0;
+while (my $l = <$fh>) { $n++; }
+close($fh);
+say "$n rows";
+open(my $in, "< two_arg.txt") or die "open: $!";
+my $c = <$in>;
+close($in);
+print $c;`,
+			ExpectedOutput: "2 rows\nfile line",
+			SetupFiles: map[string]string{
+				"two_arg.txt": "file line\n",
+			},
+		},
+		{
+			Name: "in-memory handles",
+			Code: `my $data = "one\ntwo\n";
+open(my $in, "<", \$data);
+my $first = <$in>;
+my $second = <$in>;
+my $end = <$in>;
+close($in);
+print "read $first$second";
+say defined($end) ? "more" : "eof";
+my $buf = "old";
+open(my $out, ">", \$buf);
+print $out "a", "b";
+say "buf=$buf";
+close($out);
+open($out, ">>", \$buf);
+print $out "!";
+close($out);
+say "buf=$buf";`,
+			ExpectedOutput: "read one\ntwo\neof\nbuf=ab\nbuf=ab!",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOReadWrite(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "anonymous temp file",
+			Code: `open(my $fh, "+>", undef);
+print $fh "hello\nworld\n";
+say tell($fh);
+seek($fh, 0, 0);
+my $line = <$fh>;
+chomp($line);
+say $line;
+close($fh);`,
+			ExpectedOutput: "12\nhello",
+		},
+		{
+			Name: "read-write existing file",
+			Code: `open(my $fh, "+<", "rw_mode.txt");
+my $first = <$fh>;
+chomp($first);
+seek($fh, 0, 2);
+print $fh "appended\n";
+seek($fh, 0, 0);
+my $a = <$fh>;
+my $b = <$fh>;
+close($fh);
+chomp($b);
+say "$first $b";`,
+			ExpectedOutput: "original appended",
+			SetupFiles: map[string]string{
+				"rw_mode.txt": "original\n",
+			},
+		},
+		{
+			Name: "truncate temp file",
+			Code: `open(my $fh, "+>", undef);
+print $fh "abcdef";
+truncate($fh, 3);
+seek($fh, 0, 0);
+my $c = <$fh>;
+close($fh);
+chomp($c);
+say $c;`,
+			ExpectedOutput: "abc",
+		},
+		{
+			Name: "truncate without parentheses",
+			Code: `open(my $fh, "+>", undef);
+print $fh "abcdef";
+truncate $fh, 0;
+print $fh "xy";
+seek($fh, 0, 0);
+my $c = <$fh>;
+close($fh);
+say length($c);`,
+			ExpectedOutput: "8",
+		},
+		{
+			Name: "tell and write after reading a line",
+			Code: `open(my $fh, "+<", "rw_ahead.txt");
+my $first = <$fh>;
+say tell($fh);
+print $fh "XY";
+seek($fh, 0, 0);
+my @lines = <$fh>;
+close($fh);
+print @lines;`,
+			ExpectedOutput: "6\nfirst\nXYcond\nthird",
+			SetupFiles: map[string]string{
+				"rw_ahead.txt": "first\nsecond\nthird\n",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOLocking(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "sysopen O_EXCL fails on existing file",
+			Code: `my $ok = sysopen(my $fh, "excl.lock", O_WRONLY|O_CREAT|O_EXCL);
+say $ok ? "created" : "exists";`,
+			ExpectedOutput: "exists",
+			SetupFiles: map[string]string{
+				"excl.lock": "1234\n",
+			},
+		},
+		{
+			Name: "sysopen O_CREAT write then read",
+			Code: `if (sysopen(my $fh, "sysopen.txt", O_WRONLY|O_CREAT|O_TRUNC)) {
+    print $fh "locked\n";
+    close($fh);
+}
+sysopen(my $in, "sysopen.txt", O_RDONLY);
+my $line = <$in>;
+close($in);
+chomp($line);
+say $line;`,
+			ExpectedOutput: "locked",
+			CleanupFiles:   []string{"sysopen.txt"},
+		},
+		{
+			Name: "flock exclusive non-blocking",
+			Code: `open(my $fh, ">", "flock.lock");
+say flock($fh, LOCK_EX|LOCK_NB);
+say flock($fh, LOCK_UN);
+close($fh);`,
+			ExpectedOutput: "1\n1",
+			CleanupFiles:   []string{"flock.lock"},
+		},
+		{
+			Name: "flock without parentheses",
+			Code: `use Fcntl qw(:flock);
+open(my $fh, ">", "flock_bare.lock");
+flock $fh, LOCK_EX or die "no lock";
+print $fh "locked\n";
+say flock $fh, LOCK_UN;
+close($fh);`,
+			ExpectedOutput: "1",
+			CleanupFiles:   []string{"flock_bare.lock"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOGlob(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "glob with brace expansion is sorted",
+			Code: `my @files = glob("glob_*.{log,txt}");
+say join(",", @files);`,
+			ExpectedOutput: "glob_a.log,glob_b.log,glob_a.txt",
+			SetupFiles: map[string]string{
+				"glob_b.log": "",
+				"glob_a.log": "",
+				"glob_a.txt": "",
+			},
+		},
+		{
+			Name: "angle bracket glob and scalar iterator",
+			Code: `my @all = <glob_*.dat>;
+say scalar(@all);
+my $f;
+while ($f = glob("glob_*.dat")) {
+    say $f;
+}`,
+			ExpectedOutput: "2\nglob_1.dat\nglob_2.dat",
+			SetupFiles: map[string]string{
+				"glob_1.dat": "",
+				"glob_2.dat": "",
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -246,6 +473,12 @@ func TestFileIOErrorHandling(t *testing.T) {
 say $result ? "success" : "failed";`,
 			ExpectedOutput: "failed",
 		},
+		{
+			Name: "failed open sets $!",
+			Code: `open(my $fh, "<", "this_file_does_not_exist_xyz.txt") or say "open: $!";
+open(my $p, "-|", "no_such_command_xyz") or say "pipe: $!";`,
+			ExpectedOutput: "open: No such file or directory\npipe: No such file or directory",
+		},
 		{
 			Name: "check open result",
 			Code: `if (open(my $fh, "<", "exists.txt")) {
@@ -468,3 +701,503 @@ func TestCleanup(t *testing.T) {
 		os.Remove(f)
 	}
 }
+
+func TestFileIOMetadata(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "chmod counts changed files and sets $!",
+			Code: `my $n = chmod(0600, "meta_a.txt", "meta_b.txt", "meta_missing.txt");
+say $n;
+say $!;`,
+			ExpectedOutput: "2\nNo such file or directory",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "a\n",
+				"meta_b.txt": "b\n",
+			},
+		},
+		{
+			Name: "utime and chown with a file list",
+			Code: `my @files = ("meta_a.txt", "meta_b.txt");
+my $n = utime(1000000000, 1000000000, @files);
+say $n;
+$n = chown(-1, -1, @files);
+say $n;`,
+			ExpectedOutput: "2\n2",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "a\n",
+				"meta_b.txt": "b\n",
+			},
+		},
+		{
+			Name: "symlink and readlink",
+			Code: `system("rm -f meta.link");
+my $ok = symlink("meta_a.txt", "meta.link");
+say $ok;
+my $target = readlink("meta.link");
+say $target;
+$ok = symlink("meta_a.txt", "meta.link");
+say $ok;
+say $!;
+my $none = readlink("meta_a.txt");
+say defined($none) ? "link" : "not a link";`,
+			ExpectedOutput: "1\nmeta_a.txt\n0\nFile exists\nnot a link",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "a\n",
+			},
+			CleanupFiles: []string{"meta.link"},
+		},
+		{
+			Name: "metadata builtins without parentheses",
+			Code: `system("rm -f meta.link");
+my $f = "meta_a.txt";
+chmod 0600, $f;
+printf("%o\n", (stat($f))[2] & 07777);
+utime 1000000000, 1000000000, $f;
+say((stat($f))[9]);
+say chown -1, -1, $f;
+symlink $f, "meta.link";
+my $target = readlink "meta.link";
+say $target;`,
+			ExpectedOutput: "600\n1000000000\n1\nmeta_a.txt",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "a\n",
+			},
+			CleanupFiles: []string{"meta.link"},
+		},
+		{
+			Name: "stat and lstat",
+			Code: `system("rm -f meta.link");
+chmod(0640, "meta_a.txt");
+my @st = stat("meta_a.txt");
+say scalar(@st);
+say $st[7];
+printf("%o\n", $st[2] & 07777);
+say((stat("meta_a.txt"))[7]);
+say $st[9] > 0 ? "mtime" : "no mtime";
+my @none = stat("meta_missing.txt");
+say scalar(@none);
+say $!;
+printf("%o\n", (stat("."))[2] & 0170000);
+symlink("meta_a.txt", "meta.link");
+printf("%o %o\n", (lstat("meta.link"))[2] & 0170000, (stat("meta.link"))[2] & 0170000);
+open(my $fh, "<", "meta_a.txt");
+say((stat($fh))[7]);
+close($fh);`,
+			ExpectedOutput: "13\n6\n640\n6\nmtime\n0\nNo such file or directory\n40000\n120000 100000\n6",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "hello\n",
+			},
+			CleanupFiles: []string{"meta.link"},
+		},
+		{
+			Name: "stat and lstat without parentheses",
+			Code: `system("rm -f meta.link");
+my $f = "meta_a.txt";
+my @st = stat "meta_a.txt";
+say scalar(@st), " ", $st[7];
+symlink $f, "meta.link";
+my @l = lstat "meta.link";
+say scalar(@l);
+printf("%o\n", $l[2] & 0170000);
+my @none = stat "meta_missing.txt";
+say scalar(@none);
+say "exists" if stat $f;`,
+			ExpectedOutput: "13 6\n13\n120000\n0\nexists",
+			SetupFiles: map[string]string{
+				"meta_a.txt": "hello\n",
+			},
+			CleanupFiles: []string{"meta.link"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOFlushOnExit(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "handle left open at end of program",
+			code: `open(my $fh, ">", "flush_exit.txt"); print $fh "end\n";`,
+			want: "end\n",
+		},
+		{
+			name: "exit with open handle",
+			code: `open(my $fh, ">", "flush_exit.txt"); print $fh "exit\n"; exit 0; print $fh "never\n";`,
+			want: "exit\n",
+		},
+		{
+			name: "die with open handle",
+			code: `open(my $fh, ">", "flush_exit.txt"); say $fh "died"; die "stop\n";`,
+			want: "died\n",
+		},
+	}
+
+	run := map[string]func(*testing.T, string) (string, error){
+		"INTERP":  runInterpreter,
+		"COMPILE": runCompiled,
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for mode, fn := range run {
+				os.Remove("flush_exit.txt")
+				fn(t, tc.code)
+				got, err := os.ReadFile("flush_exit.txt")
+				if err != nil {
+					t.Errorf("[%s] %s: %v", mode, tc.name, err)
+				} else if string(got) != tc.want {
+					t.Errorf("[%s] %s: file = %q, want %q", mode, tc.name, got, tc.want)
+				}
+			}
+			os.Remove("flush_exit.txt")
+		})
+	}
+}
+
+func TestFileIOAutoflush(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "autoflush makes writes visible before close",
+			Code: `open(my $fh, ">", "autoflush.txt");
+$fh->autoflush(1);
+print $fh "first\n";
+open(my $in, "<", "autoflush.txt");
+my $line = <$in>;
+close($in);
+print "read: $line";
+close($fh);`,
+			ExpectedOutput: "read: first",
+			CleanupFiles:   []string{"autoflush.txt"},
+		},
+		{
+			Name:           "STDOUT autoflush and $| are accepted",
+			Code:           `$| = 1; STDOUT->autoflush(1); say "ok";`,
+			ExpectedOutput: "ok",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOSelect(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "select routes print, say and printf",
+			Code: `open(my $fh, ">", "select.txt");
+my $old = select($fh);
+print "a\n";
+say "b";
+printf("%d\n", 3);
+select($old);
+close($fh);
+say $old;
+open(my $in, "<", "select.txt");
+while (my $line = <$in>) {
+    print $line;
+}
+close($in);`,
+			ExpectedOutput: "main::STDOUT\na\nb\n3",
+			CleanupFiles:   []string{"select.txt"},
+		},
+		{
+			Name: "print with a block filehandle",
+			Code: `open(my $fh, ">", "select_block.txt");
+my %h = (out => $fh);
+print {$fh} "one\n";
+print {$h{out}} "two\n";
+print {STDOUT} "three\n";
+close($fh);
+open(my $in, "<", "select_block.txt");
+while (my $line = <$in>) {
+    print $line;
+}
+close($in);`,
+			ExpectedOutput: "three\none\ntwo",
+			CleanupFiles:   []string{"select_block.txt"},
+		},
+		{
+			Name: "printf takes a filehandle like print",
+			Code: `open(my $fh, ">", "printf_fh.txt");
+my $x = 8;
+printf $fh "%o\n", $x;
+printf {$fh} "%s|\n", "block";
+close($fh);
+printf STDOUT "%d\n", 3;
+printf "%o\n", $x;
+my @args = ("%s+%s\n", 1, 2);
+printf @args;
+open(my $in, "<", "printf_fh.txt");
+while (my $line = <$in>) {
+    print $line;
+}
+close($in);`,
+			ExpectedOutput: "3\n10\n1+2\n10\nblock|",
+			CleanupFiles:   []string{"printf_fh.txt"},
+		},
+		{
+			Name: "a glob reference is a filehandle",
+			Code: `my $o = \*STDOUT;
+print {$o} "block\n";
+print $o "scalar\n";
+printf {$o} "%d\n", 5;
+printf $o "%s\n", "printf";
+print ref($o), "\n";
+print *STDOUT, "\n";`,
+			ExpectedOutput: "block\nscalar\n5\nprintf\nGLOB\n*main::STDOUT",
+		},
+		{
+			Name: "$| autoflushes the selected handle",
+			Code: `open(my $fh, ">", "select_flush.txt");
+my $old = select($fh);
+$| = 1;
+print "first\n";
+select($old);
+open(my $in, "<", "select_flush.txt");
+my $line = <$in>;
+close($in);
+close($fh);
+print "read: $line";
+select(undef, undef, undef, 0.01);
+print STDERR "";
+say "done";`,
+			ExpectedOutput: "read: first\ndone",
+			CleanupFiles:   []string{"select_flush.txt"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOBinmode(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "raw layer reads and writes bytes",
+			Code: `sub size_of { my @st = stat($_[0]); return $st[7]; }
+open(my $out, ">", "binmode.bin");
+binmode($out);
+print {$out} chr(200), chr(65), chr(255);
+close($out);
+say size_of("binmode.bin");
+open(my $in, "<:raw", "binmode.bin");
+my $data = <$in>;
+close($in);
+my @c = split(//, $data);
+say length($data), " ", join(",", map { ord($_) } @c);
+open($in, "<", "binmode.bin");
+binmode($in, ":raw");
+my $buf;
+read($in, $buf, 10);
+close($in);
+say length($buf), " ", ord($buf);`,
+			ExpectedOutput: "3\n3 200,65,255\n3 200",
+			CleanupFiles:   []string{"binmode.bin"},
+		},
+		{
+			Name: "crlf and utf8 layers",
+			Code: `sub size_of { my @st = stat($_[0]); return $st[7]; }
+open(my $out, ">:crlf", "binmode_crlf.txt");
+print $out "a\nb\n";
+close($out);
+say size_of("binmode_crlf.txt");
+open(my $in, "<:crlf", "binmode_crlf.txt");
+my @l;
+while (my $line = <$in>) {
+    push @l, length($line);
+}
+close($in);
+say join(",", @l);
+open($out, ">:encoding(UTF-8)", "binmode_utf8.txt");
+print {$out} "caf", chr(233), "\n";
+close($out);
+say size_of("binmode_utf8.txt");
+open($in, "<:encoding(UTF-8)", "binmode_utf8.txt");
+my $u = <$in>;
+chomp $u;
+say length($u);
+say binmode($in, ":bogus") ? "ok" : "bad layer";
+close($in);
+say binmode(STDOUT, ":encoding(UTF-8)") ? "ok" : "bad";`,
+			ExpectedOutput: "6\n2,2\n6\n4\nbad layer\nok",
+			CleanupFiles:   []string{"binmode_crlf.txt", "binmode_utf8.txt"},
+		},
+		{
+			Name: "binmode without parentheses",
+			Code: `open(my $out, ">", "binmode_bare.bin");
+binmode $out, ':raw';
+print {$out} chr(200), "\n";
+close($out);
+open(my $in, "<", "binmode_bare.bin");
+binmode $in;
+my $data = <$in>;
+close($in);
+say length($data), " ", ord($data);
+binmode STDOUT, ':encoding(UTF-8)';
+say "ok";`,
+			ExpectedOutput: "2 200\nok",
+			CleanupFiles:   []string{"binmode_bare.bin"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileOperations(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "unlink and rename",
+			Code: `open(my $fh, ">", "ops_a.txt");
+close($fh);
+open($fh, ">", "ops_b.txt");
+close($fh);
+say rename("ops_a.txt", "ops_c.txt");
+say rename("ops_missing.txt", "ops_d.txt");
+say $!;
+say unlink("ops_b.txt", "ops_c.txt", "ops_missing.txt");
+my $left = -e "ops_c.txt";
+say $left ? "kept" : "gone";
+say unlink(".");
+say $!;`,
+			ExpectedOutput: "1\n0\nNo such file or directory\n2\ngone\n0\nIs a directory",
+			CleanupFiles:   []string{"ops_a.txt", "ops_b.txt", "ops_c.txt"},
+		},
+		{
+			Name: "mkdir and rmdir",
+			Code: `say mkdir("ops_dir");
+say mkdir("ops_dir");
+say $!;
+say mkdir("ops_dir2", 0700);
+printf("%o\n", (stat("ops_dir2"))[2] & 0777);
+open(my $fh, ">", "ops_dir/f.txt");
+close($fh);
+say rmdir("ops_dir");
+say $!;
+say rmdir("ops_dir/f.txt");
+say $!;
+unlink("ops_dir/f.txt");
+say rmdir("ops_dir"), rmdir("ops_dir2");
+my $left = -d "ops_dir";
+say $left ? "kept" : "gone";`,
+			ExpectedOutput: "1\n0\nFile exists\n1\n700\n0\nDirectory not empty\n0\nNot a directory\n11\ngone",
+			CleanupFiles:   []string{"ops_dir/f.txt", "ops_dir", "ops_dir2"},
+		},
+		{
+			Name: "chdir and cwd",
+			Code: `use Cwd;
+my $start = cwd();
+mkdir("ops_cd");
+say chdir("ops_cd");
+say cwd() eq "$start/ops_cd" ? "in" : "out";
+say getcwd() eq cwd() ? "same" : "differs";
+say chdir("ops_missing");
+say $!;
+say chdir($start);
+say rmdir("ops_cd");`,
+			ExpectedOutput: "1\nin\nsame\n0\nNo such file or directory\n1\n1",
+			CleanupFiles:   []string{"ops_cd"},
+		},
+		{
+			Name: "file builtins without parentheses",
+			Code: `my $d = "ops_bare";
+mkdir $d;
+chdir $d;
+open(my $fh, ">", "a.txt");
+close($fh);
+rename "a.txt", "b.txt";
+my $f = "b.txt";
+my @st = stat("b.txt");
+say scalar(@st);
+unlink $f;
+@st = stat("b.txt");
+say scalar(@st);
+chdir "..";
+rmdir $d;
+@st = stat($d);
+say scalar(@st);`,
+			ExpectedOutput: "13\n0\n0",
+			CleanupFiles:   []string{"ops_bare/a.txt", "ops_bare/b.txt", "ops_bare"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileIOReadLineList(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "readline in list context reads all lines",
+			Code: `open(my $out, ">", "readlines.txt") or die;
+print $out "a\nb\n\n\nc\nd\n";
+close($out);
+open(my $fh, "<", "readlines.txt") or die;
+my @lines = <$fh>;
+close($fh);
+print scalar(@lines), " lines, last $lines[5]";
+open($fh, "<", "readlines.txt") or die;
+my ($first, $second) = <$fh>;
+close($fh);
+print "first $first", "second $second";
+open($fh, "<", "readlines.txt") or die;
+my $n = 0;
+foreach my $l (<$fh>) { $n++; }
+close($fh);
+print "foreach $n\n";
+open($fh, "<", "readlines.txt") or die;
+print <$fh>;
+close($fh);`,
+			ExpectedOutput: "6 lines, last d\nfirst a\nsecond b\nforeach 6\na\nb\n\n\nc\nd",
+			CleanupFiles:   []string{"readlines.txt"},
+		},
+		{
+			Name: "$/ undef slurps and empty reads paragraphs",
+			Code: `open(my $out, ">", "readrs.txt") or die;
+print $out "a\nb\n\n\nc\nd\n";
+close($out);
+open(my $fh, "<", "readrs.txt") or die;
+{
+    local $/ = undef;
+    my $all = <$fh>;
+    print length($all), " bytes\n";
+}
+close($fh);
+open($fh, "<", "readrs.txt") or die;
+$/ = "";
+my @paras = <$fh>;
+$/ = "\n";
+close($fh);
+print scalar(@paras), " paragraphs\n";
+print "[$paras[0]]\n";
+print "[$paras[1]]\n";`,
+			ExpectedOutput: "10 bytes\n2 paragraphs\n[a\nb\n\n]\n[c\nd\n]",
+			CleanupFiles:   []string{"readrs.txt"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
@@ -3,6 +3,9 @@ package tests
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -220,6 +223,105 @@ func TestVariables(t *testing.T) {
 			Code:           `my $x = 5; my $y = 10; say "x=$x, y=$y, sum=" . ($x + $y);`,
 			ExpectedOutput: "x=5, y=10, sum=15",
 		},
+		{
+			Name: "ternary as lvalue",
+			Code: `my ($a, $b) = (1, 2);
+my $first = 0;
+($first ? $a : $b) = 5;
+($first ? $a : $b) += 1;
+say "$a $b";`,
+			ExpectedOutput: "1 6",
+		},
+		{
+			Name: "ternary lvalue on hash elements",
+			Code: `my %h;
+(1 ? $h{x} : $h{y}) = "left";
+say $h{x};`,
+			ExpectedOutput: "left",
+		},
+		{
+			Name: "assign then substitute",
+			Code: `my $path = "/usr/local/bin";
+(my $name = $path) =~ s/.*\///;
+say "$name $path";`,
+			ExpectedOutput: "bin /usr/local/bin",
+		},
+		{
+			Name:           "perl version",
+			Code:           `my $v = $^V; say "$v ", $]; say "modern" if $] >= 5.010 && "$^V" =~ /^v5\.\d+\.\d+$/;`,
+			ExpectedOutput: "v5.36.0 5.036000\nmodern",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestSpecialVarAssign(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "output field and record separators",
+			Code: `my @a = (1, 2);
+$, = "-";
+$\ = "!\n";
+print "a", @a;
+$, = undef;
+$\ = undef;
+print "b", @a, "\n";`,
+			ExpectedOutput: "a-1-2!\nb12",
+		},
+		{
+			Name:           "list separator in interpolation",
+			Code:           `my @l = (1, 2, 3); $" = ":"; print "@l\n";`,
+			ExpectedOutput: "1:2:3",
+		},
+		{
+			Name: "slices in interpolation",
+			Code: `my @a = (5, 6, 7); my %h = (x => 1, y => 2); my $r = \@a; my $i = 1;
+print "@a[0, 1]|@a[$i, -1]|@h{qw(x y)}|@{$r}[0, 2]|@$r[1]|@{a}[2]\n";`,
+			ExpectedOutput: "5 6|6 7|1 2|5 7|6|5 6 7[2]",
+		},
+		{
+			Name:           "autoflush reads back 0 or 1",
+			Code:           `print "$|\n"; $| = 5; print "$|\n"; $| = 0; print "$|\n";`,
+			ExpectedOutput: "0\n1\n0",
+		},
+		{
+			Name:           "program name",
+			Code:           `$0 = "mydaemon"; say $0;`,
+			ExpectedOutput: "mydaemon",
+		},
+		{
+			Name: "input record separator",
+			Code: `sub slurp { local $/; open(my $fh, "<", "sv_in.txt"); my $all = <$fh>; close($fh); return $all; }
+sub paras {
+    local $/ = "";
+    open(my $fh, "<", "sv_in.txt");
+    my @n;
+    while (my $p = <$fh>) { push(@n, length($p)); }
+    close($fh);
+    return join(",", @n);
+}
+say length(slurp());
+say paras();
+open(my $in, "<", "sv_in.txt");
+my $line = <$in>;
+close($in);
+print "[$line]";`,
+			ExpectedOutput: "8\n5,2\n[a\n]",
+			SetupFiles:     map[string]string{"sv_in.txt": "a\nb\n\n\nc\n"},
+		},
+		{
+			Name: "local restores the separators",
+			Code: `sub show { local $, = ","; local $\ = ".\n"; print "x", @_; }
+show(1, 2);
+my @a = (1, 2);
+print "y", @a, "\n";`,
+			ExpectedOutput: "x,1,2.\ny12",
+		},
 	}
 
 	for _, tc := range tests {
@@ -280,6 +382,20 @@ func TestArithmetic(t *testing.T) {
 			Code:           `say 3.14 * 2;`,
 			ExpectedMatch:  `6\.28`,
 		},
+		{
+			Name: "floats print with 15 significant digits",
+			Code: `my $x = 0.1 + 0.2;
+my @a = (1.1 * 1.1, 10 / 4, 1e15 * 10, 1.5e-7);
+say "$x ", 1 / 3;
+say "@a";`,
+			ExpectedOutput: "0.3 0.333333333333333\n1.21 2.5 1e+16 1.5e-07",
+		},
+		{
+			Name: "powers are integers only when perl's are",
+			Code: `my $n = 2;
+say 2**53, " ", $n ** 50, " ", 10**15, " ", 7**19, " ", 3**33, " ", 2**-1;`,
+			ExpectedOutput: "9.00719925474099e+15 1.12589990684262e+15 1000000000000000 11398895185373143 5.55906056655552e+15 0.5",
+		},
 		{
 			Name:           "increment",
 			Code:           `my $x = 5; $x++; say $x;`,
@@ -290,6 +406,25 @@ func TestArithmetic(t *testing.T) {
 			Code:           `my $x = 5; $x--; say $x;`,
 			ExpectedOutput: "4",
 		},
+		{
+			Name: "magic string increment",
+			Code: `my $id = "id0098";
+my @ids;
+my $i = 0;
+while ($i < 3) { push @ids, $id++; $i++; }
+my %h = (k => "Zz");
+$h{k}++;
+my $r = { n => "a9" };
+++$r->{n};
+my $f = "1.5";
+$f++;
+my $d = "aa";
+$d--;
+my $n;
+my $old = $n++;
+say join(",", @ids), " $id $h{k} $r->{n} $f $d $old $n";`,
+			ExpectedOutput: "id0098,id0099,id0100 id0101 AAa b0 2.5 -1 0 1",
+		},
 		{
 			Name:           "compound assignment +=",
 			Code:           `my $x = 10; $x += 5; say $x;`,
@@ -300,6 +435,21 @@ func TestArithmetic(t *testing.T) {
 			Code:           `my $x = 3; $x *= 4; say $x;`,
 			ExpectedOutput: "12",
 		},
+		{
+			Name: "integer and string loop variables",
+			Code: `my $sum = 0;
+my $s = "";
+for (my $i = 0; $i < 5; $i++) {
+    $sum += $i * 2;
+    $s .= $i;
+}
+my $n = 0;
+while ($n < 3) { $n++; }
+my $half = $sum;
+$half = $half / 4;
+say "$sum $s $n $half";`,
+			ExpectedOutput: "20 01234 3 5",
+		},
 	}
 
 	for _, tc := range tests {
@@ -400,6 +550,16 @@ func TestStringOperations(t *testing.T) {
 			Code:           `say sprintf("%.2f", 3.14159);`,
 			ExpectedOutput: "3.14",
 		},
+		{
+			Name:           "sprintf perl semantics",
+			Code:           `say sprintf("%x|%05s|%g|%d|%2\$s-%1\$s", -1, "ab", 0.1 + 0.2, "42abc");`,
+			ExpectedOutput: "ffffffffffffffff|000ab|0.3|42|ab--1",
+		},
+		{
+			Name:           "printf positional and star width",
+			Code:           `printf("%4\$s %1\$s|%*d|%-*s|\n", 4, 42, 3, "ab"); my @v = (7, "z"); printf("%03d %s\n", @v);`,
+			ExpectedOutput: "ab 4|  42|ab |\n007 z",
+		},
 	}
 
 	for _, tc := range tests {
@@ -455,6 +615,16 @@ func TestArrays(t *testing.T) {
 			Code:           `my @arr = split(",", "a,b,c"); say "@arr";`,
 			ExpectedOutput: "a b c",
 		},
+		{
+			Name:           "list repetition",
+			Code:           `my @s = (0) x 5; $s[1] = 1; my @p = (1, 2) x 2; my @w = qw(a b) x 2; say scalar(@s), " @s|@p|@w|", "-" x 3;`,
+			ExpectedOutput: "5 0 1 0 0 0|1 2 1 2|a b a b|---",
+		},
+		{
+			Name:           "array split awk mode and trailing fields",
+			Code:           `my @w = split(' ', "  a b  c "); my @f = split('\\.', "1.2..3.."); my @all = split(/,/, "x,,", -1); say scalar(@w), " @w|", scalar(@f), " @f|", scalar(@all);`,
+			ExpectedOutput: "3 a b c|4 1 2  3|3",
+		},
 		{
 			Name:           "array sort numeric",
 			Code:           `my @arr = (3, 1, 4, 1, 5); my @sorted = sort { $a <=> $b } @arr; say "@sorted";`,
@@ -465,6 +635,26 @@ func TestArrays(t *testing.T) {
 			Code:           `my @arr = ("banana", "apple", "cherry"); my @sorted = sort @arr; say "@sorted";`,
 			ExpectedOutput: "apple banana cherry",
 		},
+		{
+			Name:           "array sort descending",
+			Code:           `my @arr = (10, 2, 33); say join(",", sort { $b <=> $a } @arr);`,
+			ExpectedOutput: "33,10,2",
+		},
+		{
+			Name:           "array sort with named comparator",
+			Code:           `sub by_len { length($a) <=> length($b) } my @w = sort by_len ("ccc", "a", "bb"); say join(",", @w);`,
+			ExpectedOutput: "a,bb,ccc",
+		},
+		{
+			Name:           "array sort hash keys by value",
+			Code:           `my %h = (x => 3, y => 1, z => 2); say join(",", sort { $h{$a} <=> $h{$b} } keys %h);`,
+			ExpectedOutput: "y,z,x",
+		},
+		{
+			Name:           "array sort case-insensitive",
+			Code:           `say join(",", sort { lc($a) cmp lc($b) } "pear", "Apple", "fig");`,
+			ExpectedOutput: "Apple,fig,pear",
+		},
 		{
 			Name:           "array reverse",
 			Code:           `my @arr = (1, 2, 3); my @rev = reverse(@arr); say "@rev";`,
@@ -475,6 +665,48 @@ func TestArrays(t *testing.T) {
 			Code:           `my @arr = (10, 20, 30, 40, 50); my @slice = @arr[1, 3]; say "@slice";`,
 			ExpectedOutput: "20 40",
 		},
+		{
+			Name:           "array slice with range, negative and array indices",
+			Code:           `my @arr = (10, 20, 30, 40, 50); my @idx = (0, 2); my @r = @arr[1..3]; my @n = @arr[-1, 0]; my @i = @arr[@idx]; say "@r|@n|@i";`,
+			ExpectedOutput: "20 30 40|50 10|10 30",
+		},
+		{
+			Name:           "array slice assignment",
+			Code:           `my @arr = (1, 2, 3, 4); @arr[0, 1] = (9, 8); say "@arr"; @arr[0, 1] = @arr[1, 0]; say "@arr"; @arr[5, 6] = (6); say scalar(@arr), " $arr[5]";`,
+			ExpectedOutput: "9 8 3 4\n8 9 3 4\n7 6",
+		},
+		{
+			Name: "list assignment",
+			Code: `my ($a, $b) = (1, 2);
+($a, $b) = ($b, $a);
+my @list = (1, 2, 3, 4);
+my ($x, @rest);
+($x, @rest) = @list;
+my ($first, @tail) = @list;
+my $n = (($a, undef, $b) = (7, 8, 9, 10));
+say "$a $b $n|$x|@rest|@tail";`,
+			ExpectedOutput: "7 9 4|1|2 3 4|2 3 4",
+		},
+		{
+			Name: "chained list assignment",
+			Code: `my ($x, $y) = my @l = (1, 2, 3);
+my $n = my @m = (4, 5);
+my ($k) = my %h = (a => 1);
+my ($s, $t);
+($s, $t) = @l = (6, 7, 8);
+say "$x $y @l|$n @m|$k $h{a}|$s $t";`,
+			ExpectedOutput: "1 2 6 7 8|2 4 5|a 1|6 7",
+		},
+		{
+			Name: "whole array assignment copies",
+			Code: `my @a = (1, 2);
+my @b = @a;
+@a = (@a, 3);
+my $r = [];
+@$r = (5, [6]);
+say "@a|@b|", scalar(@$r), " ", ref($r->[1]);`,
+			ExpectedOutput: "1 2 3|1 2|2 ARRAY",
+		},
 		{
 			Name:           "array range",
 			Code:           `my @arr = (1..5); say "@arr";`,
@@ -485,6 +717,17 @@ func TestArrays(t *testing.T) {
 			Code:           `my @arr = (1, 2, 3); say $arr[-1];`,
 			ExpectedOutput: "3",
 		},
+		{
+			Name: "anonymous array flattens arrays, hashes and lists",
+			Code: `my @a = (1, 2); my %h = (k => 'v');
+sub two { return (3, 4) }
+my $r = [@a, 0, two(), map { $_ * 10 } @a];
+my $p = [%h];
+my $n = [[5, 6], \@a];
+say scalar(@$r), ": @$r"; say "@$p"; say scalar(@$n);
+push @a, 9; say scalar(@$r);`,
+			ExpectedOutput: "7: 1 2 0 3 4 10 20\nk v\n2\n7",
+		},
 	}
 
 	for _, tc := range tests {
@@ -492,6 +735,22 @@ func TestArrays(t *testing.T) {
 			runTest(t, tc)
 		})
 	}
+
+	// my (...) inside an expression is rejected by both backends
+	// instead of being run as something else
+	dir := t.TempDir()
+	path := filepath.Join(dir, "m.pl")
+	if err := os.WriteFile(path, []byte("my ($x) = my ($y, $z) = (1, 2);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "Parse error: line 1: my (...) is only supported at the start of a statement"
+	for mode, args := range map[string][]string{"INTERP": {path}, "COMPILED": {"-c", "-o", filepath.Join(dir, "m"), path}} {
+		out, err := exec.Command("./perlc", args...).CombinedOutput()
+		if err == nil {
+			t.Errorf("[%s] expected a non-zero exit", mode)
+		}
+		checkOutput(t, "my list in an expression", mode, string(out), want, "")
+	}
 }
 
 // ============================================================
@@ -530,6 +789,16 @@ func TestHashes(t *testing.T) {
 			Code:           `my %h = (a => 1, b => 2); delete $h{a}; say exists $h{a} ? "yes" : "no";`,
 			ExpectedOutput: "no",
 		},
+		{
+			Name:           "hash slice",
+			Code:           `my %h = (a => 1, b => 2, c => 3); my @v = @h{qw(a c)}; my ($x, $y) = @h{'b', 'a'}; say "@v $x $y";`,
+			ExpectedOutput: "1 3 2 1",
+		},
+		{
+			Name:           "hash slice assignment",
+			Code:           `my %h; my @keys = ("x", "y"); @h{@keys} = (7, 8); say "$h{x} $h{y}"; say join(",", sort keys %h);`,
+			ExpectedOutput: "7 8\nx,y",
+		},
 		{
 			Name:           "hash each",
 			Code: `my %h = (x => 10);
@@ -538,6 +807,55 @@ while (my ($k, $v) = each %h) {
 }`,
 			ExpectedOutput: "x=10",
 		},
+		{
+			Name: "hash list assignment",
+			Code: `my %h = (x => 1);
+my $ref = \%h;
+%h = ('a', 1, 'b', 2);
+my %copy = %h;
+say join(",", sort keys %$ref);
+%h = ();
+my ($k, $v);
+my %one = (only => 5);
+while (($k, $v) = each %one) {
+    say "$k=$v";
+}
+say scalar(keys %h), " $copy{b}";`,
+			ExpectedOutput: "a,b\nonly=5\n0 2",
+		},
+		{
+			Name: "keys in scalar and boolean context",
+			Code: `my %h = (a => 1, b => 2, c => 3);
+my %e;
+my $n = keys %h;
+my $m = values %h;
+say "$n $m";
+if (keys %e) { say "full"; } else { say "empty"; }
+say keys %h == 3 ? "three" : "other";
+while (keys %h) { delete $h{a}; delete $h{b}; delete $h{c}; }
+say scalar(keys %h);`,
+			ExpectedOutput: "3 3\nempty\nthree\n0",
+		},
+		{
+			Name: "keys presize and each with delete",
+			Code: `my %h;
+keys %h = 1000;
+foreach my $i (1..100) { $h{"k$i"} = $i; }
+my @p;
+my $sum = 0;
+while (@p = each %h) {
+    $sum += $p[1];
+    delete $h{$p[0]} if $p[1] > 50;
+}
+say "$sum ", scalar(keys %h);
+my $first = 0;
+while (@p = each %h) { $first++; last; }
+my @k = keys %h;
+my $count = 0;
+while (@p = each %h) { $count++; }
+say $count;`,
+			ExpectedOutput: "5050 50\n50",
+		},
 	}
 
 	for _, tc := range tests {
@@ -669,6 +987,53 @@ foreach my $x (@arr) {
 			Code:           `my $x; say $x // "undefined";`,
 			ExpectedOutput: "undefined",
 		},
+		{
+			Name: "given/when",
+			Code: `use feature 'switch';
+my @vals = (1, 3, "abc", "zed", 500, 7, "1.0");
+foreach my $v (@vals) {
+    given ($v) {
+        when (1) { say "$v: one"; }
+        when ([2, 3]) { say "$v: two or three"; }
+        when ("abc") { say "$v: abc"; }
+        when (/^z/) { say "$v: z"; }
+        when ($_ > 100) { say "$v: big"; }
+        default { say "$v: other"; }
+    }
+}`,
+			ExpectedOutput: "1: one\n3: two or three\nabc: abc\nzed: z\n500: big\n7: other\n1.0: one",
+		},
+		{
+			Name: "do while and until",
+			Code: `my $i = 10;
+do { $i++; } while ($i < 3);
+my $n = 0;
+do { $n += 2; } until $n >= 6;
+my $x = do { my $t = 20; $t * 2 + 2 };
+print "$i $n $x";`,
+			ExpectedOutput: "11 6 42",
+		},
+		{
+			Name: "labeled loops and redo",
+			Code: `my @rows = (1, 2, 3);
+OUTER: foreach my $r (@rows) {
+    foreach my $c (@rows) {
+        next OUTER if $c > $r;
+        last OUTER if $r == 3 && $c == 2;
+        print "$r$c ";
+    }
+}
+my $n = 0;
+my $tries = 0;
+LOOP: while ($n < 3) {
+    $n++;
+    $tries++;
+    redo LOOP if $tries == 2;
+    print "n=$n ";
+}
+print "tries=$tries";`,
+			ExpectedOutput: "11 21 22 31 n=1 n=3 tries=3",
+		},
 	}
 
 	for _, tc := range tests {
@@ -825,6 +1190,153 @@ say "@arr";
 say $s;`,
 			ExpectedOutput: "1 2 3\nscalar",
 		},
+		{
+			Name: "closure counter",
+			Code: `sub make_counter {
+    my $c = shift;
+    return sub { $c++; return $c };
+}
+my $c1 = make_counter(10);
+my $c2 = make_counter(100);
+$c1->();
+say $c1->(), " ", $c2->();`,
+			ExpectedOutput: "12 101",
+		},
+		{
+			Name: "closures capture loop variable",
+			Code: `my @subs;
+foreach my $i (1..3) {
+    push @subs, sub { return $i * 10 };
+}
+say join(",", map { $_->() } @subs);`,
+			ExpectedOutput: "10,20,30",
+		},
+		{
+			Name: "closure updates outer lexical",
+			Code: `my $total = 0;
+my $acc = sub { $total += shift };
+$acc->(5);
+$acc->(7);
+say $total;`,
+			ExpectedOutput: "12",
+		},
+		{
+			Name: "code ref call syntax",
+			Code: `my $add = sub { my ($x, $y) = @_; return $x + $y };
+sub twice { my $n = shift; return $n * 2 }
+my $ref = \&twice;
+say $add->(1, 2), " ", &$add(3, 4), " ", &{$add}(5, 6), " ", $ref->(21);`,
+			ExpectedOutput: "3 7 11 42",
+		},
+		{
+			Name: "constants and inlined subs",
+			Code: `use constant PI => 3.5;
+use constant { E => 2, NAME => 'perlc' };
+use constant DAYS => qw(Mon Tue Wed);
+use constant TWICE_E => 2 * E;
+sub square { return $_[0] * $_[0] }
+sub area { PI * square($_[0]) }
+sub shout { uc($_[0]) . "!" }
+my $r = 2;
+my @d = (DAYS);
+my $sq = \&square;
+say area($r), " ", E, " ", NAME, " ", scalar(@d), " $d[1] ", TWICE_E;
+say square(3), " ", shout(NAME), " ", square($r + 1), " ", $sq->(5), " ", PI();`,
+			ExpectedOutput: "14 2 perlc 3 Tue 4\n9 PERLC! 9 25 3.5",
+		},
+		{
+			Name: "parallel_map and parallel_foreach",
+			Code: `use perlc::parallel;
+my @nums = (1..20);
+my @sq = parallel_map(sub { my ($n) = @_; return $n * $n; }, @nums);
+my @pairs = parallel_map(sub { ($_[0], -$_[0]) }, 1..3);
+parallel_foreach(sub { my $x = shift; }, @nums);
+my $ok = eval { parallel_map(sub { die "bad $_[0]\n" if $_[0] == 5; $_[0] }, @nums); 1 };
+print "$sq[0] $sq[19] ", scalar(@sq), " ", join(",", @pairs), " $@";`,
+			ExpectedOutput: "1 400 20 1,-1,2,-2,3,-3 bad 5",
+		},
+		{
+			Name: "subroutine signatures",
+			Code: `use feature 'signatures';
+sub add($x, $y = 10, @rest) { return $x + $y + scalar(@rest); }
+sub opts($name, %o) { my @k = sort(keys(%o)); return "$name:" . join(",", map { "$_=$o{$_}" } @k); }
+my $f = sub ($a, $b = $a * 2) { "$a/$b" };
+my @l = (1, 2);
+print add(1), " ", add(1, 2), " ", add(@l, 3, 4), " ", opts("n", "b", 2, "a", 1), " ", $f->(3), "\n";
+eval { add() }; print $@;
+eval { $f->(1, 2, 3) }; print $@;`,
+			ExpectedOutput: "11 3 5 n:a=1,b=2 3/6\nToo few arguments for subroutine 'main::add' (got 0; expected at least 1)\nToo many arguments for subroutine 'main::__ANON__' (got 3; expected at most 2)",
+		},
+		{
+			Name: "$a and $b as lexicals and as sort variables",
+			Code: `use feature 'signatures';
+sub diff ($a, $b) { $a - $b }
+sub prod { my ($a, $b) = @_; return $a * $b; }
+my @s = sort { $b <=> $a } (3, 1, 2);
+print diff(5, 3), " ", prod(4, 5), " @s\n";
+foreach my $a (1 .. 2) { print "a=$a "; }
+my @t = sort { $a <=> $b } (2, 3, 1);
+print "@t\n";`,
+			ExpectedOutput: "2 20 3 2 1\na=1 a=2 1 2 3",
+		},
+		{
+			Name: "@_ aliases the arguments",
+			Code: `sub up { $_[0] =~ tr/a-z/A-Z/ }
+sub bump { $_[0]++ }
+sub second { my $first = shift; $_[0] = "y"; return $first }
+sub inner { return 1 }
+sub outer { inner(5); return $_[0] }
+my $z = "abc";
+my $copy = $z;
+up($z);
+my %h = (k => "hv");
+up($h{k});
+my $n = 5;
+bump($n);
+my ($p, $q) = ("a", "b");
+second($p, $q);
+print "$z $copy $h{k} $n $p $q ", outer(7), "\n";`,
+			ExpectedOutput: "ABC abc HV 6 a y 7",
+		},
+		{
+			Name: "Perlc::Chan and Perlc::spawn",
+			Code: `my $jobs = Perlc::Chan->new;
+my $results = Perlc::Chan->new(5);
+foreach my $w (1..3) {
+    Perlc::spawn(sub {
+        while (1) {
+            my $n = $jobs->recv;
+            last unless defined $n;
+            $results->send($n * $n);
+        }
+    });
+}
+foreach my $n (1..5) { $jobs->send($n); }
+$jobs->close;
+my $sum = 0;
+foreach my $n (1..5) { $sum += $results->recv; }
+Perlc::wait();
+my $c = Perlc::Chan->new(1);
+Perlc::spawn(sub { my ($x, @l) = @_; $c->send("$x:@l"); }, "a", 1, 2);
+my $ok = eval { $jobs->send(6); 1 };
+print "sum=$sum ", $c->recv, " ", $c->len, " $@";`,
+			ExpectedOutput: "sum=55 a:1 2 0 send on closed channel",
+		},
+		{
+			Name: "arrow chains of methods and subscripts",
+			Code: `sub Node::new { my ($class, %a) = @_; return bless \%a, $class; }
+sub Node::child { my ($self) = @_; return $self->{child}; }
+sub Node::data { my ($self, $n) = @_; return { "key" => [$n, $n * 2], "code" => sub { return { "r" => "c$_[0]" } } }; }
+sub Node::name { my ($self) = @_; return $self->{name}; }
+sub Node::kids { my ($self) = @_; return [$self->{child}]; }
+my $leaf = Node->new("name", "leaf");
+my $root = Node->new("name", "root", "child", $leaf);
+my $m = "name";
+my $code = sub { my ($self, $x) = @_; return ref($self) . $x; };
+print $root->child->data(3)->{key}[1], " ", $root->data(5)->{code}->(7)->{r}, " ", $root->kids->[0]{name}, "\n";
+print $root->child ? $root->child->data(2)->{key}->[1] : 0, " ", $root->$m, " ", $root->child->$m(), " ", $root->$code(5);`,
+			ExpectedOutput: "6 c7 leaf\n4 root leaf Node5",
+		},
 	}
 
 	for _, tc := range tests {
@@ -901,6 +1413,180 @@ say $data->{name};
 say $data->{scores}[1];`,
 			ExpectedOutput: "Alice\n85",
 		},
+		{
+			Name: "code ref call",
+			Code: `my $add = sub { $_[0] + $_[1] };
+say $add->(2, 3);`,
+			ExpectedOutput: "5",
+		},
+		{
+			Name: "call on call result",
+			Code: `sub get_handler { return sub { "h:" . join(",", @_) } }
+say get_handler()->(1, 2);
+say((get_handler())->(3));`,
+			ExpectedOutput: "h:1,2\nh:3",
+		},
+		{
+			Name: "subscripts on call result",
+			Code: `sub data { return [ { k => "v0" }, { k => "v1", l => [7, 8] } ] }
+say data()->[1]{k};
+say data()->[1]{l}[0];`,
+			ExpectedOutput: "v1\n7",
+		},
+		{
+			Name: "call through subscripts",
+			Code: `my %ops = (add => sub { $_[0] + $_[1] }, neg => sub { -$_[0] });
+my $t = { ops => \%ops };
+my @subs = (sub { "zero" }, sub { "one" });
+say $ops{add}->(4, 5);
+say $t->{ops}{neg}(6);
+say $subs[1]();`,
+			ExpectedOutput: "9\n-6\none",
+		},
+		{
+			Name: "array and hash dereference",
+			Code: `my $r = [1, 2, 3];
+my $h = { a => 1, b => 2 };
+my @x = @$r; my @y = @{$r};
+my %k = %$h; my %k2 = %{$h};
+say scalar(@x) + scalar(@y);
+say join(",", sort keys %k), " ", $k2{b};
+say join(",", sort keys %$h);
+foreach my $e (@{$r}) { print $e; } print "\n";`,
+			ExpectedOutput: "6\na,b 2\na,b\n123",
+		},
+		{
+			Name: "postfix dereference",
+			Code: `my $r = [4, 5];
+my $h = { x => 9 };
+my $s = \"str";
+my %c = $h->%*;
+say join(",", $r->@*), " ", $c{x}, " ", $s->$*;`,
+			ExpectedOutput: "4,5 9 str",
+		},
+		{
+			Name: "elements, slices and code through dereference",
+			Code: `my $r = [10, 20, 30];
+my $h = { a => 1, b => 2 };
+$$r[0] = 11; ${$r}[1] = 21; $$h{c} = 3;
+say "$$r[0] ${$r}[1] $$h{c}";
+say join(",", @{$r}[1, 2]), " ", join(",", @$h{'a', 'b'});
+my $c = sub { "called @_" };
+say &$c(1), "; ", &{$c}(2);`,
+			ExpectedOutput: "11 21 3\n21,30 1,2\ncalled 1; called 2",
+		},
+		{
+			Name: "push, pop, shift and unshift through dereference",
+			Code: `my $r = [1, 2];
+my $h = { list => [] };
+push @$r, 3; push @{$h->{list}}, "a", "b";
+my $last = pop @{$r}; my $first = shift @$r;
+unshift @$r, 0;
+say "$first $last @$r ", scalar(@{$h->{list}}), " ", 7 %$first;`,
+			ExpectedOutput: "1 3 0 2 2 0",
+		},
+		{
+			Name: "nested autovivification",
+			Code: `my %h;
+$h{a}{b}[2]{c} = 1;
+my $r;
+$r->{x}[1] = 5;
+$r->{x}[1] += 2;
+my @a;
+$a[1]{k} = "v";
+push @{$h{list}}, 1, 2;
+say ref($h{a}), " ", ref($h{a}{b}), " ", scalar(@{$h{a}{b}}), " ", $h{a}{b}[2]{c};
+say ref($r), " $r->{x}[1] $a[1]{k} ", scalar(@{$h{list}});`,
+			ExpectedOutput: "HASH ARRAY 3 1\nHASH 7 v 2",
+		},
+		{
+			Name: "autovivified elements are not shared",
+			Code: `my %count;
+foreach my $w (split / /, "a b a c a") {
+    $count{$w}++;
+}
+my $u;
+my %p = (x => $u, y => $u);
+$p{x}{k} = 1;
+say "$count{a} $count{b} $count{c} ", defined($p{y}) ? "shared" : "copy";`,
+			ExpectedOutput: "3 1 1 copy",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestStringify(t *testing.T) {
+	// print, say, interpolation and . all stringify the same way
+	tests := []TestCase{
+		{
+			Name: "overloaded object",
+			Code: `package Point;
+use overload '""' => sub { my $p = shift; return "(" . $p->{x} . ", " . $p->{y} . ")"; };
+package main;
+sub Point::new { my ($class, $x, $y) = @_; my $self = { x => $x, y => $y }; return bless $self, $class; }
+my $p = Point->new(1, 2);
+print $p, "\n";
+say $p;
+print "at $p\n";
+my $s = "p=" . $p;
+say $s;
+say "equal" if $p eq "(1, 2)";`,
+			ExpectedOutput: "(1, 2)\n(1, 2)\nat (1, 2)\np=(1, 2)\nequal",
+		},
+		{
+			Name: "overload inherited",
+			Code: `sub Shape::name { my $self = shift; return "shape " . $self->{id}; }
+package Shape;
+use overload '""' => \&Shape::name, 'fallback' => 1;
+package main;
+sub Circle::new { my ($class, $id) = @_; my $self = { id => $id }; return bless $self, $class; }
+@Circle::ISA = ('Shape');
+my $c = Circle->new(7);
+say "$c";`,
+			ExpectedOutput: "shape 7",
+		},
+		{
+			Name: "plain object",
+			Code: `sub Plain::new { my ($class) = @_; my $self = { v => 1 }; return bless $self, $class; }
+my $o = Plain->new;
+print $o, "\n";`,
+			ExpectedMatch: `^Plain=HASH\(0x[0-9a-f]+\)$`,
+		},
+		{
+			Name: "unblessed references",
+			Code: `my @a = (1); my %h; my $s = 1;
+my $ar = \@a;
+print $ar, "\n";
+say "$ar|", \%h, "|", \$s, "|", sub { 1 }, "|", \$ar;
+say sprintf("%s %s", [1], {});
+say ref(\$ar);`,
+			ExpectedMatch: `^ARRAY\(0x[0-9a-f]+\)\nARRAY\(0x[0-9a-f]+\)\|HASH\(0x[0-9a-f]+\)\|SCALAR\(0x[0-9a-f]+\)\|CODE\(0x[0-9a-f]+\)\|REF\(0x[0-9a-f]+\)\nARRAY\(0x[0-9a-f]+\) HASH\(0x[0-9a-f]+\)\nREF$`,
+		},
+		{
+			Name: "references compare and numify by address",
+			Code: `my @a = (1, 2); my $r = \@a; my $b = [1, 2];
+my @c = ($r == \@a ? 1 : 0, $r != $b ? 1 : 0, $r + 0 > 0 ? 1 : 0, "$r" eq "$r" ? 1 : 0, "$r" ne "$b" ? 1 : 0);
+say "@c";
+my $n = @a;
+say $n + 0, " ", @a + 1;`,
+			ExpectedOutput: "1 1 1 1 1\n2 3",
+		},
+		{
+			Name: "filehandle glob",
+			Code: `open(my $fh, '>', '/tmp/perlc_glob_test.txt') or die "open: $!";
+my $name = "$fh";
+print $fh "line\n";
+close($fh);
+say ref($fh);
+say $name;`,
+			ExpectedMatch: `^GLOB\nGLOB\(0x[0-9a-f]+\)$`,
+			CleanupFiles:  []string{"/tmp/perlc_glob_test.txt"},
+		},
 	}
 
 	for _, tc := range tests {
@@ -986,171 +1672,1676 @@ func TestRegex(t *testing.T) {
 			Code:           `my $s = "hello world"; my $cnt = ($s =~ tr/o/o/); say $cnt;`,
 			ExpectedOutput: "2",
 		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.Name, func(t *testing.T) {
-			runTest(t, tc)
-		})
-	}
-}
-
-// ============================================================
-// File I/O Tests
-// ============================================================
-
-func TestFileIO(t *testing.T) {
-	tests := []TestCase{
 		{
-			Name: "write and read file",
-			Code: `my $fh;
-open($fh, ">", "test_io.txt");
-print $fh "Line 1\n";
-print $fh "Line 2\n";
-close($fh);
-
-open($fh, "<", "test_io.txt");
-my $line1 = <$fh>;
-my $line2 = <$fh>;
-close($fh);
-
-chomp($line1);
-chomp($line2);
-say "1: $line1";
-say "2: $line2";`,
-			ExpectedOutput: "1: Line 1\n2: Line 2",
-			CleanupFiles:   []string{"test_io.txt"},
+			Name:           "subst on hash element",
+			Code:           `my %h = (key => "xoxo"); $h{key} =~ s/x/y/g; say $h{key};`,
+			ExpectedOutput: "yoyo",
 		},
 		{
-			Name: "read existing file",
-			Code: `open(my $fh, "<", "input_test.txt");
-my $content = <$fh>;
-close($fh);
-chomp($content);
-say "Got: $content";`,
-			ExpectedOutput: "Got: Hello from test file",
-			SetupFiles: map[string]string{
-				"input_test.txt": "Hello from test file\n",
-			},
+			Name:           "match and subst through reference",
+			Code:           `my $r = { name => "Alice" }; $r->{name} =~ s/A/a/ if $r->{name} =~ /^Al/; say $r->{name};`,
+			ExpectedOutput: "alice",
 		},
 		{
-			Name: "append mode",
-			Code: `open(my $fh, ">", "append_test.txt");
-print $fh "Line 1\n";
-close($fh);
-
-open($fh, ">>", "append_test.txt");
-print $fh "Line 2\n";
-close($fh);
-
-open($fh, "<", "append_test.txt");
-my @lines;
-while (my $line = <$fh>) {
-    chomp($line);
-    push(@lines, $line);
-}
-close($fh);
-say join(", ", @lines);`,
-			ExpectedOutput: "Line 1, Line 2",
-			CleanupFiles:   []string{"append_test.txt"},
+			Name:           "subst on nested array element",
+			Code:           `my $r = { list => ["aaa", "bab"] }; $r->{list}[1] =~ s/b/c/g; say $r->{list}->[1];`,
+			ExpectedOutput: "cac",
 		},
 		{
-			Name: "say to filehandle",
-			Code: `open(my $fh, ">", "say_test.txt");
-say $fh "Hello";
-say $fh "World";
-close($fh);
-
-open($fh, "<", "say_test.txt");
-my $l1 = <$fh>;
-my $l2 = <$fh>;
-close($fh);
-chomp($l1);
-chomp($l2);
-say "$l1 $l2";`,
-			ExpectedOutput: "Hello World",
-			CleanupFiles:   []string{"say_test.txt"},
+			Name:           "tr on sub argument",
+			Code:           `sub up { $_[0] =~ tr/abc/ABC/; return $_[0] } say up("aabbcx");`,
+			ExpectedOutput: "AABBCx",
 		},
 		{
-			Name: "3-arg open read",
-			Code: `open(my $fh, "<", "three_arg_test.txt");
-my $line = <$fh>;
-close($fh);
-chomp($line);
-say $line;`,
-			ExpectedOutput: "Test content",
-			SetupFiles: map[string]string{
-				"three_arg_test.txt": "Test content\n",
-			},
+			Name:           "tr count on array element",
+			Code:           `my @a = ("a/b/c", "d"); my $n = ($a[0] =~ tr/\//_/); say "$n $a[0]";`,
+			ExpectedOutput: "2 a_b_c",
 		},
 		{
-			Name: "open return value",
-			Code: `my $result = open(my $fh, "<", "nonexistent_file_xyz.txt");
-say $result ? "opened" : "failed";`,
-			ExpectedOutput: "failed",
+			Name:           "tr count only with range",
+			Code:           `my $t = "Hello World"; my $n = ($t =~ tr/a-z//); say "$n $t";`,
+			ExpectedOutput: "8 Hello World",
 		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.Name, func(t *testing.T) {
-			runTest(t, tc)
-		})
-	}
-}
-
-// ============================================================
-// Built-in Function Tests
-// ============================================================
-
-func TestBuiltinFunctions(t *testing.T) {
-	tests := []TestCase{
 		{
-			Name:           "abs positive",
-			Code:           `say abs(42);`,
-			ExpectedOutput: "42",
+			Name:           "tr complement delete and squeeze",
+			Code:           `my $w = "hello, world!"; $w =~ tr/a-zA-Z//cd; my $x = "a  b   c"; $x =~ tr/a-zA-Z/ /cs; say "$w|$x";`,
+			ExpectedOutput: "helloworld|a b c",
 		},
 		{
-			Name:           "abs negative",
-			Code:           `say abs(-42);`,
-			ExpectedOutput: "42",
+			Name:           "tr delete and short replacement",
+			Code:           `my $y = "abcdef"; $y =~ tr/a-c/A/d; my $e = "abc"; $e =~ tr/a-c/A-/; say "$y $e";`,
+			ExpectedOutput: "Adef A--",
 		},
 		{
-			Name:           "int",
-			Code:           `say int(3.7);`,
-			ExpectedOutput: "3",
+			Name:           "tr squeeze on a copy and r flag",
+			Code:           `my $u = "aabbccdd"; (my $v = $u) =~ tr/a-c//s; my $z = "hello"; my $r = ($z =~ tr/a-y/b-z/r); say "$u $v $z $r";`,
+			ExpectedOutput: "aabbccdd abcdd hello ifmmp",
 		},
 		{
-			Name:           "sqrt",
-			Code:           `say sqrt(16);`,
-			ExpectedOutput: "4",
+			Name:           "interpolated scalar in pattern",
+			Code:           `my $prefix = "id"; say "id42" =~ /^$prefix\d+$/ ? "yes" : "no";`,
+			ExpectedOutput: "yes",
 		},
 		{
-			Name:           "sin",
-			Code:           `say int(sin(0) * 100);`,
-			ExpectedOutput: "0",
+			Name:           "qr object interpolated into pattern",
+			Code:           `my $re = qr/b+/i; say "aBBc" =~ m/^a${re}c$/ ? "yes" : "no";`,
+			ExpectedOutput: "yes",
 		},
 		{
-			Name:           "cos",
-			Code:           `say int(cos(0) * 100);`,
-			ExpectedOutput: "100",
+			Name:           "quotemeta with Q and E escapes",
+			Code:           `my $dot = "a.b"; say "a.b" =~ /^\Q$dot\E$/ ? "yes" : "no", "axb" =~ /^\Q$dot\E$/ ? "yes" : "no";`,
+			ExpectedOutput: "yesno",
 		},
 		{
-			Name:           "log",
-			Code:           `say int(log(2.718281828) * 100);`,
-			ExpectedOutput: "100",
+			Name:           "substitution with interpolated pattern",
+			Code:           `my $s = "foo bar foo"; my $w = "foo"; $s =~ s/$w/baz/g; say $s;`,
+			ExpectedOutput: "baz bar baz",
 		},
 		{
-			Name:           "exp",
-			Code:           `say int(exp(1) * 100);`,
-			ExpectedOutput: "271",
+			Name:           "split on interpolated pattern",
+			Code:           `my $sep = ","; my @p = split /$sep\s*/, "a, b,c,,"; say join("|", @p), " ", scalar(@p);`,
+			ExpectedOutput: "a|b|c 3",
 		},
 		{
-			Name:           "rand",
-			Code:           `my $r = rand(); say $r >= 0 && $r < 1 ? "ok" : "fail";`,
-			ExpectedOutput: "ok",
+			Name:           "split into characters and with limit",
+			Code:           `say join("-", split //, "abc"), " ", join("|", split(/,/, "a,b,c", 2));`,
+			ExpectedOutput: "a-b-c a|b,c",
 		},
 		{
-			Name:           "defined",
+			Name:           "split keeps captured separators",
+			Code:           `say join("|", split /(,)/, "a,b"), " ", join("|", split(/(-)|(,)/, "a,b-c")), " ", join("|", split(/\s*(=)\s*/, "k = v = w", 2));`,
+			ExpectedOutput: "a|,|b a||,|b|-||c k|=|v = w",
+		},
+		{
+			Name:           "multiline and single-line flags",
+			Code:           `my $t = "one\ntwo"; say $t =~ /^two$/m ? "m" : "-", $t =~ /^two$/ ? "x" : "-", $t =~ /one.two/s ? "s" : "-", $t =~ /one.two/ ? "x" : "-";`,
+			ExpectedOutput: "m-s-",
+		},
+		{
+			Name:           "extended pattern with comments",
+			Code:           "my $d = \"2024-05\"; $d =~ /(\\d{4})  # year\n  - (\\d\\d) # month\n/x; say $1, \"/\", $2, \"a b\" =~ /a[ ]b/x ? \" class\" : \"\";",
+			ExpectedOutput: "2024/05 class",
+		},
+		{
+			Name:           "scalar match with g iterates with pos",
+			Code:           `my $s = "a1b22c333"; my $n = 0; while ($s =~ /(\d+)/g) { print $1, ":", pos($s), " "; $n++; } say "n=$n", defined(pos($s)) ? " set" : " reset";`,
+			ExpectedOutput: "1:2 22:5 333:9 n=3 reset",
+		},
+		{
+			Name:           "gc keeps pos after a failed match",
+			Code:           `my $s = "aaa bbb"; $s =~ /\w+/g; $s =~ /zzz/gc; print pos($s); $s =~ /zzz/g; say defined(pos($s)) ? " kept" : " reset";`,
+			ExpectedOutput: "3 reset",
+		},
+		{
+			Name:           "o compiles an interpolated pattern once",
+			Code:           `my @l = ("foo", "bar"); my $n = 0; foreach my $w (@l) { $n++ if $w =~ /^$w$/o; } say $n;`,
+			ExpectedOutput: "1",
+		},
+		{
+			Name:           "match variables",
+			Code:           `if ("say hello world" =~ /(h\w+) (?<w>w\w+)/) { say "[$1|$2|$&|$`+"`"+`|$'|$+{w}]"; } "ab" =~ /(x)?b/; say defined($1) ? "def" : "undef";`,
+			ExpectedOutput: "[hello|world|hello world|say ||world]\nundef",
+		},
+		{
+			Name:           "list context match returns captures",
+			Code:           `my ($k, $v) = "key: val" =~ /(\w+):\s*(\w+)/; my @none = "abc" =~ /z/; my @one = "abc" =~ /b/; say "$k=$v ", scalar(@none), " @one";`,
+			ExpectedOutput: "key=val 0 1",
+		},
+		{
+			Name:           "substitution replacement sees the match",
+			Code:           `my $x = "-"; my $s = "ab cd"; $s =~ s/(\w)(\w)/$2$x$1[$&]/g; say "$s $1";`,
+			ExpectedOutput: "b-a[ab] d-c[cd] c",
+		},
+		{
+			Name:           "global match in list context",
+			Code:           `my @d = "a1b22c333" =~ /(\d+)/g; my @p = "a=1,b=2" =~ /(\w)=(\d)/g; my @w = "x y z" =~ /\w/g; say "@d|@p|@w";`,
+			ExpectedOutput: "1 22 333|a 1 b 2|x y z",
+		},
+		{
+			Name:           "global match count and multiline",
+			Code:           `my $n = () = "aaa" =~ /a/g; my @l = "l1\nl2\n" =~ /^(l\d)$/mg; say "$n @l";`,
+			ExpectedOutput: "3 l1 l2",
+		},
+		{
+			Name:           "substitution with e flag",
+			Code:           `(my $s = "1 2 3") =~ s/(\d)/$1*2/ge; (my $t = "a-b") =~ s/(\w)/uc($1) . "!"/e; say "$s $t";`,
+			ExpectedOutput: "2 4 6 A!-b",
+		},
+		{
+			Name:           "substitution returns the count",
+			Code:           `my $s = "abc"; my $n = ($s =~ s/\w/./g); say "$s $n";`,
+			ExpectedOutput: "... 3",
+		},
+		{
+			Name: "named captures",
+			Code: `if ("2024-05-17" =~ /(?<y>\d+)-(?<m>\d+)-(?'d'\d+)/) { my @k = sort keys %+; say "$+{d}.$+{m}.$+{y} @k"; }
+say exists($+{m}) ? "yes" : "no", exists($+{zz}) ? "yes" : "no";
+(my $s = "john smith") =~ s/(?<f>\w+) (?<l>\w+)/$+{l}, $+{f}/; say $s;`,
+			ExpectedOutput: "17.05.2024 d m y\nyesno\nsmith, john",
+		},
+		{
+			Name: "named captures with repeated names",
+			Code: `if ("b" =~ /(?<x>a)|(?<x>b)/) { say "$+{x} ", scalar(@{$-{x}}), " ", defined($-{x}[0]) ? "def" : "undef"; }
+if ("a1b2" =~ /(?<l>[a-z])\d(?<l>[a-z])/) { say "$-{l}[0]$-{l}[1] $+{l}"; }`,
+			ExpectedOutput: "b 2 undef\nab a",
+		},
+		{
+			Name: "backreferences and lookaround",
+			Code: `my @d = "aa bb cd ee" =~ /(\w)\1/g; (my $u = "abcabc abab") =~ s/(\w+)\1/<$1>/g; say "@d $u";
+say "foobaz foobar" =~ /foo(?=bar)(\w+)/ ? $1 : "no";
+my @p = split /(?<=,)/, "a,b,c"; say join("|", @p);
+say "cost: \$42" =~ /(?<=\$)(\d+)(?!\d)/ ? $1 : "no";`,
+			ExpectedOutput: "a b e <abc> <ab>\nbar\na,|b,|c\n42",
+		},
+		{
+			Name:           "possessive quantifiers and end anchors",
+			Code:           `say "aaa" =~ /a++a/ ? "yes" : "no"; say "x\n" =~ /x\Z/ ? "yes" : "no"; say "x\n" =~ /\Ax\z/ ? "yes" : "no";`,
+			ExpectedOutput: "no\nyes\nno",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Eval Tests
+// ============================================================
+
+func TestEvalString(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name:           "eval string sees lexicals",
+			Code:           `my $x = 10; my $r = eval '$x * 2 + 1'; say $r;`,
+			ExpectedOutput: "21",
+		},
+		{
+			Name:           "eval string lexicals stay inside",
+			Code:           `my $x = 1; my $y = 2; say eval('my $y = 5; $x + $y'); say $y;`,
+			ExpectedOutput: "6\n2",
+			// compiled blocks do not shadow outer lexicals yet
+			SkipCompile: true,
+		},
+		{
+			Name:           "die in eval string sets error",
+			Code:           `my $v = eval "die 'boom\n'; 1"; print defined($v) ? "def " : "undef ", $@;`,
+			ExpectedOutput: "undef boom",
+		},
+		{
+			Name:           "die in eval string names the eval",
+			Code:           `eval q{die "boom"}; print $@; eval "1;\ndie 'two'"; print $@;`,
+			ExpectedOutput: "boom at (eval 1) line 1.\ntwo at (eval 2) line 2.",
+		},
+		{
+			Name:           "syntax error in eval string",
+			Code:           `my $v = eval "1 +* ;"; say defined($v) ? "def" : "undef", " ", $@ =~ /^syntax error/ ? "syntax" : "none";`,
+			ExpectedOutput: "undef syntax",
+		},
+		{
+			Name:           "successful eval clears error",
+			Code:           `eval "die 'x'"; eval "1"; say "[", $@, "]";`,
+			ExpectedOutput: "[]",
+		},
+		{
+			Name:           "return leaves only the eval",
+			Code:           `my $x = 0; my $r = eval '$x = 42; return 5; 6'; say "$r $x";`,
+			ExpectedOutput: "5 42",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Exception Tests
+// ============================================================
+
+func TestDieObjects(t *testing.T) {
+	// die with a reference puts the reference itself into $@
+	classes := `sub MyErr::new { my ($class, %a) = @_; my $self = \%a; return bless $self, $class; }
+sub MyErr::message { my $self = shift; return $self->{message}; }
+sub MyErr::throw { my ($class, $msg) = @_; die $class->new(message => $msg); }
+@NotFound::ISA = ('MyErr');
+`
+	tests := []TestCase{
+		{
+			Name:           "die with a hash reference",
+			Code:           `eval { die { code => 404 } }; say ref($@), " ", $@->{code};`,
+			ExpectedOutput: "HASH 404",
+		},
+		{
+			Name: "fields of $@ interpolated",
+			Code: classes + `eval { die { code => 404, msg => "not found" } }; print "code $@->{code} ($@->{msg})\n";
+eval { die [500, "oops"] }; print "got $@->[0]: $@->[1]\n";
+eval { NotFound->throw("gone") }; print "message $@->{message}\n";`,
+			ExpectedOutput: "code 404 (not found)\ngot 500: oops\nmessage gone",
+		},
+		{
+			Name: "die with an exception object",
+			Code: classes + `eval { NotFound->throw("gone") };
+if (ref($@) && $@->isa('MyErr')) { say "caught ", ref($@), ": ", $@->message; }
+say $@->isa('NotFound') ? 1 : 0, $@->isa('Other') ? 1 : 0;`,
+			ExpectedOutput: "caught NotFound: gone\n10",
+		},
+		{
+			Name: "rethrow an object",
+			Code: classes + `eval { eval { die [1, 2] }; die $@ if ref $@; };
+say "rethrown ", ref($@), " ", scalar(@{$@});
+eval { eval { NotFound->throw("again") }; die; };
+say "bare die: ", ref($@), " ", $@->message;`,
+			ExpectedOutput: "rethrown ARRAY 2\nbare die: NotFound again",
+		},
+		{
+			Name:          "bare die propagates a message",
+			Code:          `eval { eval { die "inner\n" }; die; }; print $@;`,
+			ExpectedMatch: `^inner\n\t\.\.\.propagated at \S+\.pl line 1\.$`,
+		},
+		{
+			Name: "ref $@ && $@->isa tells objects from messages",
+			Code: classes + `eval { NotFound->throw("gone") };
+say "object: ", $@->message if ref $@ && $@->isa("MyErr");
+eval { die "plain" };
+say "message" unless ref $@ && $@->isa("MyErr");
+print $@;`,
+			ExpectedMatch: `^object: gone\nmessage\nplain at \S+\.pl line 7\.$`,
+		},
+		{
+			Name:           "die with a list joins it",
+			Code:           `my @parts = ("a", "b"); eval { die @parts, "\n" }; print $@; eval { die 42 }; say $@ + 1;`,
+			ExpectedOutput: "ab\n43",
+		},
+		{
+			// The runner appends stderr to the interpreter output only
+			Name:          "uncaught object",
+			Code:          classes + `die MyErr->new(message => "fatal");`,
+			ExpectedMatch: `^MyErr=HASH\(0x[0-9a-f]+\)$`,
+			SkipCompile:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestCarp(t *testing.T) {
+	// croak reports the caller outside the package, confess the whole stack
+	tests := []TestCase{
+		{
+			Name: "croak reports the caller",
+			Code: `use Carp;
+sub Lib::check { my $v = shift; croak "negative value" if $v < 0; return $v }
+sub user { Lib::check(-1) }
+eval { user() };
+print $@;`,
+			ExpectedMatch: `^negative value at \S+\.pl line 3\.$`,
+		},
+		{
+			Name: "croak within a package becomes a backtrace",
+			Code: `use Carp;
+sub f { croak "bad thing" }
+sub g { f(1, "a b", undef) }
+eval { g() };
+print $@;`,
+			ExpectedMatch: `^bad thing at \S+ line 2\.
+	main::f\(1, "a b", undef\) called at \S+ line 3
+	main::g\(\) called at \S+ line 4
+	eval \{\.\.\.\} called at \S+ line 4$`,
+		},
+		{
+			Name: "confess prints the stack",
+			Code: `use Carp;
+sub Lib::deep { confess "deep trouble" }
+sub Lib::outer { my $n = Lib::deep(42); return $n }
+eval { Lib::outer() };
+print $@;
+eval { croak { code => 7 } };
+say ref($@), " ", $@->{code};`,
+			ExpectedMatch: `^deep trouble at \S+ line 2\.
+	Lib::deep\(42\) called at \S+ line 3
+	Lib::outer\(\) called at \S+ line 4
+	eval \{\.\.\.\} called at \S+ line 4
+HASH 7$`,
+		},
+		{
+			// The runner appends stderr to the interpreter output only
+			Name: "carp and cluck warn",
+			Code: `use Carp qw(carp cluck);
+sub Lib::w { carp "careful" }
+sub Lib::c { cluck "look" }
+Lib::w();
+Lib::c();
+say "done";`,
+			ExpectedMatch: `^done
+careful at \S+ line 4\.
+look at \S+ line 3\.
+	Lib::c\(\) called at \S+ line 5$`,
+			SkipCompile: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Local Tests
+// ============================================================
+
+func TestLocal(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "local scalar is seen by called subs",
+			Code: `our $x = "global";
+sub show { say $x; }
+sub test { local $x = "local"; show(); }
+test();
+show();`,
+			ExpectedOutput: "local\nglobal",
+		},
+		{
+			Name: "local array and hash are restored",
+			Code: `my @a = (1, 2, 3);
+my %h = (k => "v");
+my $show = sub { say join(",", @a), " ", join(",", sort keys %h); };
+eval { local @a = (9, 8); local %h = (z => 1, y => 2); $show->(); };
+$show->();`,
+			ExpectedOutput: "9,8 y,z\n1,2,3 k",
+		},
+		{
+			Name:           "local without value empties",
+			Code:           `my @a = (1, 2); my %h = (k => 1); eval { local @a; local %h; say scalar(@a), " ", scalar(keys %h); }; say scalar(@a), " ", scalar(keys %h);`,
+			ExpectedOutput: "0 0\n2 1",
+		},
+		{
+			Name:           "local array and hash elements",
+			Code:           `my @a = (1, 2); my %h = (k => "v"); eval { local $a[0] = 100; local $h{k} = "t"; say "$a[0] $h{k}"; }; say "$a[0] $h{k}";`,
+			ExpectedOutput: "100 t\n1 v",
+		},
+		{
+			Name: "local is restored at the end of its block",
+			Code: `our $g = 1;
+if (1) { local $g = 2; }
+say $g;
+{ local $g = 3; say $g; }
+say $g;
+{ local $\ = "!\n"; print "a"; }
+print "b\n";
+{ local $, = "-"; print "x", "y"; print "\n"; }
+print "x", "y", "\n";`,
+			ExpectedOutput: "1\n3\n1\na!\nb\nx-y\nxy",
+		},
+		{
+			Name: "local in a loop body",
+			Code: `our $g = 1;
+foreach my $i (1 .. 3) { local $g = $g + 10; print "$g "; }
+say $g;
+foreach my $i (1 .. 3) { local $g = 5; next if $i == 1; last if $i == 2; }
+say $g;
+OUTER: foreach my $i (1 .. 2) { foreach my $j (1 .. 2) { local $g = 7; next OUTER; } }
+say $g;
+my $n = 0;
+while ($n < 3) { $n++; if ($n == 2) { local $g = 9; next; } }
+say $g;`,
+			ExpectedOutput: "11 11 11 1\n1\n1\n1",
+		},
+		{
+			Name: "local lists",
+			Code: `our ($p, $q) = (1, 2);
+our (@l, %m);
+@l = (1); %m = (k => 1);
+sub show { say "$p $q ", scalar(@l), " ", scalar(keys %m); }
+{ local ($p, $q) = ($q, $p); show(); }
+{ local ($p, @l) = (5, 6, 7); show(); }
+{ local ($p, %m); show(); }
+show();`,
+			ExpectedOutput: "2 1 1 1\n5 2 2 1\n 2 1 0\n1 2 1 1",
+		},
+		{
+			Name: "local $/ in a block before reading the rest",
+			Code: `open(my $out, ">", "local_rs.txt");
+print $out "aXbXc\nd\n";
+close($out);
+open(my $in, "<", "local_rs.txt");
+{
+    local $/ = "X";
+    my $first = <$in>;
+    say "[$first]";
+}
+my @rest = <$in>;
+close($in);
+say scalar(@rest);`,
+			ExpectedOutput: "[aX]\n2",
+			CleanupFiles:   []string{"local_rs.txt"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Package Variable Tests
+// ============================================================
+
+func TestPackageVariables(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "our declares package variables",
+			Code: `package Counter;
+our $count = 0;
+our @log;
+sub Counter::bump { $count++; push @log, "b$count"; return $count; }
+package main;
+Counter::bump();
+Counter::bump();
+say "$Counter::count @Counter::log";
+$Counter::count = 10;
+say Counter::bump();`,
+			ExpectedOutput: "2 b1 b2\n11",
+		},
+		{
+			Name: "qualified names without our",
+			Code: `$Config::h{k} = "v";
+@Config::list = (3, 4);
+$Config::name = "cfg";
+say join(",", keys %Config::h), " $Config::h{k} $Config::list[-1] ", scalar(@Config::list);
+say "$Config::name";`,
+			ExpectedOutput: "k v 4 2\ncfg",
+		},
+		{
+			Name: "main package and my hiding our",
+			Code: `our $x = 10;
+sub inner { my $x = 1; say "$x $main::x $::x"; }
+inner();
+$main::x++;
+say $x;`,
+			ExpectedOutput: "1 10 10\n11",
+		},
+		{
+			Name: "local and references on package variables",
+			Code: `our $level = "top";
+sub show { say $main::level; }
+sub nested { local $main::level = "nested"; show(); }
+nested();
+show();
+my $r = \$main::level;
+$$r = "changed";
+say $level;`,
+			ExpectedOutput: "nested\ntop\nchanged",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestStrictVars(t *testing.T) {
+	runTest(t, TestCase{
+		Name: "strict program with my, our and use vars",
+		Code: `use strict;
+use warnings;
+use vars qw($total);
+our @seen;
+my %count;
+$total = 0;
+foreach my $w (qw(a b a)) { $count{$w}++; push @seen, $w; $total++; }
+while (my $k = shift @seen) { print "$k=$count{$k} "; last; }
+say "$total $main::total";`,
+		ExpectedOutput: "a=2 3 3",
+	})
+
+	// an undeclared variable under use strict stops both backends
+	// before anything runs
+	script := `use strict;
+my $x = 1;
+print "start\n";
+$y = $x + 1;
+sub f { return "$z" }
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "Parse error: line 4: Global symbol \"$y\" requires explicit package name (did you forget to declare \"my $y\"?)\n" +
+		"Parse error: line 5: Global symbol \"$z\" requires explicit package name (did you forget to declare \"my $z\"?)\n"
+	for mode, args := range map[string][]string{"INTERP": {path}, "COMPILED": {"-c", "-o", filepath.Join(dir, "s"), path}} {
+		out, err := exec.Command("./perlc", args...).CombinedOutput()
+		if err == nil {
+			t.Errorf("[%s] expected a non-zero exit", mode)
+		}
+		checkOutput(t, "strict vars", mode, string(out), want, "")
+	}
+}
+
+// ============================================================
+// Modules: use and require along @INC
+// ============================================================
+
+func TestModules(t *testing.T) {
+	// the modules are written to the test directory, "." of @INC
+	util := `package PerlcModUtil;
+use strict;
+use Exporter 'import';
+our @EXPORT = qw(double);
+our @EXPORT_OK = qw(triple $Label);
+our %EXPORT_TAGS = (all => [qw(double triple)]);
+our $Label = "util";
+my $calls = 0;
+sub double { $calls++; return $_[0] * 2 }
+sub triple { $calls++; return helper($_[0]) * 3 }
+sub helper { return $_[0] }
+sub calls { return $calls }
+1;
+`
+	greeter := `package PerlcModGreeter;
+print "loading\n";
+sub import { my ($class, @args) = @_; print "import $class @args\n"; }
+sub new { my ($class, $name) = @_; return bless { name => $name }, $class; }
+sub greet { my $self = shift; return "Hello, " . $self->{name}; }
+1;
+`
+	files := map[string]string{
+		"PerlcModUtil.pm":    util,
+		"PerlcModGreeter.pm": greeter,
+		"PerlcModFalse.pm":   "package PerlcModFalse;\n0;\n",
+	}
+	tests := []TestCase{
+		{
+			Name: "Exporter imports, tags and variables",
+			Code: `use strict;
+use PerlcModUtil qw(:all $Label);
+print double(4), " ", triple(2), "\n";
+print PerlcModUtil::calls(), " $Label\n";
+print join(",", sort keys %INC), "\n";`,
+			ExpectedOutput: "8 6\n2 util\nExporter.pm,PerlcModUtil.pm,strict.pm",
+		},
+		{
+			Name: "import sub, methods and require once",
+			Code: `use PerlcModGreeter qw(a b);
+sub greeting { require PerlcModGreeter; return PerlcModGreeter->new(shift)->greet; }
+print greeting("Ann"), "\n";
+print greeting("Bob"), "\n";`,
+			ExpectedOutput: "loading\nimport PerlcModGreeter a b\nHello, Ann\nHello, Bob",
+		},
+		{
+			Name: "require failures",
+			Code: `eval { require PerlcModMissing; };
+print "missing\n" if $@ =~ /^Can't locate PerlcModMissing.pm in \@INC \(you may need to install the PerlcModMissing module\) \(\@INC contains: .*\) at \S+ line 1\.\n/;
+eval { require PerlcModFalse; };
+print "false\n" if $@ =~ /^PerlcModFalse.pm did not return a true value at \S+ line 3\.\n/;
+my $noted = exists($INC{"PerlcModFalse.pm"}) ? "noted" : "not noted";
+print "$noted\n";`,
+			ExpectedOutput: "missing\nfalse\nnot noted",
+		},
+	}
+
+	for _, tc := range tests {
+		tc.SetupFiles = files
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+
+	// a use that cannot import what it asks for stops the program there
+	dir := t.TempDir()
+	script := "print \"start\\n\";\nuse PerlcModUtil qw(helper);\nprint \"not reached\\n\";\n"
+	for name, content := range map[string]string{"s.pl": script, "PerlcModUtil.pm": util} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	perlc, err := filepath.Abs("perlc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "start\n\"helper\" is not exported by the PerlcModUtil module\n" +
+		"Can't continue after import errors at s.pl line 2.\nBEGIN failed--compilation aborted at s.pl line 2.\n"
+	interp := exec.Command(perlc, "s.pl")
+	interp.Dir = dir
+	out, err := interp.CombinedOutput()
+	if err == nil {
+		t.Errorf("[INTERP] expected a non-zero exit")
+	}
+	checkOutput(t, "import errors", "INTERP", string(out), want, "")
+	build := exec.Command(perlc, "-c", "-o", "s", "s.pl")
+	build.Dir = dir
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, out)
+	}
+	compiled := exec.Command("./s")
+	compiled.Dir = dir
+	out, err = compiled.CombinedOutput()
+	if err == nil {
+		t.Errorf("[COMPILED] expected a non-zero exit")
+	}
+	checkOutput(t, "import errors", "COMPILED", string(out), want, "")
+}
+
+func TestInheritance(t *testing.T) {
+	files := map[string]string{
+		"PerlcAnimal.pm": `package PerlcAnimal;
+sub new { my ($class, $name) = @_; return bless { name => $name }, $class; }
+sub speak { my $self = shift; return $self->{name} . " says " . $self->sound; }
+sub sound { return "..." }
+1;
+`,
+	}
+	diamond := `package A; sub hi { "A" }
+package B; our @ISA = ('A');
+package C; our @ISA = ('A'); sub hi { "C" }
+`
+	tests := []TestCase{
+		{
+			Name: "use parent loads the parent module",
+			Code: `package Dog;
+use parent 'PerlcAnimal';
+sub sound { "Woof" }
+package main;
+my $d = Dog->new("Rex");
+print $d->speak, "\n";
+print $d->isa('PerlcAnimal') ? "isa\n" : "not isa\n";
+print exists($INC{"PerlcAnimal.pm"}) ? "loaded\n" : "not loaded\n";`,
+			ExpectedOutput: "Rex says Woof\nisa\nloaded",
+		},
+		{
+			Name: "use parent -norequire and our @ISA",
+			Code: `package Base; sub new { bless {}, shift } sub name { "base" }
+package Mid; use parent -norequire, 'Base';
+package Leaf; our @ISA = ('Mid');
+package main;
+print Leaf->new->name, " @Mid::ISA @Leaf::ISA\n";`,
+			ExpectedOutput: "base Base Mid",
+		},
+		{
+			Name: "depth-first and C3 method resolution",
+			Code: diamond + `package D; our @ISA = ('B', 'C');
+package E; use mro 'c3'; our @ISA = ('B', 'C');
+package main;
+print D->hi, " ", E->hi, "\n";
+print join(",", @{mro::get_linear_isa('D')}), " ", mro::get_mro('D'), "\n";
+print join(",", @{mro::get_linear_isa('E')}), " ", mro::get_mro('E'), "\n";`,
+			ExpectedOutput: "A C\nD,B,A,C dfs\nE,B,C,A c3",
+		},
+		{
+			Name: "inconsistent C3 hierarchy dies",
+			Code: diamond + `package X; our @ISA = ('B', 'C');
+package Y; our @ISA = ('C', 'B');
+package Z; use mro 'c3'; our @ISA = ('X', 'Y');
+package main;
+eval { Z->hi };
+print "inconsistent\n" if $@ =~ /^Inconsistent hierarchy during C3 merge of class 'Z'/;`,
+			ExpectedOutput: "inconsistent",
+		},
+		{
+			Name: "SUPER:: from the package of the sub",
+			Code: `package Dog;
+use parent 'PerlcAnimal';
+sub new { my ($class, $name) = @_; my $self = $class->SUPER::new(uc $name); $self->{tag} = 1; return $self; }
+sub sound { "Woof" }
+sub speak { my $self = shift; return "Dog: " . $self->SUPER::speak(); }
+package Puppy;
+our @ISA = ('Dog');
+sub speak { my $self = shift; return "Puppy " . $self->SUPER::speak(); }
+package main;
+my $p = Puppy->new("rex");
+print $p->speak, " ", $p->{tag}, "\n";`,
+			ExpectedOutput: "Puppy Dog: REX says Woof 1",
+		},
+		{
+			Name: "SUPER::new with @_ and %args",
+			Code: `package Pet;
+sub new { my ($class, %args) = @_; return bless { name => $args{name}, legs => $args{legs} || 4 }, $class; }
+sub speak { my $self = shift; return "$self->{name} ($self->{legs}) says woof"; }
+package Dog;
+our @ISA = ('Pet');
+sub new { my ($class, %args) = @_; my $self = $class->SUPER::new(%args); $self->{tag} = 1; return $self; }
+package Puppy;
+our @ISA = ('Dog');
+sub new { my $class = shift; return $class->SUPER::new(@_, legs => 3); }
+package main;
+print Dog->new(name => 'Rex')->speak, "\n";
+my @args = (name => 'Bit');
+my $p = Puppy->new(@args);
+print $p->speak, " ", $p->{tag}, "\n";`,
+			ExpectedOutput: "Rex (4) says woof\nBit (3) says woof 1",
+		},
+		{
+			Name: "can, isa and DOES as methods",
+			Code: `package Dog;
+use parent 'PerlcAnimal';
+sub sound { "Woof" }
+package main;
+my $d = Dog->new("Rex");
+my $m = $d->can('speak');
+print ref($m), " ", $m->($d), "\n";
+print $d->can('fly') ? "can" : "cannot", " ", Dog->can('new') ? "can" : "cannot", "\n";
+print $d->isa('PerlcAnimal') ? 1 : 0, Dog->isa('Dog') ? 1 : 0, $d->isa('Cat') ? 1 : 0, "\n";
+print $d->DOES('PerlcAnimal') ? 1 : 0, $d->DOES('Cat') ? 1 : 0, "\n";`,
+			ExpectedOutput: "CODE Rex says Woof\ncannot can\n110\n10",
+		},
+	}
+
+	for _, tc := range tests {
+		tc.SetupFiles = files
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestScalarUtil(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "blessed, reftype, dualvar and looks_like_number",
+			Code: `use Scalar::Util qw(blessed reftype dualvar looks_like_number);
+my $obj = bless [], 'Point';
+print blessed($obj), " ", defined(blessed([])) ? "b" : "u", " ", defined(blessed("Point")) ? "b" : "u", "\n";
+print reftype($obj), " ", reftype({}), " ", reftype(sub {}), " ", reftype(qr/x/), " ", defined(reftype(1)) ? "r" : "u", "\n";
+my $d = dualvar(5, "five");
+print $d + 1, " $d\n";
+print looks_like_number("1e3") ? 1 : 0, looks_like_number("abc") ? 1 : 0, looks_like_number($d) ? 1 : 0, "\n";
+print Scalar::Util::blessed($obj), "\n";`,
+			ExpectedOutput: "Point u u\nARRAY HASH CODE REGEXP u\n6 five\n100\nPoint",
+		},
+		{
+			// a compiled reference to an aggregate is the aggregate itself
+			// and cannot be weak
+			Name: "weaken, isweak and strong copies",
+			Code: `use Scalar::Util qw(weaken unweaken isweak);
+my $root = { name => "root" };
+my $kid = { name => "kid", parent => $root };
+$root->{kid} = $kid;
+weaken($kid->{parent});
+print isweak($kid->{parent}) ? "weak" : "strong", " ", isweak($root->{kid}) ? "weak" : "strong", "\n";
+print $kid->{parent}{name}, "\n";
+my $copy = $kid->{parent};
+print isweak($copy) ? "weak" : "strong", "\n";
+unweaken($kid->{parent});
+print isweak($kid->{parent}) ? "weak" : "strong", "\n";`,
+			ExpectedOutput: "weak strong\nroot\nstrong\nstrong",
+			SkipCompile:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestPOSIX(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "floor, ceil, fmod and pow",
+			Code: `use POSIX qw(floor ceil fmod pow);
+my $x = 7;
+my @v = (floor(2.7), ceil(2.1), ceil(-0.5), floor(-2.5), fmod(7, 3), fmod(-7.5, 2), pow(2, 10), floor $x / 2);
+print join(" ", @v), "\n";
+print POSIX::floor(1.8), "\n";`,
+			ExpectedOutput: "2 3 0 -3 1 -1.5 1024 3\n1",
+		},
+		{
+			Name: "use POSIX imports the limits",
+			Code: `use POSIX;
+my @v = (INT_MAX, INT_MIN, UINT_MAX, LONG_MAX, DBL_MAX, DBL_EPSILON, EXIT_FAILURE);
+print join(" ", @v), "\n";
+print INT_MAX + 1, " ", POSIX::INT_MAX() - 1, "\n";
+print strftime("%A %B %d %Y", 0, 0, 0, 1, 0, 124), "\n";`,
+			ExpectedOutput: "2147483647 -2147483648 4294967295 9223372036854775807 1.79769313486232e+308 2.22044604925031e-16 1\n2147483648 2147483646\nMonday January 01 2024",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestGetopt(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "GetOptions with typed, negatable and list options",
+			Code: `use strict;
+use Getopt::Long;
+@ARGV = ("a", "--verb", "--count=3", "b", "--name", "x", "--name=y", "-D", "k=v", "--noquiet", "--inc", "--inc", "-h", "--", "--count");
+my ($verbose, $count, @names, %defs, $inc);
+my $quiet = 1;
+my $ok = GetOptions("verbose!" => \$verbose, "quiet!" => \$quiet, "count=i" => \$count,
+    "name=s@" => \@names, "define=s%" => \%defs, "inc+" => \$inc,
+    "help|h" => sub { print "help: $_[0]\n" });
+print "ok=$ok verbose=$verbose quiet=$quiet count=$count inc=$inc\n";
+print "names=@names k=$defs{k} argv=@ARGV\n";`,
+			ExpectedOutput: "help: help\nok=1 verbose=1 quiet=0 count=3 inc=2\nnames=x y k=v argv=a b --count",
+		},
+		{
+			Name: "GetOptions into a hash and a bad value",
+			Code: `use Getopt::Long;
+@ARGV = ("-v", "-n", "a", "-n", "b", "--level", "file");
+my %h = (level => 1);
+GetOptions(\%h, "v", "n=s@", "level:i") or print "bad\n";
+my @n = @{$h{n}};
+print "v=$h{v} n=@n level=$h{level} argv=@ARGV\n";`,
+			ExpectedOutput: "v=1 n=a b level=0 argv=file",
+		},
+		{
+			Name: "getopts and getopt",
+			Code: `use strict;
+use Getopt::Std;
+our ($opt_a, $opt_b);
+@ARGV = ("-ac", "-bfoo", "file", "-a");
+my %o;
+getopts("ab:c", \%o) or print "bad\n";
+print "a=$o{a} b=$o{b} c=$o{c} argv=@ARGV\n";
+@ARGV = ("-a", "-b", 7, "rest");
+getopts("ab:");
+print "$opt_a $opt_b @ARGV\n";
+@ARGV = ("-xb", "val", "-z");
+my %g;
+getopt("b", \%g);
+print "x=$g{x} b=$g{b} z=$g{z}\n";`,
+			ExpectedOutput: "a=1 b=foo c=1 argv=file -a\n1 7 rest\nx=1 b=val z=1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFileSpec(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "File::Spec class methods",
+			Code: `use File::Spec;
+print File::Spec->catfile("a", "b/", "c.txt"), " ", File::Spec->catfile("", "etc", "passwd"), "\n";
+print File::Spec->catdir("a/", "/b", "c"), " ", File::Spec->canonpath("./a//b/./c/"), " ", File::Spec->canonpath("/../x"), "\n";
+my ($vol, $dir, $file) = File::Spec->splitpath("/usr/lib/x.so");
+print "[$vol][$dir][$file]\n";
+my @parts = File::Spec->splitdir("/a/b//c");
+print join("|", @parts), "\n";
+print File::Spec->rel2abs("x/y", "/tmp"), " ", File::Spec->abs2rel("/tmp/a/b", "/tmp"), "\n";
+print File::Spec->file_name_is_absolute("/x") ? "abs" : "rel", " ", File::Spec->curdir, File::Spec->updir, File::Spec->rootdir, "\n";`,
+			ExpectedOutput: "a/b/c.txt /etc/passwd\na/b/c a/b/c /x\n[][/usr/lib/][x.so]\n|a|b||c\n/tmp/x/y a/b\nabs .../",
+		},
+		{
+			Name: "basename, dirname and fileparse",
+			Code: `use File::Basename;
+my @paths = ("/a/b/c.txt", "c.txt", "/a/b/", "/", "a/");
+foreach my $p (@paths) {
+    print basename($p), " ", dirname($p), "\n";
+}
+print basename "/x/prog.pl", ".pl";
+print "\n";
+my ($name, $path, $suffix) = fileparse("/a/b/c.tar.gz", qr/\.[^.]*/);
+print "$name $path $suffix\n";
+($name, $path, $suffix) = fileparse("c.tar.gz", ".gz", ".tar");
+print "$name $path $suffix\n";`,
+			ExpectedOutput: "c.txt /a/b\nc.txt .\nb /a\n/ /\na .\nprog\nc.tar /a/b/ .gz\nc ./ .tar.gz",
+		},
+		{
+			Name: "make_path, remove_tree and abs_path",
+			Code: `use File::Path qw(make_path remove_tree);
+use File::Spec;
+use Cwd qw(abs_path);
+my $base = File::Spec->catdir(File::Spec->tmpdir, "perlc_file_path_test");
+remove_tree($base);
+my @made = make_path("$base/x/y", "$base/z");
+my @st = stat("$base/x/y");
+print scalar(@made), " ", @st ? "made" : "missing", "\n";
+open(my $fh, ">", "$base/x/f") or die;
+close($fh);
+my $removed = remove_tree($base);
+@st = stat($base);
+print $removed, " ", @st ? "exists" : "gone", "\n";
+print abs_path("/"), " ", defined(abs_path("/nonexistent/x")) ? "def" : "undef", "\n";`,
+			ExpectedOutput: "4 made\n5 gone\n/ undef",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestStorable(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "freeze and thaw keep objects, sharing and cycles",
+			Code: `use Storable qw(freeze thaw dclone);
+package Point;
+sub new { my ($class, $x) = @_; my $self = { px => $x }; return bless $self, $class }
+sub getx { $_[0]->{px} }
+package main;
+my $shared = [1, 2.5, "three"];
+my %data = (list => $shared, again => $shared, pt => Point->new(3), none => undef);
+$data{self} = \%data;
+my $copy = thaw(freeze(\%data));
+print ref($copy), " ", $copy->{list}[1] + 1, " ", $copy->{list}[2], "\n";
+print $copy->{list} == $copy->{again} ? "shared" : "split", " ", $copy->{list} == $shared ? "same" : "copied", "\n";
+print $copy->{self} == $copy ? "cycle" : "nocycle", " ", ref($copy->{pt}), " ", $copy->{pt}->getx, "\n";
+print defined($copy->{none}) ? "def" : "undef", "\n";
+my @arr = (1, [2, 3]);
+my $d = dclone(\@arr);
+$d->[1][0] = 9;
+print $arr[1][0], " ", $d->[1][0], "\n";`,
+			ExpectedOutput: "HASH 3.5 three\nshared copied\ncycle Point 3\nundef\n2 9",
+		},
+		{
+			Name: "store, retrieve and errors",
+			Code: `use Storable qw(store retrieve freeze);
+my $s = "scalar";
+my %h = (n => 42, r => \$s, q => qr/a+/i);
+my $file = "storable_test.bin";
+print store(\%h, $file), "\n";
+my $back = retrieve($file);
+my $r = $back->{r};
+print $back->{n}, " ", $$r, " ", "xAAy" =~ $back->{q} ? "match" : "nomatch", "\n";
+my @bad = ([sub { 1 }], 1);
+foreach my $bad (@bad) {
+    eval { freeze($bad) };
+    my ($msg) = split / at /, $@;
+    print "$msg\n";
+}
+eval { retrieve("storable_missing.bin") };
+my ($msg) = split / at /, $@;
+print "$msg\n";`,
+			ExpectedOutput: "1\n42 scalar match\nCan't store CODE items\nnot a reference\ncan't open storable_missing.bin: No such file or directory",
+			CleanupFiles:   []string{"storable_test.bin"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// perlc links no database/sql driver, so DBI can only fail to connect
+// here; the queries are tested against a driver in pkg/eval
+func TestDBI(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "connect without a driver or a dbi: prefix",
+			Code: `use DBI;
+my @dsns = ("dbi:SQLite:dbname=dbi_test.db", "dbi:Pg:dbname=x;host=localhost", "dbi::x", "test.db");
+foreach my $dsn (@dsns) {
+    my $dbh = eval { DBI->connect($dsn, "", "", { RaiseError => 1, PrintError => 0 }) };
+    my ($msg) = split / at /, $@;
+    print defined($dbh) ? "connected" : $msg, "\n";
+}
+print defined($DBI::errstr) ? "errstr" : "no errstr", "\n";`,
+			ExpectedOutput: "install_driver(SQLite) failed: no database/sql driver for DBD::SQLite is linked in\n" +
+				"install_driver(Pg) failed: no database/sql driver for DBD::Pg is linked in\n" +
+				"Can't connect to data source 'dbi::x' because I can't work out what driver to use (it doesn't seem to contain a 'dbi:driver:' prefix)\n" +
+				"Can't connect to data source 'test.db' because I can't work out what driver to use (it doesn't seem to contain a 'dbi:driver:' prefix)\n" +
+				"no errstr",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestHTTPTiny(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		fmt.Fprint(w, "hello")
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s|%s|%s|%s", r.Method, body, r.Header.Get("Content-Type"), r.Header.Get("User-Agent"), r.Header.Get("X-Token"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hello", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []TestCase{
+		{
+			Name: "HTTP::Tiny get, post, post_form and redirects",
+			Code: `use HTTP::Tiny;
+my $base = "` + server.URL + `";
+my %dh = ("X-Token" => "abc");
+my $http = HTTP::Tiny->new(agent => "perlc-test", default_headers => \%dh);
+my $res = $http->get("$base/hello");
+print "$res->{status} $res->{reason} $res->{success} $res->{content}\n";
+print $res->{headers}{"content-type"}, " ", join(",", @{$res->{headers}{"x-multi"}}), "\n";
+my %h = ("Content-Type" => "text/plain");
+$res = $http->post("$base/echo", { content => "data", headers => \%h });
+print $res->{content}, "\n";
+$res = $http->post_form("$base/echo", { b => "x y", a => [1, 2] });
+print $res->{content}, "\n";
+$res = $http->get("$base/redirect");
+print $res->{status}, " ", $res->{url} eq "$base/hello" ? "followed" : $res->{url}, "\n";
+$res = $http->post("$base/redirect");
+print "$res->{status}\n";
+$res = $http->get("$base/missing");
+print "$res->{status} $res->{reason} [$res->{success}]\n";
+$res = $http->get("ftp://example.com/");
+print "$res->{status} $res->{content}";
+print $http->www_form_urlencode([q => "a&b", n => 1]), "\n";`,
+			ExpectedOutput: "200 OK 1 hello\ntext/plain a,b\n" +
+				"POST data|text/plain|perlc-test|abc\n" +
+				"POST a=1&a=2&b=x+y|application/x-www-form-urlencoded|perlc-test|abc\n" +
+				"200 followed\n302\n404 Not Found []\n" +
+				"599 Unsupported URL scheme or malformed URL 'ftp://example.com/'\n" +
+				"q=a%26b&n=1",
+		},
+		{
+			Name: "LWP::UserAgent and HTTP::Response",
+			Code: `use LWP::UserAgent;
+my $base = "` + server.URL + `";
+my $ua = LWP::UserAgent->new(timeout => 5);
+$ua->agent("lwp-test");
+$ua->default_header("X-Token" => "t1");
+my $r = $ua->get("$base/hello");
+print $r->is_success ? "ok" : "fail", " ", $r->code, " ", $r->status_line, " ", $r->content_type, " ", $r->decoded_content, " ", $r->header("X-Multi"), "\n";
+$r = $ua->post("$base/echo", { k => "v" });
+print $r->content, "\n";
+$r = $ua->post("$base/echo", "X-Token" => "t2", Content => "raw");
+print $r->content, "\n";
+$r = $ua->get("$base/missing");
+print $r->is_error ? "error" : "noerror", " ", $r->status_line, "\n";`,
+			ExpectedOutput: "ok 200 200 OK text/plain hello a, b\n" +
+				"POST k=v|application/x-www-form-urlencoded|lwp-test|t1\n" +
+				"POST raw||lwp-test|t2\n" +
+				"error 404 Not Found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestThreads(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "create, join, tid and a die in a thread",
+			Code: `use threads;
+sub square { my ($n) = @_; return $n * $n; }
+my @thr;
+my @ns = (1 .. 4);
+foreach my $n (@ns) {
+    push @thr, threads->create(\&square, $n);
+}
+print join(",", map { $_->tid } @thr), "\n";
+my $sum = 0;
+foreach my $t (@thr) { $sum += $t->join; }
+print "sum $sum\n";
+my $list = threads->create({ context => 'list' }, sub { return (1, 2, 3) });
+my @got = $list->join;
+print scalar(@got), " @got\n";
+my $bad = threads->create(sub { die "boom\n" });
+my $res = $bad->join;
+print defined($res) ? "defined" : "undef", " ", $bad->error;
+eval { $bad->join };
+my ($msg) = split / at /, $@;
+print $msg, "\n";
+my $d = threads->create(sub { 1 });
+$d->detach;
+print $d->is_detached ? "detached" : "attached", " ", threads->tid, "\n";`,
+			// The interpreter's stderr follows its output
+			ExpectedMatch: `^1,2,3,4\nsum 30\n3 1 2 3\nundef boom\nThread already joined\ndetached 0(\nThread 6 terminated abnormally: boom)?$`,
+		},
+		{
+			Name: "Thread::Queue between workers",
+			Code: `use threads;
+use Thread::Queue;
+my $q = Thread::Queue->new;
+my $results = Thread::Queue->new;
+my $work = sub {
+    while (1) {
+        my $item = $q->dequeue;
+        last unless defined($item);
+        $results->enqueue($item * 10);
+    }
+    return threads->tid;
+};
+my @workers;
+push @workers, threads->create($work);
+push @workers, threads->create($work);
+$q->enqueue(1, 2, 3, 4, 5);
+$q->end;
+my @tids = map { $_->join } @workers;
+print "workers @tids\n";
+print "pending ", $results->pending, "\n";
+my @r;
+while ($results->pending) { push @r, $results->dequeue_nb; }
+print join(" ", sort { $a <=> $b } @r), "\n";
+my $q2 = Thread::Queue->new(7, 8, 9);
+print $q2->peek, " ", $q2->peek(-1), " ", $q2->pending, "\n";
+my @two = $q2->dequeue(2);
+print "@two\n";
+$q2->end;
+eval { $q2->enqueue(1) };
+my ($err) = split / at /, $@;
+print $err, "\n";
+print $q2->dequeue, " ", defined($q2->dequeue) ? "more" : "done", " ", defined($q2->pending) ? "pending" : "ended", "\n";`,
+			ExpectedOutput: "workers 1 2\npending 5\n10 20 30 40 50\n7 9 3\n7 8\n" +
+				"'enqueue' method called on queue that has been 'end'ed\n9 done ended",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "report with a page header and page breaks",
+			Code: `our ($name, $price, $qty);
+$= = 5;
+$^L = "--\n";
+my @rows = (["apple", 1.5, 3], ["banana split deluxe", 12.25, 10], ["kiwi", 0.333, 120], ["fig", 2, 1]);
+foreach my $row (@rows) {
+    ($name, $price, $qty) = @$row;
+    write;
+}
+print "page $% left $-\n";
+
+format STDOUT_TOP =
+Page @<
+$%
+Name            Price   Qty
+.
+
+format STDOUT =
+@<<<<<<<<<<<<<< @##.## @>>
+$name,          $price, $qty
+.`,
+			ExpectedOutput: "Page 1\nName            Price   Qty\napple             1.50   3\n" +
+				"banana split de  12.25  10\nkiwi              0.33 120\n--\nPage 2\n" +
+				"Name            Price   Qty\nfig               2.00   1\npage 2 left 2",
+			// the compiler has no formats yet
+			SkipCompile: true,
+		},
+		{
+			Name: "continuation fields, ~ lines and a format of a file",
+			Code: `our $text = "The quick brown fox jumps over the lazy dog";
+our ($title, $none) = ("Story");
+my $buf;
+open(my $fh, '>', \$buf) or die;
+my $old = select($fh);
+$~ = "NOTE";
+select($old);
+write($fh);
+close($fh);
+print $buf;
+print defined($none) ? "set" : "unset", " [$text]\n";
+$~ = "MISSING";
+eval { write };
+my ($err) = split / at /, $@;
+print $err, "\n";
+
+format NOTE =
+@|||||||||||||||||| ~
+$title
+@<<<< ~
+$none
+^<<<<<<<<<<<<<<<<<<
+$text
+~~^<<<<<<<<<<<<<<<<<
+$text
+.`,
+			ExpectedOutput: "       Story\nThe quick brown fox\n  jumps over the\n  lazy dog\nunset []\n" +
+				"Undefined format \"MISSING\" called",
+			SkipCompile: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestListUtil(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "sum, max, min and uniq",
+			Code: `use List::Util qw(sum sum0 max min uniq);
+my @l = (3, 9, 4, 1, 9, 3);
+print sum(@l), " ", sum0(), " ", max(@l), " ", min(@l), "\n";
+print join(",", uniq @l), "\n";
+print defined(sum()) ? "def" : "undef", " ", List::Util::max(1, 7, 2), "\n";`,
+			ExpectedOutput: "29 0 9 1\n3,9,4,1\nundef 7",
+		},
+		{
+			Name: "first, any, all and none take a block",
+			Code: `use List::Util qw(first any all none);
+my @recs = ({ n => "a", v => 2 }, { n => "b", v => 5 });
+my $r = first { $_->{n} eq "b" } @recs;
+print $r->{v}, "\n";
+my $nf = first { $_ > 100 } (1, 2);
+print defined($nf) ? "def" : "undef", "\n";
+print any { $_ == 2 } (1, 2, 3);
+print all { $_ > 0 } (1, 2, 3);
+print none { $_ > 5 } (1, 2, 3);
+print "\n";
+print "not all\n" unless all { $_ > 1 } (1, 2, 3);`,
+			ExpectedOutput: "5\nundef\n111\nnot all",
+		},
+		{
+			Name: "reduce sees $a and $b",
+			Code: `use List::Util qw(reduce);
+my $product = reduce { $a * $b } 1, 2, 3, 4;
+my $longest = reduce { length($a) >= length($b) ? $a : $b } qw(ab abcd abc);
+print "$product $longest\n";`,
+			ExpectedOutput: "24 abcd",
+		},
+		{
+			Name: "shuffle keeps the elements",
+			Code: `use List::Util qw(shuffle sum);
+my @sh = shuffle(1 .. 10);
+print scalar(@sh), " ", sum(@sh), "\n";
+print join(",", sort { $a <=> $b } @sh), "\n";`,
+			ExpectedOutput: "10 55\n1,2,3,4,5,6,7,8,9,10",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Date and Time Tests
+// ============================================================
+
+func TestDateTime(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "gmtime list formatted with sprintf %02d",
+			Code: `my @t = gmtime(1700000000);
+say sprintf("%04d-%02d-%02d %02d:%02d:%02d", $t[5] + 1900, $t[4] + 1, $t[3], $t[2], $t[1], $t[0]);
+printf("%02d/%02d/%02d\n", $t[3], $t[4] + 1, ($t[5] + 1900) % 100);`,
+			ExpectedOutput: "2023-11-14 22:13:20\n14/11/23",
+		},
+		{
+			Name:           "gmtime wday, yday and field count",
+			Code:           `my @t = gmtime(0); say scalar(@t), " $t[6] $t[7] $t[8]";`,
+			ExpectedOutput: "9 4 0 0",
+		},
+		{
+			Name:           "scalar gmtime is ctime string",
+			Code:           `my $s = gmtime(86400 * 5); say $s; say scalar(gmtime(0));`,
+			ExpectedOutput: "Tue Jan  6 00:00:00 1970\nThu Jan  1 00:00:00 1970",
+		},
+		{
+			Name:           "time without parentheses",
+			Code:           `my $start = time; my $el = time - $start; print "ok\n" if $start > 0 && $el < 5;`,
+			ExpectedOutput: "ok",
+		},
+		{
+			Name: "POSIX strftime",
+			Code: `use POSIX qw(strftime);
+say strftime("%Y-%m-%d %H:%M:%S", 5, 4, 3, 2, 0, 124);
+say strftime("%a %b %e %j", 0, 0, 0, 31, 11, 99);`,
+			ExpectedOutput: "2024-01-02 03:04:05\nFri Dec 31 365",
+		},
+		{
+			Name: "Time::Piece accessors",
+			Code: `use Time::Piece;
+my $t = gmtime(1700000000);
+say $t->year, " ", $t->mon, " ", $t->_mon, " ", $t->mday, " ", $t->hour, ":", $t->min, ":", $t->sec;
+say $t->monname, " ", $t->fullmonth, " ", $t->day, " ", $t->wday, " ", $t->yday, " ", $t->epoch;
+say $t->ymd, " ", $t->hms, " ", $t->mdy("/"), " ", $t->datetime;
+say ref($t);`,
+			ExpectedOutput: "2023 11 10 14 22:13:20\nNov November Tue 3 317 1700000000\n2023-11-14 22:13:20 11/14/2023 2023-11-14T22:13:20\nTime::Piece",
+		},
+		{
+			Name: "Time::Piece strftime and localtime object",
+			Code: `use Time::Piece;
+my $t = gmtime(0);
+say $t->strftime("%d.%m.%Y %H:%M");
+say $t->cdate;
+my $now = localtime;
+say "ok" if $now->year >= 2024 && localtime->epoch >= $now->epoch;`,
+			ExpectedOutput: "01.01.1970 00:00\nThu Jan  1 00:00:00 1970\nok",
+		},
+		{
+			Name: "Time::HiRes fractional time and sleep",
+			Code: `use Time::HiRes qw(time sleep usleep);
+my $start = time;
+my $slept = sleep(0.05);
+say "sleep" if $slept >= 0.04 && $slept < 1;
+say "usleep" if usleep(10_000) >= 9_000;
+my $el = time - $start;
+say "time" if $el >= 0.05 && $el < 2 && $el != int($el);
+say "qualified" if Time::HiRes::time() > 1e9;`,
+			ExpectedOutput: "sleep\nusleep\ntime\nqualified",
+		},
+		{
+			Name: "Time::HiRes gettimeofday and tv_interval",
+			Code: `use Time::HiRes qw(gettimeofday tv_interval);
+my $t0 = [gettimeofday];
+my ($s, $us) = gettimeofday();
+say scalar(@$t0), " ", ($s >= $t0->[0] && $us < 1_000_000 ? "ok" : "bad");
+my $now = gettimeofday;
+say "scalar" if $now > $s - 1 && $now < $s + 2;
+say tv_interval([1, 500_000], [3, 250_000]);
+my $el = tv_interval($t0);
+say "elapsed" if $el >= 0 && $el < 2;`,
+			ExpectedOutput: "2 ok\nscalar\n1.75\nelapsed",
+		},
+		{
+			Name:           "sleep without Time::HiRes returns whole seconds",
+			Code:           `my $n = sleep(0.2); say $n; say time == int(time) ? "int" : "frac";`,
+			ExpectedOutput: "0\nint",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Quote-like Operator Tests
+// ============================================================
+
+func TestQuoteLike(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name:           "qw list",
+			Code:           `my @w = qw(apple banana  cherry); say scalar(@w); say $w[2];`,
+			ExpectedOutput: "3\ncherry",
+		},
+		{
+			Name:           "q does not interpolate",
+			Code:           `my $x = 1; say q{cost: $x {each}};`,
+			ExpectedOutput: "cost: $x {each}",
+		},
+		{
+			Name:           "qq interpolates",
+			Code:           `my $name = "World"; say qq(Hello, $name!);`,
+			ExpectedOutput: "Hello, World!",
+		},
+		{
+			Name:           "qr with flags",
+			Code:           `my $re = qr/b+/i; say ref($re); say "aBBc" =~ $re ? "yes" : "no"; say "xyz" !~ $re ? "yes" : "no";`,
+			ExpectedOutput: "Regexp\nyes\nyes",
+		},
+		{
+			Name: "qr stringifies in caret form",
+			Code: `my $re = qr/b+/i; my $x = "c"; my $both = qr/$re|$x/ms;
+say $re; say qr/x/; say $both; say "aC" =~ $both ? "no" : "yes", " ", "xbB" =~ /^x$re$/ ? "yes" : "no";`,
+			ExpectedOutput: "(?^i:b+)\n(?^:x)\n(?^ms:(?^i:b+)|c)\nyes yes",
+		},
+		{
+			Name:           "m with braces",
+			Code:           `my $p = "/usr/local/bin"; say $p =~ m{^/usr/local} ? "yes" : "no";`,
+			ExpectedOutput: "yes",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// String Interpolation Tests
+// ============================================================
+
+func TestInterpolation(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name:           "element with variable index and key",
+			Code:           `my @a = (10, 20, 30); my %h = (x => 1, y => 2); my ($i, $k) = (2, "y"); say "$a[$i] $a[-1] $a[$i-1] $h{$k} $h{x}";`,
+			ExpectedOutput: "30 30 20 2 1",
+		},
+		{
+			Name:           "arrow chains",
+			Code:           `my $obj = { name => "Bob", list => [1, 2, 3], inner => { x => "deep" } }; say "$obj->{name} $obj->{list}[1] $obj->{list}->[2] $obj->{inner}{x}";`,
+			ExpectedOutput: "Bob 2 3 deep",
+		},
+		{
+			Name:           "array references and scalar dereference",
+			Code:           `my @a = (1, 2, 3); my $r = \@a; my $s = "str"; my $sr = \$s; say "$r->[1] ${$r}[2] @$r @{$r} $$sr";`,
+			ExpectedOutput: "2 3 1 2 3 1 2 3 str",
+		},
+		{
+			Name: "expression blocks and method calls",
+			Code: `sub Point::new { my $class = shift; my $self = { x => shift }; bless($self, $class); return $self; }
+sub Point::get_x { my $self = shift; return $self->{x}; }
+my $p = Point->new(7);
+my @w = ("a", "b");
+say "x=@{[ $p->get_x ]} sum=@{[ 1 + 2 ]} ${\ join('-', @w)}";`,
+			ExpectedOutput: "x=7 sum=3 a-b",
+		},
+		{
+			Name:           "escaped sigils stay literal",
+			Code:           `my $s = "v"; my @a = (1); say "\$s \@a \\$s user\@example.com ${s}x $s->method";`,
+			ExpectedOutput: "$s @a \\v user@example.com vx v->method",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// File I/O Tests
+// ============================================================
+
+func TestFileIO(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "write and read file",
+			Code: `my $fh;
+open($fh, ">", "test_io.txt");
+print $fh "Line 1\n";
+print $fh "Line 2\n";
+close($fh);
+
+open($fh, "<", "test_io.txt");
+my $line1 = <$fh>;
+my $line2 = <$fh>;
+close($fh);
+
+chomp($line1);
+chomp($line2);
+say "1: $line1";
+say "2: $line2";`,
+			ExpectedOutput: "1: Line 1\n2: Line 2",
+			CleanupFiles:   []string{"test_io.txt"},
+		},
+		{
+			Name: "read existing file",
+			Code: `open(my $fh, "<", "input_test.txt");
+my $content = <$fh>;
+close($fh);
+chomp($content);
+say "Got: $content";`,
+			ExpectedOutput: "Got: Hello from test file",
+			SetupFiles: map[string]string{
+				"input_test.txt": "Hello from test file\n",
+			},
+		},
+		{
+			Name: "append mode",
+			Code: `open(my $fh, ">", "append_test.txt");
+print $fh "Line 1\n";
+close($fh);
+
+open($fh, ">>", "append_test.txt");
+print $fh "Line 2\n";
+close($fh);
+
+open($fh, "<", "append_test.txt");
+my @lines;
+while (my $line = <$fh>) {
+    chomp($line);
+    push(@lines, $line);
+}
+close($fh);
+say join(", ", @lines);`,
+			ExpectedOutput: "Line 1, Line 2",
+			CleanupFiles:   []string{"append_test.txt"},
+		},
+		{
+			Name: "say to filehandle",
+			Code: `open(my $fh, ">", "say_test.txt");
+say $fh "Hello";
+say $fh "World";
+close($fh);
+
+open($fh, "<", "say_test.txt");
+my $l1 = <$fh>;
+my $l2 = <$fh>;
+close($fh);
+chomp($l1);
+chomp($l2);
+say "$l1 $l2";`,
+			ExpectedOutput: "Hello World",
+			CleanupFiles:   []string{"say_test.txt"},
+		},
+		{
+			Name: "3-arg open read",
+			Code: `open(my $fh, "<", "three_arg_test.txt");
+my $line = <$fh>;
+close($fh);
+chomp($line);
+say $line;`,
+			ExpectedOutput: "Test content",
+			SetupFiles: map[string]string{
+				"three_arg_test.txt": "Test content\n",
+			},
+		},
+		{
+			Name: "open return value",
+			Code: `my $result = open(my $fh, "<", "nonexistent_file_xyz.txt");
+say $result ? "opened" : "failed";`,
+			ExpectedOutput: "failed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+// ============================================================
+// Built-in Function Tests
+// ============================================================
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []TestCase{
+		{
+			// The runner appends stderr to the interpreter output
+			Name:           "STDERR and warn go to stderr (interpreter)",
+			Code:           `print STDERR "err\n"; say STDOUT "out"; warn "careful\n"; say "done";`,
+			ExpectedOutput: "out\ndone\nerr\ncareful",
+			SkipCompile:    true,
+		},
+		{
+			// and drops it for compiled programs
+			Name:           "STDERR and warn go to stderr (compiled)",
+			Code:           `print STDERR "err\n"; say STDOUT "out"; warn "careful\n"; say STDERR "x"; say "done";`,
+			ExpectedOutput: "out\ndone",
+			SkipInterpret:  true,
+		},
+		{
+			Name:           "abs positive",
+			Code:           `say abs(42);`,
+			ExpectedOutput: "42",
+		},
+		{
+			Name:           "abs negative",
+			Code:           `say abs(-42);`,
+			ExpectedOutput: "42",
+		},
+		{
+			Name:           "int",
+			Code:           `say int(3.7);`,
+			ExpectedOutput: "3",
+		},
+		{
+			Name:           "sqrt",
+			Code:           `say sqrt(16);`,
+			ExpectedOutput: "4",
+		},
+		{
+			Name:           "sin",
+			Code:           `say int(sin(0) * 100);`,
+			ExpectedOutput: "0",
+		},
+		{
+			Name:           "cos",
+			Code:           `say int(cos(0) * 100);`,
+			ExpectedOutput: "100",
+		},
+		{
+			Name:           "log",
+			Code:           `say int(log(2.718281828) * 100);`,
+			ExpectedOutput: "100",
+		},
+		{
+			Name:           "exp",
+			Code:           `say int(exp(1) * 100);`,
+			ExpectedOutput: "271",
+		},
+		{
+			Name:           "rand",
+			Code:           `my $r = rand(); say $r >= 0 && $r < 1 ? "ok" : "fail";`,
+			ExpectedOutput: "ok",
+		},
+		{
+			Name:           "defined",
 			Code:           `my $x = 1; my $y; say defined($x) ? "yes" : "no"; say defined($y) ? "yes" : "no";`,
 			ExpectedOutput: "yes\nno",
 		},
@@ -1184,6 +3375,50 @@ func TestBuiltinFunctions(t *testing.T) {
 			Code:           `my $packed = pack("A3", "ABC"); say $packed;`,
 			ExpectedOutput: "ABC",
 		},
+		{
+			Name: "pack and unpack integers",
+			Code: `my @n = (1, 258, -2, 65535);
+my $s = pack("N n c v", @n);
+say length($s), " ", join(",", unpack("N n c v", $s));
+say join(",", unpack("C*", pack("n N V", 1, 2, 3)));
+say join(",", unpack("l> q< s!", pack("l> q< s!", -5, -6, -7)));
+my $first = unpack("N*", pack("N*", 7, 8));
+say $first;`,
+			ExpectedOutput: "9 1,258,-2,65535\n0,1,0,0,0,2,3,0,0,0\n-5,-6,-7\n7",
+		},
+		{
+			Name: "pack and unpack strings, groups and lengths",
+			Code: `say join("|", unpack("a3 A3 Z*", pack("a3 A3 Z*", "x", "y", "zz")));
+my $rec = pack("n/a* C/a*", "hello", "go");
+say length($rec), " ", join(",", unpack("n/a* C/a*", $rec));
+say join(",", unpack("(A2)*", "aabbcc"));
+my %h = (k => 5);
+say join(",", unpack("a C", pack("a* C", %h)));
+say join(",", unpack("(a1 x)2", pack("(a1 x)2", "p", "q")));`,
+			ExpectedOutput: "x\x00\x00|y|zz\n10 hello,go\naa,bb,cc\nk,5\np,q",
+		},
+		{
+			Name: "pack and unpack hex, bits, BER and floats",
+			Code: `my @ber = (unpack("H*", pack("w", 300)), unpack("w", pack("w", 300)));
+say join(",", @ber);
+say join(",", unpack("H4 h2", pack("H4 h2", "12ab", "f1")));
+say join(",", unpack("b8 B8", pack("b8 B8", "10000000", "10000000")));
+say join(",", unpack("d> f", pack("d> f", 2.5, -0.25)));
+say join(",", unpack("U*", pack("U*", 233, 65)));`,
+			ExpectedOutput: "822c,300\n12ab,f1\n10000000,10000000\n2.5,-0.25\n233,65",
+		},
+		{
+			Name: "unpack checksums and lists in print",
+			Code: `print unpack("C*", "AB"), "\n";
+print unpack("H*", pack("n", 0x12ab)), " ", unpack("%32C*", "abc"), " ", unpack("%8C*", pack("C*", 255, 255)), "\n";
+print join(",", unpack("%b16 %32a2 C", pack("C5", 7, 1, 97, 98, 9))), "\n";`,
+			ExpectedOutput: "6566\n12ab 294 254\n4,195,9",
+		},
+		{
+			Name:           "pack with an invalid template dies",
+			Code:           `eval { my $s = pack("N y", 1) }; print $@; eval { my @v = unpack("X2", "a") }; print $@;`,
+			ExpectedOutput: "Invalid type 'y' in pack\n'X' outside of string in unpack",
+		},
 		{
 			Name:           "lc and uc",
 			Code:           `say lc("HELLO"); say uc("world");`,
@@ -1199,6 +3434,27 @@ func TestBuiltinFunctions(t *testing.T) {
 			Code:           `my @nums = (1, 2, 3); my @doubled = map { $_ * 2 } @nums; say "@doubled";`,
 			ExpectedOutput: "2 4 6",
 		},
+		{
+			Name: "looks_like_number",
+			Code: `use Scalar::Util qw(looks_like_number);
+my @v = ("42", " 1.5\n", "-3e4", ".5", "Inf", "nan", "0x10", "1_000", "12abc", "", "1e");
+my @r = map { looks_like_number($_) ? 1 : 0 } @v;
+say "@r ", Scalar::Util::looks_like_number(7), looks_like_number(undef) ? 1 : 0, " ", "inf" + 1;`,
+			ExpectedOutput: "1 1 1 1 1 1 0 0 0 0 0 10 Inf",
+		},
+		{
+			Name: "Devel::Size size and total_size",
+			Code: `use Devel::Size qw(size total_size);
+my @a = (1 .. 100);
+my %h = (big => "x" x 1000, list => [1, 2, 3]);
+my $r = {n => 5};
+$r->{self} = $r;
+my $s = "abc";
+my @c = (size(\@a) > 0, total_size(\@a) > size(\@a), size(\%h) < 1000, total_size(\%h) > 1000,
+    total_size($r) > size($r), Devel::Size::total_size(\@a) == total_size(\@a), size("x" x 100) - size($s));
+say "@c";`,
+			ExpectedOutput: "1 1 1 1 1 1 97",
+		},
 	}
 
 	for _, tc := range tests {
@@ -1387,6 +3643,409 @@ foreach my $person (@sorted) {
 	}
 }
 
+// ============================================================
+// Doctest: examples from POD run against the module
+// ============================================================
+
+func TestDoctest(t *testing.T) {
+	module := `package Greeter;
+
+=head1 SYNOPSIS
+
+    my $g = Greeter->new("Ann");
+    say $g->greet;    # => Hello, Ann
+
+=cut
+
+sub new { my ($class, $name) = @_; return bless({ name => $name }, $class); }
+sub greet { my $self = shift; return "Hello, " . $self->{name}; }
+
+1;
+__END__
+
+=head1 EXAMPLES
+
+    say Greeter->new("Bob")->greet;    # => Hi, Bob
+`
+	path := filepath.Join(t.TempDir(), "Greeter.pm")
+	if err := os.WriteFile(path, []byte(module), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("./perlc", "-doctest", path)
+	out, err := cmd.Output()
+	if err == nil {
+		t.Errorf("expected a non-zero exit for the failing example")
+	}
+	checkOutput(t, "doctest", "doctest", string(out), "", `(?s)^1\.\.2\nok 1 - SYNOPSIS \(line 5\)\nnot ok 2 - EXAMPLES \(line 18\)\n#   expected output \["Hi, Bob"\], got \["Hello, Bob"\]`)
+}
+
+// ============================================================
+// --report: crash report for a panic of the interpreter
+// ============================================================
+
+func TestCrashReport(t *testing.T) {
+	// @$r on a scalar ref panics inside the interpreter
+	script := "my $n = 1;\nmy $r = \\$n;\n@$r = (1, 2);\nprint \"after\\n\";\n"
+	path := filepath.Join(t.TempDir(), "crash.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("./perlc", "--report", path)
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 70 {
+		t.Errorf("expected exit status 70, got %v", err)
+	}
+	checkOutput(t, "crash report", "interp", string(out), "", `(?s)perlc crash report.*\nperlc \S+ \(commit \S+, go.*\npanic: Not an array.*>    3 \| @\$r = \(1, 2\);.*--- tokens ---\n  1:1\t"my".*  3:1\t"@".*--- AST ---\n\*ast\.ExprStmt\n  Expression: \*ast\.AssignExpr\n    Left: \*ast\.DerefExpr\n.*--- stack ---`)
+}
+
+func TestWarnings(t *testing.T) {
+	// deep recursion is reported once per place however often it happens,
+	// not at all under no warnings, and an unknown category dies; perl's
+	// other categories are accepted
+	script := `sub f { my $n = shift; return $n <= 0 ? 0 : 1 + f($n - 1); }
+foreach my $k (1..3) { f(120); }
+no warnings 'recursion';
+f(120);
+use warnings qw(recursion);
+no warnings 'experimental::signatures';
+use warnings qw(io syntax once redefine);
+warn "done\n";
+no warnings 'bogus';
+print "not reached\n";
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "w.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "Deep recursion on subroutine \"main::f\" at " + path + " line 1.\ndone\nUnknown warnings category 'bogus' at " + path + " line 9."
+
+	run := func(mode string, cmd *exec.Cmd) {
+		cmd.Env = append(os.Environ(), "PERLC_WARNINGS=2")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil {
+			t.Errorf("[%s] expected a non-zero exit for the unknown category", mode)
+		}
+		checkOutput(t, "warnings", mode, stderr.String(), want, "")
+	}
+	run("INTERP", exec.Command("./perlc", path))
+
+	exe := filepath.Join(dir, "w")
+	if out, err := exec.Command("./perlc", "-c", "-o", exe, path).CombinedOutput(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, out)
+	}
+	run("COMPILE", exec.Command(exe))
+}
+
+func TestNumericWarnings(t *testing.T) {
+	// strings that are not numbers count by their numeric prefix; use
+	// warnings reports them, once per place, until no warnings 'numeric'
+	script := `use warnings;
+my @in = ("42abc", "0x1A", "1e3", " 12 ", "inf", "3 apples");
+my @out;
+foreach my $s (@in) { push @out, $s + 0; }
+my %h = (n => "5 cats");
+$h{n} += 1;
+my $lt = "b" < 1;
+my $f = sprintf("%d|%*s|%s", "7 days", "3w", "x", "word");
+my $i = int("9 lives") + abs("-4 x");
+printf("%.1f ", "2.5kg");
+no warnings 'numeric';
+my $quiet = "zzz" * 2;
+print "@out $h{n} $lt $quiet $f $i\n";
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "n.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := func(line string) string { return " at " + path + " line " + line + ".\n" }
+	want := `Argument "42abc" isn't numeric in addition (+)` + at("4") +
+		`Argument "0x1A" isn't numeric in addition (+)` + at("4") +
+		`Argument "3 apples" isn't numeric in addition (+)` + at("4") +
+		`Argument "5 cats" isn't numeric in addition (+)` + at("6") +
+		`Argument "b" isn't numeric in numeric lt (<)` + at("7") +
+		`Argument "7 days" isn't numeric in sprintf` + at("8") +
+		`Argument "3w" isn't numeric in sprintf` + at("8") +
+		`Argument "9 lives" isn't numeric in int` + at("9") +
+		`Argument "-4 x" isn't numeric in abs` + at("9") +
+		`Argument "2.5kg" isn't numeric in printf` + at("10") +
+		"2.5 42 0 1000 12 Inf 3 6 1 0 7|  x|word 13"
+
+	run := func(mode string, cmd *exec.Cmd) {
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("[%s] %v", mode, err)
+		}
+		checkOutput(t, "numeric warnings", mode, string(out), want, "")
+	}
+	run("INTERP", exec.Command("./perlc", path))
+
+	exe := filepath.Join(dir, "n")
+	if out, err := exec.Command("./perlc", "-c", "-o", exe, path).CombinedOutput(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, out)
+	}
+	run("COMPILE", exec.Command(exe))
+}
+
+func TestUninitializedWarnings(t *testing.T) {
+	// undefined operands are named by their variable and the operator;
+	// $n += and $s .= start from undef quietly, as in perl
+	script := `use warnings;
+my ($x, $y, @a, %h);
+my $s = "a" . $x;
+my $gt = $x > 3;
+my $sum = $a[0] + $h{k};
+my $n;
+$n += 2;
+$n *= $y;
+my $str;
+$str .= "b";
+my $eq = $x eq "";
+no warnings 'uninitialized';
+my $quiet = $y + 1;
+print "$s $n $str $eq $quiet\n";
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "u.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := func(line string) string { return " at " + path + " line " + line + ".\n" }
+	want := `Use of uninitialized value $x in concatenation (.) or string` + at("3") +
+		`Use of uninitialized value $x in numeric gt (>)` + at("4") +
+		`Use of uninitialized value $h{"k"} in addition (+)` + at("5") +
+		`Use of uninitialized value $a[0] in addition (+)` + at("5") +
+		`Use of uninitialized value $y in multiplication (*)` + at("8") +
+		`Use of uninitialized value $x in string eq` + at("11") +
+		"a 0 b 1 1"
+
+	run := func(mode string, cmd *exec.Cmd) {
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("[%s] %v", mode, err)
+		}
+		checkOutput(t, "uninitialized warnings", mode, string(out), want, "")
+	}
+	run("INTERP", exec.Command("./perlc", path))
+
+	exe := filepath.Join(dir, "u")
+	if out, err := exec.Command("./perlc", "-c", "-o", exe, path).CombinedOutput(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, out)
+	}
+	run("COMPILE", exec.Command(exe))
+}
+
+func TestHashListWarnings(t *testing.T) {
+	// a hash assigned an odd list warns at run time; perlc -c reports the
+	// lists it can count at compile time as well
+	script := `my %h = (1, 2, 3);
+my @arr = (1, 2, 3, 4, 5);
+my %g;
+%g = @arr;
+my %r = {a => 1};
+my ($x, %o) = (1, 2, 3);
+no warnings 'misc';
+my %q = (1);
+my @k = sort keys %h;
+print "@k ", scalar(keys %g), " ", scalar(keys %o), "\n";
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "h.pl")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := func(line string) string { return " at " + path + " line " + line + ".\n" }
+	want := "Odd number of elements in hash assignment" + at("1") +
+		"Odd number of elements in hash assignment" + at("4") +
+		"Reference found where even-sized list expected" + at("5") +
+		"1 3 3 1"
+
+	run := func(mode string, cmd *exec.Cmd) {
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("[%s] %v", mode, err)
+		}
+		checkOutput(t, "hash list warnings", mode, string(out), want, "")
+	}
+	run("INTERP", exec.Command("./perlc", path))
+
+	exe := filepath.Join(dir, "h")
+	var stderr strings.Builder
+	cmd := exec.Command("./perlc", "-c", "-o", exe, path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, stderr.String())
+	}
+	wantCompile := "Odd number of elements in hash assignment" + at("1") +
+		"Reference found where even-sized list expected" + at("5")
+	if stderr.String() != wantCompile {
+		t.Errorf("compile-time warnings = %q, want %q", stderr.String(), wantCompile)
+	}
+	run("COMPILE", exec.Command(exe))
+}
+
+func TestLexicalScoping(t *testing.T) {
+	// The interpreter scopes the my of loop bodies only, so the cases with
+	// other blocks are compiled only
+	tests := []TestCase{
+		{
+			Name: "my in a block shadows the outer variable",
+			Code: `my $x = 1; my @a = (1, 2);
+if ($x) { my $x = 10; my @a = (5); print "inner $x @a\n"; }
+print "outer $x @a\n";
+{ my $x = "block"; print "$x\n"; }
+print "after $x\n";`,
+			ExpectedOutput: "inner 10 5\nouter 1 1 2\nblock\nafter 1",
+			SkipInterpret:  true,
+		},
+		{
+			Name: "my in a loop body is new in each scope",
+			Code: `my $x = 1;
+foreach my $i (1..2) { my $x = $x + $i; print "loop $x\n"; }
+my $n = 0;
+do { my $n = 100; print "do $n\n"; } while (0);
+print "$x $n\n";`,
+			ExpectedOutput: "loop 2\nloop 3\ndo 100\n1 0",
+		},
+		{
+			Name: "shadowing with another kind of value",
+			Code: `my $x = "str";
+{ my $x = 5; $x++; print "$x\n"; }
+my $r = do { my $x = 7; $x * 2 };
+my @m = map { my $x = $_ * 3; $x } (1, 2);
+print "$x $r @m\n";`,
+			ExpectedOutput: "6\nstr 14 3 6",
+			SkipInterpret:  true,
+		},
+		{
+			Name: "sibling blocks declare the same name",
+			Code: `my @out;
+if (1) { my $v = "a"; push @out, $v; }
+if (1) { my $v = "b"; push @out, $v; }
+my $v = "c";
+sub f { my $s = shift; { my $s = $s * 2; return $s; } }
+print "@out $v ", f(4), "\n";`,
+			ExpectedOutput: "a b c 8",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "counters",
+			Code: `sub make_counter { my $n = shift; return sub { return $n++; }; }
+my $c1 = make_counter(5);
+my $c2 = make_counter(10);
+print $c1->(), $c1->(), $c2->(), $c1->(), "\n";
+my $count = 0;
+sub incr { $count++; return $count; }
+incr(); incr();
+print "$count\n";
+{
+    my $id = 100;
+    sub next_id { return ++$id; }
+}
+print next_id(), " ", next_id(), "\n";`,
+			ExpectedOutput: "56107\n2\n101 102",
+		},
+		{
+			Name: "callbacks",
+			Code: `my $total = 0;
+sub each_item { my ($cb, @items) = @_; foreach my $item (@items) { $cb->($item); } }
+each_item(sub { my $v = shift; $total += $v; }, 1, 2, 3);
+print "$total\n";
+my @log;
+my %handlers = (add => sub { push @log, "add $_[0]"; }, del => sub { push @log, "del $_[0]"; });
+sub dispatch { my ($op, $arg) = @_; $handlers{$op}->($arg); }
+dispatch("add", 1); dispatch("del", 2);
+print join(";", @log), "\n";`,
+			ExpectedOutput: "6\nadd 1;del 2",
+		},
+		{
+			Name: "each closure sees its own lexicals",
+			Code: `my @subs;
+foreach my $i (1..3) { my $f = sub { return $i * 10; }; push @subs, $f; }
+print join(",", map { $_->() } @subs), "\n";
+sub make_acc { my $sum = 0; my $add = sub { $sum += shift; return $sum; }; my $get = sub { return $sum; }; return ($add, $get); }
+my ($add, $get) = make_acc();
+$add->(3); $add->(4);
+my $n = 1;
+my $show = sub { return $n; };
+$n = 5;
+print $get->(), " ", $show->(), "\n";`,
+			ExpectedOutput: "10,20,30\n7 5",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestCacheFlag(t *testing.T) {
+	// the first run parses and saves job.plc, the second runs from it, a
+	// changed script is parsed again
+	dir := t.TempDir()
+	script := filepath.Join(dir, "job.pl")
+	run := func(src, want string) {
+		t.Helper()
+		if err := os.WriteFile(script, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("./perlc", "-cache", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("perlc -cache: %v\n%s", err, out)
+		}
+		if string(out) != want {
+			t.Errorf("perlc -cache = %q, want %q", out, want)
+		}
+	}
+	src := "my @w = qw(a b a);\nmy %seen;\nforeach my $w (@w) { $seen{$w}++; }\nmy @k = sort keys %seen;\nprint \"@k $seen{a}\\n\";\n"
+	run(src, "a b 2\n")
+	if _, err := os.Stat(filepath.Join(dir, "job.plc")); err != nil {
+		t.Fatalf("no cache file: %v", err)
+	}
+	run(src, "a b 2\n")
+	run(src+"print \"done\\n\";\n", "a b 2\ndone\n")
+}
+
+func TestReplEcho(t *testing.T) {
+	// the REPL shows the value of each expression line in Data::Dumper
+	// form; :depth limits the nesting
+	cmd := exec.Command("./perlc")
+	cmd.Stdin = strings.NewReader("my @a = (1, [2, 'x']);\n\\@a\nprint \"hi\\n\";\n:depth 1\n[[3]]\nexit\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("perlc: %v\n%s", err, out)
+	}
+	got := strings.ReplaceAll(string(out), "perl> ", "")
+	want := "[\n  1,\n  [\n    2,\n    'x'\n  ]\n]\nhi\n[\n  'ARRAY(0x"
+	if i := strings.Index(got, "\n"); i < 0 || !strings.HasPrefix(got[i+1:], want) {
+		t.Errorf("REPL output:\n%s\nwant after the banner:\n%s...", got, want)
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	out, err := exec.Command("./perlc", "--version").CombinedOutput()
+	if err != nil {
+		t.Fatalf("perlc --version: %v\n%s", err, out)
+	}
+	checkOutput(t, "version", "interp", string(out), "", `^perlc \S+ \(commit \S+, go\S+ \S+/\S+\)\nPerl feature level 5\.36\.0 \(\$\^V v5\.36\.0, \$\] 5\.036000\)$`)
+}
+
 // ============================================================
 // Main test runner
 // ============================================================
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================
+// --perl-compat-numbers: differential tests against perl
+// ============================================================
+
+// compatNumberScripts run under the system perl and under perlc
+// --perl-compat-numbers, interpreted and compiled; all three must print
+// the same, on stdout and on stderr.
+var compatNumberScripts = []struct {
+	Name string
+	Code string
+}{
+	{
+		Name: "modulo takes the sign of the right operand",
+		Code: `my @r = (13 % 4, -13 % 4, 13 % -4, -13 % -4, -7 % 3, 7 % -3, 0 % -5, -9223372036854775807 % 10);
+print "@r\n";
+my @v = (7, -7, 7.5, -7.5, 13.9, -13.9, 0.5, "12abc", 1e20, -1e20);
+my @w = (7, -3, 2.5, 1e20, "4");
+foreach my $p (@v) {
+    my @out;
+    foreach my $q (@w) { push @out, $p % $q }
+    print "@out\n";
+}
+my @big = (2**64 % 10, 1e20 % 7, -1e20 % 7, 7 % 1e20, 1e20 % 2.5);
+print "@big\n";`,
+	},
+	{
+		Name: "division keeps exact integers",
+		Code: `my @r = (7 / 2, -7 / 2, 10 / 4, 1 / 3, -1 / 3, 2 / 3 * 3, 1 / 0.1, 1e300 * 1e10, -1e300 * 1e10);
+print "@r\n";
+my @e = (9007199254740993 / 1, 9007199254740992 / 2, 18014398509481985 / 5, -18014398509481985 / 5, 9223372036854775807 / 7);
+print "@e\n";`,
+	},
+	{
+		Name: "powers are integers while they fit",
+		Code: `my @e = (0, 1, 2, 10, 20, 32, 33, 40, 63, 64, -1, -5, 0.5);
+foreach my $q (@e) {
+    my @r = (2**$q, 3**$q, (-3)**$q, 7**$q, 10**$q, 0.1**$q, 1.5**$q);
+    print "@r\n";
+}
+my @s = ((-7)**21, 15**16, 0**0, 4**0.5);
+print "@s\n";`,
+	},
+	{
+		Name: "integer overflow goes on in doubles",
+		Code: `my $m = -9223372036854775807;
+my @r = ($m - 10, $m * 2, $m * 3, 3037000500 * -3037000500, $m + -9223372036854775807);
+print "@r\n";
+my $x = 2**50;
+my @s = ($x + 0, $x * 1, $x + 1, -$x, 1e15, 1e15 + 0.5, 123456789012345678 + 1);
+print "@s\n";`,
+	},
+	{
+		Name: "unsigned integers past int64",
+		Code: `my @r = (9223372036854775807 + 1, 9223372036854775807 * 2, 4294967296 * 4294967295, 9223372036854775808 - 1);
+print "@r\n";
+my @s = (~0, ~0 - 1, ~5, ~0 % 10, ~0 / 5, ~0 + 1, -9223372036854775808 - 1, 18446744073709551615);
+print "@s\n";
+my $x = 9223372036854775807; $x++; print "$x\n";
+my $y = ~0; $y--; print "$y\n";
+print ~0 > 9223372036854775807 ? "larger\n" : "smaller\n";`,
+	},
+	{
+		Name: "negative zero",
+		Code: `my $z = 0.0;
+my $n = -$z;
+my $t = -1e-200 * 1e-200;
+my @r = ($n, 1.5 * 0 * -1, "-0.0" + 0, $t, abs($t), int(-0.5), -0.5 % 3);
+print "@r\n";
+printf("%g %.1f %s %d\n", $t, $t, $t, $t);`,
+	},
+	{
+		Name: "compound assignment",
+		Code: `my $x = 10; $x /= 4; print "$x\n";
+$x = -10; $x %= 3; print "$x\n";
+$x = 3; $x **= 33; print "$x\n";
+my @a = (17); $a[0] %= -5; print "$a[0]\n";`,
+	},
+	{
+		Name: "division by zero dies where it is",
+		Code: `my $z = 0;
+eval { my $r = 1 / $z }; print "e: $@";
+eval { my $r = 1 % $z }; print "e: $@";
+eval { my $r = 1 % 0.5 }; print "e: $@";
+my $r = 1 / $z;
+print "not reached\n";`,
+	},
+}
+
+// runCommand runs name with args and returns its stdout and stderr
+func runCommand(t *testing.T, name string, args ...string) (string, string) {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, exited := err.(*exec.ExitError); !exited {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestPerlCompatNumbers(t *testing.T) {
+	perl, err := exec.LookPath("perl")
+	if err != nil {
+		t.Skip("no perl in PATH to compare with")
+	}
+	exeName := "./perlc"
+	if os.PathSeparator == '\\' {
+		exeName = "./perlc.exe"
+	}
+	for _, tc := range compatNumberScripts {
+		t.Run(tc.Name, func(t *testing.T) {
+			// one file for all runs, so that die messages name the same one
+			file := filepath.Join(t.TempDir(), "numbers.pl")
+			if err := os.WriteFile(file, []byte(tc.Code), 0644); err != nil {
+				t.Fatal(err)
+			}
+			wantOut, wantErr := runCommand(t, perl, file)
+
+			gotOut, gotErr := runCommand(t, exeName, "--perl-compat-numbers", file)
+			if gotOut != wantOut || gotErr != wantErr {
+				t.Errorf("[interpret] perl printed:\n%s%s\nperlc printed:\n%s%s", wantOut, wantErr, gotOut, gotErr)
+			}
+
+			gotOut, gotErr = runCommand(t, exeName, "--perl-compat-numbers", "-r", file)
+			if idx := strings.Index(gotOut, "---\n"); idx != -1 {
+				gotOut = gotOut[idx+4:]
+			}
+			os.Remove("numbers")
+			os.Remove("numbers.exe")
+			if gotOut != wantOut || gotErr != wantErr {
+				t.Errorf("[compile] perl printed:\n%s%s\nperlc printed:\n%s%s", wantOut, wantErr, gotOut, gotErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ============================================================
+// -packages: a Go package per Perl package
+// ============================================================
+
+const packagesScript = `package Counter;
+our $count = 0;
+sub new { my ($class, %args) = @_; return bless { n => $args{start} // 0 }, $class; }
+sub inc { my $self = shift; $self->{n} += 2; $count++; return $self->{n}; }
+sub total { return $count; }
+package Util;
+sub double { return $_[0] * 2; }
+sub report { return "total " . Counter::total(); }
+package main;
+sub show { print join(" ", @_), "\n"; }
+my $c = Counter->new(start => 1);
+$c->inc(); $c->inc(); $c->inc();
+show(Util::double($c->{n}), Util::report());
+`
+
+func TestPackages(t *testing.T) {
+	exeName := "./perlc"
+	if os.PathSeparator == '\\' {
+		exeName = "./perlc.exe"
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "counter.pl")
+	if err := os.WriteFile(file, []byte(packagesScript), 0644); err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Join(dir, "module")
+	out, stderr := runCommand(t, exeName, "-packages", module, "-o", filepath.Join(dir, "counter"), "-r", file)
+	if idx := strings.Index(out, "---\n"); idx != -1 {
+		out = out[idx+4:]
+	}
+	if out != "14 total 3\n" {
+		t.Fatalf("compiled program printed %q, stderr:\n%s", out, stderr)
+	}
+	for _, pkg := range []string{"main.go", "pl/counter/counter.go", "pl/util/util.go", "pl/program/program.go"} {
+		if _, err := os.Stat(filepath.Join(module, pkg)); err != nil {
+			t.Errorf("%s not written: %v", pkg, err)
+		}
+	}
+
+	// hand-written Go calls the subs through their wrappers
+	user := filepath.Join(module, "cmd", "user")
+	if err := os.MkdirAll(user, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := `package main
+
+import (
+	"fmt"
+
+	"perlc/pkg/perlrt"
+	"perlc/pl/counter"
+	"perlc/pl/util"
+)
+
+func main() {
+	c := counter.New(perlrt.SvStr("Counter"), perlrt.SvStr("start"), perlrt.SvInt(5))
+	perlrt.Perl_method_call(c, "inc")
+	fmt.Println(util.Double(perlrt.SvInt(21)).AsString(), util.Report().AsString())
+}
+`
+	if err := os.WriteFile(filepath.Join(user, "main.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", "./cmd/user")
+	cmd.Dir = module
+	got, err := cmd.CombinedOutput()
+	if err != nil || string(got) != "42 total 1\n" {
+		t.Errorf("go run printed %q (%v)", got, err)
+	}
+}
@@ -0,0 +1,394 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// ============================================================
+// Process Control Tests
+// ============================================================
+
+func TestProcessSystem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	tests := []TestCase{
+		{
+			Name: "system sees %ENV changes",
+			Code: `$ENV{PERLC_GREETING} = "hello from env";
+system('echo "$PERLC_GREETING"');`,
+			ExpectedOutput: "hello from env",
+		},
+		{
+			Name: "system list form sets $?",
+			Code: `system("sh", "-c", "exit 3");
+say $? >> 8;`,
+			ExpectedOutput: "3",
+		},
+		{
+			Name: "backticks capture stdout and interpolate",
+			Code: `my $who = "world";
+my $out = ` + "`echo hello $who`" + `;
+print $out;
+say "status $?";`,
+			ExpectedOutput: "hello world\nstatus 0",
+		},
+		{
+			Name: "qx in list context splits on $/",
+			Code: `my @lines = qx{printf 'a\nb\nc\n'};
+say scalar(@lines);
+print $lines[1];
+foreach my $l (qx(printf 'x:y:')) { say $l }
+{
+    local $/ = ":";
+    my @f = qx(printf 'x:y:');
+    say scalar(@f);
+}`,
+			ExpectedOutput: "3\nb\nx:y:\n2",
+		},
+		{
+			Name: "qx with single quotes does not interpolate",
+			Code: `my $x = "perl";
+print qx'echo "$x" @x';`,
+			ExpectedOutput: " @x",
+		},
+		{
+			Name: "backticks set $? from the exit status",
+			Code: "my $out = `sh -c 'echo partial; exit 4'`;\nprint $out;\nsay $? >> 8;",
+			ExpectedOutput: "partial\n4",
+		},
+		{
+			Name: "$? of a child killed by a signal",
+			Code: `system("sh", "-c", 'kill -TERM $$');
+say "signal ", $? & 127, " exit ", $? >> 8;`,
+			ExpectedOutput: "signal 15 exit 0",
+		},
+		{
+			Name: "exec flushes output and replaces the program",
+			Code: `print "before\n";
+exec("sh", "-c", "echo replaced; exit 0");
+print "not reached\n";`,
+			ExpectedOutput: "before\nreplaced",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestProcessGlobals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	tests := []TestCase{
+		{
+			Name: "%ENV writes and deletes reach the process environment",
+			Code: `$ENV{PERLC_LIVE} = "one";
+$ENV{PERLC_LIVE} .= "two";
+system('echo "$PERLC_LIVE"');
+delete $ENV{PERLC_LIVE};
+system('echo "${PERLC_LIVE:-unset}"');
+my %copy = %ENV;
+$copy{PERLC_FILL} = "filled";
+%ENV = %copy;
+system('echo "$PERLC_FILL"');`,
+			ExpectedOutput: "onetwo\nunset\nfilled",
+		},
+		{
+			Name: "@ARGV, @INC and %INC",
+			Code: `use strict;
+use lib 'mylib', 'other';
+eval { require Foo::Bar; };
+print $@ =~ /^Can't locate Foo\/Bar.pm in \@INC \(you may need to install the Foo::Bar module\) \(\@INC contains: mylib other / ? "missing\n" : $@;
+eval { require "helper.pl"; };
+print $@ =~ /^Can't locate helper.pl in \@INC \(\@INC/ ? "missing\n" : $@;
+print scalar(@ARGV), "\n";
+print "$INC[0] $INC[1]\n";
+my @found = grep { $_ eq "lib" } @INC;
+print scalar(@found), "\n";
+print join(",", sort keys %INC), "\n";
+print "$INC{'strict.pm'}\n";`,
+			ExpectedOutput: "missing\nmissing\n0\nmylib other\n1\nlib.pm,strict.pm\nstrict.pm",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestProcessOpen3(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	tests := []TestCase{
+		{
+			Name: "open3 with separate stderr",
+			Code: `use IPC::Open3;
+use Symbol 'gensym';
+my $pid = open3(my $in, my $out, my $err = gensym, "sh", "-c", "cat; echo oops 1>&2; exit 2");
+print $in "line one\n";
+close($in);
+my $got = <$out>;
+my $e = <$err>;
+waitpid($pid, 0);
+print "out: $got";
+print "err: $e";
+say $? >> 8;`,
+			ExpectedOutput: "out: line one\nerr: oops\n2",
+		},
+		{
+			Name: "open3 merges stderr when undef",
+			Code: `my $pid = open3(my $in, my $out, undef, "sh", "-c", "echo oops 1>&2");
+close($in);
+my $got = <$out>;
+waitpid($pid, 0);
+print "merged: $got";`,
+			ExpectedOutput: "merged: oops",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestUserLookup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uid 0 is unix-only")
+	}
+	tests := []TestCase{
+		{
+			Name: "getpwuid and getpwnam round trip",
+			Code: `my $name = getpwuid(0);
+my @pw = getpwnam($name);
+say "uid=$pw[2]";
+my $uid = getpwnam($name);
+say "scalar=$uid";`,
+			ExpectedOutput: "uid=0\nscalar=0",
+		},
+		{
+			Name: "unknown user gives empty list",
+			Code: `my @pw = getpwnam("perlc_no_such_user");
+say scalar(@pw);`,
+			ExpectedOutput: "0",
+		},
+		{
+			Name: "getgrgid returns group entry",
+			Code: `my @gr = getgrgid(0);
+my $gid = getgrnam($gr[0]);
+say "gid=$gid";`,
+			ExpectedOutput: "gid=0",
+		},
+		{
+			Name: "user lookups without parentheses",
+			Code: `my $name = getpwuid 0;
+my @pw = getpwnam $name;
+say "uid=$pw[2]";
+my $uid = getpwnam $name;
+say "scalar=$uid";
+my @gr = getgrgid 0;
+my $gid = getgrnam $gr[0];
+say "gid=$gid";
+say "root" if getpwnam $name == 0;`,
+			ExpectedOutput: "uid=0\nscalar=0\ngid=0\nroot",
+		},
+		{
+			Name: "hostname matches Sys::Hostname::hostname",
+			Code: `use Sys::Hostname;
+my $host = hostname;
+say "ok" if length($host) > 0 && $host eq Sys::Hostname::hostname();`,
+			ExpectedOutput: "ok",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestProcessReaping(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	tests := []TestCase{
+		{
+			Name: "waitpid WNOHANG and wait",
+			Code: `use POSIX ":sys_wait_h";
+my $reaped = 0;
+$SIG{CHLD} = sub { $reaped++; };
+my $pid = open3(my $in, my $out, undef, "sh", "-c", "cat >/dev/null; exit 4");
+my $r = waitpid($pid, WNOHANG);
+say "running=$r";
+close($in);
+my $got = wait;
+my $same = $got == $pid ? "yes" : "no";
+say "same=$same";
+my $status = $? >> 8;
+say "status=$status";
+say "reaped=$reaped";
+my $none = wait;
+say "none=$none";`,
+			ExpectedOutput: "running=0\nsame=yes\nstatus=4\nreaped=1\nnone=-1",
+		},
+		{
+			Name: "waitpid without parentheses",
+			Code: `use POSIX ":sys_wait_h";
+my $pid = open3(my $in, my $out, undef, "sh", "-c", "cat >/dev/null; exit 3");
+my $r = waitpid -1, WNOHANG;
+say "running=$r";
+close($in);
+my $got = waitpid $pid, 0;
+my $same = $got == $pid ? "yes" : "no";
+say "same=$same status=", $? >> 8;`,
+			ExpectedOutput: "running=0\nsame=yes status=3",
+		},
+		{
+			Name: "sleep is interrupted by SIGCHLD",
+			Code: `my $done = 0;
+$SIG{CHLD} = sub { $done++; };
+my $pid = open3(my $in, my $out, undef, "sh", "-c", "exit 0");
+close($in);
+my $slept = sleep(10);
+say "slept=$slept done=$done";`,
+			ExpectedOutput: "slept=0 done=1",
+		},
+		{
+			Name: "CHLD IGNORE reaps children automatically",
+			Code: `$SIG{CHLD} = 'IGNORE';
+my $pid = open3(my $in, my $out, undef, "sh", "-c", "exit 0");
+close($in);
+sleep(10);
+my $r = wait;
+say $r;`,
+			ExpectedOutput: "-1",
+		},
+		{
+			Name: "CHLD handler given by sub name",
+			Code: `my $reaped = 0;
+sub reaper { $reaped++; }
+$SIG{CHLD} = 'reaper';
+my $pid = open3(my $in, my $out, undef, "sh", "-c", "exit 0");
+close($in);
+waitpid($pid, 0);
+say "reaped=$reaped";`,
+			ExpectedOutput: "reaped=1",
+			// compiled subs cannot see file lexicals yet
+			SkipCompile: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}
+
+func TestAlarmEmptyLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGALRM")
+	}
+	// a loop without statements still delivers the signal: the default
+	// ALRM ends the program instead of spinning forever
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alarm.pl")
+	if err := os.WriteFile(path, []byte("alarm 1;\nwhile (1) { }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "alarm")
+	if out, err := exec.Command("./perlc", "-c", "-o", exe, path).CombinedOutput(); err != nil {
+		t.Fatalf("compile: %v\n%s", err, out)
+	}
+
+	for mode, args := range map[string][]string{"INTERP": {"./perlc", path}, "COMPILE": {exe}} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if ctx.Err() != nil {
+			t.Errorf("[%s] alarm did not end the loop", mode)
+		} else if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 142 {
+			t.Errorf("[%s] expected exit status 142, got %v", mode, err)
+		}
+		checkOutput(t, "alarm in an empty loop", mode, stderr.String(), "Alarm clock", "")
+		cancel()
+	}
+}
+
+func TestAlarmTimeout(t *testing.T) {
+	tests := []TestCase{
+		{
+			Name: "alarm interrupts a busy loop",
+			Code: `my $n = 0;
+my $ok = eval {
+    local $SIG{ALRM} = sub { die "timeout\n" };
+    alarm 1;
+    while (1) { $n++; }
+    alarm 0;
+    1;
+};
+print "ok=", defined($ok) ? $ok : "undef", "\n";
+print "err=", $@;
+say defined($SIG{ALRM}) ? "handler kept" : "handler restored";`,
+			ExpectedOutput: "ok=undef\nerr=timeout\nhandler restored",
+		},
+		{
+			Name: "alarm 0 cancels before it fires",
+			Code: `$SIG{ALRM} = sub { say "outer handler"; };
+my $v = eval {
+    local $SIG{ALRM} = sub { die "timeout\n" };
+    alarm 5;
+    my $left = alarm 0;
+    "left=$left";
+};
+say $v;
+print "err=[", $@, "]\n";
+say ref($SIG{ALRM});`,
+			ExpectedOutput: "left=5\nerr=[]\nCODE",
+		},
+		{
+			Name: "alarm interrupts sleep",
+			Code: `my $start = time;
+eval {
+    local $SIG{ALRM} = sub { die "slow\n" };
+    alarm 1;
+    sleep 10;
+    alarm 0;
+};
+print "err=", $@;
+say time() - $start < 5 ? "fast" : "slow";`,
+			ExpectedOutput: "err=slow\nfast",
+		},
+		{
+			Name: "die in a sub is caught by eval",
+			Code: `sub risky { die "deep\n"; }
+eval { risky(); say "not reached"; };
+print "caught ", $@;`,
+			ExpectedOutput: "caught deep",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			runTest(t, tc)
+		})
+	}
+}